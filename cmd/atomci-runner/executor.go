@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/runner"
+)
+
+const (
+	// reportInterval the interval to report the log and receive the abort request
+	reportInterval = 2 * time.Second
+	// callbackAttempts the callback is retried like the callback stage of jenkins pipeline
+	callbackAttempts = 5
+)
+
+// executor run the steps of task in docker containers one by one, the workspace of task is mounted into all of them
+type executor struct {
+	client *runner.Client
+	opts   *options
+}
+
+// taskLog the output of steps not reported yet
+type taskLog struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *taskLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// take return the output and reset the buffer
+func (l *taskLog) take() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	text := l.buf.String()
+	l.buf.Reset()
+	return text
+}
+
+// restore put back the output failed to report before the following output
+func (l *taskLog) restore(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rest := l.buf.String()
+	l.buf.Reset()
+	l.buf.WriteString(text)
+	l.buf.WriteString(rest)
+}
+
+func (e *executor) run(task *runner.Task) {
+	log.Printf("run task %v of job %v", task.ID, task.JobName)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	output := &taskLog{}
+	var finished int32
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(reportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if e.report(task.ID, output, int(atomic.LoadInt32(&finished))) {
+					cancel()
+				}
+			}
+		}
+	}()
+
+	workspace := filepath.Join(e.opts.workDir, fmt.Sprint(task.ID))
+	status := e.runSteps(ctx, task, workspace, output, &finished)
+	if status == runner.StatusSuccess && task.Spec.Callback != nil {
+		if err := callback(task.Spec.Callback); err != nil {
+			fmt.Fprintf(output, "callback error: %v\n", err)
+			status = runner.StatusFailure
+		}
+	}
+	if err := os.RemoveAll(workspace); err != nil {
+		log.Printf("remove workspace %v error: %v", workspace, err)
+	}
+	close(done)
+	wg.Wait()
+	fmt.Fprintf(output, "Finished: %v\n", status)
+	e.report(task.ID, output, int(finished))
+	if err := e.client.ReportStatus(task.ID, &runner.StatusReq{Status: status}); err != nil {
+		log.Printf("report status of task %v error: %v", task.ID, err)
+	}
+	log.Printf("task %v finished: %v", task.ID, status)
+}
+
+// report send the output to AtomCI, return true when the task is requested to abort
+func (e *executor) report(taskID int64, output *taskLog, finished int) bool {
+	text := output.take()
+	rsp, err := e.client.AppendLog(taskID, &runner.LogReq{Text: text, FinishedSteps: finished})
+	if err != nil {
+		log.Printf("report log of task %v error: %v", taskID, err)
+		output.restore(text)
+		return false
+	}
+	return rsp.Abort
+}
+
+// runSteps return the status of task
+func (e *executor) runSteps(ctx context.Context, task *runner.Task, workspace string, output *taskLog, finished *int32) string {
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		fmt.Fprintf(output, "create workspace error: %v\n", err)
+		return runner.StatusFailure
+	}
+	for i, step := range task.Spec.Steps {
+		if ctx.Err() != nil {
+			return runner.StatusAborted
+		}
+		fmt.Fprintf(output, "[%v] step %v/%v\n", step.Stage, i+1, len(task.Spec.Steps))
+		name := fmt.Sprintf("atomci-runner-%v-%v", task.ID, i+1)
+		err := e.runStep(ctx, name, task.Spec, step, workspace, output)
+		if ctx.Err() != nil {
+			fmt.Fprintf(output, "aborted\n")
+			return runner.StatusAborted
+		}
+		if err != nil {
+			fmt.Fprintf(output, "step failed: %v\n", err)
+			return runner.StatusFailure
+		}
+		atomic.StoreInt32(finished, int32(i+1))
+	}
+	return runner.StatusSuccess
+}
+
+// runStep run the step in container, the container is removed when the task is aborted
+func (e *executor) runStep(ctx context.Context, name string, spec *runner.Spec, step runner.Step, workspace string, output *taskLog) error {
+	cmd := exec.Command(e.opts.docker, dockerArgs(name, spec, step, workspace, e.opts.defaultImage)...)
+	// the values are passed by the env of docker command, which are not shown in the process list
+	cmd.Env = os.Environ()
+	for key, value := range spec.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	result := make(chan error, 1)
+	go func() { result <- cmd.Wait() }()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		if err := exec.Command(e.opts.docker, "rm", "-f", name).Run(); err != nil {
+			log.Printf("remove container %v error: %v", name, err)
+		}
+		<-result
+		return ctx.Err()
+	}
+}
+
+// dockerArgs the args of docker run, the script is run by `sh -xe` like the sh step of jenkins
+func dockerArgs(name string, spec *runner.Spec, step runner.Step, workspace, defaultImage string) []string {
+	image, shell := defaultImage, "sh"
+	if container, ok := spec.Containers[step.Container]; ok && step.Container != runner.DefaultContainer {
+		image, shell = container.Image, container.Shell
+	}
+	args := []string{"run", "--rm", "--name", name, "-v", workspace + ":" + spec.Workspace, "-w", spec.Workspace}
+	keys := make([]string, 0, len(spec.Env))
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", key)
+	}
+	return append(args, "--entrypoint", shell, image, "-xe", "-c", step.Script)
+}
+
+// callback notify AtomCI the task succeeded
+func callback(request *runner.Callback) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var err error
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		if err = sendCallback(client, request); err == nil {
+			return nil
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	return err
+}
+
+func sendCallback(client *http.Client, request *runner.Callback) error {
+	req, err := http.NewRequest(http.MethodPost, request.URL, strings.NewReader(request.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+request.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback response status: %v", resp.Status)
+	}
+	return nil
+}
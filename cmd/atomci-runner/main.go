@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// atomci-runner is the self hosted runner of AtomCI, which runs the build steps in local docker without jenkins.
+//
+//	atomci-runner --server https://atomci.example.com --registration-token xxx --name runner-1
+//
+// The runner registers with the registration token once and saves its runner token into the config file,
+// then claims the tasks queued for the ci servers whose driver is runner.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/runner"
+	"github.com/go-atomci/atomci/version"
+)
+
+// config the runner registered, which is saved after register
+type config struct {
+	Server string `json:"server"`
+	ID     int64  `json:"id"`
+	Token  string `json:"token"`
+}
+
+// options the flags of runner
+type options struct {
+	server            string
+	registrationToken string
+	name              string
+	configPath        string
+	workDir           string
+	defaultImage      string
+	docker            string
+	pollInterval      time.Duration
+	heartbeatInterval time.Duration
+}
+
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "atomci-runner.json"
+	}
+	return filepath.Join(home, ".atomci", "runner.json")
+}
+
+func parseOptions(args []string) (*options, error) {
+	hostname, _ := os.Hostname()
+	opts := &options{}
+	fs := flag.NewFlagSet("atomci-runner", flag.ContinueOnError)
+	fs.StringVar(&opts.server, "server", os.Getenv("ATOMCI_SERVER"), "the address of AtomCI")
+	fs.StringVar(&opts.registrationToken, "registration-token", os.Getenv("ATOMCI_RUNNER_REGISTRATION_TOKEN"), "the registration token configured in AtomCI, only used by the first run")
+	fs.StringVar(&opts.name, "name", hostname, "the name of runner")
+	fs.StringVar(&opts.configPath, "config", defaultConfigPath(), "the config file saving the runner token")
+	fs.StringVar(&opts.workDir, "work-dir", "atomci-runner-work", "the directory of task workspaces")
+	fs.StringVar(&opts.defaultImage, "default-image", "alpine/git:latest", "the image of the steps without container, which needs git and sh")
+	fs.StringVar(&opts.docker, "docker", "docker", "the docker command")
+	fs.DurationVar(&opts.pollInterval, "poll-interval", 5*time.Second, "the interval to claim tasks when idle")
+	fs.DurationVar(&opts.heartbeatInterval, "heartbeat-interval", 15*time.Second, "the interval to send heartbeats")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	workDir, err := filepath.Abs(opts.workDir)
+	if err != nil {
+		return nil, err
+	}
+	opts.workDir = workDir
+	return opts, nil
+}
+
+// loadConfig register the runner when the config file of server is absent
+func loadConfig(opts *options) (*config, error) {
+	cfg := &config{}
+	content, err := ioutil.ReadFile(opts.configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", opts.configPath, err)
+		}
+	}
+	if opts.server == "" {
+		opts.server = cfg.Server
+	}
+	if opts.server == "" {
+		return nil, fmt.Errorf("the server is required")
+	}
+	if cfg.Token != "" && cfg.Server == opts.server {
+		return cfg, nil
+	}
+	if opts.registrationToken == "" {
+		return nil, fmt.Errorf("the runner is not registered, the registration token is required")
+	}
+	rsp, err := runner.NewClient(opts.server, "").Register(&runner.RegisterReq{Token: opts.registrationToken, Name: opts.name, Version: version.GetVersion()})
+	if err != nil {
+		return nil, fmt.Errorf("register runner: %v", err)
+	}
+	cfg = &config{Server: opts.server, ID: rsp.ID, Token: rsp.Token}
+	// the token is secret, so the file is only readable by the owner
+	if err := os.MkdirAll(filepath.Dir(opts.configPath), 0700); err != nil {
+		return nil, err
+	}
+	if content, err = json.MarshalIndent(cfg, "", "  "); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(opts.configPath, content, 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("runner %v registered, id: %v", opts.name, rsp.ID)
+	return cfg, nil
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	client := runner.NewClient(cfg.Server, cfg.Token)
+	go heartbeat(client, opts.heartbeatInterval)
+	executor := &executor{client: client, opts: opts}
+	log.Printf("runner %v is waiting for tasks from %v", opts.name, cfg.Server)
+	for {
+		task, err := client.Claim()
+		if err != nil {
+			log.Printf("claim task error: %v", err)
+		}
+		if task == nil {
+			time.Sleep(opts.pollInterval)
+			continue
+		}
+		executor.run(task)
+	}
+}
+
+// heartbeat keep the runner online
+func heartbeat(client *runner.Client, interval time.Duration) {
+	for {
+		if err := client.Heartbeat(&runner.HeartbeatReq{Version: version.GetVersion()}); err != nil {
+			log.Printf("send heartbeat error: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/pkg/runner"
+)
+
+func TestDockerArgs(t *testing.T) {
+	spec := &runner.Spec{
+		Workspace:  "/workspace",
+		Env:        map[string]string{"REGISTRY_ADDR": "harbor.example.com", "DOCKER_AUTH": "secret"},
+		Containers: map[string]runner.Container{"kaniko": {Image: "gcr.io/kaniko-project/executor:debug", Shell: "/busybox/sh"}},
+	}
+	args := dockerArgs("atomci-runner-1-1", spec, runner.Step{Container: "kaniko", Script: "/kaniko/executor"}, "/data/1", "alpine/git")
+	expected := []string{"run", "--rm", "--name", "atomci-runner-1-1", "-v", "/data/1:/workspace", "-w", "/workspace",
+		"-e", "DOCKER_AUTH", "-e", "REGISTRY_ADDR", "--entrypoint", "/busybox/sh", "gcr.io/kaniko-project/executor:debug", "-xe", "-c", "/kaniko/executor"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("unexpected args: %v", args)
+	}
+	args = dockerArgs("atomci-runner-1-2", spec, runner.Step{Script: "git log -1"}, "/data/1", "alpine/git")
+	if image := args[len(args)-4]; image != "alpine/git" || args[len(args)-5] != "sh" {
+		t.Fatalf("the step without container should run in the default image: %v", args)
+	}
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
+	"os"
 	"runtime"
+	"sync/atomic"
 
 	"github.com/astaxie/beego"
 	_ "github.com/go-sql-driver/mysql" // import your used driver
@@ -32,16 +35,46 @@ import (
 )
 
 func main() {
+	// `atomci serve` is the same as `atomci`, which runs the server
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		models.InitDB()
+		if err := migrations.RunCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	models.InitDB()
 	migrations.Migrate()
 	// TODO: resource items migrate later
 	initialize.Init()
 
+	// the jobs in flight when the server stopped are recovered before the background loops
+	cronjob.RecoverInFlightJobs()
 	cronjob.RunPublishJobServer()
+	cronjob.RunJobCallbackServer()
+	cronjob.RunStepTaskServer()
+	cronjob.RunJobWatchdogServer()
+	cronjob.RunDeployHealthCheckServer()
+	cronjob.RunJobQueueServer()
+	cronjob.RunImageRetentionServer()
+	cronjob.RunArchiveServer()
+	cronjob.RunPublishSLAServer()
+	cronjob.RunIntegrateCheckServer()
+	cronjob.RunPreviewServer()
+	cronjob.RunMetricsServer()
 
 	routers.RegisterRoutes()
 	beego.Info("Beego version:", beego.VERSION)
 	beego.Info("Golang version:", runtime.Version())
 	version.PrintFullVersionInfo()
+	go handleSignals()
 	beego.Run()
+	// the http server was closed by graceful shutdown, which exits after the background tasks finished
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		select {}
+	}
 }
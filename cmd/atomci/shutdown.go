@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/go-atomci/atomci/internal/cronjob"
+)
+
+// shuttingDown is set when the graceful shutdown started, the main goroutine waits for it then
+var shuttingDown int32
+
+// handleSignals shutdown the server gracefully on SIGINT/SIGTERM, the in-flight requests and
+// background tasks are finished before exit, the jobs still running are recovered at next startup
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	atomic.StoreInt32(&shuttingDown, 1)
+	timeout := time.Duration(beego.AppConfig.DefaultInt("shutdown_timeout", 30)) * time.Second
+	beego.Info("Received signal:", sig, "shutdown gracefully, timeout:", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := beego.BeeApp.Server.Shutdown(ctx); err != nil {
+		beego.Warn("Shutdown http server occur error:", err)
+	}
+	if !cronjob.Shutdown(timeout) {
+		beego.Warn("Background tasks did not finish in", timeout)
+	}
+	beego.Info("Server stopped")
+	os.Exit(0)
+}
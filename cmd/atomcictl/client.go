@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/sdk"
+)
+
+// v1BasePath the path prefix of api v1, which is used by the operations not in api v2
+const v1BasePath = "/atomci/api/v1"
+
+// config the server and the access token saved by login, ATOMCI_SERVER and ATOMCI_TOKEN take precedence
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	User   string `json:"user,omitempty"`
+}
+
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".atomci.json"
+	}
+	return filepath.Join(home, ".atomci", "config.json")
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config file %v: %v", path, err)
+		}
+	}
+	if server := os.Getenv("ATOMCI_SERVER"); server != "" {
+		cfg.Server = server
+	}
+	if token := os.Getenv("ATOMCI_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	return cfg, nil
+}
+
+// saveConfig the token is secret, so the file is only readable by the owner
+func saveConfig(path string, cfg *config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// client the client of api v2 sdk and api v1
+type client struct {
+	*sdk.Client
+}
+
+func newClient(ctx *cmdContext) (*client, error) {
+	cfg, err := loadConfig(ctx.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Server == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("not logged in, run `atomcictl login --server <address> --token <access token>` first")
+	}
+	return &client{Client: sdk.NewClient(cfg.Server, cfg.Token)}, nil
+}
+
+// v1Result the response of api v1
+type v1Result struct {
+	IsSuccess bool            `json:"IsSuccess"`
+	Data      json.RawMessage `json:"Data"`
+	ErrMsg    string          `json:"ErrMsg"`
+}
+
+// v1 send the request to api v1 and decode the data of result into out
+func (c *client) v1(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+v1BasePath+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	result := v1Result{}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if json.Unmarshal(content, &result) == nil && result.ErrMsg != "" {
+			return fmt.Errorf("%v: %v", resp.Status, result.ErrMsg)
+		}
+		return fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(content)))
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if !result.IsSuccess {
+		return fmt.Errorf("%v", result.ErrMsg)
+	}
+	if out == nil || len(result.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
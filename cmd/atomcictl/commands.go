@@ -0,0 +1,429 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/sdk"
+)
+
+// stringsFlag the repeatable flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func printJSON(ctx *cmdContext, v interface{}) error {
+	encoder := json.NewEncoder(ctx.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func newTable(ctx *cmdContext, header ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(ctx.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	return w
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func requireFlag(name string, value int64) error {
+	if value <= 0 {
+		return fmt.Errorf("flag --%v is required", name)
+	}
+	return nil
+}
+
+func loginFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	server := fs.String("server", "", "the address of AtomCI, e.g. https://atomci.example.com")
+	token := fs.String("token", "", "the personal access token, created on the access token page")
+	return func(ctx *cmdContext, args []string) error {
+		cfg, err := loadConfig(ctx.configPath)
+		if err != nil {
+			return err
+		}
+		if *server != "" {
+			cfg.Server = *server
+		}
+		if *token != "" {
+			cfg.Token = *token
+		}
+		if cfg.Server == "" || cfg.Token == "" {
+			return fmt.Errorf("flag --server and --token are required")
+		}
+		c := &client{Client: sdk.NewClient(cfg.Server, cfg.Token)}
+		user := struct {
+			User string `json:"user"`
+			Name string `json:"name"`
+		}{}
+		if err := c.v1("GET", "/getCurrentUser", nil, &user); err != nil {
+			return fmt.Errorf("login failed: %v", err)
+		}
+		cfg.Server, cfg.User = c.BaseURL, user.User
+		if err := saveConfig(ctx.configPath, cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(ctx.stdout, "Logged in to %v as %v\n", cfg.Server, user.User)
+		return nil
+	}
+}
+
+func projectListFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	limit := fs.Int64("limit", 20, "page size")
+	all := fs.Bool("all", false, "list all the pages")
+	return func(ctx *cmdContext, args []string) error {
+		c, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		projects := []sdk.Project{}
+		opts := &sdk.ListProjectsOptions{Limit: *limit}
+		for {
+			page, err := c.ListProjects(context.Background(), opts)
+			if err != nil {
+				return err
+			}
+			projects = append(projects, page.Items...)
+			if !*all || !page.HasMore {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		if ctx.output == "json" {
+			return printJSON(ctx, projects)
+		}
+		w := newTable(ctx, "ID", "NAME", "OWNER", "CREATED")
+		for _, item := range projects {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", item.ID, item.Name, item.Owner, formatTime(item.CreateAt))
+		}
+		return w.Flush()
+	}
+}
+
+func printPublishes(ctx *cmdContext, publishes []sdk.Publish) error {
+	if ctx.output == "json" {
+		return printJSON(ctx, publishes)
+	}
+	w := newTable(ctx, "ID", "NAME", "VERSION", "STAGE", "STEP", "STATUS", "UPDATED")
+	for _, item := range publishes {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", item.ID, item.Name, item.VersionNo, item.StageName, item.Step, item.Status, formatTime(item.UpdateAt))
+	}
+	return w.Flush()
+}
+
+func publishListFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	projectID := fs.Int64("project", 0, "project id")
+	stageID := fs.Int64("stage", 0, "filter by the current stage id")
+	status := fs.String("status", "", "filter by status, comma separated, e.g. running,failed")
+	limit := fs.Int64("limit", 20, "page size")
+	return func(ctx *cmdContext, args []string) error {
+		if err := requireFlag("project", *projectID); err != nil {
+			return err
+		}
+		c, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		page, err := c.ListPublishes(context.Background(), *projectID, &sdk.ListPublishesOptions{StageID: *stageID, Status: *status, Limit: *limit})
+		if err != nil {
+			return err
+		}
+		return printPublishes(ctx, page.Items)
+	}
+}
+
+func publishCreateFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	projectID := fs.Int64("project", 0, "project id")
+	pipelineID := fs.Int64("pipeline", 0, "the id of pipeline bound to the publish")
+	name := fs.String("name", "", "the name of publish")
+	version := fs.String("version", "", "the version no of publish")
+	apps := stringsFlag{}
+	fs.Var(&apps, "app", "the app of publish as <project app id>:<branch>[:<compile command>], repeatable")
+	approvers := stringsFlag{}
+	fs.Var(&approvers, "approver", "the user allowed to pass the manual steps, repeatable")
+	return func(ctx *cmdContext, args []string) error {
+		if err := requireFlag("project", *projectID); err != nil {
+			return err
+		}
+		if err := requireFlag("pipeline", *pipelineID); err != nil {
+			return err
+		}
+		if *name == "" || *version == "" || len(apps) == 0 {
+			return fmt.Errorf("flag --name, --version and --app are required")
+		}
+		type publishApp struct {
+			AppID          int64  `json:"app_id"`
+			BranchName     string `json:"branch_name"`
+			CompileCommand string `json:"compile_command,omitempty"`
+		}
+		req := struct {
+			Apps           []*publishApp `json:"apps"`
+			Name           string        `json:"name"`
+			BindPipelineID int64         `json:"bind_pipeline_id"`
+			VersionNo      string        `json:"version_no"`
+			Approvers      []string      `json:"approvers"`
+		}{Name: *name, BindPipelineID: *pipelineID, VersionNo: *version, Approvers: approvers}
+		for _, item := range apps {
+			parts := strings.SplitN(item, ":", 3)
+			appID, err := strconv.ParseInt(parts[0], 10, 64)
+			if err != nil || len(parts) < 2 || parts[1] == "" {
+				return fmt.Errorf("invalid --app %q, expect <project app id>:<branch>[:<compile command>]", item)
+			}
+			app := &publishApp{AppID: appID, BranchName: parts[1]}
+			if len(parts) == 3 {
+				app.CompileCommand = parts[2]
+			}
+			req.Apps = append(req.Apps, app)
+		}
+		c, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		if err := c.v1("POST", fmt.Sprintf("/projects/%v/publishes/create", *projectID), req, nil); err != nil {
+			return err
+		}
+		// the create api does not return the publish, find it from the latest publishes
+		page, err := c.ListPublishes(context.Background(), *projectID, &sdk.ListPublishesOptions{Limit: 20})
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			if item.Name == *name && item.VersionNo == *version {
+				return printPublishes(ctx, []sdk.Publish{item})
+			}
+		}
+		fmt.Fprintf(ctx.stdout, "Publish %v %v created\n", *name, *version)
+		return nil
+	}
+}
+
+func buildTriggerFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	projectID := fs.Int64("project", 0, "project id")
+	publishID := fs.Int64("publish", 0, "publish id")
+	stageID := fs.Int64("stage", 0, "stage id, default the current stage of publish")
+	branches := stringsFlag{}
+	fs.Var(&branches, "branch", "build the branch instead of the branch of publish, <branch> for all apps or <project app id>=<branch>, repeatable")
+	return func(ctx *cmdContext, args []string) error {
+		if err := requireFlag("project", *projectID); err != nil {
+			return err
+		}
+		if err := requireFlag("publish", *publishID); err != nil {
+			return err
+		}
+		c, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		if *stageID <= 0 {
+			publish, err := c.GetPublish(context.Background(), *projectID, *publishID)
+			if err != nil {
+				return err
+			}
+			*stageID = publish.StageID
+		}
+		stepPath := fmt.Sprintf("/pipelines/%v/publishes/%v/stages/%v/steps/build", *projectID, *publishID, *stageID)
+		info := struct {
+			Apps []struct {
+				ProjectAppID   int64  `json:"project_app_id"`
+				AppName        string `json:"app_name"`
+				BranchName     string `json:"branch_name"`
+				CompileCommand string `json:"compile_command"`
+			} `json:"apps"`
+		}{}
+		if err := c.v1("GET", stepPath, nil, &info); err != nil {
+			return err
+		}
+		overrides, all := map[int64]string{}, ""
+		for _, item := range branches {
+			parts := strings.SplitN(item, "=", 2)
+			if len(parts) == 1 {
+				all = item
+				continue
+			}
+			appID, err := strconv.ParseInt(parts[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid --branch %q, expect <branch> or <project app id>=<branch>", item)
+			}
+			overrides[appID] = parts[1]
+		}
+		type buildApp struct {
+			Branch         string `json:"branch_name"`
+			CompileCommand string `json:"compile_command"`
+			ProjectAppID   int64  `json:"project_app_id"`
+		}
+		req := struct {
+			ActionName string      `json:"action_name"`
+			Apps       []*buildApp `json:"apps"`
+		}{ActionName: "trigger"}
+		for _, app := range info.Apps {
+			item := &buildApp{Branch: app.BranchName, CompileCommand: app.CompileCommand, ProjectAppID: app.ProjectAppID}
+			if all != "" {
+				item.Branch = all
+			}
+			if branch, ok := overrides[app.ProjectAppID]; ok {
+				item.Branch = branch
+			}
+			req.Apps = append(req.Apps, item)
+		}
+		if len(req.Apps) == 0 {
+			return fmt.Errorf("the publish has no app to build")
+		}
+		if err := c.v1("POST", stepPath, req, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(ctx.stdout, "Build of publish %v triggered, run `atomcictl logs tail --project %v --publish %v --follow` to follow the log\n", *publishID, *projectID, *publishID)
+		return nil
+	}
+}
+
+// jobLog the piece of the console log of job
+type jobLog struct {
+	JobID     int64  `json:"job_id"`
+	Status    string `json:"status"`
+	Text      string `json:"text"`
+	NextStart int64  `json:"next_start"`
+	More      bool   `json:"more"`
+}
+
+func logsTailFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	projectID := fs.Int64("project", 0, "project id")
+	publishID := fs.Int64("publish", 0, "publish id")
+	jobID := fs.Int64("job", 0, "job id, default the latest build or e2e-test job of publish")
+	follow := fs.Bool("follow", false, "keep printing the log until the job finished")
+	interval := fs.Duration("interval", 2*time.Second, "the poll interval of --follow")
+	return func(ctx *cmdContext, args []string) error {
+		if err := requireFlag("project", *projectID); err != nil {
+			return err
+		}
+		if err := requireFlag("publish", *publishID); err != nil {
+			return err
+		}
+		c, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		if *jobID <= 0 {
+			page, err := c.ListPublishJobs(context.Background(), *projectID, *publishID, &sdk.ListPublishJobsOptions{Limit: 20})
+			if err != nil {
+				return err
+			}
+			for _, item := range page.Items {
+				if item.JobType != "deploy" {
+					*jobID = item.ID
+					break
+				}
+			}
+			if *jobID <= 0 {
+				return fmt.Errorf("the publish has no build or e2e-test job")
+			}
+		}
+		start := int64(0)
+		for {
+			piece := &jobLog{}
+			path := fmt.Sprintf("/pipelines/%v/publishes/%v/jobs/%v/log?%v", *projectID, *publishID, *jobID, url.Values{"start": {strconv.FormatInt(start, 10)}}.Encode())
+			if err := c.v1("GET", path, nil, piece); err != nil {
+				return err
+			}
+			fmt.Fprint(ctx.stdout, piece.Text)
+			start = piece.NextStart
+			if !*follow || !piece.More {
+				return nil
+			}
+			time.Sleep(*interval)
+		}
+	}
+}
+
+// publishFinished the publish is not pending or running any more
+func publishFinished(status string) bool {
+	return status != "pending" && status != "running"
+}
+
+func deployStatusFlags(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error {
+	projectID := fs.Int64("project", 0, "project id")
+	publishID := fs.Int64("publish", 0, "publish id")
+	wait := fs.Bool("wait", false, "wait until the publish is not pending or running, exit 1 if it failed")
+	timeout := fs.Duration("timeout", 30*time.Minute, "the timeout of --wait")
+	interval := fs.Duration("interval", 5*time.Second, "the poll interval of --wait")
+	return func(ctx *cmdContext, args []string) error {
+		if err := requireFlag("project", *projectID); err != nil {
+			return err
+		}
+		if err := requireFlag("publish", *publishID); err != nil {
+			return err
+		}
+		c, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
+		deadline := time.Now().Add(*timeout)
+		publish, err := c.GetPublish(context.Background(), *projectID, *publishID)
+		for err == nil && *wait && !publishFinished(publish.Status) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timeout waiting for publish %v, the status is %v", *publishID, publish.Status)
+			}
+			time.Sleep(*interval)
+			publish, err = c.GetPublish(context.Background(), *projectID, *publishID)
+		}
+		if err != nil {
+			return err
+		}
+		jobs, err := c.ListPublishJobs(context.Background(), *projectID, *publishID, &sdk.ListPublishJobsOptions{Limit: 20})
+		if err != nil {
+			return err
+		}
+		if ctx.output == "json" {
+			if err := printJSON(ctx, map[string]interface{}{"publish": publish, "jobs": jobs.Items}); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintf(ctx.stdout, "Publish:  %v %v (%v)\nStage:    %v\nStep:     %v\nStatus:   %v\n\n", publish.Name, publish.VersionNo, publish.ID, publish.StageName, publish.Step, publish.Status)
+			w := newTable(ctx, "JOB", "TYPE", "STAGE", "STATUS", "PROGRESS", "DURATION", "UPDATED")
+			for _, item := range jobs.Items {
+				duration := time.Duration(item.DurationInMillis) * time.Millisecond
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v%%\t%v\t%v\n", item.ID, item.JobType, item.StageID, item.Status, item.Progress, duration, formatTime(item.UpdateAt))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if *wait && publish.Status != "success" && publish.Status != "end" {
+			return fmt.Errorf("publish %v finished with status %v", *publishID, publish.Status)
+		}
+		return nil
+	}
+}
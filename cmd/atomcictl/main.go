@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// atomcictl is the command line client of AtomCI, which drives the pipelines by the access token.
+//
+//	atomcictl login --server https://atomci.example.com --token atci_xxx
+//	atomcictl project list
+//	atomcictl publish create --project 1 --pipeline 2 --name "order v1.2" --version v1.2 --app 3:release/v1.2
+//	atomcictl build trigger --project 1 --publish 10
+//	atomcictl logs tail --project 1 --publish 10 --follow
+//	atomcictl deploy status --project 1 --publish 10
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command the node of the command tree, the leaf command has run
+type command struct {
+	name        string
+	short       string
+	subcommands []*command
+	// flags define the flags of the leaf command, which are parsed before run
+	flags func(fs *flag.FlagSet) func(ctx *cmdContext, args []string) error
+}
+
+// cmdContext the context of the running command
+type cmdContext struct {
+	stdout io.Writer
+	stderr io.Writer
+	// configPath the path of the saved server and token
+	configPath string
+	// output the format of output, table or json
+	output string
+}
+
+func rootCommand() *command {
+	return &command{
+		name:  "atomcictl",
+		short: "the command line client of AtomCI",
+		subcommands: []*command{
+			{name: "login", short: "save the server address and the access token", flags: loginFlags},
+			{name: "project", short: "manage projects", subcommands: []*command{
+				{name: "list", short: "list the projects you can access", flags: projectListFlags},
+			}},
+			{name: "publish", short: "manage publishes", subcommands: []*command{
+				{name: "list", short: "list the publishes of project", flags: publishListFlags},
+				{name: "create", short: "create a publish of project", flags: publishCreateFlags},
+			}},
+			{name: "build", short: "manage builds", subcommands: []*command{
+				{name: "trigger", short: "trigger the build of the current stage", flags: buildTriggerFlags},
+			}},
+			{name: "logs", short: "show the logs of jobs", subcommands: []*command{
+				{name: "tail", short: "print the console log of the latest or given job", flags: logsTailFlags},
+			}},
+			{name: "deploy", short: "show deployments", subcommands: []*command{
+				{name: "status", short: "show the status of publish and its jobs", flags: deployStatusFlags},
+			}},
+		},
+	}
+}
+
+func main() {
+	ctx := &cmdContext{stdout: os.Stdout, stderr: os.Stderr, configPath: defaultConfigPath()}
+	os.Exit(execute(ctx, rootCommand(), os.Args[1:]))
+}
+
+// execute find the command by the args and run it, returns the exit code
+func execute(ctx *cmdContext, root *command, args []string) int {
+	cmd, path := root, []string{root.name}
+	for len(args) > 0 && len(cmd.subcommands) > 0 {
+		next := cmd.find(args[0])
+		if next == nil {
+			break
+		}
+		cmd, path, args = next, append(path, next.name), args[1:]
+	}
+	if cmd.flags == nil {
+		if len(args) > 0 && args[0] != "-h" && args[0] != "--help" && args[0] != "help" {
+			fmt.Fprintf(ctx.stderr, "unknown command %q for %q\n\n", args[0], strings.Join(path, " "))
+			cmd.usage(ctx.stderr, path)
+			return 2
+		}
+		cmd.usage(ctx.stdout, path)
+		return 0
+	}
+
+	fs := flag.NewFlagSet(strings.Join(path, " "), flag.ContinueOnError)
+	fs.SetOutput(ctx.stderr)
+	fs.StringVar(&ctx.configPath, "config", ctx.configPath, "the config file of server and token")
+	fs.StringVar(&ctx.output, "o", "table", "output format: table or json")
+	run := cmd.flags(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if ctx.output != "table" && ctx.output != "json" {
+		fmt.Fprintf(ctx.stderr, "invalid output format %q, must be table or json\n", ctx.output)
+		return 2
+	}
+	if err := run(ctx, fs.Args()); err != nil {
+		fmt.Fprintf(ctx.stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func (c *command) find(name string) *command {
+	for _, item := range c.subcommands {
+		if item.name == name {
+			return item
+		}
+	}
+	return nil
+}
+
+func (c *command) usage(w io.Writer, path []string) {
+	fmt.Fprintf(w, "%s - %s\n\nUsage:\n  %s <command> [flags]\n\nCommands:\n", strings.Join(path, " "), c.short, strings.Join(path, " "))
+	for _, item := range c.subcommands {
+		fmt.Fprintf(w, "  %-10s %s\n", item.name, item.short)
+	}
+	fmt.Fprintf(w, "\nUse \"%s <command> -h\" for more information about a command.\n", strings.Join(path, " "))
+}
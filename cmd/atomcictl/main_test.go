@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer atci_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/atomci/api/v1/getCurrentUser":
+			w.Write([]byte(`{"IsSuccess":true,"Data":{"user":"admin","name":"Admin"}}`))
+		case "/atomci/api/v2/projects":
+			w.Write([]byte(`{"items":[{"id":1,"name":"demo","owner":"admin"}],"has_more":false}`))
+		case "/atomci/api/v1/pipelines/1/publishes/2/jobs/3/log":
+			w.Write([]byte(`{"IsSuccess":true,"Data":{"job_id":3,"status":"success","text":"Finished: SUCCESS\n","next_start":18,"more":false}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("ATOMCI_SERVER", "")
+	t.Setenv("ATOMCI_TOKEN", "")
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	run := func(args ...string) (int, string, string) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		ctx := &cmdContext{stdout: stdout, stderr: stderr, configPath: configPath}
+		code := execute(ctx, rootCommand(), args)
+		return code, stdout.String(), stderr.String()
+	}
+
+	if code, _, stderr := run("project", "list"); code != 1 || !strings.Contains(stderr, "login") {
+		t.Fatalf("expected not logged in error, got %v: %v", code, stderr)
+	}
+	if code, stdout, stderr := run("login", "--server", server.URL, "--token", "atci_test"); code != 0 || !strings.Contains(stdout, "admin") {
+		t.Fatalf("login failed %v: %v%v", code, stdout, stderr)
+	}
+	if code, stdout, stderr := run("project", "list"); code != 0 || !strings.Contains(stdout, "demo") {
+		t.Fatalf("project list failed %v: %v%v", code, stdout, stderr)
+	}
+	if code, stdout, stderr := run("logs", "tail", "--project", "1", "--publish", "2", "--job", "3"); code != 0 || stdout != "Finished: SUCCESS\n" {
+		t.Fatalf("logs tail failed %v: %q%v", code, stdout, stderr)
+	}
+	if code, _, _ := run("publish", "unknown"); code != 2 {
+		t.Fatalf("expected usage error, got %v", code)
+	}
+}
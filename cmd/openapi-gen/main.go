@@ -0,0 +1,611 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// openapi-gen generates the openapi document and the go client sdk of api v2
+// from the annotations of internal/api/v2.go and the V2 prefixed types of internal/api/v2types.go.
+//
+//	go run ./cmd/openapi-gen -root .
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	typePrefix = "V2"
+	basePath   = "/atomci/api/v2"
+
+	apiDir      = "internal/api"
+	documentOut = "internal/api/openapi_gen.go"
+	sdkOut      = "pkg/sdk/zz_generated.go"
+)
+
+var (
+	paramRegexp   = regexp.MustCompile(`^(\S+)\s+(path|query)\s+(string|integer|boolean)\s+(true|false)\s+"(.*)"$`)
+	successRegexp = regexp.MustCompile(`^(\d{3})\s+\{object\}\s+(\w+)$`)
+	routerRegexp  = regexp.MustCompile(`^(\S+)\s+\[(get|post|put|delete|patch)\]$`)
+	pathRegexp    = regexp.MustCompile(`:(\w+)`)
+)
+
+// param the parameter of an operation
+type param struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// operation the handler annotated by @Router
+type operation struct {
+	Handler     string
+	ID          string
+	Tags        []string
+	Summary     string
+	Description string
+	Params      []param
+	Status      string
+	Response    string
+	Path        string
+	Method      string
+}
+
+// field the field of the resource type
+type field struct {
+	Name        string
+	JSONName    string
+	OmitEmpty   bool
+	Description string
+	Expr        ast.Expr
+}
+
+// schema the V2 prefixed struct type
+type schema struct {
+	Name        string
+	Description string
+	Fields      []field
+}
+
+func main() {
+	root := flag.String("root", ".", "the root dir of the repository")
+	flag.Parse()
+
+	document, sdk, err := generate(*root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "openapi-gen: %v\n", err)
+		os.Exit(1)
+	}
+	for path, content := range map[string][]byte{documentOut: document, sdkOut: sdk} {
+		if err := ioutil.WriteFile(filepath.Join(*root, path), content, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "openapi-gen: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// generate returns the go source of the openapi document and the go client sdk
+func generate(root string) ([]byte, []byte, error) {
+	operations, schemas, err := parse(filepath.Join(root, apiDir))
+	if err != nil {
+		return nil, nil, err
+	}
+	document, err := renderDocument(operations, schemas)
+	if err != nil {
+		return nil, nil, err
+	}
+	sdk, err := renderSDK(operations, schemas)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, sdk, nil
+}
+
+// parse collects the annotated operations and the V2 prefixed types
+func parse(dir string) ([]*operation, map[string]*schema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "v2*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(files)
+	fset := token.NewFileSet()
+	operations := []*operation{}
+	schemas := map[string]*schema{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Doc == nil || !strings.Contains(d.Doc.Text(), "@Router") {
+					continue
+				}
+				op, err := parseOperation(d.Name.Name, d.Doc.Text())
+				if err != nil {
+					return nil, nil, fmt.Errorf("%v: %v", fset.Position(d.Pos()), err)
+				}
+				operations = append(operations, op)
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok || !strings.HasPrefix(ts.Name.Name, typePrefix) {
+						continue
+					}
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					schemas[ts.Name.Name] = parseSchema(ts.Name.Name, d.Doc, st)
+				}
+			}
+		}
+	}
+	for _, op := range operations {
+		if _, ok := schemas[op.Response]; !ok {
+			return nil, nil, fmt.Errorf("the response type %v of %v is not found", op.Response, op.Handler)
+		}
+	}
+	if _, ok := schemas[typePrefix+"ErrorResponse"]; !ok {
+		return nil, nil, fmt.Errorf("the error envelope %vErrorResponse is not found", typePrefix)
+	}
+	return operations, schemas, nil
+}
+
+func parseOperation(handler, doc string) (*operation, error) {
+	op := &operation{Handler: handler}
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "@") {
+			if op.Description == "" {
+				op.Description = strings.TrimSpace(strings.TrimPrefix(line, handler))
+			}
+			continue
+		}
+		key, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			key, value = line[:i], strings.TrimSpace(line[i:])
+		}
+		switch key {
+		case "@ID":
+			op.ID = value
+		case "@Tags":
+			op.Tags = strings.Split(value, ",")
+		case "@Summary":
+			op.Summary = value
+		case "@Param":
+			m := paramRegexp.FindStringSubmatch(value)
+			if m == nil {
+				return nil, fmt.Errorf("invalid @Param: %v", value)
+			}
+			op.Params = append(op.Params, param{Name: m[1], In: m[2], Type: m[3], Required: m[4] == "true", Description: m[5]})
+		case "@Success":
+			m := successRegexp.FindStringSubmatch(value)
+			if m == nil {
+				return nil, fmt.Errorf("invalid @Success: %v", value)
+			}
+			op.Status, op.Response = m[1], m[2]
+		case "@Router":
+			m := routerRegexp.FindStringSubmatch(value)
+			if m == nil {
+				return nil, fmt.Errorf("invalid @Router: %v", value)
+			}
+			op.Path, op.Method = m[1], m[2]
+		default:
+			return nil, fmt.Errorf("unknown annotation: %v", key)
+		}
+	}
+	if op.ID == "" || op.Response == "" || op.Path == "" {
+		return nil, fmt.Errorf("@ID, @Success and @Router are required")
+	}
+	return op, nil
+}
+
+func parseSchema(name string, doc *ast.CommentGroup, st *ast.StructType) *schema {
+	s := &schema{Name: name}
+	if doc != nil {
+		s.Description = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(doc.Text()), name))
+	}
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 || f.Tag == nil {
+			continue
+		}
+		tag, _ := strconv.Unquote(f.Tag.Value)
+		jsonTag := strings.Split(reflectTag(tag, "json"), ",")
+		if jsonTag[0] == "" || jsonTag[0] == "-" {
+			continue
+		}
+		item := field{
+			Name:      f.Names[0].Name,
+			JSONName:  jsonTag[0],
+			OmitEmpty: len(jsonTag) > 1 && jsonTag[1] == "omitempty",
+			Expr:      f.Type,
+		}
+		if f.Doc != nil {
+			item.Description = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(f.Doc.Text()), item.Name))
+		}
+		s.Fields = append(s.Fields, item)
+	}
+	return s
+}
+
+// reflectTag the value of the key in struct tag
+func reflectTag(tag, key string) string {
+	for _, item := range strings.Fields(tag) {
+		if strings.HasPrefix(item, key+":") {
+			value, _ := strconv.Unquote(strings.TrimPrefix(item, key+":"))
+			return value
+		}
+	}
+	return ""
+}
+
+// schemaName the name of the type in openapi document and sdk
+func schemaName(name string) string {
+	return strings.TrimPrefix(name, typePrefix)
+}
+
+// openapiPath converts the beego path params to openapi path params
+func openapiPath(path string) string {
+	return pathRegexp.ReplaceAllString(path, "{$1}")
+}
+
+func typeSchema(expr ast.Expr) (map[string]interface{}, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return map[string]interface{}{"type": "string"}, nil
+		case "bool":
+			return map[string]interface{}{"type": "boolean"}, nil
+		case "int", "int8", "int16", "int32":
+			return map[string]interface{}{"type": "integer", "format": "int32"}, nil
+		case "int64":
+			return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+		case "float32", "float64":
+			return map[string]interface{}{"type": "number"}, nil
+		}
+		if strings.HasPrefix(t.Name, typePrefix) {
+			return map[string]interface{}{"$ref": "#/components/schemas/" + schemaName(t.Name)}, nil
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Time" {
+			return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+		}
+	case *ast.StarExpr:
+		item, err := typeSchema(t.X)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := item["$ref"]; !ok {
+			item["nullable"] = true
+		}
+		return item, nil
+	case *ast.ArrayType:
+		item, err := typeSchema(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": item}, nil
+	case *ast.MapType:
+		if key, ok := t.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, fmt.Errorf("unsupported map key type: %T", t.Key)
+		}
+		item, err := typeSchema(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": item}, nil
+	case *ast.InterfaceType:
+		// any value
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("unsupported type: %T", expr)
+}
+
+func renderDocument(operations []*operation, schemas map[string]*schema) ([]byte, error) {
+	components := map[string]interface{}{}
+	for name, s := range schemas {
+		properties := map[string]interface{}{}
+		required := []string{}
+		for _, f := range s.Fields {
+			item, err := typeSchema(f.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("%v.%v: %v", name, f.Name, err)
+			}
+			if f.Description != "" {
+				if _, ok := item["$ref"]; ok {
+					item = map[string]interface{}{"allOf": []interface{}{item}}
+				}
+				item["description"] = f.Description
+			}
+			properties[f.JSONName] = item
+			if !f.OmitEmpty {
+				required = append(required, f.JSONName)
+			}
+		}
+		component := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			component["required"] = required
+		}
+		if s.Description != "" {
+			component["description"] = s.Description
+		}
+		components[schemaName(name)] = component
+	}
+
+	errorResponse := map[string]interface{}{
+		"description": "the standard error envelope",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/" + schemaName(typePrefix+"ErrorResponse")},
+			},
+		},
+	}
+	paths := map[string]interface{}{}
+	for _, op := range operations {
+		params := []interface{}{}
+		for _, p := range op.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.Required,
+				"description": p.Description,
+				"schema":      map[string]interface{}{"type": p.Type},
+			})
+		}
+		item := map[string]interface{}{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"description": op.Description,
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				op.Status: map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/" + schemaName(op.Response)},
+						},
+					},
+				},
+				"default": errorResponse,
+			},
+		}
+		if len(op.Tags) > 0 {
+			item["tags"] = op.Tags
+		}
+		path := openapiPath(op.Path)
+		if _, ok := paths[path]; !ok {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path].(map[string]interface{})[op.Method] = item
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "AtomCI API",
+			"version":     "v2",
+			"description": "The resource oriented api of AtomCI, which uses cursor pagination and the standard error envelope.",
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": basePath}},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": map[string]interface{}{
+			"schemas": components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if bytes.ContainsRune(content, '`') {
+		return nil, fmt.Errorf("the openapi document should not contain backquote")
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by openapi-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package api\n\n")
+	fmt.Fprintf(buf, "// openAPIDocument the openapi document of api v2\n")
+	fmt.Fprintf(buf, "const openAPIDocument = `%s\n`\n", content)
+	return format.Source(buf.Bytes())
+}
+
+// goName converts the snake case name to go name
+func goName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		switch part {
+		case "id", "url":
+			parts[i] = strings.ToUpper(part)
+		default:
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// goType the type expression in sdk
+func goType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return schemaName(t.Name)
+	case *ast.SelectorExpr:
+		return goType(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + goType(t.X)
+	case *ast.ArrayType:
+		return "[]" + goType(t.Elt)
+	case *ast.MapType:
+		return "map[" + goType(t.Key) + "]" + goType(t.Value)
+	}
+	return "interface{}"
+}
+
+// paramType the go type of the parameter
+func paramType(p param) string {
+	switch p.Type {
+	case "integer":
+		return "int64"
+	case "boolean":
+		return "bool"
+	}
+	return "string"
+}
+
+// lowerName the go name of the parameter in argument list
+func lowerName(name string) string {
+	name = goName(name)
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func renderSDK(operations []*operation, schemas map[string]*schema) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	imports := map[string]bool{"context": true, "net/url": true}
+
+	names := []string{}
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := schemas[name]
+		if s.Description != "" {
+			fmt.Fprintf(buf, "// %s %s\n", schemaName(name), s.Description)
+		}
+		fmt.Fprintf(buf, "type %s struct {\n", schemaName(name))
+		for _, f := range s.Fields {
+			if f.Description != "" {
+				fmt.Fprintf(buf, "// %s %s\n", f.Name, f.Description)
+			}
+			tag := f.JSONName
+			if f.OmitEmpty {
+				tag += ",omitempty"
+			}
+			if strings.Contains(goType(f.Expr), "time.") {
+				imports["time"] = true
+			}
+			fmt.Fprintf(buf, "%s %s `json:%q`\n", f.Name, goType(f.Expr), tag)
+		}
+		fmt.Fprintf(buf, "}\n\n")
+	}
+
+	for _, op := range operations {
+		method := op.Handler
+		pathParams, queryParams := []param{}, []param{}
+		for _, p := range op.Params {
+			if p.In == "path" {
+				pathParams = append(pathParams, p)
+			} else {
+				queryParams = append(queryParams, p)
+			}
+		}
+		options := method + "Options"
+		if len(queryParams) > 0 {
+			fmt.Fprintf(buf, "// %s the query options of %s\n", options, method)
+			fmt.Fprintf(buf, "type %s struct {\n", options)
+			for _, p := range queryParams {
+				fmt.Fprintf(buf, "// %s %s\n", goName(p.Name), p.Description)
+				fmt.Fprintf(buf, "%s %s\n", goName(p.Name), paramType(p))
+			}
+			fmt.Fprintf(buf, "}\n\n")
+		}
+
+		args := []string{"ctx context.Context"}
+		for _, p := range pathParams {
+			args = append(args, fmt.Sprintf("%s %s", lowerName(p.Name), paramType(p)))
+		}
+		if len(queryParams) > 0 {
+			args = append(args, "opts *"+options)
+		}
+		response := schemaName(op.Response)
+		fmt.Fprintf(buf, "// %s %s\n", method, op.Summary)
+		fmt.Fprintf(buf, "//\n// %s %s\n", strings.ToUpper(op.Method), openapiPath(op.Path))
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) (*%s, error) {\n", method, strings.Join(args, ", "), response)
+		pathFormat, pathArgs := op.Path, []string{}
+		for _, p := range pathParams {
+			pathFormat = strings.Replace(pathFormat, ":"+p.Name, "%v", 1)
+			pathArgs = append(pathArgs, lowerName(p.Name))
+		}
+		if len(pathArgs) > 0 {
+			imports["fmt"] = true
+			fmt.Fprintf(buf, "path := fmt.Sprintf(%q, %s)\n", pathFormat, strings.Join(pathArgs, ", "))
+		} else {
+			fmt.Fprintf(buf, "path := %q\n", pathFormat)
+		}
+		fmt.Fprintf(buf, "values := url.Values{}\n")
+		if len(queryParams) > 0 {
+			fmt.Fprintf(buf, "if opts != nil {\n")
+			for _, p := range queryParams {
+				name := goName(p.Name)
+				switch paramType(p) {
+				case "int64":
+					imports["strconv"] = true
+					fmt.Fprintf(buf, "if opts.%s != 0 {\nvalues.Set(%q, strconv.FormatInt(opts.%s, 10))\n}\n", name, p.Name, name)
+				case "bool":
+					fmt.Fprintf(buf, "if opts.%s {\nvalues.Set(%q, \"true\")\n}\n", name, p.Name)
+				default:
+					fmt.Fprintf(buf, "if opts.%s != \"\" {\nvalues.Set(%q, opts.%s)\n}\n", name, p.Name, name)
+				}
+			}
+			fmt.Fprintf(buf, "}\n")
+		}
+		fmt.Fprintf(buf, "out := &%s{}\n", response)
+		fmt.Fprintf(buf, "if err := c.do(ctx, %q, path, values, out); err != nil {\nreturn nil, err\n}\n", strings.ToUpper(op.Method))
+		fmt.Fprintf(buf, "return out, nil\n}\n\n")
+	}
+	header := &bytes.Buffer{}
+	fmt.Fprintf(header, "// Code generated by openapi-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(header, "package sdk\n\n")
+	packages := []string{}
+	for item := range imports {
+		packages = append(packages, item)
+	}
+	sort.Strings(packages)
+	fmt.Fprintf(header, "import (\n")
+	for _, item := range packages {
+		fmt.Fprintf(header, "%q\n", item)
+	}
+	fmt.Fprintf(header, ")\n\n")
+	source, err := format.Source(append(header.Bytes(), buf.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("format sdk: %v", err)
+	}
+	return source, nil
+}
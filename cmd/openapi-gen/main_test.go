@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedUpToDate(t *testing.T) {
+	root := filepath.Join("..", "..")
+	document, sdk, err := generate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string][]byte{documentOut: document, sdkOut: sdk} {
+		current, err := ioutil.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(current, content) {
+			t.Errorf("%v is out of date, run `make openapi`", path)
+		}
+	}
+
+	start, end := bytes.IndexByte(document, '`'), bytes.LastIndexByte(document, '`')
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(document[start+1:end], &spec); err != nil {
+		t.Fatalf("invalid openapi document: %v", err)
+	}
+	paths := spec["paths"].(map[string]interface{})
+	if _, ok := paths["/projects/{project_id}/publishes/{publish_id}/jobs"]; !ok {
+		t.Errorf("missing path of ListPublishJobs: %v", paths)
+	}
+}
+
+func TestParseOperation(t *testing.T) {
+	doc := strings.Join([]string{
+		"GetThing get the thing",
+		"@ID getThing",
+		"@Param thing_id path integer true \"thing id\"",
+		"@Success 200 {object} V2Thing",
+		"@Router /things/:thing_id [get]",
+	}, "\n")
+	op, err := parseOperation("GetThing", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Description != "get the thing" || op.Method != "get" || op.Response != "V2Thing" || len(op.Params) != 1 || !op.Params[0].Required {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+	if path := openapiPath(op.Path); path != "/things/{thing_id}" {
+		t.Errorf("unexpected openapi path: %v", path)
+	}
+
+	if _, err := parseOperation("GetThing", "@ID getThing\n@Router /things [get]"); err == nil {
+		t.Errorf("expect error without @Success")
+	}
+	if _, err := parseOperation("GetThing", "@Unknown x"); err == nil {
+		t.Errorf("expect error of unknown annotation")
+	}
+}
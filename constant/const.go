@@ -31,6 +31,14 @@ const (
 	StepSubTaskCompile      = "compile"
 	StepSubTaskBuildImage   = "build-image"
 	StepSubTaskCustomScript = "custom-script"
+	StepSubTaskTerraform    = "terraform"
+	StepSubTaskDBMigration  = "db-migration"
+	StepSubTaskE2ESuite     = "e2e-suite"
+	StepSubTaskPerfTest     = "perf-test"
+	StepSubTaskInput        = "input"
+	StepSubTaskArtifact     = "artifact"
+	StepSubTaskSBOM         = "sbom"
+	StepSubTaskSign         = "sign"
 )
 
 // const variables
@@ -62,15 +70,31 @@ const (
 	SCMGitea            = "gitea"
 	SCMGitee            = "gitee"
 	SCMGogs             = "gogs"
+	SCMBitbucket        = "bitbucket"
+	SCMBitbucketServer  = "bitbucket-server"
 	IntegrateKubernetes = "kubernetes"
 	IntegrateJenkins    = "jenkins"
 	IntegrateRegistry   = "registry"
+	IntegrateArgoCD     = "argocd"
+	IntegrateJira       = "jira"
+	IntegrateKafka      = "kafka"
+	IntegrateNATS       = "nats"
+	IntegrateCosign     = "cosign"
 )
 
-var Integratetypes = []string{IntegrateKubernetes, IntegrateJenkins, IntegrateRegistry}
-var ScmIntegratetypes = []string{SCMGitlab, SCMGithub, SCMGitea, SCMGitee, SCMGogs}
+var Integratetypes = []string{IntegrateKubernetes, IntegrateJenkins, IntegrateRegistry, IntegrateArgoCD, IntegrateJira, IntegrateKafka, IntegrateNATS, IntegrateCosign}
+var ScmIntegratetypes = []string{SCMGitlab, SCMGithub, SCMGitea, SCMGitee, SCMGogs, SCMBitbucket, SCMBitbucketServer}
 
 const (
 	DefaultContainerName    = "jnlp"
 	BuildImageContainerName = "kaniko"
+	// the sys compile envs of the windows build pod
+	WindowsDefaultContainerName    = "jnlp-windows"
+	WindowsBuildImageContainerName = "docker-windows"
+)
+
+// the build mode of app image
+const (
+	BuildModeKaniko     = "kaniko"
+	BuildModeBuildpacks = "buildpacks"
 )
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"github.com/go-atomci/atomci/internal/core/accesstoken"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// AccessTokenController the personal access tokens of current user
+type AccessTokenController struct {
+	BaseController
+}
+
+// GetAccessTokens ..
+func (a *AccessTokenController) GetAccessTokens() {
+	rsp, err := dao.GetUserAccessTokens(a.User)
+	if err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("get access tokens error: %s", err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, rsp, "")
+	a.ServeJSON()
+}
+
+// CreateAccessToken the token is only returned once
+func (a *AccessTokenController) CreateAccessToken() {
+	request := models.AccessTokenReq{}
+	a.DecodeJSONReq(&request)
+	rsp, err := accesstoken.CreateToken(a.User, 0, &request, a.User)
+	if err != nil {
+		a.HandleBadRequest(err.Error())
+		log.Log.Error("create access token error: %s", err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, rsp, "")
+	a.ServeJSON()
+}
+
+// RevokeAccessToken ..
+func (a *AccessTokenController) RevokeAccessToken() {
+	tokenID, _ := a.GetInt64FromPath(":token_id")
+	if err := accesstoken.RevokeToken(a.User, tokenID); err != nil {
+		a.HandleBadRequest(err.Error())
+		log.Log.Error("revoke access token error: %s", err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, nil, "")
+	a.ServeJSON()
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/analytics"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// AnalyticsController the ci/cd analytics of the project
+type AnalyticsController struct {
+	BaseController
+}
+
+// getDORAMetrics get the dora metrics by the time range query, respond the error if failed
+func (a *AnalyticsController) getDORAMetrics() ([]*analytics.DORAMetrics, bool) {
+	projectID, _ := a.GetInt64FromPath(":project_id")
+	envID, _ := a.GetInt64FromQuery("stage_id")
+	timeRange, err := analytics.ParseTimeRange(a.GetStringFromQuery("start"), a.GetStringFromQuery("end"), time.Now())
+	if err != nil {
+		a.HandleBadRequest(err.Error())
+		return nil, false
+	}
+	items, err := analytics.GetDORAMetrics(projectID, envID, timeRange)
+	if err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("Get dora metrics of project: %v occur error: %s", projectID, err.Error())
+		return nil, false
+	}
+	return items, true
+}
+
+// GetDORAMetrics get the deployment frequency, lead time, change failure rate and mttr of the project envs
+func (a *AnalyticsController) GetDORAMetrics() {
+	items, ok := a.getDORAMetrics()
+	if !ok {
+		return
+	}
+	a.Data["json"] = NewResult(true, items, "")
+	a.ServeJSON()
+}
+
+// ExportDORAMetrics export the dora metrics of the project envs as csv
+func (a *AnalyticsController) ExportDORAMetrics() {
+	items, ok := a.getDORAMetrics()
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if err := analytics.ExportCSV(&buf, items); err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("Export dora metrics occur error: %s", err.Error())
+		return
+	}
+	projectID, _ := a.GetInt64FromPath(":project_id")
+	a.Ctx.Output.Header("Content-Type", "text/csv; charset=utf-8")
+	a.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=dora-%v-%v.csv", projectID, time.Now().Format("20060102150405")))
+	a.Ctx.Output.Body(buf.Bytes())
+}
+
+// GetStepAnalytics get the duration trends and failure causes of the steps, the slowest step is the first
+func (a *AnalyticsController) GetStepAnalytics() {
+	projectID, _ := a.GetInt64FromPath(":project_id")
+	envID, _ := a.GetInt64FromQuery("stage_id")
+	timeRange, err := analytics.ParseTimeRange(a.GetStringFromQuery("start"), a.GetStringFromQuery("end"), time.Now())
+	if err != nil {
+		a.HandleBadRequest(err.Error())
+		return
+	}
+	rsp, err := analytics.GetStepAnalytics(projectID, envID, a.GetStringFromQuery("job_type"), timeRange)
+	if err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("Get step analytics of project: %v occur error: %s", projectID, err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, rsp, "")
+	a.ServeJSON()
+}
+
+// GetPublishJobStages get the timings and failure causes of the steps run by the publish jobs
+func (a *AnalyticsController) GetPublishJobStages() {
+	projectID, _ := a.GetInt64FromPath(":project_id")
+	publishID, _ := a.GetInt64FromPath(":publish_id")
+	publish, err := dao.NewPublishModel().GetPublishByID(publishID)
+	if err != nil || publish.ProjectID != projectID {
+		a.HandleNotFound("流水线不存在")
+		return
+	}
+	rsp, err := analytics.GetPublishJobStages(publishID)
+	if err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("Get publish: %v job stages occur error: %s", publishID, err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, rsp, "")
+	a.ServeJSON()
+}
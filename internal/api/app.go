@@ -133,12 +133,12 @@ func (a *AppController) DeleteScmApp() {
 func (a *AppController) GetArrange() {
 	appID, err := a.GetInt64FromPath(":app_id")
 	if err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("invalid app id"))
+		a.ServeError(errors.NewInvalidParam("app_id"))
 		return
 	}
 	envID, err := a.GetInt64FromPath(":env_id")
 	if err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("invalid env id"))
+		a.ServeError(errors.NewInvalidParam("env_id"))
 		return
 	}
 	mgr := apps.NewAppManager()
@@ -154,41 +154,43 @@ func (a *AppController) GetArrange() {
 func (a *AppController) SetArrange() {
 	projectAppID, err := a.GetInt64FromPath(":app_id")
 	if err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("invalid project app id"))
+		a.ServeError(errors.NewInvalidParam("project_app_id"))
 		return
 	}
 	arrangeEnvID, err := a.GetInt64FromPath(":env_id")
 	if err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("invalid project env id"))
+		a.ServeError(errors.NewInvalidParam("project_env_id"))
 		return
 	}
 	request := apps.AppArrangeReq{}
 	a.DecodeJSONReq(&request)
 
-	native := &kuberes.NativeTemplate{
-		Template: request.Config,
-	}
-
-	if err := native.Validate(); err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("yaml parse error: %s", err.Error()))
+	mgr := apps.NewAppManager()
+	rsp, err := mgr.ValidateArrange(projectAppID, arrangeEnvID, &request)
+	if err != nil {
+		a.ServeError(err)
 		return
 	}
 
-	mgr := apps.NewAppManager()
-	err = mgr.SetArrange(projectAppID, arrangeEnvID, &request)
+	err = mgr.SetArrange(projectAppID, arrangeEnvID, &request, a.User)
 	if err != nil {
 		a.ServeError(err)
 		return
 	}
-	a.ServeResult(NewResult(true, nil, ""))
+	a.ServeResult(NewResult(true, rsp, ""))
 }
 
 func (a *AppController) ParseArrangeYaml() {
 	request := apps.AppArrangConfig{}
 	a.DecodeJSONReq(&request)
 
+	config, err := apps.RenderArrange(request.Config, &apps.ArrangeTemplateData{Vars: request.Variables})
+	if err != nil {
+		a.ServeError(errors.New(errors.CodeArrangeRenderFailed, errors.Params{"reason": err.Error()}))
+		return
+	}
 	native := &kuberes.NativeTemplate{
-		Template: request.Config,
+		Template: config,
 	}
 	rsp, err := native.GetContainerImages()
 	if err != nil {
@@ -197,6 +199,129 @@ func (a *AppController) ParseArrangeYaml() {
 	a.ServeResult(NewResult(true, rsp, ""))
 }
 
+// RenderArrange preview the arrange rendered with env/app variables
+func (a *AppController) RenderArrange() {
+	projectAppID, err := a.GetInt64FromPath(":app_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_app_id"))
+		return
+	}
+	arrangeEnvID, err := a.GetInt64FromPath(":env_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_env_id"))
+		return
+	}
+	request := apps.AppArrangeReq{}
+	a.DecodeJSONReq(&request)
+
+	mgr := apps.NewAppManager()
+	rendered, err := mgr.PreviewArrange(projectAppID, arrangeEnvID, &request)
+	if err != nil {
+		a.ServeError(errors.New(errors.CodeArrangeRenderFailed, errors.Params{"reason": err.Error()}))
+		return
+	}
+	a.ServeResult(NewResult(true, &apps.AppArrangConfig{Config: rendered}, ""))
+}
+
+// GetArrangeRevisions ..
+func (a *AppController) GetArrangeRevisions() {
+	projectAppID, err := a.GetInt64FromPath(":app_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_app_id"))
+		return
+	}
+	arrangeEnvID, err := a.GetInt64FromPath(":env_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_env_id"))
+		return
+	}
+	mgr := apps.NewAppManager()
+	rsp, err := mgr.GetArrangeRevisions(projectAppID, arrangeEnvID)
+	if err != nil {
+		a.ServeError(err)
+		return
+	}
+	a.ServeResult(NewResult(true, rsp, ""))
+}
+
+// GetArrangeRevision ..
+func (a *AppController) GetArrangeRevision() {
+	projectAppID, err := a.GetInt64FromPath(":app_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_app_id"))
+		return
+	}
+	arrangeEnvID, err := a.GetInt64FromPath(":env_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_env_id"))
+		return
+	}
+	revision, err := a.GetInt64FromPath(":revision")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("revision"))
+		return
+	}
+	mgr := apps.NewAppManager()
+	rsp, err := mgr.GetArrangeRevision(projectAppID, arrangeEnvID, revision)
+	if err != nil {
+		a.ServeError(err)
+		return
+	}
+	a.ServeResult(NewResult(true, rsp, ""))
+}
+
+// DiffArrangeRevisions diff the revision with the revision of query "to", default to the current revision
+func (a *AppController) DiffArrangeRevisions() {
+	projectAppID, err := a.GetInt64FromPath(":app_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_app_id"))
+		return
+	}
+	arrangeEnvID, err := a.GetInt64FromPath(":env_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_env_id"))
+		return
+	}
+	revision, err := a.GetInt64FromPath(":revision")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("revision"))
+		return
+	}
+	to, _ := a.GetInt64FromQuery("to")
+	mgr := apps.NewAppManager()
+	rsp, err := mgr.DiffArrangeRevisions(projectAppID, arrangeEnvID, revision, to)
+	if err != nil {
+		a.ServeError(err)
+		return
+	}
+	a.ServeResult(NewResult(true, rsp, ""))
+}
+
+// RestoreArrangeRevision ..
+func (a *AppController) RestoreArrangeRevision() {
+	projectAppID, err := a.GetInt64FromPath(":app_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_app_id"))
+		return
+	}
+	arrangeEnvID, err := a.GetInt64FromPath(":env_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("project_env_id"))
+		return
+	}
+	revision, err := a.GetInt64FromPath(":revision")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("revision"))
+		return
+	}
+	mgr := apps.NewAppManager()
+	if err := mgr.RestoreArrange(projectAppID, arrangeEnvID, revision, a.User); err != nil {
+		a.ServeError(err)
+		return
+	}
+	a.ServeResult(NewResult(true, nil, ""))
+}
+
 // GetGitProjectsByRepoID ..
 func (a *AppController) GetGitProjectsByRepoID() {
 	repoID, _ := a.GetInt64FromPath(":repo_id")
@@ -214,7 +339,7 @@ func (a *AppController) GetGitProjectsByRepoID() {
 func (a *AppController) GetAppBranches() {
 	AppID, err := a.GetInt64FromPath(":app_id")
 	if err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("invalid app id"))
+		a.ServeError(errors.NewInvalidParam("app_id"))
 		return
 	}
 	filterQuery := a.GetFilterQuery()
@@ -229,11 +354,28 @@ func (a *AppController) GetAppBranches() {
 	a.ServeJSON()
 }
 
+// RegisterAppWebhook register the push webhook to app repository
+func (a *AppController) RegisterAppWebhook() {
+	appID, err := a.GetInt64FromPath(":app_id")
+	if err != nil {
+		a.ServeError(errors.NewInvalidParam("app_id"))
+		return
+	}
+	mgr := apps.NewAppManager()
+	if err := mgr.RegisterAppWebhook(appID); err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("register app webhook error: %s", err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, nil, "")
+	a.ServeJSON()
+}
+
 // SyncAppBranches ..
 func (a *AppController) SyncAppBranches() {
 	AppID, err := a.GetInt64FromPath(":app_id")
 	if err != nil {
-		a.ServeError(errors.NewBadRequest().SetMessage("invalid app id"))
+		a.ServeError(errors.NewInvalidParam("app_id"))
 		return
 	}
 	mgr := apps.NewAppManager()
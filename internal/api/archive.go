@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// ArchiveController the retention of publish jobs and operation logs, only for the system admin
+type ArchiveController struct {
+	BaseController
+}
+
+// ArchiveReq archive request body
+type ArchiveReq struct {
+	// ProjectID 0 means all projects
+	ProjectID int64 `json:"project_id"`
+}
+
+// Archive archive the publish jobs and operation logs out of the retention of project now
+func (a *ArchiveController) Archive() {
+	if !a.IsSysAdmin() {
+		a.HandleForbidden("仅系统管理员可以执行归档")
+		return
+	}
+	request := ArchiveReq{}
+	a.DecodeJSONReq(&request)
+	rsp, err := publish.NewArchiveManager().Archive(request.ProjectID)
+	if err != nil {
+		a.HandleInternalServerError(err.Error())
+		log.Log.Error("Archive project: %v occur error: %s", request.ProjectID, err.Error())
+		return
+	}
+	a.Data["json"] = NewResult(true, rsp, "")
+	a.ServeJSON()
+}
+
+// PurgeArchives permanently delete the archived records created before the date
+func (a *ArchiveController) PurgeArchives() {
+	if !a.IsSysAdmin() {
+		a.HandleForbidden("仅系统管理员可以清理归档数据")
+		return
+	}
+	request := publish.PurgeArchiveReq{}
+	a.DecodeJSONReq(&request)
+	rsp, err := publish.NewArchiveManager().PurgeArchives(&request)
+	if err != nil {
+		a.HandleBadRequest(err.Error())
+		log.Log.Error("Purge archives occur error: %s", err.Error())
+		return
+	}
+	log.Log.Info("user: %v purged the archives of project: %v before %v, jobs: %v, operation logs: %v, dry run: %v",
+		a.User, request.ProjectID, request.Before, rsp.Jobs, rsp.OperationLogs, rsp.DryRun)
+	a.Data["json"] = NewResult(true, rsp, "")
+	a.ServeJSON()
+}
@@ -17,10 +17,19 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/audit"
 	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
 )
 
+// maxAuditExportItems the max audit items exported once
+const maxAuditExportItems = 10000
+
 type AuditController struct {
 	BaseController
 }
@@ -35,3 +44,38 @@ func (ac *AuditController) AuditList() {
 	ac.Data["json"] = NewResult(true, res, "")
 	ac.ServeJSON()
 }
+
+// AuditListByPagination ..
+func (ac *AuditController) AuditListByPagination() {
+	filter := &models.AuditFilterQuery{}
+	ac.DecodeJSONReq(filter)
+	res, err := dao.AuditListByPagination(filter)
+	if err != nil {
+		ac.HandleInternalServerError(err.Error())
+		log.Log.Error("Get audit list by pagination error: %s", err.Error())
+		return
+	}
+	ac.Data["json"] = NewResult(true, res, "")
+	ac.ServeJSON()
+}
+
+// AuditExport export the audits match the filter as csv
+func (ac *AuditController) AuditExport() {
+	filter := &models.AuditFilterQuery{}
+	ac.DecodeJSONReq(filter)
+	audits, err := dao.AuditListByFilter(filter, maxAuditExportItems)
+	if err != nil {
+		ac.HandleInternalServerError(err.Error())
+		log.Log.Error("Export audit list error: %s", err.Error())
+		return
+	}
+	var buf bytes.Buffer
+	if err := audit.ExportCSV(&buf, audits); err != nil {
+		ac.HandleInternalServerError(err.Error())
+		log.Log.Error("Export audit list error: %s", err.Error())
+		return
+	}
+	ac.Ctx.Output.Header("Content-Type", "text/csv; charset=utf-8")
+	ac.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-%v.csv", time.Now().Format("20060102150405")))
+	ac.Ctx.Output.Body(buf.Bytes())
+}
@@ -64,6 +64,11 @@ func (a *AuthController) Authenticate() {
 		log.Log.Error("Invalid json request: " + err.Error())
 		a.CustomAbort(http.StatusBadRequest, "Invalid json request: "+err.Error())
 	}
+	clientIP := middleware.ClientIP(a.Ctx)
+	if rejectLockedLogin(a.Ctx, req.Username, clientIP) {
+		log.Log.Warn("login of user %v from %v is rejected, too many failures", req.Username, clientIP)
+		return
+	}
 
 	var loginProvider auth.Provider
 	switch req.LoginType {
@@ -71,6 +76,7 @@ func (a *AuthController) Authenticate() {
 		userModel, err := dao.GetUser(req.Username)
 		if err != nil {
 			log.Log.Error("get user error: " + err.Error())
+			middleware.LoginFailed(req.Username, clientIP)
 			a.CustomAbort(http.StatusBadRequest, "用户不存在或密码错误")
 		}
 		loginProvider = local.NewProvider(
@@ -98,8 +104,10 @@ func (a *AuthController) Authenticate() {
 	externalAccountInfo, authErr := loginProvider.Authenticate(req.Username, req.Password)
 	if authErr == nil {
 		log.Log.Debug("externalAccountInfo user: %s", externalAccountInfo.User)
+		middleware.LoginSucceeded(req.Username, clientIP)
 	} else {
 		log.Log.Error("login authenticate error: %v", authErr.Error())
+		middleware.LoginFailed(req.Username, clientIP)
 		http.Error(a.Ctx.ResponseWriter, "用户不存在或密码错误", http.StatusInternalServerError)
 		return
 	}
@@ -153,7 +161,9 @@ func createOrUpdateUser(userAttributes *auth.ExternalAccount, loginType int) (*m
 			LoginType: loginType,
 			Token:     token,
 		}
-		dao.InitSystemMember(user)
+		if err := dao.InitSystemMember(user); err != nil {
+			return nil, err
+		}
 	} else {
 		user.Email = email
 		user.Name = realName
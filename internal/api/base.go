@@ -26,6 +26,8 @@ import (
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/validation"
 
+	"github.com/go-atomci/atomci/internal/core/accesstoken"
+	"github.com/go-atomci/atomci/internal/core/audit"
 	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware"
 	"github.com/go-atomci/atomci/internal/middleware/log"
@@ -41,6 +43,26 @@ type BaseController struct {
 	User      string
 	audit     models.Audit
 	UserModel *models.User
+	// auditResource the resource changed by the request, snapshot before and after the change
+	auditResource *audit.Resource
+	// accessToken the access token used by the request, nil means the user logged in
+	accessToken *models.AccessToken
+	// callback the claims of the callback token used by the publish job callback
+	callback *middleware.CallbackClaims
+}
+
+// callbackActor the actor of the callbacks authenticated by callback token
+const callbackActor = "system"
+
+// callbackRouterPatterns the routes of publish job callback and report
+var callbackRouterPatterns = map[string]bool{
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/callback":     true,
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/terraform":    true,
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/db-migration": true,
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/e2e-report":   true,
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/perf-report":  true,
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/quality":      true,
+	"/atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/sbom":         true,
 }
 
 // GetStringFromPath gets the param from path and returns it as string
@@ -91,13 +113,18 @@ func (b *BaseController) ServeResult(result Result) {
 	b.ServeJSON()
 }
 
-// ServeError serve error
+// Lang the language of the error messages, negotiated by header `Accept-Language`
+func (b *BaseController) Lang() string {
+	return errors.ParseLang(b.Ctx.Input.Header("Accept-Language"))
+}
+
+// ServeError serve error, the message of the catalog error is localized by the language of request
 func (b *BaseController) ServeError(err error) {
 	if err == nil {
 		err = fmt.Errorf("nil")
 	}
 	var statusCode int
-	var result Result
+	var result *ErrorResult
 	switch srcErr := err.(type) {
 	// error
 	case *errors.Error:
@@ -107,15 +134,21 @@ func (b *BaseController) ServeError(err error) {
 				errDetail = srcErr.Cause().Error()
 			}
 			statusCode = srcErr.Status()
-			result = NewErrorResult(srcErr.Code(), srcErr.Message(), errDetail)
+			result = NewErrorResult(srcErr.Code(), srcErr.LocalizedMessage(b.Lang()), errDetail).(*ErrorResult)
+			result.ErrParams = srcErr.Params()
 		}
 	// go error
 	default:
 		{
 			statusCode = http.StatusInternalServerError
-			result = NewErrorResult("InternalServerError", "internal server error", err.Error())
+			internalErr := errors.NewInternalServerError()
+			result = NewErrorResult(internalErr.Code(), internalErr.LocalizedMessage(b.Lang()), err.Error()).(*ErrorResult)
 		}
 	}
+	if b.isAPIV2() {
+		b.renderV2Error(statusCode, result.ErrCode, result.ErrMsg, result.ErrDetail, result.ErrParams)
+		return
+	}
 	b.Ctx.Output.SetStatus(statusCode)
 	b.Data["json"] = result
 	b.ServeJSON()
@@ -166,8 +199,12 @@ func (b *BaseController) Render() error {
 	return nil
 }
 
-// RenderError provides shortcut to render http error
+// RenderError provides shortcut to render http error, the api v2 renders the standard error envelope
 func (b *BaseController) RenderError(code int, text string) {
+	if b.isAPIV2() {
+		b.renderV2Error(code, "", text, "", nil)
+		return
+	}
 	http.Error(b.Ctx.ResponseWriter, text, code)
 }
 
@@ -253,7 +290,14 @@ func (b *BaseController) getUserName() string {
 	log.Log.Debug("get token from header: %s", token)
 	user := ""
 	var err error
-	if strings.Contains(token, ".") {
+	if accesstoken.IsAccessToken(token) {
+		b.accessToken, err = accesstoken.Authenticate(token)
+		if err != nil {
+			log.Log.Warn("authenticate access token error: %s", err.Error())
+			return ""
+		}
+		user = b.accessToken.User
+	} else if strings.Contains(token, ".") {
 		user, err = middleware.JwtParse(b.Controller.Ctx, token)
 		if err != nil {
 			return ""
@@ -292,6 +336,10 @@ func (b *BaseController) checkUserLogin() string {
 // Prepare inits security context and project manager from request
 // context
 func (b *BaseController) Prepare() {
+	if claims, err := middleware.CallbackParse(b.getAuthHeader()); err == nil {
+		b.prepareCallback(claims)
+		return
+	}
 	user := b.checkUserLogin()
 	if user == "" {
 		b.ServeError(errors.NewUnauthorized().SetCause(fmt.Errorf("user is empty, missing header maybe")))
@@ -316,14 +364,76 @@ func (b *BaseController) Prepare() {
 		b.HandleForbidden("permission denied")
 		return
 	}
+	if b.accessToken != nil {
+		if err := b.verifyAccessToken(constraint["project_id"]); err != nil {
+			beego.Warn(fmt.Sprintf("user %v access token %v denied: %v", user, b.accessToken.ID, err.Error()))
+			b.HandleForbidden(err.Error())
+			return
+		}
+	}
 
 	operationObject, _ := json.Marshal(constraint)
+	operationBody := ""
+	// the files uploaded are not recorded, eg: the attachments of comment
+	if !strings.HasPrefix(b.Ctx.Input.Header("Content-Type"), "multipart/form-data") {
+		operationBody = audit.Redact(string(b.Ctx.Input.CopyBody(1 << 32)))
+	}
 	b.audit = models.Audit{
 		User:            user,
 		Method:          b.Ctx.Input.Method(),
 		Operation:       b.Ctx.Input.URL(),
 		OperationObject: string(operationObject),
-		OperationBody:   string(b.Ctx.Input.CopyBody(1 << 32)),
+		OperationBody:   operationBody,
+		IP:              b.Ctx.Input.IP(),
+	}
+	if b.audit.Method != "GET" {
+		b.prepareAuditResource(constraint)
+	}
+}
+
+// prepareCallback the callback token is only valid for the callback of its publish job
+func (b *BaseController) prepareCallback(claims *middleware.CallbackClaims) {
+	pattern, _ := b.Ctx.Input.GetData("RouterPattern").(string)
+	if !callbackRouterPatterns[pattern] ||
+		b.GetStringFromPath(":project_id") != strconv.FormatInt(claims.ProjectID, 10) ||
+		b.GetStringFromPath(":publish_id") != strconv.FormatInt(claims.PublishID, 10) ||
+		b.GetStringFromPath(":stage_id") != strconv.FormatInt(claims.StageID, 10) ||
+		b.GetStringFromPath(":step_name") != claims.Step {
+		beego.Warn(fmt.Sprintf("callback token of publish job %v denied, the request path is: %v", claims.PublishJobID, b.Ctx.Request.URL.Path))
+		b.HandleForbidden("callback token is only valid for the callback of its publish job")
+		return
+	}
+	b.callback = claims
+	b.User = callbackActor
+}
+
+// verifyAccessToken verify the request is in the scopes and project of the access token
+func (b *BaseController) verifyAccessToken(projectID string) error {
+	pattern, _ := b.Ctx.Input.GetData("RouterPattern").(string)
+	method := b.Ctx.Input.Method()
+	resourceType, operation := "", ""
+	if route, err := dao.GetGatewayRoute(strings.TrimPrefix(pattern, "/"), method); err == nil {
+		resourceType, operation = route.ResourceType, route.ResourceOperation
+	}
+	return accesstoken.Permit(b.accessToken, method, resourceType, operation, projectID)
+}
+
+// prepareAuditResource classify the operation and take the snapshot of the resource before change
+func (b *BaseController) prepareAuditResource(params map[string]string) {
+	pattern, _ := b.Ctx.Input.GetData("RouterPattern").(string)
+	operation := ""
+	if route, err := dao.GetGatewayRoute(strings.TrimPrefix(pattern, "/"), b.audit.Method); err == nil {
+		operation = route.ResourceOperation
+		b.audit.ResourceType = route.ResourceType
+	}
+	b.audit.Action = audit.Action(operation, b.audit.Method)
+	if b.audit.Action == "" {
+		return
+	}
+	if b.auditResource = audit.Match(pattern, params); b.auditResource != nil {
+		b.audit.ResourceType = b.auditResource.Type
+		b.audit.ResourceID = b.auditResource.ID
+		b.audit.Before = b.auditResource.Snapshot()
 	}
 }
 
@@ -338,7 +448,7 @@ func (b *BaseController) AuditBlackList(operation string) bool {
 }
 
 func (b *BaseController) Finish() {
-	if b.audit.Method == "GET" || b.AuditBlackList(b.audit.Operation) || b.audit.User == "" || b.audit.Operation == "" {
+	if b.audit.Method == "GET" || b.audit.Action == "" || b.AuditBlackList(b.audit.Operation) || b.audit.User == "" || b.audit.Operation == "" {
 		return
 	}
 	var status int
@@ -350,6 +460,7 @@ func (b *BaseController) Finish() {
 		status = b.Ctx.ResponseWriter.Status
 	}
 	b.audit.OperationStatus = status
+	b.audit.After = b.auditResource.Snapshot()
 	b.audit.Addons = models.NewAddons()
 	if err := dao.AuditInsert(&b.audit); err != nil {
 		log.Log.Error(fmt.Sprintf("audit insert error: %v", err.Error()))
@@ -476,8 +587,59 @@ func (b *BaseController) UserGroup() string {
 	return "system"
 }
 
-// Projects ..
+// Projects return the projects which current user is allowed to access,
+// the projects of the organizations which the user is not member of are excluded
 func (b *BaseController) Projects() ([]int64, error) {
+	projectIDs, err := b.constraintProjects()
+	if err != nil {
+		return nil, err
+	}
+	orgIDs, err := b.OrgIDs()
+	if err != nil || orgIDs == nil || len(projectIDs) == 0 {
+		return projectIDs, err
+	}
+	projects, err := dao.NewProjectModel().GetProjects()
+	if err != nil {
+		log.Log.Error("when filter projects by organization, get projects occur error: %s", err.Error())
+		return nil, err
+	}
+	projectOrgs := map[int64]int64{}
+	for _, item := range projects {
+		projectOrgs[item.ID] = item.OrgID
+	}
+	visible := []int64{}
+	for _, projectID := range projectIDs {
+		if orgID, ok := projectOrgs[projectID]; ok && containsInt64(orgIDs, orgID) {
+			visible = append(visible, projectID)
+		}
+	}
+	return visible, nil
+}
+
+// OrgIDs return the organizations whose resources are visible to current user, 0 is included for the shared resources,
+// nil is returned for the system admin which means all the organizations
+func (b *BaseController) OrgIDs() ([]int64, error) {
+	if b.IsSysAdmin() {
+		return nil, nil
+	}
+	orgIDs, err := dao.NewOrganizationModel().GetUserOrganizationIDs(b.User)
+	if err != nil {
+		log.Log.Error("get organizations of user: %v occur error: %s", b.User, err.Error())
+		return nil, err
+	}
+	return append([]int64{0}, orgIDs...), nil
+}
+
+func containsInt64(items []int64, target int64) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BaseController) constraintProjects() ([]int64, error) {
 	var projectIDStrs []string
 	conValues, err := dao.GetUserResourceConstraintValues("project", b.User)
 	if err != nil {
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-atomci/atomci/internal/core/graph"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/graphql"
+)
+
+// GraphQLController the read only graphql query over projects, publishes, jobs, apps and envs
+type GraphQLController struct {
+	BaseController
+}
+
+// Query execute the graphql query, the request is posted as json or passed by the query string of GET
+func (g *GraphQLController) Query() {
+	params := graphql.Params{}
+	if g.Ctx.Input.Method() == http.MethodGet {
+		params.Query = g.GetStringFromQuery("query")
+		params.OperationName = g.GetStringFromQuery("operationName")
+		if variables := g.GetStringFromQuery("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &params.Variables); err != nil {
+				g.HandleBadRequest("variables 参数不是合法的 json: " + err.Error())
+				return
+			}
+		}
+	} else {
+		g.DecodeJSONReq(&params)
+	}
+	if params.Query == "" {
+		g.HandleBadRequest("query 不能为空")
+		return
+	}
+	projectIDs, err := g.Projects()
+	if err != nil {
+		g.HandleInternalServerError(err.Error())
+		log.Log.Error("Base on permission, filter project error: %s", err.Error())
+		return
+	}
+	result := graph.Execute(g.Ctx.Request.Context(), &graph.Viewer{User: g.User, ProjectIDs: projectIDs}, params)
+	if result.Data == nil {
+		g.Ctx.Output.SetStatus(http.StatusBadRequest)
+	}
+	g.Data["json"] = result
+	g.ServeJSON()
+}
@@ -17,12 +17,18 @@ limitations under the License.
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"reflect"
+	"time"
 
 	"github.com/go-atomci/atomci/constant"
 
 	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/core/project"
+	"github.com/go-atomci/atomci/internal/core/runnermgr"
 	"github.com/go-atomci/atomci/internal/core/settings"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 )
@@ -32,9 +38,51 @@ type IntegrateController struct {
 	BaseController
 }
 
+// verifyOrgAccess only the settings of the organizations of user, or shared by all organizations are accessible
+func (p *IntegrateController) verifyOrgAccess(inOrgs func(orgIDs []int64) (bool, error)) bool {
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return false
+	}
+	ok, err := inOrgs(orgIDs)
+	if err != nil {
+		p.HandleNotFound(err.Error())
+		return false
+	}
+	if !ok {
+		p.HandleForbidden("你不是该组织的成员")
+		return false
+	}
+	return true
+}
+
+func (p *IntegrateController) verifyIntegrateSettingOrg(settingID int64) bool {
+	return p.verifyOrgAccess(func(orgIDs []int64) (bool, error) {
+		return settings.NewSettingManager().IntegrateSettingInOrgs(settingID, orgIDs)
+	})
+}
+
+func (p *IntegrateController) verifyCompileEnvOrg(compileEnvID int64) bool {
+	return p.verifyOrgAccess(func(orgIDs []int64) (bool, error) {
+		return settings.NewSettingManager().CompileEnvInOrgs(compileEnvID, orgIDs)
+	})
+}
+
+func (p *IntegrateController) verifyCreateOrg(orgID int64) bool {
+	return p.verifyOrgAccess(func(orgIDs []int64) (bool, error) {
+		return settings.InOrgs(orgID, orgIDs), nil
+	})
+}
+
 func (p *IntegrateController) GetClusterIntegrateSettings() {
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetIntegrateSettings([]string{constant.IntegrateKubernetes})
+	rsp, err := pm.GetOrgIntegrateSettings([]string{constant.IntegrateKubernetes}, orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get integrate settings occur error: %s", err.Error())
@@ -46,8 +94,13 @@ func (p *IntegrateController) GetClusterIntegrateSettings() {
 
 // GetIntegrateSettings ..
 func (p *IntegrateController) GetIntegrateSettings() {
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetIntegrateSettings(constant.Integratetypes)
+	rsp, err := pm.GetOrgIntegrateSettings(constant.Integratetypes, orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get integrate settings occur error: %s", err.Error())
@@ -60,8 +113,13 @@ func (p *IntegrateController) GetIntegrateSettings() {
 // GetIntegrateSettingsByPagination ..
 func (p *IntegrateController) GetIntegrateSettingsByPagination() {
 	filterQuery := p.GetFilterQuery()
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetIntegrateSettingsByPagination(filterQuery, constant.Integratetypes)
+	rsp, err := pm.GetIntegrateSettingsByPagination(filterQuery, constant.Integratetypes, orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get integrate settings occur error: %s", err.Error())
@@ -72,8 +130,13 @@ func (p *IntegrateController) GetIntegrateSettingsByPagination() {
 }
 
 func (p *IntegrateController) GetSCMIntegrateSettings() {
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetIntegrateSettings(constant.ScmIntegratetypes)
+	rsp, err := pm.GetOrgIntegrateSettings(constant.ScmIntegratetypes, orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get integrate settings occur error: %s", err.Error())
@@ -101,8 +164,13 @@ func getBaseConfigUrl(config interface{}) string {
 // GetSCMIntegrateSettingsByPagination ..
 func (p *IntegrateController) GetSCMIntegrateSettingsByPagination() {
 	filterQuery := p.GetFilterQuery()
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetIntegrateSettingsByPagination(filterQuery, constant.ScmIntegratetypes)
+	rsp, err := pm.GetIntegrateSettingsByPagination(filterQuery, constant.ScmIntegratetypes, orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get integrate settings occur error: %s", err.Error())
@@ -117,6 +185,9 @@ func (p *IntegrateController) CreateIntegrateSetting() {
 	request := settings.IntegrateSettingReq{}
 	creator := p.User
 	p.DecodeJSONReq(&request)
+	if !p.verifyCreateOrg(request.OrgID) {
+		return
+	}
 	pm := settings.NewSettingManager()
 	err := pm.CreateIntegrateSetting(&request, creator)
 	if err != nil {
@@ -142,6 +213,22 @@ func (p *IntegrateController) VerifyRepoConnetion() {
 		if v, has := m["token"]; has {
 			token = fmt.Sprintf("%s", v)
 		}
+		if _, has := m["app_id"]; has {
+			conf := settings.ScmAuthConf{}
+			config, err := request.String()
+			if err == nil {
+				err = json.Unmarshal([]byte(config), &conf)
+			}
+			if err == nil {
+				err = settings.ResolveGithubAppToken(request.Type, &conf)
+			}
+			if err != nil {
+				p.HandleInternalServerError(err.Error())
+				log.Log.Error("get github app installation token occur error: %s", err.Error())
+				return
+			}
+			token = conf.Token
+		}
 	}
 
 	app := apps.NewAppManager()
@@ -170,9 +257,214 @@ func (p *IntegrateController) VerifyIntegrateSetting() {
 	p.ServeJSON()
 }
 
+// the max size of uploaded kubeconfig
+const maxKubeConfigSize = 1 << 20
+
+// ParseKubeConfig parse the uploaded kubeconfig file, the optional form value `context` select the context
+func (p *IntegrateController) ParseKubeConfig() {
+	file, header, err := p.GetFile("file")
+	if err != nil {
+		p.HandleBadRequest(fmt.Sprintf("请上传 kubeconfig 文件: %v", err.Error()))
+		return
+	}
+	defer file.Close()
+	if header.Size > maxKubeConfigSize {
+		p.HandleBadRequest("kubeconfig 文件不能超过 1MB")
+		return
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("read kubeconfig occur error: %s", err.Error())
+		return
+	}
+	rsp, err := settings.ParseKubeConfig(content, p.GetString("context"))
+	if err != nil {
+		p.HandleBadRequest(err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetClusterCapability return the version and api resources of cluster, detect again if `refresh=true`
+func (p *IntegrateController) GetClusterCapability() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	refresh, _ := p.GetBool("refresh", false)
+	rsp, err := settings.NewSettingManager().GetClusterCapability(settingID, refresh)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get cluster: %v capability occur error: %s", settingID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetWorkflowDrivers return the workflow drivers and their capabilities
+func (p *IntegrateController) GetWorkflowDrivers() {
+	rsp, err := settings.NewSettingManager().GetWorkflowDrivers()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get workflow drivers occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetRunners return the self hosted runners
+func (p *IntegrateController) GetRunners() {
+	rsp, err := runnermgr.GetRunners()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get runners occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// DeleteRunner the runner deleted is unable to claim tasks
+func (p *IntegrateController) DeleteRunner() {
+	runnerID, _ := p.GetInt64FromPath(":runner_id")
+	if err := runnermgr.DeleteRunner(runnerID); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("delete runner: %v occur error: %s", runnerID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetIntegrateSettingHealths return the health of integrate settings, only the degraded ones if `degraded=true`
+func (p *IntegrateController) GetIntegrateSettingHealths() {
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
+	degradedOnly, _ := p.GetBool("degraded", false)
+	pm := settings.NewSettingManager()
+	rsp, err := pm.GetIntegrateSettingHealths(orgIDs, degradedOnly)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get integrate setting healths occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CheckIntegrateSetting check the integrate setting immediately
+func (p *IntegrateController) CheckIntegrateSetting() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	pm := settings.NewSettingManager()
+	rsp, err := pm.CheckIntegrateSetting(settingID, apps.VerifyScmSetting)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("check integrate setting occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetIntegrateCredentials the credential versions of integrate setting
+func (p *IntegrateController) GetIntegrateCredentials() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	rsp, err := settings.NewSettingManager().GetIntegrateCredentials(settingID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get integrate setting credentials occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// AddIntegrateCredential add the new credential version of integrate setting
+func (p *IntegrateController) AddIntegrateCredential() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	request := settings.IntegrateCredentialReq{}
+	p.DecodeJSONReq(&request)
+	rsp, err := settings.NewSettingManager().AddIntegrateCredential(settingID, &request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("add integrate setting credential occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ValidateIntegrateCredential verify the credential version with the server
+func (p *IntegrateController) ValidateIntegrateCredential() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	version, _ := p.GetInt64FromPath(":version")
+	rsp, err := settings.NewSettingManager().ValidateIntegrateCredential(settingID, int(version), apps.VerifyScmSetting)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("validate integrate setting: %v credential: %v occur error: %s", settingID, version, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ActivateIntegrateCredential switch the integrate setting to the credential version
+func (p *IntegrateController) ActivateIntegrateCredential() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	version, _ := p.GetInt64FromPath(":version")
+	if err := settings.NewSettingManager().ActivateIntegrateCredential(settingID, int(version)); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("activate integrate setting: %v credential: %v occur error: %s", settingID, version, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// RollbackIntegrateCredential switch the integrate setting back to the previous credential version
+func (p *IntegrateController) RollbackIntegrateCredential() {
+	settingID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(settingID) {
+		return
+	}
+	version, err := settings.NewSettingManager().RollbackIntegrateCredential(settingID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("rollback integrate setting: %v credential occur error: %s", settingID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, version, "")
+	p.ServeJSON()
+}
+
 // UpdateIntegrateSetting ..
 func (p *IntegrateController) UpdateIntegrateSetting() {
 	stageID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(stageID) {
+		return
+	}
 	request := settings.IntegrateSettingReq{}
 	p.DecodeJSONReq(&request)
 	pm := settings.NewSettingManager()
@@ -189,6 +481,9 @@ func (p *IntegrateController) UpdateIntegrateSetting() {
 // DeleteIntegrateSetting ..
 func (p *IntegrateController) DeleteIntegrateSetting() {
 	itemID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyIntegrateSettingOrg(itemID) {
+		return
+	}
 	pm := settings.NewSettingManager()
 	err := pm.DeleteIntegrateSetting(itemID)
 	if err != nil {
@@ -202,8 +497,13 @@ func (p *IntegrateController) DeleteIntegrateSetting() {
 
 // GetCompileEnvs ..
 func (p *IntegrateController) GetCompileEnvs() {
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetCompileEnvs("")
+	rsp, err := pm.GetCompileEnvs("", orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get compile envs occur error: %s", err.Error())
@@ -216,8 +516,13 @@ func (p *IntegrateController) GetCompileEnvs() {
 // GetCompileEnvsByPagination ..
 func (p *IntegrateController) GetCompileEnvsByPagination() {
 	filterQuery := p.GetFilterQuery()
+	orgIDs, err := p.OrgIDs()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
 	pm := settings.NewSettingManager()
-	rsp, err := pm.GetCompileEnvsByPagination(filterQuery)
+	rsp, err := pm.GetCompileEnvsByPagination(filterQuery, orgIDs)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Get compile envs occur error: %s", err.Error())
@@ -232,6 +537,9 @@ func (p *IntegrateController) CreateCompileEnv() {
 	request := settings.CompileEnvReq{}
 	creator := p.User
 	p.DecodeJSONReq(&request)
+	if !p.verifyCreateOrg(request.OrgID) {
+		return
+	}
 	pm := settings.NewSettingManager()
 	err := pm.CreateCompileEnv(&request, creator)
 	if err != nil {
@@ -246,10 +554,13 @@ func (p *IntegrateController) CreateCompileEnv() {
 // UpdateCompileEnv ..
 func (p *IntegrateController) UpdateCompileEnv() {
 	stageID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyCompileEnvOrg(stageID) {
+		return
+	}
 	request := settings.CompileEnvReq{}
 	p.DecodeJSONReq(&request)
 	pm := settings.NewSettingManager()
-	err := pm.UpdateCompileEnv(&request, stageID)
+	err := pm.UpdateCompileEnv(&request, stageID, p.User)
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("update compile env occur error: %s", err.Error())
@@ -262,6 +573,9 @@ func (p *IntegrateController) UpdateCompileEnv() {
 // DeleteCompileEnv ..
 func (p *IntegrateController) DeleteCompileEnv() {
 	itemID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyCompileEnvOrg(itemID) {
+		return
+	}
 	pm := settings.NewSettingManager()
 	err := pm.DeleteCompileEnv(itemID)
 	if err != nil {
@@ -272,3 +586,291 @@ func (p *IntegrateController) DeleteCompileEnv() {
 	p.Data["json"] = NewResult(true, nil, "")
 	p.ServeJSON()
 }
+
+// GetCompileEnvVersions ..
+func (p *IntegrateController) GetCompileEnvVersions() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyCompileEnvOrg(itemID) {
+		return
+	}
+	rsp, err := settings.NewSettingManager().GetCompileEnvVersions(itemID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get compile env versions occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ActivateCompileEnvVersion set the version as the current version of compile env
+func (p *IntegrateController) ActivateCompileEnvVersion() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyCompileEnvOrg(itemID) {
+		return
+	}
+	version := p.GetStringFromPath(":version")
+	if err := settings.NewSettingManager().ActivateCompileEnvVersion(itemID, version); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("activate compile env: %v version: %v occur error: %s", itemID, version, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetCompileCommands ..
+func (p *IntegrateController) GetCompileCommands() {
+	rsp, err := settings.NewSettingManager().GetCompileCommandTemplates()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get compile commands occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateCompileCommand ..
+func (p *IntegrateController) CreateCompileCommand() {
+	request := settings.CompileCommandReq{}
+	p.DecodeJSONReq(&request)
+	rsp, err := settings.NewSettingManager().CreateCompileCommandTemplate(&request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("create compile command occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// UpdateCompileCommand ..
+func (p *IntegrateController) UpdateCompileCommand() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	request := settings.CompileCommandReq{}
+	p.DecodeJSONReq(&request)
+	rsp, err := settings.NewSettingManager().UpdateCompileCommandTemplate(itemID, &request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update compile command: %v occur error: %s", itemID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// DeleteCompileCommand ..
+func (p *IntegrateController) DeleteCompileCommand() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if err := settings.NewSettingManager().DeleteCompileCommandTemplate(itemID); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("delete compile command: %v occur error: %s", itemID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetCompileCommandRevisions ..
+func (p *IntegrateController) GetCompileCommandRevisions() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	rsp, err := settings.NewSettingManager().GetCompileCommandRevisions(itemID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get compile command: %v revisions occur error: %s", itemID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// RestoreCompileCommandRevision restore the command of the revision as the next revision
+func (p *IntegrateController) RestoreCompileCommandRevision() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	revision, _ := p.GetInt64FromPath(":revision")
+	rsp, err := settings.NewSettingManager().RestoreCompileCommandRevision(itemID, revision, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("restore compile command: %v revision: %v occur error: %s", itemID, revision, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetDockerfileTemplates ..
+func (p *IntegrateController) GetDockerfileTemplates() {
+	rsp, err := settings.NewSettingManager().GetDockerfileTemplates()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get dockerfile templates occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateDockerfileTemplate ..
+func (p *IntegrateController) CreateDockerfileTemplate() {
+	request := settings.DockerfileTemplateReq{}
+	p.DecodeJSONReq(&request)
+	rsp, err := settings.NewSettingManager().CreateDockerfileTemplate(&request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("create dockerfile template occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// UpdateDockerfileTemplate ..
+func (p *IntegrateController) UpdateDockerfileTemplate() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	request := settings.DockerfileTemplateReq{}
+	p.DecodeJSONReq(&request)
+	rsp, err := settings.NewSettingManager().UpdateDockerfileTemplate(itemID, &request)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update dockerfile template: %v occur error: %s", itemID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// DeleteDockerfileTemplate ..
+func (p *IntegrateController) DeleteDockerfileTemplate() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if err := settings.NewSettingManager().DeleteDockerfileTemplate(itemID); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("delete dockerfile template: %v occur error: %s", itemID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// DeprecateCompileEnv ..
+func (p *IntegrateController) DeprecateCompileEnv() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyCompileEnvOrg(itemID) {
+		return
+	}
+	request := settings.CompileEnvDeprecationReq{}
+	p.DecodeJSONReq(&request)
+	if err := settings.NewSettingManager().DeprecateCompileEnv(itemID, &request); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("deprecate compile env: %v occur error: %s", itemID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// ValidateCompileEnv pull the image and run the version command of compile env in the cluster
+func (p *IntegrateController) ValidateCompileEnv() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if !p.verifyCompileEnvOrg(itemID) {
+		return
+	}
+	request := struct {
+		Cluster   string `json:"cluster"`
+		Namespace string `json:"namespace"`
+		// Timeout seconds, default is 300
+		Timeout int64 `json:"timeout"`
+	}{}
+	p.DecodeJSONReq(&request)
+	if request.Cluster == "" {
+		p.HandleBadRequest("请选择校验使用的集群")
+		return
+	}
+	if request.Namespace == "" {
+		request.Namespace = "default"
+	}
+	if request.Timeout <= 0 {
+		request.Timeout = 300
+	}
+	pm := settings.NewSettingManager()
+	compileEnv, err := pm.GetCompileEnvByID(itemID)
+	if err != nil {
+		p.HandleNotFound(fmt.Sprintf("编译环境 %v 不存在", itemID))
+		return
+	}
+	rsp, err := kuberes.ValidateCompileEnv(request.Cluster, request.Namespace, compileEnv, time.Duration(request.Timeout)*time.Second)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("validate compile env: %v occur error: %s", itemID, err.Error())
+		return
+	}
+	if err := pm.RecordCompileEnvValidation(itemID, rsp); err != nil {
+		log.Log.Error("record compile env: %v validation occur error: %s", itemID, err.Error())
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetServiceTemplates ..
+func (p *IntegrateController) GetServiceTemplates() {
+	rsp, err := project.NewProjectManager().GetServiceTemplates()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get service templates occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetServiceTemplate ..
+func (p *IntegrateController) GetServiceTemplate() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	rsp, err := project.NewProjectManager().GetServiceTemplate(itemID)
+	if err != nil {
+		p.HandleNotFound(fmt.Sprintf("服务模板 %v 不存在", itemID))
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateServiceTemplate ..
+func (p *IntegrateController) CreateServiceTemplate() {
+	request := project.ServiceTemplateReq{}
+	p.DecodeJSONReq(&request)
+	id, err := project.NewProjectManager().CreateServiceTemplate(&request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("create service template occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, id, "")
+	p.ServeJSON()
+}
+
+// UpdateServiceTemplate ..
+func (p *IntegrateController) UpdateServiceTemplate() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	request := project.ServiceTemplateReq{}
+	p.DecodeJSONReq(&request)
+	if err := project.NewProjectManager().UpdateServiceTemplate(itemID, &request); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update service template occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// DeleteServiceTemplate ..
+func (p *IntegrateController) DeleteServiceTemplate() {
+	itemID, _ := p.GetInt64FromPath(":id")
+	if err := project.NewProjectManager().DeleteServiceTemplate(itemID); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("delete service template occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-atomci/atomci/pkg/metrics"
+
+	"github.com/astaxie/beego"
+)
+
+// MetricsController expose the prometheus metrics, which is verified by the metrics token instead of user token
+type MetricsController struct {
+	beego.Controller
+}
+
+// Metrics write the metrics in prometheus text format
+func (m *MetricsController) Metrics() {
+	token := beego.AppConfig.DefaultString("metrics::token", "")
+	if token != "" && m.Ctx.Input.Header("Authorization") != "Bearer "+token {
+		m.CustomAbort(http.StatusUnauthorized, "Invalid metrics token")
+	}
+	m.Ctx.Output.Header("Content-Type", metrics.ContentType)
+	metrics.WriteTo(m.Ctx.ResponseWriter)
+}
@@ -0,0 +1,1050 @@
+// Code generated by openapi-gen. DO NOT EDIT.
+
+package api
+
+// openAPIDocument the openapi document of api v2
+const openAPIDocument = `{
+  "components": {
+    "schemas": {
+      "Env": {
+        "description": "the deploy env of project",
+        "properties": {
+          "arrange_env": {
+            "type": "string"
+          },
+          "cluster": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "concurrency_policy": {
+            "type": "string"
+          },
+          "create_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "description": {
+            "type": "string"
+          },
+          "id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "name": {
+            "type": "string"
+          },
+          "namespace": {
+            "type": "string"
+          },
+          "project_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "update_at": {
+            "format": "date-time",
+            "type": "string"
+          }
+        },
+        "required": [
+          "id",
+          "project_id",
+          "name",
+          "description",
+          "cluster",
+          "namespace",
+          "arrange_env",
+          "concurrency_policy",
+          "create_at",
+          "update_at"
+        ],
+        "type": "object"
+      },
+      "EnvList": {
+        "description": "the envs of project",
+        "properties": {
+          "items": {
+            "items": {
+              "$ref": "#/components/schemas/Env"
+            },
+            "type": "array"
+          }
+        },
+        "required": [
+          "items"
+        ],
+        "type": "object"
+      },
+      "Error": {
+        "description": "the error of api v2",
+        "properties": {
+          "code": {
+            "description": "stable error code, e.g. NotFound, BadRequest",
+            "type": "string"
+          },
+          "detail": {
+            "description": "the cause of the error",
+            "type": "string"
+          },
+          "message": {
+            "description": "human readable error message",
+            "type": "string"
+          },
+          "params": {
+            "additionalProperties": {},
+            "description": "the params of the error code, the message is localized by header Accept-Language(zh, en)",
+            "type": "object"
+          }
+        },
+        "required": [
+          "code",
+          "message"
+        ],
+        "type": "object"
+      },
+      "ErrorResponse": {
+        "description": "the standard error envelope of api v2",
+        "properties": {
+          "error": {
+            "$ref": "#/components/schemas/Error"
+          }
+        },
+        "required": [
+          "error"
+        ],
+        "type": "object"
+      },
+      "OperationLog": {
+        "description": "the operation log of publish",
+        "properties": {
+          "create_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "creator": {
+            "type": "string"
+          },
+          "id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "job_name": {
+            "type": "string"
+          },
+          "message": {
+            "type": "string"
+          },
+          "publish_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "run_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "stage": {
+            "type": "string"
+          },
+          "stage_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "status": {
+            "description": "one of pending, running, success, failed, end, closed, unknown, terminate-success, terminate-failed, merge-failed, skipped",
+            "type": "string"
+          },
+          "step": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "id",
+          "publish_id",
+          "stage_id",
+          "stage",
+          "step",
+          "type",
+          "creator",
+          "status",
+          "run_id",
+          "job_name",
+          "message",
+          "create_at"
+        ],
+        "type": "object"
+      },
+      "OperationLogList": {
+        "description": "the page of operation logs",
+        "properties": {
+          "has_more": {
+            "type": "boolean"
+          },
+          "items": {
+            "items": {
+              "$ref": "#/components/schemas/OperationLog"
+            },
+            "type": "array"
+          },
+          "next_cursor": {
+            "description": "the cursor of the next page, empty if there is no more",
+            "type": "string"
+          }
+        },
+        "required": [
+          "items",
+          "has_more"
+        ],
+        "type": "object"
+      },
+      "Project": {
+        "description": "the project",
+        "properties": {
+          "create_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "creator": {
+            "type": "string"
+          },
+          "description": {
+            "type": "string"
+          },
+          "id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "name": {
+            "type": "string"
+          },
+          "owner": {
+            "type": "string"
+          },
+          "status": {
+            "format": "int32",
+            "type": "integer"
+          },
+          "update_at": {
+            "format": "date-time",
+            "type": "string"
+          }
+        },
+        "required": [
+          "id",
+          "name",
+          "description",
+          "owner",
+          "creator",
+          "status",
+          "create_at",
+          "update_at"
+        ],
+        "type": "object"
+      },
+      "ProjectList": {
+        "description": "the page of projects",
+        "properties": {
+          "has_more": {
+            "type": "boolean"
+          },
+          "items": {
+            "items": {
+              "$ref": "#/components/schemas/Project"
+            },
+            "type": "array"
+          },
+          "next_cursor": {
+            "description": "the cursor of the next page, empty if there is no more",
+            "type": "string"
+          }
+        },
+        "required": [
+          "items",
+          "has_more"
+        ],
+        "type": "object"
+      },
+      "Publish": {
+        "description": "the publish order of project",
+        "properties": {
+          "create_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "creator": {
+            "type": "string"
+          },
+          "end_at": {
+            "format": "date-time",
+            "nullable": true,
+            "type": "string"
+          },
+          "id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "name": {
+            "type": "string"
+          },
+          "pipeline_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "project_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "stage_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "stage_name": {
+            "type": "string"
+          },
+          "start_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "status": {
+            "description": "one of pending, running, success, failed, end, closed, unknown, terminate-success, terminate-failed, merge-failed, skipped",
+            "type": "string"
+          },
+          "step": {
+            "type": "string"
+          },
+          "step_type": {
+            "type": "string"
+          },
+          "update_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "version_no": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "id",
+          "project_id",
+          "name",
+          "version_no",
+          "creator",
+          "pipeline_id",
+          "stage_id",
+          "stage_name",
+          "step",
+          "step_type",
+          "status",
+          "start_at",
+          "create_at",
+          "update_at"
+        ],
+        "type": "object"
+      },
+      "PublishJob": {
+        "description": "the build/deploy job of publish",
+        "properties": {
+          "create_at": {
+            "format": "date-time",
+            "type": "string"
+          },
+          "duration_in_millis": {
+            "description": "the duration of the finished job",
+            "format": "int64",
+            "type": "integer"
+          },
+          "id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "job_type": {
+            "type": "string"
+          },
+          "operator": {
+            "type": "string"
+          },
+          "progress": {
+            "format": "int32",
+            "type": "integer"
+          },
+          "publish_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "run_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "stage_id": {
+            "format": "int64",
+            "type": "integer"
+          },
+          "status": {
+            "type": "string"
+          },
+          "update_at": {
+            "format": "date-time",
+            "type": "string"
+          }
+        },
+        "required": [
+          "id",
+          "publish_id",
+          "stage_id",
+          "job_type",
+          "status",
+          "run_id",
+          "progress",
+          "duration_in_millis",
+          "operator",
+          "create_at",
+          "update_at"
+        ],
+        "type": "object"
+      },
+      "PublishJobList": {
+        "description": "the page of publish jobs",
+        "properties": {
+          "has_more": {
+            "type": "boolean"
+          },
+          "items": {
+            "items": {
+              "$ref": "#/components/schemas/PublishJob"
+            },
+            "type": "array"
+          },
+          "next_cursor": {
+            "description": "the cursor of the next page, empty if there is no more",
+            "type": "string"
+          }
+        },
+        "required": [
+          "items",
+          "has_more"
+        ],
+        "type": "object"
+      },
+      "PublishList": {
+        "description": "the page of publishes",
+        "properties": {
+          "has_more": {
+            "type": "boolean"
+          },
+          "items": {
+            "items": {
+              "$ref": "#/components/schemas/Publish"
+            },
+            "type": "array"
+          },
+          "next_cursor": {
+            "description": "the cursor of the next page, empty if there is no more",
+            "type": "string"
+          }
+        },
+        "required": [
+          "items",
+          "has_more"
+        ],
+        "type": "object"
+      }
+    },
+    "securitySchemes": {
+      "bearerAuth": {
+        "scheme": "bearer",
+        "type": "http"
+      }
+    }
+  },
+  "info": {
+    "description": "The resource oriented api of AtomCI, which uses cursor pagination and the standard error envelope.",
+    "title": "AtomCI API",
+    "version": "v2"
+  },
+  "openapi": "3.0.3",
+  "paths": {
+    "/projects": {
+      "get": {
+        "description": "list the projects which the user can access, order by id desc",
+        "operationId": "listProjects",
+        "parameters": [
+          {
+            "description": "the next_cursor of the previous page",
+            "in": "query",
+            "name": "cursor",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "page size, default 20, max 100",
+            "in": "query",
+            "name": "limit",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectList"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "List projects",
+        "tags": [
+          "projects"
+        ]
+      }
+    },
+    "/projects/{project_id}": {
+      "get": {
+        "description": "get the project",
+        "operationId": "getProject",
+        "parameters": [
+          {
+            "description": "project id",
+            "in": "path",
+            "name": "project_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Project"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "Get a project",
+        "tags": [
+          "projects"
+        ]
+      }
+    },
+    "/projects/{project_id}/envs": {
+      "get": {
+        "description": "list the deploy envs of the project",
+        "operationId": "listProjectEnvs",
+        "parameters": [
+          {
+            "description": "project id",
+            "in": "path",
+            "name": "project_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/EnvList"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "List the envs of a project",
+        "tags": [
+          "projects"
+        ]
+      }
+    },
+    "/projects/{project_id}/publishes": {
+      "get": {
+        "description": "list the publishes of the project, order by id desc by default",
+        "operationId": "listPublishes",
+        "parameters": [
+          {
+            "description": "project id",
+            "in": "path",
+            "name": "project_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by the current env",
+            "in": "query",
+            "name": "stage_id",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by status, comma separated, e.g. running,failed",
+            "in": "query",
+            "name": "status",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by creator",
+            "in": "query",
+            "name": "creator",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by the project app id included",
+            "in": "query",
+            "name": "app_id",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by create time, inclusive, RFC3339 or 2006-01-02",
+            "in": "query",
+            "name": "created_after",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by create time, exclusive, RFC3339 or 2006-01-02",
+            "in": "query",
+            "name": "created_before",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "one of id, create_at, update_at, prefixed by - for descending, default -id",
+            "in": "query",
+            "name": "sort",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "the next_cursor of the previous page",
+            "in": "query",
+            "name": "cursor",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "page size, default 20, max 100",
+            "in": "query",
+            "name": "limit",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PublishList"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "List the publishes of a project",
+        "tags": [
+          "publishes"
+        ]
+      }
+    },
+    "/projects/{project_id}/publishes/{publish_id}": {
+      "get": {
+        "description": "get the publish",
+        "operationId": "getPublish",
+        "parameters": [
+          {
+            "description": "project id",
+            "in": "path",
+            "name": "project_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "publish id",
+            "in": "path",
+            "name": "publish_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Publish"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "Get a publish",
+        "tags": [
+          "publishes"
+        ]
+      }
+    },
+    "/projects/{project_id}/publishes/{publish_id}/jobs": {
+      "get": {
+        "description": "list the build/deploy jobs of the publish, order by id desc by default",
+        "operationId": "listPublishJobs",
+        "parameters": [
+          {
+            "description": "project id",
+            "in": "path",
+            "name": "project_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "publish id",
+            "in": "path",
+            "name": "publish_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by the env",
+            "in": "query",
+            "name": "stage_id",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by status, comma separated, e.g. RUNNING,FAILURE",
+            "in": "query",
+            "name": "status",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by job type, one of build, deploy, e2e-test",
+            "in": "query",
+            "name": "job_type",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by operator",
+            "in": "query",
+            "name": "operator",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by create time, inclusive, RFC3339 or 2006-01-02",
+            "in": "query",
+            "name": "created_after",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by create time, exclusive, RFC3339 or 2006-01-02",
+            "in": "query",
+            "name": "created_before",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "one of id, create_at, update_at, prefixed by - for descending, default -id",
+            "in": "query",
+            "name": "sort",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "the next_cursor of the previous page",
+            "in": "query",
+            "name": "cursor",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "page size, default 20, max 100",
+            "in": "query",
+            "name": "limit",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PublishJobList"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "List the jobs of a publish",
+        "tags": [
+          "publishes"
+        ]
+      }
+    },
+    "/projects/{project_id}/publishes/{publish_id}/operation-logs": {
+      "get": {
+        "description": "list the operation logs of the publish, order by id desc by default",
+        "operationId": "listPublishOperationLogs",
+        "parameters": [
+          {
+            "description": "project id",
+            "in": "path",
+            "name": "project_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "publish id",
+            "in": "path",
+            "name": "publish_id",
+            "required": true,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by the env",
+            "in": "query",
+            "name": "stage_id",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "description": "filter by step name",
+            "in": "query",
+            "name": "step",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by operator",
+            "in": "query",
+            "name": "creator",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by create time, inclusive, RFC3339 or 2006-01-02",
+            "in": "query",
+            "name": "created_after",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "filter by create time, exclusive, RFC3339 or 2006-01-02",
+            "in": "query",
+            "name": "created_before",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "one of id, create_at, prefixed by - for descending, default -id",
+            "in": "query",
+            "name": "sort",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "the next_cursor of the previous page",
+            "in": "query",
+            "name": "cursor",
+            "required": false,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "description": "page size, default 20, max 100",
+            "in": "query",
+            "name": "limit",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OperationLogList"
+                }
+              }
+            },
+            "description": "OK"
+          },
+          "default": {
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            },
+            "description": "the standard error envelope"
+          }
+        },
+        "summary": "List the operation logs of a publish",
+        "tags": [
+          "publishes"
+        ]
+      }
+    }
+  },
+  "security": [
+    {
+      "bearerAuth": []
+    }
+  ],
+  "servers": [
+    {
+      "url": "/atomci/api/v2"
+    }
+  ]
+}
+`
@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"github.com/go-atomci/atomci/internal/core/organization"
+	"github.com/go-atomci/atomci/internal/core/project"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// OrganizationController the organizations isolate the projects, settings and quotas of departments
+type OrganizationController struct {
+	BaseController
+}
+
+// GetOrganizations return all the organizations for system admin, or the organizations which current user is member of
+func (o *OrganizationController) GetOrganizations() {
+	orgIDs, err := o.OrgIDs()
+	if err != nil {
+		o.HandleInternalServerError(err.Error())
+		return
+	}
+	rsp, err := organization.NewOrganizationManager().GetOrganizations(orgIDs)
+	if err != nil {
+		o.HandleInternalServerError(err.Error())
+		log.Log.Error("get organizations error: %s", err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, rsp, "")
+	o.ServeJSON()
+}
+
+// CreateOrganization ..
+func (o *OrganizationController) CreateOrganization() {
+	request := organization.OrganizationReq{}
+	o.DecodeJSONReq(&request)
+	rsp, err := organization.NewOrganizationManager().CreateOrganization(&request, o.User)
+	if err != nil {
+		o.HandleBadRequest(err.Error())
+		log.Log.Error("create organization error: %s", err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, rsp, "")
+	o.ServeJSON()
+}
+
+// GetOrganization return the organization with the usage of quotas
+func (o *OrganizationController) GetOrganization() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	om := organization.NewOrganizationManager()
+	if !om.IsOrganizationMember(orgID, o.User) {
+		o.HandleForbidden("你不是该组织的成员")
+		return
+	}
+	rsp, err := om.GetOrganization(orgID)
+	if err != nil {
+		o.HandleInternalServerError(err.Error())
+		log.Log.Error("get organization: %v error: %s", orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, rsp, "")
+	o.ServeJSON()
+}
+
+// UpdateOrganization ..
+func (o *OrganizationController) UpdateOrganization() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	request := organization.OrganizationReq{}
+	o.DecodeJSONReq(&request)
+	if err := organization.NewOrganizationManager().UpdateOrganization(orgID, &request); err != nil {
+		o.HandleBadRequest(err.Error())
+		log.Log.Error("update organization: %v error: %s", orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, nil, "")
+	o.ServeJSON()
+}
+
+// DeleteOrganization ..
+func (o *OrganizationController) DeleteOrganization() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	if err := organization.NewOrganizationManager().DeleteOrganization(orgID); err != nil {
+		o.HandleBadRequest(err.Error())
+		log.Log.Error("delete organization: %v error: %s", orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, nil, "")
+	o.ServeJSON()
+}
+
+// GetOrganizationMembers ..
+func (o *OrganizationController) GetOrganizationMembers() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	om := organization.NewOrganizationManager()
+	if !om.IsOrganizationMember(orgID, o.User) {
+		o.HandleForbidden("你不是该组织的成员")
+		return
+	}
+	rsp, err := om.GetOrganizationMembers(orgID)
+	if err != nil {
+		o.HandleInternalServerError(err.Error())
+		log.Log.Error("get members of organization: %v error: %s", orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, rsp, "")
+	o.ServeJSON()
+}
+
+// AddOrganizationMember only the admin of organization is allowed
+func (o *OrganizationController) AddOrganizationMember() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	request := organization.OrganizationMemberReq{}
+	o.DecodeJSONReq(&request)
+	om := organization.NewOrganizationManager()
+	if !om.IsOrganizationAdmin(orgID, o.User) {
+		o.HandleForbidden("仅组织管理员允许管理组织成员")
+		return
+	}
+	if err := om.AddOrganizationMember(orgID, &request); err != nil {
+		o.HandleBadRequest(err.Error())
+		log.Log.Error("add member of organization: %v error: %s", orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, nil, "")
+	o.ServeJSON()
+}
+
+// RemoveOrganizationMember only the admin of organization is allowed
+func (o *OrganizationController) RemoveOrganizationMember() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	user := o.GetStringFromPath(":user")
+	om := organization.NewOrganizationManager()
+	if !om.IsOrganizationAdmin(orgID, o.User) {
+		o.HandleForbidden("仅组织管理员允许管理组织成员")
+		return
+	}
+	if err := om.RemoveOrganizationMember(orgID, user); err != nil {
+		o.HandleBadRequest(err.Error())
+		log.Log.Error("remove member: %v of organization: %v error: %s", user, orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, nil, "")
+	o.ServeJSON()
+}
+
+// MoveOrganizationProject move the project into organization, org id 0 means move out of organization
+func (o *OrganizationController) MoveOrganizationProject() {
+	orgID, _ := o.GetInt64FromPath(":org_id")
+	projectID, _ := o.GetInt64FromPath(":project_id")
+	if err := project.NewProjectManager().MoveProjectToOrganization(projectID, orgID); err != nil {
+		o.HandleBadRequest(err.Error())
+		log.Log.Error("move project: %v to organization: %v error: %s", projectID, orgID, err.Error())
+		return
+	}
+	o.Data["json"] = NewResult(true, nil, "")
+	o.ServeJSON()
+}
@@ -17,11 +17,14 @@ limitations under the License.
 package api
 
 import (
-	"github.com/astaxie/beego"
+	"bytes"
+	"fmt"
+
 	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
 	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
-	"github.com/go-atomci/atomci/pkg/notification"
+	"github.com/go-atomci/atomci/internal/models"
 )
 
 // PipelineController ...
@@ -54,30 +57,60 @@ func (p *PipelineController) RunStep() {
 	publishID, _ := p.GetInt64FromPath(":publish_id")
 	stageID, _ := p.GetInt64FromPath(":stage_id")
 	stepName := p.GetStringFromPath(":step_name")
+	// step_index is optional, run one of the parallel ready steps
+	stepIndex, _ := p.GetInt64FromQuery("step_index")
+	// async is optional, the client polls the task opts in the background trigger, task::async by default
+	async, _ := p.GetBool("async", publish.StepTaskAsync())
 
 	pm := pipelinemgr.NewPipelineManager()
+	if stepIndex > 0 {
+		if err := pm.SwitchPublishStep(publishID, stageID, int(stepIndex)); err != nil {
+			p.HandleInternalServerError(err.Error())
+			log.Log.Error("switch publish step error: %s", err.Error())
+			return
+		}
+	}
 	var err error
 	var publishStatus, runID int64
 	var message, jobName string
+	var inputs map[string]string
 	switch stepName {
 	case "manual":
 		request := &pipelinemgr.ManualStepReq{}
 		p.DecodeJSONReq(&request)
 		message = request.Message
-		publishStatus, err = pm.RunManualStep(publishID, stageID, request)
+		publishStatus, inputs, err = pm.RunManualStep(publishID, stageID, creator, request)
 	case "build":
 		request := &pipelinemgr.BuildStepReq{}
 		p.DecodeJSONReq(&request)
+		if request.ActionName == "trigger" && async {
+			p.submitStepTask(projectID, publishID, stageID, stepName, request)
+			return
+		}
 		publishStatus, runID, jobName, err = pm.RunBuildStep(projectID, publishID, stageID, creator, stepName, request)
 	case "deploy":
 		request := &pipelinemgr.DeployStepReq{}
 		p.DecodeJSONReq(&request)
+		if request.ActionName == "trigger" && async {
+			p.submitStepTask(projectID, publishID, stageID, stepName, request)
+			return
+		}
 		publishStatus, runID, jobName, err = pm.RunDeployStep(projectID, publishID, stageID, creator, stepName, request)
+	case "promote":
+		publishStatus, err = pm.RunPromoteStep(projectID, publishID, stageID, creator)
+	case models.StepE2ETest:
+		request := &pipelinemgr.E2ETestStepReq{}
+		p.DecodeJSONReq(&request)
+		if request.ActionName == "trigger" && async {
+			p.submitStepTask(projectID, publishID, stageID, stepName, request)
+			return
+		}
+		publishStatus, runID, jobName, err = pm.RunE2ETestStep(projectID, publishID, stageID, creator, request)
 	default:
 		log.Log.Error("unknow step_name: %s", stepName)
 	}
 	publishmgr := publish.NewPublishManager()
-	updateErr := publishmgr.UpdatePublish(publishID, stageID, publishStatus, runID, creator, message, jobName)
+	updateErr := publishmgr.UpdatePublishWithInputs(publishID, stageID, publishStatus, runID, creator, message, jobName, inputs)
 	if err != nil || updateErr != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("Run Publish error: %s", err.Error())
@@ -87,6 +120,32 @@ func (p *PipelineController) RunStep() {
 	p.ServeJSON()
 }
 
+// submitStepTask run the heavy step trigger in background, return the task for polling
+func (p *PipelineController) submitStepTask(projectID, publishID, stageID int64, stepName string, request interface{}) {
+	task, err := publish.NewPublishManager().SubmitStepTask(projectID, publishID, stageID, stepName, p.User, request)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("submit step task error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, task, "")
+	p.ServeJSON()
+}
+
+// GetStepTask ..
+func (p *PipelineController) GetStepTask() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	taskID, _ := p.GetInt64FromPath(":task_id")
+	rsp, err := publish.NewPublishManager().GetStepTask(projectID, taskID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get step task error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // RunStepCallback ..
 func (p *PipelineController) RunStepCallback() {
 	creator := p.User
@@ -94,62 +153,155 @@ func (p *PipelineController) RunStepCallback() {
 	stageID, _ := p.GetInt64FromPath(":stage_id")
 	stepName := p.GetStringFromPath(":step_name")
 
-	pm := pipelinemgr.NewPipelineManager()
-	var err error
-	var publishStatus int64
-	var message string
 	switch stepName {
-	case "build", "deploy":
-		request := &pipelinemgr.BuildStepCallbackReq{}
-		p.DecodeJSONReq(&request)
-		publishStatus, err = pm.RunBuildDeployCallBackStep(request)
+	case "build", "deploy", models.StepE2ETest:
 	default:
 		log.Log.Error("callback occur erro: unknow step_name: %s", stepName)
+		p.HandleBadRequest(fmt.Sprintf("unknown step: %s", stepName))
+		return
 	}
+	request := &pipelinemgr.BuildStepCallbackReq{}
+	p.DecodeJSONReq(&request)
+	if p.callback != nil && p.callback.PublishJobID != request.PublishJobID {
+		p.HandleForbidden(fmt.Sprintf("callback token is not issued to publish job %v", request.PublishJobID))
+		log.Log.Error("callback token of publish job %v is used by publish job %v", p.callback.PublishJobID, request.PublishJobID)
+		return
+	}
+	if err := request.Verify(stepName); err != nil {
+		p.HandleForbidden(err.Error())
+		log.Log.Error("callback of publish job %v verify failed: %s", request.PublishJobID, err.Error())
+		return
+	}
+	rsp, err := publish.NewPublishManager().HandleJobCallback(&publish.JobCallbackReq{
+		PublishID:      publishID,
+		StageID:        stageID,
+		PublishJobID:   request.PublishJobID,
+		IdempotencyKey: request.IdempotencyKey,
+		Step:           stepName,
+		Source:         models.CallbackSourceJenkins,
+		Creator:        creator,
+	})
 	if err != nil {
 		p.HandleInternalServerError(err.Error())
 		log.Log.Error("RunStep callback error: %s", err.Error())
 		return
 	}
-	var runID int64
-	var jobName string
-	publishmgr := publish.NewPublishManager()
-	updateErr := publishmgr.UpdatePublish(publishID, stageID, publishStatus, runID, creator, message, jobName)
-	if updateErr != nil {
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetJobCallbacks get the callbacks of publish jobs, filter the dead callbacks by status=DEAD
+func (p *PipelineController) GetJobCallbacks() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishJobID, _ := p.GetInt64("publish_job_id", 0)
+	rsp, err := publish.NewPublishManager().GetJobCallbacks(projectID, p.GetString("status"), publishJobID)
+	if err != nil {
 		p.HandleInternalServerError(err.Error())
-		log.Log.Error("RunStep callback, update publish Order occur error: %s", err.Error())
-		return
-	}
-	publishInfo, _ := publishmgr.GetPublishInfo(publishID)
-
-	dingEnable := beego.AppConfig.DefaultBool("notification::dingEnable", false)
-	mailEnable := beego.AppConfig.DefaultBool("notification::mailEnable", false)
-	dingURL := beego.AppConfig.String("notification::ding")
-
-	smtpHost := beego.AppConfig.String("notification::smtpHost")
-	smtpAccount := beego.AppConfig.String("notification::smtpAccount")
-	smtpPassword := beego.AppConfig.String("notification::smtpPassword")
-	smtpPort, _ := beego.AppConfig.Int("notification::smtpPort")
-
-	pushOptions := notification.PushNotification{
-		// message
-		Status:      publishStatus,
-		PublishName: publishInfo.Name,
-		StageName:   publishInfo.StageName,
-		StepName:    publishInfo.Step,
-		// dingding
-		DingURL:    dingURL,
-		DingEnable: dingEnable,
-		// email
-		EmailEnable:   mailEnable,
-		EmailHost:     smtpHost,
-		EmailPort:     smtpPort,
-		EmailUser:     smtpAccount,
-		EmailPassword: smtpPassword,
-	}
-	// publishID
-	go notification.Send(pushOptions)
+		log.Log.Error("Get job callbacks occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ReplayJobCallback process the dead callback again
+func (p *PipelineController) ReplayJobCallback() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	callbackID, _ := p.GetInt64FromPath(":callback_id")
+	rsp, err := publish.NewPublishManager().ReplayJobCallback(projectID, callbackID, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Replay job callback occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetJobQueue ..
+func (p *PipelineController) GetJobQueue() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetJobQueue(projectID, stageID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get job queue occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
 
+// GetPublishJobLog get the console log of the publish job from the offset, the cli tails the log by it
+func (p *PipelineController) GetPublishJobLog() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	jobID, _ := p.GetInt64FromPath(":job_id")
+	start, err := p.GetInt64FromQuery("start")
+	if err != nil {
+		p.HandleBadRequest(fmt.Sprintf("start 参数错误: %v", p.GetStringFromQuery("start")))
+		return
+	}
+	if start < 0 {
+		start = 0
+	}
+	job, err := dao.NewPublishJobModel().GetPublishJobByID(jobID)
+	if err != nil || job.ProjectID != projectID || job.PublishID != publishID {
+		p.HandleNotFound("流水线任务不存在")
+		return
+	}
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetJobLog(job, start)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get publish job: %v log occur error: %s", jobID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetAppImageTags list the image tags of app in the registry of stage
+func (p *PipelineController) GetAppImageTags() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	appID, _ := p.GetInt64FromPath(":app_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetAppImageTags(projectID, stageID, appID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get app image tags occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// PreviewImageRetention dry run the image cleanup policy of project, return the tags will be deleted
+func (p *PipelineController) PreviewImageRetention() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.PlanImageRetention(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Preview image retention occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CancelJobQueueItem ..
+func (p *PipelineController) CancelJobQueueItem() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	queueID, _ := p.GetInt64FromPath(":queue_id")
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.CancelJobQueueItem(projectID, queueID, p.User); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Cancel job queue item occur error: %s", err.Error())
+		return
+	}
 	p.Data["json"] = NewResult(true, nil, "")
 	p.ServeJSON()
 }
@@ -272,3 +424,284 @@ func (p *PipelineController) DeleteTaskTmpl() {
 	p.Data["json"] = NewResult(true, nil, "")
 	p.ServeJSON()
 }
+
+// ReportTerraform the terraform output reported by build job, only the callback token of the job is accepted
+func (p *PipelineController) ReportTerraform() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	publishJobID, _ := p.GetInt64FromQuery("publish_job_id")
+	if p.callback == nil || p.callback.PublishJobID != publishJobID {
+		p.HandleForbidden(fmt.Sprintf("terraform output is only accepted from publish job %v", publishJobID))
+		return
+	}
+	request := &pipelinemgr.TerraformReportReq{
+		PublishJobID: publishJobID,
+		AppName:      p.GetStringFromQuery("app"),
+		Dir:          p.GetStringFromQuery("dir"),
+		Workspace:    p.GetStringFromQuery("workspace"),
+		Action:       p.GetStringFromQuery("action"),
+	}
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.ReportTerraform(projectID, publishID, stageID, request, string(p.Ctx.Input.CopyBody(1<<32))); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("report terraform output error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetTerraformPlans ..
+func (p *PipelineController) GetTerraformPlans() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetTerraformPlans(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get terraform plans error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetPublishGraph return the stage/step/sub task graph of publish with statuses and timings
+func (p *PipelineController) GetPublishGraph() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetPublishGraph(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get publish graph error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ReportDBMigration the db migration version reported by build job or db rollback job, only the callback token of the job is accepted
+func (p *PipelineController) ReportDBMigration() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	publishJobID, _ := p.GetInt64FromQuery("publish_job_id")
+	if p.callback == nil || p.callback.PublishJobID != publishJobID {
+		p.HandleForbidden(fmt.Sprintf("db migration version is only accepted from publish job %v", publishJobID))
+		return
+	}
+	projectAppID, _ := p.GetInt64FromQuery("project_app_id")
+	request := &pipelinemgr.DBMigrationReportReq{
+		PublishJobID: publishJobID,
+		ProjectAppID: projectAppID,
+		Phase:        p.GetStringFromQuery("phase"),
+	}
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.ReportDBMigration(projectID, publishID, stageID, request, string(p.Ctx.Input.CopyBody(1<<20))); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("report db migration version error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetDBMigrations ..
+func (p *PipelineController) GetDBMigrations() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetDBMigrations(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get db migrations error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ReportE2ETest the junit report of e2e suite reported by e2e test job, only the callback token of the job is accepted
+func (p *PipelineController) ReportE2ETest() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	publishJobID, _ := p.GetInt64FromQuery("publish_job_id")
+	if p.callback == nil || p.callback.PublishJobID != publishJobID {
+		p.HandleForbidden(fmt.Sprintf("e2e test report is only accepted from publish job %v", publishJobID))
+		return
+	}
+	suiteIndex, _ := p.GetInt64FromQuery("suite")
+	request := &pipelinemgr.E2ETestReportReq{
+		PublishJobID: publishJobID,
+		SuiteIndex:   int(suiteIndex),
+	}
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.ReportE2ETest(projectID, publishID, stageID, request, bytes.NewReader(p.Ctx.Input.CopyBody(32<<20))); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("report e2e test error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetE2ETestReports ..
+func (p *PipelineController) GetE2ETestReports() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetE2ETestReports(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get e2e test reports error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ReportPerfTest the perf test result reported by e2e test job, only the callback token of the job is accepted
+func (p *PipelineController) ReportPerfTest() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	publishJobID, _ := p.GetInt64FromQuery("publish_job_id")
+	if p.callback == nil || p.callback.PublishJobID != publishJobID {
+		p.HandleForbidden(fmt.Sprintf("perf test result is only accepted from publish job %v", publishJobID))
+		return
+	}
+	taskIndex, _ := p.GetInt64FromQuery("task")
+	request := &pipelinemgr.PerfTestReportReq{
+		PublishJobID: publishJobID,
+		TaskIndex:    int(taskIndex),
+	}
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.ReportPerfTest(projectID, publishID, stageID, request, p.Ctx.Input.CopyBody(64<<20)); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("report perf test error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetPerfTestResults ..
+func (p *PipelineController) GetPerfTestResults() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetPerfTestResults(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get perf test results error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// ReportQuality the unit test, coverage, sonar or vulnerability result reported by build job, only the callback token of the job is accepted
+func (p *PipelineController) ReportQuality() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	publishJobID, _ := p.GetInt64FromQuery("publish_job_id")
+	if p.callback == nil || p.callback.PublishJobID != publishJobID {
+		p.HandleForbidden(fmt.Sprintf("quality report is only accepted from publish job %v", publishJobID))
+		return
+	}
+	request := &pipelinemgr.QualityReportReq{}
+	p.DecodeJSONReq(&request)
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.ReportQuality(projectID, publishID, stageID, publishJobID, p.GetStringFromQuery("app"), p.GetStringFromQuery("kind"), request); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("report quality error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// ReportSBOM the cyclonedx sbom of the image built by build job, only the callback token of the job is accepted
+func (p *PipelineController) ReportSBOM() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	publishJobID, _ := p.GetInt64FromQuery("publish_job_id")
+	if p.callback == nil || p.callback.PublishJobID != publishJobID {
+		p.HandleForbidden(fmt.Sprintf("sbom is only accepted from publish job %v", publishJobID))
+		return
+	}
+	projectAppID, _ := p.GetInt64FromQuery("project_app_id")
+	pm := pipelinemgr.NewPipelineManager()
+	if err := pm.ReportSBOM(projectID, publishID, stageID, publishJobID, projectAppID, p.GetStringFromQuery("image"), p.Ctx.Input.CopyBody(64<<20)); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("report sbom error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetPublishSBOMs the sboms of the images built by the publish
+func (p *PipelineController) GetPublishSBOMs() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetPublishSBOMs(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get publish sboms error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// DownloadSBOM download the cyclonedx json of sbom
+func (p *PipelineController) DownloadSBOM() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	sbomID, _ := p.GetInt64FromPath(":sbom_id")
+	pm := pipelinemgr.NewPipelineManager()
+	sbom, err := pm.GetSBOM(projectID, sbomID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("download sbom error: %s", err.Error())
+		return
+	}
+	p.Ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+	p.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=sbom-%v-%v.json", sbom.AppName, sbom.PublishJobID))
+	p.Ctx.Output.Body([]byte(sbom.Content))
+}
+
+// FindSBOMDeployments the deployments whose image contains the package, eg: ?name=log4j-core&version=2.14.1
+func (p *PipelineController) FindSBOMDeployments() {
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.FindSBOMDeployments(p.GetStringFromQuery("name"), p.GetStringFromQuery("version"))
+	if err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("find sbom deployments error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetQualitySummary the quality verdict of publish, evaluated by the quality gate of stage_id if given
+func (p *PipelineController) GetQualitySummary() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromQuery("stage_id")
+	pm := pipelinemgr.NewPipelineManager()
+	rsp, err := pm.GetQualitySummary(projectID, publishID, stageID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get quality summary error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
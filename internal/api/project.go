@@ -17,11 +17,16 @@ limitations under the License.
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/go-atomci/atomci/constant"
 	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/core/organization"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
 	"github.com/go-atomci/atomci/internal/core/project"
+	mycasbin "github.com/go-atomci/atomci/internal/middleware/casbin"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 )
@@ -40,6 +45,10 @@ func (p *ProjectController) Create() {
 	}
 	req := &project.ProjectReq{}
 	p.DecodeJSONReq(req)
+	if req.OrgID != 0 && !organization.NewOrganizationManager().IsOrganizationMember(req.OrgID, user.User) {
+		p.HandleForbidden("你不是该组织的成员，不允许在该组织下创建项目")
+		return
+	}
 	pm := project.NewProjectManager()
 
 	result, err := pm.CreateProject(user.User, groupName, req)
@@ -301,6 +310,65 @@ func (p *ProjectController) Delete() {
 	p.ServeJSON()
 }
 
+// ExportProject export the apps, arranges, pipelines and envs of the project as a portable bundle
+func (p *ProjectController) ExportProject() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	bundle, err := project.NewProjectManager().ExportProject(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Export project: %v occur error: %s", projectID, err.Error())
+		return
+	}
+	body, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		return
+	}
+	p.Ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+	p.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=project-%v-%v.json", projectID, time.Now().Format("20060102150405")))
+	p.Ctx.Output.Body(body)
+}
+
+// ImportProject import the bundle as a new project
+func (p *ProjectController) ImportProject() {
+	orgID, _ := p.GetInt64FromQuery("org_id")
+	if orgID < 0 {
+		orgID = 0
+	}
+	if orgID != 0 && !organization.NewOrganizationManager().IsOrganizationMember(orgID, p.User) {
+		p.HandleForbidden("你不是该组织的成员，不允许在该组织下创建项目")
+		return
+	}
+	p.importProject(0, &project.ProjectImportReq{Name: p.GetStringFromQuery("name"), OrgID: orgID})
+}
+
+// ImportProjectConfig import the bundle into the existing project, the objects with the same key are updated
+func (p *ProjectController) ImportProjectConfig() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	p.importProject(projectID, &project.ProjectImportReq{})
+}
+
+func (p *ProjectController) importProject(projectID int64, req *project.ProjectImportReq) {
+	bundle := &project.ProjectBundle{}
+	if err := json.Unmarshal(p.Ctx.Input.RequestBody, bundle); err != nil {
+		p.HandleBadRequest(fmt.Sprintf("项目导出包解析失败: %s", err.Error()))
+		return
+	}
+	req.DryRun, _ = p.GetBoolFromQuery("dry_run")
+	groupName := p.UserGroup()
+	if groupName == "" {
+		groupName = "system"
+	}
+	rsp, err := project.NewProjectManager().ImportProject(projectID, bundle, req, p.User, groupName)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Import project: %v occur error: %s", projectID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // GetProject ...
 func (p *ProjectController) GetProject() {
 	projectID, _ := p.GetInt64FromPath(":project_id")
@@ -414,6 +482,37 @@ func (p *ProjectController) CreateProjectEnv() {
 	p.ServeJSON()
 }
 
+// CloneProjectEnv ..
+func (p *ProjectController) CloneProjectEnv() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	envID, _ := p.GetInt64FromPath(":env_id")
+	request := project.ProjectEnvCloneReq{}
+	p.DecodeJSONReq(&request)
+	pm := project.NewProjectManager()
+	rsp, err := pm.CloneProjectEnv(projectID, envID, &request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("clone project env occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetPreviewEnvs ..
+func (p *ProjectController) GetPreviewEnvs() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetPreviewEnvs(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get preview envs occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // UpdateProjectEnv ..
 func (p *ProjectController) UpdateProjectEnv() {
 	stageID, _ := p.GetInt64FromPath(":env_id")
@@ -444,6 +543,238 @@ func (p *ProjectController) DeleteProjectEnv() {
 	p.ServeJSON()
 }
 
+// GetDeployFreezes ..
+func (p *ProjectController) GetDeployFreezes() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetDeployFreezes(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get deploy freezes occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateDeployFreeze ..
+func (p *ProjectController) CreateDeployFreeze() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	request := project.DeployFreezeReq{}
+	p.DecodeJSONReq(&request)
+	pm := project.NewProjectManager()
+	id, err := pm.CreateDeployFreeze(projectID, &request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("create deploy freeze occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, id, "")
+	p.ServeJSON()
+}
+
+// DeleteDeployFreeze ..
+func (p *ProjectController) DeleteDeployFreeze() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	freezeID, _ := p.GetInt64FromPath(":freeze_id")
+	pm := project.NewProjectManager()
+	if err := pm.DeleteDeployFreeze(projectID, freezeID); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("delete deploy freeze occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetProjectEnvLock ..
+func (p *ProjectController) GetProjectEnvLock() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	envID, _ := p.GetInt64FromPath(":env_id")
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetProjectEnvLock(projectID, envID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get project env lock occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// LockProjectEnv ..
+func (p *ProjectController) LockProjectEnv() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	envID, _ := p.GetInt64FromPath(":env_id")
+	request := project.EnvLockReq{}
+	p.DecodeJSONReq(&request)
+	pm := project.NewProjectManager()
+	rsp, err := pm.LockProjectEnv(projectID, envID, &request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("lock project env occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// UnlockProjectEnv ..
+func (p *ProjectController) UnlockProjectEnv() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	envID, _ := p.GetInt64FromPath(":env_id")
+	pm := project.NewProjectManager()
+	if err := pm.UnlockProjectEnv(projectID, envID, p.User); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("unlock project env occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetAgentTemplate ..
+func (p *ProjectController) GetAgentTemplate() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetAgentTemplate(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get agent template occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// UpdateAgentTemplate ..
+func (p *ProjectController) UpdateAgentTemplate() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	request := pipelinemgr.AgentTemplate{}
+	p.DecodeJSONReq(&request)
+	pm := project.NewProjectManager()
+	if err := pm.UpdateAgentTemplate(projectID, &request, p.User); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update agent template occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetServiceAccounts ..
+func (p *ProjectController) GetServiceAccounts() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetServiceAccounts(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get service accounts occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateServiceAccount ..
+func (p *ProjectController) CreateServiceAccount() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	request := models.ServiceAccountReq{}
+	p.DecodeJSONReq(&request)
+	pm := project.NewProjectManager()
+	rsp, err := pm.CreateServiceAccount(projectID, &request)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("create service account occur error: %s", err.Error())
+		return
+	}
+	// service accounts never login, grant the system member role on creation
+	e, err := mycasbin.NewCasbin()
+	if err != nil {
+		log.Log.Error("add service account role, new casbin instance error: %s", err.Error())
+		p.HandleInternalServerError(err.Error())
+		return
+	}
+	if _, err := e.AddRoleForUser(rsp.User, constant.SystemMemberRole); err != nil {
+		log.Log.Error("add %v service account %v error: %s", constant.SystemMemberRole, rsp.User, err.Error())
+	}
+	if err := e.SavePolicy(); err != nil {
+		log.Log.Error("save casbin policy error: %s", err.Error())
+		p.HandleInternalServerError(err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// DeleteServiceAccount ..
+func (p *ProjectController) DeleteServiceAccount() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	account := p.GetStringFromPath(":account")
+	pm := project.NewProjectManager()
+	if err := pm.DeleteServiceAccount(projectID, account); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("delete service account occur error: %s", err.Error())
+		return
+	}
+	if e, err := mycasbin.NewCasbin(); err == nil {
+		if _, err := e.DeleteUser(account); err != nil {
+			log.Log.Error("delete service account %v roles error: %s", account, err.Error())
+		} else if err := e.SavePolicy(); err != nil {
+			log.Log.Error("save casbin policy error: %s", err.Error())
+		}
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetServiceAccountTokens ..
+func (p *ProjectController) GetServiceAccountTokens() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	account := p.GetStringFromPath(":account")
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetServiceAccountTokens(projectID, account)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get service account tokens occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateServiceAccountToken the token is only returned once
+func (p *ProjectController) CreateServiceAccountToken() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	account := p.GetStringFromPath(":account")
+	request := models.AccessTokenReq{}
+	p.DecodeJSONReq(&request)
+	pm := project.NewProjectManager()
+	rsp, err := pm.CreateServiceAccountToken(projectID, account, &request, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("create service account token occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// RevokeServiceAccountToken ..
+func (p *ProjectController) RevokeServiceAccountToken() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	account := p.GetStringFromPath(":account")
+	tokenID, _ := p.GetInt64FromPath(":token_id")
+	pm := project.NewProjectManager()
+	if err := pm.RevokeServiceAccountToken(projectID, account, tokenID); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("revoke service account token occur error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
 // GetProjectPipelines ..
 func (p *ProjectController) GetProjectPipelines() {
 	projectID, _ := p.GetInt64FromPath(":project_id")
@@ -548,6 +879,21 @@ func (p *ProjectController) CreateApp() {
 	p.ServeJSON()
 }
 
+// ScaffoldService create the app of the service template into the project, arrange it into the env and create the pipeline
+func (p *ProjectController) ScaffoldService() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	req := &project.ServiceScaffoldReq{}
+	p.DecodeJSONReq(req)
+	rsp, err := project.NewProjectManager().ScaffoldService(projectID, req, p.User)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("scaffold service of project: %v occur error: %s", projectID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // GetApps ..
 func (p *ProjectController) GetApps() {
 	projectID, _ := p.GetInt64FromPath(":project_id")
@@ -592,6 +938,94 @@ func (p *ProjectController) DeleteProjectApp() {
 	p.ServeJSON()
 }
 
+// UpdateProjectAppDeployAfter ..
+func (p *ProjectController) UpdateProjectAppDeployAfter() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	projectAppID, _ := p.GetInt64FromPath(":project_app_id")
+	req := &project.ProjectAppDeployAfterReq{}
+	p.DecodeJSONReq(req)
+	pm := project.NewProjectManager()
+	if err := pm.UpdateProjectAppDeployAfter(projectID, projectAppID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update project app deploy after error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// UpdateProjectAppCompileCommand ..
+func (p *ProjectController) UpdateProjectAppCompileCommand() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	projectAppID, _ := p.GetInt64FromPath(":project_app_id")
+	req := &project.ProjectAppCompileCommandReq{}
+	p.DecodeJSONReq(req)
+	pm := project.NewProjectManager()
+	if err := pm.UpdateProjectAppCompileCommand(projectID, projectAppID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update project app compile command error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// UpdateProjectAppDockerfileTemplate ..
+func (p *ProjectController) UpdateProjectAppDockerfileTemplate() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	projectAppID, _ := p.GetInt64FromPath(":project_app_id")
+	req := &project.ProjectAppDockerfileTemplateReq{}
+	p.DecodeJSONReq(req)
+	pm := project.NewProjectManager()
+	if err := pm.UpdateProjectAppDockerfileTemplate(projectID, projectAppID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update project app dockerfile template error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// UpdateProjectAppOwnership ..
+func (p *ProjectController) UpdateProjectAppOwnership() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	projectAppID, _ := p.GetInt64FromPath(":project_app_id")
+	req := &project.ProjectAppOwnershipReq{}
+	p.DecodeJSONReq(req)
+	pm := project.NewProjectManager()
+	if err := pm.UpdateProjectAppOwnership(projectID, projectAppID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("update project app ownership error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetServiceCatalog return the services of visible projects with owner and deployed versions
+func (p *ProjectController) GetServiceCatalog() {
+	projectIDs, err := p.Projects()
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Base on permission, filter project error: %s", err.Error())
+		return
+	}
+	filter := &project.CatalogFilter{
+		Team: p.GetString("team"),
+		Tier: p.GetString("tier"),
+		Name: p.GetString("name"),
+	}
+	pm := project.NewProjectManager()
+	rsp, err := pm.GetServiceCatalog(projectIDs, filter)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get service catalog error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // UpdateProjectApp ..
 func (p *ProjectController) UpdateProjectApp() {
 	projectID, _ := p.GetInt64FromPath(":project_id")
@@ -17,6 +17,12 @@ limitations under the License.
 package api
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
 	"github.com/go-atomci/atomci/internal/core/publish"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
@@ -44,6 +50,22 @@ func (p *PublishController) Create() {
 	p.ServeJSON()
 }
 
+// BatchPublish ..
+func (p *PublishController) BatchPublish() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	req := &publish.BatchPublishReq{}
+	p.DecodeJSONReq(req)
+	pm := publish.NewPublishManager()
+	rsp, err := pm.BatchPublish(p.User, projectID, req)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Batch publish error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // PublishList ...
 func (p *PublishController) PublishList() {
 	projectID, _ := p.GetInt64FromPath(":project_id")
@@ -234,6 +256,37 @@ func (p *PublishController) TriggerNextStage() {
 	p.ServeJSON()
 }
 
+// ApproveStage approve the publish to enter the stage which require approval
+func (p *PublishController) ApproveStage() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromPath(":stage_id")
+	req := &publish.StageApprovalReq{}
+	p.DecodeJSONReq(req)
+	pm := publish.NewPublishManager()
+	if err := pm.ApproveStage(projectID, publishID, stageID, req, p.User); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("approve publish: %v stage: %v error: %s", publishID, stageID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetPublishAging return the aging metrics of the project publishes waiting for manual operation
+func (p *PublishController) GetPublishAging() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.GetPublishAging(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("get project: %v publish aging error: %s", projectID, err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
 // GetOpertaionLogByPagination ..
 func (p *PublishController) GetOpertaionLogByPagination() {
 	publishID, _ := p.GetInt64FromPath(":publish_id")
@@ -248,3 +301,289 @@ func (p *PublishController) GetOpertaionLogByPagination() {
 	p.Data["json"] = NewResult(true, result, "")
 	p.ServeJSON()
 }
+
+// ExportReleaseNotes download the markdown release notes of publish
+func (p *PublishController) ExportReleaseNotes() {
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := publish.NewPublishManager()
+	publishItem, err := pm.GetReleaseNotes(publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Export release notes error: %s", err.Error())
+		return
+	}
+	p.Ctx.Output.Header("Content-Type", "text/markdown; charset=utf-8")
+	p.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=release-notes-%v.md", publishItem.ID))
+	p.Ctx.Output.Body([]byte(publishItem.ReleaseNotes))
+}
+
+// GenerateReleaseNotes regenerate the release notes of publish, stage_id default is the current stage
+func (p *PublishController) GenerateReleaseNotes() {
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromQuery("stage_id")
+	pm := publish.NewPublishManager()
+	notes, err := pm.GenerateReleaseNotes(publishID, stageID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Generate release notes error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, notes, "")
+	p.ServeJSON()
+}
+
+// DiffPublishes compare the publish with the base publish of the same pipeline, stage_id default is the current stage
+func (p *PublishController) DiffPublishes() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	baseID, err := p.GetInt64FromQuery("base")
+	if err != nil || baseID == 0 {
+		p.HandleBadRequest("请指定对比的发布单 base")
+		return
+	}
+	stageID, _ := p.GetInt64FromQuery("stage_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.DiffPublishes(projectID, baseID, publishID, stageID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Diff publishes error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetPublishIssues return the issue keys linked to publish
+func (p *PublishController) GetPublishIssues() {
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.GetPublishIssues(publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get publish issues error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// LinkPublishIssues reparse the issue keys from commit messages, stage_id default is the current stage
+func (p *PublishController) LinkPublishIssues() {
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	stageID, _ := p.GetInt64FromQuery("stage_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.LinkPublishIssues(publishID, stageID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Link publish issues error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// GetReleasePlans ..
+func (p *PublishController) GetReleasePlans() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.GetReleasePlans(projectID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get release plans error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreateReleasePlan ..
+func (p *PublishController) CreateReleasePlan() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	req := &publish.ReleasePlanReq{}
+	p.DecodeJSONReq(req)
+	pm := publish.NewPublishManager()
+	if err := pm.CreateReleasePlan(p.User, projectID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Create release plan error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetReleasePlan ..
+func (p *PublishController) GetReleasePlan() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	planID, _ := p.GetInt64FromPath(":plan_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.GetReleasePlan(projectID, planID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get release plan error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// UpdateReleasePlan ..
+func (p *PublishController) UpdateReleasePlan() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	planID, _ := p.GetInt64FromPath(":plan_id")
+	req := &publish.ReleasePlanReq{}
+	p.DecodeJSONReq(req)
+	pm := publish.NewPublishManager()
+	if err := pm.UpdateReleasePlan(projectID, planID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Update release plan error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// DeleteReleasePlan ..
+func (p *PublishController) DeleteReleasePlan() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	planID, _ := p.GetInt64FromPath(":plan_id")
+	pm := publish.NewPublishManager()
+	if err := pm.DeleteReleasePlan(projectID, planID); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Delete release plan error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// CreatePublishFromReleasePlan ..
+func (p *PublishController) CreatePublishFromReleasePlan() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	planID, _ := p.GetInt64FromPath(":plan_id")
+	req := &publish.ReleasePlanPublishReq{}
+	p.DecodeJSONReq(req)
+	pm := publish.NewPublishManager()
+	if err := pm.CreatePublishFromReleasePlan(p.User, projectID, planID, req); err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Create publish from release plan error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// GetPublishComments return the comment threads of publish
+func (p *PublishController) GetPublishComments() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	pm := publish.NewPublishManager()
+	rsp, err := pm.GetPublishComments(projectID, publishID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Get publish comments error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// CreatePublishComment accept json body, or multipart form with `content`, `parent_id` and the `files` attached
+func (p *PublishController) CreatePublishComment() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	req := &publish.PublishCommentReq{}
+	attachments := []*publish.CommentAttachment{}
+	if strings.HasPrefix(p.Ctx.Input.Header("Content-Type"), "multipart/form-data") {
+		req.Content = p.GetString("content")
+		req.ParentID, _ = p.GetInt64("parent_id", 0)
+		files, err := p.GetFiles("files")
+		if err != nil && err != http.ErrMissingFile {
+			p.HandleBadRequest(fmt.Sprintf("附件上传失败: %v", err.Error()))
+			return
+		}
+		for _, header := range files {
+			if header.Size > publish.MaxAttachmentSize {
+				p.HandleBadRequest(fmt.Sprintf("附件 %v 不能超过 %vMB", header.Filename, publish.MaxAttachmentSize>>20))
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				p.HandleBadRequest(fmt.Sprintf("附件上传失败: %v", err.Error()))
+				return
+			}
+			data, err := ioutil.ReadAll(io.LimitReader(file, publish.MaxAttachmentSize+1))
+			file.Close()
+			if err != nil {
+				p.HandleInternalServerError(err.Error())
+				log.Log.Error("read comment attachment occur error: %s", err.Error())
+				return
+			}
+			attachments = append(attachments, &publish.CommentAttachment{
+				Name:        header.Filename,
+				ContentType: header.Header.Get("Content-Type"),
+				Data:        data,
+			})
+		}
+	} else {
+		p.DecodeJSONReq(req)
+	}
+	pm := publish.NewPublishManager()
+	rsp, err := pm.CreatePublishComment(projectID, publishID, p.User, req, attachments)
+	if err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("Create publish comment error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// UpdatePublishComment only the author can update the comment
+func (p *PublishController) UpdatePublishComment() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	commentID, _ := p.GetInt64FromPath(":comment_id")
+	req := &publish.PublishCommentReq{}
+	p.DecodeJSONReq(req)
+	pm := publish.NewPublishManager()
+	rsp, err := pm.UpdatePublishComment(projectID, publishID, commentID, p.User, req)
+	if err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("Update publish comment error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, rsp, "")
+	p.ServeJSON()
+}
+
+// DeletePublishComment only the author can delete the comment
+func (p *PublishController) DeletePublishComment() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	commentID, _ := p.GetInt64FromPath(":comment_id")
+	pm := publish.NewPublishManager()
+	if err := pm.DeletePublishComment(projectID, publishID, commentID, p.User); err != nil {
+		p.HandleBadRequest(err.Error())
+		log.Log.Error("Delete publish comment error: %s", err.Error())
+		return
+	}
+	p.Data["json"] = NewResult(true, nil, "")
+	p.ServeJSON()
+}
+
+// DownloadCommentAttachment ..
+func (p *PublishController) DownloadCommentAttachment() {
+	projectID, _ := p.GetInt64FromPath(":project_id")
+	publishID, _ := p.GetInt64FromPath(":publish_id")
+	attachmentID, _ := p.GetInt64FromPath(":attachment_id")
+	pm := publish.NewPublishManager()
+	attachment, data, err := pm.GetCommentAttachment(projectID, publishID, attachmentID)
+	if err != nil {
+		p.HandleInternalServerError(err.Error())
+		log.Log.Error("Download comment attachment error: %s", err.Error())
+		return
+	}
+	p.Ctx.Output.Header("Content-Type", attachment.ContentType)
+	p.Ctx.Output.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Name))
+	p.Ctx.Output.Body(data)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware"
+	"github.com/go-atomci/atomci/utils/errors"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+)
+
+// retryAfterSeconds the value of header `Retry-After`, at least 1 second
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Max(1, math.Ceil(wait.Seconds())))
+}
+
+// RateLimitFilter reject the api request with 429 when the rate limit of client ip or token exceeded
+func RateLimitFilter(ctx *context.Context) {
+	limit, wait := middleware.RateLimit(ctx)
+	if limit == "" {
+		return
+	}
+	renderTooManyRequests(ctx, errors.NewTooManyRequests(retryAfterSeconds(wait)), wait)
+}
+
+// rejectLockedLogin render 429 if the login of user from the client ip is locked after too many failures
+func rejectLockedLogin(ctx *context.Context, user, ip string) bool {
+	wait := middleware.LoginLocked(user, ip)
+	if wait <= 0 {
+		return false
+	}
+	renderTooManyRequests(ctx, errors.New(errors.CodeLoginLocked, errors.Params{"retry_after": retryAfterSeconds(wait)}), wait)
+	return true
+}
+
+// renderTooManyRequests render the 429 error in the envelope of api version
+func renderTooManyRequests(ctx *context.Context, err *errors.Error, wait time.Duration) {
+	message := err.LocalizedMessage(errors.ParseLang(ctx.Input.Header("Accept-Language")))
+	ctx.Output.Header("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	ctx.Output.SetStatus(http.StatusTooManyRequests)
+	hasIndent := beego.BConfig.RunMode != beego.PROD
+	if strings.HasPrefix(ctx.Request.URL.Path, apiV2Prefix) {
+		ctx.Output.JSON(V2ErrorResponse{Error: V2Error{Code: err.Code(), Message: message, Params: err.Params()}}, hasIndent, false)
+		return
+	}
+	ctx.Output.JSON(NewErrorResult(err.Code(), message, ""), hasIndent, false)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package api
 
 import (
+	"github.com/go-atomci/atomci/internal/core/rbac"
 	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
@@ -214,3 +215,42 @@ func (r *RoleController) RemoveRoleOperation() {
 	r.Data["json"] = NewResult(true, nil, "")
 	r.ServeJSON()
 }
+
+// GetRoleEnvPermissions ..
+func (r *RoleController) GetRoleEnvPermissions() {
+	roleName := r.GetStringFromPath(":role")
+	rsp, err := dao.GetRoleEnvPermissions("system", roleName)
+	if err != nil {
+		r.HandleInternalServerError(err.Error())
+		log.Log.Error("get role env permissions error: %s", err.Error())
+		return
+	}
+	r.Data["json"] = NewResult(true, rsp, "")
+	r.ServeJSON()
+}
+
+// SetRoleEnvPermissions ..
+func (r *RoleController) SetRoleEnvPermissions() {
+	roleName := r.GetStringFromPath(":role")
+	var req models.RoleEnvPermissionReq
+	r.DecodeJSONReq(&req)
+
+	if _, err := dao.GetGroupRoleByName("system", roleName); err != nil {
+		r.HandleBadRequest(err.Error())
+		log.Log.Error("set role env permissions error: %s", err.Error())
+		return
+	}
+	if err := rbac.ValidatePermissions(req.Permissions); err != nil {
+		r.HandleBadRequest(err.Error())
+		log.Log.Error("set role env permissions error: %s", err.Error())
+		return
+	}
+	if err := dao.SetRoleEnvPermissions("system", roleName, req.Permissions); err != nil {
+		r.HandleInternalServerError(err.Error())
+		log.Log.Error("set role env permissions error: %s", err.Error())
+		return
+	}
+
+	r.Data["json"] = NewResult(true, nil, "")
+	r.ServeJSON()
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/runnermgr"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/runner"
+
+	"github.com/astaxie/beego"
+)
+
+// RunnerAgentController serve the self hosted runners, which are authenticated by the runner token instead of user token
+type RunnerAgentController struct {
+	beego.Controller
+}
+
+// runner authenticate the runner by the bearer token
+func (r *RunnerAgentController) runner() *models.Runner {
+	token := strings.TrimPrefix(r.Ctx.Input.Header("Authorization"), "Bearer ")
+	item, err := runnermgr.Authenticate(token)
+	if err != nil {
+		r.CustomAbort(http.StatusUnauthorized, err.Error())
+	}
+	return item
+}
+
+func (r *RunnerAgentController) decode(req interface{}) {
+	if len(r.Ctx.Input.RequestBody) == 0 {
+		return
+	}
+	if err := json.Unmarshal(r.Ctx.Input.RequestBody, req); err != nil {
+		r.CustomAbort(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+}
+
+func (r *RunnerAgentController) taskID() int64 {
+	taskID, err := strconv.ParseInt(r.Ctx.Input.Param(":task_id"), 10, 64)
+	if err != nil {
+		r.CustomAbort(http.StatusBadRequest, "Invalid task id: "+err.Error())
+	}
+	return taskID
+}
+
+func (r *RunnerAgentController) serve(rsp interface{}) {
+	r.Data["json"] = NewResult(true, rsp, "")
+	r.ServeJSON()
+}
+
+// Register register the runner by the registration token
+func (r *RunnerAgentController) Register() {
+	req := runner.RegisterReq{}
+	r.decode(&req)
+	rsp, err := runnermgr.Register(&req)
+	if err != nil {
+		log.Log.Warn("register runner: %v error: %s", req.Name, err.Error())
+		r.CustomAbort(http.StatusBadRequest, err.Error())
+	}
+	r.serve(rsp)
+}
+
+// Heartbeat ..
+func (r *RunnerAgentController) Heartbeat() {
+	item := r.runner()
+	req := runner.HeartbeatReq{}
+	r.decode(&req)
+	if err := runnermgr.Heartbeat(item, &req); err != nil {
+		log.Log.Error("update heartbeat of runner: %v error: %s", item.Name, err.Error())
+		r.CustomAbort(http.StatusInternalServerError, err.Error())
+	}
+	r.serve(nil)
+}
+
+// ClaimTask return the task assigned to runner, null means no task queued
+func (r *RunnerAgentController) ClaimTask() {
+	item := r.runner()
+	rsp, err := runnermgr.Claim(item)
+	if err != nil {
+		log.Log.Error("runner: %v claim task error: %s", item.Name, err.Error())
+		r.CustomAbort(http.StatusInternalServerError, err.Error())
+	}
+	r.serve(rsp)
+}
+
+// AppendTaskLog ..
+func (r *RunnerAgentController) AppendTaskLog() {
+	item := r.runner()
+	taskID := r.taskID()
+	req := runner.LogReq{}
+	r.decode(&req)
+	rsp, err := runnermgr.AppendLog(item, taskID, &req)
+	if err != nil {
+		log.Log.Error("runner: %v append log of task: %v error: %s", item.Name, taskID, err.Error())
+		r.CustomAbort(http.StatusBadRequest, err.Error())
+	}
+	r.serve(rsp)
+}
+
+// ReportTaskStatus ..
+func (r *RunnerAgentController) ReportTaskStatus() {
+	item := r.runner()
+	taskID := r.taskID()
+	req := runner.StatusReq{}
+	r.decode(&req)
+	if err := runnermgr.ReportStatus(item, taskID, &req); err != nil {
+		log.Log.Error("runner: %v report status of task: %v error: %s", item.Name, taskID, err.Error())
+		r.CustomAbort(http.StatusBadRequest, err.Error())
+	}
+	r.serve(nil)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"github.com/go-atomci/atomci/internal/core/search"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// SearchController the global search across projects
+type SearchController struct {
+	BaseController
+}
+
+// Search find the projects, apps, publishes and images by name, image tag, commit sha or branch
+func (s *SearchController) Search() {
+	keyword, err := search.VerifyKeyword(s.GetStringFromQuery("q"))
+	if err != nil {
+		s.HandleBadRequest(err.Error())
+		return
+	}
+	types, err := search.ParseTypes(s.GetStringFromQuery("types"))
+	if err != nil {
+		s.HandleBadRequest(err.Error())
+		return
+	}
+	projectIDs, err := s.Projects()
+	if err != nil {
+		s.HandleInternalServerError(err.Error())
+		log.Log.Error("Base on permission, filter project error: %s", err.Error())
+		return
+	}
+	rsp, err := search.NewManager().Search(projectIDs, keyword, types)
+	if err != nil {
+		s.HandleInternalServerError(err.Error())
+		log.Log.Error("search error: %s", err.Error())
+		return
+	}
+	s.Data["json"] = NewResult(true, rsp, "")
+	s.ServeJSON()
+}
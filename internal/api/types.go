@@ -31,6 +31,8 @@ type ErrorResult struct {
 	ErrCode   string `json:"ErrCode,omitempty"`
 	ErrMsg    string `json:"ErrMsg,omitempty"`
 	ErrDetail string `json:"ErrDetail,omitempty"`
+	// ErrParams the params of the error code, eg: the name of the invalid param
+	ErrParams map[string]interface{} `json:"ErrParams,omitempty"`
 }
 
 func NewResult(isSuccess bool, data interface{}, errMsg string) Result {
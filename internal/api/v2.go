@@ -0,0 +1,432 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+//go:generate go run ../../cmd/openapi-gen -root ../..
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/events"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils/query"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// apiV2Prefix the path prefix of api v2
+const apiV2Prefix = "/atomci/api/v2/"
+
+// APIV2Controller the resource oriented api v2, which uses cursor pagination and the standard error envelope.
+// The annotations of the handlers are parsed by cmd/openapi-gen to generate the openapi document and the go client sdk.
+type APIV2Controller struct {
+	BaseController
+}
+
+// cursorQuery parse the cursor and limit query
+func (v *APIV2Controller) cursorQuery() (*query.CursorQuery, bool) {
+	limit, err := v.GetInt64FromQuery("limit")
+	if err != nil {
+		v.HandleBadRequest(fmt.Sprintf("limit 参数错误: %v", v.GetStringFromQuery("limit")))
+		return nil, false
+	}
+	cursor, err := query.NewCursorQuery(v.GetStringFromQuery("cursor"), int(limit))
+	if err != nil {
+		v.HandleBadRequest("cursor 参数错误，请使用上一页返回的 next_cursor")
+		return nil, false
+	}
+	return cursor, true
+}
+
+// sortedCursorQuery parse the cursor, limit and sort query, the sort keys besides id and create_at are allowed
+func (v *APIV2Controller) sortedCursorQuery(sortKeys ...string) (*query.CursorQuery, bool) {
+	limit, err := v.GetInt64FromQuery("limit")
+	if err != nil {
+		v.HandleBadRequest(fmt.Sprintf("limit 参数错误: %v", v.GetStringFromQuery("limit")))
+		return nil, false
+	}
+	key, asc, err := query.ParseSort(v.GetStringFromQuery("sort"), sortKeys...)
+	if err != nil {
+		v.HandleBadRequest(fmt.Sprintf("sort 参数错误: %v", v.GetStringFromQuery("sort")))
+		return nil, false
+	}
+	cursor, err := query.NewSortedCursorQuery(v.GetStringFromQuery("cursor"), int(limit), key, asc)
+	if err != nil {
+		v.HandleBadRequest("cursor 参数错误，请使用上一页返回的 next_cursor，且 sort 参数需与上一页一致")
+		return nil, false
+	}
+	return cursor, true
+}
+
+// createAtQuery parse the created_after and created_before query, both RFC3339 time and date like 2006-01-02 are accepted
+func (v *APIV2Controller) createAtQuery() (start, end *time.Time, ok bool) {
+	parse := func(key string) (*time.Time, bool) {
+		value := v.GetStringFromQuery(key)
+		if value == "" {
+			return nil, true
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t, err = time.ParseInLocation("2006-01-02", value, time.Local)
+		}
+		if err != nil {
+			v.HandleBadRequest(fmt.Sprintf("%s 参数错误: %v", key, value))
+			return nil, false
+		}
+		return &t, true
+	}
+	if start, ok = parse("created_after"); !ok {
+		return
+	}
+	end, ok = parse("created_before")
+	return
+}
+
+// ListProjects list the projects which the user can access, order by id desc
+// @ID listProjects
+// @Tags projects
+// @Summary List projects
+// @Param cursor query string false "the next_cursor of the previous page"
+// @Param limit query integer false "page size, default 20, max 100"
+// @Success 200 {object} V2ProjectList
+// @Router /projects [get]
+func (v *APIV2Controller) ListProjects() {
+	cursor, ok := v.cursorQuery()
+	if !ok {
+		return
+	}
+	projectIDs, err := v.Projects()
+	if err != nil {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Base on permission, filter project error: %s", err.Error())
+		return
+	}
+	items, err := dao.NewProjectModel().GetProjectsByCursor(projectIDs, cursor)
+	if err != nil {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get projects by cursor error: %s", err.Error())
+		return
+	}
+	rsp := V2ProjectList{Items: []V2Project{}}
+	if len(items) > cursor.Limit {
+		items = items[:cursor.Limit]
+		rsp.HasMore = true
+		rsp.NextCursor = query.EncodeCursor(items[len(items)-1].ID)
+	}
+	for _, item := range items {
+		rsp.Items = append(rsp.Items, newV2Project(item))
+	}
+	v.serveV2(rsp)
+}
+
+// GetProject get the project
+// @ID getProject
+// @Tags projects
+// @Summary Get a project
+// @Param project_id path integer true "project id"
+// @Success 200 {object} V2Project
+// @Router /projects/:project_id [get]
+func (v *APIV2Controller) GetProject() {
+	projectID, _ := v.GetInt64FromPath(":project_id")
+	item, err := dao.NewProjectModel().GetProjectByID(projectID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			v.HandleNotFound("项目不存在")
+			return
+		}
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get project: %v error: %s", projectID, err.Error())
+		return
+	}
+	v.serveV2(newV2Project(item))
+}
+
+// ListProjectEnvs list the deploy envs of the project
+// @ID listProjectEnvs
+// @Tags projects
+// @Summary List the envs of a project
+// @Param project_id path integer true "project id"
+// @Success 200 {object} V2EnvList
+// @Router /projects/:project_id/envs [get]
+func (v *APIV2Controller) ListProjectEnvs() {
+	projectID, _ := v.GetInt64FromPath(":project_id")
+	items, err := dao.NewProjectModel().GetProjectEnvs(projectID)
+	if err != nil {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get envs of project: %v error: %s", projectID, err.Error())
+		return
+	}
+	rsp := V2EnvList{Items: []V2Env{}}
+	for _, item := range items {
+		rsp.Items = append(rsp.Items, newV2Env(item))
+	}
+	v.serveV2(rsp)
+}
+
+// ListPublishes list the publishes of the project, order by id desc by default
+// @ID listPublishes
+// @Tags publishes
+// @Summary List the publishes of a project
+// @Param project_id path integer true "project id"
+// @Param stage_id query integer false "filter by the current env"
+// @Param status query string false "filter by status, comma separated, e.g. running,failed"
+// @Param creator query string false "filter by creator"
+// @Param app_id query integer false "filter by the project app id included"
+// @Param created_after query string false "filter by create time, inclusive, RFC3339 or 2006-01-02"
+// @Param created_before query string false "filter by create time, exclusive, RFC3339 or 2006-01-02"
+// @Param sort query string false "one of id, create_at, update_at, prefixed by - for descending, default -id"
+// @Param cursor query string false "the next_cursor of the previous page"
+// @Param limit query integer false "page size, default 20, max 100"
+// @Success 200 {object} V2PublishList
+// @Router /projects/:project_id/publishes [get]
+func (v *APIV2Controller) ListPublishes() {
+	projectID, _ := v.GetInt64FromPath(":project_id")
+	filter := &models.PublishListFilter{Creator: v.GetStringFromQuery("creator")}
+	var err error
+	if filter.StageID, err = v.GetInt64FromQuery("stage_id"); err != nil {
+		v.HandleBadRequest(fmt.Sprintf("stage_id 参数错误: %v", v.GetStringFromQuery("stage_id")))
+		return
+	}
+	if filter.ProjectAppID, err = v.GetInt64FromQuery("app_id"); err != nil {
+		v.HandleBadRequest(fmt.Sprintf("app_id 参数错误: %v", v.GetStringFromQuery("app_id")))
+		return
+	}
+	if names := v.GetStringFromQuery("status"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			item, ok := events.ParseStatusName(strings.TrimSpace(name))
+			if !ok {
+				v.HandleBadRequest(fmt.Sprintf("status 参数错误: %v", name))
+				return
+			}
+			filter.Status = append(filter.Status, item)
+		}
+	}
+	var ok bool
+	if filter.CreateAtStart, filter.CreateAtEnd, ok = v.createAtQuery(); !ok {
+		return
+	}
+	cursor, ok := v.sortedCursorQuery("update_at")
+	if !ok {
+		return
+	}
+	items, err := dao.NewPublishModel().GetPublishesByCursor(projectID, filter, cursor)
+	if err != nil {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get publishes of project: %v by cursor error: %s", projectID, err.Error())
+		return
+	}
+	rsp := V2PublishList{Items: []V2Publish{}}
+	if len(items) > cursor.Limit {
+		items = items[:cursor.Limit]
+		rsp.HasMore = true
+		last := items[len(items)-1]
+		rsp.NextCursor = cursor.NextCursor(last.ID, last.UpdateAt)
+	}
+	for _, item := range items {
+		rsp.Items = append(rsp.Items, newV2Publish(item))
+	}
+	v.serveV2(rsp)
+}
+
+// getPublish get the publish of the project, respond not found if the publish belongs to other project
+func (v *APIV2Controller) getPublish() (*models.Publish, bool) {
+	projectID, _ := v.GetInt64FromPath(":project_id")
+	publishID, _ := v.GetInt64FromPath(":publish_id")
+	publish, err := dao.NewPublishModel().GetPublishByID(publishID)
+	if err != nil && err != orm.ErrNoRows {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get publish: %v error: %s", publishID, err.Error())
+		return nil, false
+	}
+	if err == orm.ErrNoRows || publish.ProjectID != projectID {
+		v.HandleNotFound("流水线不存在")
+		return nil, false
+	}
+	return publish, true
+}
+
+// GetPublish get the publish
+// @ID getPublish
+// @Tags publishes
+// @Summary Get a publish
+// @Param project_id path integer true "project id"
+// @Param publish_id path integer true "publish id"
+// @Success 200 {object} V2Publish
+// @Router /projects/:project_id/publishes/:publish_id [get]
+func (v *APIV2Controller) GetPublish() {
+	publish, ok := v.getPublish()
+	if !ok {
+		return
+	}
+	v.serveV2(newV2Publish(publish))
+}
+
+// ListPublishJobs list the build/deploy jobs of the publish, order by id desc by default
+// @ID listPublishJobs
+// @Tags publishes
+// @Summary List the jobs of a publish
+// @Param project_id path integer true "project id"
+// @Param publish_id path integer true "publish id"
+// @Param stage_id query integer false "filter by the env"
+// @Param status query string false "filter by status, comma separated, e.g. RUNNING,FAILURE"
+// @Param job_type query string false "filter by job type, one of build, deploy, e2e-test"
+// @Param operator query string false "filter by operator"
+// @Param created_after query string false "filter by create time, inclusive, RFC3339 or 2006-01-02"
+// @Param created_before query string false "filter by create time, exclusive, RFC3339 or 2006-01-02"
+// @Param sort query string false "one of id, create_at, update_at, prefixed by - for descending, default -id"
+// @Param cursor query string false "the next_cursor of the previous page"
+// @Param limit query integer false "page size, default 20, max 100"
+// @Success 200 {object} V2PublishJobList
+// @Router /projects/:project_id/publishes/:publish_id/jobs [get]
+func (v *APIV2Controller) ListPublishJobs() {
+	publish, ok := v.getPublish()
+	if !ok {
+		return
+	}
+	filter := &models.PublishJobListFilter{
+		JobType:  v.GetStringFromQuery("job_type"),
+		Operator: v.GetStringFromQuery("operator"),
+	}
+	var err error
+	if filter.StageID, err = v.GetInt64FromQuery("stage_id"); err != nil {
+		v.HandleBadRequest(fmt.Sprintf("stage_id 参数错误: %v", v.GetStringFromQuery("stage_id")))
+		return
+	}
+	if status := v.GetStringFromQuery("status"); status != "" {
+		for _, item := range strings.Split(status, ",") {
+			filter.Status = append(filter.Status, strings.ToUpper(strings.TrimSpace(item)))
+		}
+	}
+	if filter.CreateAtStart, filter.CreateAtEnd, ok = v.createAtQuery(); !ok {
+		return
+	}
+	cursor, ok := v.sortedCursorQuery("update_at")
+	if !ok {
+		return
+	}
+	items, err := dao.NewPublishJobModel().GetPublishJobsByCursor(publish.ID, filter, cursor)
+	if err != nil {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get jobs of publish: %v by cursor error: %s", publish.ID, err.Error())
+		return
+	}
+	rsp := V2PublishJobList{Items: []V2PublishJob{}}
+	if len(items) > cursor.Limit {
+		items = items[:cursor.Limit]
+		rsp.HasMore = true
+		last := items[len(items)-1]
+		rsp.NextCursor = cursor.NextCursor(last.ID, last.UpdateAt)
+	}
+	for _, item := range items {
+		rsp.Items = append(rsp.Items, newV2PublishJob(item))
+	}
+	v.serveV2(rsp)
+}
+
+// ListPublishOperationLogs list the operation logs of the publish, order by id desc by default
+// @ID listPublishOperationLogs
+// @Tags publishes
+// @Summary List the operation logs of a publish
+// @Param project_id path integer true "project id"
+// @Param publish_id path integer true "publish id"
+// @Param stage_id query integer false "filter by the env"
+// @Param step query string false "filter by step name"
+// @Param creator query string false "filter by operator"
+// @Param created_after query string false "filter by create time, inclusive, RFC3339 or 2006-01-02"
+// @Param created_before query string false "filter by create time, exclusive, RFC3339 or 2006-01-02"
+// @Param sort query string false "one of id, create_at, prefixed by - for descending, default -id"
+// @Param cursor query string false "the next_cursor of the previous page"
+// @Param limit query integer false "page size, default 20, max 100"
+// @Success 200 {object} V2OperationLogList
+// @Router /projects/:project_id/publishes/:publish_id/operation-logs [get]
+func (v *APIV2Controller) ListPublishOperationLogs() {
+	publish, ok := v.getPublish()
+	if !ok {
+		return
+	}
+	filter := &models.OperationLogListFilter{
+		Step:    v.GetStringFromQuery("step"),
+		Creator: v.GetStringFromQuery("creator"),
+	}
+	var err error
+	if filter.StageID, err = v.GetInt64FromQuery("stage_id"); err != nil {
+		v.HandleBadRequest(fmt.Sprintf("stage_id 参数错误: %v", v.GetStringFromQuery("stage_id")))
+		return
+	}
+	if filter.CreateAtStart, filter.CreateAtEnd, ok = v.createAtQuery(); !ok {
+		return
+	}
+	cursor, ok := v.sortedCursorQuery()
+	if !ok {
+		return
+	}
+	items, err := dao.NewPublishModel().GetOperationLogsByCursor(publish.ID, filter, cursor)
+	if err != nil {
+		v.HandleInternalServerError(err.Error())
+		log.Log.Error("Get operation logs of publish: %v by cursor error: %s", publish.ID, err.Error())
+		return
+	}
+	rsp := V2OperationLogList{Items: []V2OperationLog{}}
+	if len(items) > cursor.Limit {
+		items = items[:cursor.Limit]
+		rsp.HasMore = true
+		last := items[len(items)-1]
+		rsp.NextCursor = cursor.NextCursor(last.ID, last.UpdateAt)
+	}
+	for _, item := range items {
+		rsp.Items = append(rsp.Items, newV2OperationLog(item))
+	}
+	v.serveV2(rsp)
+}
+
+// serveV2 serve the resource without the v1 result wrapper
+func (v *APIV2Controller) serveV2(data interface{}) {
+	v.Data["json"] = data
+	v.ServeJSON()
+}
+
+// isAPIV2 whether the request is the api v2
+func (b *BaseController) isAPIV2() bool {
+	return strings.HasPrefix(b.Ctx.Request.URL.Path, apiV2Prefix)
+}
+
+// renderV2Error render the standard error envelope of api v2
+func (b *BaseController) renderV2Error(status int, code, message, detail string, params map[string]interface{}) {
+	if code == "" {
+		code = strings.ReplaceAll(http.StatusText(status), " ", "")
+	}
+	b.Ctx.Output.SetStatus(status)
+	b.Data["json"] = V2ErrorResponse{Error: V2Error{Code: code, Message: message, Detail: detail, Params: params}}
+	b.ServeJSON()
+}
+
+// OpenAPIController publish the openapi document of api v2, which is public
+type OpenAPIController struct {
+	beego.Controller
+}
+
+// Document write the openapi document generated by cmd/openapi-gen
+func (o *OpenAPIController) Document() {
+	o.Ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+	o.Ctx.Output.Body([]byte(openAPIDocument))
+}
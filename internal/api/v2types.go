@@ -0,0 +1,241 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/events"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// The resources of api v2, the openapi document and the go client sdk are generated from
+// the struct types prefixed by V2 in this package, run `make openapi` after changing them.
+
+// V2ErrorResponse the standard error envelope of api v2
+type V2ErrorResponse struct {
+	Error V2Error `json:"error"`
+}
+
+// V2Error the error of api v2
+type V2Error struct {
+	// Code stable error code, e.g. NotFound, BadRequest
+	Code string `json:"code"`
+	// Message human readable error message
+	Message string `json:"message"`
+	// Detail the cause of the error
+	Detail string `json:"detail,omitempty"`
+	// Params the params of the error code, the message is localized by header Accept-Language(zh, en)
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// V2Project the project
+type V2Project struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Owner       string    `json:"owner"`
+	Creator     string    `json:"creator"`
+	Status      int       `json:"status"`
+	CreateAt    time.Time `json:"create_at"`
+	UpdateAt    time.Time `json:"update_at"`
+}
+
+// V2ProjectList the page of projects
+type V2ProjectList struct {
+	Items []V2Project `json:"items"`
+	// NextCursor the cursor of the next page, empty if there is no more
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// V2Env the deploy env of project
+type V2Env struct {
+	ID                int64     `json:"id"`
+	ProjectID         int64     `json:"project_id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	Cluster           int64     `json:"cluster"`
+	Namespace         string    `json:"namespace"`
+	ArrangeEnv        string    `json:"arrange_env"`
+	ConcurrencyPolicy string    `json:"concurrency_policy"`
+	CreateAt          time.Time `json:"create_at"`
+	UpdateAt          time.Time `json:"update_at"`
+}
+
+// V2EnvList the envs of project
+type V2EnvList struct {
+	Items []V2Env `json:"items"`
+}
+
+// V2Publish the publish order of project
+type V2Publish struct {
+	ID         int64  `json:"id"`
+	ProjectID  int64  `json:"project_id"`
+	Name       string `json:"name"`
+	VersionNo  string `json:"version_no"`
+	Creator    string `json:"creator"`
+	PipelineID int64  `json:"pipeline_id"`
+	StageID    int64  `json:"stage_id"`
+	StageName  string `json:"stage_name"`
+	Step       string `json:"step"`
+	StepType   string `json:"step_type"`
+	// Status one of pending, running, success, failed, end, closed, unknown, terminate-success, terminate-failed, merge-failed, skipped
+	Status   string     `json:"status"`
+	StartAt  time.Time  `json:"start_at"`
+	EndAt    *time.Time `json:"end_at,omitempty"`
+	CreateAt time.Time  `json:"create_at"`
+	UpdateAt time.Time  `json:"update_at"`
+}
+
+// V2PublishList the page of publishes
+type V2PublishList struct {
+	Items []V2Publish `json:"items"`
+	// NextCursor the cursor of the next page, empty if there is no more
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// V2PublishJob the build/deploy job of publish
+type V2PublishJob struct {
+	ID        int64  `json:"id"`
+	PublishID int64  `json:"publish_id"`
+	StageID   int64  `json:"stage_id"`
+	JobType   string `json:"job_type"`
+	Status    string `json:"status"`
+	RunID     int64  `json:"run_id"`
+	Progress  int    `json:"progress"`
+	// DurationInMillis the duration of the finished job
+	DurationInMillis int64     `json:"duration_in_millis"`
+	Operator         string    `json:"operator"`
+	CreateAt         time.Time `json:"create_at"`
+	UpdateAt         time.Time `json:"update_at"`
+}
+
+// V2PublishJobList the page of publish jobs
+type V2PublishJobList struct {
+	Items []V2PublishJob `json:"items"`
+	// NextCursor the cursor of the next page, empty if there is no more
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// V2OperationLog the operation log of publish
+type V2OperationLog struct {
+	ID        int64  `json:"id"`
+	PublishID int64  `json:"publish_id"`
+	StageID   int64  `json:"stage_id"`
+	Stage     string `json:"stage"`
+	Step      string `json:"step"`
+	Type      string `json:"type"`
+	Creator   string `json:"creator"`
+	// Status one of pending, running, success, failed, end, closed, unknown, terminate-success, terminate-failed, merge-failed, skipped
+	Status   string    `json:"status"`
+	RunID    int64     `json:"run_id"`
+	JobName  string    `json:"job_name"`
+	Message  string    `json:"message"`
+	CreateAt time.Time `json:"create_at"`
+}
+
+// V2OperationLogList the page of operation logs
+type V2OperationLogList struct {
+	Items []V2OperationLog `json:"items"`
+	// NextCursor the cursor of the next page, empty if there is no more
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+func newV2Project(item *models.Project) V2Project {
+	return V2Project{
+		ID:          item.ID,
+		Name:        item.Name,
+		Description: item.Description,
+		Owner:       item.Owner,
+		Creator:     item.Creator,
+		Status:      int(item.Status),
+		CreateAt:    item.CreateAt,
+		UpdateAt:    item.UpdateAt,
+	}
+}
+
+func newV2Env(item *models.ProjectEnv) V2Env {
+	return V2Env{
+		ID:                item.ID,
+		ProjectID:         item.ProjectID,
+		Name:              item.Name,
+		Description:       item.Description,
+		Cluster:           item.Cluster,
+		Namespace:         item.Namespace,
+		ArrangeEnv:        item.ArrangeEnv,
+		ConcurrencyPolicy: item.ConcurrencyPolicy,
+		CreateAt:          item.CreateAt,
+		UpdateAt:          item.UpdateAt,
+	}
+}
+
+func newV2Publish(item *models.Publish) V2Publish {
+	return V2Publish{
+		ID:         item.ID,
+		ProjectID:  item.ProjectID,
+		Name:       item.Name,
+		VersionNo:  item.VersionNo,
+		Creator:    item.Creator,
+		PipelineID: item.PipelineID,
+		StageID:    item.StageID,
+		StageName:  item.StageName,
+		Step:       item.Step,
+		StepType:   item.StepType,
+		Status:     events.StatusName(item.Status),
+		StartAt:    item.StartAt,
+		EndAt:      item.EndAt,
+		CreateAt:   item.CreateAt,
+		UpdateAt:   item.UpdateAt,
+	}
+}
+
+func newV2PublishJob(item *models.PublishJob) V2PublishJob {
+	return V2PublishJob{
+		ID:               item.ID,
+		PublishID:        item.PublishID,
+		StageID:          item.EnvID,
+		JobType:          item.JobType,
+		Status:           item.Status,
+		RunID:            item.RunID,
+		Progress:         item.Progress,
+		DurationInMillis: item.DurationInMillis,
+		Operator:         item.Operator,
+		CreateAt:         item.CreateAt,
+		UpdateAt:         item.UpdateAt,
+	}
+}
+
+func newV2OperationLog(item *models.PublishOperationLog) V2OperationLog {
+	return V2OperationLog{
+		ID:        item.ID,
+		PublishID: item.PublishID,
+		StageID:   item.StageID,
+		Stage:     item.Stage,
+		Step:      item.Step,
+		Type:      item.Type,
+		Creator:   item.Creator,
+		Status:    events.StatusName(item.Status),
+		RunID:     item.RunID,
+		JobName:   item.JobName,
+		Message:   item.Message,
+		CreateAt:  item.CreateAt,
+	}
+}
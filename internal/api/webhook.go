@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/astaxie/beego"
+)
+
+// WebhookController receive the scm webhook events, which is verified by webhook secret instead of user token
+type WebhookController struct {
+	beego.Controller
+}
+
+// ScmPush trigger the builds of the apps changed by push
+func (w *WebhookController) ScmPush() {
+	repoID, err := strconv.ParseInt(w.Ctx.Input.Param(":repo_id"), 10, 64)
+	if err != nil {
+		w.CustomAbort(http.StatusBadRequest, "Invalid repo id: "+err.Error())
+	}
+	// the request body was consumed by beego when copyrequestbody is enabled
+	if len(w.Ctx.Input.RequestBody) > 0 {
+		w.Ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(w.Ctx.Input.RequestBody))
+	}
+	rsp, err := publish.NewPublishManager().HandleScmPush(repoID, w.Ctx.Request)
+	if err != nil {
+		log.Log.Error("handle repo: %v push webhook error: %s", repoID, err.Error())
+		w.CustomAbort(http.StatusBadRequest, err.Error())
+	}
+	w.Data["json"] = NewResult(true, rsp, "")
+	w.ServeJSON()
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesstoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// displayPrefixLen the length of token prefix kept to tell the tokens apart
+const displayPrefixLen = 12
+
+// manageOperations the token management operations, access tokens are not allowed to create or revoke tokens
+var manageOperations = map[string]bool{
+	"GetAccessTokens":           true,
+	"CreateAccessToken":         true,
+	"RevokeAccessToken":         true,
+	"GetServiceAccounts":        true,
+	"CreateServiceAccount":      true,
+	"DeleteServiceAccount":      true,
+	"GetServiceAccountTokens":   true,
+	"CreateServiceAccountToken": true,
+	"RevokeServiceAccountToken": true,
+}
+
+// pipelineResourceType the resource type allowed by the pipeline scope besides the read only requests
+const pipelineResourceType = "publish"
+
+// IsAccessToken the token is an access token instead of the login token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, models.AccessTokenPrefix)
+}
+
+// HashToken only the hash of token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generate return a random token
+func generate() (string, error) {
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return models.AccessTokenPrefix + hex.EncodeToString(random), nil
+}
+
+// maxDays the max valid days of token, 0 means the tokens never expire
+func maxDays() int {
+	return beego.AppConfig.DefaultInt("accesstoken::max_days", 365)
+}
+
+// CreateToken create the access token of user, the project id is set for the tokens of project service account
+func CreateToken(user string, projectID int64, req *models.AccessTokenReq, creator string) (*models.AccessTokenRsp, error) {
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+	limit := maxDays()
+	if req.ExpireDays == 0 {
+		req.ExpireDays = limit
+	}
+	if limit > 0 && req.ExpireDays > limit {
+		return nil, fmt.Errorf("令牌有效天数不能超过 %v 天", limit)
+	}
+	plain, err := generate()
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %s", err.Error())
+	}
+	token := &models.AccessToken{
+		Addons:      models.NewAddons(),
+		Name:        req.Name,
+		User:        user,
+		ProjectID:   projectID,
+		TokenHash:   HashToken(plain),
+		TokenPrefix: plain[:displayPrefixLen],
+		Scopes:      strings.Join(req.Scopes, ","),
+		Creator:     creator,
+	}
+	if req.ExpireDays > 0 {
+		expireAt := time.Now().AddDate(0, 0, req.ExpireDays)
+		token.ExpireAt = &expireAt
+	}
+	if _, err := dao.CreateAccessToken(token); err != nil {
+		return nil, err
+	}
+	return &models.AccessTokenRsp{AccessToken: token, Token: plain}, nil
+}
+
+// RevokeToken revoke the token owned by user
+func RevokeToken(user string, tokenID int64) error {
+	token, err := dao.GetAccessTokenByID(tokenID)
+	if err != nil || token.User != user {
+		return fmt.Errorf("令牌 %v 不存在", tokenID)
+	}
+	if token.Revoked {
+		return nil
+	}
+	return dao.RevokeAccessToken(token)
+}
+
+// Authenticate return the valid access token of the plain token
+func Authenticate(plain string) (*models.AccessToken, error) {
+	token, err := dao.GetAccessTokenByHash(HashToken(plain))
+	if err != nil {
+		return nil, fmt.Errorf("无效的访问令牌")
+	}
+	now := time.Now()
+	if token.Revoked {
+		return nil, fmt.Errorf("访问令牌 %v 已被吊销", token.Name)
+	}
+	if token.Expired(now) {
+		return nil, fmt.Errorf("访问令牌 %v 已过期", token.Name)
+	}
+	if err := dao.UpdateAccessTokenLastUsed(token, now); err != nil {
+		log.Log.Warn("update access token: %v last used time error: %s", token.ID, err.Error())
+	}
+	return token, nil
+}
+
+// postReadOperations the list apis which carry the filter by POST, they are read only as well
+var postReadOperations = map[string]bool{
+	"AuditListByPagination":               true,
+	"GetAppBranches":                      true,
+	"GetAppsByPagination":                 true,
+	"GetAppserviceList":                   true,
+	"GetCompileEnvsByPagination":          true,
+	"GetGitProjectsByRepoID":              true,
+	"GetIntegrateSettingsByPagination":    true,
+	"GetOpertaionLogByPagination":         true,
+	"GetPipelinesByPagination":            true,
+	"GetProjectEnvsByPagination":          true,
+	"GetPublishStats":                     true,
+	"GetSCMIntegrateSettingsByPagination": true,
+	"GetTaskTmplsByPagination":            true,
+	"ProjectList":                         true,
+	"PublishList":                         true,
+	"Query":                               true,
+}
+
+// readOnlyRequest only GET/HEAD and the list apis of POST are read only, the other operations may change
+// or execute something even if their names look like read, eg: verify the integrate setting
+func readOnlyRequest(method, operation string) bool {
+	switch method {
+	case "GET", "HEAD":
+		return true
+	case "POST":
+		return postReadOperations[operation]
+	}
+	return false
+}
+
+// Permit verify the request is in the scopes and project of the token
+func Permit(token *models.AccessToken, method, resourceType, operation, projectID string) error {
+	if manageOperations[operation] {
+		return fmt.Errorf("访问令牌不能用于管理令牌")
+	}
+	if token.ProjectID != 0 && projectID != fmt.Sprint(token.ProjectID) {
+		return fmt.Errorf("访问令牌仅能访问项目 %v 的资源", token.ProjectID)
+	}
+	readOnly := readOnlyRequest(method, operation)
+	for _, scope := range token.ScopeList() {
+		switch {
+		case scope == models.AccessTokenScopeAPI:
+			return nil
+		case scope == models.AccessTokenScopePipeline && (readOnly || resourceType == pipelineResourceType):
+			return nil
+		case scope == models.AccessTokenScopeRead && readOnly:
+			return nil
+		}
+	}
+	return fmt.Errorf("访问令牌的权限范围 %v 不允许该操作", token.Scopes)
+}
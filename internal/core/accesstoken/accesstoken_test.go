@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package accesstoken
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestPermit(t *testing.T) {
+	tests := []struct {
+		name         string
+		scopes       string
+		projectID    int64
+		method       string
+		resourceType string
+		operation    string
+		reqProjectID string
+		wantErr      bool
+	}{
+		{"read get", "read", 0, "GET", "project", "GetProject", "1", false},
+		{"read list by post", "read", 0, "POST", "publish", "PublishList", "1", false},
+		{"read run step", "read", 0, "POST", "publish", "RunStep", "1", true},
+		{"read head", "read", 0, "HEAD", "project", "GetProject", "1", false},
+		{"read verify by post", "read", 0, "POST", "integrate", "VerifyIntegrateSetting", "", true},
+		{"read render by post", "read", 0, "POST", "app", "RenderArrange", "1", true},
+		{"read get prefix by put", "read", 0, "PUT", "project", "GetProject", "1", true},
+		{"read unknown post", "read", 0, "POST", "publish", "PreviewEnv", "1", true},
+		{"pipeline run step", "pipeline", 0, "POST", "publish", "RunStep", "1", false},
+		{"pipeline update project", "pipeline", 0, "PUT", "project", "UpdateProject", "1", true},
+		{"api update project", "api", 0, "PUT", "project", "UpdateProject", "1", false},
+		{"multiple scopes", "read,pipeline", 0, "POST", "publish", "TriggerNextStage", "1", false},
+		{"manage tokens", "api", 0, "POST", "auth", "CreateAccessToken", "", true},
+		{"project token", "api", 1, "POST", "publish", "RunStep", "1", false},
+		{"project token other project", "api", 1, "POST", "publish", "RunStep", "2", true},
+		{"project token without project", "api", 1, "GET", "auth", "GetCurrentUser", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &models.AccessToken{Scopes: tt.scopes, ProjectID: tt.projectID}
+			if err := Permit(token, tt.method, tt.resourceType, tt.operation, tt.reqProjectID); (err != nil) != tt.wantErr {
+				t.Errorf("Permit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	token, err := generate()
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if !IsAccessToken(token) || len(token) != len(models.AccessTokenPrefix)+40 {
+		t.Errorf("generate() = %v, invalid format", token)
+	}
+	if strings.Contains(token, ".") {
+		t.Errorf("generate() = %v, must not be taken as jwt", token)
+	}
+	if HashToken(token) == HashToken(token+"x") || len(HashToken(token)) != 64 {
+		t.Errorf("HashToken() is invalid")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	tests := []struct {
+		name     string
+		expireAt *time.Time
+		want     bool
+	}{
+		{"never expire", nil, false},
+		{"expired", &past, true},
+		{"valid", &future, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &models.AccessToken{ExpireAt: tt.expireAt}
+			if got := token.Expired(now); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
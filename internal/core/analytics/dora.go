@@ -0,0 +1,303 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// dateLayout the layout of the date in the time range query
+const dateLayout = "2006-01-02"
+
+// defaultRangeDays the days of the time range when the start date is not given
+const defaultRangeDays = 30
+
+// maxRangeDays the max days of the time range queried once
+const maxRangeDays = 366
+
+// allEnvsName the name of the project level metrics which include all envs
+const allEnvsName = "all"
+
+// TimeRange the time range of the metrics, End is exclusive
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Days the days of the time range
+func (r *TimeRange) Days() float64 {
+	return r.End.Sub(r.Start).Hours() / 24
+}
+
+// ParseTimeRange parse the start and end date (inclusive) like 2006-01-02, the range is the last 30 days
+// until the end date when the start date is empty, and the end date is today when it is empty
+func ParseTimeRange(start, end string, now time.Time) (*TimeRange, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	endDate := today
+	if end != "" {
+		date, err := time.ParseInLocation(dateLayout, end, now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("结束日期格式错误, 应为 %v", dateLayout)
+		}
+		endDate = date
+	}
+	startDate := endDate.AddDate(0, 0, 1-defaultRangeDays)
+	if start != "" {
+		date, err := time.ParseInLocation(dateLayout, start, now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("开始日期格式错误, 应为 %v", dateLayout)
+		}
+		startDate = date
+	}
+	if endDate.Before(startDate) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+	timeRange := &TimeRange{Start: startDate, End: endDate.AddDate(0, 0, 1)}
+	if timeRange.Days() > maxRangeDays {
+		return nil, fmt.Errorf("查询时间范围不能超过 %v 天", maxRangeDays)
+	}
+	return timeRange, nil
+}
+
+// DORAMetrics the four key metrics of the env in the time range, the durations are in hours,
+// the env id is 0 for the metrics of all envs in the project
+type DORAMetrics struct {
+	ProjectID   int64  `json:"project_id"`
+	EnvID       int64  `json:"stage_id"`
+	EnvName     string `json:"stage_name"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Deployments int    `json:"deployments"`
+	Failures    int    `json:"failures"`
+	// DeploymentFrequency the successful deployments per day
+	DeploymentFrequency float64 `json:"deployment_frequency"`
+	// LeadTime the median hours from the publish created to deployed successfully
+	LeadTime float64 `json:"lead_time_hours"`
+	// ChangeFailureRate the percent of the failed deployments
+	ChangeFailureRate float64 `json:"change_failure_rate"`
+	// MTTR the mean hours from a deployment failed to the next successful deployment of the env
+	MTTR        float64 `json:"mttr_hours"`
+	Recoveries  int     `json:"recoveries"`
+	Unrecovered int     `json:"unrecovered"`
+}
+
+// doraSamples the samples collected from the deploy jobs to calculate the metrics
+type doraSamples struct {
+	successes   int
+	failures    int
+	leadTimes   []float64
+	recoveries  []float64
+	unrecovered int
+}
+
+// collect the jobs should be in one env and sorted by the finish time, the finish time of job is the update time,
+// the failures in a row until the next success are regarded as one incident
+func (s *doraSamples) collect(jobs []*models.PublishJob, publishCreateAt map[int64]time.Time) {
+	var failedSince *time.Time
+	for _, job := range jobs {
+		finishAt := job.UpdateAt
+		switch job.Status {
+		case models.StatusSuccess:
+			s.successes++
+			if createAt, ok := publishCreateAt[job.PublishID]; ok && finishAt.After(createAt) {
+				s.leadTimes = append(s.leadTimes, finishAt.Sub(createAt).Hours())
+			}
+			if failedSince != nil {
+				s.recoveries = append(s.recoveries, finishAt.Sub(*failedSince).Hours())
+				failedSince = nil
+			}
+		case models.StatusFailure:
+			s.failures++
+			if failedSince == nil {
+				failedSince = &finishAt
+			}
+		}
+	}
+	if failedSince != nil {
+		s.unrecovered++
+	}
+}
+
+func (s *doraSamples) metrics(days float64) *DORAMetrics {
+	metrics := &DORAMetrics{
+		Deployments: s.successes + s.failures,
+		Failures:    s.failures,
+		LeadTime:    round(median(s.leadTimes)),
+		MTTR:        round(mean(s.recoveries)),
+		Recoveries:  len(s.recoveries),
+		Unrecovered: s.unrecovered,
+	}
+	if days > 0 {
+		metrics.DeploymentFrequency = round(float64(s.successes) / days)
+	}
+	if metrics.Deployments > 0 {
+		metrics.ChangeFailureRate = round(float64(s.failures) * 100 / float64(metrics.Deployments))
+	}
+	return metrics
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// round keep 2 decimals
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// calculateDORA calculate the metrics of every env which has deployments and all envs of the project,
+// the metrics of all envs is the first
+func calculateDORA(projectID int64, envNames map[int64]string, jobs []*models.PublishJob,
+	publishCreateAt map[int64]time.Time, timeRange *TimeRange) []*DORAMetrics {
+	envJobs := map[int64][]*models.PublishJob{}
+	envIDs := []int64{}
+	for _, job := range jobs {
+		if _, ok := envJobs[job.EnvID]; !ok {
+			envIDs = append(envIDs, job.EnvID)
+		}
+		envJobs[job.EnvID] = append(envJobs[job.EnvID], job)
+	}
+	sort.Slice(envIDs, func(i, j int) bool { return envIDs[i] < envIDs[j] })
+
+	days := timeRange.Days()
+	total := &doraSamples{}
+	items := []*DORAMetrics{}
+	for _, envID := range envIDs {
+		samples := &doraSamples{}
+		samples.collect(envJobs[envID], publishCreateAt)
+		total.successes += samples.successes
+		total.failures += samples.failures
+		total.leadTimes = append(total.leadTimes, samples.leadTimes...)
+		total.recoveries = append(total.recoveries, samples.recoveries...)
+		total.unrecovered += samples.unrecovered
+
+		item := samples.metrics(days)
+		item.EnvID = envID
+		item.EnvName = envNames[envID]
+		items = append(items, item)
+	}
+	all := total.metrics(days)
+	all.EnvName = allEnvsName
+	items = append([]*DORAMetrics{all}, items...)
+
+	start, end := timeRange.Start.Format(dateLayout), timeRange.End.AddDate(0, 0, -1).Format(dateLayout)
+	for _, item := range items {
+		item.ProjectID = projectID
+		item.Start = start
+		item.End = end
+	}
+	return items
+}
+
+// GetDORAMetrics get the dora metrics of the project calculated from the deploy history in the time range,
+// envID 0 means all envs of the project
+func GetDORAMetrics(projectID, envID int64, timeRange *TimeRange) ([]*DORAMetrics, error) {
+	jobs, err := dao.NewPublishJobModel().GetFinishedDeployJobs(projectID, envID, timeRange.Start, timeRange.End)
+	if err != nil {
+		log.Log.Error("when get dora metrics, get deploy jobs of project: %v occur error: %s", projectID, err.Error())
+		return nil, err
+	}
+	publishIDs := []int64{}
+	seen := map[int64]bool{}
+	for _, job := range jobs {
+		if !seen[job.PublishID] {
+			seen[job.PublishID] = true
+			publishIDs = append(publishIDs, job.PublishID)
+		}
+	}
+	publishes, err := dao.NewPublishModel().GetPublishesByIDs(publishIDs)
+	if err != nil {
+		log.Log.Error("when get dora metrics, get publishes of project: %v occur error: %s", projectID, err.Error())
+		return nil, err
+	}
+	publishCreateAt := map[int64]time.Time{}
+	for _, publish := range publishes {
+		publishCreateAt[publish.ID] = publish.CreateAt
+	}
+	envs, err := dao.NewProjectModel().GetProjectEnvs(projectID)
+	if err != nil {
+		log.Log.Error("when get dora metrics, get envs of project: %v occur error: %s", projectID, err.Error())
+		return nil, err
+	}
+	envNames := map[int64]string{}
+	for _, env := range envs {
+		envNames[env.ID] = env.Name
+	}
+	return calculateDORA(projectID, envNames, jobs, publishCreateAt, timeRange), nil
+}
+
+// ExportCSV write the dora metrics as csv
+func ExportCSV(w io.Writer, items []*DORAMetrics) error {
+	writer := csv.NewWriter(w)
+	header := []string{"project_id", "stage_id", "stage_name", "start", "end", "deployments", "failures",
+		"deployment_frequency", "lead_time_hours", "change_failure_rate", "mttr_hours", "recoveries", "unrecovered"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, item := range items {
+		record := []string{
+			strconv.FormatInt(item.ProjectID, 10),
+			strconv.FormatInt(item.EnvID, 10),
+			item.EnvName,
+			item.Start,
+			item.End,
+			strconv.Itoa(item.Deployments),
+			strconv.Itoa(item.Failures),
+			strconv.FormatFloat(item.DeploymentFrequency, 'f', -1, 64),
+			strconv.FormatFloat(item.LeadTime, 'f', -1, 64),
+			strconv.FormatFloat(item.ChangeFailureRate, 'f', -1, 64),
+			strconv.FormatFloat(item.MTTR, 'f', -1, 64),
+			strconv.Itoa(item.Recoveries),
+			strconv.Itoa(item.Unrecovered),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC)
+	timeRange, err := ParseTimeRange("", "", now)
+	if err != nil {
+		t.Fatalf("ParseTimeRange() error = %v", err)
+	}
+	if got := timeRange.Start.Format(dateLayout); got != "2022-05-17" {
+		t.Errorf("Start = %v, want 2022-05-17", got)
+	}
+	if got := timeRange.End.Format(dateLayout); got != "2022-06-16" {
+		t.Errorf("End = %v, want 2022-06-16", got)
+	}
+	if timeRange.Days() != defaultRangeDays {
+		t.Errorf("Days() = %v, want %v", timeRange.Days(), defaultRangeDays)
+	}
+
+	for _, tt := range [][]string{{"2022-06-10", "2022-06-01"}, {"2022/06/01", ""}, {"2020-01-01", "2022-01-01"}} {
+		if _, err := ParseTimeRange(tt[0], tt[1], now); err == nil {
+			t.Errorf("ParseTimeRange(%v, %v) expects error", tt[0], tt[1])
+		}
+	}
+}
+
+func TestCalculateDORA(t *testing.T) {
+	base := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	job := func(envID, publishID int64, status string, hours int) *models.PublishJob {
+		item := &models.PublishJob{EnvID: envID, PublishID: publishID, Status: status}
+		item.UpdateAt = base.Add(time.Duration(hours) * time.Hour)
+		return item
+	}
+	jobs := []*models.PublishJob{
+		job(1, 1, models.StatusSuccess, 2),
+		job(2, 1, models.StatusFailure, 10),
+		job(2, 1, models.StatusFailure, 11),
+		job(1, 2, models.StatusSuccess, 12),
+		job(2, 1, models.StatusSuccess, 14),
+		job(2, 2, models.StatusFailure, 20),
+	}
+	publishCreateAt := map[int64]time.Time{1: base, 2: base.Add(6 * time.Hour)}
+	timeRange := &TimeRange{Start: base, End: base.AddDate(0, 0, 2)}
+
+	items := calculateDORA(7, map[int64]string{1: "dev", 2: "prod"}, jobs, publishCreateAt, timeRange)
+	if len(items) != 3 {
+		t.Fatalf("calculateDORA() got %v items, want 3", len(items))
+	}
+	all, dev, prod := items[0], items[1], items[2]
+	if all.EnvName != allEnvsName || dev.EnvName != "dev" || prod.EnvName != "prod" {
+		t.Errorf("env names = %v, %v, %v", all.EnvName, dev.EnvName, prod.EnvName)
+	}
+	if all.Deployments != 6 || all.Failures != 3 || all.ChangeFailureRate != 50 || all.DeploymentFrequency != 1.5 {
+		t.Errorf("all = %+v", all)
+	}
+	// lead times: 2h, 6h, 14h
+	if all.LeadTime != 6 {
+		t.Errorf("all.LeadTime = %v, want 6", all.LeadTime)
+	}
+	if dev.ChangeFailureRate != 0 || dev.MTTR != 0 || dev.LeadTime != 4 {
+		t.Errorf("dev = %+v", dev)
+	}
+	// the failures at 10h and 11h are one incident recovered at 14h, the failure at 20h is not recovered
+	if prod.MTTR != 4 || prod.Recoveries != 1 || prod.Unrecovered != 1 || all.MTTR != 4 {
+		t.Errorf("prod = %+v", prod)
+	}
+	if all.Start != "2022-06-01" || all.End != "2022-06-02" || all.ProjectID != 7 {
+		t.Errorf("all = %+v", all)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportCSV(&buf, items); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[1] != "7,0,all,2022-06-01,2022-06-02,6,3,1.5,6,50,4,1,1" {
+		t.Errorf("ExportCSV() = %v", buf.String())
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// StepTrendPoint the runs of the step in one day, the durations are in seconds
+type StepTrendPoint struct {
+	Date        string  `json:"date"`
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	AvgDuration float64 `json:"avg_duration"`
+}
+
+// StepStats the duration and failure stats of the step (sub task) in the time range, the durations are in seconds
+type StepStats struct {
+	Name        string            `json:"name"`
+	JobType     string            `json:"job_type"`
+	Runs        int               `json:"runs"`
+	Failures    int               `json:"failures"`
+	AvgDuration float64           `json:"avg_duration"`
+	P90Duration float64           `json:"p90_duration"`
+	MaxDuration float64           `json:"max_duration"`
+	Trend       []*StepTrendPoint `json:"trend"`
+}
+
+// FailureCauseStats the failures of the cause in the time range
+type FailureCauseStats struct {
+	Cause    string  `json:"cause"`
+	Failures int     `json:"failures"`
+	Percent  float64 `json:"percent"`
+	// Steps the failures of the cause by steps
+	Steps map[string]int `json:"steps"`
+}
+
+// StepAnalytics the steps are sorted by the average duration, the slowest is the first
+type StepAnalytics struct {
+	Start         string               `json:"start"`
+	End           string               `json:"end"`
+	Steps         []*StepStats         `json:"steps"`
+	FailureCauses []*FailureCauseStats `json:"failure_causes"`
+}
+
+// percentile the nearest rank percentile of the sorted values
+func percentile(sorted []float64, percent float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(percent / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func isFailedStage(stage *models.PublishJobStage) bool {
+	return stage.FailureCause != "" || stage.Status == "FAILED" || stage.Status == models.StatusFailure
+}
+
+// calculateStepAnalytics the stages should be sorted by the start time
+func calculateStepAnalytics(stages []*models.PublishJobStage, timeRange *TimeRange) *StepAnalytics {
+	type stepKey struct{ jobType, name string }
+	type dayStats struct {
+		runs, failures int
+		duration       float64
+	}
+	keys := []stepKey{}
+	durations := map[stepKey][]float64{}
+	failures := map[stepKey]int{}
+	days := map[stepKey]map[string]*dayStats{}
+	causes := map[string]*FailureCauseStats{}
+	totalFailures := 0
+	for _, stage := range stages {
+		key := stepKey{stage.JobType, stage.Name}
+		if _, ok := durations[key]; !ok {
+			keys = append(keys, key)
+			days[key] = map[string]*dayStats{}
+		}
+		duration := float64(stage.DurationInMillis) / 1000
+		durations[key] = append(durations[key], duration)
+		date := stage.StartAt.In(timeRange.Start.Location()).Format(dateLayout)
+		day, ok := days[key][date]
+		if !ok {
+			day = &dayStats{}
+			days[key][date] = day
+		}
+		day.runs++
+		day.duration += duration
+		if isFailedStage(stage) {
+			failures[key]++
+			day.failures++
+		}
+		if stage.FailureCause != "" {
+			totalFailures++
+			cause, ok := causes[stage.FailureCause]
+			if !ok {
+				cause = &FailureCauseStats{Cause: stage.FailureCause, Steps: map[string]int{}}
+				causes[stage.FailureCause] = cause
+			}
+			cause.Failures++
+			cause.Steps[stage.Name]++
+		}
+	}
+
+	result := &StepAnalytics{
+		Start:         timeRange.Start.Format(dateLayout),
+		End:           timeRange.End.AddDate(0, 0, -1).Format(dateLayout),
+		Steps:         []*StepStats{},
+		FailureCauses: []*FailureCauseStats{},
+	}
+	for _, key := range keys {
+		values := append([]float64{}, durations[key]...)
+		sort.Float64s(values)
+		step := &StepStats{
+			Name:        key.name,
+			JobType:     key.jobType,
+			Runs:        len(values),
+			Failures:    failures[key],
+			AvgDuration: round(mean(values)),
+			P90Duration: round(percentile(values, 90)),
+			MaxDuration: round(values[len(values)-1]),
+			Trend:       []*StepTrendPoint{},
+		}
+		for date := timeRange.Start; date.Before(timeRange.End); date = date.AddDate(0, 0, 1) {
+			point := &StepTrendPoint{Date: date.Format(dateLayout)}
+			if day, ok := days[key][point.Date]; ok {
+				point.Runs = day.runs
+				point.Failures = day.failures
+				point.AvgDuration = round(day.duration / float64(day.runs))
+			}
+			step.Trend = append(step.Trend, point)
+		}
+		result.Steps = append(result.Steps, step)
+	}
+	sort.SliceStable(result.Steps, func(i, j int) bool {
+		return result.Steps[i].AvgDuration > result.Steps[j].AvgDuration
+	})
+
+	for _, cause := range causes {
+		cause.Percent = round(float64(cause.Failures) * 100 / float64(totalFailures))
+		result.FailureCauses = append(result.FailureCauses, cause)
+	}
+	sort.Slice(result.FailureCauses, func(i, j int) bool {
+		if result.FailureCauses[i].Failures != result.FailureCauses[j].Failures {
+			return result.FailureCauses[i].Failures > result.FailureCauses[j].Failures
+		}
+		return result.FailureCauses[i].Cause < result.FailureCauses[j].Cause
+	})
+	return result
+}
+
+// GetStepAnalytics get the duration trends and failure causes of the steps run by the publish jobs of project,
+// envID 0 means all envs and empty job type means all types of job
+func GetStepAnalytics(projectID, envID int64, jobType string, timeRange *TimeRange) (*StepAnalytics, error) {
+	stages, err := dao.NewPublishJobStageModel().GetJobStagesByTimeRange(projectID, envID, jobType, timeRange.Start, timeRange.End)
+	if err != nil {
+		log.Log.Error("when get step analytics, get job stages of project: %v occur error: %s", projectID, err.Error())
+		return nil, err
+	}
+	return calculateStepAnalytics(stages, timeRange), nil
+}
+
+// GetPublishJobStages get the stages of the jobs run by publish
+func GetPublishJobStages(publishID int64) ([]*models.PublishJobStage, error) {
+	return dao.NewPublishJobStageModel().GetPublishJobStages(publishID)
+}
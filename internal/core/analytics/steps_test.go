@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestCalculateStepAnalytics(t *testing.T) {
+	base := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	stage := func(name string, day int, seconds int64, cause string) *models.PublishJobStage {
+		status := "SUCCESS"
+		if cause != "" {
+			status = "FAILED"
+		}
+		return &models.PublishJobStage{
+			JobType:          models.JobTypeBuild,
+			Name:             name,
+			Status:           status,
+			StartAt:          base.AddDate(0, 0, day),
+			DurationInMillis: seconds * 1000,
+			FailureCause:     cause,
+		}
+	}
+	stages := []*models.PublishJobStage{
+		stage("Checkout", 0, 10, ""),
+		stage("Builds", 0, 100, ""),
+		stage("Builds", 0, 200, models.FailureCauseCompile),
+		stage("Images", 1, 60, models.FailureCauseImagePush),
+		stage("Builds", 1, 300, ""),
+		stage("Images", 1, 40, models.FailureCauseImagePush),
+	}
+	timeRange := &TimeRange{Start: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 6, 3, 0, 0, 0, 0, time.UTC)}
+
+	result := calculateStepAnalytics(stages, timeRange)
+	if len(result.Steps) != 3 {
+		t.Fatalf("got %v steps, want 3", len(result.Steps))
+	}
+	builds := result.Steps[0]
+	if builds.Name != "Builds" || builds.Runs != 3 || builds.Failures != 1 || builds.AvgDuration != 200 ||
+		builds.P90Duration != 300 || builds.MaxDuration != 300 {
+		t.Errorf("slowest step = %+v", builds)
+	}
+	if len(builds.Trend) != 2 || builds.Trend[0].Runs != 2 || builds.Trend[0].AvgDuration != 150 || builds.Trend[1].AvgDuration != 300 {
+		t.Errorf("builds trend = %+v, %+v", builds.Trend[0], builds.Trend[1])
+	}
+	if result.Steps[2].Name != "Checkout" {
+		t.Errorf("fastest step = %v, want Checkout", result.Steps[2].Name)
+	}
+	if len(result.FailureCauses) != 2 {
+		t.Fatalf("got %v failure causes, want 2", len(result.FailureCauses))
+	}
+	push := result.FailureCauses[0]
+	if push.Cause != models.FailureCauseImagePush || push.Failures != 2 || push.Percent != 66.67 || push.Steps["Images"] != 2 {
+		t.Errorf("top failure cause = %+v", push)
+	}
+}
@@ -19,6 +19,7 @@ package apps
 import (
 	"fmt"
 
+	"github.com/go-atomci/atomci/internal/core/rbac"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils/errors"
@@ -53,11 +54,16 @@ func (manager *AppManager) GetArrange(ProjectAppID, envID int64) (*AppArrangeRes
 		}
 		imageMapings = append(imageMapings, item)
 	}
+	variables, err := DecodeArrangeVariables(arrange.Variables)
+	if err != nil {
+		log.Log.Warn("when get arrange, decode arrange: %v variables error: %s", arrange.ID, err.Error())
+	}
 	return &AppArrangeResp{
 		ID:           arrange.ID,
 		EnvID:        arrange.EnvID,
 		ProjectAppID: arrange.ProjectAppID,
 		Config:       arrange.Config,
+		Variables:    variables,
 		ImageMapings: imageMapings,
 	}, nil
 }
@@ -81,20 +87,32 @@ func (manager *AppManager) SetArrange(
 	projectAppID int64,
 	arrangeEnvID int64,
 	request *AppArrangeReq,
+	creator string,
 ) error {
-	_, err := manager.projectModel.GetProjectApp(projectAppID)
+	projectApp, err := manager.projectModel.GetProjectApp(projectAppID)
 	if err != nil {
 		if err == orm.ErrNoRows {
 			return errors.NewNotFound().SetCause(err)
 		}
 		return errors.NewInternalServerError().SetCause(err)
 	}
+	for _, envID := range append([]int64{arrangeEnvID}, request.CopyToEnvIDs...) {
+		if err := rbac.VerifyEnvAction(creator, projectApp.ProjectID, envID, rbac.ActionArrange); err != nil {
+			return errors.NewForbidden().SetCause(err)
+		}
+	}
+	variables, err := encodeArrangeVariables(request.Variables)
+	if err != nil {
+		return errors.NewBadRequest().SetCause(err)
+	}
 	request.CopyToEnvIDs = append(request.CopyToEnvIDs, arrangeEnvID)
 	if len(request.CopyToEnvIDs) > 0 {
 		for _, item := range request.CopyToEnvIDs {
-			apparrangeModel := genrateAppArrangeModel(projectAppID, item, request.Config)
+			apparrangeModel := genrateAppArrangeModel(projectAppID, item, request.Config, variables)
+			// the variables are the overlay of env, the envs copied to keep their own variables
+			keepVariables := item != arrangeEnvID
 			// create or update arrange with the config
-			id, err := manager.createOrUpdateAppConfig(apparrangeModel)
+			id, err := manager.createOrUpdateAppConfig(apparrangeModel, keepVariables)
 			if err != nil {
 				return err
 			}
@@ -142,16 +160,23 @@ func (manager *AppManager) SetArrange(
 					return err
 				}
 			}
+			if err := manager.createArrangeRevision(projectAppID, item, creator); err != nil {
+				return errors.NewInternalServerError().SetCause(err)
+			}
 		}
 	}
 	return nil
 }
 
-func (manager *AppManager) createOrUpdateAppConfig(newArrange models.AppArrange) (int64, error) {
+func (manager *AppManager) createOrUpdateAppConfig(newArrange models.AppArrange, keepVariables bool) (int64, error) {
 	// create or update arrange with the config
 	oldArrange, err := manager.model.GetAppArrange(newArrange.ProjectAppID, newArrange.EnvID)
 	if err == nil {
 		newArrange.Addons = oldArrange.Addons
+		newArrange.Revision = oldArrange.Revision
+		if keepVariables {
+			newArrange.Variables = oldArrange.Variables
+		}
 		err = manager.model.UpdateAppArrange(&newArrange)
 	} else if err == orm.ErrNoRows {
 		newArrange.Addons = models.NewAddons()
@@ -184,11 +209,12 @@ func (manager *AppManager) deleteAppImageMapping(arrangeID int64, image string)
 	return manager.model.DeleteAppImageMapping(imageMapping)
 }
 
-func genrateAppArrangeModel(appID, envID int64, config string) models.AppArrange {
+func genrateAppArrangeModel(appID, envID int64, config, variables string) models.AppArrange {
 	return models.AppArrange{
 		EnvID:        envID,
 		ProjectAppID: appID,
 		Config:       config,
+		Variables:    variables,
 	}
 }
 
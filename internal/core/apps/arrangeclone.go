@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// CloneArrange copy the arrange and image mappings of app from one env to another, the ingress hosts of arrange
+// are replaced by rewriteHost so the envs do not serve the same hosts, return the hosts of the cloned arrange
+func (manager *AppManager) CloneArrange(projectAppID, fromEnvID, toEnvID int64, rewriteHost func(string) string, creator string) ([]string, error) {
+	arrange, err := manager.GetRealArrange(projectAppID, fromEnvID)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := manager.RenderRealArrange(arrange, "", "")
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := kuberes.IngressHosts(rendered)
+	if err != nil {
+		return nil, err
+	}
+	// the longer host first, so the host which contains another host is replaced as a whole
+	sort.SliceStable(hosts, func(i, j int) bool { return len(hosts[i]) > len(hosts[j]) })
+	pairs := []string{}
+	newHosts := []string{}
+	for _, host := range hosts {
+		newHost := rewriteHost(host)
+		pairs = append(pairs, host, newHost)
+		newHosts = append(newHosts, newHost)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	newArrange := genrateAppArrangeModel(projectAppID, toEnvID, replacer.Replace(arrange.Config), replacer.Replace(arrange.Variables))
+	id, err := manager.createOrUpdateAppConfig(newArrange, false)
+	if err != nil {
+		return nil, err
+	}
+	log.Log.Debug("app: %v arrange cloned from env: %v to env: %v, hosts: %v", projectAppID, fromEnvID, toEnvID, newHosts)
+	imageMappings, err := manager.model.GetAppImageMappingByArrangeID(arrange.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range imageMappings {
+		item.ArrangeID = id
+		if _, err := manager.createAppMapping(*item); err != nil {
+			return nil, err
+		}
+	}
+	if err := manager.createArrangeRevision(projectAppID, toEnvID, creator); err != nil {
+		return nil, err
+	}
+	return newHosts, nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils/errors"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// createArrangeRevision snapshot the current config/variables/image mappings of arrange as a new revision
+func (manager *AppManager) createArrangeRevision(projectAppID, envID int64, creator string) error {
+	arrange, err := manager.model.GetAppArrange(projectAppID, envID)
+	if err != nil {
+		return err
+	}
+	imageMappings, err := manager.model.GetAppImageMappingByArrangeID(arrange.ID)
+	if err != nil {
+		return err
+	}
+	mappings := make([]ImageMaping, 0, len(imageMappings))
+	for _, item := range imageMappings {
+		mappings = append(mappings, ImageMaping{
+			Name:         item.Name,
+			Image:        item.Image,
+			ProjectAppID: item.ProjectAppID,
+			ImageTagType: item.ImageTagType,
+		})
+	}
+	mappingsContent, err := json.Marshal(mappings)
+	if err != nil {
+		return err
+	}
+	revision := &models.AppArrangeRevision{
+		Addons:        models.NewAddons(),
+		ArrangeID:     arrange.ID,
+		ProjectAppID:  projectAppID,
+		EnvID:         envID,
+		Revision:      arrange.Revision + 1,
+		Config:        arrange.Config,
+		Variables:     arrange.Variables,
+		ImageMappings: string(mappingsContent),
+		Creator:       creator,
+	}
+	if _, err := manager.model.InsertAppArrangeRevision(revision); err != nil {
+		log.Log.Error("insert arrange: %v revision: %v occur error: %s", arrange.ID, revision.Revision, err.Error())
+		return err
+	}
+	arrange.Revision = revision.Revision
+	return manager.model.UpdateAppArrange(arrange)
+}
+
+// GetArrangeRevisions return the revisions of app env arrange, the latest first
+func (manager *AppManager) GetArrangeRevisions(projectAppID, envID int64) ([]*ArrangeRevisionItem, error) {
+	arrange, err := manager.model.GetAppArrange(projectAppID, envID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return []*ArrangeRevisionItem{}, nil
+		}
+		return nil, errors.NewInternalServerError().SetCause(err)
+	}
+	revisions, err := manager.model.GetAppArrangeRevisions(arrange.ID)
+	if err != nil {
+		return nil, errors.NewInternalServerError().SetCause(err)
+	}
+	rsp := []*ArrangeRevisionItem{}
+	for _, item := range revisions {
+		rsp = append(rsp, &ArrangeRevisionItem{
+			Revision: item.Revision,
+			Creator:  item.Creator,
+			CreateAt: item.CreateAt,
+			Current:  item.Revision == arrange.Revision,
+		})
+	}
+	return rsp, nil
+}
+
+// GetArrangeRevision ..
+func (manager *AppManager) GetArrangeRevision(projectAppID, envID, revision int64) (*ArrangeRevisionResp, error) {
+	arrange, err := manager.model.GetAppArrange(projectAppID, envID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New(errors.CodeArrangeNotFound, nil)
+		}
+		return nil, errors.NewInternalServerError().SetCause(err)
+	}
+	item, err := manager.model.GetAppArrangeRevision(arrange.ID, revision)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, errors.New(errors.CodeArrangeRevisionNotFound, errors.Params{"revision": revision})
+		}
+		return nil, errors.NewInternalServerError().SetCause(err)
+	}
+	rsp := &ArrangeRevisionResp{
+		ArrangeRevisionItem: ArrangeRevisionItem{
+			Revision: item.Revision,
+			Creator:  item.Creator,
+			CreateAt: item.CreateAt,
+			Current:  item.Revision == arrange.Revision,
+		},
+		Config: item.Config,
+	}
+	if rsp.Variables, err = DecodeArrangeVariables(item.Variables); err != nil {
+		return nil, errors.NewInternalServerError().SetCause(err)
+	}
+	if item.ImageMappings != "" {
+		if err := json.Unmarshal([]byte(item.ImageMappings), &rsp.ImageMapings); err != nil {
+			return nil, errors.NewInternalServerError().SetCause(err)
+		}
+	}
+	return rsp, nil
+}
+
+// DiffArrangeRevisions return the unified diff from revision to revision, the current revision used if to is 0
+func (manager *AppManager) DiffArrangeRevisions(projectAppID, envID, from, to int64) (*ArrangeRevisionDiff, error) {
+	if to == 0 {
+		arrange, err := manager.model.GetAppArrange(projectAppID, envID)
+		if err != nil {
+			return nil, errors.New(errors.CodeArrangeNotFound, nil)
+		}
+		to = arrange.Revision
+	}
+	fromRevision, err := manager.GetArrangeRevision(projectAppID, envID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := manager.GetArrangeRevision(projectAppID, envID, to)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrangeRevisionDiff{
+		From:          from,
+		To:            to,
+		Config:        unifiedDiff(fromRevision.Config, toRevision.Config, from, to),
+		Variables:     unifiedDiff(variablesText(fromRevision.Variables), variablesText(toRevision.Variables), from, to),
+		ImageMappings: unifiedDiff(imageMappingsText(fromRevision.ImageMapings), imageMappingsText(toRevision.ImageMapings), from, to),
+	}, nil
+}
+
+// RestoreArrange save the config/variables/image mappings of revision as the new revision of arrange
+func (manager *AppManager) RestoreArrange(projectAppID, envID, revision int64, creator string) error {
+	item, err := manager.GetArrangeRevision(projectAppID, envID, revision)
+	if err != nil {
+		return err
+	}
+	arrange, err := manager.model.GetAppArrange(projectAppID, envID)
+	if err != nil {
+		return errors.NewInternalServerError().SetCause(err)
+	}
+	// reuse the image mapping items which image did not change
+	currentMappings, err := manager.model.GetAppImageMappingByArrangeID(arrange.ID)
+	if err != nil {
+		return errors.NewInternalServerError().SetCause(err)
+	}
+	for i := range item.ImageMapings {
+		item.ImageMapings[i].ArrangeID = arrange.ID
+		for _, current := range currentMappings {
+			if current.Image == item.ImageMapings[i].Image && current.ProjectAppID == item.ImageMapings[i].ProjectAppID {
+				item.ImageMapings[i].ID = current.ID
+				break
+			}
+		}
+	}
+	request := &AppArrangeReq{
+		ProjectAppID: projectAppID,
+		Config:       item.Config,
+		Variables:    item.Variables,
+		ImageMapings: item.ImageMapings,
+	}
+	log.Log.Info("restore app: %v env: %v arrange to revision: %v by %v", projectAppID, envID, revision, creator)
+	return manager.SetArrange(projectAppID, envID, request, creator)
+}
+
+func unifiedDiff(from, to string, fromRevision, toRevision int64) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(from),
+		B:        splitLines(to),
+		FromFile: fmt.Sprintf("revision-%v", fromRevision),
+		ToFile:   fmt.Sprintf("revision-%v", toRevision),
+		Context:  3,
+	})
+	if err != nil {
+		log.Log.Warn("diff arrange revision %v..%v occur error: %s", fromRevision, toRevision, err.Error())
+	}
+	return diff
+}
+
+// splitLines split the content into lines which keep the line break, the last line without line break is completed
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	lines := strings.SplitAfter(content, "\n")
+	return lines[:len(lines)-1]
+}
+
+func variablesText(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := []string{}
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%s\n", key, vars[key]))
+	}
+	return strings.Join(lines, "")
+}
+
+func imageMappingsText(mappings []ImageMaping) string {
+	lines := []string{}
+	for _, item := range mappings {
+		lines = append(lines, fmt.Sprintf("%s app:%v image:%s tag_type:%v\n", item.Name, item.ProjectAppID, item.Image, item.ImageTagType))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := variablesText(map[string]string{"replicas": "1", "cpu": "100m"})
+	to := variablesText(map[string]string{"replicas": "3", "cpu": "100m"})
+	want := `--- revision-1
++++ revision-2
+@@ -1,2 +1,2 @@
+ cpu=100m
+-replicas=1
++replicas=3
+`
+	if got := unifiedDiff(from, to, 1, 2); got != want {
+		t.Errorf("unifiedDiff() = %q, want %q", got, want)
+	}
+	if got := unifiedDiff(from, from, 1, 2); got != "" {
+		t.Errorf("unifiedDiff() of same content = %q, want empty", got)
+	}
+}
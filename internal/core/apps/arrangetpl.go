@@ -0,0 +1,282 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	yamlencoder "github.com/ghodss/yaml"
+)
+
+const arrangeTemplateName = "arrange"
+
+var (
+	templateErrPosRegex = regexp.MustCompile(`template: ` + arrangeTemplateName + `:(\d+)(?::(\d+))?: `)
+	templateStartRegex  = regexp.MustCompile(`started at ` + arrangeTemplateName + `:(\d+)`)
+	yamlErrLineRegex    = regexp.MustCompile(`line (\d+): `)
+)
+
+// ArrangeTemplateData is the data which the arrange template rendered with,
+// eg: {{ .Env.Namespace }}, {{ .App.ImageAddr }}, {{ .Vars.replicas | default "1" }}
+type ArrangeTemplateData struct {
+	Project ArrangeTemplateProject
+	Env     ArrangeTemplateEnv
+	App     ArrangeTemplateApp
+	Vars    map[string]string
+}
+
+// ArrangeTemplateProject ..
+type ArrangeTemplateProject struct {
+	ID   int64
+	Name string
+}
+
+// ArrangeTemplateEnv ..
+type ArrangeTemplateEnv struct {
+	ID         int64
+	Name       string
+	Namespace  string
+	ArrangeEnv string
+}
+
+// ArrangeTemplateApp ..
+type ArrangeTemplateApp struct {
+	ID        int64
+	Name      string
+	Branch    string
+	ImageAddr string
+}
+
+// ArrangeRenderError is the error of arrange rendering, Line/Column start with 1, zero means unknown
+type ArrangeRenderError struct {
+	Line    int
+	Column  int
+	Source  string
+	Message string
+}
+
+func (e *ArrangeRenderError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	pos := strconv.Itoa(e.Line)
+	if e.Column > 0 {
+		pos = fmt.Sprintf("%v:%v", e.Line, e.Column)
+	}
+	if e.Source == "" {
+		return fmt.Sprintf("line %s: %s", pos, e.Message)
+	}
+	return fmt.Sprintf("line %s: %s\n%4d | %s", pos, e.Message, e.Line, e.Source)
+}
+
+var arrangeTemplateFuncs = template.FuncMap{
+	"default": func(def string, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"required": func(msg string, value string) (string, error) {
+		if value == "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return value, nil
+	},
+	"quote": strconv.Quote,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// IsArrangeTemplate return true if the arrange config use template actions,
+// the arrange without actions keep the raw yaml and do not need render.
+func IsArrangeTemplate(config string) bool {
+	return strings.Contains(config, "{{")
+}
+
+// RenderArrange render the arrange config with data, and verify the output is valid yaml
+func RenderArrange(config string, data *ArrangeTemplateData) (string, error) {
+	if !IsArrangeTemplate(config) {
+		return config, nil
+	}
+	tpl, err := template.New(arrangeTemplateName).Option("missingkey=zero").Funcs(arrangeTemplateFuncs).Parse(config)
+	if err != nil {
+		return "", newTemplateRenderError(config, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, data); err != nil {
+		return "", newTemplateRenderError(config, err)
+	}
+	rendered := buf.String()
+	if err := verifyArrangeYaml(rendered); err != nil {
+		return "", err
+	}
+	return rendered, nil
+}
+
+// DecodeArrangeVariables ..
+func DecodeArrangeVariables(variables string) (map[string]string, error) {
+	vars := map[string]string{}
+	if variables == "" {
+		return vars, nil
+	}
+	if err := json.Unmarshal([]byte(variables), &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func encodeArrangeVariables(vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newTemplateRenderError convert the text/template error to ArrangeRenderError,
+// the error like: template: arrange:3:14: executing "arrange" at <.Env.Foo>: can't evaluate field Foo
+func newTemplateRenderError(config string, err error) error {
+	msg := err.Error()
+	match := templateErrPosRegex.FindStringSubmatchIndex(msg)
+	if match == nil {
+		return &ArrangeRenderError{Message: msg}
+	}
+	renderErr := &ArrangeRenderError{}
+	renderErr.Line, _ = strconv.Atoi(msg[match[2]:match[3]])
+	if match[4] >= 0 {
+		renderErr.Column, _ = strconv.Atoi(msg[match[4]:match[5]])
+	}
+	renderErr.Message = strings.TrimPrefix(msg[match[1]:], fmt.Sprintf("executing %q at ", arrangeTemplateName))
+	// the unclosed action is reported at the end of template, use the line where it started
+	if start := templateStartRegex.FindStringSubmatch(renderErr.Message); start != nil {
+		renderErr.Line, _ = strconv.Atoi(start[1])
+	}
+	renderErr.Source = sourceLine(config, renderErr.Line)
+	return renderErr
+}
+
+// verifyArrangeYaml verify every document of the rendered arrange,
+// the yaml error line is relative to the document, so convert it to the line of whole arrange.
+func verifyArrangeYaml(rendered string) error {
+	lines := strings.Split(rendered, "\n")
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && strings.TrimRight(lines[i], " \t\r") != "---" {
+			continue
+		}
+		doc := strings.Join(lines[start:i], "\n")
+		if _, err := yamlencoder.YAMLToJSON([]byte(doc)); err != nil {
+			renderErr := &ArrangeRenderError{Message: strings.TrimPrefix(err.Error(), "error converting YAML to JSON: ")}
+			if match := yamlErrLineRegex.FindStringSubmatch(renderErr.Message); match != nil {
+				line, _ := strconv.Atoi(match[1])
+				renderErr.Message = strings.Replace(renderErr.Message, match[0], "", 1)
+				renderErr.Line = start + line
+				renderErr.Source = sourceLine(rendered, renderErr.Line)
+			}
+			return renderErr
+		}
+		start = i + 1
+	}
+	return nil
+}
+
+func sourceLine(content string, line int) string {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// ArrangeTemplateData return the data of project app/env, the env variables overlay on the arrange
+func (manager *AppManager) ArrangeTemplateData(projectAppID, envID int64, vars map[string]string) (*ArrangeTemplateData, error) {
+	projectApp, err := manager.projectModel.GetProjectApp(projectAppID)
+	if err != nil {
+		return nil, err
+	}
+	data := &ArrangeTemplateData{
+		App:  ArrangeTemplateApp{ID: projectAppID},
+		Vars: map[string]string{},
+	}
+	if scmApp, err := manager.scmAppModel.GetScmAppByID(projectApp.ScmID); err == nil {
+		data.App.Name = scmApp.Name
+		data.App.Branch = scmApp.BranchName
+	}
+	if project, err := manager.projectModel.GetProjectByID(projectApp.ProjectID); err == nil {
+		data.Project = ArrangeTemplateProject{ID: project.ID, Name: project.Name}
+	}
+	if env, err := manager.projectModel.GetProjectEnvByID(envID); err == nil {
+		data.Env = ArrangeTemplateEnv{
+			ID:         env.ID,
+			Name:       env.Name,
+			Namespace:  env.Namespace,
+			ArrangeEnv: env.ArrangeEnv,
+		}
+	}
+	for key, value := range vars {
+		data.Vars[key] = value
+	}
+	return data, nil
+}
+
+// RenderRealArrange render the arrange which stored, imageAddr/branch is the app image and branch of this deploy
+func (manager *AppManager) RenderRealArrange(arrange *models.AppArrange, imageAddr, branch string) (string, error) {
+	if !IsArrangeTemplate(arrange.Config) {
+		return arrange.Config, nil
+	}
+	vars, err := DecodeArrangeVariables(arrange.Variables)
+	if err != nil {
+		return "", fmt.Errorf("decode arrange variables error: %s", err.Error())
+	}
+	data, err := manager.ArrangeTemplateData(arrange.ProjectAppID, arrange.EnvID, vars)
+	if err != nil {
+		return "", err
+	}
+	data.App.ImageAddr = imageAddr
+	if branch != "" {
+		data.App.Branch = branch
+	}
+	return RenderArrange(arrange.Config, data)
+}
+
+// PreviewArrange render the arrange config before save,
+// use the image of the first image mapping as the app image address.
+func (manager *AppManager) PreviewArrange(projectAppID, envID int64, request *AppArrangeReq) (string, error) {
+	data, err := manager.ArrangeTemplateData(projectAppID, envID, request.Variables)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range request.ImageMapings {
+		if item.ProjectAppID == projectAppID {
+			data.App.ImageAddr = item.Image
+			break
+		}
+	}
+	return RenderArrange(request.Config, data)
+}
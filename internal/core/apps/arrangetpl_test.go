@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"testing"
+)
+
+func TestRenderArrange(t *testing.T) {
+	data := &ArrangeTemplateData{
+		Env:  ArrangeTemplateEnv{Namespace: "dev"},
+		App:  ArrangeTemplateApp{Name: "order", ImageAddr: "registry.io/order:master-1a2b3c4"},
+		Vars: map[string]string{"replicas": "3"},
+	}
+	config := `metadata:
+  name: {{ .App.Name }}
+  namespace: {{ .Env.Namespace }}
+spec:
+  replicas: {{ .Vars.replicas | default "1" }}
+  cpu: {{ .Vars.cpu | default "100m" | quote }}
+  image: {{ .App.ImageAddr }}`
+	want := `metadata:
+  name: order
+  namespace: dev
+spec:
+  replicas: 3
+  cpu: "100m"
+  image: registry.io/order:master-1a2b3c4`
+	got, err := RenderArrange(config, data)
+	if err != nil {
+		t.Fatalf("RenderArrange() error: %v", err)
+	}
+	if got != want {
+		t.Errorf("RenderArrange() = %q, want %q", got, want)
+	}
+
+	raw := "image: {nginx"
+	if got, err := RenderArrange(raw, data); err != nil || got != raw {
+		t.Errorf("RenderArrange(%q) = %q, %v, want raw config", raw, got, err)
+	}
+}
+
+func TestRenderArrangeErrorPosition(t *testing.T) {
+	data := &ArrangeTemplateData{}
+	tests := []struct {
+		config string
+		line   int
+		column int
+		source string
+	}{
+		{"a: 1\nb: {{ .Vars.x \n", 2, 0, "b: {{ .Vars.x "},
+		{"a: 1\nb: 2\nc: {{ .Env.Foo }}", 3, 10, "c: {{ .Env.Foo }}"},
+		{"a: 1\nb: {{ required \"b is required\" .Vars.b }}", 2, 6, "b: {{ required \"b is required\" .Vars.b }}"},
+		{"a: {{ .Env.Name }}\n---\nb: 1\nc: [1\n", 4, 0, "c: [1"},
+	}
+	for _, tt := range tests {
+		_, err := RenderArrange(tt.config, data)
+		renderErr, ok := err.(*ArrangeRenderError)
+		if !ok {
+			t.Errorf("RenderArrange(%q) error = %v, want ArrangeRenderError", tt.config, err)
+			continue
+		}
+		if renderErr.Line != tt.line || renderErr.Column != tt.column || renderErr.Source != tt.source {
+			t.Errorf("RenderArrange(%q) error at %v:%v %q, want %v:%v %q", tt.config, renderErr.Line, renderErr.Column, renderErr.Source, tt.line, tt.column, tt.source)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/utils/errors"
+)
+
+// ArrangeValidateRsp the warnings of arrange validation, eg: deprecated apiVersion
+type ArrangeValidateRsp struct {
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ValidateArrange render the arrange for the env and the envs copied to, then validate it
+// against the openapi schema of the env cluster, so that the errors occur on save instead of deploy.
+func (manager *AppManager) ValidateArrange(projectAppID, arrangeEnvID int64, request *AppArrangeReq) (*ArrangeValidateRsp, error) {
+	rsp := &ArrangeValidateRsp{}
+	envIDs := append([]int64{arrangeEnvID}, request.CopyToEnvIDs...)
+	validated := map[int64]bool{}
+	for _, envID := range envIDs {
+		if validated[envID] {
+			continue
+		}
+		validated[envID] = true
+		envRequest := *request
+		if envID != arrangeEnvID {
+			// the envs copied to render with their own variables
+			envRequest.Variables = nil
+			if arrange, err := manager.model.GetAppArrange(projectAppID, envID); err == nil {
+				envRequest.Variables, _ = DecodeArrangeVariables(arrange.Variables)
+			}
+		}
+		env, err := manager.projectModel.GetProjectEnvByID(envID)
+		if err != nil {
+			return nil, errors.New(errors.CodeProjectEnvNotFound, errors.Params{"env_id": envID})
+		}
+		rendered, err := manager.PreviewArrange(projectAppID, envID, &envRequest)
+		if err != nil {
+			return nil, errors.New(errors.CodeArrangeEnvRenderFailed, errors.Params{"env": env.Name, "reason": err.Error()})
+		}
+		native := &kuberes.NativeTemplate{
+			Template: rendered,
+		}
+		if err := native.Validate(); err != nil {
+			return nil, errors.New(errors.CodeArrangeYAMLInvalid, errors.Params{"env": env.Name, "reason": err.Error()})
+		}
+
+		cluster, err := manager.settingsHandler.GetIntegrateSettingByID(env.Cluster)
+		if err != nil {
+			log.Log.Warn("validate arrange, get env: %v cluster: %v occur error: %s", env.Name, env.Cluster, err.Error())
+			rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("[%s] 环境未关联集群, 跳过 schema 校验", env.Name))
+			continue
+		}
+		result, err := native.ValidateForCluster(cluster.Name)
+		if err != nil {
+			return nil, errors.New(errors.CodeArrangeYAMLInvalid, errors.Params{"env": env.Name, "reason": err.Error()})
+		}
+		if len(result.Errors) > 0 {
+			return nil, errors.New(errors.CodeArrangeIncompatible, errors.Params{
+				"env":     env.Name,
+				"cluster": cluster.Name,
+				"version": result.ServerVersion,
+				"reason":  strings.Join(result.Errors, "; "),
+			})
+		}
+		for _, warning := range result.Warnings {
+			rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("[%s] %s", env.Name, warning))
+		}
+	}
+	return rsp, nil
+}
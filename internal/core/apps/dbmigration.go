@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the runners of database migration
+const (
+	DBMigrationFlyway    = "flyway"
+	DBMigrationLiquibase = "liquibase"
+	DBMigrationMigrate   = "migrate"
+)
+
+var (
+	dbMigrationPathPattern       = regexp.MustCompile(`^[A-Za-z0-9_./-]*$`)
+	dbMigrationCredentialPattern = regexp.MustCompile(`^[A-Za-z0-9_.{}-]+$`)
+	dbMigrationImagePattern      = regexp.MustCompile(`^[A-Za-z0-9_./:@-]*$`)
+)
+
+// DBMigration the database migration config of app, executed by the db-migration sub task before deploy
+type DBMigration struct {
+	// Tool flyway/liquibase/migrate
+	Tool string `json:"tool"`
+	// Dir the dir of migrations relative to the build path of app
+	Dir string `json:"dir,omitempty"`
+	// ChangeLogFile only for liquibase, default is changelog.xml
+	ChangeLogFile string `json:"changelog_file,omitempty"`
+	// Image override the default runner image of tool
+	Image string `json:"image,omitempty"`
+	// CredentialID the jenkins secret text credential of database url, {env} is replaced by the arrange env of stage
+	CredentialID string `json:"credential_id"`
+	// DownOnRollback run the down migration to the previous version when the deploy rolled back
+	DownOnRollback bool `json:"down_on_rollback,omitempty"`
+}
+
+// Validate the config is rendered into shell commands, only the safe characters are allowed
+func (m *DBMigration) Validate() error {
+	switch m.Tool {
+	case DBMigrationFlyway, DBMigrationLiquibase, DBMigrationMigrate:
+	default:
+		return fmt.Errorf("不支持的数据库迁移工具: %v，可选值为 %v/%v/%v", m.Tool, DBMigrationFlyway, DBMigrationLiquibase, DBMigrationMigrate)
+	}
+	for _, path := range []string{m.Dir, m.ChangeLogFile} {
+		if !dbMigrationPathPattern.MatchString(path) || strings.Contains(path, "..") || strings.HasPrefix(path, "/") {
+			return fmt.Errorf("数据库迁移路径: %v 无效，须为代码仓库内的相对路径", path)
+		}
+	}
+	if !dbMigrationCredentialPattern.MatchString(m.CredentialID) {
+		return fmt.Errorf("数据库迁移凭证: %v 无效", m.CredentialID)
+	}
+	if !dbMigrationImagePattern.MatchString(m.Image) {
+		return fmt.Errorf("数据库迁移镜像: %v 无效", m.Image)
+	}
+	return nil
+}
+
+// ParseDBMigration parse the db migration config stored in scm app, return nil when it is not configured
+func ParseDBMigration(config string) (*DBMigration, error) {
+	if config == "" {
+		return nil, nil
+	}
+	migration := &DBMigration{}
+	if err := json.Unmarshal([]byte(config), migration); err != nil {
+		return nil, fmt.Errorf("数据库迁移配置解析失败: %s", err.Error())
+	}
+	return migration, nil
+}
+
+// dbMigrationConfig validate and marshal the db migration config for storage
+func dbMigrationConfig(migration *DBMigration) (string, error) {
+	if migration == nil {
+		return "", nil
+	}
+	if err := migration.Validate(); err != nil {
+		return "", err
+	}
+	config, err := json.Marshal(migration)
+	if err != nil {
+		return "", err
+	}
+	return string(config), nil
+}
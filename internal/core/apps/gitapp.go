@@ -26,6 +26,8 @@ import (
 
 	"github.com/drone/go-scm/scm/driver/gitea"
 
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/core/settings"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils/query"
@@ -33,18 +35,47 @@ import (
 	"github.com/go-atomci/atomci/utils"
 
 	"github.com/drone/go-scm/scm"
+	"github.com/drone/go-scm/scm/driver/bitbucket"
 	"github.com/drone/go-scm/scm/driver/gitee"
 	"github.com/drone/go-scm/scm/driver/github"
 	"github.com/drone/go-scm/scm/driver/gitlab"
+	"github.com/drone/go-scm/scm/driver/stash"
 	"github.com/drone/go-scm/scm/transport"
 )
 
+// VerifyScmSetting verify the scm server is reachable and the token is valid
+func VerifyScmSetting(scmType string, conf *settings.ScmAuthConf) (string, error) {
+	url := strings.TrimSuffix(conf.URL, "/")
+	if !strings.Contains(url, "://") {
+		url = "https://" + url
+	}
+	// verify with the dedicated transport of the tls options, the tls configs registered by host are not changed
+	base, err := conf.TLS.Transport()
+	if err != nil {
+		return "", err
+	}
+	client, err := newScmProvider(scmType, url+"/", conf.Token, base)
+	if err != nil {
+		return "", err
+	}
+	user, _, err := client.Users.Find(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Connected to %v as %v", scmType, user.Login), nil
+}
+
 // NewScmProvider ..
 func NewScmProvider(vcsType, vcsPath, token string) (*scm.Client, error) {
+	return newScmProvider(vcsType, vcsPath, token, nil)
+}
+
+// newScmProvider base is the transport under the authorization, nil means the default transport
+func newScmProvider(vcsType, vcsPath, token string, base http.RoundTripper) (*scm.Client, error) {
 	var err error
 	var client *scm.Client
 	switch strings.ToLower(vcsType) {
-	case "gitea", "gitlab", "gogs":
+	case "gitea", "gitlab", "gogs", "bitbucket-server":
 		if strings.HasSuffix(vcsPath, ".git") {
 			vcsPath = strings.TrimSuffix(vcsPath, ".git")
 		}
@@ -62,6 +93,8 @@ func NewScmProvider(vcsType, vcsPath, token string) (*scm.Client, error) {
 			client, err = gitea.New(schema + "://" + projectPathSplit[0])
 		} else if "gitlab" == gitRepo {
 			client, err = gitlab.New(schema + "://" + projectPathSplit[0])
+		} else if "bitbucket-server" == gitRepo {
+			client, err = stash.New(schema + "://" + projectPathSplit[0])
 		} else {
 			client, err = gogs.New(schema + "://" + projectPathSplit[0])
 		}
@@ -69,30 +102,64 @@ func NewScmProvider(vcsType, vcsPath, token string) (*scm.Client, error) {
 		client = github.NewDefault()
 	case "gitee":
 		client = gitee.NewDefault()
+	case "bitbucket":
+		client = bitbucket.NewDefault()
 	default:
 		err = fmt.Errorf("source code management system not configured")
 	}
 	if client != nil {
-		client.Client = getSCMHttpClient(vcsType, token)
+		client.Client = getSCMHttpClient(vcsType, token, base)
+		if client.Client == nil {
+			client.Client = &http.Client{Transport: base}
+		}
+		client.Client.Transport = newRateLimitTransport(client.Client.Transport)
 	}
 	return client, err
 }
 
 // 根据不同类型获取客户端，若有token，则配置对应token；无token,表示公共库，不配置鉴权信息
-func getSCMHttpClient(scmType string, token string) *http.Client {
+func getSCMHttpClient(scmType string, token string, base http.RoundTripper) *http.Client {
 	if token == "" {
-		return &http.Client{}
+		return &http.Client{Transport: base}
 	}
 	switch strings.ToLower(scmType) {
-	case "gitlab", "gogs":
+	case "gitlab":
 		return &http.Client{
 			Transport: &transport.PrivateToken{
 				Token: token,
+				Base:  base,
 			}}
+	case "gogs":
+		// gogs only accept the access token in header `Authorization: token xxx`
+		return &http.Client{
+			Transport: &transport.Authorization{
+				Scheme:      "token",
+				Credentials: token,
+				Base:        base,
+			},
+		}
 	case "gitea", "gitee", "github":
 		return &http.Client{
 			Transport: &transport.BearerToken{
 				Token: token,
+				Base:  base,
+			},
+		}
+	case "bitbucket", "bitbucket-server":
+		// bitbucket app password was configured as `username:app_password`, otherwise it is an access token
+		if user, password, ok := SplitBasicToken(scmType, token); ok {
+			return &http.Client{
+				Transport: &transport.BasicAuth{
+					Username: user,
+					Password: password,
+					Base:     base,
+				},
+			}
+		}
+		return &http.Client{
+			Transport: &transport.BearerToken{
+				Token: token,
+				Base:  base,
 			},
 		}
 	default:
@@ -123,14 +190,12 @@ func (manager *AppManager) SyncAppBranches(appID int64) error {
 	}
 	branchList = append(branchList, got...)
 
-	for i := 1; i < res.Page.Last; {
-		listOptions.Page++
-		got, _, err := client.Git.ListBranches(context.Background(), scmApp.FullName, listOptions)
+	for listOptions.Page = nextPage(res, listOptions.Page); listOptions.Page > 0; listOptions.Page = nextPage(res, listOptions.Page) {
+		got, res, err = client.Git.ListBranches(context.Background(), scmApp.FullName, listOptions)
 		if err != nil {
 			return fmt.Errorf("when get branches list from gitlab occur error: %s", err.Error())
 		}
 		branchList = append(branchList, got...)
-		i++
 	}
 	for _, branch := range branchList {
 		if strings.HasPrefix(branch.Name, "release_") {
@@ -189,6 +254,22 @@ func (manager *AppManager) CreateSCMApp(item *ScmAppReq, creator string) (int64,
 	if item.Dockerfile == "" {
 		item.Dockerfile = "Dockerfile"
 	}
+	if err := verifyBuildMode(item.BuildMode); err != nil {
+		return 0, err
+	}
+	if err := manager.verifyMonorepoBuildPath(0, item.RepoID, item.FullName, item.BuildPath); err != nil {
+		return 0, err
+	}
+	if err := manager.settingsHandler.VerifyCompileEnvUsable(item.CompileEnvID); err != nil {
+		return 0, err
+	}
+	if err := manager.verifyAppArch(item.CompileEnvID, item.Arch); err != nil {
+		return 0, err
+	}
+	dbMigration, err := dbMigrationConfig(item.DBMigration)
+	if err != nil {
+		return 0, err
+	}
 	scmAppModel := models.ScmApp{
 		Addons:       models.NewAddons(),
 		Creator:      creator,
@@ -201,6 +282,10 @@ func (manager *AppManager) CreateSCMApp(item *ScmAppReq, creator string) (int64,
 		RepoID:       item.RepoID,
 		BuildPath:    item.BuildPath,
 		Dockerfile:   item.Dockerfile,
+		BuildMode:    item.BuildMode,
+		WatchPaths:   item.WatchPaths,
+		Arch:         strings.Join(models.ParseArchs(item.Arch), ","),
+		DBMigration:  dbMigration,
 	}
 
 	id, err := manager.scmAppModel.CreateScmAppIfNotExist(&scmAppModel)
@@ -256,6 +341,29 @@ func (manager *AppManager) UpdateProjectApp(scmAppID int64, req *ScmAppUpdateReq
 	} else {
 		scmApp.Dockerfile = req.Dockerfile
 	}
+	if err := manager.verifyMonorepoBuildPath(scmApp.ID, scmApp.RepoID, scmApp.FullName, scmApp.BuildPath); err != nil {
+		return err
+	}
+	if err := verifyBuildMode(req.BuildMode); err != nil {
+		return err
+	}
+	scmApp.BuildMode = req.BuildMode
+	scmApp.WatchPaths = req.WatchPaths
+	scmApp.DBMigration, err = dbMigrationConfig(req.DBMigration)
+	if err != nil {
+		return err
+	}
+
+	if req.CompileEnvID != scmApp.CompileEnvID {
+		if err := manager.settingsHandler.VerifyCompileEnvUsable(req.CompileEnvID); err != nil {
+			return err
+		}
+	}
+
+	if err := manager.verifyAppArch(req.CompileEnvID, req.Arch); err != nil {
+		return err
+	}
+	scmApp.Arch = strings.Join(models.ParseArchs(req.Arch), ",")
 
 	scmApp.BranchName = req.BranchName
 	scmApp.CompileEnvID = req.CompileEnvID
@@ -265,6 +373,38 @@ func (manager *AppManager) UpdateProjectApp(scmAppID int64, req *ScmAppUpdateReq
 	return manager.scmAppModel.UpdateSCMApp(scmApp)
 }
 
+// verifyBuildMode empty means build with dockerfile by kaniko
+func verifyBuildMode(mode string) error {
+	switch mode {
+	case "", constant.BuildModeKaniko, constant.BuildModeBuildpacks:
+		return nil
+	}
+	return fmt.Errorf("不支持的镜像构建方式: %v", mode)
+}
+
+// verifyAppArch the target architectures of app image must be supported by the compile env
+func (manager *AppManager) verifyAppArch(compileEnvID int64, arch string) error {
+	archs := models.ParseArchs(arch)
+	if err := settings.VerifyArchs(archs); err != nil {
+		return err
+	}
+	return manager.settingsHandler.VerifyCompileEnvArchs(compileEnvID, archs)
+}
+
+// verifyMonorepoBuildPath the apps share one repository must have distinct build paths
+func (manager *AppManager) verifyMonorepoBuildPath(scmAppID, repoID int64, fullName, buildPath string) error {
+	repoApps, err := manager.scmAppModel.GetScmAppsByRepo(repoID, fullName)
+	if err != nil {
+		return err
+	}
+	for _, app := range repoApps {
+		if app.ID != scmAppID && NormalizeRepoPath(app.BuildPath) == NormalizeRepoPath(buildPath) {
+			return fmt.Errorf("应用 %v 已使用仓库 %v 的构建路径 %v，同一仓库的应用构建路径不能重复", app.Name, fullName, buildPath)
+		}
+	}
+	return nil
+}
+
 func (manager *AppManager) DeleteSCMApp(scmAppID int64) error {
 	log.Log.Debug("delete project app, scmAppID: %v", scmAppID)
 
@@ -294,9 +434,15 @@ func (manager *AppManager) formatscmAppResp(modelApp *models.ScmApp) (*SCMAppRsp
 		}
 	}
 
+	dbMigration, err := ParseDBMigration(modelApp.DBMigration)
+	if err != nil {
+		log.Log.Error("parse scm app: %v db migration error: %s", modelApp.ID, err.Error())
+	}
+
 	return &SCMAppRsp{
-		ScmApp:     modelApp,
-		CompileEnv: compileEnvName,
+		ScmApp:      modelApp,
+		CompileEnv:  compileEnvName,
+		DBMigration: dbMigration,
 	}, nil
 
 }
@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// NormalizeRepoPath trim the leading `./`, `/` and the trailing `/` of the path in repository,
+// empty means the root of repository
+func NormalizeRepoPath(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, ".")
+	return strings.Trim(path, "/")
+}
+
+// AppWatchPaths return the paths watched by app, default is the build path
+func AppWatchPaths(app *models.ScmApp) []string {
+	paths := []string{}
+	for _, path := range strings.Split(app.WatchPaths, ",") {
+		if strings.TrimSpace(path) != "" {
+			paths = append(paths, NormalizeRepoPath(path))
+		}
+	}
+	if len(paths) == 0 {
+		paths = append(paths, NormalizeRepoPath(app.BuildPath))
+	}
+	return paths
+}
+
+// PathsChanged check whether any of the changed files is under the watched paths
+func PathsChanged(watchPaths, changes []string) bool {
+	for _, watchPath := range watchPaths {
+		if watchPath == "" {
+			return len(changes) > 0
+		}
+		for _, change := range changes {
+			change = NormalizeRepoPath(change)
+			if change == watchPath || strings.HasPrefix(change, watchPath+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
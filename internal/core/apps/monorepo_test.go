@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestPathsChanged(t *testing.T) {
+	changes := []string{"services/order/main.go", "README.md"}
+	tests := []struct {
+		app  *models.ScmApp
+		want bool
+	}{
+		{&models.ScmApp{BuildPath: "/"}, true},
+		{&models.ScmApp{BuildPath: "./services/order/"}, true},
+		{&models.ScmApp{BuildPath: "services/user"}, false},
+		{&models.ScmApp{BuildPath: "services/ord"}, false},
+		{&models.ScmApp{BuildPath: "services/user", WatchPaths: "libs, README.md"}, true},
+	}
+	for _, tt := range tests {
+		if got := PathsChanged(AppWatchPaths(tt.app), changes); got != tt.want {
+			t.Errorf("PathsChanged(%q, %q) = %v, want %v", tt.app.BuildPath, tt.app.WatchPaths, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// scmRetryBackoff the wait before the first retry, doubled by each retry
+const scmRetryBackoff = 500 * time.Millisecond
+
+// scmRateLimitResets the time when the exhausted rate limit of scm host resets, host -> time.Time
+var scmRateLimitResets sync.Map
+
+// scmRetries the max retries of the throttled or unavailable scm request
+func scmRetries() int {
+	return beego.AppConfig.DefaultInt("scm::retries", 3)
+}
+
+// scmRetryMaxWait the longest wait before a retry, the request fails at once if the rate limit resets later than it
+func scmRetryMaxWait() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("scm::retry_max_wait", 60)) * time.Second
+}
+
+// rateLimitTransport retry the scm requests throttled(429, 403 with exhausted rate limit) or failed by
+// the unavailable server with backoff, the `Retry-After` and rate limit reset headers of github/gitlab are respected
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	retries int
+	backoff time.Duration
+	maxWait time.Duration
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{
+		base:    base,
+		retries: scmRetries(),
+		backoff: scmRetryBackoff,
+		maxWait: scmRetryMaxWait(),
+	}
+}
+
+// RoundTrip ..
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.waitRateLimitReset(req, host); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		current := req
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			current = req.Clone(req.Context())
+			current.Body = body
+		}
+		res, err := t.base.RoundTrip(current)
+		if res != nil {
+			recordRateLimit(host, res.Header)
+		}
+		wait, retry := t.retryWait(req, res, err, attempt)
+		if !retry || attempt >= t.retries {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+			log.Log.Warn("scm request %v %v responds %v, retry after %v", req.Method, req.URL.Path, res.StatusCode, wait)
+		} else {
+			log.Log.Warn("scm request %v %v occur error: %s, retry after %v", req.Method, req.URL.Path, err.Error(), wait)
+		}
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitRateLimitReset wait until the exhausted rate limit of host resets
+func (t *rateLimitTransport) waitRateLimitReset(req *http.Request, host string) error {
+	value, ok := scmRateLimitResets.Load(host)
+	if !ok {
+		return nil
+	}
+	wait := time.Until(value.(time.Time))
+	if wait <= 0 {
+		scmRateLimitResets.Delete(host)
+		return nil
+	}
+	if wait > t.maxWait {
+		return fmt.Errorf("代码仓库 %v 接口调用次数已达上限, 将于 %v 恢复", host, value.(time.Time).Format("2006-01-02 15:04:05"))
+	}
+	log.Log.Warn("scm %v rate limit exceeded, wait %v before request %v", host, wait, req.URL.Path)
+	return sleepContext(req.Context(), wait)
+}
+
+// retryWait return the wait before retry and whether the request should be retried
+func (t *rateLimitTransport) retryWait(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if req.Body != nil && req.GetBody == nil {
+		// the body can not be sent again
+		return 0, false
+	}
+	backoff := t.backoff << uint(attempt)
+	if backoff > t.maxWait {
+		backoff = t.maxWait
+	}
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if err != nil {
+		return backoff, idempotent && req.Context().Err() == nil
+	}
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests, isRateLimitExceeded(res):
+		// the throttled request was not processed, it is safe to retry any method
+		wait, ok := rateLimitWait(res.Header, time.Now())
+		if !ok {
+			return backoff, true
+		}
+		return wait, wait <= t.maxWait
+	case res.StatusCode == http.StatusBadGateway, res.StatusCode == http.StatusServiceUnavailable, res.StatusCode == http.StatusGatewayTimeout:
+		if wait, ok := rateLimitWait(res.Header, time.Now()); ok && wait <= t.maxWait {
+			return wait, idempotent
+		}
+		return backoff, idempotent
+	}
+	return 0, false
+}
+
+// isRateLimitExceeded github responds 403 when the rate limit is exhausted
+func isRateLimitExceeded(res *http.Response) bool {
+	return res.StatusCode == http.StatusForbidden && rateLimitRemaining(res.Header) == "0"
+}
+
+// rateLimitRemaining github uses `X-RateLimit-*` headers, gitlab uses `RateLimit-*` headers
+func rateLimitRemaining(header http.Header) string {
+	if value := header.Get("X-RateLimit-Remaining"); value != "" {
+		return value
+	}
+	return header.Get("RateLimit-Remaining")
+}
+
+func rateLimitReset(header http.Header) (time.Time, bool) {
+	value := header.Get("X-RateLimit-Reset")
+	if value == "" {
+		value = header.Get("RateLimit-Reset")
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// rateLimitWait return the wait from `Retry-After`(seconds or http date), then the rate limit reset time
+func rateLimitWait(header http.Header, now time.Time) (time.Duration, bool) {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return nonNegative(at.Sub(now)), true
+		}
+	}
+	if rateLimitRemaining(header) != "0" {
+		return 0, false
+	}
+	if reset, ok := rateLimitReset(header); ok {
+		return nonNegative(reset.Sub(now)), true
+	}
+	return 0, false
+}
+
+// recordRateLimit remember the reset time of the exhausted rate limit, the later requests to the host wait for it
+func recordRateLimit(host string, header http.Header) {
+	remaining := rateLimitRemaining(header)
+	if remaining == "" {
+		return
+	}
+	if remaining != "0" {
+		scmRateLimitResets.Delete(host)
+		return
+	}
+	if reset, ok := rateLimitReset(header); ok {
+		scmRateLimitResets.Store(host, reset)
+	}
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
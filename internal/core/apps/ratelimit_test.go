@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransportRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &rateLimitTransport{
+		base:    http.DefaultTransport,
+		retries: 3,
+		backoff: time.Millisecond,
+		maxWait: time.Second,
+	}}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || calls != 3 {
+		t.Errorf("expected ok after 3 calls, got status: %v calls: %v", res.StatusCode, calls)
+	}
+}
+
+func TestRateLimitTransportResetTooLate(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &rateLimitTransport{
+		base:    http.DefaultTransport,
+		retries: 3,
+		backoff: time.Millisecond,
+		maxWait: time.Second,
+	}}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden || calls != 1 {
+		t.Errorf("expected no retry of the rate limit reset later, got status: %v calls: %v", res.StatusCode, calls)
+	}
+	// the later request fails before sent until the rate limit resets
+	if _, err := client.Get(server.URL); err == nil || calls != 1 {
+		t.Errorf("expected the request to fail before sent, got error: %v calls: %v", err, calls)
+	}
+	u, _ := url.Parse(server.URL)
+	scmRateLimitResets.Delete(u.Host)
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{http.Header{"Retry-After": []string{"5"}}, 5 * time.Second, true},
+		{http.Header{"Retry-After": []string{now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, time.Minute, true},
+		{http.Header{"X-Ratelimit-Remaining": []string{"0"}, "X-Ratelimit-Reset": []string{"1700000030"}}, 30 * time.Second, true},
+		{http.Header{"Ratelimit-Remaining": []string{"0"}, "Ratelimit-Reset": []string{"1700000010"}}, 10 * time.Second, true},
+		{http.Header{"X-Ratelimit-Remaining": []string{"10"}, "X-Ratelimit-Reset": []string{"1700000030"}}, 0, false},
+		{http.Header{}, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := rateLimitWait(test.header, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("header: %v expected %v %v, got %v %v", test.header, test.want, test.ok, got, ok)
+		}
+	}
+}
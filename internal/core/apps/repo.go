@@ -76,14 +76,12 @@ func (manager *AppManager) GetScmProjectsByRepoID(repoID int64) (interface{}, er
 		return nil, fmt.Errorf("scmclient get repositories list error: %s", err.Error())
 	}
 	repoList = append(repoList, got...)
-	for i := 1; i < rsp.Page.Last; {
-		listOptions.Page++
-		got, _, err := scmClient.Repositories.List(context.Background(), listOptions)
+	for listOptions.Page = nextPage(rsp, listOptions.Page); listOptions.Page > 0; listOptions.Page = nextPage(rsp, listOptions.Page) {
+		got, rsp, err = scmClient.Repositories.List(context.Background(), listOptions)
 		if err != nil {
 			return nil, fmt.Errorf("when get repositories list from gitlab occur error: %s", err.Error())
 		}
 		repoList = append(repoList, got...)
-		i++
 	}
 
 	newRsp := []*RepoProjectRsp{}
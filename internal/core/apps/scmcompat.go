@@ -0,0 +1,214 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/astaxie/beego"
+	"github.com/drone/go-scm/scm"
+)
+
+// ListCommits list the commits of ref, fallback to the head commit when the scm(eg: gogs) does not support list commits
+func ListCommits(ctx context.Context, client *scm.Client, repo string, opts scm.CommitListOptions) ([]*scm.Commit, error) {
+	if client.Driver == scm.DriverStash {
+		// the stash driver ignores the ref of list options
+		return listStashCommits(ctx, client, repo, opts)
+	}
+	commits, _, err := client.Git.ListCommits(ctx, repo, opts)
+	if err != scm.ErrNotSupported {
+		return commits, err
+	}
+	commit, _, err := client.Git.FindCommit(ctx, repo, opts.Ref)
+	if err != nil {
+		return nil, err
+	}
+	return []*scm.Commit{commit}, nil
+}
+
+// CreateBranch create branch from the source branch head, gitea is supported by its native api
+func CreateBranch(ctx context.Context, client *scm.Client, repo, name, sourceBranch, sha string) error {
+	_, err := client.Git.CreateBranch(ctx, repo, &scm.CreateBranch{
+		Name: name,
+		Sha:  sha,
+	})
+	if err != scm.ErrNotSupported {
+		return err
+	}
+	switch client.Driver {
+	case scm.DriverGitea:
+		body, err := json.Marshal(map[string]string{
+			"new_branch_name": name,
+			"old_branch_name": sourceBranch,
+		})
+		if err != nil {
+			return err
+		}
+		res, err := client.Do(ctx, &scm.Request{
+			Method: http.MethodPost,
+			Path:   fmt.Sprintf("api/v1/repos/%s/branches", repo),
+			Header: http.Header{"Content-Type": []string{"application/json"}},
+			Body:   bytes.NewReader(body),
+		})
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.Status > 299 {
+			return fmt.Errorf("create branch %v failed, status code: %d", name, res.Status)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%v 不支持通过 API 创建分支", client.Driver)
+	}
+}
+
+// RegisterAppWebhook register the push webhook of atomci to the app repository, skip when it already exists
+func (manager *AppManager) RegisterAppWebhook(appID int64) error {
+	atomciURL := strings.TrimSuffix(beego.AppConfig.String("atomci::url"), "/")
+	if atomciURL == "" {
+		return fmt.Errorf("请先配置 atomci 服务地址 atomci::url")
+	}
+	scmApp, err := manager.scmAppModel.GetScmAppByID(appID)
+	if err != nil {
+		return err
+	}
+	scmIntegrateResp, err := manager.settingsHandler.GetSCMIntegrateSettinByID(scmApp.RepoID)
+	if err != nil {
+		return err
+	}
+	client, err := NewScmProvider(scmIntegrateResp.Type, scmApp.Path, scmIntegrateResp.Token)
+	if err != nil {
+		return err
+	}
+	target := fmt.Sprintf("%s/atomci/api/v1/webhooks/scm/%v", atomciURL, scmApp.RepoID)
+	ctx := context.Background()
+	hooks, _, err := client.Repositories.ListHooks(ctx, scmApp.FullName, scm.ListOptions{Page: 1, Size: 100})
+	if err != nil {
+		return fmt.Errorf("获取仓库 %v webhook 列表失败: %s", scmApp.FullName, err.Error())
+	}
+	for _, hook := range hooks {
+		// gitea append the secret to the target as query
+		if strings.HasPrefix(hook.Target, target) {
+			log.Log.Info("app: %v webhook %v already exist", scmApp.Name, target)
+			return nil
+		}
+	}
+	_, _, err = client.Repositories.CreateHook(ctx, scmApp.FullName, &scm.HookInput{
+		Name:       "atomci",
+		Target:     target,
+		Secret:     beego.AppConfig.String("webhook::secret"),
+		Events:     scm.HookEvents{Push: true},
+		SkipVerify: true,
+	})
+	if err != nil {
+		return fmt.Errorf("注册仓库 %v webhook 失败: %s", scmApp.FullName, err.Error())
+	}
+	return nil
+}
+
+// SplitBasicToken split the bitbucket app password token configured as `username:app_password`
+func SplitBasicToken(scmType, token string) (string, string, bool) {
+	switch strings.ToLower(scmType) {
+	case constant.SCMBitbucket, constant.SCMBitbucketServer:
+		items := strings.SplitN(token, ":", 2)
+		if len(items) == 2 && items[0] != "" && items[1] != "" {
+			return items[0], items[1], true
+		}
+	}
+	return "", "", false
+}
+
+// nextPage return the next page number of list response, 0 means the last page,
+// bitbucket/stash only set the next page while gitlab/github set the last page
+func nextPage(res *scm.Response, current int) int {
+	if res == nil {
+		return 0
+	}
+	if res.Page.Next > current {
+		return res.Page.Next
+	}
+	if res.Page.Next == 0 && current < res.Page.Last {
+		return current + 1
+	}
+	return 0
+}
+
+type stashCommits struct {
+	Values []struct {
+		ID     string `json:"id"`
+		Author struct {
+			Name         string `json:"name"`
+			EmailAddress string `json:"emailAddress"`
+		} `json:"author"`
+		AuthorTimestamp int64  `json:"authorTimestamp"`
+		Message         string `json:"message"`
+	} `json:"values"`
+}
+
+func listStashCommits(ctx context.Context, client *scm.Client, repo string, opts scm.CommitListOptions) ([]*scm.Commit, error) {
+	namespace, name := scm.Split(repo)
+	params := url.Values{}
+	if opts.Ref != "" {
+		params.Set("until", opts.Ref)
+	}
+	if opts.Size > 0 {
+		params.Set("limit", strconv.Itoa(opts.Size))
+		if opts.Page > 1 {
+			params.Set("start", strconv.Itoa((opts.Page-1)*opts.Size))
+		}
+	}
+	res, err := client.Do(ctx, &scm.Request{
+		Method: http.MethodGet,
+		Path:   fmt.Sprintf("rest/api/1.0/projects/%s/repos/%s/commits?%s", namespace, name, params.Encode()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.Status > 299 {
+		return nil, fmt.Errorf("list repo: %v commits failed, status code: %d", repo, res.Status)
+	}
+	out := &stashCommits{}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return nil, err
+	}
+	commits := []*scm.Commit{}
+	for _, item := range out.Values {
+		commits = append(commits, &scm.Commit{
+			Sha:     item.ID,
+			Message: item.Message,
+			Author: scm.Signature{
+				Name:  item.Author.Name,
+				Email: item.Author.EmailAddress,
+				Date:  time.Unix(item.AuthorTimestamp/1000, 0),
+			},
+		})
+	}
+	return commits, nil
+}
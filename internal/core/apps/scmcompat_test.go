@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/drone/go-scm/scm"
+	_ "modernc.org/sqlite"
+)
+
+func TestNextPage(t *testing.T) {
+	tests := []struct {
+		page    scm.Page
+		current int
+		want    int
+	}{
+		{scm.Page{}, 1, 0},
+		{scm.Page{Next: 2, Last: 3}, 1, 2},
+		{scm.Page{Last: 3}, 2, 3},
+		{scm.Page{Last: 3}, 3, 0},
+		{scm.Page{Next: 3}, 2, 3},
+	}
+	for _, tt := range tests {
+		if got := nextPage(&scm.Response{Page: tt.page}, tt.current); got != tt.want {
+			t.Errorf("nextPage(%+v, %v) = %v, want %v", tt.page, tt.current, got, tt.want)
+		}
+	}
+	if got := nextPage(nil, 1); got != 0 {
+		t.Errorf("nextPage(nil, 1) = %v, want 0", got)
+	}
+}
+
+func TestSplitBasicToken(t *testing.T) {
+	if user, password, ok := SplitBasicToken("bitbucket", "atomci:app-password"); !ok || user != "atomci" || password != "app-password" {
+		t.Errorf("SplitBasicToken bitbucket app password = %v, %v, %v", user, password, ok)
+	}
+	if _, _, ok := SplitBasicToken("bitbucket", "access-token"); ok {
+		t.Errorf("SplitBasicToken bitbucket access token should not be split")
+	}
+	if _, _, ok := SplitBasicToken("gitlab", "atomci:token"); ok {
+		t.Errorf("SplitBasicToken gitlab token should not be split")
+	}
+}
+
+func TestListCommitsFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// gogs only accept the access token in header `Authorization: token xxx`
+		if r.Header.Get("Authorization") != "token scm-token" || r.URL.Path != "/api/v1/repos/atomci/app/commits/master" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"sha":"c1","commit":{"message":"init"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewScmProvider("gogs", server.URL+"/atomci/app.git", "scm-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := ListCommits(context.Background(), client, "atomci/app", scm.CommitListOptions{Ref: "master", Page: 1, Size: 10})
+	if err != nil || len(commits) != 1 || commits[0].Sha != "c1" {
+		t.Errorf("ListCommits() = %v, %v", commits, err)
+	}
+}
+
+func TestCreateBranch(t *testing.T) {
+	status := http.StatusCreated
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/repos/atomci/app/branches" ||
+			body["new_branch_name"] != "release/v1" || body["old_branch_name"] != "master" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client, err := NewScmProvider("gitea", server.URL+"/atomci/app.git", "scm-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateBranch(context.Background(), client, "atomci/app", "release/v1", "master", "c1"); err != nil {
+		t.Errorf("CreateBranch() of gitea error: %v", err)
+	}
+	status = http.StatusConflict
+	if err := CreateBranch(context.Background(), client, "atomci/app", "release/v1", "master", "c1"); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("CreateBranch() of existing branch error = %v", err)
+	}
+
+	client, err = NewScmProvider("gogs", server.URL+"/atomci/app.git", "scm-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateBranch(context.Background(), client, "atomci/app", "release/v1", "master", "c1"); err == nil || !strings.Contains(err.Error(), "不支持通过 API 创建分支") {
+		t.Errorf("CreateBranch() of gogs error = %v", err)
+	}
+}
+
+func TestRegisterAppWebhook(t *testing.T) {
+	models.InitSQLite(filepath.Join(t.TempDir(), "atomci.db"))
+	hooks := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token scm-token" || r.URL.Path != "/api/v1/repos/atomci/app/hooks" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPost {
+			hook := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&hook)
+			hooks = append(hooks, hook)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(hook)
+			return
+		}
+		json.NewEncoder(w).Encode(hooks)
+	}))
+	defer server.Close()
+
+	setting := &models.IntegrateSetting{Addons: models.NewAddons(), Name: "gogs", Type: "gogs"}
+	setting.CryptoConfig(fmt.Sprintf(`{"url":%q,"token":"scm-token"}`, server.URL))
+	repoID, err := orm.NewOrm().Insert(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appID, err := orm.NewOrm().Insert(&models.ScmApp{Addons: models.NewAddons(), Name: "app", FullName: "atomci/app", Path: server.URL + "/atomci/app.git", RepoID: repoID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewAppManager()
+	beego.AppConfig.Set("atomci::url", "")
+	if err := manager.RegisterAppWebhook(appID); err == nil {
+		t.Errorf("the webhook should not be registered without atomci url")
+	}
+	beego.AppConfig.Set("atomci::url", "http://atomci.example.com/")
+	defer beego.AppConfig.Set("atomci::url", "")
+	// the webhook is registered only once
+	for i := 0; i < 2; i++ {
+		if err := manager.RegisterAppWebhook(appID); err != nil {
+			t.Fatalf("RegisterAppWebhook() error: %v", err)
+		}
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("the webhook should be registered once, hooks: %v", hooks)
+	}
+	config, _ := hooks[0]["config"].(map[string]interface{})
+	if want := fmt.Sprintf("http://atomci.example.com/atomci/api/v1/webhooks/scm/%v", repoID); config["url"] != want {
+		t.Errorf("the webhook target = %v, want %v", config["url"], want)
+	}
+}
@@ -16,7 +16,11 @@ limitations under the License.
 
 package apps
 
-import "github.com/go-atomci/atomci/internal/models"
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
 
 type ScmAppReq struct {
 	// ProjectAppReq add app into project request body.
@@ -29,23 +33,36 @@ type ScmAppReq struct {
 	BranchName   string `json:"branch_name"`
 	BuildPath    string `json:"build_path"`
 	Dockerfile   string `json:"dockerfile"`
+	// BuildMode kaniko(default) or buildpacks, the buildpacks build the image without dockerfile
+	BuildMode string `json:"build_mode"`
+	// WatchPaths comma separated paths, the push only triggers build when the paths changed, default is build path
+	WatchPaths string `json:"watch_paths"`
+	// Arch comma separated target cpu architectures of image, eg: arm64 or amd64,arm64, empty means the default nodes
+	Arch string `json:"arch"`
+	// DBMigration the database migration executed by the db-migration sub task, nil means no migration
+	DBMigration *DBMigration `json:"db_migration,omitempty"`
 }
 
 type ScmAppUpdateReq struct {
-	BranchName   string `json:"branch_name"`
-	Language     string `json:"language"`
-	Name         string `json:"name"`
-	Path         string `json:"path"`
-	CompileEnvID int64  `json:"compile_env_id"`
-	BuildPath    string `json:"build_path"`
-	Dockerfile   string `json:"dockerfile"`
+	BranchName   string       `json:"branch_name"`
+	Language     string       `json:"language"`
+	Name         string       `json:"name"`
+	Path         string       `json:"path"`
+	CompileEnvID int64        `json:"compile_env_id"`
+	BuildPath    string       `json:"build_path"`
+	Dockerfile   string       `json:"dockerfile"`
+	BuildMode    string       `json:"build_mode"`
+	WatchPaths   string       `json:"watch_paths"`
+	Arch         string       `json:"arch"`
+	DBMigration  *DBMigration `json:"db_migration,omitempty"`
 }
 
 // SCMAppRsp ..
 type SCMAppRsp struct {
 	*models.ScmApp
-	BranchHistoryList []string `json:"branch_history_list,omitempty"`
-	CompileEnv        string   `json:"compile_env"`
+	BranchHistoryList []string     `json:"branch_history_list,omitempty"`
+	CompileEnv        string       `json:"compile_env"`
+	DBMigration       *DBMigration `json:"db_migration,omitempty"`
 }
 
 // RepoProjectRsp ..
@@ -57,15 +74,17 @@ type RepoProjectRsp struct {
 }
 
 type AppArrangConfig struct {
-	Config string `json:"config,omitempty"`
+	Config    string            `json:"config,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
 }
 
 // AppArrangeReq ..
 type AppArrangeReq struct {
-	ProjectAppID int64         `json:"project_app_id,omitempty"`
-	CopyToEnvIDs []int64       `json:"copy_to_env_ids,omitempty"`
-	Config       string        `json:"config,omitempty"`
-	ImageMapings []ImageMaping `json:"image_mapings,omitempty"`
+	ProjectAppID int64             `json:"project_app_id,omitempty"`
+	CopyToEnvIDs []int64           `json:"copy_to_env_ids,omitempty"`
+	Config       string            `json:"config,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	ImageMapings []ImageMaping     `json:"image_mapings,omitempty"`
 }
 
 type ImageMaping struct {
@@ -78,10 +97,36 @@ type ImageMaping struct {
 }
 
 type AppArrangeResp struct {
-	ID           int64         `json:"id,omitempty"`
-	Name         string        `json:"name,omitempty"`
-	EnvID        int64         `json:"env_id,omitempty"`
-	ProjectAppID int64         `json:"project_app_id,omitempty"`
-	Config       string        `json:"config,omitempty"`
-	ImageMapings []ImageMaping `json:"image_mapings,omitempty"`
+	ID           int64             `json:"id,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	EnvID        int64             `json:"env_id,omitempty"`
+	ProjectAppID int64             `json:"project_app_id,omitempty"`
+	Config       string            `json:"config,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	ImageMapings []ImageMaping     `json:"image_mapings,omitempty"`
+}
+
+// ArrangeRevisionItem ..
+type ArrangeRevisionItem struct {
+	Revision int64     `json:"revision"`
+	Creator  string    `json:"creator,omitempty"`
+	CreateAt time.Time `json:"create_at"`
+	Current  bool      `json:"current"`
+}
+
+// ArrangeRevisionResp ..
+type ArrangeRevisionResp struct {
+	ArrangeRevisionItem
+	Config       string            `json:"config,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	ImageMapings []ImageMaping     `json:"image_mapings,omitempty"`
+}
+
+// ArrangeRevisionDiff the unified diff between two revisions of arrange
+type ArrangeRevisionDiff struct {
+	From          int64  `json:"from"`
+	To            int64  `json:"to"`
+	Config        string `json:"config"`
+	Variables     string `json:"variables"`
+	ImageMappings string `json:"image_mappings"`
 }
@@ -0,0 +1,368 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// apiPrefix the prefix of router patterns
+const apiPrefix = "/atomci/api/v1"
+
+// redactedValue replace the sensitive values in snapshot and request body
+const redactedValue = "******"
+
+var sensitiveKey = regexp.MustCompile(`(?i)password|passwd|token|secret|credential|private_key`)
+
+type snapshotLoader func(params map[string]string) (interface{}, error)
+
+// resourceSpec the audited resource located by router pattern, the operations on the sub paths are regarded
+// as the changes of the resource when prefix is true, resource without loader only records the resource type
+type resourceSpec struct {
+	pattern  string
+	prefix   bool
+	kind     string
+	idParams []string
+	loader   snapshotLoader
+}
+
+// resourceSpecs the more specific pattern should be in front
+var resourceSpecs = []*resourceSpec{
+	{pattern: "/projects/create", kind: "project"},
+	{pattern: "/projects/:project_id/apps/create", kind: "project_app"},
+	{pattern: "/projects/:project_id/apps/:app_id/:env_id/arrange", prefix: true, kind: "arrange", idParams: []string{"app_id", "env_id"}, loader: loadArrange},
+	{pattern: "/projects/:project_id/apps/:project_app_id", kind: "project_app", idParams: []string{"project_app_id"}, loader: loadProjectApp},
+	{pattern: "/projects/:project_id/envs/create", kind: "project_env"},
+	{pattern: "/projects/:project_id/envs/:env_id", kind: "project_env", idParams: []string{"env_id"}, loader: loadProjectEnv},
+	{pattern: "/projects/:project_id/pipelines/create", kind: "project_pipeline"},
+	{pattern: "/projects/:project_id/pipelines/:id", kind: "project_pipeline", idParams: []string{"id"}, loader: loadProjectPipeline},
+	{pattern: "/projects/:project_id/members", prefix: true, kind: "project_member", idParams: []string{"project_id"}, loader: loadProjectMembers},
+	{pattern: "/projects/:project_id/freezes", kind: "deploy_freeze"},
+	{pattern: "/projects/:project_id/freezes/:freeze_id", kind: "deploy_freeze", idParams: []string{"freeze_id"}, loader: loadDeployFreeze},
+	{pattern: "/projects/:project_id", kind: "project", idParams: []string{"project_id"}, loader: loadProject},
+	{pattern: "/apps/create", kind: "scm_app"},
+	{pattern: "/apps/:app_id", kind: "scm_app", idParams: []string{"app_id"}, loader: loadScmApp},
+	{pattern: "/integrate/settings/create", kind: "integrate_setting"},
+	{pattern: "/integrate/settings/:id", kind: "integrate_setting", idParams: []string{"id"}, loader: loadIntegrateSetting},
+	{pattern: "/integrate/compile_envs/create", kind: "compile_env"},
+	{pattern: "/integrate/compile_envs/:id", kind: "compile_env", idParams: []string{"id"}, loader: loadCompileEnv},
+	{pattern: "/users", kind: "user"},
+	{pattern: "/users/:user", kind: "user", idParams: []string{"user"}, loader: loadUser},
+	{pattern: "/roles", kind: "role"},
+	{pattern: "/roles/:role", prefix: true, kind: "role", idParams: []string{"role"}, loader: loadRole},
+	{pattern: "/groups/:group/roles/:role/bundling", kind: "role_binding", idParams: []string{"group", "role"}, loader: loadRoleBinding},
+	{pattern: "/groups/:group/users/:user/roles", prefix: true, kind: "user_role", idParams: []string{"group", "user"}, loader: loadUserRoles},
+	{pattern: "/groups/:group/users/:user/constraints", prefix: true, kind: "user_constraint", idParams: []string{"group", "user"}, loader: loadUserConstraints},
+}
+
+// Resource the resource changed by the request
+type Resource struct {
+	Type   string
+	ID     string
+	params map[string]string
+	loader snapshotLoader
+}
+
+func (s *resourceSpec) match(pattern string) bool {
+	return pattern == s.pattern || (s.prefix && strings.HasPrefix(pattern, s.pattern+"/"))
+}
+
+// Match locate the resource by router pattern and the path params without colon prefix, nil means not matched
+func Match(routerPattern string, params map[string]string) *Resource {
+	pattern := strings.TrimPrefix(routerPattern, apiPrefix)
+	for _, spec := range resourceSpecs {
+		if !spec.match(pattern) {
+			continue
+		}
+		ids := []string{}
+		for _, key := range spec.idParams {
+			ids = append(ids, params[key])
+		}
+		return &Resource{
+			Type:   spec.kind,
+			ID:     strings.Join(ids, "/"),
+			params: params,
+			loader: spec.loader,
+		}
+	}
+	return nil
+}
+
+// Snapshot return the redacted json of the resource current state, empty when the resource does not exist
+func (r *Resource) Snapshot() string {
+	if r == nil || r.loader == nil {
+		return ""
+	}
+	item, err := r.loader(r.params)
+	if err != nil {
+		log.Log.Debug("load audit resource: %v %v snapshot occur error: %s", r.Type, r.ID, err.Error())
+		return ""
+	}
+	bytes, err := json.Marshal(item)
+	if err != nil {
+		return ""
+	}
+	return Redact(string(bytes))
+}
+
+// Action classify the resource operation, empty means the operation is read only and need not audit
+func Action(operation, method string) string {
+	readOnly := []string{"List", "ByPagination", "Stats"}
+	for _, keyword := range readOnly {
+		if strings.Contains(operation, keyword) {
+			return ""
+		}
+	}
+	readOnlyPrefixes := []string{"Get", "Verify", "Parse", "Render", "Diff", "Preview", "Plan"}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return ""
+		}
+	}
+	// the delete keywords must be in front, Unbundling contains Bundling
+	actions := []struct {
+		keywords []string
+		action   string
+	}{
+		{[]string{"Delete", "Remove", "Unbundling"}, models.AuditActionDelete},
+		{[]string{"Create", "Add", "Register"}, models.AuditActionCreate},
+		{[]string{"Update", "Set", "Restore", "Sync", "Bundling", "Scale", "Link"}, models.AuditActionUpdate},
+	}
+	for _, item := range actions {
+		for _, keyword := range item.keywords {
+			if strings.Contains(operation, keyword) {
+				return item.action
+			}
+		}
+	}
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return ""
+	case "DELETE":
+		return models.AuditActionDelete
+	case "PUT", "PATCH":
+		return models.AuditActionUpdate
+	default:
+		return models.AuditActionExecute
+	}
+}
+
+// Redact mask the sensitive values of json, the json string value is redacted recursively,
+// the content is returned unchanged when it is not json
+func Redact(content string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return content
+	}
+	bytes, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return content
+	}
+	return string(bytes)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch item := value.(type) {
+	case map[string]interface{}:
+		for key, val := range item {
+			if sensitiveKey.MatchString(key) {
+				if val != nil && val != "" {
+					item[key] = redactedValue
+				}
+				continue
+			}
+			item[key] = redactValue(val)
+		}
+		return item
+	case []interface{}:
+		for i, val := range item {
+			item[i] = redactValue(val)
+		}
+		return item
+	case string:
+		trimmed := strings.TrimSpace(item)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			return Redact(item)
+		}
+		return item
+	default:
+		return item
+	}
+}
+
+// ExportCSV write the audits as csv
+func ExportCSV(w io.Writer, audits []*models.Audit) error {
+	writer := csv.NewWriter(w)
+	header := []string{"id", "create_at", "user", "ip", "method", "operation", "resource_type", "resource_id",
+		"action", "status", "params", "body", "before", "after"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, item := range audits {
+		record := []string{
+			strconv.FormatInt(item.ID, 10),
+			item.CreateAt.Format("2006-01-02 15:04:05"),
+			item.User,
+			item.IP,
+			item.Method,
+			item.Operation,
+			item.ResourceType,
+			item.ResourceID,
+			item.Action,
+			strconv.Itoa(item.OperationStatus),
+			item.OperationObject,
+			item.OperationBody,
+			item.Before,
+			item.After,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func parseID(params map[string]string, key string) (int64, error) {
+	id, err := strconv.ParseInt(params[key], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v: %v", key, params[key])
+	}
+	return id, nil
+}
+
+func loadProject(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "project_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewProjectModel().GetProjectByID(id)
+}
+
+func loadProjectApp(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "project_app_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewProjectModel().GetProjectApp(id)
+}
+
+func loadProjectEnv(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "env_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewProjectModel().GetProjectEnvByID(id)
+}
+
+func loadProjectPipeline(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewProjectModel().GetProjectPipelineByID(id)
+}
+
+func loadProjectMembers(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "project_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewProjectModel().GetProjectUsers(id)
+}
+
+func loadDeployFreeze(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "freeze_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewProjectModel().GetDeployFreezeByID(id)
+}
+
+func loadArrange(params map[string]string) (interface{}, error) {
+	appID, err := parseID(params, "app_id")
+	if err != nil {
+		return nil, err
+	}
+	envID, err := parseID(params, "env_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewAppArrangeModel().GetAppArrange(appID, envID)
+}
+
+func loadScmApp(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "app_id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewScmAppModel().GetScmAppByID(id)
+}
+
+func loadIntegrateSetting(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewSysSettingModel().GetIntegrateSettingByID(id)
+}
+
+func loadCompileEnv(params map[string]string) (interface{}, error) {
+	id, err := parseID(params, "id")
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewSysSettingModel().GetCompileEnvByID(id)
+}
+
+func loadUser(params map[string]string) (interface{}, error) {
+	return dao.GetUser(params["user"])
+}
+
+func loadRole(params map[string]string) (interface{}, error) {
+	role, err := dao.GetGroupRoleByName("system", params["role"])
+	if err != nil {
+		return nil, err
+	}
+	operations, err := dao.GetRoleOperationsByRoleName(params["role"])
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"role": role, "operations": operations}, nil
+}
+
+func loadRoleBinding(params map[string]string) (interface{}, error) {
+	return dao.GroupRoleBundlingList(params["group"], params["role"])
+}
+
+func loadUserRoles(params map[string]string) (interface{}, error) {
+	return dao.GetGroupUserRoles(params["group"], params["user"])
+}
+
+func loadUserConstraints(params map[string]string) (interface{}, error) {
+	return dao.GetGroupUserConstraint(params["group"], params["user"])
+}
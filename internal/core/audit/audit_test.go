@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		params   map[string]string
+		wantType string
+		wantID   string
+	}{
+		{"/atomci/api/v1/projects/:project_id", map[string]string{"project_id": "1"}, "project", "1"},
+		{"/atomci/api/v1/projects/:project_id/envs/:env_id", map[string]string{"project_id": "1", "env_id": "3"}, "project_env", "3"},
+		{"/atomci/api/v1/projects/:project_id/apps/:app_id/:env_id/arrange/revisions/:revision/restore",
+			map[string]string{"project_id": "1", "app_id": "5", "env_id": "3", "revision": "2"}, "arrange", "5/3"},
+		{"/atomci/api/v1/projects/create", map[string]string{}, "project", ""},
+		{"/atomci/api/v1/roles/:role/operations/:operationID", map[string]string{"role": "dev", "operationID": "9"}, "role", "dev"},
+		{"/atomci/api/v1/projects/:project_id/publishes/create", map[string]string{"project_id": "1"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			resource := Match(tt.pattern, tt.params)
+			if tt.wantType == "" {
+				if resource != nil {
+					t.Errorf("Match() = %v, want nil", resource.Type)
+				}
+				return
+			}
+			if resource == nil {
+				t.Fatalf("Match() = nil, want %v", tt.wantType)
+			}
+			if resource.Type != tt.wantType || resource.ID != tt.wantID {
+				t.Errorf("Match() = %v %v, want %v %v", resource.Type, resource.ID, tt.wantType, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestAction(t *testing.T) {
+	tests := []struct {
+		operation string
+		method    string
+		want      string
+	}{
+		{"CreateProjectEnv", "POST", models.AuditActionCreate},
+		{"PipelineCreate", "POST", models.AuditActionCreate},
+		{"SetArrange", "POST", models.AuditActionUpdate},
+		{"RoleUnbundling", "DELETE", models.AuditActionDelete},
+		{"RoleBundling", "POST", models.AuditActionUpdate},
+		{"GetProjectEnvsByPagination", "POST", ""},
+		{"RenderArrange", "POST", ""},
+		{"ProjectList", "POST", ""},
+		{"TriggerNextStage", "POST", models.AuditActionExecute},
+		{"", "PUT", models.AuditActionUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			if got := Action(tt.operation, tt.method); got != tt.want {
+				t.Errorf("Action() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", `{"name":"dev","password":"123"}`, `{"name":"dev","password":"******"}`},
+		{"nested json string", `{"config":"{\"url\":\"http://jenkins\",\"token\":\"abc\"}"}`, `{"config":"{\"token\":\"******\",\"url\":\"http://jenkins\"}"}`},
+		{"empty secret kept", `{"token":""}`, `{"token":""}`},
+		{"array", `[{"secret":"x"}]`, `[{"secret":"******"}]`},
+		{"not json", `name=dev`, `name=dev`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.content); got != tt.want {
+				t.Errorf("Redact() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
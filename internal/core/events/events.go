@@ -0,0 +1,301 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/eventbus"
+	"github.com/go-atomci/atomci/utils"
+)
+
+// SchemaVersion the version of event schema, the fields are only added in the same version
+const SchemaVersion = "v1"
+
+// the types of pipeline events
+const (
+	TypePublishTransition = "publish.transition"
+	TypeJobStatus         = "job.status"
+)
+
+// queueSize the events are dropped when the queue is full, the message bus should not block the pipeline
+const queueSize = 1024
+
+// sinksRefreshInterval the interval of reloading the kafka/nats integrate settings
+const sinksRefreshInterval = time.Minute
+
+// Event the pipeline event published to the message bus
+type Event struct {
+	ID        string      `json:"id"`
+	Version   string      `json:"version"`
+	Type      string      `json:"type"`
+	Source    string      `json:"source"`
+	Time      time.Time   `json:"time"`
+	ProjectID int64       `json:"project_id"`
+	PublishID int64       `json:"publish_id"`
+	StageID   int64       `json:"stage_id"`
+	Data      interface{} `json:"data"`
+}
+
+// PublishTransition the publish entered a step or the step status changed
+type PublishTransition struct {
+	PublishName string `json:"publish_name"`
+	VersionNo   string `json:"version_no"`
+	Stage       string `json:"stage"`
+	Step        string `json:"step"`
+	Operation   string `json:"operation"`
+	Status      int64  `json:"status"`
+	StatusName  string `json:"status_name"`
+	Operator    string `json:"operator"`
+	Message     string `json:"message"`
+	RunID       int64  `json:"run_id"`
+	JobName     string `json:"job_name"`
+}
+
+// JobStatus the status of the build/deploy/e2e-test job changed
+type JobStatus struct {
+	JobID            int64  `json:"job_id"`
+	JobType          string `json:"job_type"`
+	Status           string `json:"status"`
+	RunID            int64  `json:"run_id"`
+	Progress         int    `json:"progress"`
+	DurationInMillis int64  `json:"duration_in_millis"`
+	StepIndex        int    `json:"step_index"`
+	Operator         string `json:"operator"`
+}
+
+var statusNames = map[int64]string{
+	models.Failed:           "failed",
+	models.Success:          "success",
+	models.Running:          "running",
+	models.Pending:          "pending",
+	models.END:              "end",
+	models.Closed:           "closed",
+	models.UnKnown:          "unknown",
+	models.TerminateSuccess: "terminate-success",
+	models.TerminateFailed:  "terminate-failed",
+	models.MergeFailed:      "merge-failed",
+	models.Skipped:          "skipped",
+}
+
+// StatusName the stable name of publish status
+func StatusName(status int64) string {
+	if name, ok := statusNames[status]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseStatusName the publish status of the stable name
+func ParseStatusName(name string) (int64, bool) {
+	for status, item := range statusNames {
+		if item == name {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+// sink the message bus configured by kafka/nats integrate setting
+type sink struct {
+	settingID int64
+	// orgID the sink only receives the events of the projects of organization, 0 means all the projects
+	orgID    int64
+	updateAt time.Time
+	topic    string
+	// subjectByType the event type is appended to the topic as nats subject
+	subjectByType bool
+	publisher     eventbus.Publisher
+}
+
+func (s *sink) topicOf(event *Event) string {
+	if s.subjectByType {
+		return s.topic + "." + event.Type
+	}
+	return s.topic
+}
+
+var (
+	startOnce sync.Once
+	queue     = make(chan *Event, queueSize)
+
+	sinksMu       sync.Mutex
+	sinks         = []*sink{}
+	sinksLoadedAt time.Time
+)
+
+// loadSinks reload the integrate settings periodically, the publishers of unchanged settings are reused
+func loadSinks() []*sink {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if time.Since(sinksLoadedAt) < sinksRefreshInterval {
+		return sinks
+	}
+	sinksLoadedAt = time.Now()
+	items, err := settings.NewSettingManager().GetIntegrateSettings([]string{settings.KafkaType, settings.NATSType})
+	if err != nil {
+		log.Log.Error("load event bus integrate settings occur error: %s", err.Error())
+		return sinks
+	}
+	existing := map[int64]*sink{}
+	for _, item := range sinks {
+		existing[item.settingID] = item
+	}
+	loaded := []*sink{}
+	for _, item := range items {
+		if current, ok := existing[item.ID]; ok && item.UpdateAt != nil && current.updateAt.Equal(*item.UpdateAt) {
+			loaded = append(loaded, current)
+			delete(existing, item.ID)
+			continue
+		}
+		next := &sink{settingID: item.ID, orgID: item.OrgID}
+		if item.UpdateAt != nil {
+			next.updateAt = *item.UpdateAt
+		}
+		switch conf := item.Config.(type) {
+		case *settings.KafkaConfig:
+			next.topic = conf.Topic
+			next.publisher = eventbus.NewKafkaPublisher(conf.URL, conf.User, conf.Password, conf.Insecure)
+		case *settings.NATSConfig:
+			next.topic = conf.Subject
+			next.subjectByType = true
+			next.publisher = eventbus.NewNATSPublisher(conf.URL, conf.User, conf.Password, conf.Token, conf.Insecure)
+		default:
+			continue
+		}
+		loaded = append(loaded, next)
+	}
+	for _, item := range existing {
+		item.publisher.Close()
+	}
+	sinks = loaded
+	return sinks
+}
+
+func start() {
+	startOnce.Do(func() {
+		go func() {
+			for event := range queue {
+				deliver(event)
+			}
+		}()
+	})
+}
+
+func deliver(event *Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Log.Error("marshal event: %v occur error: %s", event.ID, err.Error())
+		return
+	}
+	key := strconv.FormatInt(event.PublishID, 10)
+	orgID := int64(0)
+	if project, err := dao.NewProjectModel().GetProjectByID(event.ProjectID); err == nil {
+		orgID = project.OrgID
+	}
+	for _, item := range loadSinks() {
+		if item.orgID != 0 && item.orgID != orgID {
+			continue
+		}
+		topic := item.topicOf(event)
+		err := item.publisher.Publish(topic, key, payload)
+		if err != nil {
+			// retry once, the broken connection is reestablished on the next publish
+			err = item.publisher.Publish(topic, key, payload)
+		}
+		if err != nil {
+			log.Log.Warn("publish event: %v to %v occur error: %s", event.ID, topic, err.Error())
+		}
+	}
+}
+
+func newEvent(eventType string, projectID, publishID, stageID int64, data interface{}) *Event {
+	return &Event{
+		ID:        utils.NewUUID(),
+		Version:   SchemaVersion,
+		Type:      eventType,
+		Source:    "atomci",
+		Time:      time.Now(),
+		ProjectID: projectID,
+		PublishID: publishID,
+		StageID:   stageID,
+		Data:      data,
+	}
+}
+
+// emit queue the event without blocking, the event is dropped when no message bus configured or the queue is full
+func emit(event *Event) {
+	start()
+	select {
+	case queue <- event:
+	default:
+		log.Log.Warn("event queue is full, drop event: %v %v of publish: %v", event.Type, event.ID, event.PublishID)
+	}
+}
+
+func enabled() bool {
+	return len(loadSinks()) > 0
+}
+
+// EmitPublishTransition publish the event of the operation log, which records every transition of publish
+func EmitPublishTransition(operationLog *models.PublishOperationLog) {
+	if !enabled() {
+		return
+	}
+	publish, err := dao.NewPublishModel().GetPublishByID(operationLog.PublishID)
+	if err != nil {
+		log.Log.Warn("when emit publish transition event, get publish: %v occur error: %s", operationLog.PublishID, err.Error())
+		return
+	}
+	emit(newEvent(TypePublishTransition, publish.ProjectID, publish.ID, operationLog.StageID, &PublishTransition{
+		PublishName: publish.Name,
+		VersionNo:   publish.VersionNo,
+		Stage:       operationLog.Stage,
+		Step:        operationLog.Step,
+		Operation:   operationLog.Type,
+		Status:      operationLog.Status,
+		StatusName:  StatusName(operationLog.Status),
+		Operator:    operationLog.Creator,
+		Message:     operationLog.Message,
+		RunID:       operationLog.RunID,
+		JobName:     operationLog.JobName,
+	}))
+}
+
+// EmitJobStatus publish the event of the job status changed
+func EmitJobStatus(job *models.PublishJob) {
+	if !enabled() {
+		return
+	}
+	emit(newEvent(TypeJobStatus, job.ProjectID, job.PublishID, job.EnvID, &JobStatus{
+		JobID:            job.ID,
+		JobType:          job.JobType,
+		Status:           job.Status,
+		RunID:            job.RunID,
+		Progress:         job.Progress,
+		DurationInMillis: job.DurationInMillis,
+		StepIndex:        job.StepIndex,
+		Operator:         job.Operator,
+	}))
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestEventSchema(t *testing.T) {
+	event := newEvent(TypeJobStatus, 1, 2, 3, &JobStatus{JobID: 4, JobType: models.JobTypeBuild, Status: models.StatusSuccess})
+	content, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"id", "version", "type", "source", "time", "project_id", "publish_id", "stage_id", "data"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("event field %v is missing", key)
+		}
+	}
+	if fields["version"] != SchemaVersion || fields["type"] != TypeJobStatus || fields["id"] == "" {
+		t.Errorf("event = %s", content)
+	}
+	data := fields["data"].(map[string]interface{})
+	if data["job_type"] != "build" || data["status"] != "SUCCESS" || data["job_id"] != float64(4) {
+		t.Errorf("event data = %v", data)
+	}
+
+	kafka := &sink{topic: "atomci.pipeline.events"}
+	nats := &sink{topic: "atomci.pipeline", subjectByType: true}
+	if kafka.topicOf(event) != "atomci.pipeline.events" || nats.topicOf(event) != "atomci.pipeline.job.status" {
+		t.Errorf("topics = %v, %v", kafka.topicOf(event), nats.topicOf(event))
+	}
+}
+
+func TestStatusName(t *testing.T) {
+	if StatusName(models.Success) != "success" || StatusName(models.TerminateFailed) != "terminate-failed" || StatusName(100) != "unknown" {
+		t.Errorf("unexpected status names")
+	}
+}
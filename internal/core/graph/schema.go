@@ -0,0 +1,373 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-atomci/atomci/internal/core/events"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/graphql"
+	"github.com/go-atomci/atomci/utils/query"
+
+	"github.com/astaxie/beego/orm"
+)
+
+const (
+	defaultFirst = 20
+	maxFirst     = 100
+)
+
+// Viewer the user of the query and the projects which the user can access
+type Viewer struct {
+	User       string
+	ProjectIDs []int64
+}
+
+func (v *Viewer) canAccess(projectID int64) bool {
+	for _, id := range v.ProjectIDs {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// request the state of one query, the apps are cached since they are shared by publishes and jobs
+type request struct {
+	viewer *Viewer
+	apps   map[int64]*App
+}
+
+type requestKey struct{}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// App the app of project with the scm info
+type App struct {
+	ID         int64  `json:"id"`
+	ProjectID  int64  `json:"project_id"`
+	ScmID      int64  `json:"scm_id"`
+	Name       string `json:"name"`
+	FullName   string `json:"full_name"`
+	Language   string `json:"language"`
+	BranchName string `json:"branch_name"`
+	Path       string `json:"path"`
+}
+
+// Execute run the read only query of the viewer
+func Execute(ctx context.Context, viewer *Viewer, params graphql.Params) *graphql.Result {
+	ctx = context.WithValue(ctx, requestKey{}, &request{viewer: viewer, apps: map[int64]*App{}})
+	return graphql.Do(ctx, schema(), params)
+}
+
+var (
+	schemaOnce sync.Once
+	rootSchema *graphql.Schema
+)
+
+// schema the types reference each other, so the fields are filled after all the objects are declared
+func schema() *graphql.Schema {
+	schemaOnce.Do(func() {
+		project := &graphql.Object{Name: "Project"}
+		env := &graphql.Object{Name: "Env"}
+		app := &graphql.Object{Name: "App"}
+		publish := &graphql.Object{Name: "Publish"}
+		publishApp := &graphql.Object{Name: "PublishApp"}
+		job := &graphql.Object{Name: "Job"}
+		jobApp := &graphql.Object{Name: "JobApp"}
+
+		publishesArgs := map[string]*graphql.Argument{
+			"stage_id": {Type: "Int"},
+			"status":   {Type: "String"},
+			"first":    {Type: "Int", Default: int64(defaultFirst)},
+			"after_id": {Type: "Int"},
+		}
+
+		project.Fields = scalarFields("id", "name", "description", "status", "owner", "creator", "max_concurrent_builds", "create_at", "update_at")
+		project.Fields["envs"] = &graphql.Field{Type: env, Resolve: resolveProjectEnvs}
+		project.Fields["apps"] = &graphql.Field{Type: app, Resolve: resolveProjectApps}
+		project.Fields["publishes"] = &graphql.Field{Type: publish, Args: publishesArgs, Resolve: resolveProjectPublishes}
+
+		env.Fields = scalarFields("id", "project_id", "name", "description", "cluster", "namespace", "arrange_env", "concurrency_policy", "create_at", "update_at")
+
+		app.Fields = scalarFields("id", "project_id", "scm_id", "name", "full_name", "language", "branch_name", "path")
+
+		publish.Fields = scalarFields("id", "project_id", "name", "version_no", "creator", "pipeline_id", "stage_id", "stage_name", "step", "step_type", "issues", "start_at", "end_at", "create_at", "update_at")
+		publish.Fields["status"] = &graphql.Field{Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return events.StatusName(p.Source.(*models.Publish).Status), nil
+		}}
+		publish.Fields["project"] = &graphql.Field{Type: project, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return getProject(p.Source.(*models.Publish).ProjectID)
+		}}
+		publish.Fields["env"] = &graphql.Field{Type: env, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return getEnv(p.Source.(*models.Publish).StageID)
+		}}
+		publish.Fields["apps"] = &graphql.Field{Type: publishApp, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return dao.NewPublishModel().GetPublishAppsByID(p.Source.(*models.Publish).ID)
+		}}
+		publish.Fields["jobs"] = &graphql.Field{
+			Type: job,
+			Args: map[string]*graphql.Argument{
+				"first":    {Type: "Int", Default: int64(defaultFirst)},
+				"job_type": {Type: "String"},
+			},
+			Resolve: resolvePublishJobs,
+		}
+
+		publishApp.Fields = scalarFields("id", "publish_id", "project_app_id", "branch_name", "release_branch", "image_digest", "create_at", "update_at")
+		publishApp.Fields["app"] = &graphql.Field{Type: app, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return getApp(p.Context, p.Source.(*models.PublishApp).ProjectAppID)
+		}}
+
+		job.Fields = scalarFields("id", "publish_id", "project_id", "stage_id", "status", "run_id", "progress", "duration_in_millis", "operator", "job_type", "step_index", "create_at", "update_at")
+		job.Fields["env"] = &graphql.Field{Type: env, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return getEnv(p.Source.(*models.PublishJob).EnvID)
+		}}
+		job.Fields["apps"] = &graphql.Field{Type: jobApp, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return dao.NewPublishJobModel().GetPublishJobApps(p.Source.(*models.PublishJob).ID)
+		}}
+
+		jobApp.Fields = scalarFields("id", "publish_job_id", "project_app_id", "branch_name", "commit_sha", "image_addr", "image_version", "release", "gray", "arrange_revision", "create_at", "update_at")
+		jobApp.Fields["app"] = &graphql.Field{Type: app, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return getApp(p.Context, p.Source.(*models.PublishJobApp).ProjectAPPID)
+		}}
+
+		queryType := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+			"projects": {
+				Type:    project,
+				Args:    map[string]*graphql.Argument{"first": {Type: "Int", Default: int64(maxFirst)}, "after_id": {Type: "Int"}},
+				Resolve: resolveProjects,
+			},
+			"project": {
+				Type:    project,
+				Args:    map[string]*graphql.Argument{"id": {Type: "Int!"}},
+				Resolve: resolveProject,
+			},
+			"publishes": {
+				Type:    publish,
+				Args:    withProjectID(publishesArgs),
+				Resolve: resolvePublishes,
+			},
+			"publish": {
+				Type:    publish,
+				Args:    map[string]*graphql.Argument{"id": {Type: "Int!"}},
+				Resolve: resolvePublish,
+			},
+		}}
+		rootSchema = &graphql.Schema{Query: queryType}
+	})
+	return rootSchema
+}
+
+// scalarFields the fields read from the json tag of models
+func scalarFields(names ...string) map[string]*graphql.Field {
+	fields := map[string]*graphql.Field{}
+	for _, name := range names {
+		fields[name] = &graphql.Field{}
+	}
+	return fields
+}
+
+func withProjectID(args map[string]*graphql.Argument) map[string]*graphql.Argument {
+	items := map[string]*graphql.Argument{"project_id": {Type: "Int!"}}
+	for name, arg := range args {
+		items[name] = arg
+	}
+	return items
+}
+
+// cursorArgs the first and after_id arguments as the cursor query
+func cursorArgs(args map[string]interface{}) (*query.CursorQuery, error) {
+	first, _ := args["first"].(int64)
+	if first <= 0 || first > maxFirst {
+		return nil, fmt.Errorf("first 参数需在 1 到 %d 之间", maxFirst)
+	}
+	after, _ := args["after_id"].(int64)
+	return &query.CursorQuery{After: after, Limit: int(first)}, nil
+}
+
+// trimCursor the dao fetch one more item to tell whether there is a next page, which is not used by graphql
+func trimCursor(count int, cursor *query.CursorQuery) int {
+	if count > cursor.Limit {
+		return cursor.Limit
+	}
+	return count
+}
+
+func resolveProjects(p graphql.ResolveParams) (interface{}, error) {
+	cursor, err := cursorArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	items, err := dao.NewProjectModel().GetProjectsByCursor(requestFrom(p.Context).viewer.ProjectIDs, cursor)
+	if err != nil {
+		return nil, err
+	}
+	return items[:trimCursor(len(items), cursor)], nil
+}
+
+func resolveProject(p graphql.ResolveParams) (interface{}, error) {
+	projectID := p.Args["id"].(int64)
+	if !requestFrom(p.Context).viewer.canAccess(projectID) {
+		return nil, fmt.Errorf("项目不存在或无权访问: %v", projectID)
+	}
+	return getProject(projectID)
+}
+
+func resolveProjectEnvs(p graphql.ResolveParams) (interface{}, error) {
+	return dao.NewProjectModel().GetProjectEnvs(p.Source.(*models.Project).ID)
+}
+
+func resolveProjectApps(p graphql.ResolveParams) (interface{}, error) {
+	items, err := dao.NewProjectModel().GetProjectApps(p.Source.(*models.Project).ID)
+	if err != nil {
+		return nil, err
+	}
+	apps := []*App{}
+	for _, item := range items {
+		app, err := getApp(p.Context, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		if app != nil {
+			apps = append(apps, app)
+		}
+	}
+	return apps, nil
+}
+
+func resolveProjectPublishes(p graphql.ResolveParams) (interface{}, error) {
+	return listPublishes(p.Source.(*models.Project).ID, p.Args)
+}
+
+func resolvePublishes(p graphql.ResolveParams) (interface{}, error) {
+	projectID := p.Args["project_id"].(int64)
+	if !requestFrom(p.Context).viewer.canAccess(projectID) {
+		return nil, fmt.Errorf("项目不存在或无权访问: %v", projectID)
+	}
+	return listPublishes(projectID, p.Args)
+}
+
+func listPublishes(projectID int64, args map[string]interface{}) (interface{}, error) {
+	cursor, err := cursorArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	filter := &models.PublishListFilter{}
+	filter.StageID, _ = args["stage_id"].(int64)
+	if names, _ := args["status"].(string); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			item, ok := events.ParseStatusName(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("status 参数错误: %v", name)
+			}
+			filter.Status = append(filter.Status, item)
+		}
+	}
+	items, err := dao.NewPublishModel().GetPublishesByCursor(projectID, filter, cursor)
+	if err != nil {
+		return nil, err
+	}
+	return items[:trimCursor(len(items), cursor)], nil
+}
+
+func resolvePublish(p graphql.ResolveParams) (interface{}, error) {
+	publishID := p.Args["id"].(int64)
+	publish, err := dao.NewPublishModel().GetPublishByID(publishID)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	if err == orm.ErrNoRows || !requestFrom(p.Context).viewer.canAccess(publish.ProjectID) {
+		return nil, fmt.Errorf("流水线不存在或无权访问: %v", publishID)
+	}
+	return publish, nil
+}
+
+func resolvePublishJobs(p graphql.ResolveParams) (interface{}, error) {
+	cursor, err := cursorArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	// the jobs of a publish are few, so fetch all of them and filter by job type
+	items, err := dao.NewPublishJobModel().GetPublishJobsByPublishID(p.Source.(*models.Publish).ID)
+	if err != nil {
+		return nil, err
+	}
+	jobType, _ := p.Args["job_type"].(string)
+	jobs := []*models.PublishJob{}
+	for _, item := range items {
+		if jobType != "" && item.JobType != jobType {
+			continue
+		}
+		if len(jobs) == cursor.Limit {
+			break
+		}
+		jobs = append(jobs, item)
+	}
+	return jobs, nil
+}
+
+func getProject(projectID int64) (*models.Project, error) {
+	item, err := dao.NewProjectModel().GetProjectByID(projectID)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	return item, err
+}
+
+func getEnv(envID int64) (*models.ProjectEnv, error) {
+	item, err := dao.NewProjectModel().GetProjectEnvByID(envID)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	return item, err
+}
+
+// getApp get the project app with the scm info, nil if the app was deleted
+func getApp(ctx context.Context, projectAppID int64) (*App, error) {
+	req := requestFrom(ctx)
+	if projectAppID == 0 {
+		return nil, nil
+	}
+	if app, ok := req.apps[projectAppID]; ok {
+		return app, nil
+	}
+	projectApp, err := dao.NewProjectModel().GetProjectApp(projectAppID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			req.apps[projectAppID] = nil
+			return nil, nil
+		}
+		return nil, err
+	}
+	app := &App{ID: projectApp.ID, ProjectID: projectApp.ProjectID, ScmID: projectApp.ScmID}
+	if scmApp, err := dao.NewScmAppModel().GetScmAppByID(projectApp.ScmID); err == nil {
+		app.Name, app.FullName, app.Language = scmApp.Name, scmApp.FullName, scmApp.Language
+		app.BranchName, app.Path = scmApp.BranchName, scmApp.Path
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+	req.apps[projectAppID] = app
+	return app, nil
+}
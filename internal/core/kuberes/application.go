@@ -525,9 +525,12 @@ func CreateRegistrySecret(cluster, namespace string, envID int64) error {
 	var registryAddr, registryUser, registryPassword, registryAuth string
 	if registryConf, ok := integrateSettingRegistry.Config.(*settings.RegistryConfig); ok {
 		registryAddr = registryConf.URL
-		registryPassword = registryConf.Password
-		registryUser = registryConf.User
-		registryAuth = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", registryConf.User, registryConf.Password)))
+		registryUser, registryPassword, err = registryConf.Credential()
+		if err != nil {
+			log.Log.Error("when create registry secret get registry credential occur error: %s", err.Error())
+			return err
+		}
+		registryAuth = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", registryUser, registryPassword)))
 	} else {
 		log.Log.Error("parse integrate setting registry config error")
 		return fmt.Errorf("parse integrate setting registry config error")
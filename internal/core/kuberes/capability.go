@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+type cachedCapability struct {
+	capability *settings.ClusterCapability
+	fetchAt    time.Time
+}
+
+var clusterCapabilities sync.Map
+
+// getClusterCapability return the capability detected when the cluster bound, cached like the schema
+func getClusterCapability(cluster string) (*settings.ClusterCapability, error) {
+	if cached, ok := clusterCapabilities.Load(cluster); ok {
+		item := cached.(*cachedCapability)
+		if time.Since(item.fetchAt) < schemaCacheTTL {
+			return item.capability, nil
+		}
+	}
+	pm := settings.NewSettingManager()
+	setting, err := pm.GetIntegrateSettingByName(cluster, settings.KubernetesType)
+	if err != nil {
+		return nil, err
+	}
+	capability, err := pm.GetClusterCapability(setting.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	clusterCapabilities.Store(cluster, &cachedCapability{capability: capability, fetchAt: time.Now()})
+	return capability, nil
+}
+
+// clusterSupports return true if the cluster serves the kind, the unknown capability is treated as supported
+func clusterSupports(cluster, apiVersion, kind string) bool {
+	capability, err := getClusterCapability(cluster)
+	if err != nil {
+		log.Log.Warn("get capability of cluster: %v occur error: %s", cluster, err.Error())
+		return true
+	}
+	return capability.Supports(apiVersion, kind)
+}
+
+// unavailableResources return the resources whose kind is not served by the cluster
+func unavailableResources(capability *settings.ClusterCapability, resObjects []*ResObject) []string {
+	items := []string{}
+	for _, obj := range resObjects {
+		gvk := obj.Object.GetObjectKind().GroupVersionKind()
+		apiVersion := gvk.GroupVersion().String()
+		if capability.Supports(apiVersion, gvk.Kind) {
+			continue
+		}
+		items = append(items, fmt.Sprintf("%s/%s(%s)", gvk.Kind, obj.Name, apiVersion))
+	}
+	return items
+}
+
+// checkClusterCapability block the deploy if the cluster does not serve the kinds of template, eg: the CRD is not
+// installed, the deploy continues if the capability is unknown
+func (t *NativeTemplate) checkClusterCapability(cluster string) error {
+	capability, err := getClusterCapability(cluster)
+	if err != nil {
+		log.Log.Warn("get capability of cluster: %v occur error: %s, skip the check", cluster, err.Error())
+		return nil
+	}
+	resObjects, err := t.parser()
+	if err != nil {
+		return err
+	}
+	if missing := unavailableResources(capability, resObjects); len(missing) > 0 {
+		return fmt.Errorf("集群 %s %s 不支持以下资源类型, 请确认 apiVersion 或先安装对应的 CRD: %s",
+			cluster, capability.Version, strings.Join(missing, ", "))
+	}
+	return nil
+}
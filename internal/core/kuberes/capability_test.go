@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+)
+
+func TestCheckClusterCapability(t *testing.T) {
+	clusterCapabilities.Store("capability", &cachedCapability{
+		capability: &settings.ClusterCapability{
+			Version:   "v1.22.3",
+			Resources: []string{"apps/v1/Deployment", "v1/Service"},
+		},
+		fetchAt: time.Now(),
+	})
+	native := &NativeTemplate{Template: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: demo\n---\n" +
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: demo"}
+	if err := native.checkClusterCapability("capability"); err != nil {
+		t.Errorf("expect no error, got %v", err)
+	}
+	native.Template += "\n---\napiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: demo"
+	if err := native.checkClusterCapability("capability"); err == nil {
+		t.Error("expect error of missing crd")
+	}
+	resObjects, err := native.parser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	capability, _ := getClusterCapability("capability")
+	want := []string{"ServiceMonitor/demo(monitoring.coreos.com/v1)"}
+	if got := unavailableResources(capability, resObjects); !reflect.DeepEqual(got, want) {
+		t.Errorf("unavailableResources() = %v, want %v", got, want)
+	}
+}
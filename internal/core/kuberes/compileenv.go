@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/kube"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the waiting reasons which mean the image can not be pulled
+var imagePullFailedReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+}
+
+// compileEnvValidationInterval the interval of checking the validation pod
+var compileEnvValidationInterval = 2 * time.Second
+
+// compileEnvPodLogs return the logs of the validation pod
+var compileEnvPodLogs = func(client kubernetes.Interface, namespace, name string) ([]byte, error) {
+	return client.CoreV1().Pods(namespace).GetLogs(name, &apiv1.PodLogOptions{}).Do().Raw()
+}
+
+// ValidateCompileEnv test pull the image of compile env and run its version command in the namespace of cluster
+func ValidateCompileEnv(cluster, namespace string, compileEnv *models.CompileEnv, timeout time.Duration) (*settings.CompileEnvValidation, error) {
+	client, _, err := kube.GetClientset(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return validateCompileEnv(client, namespace, compileEnv, timeout)
+}
+
+func validateCompileEnv(client kubernetes.Interface, namespace string, compileEnv *models.CompileEnv, timeout time.Duration) (*settings.CompileEnvValidation, error) {
+	pod, err := compileEnvValidationPod(namespace, compileEnv)
+	if err != nil {
+		return nil, err
+	}
+	pod, err = client.CoreV1().Pods(namespace).Create(pod)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := client.CoreV1().Pods(namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
+			log.Log.Warn("delete compile env validation pod: %v occur error: %s", pod.Name, err.Error())
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := client.CoreV1().Pods(namespace).Get(pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		switch current.Status.Phase {
+		case apiv1.PodSucceeded, apiv1.PodFailed:
+			output, err := compileEnvPodLogs(client, namespace, pod.Name)
+			if err != nil {
+				output = []byte(fmt.Sprintf("获取日志失败: %s", err.Error()))
+			}
+			status := models.CompileEnvValidationSuccess
+			if current.Status.Phase == apiv1.PodFailed {
+				status = models.CompileEnvValidationFailed
+			}
+			return &settings.CompileEnvValidation{Status: status, Output: strings.TrimSpace(string(output))}, nil
+		}
+		for _, containerStatus := range current.Status.ContainerStatuses {
+			if waiting := containerStatus.State.Waiting; waiting != nil && imagePullFailedReasons[waiting.Reason] {
+				return &settings.CompileEnvValidation{
+					Status: models.CompileEnvValidationFailed,
+					Output: fmt.Sprintf("镜像 %v 拉取失败: %v %v", compileEnv.Image, waiting.Reason, waiting.Message),
+				}, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return &settings.CompileEnvValidation{
+				Status: models.CompileEnvValidationFailed,
+				Output: fmt.Sprintf("校验超时(%v)，当前状态: %v", timeout, current.Status.Phase),
+			}, nil
+		}
+		time.Sleep(compileEnvValidationInterval)
+	}
+}
+
+// compileEnvValidationPod the pod runs the version command with the resources of compile env
+func compileEnvValidationPod(namespace string, compileEnv *models.CompileEnv) (*apiv1.Pod, error) {
+	command := strings.TrimSpace(compileEnv.VersionCommand)
+	if command == "" {
+		return nil, fmt.Errorf("编译环境 %v 未配置版本命令，例如: go version", compileEnv.Name)
+	}
+	resources, err := CompileEnvResources(compileEnv)
+	if err != nil {
+		return nil, err
+	}
+	shell := []string{"/bin/sh", "-c", command}
+	var nodeSelector map[string]string
+	if compileEnv.CompileEnvOS() == models.CompileEnvOSWindows {
+		// the windows image runs on the windows nodes only
+		shell = []string{"powershell", "-Command", command}
+		if compileEnv.CompileEnvShell() == models.CompileEnvShellBat {
+			shell = []string{"cmd", "/S", "/C", command}
+		}
+		nodeSelector = map[string]string{"kubernetes.io/os": models.CompileEnvOSWindows}
+	}
+	envs := []apiv1.EnvVar{}
+	for _, env := range settings.ProxyEnvVars() {
+		envs = append(envs, apiv1.EnvVar{Name: env.Key, Value: env.Value})
+	}
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "compile-env-validation-",
+			Namespace:    namespace,
+			Labels: map[string]string{
+				"atomci.io/compile-env": fmt.Sprintf("%v", compileEnv.ID),
+			},
+		},
+		Spec: apiv1.PodSpec{
+			RestartPolicy: apiv1.RestartPolicyNever,
+			NodeSelector:  nodeSelector,
+			Containers: []apiv1.Container{
+				{
+					Name:            "validation",
+					Image:           settings.MirrorImage(compileEnv.Image),
+					ImagePullPolicy: apiv1.PullAlways,
+					Env:             envs,
+					Command:         shell,
+					Resources:       resources,
+				},
+			},
+		},
+	}, nil
+}
+
+// CompileEnvResources return the resource requirements of compile env
+func CompileEnvResources(compileEnv *models.CompileEnv) (apiv1.ResourceRequirements, error) {
+	requirements := apiv1.ResourceRequirements{}
+	items := []struct {
+		list  *apiv1.ResourceList
+		name  apiv1.ResourceName
+		value string
+	}{
+		{list: &requirements.Requests, name: apiv1.ResourceCPU, value: compileEnv.CPURequest},
+		{list: &requirements.Requests, name: apiv1.ResourceMemory, value: compileEnv.MemoryRequest},
+		{list: &requirements.Limits, name: apiv1.ResourceCPU, value: compileEnv.CPULimit},
+		{list: &requirements.Limits, name: apiv1.ResourceMemory, value: compileEnv.MemoryLimit},
+	}
+	for _, item := range items {
+		if item.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(item.value)
+		if err != nil {
+			return requirements, fmt.Errorf("无效的资源配置 %v: %v", item.name, item.value)
+		}
+		if *item.list == nil {
+			*item.list = apiv1.ResourceList{}
+		}
+		(*item.list)[item.name] = quantity
+	}
+	return requirements, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestValidateCompileEnv(t *testing.T) {
+	compileEnvValidationInterval = time.Millisecond
+	compileEnvPodLogs = func(client kubernetes.Interface, namespace, name string) ([]byte, error) {
+		return []byte("go version go1.17 linux/amd64\n"), nil
+	}
+	compileEnv := &models.CompileEnv{Name: "golang", Image: "golang:1.17", VersionCommand: "go version", CPULimit: "1", MemoryRequest: "256Mi"}
+	tests := []struct {
+		name       string
+		status     apiv1.PodStatus
+		wantStatus string
+	}{
+		{name: "succeeded", status: apiv1.PodStatus{Phase: apiv1.PodSucceeded}, wantStatus: models.CompileEnvValidationSuccess},
+		{name: "failed", status: apiv1.PodStatus{Phase: apiv1.PodFailed}, wantStatus: models.CompileEnvValidationFailed},
+		{
+			name: "image pull failed",
+			status: apiv1.PodStatus{Phase: apiv1.PodPending, ContainerStatuses: []apiv1.ContainerStatus{
+				{State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+			}},
+			wantStatus: models.CompileEnvValidationFailed,
+		},
+		{name: "timeout", status: apiv1.PodStatus{Phase: apiv1.PodPending}, wantStatus: models.CompileEnvValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			var created *apiv1.Pod
+			client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				created = action.(k8stesting.CreateAction).GetObject().(*apiv1.Pod).DeepCopy()
+				created.Name = "compile-env-validation-test"
+				return true, created, nil
+			})
+			client.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				pod := created.DeepCopy()
+				pod.Status = tt.status
+				return true, pod, nil
+			})
+			got, err := validateCompileEnv(client, "default", compileEnv, 5*time.Millisecond)
+			if err != nil {
+				t.Fatalf("validate compile env: %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Fatalf("validation status = %v, want %v, output: %v", got.Status, tt.wantStatus, got.Output)
+			}
+			if tt.name == "succeeded" && got.Output != "go version go1.17 linux/amd64" {
+				t.Fatalf("unexpected validation output: %q", got.Output)
+			}
+			container := created.Spec.Containers[0]
+			if container.Command[2] != "go version" || container.Resources.Limits.Cpu().String() != "1" || container.Resources.Requests.Memory().String() != "256Mi" {
+				t.Fatalf("unexpected validation container: %+v", container)
+			}
+		})
+	}
+
+	if _, err := validateCompileEnv(fake.NewSimpleClientset(), "default", &models.CompileEnv{Name: "jnlp"}, time.Second); err == nil {
+		t.Fatalf("compile env without version command should not be validated")
+	}
+}
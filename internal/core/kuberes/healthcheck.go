@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/kube"
+
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// progressDeadlineExceeded deployment condition reason, the rollout will never be finished
+const progressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// RolloutStatus rollout status of one app workload
+type RolloutStatus struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Failed  bool   `json:"failed"`
+	Message string `json:"message"`
+	// Timeout the health check timeout of the resource, 0 means the default timeout of kind
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// HealthChecker watch the rollout status and readiness of the app workloads
+type HealthChecker struct {
+	Cluster   string
+	Namespace string
+	client    kubernetes.Interface
+}
+
+// NewHealthChecker ..
+func NewHealthChecker(cluster, namespace string) (*HealthChecker, error) {
+	client, _, err := kube.GetClientset(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("get cluster: %v client occur error: %s", cluster, err.Error())
+	}
+	return &HealthChecker{
+		Cluster:   cluster,
+		Namespace: namespace,
+		client:    client,
+	}, nil
+}
+
+// Check return the current rollout status of items, it does not block
+func (hc *HealthChecker) Check(items []AppResourceItem) ([]*RolloutStatus, error) {
+	rsp := []*RolloutStatus{}
+	for _, item := range items {
+		var status *RolloutStatus
+		var err error
+		switch strings.ToLower(item.Kind) {
+		case AppKindDeployment:
+			status, err = hc.deploymentStatus(item.Name)
+		case AppKindStatefulSet:
+			status, err = hc.statefulSetStatus(item.Name)
+		case AppKindDaemonSet:
+			status, err = hc.daemonSetStatus(item.Name)
+		case AppKindJob:
+			status, err = hc.jobStatus(item.Name)
+		default:
+			log.Log.Info("app: %v kind: %v did not support health check, skip", item.Name, item.Kind)
+			status = &RolloutStatus{Ready: true, Message: "skipped"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		status.Kind = item.Kind
+		status.Name = item.Name
+		status.Timeout = item.Timeout
+		rsp = append(rsp, status)
+	}
+	return rsp, nil
+}
+
+func (hc *HealthChecker) deploymentStatus(name string) (*RolloutStatus, error) {
+	deployment, err := hc.client.AppsV1().Deployments(hc.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get deployment: %v occur error: %s", name, err.Error())
+	}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return &RolloutStatus{Message: "waiting for deployment spec update to be observed"}, nil
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == v1.DeploymentProgressing && condition.Reason == progressDeadlineExceeded {
+			return &RolloutStatus{Failed: true, Message: fmt.Sprintf("deployment %q exceeded its progress deadline", name)}, nil
+		}
+	}
+	replicas := int32(default_replicas)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	switch {
+	case status.UpdatedReplicas < replicas:
+		return &RolloutStatus{Message: fmt.Sprintf("%d out of %d new replicas have been updated", status.UpdatedReplicas, replicas)}, nil
+	case status.Replicas > status.UpdatedReplicas:
+		return &RolloutStatus{Message: fmt.Sprintf("%d old replicas are pending termination", status.Replicas-status.UpdatedReplicas)}, nil
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		message := fmt.Sprintf("%d of %d updated replicas are available", status.AvailableReplicas, status.UpdatedReplicas)
+		if reason := hc.podsFailedReason(deployment.Spec.Selector); reason != "" {
+			message = fmt.Sprintf("%s, %s", message, reason)
+		}
+		return &RolloutStatus{Message: message}, nil
+	}
+	return &RolloutStatus{Ready: true, Message: "successfully rolled out"}, nil
+}
+
+// podsFailedReason return the waiting reason of the pod containers which can not start, eg: ImagePullBackOff
+func (hc *HealthChecker) podsFailedReason(selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return ""
+	}
+	podList, err := hc.client.CoreV1().Pods(hc.Namespace).List(metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+	})
+	if err != nil {
+		log.Log.Warn("list pods by selector: %v occur error: %s", metav1.FormatLabelSelector(selector), err.Error())
+		return ""
+	}
+	for _, pod := range podList.Items {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerWaitingFailed(containerStatus) {
+				return fmt.Sprintf("pod %v container %v: %v", pod.Name, containerStatus.Name, containerStatus.State.Waiting.Reason)
+			}
+		}
+	}
+	return ""
+}
+
+func containerWaitingFailed(status apiv1.ContainerStatus) bool {
+	if status.State.Waiting == nil {
+		return false
+	}
+	switch status.State.Waiting.Reason {
+	case "ErrImagePull", "ImagePullBackOff", "CrashLoopBackOff", "InvalidImageName", "CreateContainerConfigError":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestDeployment(replicas int32, status v1.DeploymentStatus) *v1.Deployment {
+	return &v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Generation: 1},
+		Spec:       v1.DeploymentSpec{Replicas: &replicas},
+		Status:     status,
+	}
+}
+
+func TestHealthCheckerDeploymentStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployment *v1.Deployment
+		wantReady  bool
+		wantFailed bool
+	}{
+		{name: "generation not observed", deployment: newTestDeployment(1, v1.DeploymentStatus{ObservedGeneration: 0})},
+		{name: "updating replicas", deployment: newTestDeployment(2, v1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 1})},
+		{name: "old replicas terminating", deployment: newTestDeployment(1, v1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1})},
+		{name: "waiting available", deployment: newTestDeployment(1, v1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1})},
+		{name: "progress deadline exceeded", deployment: newTestDeployment(1, v1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Conditions:         []v1.DeploymentCondition{{Type: v1.DeploymentProgressing, Reason: progressDeadlineExceeded}},
+		}), wantFailed: true},
+		{name: "rolled out", deployment: newTestDeployment(1, v1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}), wantReady: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := &HealthChecker{Namespace: "default", client: fake.NewSimpleClientset(tt.deployment)}
+			got, err := hc.Check([]AppResourceItem{{Kind: "Deployment", Name: "demo"}})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got[0].Ready != tt.wantReady || got[0].Failed != tt.wantFailed {
+				t.Errorf("Check() = %+v, want ready %v failed %v", got[0], tt.wantReady, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func newTestReplicaSet(deployment *v1.Deployment, revision, image string) *v1.ReplicaSet {
+	return &v1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "demo-" + revision,
+			Namespace:       "default",
+			Annotations:     map[string]string{deploymentRevisionAnnotation: revision},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, v1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: v1.ReplicaSetSpec{
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.DefaultDeploymentUniqueLabelKey: revision}},
+				Spec:       apiv1.PodSpec{Containers: []apiv1.Container{{Name: "demo", Image: image}}},
+			},
+		},
+	}
+}
+
+func TestHealthCheckerRollback(t *testing.T) {
+	deployment := newTestDeployment(1, v1.DeploymentStatus{})
+	deployment.UID = "demo-uid"
+	deployment.Annotations = map[string]string{deploymentRevisionAnnotation: "3"}
+	client := fake.NewSimpleClientset(deployment,
+		newTestReplicaSet(deployment, "1", "demo:v1"),
+		newTestReplicaSet(deployment, "2", "demo:v2"),
+		newTestReplicaSet(deployment, "3", "demo:v3"),
+	)
+	hc := &HealthChecker{Namespace: "default", client: client}
+	if _, err := hc.Rollback([]AppResourceItem{{Kind: "Deployment", Name: "demo"}}); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	got, err := client.AppsV1().Deployments("default").Get("demo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := got.Spec.Template.Spec.Containers[0].Image; image != "demo:v2" {
+		t.Errorf("Rollback() image = %v, want demo:v2", image)
+	}
+	if _, ok := got.Spec.Template.Labels[v1.DefaultDeploymentUniqueLabelKey]; ok {
+		t.Errorf("Rollback() template should not keep %v label", v1.DefaultDeploymentUniqueLabelKey)
+	}
+}
+
+func TestHealthCheckerWorkloadStatus(t *testing.T) {
+	replicas := int32(2)
+	meta := metav1.ObjectMeta{Name: "demo", Namespace: "default", Generation: 1}
+	rolling := v1.StatefulSetUpdateStrategy{Type: v1.RollingUpdateStatefulSetStrategyType}
+	tests := []struct {
+		name       string
+		kind       string
+		object     runtime.Object
+		wantReady  bool
+		wantFailed bool
+	}{
+		{name: "statefulset not created", kind: "StatefulSet"},
+		{name: "statefulset replicas not ready", kind: "StatefulSet", object: &v1.StatefulSet{ObjectMeta: meta,
+			Spec:   v1.StatefulSetSpec{Replicas: &replicas, UpdateStrategy: rolling},
+			Status: v1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 1}}},
+		{name: "statefulset revision updating", kind: "StatefulSet", object: &v1.StatefulSet{ObjectMeta: meta,
+			Spec:   v1.StatefulSetSpec{Replicas: &replicas, UpdateStrategy: rolling},
+			Status: v1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, CurrentRevision: "r1", UpdateRevision: "r2"}}},
+		{name: "statefulset rolled out", kind: "StatefulSet", object: &v1.StatefulSet{ObjectMeta: meta,
+			Spec:   v1.StatefulSetSpec{Replicas: &replicas, UpdateStrategy: rolling},
+			Status: v1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, CurrentRevision: "r2", UpdateRevision: "r2"}}, wantReady: true},
+		{name: "daemonset updating", kind: "DaemonSet", object: &v1.DaemonSet{ObjectMeta: meta,
+			Spec:   v1.DaemonSetSpec{UpdateStrategy: v1.DaemonSetUpdateStrategy{Type: v1.RollingUpdateDaemonSetStrategyType}},
+			Status: v1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberAvailable: 3}}},
+		{name: "daemonset rolled out", kind: "DaemonSet", object: &v1.DaemonSet{ObjectMeta: meta,
+			Status: v1.DaemonSetStatus{ObservedGeneration: 1, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 3}}, wantReady: true},
+		{name: "job running", kind: "Job", object: &batchv1.Job{ObjectMeta: meta, Status: batchv1.JobStatus{Active: 1}}},
+		{name: "job completed", kind: "Job", object: &batchv1.Job{ObjectMeta: meta, Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: apiv1.ConditionTrue}}}}, wantReady: true},
+		{name: "job failed", kind: "Job", object: &batchv1.Job{ObjectMeta: meta, Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: apiv1.ConditionTrue, Reason: "BackoffLimitExceeded"}}}}, wantFailed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			if tt.object != nil {
+				objects = append(objects, tt.object)
+			}
+			hc := &HealthChecker{Namespace: "default", client: fake.NewSimpleClientset(objects...)}
+			got, err := hc.Check([]AppResourceItem{{Kind: tt.kind, Name: "demo", Timeout: time.Minute}})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got[0].Ready != tt.wantReady || got[0].Failed != tt.wantFailed || got[0].Timeout != time.Minute {
+				t.Errorf("Check() = %+v, want ready %v failed %v", got[0], tt.wantReady, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestParseHealthCheckTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"300", 5 * time.Minute},
+		{"10m", 10 * time.Minute},
+		{"invalid", 0},
+		{"-1", 0},
+	}
+	for _, tt := range tests {
+		if got := parseHealthCheckTimeout(map[string]string{HealthCheckTimeoutAnnotation: tt.value}); got != tt.want {
+			t.Errorf("parseHealthCheckTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
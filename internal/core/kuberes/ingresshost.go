@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+
+	"github.com/go-atomci/atomci/pkg/kube"
+
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// IngressHosts return the hosts of the ingress rules and tls in template, in the order of appearance
+func IngressHosts(template string) ([]string, error) {
+	resObjects, err := (&NativeTemplate{Template: template}).parser()
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{}
+	seen := map[string]bool{}
+	add := func(host string) {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	for _, obj := range resObjects {
+		item, ok := obj.Object.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("unexpected resource: %v", obj.Name)
+		}
+		if item.GetKind() != "Ingress" {
+			continue
+		}
+		rules, _, _ := unstructured.NestedSlice(item.Object, "spec", "rules")
+		for _, rule := range rules {
+			if rule, ok := rule.(map[string]interface{}); ok {
+				host, _, _ := unstructured.NestedString(rule, "host")
+				add(host)
+			}
+		}
+		tlsItems, _, _ := unstructured.NestedSlice(item.Object, "spec", "tls")
+		for _, tls := range tlsItems {
+			if tls, ok := tls.(map[string]interface{}); ok {
+				tlsHosts, _, _ := unstructured.NestedStringSlice(tls, "hosts")
+				for _, host := range tlsHosts {
+					add(host)
+				}
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// DeleteK8sNamespace delete the namespace with all the resources in it, the namespace not found is ignored
+func DeleteK8sNamespace(cluster, namespace string) error {
+	client, _, err := kube.GetClientset(cluster)
+	if err != nil {
+		return err
+	}
+	err = client.CoreV1().Namespaces().Delete(namespace, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIngressHosts(t *testing.T) {
+	template := "apiVersion: v1\nkind: Service\nmetadata:\n  name: demo\n---\n" +
+		"apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: demo\nspec:\n" +
+		"  tls:\n  - hosts:\n    - demo.example.com\n    - static.example.com\n    secretName: demo-tls\n" +
+		"  rules:\n  - host: demo.example.com\n    http: {}\n  - http: {}\n"
+	hosts, err := IngressHosts(template)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	want := []string{"demo.example.com", "static.example.com"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("IngressHosts() = %v, want %v", hosts, want)
+	}
+}
@@ -49,7 +49,7 @@ func NewKubeAppRes(client kubernetes.Interface, cluster, namespace, kind string)
 		client:    client,
 	}
 	if kind == AppKindStatefulSet {
-		res.kubeAppHandle = NewStatefulRes(client, namespace)
+		res.kubeAppHandle = newClusterStatefulRes(client, cluster, namespace)
 	} else {
 		//default is deployment
 		res.kubeAppHandle = NewDeploymentRes(client, namespace)
@@ -22,11 +22,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/utils/validate"
 
 	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
 	errors "k8s.io/apimachinery/pkg/api/errors"
@@ -66,6 +68,8 @@ type ContainerItem struct {
 type AppResourceItem struct {
 	Kind string `json:"kind,omitempty"`
 	Name string `json:"name,omitempty"`
+	// Timeout the health check timeout of annotation, 0 means the default timeout of kind
+	Timeout time.Duration `json:"timeout,omitempty"`
 }
 
 type DeployConfig struct {
@@ -123,6 +127,20 @@ func (t *NativeTemplate) Validate() error {
 			}
 			podSpecList = append(podSpecList, deploy.Spec.Template.Spec)
 			continue
+		case AppKindDaemonSet:
+			daemon := &v1.DaemonSet{}
+			if err := json.Unmarshal(obj.RawData, daemon); err != nil {
+				return err
+			}
+			podSpecList = append(podSpecList, daemon.Spec.Template.Spec)
+			continue
+		case AppKindJob:
+			job := &batchv1.Job{}
+			if err := json.Unmarshal(obj.RawData, job); err != nil {
+				return err
+			}
+			podSpecList = append(podSpecList, job.Spec.Template.Spec)
+			continue
 		case SecretKind, ConfigMapKind:
 			continue
 		case ServiceKind:
@@ -215,8 +233,7 @@ func (t *NativeTemplate) GetContainerImages() ([]*ContainerItem, error) {
 	return rsp, nil
 }
 
-// GetAppResourceNames ..
-// TODO: current support deployment/statefulset daemonset parse
+// GetAppResourceNames return the workloads which are waited by health check
 func (t *NativeTemplate) GetAppResourceNames() ([]AppResourceItem, error) {
 	resObjects, err := t.parser()
 	if err != nil {
@@ -232,15 +249,15 @@ func (t *NativeTemplate) GetAppResourceNames() ([]AppResourceItem, error) {
 			return nil, err
 		}
 		switch strings.ToLower(kind) {
-		case AppKindDeployment, AppKindStatefulSet, AppKindDaemonSet:
-			deploy := &v1.Deployment{}
-			err := json.Unmarshal(obj.RawData, &deploy)
+		case AppKindDeployment, AppKindStatefulSet, AppKindDaemonSet, AppKindJob:
+			annotations, err := metaAccessor.Annotations(obj.Object)
 			if err != nil {
 				return nil, err
 			}
 			appResourceItems = append(appResourceItems, AppResourceItem{
-				Name: deploy.Name,
-				Kind: deploy.Kind,
+				Name:    obj.Name,
+				Kind:    kind,
+				Timeout: parseHealthCheckTimeout(annotations),
 			})
 		default:
 			log.Log.Info("obj name: %v kind: %v was skipped parse", obj.Name, kind)
@@ -387,6 +404,9 @@ func (t *NativeTemplate) GenNativeAppTemplate(namespace, appname string) ([]*Nat
 }
 
 func (t *NativeTemplate) Deploy(projectID, envID int64, cluster, namespace, tname string, eparam *ExtensionParam) error {
+	if err := t.checkClusterCapability(cluster); err != nil {
+		return err
+	}
 	ar, err := NewAppRes(cluster, envID, projectID)
 	if err != nil {
 		return err
@@ -497,6 +517,9 @@ func (t *NativeTemplate) CreateNoAppResource(client kubernetes.Interface, cluste
 	// }
 	configs := configList{}
 	secrets := secretList{}
+	statefulSets := statefulSetList{}
+	daemonSets := daemonSetList{}
+	jobs := jobList{}
 	for _, obj := range objs {
 		kind, err := metaAccessor.Kind(obj.Object)
 		if err != nil {
@@ -544,6 +567,33 @@ func (t *NativeTemplate) CreateNoAppResource(client kubernetes.Interface, cluste
 			sec.Namespace = namespace
 			secrets = append(secrets, sec)
 			resMap[SecretKind] = secrets
+		case AppKindStatefulSet:
+			set := &v1.StatefulSet{}
+			if err := json.Unmarshal(obj.RawData, set); err != nil {
+				log.Log.Error("unmarshal virgin data to statefulset type failed: %v", err)
+				continue
+			}
+			set.Namespace = namespace
+			statefulSets = append(statefulSets, set)
+			resMap[AppKindStatefulSet] = statefulSets
+		case AppKindDaemonSet:
+			daemon := &v1.DaemonSet{}
+			if err := json.Unmarshal(obj.RawData, daemon); err != nil {
+				log.Log.Error("unmarshal virgin data to daemonset type failed: %v", err)
+				continue
+			}
+			daemon.Namespace = namespace
+			daemonSets = append(daemonSets, daemon)
+			resMap[AppKindDaemonSet] = daemonSets
+		case AppKindJob:
+			job := &batchv1.Job{}
+			if err := json.Unmarshal(obj.RawData, job); err != nil {
+				log.Log.Error("unmarshal virgin data to job type failed: %v", err)
+				continue
+			}
+			job.Namespace = namespace
+			jobs = append(jobs, job)
+			resMap[AppKindJob] = jobs
 		default:
 			log.Log.Warn("dont support this resource kind", obj.Object.GetObjectKind())
 		}
@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/kube"
+
+	"github.com/ghodss/yaml"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the labels which track the owner of the resources applied by atomci
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "atomci"
+	OwnerAppLabel  = "atomci.io/app"
+	OwnerEnvLabel  = "atomci.io/env"
+)
+
+// LabelOwnedResources add the owner labels of the project app and env to the resources of arrange
+func LabelOwnedResources(template string, projectAppID, envID int64) (string, error) {
+	resObjects, err := (&NativeTemplate{Template: template}).parser()
+	if err != nil {
+		return "", err
+	}
+	docs := []string{}
+	for _, obj := range resObjects {
+		item, ok := obj.Object.(*unstructured.Unstructured)
+		if !ok {
+			return "", fmt.Errorf("unexpected resource: %v", obj.Name)
+		}
+		labels := item.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[ManagedByLabel] = ManagedByValue
+		labels[OwnerAppLabel] = strconv.FormatInt(projectAppID, 10)
+		labels[OwnerEnvLabel] = strconv.FormatInt(envID, 10)
+		item.SetLabels(labels)
+		data, err := yaml.Marshal(item.Object)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, YamlSeparator), nil
+}
+
+// prunableKind the kind of resources which are deleted when orphaned
+type prunableKind struct {
+	kind   string
+	list   func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error)
+	delete func(client kubernetes.Interface, namespace, name string) error
+}
+
+var prunableKinds = []prunableKind{
+	{
+		kind: "Deployment",
+		list: func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+			items, err := client.AppsV1().Deployments(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			metas := []metav1.ObjectMeta{}
+			for _, item := range items.Items {
+				metas = append(metas, item.ObjectMeta)
+			}
+			return metas, nil
+		},
+		delete: func(client kubernetes.Interface, namespace, name string) error {
+			propagation := metav1.DeletePropagationBackground
+			return client.AppsV1().Deployments(namespace).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		},
+	},
+	{
+		kind: "StatefulSet",
+		list: func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+			items, err := client.AppsV1().StatefulSets(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			metas := []metav1.ObjectMeta{}
+			for _, item := range items.Items {
+				metas = append(metas, item.ObjectMeta)
+			}
+			return metas, nil
+		},
+		delete: func(client kubernetes.Interface, namespace, name string) error {
+			propagation := metav1.DeletePropagationBackground
+			return client.AppsV1().StatefulSets(namespace).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		},
+	},
+	{
+		kind: "DaemonSet",
+		list: func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+			items, err := client.AppsV1().DaemonSets(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			metas := []metav1.ObjectMeta{}
+			for _, item := range items.Items {
+				metas = append(metas, item.ObjectMeta)
+			}
+			return metas, nil
+		},
+		delete: func(client kubernetes.Interface, namespace, name string) error {
+			propagation := metav1.DeletePropagationBackground
+			return client.AppsV1().DaemonSets(namespace).Delete(name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		},
+	},
+	{
+		kind: "Service",
+		list: func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+			items, err := client.CoreV1().Services(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			metas := []metav1.ObjectMeta{}
+			for _, item := range items.Items {
+				metas = append(metas, item.ObjectMeta)
+			}
+			return metas, nil
+		},
+		delete: func(client kubernetes.Interface, namespace, name string) error {
+			return client.CoreV1().Services(namespace).Delete(name, &metav1.DeleteOptions{})
+		},
+	},
+	{
+		kind: "ConfigMap",
+		list: func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+			items, err := client.CoreV1().ConfigMaps(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			metas := []metav1.ObjectMeta{}
+			for _, item := range items.Items {
+				metas = append(metas, item.ObjectMeta)
+			}
+			return metas, nil
+		},
+		delete: func(client kubernetes.Interface, namespace, name string) error {
+			return client.CoreV1().ConfigMaps(namespace).Delete(name, &metav1.DeleteOptions{})
+		},
+	},
+	{
+		kind: "Secret",
+		list: func(client kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+			items, err := client.CoreV1().Secrets(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			metas := []metav1.ObjectMeta{}
+			for _, item := range items.Items {
+				metas = append(metas, item.ObjectMeta)
+			}
+			return metas, nil
+		},
+		delete: func(client kubernetes.Interface, namespace, name string) error {
+			return client.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
+		},
+	},
+}
+
+// ownerSelector select the resources of the apps in env applied by atomci
+func ownerSelector(envID int64, appIDs []string) string {
+	sort.Strings(appIDs)
+	return fmt.Sprintf("%s=%s,%s=%d,%s in (%s)", ManagedByLabel, ManagedByValue, OwnerEnvLabel, envID, OwnerAppLabel, strings.Join(appIDs, ","))
+}
+
+// pruneResources delete the resources selected which are not desired, the desired key is kind/name
+func pruneResources(client kubernetes.Interface, namespace, selector string, desired map[string]bool) ([]string, error) {
+	pruned := []string{}
+	for _, prunable := range prunableKinds {
+		metas, err := prunable.list(client, namespace, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return pruned, fmt.Errorf("list %s occur error: %s", prunable.kind, err.Error())
+		}
+		for _, meta := range metas {
+			key := prunable.kind + "/" + meta.Name
+			if desired[strings.ToLower(key)] {
+				continue
+			}
+			if err := prunable.delete(client, namespace, meta.Name); err != nil && !errors.IsNotFound(err) {
+				return pruned, fmt.Errorf("delete %s occur error: %s", key, err.Error())
+			}
+			log.Log.Info("orphaned resource: %v in namespace: %v pruned", key, namespace)
+			pruned = append(pruned, key)
+		}
+	}
+	return pruned, nil
+}
+
+// PruneOrphanedResources delete the resources of the apps in template which are no longer in their arranges,
+// the resources of the other apps in namespace are untouched
+func PruneOrphanedResources(cluster, namespace string, envID int64, template string) ([]string, error) {
+	resObjects, err := (&NativeTemplate{Template: template}).parser()
+	if err != nil {
+		return nil, err
+	}
+	desired := map[string]bool{}
+	appIDs := []string{}
+	seen := map[string]bool{}
+	for _, obj := range resObjects {
+		kind, err := metaAccessor.Kind(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		desired[strings.ToLower(kind+"/"+obj.Name)] = true
+		labels, _ := metaAccessor.Labels(obj.Object)
+		if appID := labels[OwnerAppLabel]; appID != "" && !seen[appID] {
+			seen[appID] = true
+			appIDs = append(appIDs, appID)
+		}
+	}
+	if len(appIDs) == 0 {
+		return nil, nil
+	}
+	client, _, err := kube.GetClientset(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return pruneResources(client, namespace, ownerSelector(envID, appIDs), desired)
+}
+
+// PruneAppResources delete all the resources of the app removed from env
+func PruneAppResources(cluster, namespace string, envID, projectAppID int64) ([]string, error) {
+	client, _, err := kube.GetClientset(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return pruneResources(client, namespace, ownerSelector(envID, []string{strconv.FormatInt(projectAppID, 10)}), nil)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLabelOwnedResources(t *testing.T) {
+	template := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: demo\n  labels:\n    app: demo\n---\n" +
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: demo"
+	labeled, err := LabelOwnedResources(template, 3, 5)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	resObjects, err := (&NativeTemplate{Template: labeled}).parser()
+	if err != nil {
+		t.Fatalf("parse labeled template occur error: %v", err)
+	}
+	if len(resObjects) != 2 {
+		t.Fatalf("expect 2 resources, got %d", len(resObjects))
+	}
+	for _, obj := range resObjects {
+		labels, _ := metaAccessor.Labels(obj.Object)
+		if labels[ManagedByLabel] != ManagedByValue || labels[OwnerAppLabel] != "3" || labels[OwnerEnvLabel] != "5" {
+			t.Errorf("expect owner labels on %s, got %v", obj.Name, labels)
+		}
+	}
+	if !strings.Contains(labeled, "app: demo") {
+		t.Errorf("expect origin labels kept, got %s", labeled)
+	}
+}
+
+func TestPruneResources(t *testing.T) {
+	owned := func(app string) map[string]string {
+		return map[string]string{ManagedByLabel: ManagedByValue, OwnerAppLabel: app, OwnerEnvLabel: "5"}
+	}
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Labels: owned("3")}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default", Labels: owned("3")}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", Labels: owned("4")}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: "default"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Labels: owned("3")}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "legacy-config", Namespace: "default", Labels: owned("3")}},
+	)
+	desired := map[string]bool{"deployment/demo": true, "service/demo": true}
+	pruned, err := pruneResources(client, "default", ownerSelector(5, []string{"3"}), desired)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	sort.Strings(pruned)
+	if expect := []string{"ConfigMap/legacy-config", "Deployment/legacy"}; !reflect.DeepEqual(pruned, expect) {
+		t.Errorf("expect pruned %v, got %v", expect, pruned)
+	}
+	deployments, _ := client.AppsV1().Deployments("default").List(metav1.ListOptions{})
+	names := []string{}
+	for _, item := range deployments.Items {
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+	if expect := []string{"demo", "manual", "other"}; !reflect.DeepEqual(names, expect) {
+		t.Errorf("expect deployments %v left, got %v", expect, names)
+	}
+
+	pruned, err = pruneResources(client, "default", ownerSelector(5, []string{"3"}), nil)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	sort.Strings(pruned)
+	if expect := []string{"Deployment/demo", "Service/demo"}; !reflect.DeepEqual(pruned, expect) {
+		t.Errorf("expect pruned %v, got %v", expect, pruned)
+	}
+}
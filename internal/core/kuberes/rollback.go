@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// Rollback roll back the workloads to the previous revision, like kubectl rollout undo,
+// return the names of workloads rolled back.
+func (hc *HealthChecker) Rollback(items []AppResourceItem) ([]string, error) {
+	rolledBack := []string{}
+	for _, item := range items {
+		switch strings.ToLower(item.Kind) {
+		case AppKindDeployment:
+			if err := hc.rollbackDeployment(item.Name); err != nil {
+				return rolledBack, err
+			}
+			rolledBack = append(rolledBack, fmt.Sprintf("%s/%s", item.Kind, item.Name))
+		default:
+			log.Log.Info("app: %v kind: %v did not support rollback, skip", item.Name, item.Kind)
+		}
+	}
+	return rolledBack, nil
+}
+
+func (hc *HealthChecker) rollbackDeployment(name string) error {
+	deployment, err := hc.client.AppsV1().Deployments(hc.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get deployment: %v occur error: %s", name, err.Error())
+	}
+	// the replicasets are filtered by owner reference, the selector only narrow the list
+	listOptions := metav1.ListOptions{}
+	if deployment.Spec.Selector != nil {
+		listOptions.LabelSelector = metav1.FormatLabelSelector(deployment.Spec.Selector)
+	}
+	rsList, err := hc.client.AppsV1().ReplicaSets(hc.Namespace).List(listOptions)
+	if err != nil {
+		return fmt.Errorf("list deployment: %v replicasets occur error: %s", name, err.Error())
+	}
+	previous := previousReplicaSet(deployment, rsList.Items)
+	if previous == nil {
+		return fmt.Errorf("deployment: %v has no previous revision to roll back", name)
+	}
+	template := previous.Spec.Template.DeepCopy()
+	delete(template.Labels, v1.DefaultDeploymentUniqueLabelKey)
+	deployment.Spec.Template = *template
+	if _, err := hc.client.AppsV1().Deployments(hc.Namespace).Update(deployment); err != nil {
+		return fmt.Errorf("roll back deployment: %v occur error: %s", name, err.Error())
+	}
+	log.Log.Info("deployment: %v rolled back to revision: %v", name, previous.Annotations[deploymentRevisionAnnotation])
+	return nil
+}
+
+// previousReplicaSet return the replicaset of deployment with the max revision which less than the current
+func previousReplicaSet(deployment *v1.Deployment, rsList []v1.ReplicaSet) *v1.ReplicaSet {
+	current, _ := strconv.ParseInt(deployment.Annotations[deploymentRevisionAnnotation], 10, 64)
+	var previous *v1.ReplicaSet
+	var previousRevision int64
+	for i := range rsList {
+		rs := &rsList[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		revision, err := strconv.ParseInt(rs.Annotations[deploymentRevisionAnnotation], 10, 64)
+		if err != nil || revision >= current {
+			continue
+		}
+		if revision > previousRevision {
+			previous = rs
+			previousRevision = revision
+		}
+	}
+	return previous
+}
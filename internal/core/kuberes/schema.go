@@ -0,0 +1,252 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/kube"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+)
+
+// schemaCacheTTL the openapi schema of cluster is large, cache it to avoid fetching on every save
+const schemaCacheTTL = 10 * time.Minute
+
+const gvkExtensionKey = "x-kubernetes-group-version-kind"
+
+// DeprecatedAPI the apiVersion of kind which deprecated, and removed since the kubernetes version
+type DeprecatedAPI struct {
+	APIVersion  string
+	Kind        string
+	RemovedIn   string
+	Replacement string
+}
+
+// deprecatedAPIs refer to https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var deprecatedAPIs = []DeprecatedAPI{
+	{"extensions/v1beta1", "Deployment", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "DaemonSet", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", "1.16", "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", "1.16", "policy/v1beta1"},
+	{"extensions/v1beta1", "Ingress", "1.22", "networking.k8s.io/v1"},
+	{"apps/v1beta1", "Deployment", "1.16", "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", "1.16", "apps/v1"},
+	{"apps/v1beta2", "Deployment", "1.16", "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", "1.16", "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", "1.16", "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", "1.16", "apps/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", "1.22", "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "1.22", "apiextensions.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", "1.25", "batch/v1"},
+	{"policy/v1beta1", "PodDisruptionBudget", "1.25", "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", "1.25", ""},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "1.25", "autoscaling/v2"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.26", "autoscaling/v2"},
+}
+
+// SchemaValidateResult the result of validating the template for cluster,
+// Errors the resources which the cluster can not accept, Warnings the resources use deprecated apiVersion
+type SchemaValidateResult struct {
+	ServerVersion string   `json:"server_version,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+type clusterSchema struct {
+	version   string
+	major     int
+	minor     int
+	gvkModels map[schema.GroupVersionKind]proto.Schema
+	fetchAt   time.Time
+}
+
+var clusterSchemas sync.Map
+
+// ValidateForCluster validate the resources of template against the openapi schema of the cluster,
+// the schema validation is skipped with warning if the cluster is unreachable.
+func (t *NativeTemplate) ValidateForCluster(cluster string) (*SchemaValidateResult, error) {
+	resObjects, err := t.parser()
+	if err != nil {
+		return nil, err
+	}
+	rsp := &SchemaValidateResult{}
+	clusterSchema, err := getClusterSchema(cluster)
+	if err != nil {
+		log.Log.Warn("get cluster: %v openapi schema occur error: %s", cluster, err.Error())
+		rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("集群 %v 无法获取 OpenAPI schema, 跳过 schema 校验: %s", cluster, err.Error()))
+		// fallback to the capability detected when the cluster bound, the unavailable kinds block the deploy
+		if capability, err := getClusterCapability(cluster); err == nil {
+			rsp.ServerVersion = capability.Version
+			for _, missing := range unavailableResources(capability, resObjects) {
+				rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("%s is not available in cluster %s, the deploy will be blocked", missing, capability.Version))
+			}
+		}
+	} else {
+		rsp.ServerVersion = clusterSchema.version
+	}
+
+	for _, obj := range resObjects {
+		gvk := obj.Object.GetObjectKind().GroupVersionKind()
+		resName := fmt.Sprintf("%s/%s", gvk.Kind, obj.Name)
+		if deprecated := lookupDeprecatedAPI(gvk); deprecated != nil {
+			removed := clusterSchema != nil && clusterSchema.removed(deprecated.RemovedIn)
+			msg := fmt.Sprintf("%s: apiVersion %s is deprecated and removed in kubernetes v%s", resName, gvk.GroupVersion().String(), deprecated.RemovedIn)
+			if deprecated.Replacement != "" {
+				msg = fmt.Sprintf("%s, use %s instead", msg, deprecated.Replacement)
+			}
+			if removed {
+				rsp.Errors = append(rsp.Errors, msg)
+				continue
+			}
+			rsp.Warnings = append(rsp.Warnings, msg)
+		}
+		if clusterSchema == nil {
+			continue
+		}
+		model, ok := clusterSchema.gvkModels[gvk]
+		if !ok {
+			rsp.Errors = append(rsp.Errors, fmt.Sprintf("%s: apiVersion %s kind %s is not supported by cluster %s", resName, gvk.GroupVersion().String(), gvk.Kind, clusterSchema.version))
+			continue
+		}
+		var data interface{}
+		if err := json.Unmarshal(obj.RawData, &data); err != nil {
+			return nil, err
+		}
+		for _, err := range validation.ValidateModel(data, model, gvk.Kind) {
+			rsp.Errors = append(rsp.Errors, fmt.Sprintf("%s: %s", resName, err.Error()))
+		}
+	}
+	return rsp, nil
+}
+
+func lookupDeprecatedAPI(gvk schema.GroupVersionKind) *DeprecatedAPI {
+	apiVersion := gvk.GroupVersion().String()
+	for i, item := range deprecatedAPIs {
+		if item.APIVersion == apiVersion && (item.Kind == "" || item.Kind == gvk.Kind) {
+			return &deprecatedAPIs[i]
+		}
+	}
+	return nil
+}
+
+// removed return true if the cluster version is greater than or equal to version, eg: 1.16
+func (s *clusterSchema) removed(version string) bool {
+	major, minor := parseVersion(version)
+	if s.major != major {
+		return s.major > major
+	}
+	return s.minor >= minor
+}
+
+func parseVersion(version string) (int, int) {
+	items := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	major, _ := strconv.Atoi(strings.TrimSuffix(items[0], "+"))
+	minor := 0
+	if len(items) > 1 {
+		minor, _ = strconv.Atoi(strings.TrimSuffix(items[1], "+"))
+	}
+	return major, minor
+}
+
+func getClusterSchema(cluster string) (*clusterSchema, error) {
+	if cached, ok := clusterSchemas.Load(cluster); ok {
+		item := cached.(*clusterSchema)
+		if time.Since(item.fetchAt) < schemaCacheTTL {
+			return item, nil
+		}
+	}
+	client, _, err := kube.GetClientset(cluster)
+	if err != nil {
+		return nil, err
+	}
+	serverVersion, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := client.Discovery().OpenAPISchema()
+	if err != nil {
+		return nil, err
+	}
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, err
+	}
+	item := &clusterSchema{
+		version:   serverVersion.GitVersion,
+		gvkModels: gvkModels(models),
+		fetchAt:   time.Now(),
+	}
+	item.major, item.minor = parseVersion(serverVersion.Major + "." + serverVersion.Minor)
+	clusterSchemas.Store(cluster, item)
+	return item, nil
+}
+
+// gvkModels index the models by the group version kind extension of the model
+func gvkModels(models proto.Models) map[schema.GroupVersionKind]proto.Schema {
+	rsp := map[schema.GroupVersionKind]proto.Schema{}
+	for _, name := range models.ListModels() {
+		model := models.LookupModel(name)
+		if model == nil {
+			continue
+		}
+		for _, gvk := range parseGroupVersionKind(model.GetExtensions()[gvkExtensionKey]) {
+			rsp[gvk] = model
+		}
+	}
+	return rsp
+}
+
+func parseGroupVersionKind(extension interface{}) []schema.GroupVersionKind {
+	items, ok := extension.([]interface{})
+	if !ok {
+		return nil
+	}
+	gvkList := []schema.GroupVersionKind{}
+	for _, item := range items {
+		values := map[string]string{}
+		switch gvk := item.(type) {
+		case map[interface{}]interface{}:
+			for key, value := range gvk {
+				values[fmt.Sprint(key)] = fmt.Sprint(value)
+			}
+		case map[string]interface{}:
+			for key, value := range gvk {
+				values[key] = fmt.Sprint(value)
+			}
+		default:
+			continue
+		}
+		gvkList = append(gvkList, schema.GroupVersionKind{
+			Group:   values["group"],
+			Version: values["version"],
+			Kind:    values["kind"],
+		})
+	}
+	return gvkList
+}
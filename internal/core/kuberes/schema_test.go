@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/util/proto"
+)
+
+func TestValidateForCluster(t *testing.T) {
+	clusterSchemas.Store("test", &clusterSchema{
+		version: "v1.16.2",
+		major:   1,
+		minor:   16,
+		gvkModels: map[schema.GroupVersionKind]proto.Schema{
+			{Group: "apps", Version: "v1", Kind: "Deployment"}:                &proto.Arbitrary{},
+			{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}: &proto.Arbitrary{},
+		},
+		fetchAt: time.Now(),
+	})
+	tests := []struct {
+		template     string
+		wantErrors   int
+		wantWarnings int
+	}{
+		{"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: demo", 0, 0},
+		{"apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: demo", 1, 0},
+		{"apiVersion: networking.k8s.io/v1beta1\nkind: Ingress\nmetadata:\n  name: demo", 0, 1},
+		{"apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: demo", 1, 0},
+	}
+	for _, tt := range tests {
+		native := &NativeTemplate{Template: tt.template}
+		rsp, err := native.ValidateForCluster("test")
+		if err != nil {
+			t.Fatalf("ValidateForCluster() error: %v", err)
+		}
+		if len(rsp.Errors) != tt.wantErrors || len(rsp.Warnings) != tt.wantWarnings {
+			t.Errorf("ValidateForCluster(%q) errors: %v, warnings: %v, want %v errors %v warnings",
+				strings.Split(tt.template, "\n")[1], rsp.Errors, rsp.Warnings, tt.wantErrors, tt.wantWarnings)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		major   int
+		minor   int
+	}{
+		{"1.16", 1, 16},
+		{"v1.22.3", 1, 22},
+		{"1.18+", 1, 18},
+	}
+	for _, tt := range tests {
+		if major, minor := parseVersion(tt.version); major != tt.major || minor != tt.minor {
+			t.Errorf("parseVersion(%q) = %v, %v, want %v, %v", tt.version, major, minor, tt.major, tt.minor)
+		}
+	}
+}
@@ -34,6 +34,8 @@ import (
 type StatefulRes struct {
 	Namespace string
 	client    kubernetes.Interface
+	// legacy the cluster does not serve apps/v1 statefulset, eg: kubernetes before v1.9
+	legacy bool
 }
 
 const emptyVersion = ""
@@ -45,6 +47,15 @@ func NewStatefulRes(client kubernetes.Interface, namespace string) KubeAppInterf
 	}
 }
 
+// newClusterStatefulRes choose the apiVersion of statefulset by the capability of cluster
+func newClusterStatefulRes(client kubernetes.Interface, cluster, namespace string) KubeAppInterface {
+	return &StatefulRes{
+		Namespace: namespace,
+		client:    client,
+		legacy:    !clusterSupports(cluster, "apps/v1", "StatefulSet"),
+	}
+}
+
 func (kr *StatefulRes) Create(obj interface{}) error {
 	state, ok := obj.(*v1.StatefulSet)
 	if !ok {
@@ -107,7 +118,12 @@ func (kr *StatefulRes) DeletePods(options *metav1.ListOptions, appname string) e
 
 // AppIsExisted ..
 func (kr *StatefulRes) AppIsExisted(appname string) (bool, error) {
-	_, err := kr.client.AppsV1beta1().StatefulSets(kr.Namespace).Get(GenerateDeployName(appname), metav1.GetOptions{})
+	var err error
+	if kr.legacy {
+		_, err = kr.client.AppsV1beta1().StatefulSets(kr.Namespace).Get(GenerateDeployName(appname), metav1.GetOptions{})
+	} else {
+		_, err = kr.client.AppsV1().StatefulSets(kr.Namespace).Get(GenerateDeployName(appname), metav1.GetOptions{})
+	}
 	if err != nil {
 		if !errors.IsNotFound(err) {
 			return false, err
@@ -119,12 +135,21 @@ func (kr *StatefulRes) AppIsExisted(appname string) (bool, error) {
 }
 
 func (kr *StatefulRes) Scale(appname string, replicas int) error {
-	ds, err := kr.client.AppsV1beta1().StatefulSets(kr.Namespace).Get(GenerateDeployName(appname), metav1.GetOptions{})
+	if kr.legacy {
+		ds, err := kr.client.AppsV1beta1().StatefulSets(kr.Namespace).Get(GenerateDeployName(appname), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		*ds.Spec.Replicas = int32(replicas)
+		_, err = kr.client.AppsV1beta1().StatefulSets(kr.Namespace).Update(ds)
+		return err
+	}
+	ds, err := kr.client.AppsV1().StatefulSets(kr.Namespace).Get(GenerateDeployName(appname), metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 	*ds.Spec.Replicas = int32(replicas)
-	if _, err := kr.client.AppsV1beta1().StatefulSets(kr.Namespace).Update(ds); err != nil {
+	if _, err := kr.client.AppsV1().StatefulSets(kr.Namespace).Update(ds); err != nil {
 		return err
 	}
 
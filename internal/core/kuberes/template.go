@@ -60,7 +60,7 @@ func NewTemplate() Template {
 func (tr *TemplateRes) CreateTemplate(template models.CaasTemplate) (*models.CaasTemplate, error) {
 	texist, err := tr.modelHandle.GetTemplate(template.Namespace, template.Name)
 	if texist != nil {
-		return nil, errors.NewConflict().SetCode("TemplateAlreadyExists").SetMessage("template already exists")
+		return nil, errors.New(errors.CodeTemplateAlreadyExists, nil)
 	} else {
 		if err != nil {
 			if err != orm.ErrNoRows {
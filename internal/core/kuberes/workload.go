@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kuberes
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AppKindJob the job is regarded as ready when it completed
+const AppKindJob = "job"
+
+// HealthCheckTimeoutAnnotation the health check timeout of the resource, eg: 10m or 600 (seconds)
+const HealthCheckTimeoutAnnotation = "atomci.io/health-check-timeout"
+
+// parseHealthCheckTimeout return the timeout of annotations, 0 means the default timeout of kind
+func parseHealthCheckTimeout(annotations map[string]string) time.Duration {
+	value := annotations[HealthCheckTimeoutAnnotation]
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Log.Warn("invalid health check timeout: %v, use the default timeout", value)
+		return 0
+	}
+	return timeout
+}
+
+func (hc *HealthChecker) statefulSetStatus(name string) (*RolloutStatus, error) {
+	set, err := hc.client.AppsV1().StatefulSets(hc.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &RolloutStatus{Message: "waiting for statefulset to be created"}, nil
+		}
+		return nil, fmt.Errorf("get statefulset: %v occur error: %s", name, err.Error())
+	}
+	if set.Generation > set.Status.ObservedGeneration {
+		return &RolloutStatus{Message: "waiting for statefulset spec update to be observed"}, nil
+	}
+	replicas := int32(default_replicas)
+	if set.Spec.Replicas != nil {
+		replicas = *set.Spec.Replicas
+	}
+	status := set.Status
+	if status.ReadyReplicas < replicas {
+		message := fmt.Sprintf("%d of %d replicas are ready", status.ReadyReplicas, replicas)
+		if reason := hc.podsFailedReason(set.Spec.Selector); reason != "" {
+			message = fmt.Sprintf("%s, %s", message, reason)
+		}
+		return &RolloutStatus{Message: message}, nil
+	}
+	// the pods are replaced manually with OnDelete strategy, the rollout is not waited
+	if set.Spec.UpdateStrategy.Type != v1.RollingUpdateStatefulSetStrategyType {
+		return &RolloutStatus{Ready: true, Message: "replicas are ready"}, nil
+	}
+	if rolling := set.Spec.UpdateStrategy.RollingUpdate; rolling != nil && rolling.Partition != nil && *rolling.Partition > 0 {
+		if status.UpdatedReplicas < replicas-*rolling.Partition {
+			return &RolloutStatus{Message: fmt.Sprintf("%d of %d new pods have been updated in partition", status.UpdatedReplicas, replicas-*rolling.Partition)}, nil
+		}
+		return &RolloutStatus{Ready: true, Message: "partitioned roll out complete"}, nil
+	}
+	if status.UpdateRevision != status.CurrentRevision {
+		return &RolloutStatus{Message: fmt.Sprintf("%d of %d pods have been updated to revision %s", status.UpdatedReplicas, replicas, status.UpdateRevision)}, nil
+	}
+	return &RolloutStatus{Ready: true, Message: "successfully rolled out"}, nil
+}
+
+func (hc *HealthChecker) daemonSetStatus(name string) (*RolloutStatus, error) {
+	daemon, err := hc.client.AppsV1().DaemonSets(hc.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &RolloutStatus{Message: "waiting for daemonset to be created"}, nil
+		}
+		return nil, fmt.Errorf("get daemonset: %v occur error: %s", name, err.Error())
+	}
+	if daemon.Generation > daemon.Status.ObservedGeneration {
+		return &RolloutStatus{Message: "waiting for daemonset spec update to be observed"}, nil
+	}
+	status := daemon.Status
+	if daemon.Spec.UpdateStrategy.Type == v1.RollingUpdateDaemonSetStrategyType && status.UpdatedNumberScheduled < status.DesiredNumberScheduled {
+		return &RolloutStatus{Message: fmt.Sprintf("%d out of %d new pods have been updated", status.UpdatedNumberScheduled, status.DesiredNumberScheduled)}, nil
+	}
+	if status.NumberAvailable < status.DesiredNumberScheduled {
+		message := fmt.Sprintf("%d of %d updated pods are available", status.NumberAvailable, status.DesiredNumberScheduled)
+		if reason := hc.podsFailedReason(daemon.Spec.Selector); reason != "" {
+			message = fmt.Sprintf("%s, %s", message, reason)
+		}
+		return &RolloutStatus{Message: message}, nil
+	}
+	return &RolloutStatus{Ready: true, Message: "successfully rolled out"}, nil
+}
+
+func (hc *HealthChecker) jobStatus(name string) (*RolloutStatus, error) {
+	job, err := hc.client.BatchV1().Jobs(hc.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &RolloutStatus{Message: "waiting for job to be created"}, nil
+		}
+		return nil, fmt.Errorf("get job: %v occur error: %s", name, err.Error())
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != apiv1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return &RolloutStatus{Ready: true, Message: "job completed"}, nil
+		case batchv1.JobFailed:
+			return &RolloutStatus{Failed: true, Message: fmt.Sprintf("job %q failed: %s %s", name, condition.Reason, condition.Message)}, nil
+		}
+	}
+	completions := int32(1)
+	if job.Spec.Completions != nil {
+		completions = *job.Spec.Completions
+	}
+	message := fmt.Sprintf("%d of %d completions succeeded, %d active, %d failed", job.Status.Succeeded, completions, job.Status.Active, job.Status.Failed)
+	if reason := hc.podsFailedReason(job.Spec.Selector); reason != "" {
+		message = fmt.Sprintf("%s, %s", message, reason)
+	}
+	return &RolloutStatus{Message: message}, nil
+}
+
+type statefulSetList []*v1.StatefulSet
+
+type daemonSetList []*v1.DaemonSet
+
+type jobList []*batchv1.Job
+
+func (sets statefulSetList) create(client kubernetes.Interface) error {
+	for _, set := range sets {
+		old, err := client.AppsV1().StatefulSets(set.Namespace).Get(set.Name, metav1.GetOptions{})
+		if err == nil {
+			set.ResourceVersion = old.ResourceVersion
+			if _, err := client.AppsV1().StatefulSets(set.Namespace).Update(set); err != nil {
+				return fmt.Errorf("update statefulset: %v error: %v", set.Name, err)
+			}
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if _, err := client.AppsV1().StatefulSets(set.Namespace).Create(set); err != nil {
+			return fmt.Errorf("create statefulset: %v error: %v", set.Name, err)
+		}
+	}
+	return nil
+}
+
+func (daemons daemonSetList) create(client kubernetes.Interface) error {
+	for _, daemon := range daemons {
+		old, err := client.AppsV1().DaemonSets(daemon.Namespace).Get(daemon.Name, metav1.GetOptions{})
+		if err == nil {
+			daemon.ResourceVersion = old.ResourceVersion
+			if _, err := client.AppsV1().DaemonSets(daemon.Namespace).Update(daemon); err != nil {
+				return fmt.Errorf("update daemonset: %v error: %v", daemon.Name, err)
+			}
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if _, err := client.AppsV1().DaemonSets(daemon.Namespace).Create(daemon); err != nil {
+			return fmt.Errorf("create daemonset: %v error: %v", daemon.Name, err)
+		}
+	}
+	return nil
+}
+
+// create the spec of job is immutable, the finished job is deleted and created again to run the new version
+func (jobs jobList) create(client kubernetes.Interface) error {
+	propagation := metav1.DeletePropagationBackground
+	for _, job := range jobs {
+		err := client.BatchV1().Jobs(job.Namespace).Delete(job.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete job: %v error: %v", job.Name, err)
+		}
+		if _, err := client.BatchV1().Jobs(job.Namespace).Create(job); err != nil {
+			return fmt.Errorf("create job: %v error: %v", job.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package organization
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/project"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils/validate"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// OrganizationReq ..
+type OrganizationReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// MaxConcurrentBuilds max running build jobs of all the projects of organization, 0 means unlimited
+	MaxConcurrentBuilds int `json:"max_concurrent_builds"`
+	// MaxEnvs max envs of all the projects of organization, 0 means unlimited
+	MaxEnvs int `json:"max_envs"`
+}
+
+// Verify ..
+func (r *OrganizationReq) Verify() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("组织名称不能为空")
+	}
+	if validate.IsIllegalLength(r.Name, validate.NameMinLen, validate.NameMaxLen) {
+		return fmt.Errorf("组织名称不能超过 %v 个字符", validate.NameMaxLen)
+	}
+	if r.MaxConcurrentBuilds < 0 || r.MaxEnvs < 0 {
+		return fmt.Errorf("组织配额不能小于0")
+	}
+	return nil
+}
+
+// OrganizationMemberReq ..
+type OrganizationMemberReq struct {
+	User string `json:"user"`
+	Role string `json:"role"`
+}
+
+// OrganizationRsp the organization with its usage of quotas
+type OrganizationRsp struct {
+	*models.Organization
+	Members       int   `json:"members"`
+	Projects      int   `json:"projects"`
+	Envs          int64 `json:"envs"`
+	RunningBuilds int   `json:"running_builds"`
+}
+
+// OrganizationManager ...
+type OrganizationManager struct {
+	model           *dao.OrganizationModel
+	modelPublishJob *dao.PublishJobModel
+}
+
+// NewOrganizationManager ...
+func NewOrganizationManager() *OrganizationManager {
+	return &OrganizationManager{
+		model:           dao.NewOrganizationModel(),
+		modelPublishJob: dao.NewPublishJobModel(),
+	}
+}
+
+// IsOrganizationAdmin the system admin is the admin of all organizations
+func (om *OrganizationManager) IsOrganizationAdmin(orgID int64, user string) bool {
+	if dao.UserIsAdmin(user) {
+		return true
+	}
+	member, err := om.model.GetOrganizationUser(orgID, user)
+	return err == nil && member.Role == models.OrgRoleAdmin
+}
+
+// IsOrganizationMember the system admin is the member of all organizations
+func (om *OrganizationManager) IsOrganizationMember(orgID int64, user string) bool {
+	if dao.UserIsAdmin(user) {
+		return true
+	}
+	_, err := om.model.GetOrganizationUser(orgID, user)
+	return err == nil
+}
+
+// GetOrganizations return the organizations, all the organizations are returned if orgIDs is nil
+func (om *OrganizationManager) GetOrganizations(orgIDs []int64) ([]*models.Organization, error) {
+	return om.model.GetOrganizations(orgIDs)
+}
+
+// GetOrganization ..
+func (om *OrganizationManager) GetOrganization(orgID int64) (*OrganizationRsp, error) {
+	org, err := om.model.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &OrganizationRsp{Organization: org}
+	if members, err := om.model.GetOrganizationUsers(orgID); err == nil {
+		rsp.Members = len(members)
+	} else {
+		log.Log.Warn("get members of organization: %v occur error: %s", orgID, err.Error())
+	}
+	projects, err := om.model.GetOrganizationProjects(orgID)
+	if err != nil {
+		return nil, err
+	}
+	rsp.Projects = len(projects)
+	if rsp.Envs, err = om.model.CountOrganizationEnvs(orgID); err != nil {
+		return nil, err
+	}
+	runningJobs, err := om.modelPublishJob.GetPublishJobsByFilter([]string{models.StatusRunning, models.StatusInit}, []string{models.JobTypeBuild})
+	if err != nil {
+		return nil, err
+	}
+	projectIDs := map[int64]bool{}
+	for _, item := range projects {
+		projectIDs[item.ID] = true
+	}
+	for _, job := range runningJobs {
+		if projectIDs[job.ProjectID] {
+			rsp.RunningBuilds++
+		}
+	}
+	return rsp, nil
+}
+
+// CreateOrganization the creator is added as the admin of organization
+func (om *OrganizationManager) CreateOrganization(request *OrganizationReq, creator string) (*models.Organization, error) {
+	if err := request.Verify(); err != nil {
+		return nil, err
+	}
+	if _, err := om.model.GetOrganizationByName(request.Name); err == nil {
+		return nil, fmt.Errorf("组织名称 %v 已经存在", request.Name)
+	}
+	org := &models.Organization{
+		Name:                request.Name,
+		Description:         request.Description,
+		Creator:             creator,
+		MaxConcurrentBuilds: request.MaxConcurrentBuilds,
+		MaxEnvs:             request.MaxEnvs,
+	}
+	orgID, err := om.model.CreateOrganization(org)
+	if err != nil {
+		return nil, err
+	}
+	org.ID = orgID
+	if _, err := om.model.CreateOrganizationUser(&models.OrganizationUser{OrgID: orgID, User: creator, Role: models.OrgRoleAdmin}); err != nil {
+		log.Log.Warn("add creator: %v to organization: %v occur error: %s", creator, orgID, err.Error())
+	}
+	return org, nil
+}
+
+// UpdateOrganization ..
+func (om *OrganizationManager) UpdateOrganization(orgID int64, request *OrganizationReq) error {
+	if err := request.Verify(); err != nil {
+		return err
+	}
+	org, err := om.model.GetOrganizationByID(orgID)
+	if err != nil {
+		return err
+	}
+	if item, err := om.model.GetOrganizationByName(request.Name); err == nil && item.ID != orgID {
+		return fmt.Errorf("组织名称 %v 已经存在", request.Name)
+	}
+	org.Name = request.Name
+	org.Description = request.Description
+	org.MaxConcurrentBuilds = request.MaxConcurrentBuilds
+	org.MaxEnvs = request.MaxEnvs
+	return om.model.UpdateOrganization(org)
+}
+
+// DeleteOrganization the organization is only allowed to be deleted without any projects and settings
+func (om *OrganizationManager) DeleteOrganization(orgID int64) error {
+	org, err := om.model.GetOrganizationByID(orgID)
+	if err != nil {
+		return err
+	}
+	projects, err := om.model.GetOrganizationProjects(orgID)
+	if err != nil {
+		return err
+	}
+	if len(projects) > 0 {
+		return fmt.Errorf("组织下存在 %v 个项目，请先移出或删除项目后重试", len(projects))
+	}
+	count, err := om.model.CountOrganizationSettings(orgID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("组织下存在 %v 个集成配置或编译环境，请先删除后重试", count)
+	}
+	return om.model.DeleteOrganization(org)
+}
+
+// GetOrganizationMembers ..
+func (om *OrganizationManager) GetOrganizationMembers(orgID int64) ([]*models.OrganizationUser, error) {
+	return om.model.GetOrganizationUsers(orgID)
+}
+
+// AddOrganizationMember add the user to organization, or update the role if the user is already a member
+func (om *OrganizationManager) AddOrganizationMember(orgID int64, request *OrganizationMemberReq) error {
+	if _, err := om.model.GetOrganizationByID(orgID); err != nil {
+		return err
+	}
+	if request.Role == "" {
+		request.Role = models.OrgRoleMember
+	}
+	if request.Role != models.OrgRoleAdmin && request.Role != models.OrgRoleMember {
+		return fmt.Errorf("无效的组织角色: %v", request.Role)
+	}
+	if _, err := dao.GetUser(request.User); err != nil {
+		return fmt.Errorf("用户 %v 不存在", request.User)
+	}
+	member, err := om.model.GetOrganizationUser(orgID, request.User)
+	if err == nil {
+		member.Role = request.Role
+		return om.model.UpdateOrganizationUser(member)
+	}
+	if err != orm.ErrNoRows {
+		return err
+	}
+	_, err = om.model.CreateOrganizationUser(&models.OrganizationUser{OrgID: orgID, User: request.User, Role: request.Role})
+	return err
+}
+
+// RemoveOrganizationMember the user is removed from the projects of organization too
+func (om *OrganizationManager) RemoveOrganizationMember(orgID int64, user string) error {
+	member, err := om.model.GetOrganizationUser(orgID, user)
+	if err != nil {
+		return err
+	}
+	projects, err := om.model.GetOrganizationProjects(orgID)
+	if err != nil {
+		return err
+	}
+	pm := project.NewProjectManager()
+	projectModel := dao.NewProjectModel()
+	for _, item := range projects {
+		if item.Owner == user {
+			return fmt.Errorf("用户 %v 是项目 %v 的负责人，请先变更项目负责人后重试", user, item.Name)
+		}
+		projectUsers, err := projectModel.GetProjectUsers(item.ID)
+		if err != nil {
+			return err
+		}
+		for _, projectUser := range projectUsers {
+			if projectUser.User != user {
+				continue
+			}
+			if err := pm.DeleteProjectMember(projectUser.ID, "system"); err != nil {
+				return err
+			}
+		}
+	}
+	return om.model.DeleteOrganizationUser(member)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package organization
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrganizationReqVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     OrganizationReq
+		wantErr bool
+	}{
+		{name: "valid", req: OrganizationReq{Name: " 研发一部 ", MaxConcurrentBuilds: 2, MaxEnvs: 10}},
+		{name: "unlimited", req: OrganizationReq{Name: "ops"}},
+		{name: "empty name", req: OrganizationReq{Name: "  "}, wantErr: true},
+		{name: "long name", req: OrganizationReq{Name: strings.Repeat("a", 65)}, wantErr: true},
+		{name: "negative builds", req: OrganizationReq{Name: "ops", MaxConcurrentBuilds: -1}, wantErr: true},
+		{name: "negative envs", req: OrganizationReq{Name: "ops", MaxEnvs: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Verify()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.req.Name != strings.TrimSpace(tt.req.Name) {
+				t.Errorf("Verify() did not trim the name: %q", tt.req.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AgentTemplate the customization of the jenkins agent pod of project ci jobs,
+// all the sub tasks of a job run in the same agent pod
+type AgentTemplate struct {
+	Labels         map[string]string `json:"labels,omitempty"`
+	Volumes        []*AgentVolume    `json:"volumes,omitempty"`
+	ServiceAccount string            `json:"service_account,omitempty"`
+	// IdleMinutes the agent pod is kept for reuse by the next build of the same job, 0 means deleted after build
+	IdleMinutes int `json:"idle_minutes,omitempty"`
+}
+
+// AgentVolume the volume mounted into all the containers of agent pod
+type AgentVolume struct {
+	Name string `json:"name"`
+	// Type hostPath/emptyDir/configMap/secret/pvc
+	Type string `json:"type"`
+	// Source the host path, config map, secret or claim name, not used by emptyDir
+	Source    string `json:"source,omitempty"`
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// the volume types of agent pod
+const (
+	AgentVolumeHostPath  = "hostPath"
+	AgentVolumeEmptyDir  = "emptyDir"
+	AgentVolumeConfigMap = "configMap"
+	AgentVolumeSecret    = "secret"
+	AgentVolumePVC       = "pvc"
+)
+
+// the volume added by jenkins kubernetes plugin
+const agentWorkspaceVolume = "workspace-volume"
+
+// the max idle minutes of agent pod
+const maxAgentIdleMinutes = 24 * 60
+
+// Validate ..
+func (t *AgentTemplate) Validate() error {
+	for key, value := range t.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return fmt.Errorf("无效的标签: %v, %v", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			return fmt.Errorf("无效的标签值: %v, %v", value, strings.Join(errs, "; "))
+		}
+	}
+	names := map[string]bool{}
+	mountPaths := map[string]bool{}
+	for _, volume := range t.Volumes {
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) != 0 || volume.Name == agentWorkspaceVolume || names[volume.Name] {
+			return fmt.Errorf("存储卷名称 %v 无效或重复", volume.Name)
+		}
+		names[volume.Name] = true
+		switch volume.Type {
+		case AgentVolumeEmptyDir:
+		case AgentVolumeHostPath, AgentVolumeConfigMap, AgentVolumeSecret, AgentVolumePVC:
+			if volume.Source == "" {
+				return fmt.Errorf("存储卷 %v 的来源不能为空", volume.Name)
+			}
+		default:
+			return fmt.Errorf("存储卷 %v 的类型 %v 无效, 仅支持 hostPath/emptyDir/configMap/secret/pvc", volume.Name, volume.Type)
+		}
+		if !path.IsAbs(volume.MountPath) || mountPaths[path.Clean(volume.MountPath)] {
+			return fmt.Errorf("存储卷 %v 的挂载路径 %v 必须是绝对路径且不能重复", volume.Name, volume.MountPath)
+		}
+		mountPaths[path.Clean(volume.MountPath)] = true
+	}
+	if t.ServiceAccount != "" {
+		if errs := validation.IsDNS1123Subdomain(t.ServiceAccount); len(errs) != 0 {
+			return fmt.Errorf("无效的服务账号: %v", t.ServiceAccount)
+		}
+	}
+	if t.IdleMinutes < 0 || t.IdleMinutes > maxAgentIdleMinutes {
+		return fmt.Errorf("空闲保留时间必须在 0 到 %v 分钟之间", maxAgentIdleMinutes)
+	}
+	return nil
+}
+
+func (t *AgentTemplate) empty() bool {
+	return t == nil || (len(t.Labels) == 0 && len(t.Volumes) == 0 && t.ServiceAccount == "" && t.IdleMinutes == 0)
+}
+
+// ParseAgentTemplate decode the agent template of project
+func ParseAgentTemplate(item *models.ProjectAgentTemplate) (*AgentTemplate, error) {
+	template := &AgentTemplate{
+		Labels:         map[string]string{},
+		Volumes:        []*AgentVolume{},
+		ServiceAccount: item.ServiceAccount,
+		IdleMinutes:    item.IdleMinutes,
+	}
+	if item.Labels != "" {
+		if err := json.Unmarshal([]byte(item.Labels), &template.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if item.Volumes != "" {
+		if err := json.Unmarshal([]byte(item.Volumes), &template.Volumes); err != nil {
+			return nil, err
+		}
+	}
+	return template, nil
+}
+
+// EncodeAgentTemplate encode the agent template into the model
+func EncodeAgentTemplate(template *AgentTemplate, item *models.ProjectAgentTemplate) error {
+	labels, err := json.Marshal(template.Labels)
+	if err != nil {
+		return err
+	}
+	volumes, err := json.Marshal(template.Volumes)
+	if err != nil {
+		return err
+	}
+	item.Labels = string(labels)
+	item.Volumes = string(volumes)
+	item.ServiceAccount = template.ServiceAccount
+	item.IdleMinutes = template.IdleMinutes
+	return nil
+}
+
+// podVolumes the volumes of agent pod
+func (t *AgentTemplate) podVolumes() []apiv1.Volume {
+	volumes := []apiv1.Volume{}
+	for _, item := range t.Volumes {
+		volume := apiv1.Volume{Name: item.Name}
+		switch item.Type {
+		case AgentVolumeHostPath:
+			volume.HostPath = &apiv1.HostPathVolumeSource{Path: item.Source}
+		case AgentVolumeEmptyDir:
+			volume.EmptyDir = &apiv1.EmptyDirVolumeSource{}
+		case AgentVolumeConfigMap:
+			volume.ConfigMap = &apiv1.ConfigMapVolumeSource{LocalObjectReference: apiv1.LocalObjectReference{Name: item.Source}}
+		case AgentVolumeSecret:
+			volume.Secret = &apiv1.SecretVolumeSource{SecretName: item.Source}
+		case AgentVolumePVC:
+			volume.PersistentVolumeClaim = &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: item.Source, ReadOnly: item.ReadOnly}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// volumeMounts the volume mounts of the containers of agent pod
+func (t *AgentTemplate) volumeMounts() []apiv1.VolumeMount {
+	mounts := []apiv1.VolumeMount{}
+	for _, item := range t.Volumes {
+		mounts = append(mounts, apiv1.VolumeMount{Name: item.Name, MountPath: item.MountPath, ReadOnly: item.ReadOnly})
+	}
+	return mounts
+}
+
+// the anchors of the pod template generated by workflow
+const (
+	podMetadataAnchor = "\nmetadata:\n"
+	containerAnchor   = "\n    tty: true"
+)
+
+// patchAgentTemplate insert the labels and the volume mounts of agent template into the pod template
+func patchAgentTemplate(pipelineXML string, template *AgentTemplate) (string, error) {
+	if template.empty() {
+		return pipelineXML, nil
+	}
+	if len(template.Labels) > 0 {
+		index := strings.Index(pipelineXML, podMetadataAnchor)
+		if index < 0 {
+			return "", fmt.Errorf("the pod template of pipeline has no metadata")
+		}
+		labels, err := json.Marshal(template.Labels)
+		if err != nil {
+			return "", err
+		}
+		index += len(podMetadataAnchor)
+		pipelineXML = pipelineXML[:index] + fmt.Sprintf("  labels: %s\n", labels) + pipelineXML[index:]
+	}
+	if len(template.Volumes) > 0 {
+		mounts, err := json.Marshal(template.volumeMounts())
+		if err != nil {
+			return "", err
+		}
+		pipelineXML = strings.Replace(pipelineXML, containerAnchor, fmt.Sprintf("%v\n    volumeMounts: %s", containerAnchor, mounts), -1)
+	}
+	return pipelineXML, nil
+}
+
+// agentDirectives the directives of the kubernetes agent, the agent pod with the same label is reused during the idle minutes
+func (t *AgentTemplate) agentDirectives(jobName string) []string {
+	if t == nil || t.IdleMinutes == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("label '%v'", jobName), fmt.Sprintf("idleMinutes %d", t.IdleMinutes)}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// appLookupConcurrency bound the concurrent scm requests when generate the images of apps
+const appLookupConcurrency = 8
+
+// appRecords the records of the apps in one env, fetched in batch instead of one query per app
+type appRecords struct {
+	projectApps   map[int64]*models.ProjectApp
+	scmApps       map[int64]*models.ScmApp
+	arranges      map[int64]*models.AppArrange
+	imageMappings map[[2]int64]*models.AppImageMapping
+	publishApps   map[int64]*models.PublishApp
+}
+
+// fetchAppRecords fetch the project apps and scm apps of appIDs, the arranges and image mappings are fetched
+// when envID is not 0, and the publish apps are fetched when publishID is not 0
+func (pm *PipelineManager) fetchAppRecords(publishID, envID int64, appIDs []int64) (*appRecords, error) {
+	records := &appRecords{
+		projectApps:   map[int64]*models.ProjectApp{},
+		scmApps:       map[int64]*models.ScmApp{},
+		arranges:      map[int64]*models.AppArrange{},
+		imageMappings: map[[2]int64]*models.AppImageMapping{},
+		publishApps:   map[int64]*models.PublishApp{},
+	}
+	projectApps, err := pm.modelProject.GetProjectAppsByAppIDs(appIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmIDs := []int64{}
+	for _, item := range projectApps {
+		records.projectApps[item.ID] = item
+		scmIDs = append(scmIDs, item.ScmID)
+	}
+	scmApps, err := pm.modelApp.GetScmAppsByIDs(scmIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range scmApps {
+		records.scmApps[item.ID] = item
+	}
+
+	if envID != 0 {
+		arranges, err := pm.modelAppArrange.GetAppArrangesByEnvID(envID, appIDs)
+		if err != nil {
+			return nil, err
+		}
+		arrangeIDs := []int64{}
+		for _, item := range arranges {
+			records.arranges[item.ProjectAppID] = item
+			arrangeIDs = append(arrangeIDs, item.ID)
+		}
+		imageMappings, err := pm.modelAppArrange.GetAppImageMappingsByArrangeIDs(arrangeIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range imageMappings {
+			key := [2]int64{item.ArrangeID, item.ProjectAppID}
+			if _, ok := records.imageMappings[key]; !ok {
+				records.imageMappings[key] = item
+			}
+		}
+	}
+
+	if publishID != 0 {
+		publishApps, err := pm.modelPublish.GetPublishAppsByPublishIDAndAppIDs(publishID, appIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range publishApps {
+			records.publishApps[item.ProjectAppID] = item
+		}
+	}
+	return records, nil
+}
+
+// projectApp return the project app and its scm app
+func (r *appRecords) projectApp(appID int64) (*models.ProjectApp, *models.ScmApp, error) {
+	projectApp, ok := r.projectApps[appID]
+	if !ok {
+		return nil, nil, orm.ErrNoRows
+	}
+	scmApp, ok := r.scmApps[projectApp.ScmID]
+	if !ok {
+		return projectApp, nil, fmt.Errorf("scm app: %v of app id: %v does not exist", projectApp.ScmID, appID)
+	}
+	return projectApp, scmApp, nil
+}
+
+// realArrange same as AppManager.GetRealArrange, the arrange without config is regarded as not setup
+func (r *appRecords) realArrange(appID, envID int64) (*models.AppArrange, error) {
+	arrange, ok := r.arranges[appID]
+	if !ok {
+		return nil, orm.ErrNoRows
+	}
+	if arrange.Config == "" {
+		return nil, fmt.Errorf("app id: %v  env id: %v arrange did not setup", appID, envID)
+	}
+	return arrange, nil
+}
+
+// imageMapping return the first image mapping of app in arrange
+func (r *appRecords) imageMapping(arrangeID, appID int64) (*models.AppImageMapping, error) {
+	imageMapping, ok := r.imageMappings[[2]int64{arrangeID, appID}]
+	if !ok {
+		return nil, orm.ErrNoRows
+	}
+	return imageMapping, nil
+}
+
+// publishApp ..
+func (r *appRecords) publishApp(appID int64) (*models.PublishApp, error) {
+	publishApp, ok := r.publishApps[appID]
+	if !ok {
+		return nil, orm.ErrNoRows
+	}
+	return publishApp, nil
+}
+
+// forEachLimit call fn with 0..n-1 concurrently, at most limit calls run at the same time
+func forEachLimit(n, limit int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
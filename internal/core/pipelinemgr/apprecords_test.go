@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestForEachLimit(t *testing.T) {
+	var running, peak int32
+	results := make([]int, 20)
+	forEachLimit(len(results), 3, func(i int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&peak)
+			if current <= max || atomic.CompareAndSwapInt32(&peak, max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		atomic.AddInt32(&running, -1)
+	})
+	if peak > 3 {
+		t.Errorf("expected at most 3 concurrent calls, got %v", peak)
+	}
+	for i, item := range results {
+		if item != i*i {
+			t.Errorf("expected result %v of index %v, got %v", i*i, i, item)
+		}
+	}
+}
+
+func TestAppRecordsRealArrange(t *testing.T) {
+	records := &appRecords{
+		arranges: map[int64]*models.AppArrange{
+			1: {ProjectAppID: 1, Config: "kind: Deployment"},
+			2: {ProjectAppID: 2},
+		},
+	}
+	if _, err := records.realArrange(1, 10); err != nil {
+		t.Errorf("expected arrange of app 1, got error: %v", err)
+	}
+	if _, err := records.realArrange(2, 10); err == nil {
+		t.Error("expected error of the arrange without config")
+	}
+	if _, err := records.realArrange(3, 10); err == nil {
+		t.Error("expected error of the missing arrange")
+	}
+}
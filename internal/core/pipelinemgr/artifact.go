@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+// the object storage of artifacts passed between steps, the storage of e2e reports is used by default
+var (
+	artifactImage           = beego.AppConfig.DefaultString("artifact::image", e2eUploaderImage)
+	artifactStorageEndpoint = beego.AppConfig.DefaultString("artifact::endpoint", e2eStorageEndpoint)
+	artifactStorageBucket   = beego.AppConfig.DefaultString("artifact::bucket", "atomci-artifacts")
+	artifactStorageAccess   = beego.AppConfig.DefaultString("artifact::access_key", e2eStorageAccess)
+	artifactStorageSecret   = beego.AppConfig.DefaultString("artifact::secret_key", e2eStorageSecret)
+)
+
+const artifactContainerName = "artifact"
+
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// artifactTask the params of artifact sub task, the build step uploads the path of each app repo to object storage
+// as the artifact of publish, the e2e-test step downloads the artifact into $ARTIFACT_DIR/<name>/<app>/
+type artifactTask struct {
+	Name string `json:"name,omitempty"`
+	// Path the file or dir relative to the app repo, only for build step
+	Path string `json:"path,omitempty"`
+}
+
+// validate the params are rendered into shell commands, only the safe characters are allowed
+func (t *artifactTask) validate(stepType string) error {
+	if t == nil {
+		return fmt.Errorf("artifact 子任务缺少参数配置")
+	}
+	if !artifactNamePattern.MatchString(t.Name) || t.Name == "." || t.Name == ".." {
+		return fmt.Errorf("制品名称: %v 无效，只允许字母、数字、下划线、点和中划线", t.Name)
+	}
+	switch stepType {
+	case models.StepBuild:
+		if t.Path == "" || !terraformDirPattern.MatchString(t.Path) || strings.Contains(t.Path, "..") || strings.HasPrefix(t.Path, "/") {
+			return fmt.Errorf("制品 %v 的路径: %v 无效，须为代码仓库内的相对路径", t.Name, t.Path)
+		}
+	case models.StepE2ETest:
+	default:
+		return fmt.Errorf("artifact 子任务只能用于构建或端到端测试任务节点")
+	}
+	return nil
+}
+
+// artifactStorageEnabled the artifact sub task requires the object storage
+func artifactStorageEnabled() error {
+	if artifactStorageEndpoint == "" {
+		return fmt.Errorf("未配置制品存储，无法使用 artifact 子任务，请联系管理员")
+	}
+	return nil
+}
+
+// artifactPrefix the object prefix of the artifact of publish in the bucket, uploaded by the latest build
+func artifactPrefix(projectID, publishID int64, name string) string {
+	return fmt.Sprintf("%d/%d/%s", projectID, publishID, name)
+}
+
+// artifactDir the dir of the artifacts downloaded by the job
+func artifactDir(workspace string, publishJobID int64) string {
+	return fmt.Sprintf("%s/artifacts/%d", workspace, publishJobID)
+}
+
+// artifactContainer the container used to upload and download artifacts, with the storage credentials
+func artifactContainer() (jenkins.ContainerEnv, []jenkins.EnvItem) {
+	container := jenkins.ContainerEnv{
+		Name:       artifactContainerName,
+		Image:      artifactImage,
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	}
+	envVars := []jenkins.EnvItem{
+		{Key: "ARTIFACT_STORAGE_ENDPOINT", Value: artifactStorageEndpoint},
+		{Key: "ARTIFACT_STORAGE_ACCESS_KEY", Value: artifactStorageAccess},
+		{Key: "ARTIFACT_STORAGE_SECRET_KEY", Value: artifactStorageSecret},
+	}
+	return container, envVars
+}
+
+const artifactAliasCommand = `mc --config-dir /tmp/.mc alias set atomci "$ARTIFACT_STORAGE_ENDPOINT" "$ARTIFACT_STORAGE_ACCESS_KEY" "$ARTIFACT_STORAGE_SECRET_KEY" >/dev/null`
+
+// artifactUploadCommands replace the artifact of publish by the path of each app repo, the missing path fails the build
+func artifactUploadCommands(prefix string, appPaths map[string]string, apps []string) []string {
+	commands := []string{
+		fmt.Sprintf("container('%s') {", artifactContainerName),
+		fmt.Sprintf(`sh '%s && (mc --config-dir /tmp/.mc rm --recursive --force atomci/%s/%s/ || true)'`, artifactAliasCommand, artifactStorageBucket, prefix),
+	}
+	for _, app := range apps {
+		src := appPaths[app]
+		commands = append(commands,
+			fmt.Sprintf(`sh 'test -e %s || (echo "artifact path %s not found" && exit 1)'`, src, src),
+			fmt.Sprintf(`sh 'mc --config-dir /tmp/.mc cp --recursive %s atomci/%s/%s/%s/'`, src, artifactStorageBucket, prefix, app),
+		)
+	}
+	return append(commands, "}")
+}
+
+// artifactDownloadCommands download the artifact of publish, the missing artifact fails the job
+func artifactDownloadCommands(prefix, dir string) []string {
+	return []string{
+		fmt.Sprintf("container('%s') {", artifactContainerName),
+		fmt.Sprintf(`sh 'rm -rf %s && mkdir -p %s'`, dir, dir),
+		fmt.Sprintf(`sh '%s && mc --config-dir /tmp/.mc cp --recursive atomci/%s/%s/ %s/'`, artifactAliasCommand, artifactStorageBucket, prefix, dir),
+		fmt.Sprintf(`sh 'test -n "$(ls -A %s)" || (echo "artifact %s not found, run the build step which uploads it first" && exit 1)'`, dir, prefix),
+		"}",
+	}
+}
+
+// artifactStage render the commands into the jenkins stage of artifact sub task
+func artifactStage(name string, commands []string) (string, error) {
+	item := jenkins.StepItem{
+		Name:    fmt.Sprintf("'Artifact-%s'", name),
+		Command: strings.Join(commands, "\n"),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
+
+// renderArtifactUploadStageForBuild upload the path of each app repo built by the job
+func (pm *PipelineManager) renderArtifactUploadStageForBuild(projectID, publishID, stageID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, task *artifactTask) (string, error) {
+	if err := artifactStorageEnabled(); err != nil {
+		return "", err
+	}
+	if err := task.validate(models.StepBuild); err != nil {
+		return "", err
+	}
+	apps := []string{}
+	appPaths := map[string]string{}
+	for _, app := range allParms {
+		apps = append(apps, app.Name)
+		appPaths[app.Name] = strings.Join([]string{pm.generateAppRepoPth(stageID, projectID, ciConfig.Workspace, app), task.Path}, "/")
+	}
+	return artifactStage(task.Name, artifactUploadCommands(artifactPrefix(projectID, publishID, task.Name), appPaths, apps))
+}
+
+// renderArtifactDownloadStages download the artifacts declared by the sub tasks of step into the dir of job
+func renderArtifactDownloadStages(projectID, publishID int64, dir string, tasks []*subTask) ([]string, error) {
+	stages := []string{}
+	for _, task := range tasks {
+		if task.Type != constant.StepSubTaskArtifact {
+			continue
+		}
+		if err := artifactStorageEnabled(); err != nil {
+			return nil, err
+		}
+		if err := task.Artifact.validate(models.StepE2ETest); err != nil {
+			return nil, err
+		}
+		stage, err := artifactStage(task.Artifact.Name, artifactDownloadCommands(artifactPrefix(projectID, publishID, task.Artifact.Name), dir+"/"+task.Artifact.Name))
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestArtifactTaskValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     *artifactTask
+		stepType string
+		wantErr  bool
+	}{
+		{name: "upload", task: &artifactTask{Name: "dist", Path: "target/app.jar"}, stepType: models.StepBuild},
+		{name: "download", task: &artifactTask{Name: "dist"}, stepType: models.StepE2ETest},
+		{name: "missing", stepType: models.StepBuild, wantErr: true},
+		{name: "invalid name", task: &artifactTask{Name: "dist/app", Path: "target"}, stepType: models.StepBuild, wantErr: true},
+		{name: "upload without path", task: &artifactTask{Name: "dist"}, stepType: models.StepBuild, wantErr: true},
+		{name: "path outside repo", task: &artifactTask{Name: "dist", Path: "../secrets"}, stepType: models.StepBuild, wantErr: true},
+		{name: "path with quote", task: &artifactTask{Name: "dist", Path: "target'; rm -rf /"}, stepType: models.StepBuild, wantErr: true},
+		{name: "deploy step", task: &artifactTask{Name: "dist"}, stepType: models.StepDeploy, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.task.validate(tt.stepType); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArtifactCommands(t *testing.T) {
+	prefix := artifactPrefix(1, 2, "dist")
+	upload := strings.Join(artifactUploadCommands(prefix, map[string]string{"web": "/ws/web/dist", "api": "/ws/api/dist"}, []string{"web", "api"}), "\n")
+	for _, want := range []string{
+		"rm --recursive --force atomci/" + artifactStorageBucket + "/1/2/dist/",
+		"cp --recursive /ws/web/dist atomci/" + artifactStorageBucket + "/1/2/dist/web/",
+		"cp --recursive /ws/api/dist atomci/" + artifactStorageBucket + "/1/2/dist/api/",
+	} {
+		if !strings.Contains(upload, want) {
+			t.Errorf("artifactUploadCommands() = %v, want contains %v", upload, want)
+		}
+	}
+	if strings.Index(upload, "/ws/web/dist atomci") > strings.Index(upload, "/ws/api/dist atomci") {
+		t.Errorf("artifactUploadCommands() want the apps uploaded in order")
+	}
+	download := strings.Join(artifactDownloadCommands(prefix, "/ws/artifacts/3/dist"), "\n")
+	if !strings.Contains(download, "cp --recursive atomci/"+artifactStorageBucket+"/1/2/dist/ /ws/artifacts/3/dist/") {
+		t.Errorf("artifactDownloadCommands() = %v, want download into the dir", download)
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/workflow/jenkins"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// buildCloud the kubernetes cloud of jenkins which runs the build pods on the dedicated build cluster
+type buildCloud struct {
+	Name      string `json:"name"`
+	ServerURL string `json:"server_url"`
+	Namespace string `json:"namespace"`
+	// Token/KubeConfig the credential of build cluster, KubeConfig is used only if the kubeconfig has no token
+	Token      string `json:"token,omitempty"`
+	KubeConfig string `json:"kubeconfig,omitempty"`
+	CACert     string `json:"ca_cert,omitempty"`
+	// AgentURL/AgentTunnel the jenkins url and tunnel which the agents connect to
+	AgentURL    string `json:"agent_url,omitempty"`
+	AgentTunnel string `json:"agent_tunnel,omitempty"`
+}
+
+// buildCloudName the cloud and credential name of the build cluster in jenkins
+func buildCloudName(clusterID int64) string {
+	return fmt.Sprintf("atomci-cluster-%d", clusterID)
+}
+
+// getBuildCloud return the dedicated build cluster of project env or ci server,
+// nil means the build pods run on the kubernetes cloud configured in jenkins
+func (pm *PipelineManager) getBuildCloud(projectEnv *models.ProjectEnv, namespace string) (*buildCloud, error) {
+	ciServer, err := pm.settingsHandler.GetIntegrateSettingByID(projectEnv.CIServer)
+	if err != nil {
+		return nil, err
+	}
+	jenkinsConfig, ok := ciServer.Config.(*settings.JenkinsConfig)
+	if !ok {
+		return nil, fmt.Errorf("parse jenkins config error")
+	}
+	clusterID := projectEnv.BuildCluster
+	if clusterID == 0 {
+		clusterID = jenkinsConfig.BuildCluster
+	}
+	if clusterID == 0 {
+		return nil, nil
+	}
+
+	cluster, err := pm.settingsHandler.GetIntegrateSettingByID(clusterID)
+	if err != nil {
+		log.Log.Error("get build cluster: %v occur error: %s", clusterID, err.Error())
+		return nil, fmt.Errorf("构建集群 %v 不存在", clusterID)
+	}
+	kubeConfig, ok := cluster.Config.(*settings.KubeConfig)
+	if cluster.Type != settings.KubernetesType || !ok {
+		return nil, fmt.Errorf("构建集群 %v 必须是 kubernetes 集成配置", cluster.Name)
+	}
+	cloud := &buildCloud{
+		Name:        buildCloudName(clusterID),
+		Namespace:   namespace,
+		AgentURL:    jenkinsConfig.AgentURL,
+		AgentTunnel: jenkinsConfig.AgentTunnel,
+	}
+	if kubeConfig.Type == settings.KubernetesToken {
+		cloud.ServerURL = kubeConfig.URL
+		cloud.Token = kubeConfig.Conf
+		if !kubeConfig.Insecure {
+			cloud.CACert = kubeConfig.CACert
+		}
+		return cloud, nil
+	}
+	restConfig, err := kubeConfig.RESTConfig()
+	if err != nil {
+		log.Log.Error("parse kubeconfig of build cluster: %v occur error: %s", cluster.Name, err.Error())
+		return nil, fmt.Errorf("构建集群 %v 的 kubeconfig 无效", cluster.Name)
+	}
+	cloud.ServerURL = restConfig.Host
+	cloud.CACert = string(restConfig.CAData)
+	if restConfig.BearerToken != "" {
+		cloud.Token = restConfig.BearerToken
+	} else {
+		cloud.KubeConfig = kubeConfig.Conf
+	}
+	return cloud, nil
+}
+
+// buildCloudSynced printed by the script when the cloud synced
+const buildCloudSynced = "atomci build cloud synced"
+
+// buildCloudScript the jenkins script which creates or updates the credential and kubernetes cloud of build cluster,
+// the values are base64 encoded to avoid escaping
+const buildCloudScript = `import jenkins.model.Jenkins
+import hudson.util.Secret
+import com.cloudbees.plugins.credentials.CredentialsScope
+import com.cloudbees.plugins.credentials.SecretBytes
+import com.cloudbees.plugins.credentials.SystemCredentialsProvider
+import com.cloudbees.plugins.credentials.domains.Domain
+import org.jenkinsci.plugins.plaincredentials.impl.FileCredentialsImpl
+import org.jenkinsci.plugins.plaincredentials.impl.StringCredentialsImpl
+import org.csanchez.jenkins.plugins.kubernetes.KubernetesCloud
+
+def decode = { String value -> new String(value.decodeBase64(), "UTF-8") }
+def name = decode("{{ base64 .Name }}")
+def store = SystemCredentialsProvider.getInstance().getStore()
+{{- if .Token }}
+def credentials = new StringCredentialsImpl(CredentialsScope.GLOBAL, name, "managed by atomci", Secret.fromString(decode("{{ base64 .Token }}")))
+{{- else }}
+def credentials = new FileCredentialsImpl(CredentialsScope.GLOBAL, name, "managed by atomci", "kubeconfig", SecretBytes.fromBytes("{{ base64 .KubeConfig }}".decodeBase64()))
+{{- end }}
+def existing = store.getCredentials(Domain.global()).find { it.id == name }
+if (existing) {
+    store.updateCredentials(Domain.global(), existing, credentials)
+} else {
+    store.addCredentials(Domain.global(), credentials)
+}
+
+def instance = Jenkins.get()
+def cloud = instance.clouds.getByName(name)
+if (cloud == null) {
+    cloud = new KubernetesCloud(name)
+    instance.clouds.add(cloud)
+}
+cloud.setServerUrl(decode("{{ base64 .ServerURL }}"))
+cloud.setNamespace(decode("{{ base64 .Namespace }}"))
+cloud.setCredentialsId(name)
+{{- if .CACert }}
+cloud.setServerCertificate(decode("{{ base64 .CACert }}"))
+cloud.setSkipTlsVerify(false)
+{{- else }}
+cloud.setSkipTlsVerify(true)
+{{- end }}
+{{- if .AgentURL }}
+cloud.setJenkinsUrl(decode("{{ base64 .AgentURL }}"))
+{{- end }}
+{{- if .AgentTunnel }}
+cloud.setJenkinsTunnel(decode("{{ base64 .AgentTunnel }}"))
+{{- end }}
+instance.save()
+println("` + buildCloudSynced + `")
+`
+
+// script return the jenkins script which syncs the build cloud
+func (c *buildCloud) script() (string, error) {
+	tmpl, err := template.New("cloud").Funcs(template.FuncMap{
+		"base64": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+	}).Parse(buildCloudScript)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, c); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// syncCloud create or update the kubernetes cloud of build cluster by the script console of jenkins
+func (j *jenkinsJob) syncCloud(cloud *buildCloud) error {
+	script, err := cloud.script()
+	if err != nil {
+		return err
+	}
+	form := url.Values{"script": []string{script}}
+	output, err := j.request("POST", fmt.Sprintf("%v/scriptText", j.url), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(output), buildCloudSynced) {
+		log.Log.Error("sync jenkins cloud: %v failed: %s", cloud.Name, string(output))
+		return fmt.Errorf("同步构建集群 %v 到 Jenkins 失败，请确认 Jenkins 已安装 kubernetes 插件", cloud.Name)
+	}
+	return nil
+}
+
+// pipelineAgentAnchor the kubernetes agent of pipeline generated by workflow
+const pipelineAgentAnchor = "defaultContainer 'jnlp'"
+
+// patchPipelineAgent insert the directives into the kubernetes agent of pipeline, eg: cloud 'name'
+func patchPipelineAgent(pipelineXML string, directives ...string) (string, error) {
+	if len(directives) == 0 {
+		return pipelineXML, nil
+	}
+	index := strings.Index(pipelineXML, pipelineAgentAnchor)
+	if index < 0 {
+		return "", fmt.Errorf("the agent of pipeline has no default container")
+	}
+	lines := ""
+	for _, directive := range directives {
+		lines += directive + "\n            "
+	}
+	return pipelineXML[:index] + lines + pipelineXML[index:], nil
+}
+
+// ciFlowProcessor return the flow processor of ci job, the pipeline is patched when the env has dedicated build cluster,
+// the project has agent template or the compile envs have scheduling, the build pod runs on the nodes of arch if it is not empty
+func (pm *PipelineManager) ciFlowProcessor(stageID int64, ciContext jenkins.CIContext, compileParams []compileEnv, arch string) (jenkins.FlowProcessor, error) {
+	projectEnv, err := pm.modelProject.GetProjectEnvByID(stageID)
+	if err != nil {
+		return nil, err
+	}
+	ciContext = withNetworkSettings(ciContext)
+	cloud, err := pm.getBuildCloud(projectEnv, ciContext.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	agent, err := pm.getAgentTemplate(projectEnv.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	podSpec := mergeBuildPodSpec(compileParams)
+	if arch != "" {
+		podSpec.NodeSelector[archNodeLabel] = arch
+	}
+	if osType, _ := buildOS(compileParams); osType == models.CompileEnvOSWindows {
+		// the images are built by the docker engine of windows node
+		agent = withDockerEngine(agent)
+	}
+	if agent != nil {
+		podSpec.ServiceAccountName = agent.ServiceAccount
+		podSpec.Volumes = agent.podVolumes()
+	}
+	// the job in folder or calling the shared library is created by the pod template context,
+	// since the workflow creates the top level job with the generated pipeline only
+	folder := jenkinsFolder(projectEnv.ProjectID)
+	if cloud == nil && agent.empty() && podSpec.empty() && folder == "" && jenkinsSharedLibrary == "" {
+		return &ciContext, nil
+	}
+	return &podTemplateContext{CIContext: ciContext, PodSpec: podSpec, Agent: agent, Cloud: cloud, Label: folder}, nil
+}
+
+// withNetworkSettings the containers of build pod pull the images from the registry mirrors,
+// and access the internet by the proxy in the network without direct internet access
+func withNetworkSettings(ciContext jenkins.CIContext) jenkins.CIContext {
+	containers := make([]jenkins.ContainerEnv, 0, len(ciContext.ContainerTemplates))
+	for _, container := range ciContext.ContainerTemplates {
+		container.Image = settings.MirrorImage(container.Image)
+		containers = append(containers, container)
+	}
+	ciContext.ContainerTemplates = containers
+	envVars := append([]jenkins.EnvItem{}, ciContext.EnvVars...)
+	for _, env := range settings.ProxyEnvVars() {
+		envVars = append(envVars, jenkins.EnvItem{Key: env.Key, Value: env.Value})
+	}
+	ciContext.EnvVars = envVars
+	return ciContext
+}
+
+// getAgentTemplate return the agent template of project, nil means not customized
+func (pm *PipelineManager) getAgentTemplate(projectID int64) (*AgentTemplate, error) {
+	item, err := pm.modelProject.GetProjectAgentTemplate(projectID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	agent, err := ParseAgentTemplate(item)
+	if err != nil {
+		log.Log.Error("parse agent template of project: %v occur error: %s", projectID, err.Error())
+		return nil, fmt.Errorf("项目的构建代理模板无效，请更新后重试")
+	}
+	return agent, nil
+}
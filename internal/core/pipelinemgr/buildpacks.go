@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	defaultBuildpacksBuilder = beego.AppConfig.DefaultString("buildpacks::builder", "paketobuildpacks/builder:base")
+)
+
+const buildpacksContainerPrefix = "buildpacks"
+
+// buildWithBuildpacks the image of app is built by cloud native buildpacks instead of dockerfile
+func buildWithBuildpacks(app *RunBuildAllParms) bool {
+	return app.BuildMode == constant.BuildModeBuildpacks
+}
+
+// buildpacksBuilder return the builder image of app, the builder of compile env is used first
+func (pm *PipelineManager) buildpacksBuilder(app *RunBuildAllParms) string {
+	if app.CompileEnvID != 0 {
+		compileEnv, err := pm.settingsHandler.GetCompileEnvByID(app.CompileEnvID)
+		if err != nil {
+			log.Log.Warn("get compile env: %v of app: %v error: %s, use the default builder", app.CompileEnvID, app.Name, err.Error())
+		} else if compileEnv.BuilderImage != "" {
+			return compileEnv.BuilderImage
+		}
+	}
+	return defaultBuildpacksBuilder
+}
+
+// buildpacksContainers return one container per builder image, and the container name of every buildpacks app
+func (pm *PipelineManager) buildpacksContainers(allParms []*RunBuildAllParms) ([]jenkins.ContainerEnv, map[int64]string) {
+	containers := []jenkins.ContainerEnv{}
+	containerOfBuilder := map[string]string{}
+	containerOfApp := map[int64]string{}
+	for _, app := range allParms {
+		if !buildWithBuildpacks(app) {
+			continue
+		}
+		builder := pm.buildpacksBuilder(app)
+		name, ok := containerOfBuilder[builder]
+		if !ok {
+			name = fmt.Sprintf("%s-%d", buildpacksContainerPrefix, len(containers))
+			containerOfBuilder[builder] = name
+			containers = append(containers, jenkins.ContainerEnv{
+				Name:       name,
+				Image:      builder,
+				WorkingDir: "/home/jenkins/agent",
+				CommandArr: []string{"cat"},
+			})
+		}
+		containerOfApp[app.ProjectAppID] = name
+	}
+	return containers, containerOfApp
+}
+
+// buildpacksCommand run the lifecycle creator of builder, the buildpacks detect the language of app and
+// export the image to the same address as the dockerfile build, so the image is recorded in the same way
+func buildpacksCommand(container, appPath, imageURL string, insecure bool) string {
+	flags := ""
+	if insecure {
+		flags = "-insecure-registry=$REGISTRY_ADDR "
+	}
+	return fmt.Sprintf(`container('%s') {
+sh """
+cd %v; export CNB_REGISTRY_AUTH='{"'$REGISTRY_ADDR'": "Basic '$DOCKER_AUTH'"}'; /cnb/lifecycle/creator -app=. -skip-restore %s%v
+"""
+}`, container, appPath, flags, imageURL)
+}
+
+// renderBuildpacksStageForBuild build the images of apps without dockerfile, the stage is empty if there is no such app
+func (pm *PipelineManager) renderBuildpacksStageForBuild(projectID, stageID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, deployTarget *DeployTarget, matrix *buildMatrix, containerOfApp map[int64]string) (string, error) {
+	if len(containerOfApp) == 0 {
+		return "", nil
+	}
+	if matrix.multiArch() {
+		return "", fmt.Errorf("Buildpacks 构建方式暂不支持多架构矩阵构建")
+	}
+	imageURLs, err := pm.buildImageAddrs(stageID, allParms)
+	if err != nil {
+		return "", err
+	}
+	commands := []string{}
+	for i, app := range allParms {
+		if !buildWithBuildpacks(app) || imageURLs[i] == "" {
+			continue
+		}
+		appPath := pm.matrixAppPath(stageID, projectID, ciConfig.Workspace, app, pm.matrixCells(matrix, app)[0])
+		commands = append(commands, buildpacksCommand(containerOfApp[app.ProjectAppID], appPath, imageURLs[i], !deployTarget.RegistryHTTPS))
+	}
+	if len(commands) == 0 {
+		return "", nil
+	}
+	item := jenkins.StepItem{
+		Name:    "'Buildpacks'",
+		Command: strings.Join(commands, "\n"),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
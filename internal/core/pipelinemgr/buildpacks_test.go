@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestBuildpacksContainers(t *testing.T) {
+	pm := &PipelineManager{}
+	allParms := []*RunBuildAllParms{
+		{RunBuildAppReq: &RunBuildAppReq{ProjectAppID: 1}, ScmApp: &models.ScmApp{Name: "web", BuildMode: constant.BuildModeBuildpacks}},
+		{RunBuildAppReq: &RunBuildAppReq{ProjectAppID: 2}, ScmApp: &models.ScmApp{Name: "api"}},
+		{RunBuildAppReq: &RunBuildAppReq{ProjectAppID: 3}, ScmApp: &models.ScmApp{Name: "job", BuildMode: constant.BuildModeBuildpacks}},
+	}
+	containers, containerOfApp := pm.buildpacksContainers(allParms)
+	if len(containers) != 1 || containers[0].Image != defaultBuildpacksBuilder {
+		t.Fatalf("containers = %+v, want one container of the default builder", containers)
+	}
+	if len(containerOfApp) != 2 || containerOfApp[1] != containers[0].Name || containerOfApp[3] != containers[0].Name {
+		t.Fatalf("container of apps = %v", containerOfApp)
+	}
+}
+
+func TestBuildpacksCommand(t *testing.T) {
+	command := buildpacksCommand("buildpacks-0", "/workspace/web", "registry.local/demo/web:master-abc", true)
+	for _, want := range []string{"container('buildpacks-0')", "cd /workspace/web;", "/cnb/lifecycle/creator -app=.", "-insecure-registry=$REGISTRY_ADDR registry.local/demo/web:master-abc"} {
+		if !strings.Contains(command, want) {
+			t.Fatalf("command %q does not contain %q", command, want)
+		}
+	}
+	if strings.Contains(buildpacksCommand("buildpacks-0", "/workspace/web", "registry.local/demo/web:v1", false), "-insecure-registry") {
+		t.Fatalf("https registry should not be insecure")
+	}
+}
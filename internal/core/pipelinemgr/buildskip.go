@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// builtImageVersion return the image tag `branch-commit` of the branch head built by the job, empty when the lookup failed
+func (pm *PipelineManager) builtImageVersion(projectAppID int64, branch string) string {
+	version, err := pm.GetAppCodeCommitByBranch(projectAppID, branch)
+	if err != nil {
+		log.Log.Warn("get app: %v branch: %v head commit occur error: %s", projectAppID, branch, err.Error())
+		return ""
+	}
+	return version
+}
+
+// lastBuiltVersions return the image version of each app built by the latest success build of publish
+func (pm *PipelineManager) lastBuiltVersions(publishID int64) map[int64]string {
+	versions := map[int64]string{}
+	jobApps, err := pm.modelPublishJob.GetLastSuccessBuildJobApps(publishID)
+	if err != nil {
+		log.Log.Warn("get publish: %v last success build job apps occur error: %s", publishID, err.Error())
+		return versions
+	}
+	for appID, jobApp := range jobApps {
+		if jobApp.ImageVersion != "" {
+			versions[appID] = jobApp.ImageVersion
+		}
+	}
+	return versions
+}
+
+// unchangedApp tell whether the branch head was built already, the version is `branch-commit`
+func unchangedApp(builtVersion, headVersion string) bool {
+	return builtVersion != "" && builtVersion == headVersion
+}
+
+// skipUnchangedApps split the apps into the apps to build and the ids of apps whose branch head was built by the publish,
+// the app whose head is unknown is built
+func (pm *PipelineManager) skipUnchangedApps(publishID int64, apps []*RunBuildAppReq) ([]*RunBuildAppReq, []int64) {
+	pm = pm.withCommitCache()
+	pm.applyReleaseBranches(publishID, apps)
+	versions := pm.lastBuiltVersions(publishID)
+	builds, skipped := []*RunBuildAppReq{}, []int64{}
+	for _, app := range apps {
+		if unchangedApp(versions[app.ProjectAppID], pm.builtImageVersion(app.ProjectAppID, app.Branch)) {
+			skipped = append(skipped, app.ProjectAppID)
+			continue
+		}
+		builds = append(builds, app)
+	}
+	return builds, skipped
+}
+
+// applyBuiltImageTags deploy the image built by the publish for the apps without explicit image tag,
+// the version built from another branch, eg: before the release branch created, is ignored
+func (pm *PipelineManager) applyBuiltImageTags(publishID int64, apps []*RunDeployAppReq) {
+	versions := pm.lastBuiltVersions(publishID)
+	for _, app := range apps {
+		version := versions[app.ProjectAppID]
+		if app.ImageTag != "" || version == "" {
+			continue
+		}
+		publishApp, err := pm.modelPublish.GetPublishAppByPublishIDAndAppID(publishID, app.ProjectAppID)
+		if err != nil {
+			continue
+		}
+		if builtByBranch(version, PublishAppBuildBranch(publishApp)) {
+			app.BuiltTag = version
+		}
+	}
+}
+
+// builtByBranch tell whether the version `branch-commit` was built from the branch
+func builtByBranch(version, branch string) bool {
+	return strings.HasPrefix(version, branch+"-") && !strings.Contains(strings.TrimPrefix(version, branch+"-"), "-")
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import "testing"
+
+func TestBuiltByBranch(t *testing.T) {
+	tests := []struct {
+		version string
+		branch  string
+		want    bool
+	}{
+		{"master-1a2b3c4", "master", true},
+		{"feature-x-1a2b3c4", "feature-x", true},
+		{"feature-x-1a2b3c4", "feature", false},
+		{"release-1.0-1a2b3c4", "master", false},
+		{"", "master", false},
+	}
+	for _, tt := range tests {
+		if got := builtByBranch(tt.version, tt.branch); got != tt.want {
+			t.Errorf("builtByBranch(%v, %v) = %v, want %v", tt.version, tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestUnchangedApp(t *testing.T) {
+	if !unchangedApp("master-1a2b3c4", "master-1a2b3c4") {
+		t.Errorf("unchangedApp() want true for the head built already")
+	}
+	if unchangedApp("master-1a2b3c4", "master-5d6e7f8") {
+		t.Errorf("unchangedApp() want false for the new commits")
+	}
+	if unchangedApp("", "") {
+		t.Errorf("unchangedApp() want false when the head is unknown")
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// CallbackTokenTTL the valid duration of callback tokens, it should cover the longest build,
+// the callback never arrives after the token expired
+func CallbackTokenTTL() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("jwt::callback_ttl", 6)) * time.Hour
+}
+
+// issueCallbackToken the token is only valid for the callback of the publish job, instead of the admin token
+func issueCallbackToken(projectID, publishID, stageID, publishJobID int64, step string) (string, error) {
+	return middleware.CallbackAuth(middleware.CallbackClaims{
+		ProjectID:    projectID,
+		PublishID:    publishID,
+		StageID:      stageID,
+		Step:         step,
+		PublishJobID: publishJobID,
+	}, CallbackTokenTTL())
+}
+
+// callbackRequestBody the body of the job callback, which carries the signed idempotency key
+func callbackRequestBody(publishJobID int64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(random)
+	return fmt.Sprintf("{\"publish_job_id\": %d, \"idempotency_key\": \"%s\", \"signature\": \"%s\"}", publishJobID, key, signCallback(publishJobID, key)), nil
+}
+
+func signCallback(publishJobID int64, key string) string {
+	mac := hmac.New(sha256.New, []byte(beego.AppConfig.String("jwt::secret")))
+	mac.Write([]byte(fmt.Sprintf("%d:%s", publishJobID, key)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify verify the signature of the callback, the callback without idempotency key is sent by the job created
+// before upgrade, it is keyed by the publish job and step, and rejected when callback::require_signature enabled
+func (req *BuildStepCallbackReq) Verify(step string) error {
+	if req.PublishJobID == 0 {
+		return fmt.Errorf("缺少 publish_job_id")
+	}
+	if req.IdempotencyKey == "" {
+		if beego.AppConfig.DefaultBool("callback::require_signature", false) {
+			return fmt.Errorf("回调请求缺少签名")
+		}
+		req.IdempotencyKey = fmt.Sprintf("job-%d-%s", req.PublishJobID, step)
+		return nil
+	}
+	if !hmac.Equal([]byte(req.Signature), []byte(signCallback(req.PublishJobID, req.IdempotencyKey))) {
+		return fmt.Errorf("回调请求签名校验失败")
+	}
+	return nil
+}
+
+// jobPublishStatus the publish status of the job in end status
+func jobPublishStatus(status string) int64 {
+	switch status {
+	case models.StatusSuccess:
+		return models.Success
+	case models.StatusAbort:
+		return models.TerminateSuccess
+	default:
+		return models.Failed
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCallbackRequestVerify(t *testing.T) {
+	body, err := callbackRequestBody(3)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	request := &BuildStepCallbackReq{}
+	if err := json.Unmarshal([]byte(body), request); err != nil {
+		t.Fatalf("unmarshal callback body %s occur error: %v", body, err)
+	}
+	if request.PublishJobID != 3 || request.IdempotencyKey == "" {
+		t.Fatalf("unexpected callback body: %s", body)
+	}
+	if err := request.Verify("build"); err != nil {
+		t.Errorf("expect signed callback verified, got %v", err)
+	}
+
+	another, _ := callbackRequestBody(3)
+	if another == body {
+		t.Errorf("expect the idempotency key of each job unique")
+	}
+
+	forged := *request
+	forged.PublishJobID = 4
+	if err := forged.Verify("build"); err == nil {
+		t.Errorf("expect the signature of another job rejected")
+	}
+
+	legacy := &BuildStepCallbackReq{PublishJobID: 3}
+	if err := legacy.Verify("build"); err != nil {
+		t.Errorf("expect the unsigned callback accepted, got %v", err)
+	}
+	if legacy.IdempotencyKey != "job-3-build" {
+		t.Errorf("expect the unsigned callback keyed by job, got %v", legacy.IdempotencyKey)
+	}
+}
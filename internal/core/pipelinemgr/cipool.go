@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/workflow"
+)
+
+// ciServerRoute the jenkins which the ci job is routed to
+type ciServerRoute struct {
+	SettingID int64
+	Version   int
+	// Queue the length of the build queue of jenkins when routed
+	Queue int
+}
+
+// ParseCIServerPool decode the ci server pool stored in project env
+func ParseCIServerPool(config string) ([]int64, error) {
+	pool := []int64{}
+	if strings.TrimSpace(config) == "" {
+		return pool, nil
+	}
+	if err := json.Unmarshal([]byte(config), &pool); err != nil {
+		return nil, fmt.Errorf("CI 服务器池配置解析失败: %s", err.Error())
+	}
+	return pool, nil
+}
+
+// ciServerPool the ci server of env followed by the jenkins of the ci server pool
+func ciServerPool(env *models.ProjectEnv) []int64 {
+	servers := []int64{env.CIServer}
+	pool, err := ParseCIServerPool(env.CIServerPool)
+	if err != nil {
+		log.Log.Warn("the ci server pool of env: %v is ignored, error: %s", env.ID, err.Error())
+		return servers
+	}
+	for _, settingID := range pool {
+		exists := false
+		for _, server := range servers {
+			if server == settingID {
+				exists = true
+				break
+			}
+		}
+		if !exists && settingID != 0 {
+			servers = append(servers, settingID)
+		}
+	}
+	return servers
+}
+
+// routeCIServer return the healthy jenkins whose build queue is the shortest in the ci server pool of env,
+// the jenkins failed to ping is skipped
+func (pm *PipelineManager) routeCIServer(stageID int64) (*ciServerRoute, error) {
+	projectEnv, err := pm.modelProject.GetProjectEnvByID(stageID)
+	if err != nil {
+		return nil, err
+	}
+	servers := ciServerPool(projectEnv)
+	var route *ciServerRoute
+	errs := []string{}
+	for _, settingID := range servers {
+		setting, err := pm.settingsHandler.GetIntegrateSettingByID(settingID)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("ci server: %v, error: %s", settingID, err.Error()))
+			continue
+		}
+		CIInfo, err := pm.jenkinsCI(projectEnv, settingID, setting.CredentialVersion)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %s", setting.Name, err.Error()))
+			continue
+		}
+		// the queue length is useless when there is only one jenkins
+		queue, err := probeJenkins(CIInfo, len(servers) > 1)
+		if err != nil {
+			log.Log.Warn("jenkins: %v of env: %v is unhealthy, skip it, error: %s", setting.Name, stageID, err.Error())
+			errs = append(errs, fmt.Sprintf("%v: %s", setting.Name, err.Error()))
+			continue
+		}
+		if route == nil || queue < route.Queue {
+			route = &ciServerRoute{SettingID: settingID, Version: setting.CredentialVersion, Queue: queue}
+		}
+	}
+	if route == nil {
+		return nil, fmt.Errorf("jenkins is unhealthy, error: %s", strings.Join(errs, "; "))
+	}
+	if len(servers) > 1 {
+		log.Log.Info("ci job of env: %v is routed to jenkins: %v, queue length: %v", stageID, route.SettingID, route.Queue)
+	}
+	return route, nil
+}
+
+// jenkinsQueue the build queue of jenkins
+type jenkinsQueue struct {
+	Items []struct {
+		ID int64 `json:"id"`
+	} `json:"items"`
+}
+
+// probeJenkins ping the jenkins, and return the length of its build queue if required
+func probeJenkins(CIInfo *JenkinsCI, withQueue bool) (int, error) {
+	client, err := NewWorkFlowProvide(CIInfo.Driver, CIInfo.URL, CIInfo.User, CIInfo.Token, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := client.Ping(); err != nil {
+		return 0, err
+	}
+	// the queue length of the external driver is unknown
+	if !withQueue || CIInfo.Driver != workflow.DriverJenkins.String() {
+		return 0, nil
+	}
+	job := &jenkinsJob{url: strings.TrimSuffix(CIInfo.URL, "/"), user: CIInfo.User, token: CIInfo.Token}
+	body, err := job.request("GET", fmt.Sprintf("%v/queue/api/json?tree=items[id]", job.url), xmlContentType, nil)
+	if err != nil {
+		return 0, err
+	}
+	queue := jenkinsQueue{}
+	if err := json.Unmarshal(body, &queue); err != nil {
+		return 0, err
+	}
+	return len(queue.Items), nil
+}
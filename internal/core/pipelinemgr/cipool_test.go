@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestCIServerPool(t *testing.T) {
+	env := &models.ProjectEnv{CIServer: 1, CIServerPool: "[3,1,2,3,0]"}
+	if got, want := ciServerPool(env), []int64{1, 3, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ciServerPool() = %v, want %v", got, want)
+	}
+	// the invalid pool is ignored
+	env.CIServerPool = "3,2"
+	if got, want := ciServerPool(env), []int64{1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ciServerPool() = %v, want %v", got, want)
+	}
+}
+
+func TestProbeJenkins(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/crumbIssuer/api/json":
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("X-Jenkins", "2.332")
+			w.Write([]byte(`{"crumb":"crumb","crumbRequestField":"Jenkins-Crumb"}`))
+		case "/queue/api/json":
+			w.Write([]byte(`{"items":[{"id":1},{"id":2}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	CIInfo := &JenkinsCI{URL: server.URL + "/", User: "admin", Token: "token", Driver: "jenkins"}
+	if queue, err := probeJenkins(CIInfo, true); err != nil || queue != 2 {
+		t.Fatalf("probeJenkins() = %v, %v, want 2", queue, err)
+	}
+	if queue, err := probeJenkins(CIInfo, false); err != nil || queue != 0 {
+		t.Fatalf("probeJenkins() without queue = %v, %v, want 0", queue, err)
+	}
+	healthy = false
+	if _, err := probeJenkins(CIInfo, true); err == nil {
+		t.Fatalf("probe the unhealthy jenkins should fail")
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sync"
+)
+
+// commitCache the latest commit of app branch looked up in one publish job, every app image of the build
+// and deploy job is tagged by the same commit and the scm is requested once per branch
+type commitCache struct {
+	mu      sync.Mutex
+	entries map[string]*commitEntry
+}
+
+type commitEntry struct {
+	once sync.Once
+	tag  string
+	err  error
+}
+
+func newCommitCache() *commitCache {
+	return &commitCache{entries: map[string]*commitEntry{}}
+}
+
+// get return the cached result of app branch, the concurrent lookups of the same branch wait for the first one
+func (c *commitCache) get(appID int64, branch string, lookup func() (string, error)) (string, error) {
+	key := fmt.Sprintf("%v/%v", appID, branch)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &commitEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+	entry.once.Do(func() {
+		entry.tag, entry.err = lookup()
+	})
+	return entry.tag, entry.err
+}
+
+// withCommitCache return a copy of manager which caches the latest commit of app branches, used for one publish job
+func (pm *PipelineManager) withCommitCache() *PipelineManager {
+	scoped := *pm
+	scoped.commits = newCommitCache()
+	return &scoped
+}
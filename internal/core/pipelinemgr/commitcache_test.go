@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCommitCache(t *testing.T) {
+	cache := newCommitCache()
+	var lookups int32
+	lookup := func() (string, error) {
+		atomic.AddInt32(&lookups, 1)
+		return "master-abcdefg", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tag, err := cache.get(1, "master", lookup); err != nil || tag != "master-abcdefg" {
+				t.Errorf("unexpected tag: %v error: %v", tag, err)
+			}
+		}()
+	}
+	wg.Wait()
+	cache.get(1, "develop", lookup)
+	cache.get(2, "master", lookup)
+	if lookups != 3 {
+		t.Errorf("expected 3 lookups, got %v", lookups)
+	}
+}
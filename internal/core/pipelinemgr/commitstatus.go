@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/drone/go-scm/scm"
+)
+
+var (
+	commitStatusEnabled = beego.AppConfig.DefaultBool("commitstatus::enable", true)
+	// commitStatusWebURL the atomci web address which the commit status target url links to
+	commitStatusWebURL = beego.AppConfig.DefaultString("commitstatus::web_url", beego.AppConfig.String("atomci::url"))
+)
+
+// jobCommitState convert the publish job status to commit status state
+func jobCommitState(status string) scm.State {
+	switch status {
+	case models.StatusSuccess:
+		return scm.StateSuccess
+	case models.StatusFailure, models.StatusInitFailure:
+		return scm.StateFailure
+	case models.StatusAbort:
+		return scm.StateCanceled
+	case models.StatusUnknown:
+		return scm.StateUnknown
+	default:
+		return scm.StatePending
+	}
+}
+
+// ReportJobCommitStatus report the status of publish job to the commits of the job apps,
+// so the build/deploy result was shown on the merge requests directly.
+func (pm *PipelineManager) ReportJobCommitStatus(job *models.PublishJob) {
+	if !commitStatusEnabled {
+		return
+	}
+	jobApps, err := pm.modelPublishJob.GetPublishJobApps(job.ID)
+	if err != nil {
+		log.Log.Warn("when report commit status, get publish job: %v apps occur error: %s", job.ID, err.Error())
+		return
+	}
+	envName := fmt.Sprintf("%d", job.EnvID)
+	if envStage, err := pm.modelProject.GetProjectEnvByID(job.EnvID); err == nil {
+		envName = envStage.Name
+	}
+	state := jobCommitState(job.Status)
+	input := &scm.StatusInput{
+		State: state,
+		Label: fmt.Sprintf("atomci/%s/%s", envName, job.JobType),
+		Desc:  fmt.Sprintf("AtomCI %s %s", job.JobType, strings.ToLower(job.Status)),
+	}
+	if commitStatusWebURL != "" {
+		input.Target = fmt.Sprintf("%s/project/projectCIDetail/%d/%d", strings.TrimSuffix(commitStatusWebURL, "/"), job.ProjectID, job.PublishID)
+	}
+	for _, jobApp := range jobApps {
+		if err := pm.reportAppCommitStatus(jobApp, input); err != nil {
+			log.Log.Warn("report publish job: %v app: %v commit status occur error: %s", job.ID, jobApp.ProjectAPPID, err.Error())
+		}
+	}
+}
+
+// reportAppCommitStatus create commit status on the head commit of job app branch,
+// the commit was recorded at the first report so the final state goes to the same commit.
+func (pm *PipelineManager) reportAppCommitStatus(jobApp *models.PublishJobApp, input *scm.StatusInput) error {
+	client, scmApp, err := pm.getAppScmClient(jobApp.ProjectAPPID)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if jobApp.CommitSha == "" {
+		branch, _, err := client.Git.FindBranch(ctx, scmApp.FullName, jobApp.BranchName)
+		if err != nil {
+			return fmt.Errorf("get app: %v branch: %v occur error: %s", scmApp.Name, jobApp.BranchName, err.Error())
+		}
+		jobApp.CommitSha = branch.Sha
+		if err := pm.modelPublishJob.UpdatePublishJobApp(jobApp); err != nil {
+			log.Log.Warn("record publish job app: %v commit sha occur error: %s", jobApp.ID, err.Error())
+		}
+	}
+	if _, _, err := client.Repositories.CreateStatus(ctx, scmApp.FullName, jobApp.CommitSha, input); err != nil {
+		if err == scm.ErrNotSupported {
+			log.Log.Debug("scm of app: %v did not support commit status, skip", scmApp.Name)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
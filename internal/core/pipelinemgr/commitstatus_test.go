@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/drone/go-scm/scm"
+)
+
+func TestJobCommitState(t *testing.T) {
+	tests := map[string]scm.State{
+		models.StatusSuccess:     scm.StateSuccess,
+		models.StatusFailure:     scm.StateFailure,
+		models.StatusInitFailure: scm.StateFailure,
+		models.StatusAbort:       scm.StateCanceled,
+		models.StatusUnknown:     scm.StateUnknown,
+		models.StatusRunning:     scm.StatePending,
+		models.StatusInit:        scm.StatePending,
+	}
+	for status, want := range tests {
+		if got := jobCommitState(status); got != want {
+			t.Errorf("jobCommitState(%v) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestReportJobCommitStatus(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	head := "c1"
+	statuses := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != "scm-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/atomci/app/repository/branches/feature":
+			fmt.Fprintf(w, `{"name":"feature","commit":{"id":%q}}`, head)
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/v4/projects/atomci/app/statuses/"):
+			query := r.URL.Query()
+			statuses = append(statuses, fmt.Sprintf("%s %s %s %s", strings.TrimPrefix(r.URL.Path, "/api/v4/projects/atomci/app/statuses/"), query.Get("state"), query.Get("name"), query.Get("target_url")))
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repoID := newIntegrateSetting(t, "commit-status", "gitlab", map[string]string{"url": server.URL, "token": "scm-token"})
+	scmID := insertTestItem(t, &models.ScmApp{Addons: models.NewAddons(), Name: "app", FullName: "atomci/app", Path: server.URL + "/atomci/app.git", RepoID: repoID})
+	appID := insertTestItem(t, &models.ProjectApp{Addons: models.NewAddons(), ProjectID: 400, ScmID: scmID})
+	envID := insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 400, Name: "uat"})
+	job := &models.PublishJob{Addons: models.NewAddons(), ProjectID: 400, PublishID: 8, EnvID: envID, JobType: models.JobTypeBuild, Status: models.StatusRunning}
+	job.ID = insertTestItem(t, job)
+	jobApp := &models.PublishJobApp{Addons: models.NewAddons(), ProjectID: 400, PublishJobID: job.ID, ProjectAPPID: appID, BranchName: "feature"}
+	jobApp.ID = insertTestItem(t, jobApp)
+
+	origin := commitStatusWebURL
+	commitStatusWebURL = "http://atomci.example.com/"
+	defer func() { commitStatusWebURL = origin }()
+
+	pm.ReportJobCommitStatus(job)
+	// the final state goes to the commit recorded at the first report, though the branch has new commits
+	head = "c2"
+	job.Status = models.StatusSuccess
+	pm.ReportJobCommitStatus(job)
+
+	target := "http://atomci.example.com/project/projectCIDetail/400/8"
+	want := []string{
+		"c1 pending atomci/uat/build " + target,
+		"c1 success atomci/uat/build " + target,
+	}
+	if strings.Join(statuses, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commit statuses = %v, want %v", statuses, want)
+	}
+	if err := orm.NewOrm().Read(jobApp); err != nil || jobApp.CommitSha != "c1" {
+		t.Errorf("the commit of job app = %v, error: %v", jobApp.CommitSha, err)
+	}
+
+	// the disabled commit status is not reported
+	commitStatusEnabled = false
+	defer func() { commitStatusEnabled = true }()
+	pm.ReportJobCommitStatus(job)
+	if len(statuses) != 2 {
+		t.Errorf("the commit status should not be reported when disabled, statuses: %v", statuses)
+	}
+}
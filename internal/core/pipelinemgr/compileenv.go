@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/workflow/jenkins"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// newCompileEnvParam the compile container param of the compile env
+func newCompileEnvParam(name string, compileItem *models.CompileEnv) compileEnv {
+	if compileItem.Deprecated {
+		log.Log.Warn("compile env: %v of container: %v is deprecated, %v", compileItem.Name, name, compileItem.DeprecationMessage)
+	}
+	nodeSelector, tolerations, secrets, err := settings.CompileEnvScheduling(compileItem)
+	if err != nil {
+		log.Log.Warn("ignore the scheduling of container: %v, %v", name, err.Error())
+	}
+	return compileEnv{
+		Name:          name,
+		Version:       compileItem.Version,
+		Image:         compileItem.Image,
+		Args:          compileItem.Args,
+		Command:       compileItem.Command,
+		WorkingDir:    agentWorkingDir(compileItem.CompileEnvOS()),
+		CPURequest:    compileItem.CPURequest,
+		CPULimit:      compileItem.CPULimit,
+		MemoryRequest: compileItem.MemoryRequest,
+		MemoryLimit:   compileItem.MemoryLimit,
+
+		NodeSelector:     nodeSelector,
+		Tolerations:      tolerations,
+		ImagePullSecrets: secrets,
+		OSType:           compileItem.CompileEnvOS(),
+		Shell:            compileItem.CompileEnvShell(),
+		Arch:             compileItem.CompileEnvArchs(),
+	}
+}
+
+// stepResources the resources of build step, which override the resources of compile env field by field
+type stepResources struct {
+	CPURequest    string `json:"cpu_request,omitempty"`
+	CPULimit      string `json:"cpu_limit,omitempty"`
+	MemoryRequest string `json:"memory_request,omitempty"`
+	MemoryLimit   string `json:"memory_limit,omitempty"`
+}
+
+func (r *stepResources) validate() error {
+	if r == nil {
+		return nil
+	}
+	for _, value := range []string{r.CPURequest, r.CPULimit, r.MemoryRequest, r.MemoryLimit} {
+		if value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("无效的资源配置: %v", value)
+		}
+	}
+	return nil
+}
+
+// apply return the compile param with the resources of step
+func (r *stepResources) apply(item compileEnv) compileEnv {
+	if r == nil {
+		return item
+	}
+	for _, field := range []struct {
+		value  string
+		target *string
+	}{
+		{value: r.CPURequest, target: &item.CPURequest},
+		{value: r.CPULimit, target: &item.CPULimit},
+		{value: r.MemoryRequest, target: &item.MemoryRequest},
+		{value: r.MemoryLimit, target: &item.MemoryLimit},
+	} {
+		if field.value != "" {
+			*field.target = field.value
+		}
+	}
+	return item
+}
+
+// compileContainer the container template of the compile param,
+// the pod template of workflow has no resources, so they are appended after the working dir
+func compileContainer(item compileEnv) jenkins.ContainerEnv {
+	return jenkins.ContainerEnv{
+		Name:       item.Name,
+		Image:      item.Image,
+		CommandArr: commandAndArgSplit(item.Command),
+		ArgsArr:    commandAndArgSplit(item.Args),
+		WorkingDir: item.WorkingDir + compileContainerResources(item),
+	}
+}
+
+// compileContainerResources return the resources yaml of the container, which indented under the container
+func compileContainerResources(item compileEnv) string {
+	sections := []string{}
+	for _, section := range []struct {
+		name        string
+		cpu, memory string
+	}{
+		{name: "requests", cpu: item.CPURequest, memory: item.MemoryRequest},
+		{name: "limits", cpu: item.CPULimit, memory: item.MemoryLimit},
+	} {
+		if section.cpu == "" && section.memory == "" {
+			continue
+		}
+		lines := []string{fmt.Sprintf("      %v:", section.name)}
+		if section.cpu != "" {
+			lines = append(lines, fmt.Sprintf("        cpu: \"%v\"", section.cpu))
+		}
+		if section.memory != "" {
+			lines = append(lines, fmt.Sprintf("        memory: \"%v\"", section.memory))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if len(sections) == 0 {
+		return ""
+	}
+	return "\n    resources:\n" + strings.Join(sections, "\n")
+}
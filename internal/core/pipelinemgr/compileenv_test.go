@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestCompileContainerResources(t *testing.T) {
+	container := compileContainer(newCompileEnvParam("demo", &models.CompileEnv{
+		Name:          "maven",
+		Image:         "maven:3.8.2-openjdk-8",
+		Command:       "/bin/sh -c",
+		Args:          "cat",
+		CPURequest:    "500m",
+		MemoryRequest: "1Gi",
+		MemoryLimit:   "2Gi",
+	}))
+	want := "/home/jenkins/agent\n    resources:\n      requests:\n        cpu: \"500m\"\n        memory: \"1Gi\"\n      limits:\n        memory: \"2Gi\""
+	if container.WorkingDir != want {
+		t.Fatalf("working dir = %q, want %q", container.WorkingDir, want)
+	}
+
+	container = compileContainer(newCompileEnvParam("demo", &models.CompileEnv{Name: "node", Image: "node:12.12-alpine"}))
+	if container.WorkingDir != "/home/jenkins/agent" {
+		t.Fatalf("working dir without resources = %q", container.WorkingDir)
+	}
+}
+
+func TestStepResourcesApply(t *testing.T) {
+	item := compileEnv{Name: "demo", CPURequest: "500m", CPULimit: "1", MemoryLimit: "2Gi"}
+	var resources *stepResources
+	if got := resources.apply(item); !reflect.DeepEqual(got, item) {
+		t.Fatalf("nil step resources changed the param: %+v", got)
+	}
+
+	resources = &stepResources{CPULimit: "2", MemoryRequest: "1Gi"}
+	want := compileEnv{Name: "demo", CPURequest: "500m", CPULimit: "2", MemoryRequest: "1Gi", MemoryLimit: "2Gi"}
+	if got := resources.apply(item); !reflect.DeepEqual(got, want) {
+		t.Fatalf("apply step resources = %+v, want %+v", got, want)
+	}
+	if err := (&stepResources{CPULimit: "two"}).validate(); err == nil {
+		t.Fatalf("invalid step resources should fail")
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/registry"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	// cosignImage the dev image of cosign contains the shell which jenkins container step requires
+	cosignImage = beego.AppConfig.DefaultString("cosign::image", "gcr.io/projectsigstore/cosign:v1.13.1-dev")
+)
+
+const cosignContainerName = "cosign"
+
+// signTask the images are signed by the private key of the cosign integrate setting
+type signTask struct {
+	Cosign int64 `json:"cosign,omitempty"`
+}
+
+// validateSignTask the images built by the previous build-image sub task of build step are signed
+func validateSignTask(stepType string, tasks []*subTask, position int) error {
+	if stepType != models.StepBuild {
+		return fmt.Errorf("%v 子任务只能用于构建任务节点", constant.StepSubTaskSign)
+	}
+	if tasks[position].Sign == nil || tasks[position].Sign.Cosign == 0 {
+		return fmt.Errorf("%v 子任务须选择 cosign 集成配置", constant.StepSubTaskSign)
+	}
+	for _, task := range tasks[:position] {
+		if task.Type == constant.StepSubTaskBuildImage {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v 子任务须在 %v 子任务之后执行", constant.StepSubTaskSign, constant.StepSubTaskBuildImage)
+}
+
+// cosignContainer the container of cosign, which pushes the signatures to the registry of images
+func cosignContainer() jenkins.ContainerEnv {
+	return jenkins.ContainerEnv{
+		Name:       cosignContainerName,
+		Image:      cosignImage,
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	}
+}
+
+// cosignEnvVars the private key of cosign setting is passed to job by env, which is read by `--key env://`
+func (pm *PipelineManager) cosignEnvVars(settingID int64) ([]jenkins.EnvItem, error) {
+	cosignConf, err := pm.settingsHandler.GetCosignIntegrateSettingByID(settingID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(cosignConf.PrivateKey) == "" {
+		return nil, fmt.Errorf("cosign 集成配置 %v 未配置私钥, 无法签名镜像", settingID)
+	}
+	return []jenkins.EnvItem{
+		{Key: "COSIGN_PRIVATE_KEY", Value: cosignConf.PrivateKey},
+		{Key: "COSIGN_PASSWORD", Value: cosignConf.Password},
+	}, nil
+}
+
+// signCommand sign the image by tag, cosign resolves the tag to digest and pushes the signature as `sha256-<digest>.sig`
+func signCommand(image string, insecure bool) string {
+	flags := ""
+	if insecure {
+		flags = "--allow-insecure-registry "
+	}
+	return fmt.Sprintf(`sh 'cosign sign --key env://COSIGN_PRIVATE_KEY %s%s'`, flags, image)
+}
+
+// renderSignStageForBuild sign the images built by the job, the failure of sign breaks the build
+func (pm *PipelineManager) renderSignStageForBuild(stageID int64, allParms []*RunBuildAllParms, deployTarget *DeployTarget) (string, error) {
+	imageURLs, err := pm.buildImageAddrs(stageID, allParms)
+	if err != nil {
+		return "", err
+	}
+	commands := []string{
+		fmt.Sprintf("container('%s') {", cosignContainerName),
+		`sh 'mkdir -p $DOCKER_CONFIG'`,
+		`sh """
+        echo '{"auths": {"'$REGISTRY_ADDR'": {"auth": "'$DOCKER_AUTH'"}}}' > $DOCKER_CONFIG/config.json
+        """`,
+	}
+	for _, image := range imageURLs {
+		if image != "" {
+			commands = append(commands, signCommand(image, !deployTarget.RegistryHTTPS))
+		}
+	}
+	commands = append(commands, "}")
+	item := jenkins.StepItem{
+		Name:    "'Sign'",
+		Command: strings.Join(commands, "\n"),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
+
+// signatureVerifier verify the signatures of the images deployed to env by the public key of env cosign setting
+type signatureVerifier struct {
+	env       *models.ProjectEnv
+	publicKey crypto.PublicKey
+	provider  registry.Provider
+	host      string
+}
+
+// newSignatureVerifier return nil when the env does not require signature
+func (pm *PipelineManager) newSignatureVerifier(env *models.ProjectEnv) (*signatureVerifier, error) {
+	if env.Cosign == 0 {
+		return nil, nil
+	}
+	cosignConf, err := pm.settingsHandler.GetCosignIntegrateSettingByID(env.Cosign)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := registry.ParsePublicKey(cosignConf.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("环境 %v 的 cosign 公钥无效: %s", env.Name, err.Error())
+	}
+	registryConf, err := pm.getEnvRegistryConfig(env.ID)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := registryConf.Provider()
+	if err != nil {
+		return nil, err
+	}
+	return &signatureVerifier{env: env, publicKey: publicKey, provider: provider, host: registryHost(registryConf.URL)}, nil
+}
+
+// verify the image signature, the failure is only logged when the policy of env is warn
+func (v *signatureVerifier) verify(image string) error {
+	err := v.verifyImage(image)
+	if err == nil {
+		log.Log.Debug("env: %v verified the signature of image: %v", v.env.Name, image)
+		return nil
+	}
+	if v.env.SignaturePolicy == models.SignaturePolicyWarn {
+		log.Log.Warn("env: %v deploy image: %v with invalid signature: %s", v.env.Name, image, err.Error())
+		return nil
+	}
+	return fmt.Errorf("镜像 %v 签名验证失败: %s", image, err.Error())
+}
+
+func (v *signatureVerifier) verifyImage(image string) error {
+	digest := ""
+	if index := strings.Index(image, "@"); index != -1 {
+		image, digest = image[:index], image[index+1:]
+	}
+	host, repo, tag := splitImage(image)
+	if strings.ToLower(host) != v.host {
+		// the signatures are only fetched from the registry of env
+		return fmt.Errorf("镜像不在环境的镜像仓库 %v 中", v.host)
+	}
+	if digest == "" {
+		var err error
+		if digest, err = v.provider.ManifestDigest(repo, tag); err != nil {
+			return err
+		}
+	}
+	signatures, err := v.provider.Signatures(repo, digest)
+	if err != nil {
+		return err
+	}
+	return registry.VerifySignatures(v.publicKey, digest, signatures)
+}
+
+// verifyDeployImages verify the signatures of the images before apply the arranges of apps to env
+func (pm *PipelineManager) verifyDeployImages(env *models.ProjectEnv, publishID int64, apps []*RunDeployAppReq) error {
+	verifier, err := pm.newSignatureVerifier(env)
+	if err != nil || verifier == nil {
+		return err
+	}
+	for _, app := range apps {
+		arrange, err := pm.appHandler.GetRealArrange(app.ProjectAppID, env.ID)
+		if err != nil {
+			return fmt.Errorf("获取应用: %v 环境: %v 编排失败: %s", app.ProjectAppID, env.ID, err.Error())
+		}
+		publishApp, err := pm.modelPublish.GetPublishAppByPublishIDAndAppID(publishID, app.ProjectAppID)
+		if err != nil {
+			return err
+		}
+		image, _, err := pm.deployImageAddr(app, arrange.ID, publishApp)
+		if err != nil {
+			return fmt.Errorf("生成应用: %v 部署镜像失败: %s", app.ProjectAppID, err.Error())
+		}
+		if err := verifier.verify(image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestValidateSignTask(t *testing.T) {
+	tasks := []*subTask{
+		{Type: constant.StepSubTaskSign, Sign: &signTask{Cosign: 1}},
+		{Type: constant.StepSubTaskBuildImage},
+		{Type: constant.StepSubTaskSign, Sign: &signTask{Cosign: 1}},
+		{Type: constant.StepSubTaskSign},
+	}
+	if err := validateSignTask(models.StepBuild, tasks, 0); err == nil {
+		t.Fatalf("sign before build-image expect error")
+	}
+	if err := validateSignTask(models.StepBuild, tasks, 2); err != nil {
+		t.Fatalf("sign after build-image error: %v", err)
+	}
+	if err := validateSignTask(models.StepBuild, tasks, 3); err == nil {
+		t.Fatalf("sign without cosign setting expect error")
+	}
+	if err := validateSignTask(models.StepDeploy, tasks, 2); err == nil {
+		t.Fatalf("sign of deploy step expect error")
+	}
+}
+
+func TestSignCommand(t *testing.T) {
+	if got, want := signCommand("harbor.example.com/dev/app:v1", false), `sh 'cosign sign --key env://COSIGN_PRIVATE_KEY harbor.example.com/dev/app:v1'`; got != want {
+		t.Fatalf("signCommand() = %v, want %v", got, want)
+	}
+	if got, want := signCommand("10.0.0.8:5000/dev/app:v1", true), `sh 'cosign sign --key env://COSIGN_PRIVATE_KEY --allow-insecure-registry 10.0.0.8:5000/dev/app:v1'`; got != want {
+		t.Fatalf("signCommand() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// the operation log step label of back-to/next-stage, steps of the stage restart after it
+var stageRestartLabels = map[string]bool{"back-to": true, "next-stage": true}
+
+// Dependencies return the dependencies of each step index,
+// the step without depends_on depends on the previous step, so the linear pipeline keep compatible
+func (p PipelineSteps) Dependencies() map[int][]int {
+	sorted := make(PipelineSteps, len(p))
+	copy(sorted, p)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+
+	deps := map[int][]int{}
+	for i, step := range sorted {
+		switch {
+		case step.DependsOn != nil:
+			deps[step.Index] = step.DependsOn
+		case i > 0:
+			deps[step.Index] = []int{sorted[i-1].Index}
+		default:
+			deps[step.Index] = []int{}
+		}
+	}
+	return deps
+}
+
+// Validate verify the steps dependencies is a DAG
+func (p PipelineSteps) Validate() error {
+	deps := p.Dependencies()
+	if len(deps) != len(p) {
+		return fmt.Errorf("任务节点序号重复，请检查后重试")
+	}
+	inDegree := map[int]int{}
+	children := map[int][]int{}
+	for index, depends := range deps {
+		inDegree[index] += 0
+		for _, dep := range depends {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("任务节点 %v 依赖的节点 %v 不存在", index, dep)
+			}
+			if dep == index {
+				return fmt.Errorf("任务节点 %v 不能依赖自身", index)
+			}
+			inDegree[index]++
+			children[dep] = append(children[dep], index)
+		}
+	}
+	queue := []int{}
+	for index, degree := range inDegree {
+		if degree == 0 {
+			queue = append(queue, index)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		index := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, child := range children[index] {
+			inDegree[child]--
+			if inDegree[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+	if visited != len(deps) {
+		return fmt.Errorf("任务节点之间存在循环依赖，请检查后重试")
+	}
+	return nil
+}
+
+// ReadySteps return the indexes of steps which dependencies all success and itself did not start
+func (p PipelineSteps) ReadySteps(states map[int]int64) []int {
+	ready := []int{}
+	for index, depends := range p.Dependencies() {
+		if status, ok := states[index]; ok && (status == models.Success || status == models.Running) {
+			continue
+		}
+		satisfied := true
+		for _, dep := range depends {
+			if states[dep] != models.Success {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			ready = append(ready, index)
+		}
+	}
+	sort.Ints(ready)
+	return ready
+}
+
+// Validate verify all stages steps
+func (config PipelineConfig) Validate() error {
+	for _, stage := range config {
+		if err := stage.Steps.Validate(); err != nil {
+			return fmt.Errorf("阶段 %v: %s", stage.Name, err.Error())
+		}
+		if err := stage.Steps.validateSubTasks(); err != nil {
+			return fmt.Errorf("阶段 %v: %s", stage.Name, err.Error())
+		}
+		if err := stage.Policy.Validate(); err != nil {
+			return fmt.Errorf("阶段 %v: %s", stage.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// GetStageStepStates return the latest status of each step in the pipeline instance stage
+func (pm *PipelineManager) GetStageStepStates(instanceID, stageID int64) (map[int]int64, error) {
+	operationLogs, err := pm.modelPublish.GetOperationLogsByInstanceIDAndStageID(instanceID, stageID)
+	if err != nil {
+		log.Log.Error("get instance id: %v stage id: %v operation logs occur error: %s", instanceID, stageID, err.Error())
+		return nil, err
+	}
+	states := map[int]int64{}
+	for _, item := range operationLogs {
+		if stageRestartLabels[item.Step] {
+			states = map[int]int64{}
+			continue
+		}
+		if item.Status == models.Skipped {
+			continue
+		}
+		states[item.StepIndex] = item.Status
+	}
+	return states, nil
+}
+
+// GetReadyStepIndexes regard the step index as success, return all the ready steps which have not been started,
+// it is empty when other parallel steps are still running, allDone is true when all the steps of stage success
+func (pm *PipelineManager) GetReadyStepIndexes(publishID, stageID int64, stepIndex int) ([]int, bool, error) {
+	publishItem, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return nil, false, err
+	}
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return nil, false, err
+	}
+	states, err := pm.GetStageStepStates(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return nil, false, err
+	}
+	states[stepIndex] = models.Success
+
+	allDone := true
+	for _, step := range stageJSON.Steps {
+		if states[step.Index] != models.Success {
+			allDone = false
+			break
+		}
+	}
+	// the failed or queued steps are retried by user, only the steps not started yet are ready to continue
+	ready := []int{}
+	for _, index := range stageJSON.Steps.ReadySteps(states) {
+		if _, ok := states[index]; !ok {
+			ready = append(ready, index)
+		}
+	}
+	return ready, allDone, nil
+}
+
+// SwitchPublishStep switch the publish current step to another ready step, so parallel steps could be triggered
+func (pm *PipelineManager) SwitchPublishStep(publishID, stageID int64, stepIndex int) error {
+	publishItem, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return err
+	}
+	if publishItem.StepIndex == stepIndex {
+		return nil
+	}
+	if publishItem.StageID != stageID {
+		return fmt.Errorf("流水线当前阶段不是 %v，操作拒绝", stageID)
+	}
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return err
+	}
+	states, err := pm.GetStageStepStates(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return err
+	}
+	ready := false
+	for _, index := range stageJSON.Steps.ReadySteps(states) {
+		if index == stepIndex {
+			ready = true
+			break
+		}
+	}
+	if !ready {
+		return fmt.Errorf("任务节点 %v 的依赖未完成或已在执行中，操作拒绝", stepIndex)
+	}
+	return pm.focusPublishStep(publishItem, stepIndex, models.Pending)
+}
+
+func (pm *PipelineManager) focusPublishStep(publishItem *models.Publish, stepIndex int, status int64) error {
+	stepType, stepName, err := pm.GetNextStepType(publishItem.ID, stepIndex)
+	if err != nil {
+		return err
+	}
+	log.Log.Debug("publish: %v switch step from %v to %v", publishItem.ID, publishItem.StepIndex, stepIndex)
+	publishItem.StepIndex = stepIndex
+	publishItem.StepType = stepType
+	publishItem.Step = stepName
+	publishItem.Status = status
+	return pm.modelPublish.UpdatePublish(publishItem)
+}
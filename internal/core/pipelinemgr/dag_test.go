@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestPipelineStepsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   string
+		wantErr bool
+	}{
+		{name: "linear", steps: `[{"index":1},{"index":2},{"index":3}]`},
+		{name: "fan-out fan-in", steps: `[{"index":1},{"index":2,"depends_on":[1]},{"index":3,"depends_on":[1]},{"index":4,"depends_on":[2,3]}]`},
+		{name: "unknown dependency", steps: `[{"index":1},{"index":2,"depends_on":[5]}]`, wantErr: true},
+		{name: "self dependency", steps: `[{"index":1,"depends_on":[1]}]`, wantErr: true},
+		{name: "cycle", steps: `[{"index":1,"depends_on":[2]},{"index":2,"depends_on":[1]}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := PipelineSteps{}.Struct(tt.steps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := steps.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPipelineStepsReadySteps(t *testing.T) {
+	steps, _ := PipelineSteps{}.Struct(`[{"index":1},{"index":2,"depends_on":[1]},{"index":3,"depends_on":[1]},{"index":4,"depends_on":[2,3]}]`)
+	tests := []struct {
+		name   string
+		states map[int]int64
+		want   []int
+	}{
+		{name: "start", states: map[int]int64{}, want: []int{1}},
+		{name: "fan-out", states: map[int]int64{1: models.Success}, want: []int{2, 3}},
+		{name: "one branch running", states: map[int]int64{1: models.Success, 2: models.Running}, want: []int{3}},
+		{name: "waiting fan-in", states: map[int]int64{1: models.Success, 2: models.Success, 3: models.Running}, want: []int{}},
+		{name: "fan-in", states: map[int]int64{1: models.Success, 2: models.Success, 3: models.Success}, want: []int{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := steps.ReadySteps(tt.states); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadySteps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineConfigValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "no policy", config: `[{"name":"dev","steps":[{"index":1}]}]`},
+		{name: "auto promote and rollback", config: `[{"name":"dev","steps":[{"index":1}],"policy":{"auto_promote":true,"on_failure":"rollback"}}]`},
+		{name: "unknown on failure", config: `[{"name":"dev","steps":[{"index":1}],"policy":{"on_failure":"retry"}}]`, wantErr: true},
+		{name: "sla override", config: `[{"name":"dev","steps":[{"index":1}],"policy":{"sla_warn_hours":4,"sla_escalate_hours":8}}]`},
+		{name: "sla escalate before warn", config: `[{"name":"dev","steps":[{"index":1}],"policy":{"sla_warn_hours":8,"sla_escalate_hours":4}}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := PipelineConfig{}.Struct(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestParallelStepsJoin the two ready branches run together, the join step waits for both of them
+func TestParallelStepsJoin(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+
+	stageID := insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 1, Name: "dev"})
+	stepIDs := []int64{}
+	for _, name := range []string{"build", "unit-test", "scan", "deploy"} {
+		stepIDs = append(stepIDs, insertTestItem(t, &models.TaskTmpl{Addons: models.NewAddons(), Name: name, Type: name}))
+	}
+	config := fmt.Sprintf(`[{"stage_id":%d,"steps":[{"index":1,"step_id":%d},{"index":2,"step_id":%d,"depends_on":[1]},{"index":3,"step_id":%d,"depends_on":[1]},{"index":4,"step_id":%d,"depends_on":[2,3]}]}]`,
+		stageID, stepIDs[0], stepIDs[1], stepIDs[2], stepIDs[3])
+	instanceID := insertTestItem(t, &models.PipelineInstance{Addons: models.NewAddons(), Config: config})
+	publishID := insertTestItem(t, &models.Publish{Addons: models.NewAddons(), StageID: stageID, StepIndex: 1, Status: models.Running, LastPipelineInstanceID: instanceID})
+	stepLog := func(stepIndex int, status int64) {
+		insertTestItem(t, &models.PublishOperationLog{Addons: models.NewAddons(), PublishID: publishID, PipelineInstanceID: instanceID, StageID: stageID, StepIndex: stepIndex, Status: status})
+	}
+	nextStep := func(stepIndex int, want []int, wantDone bool) {
+		t.Helper()
+		if ready, allDone, err := pm.GetReadyStepIndexes(publishID, stageID, stepIndex); err != nil || !reflect.DeepEqual(ready, want) || allDone != wantDone {
+			t.Fatalf("GetReadyStepIndexes(%v) = %v, %v, %v, want %v, %v", stepIndex, ready, allDone, err, want, wantDone)
+		}
+	}
+	currentStep := func(want int) {
+		t.Helper()
+		publishItem, err := pm.modelPublish.GetPublishByID(publishID)
+		if err != nil || publishItem.StepIndex != want {
+			t.Fatalf("publish current step = %v, %v, want %v", publishItem.StepIndex, err, want)
+		}
+	}
+
+	// the step 1 success, the branches 2 and 3 are ready
+	stepLog(1, models.Success)
+	nextStep(1, []int{2, 3}, false)
+	if err := pm.SwitchPublishStep(publishID, stageID, 2); err != nil {
+		t.Fatalf("switch to step 2 error: %v", err)
+	}
+	stepLog(2, models.Running)
+	if err := pm.SwitchPublishStep(publishID, stageID, 4); err == nil {
+		t.Fatalf("the join step should not run before the branches success")
+	}
+	// the branch 3 runs while the branch 2 is running
+	if err := pm.SwitchPublishStep(publishID, stageID, 3); err != nil {
+		t.Fatalf("switch to step 3 while step 2 running error: %v", err)
+	}
+	currentStep(3)
+	stepLog(3, models.Running)
+	if err := pm.SwitchPublishStep(publishID, stageID, 2); err == nil {
+		t.Fatalf("the running step should not run again")
+	}
+
+	// the branch 2 success first, the join waits for the branch 3
+	nextStep(2, []int{}, false)
+	stepLog(2, models.Success)
+	if err := pm.SwitchPublishStep(publishID, stageID, 4); err == nil {
+		t.Fatalf("the join step should wait for the branch 3")
+	}
+
+	// the branch 3 success, the join is ready
+	nextStep(3, []int{4}, false)
+	stepLog(3, models.Success)
+	if err := pm.SwitchPublishStep(publishID, stageID, 4); err != nil {
+		t.Fatalf("switch to the join step error: %v", err)
+	}
+	currentStep(4)
+	nextStep(4, []int{}, true)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+	_ "modernc.org/sqlite"
+)
+
+var initTestDBOnce sync.Once
+
+// initTestDB the orm can only be initialized once, the tests share the sqlite database,
+// which outlives the temp dir of the first test
+func initTestDB(t *testing.T) {
+	initTestDBOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "atomci")
+		if err != nil {
+			t.Fatalf("create temp dir error: %v", err)
+		}
+		// the concurrent writers wait for the busy database
+		models.InitSQLite(filepath.Join(dir, "atomci.db") + "?_pragma=busy_timeout(5000)")
+	})
+}
+
+// insertTestItem insert the item into the sqlite database, return its id
+func insertTestItem(t *testing.T, item interface{}) int64 {
+	id, err := orm.NewOrm().Insert(item)
+	if err != nil {
+		t.Fatalf("insert %T error: %v", item, err)
+	}
+	return id
+}
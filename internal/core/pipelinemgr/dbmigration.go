@@ -0,0 +1,527 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	appmgr "github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	dbMigrationImages = map[string]string{
+		appmgr.DBMigrationFlyway:    beego.AppConfig.DefaultString("dbmigration::flyway_image", "flyway/flyway:9.16"),
+		appmgr.DBMigrationLiquibase: beego.AppConfig.DefaultString("dbmigration::liquibase_image", "liquibase/liquibase:4.20"),
+		appmgr.DBMigrationMigrate:   beego.AppConfig.DefaultString("dbmigration::migrate_image", "migrate/migrate:v4.15.2"),
+	}
+)
+
+// dbMigrationURLVar the env var which the jenkins credential of database url bound to
+const dbMigrationURLVar = "ATOMCI_DB_URL"
+
+// dbRollbackStep the step name of the job which runs the down migrations, it is used by the callback token of the job
+const dbRollbackStep = "db-rollback"
+
+// the phases of db migration reported by the jobs
+const (
+	DBMigrationPhaseBefore   = "before"
+	DBMigrationPhaseAfter    = "after"
+	DBMigrationPhaseRollback = "rollback"
+)
+
+var (
+	migrateVersionPattern = regexp.MustCompile(`(?m)^\s*([0-9]+)( \(dirty\))?\s*$`)
+	flywayVersionPattern  = regexp.MustCompile(`^[0-9][0-9._]*$`)
+	liquibaseTagPattern   = regexp.MustCompile(`(?m)^\s*(atomci-[0-9]+-[0-9]+(-base)?)\s*$`)
+)
+
+// validateBeforeDeploy the deploy steps of stage must depend on the step, so the migration is executed before deploy
+func (p PipelineSteps) validateBeforeDeploy(index int) error {
+	deps := p.Dependencies()
+	for _, step := range p {
+		if step.Type != models.StepDeploy {
+			continue
+		}
+		if !dependsOn(deps, step.Index, index, map[int]bool{}) {
+			return fmt.Errorf("数据库迁移须在部署之前执行，部署节点 %v 须依赖此节点", step.Name)
+		}
+	}
+	return nil
+}
+
+// dependsOn whether the step depends on the target directly or indirectly
+func dependsOn(deps map[int][]int, index, target int, visited map[int]bool) bool {
+	for _, dep := range deps[index] {
+		if dep == target {
+			return true
+		}
+		if visited[dep] {
+			continue
+		}
+		visited[dep] = true
+		if dependsOn(deps, dep, target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// dbMigrationCLI return the command prefix of tool, it runs in the dir of migrations
+func dbMigrationCLI(migration *appmgr.DBMigration) string {
+	switch migration.Tool {
+	case appmgr.DBMigrationFlyway:
+		return fmt.Sprintf(`flyway -url="$%s" -locations=filesystem:.`, dbMigrationURLVar)
+	case appmgr.DBMigrationLiquibase:
+		changeLog := migration.ChangeLogFile
+		if changeLog == "" {
+			changeLog = "changelog.xml"
+		}
+		return fmt.Sprintf(`liquibase --url="$%s" --changelog-file=%s`, dbMigrationURLVar, changeLog)
+	default:
+		return fmt.Sprintf(`migrate -path . -database "$%s"`, dbMigrationURLVar)
+	}
+}
+
+// dbMigrationVersionCommand print the current version of database,
+// liquibase has no version, the database is tagged and the tag is the version
+func dbMigrationVersionCommand(migration *appmgr.DBMigration, tag string) string {
+	cli := dbMigrationCLI(migration)
+	switch migration.Tool {
+	case appmgr.DBMigrationFlyway:
+		return cli + " info -outputType=json 2>/dev/null"
+	case appmgr.DBMigrationLiquibase:
+		return fmt.Sprintf("%s tag %s >/dev/null 2>&1 && echo %s", cli, tag, tag)
+	default:
+		return cli + " version 2>&1"
+	}
+}
+
+// dbMigrationUpCommand apply all pending migrations
+func dbMigrationUpCommand(migration *appmgr.DBMigration) string {
+	cli := dbMigrationCLI(migration)
+	switch migration.Tool {
+	case appmgr.DBMigrationFlyway:
+		return cli + " migrate"
+	case appmgr.DBMigrationLiquibase:
+		return cli + " update"
+	default:
+		return cli + " up"
+	}
+}
+
+// dbMigrationDownCommand migrate down to the target version, flyway undo requires the edition supports it
+func dbMigrationDownCommand(migration *appmgr.DBMigration, target string) string {
+	cli := dbMigrationCLI(migration)
+	switch migration.Tool {
+	case appmgr.DBMigrationFlyway:
+		return fmt.Sprintf("%s undo -target=%s", cli, target)
+	case appmgr.DBMigrationLiquibase:
+		return fmt.Sprintf("%s rollback --tag=%s", cli, target)
+	default:
+		return fmt.Sprintf("%s goto %s", cli, target)
+	}
+}
+
+// parseDBMigrationVersion parse the output of version command, empty means the version is unknown
+func parseDBMigrationVersion(tool, output string) string {
+	switch tool {
+	case appmgr.DBMigrationFlyway:
+		// the warnings may be printed before the json
+		if start := strings.Index(output, "{"); start >= 0 {
+			output = output[start:]
+		}
+		info := struct {
+			SchemaVersion string `json:"schemaVersion"`
+		}{}
+		if err := json.Unmarshal([]byte(output), &info); err == nil && flywayVersionPattern.MatchString(info.SchemaVersion) {
+			return info.SchemaVersion
+		}
+	case appmgr.DBMigrationLiquibase:
+		if match := liquibaseTagPattern.FindStringSubmatch(output); match != nil {
+			return match[1]
+		}
+	case appmgr.DBMigrationMigrate:
+		if match := migrateVersionPattern.FindStringSubmatch(output); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// dbMigrationWorkDir the migrations dir is relative to the build path of app
+func dbMigrationWorkDir(repoPath, buildPath, dir string) string {
+	paths := []string{repoPath}
+	for _, path := range []string{appmgr.NormalizeRepoPath(buildPath), appmgr.NormalizeRepoPath(dir)} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return strings.Join(paths, "/")
+}
+
+// dbMigrationReportCommands run the version command, then report the output of it
+func dbMigrationReportCommands(workDir, command, reportURL string) []string {
+	output := fmt.Sprintf("%s/.atomci_db_version", workDir)
+	return []string{
+		fmt.Sprintf(`sh 'cd %s && (%s) > %s || true'`, workDir, command, output),
+		fmt.Sprintf(
+			`httpRequest acceptType: 'APPLICATION_JSON', contentType: 'TEXT_PLAIN', customHeaders: [[maskValue: true, name: 'Authorization', value: "Bearer ${env.ACCESS_TOKEN}"]], httpMode: 'POST', requestBody: readFile('%s'), responseHandle: 'NONE', timeout: 30, url: '%s'`,
+			output, reportURL),
+	}
+}
+
+// dbMigrationStep wrap the commands with the container of runner and the database url credential
+func dbMigrationStep(name, container, credentialID string, commands []string) (string, error) {
+	item := jenkins.StepItem{
+		Name: fmt.Sprintf("'%s'", name),
+		Command: fmt.Sprintf("container('%s') {\nwithCredentials([string(credentialsId: '%s', variable: '%s')]) {\n%s\n}\n}",
+			container, credentialID, dbMigrationURLVar, strings.Join(commands, "\n")),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
+
+// dbMigrationContainers the runner containers keyed by image, the apps use the same image share the container
+type dbMigrationContainers struct {
+	names      map[string]string
+	containers []jenkins.ContainerEnv
+}
+
+func (c *dbMigrationContainers) container(migration *appmgr.DBMigration) string {
+	image := migration.Image
+	if image == "" {
+		image = dbMigrationImages[migration.Tool]
+	}
+	if name, ok := c.names[image]; ok {
+		return name
+	}
+	if c.names == nil {
+		c.names = map[string]string{}
+	}
+	name := fmt.Sprintf("db-migration-%d", len(c.containers))
+	c.names[image] = name
+	c.containers = append(c.containers, jenkins.ContainerEnv{
+		Name:       name,
+		Image:      image,
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	})
+	return name
+}
+
+// dbMigrationCredentialID the {env} of credential id is replaced by the arrange env of stage
+func (pm *PipelineManager) dbMigrationCredentialID(stageID int64, credentialID string) (string, error) {
+	if !strings.Contains(credentialID, "{env}") {
+		return credentialID, nil
+	}
+	envStage, err := pm.modelProject.GetProjectEnvByID(stageID)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(credentialID, "{env}", envStage.ArrangeEnv, -1), nil
+}
+
+func dbMigrationReportURL(projectID, publishID, stageID, publishJobID, projectAppID int64, step, phase string) string {
+	query := url.Values{}
+	query.Set("publish_job_id", fmt.Sprint(publishJobID))
+	query.Set("project_app_id", fmt.Sprint(projectAppID))
+	query.Set("phase", phase)
+	return fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/db-migration?%s", atomciServer, projectID, publishID, stageID, step, query.Encode())
+}
+
+// renderDBMigrationStageForBuild migrate the database of the apps configured db migration,
+// the versions before and after migration are reported to atomci
+func (pm *PipelineManager) renderDBMigrationStageForBuild(projectID, publishID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI) (string, []jenkins.ContainerEnv, error) {
+	containers := &dbMigrationContainers{}
+	stages := []string{}
+	for _, app := range allParms {
+		migration, err := appmgr.ParseDBMigration(app.ScmApp.DBMigration)
+		if err != nil {
+			return "", nil, fmt.Errorf("应用 %v: %s", app.Name, err.Error())
+		}
+		if migration == nil {
+			log.Log.Debug("app: %v did not configure db migration, skip", app.Name)
+			continue
+		}
+		if err := migration.Validate(); err != nil {
+			return "", nil, fmt.Errorf("应用 %v: %s", app.Name, err.Error())
+		}
+		credentialID, err := pm.dbMigrationCredentialID(stageID, migration.CredentialID)
+		if err != nil {
+			return "", nil, err
+		}
+		workDir := dbMigrationWorkDir(pm.generateAppRepoPth(stageID, projectID, ciConfig.Workspace, app), app.BuildPath, migration.Dir)
+		tag := fmt.Sprintf("atomci-%d-%d", publishJobID, app.ProjectAppID)
+		commands := dbMigrationReportCommands(workDir, dbMigrationVersionCommand(migration, tag+"-base"),
+			dbMigrationReportURL(projectID, publishID, stageID, publishJobID, app.ProjectAppID, constant.StepBuild, DBMigrationPhaseBefore))
+		commands = append(commands, fmt.Sprintf(`sh 'cd %s && %s'`, workDir, dbMigrationUpCommand(migration)))
+		commands = append(commands, dbMigrationReportCommands(workDir, dbMigrationVersionCommand(migration, tag),
+			dbMigrationReportURL(projectID, publishID, stageID, publishJobID, app.ProjectAppID, constant.StepBuild, DBMigrationPhaseAfter))...)
+		stage, err := dbMigrationStep(fmt.Sprintf("DB-Migration-%s", app.Name), containers.container(migration), credentialID, commands)
+		if err != nil {
+			return "", nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return strings.Join(stages, " "), containers.containers, nil
+}
+
+// DBMigrationReportReq the db migration version reported by the jobs
+type DBMigrationReportReq struct {
+	PublishJobID int64  `json:"publish_job_id"`
+	ProjectAppID int64  `json:"project_app_id"`
+	Phase        string `json:"phase"`
+}
+
+// ReportDBMigration record the version before and after migration, or the result of down migrations
+func (pm *PipelineManager) ReportDBMigration(projectID, publishID, stageID int64, req *DBMigrationReportReq, output string) error {
+	job, err := pm.modelPublishJob.GetPublishJobByID(req.PublishJobID)
+	if err != nil || job.PublishID != publishID || job.EnvID != stageID {
+		return fmt.Errorf("发布任务 %v 不属于流水线 %v 的阶段 %v", req.PublishJobID, publishID, stageID)
+	}
+	switch req.Phase {
+	case DBMigrationPhaseBefore:
+		projectApp, err := pm.modelProject.GetProjectApp(req.ProjectAppID)
+		if err != nil {
+			return err
+		}
+		scmApp, err := pm.modelApp.GetScmAppByID(projectApp.ScmID)
+		if err != nil {
+			return err
+		}
+		migration, err := appmgr.ParseDBMigration(scmApp.DBMigration)
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			return fmt.Errorf("应用 %v 未配置数据库迁移", scmApp.Name)
+		}
+		jobApp, err := pm.modelPublishJob.GetPublishJobApp(req.PublishJobID, req.ProjectAppID)
+		if err != nil {
+			return fmt.Errorf("应用 %v 不属于发布任务 %v", scmApp.Name, req.PublishJobID)
+		}
+		_, err = pm.modelDBMigration.CreateDBMigration(&models.DBMigration{
+			Addons:          models.NewAddons(),
+			ProjectID:       projectID,
+			PublishID:       publishID,
+			StageID:         stageID,
+			PublishJobID:    req.PublishJobID,
+			ProjectAppID:    req.ProjectAppID,
+			AppName:         scmApp.Name,
+			Branch:          jobApp.BranchName,
+			Tool:            migration.Tool,
+			Config:          scmApp.DBMigration,
+			PreviousVersion: parseDBMigrationVersion(migration.Tool, output),
+			Status:          models.DBMigrationMigrating,
+		})
+		return err
+	case DBMigrationPhaseAfter:
+		migration, err := pm.modelDBMigration.GetJobDBMigration(req.PublishJobID, req.ProjectAppID)
+		if err != nil {
+			return fmt.Errorf("应用 %v 的数据库迁移记录不存在: %s", req.ProjectAppID, err.Error())
+		}
+		if migration.Status != models.DBMigrationMigrating {
+			return fmt.Errorf("应用 %v 的数据库迁移状态为 %v，不能更新", migration.AppName, migration.Status)
+		}
+		migration.Version = parseDBMigrationVersion(migration.Tool, output)
+		migration.Status = models.DBMigrationApplied
+		return pm.modelDBMigration.UpdateDBMigration(migration, "version", "status", "update_at")
+	case DBMigrationPhaseRollback:
+		// the callback of rollback job is only sent when all down migrations success
+		migrations, err := pm.modelDBMigration.GetDBMigrations(publishID, stageID)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if migration.Status != models.DBMigrationRollingBack {
+				continue
+			}
+			migration.Status = models.DBMigrationRolledBack
+			migration.Message = fmt.Sprintf("已回滚至版本 %v", migration.PreviousVersion)
+			if err := pm.modelDBMigration.UpdateDBMigration(migration, "status", "message", "update_at"); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的数据库迁移阶段: %v", req.Phase)
+	}
+}
+
+// rollbackDBMigrations return the latest applied migration of each app which needs down migration on rollback
+func (pm *PipelineManager) rollbackDBMigrations(publishID, stageID int64) ([]*models.DBMigration, error) {
+	migrations, err := pm.modelDBMigration.GetDBMigrations(publishID, stageID)
+	if err != nil {
+		return nil, err
+	}
+	items := []*models.DBMigration{}
+	latest := map[int64]bool{}
+	for _, migration := range migrations {
+		if latest[migration.ProjectAppID] {
+			continue
+		}
+		latest[migration.ProjectAppID] = true
+		if migration.Status != models.DBMigrationApplied {
+			continue
+		}
+		config, err := appmgr.ParseDBMigration(migration.Config)
+		if err != nil || config == nil || !config.DownOnRollback {
+			continue
+		}
+		if migration.PreviousVersion == "" {
+			log.Log.Warn("app: %v db migration: %v has no previous version, skip down migration", migration.AppName, migration.ID)
+			continue
+		}
+		items = append(items, migration)
+	}
+	return items, nil
+}
+
+// RollbackDBMigrations run the down migrations to the previous versions for the apps migrated in the stage of failed deploy job,
+// the configs recorded on migration are used, return the apps rolling back, the result is reported by the callback of rollback job.
+func (pm *PipelineManager) RollbackDBMigrations(job *models.PublishJob) ([]string, error) {
+	migrations, err := pm.rollbackDBMigrations(job.PublishID, job.EnvID)
+	if err != nil || len(migrations) == 0 {
+		return nil, err
+	}
+	rollingBack := []string{}
+	err = pm.createDBRollbackJob(job, migrations)
+	for _, migration := range migrations {
+		migration.Status = models.DBMigrationRollingBack
+		migration.Message = ""
+		if err != nil {
+			migration.Status = models.DBMigrationRollbackFailed
+			migration.Message = utils.Truncate(err.Error(), 256)
+		}
+		if updateErr := pm.modelDBMigration.UpdateDBMigration(migration, "status", "message", "update_at"); updateErr != nil {
+			log.Log.Error("update db migration: %v status error: %s", migration.ID, updateErr.Error())
+		}
+		rollingBack = append(rollingBack, fmt.Sprintf("%s@%s", migration.AppName, migration.PreviousVersion))
+	}
+	return rollingBack, err
+}
+
+// createDBRollbackJob checkout the branches migrated, then run the down migrations in jenkins
+func (pm *PipelineManager) createDBRollbackJob(job *models.PublishJob, migrations []*models.DBMigration) error {
+	CIInfo, err := pm.GetJobCIConfig(job)
+	if err != nil {
+		return err
+	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
+
+	apps := []*RunBuildAppReq{}
+	for _, migration := range migrations {
+		apps = append(apps, &RunBuildAppReq{ProjectAppID: migration.ProjectAppID, Branch: migration.Branch})
+	}
+	appsAllParams, _ := pm.aggregateAppsParamsForBuild(apps, nil)
+	appCheckoutItems, err := pm.renderAppCheckoutItemsForBuild(job.ProjectID, job.EnvID, appsAllParams, CIInfo, models.CompileEnvShellSh)
+	if err != nil {
+		return err
+	}
+	checkoutStage, err := jenkins.GeneratePipelineXMLStr(templates.Checkout, map[string]interface{}{"CheckoutItems": appCheckoutItems})
+	if err != nil {
+		return err
+	}
+	stages := []string{checkoutStage}
+	containers := &dbMigrationContainers{}
+	for _, app := range appsAllParams {
+		for _, migration := range migrations {
+			if migration.ProjectAppID != app.ProjectAppID {
+				continue
+			}
+			config, err := appmgr.ParseDBMigration(migration.Config)
+			if err != nil {
+				return err
+			}
+			credentialID, err := pm.dbMigrationCredentialID(job.EnvID, config.CredentialID)
+			if err != nil {
+				return err
+			}
+			workDir := dbMigrationWorkDir(pm.generateAppRepoPth(job.EnvID, job.ProjectID, CIInfo.Workspace, app), app.BuildPath, config.Dir)
+			command := fmt.Sprintf(`sh 'cd %s && %s'`, workDir, dbMigrationDownCommand(config, migration.PreviousVersion))
+			stage, err := dbMigrationStep(fmt.Sprintf("DB-Rollback-%s", app.Name), containers.container(config), credentialID, []string{command})
+			if err != nil {
+				return err
+			}
+			stages = append(stages, stage)
+		}
+	}
+
+	jenkinsJNLPTemplate, err := pm.getSysDefaultCompileEnv(constant.DefaultContainerName)
+	if err != nil {
+		return err
+	}
+	scmCredentialEnvVars, err := pm.generateSCMCredentialEnvVars(appsAllParams)
+	if err != nil {
+		return err
+	}
+	callbackToken, err := issueCallbackToken(job.ProjectID, job.PublishID, job.EnvID, job.ID, dbRollbackStep)
+	if err != nil {
+		return err
+	}
+	envVars := []jenkins.EnvItem{
+		{Key: "JENKINS_SLAVE_WORKSPACE", Value: CIInfo.Workspace},
+		{Key: "ACCESS_TOKEN", Value: callbackToken},
+	}
+	ciContext := jenkins.CIContext{
+		EnvVars:            append(envVars, scmCredentialEnvVars...),
+		ContainerTemplates: append([]jenkins.ContainerEnv{jenkinsJNLPTemplate}, containers.containers...),
+		Stages:             strings.Join(stages, " "),
+		CommonContext: jenkins.CommonContext{
+			Namespace: CIInfo.Namespace,
+		},
+		CallBack: jenkins.CallbackRequest{
+			Token: callbackToken,
+			URL:   dbMigrationReportURL(job.ProjectID, job.PublishID, job.EnvID, job.ID, 0, dbRollbackStep, DBMigrationPhaseRollback),
+			Body:  fmt.Sprintf("{\"publish_job_id\": %d}", job.ID),
+		},
+	}
+	flowProcessor, err := pm.ciFlowProcessor(job.EnvID, ciContext, nil, "")
+	if err != nil {
+		return err
+	}
+	jobName := jenkinsJobPath(job.ProjectID, fmt.Sprintf("atomci_%v_%v_%v_%v", job.ProjectID, job.PublishID, job.EnvID, dbRollbackStep))
+	workerflowClient, err := NewWorkFlowProvide(CIInfo.Driver, addr, user, token, jobName, flowProcessor)
+	if err != nil {
+		return err
+	}
+	runID, err := workerflowClient.Build()
+	if err != nil {
+		return err
+	}
+	log.Log.Info("publish: %v stage: %v db rollback job: %v run: %v triggered", job.PublishID, job.EnvID, jobName, runID)
+	return nil
+}
+
+// GetDBMigrations return the db migrations of publish
+func (pm *PipelineManager) GetDBMigrations(projectID, publishID int64) ([]*models.DBMigration, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return nil, err
+	}
+	return pm.modelDBMigration.GetDBMigrations(publishID, 0)
+}
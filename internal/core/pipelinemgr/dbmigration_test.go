@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"testing"
+
+	appmgr "github.com/go-atomci/atomci/internal/core/apps"
+)
+
+func TestParseDBMigrationVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		output string
+		want   string
+	}{
+		{"migrate", appmgr.DBMigrationMigrate, "3\n", "3"},
+		{"migrate dirty", appmgr.DBMigrationMigrate, "20230101120000 (dirty)\n", "20230101120000"},
+		{"migrate empty database", appmgr.DBMigrationMigrate, "error: no migration\n", ""},
+		{"flyway", appmgr.DBMigrationFlyway, `{"schemaVersion": "1.2.1", "migrations": []}`, "1.2.1"},
+		{"flyway warnings", appmgr.DBMigrationFlyway, "WARNING: deprecated\n{\"schemaVersion\": \"4\"}", "4"},
+		{"flyway empty database", appmgr.DBMigrationFlyway, `{"schemaVersion": null}`, ""},
+		{"flyway injection", appmgr.DBMigrationFlyway, `{"schemaVersion": "1; rm -rf /"}`, ""},
+		{"liquibase", appmgr.DBMigrationLiquibase, "atomci-12-3-base\n", "atomci-12-3-base"},
+		{"liquibase tag failed", appmgr.DBMigrationLiquibase, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDBMigrationVersion(tt.tool, tt.output); got != tt.want {
+				t.Errorf("parseDBMigrationVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBMigrationCommands(t *testing.T) {
+	tests := []struct {
+		migration *appmgr.DBMigration
+		up        string
+		down      string
+	}{
+		{
+			&appmgr.DBMigration{Tool: appmgr.DBMigrationMigrate},
+			`migrate -path . -database "$ATOMCI_DB_URL" up`,
+			`migrate -path . -database "$ATOMCI_DB_URL" goto 3`,
+		},
+		{
+			&appmgr.DBMigration{Tool: appmgr.DBMigrationFlyway},
+			`flyway -url="$ATOMCI_DB_URL" -locations=filesystem:. migrate`,
+			`flyway -url="$ATOMCI_DB_URL" -locations=filesystem:. undo -target=3`,
+		},
+		{
+			&appmgr.DBMigration{Tool: appmgr.DBMigrationLiquibase, ChangeLogFile: "db/changelog.yaml"},
+			`liquibase --url="$ATOMCI_DB_URL" --changelog-file=db/changelog.yaml update`,
+			`liquibase --url="$ATOMCI_DB_URL" --changelog-file=db/changelog.yaml rollback --tag=3`,
+		},
+	}
+	for _, tt := range tests {
+		if got := dbMigrationUpCommand(tt.migration); got != tt.up {
+			t.Errorf("dbMigrationUpCommand() = %v, want %v", got, tt.up)
+		}
+		if got := dbMigrationDownCommand(tt.migration, "3"); got != tt.down {
+			t.Errorf("dbMigrationDownCommand() = %v, want %v", got, tt.down)
+		}
+	}
+	if got := dbMigrationWorkDir("/ws/1/2/app/master", "/", "./db/migrations/"); got != "/ws/1/2/app/master/db/migrations" {
+		t.Errorf("dbMigrationWorkDir() = %v", got)
+	}
+}
+
+func TestValidateBeforeDeploy(t *testing.T) {
+	var steps PipelineSteps
+	if err := json.Unmarshal([]byte(`[
+		{"name": "build", "type": "build", "index": 1},
+		{"name": "manual", "type": "manual", "index": 2},
+		{"name": "deploy", "type": "deploy", "index": 3},
+		{"name": "hotfix-deploy", "type": "deploy", "index": 4, "depends_on": []}
+	]`), &steps); err != nil {
+		t.Fatal(err)
+	}
+	if err := steps[:3].validateBeforeDeploy(1); err != nil {
+		t.Errorf("validateBeforeDeploy() deploy depends on build indirectly, error = %v", err)
+	}
+	if err := steps.validateBeforeDeploy(1); err == nil {
+		t.Errorf("validateBeforeDeploy() deploy without dependency on build, want error")
+	}
+	if err := steps[:3].validateBeforeDeploy(3); err == nil {
+		t.Errorf("validateBeforeDeploy() the migration step is the deploy itself, want error")
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// DeployWaves layer the apps by their deploy dependencies, the apps of a wave depend on the apps of lower waves only,
+// the dependencies out of apps are ignored since those apps are not deployed by the same job.
+func DeployWaves(appIDs []int64, deps map[int64][]int64) ([][]int64, error) {
+	inSet := map[int64]bool{}
+	for _, id := range appIDs {
+		inSet[id] = true
+	}
+	pending := map[int64]int{}
+	dependents := map[int64][]int64{}
+	for id := range inSet {
+		pending[id] = 0
+		for _, dep := range deps[id] {
+			if !inSet[dep] || dep == id {
+				continue
+			}
+			pending[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	waves := [][]int64{}
+	for len(pending) > 0 {
+		wave := []int64{}
+		for id, count := range pending {
+			if count == 0 {
+				wave = append(wave, id)
+			}
+		}
+		if len(wave) == 0 {
+			cycle := []int64{}
+			for id := range pending {
+				cycle = append(cycle, id)
+			}
+			sort.Slice(cycle, func(i, j int) bool { return cycle[i] < cycle[j] })
+			return nil, fmt.Errorf("应用部署依赖存在循环: %v", cycle)
+		}
+		sort.Slice(wave, func(i, j int) bool { return wave[i] < wave[j] })
+		for _, id := range wave {
+			delete(pending, id)
+			for _, dependent := range dependents[id] {
+				pending[dependent]--
+			}
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// planDeployWaves return the wave of every app to deploy, starts from 1
+func (pm *PipelineManager) planDeployWaves(apps []*RunDeployAppReq) (map[int64]int, int, error) {
+	appIDs := []int64{}
+	deps := map[int64][]int64{}
+	for _, app := range apps {
+		appIDs = append(appIDs, app.ProjectAppID)
+		projectApp, err := pm.modelProject.GetProjectApp(app.ProjectAppID)
+		if err != nil {
+			log.Log.Warn("when plan deploy waves, get project app: %v occur error: %s", app.ProjectAppID, err.Error())
+			continue
+		}
+		deps[app.ProjectAppID] = projectApp.DeployAfterIDs()
+	}
+	waves, err := DeployWaves(appIDs, deps)
+	if err != nil {
+		return nil, 0, err
+	}
+	appWaves := map[int64]int{}
+	for index, wave := range waves {
+		for _, id := range wave {
+			appWaves[id] = index + 1
+		}
+	}
+	return appWaves, len(waves), nil
+}
+
+// appsOfWave return the apps to deploy in the wave
+func appsOfWave(apps []*RunDeployAppReq, appWaves map[int64]int, wave int) []*RunDeployAppReq {
+	items := []*RunDeployAppReq{}
+	for _, app := range apps {
+		if appWaves[app.ProjectAppID] == wave {
+			items = append(items, app)
+		}
+	}
+	return items
+}
+
+// startDeployWave record the first wave of deploy job applied
+func (pm *PipelineManager) startDeployWave(publishJobID int64) error {
+	job, err := pm.modelPublishJob.GetPublishJobByID(publishJobID)
+	if err != nil {
+		log.Log.Error("when start deploy wave, get publish job by id: %v occur error: %s", publishJobID, err.Error())
+		return err
+	}
+	now := time.Now()
+	job.Wave = 1
+	job.WaveStartAt = &now
+	return pm.modelPublishJob.UpdatePublishJob(job)
+}
+
+// applyDeployTemplate apply the rendered arrange of apps to the cluster of env, then prune the orphaned resources of them
+func (pm *PipelineManager) applyDeployTemplate(envModel *models.ProjectEnv, projectID int64, templateStr string) error {
+	clusterModel, err := pm.settingsHandler.GetIntegrateSettingByID(envModel.Cluster)
+	if err != nil {
+		log.Log.Error("when apply deploy template, get cluster by id %v occur error: %s", envModel.Cluster, err.Error())
+		return err
+	}
+
+	err = kuberes.TriggerApplicationCreate(clusterModel.Name, envModel.Namespace, templateStr, projectID, envModel.ID, true)
+	if err != nil {
+		log.Log.Error("when apply deploy template, trigger application create occur error: %s", err.Error())
+		return err
+	}
+	if envModel.Prune {
+		// the deploy succeeded already, the failure of prune is retried by the next deploy
+		pruned, err := kuberes.PruneOrphanedResources(clusterModel.Name, envModel.Namespace, envModel.ID, templateStr)
+		if err != nil {
+			log.Log.Warn("when apply deploy template, prune orphaned resources occur error: %s", err.Error())
+		} else if len(pruned) > 0 {
+			log.Log.Info("env: %v pruned orphaned resources: %v", envModel.Name, pruned)
+		}
+	}
+	return nil
+}
+
+// applyNextDeployWave apply the apps of next wave once the apps of current wave are ready,
+// the images recorded by the job are deployed so that all the waves deploy the same version.
+func (pm *PipelineManager) applyNextDeployWave(job *models.PublishJob, envModel *models.ProjectEnv) error {
+	jobApps, err := pm.modelPublishJob.GetPublishJobApps(job.ID)
+	if err != nil {
+		return err
+	}
+	next := job.Wave + 1
+	apps := []*RunDeployAppReq{}
+	for _, jobApp := range jobApps {
+		if jobApp.Wave == next {
+			apps = append(apps, &RunDeployAppReq{
+				ProjectAppID: jobApp.ProjectAPPID,
+				Gray:         jobApp.Gray,
+				ImageTag:     imageTagOfAddr(jobApp.ImageAddr),
+			})
+		}
+	}
+	if len(apps) > 0 {
+		if err := pm.deployApps(envModel, apps, job.PublishID); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	job.Wave = next
+	job.WaveStartAt = &now
+	log.Log.Info("deploy job: %d applied wave %d, apps: %d", job.ID, next, len(apps))
+	return nil
+}
+
+// lastDeployWave return the highest wave of the apps deployed by job
+func lastDeployWave(jobApps []*models.PublishJobApp) int {
+	last := 0
+	for _, app := range jobApps {
+		if app.Wave > last {
+			last = app.Wave
+		}
+	}
+	return last
+}
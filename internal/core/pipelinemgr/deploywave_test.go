@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeployWaves(t *testing.T) {
+	// migrate-db(1) before api(2) before frontend(3), worker(4) depends on the app out of deploy
+	deps := map[int64][]int64{
+		2: {1},
+		3: {2},
+		4: {9},
+	}
+	waves, err := DeployWaves([]int64{3, 2, 1, 4}, deps)
+	if err != nil {
+		t.Fatalf("DeployWaves() error = %v", err)
+	}
+	want := [][]int64{{1, 4}, {2}, {3}}
+	if !reflect.DeepEqual(waves, want) {
+		t.Errorf("DeployWaves() = %v, want %v", waves, want)
+	}
+
+	waves, err = DeployWaves([]int64{3, 1}, deps)
+	if err != nil || !reflect.DeepEqual(waves, [][]int64{{1, 3}}) {
+		t.Errorf("DeployWaves() = %v, %v, want the apps without deployed dependencies in one wave", waves, err)
+	}
+
+	deps[1] = []int64{3}
+	if _, err := DeployWaves([]int64{1, 2, 3}, deps); err == nil {
+		t.Errorf("DeployWaves() want error for the cycle dependencies")
+	}
+}
+
+func TestImageTagOfAddr(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"10.10.0.8:9980/abc:master-1a2b3c4", "master-1a2b3c4"},
+		{"10.10.0.8:9980/abc:master-1a2b3c4@sha256:abc", "master-1a2b3c4@sha256:abc"},
+		{"10.10.0.8:9980/abc@sha256:abc", "sha256:abc"},
+		{"10.10.0.8:9980/abc", ""},
+	}
+	for _, tt := range tests {
+		if got := imageTagOfAddr(tt.image); got != tt.want {
+			t.Errorf("imageTagOfAddr(%v) = %v, want %v", tt.image, got, tt.want)
+		}
+		if tt.want == "" {
+			continue
+		}
+		if image, _ := withImageTag(tt.image, tt.want); image != tt.image {
+			t.Errorf("withImageTag(%v, %v) = %v, want the same image", tt.image, tt.want, image)
+		}
+	}
+}
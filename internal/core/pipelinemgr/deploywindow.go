@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/events"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// deployWindowTimezone the timezone of deploy windows and freeze periods, empty means the server local timezone
+var deployWindowTimezone = beego.AppConfig.DefaultString("deploywindow::timezone", "")
+
+// DeployWindow the period of a day which deploy is allowed
+type DeployWindow struct {
+	// Weekdays 0 is Sunday, empty means every day
+	Weekdays []int `json:"weekdays"`
+	// Start/End format is HH:MM, the window crosses midnight when end is earlier than start
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ParseDeployWindows decode the deploy windows stored in project env
+func ParseDeployWindows(config string) ([]*DeployWindow, error) {
+	windows := []*DeployWindow{}
+	if strings.TrimSpace(config) == "" {
+		return windows, nil
+	}
+	if err := json.Unmarshal([]byte(config), &windows); err != nil {
+		return nil, fmt.Errorf("部署窗口配置解析失败: %s", err.Error())
+	}
+	return windows, nil
+}
+
+// ValidateDeployWindows ..
+func ValidateDeployWindows(windows []*DeployWindow) error {
+	for i, window := range windows {
+		if window == nil {
+			return fmt.Errorf("第%v个部署窗口不能为空", i+1)
+		}
+		start, err := parseClock(window.Start)
+		if err != nil {
+			return fmt.Errorf("第%v个部署窗口开始时间无效: %s", i+1, err.Error())
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return fmt.Errorf("第%v个部署窗口结束时间无效: %s", i+1, err.Error())
+		}
+		if start == end {
+			return fmt.Errorf("第%v个部署窗口开始时间和结束时间不能相同", i+1)
+		}
+		for _, day := range window.Weekdays {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("第%v个部署窗口星期无效: %v，可选值为 0-6(0 为星期日)", i+1, day)
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock return the minutes from midnight of HH:MM
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("%v 不是有效的 HH:MM 时间", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *DeployWindow) onDay(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, item := range w.Weekdays {
+		if time.Weekday(item) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains verify the moment is in the window, the moment should be in the deploy window timezone
+func (w *DeployWindow) Contains(moment time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	minutes := moment.Hour()*60 + moment.Minute()
+	if start < end {
+		return w.onDay(moment.Weekday()) && minutes >= start && minutes < end
+	}
+	// the window crosses midnight, the part after midnight belongs to the previous day
+	if minutes >= start {
+		return w.onDay(moment.Weekday())
+	}
+	return minutes < end && w.onDay(moment.AddDate(0, 0, -1).Weekday())
+}
+
+func (w *DeployWindow) String() string {
+	days := []string{}
+	for _, day := range w.Weekdays {
+		days = append(days, time.Weekday(day).String()[:3])
+	}
+	if len(days) == 0 {
+		days = append(days, "Everyday")
+	}
+	return fmt.Sprintf("%s %s-%s", strings.Join(days, ","), w.Start, w.End)
+}
+
+func deployWindowLocation() *time.Location {
+	if deployWindowTimezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(deployWindowTimezone)
+	if err != nil {
+		log.Log.Warn("load deploy window timezone: %v occur error: %s, use local timezone", deployWindowTimezone, err.Error())
+		return time.Local
+	}
+	return location
+}
+
+// ParseDeployTime parse the time format as 2006-01-02 15:04 in the deploy window timezone
+func ParseDeployTime(value string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", strings.TrimSpace(value), deployWindowLocation())
+}
+
+// deployWindowOpen verify the env is not frozen and the moment is in the deploy windows,
+// the reason is returned when deploy is not allowed
+func (pm *PipelineManager) deployWindowOpen(envModel *models.ProjectEnv, moment time.Time) (bool, string, error) {
+	freezes, err := pm.modelProject.GetActiveDeployFreezes(envModel.ProjectID, envModel.ID, moment)
+	if err != nil {
+		return false, "", err
+	}
+	location := deployWindowLocation()
+	if len(freezes) > 0 {
+		freeze := freezes[0]
+		return false, fmt.Sprintf("环境处于封版期: %s(%s ~ %s)", freeze.Name,
+			freeze.StartAt.In(location).Format("2006-01-02 15:04"), freeze.EndAt.In(location).Format("2006-01-02 15:04")), nil
+	}
+
+	windows, err := ParseDeployWindows(envModel.DeployWindows)
+	if err != nil {
+		return false, "", err
+	}
+	if len(windows) == 0 {
+		return true, "", nil
+	}
+	descriptions := []string{}
+	for _, window := range windows {
+		if window.Contains(moment.In(location)) {
+			return true, "", nil
+		}
+		descriptions = append(descriptions, window.String())
+	}
+	return false, fmt.Sprintf("当前不在部署窗口内: %s", strings.Join(descriptions, "; ")), nil
+}
+
+// applyDeployWindowPolicy handle the deploy job triggered out of the deploy windows or during the freeze period,
+// proceed is true when the job could be created right now
+func (pm *PipelineManager) applyDeployWindowPolicy(publish *models.Publish, stageID int64, creator string, params *DeployStepReq) (int64, bool, error) {
+	envModel, err := pm.modelProject.GetProjectEnvByID(stageID)
+	if err != nil {
+		return models.Failed, false, fmt.Errorf("获取项目环境: %v 失败: %s", stageID, err.Error())
+	}
+	// the locked env could not be forced or queued, it must be unlocked first
+	if err := pm.VerifyEnvUnlocked(stageID); err != nil {
+		return models.Skipped, false, err
+	}
+	open, reason, err := pm.deployWindowOpen(envModel, time.Now())
+	if err != nil {
+		return models.Failed, false, err
+	}
+	if open {
+		return models.Running, true, nil
+	}
+
+	if params.OverrideWindow {
+		if !dao.UserIsAdmin(creator) {
+			return models.Skipped, false, fmt.Errorf("%s，仅管理员可以强制部署", reason)
+		}
+		log.Log.Warn("publish: %v deploy to env: %v was forced by admin: %v, %s", publish.ID, stageID, creator, reason)
+		operationLog := &models.PublishOperationLog{
+			Creator:            creator,
+			Type:               "强制部署",
+			Stage:              publish.StageName,
+			StageID:            stageID,
+			Step:               "deploy-window",
+			Status:             models.Success,
+			PublishID:          publish.ID,
+			PipelineInstanceID: publish.LastPipelineInstanceID,
+			Message:            reason,
+		}
+		if err := pm.modelPublish.CreatePublishOperation(operationLog); err != nil {
+			log.Log.Error("create publish: %v force deploy operation log occur error: %s", publish.ID, err.Error())
+		} else {
+			go events.EmitPublishTransition(operationLog)
+		}
+		return models.Running, true, nil
+	}
+
+	if envModel.WindowPolicy == models.WindowPolicyQueue {
+		if err := pm.enqueueJob(publish, stageID, models.JobTypeDeploy, creator, reason, params); err != nil {
+			return models.Skipped, false, err
+		}
+		return models.Pending, false, nil
+	}
+	return models.Skipped, false, fmt.Errorf("%s，如需紧急发布请联系管理员强制部署", reason)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeployWindowContains(t *testing.T) {
+	// 2021-06-07 is Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2021, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+	weekdays := &DeployWindow{Weekdays: []int{1, 2, 3, 4, 5}, Start: "10:00", End: "16:00"}
+	overnight := &DeployWindow{Weekdays: []int{5}, Start: "22:00", End: "02:00"}
+	everyday := &DeployWindow{Start: "09:00", End: "10:00"}
+	tests := []struct {
+		name   string
+		window *DeployWindow
+		moment time.Time
+		want   bool
+	}{
+		{"weekday in window", weekdays, at(7, 10, 0), true},
+		{"weekday end is exclusive", weekdays, at(7, 16, 0), false},
+		{"weekday before window", weekdays, at(8, 9, 59), false},
+		{"weekend", weekdays, at(6, 11, 0), false},
+		{"overnight start day", overnight, at(4, 23, 0), true},
+		{"overnight next day", overnight, at(5, 1, 30), true},
+		{"overnight next day after end", overnight, at(5, 2, 0), false},
+		{"overnight wrong day", overnight, at(4, 1, 0), false},
+		{"everyday", everyday, at(6, 9, 30), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.moment); got != tt.want {
+				t.Errorf("%v Contains(%v) = %v, want %v", tt.window, tt.moment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDeployWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []*DeployWindow
+		wantErr bool
+	}{
+		{"valid", []*DeployWindow{{Weekdays: []int{0, 6}, Start: "10:00", End: "16:00"}}, false},
+		{"invalid clock", []*DeployWindow{{Start: "25:00", End: "16:00"}}, true},
+		{"same start and end", []*DeployWindow{{Start: "10:00", End: "10:00"}}, true},
+		{"invalid weekday", []*DeployWindow{{Weekdays: []int{7}, Start: "10:00", End: "16:00"}}, true},
+		{"nil window", []*DeployWindow{nil}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDeployWindows(tt.windows); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDeployWindows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
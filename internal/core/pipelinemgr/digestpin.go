@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+// pinDeployImages resolve the image tag of each app to digest through the registry api before deploy,
+// the apps are deployed by digest so the tag overwritten between build and deploy takes no effect.
+// the images which are not in the registry of env, eg: the public images, are not pinned.
+func (pm *PipelineManager) pinDeployImages(publishID, envID int64, apps []*RunDeployAppReq) error {
+	registryConf, err := pm.getEnvRegistryConfig(envID)
+	if err != nil {
+		return err
+	}
+	provider, err := registryConf.Provider()
+	if err != nil {
+		return err
+	}
+	envRegistryHost := registryHost(registryConf.URL)
+	for _, app := range apps {
+		arrange, err := pm.appHandler.GetRealArrange(app.ProjectAppID, envID)
+		if err != nil {
+			log.Log.Warn("when pin image digest, get app: %v env: %v arrange occur error: %s", app.ProjectAppID, envID, err.Error())
+			continue
+		}
+		publishApp, err := pm.modelPublish.GetPublishAppByPublishIDAndAppID(publishID, app.ProjectAppID)
+		if err != nil {
+			log.Log.Warn("when pin image digest, get publish: %v app: %v occur error: %s", publishID, app.ProjectAppID, err.Error())
+			continue
+		}
+		image, _, err := pm.deployImageAddr(app, arrange.ID, publishApp)
+		if err != nil {
+			return fmt.Errorf("生成应用: %v 部署镜像失败: %s", app.ProjectAppID, err.Error())
+		}
+		if strings.Contains(image, "@") {
+			// deploy by digest already
+			continue
+		}
+		host, repo, tag := splitImage(image)
+		if strings.ToLower(host) != envRegistryHost {
+			log.Log.Debug("image: %v is not in env: %v registry %v, skip pin digest", image, envID, envRegistryHost)
+			continue
+		}
+		digest, err := provider.ManifestDigest(repo, tag)
+		if err != nil {
+			return fmt.Errorf("镜像 %v 不存在或无法访问, 请确认构建成功后重试: %s", image, err.Error())
+		}
+		log.Log.Debug("pin image: %v to digest: %v", image, digest)
+		app.ImageTag = tag + "@" + digest
+	}
+	return nil
+}
+
+// registryHost return the host of registry url, eg: https://harbor.example.com/library -> harbor.example.com
+func registryHost(addr string) string {
+	addr = strings.TrimSpace(strings.ToLower(addr))
+	if index := strings.Index(addr, "://"); index != -1 {
+		addr = addr[index+3:]
+	}
+	return strings.SplitN(addr, "/", 2)[0]
+}
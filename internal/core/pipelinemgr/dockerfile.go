@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+// generateDockerfileCommand write the dockerfile into the app path when the app lacks it, the content is base64
+// encoded to avoid the quotes and groovy interpolation
+func generateDockerfileCommand(appPath, dockerfile, content string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	return fmt.Sprintf(`sh "cd %v; [ -f %v ] || { echo %v | base64 -d > %v; echo generated %v from template; }"`, appPath, dockerfile, encoded, dockerfile, dockerfile)
+}
+
+// renderDockerfileStageForBuild generate the dockerfile of apps from the templates before build image,
+// the stage is empty if there is no template for the apps
+func (pm *PipelineManager) renderDockerfileStageForBuild(projectID, stageID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, matrix *buildMatrix) (string, error) {
+	commands := []string{}
+	for _, app := range allParms {
+		if buildWithBuildpacks(app) {
+			continue
+		}
+		projectApp, err := pm.modelProject.GetProjectApp(app.ProjectAppID)
+		if err != nil {
+			log.Log.Warn("get project app: %v occur error: %s, use the dockerfile template of language", app.ProjectAppID, err.Error())
+			projectApp = nil
+		}
+		content, err := pm.settingsHandler.ResolveDockerfile(projectApp, settings.CompileCommandData{
+			AppName:   app.Name,
+			Language:  app.Language,
+			BuildPath: app.BuildPath,
+			Branch:    app.Branch,
+		})
+		if err != nil {
+			return "", fmt.Errorf("应用 %v Dockerfile 模板无效: %v", app.Name, err)
+		}
+		if content == "" {
+			continue
+		}
+		dockerfile := app.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		// every cell of build matrix builds the image in its own copy of app repo
+		seen := map[string]bool{}
+		for _, cell := range pm.matrixCells(matrix, app) {
+			appPath := pm.matrixAppPath(stageID, projectID, ciConfig.Workspace, app, cell)
+			if seen[appPath] {
+				continue
+			}
+			seen[appPath] = true
+			commands = append(commands, generateDockerfileCommand(appPath, dockerfile, content))
+		}
+	}
+	if len(commands) == 0 {
+		return "", nil
+	}
+	item := jenkins.StepItem{
+		Name:    "'Dockerfiles'",
+		Command: strings.Join(commands, "\n"),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
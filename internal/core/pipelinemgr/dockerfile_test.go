@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDockerfileCommand(t *testing.T) {
+	content := "FROM alpine:3.15\nCMD [\"echo\", \"it's $HOME\"]\n"
+	command := generateDockerfileCommand("/workspace/demo", "Dockerfile", content)
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	for _, want := range []string{"cd /workspace/demo;", "[ -f Dockerfile ] ||", "echo " + encoded + " | base64 -d > Dockerfile"} {
+		if !strings.Contains(command, want) {
+			t.Fatalf("command %q does not contain %q", command, want)
+		}
+	}
+	if strings.Contains(command, "$HOME") {
+		t.Fatalf("the content should be encoded, got %q", command)
+	}
+}
@@ -0,0 +1,595 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/core/rbac"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	e2eUploaderImage   = beego.AppConfig.DefaultString("e2e::uploader_image", "minio/mc:RELEASE.2023-01-28T20-29-38Z")
+	e2eStorageEndpoint = beego.AppConfig.DefaultString("e2e::endpoint", "")
+	e2eStorageBucket   = beego.AppConfig.DefaultString("e2e::bucket", "atomci-e2e")
+	e2eStorageAccess   = beego.AppConfig.DefaultString("e2e::access_key", "")
+	e2eStorageSecret   = beego.AppConfig.DefaultString("e2e::secret_key", "")
+	// e2eReportURL the public address of the bucket to browse the html reports, default is endpoint/bucket
+	e2eReportURL = beego.AppConfig.DefaultString("e2e::report_url", "")
+)
+
+const e2eUploaderContainerName = "e2e-uploader"
+
+// the frameworks of e2e suite, the suite command writes the junit xml reports into $REPORT_DIR
+const (
+	E2EFrameworkSelenium   = "selenium"
+	E2EFrameworkPlaywright = "playwright"
+	E2EFrameworkNewman     = "newman"
+)
+
+var (
+	e2eImagePattern   = regexp.MustCompile(`^[A-Za-z0-9_./:@-]+$`)
+	e2eBaseURLPattern = regexp.MustCompile(`^[A-Za-z0-9_.:/?&=%{}#@+,~-]*$`)
+)
+
+// e2eSuite the params of e2e-suite sub task, the command runs in the suite image against the deployed env,
+// the address of env is passed by $E2E_BASE_URL, and the reports should be written into $REPORT_DIR
+type e2eSuite struct {
+	Framework string `json:"framework,omitempty"`
+	Image     string `json:"image"`
+	Command   string `json:"command"`
+	// BaseURL the address of deployed env, {env} and {namespace} are replaced by the arrange env and namespace of stage
+	BaseURL string `json:"base_url,omitempty"`
+	// MinPassRate the min pass rate percent of suite to promote the publish, 0 means all tests must pass
+	MinPassRate float64 `json:"min_pass_rate,omitempty"`
+}
+
+// validate the image and base url are rendered into pipeline script, only the safe characters are allowed
+func (s *e2eSuite) validate() error {
+	if s == nil {
+		return fmt.Errorf("端到端测试子任务缺少参数配置")
+	}
+	switch s.Framework {
+	case "", E2EFrameworkSelenium, E2EFrameworkPlaywright, E2EFrameworkNewman:
+	default:
+		return fmt.Errorf("端到端测试不支持的框架: %v，可选值为 %v/%v/%v", s.Framework, E2EFrameworkSelenium, E2EFrameworkPlaywright, E2EFrameworkNewman)
+	}
+	if !e2eImagePattern.MatchString(s.Image) {
+		return fmt.Errorf("端到端测试镜像: %v 无效", s.Image)
+	}
+	if strings.TrimSpace(s.Command) == "" {
+		return fmt.Errorf("端到端测试命令不能为空")
+	}
+	if !e2eBaseURLPattern.MatchString(s.BaseURL) {
+		return fmt.Errorf("端到端测试环境地址: %v 无效", s.BaseURL)
+	}
+	if s.MinPassRate < 0 || s.MinPassRate > 100 {
+		return fmt.Errorf("端到端测试通过率阈值: %v 无效，须在 0-100 之间", s.MinPassRate)
+	}
+	return nil
+}
+
+// threshold the pass rate percent required by the suite
+func (s *e2eSuite) threshold() float64 {
+	if s.MinPassRate == 0 {
+		return 100
+	}
+	return s.MinPassRate
+}
+
+// e2eBaseURL replace the placeholders of base url by the env of stage
+func e2eBaseURL(baseURL string, env *models.ProjectEnv) string {
+	return strings.NewReplacer("{env}", env.ArrangeEnv, "{namespace}", env.Namespace).Replace(baseURL)
+}
+
+// e2eSubTasks return the sub tasks of the type in the e2e-test step
+func (p PipelineSteps) e2eSubTasks(index int, taskType string) []*subTask {
+	tasks := []*subTask{}
+	for _, step := range p {
+		if step.Index != index || step.Type != models.StepE2ETest {
+			continue
+		}
+		for _, task := range step.SubTask {
+			if task.Type == taskType {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	return tasks
+}
+
+// hasStep return true when the stage contains the type of step
+func (p PipelineSteps) hasStep(stepType string) bool {
+	for _, step := range p {
+		if step.Type == stepType {
+			return true
+		}
+	}
+	return false
+}
+
+// groovyEscape escape the command for groovy triple single quoted string
+func groovyEscape(command string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(command)
+}
+
+// parseJUnit count the test cases of junit xml reports, the reports of suite may be concatenated
+func parseJUnit(r io.Reader) (total, failed, skipped int, err error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	inCase, caseFailed, caseSkipped := false, false, false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return total, failed, skipped, nil
+		}
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "testcase":
+				inCase, caseFailed, caseSkipped = true, false, false
+			case "failure", "error":
+				caseFailed = caseFailed || inCase
+			case "skipped":
+				caseSkipped = caseSkipped || inCase
+			}
+		case xml.EndElement:
+			if t.Name.Local != "testcase" || !inCase {
+				continue
+			}
+			inCase = false
+			total++
+			if caseFailed {
+				failed++
+			} else if caseSkipped {
+				skipped++
+			}
+		}
+	}
+}
+
+// e2ePassRate the percent of passed tests in the executed tests, round down to keep the gate strict
+func e2ePassRate(total, failed, skipped int) float64 {
+	executed := total - skipped
+	if executed <= 0 {
+		return 0
+	}
+	return math.Floor(float64(executed-failed)*10000/float64(executed)) / 100
+}
+
+// e2eStorageEnabled the reports are uploaded only when the object storage is configured
+func e2eStorageEnabled() bool {
+	return e2eStorageEndpoint != ""
+}
+
+// e2eReportPrefix the object prefix of suite reports in the bucket
+func e2eReportPrefix(projectID, publishID, publishJobID int64, index int) string {
+	return fmt.Sprintf("%d/%d/%d/%d", projectID, publishID, publishJobID, index)
+}
+
+// e2eReportLink the address to browse the uploaded reports
+func e2eReportLink(prefix string) string {
+	if !e2eStorageEnabled() {
+		return ""
+	}
+	base := e2eReportURL
+	if base == "" {
+		base = fmt.Sprintf("%s/%s", strings.TrimSuffix(e2eStorageEndpoint, "/"), e2eStorageBucket)
+	}
+	return fmt.Sprintf("%s/%s/", strings.TrimSuffix(base, "/"), prefix)
+}
+
+// E2ETestJobName the jenkins job name of e2e test
+func E2ETestJobName(projectID, publishID, stageID int64) string {
+	return jenkinsJobPath(projectID, fmt.Sprintf("atomci_%v_%v_%v_e2e", projectID, publishID, stageID))
+}
+
+// e2eSuiteCommands return the pipeline steps of the suite, the suite failure does not break the job,
+// the pass rate of the reported junit decides the result
+func e2eSuiteCommands(container, reportDir, baseURL, command, prefix, reportURL string) []string {
+	commands := []string{
+		fmt.Sprintf("container('%s') {", container),
+		fmt.Sprintf("withEnv(['E2E_BASE_URL=%s', 'REPORT_DIR=%s']) {", baseURL, reportDir),
+		fmt.Sprintf(`sh 'rm -rf %s && mkdir -p %s'`, reportDir, reportDir),
+		fmt.Sprintf(`sh script: '''%s''', returnStatus: true`, groovyEscape(command)),
+		"}",
+		"}",
+		fmt.Sprintf(`sh 'find %s -name "*.xml" -exec cat {} + > %s.junit 2>/dev/null || true'`, reportDir, reportDir),
+	}
+	if e2eStorageEnabled() {
+		commands = append(commands,
+			fmt.Sprintf("container('%s') {", e2eUploaderContainerName),
+			fmt.Sprintf(`sh 'mc --config-dir /tmp/.mc alias set atomci "$E2E_STORAGE_ENDPOINT" "$E2E_STORAGE_ACCESS_KEY" "$E2E_STORAGE_SECRET_KEY" >/dev/null && mc --config-dir /tmp/.mc cp --recursive %s/ atomci/%s/%s/ || true'`, reportDir, e2eStorageBucket, prefix),
+			"}",
+		)
+	}
+	commands = append(commands, fmt.Sprintf(
+		`httpRequest acceptType: 'APPLICATION_JSON', contentType: 'TEXT_PLAIN', customHeaders: [[maskValue: true, name: 'Authorization', value: "Bearer ${env.ACCESS_TOKEN}"]], httpMode: 'POST', requestBody: readFile('%s.junit'), responseHandle: 'NONE', timeout: 30, url: '%s'`,
+		reportDir, reportURL))
+	return commands
+}
+
+// E2ETestStepReq ..
+type E2ETestStepReq struct {
+	ActionName string `json:"action_name"`
+}
+
+// RunE2ETestStep publish-order e2e test operation
+func (pm *PipelineManager) RunE2ETestStep(projectID, publishID, stageID int64, creator string, params *E2ETestStepReq) (int64, int64, string, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return models.Failed, 0, "", fmt.Errorf("请选择有效的项目/流水线后重试：%s", err.Error())
+	}
+	if err := rbac.VerifyEnvAction(creator, projectID, stageID, rbac.ActionTest); err != nil {
+		return models.Skipped, 0, "", err
+	}
+	publish, _ := pm.modelPublish.GetPublishByID(publishID)
+	envStageJSON, err := pm.GetPipelineInstanceEnvStageByID(publish.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return models.Failed, 0, "", fmt.Errorf("can not get env stage based on lastpipelineinstance id: %v", publish.LastPipelineInstanceID)
+	}
+
+	switch params.ActionName {
+	case "trigger":
+		unlock, err := lockStageTrigger(projectID, stageID)
+		if err != nil {
+			return models.Skipped, 0, "", err
+		}
+		defer unlock()
+		runningJobs, err := pm.modelPublishJob.GetCurrentRunningBuildJob(projectID, stageID, publishID, []string{models.StatusRunning, models.StatusInit}, models.JobTypeE2ETest)
+		if err != nil {
+			return models.Failed, 0, "", err
+		}
+		if len(runningJobs) > 0 {
+			return models.Skipped, 0, "", fmt.Errorf("此阶段的流水线存在端到端测试中的任务, 任务ID: %v", runningJobs[0].ID)
+		}
+		runID, jobName, err := pm.CreateE2ETestJob(creator, projectID, publishID, envStageJSON)
+		if err != nil {
+			return models.Failed, 0, "", err
+		}
+		log.Log.Info("create e2e test job success, job run id: %v", runID)
+		return models.Running, runID, jobName, nil
+	case "terminate":
+		if err := pm.publishTerminatePublish(projectID, publishID, stageID, models.JobTypeE2ETest); err != nil {
+			if strings.Contains(err.Error(), "操作拒绝") {
+				return models.Skipped, 0, "", err
+			}
+			return models.TerminateFailed, 0, "", err
+		}
+		return models.TerminateSuccess, 0, "", nil
+	default:
+		return models.UnKnown, 0, "", fmt.Errorf("step_name: %s did not defined ActionName, params: %v", models.StepE2ETest, params)
+	}
+}
+
+// CreateE2ETestJob run the e2e suites of the current step in the jenkins job, each suite reports its junit result
+func (pm *PipelineManager) CreateE2ETestJob(creator string, projectID, publishID int64, stageJSON *PipelineStageStruct) (int64, string, error) {
+	publish, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return 0, "", err
+	}
+	stageID := stageJSON.StageID
+	suites := stageJSON.Steps.e2eSubTasks(publish.StepIndex, constant.StepSubTaskE2ESuite)
+	perfTests := stageJSON.Steps.e2eSubTasks(publish.StepIndex, constant.StepSubTaskPerfTest)
+	if len(suites)+len(perfTests) == 0 {
+		return 0, "", fmt.Errorf("端到端测试任务未配置测试套件或性能测试")
+	}
+	envModel, err := pm.modelProject.GetProjectEnvByID(stageID)
+	if err != nil {
+		return 0, "", err
+	}
+	route, err := pm.routeCIServer(stageID)
+	if err != nil {
+		return 0, "", err
+	}
+	CIInfo, err := pm.getCIConfig(stageID, route.SettingID, route.Version)
+	if err != nil {
+		return 0, "", err
+	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
+
+	publishJobID, err := pm.createPublishJob(projectID, publishID, stageID, creator, models.JobTypeE2ETest, nil, route)
+	if err != nil {
+		return 0, "", err
+	}
+	jenkinsJNLPTemplate, err := pm.getSysDefaultCompileEnv(constant.DefaultContainerName)
+	if err != nil {
+		return 0, "", err
+	}
+	containers := []jenkins.ContainerEnv{jenkinsJNLPTemplate}
+	// the artifacts uploaded by the build step are downloaded before the suites run
+	downloadDir := artifactDir(CIInfo.Workspace, publishJobID)
+	downloads, err := renderArtifactDownloadStages(projectID, publishID, downloadDir, stageJSON.Steps.e2eSubTasks(publish.StepIndex, constant.StepSubTaskArtifact))
+	if err != nil {
+		return 0, "", err
+	}
+	stages := append([]string{}, downloads...)
+	for index, suite := range suites {
+		if err := suite.E2E.validate(); err != nil {
+			return 0, "", err
+		}
+		container := fmt.Sprintf("e2e-%d", index)
+		containers = append(containers, jenkins.ContainerEnv{
+			Name:       container,
+			Image:      suite.E2E.Image,
+			WorkingDir: "/home/jenkins/agent",
+			CommandArr: []string{"cat"},
+		})
+		query := url.Values{}
+		query.Set("publish_job_id", fmt.Sprint(publishJobID))
+		query.Set("suite", fmt.Sprint(index))
+		reportURL := fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/e2e-report?%s", atomciServer, projectID, publishID, stageID, models.StepE2ETest, query.Encode())
+		reportDir := fmt.Sprintf("%s/e2e/%d/%d", CIInfo.Workspace, publishJobID, index)
+		commands := e2eSuiteCommands(container, reportDir, e2eBaseURL(suite.E2E.BaseURL, envModel), suite.E2E.Command, e2eReportPrefix(projectID, publishID, publishJobID, index), reportURL)
+		item := jenkins.StepItem{
+			Name:    fmt.Sprintf("'E2E-%d-%s'", index, groovyEscape(suite.Name)),
+			Command: strings.Join(commands, "\n"),
+		}
+		stage, err := jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+		if err != nil {
+			return 0, "", err
+		}
+		stages = append(stages, stage)
+	}
+	for index, task := range perfTests {
+		container, stage, err := renderPerfTestStage(projectID, publishID, stageID, publishJobID, index, task, e2eBaseURL(task.Perf.BaseURL, envModel), CIInfo.Workspace)
+		if err != nil {
+			return 0, "", err
+		}
+		containers = append(containers, container)
+		stages = append(stages, stage)
+	}
+
+	callbackToken, err := issueCallbackToken(projectID, publishID, stageID, publishJobID, models.StepE2ETest)
+	if err != nil {
+		return 0, "", err
+	}
+	callbackBody, err := callbackRequestBody(publishJobID)
+	if err != nil {
+		return 0, "", err
+	}
+	envVars := []jenkins.EnvItem{
+		{Key: "JENKINS_SLAVE_WORKSPACE", Value: CIInfo.Workspace},
+		{Key: "ACCESS_TOKEN", Value: callbackToken},
+	}
+	inputEnvVars, err := pm.manualInputEnvVars(publish.LastPipelineInstanceID)
+	if err != nil {
+		return 0, "", err
+	}
+	envVars = append(envVars, inputEnvVars...)
+	if len(downloads) > 0 {
+		container, artifactEnvVars := artifactContainer()
+		containers = append(containers, container)
+		envVars = append(envVars, artifactEnvVars...)
+		envVars = append(envVars, jenkins.EnvItem{Key: "ARTIFACT_DIR", Value: downloadDir})
+	}
+	if e2eStorageEnabled() {
+		containers = append(containers, jenkins.ContainerEnv{
+			Name:       e2eUploaderContainerName,
+			Image:      e2eUploaderImage,
+			WorkingDir: "/home/jenkins/agent",
+			CommandArr: []string{"cat"},
+		})
+		envVars = append(envVars,
+			jenkins.EnvItem{Key: "E2E_STORAGE_ENDPOINT", Value: e2eStorageEndpoint},
+			jenkins.EnvItem{Key: "E2E_STORAGE_ACCESS_KEY", Value: e2eStorageAccess},
+			jenkins.EnvItem{Key: "E2E_STORAGE_SECRET_KEY", Value: e2eStorageSecret},
+		)
+	}
+	ciContext := jenkins.CIContext{
+		EnvVars:            envVars,
+		ContainerTemplates: containers,
+		Stages:             strings.Join(stages, " "),
+		CommonContext: jenkins.CommonContext{
+			Namespace: CIInfo.Namespace,
+		},
+		CallBack: jenkins.CallbackRequest{
+			Token: callbackToken,
+			URL:   fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/callback", atomciServer, projectID, publishID, stageID, models.StepE2ETest),
+			Body:  callbackBody,
+		},
+	}
+	flowProcessor, err := pm.ciFlowProcessor(stageID, ciContext, nil, "")
+	if err != nil {
+		return 0, "", err
+	}
+	jobName := E2ETestJobName(projectID, publishID, stageID)
+	workerflowClient, err := NewWorkFlowProvide(CIInfo.Driver, addr, user, token, jobName, flowProcessor)
+	if err != nil {
+		return 0, "", err
+	}
+	runID, err := workerflowClient.Build()
+	if err != nil {
+		return 0, "", err
+	}
+	if err := pm.UpdatePublishJob(publishJobID, runID); err != nil {
+		return 0, "", err
+	}
+	return runID, jobName, nil
+}
+
+// E2ETestReportReq the junit report of suite reported by the e2e test job
+type E2ETestReportReq struct {
+	PublishJobID int64 `json:"publish_job_id"`
+	SuiteIndex   int   `json:"suite"`
+}
+
+// ReportE2ETest store the pass rate of suite and whether it reaches the threshold
+func (pm *PipelineManager) ReportE2ETest(projectID, publishID, stageID int64, req *E2ETestReportReq, junit io.Reader) error {
+	job, suite, err := pm.e2eJobSubTask(publishID, stageID, req.PublishJobID, constant.StepSubTaskE2ESuite, req.SuiteIndex)
+	if err != nil {
+		return err
+	}
+	total, failed, skipped, err := parseJUnit(junit)
+	if err != nil {
+		// the broken report is recorded as no test passed
+		log.Log.Warn("parse publish job: %v suite: %v junit report error: %s", job.ID, suite.Name, err.Error())
+		total, failed, skipped = 0, 0, 0
+	}
+	passRate := e2ePassRate(total, failed, skipped)
+	report := &models.E2ETestReport{
+		Addons:       models.NewAddons(),
+		ProjectID:    projectID,
+		PublishID:    publishID,
+		StageID:      stageID,
+		PublishJobID: job.ID,
+		Suite:        suite.Name,
+		Framework:    suite.E2E.Framework,
+		Total:        total,
+		Passed:       total - failed - skipped,
+		Failed:       failed,
+		Skipped:      skipped,
+		PassRate:     passRate,
+		Threshold:    suite.E2E.threshold(),
+		GatePassed:   total-skipped > 0 && passRate >= suite.E2E.threshold(),
+		ReportURL:    e2eReportLink(e2eReportPrefix(projectID, publishID, job.ID, req.SuiteIndex)),
+	}
+	_, err = pm.modelE2ETest.CreateE2ETestReport(report)
+	return err
+}
+
+// e2eJobSubTask return the e2e test job and the sub task reported by the job
+func (pm *PipelineManager) e2eJobSubTask(publishID, stageID, publishJobID int64, taskType string, index int) (*models.PublishJob, *subTask, error) {
+	job, err := pm.modelPublishJob.GetPublishJobByID(publishJobID)
+	if err != nil || job.PublishID != publishID || job.EnvID != stageID || job.JobType != models.JobTypeE2ETest {
+		return nil, nil, fmt.Errorf("端到端测试任务 %v 不属于流水线 %v 的阶段 %v", publishJobID, publishID, stageID)
+	}
+	publish, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return nil, nil, err
+	}
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(publish.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := stageJSON.Steps.e2eSubTasks(job.StepIndex, taskType)
+	if index < 0 || index >= len(tasks) {
+		return nil, nil, fmt.Errorf("%v 子任务 %v 不存在", taskType, index)
+	}
+	return job, tasks[index], nil
+}
+
+// E2ETestGate return whether all suites of the job reached the pass rate thresholds
+// and the perf tests did not regress, and the reason if not
+func (pm *PipelineManager) E2ETestGate(publishJobID int64) (bool, string, error) {
+	reports, err := pm.modelE2ETest.GetJobE2ETestReports(publishJobID)
+	if err != nil {
+		return false, "", err
+	}
+	perfResults, err := pm.modelPerfTest.GetJobPerfTestResults(publishJobID)
+	if err != nil {
+		return false, "", err
+	}
+	if len(reports)+len(perfResults) == 0 {
+		return false, "未收到端到端测试报告", nil
+	}
+	reasons := []string{}
+	for _, report := range reports {
+		if !report.GatePassed {
+			reasons = append(reasons, fmt.Sprintf("%s 通过率 %.2f%% 低于阈值 %.2f%%", report.Suite, report.PassRate, report.Threshold))
+		}
+	}
+	for _, result := range perfResults {
+		if !result.GatePassed {
+			reasons = append(reasons, fmt.Sprintf("%s %s", result.Task, result.Message))
+		}
+	}
+	return len(reasons) == 0, strings.Join(reasons, "; "), nil
+}
+
+// RunE2ETestCallBackStep the job finished all suites, the result is decided by the pass rate gate
+func (pm *PipelineManager) RunE2ETestCallBackStep(request *BuildStepCallbackReq) (int64, string, error) {
+	status, message := models.StatusFailure, ""
+	if request.JobStatus == "" || request.JobStatus == models.StatusSuccess {
+		passed, gateMessage, err := pm.E2ETestGate(request.PublishJobID)
+		if err != nil {
+			return models.Skipped, "", err
+		}
+		message = gateMessage
+		if passed {
+			status = models.StatusSuccess
+		}
+	}
+	if err := pm.UpdatePublishJobStatus(request.PublishJobID, status); err != nil {
+		if !strings.Contains(err.Error(), "already was end status") {
+			log.Log.Error("e2e test callback, update publish job status occur error: %s", err.Error())
+			return models.Skipped, "", err
+		}
+		// the resumed callback continue the publish transition, which failed after the job status updated
+		if !request.Resume {
+			return models.Skipped, "", nil
+		}
+	}
+	job, err := pm.modelPublishJob.GetPublishJobByID(request.PublishJobID)
+	if err != nil {
+		return models.Skipped, "", err
+	}
+	return jobPublishStatus(job.Status), message, nil
+}
+
+// VerifyE2ETestGate the stage with e2e-test step promotes only when the latest e2e test reached the thresholds
+func (pm *PipelineManager) VerifyE2ETestGate(pipelineInstanceID, publishID, stageID int64) error {
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(pipelineInstanceID, stageID)
+	if err != nil {
+		return err
+	}
+	if !stageJSON.Steps.hasStep(models.StepE2ETest) {
+		return nil
+	}
+	job, err := pm.modelPublishJob.GetLastStageJobByType(publishID, stageID, models.JobTypeE2ETest)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return fmt.Errorf("当前阶段尚未执行端到端测试，不能流转到下一阶段")
+		}
+		return err
+	}
+	if job.Status == models.StatusRunning || job.Status == models.StatusInit {
+		return fmt.Errorf("端到端测试任务 %v 执行中，不能流转到下一阶段", job.ID)
+	}
+	passed, message, err := pm.E2ETestGate(job.ID)
+	if err != nil {
+		return err
+	}
+	if !passed || job.Status != models.StatusSuccess {
+		return fmt.Errorf("端到端测试未通过: %s，不能流转到下一阶段", message)
+	}
+	return nil
+}
+
+// GetE2ETestReports return the e2e test reports of publish
+func (pm *PipelineManager) GetE2ETestReports(projectID, publishID int64) ([]*models.E2ETestReport, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return nil, err
+	}
+	return pm.modelE2ETest.GetE2ETestReports(publishID, 0)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  string
+		total   int
+		failed  int
+		skipped int
+	}{
+		{"empty", "", 0, 0, 0},
+		{
+			"playwright",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" skipped="1">
+<testsuite name="login.spec.ts">
+<testcase name="login" classname="login"></testcase>
+<testcase name="logout" classname="login"><failure message="timeout">Timeout 30000ms exceeded</failure></testcase>
+<testcase name="signup" classname="login"><skipped/></testcase>
+</testsuite>
+</testsuites>`,
+			3, 1, 1,
+		},
+		{
+			"concatenated newman and selenium",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="api"><testsuite name="users"><testcase name="GET /users"/><testcase name="POST /users"><error message="500"/></testcase></testsuite></testsuites>
+<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="ui"><testcase name="home"/></testsuite>`,
+			3, 1, 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, failed, skipped, err := parseJUnit(strings.NewReader(tt.report))
+			if err != nil {
+				t.Fatalf("parseJUnit() error = %v", err)
+			}
+			if total != tt.total || failed != tt.failed || skipped != tt.skipped {
+				t.Errorf("parseJUnit() = %v/%v/%v, want %v/%v/%v", total, failed, skipped, tt.total, tt.failed, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestE2EPassRate(t *testing.T) {
+	tests := []struct {
+		total, failed, skipped int
+		want                   float64
+	}{
+		{0, 0, 0, 0},
+		{3, 0, 3, 0},
+		{10, 0, 2, 100},
+		{3, 1, 0, 66.66},
+		{200, 1, 0, 99.5},
+	}
+	for _, tt := range tests {
+		if got := e2ePassRate(tt.total, tt.failed, tt.skipped); got != tt.want {
+			t.Errorf("e2ePassRate(%v, %v, %v) = %v, want %v", tt.total, tt.failed, tt.skipped, got, tt.want)
+		}
+	}
+}
+
+func TestE2ESuiteValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		suite   *e2eSuite
+		wantErr bool
+	}{
+		{"valid", &e2eSuite{Framework: E2EFrameworkPlaywright, Image: "registry.local/e2e:1.0", Command: "npx playwright test", BaseURL: "http://web.{namespace}.svc"}, false},
+		{"missing params", nil, true},
+		{"unknown framework", &e2eSuite{Framework: "cypress", Image: "e2e", Command: "run"}, true},
+		{"image injection", &e2eSuite{Image: "e2e'; rm -rf /", Command: "run"}, true},
+		{"empty command", &e2eSuite{Image: "e2e", Command: " "}, true},
+		{"base url injection", &e2eSuite{Image: "e2e", Command: "run", BaseURL: "http://web' ]) { sh 'id"}, true},
+		{"pass rate out of range", &e2eSuite{Image: "e2e", Command: "run", MinPassRate: 101}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.suite.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
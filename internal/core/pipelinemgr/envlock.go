@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// VerifyEnvUnlocked the deploy and auto promote to the env are not allowed while the env is locked
+func (pm *PipelineManager) VerifyEnvUnlocked(envID int64) error {
+	lock, err := pm.modelProject.GetActiveEnvLock(envID, time.Now())
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("获取环境: %v 锁定状态失败: %s", envID, err.Error())
+	}
+	envName := fmt.Sprint(envID)
+	if env, err := pm.modelProject.GetProjectEnvByID(envID); err == nil {
+		envName = env.Name
+	}
+	return fmt.Errorf("%s", EnvLockedReason(envName, lock))
+}
+
+// EnvLockedReason describe who locked the env, why and when it is unlocked automatically
+func EnvLockedReason(envName string, lock *models.EnvLock) string {
+	reason := fmt.Sprintf("环境 %s 已被 %s 于 %s 锁定: %s", envName, lock.Locker,
+		lock.CreateAt.In(deployWindowLocation()).Format("2006-01-02 15:04"), lock.Reason)
+	if lock.UnlockAt != nil {
+		reason += fmt.Sprintf("，将于 %s 自动解锁", lock.UnlockAt.In(deployWindowLocation()).Format("2006-01-02 15:04"))
+	}
+	return reason
+}
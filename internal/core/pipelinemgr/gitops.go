@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/argocd"
+
+	"github.com/drone/go-scm/scm"
+)
+
+const (
+	argoCDApplicationKind = "Application"
+	// argoCDLegacyManifest the single manifest file of all the apps committed by early versions
+	argoCDLegacyManifest = "manifests.yaml"
+)
+
+// argoCDApplicationName one argo cd application per project env
+func argoCDApplicationName(projectID, envID int64) string {
+	return fmt.Sprintf("atomci-%d-%d", projectID, envID)
+}
+
+// argoCDManifestDir the dir of project env arrange in git config repo
+func argoCDManifestDir(argoCDConf *settings.ArgoCDConfig, projectID, envID int64) string {
+	return path.Join(argoCDConf.RepoPath, fmt.Sprintf("%d", projectID), fmt.Sprintf("%d", envID))
+}
+
+// argoCDManifestFile one manifest file per app, the apps not deployed by publish are kept in env
+func argoCDManifestFile(manifestDir string, projectAppID int64) string {
+	return path.Join(manifestDir, fmt.Sprintf("app-%d.yaml", projectAppID))
+}
+
+// repoFullName return the repo full name from clone url, eg: http://gitlab.com/atomci/config.git => atomci/config
+func repoFullName(repoURL string) (string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("无效的配置仓库地址: %v，仅支持 http(s) 地址", repoURL)
+	}
+	return strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"), nil
+}
+
+// deployByArgoCD commit the rendered arrange of apps to git config repo, then create/sync the argo cd application
+func (pm *PipelineManager) deployByArgoCD(env *models.ProjectEnv, publishID int64, templates []*appTemplate) error {
+	argoCDConf, err := pm.settingsHandler.GetArgoCDIntegrateSettingByID(env.ArgoCD)
+	if err != nil {
+		log.Log.Error("get argocd integrate setting by id: %v occur error: %s", env.ArgoCD, err.Error())
+		return err
+	}
+	scmIntegrateResp, err := pm.settingsHandler.GetSCMIntegrateSettinByID(argoCDConf.RepoID)
+	if err != nil {
+		log.Log.Error("get argocd config repo scm integrate setting by id: %v occur error: %s", argoCDConf.RepoID, err.Error())
+		return err
+	}
+
+	manifestDir := argoCDManifestDir(argoCDConf, env.ProjectID, env.ID)
+	message := fmt.Sprintf("atomci: deploy publish %d to env %s", publishID, env.Name)
+	for _, item := range templates {
+		if err := commitManifest(scmIntegrateResp, argoCDConf, argoCDManifestFile(manifestDir, item.ProjectAppID), item.Content, message); err != nil {
+			return err
+		}
+	}
+	// the apps in legacy manifest file are committed to their own file now, avoid the duplicate resources
+	if err := removeManifest(scmIntegrateResp, argoCDConf, path.Join(manifestDir, argoCDLegacyManifest), message); err != nil {
+		return err
+	}
+
+	client, err := argoCDConf.Client()
+	if err != nil {
+		return err
+	}
+	err = client.UpsertRepository(&argocd.Repository{
+		Repo:     argoCDConf.RepoURL,
+		Type:     "git",
+		Username: scmIntegrateResp.User,
+		Password: scmIntegrateResp.Token,
+	})
+	if err != nil {
+		log.Log.Error("register config repo: %v to argocd occur error: %s", argoCDConf.RepoURL, err.Error())
+		return err
+	}
+	appName := argoCDApplicationName(env.ProjectID, env.ID)
+	err = client.UpsertApplication(&argocd.Application{
+		Metadata: argocd.ObjectMeta{
+			Name: appName,
+			Labels: map[string]string{
+				"atomci/project-id": fmt.Sprintf("%d", env.ProjectID),
+				"atomci/env-id":     fmt.Sprintf("%d", env.ID),
+			},
+		},
+		Spec: argocd.ApplicationSpec{
+			Project: argoCDConf.Project,
+			Source: argocd.ApplicationSource{
+				RepoURL:        argoCDConf.RepoURL,
+				Path:           manifestDir,
+				TargetRevision: argoCDConf.RepoBranch,
+			},
+			Destination: argocd.ApplicationDestination{
+				Server:    argoCDConf.DestServer,
+				Namespace: env.Namespace,
+			},
+		},
+	})
+	if err != nil {
+		log.Log.Error("upsert argocd application: %v occur error: %s", appName, err.Error())
+		return err
+	}
+	if err := client.SyncApplication(appName, argoCDConf.RepoBranch, env.Prune); err != nil {
+		log.Log.Error("sync argocd application: %v occur error: %s", appName, err.Error())
+		return err
+	}
+	return nil
+}
+
+// commitManifest create or update the manifest file in git config repo
+func commitManifest(scmIntegrate *settings.ScmIntegrateSetting, argoCDConf *settings.ArgoCDConfig, filePath, content, message string) error {
+	fullName, err := repoFullName(argoCDConf.RepoURL)
+	if err != nil {
+		return err
+	}
+	client, err := apps.NewScmProvider(scmIntegrate.Type, argoCDConf.RepoURL, scmIntegrate.Token)
+	if err != nil {
+		return err
+	}
+	params := &scm.ContentParams{
+		Branch:  argoCDConf.RepoBranch,
+		Message: message,
+		Data:    []byte(content),
+	}
+	ctx := context.Background()
+	origin, _, err := client.Contents.Find(ctx, fullName, filePath, argoCDConf.RepoBranch)
+	if err != nil {
+		log.Log.Debug("find %v in config repo: %v occur error: %s, create it", filePath, fullName, err.Error())
+		_, err = client.Contents.Create(ctx, fullName, filePath, params)
+	} else {
+		params.Sha = origin.Sha
+		params.BlobID = origin.BlobID
+		_, err = client.Contents.Update(ctx, fullName, filePath, params)
+	}
+	if err != nil {
+		log.Log.Error("commit %v to config repo: %v occur error: %s", filePath, fullName, err.Error())
+		return fmt.Errorf("提交编排文件到配置仓库失败: %s", err.Error())
+	}
+	return nil
+}
+
+// removeManifest delete the manifest file from git config repo if exist
+func removeManifest(scmIntegrate *settings.ScmIntegrateSetting, argoCDConf *settings.ArgoCDConfig, filePath, message string) error {
+	fullName, err := repoFullName(argoCDConf.RepoURL)
+	if err != nil {
+		return err
+	}
+	client, err := apps.NewScmProvider(scmIntegrate.Type, argoCDConf.RepoURL, scmIntegrate.Token)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	origin, _, err := client.Contents.Find(ctx, fullName, filePath, argoCDConf.RepoBranch)
+	if err != nil {
+		return nil
+	}
+	params := &scm.ContentParams{
+		Branch:  argoCDConf.RepoBranch,
+		Message: message,
+		Sha:     origin.Sha,
+		BlobID:  origin.BlobID,
+	}
+	if _, err := client.Contents.Delete(ctx, fullName, filePath, params); err != nil {
+		log.Log.Error("delete %v from config repo: %v occur error: %s", filePath, fullName, err.Error())
+		return fmt.Errorf("删除配置仓库编排文件失败: %s", err.Error())
+	}
+	return nil
+}
+
+// argoCDApplicationStatus return the sync/health status of the project env argo cd application
+func (pm *PipelineManager) argoCDApplicationStatus(env *models.ProjectEnv) (*kuberes.RolloutStatus, error) {
+	argoCDConf, err := pm.settingsHandler.GetArgoCDIntegrateSettingByID(env.ArgoCD)
+	if err != nil {
+		return nil, err
+	}
+	appName := argoCDApplicationName(env.ProjectID, env.ID)
+	client, err := argoCDConf.Client()
+	if err != nil {
+		return nil, err
+	}
+	app, err := client.GetApplication(appName)
+	if err != nil {
+		return nil, err
+	}
+	return applicationRolloutStatus(app), nil
+}
+
+func applicationRolloutStatus(app *argocd.Application) *kuberes.RolloutStatus {
+	status := &kuberes.RolloutStatus{
+		Kind: argoCDApplicationKind,
+		Name: app.Metadata.Name,
+	}
+	operationPhase := ""
+	if app.Status.OperationState != nil {
+		operationPhase = app.Status.OperationState.Phase
+	}
+	switch {
+	case operationPhase == argocd.OperationPhaseFailed || operationPhase == argocd.OperationPhaseError:
+		status.Failed = true
+		status.Message = fmt.Sprintf("sync %s: %s", strings.ToLower(operationPhase), app.Status.OperationState.Message)
+	case app.Status.Health.Status == argocd.HealthStatusDegraded:
+		status.Failed = true
+		status.Message = fmt.Sprintf("health degraded: %s", app.Status.Health.Message)
+	case operationPhase == argocd.OperationPhaseSucceeded && app.Status.Sync.Status == argocd.SyncStatusSynced && app.Status.Health.Status == argocd.HealthStatusHealthy:
+		status.Ready = true
+		status.Message = fmt.Sprintf("synced to %s and healthy", app.Status.Sync.Revision)
+	default:
+		status.Message = fmt.Sprintf("sync status: %s, health status: %s, operation phase: %s", app.Status.Sync.Status, app.Status.Health.Status, operationPhase)
+	}
+	return status
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/argocd"
+)
+
+func TestRepoFullName(t *testing.T) {
+	tests := []struct {
+		repoURL string
+		want    string
+		wantErr bool
+	}{
+		{"http://gitlab.com/atomci/config.git", "atomci/config", false},
+		{"https://gitlab.com/group/sub/config/", "group/sub/config", false},
+		{"git@gitlab.com:atomci/config.git", "", true},
+	}
+	for _, tt := range tests {
+		got, err := repoFullName(tt.repoURL)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("repoFullName(%q) = %v, %v", tt.repoURL, got, err)
+		}
+	}
+	conf := &settings.ArgoCDConfig{RepoPath: "atomci"}
+	if got := argoCDManifestDir(conf, 1, 2); got != "atomci/1/2" {
+		t.Errorf("argoCDManifestDir() = %v", got)
+	}
+}
+
+func TestApplicationRolloutStatus(t *testing.T) {
+	tests := []struct {
+		name, sync, health, phase string
+		ready, failed             bool
+	}{
+		{"synced and healthy", argocd.SyncStatusSynced, argocd.HealthStatusHealthy, argocd.OperationPhaseSucceeded, true, false},
+		{"sync failed", "OutOfSync", argocd.HealthStatusHealthy, argocd.OperationPhaseFailed, false, true},
+		{"sync error", "OutOfSync", "Missing", argocd.OperationPhaseError, false, true},
+		{"degraded", argocd.SyncStatusSynced, argocd.HealthStatusDegraded, argocd.OperationPhaseSucceeded, false, true},
+		{"progressing", argocd.SyncStatusSynced, "Progressing", argocd.OperationPhaseSucceeded, false, false},
+		{"syncing", "OutOfSync", argocd.HealthStatusHealthy, "Running", false, false},
+		{"never synced", argocd.SyncStatusSynced, argocd.HealthStatusHealthy, "", false, false},
+	}
+	for _, tt := range tests {
+		app := &argocd.Application{Metadata: argocd.ObjectMeta{Name: "atomci-1-2"}}
+		app.Status.Sync.Status = tt.sync
+		app.Status.Health.Status = tt.health
+		if tt.phase != "" {
+			app.Status.OperationState = &struct {
+				Phase   string `json:"phase"`
+				Message string `json:"message"`
+			}{Phase: tt.phase}
+		}
+		status := applicationRolloutStatus(app)
+		if status.Ready != tt.ready || status.Failed != tt.failed || status.Kind != argoCDApplicationKind || status.Name != "atomci-1-2" {
+			t.Errorf("%s: applicationRolloutStatus() = %+v", tt.name, status)
+		}
+	}
+}
+
+// fakeGitlab the gitlab repository files api of the git config repo
+type fakeGitlab struct {
+	files    map[string]string
+	requests []string
+}
+
+func (s *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.Path
+	s.requests = append(s.requests, r.Method+" "+uri)
+	if r.Header.Get("Private-Token") != "scm-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		content, ok := s.files[uri]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"404 File Not Found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"content":        base64.StdEncoding.EncodeToString([]byte(content)),
+			"last_commit_id": "last-commit",
+		})
+	case http.MethodPost, http.MethodPut:
+		in := struct {
+			Branch       string `json:"branch"`
+			Content      []byte `json:"content"`
+			LastCommitID string `json:"last_commit_id"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.Branch != "master" || (r.Method == http.MethodPut && in.LastCommitID != "last-commit") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.files[uri] = string(in.Content)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(s.files, uri)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// fakeArgoCD record the requests of argo cd api, the application is always degraded
+type fakeArgoCD struct {
+	requests map[string]string
+}
+
+func (s *fakeArgoCD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests[r.Method+" "+r.URL.Path] = string(body)
+	if r.Method == http.MethodGet {
+		w.Write([]byte(`{"metadata":{"name":"app"},"status":{"sync":{"status":"Synced"},"health":{"status":"Degraded","message":"back-off restarting"}}}`))
+	}
+}
+
+func newIntegrateSetting(t *testing.T, name, settingType string, config interface{}) int64 {
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting := &models.IntegrateSetting{Addons: models.NewAddons(), Name: name, Type: settingType}
+	setting.CryptoConfig(string(data))
+	return insertTestItem(t, setting)
+}
+
+func TestDeployByArgoCD(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	gitlab := &fakeGitlab{files: map[string]string{}}
+	gitlabServer := httptest.NewServer(gitlab)
+	defer gitlabServer.Close()
+	argo := &fakeArgoCD{requests: map[string]string{}}
+	argoServer := httptest.NewServer(argo)
+	defer argoServer.Close()
+
+	scmID := newIntegrateSetting(t, "config-repo", "gitlab", map[string]string{"url": gitlabServer.URL, "user": "atomci", "token": "scm-token"})
+	argoCDID := newIntegrateSetting(t, "argocd", settings.ArgoCDType, map[string]interface{}{
+		"url":      argoServer.URL,
+		"token":    "argocd-token",
+		"repo_id":  scmID,
+		"repo_url": gitlabServer.URL + "/atomci/config.git",
+	})
+	env := &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 300, Name: "gitops", Namespace: "prod", ArgoCD: argoCDID}
+	env.ID = insertTestItem(t, env)
+
+	filesPath := fmt.Sprintf("/api/v4/projects/atomci/config/repository/files/atomci/300/%d/", env.ID)
+	legacyPath, appAPath, appBPath := filesPath+"manifests.yaml", filesPath+"app-1.yaml", filesPath+"app-2.yaml"
+	gitlab.files[legacyPath] = "replicas: 0"
+
+	// the apps are committed to their own manifest file, the legacy manifest file is removed
+	err := pm.deployByArgoCD(env, 9, []*appTemplate{{ProjectAppID: 1, Content: "app: a"}, {ProjectAppID: 2, Content: "app: b"}})
+	if err != nil {
+		t.Fatalf("deployByArgoCD() error: %v", err)
+	}
+	want := []string{"GET " + appAPath, "POST " + appAPath, "GET " + appBPath, "POST " + appBPath, "GET " + legacyPath, "DELETE " + legacyPath}
+	if strings.Join(gitlab.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("gitlab requests = %v, want %v", gitlab.requests, want)
+	}
+
+	// deploy app a only, the manifest of app b is kept
+	gitlab.requests = nil
+	if err := pm.deployByArgoCD(env, 10, []*appTemplate{{ProjectAppID: 1, Content: "app: a2"}}); err != nil {
+		t.Fatalf("deployByArgoCD() error: %v", err)
+	}
+	want = []string{"GET " + appAPath, "PUT " + appAPath, "GET " + legacyPath}
+	if strings.Join(gitlab.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("gitlab requests = %v, want %v", gitlab.requests, want)
+	}
+	wantFiles := map[string]string{appAPath: "app: a2", appBPath: "app: b"}
+	if len(gitlab.files) != len(wantFiles) || gitlab.files[appAPath] != wantFiles[appAPath] || gitlab.files[appBPath] != wantFiles[appBPath] {
+		t.Errorf("the manifest files = %v, want %v", gitlab.files, wantFiles)
+	}
+
+	appName := fmt.Sprintf("atomci-300-%d", env.ID)
+	repo := argocd.Repository{}
+	json.Unmarshal([]byte(argo.requests["POST /api/v1/repositories"]), &repo)
+	if repo.Repo != gitlabServer.URL+"/atomci/config.git" || repo.Username != "atomci" || repo.Password != "scm-token" {
+		t.Errorf("the registered repository = %+v", repo)
+	}
+	app := argocd.Application{}
+	json.Unmarshal([]byte(argo.requests["POST /api/v1/applications"]), &app)
+	if app.Metadata.Name != appName || app.Spec.Project != "default" || app.Spec.Source.Path != fmt.Sprintf("atomci/300/%d", env.ID) ||
+		app.Spec.Source.TargetRevision != "master" || app.Spec.Destination.Namespace != "prod" || app.Spec.Destination.Server != "https://kubernetes.default.svc" {
+		t.Errorf("the upserted application = %+v", app)
+	}
+	sync := map[string]interface{}{}
+	json.Unmarshal([]byte(argo.requests["POST /api/v1/applications/"+appName+"/sync"]), &sync)
+	if sync["revision"] != "master" || sync["prune"] != false {
+		t.Errorf("the application should be synced without prune by default, requests: %v", argo.requests)
+	}
+
+	// the health of deploy job is the health of argo cd application
+	job := &models.PublishJob{Addons: models.NewAddons(), ProjectID: env.ProjectID, EnvID: env.ID, JobType: models.JobTypeDeploy, Status: models.StatusRunning}
+	job.ID = insertTestItem(t, job)
+	result, err := pm.CheckDeployJobHealth(job)
+	if err != nil {
+		t.Fatalf("CheckDeployJobHealth() error: %v", err)
+	}
+	if result.JobStatus != models.StatusFailure || result.PublishStatus != models.Failed || !strings.Contains(result.Message, "back-off restarting") {
+		t.Errorf("CheckDeployJobHealth() = %+v", result)
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// PublishGraphRsp the stage/step/sub task graph of the publish with statuses and timings
+type PublishGraphRsp struct {
+	PublishID          int64         `json:"publish_id"`
+	PipelineInstanceID int64         `json:"pipeline_instance_id"`
+	Status             int64         `json:"status"`
+	CurrentStageID     int64         `json:"current_stage_id"`
+	CurrentStepIndex   int           `json:"current_step_index"`
+	Stages             []*GraphStage `json:"stages"`
+}
+
+// GraphStage the stage node of publish graph, the status is derived from its steps
+type GraphStage struct {
+	StageID          int64        `json:"stage_id"`
+	Name             string       `json:"name"`
+	Index            int64        `json:"index"`
+	Status           int64        `json:"status"`
+	StartAt          *time.Time   `json:"start_at"`
+	EndAt            *time.Time   `json:"end_at"`
+	DurationInMillis int64        `json:"duration_in_millis"`
+	Steps            []*GraphStep `json:"steps"`
+}
+
+// GraphStep the step node of publish graph, the job fields are empty for the steps without publish job
+type GraphStep struct {
+	Index            int             `json:"index"`
+	Name             string          `json:"name"`
+	Type             string          `json:"type"`
+	DependsOn        []int           `json:"depends_on"`
+	Status           int64           `json:"status"`
+	StartAt          *time.Time      `json:"start_at"`
+	EndAt            *time.Time      `json:"end_at"`
+	DurationInMillis int64           `json:"duration_in_millis"`
+	JobID            int64           `json:"job_id"`
+	JobStatus        string          `json:"job_status"`
+	RunID            int64           `json:"run_id"`
+	LogURL           string          `json:"log_url"`
+	SubTasks         []*GraphSubTask `json:"sub_tasks"`
+}
+
+// GraphSubTask the sub task node of publish graph, the job stages not defined by the step are appended without index
+type GraphSubTask struct {
+	Index            int        `json:"index"`
+	Name             string     `json:"name"`
+	Type             string     `json:"type"`
+	Status           string     `json:"status"`
+	StartAt          *time.Time `json:"start_at"`
+	EndAt            *time.Time `json:"end_at"`
+	DurationInMillis int64      `json:"duration_in_millis"`
+	FailureCause     string     `json:"failure_cause"`
+	FailureMessage   string     `json:"failure_message"`
+}
+
+// subTaskJobStagePrefixes the name prefixes of the jenkins stages generated for each type of sub task
+var subTaskJobStagePrefixes = map[string][]string{
+	"checkout":     {"Checkout"},
+	"compile":      {"Builds"},
+	"build-image":  {"Dockerfiles", "Images", "Buildpacks", "Manifests"},
+	"terraform":    {"Terraform-"},
+	"db-migration": {"DB-Migration-", "DB-Rollback-"},
+	"e2e-suite":    {"E2E-"},
+	"perf-test":    {"Perf-"},
+	"artifact":     {"Artifact-"},
+	"sbom":         {"SBOM"},
+	"sign":         {"Sign"},
+}
+
+// matchJobStage tell whether the job stage is run for the sub task, the stages of e2e suite and perf test
+// are named by the sub task, so they are matched by the name suffix too
+func matchJobStage(task *subTask, stageName string) bool {
+	for _, prefix := range subTaskJobStagePrefixes[task.Type] {
+		if !strings.HasPrefix(stageName, prefix) {
+			continue
+		}
+		if task.Type == "e2e-suite" || task.Type == "perf-test" {
+			return task.Name == "" || strings.HasSuffix(stageName, "-"+task.Name)
+		}
+		if task.Type == "artifact" && task.Artifact != nil {
+			return stageName == prefix+task.Artifact.Name
+		}
+		return true
+	}
+	return false
+}
+
+// buildGraphSubTasks merge the sub tasks defined by step with the job stages recorded for the job of step
+func buildGraphSubTasks(tasks []*subTask, jobStages []*models.PublishJobStage) []*GraphSubTask {
+	items := []*GraphSubTask{}
+	matched := map[int64]bool{}
+	for _, task := range tasks {
+		item := &GraphSubTask{Index: task.Index, Name: task.Name, Type: task.Type}
+		for _, stage := range jobStages {
+			if matched[stage.ID] || !matchJobStage(task, stage.Name) {
+				continue
+			}
+			matched[stage.ID] = true
+			mergeJobStage(item, stage)
+		}
+		items = append(items, item)
+	}
+	for _, stage := range jobStages {
+		if matched[stage.ID] || stage.Name == jobStageCallback {
+			continue
+		}
+		item := &GraphSubTask{Name: stage.Name}
+		mergeJobStage(item, stage)
+		items = append(items, item)
+	}
+	return items
+}
+
+// mergeJobStage merge the job stage into sub task, a sub task may run as several stages such as images and manifests,
+// the earliest start, the latest end and the worst status are kept
+func mergeJobStage(item *GraphSubTask, stage *models.PublishJobStage) {
+	if item.StartAt == nil || stage.StartAt.Before(*item.StartAt) {
+		startAt := stage.StartAt
+		item.StartAt = &startAt
+	}
+	if item.EndAt == nil || stage.EndAt.After(*item.EndAt) {
+		endAt := stage.EndAt
+		item.EndAt = &endAt
+	}
+	item.DurationInMillis = item.EndAt.Sub(*item.StartAt).Milliseconds()
+	if item.Status == "" || item.Status == models.StatusSuccess {
+		item.Status = stage.Status
+	}
+	if stage.FailureCause != "" {
+		item.FailureCause, item.FailureMessage = stage.FailureCause, stage.FailureMessage
+	}
+}
+
+// graphStageStatus derive the status of stage from its steps, the stage not started yet is pending
+func graphStageStatus(steps []*GraphStep, current bool) int64 {
+	started, allSuccess := false, len(steps) > 0
+	for _, step := range steps {
+		switch step.Status {
+		case models.Running, models.Failed, models.TerminateSuccess, models.TerminateFailed, models.MergeFailed:
+			return step.Status
+		case models.Success:
+			started = true
+		default:
+			allSuccess = false
+		}
+	}
+	switch {
+	case allSuccess:
+		return models.Success
+	case started || current:
+		return models.Running
+	default:
+		return models.Pending
+	}
+}
+
+// GetPublishGraph return the stage/step/sub task graph of the last pipeline instance of publish,
+// the steps run after the last back-to/next-stage of their stage are taken
+func (pm *PipelineManager) GetPublishGraph(projectID, publishID int64) (*PublishGraphRsp, error) {
+	publish, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	if publish.ProjectID != projectID {
+		return nil, fmt.Errorf("流水线 %v 不属于项目 %v", publishID, projectID)
+	}
+	config, err := pm.GetPipelineInstanceJSONByID(publish.LastPipelineInstanceID)
+	if err != nil {
+		log.Log.Error("get pipeline instance %v config occur error: %s", publish.LastPipelineInstanceID, err.Error())
+		return nil, err
+	}
+	operationLogs, err := pm.modelPublish.GetOperationLogsByInstanceID(publish.LastPipelineInstanceID)
+	if err != nil {
+		log.Log.Error("get pipeline instance %v operation logs occur error: %s", publish.LastPipelineInstanceID, err.Error())
+		return nil, err
+	}
+	jobs, err := pm.modelPublishJob.GetPublishJobsByPublishID(publishID)
+	if err != nil {
+		log.Log.Error("get publish %v jobs occur error: %s", publishID, err.Error())
+		return nil, err
+	}
+	jobStages, err := pm.modelJobStage.GetPublishJobStages(publishID)
+	if err != nil {
+		log.Log.Error("get publish %v job stages occur error: %s", publishID, err.Error())
+		return nil, err
+	}
+
+	// the step states and the time of last operation of each stage, reset by the restart of stage
+	states := map[int64]map[int]*models.PublishOperationLog{}
+	restartAt := map[int64]time.Time{}
+	for _, item := range operationLogs {
+		if stageRestartLabels[item.Step] {
+			states[item.StageID] = map[int]*models.PublishOperationLog{}
+			restartAt[item.StageID] = item.CreateAt
+			continue
+		}
+		if item.Status == models.Skipped {
+			continue
+		}
+		if states[item.StageID] == nil {
+			states[item.StageID] = map[int]*models.PublishOperationLog{}
+		}
+		states[item.StageID][item.StepIndex] = item
+	}
+	stagesOfJob := map[int64][]*models.PublishJobStage{}
+	for _, stage := range jobStages {
+		stagesOfJob[stage.PublishJobID] = append(stagesOfJob[stage.PublishJobID], stage)
+	}
+
+	rsp := &PublishGraphRsp{
+		PublishID:          publish.ID,
+		PipelineInstanceID: publish.LastPipelineInstanceID,
+		Status:             publish.Status,
+		CurrentStageID:     publish.StageID,
+		CurrentStepIndex:   publish.StepIndex,
+		Stages:             []*GraphStage{},
+	}
+	sort.SliceStable(config, func(i, j int) bool { return config[i].Index < config[j].Index })
+	for _, stageJSON := range config {
+		stage := &GraphStage{StageID: stageJSON.StageID, Name: stageJSON.Name, Index: stageJSON.Index, Steps: []*GraphStep{}}
+		deps := stageJSON.Steps.Dependencies()
+		for _, stepJSON := range stageJSON.Steps {
+			step := &GraphStep{
+				Index:     stepJSON.Index,
+				Name:      stepJSON.Name,
+				Type:      stepJSON.Type,
+				DependsOn: deps[stepJSON.Index],
+				Status:    models.Pending,
+			}
+			if item, ok := states[stageJSON.StageID][stepJSON.Index]; ok {
+				step.Status = item.Status
+				endAt := item.CreateAt
+				step.EndAt = &endAt
+			}
+			if publish.StageID == stageJSON.StageID && publish.StepIndex == stepJSON.Index {
+				step.Status = publish.Status
+			}
+			for _, job := range jobs {
+				if job.EnvID != stageJSON.StageID || job.StepIndex != stepJSON.Index || job.CreateAt.Before(restartAt[stageJSON.StageID]) {
+					continue
+				}
+				startAt := job.CreateAt
+				step.StartAt = &startAt
+				step.EndAt = nil
+				if job.DurationInMillis > 0 {
+					endAt := startAt.Add(time.Duration(job.DurationInMillis) * time.Millisecond)
+					step.EndAt = &endAt
+				}
+				step.JobID, step.JobStatus, step.RunID = job.ID, job.Status, job.RunID
+				step.LogURL = fmt.Sprintf("/atomci/api/v1/pipelines/%d/publishes/%d/jobs/%d/log", projectID, publishID, job.ID)
+				break
+			}
+			var recorded []*models.PublishJobStage
+			if step.JobID > 0 {
+				recorded = stagesOfJob[step.JobID]
+			}
+			step.SubTasks = buildGraphSubTasks(stepJSON.SubTask, recorded)
+			if step.StartAt != nil && step.EndAt != nil {
+				step.DurationInMillis = step.EndAt.Sub(*step.StartAt).Milliseconds()
+			}
+
+			if step.StartAt != nil && (stage.StartAt == nil || step.StartAt.Before(*stage.StartAt)) {
+				stage.StartAt = step.StartAt
+			}
+			if step.EndAt != nil && (stage.EndAt == nil || step.EndAt.After(*stage.EndAt)) {
+				stage.EndAt = step.EndAt
+			}
+			stage.Steps = append(stage.Steps, step)
+		}
+		sort.SliceStable(stage.Steps, func(i, j int) bool { return stage.Steps[i].Index < stage.Steps[j].Index })
+		stage.Status = graphStageStatus(stage.Steps, publish.StageID == stageJSON.StageID)
+		if stage.Status == models.Running {
+			stage.EndAt = nil
+		}
+		if stage.StartAt != nil && stage.EndAt != nil {
+			stage.DurationInMillis = stage.EndAt.Sub(*stage.StartAt).Milliseconds()
+		}
+		rsp.Stages = append(rsp.Stages, stage)
+	}
+	return rsp, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestBuildGraphSubTasks(t *testing.T) {
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	jobStage := func(id int64, name, status string, offset, seconds int) *models.PublishJobStage {
+		stage := &models.PublishJobStage{Name: name, Status: status}
+		stage.ID = id
+		stage.StartAt = start.Add(time.Duration(offset) * time.Second)
+		stage.EndAt = stage.StartAt.Add(time.Duration(seconds) * time.Second)
+		return stage
+	}
+	tasks := []*subTask{
+		{Index: 1, Name: "代码检出", Type: "checkout"},
+		{Index: 2, Name: "镜像制作", Type: "build-image"},
+		{Index: 3, Name: "smoke", Type: "e2e-suite"},
+		{Index: 4, Name: "regression", Type: "e2e-suite"},
+	}
+	stages := []*models.PublishJobStage{
+		jobStage(1, "Checkout", models.StatusSuccess, 0, 10),
+		jobStage(2, "Images", models.StatusSuccess, 10, 20),
+		jobStage(3, "Manifests", models.StatusFailure, 30, 5),
+		jobStage(4, "E2E-1-regression", models.StatusSuccess, 35, 5),
+		jobStage(5, "Custom", models.StatusSuccess, 40, 1),
+		jobStage(6, jobStageCallback, models.StatusSuccess, 41, 1),
+	}
+	stages[2].FailureCause = models.FailureCauseImagePush
+
+	got := buildGraphSubTasks(tasks, stages)
+	if len(got) != 5 {
+		t.Fatalf("buildGraphSubTasks() got %v sub tasks, want 5", len(got))
+	}
+	if got[1].Status != models.StatusFailure || got[1].DurationInMillis != 25000 || got[1].FailureCause != models.FailureCauseImagePush {
+		t.Errorf("build-image sub task = %+v, want merged images and manifests", got[1])
+	}
+	if got[2].StartAt != nil || got[3].Status != models.StatusSuccess {
+		t.Errorf("e2e sub tasks = %+v, %+v, want matched by suite name", got[2], got[3])
+	}
+	if got[4].Name != "Custom" || got[4].Index != 0 {
+		t.Errorf("unmatched job stage = %+v, want appended", got[4])
+	}
+}
+
+func TestGraphStageStatus(t *testing.T) {
+	steps := func(states ...int64) []*GraphStep {
+		items := []*GraphStep{}
+		for _, state := range states {
+			items = append(items, &GraphStep{Status: state})
+		}
+		return items
+	}
+	tests := []struct {
+		name    string
+		steps   []*GraphStep
+		current bool
+		want    int64
+	}{
+		{name: "not started", steps: steps(models.Pending, models.Pending), want: models.Pending},
+		{name: "current", steps: steps(models.Pending, models.Pending), current: true, want: models.Running},
+		{name: "partial", steps: steps(models.Success, models.Pending), want: models.Running},
+		{name: "failed", steps: steps(models.Success, models.Failed), want: models.Failed},
+		{name: "success", steps: steps(models.Success, models.Success), want: models.Success},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphStageStatus(tt.steps, tt.current); got != tt.want {
+				t.Errorf("graphStageStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
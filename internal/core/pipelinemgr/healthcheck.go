@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+var (
+	healthCheckTimeout = time.Duration(beego.AppConfig.DefaultInt("healthcheck::timeout", 600)) * time.Second
+)
+
+// healthCheckTimeoutOf the annotation of resource overrides the timeout of kind, eg: healthcheck::job_timeout
+func healthCheckTimeoutOf(item *kuberes.RolloutStatus) time.Duration {
+	if item.Timeout > 0 {
+		return item.Timeout
+	}
+	key := fmt.Sprintf("healthcheck::%s_timeout", strings.ToLower(item.Kind))
+	return time.Duration(beego.AppConfig.DefaultInt(key, int(healthCheckTimeout/time.Second))) * time.Second
+}
+
+// DeployHealthResult ..
+type DeployHealthResult struct {
+	JobStatus     string
+	PublishStatus int64
+	Progress      int
+	Message       string
+	Items         []*kuberes.RolloutStatus
+}
+
+// getDeployHealthCheckItems return the workloads of the apps which deployed by publish job
+func (pm *PipelineManager) getDeployHealthCheckItems(job *models.PublishJob) ([]kuberes.AppResourceItem, error) {
+	jobApps, err := pm.modelPublishJob.GetPublishJobApps(job.ID)
+	if err != nil {
+		log.Log.Error("when get health check items, get publish job: %v apps occur error: %s", job.ID, err.Error())
+		return nil, err
+	}
+	items := []kuberes.AppResourceItem{}
+	for _, app := range jobApps {
+		if job.Wave > 0 && app.Wave > job.Wave {
+			// the app of later wave is not applied yet
+			continue
+		}
+		appArrange, err := pm.appHandler.GetRealArrange(app.ProjectAPPID, job.EnvID)
+		if err != nil {
+			log.Log.Warn("get app id: %v, env id: %v arrange occur error: %s", app.ProjectAPPID, job.EnvID, err.Error())
+			continue
+		}
+		arrangeConfig, err := pm.appHandler.RenderRealArrange(appArrange, "", "")
+		if err != nil {
+			log.Log.Warn("render app id: %v, env id: %v arrange occur error: %s", app.ProjectAPPID, job.EnvID, err.Error())
+			continue
+		}
+		native := &kuberes.NativeTemplate{
+			Template: arrangeConfig,
+		}
+		appResItems, err := native.GetAppResourceNames()
+		if err != nil {
+			log.Log.Warn("parse app arrange occur error: %s", err.Error())
+			continue
+		}
+		items = append(items, appResItems...)
+	}
+	return items, nil
+}
+
+// checkWorkloadsHealth return the rollout status of the workloads applied to cluster directly
+func (pm *PipelineManager) checkWorkloadsHealth(job *models.PublishJob, envStage *models.ProjectEnv) ([]*kuberes.RolloutStatus, error) {
+	clusterItem, err := pm.settingsHandler.GetIntegrateSettingByID(envStage.Cluster)
+	if err != nil {
+		log.Log.Error("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
+		return nil, err
+	}
+	items, err := pm.getDeployHealthCheckItems(job)
+	if err != nil {
+		return nil, err
+	}
+
+	checker, err := kuberes.NewHealthChecker(clusterItem.Name, envStage.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return checker.Check(items)
+}
+
+// CheckDeployJobHealth poll the rollout status of the apps deployed by publish job once,
+// the job was regarded as failure when its workloads did not become ready before timeout.
+// the job deployed in waves applies the next wave when the apps of current wave are ready.
+func (pm *PipelineManager) CheckDeployJobHealth(job *models.PublishJob) (*DeployHealthResult, error) {
+	envStage, err := pm.modelProject.GetProjectEnvByID(job.EnvID)
+	if err != nil {
+		log.Log.Error("when check deploy job health, get project env by id: %v, occur error: %s", job.EnvID, err.Error())
+		return nil, err
+	}
+	var statusItems []*kuberes.RolloutStatus
+	if envStage.ArgoCD != 0 {
+		appStatus, err := pm.argoCDApplicationStatus(envStage)
+		if err != nil {
+			return nil, err
+		}
+		statusItems = []*kuberes.RolloutStatus{appStatus}
+	} else {
+		statusItems, err = pm.checkWorkloadsHealth(job, envStage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DeployHealthResult{
+		JobStatus:     models.StatusRunning,
+		PublishStatus: models.Running,
+		Progress:      100,
+		Items:         statusItems,
+	}
+	ready := 0
+	messages := []string{}
+	elapsed := time.Since(job.CreateAt)
+	if job.WaveStartAt != nil {
+		elapsed = time.Since(*job.WaveStartAt)
+	}
+	for _, item := range statusItems {
+		if item.Ready {
+			ready++
+			continue
+		}
+		if timeout := healthCheckTimeoutOf(item); !item.Failed && elapsed > timeout {
+			item.Failed = true
+			item.Message = fmt.Sprintf("health check timeout after %v, %s", timeout, item.Message)
+		}
+		messages = append(messages, fmt.Sprintf("%s/%s: %s", item.Kind, item.Name, item.Message))
+		if item.Failed {
+			result.JobStatus = models.StatusFailure
+			result.PublishStatus = models.Failed
+		}
+	}
+	result.Message = strings.Join(messages, "; ")
+	if len(statusItems) > 0 {
+		result.Progress = ready * 100 / len(statusItems)
+	}
+
+	lastWave := 0
+	if job.Wave > 0 {
+		jobApps, err := pm.modelPublishJob.GetPublishJobApps(job.ID)
+		if err != nil {
+			return nil, err
+		}
+		if lastWave = lastDeployWave(jobApps); lastWave >= job.Wave {
+			result.Progress = ((job.Wave-1)*100 + result.Progress) / lastWave
+		}
+	}
+
+	switch {
+	case result.JobStatus == models.StatusFailure:
+	case ready == len(statusItems) && job.Wave < lastWave:
+		if err := pm.applyNextDeployWave(job, envStage); err != nil {
+			result.JobStatus = models.StatusFailure
+			result.PublishStatus = models.Failed
+			result.Message = fmt.Sprintf("apply deploy wave %d failed: %s", job.Wave+1, err.Error())
+		}
+	case ready == len(statusItems):
+		result.JobStatus = models.StatusSuccess
+		result.PublishStatus = models.Success
+	}
+	return result, nil
+}
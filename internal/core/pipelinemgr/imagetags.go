@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// digestPrefix the image tag selected by digest, eg: sha256:xxx
+const digestPrefix = "sha256:"
+
+// GetAppImageTags return the tags of the app image repository in the registry of stage
+func (pm *PipelineManager) GetAppImageTags(projectID, stageID, projectAppID int64) (*AppImageTagsRsp, error) {
+	projectApp, err := pm.modelProject.GetProjectApp(projectAppID)
+	if err != nil {
+		return nil, err
+	}
+	if projectID > 0 && projectApp.ProjectID != projectID {
+		return nil, fmt.Errorf("应用 %v 不属于当前项目", projectAppID)
+	}
+	arrange, err := pm.appHandler.GetRealArrange(projectAppID, stageID)
+	if err != nil {
+		return nil, fmt.Errorf("获取应用: %v 环境: %v 编排失败: %s", projectAppID, stageID, err.Error())
+	}
+	imageMapping, err := pm.modelAppArrange.GetAppImageMappingByArrangeIDAndProjectAppID(arrange.ID, projectAppID)
+	if err != nil {
+		return nil, fmt.Errorf("获取应用: %v 镜像配置失败: %s", projectAppID, err.Error())
+	}
+	provider, err := pm.getEnvRegistryProvider(stageID)
+	if err != nil {
+		return nil, err
+	}
+	image, _ := removeImageUrlTag(imageMapping.Image)
+	_, repo, _ := splitImage(image)
+	tags, err := provider.ListTags(repo)
+	if err != nil {
+		return nil, fmt.Errorf("获取镜像 %v 版本列表失败: %s", image, err.Error())
+	}
+	// the default tag is `branch-commit`, show the latest name first
+	sort.Sort(sort.Reverse(sort.StringSlice(tags)))
+	return &AppImageTagsRsp{
+		ProjectAppID: projectAppID,
+		Image:        image,
+		Tags:         tags,
+	}, nil
+}
+
+// deployImageAddr return the image to deploy and the origin image of arrange, the explicit tag of request takes precedence
+func (pm *PipelineManager) deployImageAddr(app *RunDeployAppReq, arrangeID int64, publishApp *models.PublishApp) (string, string, error) {
+	imageMapping, err := pm.modelAppArrange.GetAppImageMappingByArrangeIDAndProjectAppID(arrangeID, app.ProjectAppID)
+	if err != nil {
+		log.Log.Error("get imagemapping error: %s", err.Error())
+		return "", "", err
+	}
+	return pm.deployImageAddrOfMapping(app, imageMapping, publishApp)
+}
+
+// deployImageAddrOfMapping same as deployImageAddr with the image mapping fetched already
+func (pm *PipelineManager) deployImageAddrOfMapping(app *RunDeployAppReq, imageMapping *models.AppImageMapping, publishApp *models.PublishApp) (string, string, error) {
+	if app.ImageTag == "" && app.BuiltTag != "" && imageMapping.ImageTagType == models.SystemDefaultTag {
+		// the branch head may have moved after the build, or the app was skipped by the latest build
+		newImageAddr, err := withImageTag(imageMapping.Image, app.BuiltTag)
+		return newImageAddr, imageMapping.Image, err
+	}
+	newImageAddr, originImage, err := pm.imageAddrOfMapping(imageMapping, app.ProjectAppID, PublishAppBuildBranch(publishApp))
+	if err != nil || app.ImageTag == "" {
+		return newImageAddr, originImage, err
+	}
+	newImageAddr, err = withImageTag(newImageAddr, app.ImageTag)
+	return newImageAddr, originImage, err
+}
+
+// withImageTag replace the tag of image, the tag starts with `sha256:` was regarded as digest,
+// the tag pinned to digest is in format `tag@sha256:xxx`
+func withImageTag(image, tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	origin, digest := tag, ""
+	if index := strings.Index(tag, "@"); index != -1 {
+		tag, digest = tag[:index], tag[index+1:]
+	} else if strings.HasPrefix(tag, digestPrefix) {
+		tag, digest = "", tag
+	}
+	if strings.ContainsAny(tag, " /@:") || (digest != "" && !strings.HasPrefix(digest, digestPrefix)) {
+		return "", fmt.Errorf("无效的镜像版本: %v", origin)
+	}
+	if index := strings.Index(image, "@"); index != -1 {
+		image = image[:index]
+	}
+	name, _ := removeImageUrlTag(image)
+	if tag != "" {
+		name = name + ":" + tag
+	}
+	if digest != "" {
+		name = name + "@" + digest
+	}
+	return name, nil
+}
+
+// imageTagOfAddr the reverse of withImageTag, return the tag(or `tag@sha256:xxx`) of image address
+func imageTagOfAddr(image string) string {
+	digest := ""
+	if index := strings.Index(image, "@"); index != -1 {
+		image, digest = image[:index], image[index+1:]
+	}
+	tag := ""
+	if index := strings.LastIndex(image, ":"); index != -1 && !strings.Contains(image[index+1:], "/") {
+		tag = image[index+1:]
+	}
+	switch {
+	case tag != "" && digest != "":
+		return tag + "@" + digest
+	case digest != "":
+		return digest
+	}
+	return tag
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/astaxie/beego"
+)
+
+// the ci jobs of each project are created in its own jenkins folder when folder isolation is enabled,
+// the folder is created on demand and named by the prefix and the project id
+var (
+	jenkinsFolderIsolation = beego.AppConfig.DefaultBool("jenkins::folder_isolation", false)
+	jenkinsFolderPrefix    = beego.AppConfig.DefaultString("jenkins::folder_prefix", "atomci-project-")
+)
+
+const jenkinsFolderXML = `<?xml version='1.1' encoding='UTF-8'?>
+<com.cloudbees.hudson.plugins.folder.Folder plugin="cloudbees-folder">
+  <description>%s</description>
+</com.cloudbees.hudson.plugins.folder.Folder>`
+
+// BuildJobName the jenkins job name of build job
+func BuildJobName(projectID, publishID, stageID int64) string {
+	return jenkinsJobPath(projectID, fmt.Sprintf("atomci_%v_%v_%v", projectID, publishID, stageID))
+}
+
+// jenkinsFolder the jenkins folder and the agent label of project, empty when folder isolation is disabled
+func jenkinsFolder(projectID int64) string {
+	if !jenkinsFolderIsolation {
+		return ""
+	}
+	return fmt.Sprintf("%s%v", jenkinsFolderPrefix, projectID)
+}
+
+// jenkinsJobPath the job path relative to the jenkins url, the urls of job are built as <url>/job/<path>
+func jenkinsJobPath(projectID int64, name string) string {
+	folder := jenkinsFolder(projectID)
+	if folder == "" {
+		return name
+	}
+	return folder + "/job/" + name
+}
+
+// splitJenkinsJobPath return the folder and the name of job path, folder is empty for the top level job
+func splitJenkinsJobPath(path string) (string, string) {
+	index := strings.LastIndex(path, "/job/")
+	if index < 0 {
+		return "", path
+	}
+	return path[:index], path[index+len("/job/"):]
+}
+
+// ensureFolder create the folder of job when it does not exist
+func (j *jenkinsJob) ensureFolder(folder string) error {
+	_, err := j.request("GET", fmt.Sprintf("%v/job/%v/api/json", j.url, folder), xmlContentType, nil)
+	if err != errJenkinsJobNotFound {
+		return err
+	}
+	parent, name := splitJenkinsJobPath(folder)
+	configXML := fmt.Sprintf(jenkinsFolderXML, "The ci jobs of AtomCI project, managed by AtomCI")
+	_, err = j.request("POST", fmt.Sprintf("%v/createItem?name=%v", j.parentURL(parent), name), xmlContentType, bytes.NewBufferString(configXML))
+	return err
+}
+
+// parentURL the url of the folder, the jenkins url for the top level
+func (j *jenkinsJob) parentURL(folder string) string {
+	if folder == "" {
+		return j.url
+	}
+	return fmt.Sprintf("%v/job/%v", j.url, folder)
+}
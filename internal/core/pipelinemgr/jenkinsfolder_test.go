@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestJenkinsJobInFolder(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	folder, name := splitJenkinsJobPath("atomci-project-1/job/atomci_1_2_3")
+	if folder != "atomci-project-1" || name != "atomci_1_2_3" {
+		t.Fatalf("splitJenkinsJobPath() = %v, %v", folder, name)
+	}
+	job := &jenkinsJob{url: server.URL, name: "atomci-project-1/job/atomci_1_2_3"}
+	if err := job.createOrUpdate("<flow-definition/>"); err != nil {
+		t.Fatalf("createOrUpdate() error: %v", err)
+	}
+	want := []string{
+		"GET /job/atomci-project-1/job/atomci_1_2_3/api/json",
+		"GET /job/atomci-project-1/api/json",
+		"POST /createItem?name=atomci-project-1",
+		"POST /job/atomci-project-1/createItem?name=atomci_1_2_3",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/workflow"
+)
+
+// maxJobLogBytes the max bytes of the job log returned once, the client continues from NextStart
+const maxJobLogBytes = 512 * 1024
+
+// JobLog the piece of the console log of job run
+type JobLog struct {
+	JobID  int64  `json:"job_id"`
+	Status string `json:"status"`
+	Text   string `json:"text"`
+	// NextStart the offset to get the following log
+	NextStart int64 `json:"next_start"`
+	// More whether the job is still writing the log
+	More bool `json:"more"`
+}
+
+// GetJobLog get the console log of the build/e2e-test job from the offset, it's the progressive text of jenkins
+func (pm *PipelineManager) GetJobLog(job *models.PublishJob, start int64) (*JobLog, error) {
+	if job.JobType == models.JobTypeDeploy {
+		return nil, fmt.Errorf("部署任务没有构建日志，请查看应用的容器日志")
+	}
+	jobLog := &JobLog{JobID: job.ID, Status: job.Status, NextStart: start}
+	if job.RunID == 0 {
+		// the job is waiting for the jenkins executor
+		jobLog.More = job.Status == models.StatusInit || job.Status == models.StatusRunning
+		return jobLog, nil
+	}
+	CIInfo, err := pm.GetJobCIConfig(job)
+	if err != nil {
+		return nil, err
+	}
+	if CIInfo.Driver != workflow.DriverJenkins.String() {
+		if err := getPluginJobLog(CIInfo, jenkinsJobName(job), jobLog, job.RunID); err != nil {
+			return nil, err
+		}
+		return jobLog, nil
+	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
+	url := fmt.Sprintf("%v/job/%v/%v/logText/progressiveText?start=%v", strings.TrimSuffix(addr, "/"), jenkinsJobName(job), job.RunID, start)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(user, token)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get progressive log response status: %v", resp.Status)
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxJobLogBytes))
+	if err != nil {
+		return nil, err
+	}
+	jobLog.Text = string(content)
+	jobLog.More = resp.Header.Get("X-More-Data") == "true"
+	jobLog.NextStart = start + int64(len(content))
+	if len(content) == maxJobLogBytes {
+		// the log is truncated, the rest is returned by the next request
+		jobLog.More = true
+	} else if size, err := strconv.ParseInt(resp.Header.Get("X-Text-Size"), 10, 64); err == nil {
+		jobLog.NextStart = size
+	}
+	return jobLog, nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/go-atomci/workflow/jenkins"
+)
+
+// jobStageCallback the callback stage of jenkins pipeline is not a sub task
+const jobStageCallback = "Callback"
+
+// jobStageHealthCheck the stage recorded for the deploy job, which waits for the workloads ready inside atomci
+const jobStageHealthCheck = "HealthCheck"
+
+// maxConsoleLogBytes the tail of the console log parsed to classify the failure
+const maxConsoleLogBytes = 64 * 1024
+
+// stageFailureCauses the failure causes of the stages defined by the default build pipeline
+var stageFailureCauses = map[string]string{
+	"Checkout": models.FailureCauseCheckout,
+	"Builds":   models.FailureCauseCompile,
+	"Images":   models.FailureCauseImagePush,
+}
+
+type failureRule struct {
+	cause   string
+	pattern *regexp.Regexp
+}
+
+// failureRules the log patterns of the failure causes, the former takes precedence
+var failureRules = []*failureRule{
+	{models.FailureCauseImagePush, regexp.MustCompile(`(?i)error pushing image|failed to push|push access denied|denied: requested access|error checking push permissions|unauthorized: authentication required`)},
+	{models.FailureCauseCheckout, regexp.MustCompile(`(?i)fatal: (could not read|repository .* not found|couldn't find remote ref)|authentication failed for|could not resolve host`)},
+	{models.FailureCauseCompile, regexp.MustCompile(`(?i)compilation (failed|error)|build failure|npm err!|cannot find symbol|undefined: |syntax error|make: \*\*\*`)},
+	{models.FailureCauseTest, regexp.MustCompile(`(?i)tests? failed|--- fail:|pass rate`)},
+	{models.FailureCauseHealthCheckTimeout, regexp.MustCompile(`(?i)health check timeout|timed out|deadline exceeded`)},
+}
+
+// classifyFailure classify the failure by the failed stage and the log, return the cause and the line
+// of log explains it
+func classifyFailure(jobType, stageName, text string) (string, string) {
+	lines := strings.Split(text, "\n")
+	matchedLine := func(pattern *regexp.Regexp) string {
+		for _, line := range lines {
+			if pattern.MatchString(line) {
+				return strings.TrimSpace(line)
+			}
+		}
+		return ""
+	}
+
+	cause, message := "", ""
+	for _, rule := range failureRules {
+		if line := matchedLine(rule.pattern); line != "" {
+			cause, message = rule.cause, line
+			break
+		}
+	}
+	if stageCause, ok := stageFailureCauses[stageName]; ok {
+		cause = stageCause
+	}
+	if cause == "" {
+		cause = models.FailureCauseUnknown
+		if jobType == models.JobTypeDeploy {
+			cause = models.FailureCauseDeploy
+		}
+	}
+	if message == "" {
+		for i := len(lines) - 1; i >= 0; i-- {
+			if line := strings.TrimSpace(lines[i]); line != "" {
+				message = line
+				break
+			}
+		}
+	}
+	return cause, utils.Truncate(message, 512)
+}
+
+// RecordJobStages record the timings of the sub tasks run by the finished publish job and classify the failure,
+// the message is the result of the job run inside atomci, such as the health check of deploy job
+func (pm *PipelineManager) RecordJobStages(job *models.PublishJob, message string) {
+	if !utils.Contains([]string{models.StatusSuccess, models.StatusFailure}, job.Status) {
+		return
+	}
+	var stages []*models.PublishJobStage
+	var err error
+	if job.JobType == models.JobTypeDeploy {
+		stages = pm.deployJobStages(job, message)
+	} else {
+		stages, err = pm.jenkinsJobStages(job)
+	}
+	if err != nil {
+		log.Log.Warn("record publish job: %v stages occur error: %s", job.ID, err.Error())
+		return
+	}
+	if err := pm.modelJobStage.ReplaceJobStages(job.ID, stages); err != nil {
+		log.Log.Error("save publish job: %v stages occur error: %s", job.ID, err.Error())
+	}
+}
+
+func newJobStage(job *models.PublishJob, name, status string, startAt, endAt time.Time) *models.PublishJobStage {
+	return &models.PublishJobStage{
+		ProjectID:        job.ProjectID,
+		PublishID:        job.PublishID,
+		PublishJobID:     job.ID,
+		StageID:          job.EnvID,
+		JobType:          job.JobType,
+		Name:             name,
+		Status:           status,
+		StartAt:          startAt,
+		EndAt:            endAt,
+		DurationInMillis: endAt.Sub(startAt).Milliseconds(),
+	}
+}
+
+// deployJobStages the deploy job runs inside atomci, the whole job is regarded as the health check stage
+func (pm *PipelineManager) deployJobStages(job *models.PublishJob, message string) []*models.PublishJobStage {
+	stage := newJobStage(job, jobStageHealthCheck, job.Status, job.CreateAt, time.Now())
+	if job.Status == models.StatusFailure {
+		stage.FailureCause, stage.FailureMessage = classifyFailure(job.JobType, stage.Name, message)
+	}
+	return []*models.PublishJobStage{stage}
+}
+
+// jenkinsJobName the name of jenkins job run the publish job
+func jenkinsJobName(job *models.PublishJob) string {
+	if job.JobType == models.JobTypeE2ETest {
+		return E2ETestJobName(job.ProjectID, job.PublishID, job.EnvID)
+	}
+	return BuildJobName(job.ProjectID, job.PublishID, job.EnvID)
+}
+
+// jenkinsJobStages the stages of jenkins pipeline, the failure is classified by the first failed stage
+// and the tail of console log
+func (pm *PipelineManager) jenkinsJobStages(job *models.PublishJob) ([]*models.PublishJobStage, error) {
+	if job.RunID == 0 {
+		return nil, fmt.Errorf("job has not run")
+	}
+	CIInfo, err := pm.GetJobCIConfig(job)
+	if err != nil {
+		return nil, err
+	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
+	jobName := jenkinsJobName(job)
+	client, err := jenkins.NewJenkinsClient(
+		jenkins.URL(addr),
+		jenkins.JenkinsUser(user),
+		jenkins.JenkinsToken(token),
+		jenkins.JenkinsJob(jobName),
+	)
+	if err != nil {
+		return nil, err
+	}
+	jobInfo, err := client.GetJobInfo(job.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := []*models.PublishJobStage{}
+	var failedStage *models.PublishJobStage
+	for _, item := range jobInfo.Stages {
+		if item.Name == jobStageCallback || item.StartTimeMillis == 0 {
+			continue
+		}
+		startAt := time.Unix(0, item.StartTimeMillis*int64(time.Millisecond))
+		endAt := startAt.Add(time.Duration(item.DurationMillis) * time.Millisecond)
+		stage := newJobStage(job, item.Name, item.Status, startAt, endAt)
+		if failedStage == nil && (item.Status == "FAILED" || item.Status == "FAILURE") {
+			failedStage = stage
+		}
+		stages = append(stages, stage)
+	}
+	if job.Status != models.StatusFailure {
+		return stages, nil
+	}
+	consoleLog, err := jenkinsConsoleTail(addr, user, token, jobName, job.RunID)
+	if err != nil {
+		log.Log.Warn("get publish job: %v console log occur error: %s", job.ID, err.Error())
+	}
+	switch {
+	case failedStage != nil:
+	case len(stages) > 0:
+		// the stages passed but the job failed by the gate, such as the pass rate of e2e tests
+		failedStage = stages[len(stages)-1]
+	default:
+		// the pipeline failed before any stage started, such as the agent pod failed to create
+		failedStage = newJobStage(job, "Pipeline", job.Status, job.CreateAt, job.CreateAt.Add(time.Duration(job.DurationInMillis)*time.Millisecond))
+		stages = append(stages, failedStage)
+	}
+	failedStage.FailureCause, failedStage.FailureMessage = classifyFailure(job.JobType, failedStage.Name, consoleLog)
+	return stages, nil
+}
+
+// jenkinsConsoleTail return the tail of the console log of jenkins job run
+func jenkinsConsoleTail(addr, user, token, jobName string, runID int64) (string, error) {
+	url := fmt.Sprintf("%v/job/%v/%v/consoleText", strings.TrimSuffix(addr, "/"), jobName, runID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(user, token)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get console log response status: %v", resp.Status)
+	}
+	// the console log maybe huge, only keep the tail while reading
+	content := []byte{}
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(chunk)
+		content = append(content, chunk[:n]...)
+		if len(content) > maxConsoleLogBytes {
+			content = content[len(content)-maxConsoleLogBytes:]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(content), nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		jobType     string
+		stage       string
+		text        string
+		wantCause   string
+		wantMessage string
+	}{
+		{"stage", models.JobTypeBuild, "Builds", "[INFO] BUILD FAILURE\nfinished", models.FailureCauseCompile, "[INFO] BUILD FAILURE"},
+		{"image push", models.JobTypeBuild, "atomci-web", "step 1\nerror pushing image: unauthorized\n", models.FailureCauseImagePush, "error pushing image: unauthorized"},
+		{"checkout", models.JobTypeBuild, "app", "fatal: could not read Username for 'https://git'", models.FailureCauseCheckout, "fatal: could not read Username for 'https://git'"},
+		{"go compile", models.JobTypeBuild, "app", "./main.go:10:2: undefined: foo", models.FailureCauseCompile, "./main.go:10:2: undefined: foo"},
+		{"health check timeout", models.JobTypeDeploy, jobStageHealthCheck, "health check timeout after 10m0s, Deployment/web: 0/1 ready", models.FailureCauseHealthCheckTimeout, "health check timeout after 10m0s, Deployment/web: 0/1 ready"},
+		{"deploy", models.JobTypeDeploy, jobStageHealthCheck, "Deployment/web: CrashLoopBackOff", models.FailureCauseDeploy, "Deployment/web: CrashLoopBackOff"},
+		{"unknown", models.JobTypeBuild, "custom", "line one\n  last line  \n\n", models.FailureCauseUnknown, "last line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cause, message := classifyFailure(tt.jobType, tt.stage, tt.text)
+			if cause != tt.wantCause || message != tt.wantMessage {
+				t.Errorf("classifyFailure() = %v, %v, want %v, %v", cause, message, tt.wantCause, tt.wantMessage)
+			}
+		})
+	}
+}
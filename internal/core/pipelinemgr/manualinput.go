@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/go-atomci/workflow/jenkins"
+)
+
+// the kinds of manual step input field
+const (
+	ManualInputChoice  = "choice"
+	ManualInputText    = "text"
+	ManualInputBoolean = "boolean"
+)
+
+// manualInputEnvPrefix the prefix of the env vars which expose the input values to the subsequent jobs
+const manualInputEnvPrefix = "INPUT_"
+
+// maxManualInputLength the max length of the input value
+const maxManualInputLength = 256
+
+var manualInputKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// manualInput the input field of manual step filled in by the approver at approval time,
+// the value is exposed to the subsequent jobs of the pipeline instance as env var INPUT_<KEY>
+type manualInput struct {
+	Key      string   `json:"key,omitempty"`
+	Label    string   `json:"label,omitempty"`
+	Kind     string   `json:"kind,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Default  string   `json:"default,omitempty"`
+	Required bool     `json:"required,omitempty"`
+}
+
+// validate the definition of input field
+func (i *manualInput) validate() error {
+	if i == nil {
+		return fmt.Errorf("input 子任务缺少输入项配置")
+	}
+	if !manualInputKeyPattern.MatchString(i.Key) {
+		return fmt.Errorf("输入项标识: %v 无效，须以字母开头，只包含字母、数字和下划线", i.Key)
+	}
+	switch i.Kind {
+	case ManualInputChoice:
+		if len(i.Options) == 0 {
+			return fmt.Errorf("输入项 %v 至少包含一个选项", i.Key)
+		}
+		for _, option := range i.Options {
+			if err := checkManualInputValue(option); err != nil {
+				return fmt.Errorf("输入项 %v 的选项%s", i.Key, err.Error())
+			}
+		}
+	case ManualInputText, ManualInputBoolean:
+	default:
+		return fmt.Errorf("输入项 %v 不支持的类型: %v，可选值为 %v/%v/%v", i.Key, i.Kind, ManualInputChoice, ManualInputText, ManualInputBoolean)
+	}
+	if i.Default != "" {
+		if _, err := i.resolve(i.Default); err != nil {
+			return fmt.Errorf("输入项 %v 的默认值无效: %s", i.Key, err.Error())
+		}
+	}
+	return nil
+}
+
+// resolve verify the value filled in by the approver, the default value is taken when the value is empty
+func (i *manualInput) resolve(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		value = i.Default
+	}
+	if value == "" {
+		if i.Required {
+			return "", fmt.Errorf("请填写 %v", i.label())
+		}
+		return "", nil
+	}
+	if err := checkManualInputValue(value); err != nil {
+		return "", fmt.Errorf("%v %s", i.label(), err.Error())
+	}
+	switch i.Kind {
+	case ManualInputChoice:
+		if !utils.Contains(i.Options, value) {
+			return "", fmt.Errorf("%v 的值: %v 不在可选项 %v 中", i.label(), value, strings.Join(i.Options, "/"))
+		}
+	case ManualInputBoolean:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%v 的值: %v 须为 true/false", i.label(), value)
+		}
+		value = strconv.FormatBool(parsed)
+	}
+	return value, nil
+}
+
+func (i *manualInput) label() string {
+	if i.Label != "" {
+		return i.Label
+	}
+	return i.Key
+}
+
+// checkManualInputValue the value is rendered into the jenkins pipeline as string, multiple lines are not allowed
+func checkManualInputValue(value string) error {
+	if len(value) > maxManualInputLength {
+		return fmt.Errorf("长度不能超过 %v 个字符", maxManualInputLength)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("不能包含换行")
+	}
+	return nil
+}
+
+// validateManualInputKeys verify the keys of the input fields are unique in the step, case insensitive as the env var is upper case
+func validateManualInputKeys(tasks []*subTask) error {
+	keys := map[string]bool{}
+	for _, task := range tasks {
+		if task.Type != constant.StepSubTaskInput || task.Input == nil {
+			continue
+		}
+		key := strings.ToUpper(task.Input.Key)
+		if keys[key] {
+			return fmt.Errorf("输入项标识 %v 重复", task.Input.Key)
+		}
+		keys[key] = true
+	}
+	return nil
+}
+
+// manualInputs return the input fields defined by the sub tasks of manual step
+func manualInputs(tasks []*subTask) []*manualInput {
+	inputs := []*manualInput{}
+	for _, task := range tasks {
+		if task.Type == constant.StepSubTaskInput && task.Input != nil {
+			inputs = append(inputs, task.Input)
+		}
+	}
+	return inputs
+}
+
+// resolveManualInputs verify the values filled in by the approver against the input fields, the values of unknown keys are dropped
+func resolveManualInputs(inputs []*manualInput, values map[string]string) (map[string]string, error) {
+	resolved := map[string]string{}
+	for _, input := range inputs {
+		value, err := input.resolve(values[input.Key])
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			resolved[input.Key] = value
+		}
+	}
+	return resolved, nil
+}
+
+// getManualStepInputs return the input fields of the manual step of stage in the pipeline instance
+func (pm *PipelineManager) getManualStepInputs(instanceID, stageID int64, stepIndex int) ([]*manualInput, error) {
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(instanceID, stageID)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range stageJSON.Steps {
+		if step.Index == stepIndex && step.Type == models.StepManual {
+			return manualInputs(step.SubTask), nil
+		}
+	}
+	return []*manualInput{}, nil
+}
+
+// parseManualInputValues parse the input values stored on the operation log
+func parseManualInputValues(inputs string) map[string]string {
+	values := map[string]string{}
+	if inputs == "" {
+		return values
+	}
+	if err := json.Unmarshal([]byte(inputs), &values); err != nil {
+		log.Log.Warn("parse manual step inputs: %v occur error: %s", inputs, err.Error())
+	}
+	return values
+}
+
+// manualInputEnvVars return the input values filled in the manual steps of pipeline instance as env vars of jobs,
+// the value filled in later overrides the former one of the same key
+func (pm *PipelineManager) manualInputEnvVars(instanceID int64) ([]jenkins.EnvItem, error) {
+	operationLogs, err := pm.modelPublish.GetOperationLogsByInstanceID(instanceID)
+	if err != nil {
+		log.Log.Error("get pipeline instance %v operation logs occur error: %s", instanceID, err.Error())
+		return nil, err
+	}
+	keys := []string{}
+	values := map[string]string{}
+	for _, item := range operationLogs {
+		inputs := parseManualInputValues(item.Inputs)
+		inputKeys := []string{}
+		for key := range inputs {
+			inputKeys = append(inputKeys, key)
+		}
+		sort.Strings(inputKeys)
+		for _, key := range inputKeys {
+			envKey := manualInputEnvPrefix + strings.ToUpper(key)
+			if _, ok := values[envKey]; !ok {
+				keys = append(keys, envKey)
+			}
+			values[envKey] = inputs[key]
+		}
+	}
+	envVars := []jenkins.EnvItem{}
+	for _, key := range keys {
+		envVars = append(envVars, jenkins.EnvItem{Key: key, Value: groovyEscape(values[key])})
+	}
+	return envVars, nil
+}
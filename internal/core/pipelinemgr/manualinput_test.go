@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/core/rbac"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestManualInputValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   *manualInput
+		wantErr bool
+	}{
+		{name: "choice", input: &manualInput{Key: "shard", Kind: ManualInputChoice, Options: []string{"cn-1", "cn-2"}, Default: "cn-1"}},
+		{name: "text", input: &manualInput{Key: "ticket_id", Kind: ManualInputText}},
+		{name: "boolean", input: &manualInput{Key: "dry_run", Kind: ManualInputBoolean, Default: "true"}},
+		{name: "missing", wantErr: true},
+		{name: "invalid key", input: &manualInput{Key: "1shard", Kind: ManualInputText}, wantErr: true},
+		{name: "unknown kind", input: &manualInput{Key: "shard", Kind: "number"}, wantErr: true},
+		{name: "choice without options", input: &manualInput{Key: "shard", Kind: ManualInputChoice}, wantErr: true},
+		{name: "default not in options", input: &manualInput{Key: "shard", Kind: ManualInputChoice, Options: []string{"cn-1"}, Default: "cn-3"}, wantErr: true},
+		{name: "invalid boolean default", input: &manualInput{Key: "dry_run", Kind: ManualInputBoolean, Default: "yes"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.input.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveManualInputs(t *testing.T) {
+	inputs := []*manualInput{
+		{Key: "shard", Kind: ManualInputChoice, Options: []string{"cn-1", "cn-2"}, Required: true},
+		{Key: "dry_run", Kind: ManualInputBoolean, Default: "false"},
+		{Key: "note", Kind: ManualInputText},
+	}
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "filled", values: map[string]string{"shard": "cn-2", "dry_run": "1", "note": " hotfix ", "unknown": "x"}, want: map[string]string{"shard": "cn-2", "dry_run": "true", "note": "hotfix"}},
+		{name: "default", values: map[string]string{"shard": "cn-1"}, want: map[string]string{"shard": "cn-1", "dry_run": "false"}},
+		{name: "required", values: map[string]string{}, wantErr: true},
+		{name: "not in options", values: map[string]string{"shard": "us-1"}, wantErr: true},
+		{name: "multiple lines", values: map[string]string{"shard": "cn-1", "note": "a\nb"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveManualInputs(inputs, tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveManualInputs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveManualInputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateInputSubTasks(t *testing.T) {
+	input := func(key string) *subTask {
+		return &subTask{Type: constant.StepSubTaskInput, Input: &manualInput{Key: key, Kind: ManualInputText}}
+	}
+	steps, _ := PipelineSteps{}.Struct(`[{"index":1,"type":"manual"},{"index":2,"type":"build"}]`)
+	steps[0].SubTask = []*subTask{input("shard"), input("SHARD")}
+	if err := steps.validateSubTasks(); err == nil {
+		t.Errorf("validateSubTasks() want error of duplicated keys")
+	}
+	steps[0].SubTask = []*subTask{input("shard")}
+	if err := steps.validateSubTasks(); err != nil {
+		t.Errorf("validateSubTasks() error = %v", err)
+	}
+	steps[1].SubTask = []*subTask{input("shard")}
+	if err := steps.validateSubTasks(); err == nil {
+		t.Errorf("validateSubTasks() want error of input sub task in build step")
+	}
+}
+
+func TestRunManualStepApprovers(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	stageID := insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 1, Name: "uat"})
+	config := fmt.Sprintf(`[{"stage_id":%d,"steps":[{"index":1,"name":"review","type":"manual"}]}]`, stageID)
+	instanceID := insertTestItem(t, &models.PipelineInstance{Addons: models.NewAddons(), Config: config})
+	publishID := insertTestItem(t, &models.Publish{Addons: models.NewAddons(), StageID: stageID, StepIndex: 1, Status: models.Running, LastPipelineInstanceID: instanceID, Approvers: "alice,bob"})
+
+	status, _, err := pm.RunManualStep(publishID, stageID, "carol", &ManualStepReq{Status: "success"})
+	if status != models.Skipped || err == nil || !strings.Contains(err.Error(), "仅审批人 alice,bob") {
+		t.Errorf("RunManualStep() by other user = %v, %v", status, err)
+	}
+	if status, _, err := pm.RunManualStep(publishID, stageID, "bob", &ManualStepReq{Status: "success"}); status != models.Success || err != nil {
+		t.Errorf("RunManualStep() by approver = %v, %v", status, err)
+	}
+	if status, _, err := pm.RunManualStep(publishID, stageID, "alice", &ManualStepReq{Status: "failed"}); status != models.Failed || err != nil {
+		t.Errorf("RunManualStep() rejected by approver = %v, %v", status, err)
+	}
+
+	// the approvers are restricted by the env permissions, the automation actor names are not trusted
+	insertTestItem(t, &models.GroupRole{Addons: models.NewAddons(), Group: constant.SystemGroup, Role: "deployer"})
+	if err := dao.SetRoleEnvPermissions(constant.SystemGroup, "deployer", []*models.RoleEnvPermissionItem{{Action: rbac.ActionDeploy, Env: "uat"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"dave", "system"} {
+		insertTestItem(t, &models.GroupRoleUser{Addons: models.NewAddons(), Group: constant.SystemGroup, User: user, Role: "deployer"})
+		publishID := insertTestItem(t, &models.Publish{Addons: models.NewAddons(), ProjectID: 1, StageID: stageID, StepIndex: 1, Status: models.Running, LastPipelineInstanceID: instanceID})
+		status, _, err := pm.RunManualStep(publishID, stageID, user, &ManualStepReq{Status: "success"})
+		if status != models.Skipped || err == nil || !strings.Contains(err.Error(), "无权在环境 uat 执行审批操作") {
+			t.Errorf("RunManualStep() by %v without approve permission = %v, %v", user, status, err)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	manifestImage = beego.AppConfig.DefaultString("matrix::manifest_image", "gcr.io/go-containerregistry/crane:debug")
+)
+
+const manifestContainerName = "manifest"
+
+// buildMatrix compile sub task matrix, eg: arch: [amd64, arm64], compile_envs: [jdk8 id, jdk17 id]
+type buildMatrix struct {
+	Arch        []string `json:"arch,omitempty"`
+	CompileEnvs []int64  `json:"compile_envs,omitempty"`
+}
+
+// multiArch the images of all arch are combined to one manifest list
+func (m *buildMatrix) multiArch() bool {
+	return m != nil && len(m.Arch) > 1
+}
+
+// nodeArch the build pod of single arch matrix runs on the nodes of the arch natively,
+// the multi-arch images are cross built on the default nodes
+func (m *buildMatrix) nodeArch() string {
+	if m != nil && len(m.Arch) == 1 {
+		return m.Arch[0]
+	}
+	return ""
+}
+
+// withAppArchs fill the arch of build matrix with the target architectures of apps if the matrix has no arch,
+// the apps of a build share the matrix, so the apps with target architectures must have the same ones
+func withAppArchs(matrix *buildMatrix, allParms []*RunBuildAllParms) (*buildMatrix, error) {
+	if matrix != nil && len(matrix.Arch) > 0 {
+		return matrix, nil
+	}
+	archs := ""
+	for _, app := range allParms {
+		appArchs := strings.Join(models.ParseArchs(app.Arch), ",")
+		if appArchs == "" {
+			continue
+		}
+		if archs != "" && appArchs != archs {
+			return nil, fmt.Errorf("应用 %v 的目标架构 %v 与同一构建的其它应用 %v 不一致", app.Name, appArchs, archs)
+		}
+		archs = appArchs
+	}
+	if archs == "" {
+		return matrix, nil
+	}
+	filled := &buildMatrix{Arch: models.ParseArchs(archs)}
+	if matrix != nil {
+		filled.CompileEnvs = matrix.CompileEnvs
+	}
+	return filled, nil
+}
+
+// verifyNodeArch the compile envs must support the arch of the nodes which the build pod runs on
+func verifyNodeArch(arch string, params []compileEnv) error {
+	if arch == "" {
+		return nil
+	}
+	for _, param := range params {
+		supported := false
+		for _, item := range param.Arch {
+			supported = supported || item == arch
+		}
+		if !supported {
+			return fmt.Errorf("编译容器 %v 的编译环境不支持 CPU 架构 %v", param.Name, arch)
+		}
+	}
+	return nil
+}
+
+// verifyMatrix the invalid entries of matrix axes are rejected before expanded,
+// otherwise the cells of them are missing from the build silently
+func (pm *PipelineManager) verifyMatrix(matrix *buildMatrix) error {
+	if matrix == nil {
+		return nil
+	}
+	if len(matrix.Arch) == 0 && len(matrix.CompileEnvs) == 0 {
+		return fmt.Errorf("构建矩阵至少需要声明 arch 或 compile_envs")
+	}
+	if err := settings.VerifyArchs(matrix.Arch); err != nil {
+		return fmt.Errorf("构建矩阵: %s", err.Error())
+	}
+	seen := map[int64]bool{}
+	for _, envID := range matrix.CompileEnvs {
+		if seen[envID] {
+			return fmt.Errorf("构建矩阵: 编译环境 %v 重复", envID)
+		}
+		seen[envID] = true
+		if _, err := pm.settingsHandler.GetCompileEnvByID(envID); err != nil {
+			log.Log.Warn("get matrix compile env by id: %v error: %s", envID, err.Error())
+			return fmt.Errorf("构建矩阵: 编译环境 %v 不存在", envID)
+		}
+	}
+	return nil
+}
+
+// matrixCell one combination of the build matrix
+type matrixCell struct {
+	CompileEnvID   int64
+	CompileEnvName string
+	Arch           string
+}
+
+func sanitizeName(name string) string {
+	return strings.NewReplacer("_", "-", ".", "-", " ", "-", "/", "-").Replace(strings.ToLower(name))
+}
+
+// suffix return the cell identify, it is empty when there is no matrix
+func (c matrixCell) suffix() string {
+	parts := []string{}
+	if c.CompileEnvName != "" {
+		parts = append(parts, sanitizeName(c.CompileEnvName))
+	}
+	if c.Arch != "" {
+		parts = append(parts, sanitizeName(c.Arch))
+	}
+	return strings.Join(parts, "-")
+}
+
+// containerName the compile container of cell, the cells of different arch share one container
+func (c matrixCell) containerName(appName string) string {
+	if c.CompileEnvName == "" {
+		return strings.ToLower(appName)
+	}
+	return fmt.Sprintf("%s-%s", strings.ToLower(appName), sanitizeName(c.CompileEnvName))
+}
+
+// matrixCells expand the build matrix of app, return one default cell when matrix is nil
+func (pm *PipelineManager) matrixCells(matrix *buildMatrix, app *RunBuildAllParms) []matrixCell {
+	if matrix == nil {
+		return []matrixCell{{CompileEnvID: app.CompileEnvID}}
+	}
+	envs := []matrixCell{}
+	for _, envID := range matrix.CompileEnvs {
+		compileItem, err := pm.settingsHandler.GetCompileEnvByID(envID)
+		if err != nil {
+			log.Log.Warn("get matrix compile env by id: %v error: %s", envID, err.Error())
+			continue
+		}
+		envs = append(envs, matrixCell{CompileEnvID: envID, CompileEnvName: compileItem.Name})
+	}
+	if len(envs) == 0 {
+		envs = append(envs, matrixCell{CompileEnvID: app.CompileEnvID})
+	}
+	if len(matrix.Arch) == 0 {
+		return envs
+	}
+	cells := []matrixCell{}
+	for _, env := range envs {
+		for _, arch := range matrix.Arch {
+			cell := env
+			cell.Arch = arch
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// matrixRepoPath every cell build in its own copy of app repo, avoid the parallel builds overwrite each other
+func (pm *PipelineManager) matrixRepoPath(stageID, projectID int64, workSpace string, app *RunBuildAllParms, cell matrixCell) string {
+	repoPath := pm.generateAppRepoPth(stageID, projectID, workSpace, app)
+	if cell.suffix() == "" {
+		return repoPath
+	}
+	return fmt.Sprintf("%s-%s", repoPath, cell.suffix())
+}
+
+func (pm *PipelineManager) matrixAppPath(stageID, projectID int64, workSpace string, app *RunBuildAllParms, cell matrixCell) string {
+	appPath := strings.Join([]string{pm.matrixRepoPath(stageID, projectID, workSpace, app, cell), app.BuildPath}, "/")
+	return strings.ReplaceAll(appPath, "//", "/")
+}
+
+// generateMatrixCompileEnvParams return the compile containers of all apps matrix compile envs,
+// the cells of different arch share one container, the default container is not generated again
+func (pm *PipelineManager) generateMatrixCompileEnvParams(allParms []*RunBuildAllParms, matrix *buildMatrix) ([]compileEnv, error) {
+	compileParams := []compileEnv{}
+	containers := map[string]bool{}
+	for _, app := range allParms {
+		for _, cell := range pm.matrixCells(matrix, app) {
+			name := cell.containerName(app.Name)
+			if cell.CompileEnvID == 0 || containers[name] {
+				continue
+			}
+			compileItem, err := pm.settingsHandler.GetCompileEnvByID(cell.CompileEnvID)
+			if err != nil {
+				log.Log.Warn("get compile env by id:%v error: %s", cell.CompileEnvID, err.Error())
+				return nil, fmt.Errorf("应用 %v 的编译环境 %v 不存在", app.Name, cell.CompileEnvID)
+			}
+			if compileItem.Name == constant.DefaultContainerName {
+				log.Log.Warn("app: %v setup complie env to %v, skip this compileItem generate", app.Name, constant.DefaultContainerName)
+				continue
+			}
+			compileParams = append(compileParams, newCompileEnvParam(name, compileItem))
+			containers[name] = true
+		}
+	}
+	return compileParams, nil
+}
+
+// manifestContainer the container used to push multi-arch image manifest list
+func manifestContainer() jenkins.ContainerEnv {
+	return jenkins.ContainerEnv{
+		Name:       manifestContainerName,
+		Image:      manifestImage,
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	}
+}
+
+// renderManifestStageForBuild combine the images of all arch to one tag
+func (pm *PipelineManager) renderManifestStageForBuild(stageID int64, allParms []*RunBuildAllParms, matrix *buildMatrix, deployTarget *DeployTarget) (string, error) {
+	var insecure = ""
+	if !deployTarget.RegistryHTTPS {
+		insecure = "--insecure"
+	}
+	commands := []string{
+		`sh "[ -d $DOCKER_CONFIG ] || mkdir -pv $DOCKER_CONFIG"`,
+		`sh """
+        echo '{"auths": {"'$REGISTRY_ADDR'": {"auth": "'$DOCKER_AUTH'"}}}' > $DOCKER_CONFIG/config.json
+        """`,
+	}
+	for _, app := range allParms {
+		// the app without manifest list would deploy the image of a single arch, fail the build instead
+		arrange, err := pm.appHandler.GetRealArrange(app.ProjectAppID, stageID)
+		if err != nil {
+			log.Log.Error("get app id: %v  env id: %v real arrange, occur error: %s", app.ProjectAppID, stageID, err.Error())
+			return "", fmt.Errorf("获取应用 %v 的编排失败: %s", app.Name, err.Error())
+		}
+		imageURL, _, err := pm.generateImageAddr(arrange.ID, app.ProjectAppID, app.Branch)
+		if err != nil {
+			log.Log.Error("generate app id: %v image address, occur error: %s", app.ProjectAppID, err.Error())
+			return "", fmt.Errorf("生成应用 %v 的镜像地址失败: %s", app.Name, err.Error())
+		}
+		manifests := []string{}
+		for _, arch := range matrix.Arch {
+			manifests = append(manifests, fmt.Sprintf("-m %s-%s", imageURL, sanitizeName(arch)))
+		}
+		commands = append(commands, fmt.Sprintf(`sh "crane index append %s -t %s %s"`, insecure, imageURL, strings.Join(manifests, " ")))
+	}
+	item := jenkins.StepItem{
+		Name:    "'Manifests'",
+		Command: fmt.Sprintf("container('%s') {\n%s\n}", manifestContainerName, strings.Join(commands, "\n")),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
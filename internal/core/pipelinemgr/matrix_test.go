@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestWithAppArchs(t *testing.T) {
+	app := func(name, arch string) *RunBuildAllParms {
+		return &RunBuildAllParms{ScmApp: &models.ScmApp{Name: name, Arch: arch}}
+	}
+	matrix, err := withAppArchs(nil, []*RunBuildAllParms{app("api", ""), app("web", "")})
+	if err != nil || matrix != nil {
+		t.Fatalf("matrix of apps without arch = %+v, %v", matrix, err)
+	}
+
+	matrix, err = withAppArchs(&buildMatrix{CompileEnvs: []int64{1}}, []*RunBuildAllParms{app("api", "amd64, arm64"), app("web", "")})
+	if err != nil {
+		t.Fatalf("fill matrix arch: %v", err)
+	}
+	if !reflect.DeepEqual(matrix, &buildMatrix{Arch: []string{"amd64", "arm64"}, CompileEnvs: []int64{1}}) || !matrix.multiArch() || matrix.nodeArch() != "" {
+		t.Fatalf("unexpected matrix: %+v", matrix)
+	}
+
+	declared := &buildMatrix{Arch: []string{"amd64"}}
+	if matrix, _ = withAppArchs(declared, []*RunBuildAllParms{app("api", "arm64")}); matrix != declared {
+		t.Fatalf("the arch declared by pipeline should win: %+v", matrix)
+	}
+
+	if _, err = withAppArchs(nil, []*RunBuildAllParms{app("api", "arm64"), app("web", "amd64,arm64")}); err == nil {
+		t.Fatalf("apps with different archs should be rejected")
+	}
+}
+
+func TestVerifyNodeArch(t *testing.T) {
+	params := []compileEnv{{Name: "api", Arch: []string{"amd64", "arm64"}}, {Name: "web", Arch: []string{"amd64"}}}
+	if err := verifyNodeArch("", params); err != nil {
+		t.Fatalf("verify default node arch: %v", err)
+	}
+	if err := verifyNodeArch("amd64", params); err != nil {
+		t.Fatalf("verify amd64 node arch: %v", err)
+	}
+	if err := verifyNodeArch("arm64", params); err == nil {
+		t.Fatalf("compile env without arm64 should be rejected")
+	}
+	matrix := &buildMatrix{Arch: []string{"arm64"}}
+	if matrix.nodeArch() != "arm64" || matrix.multiArch() {
+		t.Fatalf("single arch matrix node arch = %q", matrix.nodeArch())
+	}
+}
+
+func TestMatrixAxes(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	jdk8 := insertTestItem(t, &models.CompileEnv{Addons: models.NewAddons(), Name: "jdk8", Image: "maven:jdk8"})
+	jdk17 := insertTestItem(t, &models.CompileEnv{Addons: models.NewAddons(), Name: "jdk17", Image: "maven:jdk17"})
+	jnlp := insertTestItem(t, &models.CompileEnv{Addons: models.NewAddons(), Name: constant.DefaultContainerName, Image: "jenkins/inbound-agent"})
+	const missing = int64(99999)
+	app := &RunBuildAllParms{ScmApp: &models.ScmApp{Name: "api", CompileEnvID: jdk8}}
+
+	tests := []struct {
+		name       string
+		matrix     *buildMatrix
+		app        *RunBuildAllParms
+		wantErr    bool
+		cells      []matrixCell
+		containers []string
+	}{
+		{name: "no matrix", app: app, cells: []matrixCell{{CompileEnvID: jdk8}}, containers: []string{"api"}},
+		{name: "arch axis", matrix: &buildMatrix{Arch: []string{"amd64", "arm64"}}, app: app,
+			cells: []matrixCell{{CompileEnvID: jdk8, Arch: "amd64"}, {CompileEnvID: jdk8, Arch: "arm64"}}, containers: []string{"api"}},
+		{name: "compile env and arch axes", matrix: &buildMatrix{Arch: []string{"amd64", "arm64"}, CompileEnvs: []int64{jdk8, jdk17}}, app: app,
+			cells: []matrixCell{
+				{CompileEnvID: jdk8, CompileEnvName: "jdk8", Arch: "amd64"}, {CompileEnvID: jdk8, CompileEnvName: "jdk8", Arch: "arm64"},
+				{CompileEnvID: jdk17, CompileEnvName: "jdk17", Arch: "amd64"}, {CompileEnvID: jdk17, CompileEnvName: "jdk17", Arch: "arm64"},
+			},
+			containers: []string{"api-jdk8", "api-jdk17"}},
+		{name: "default container excluded", matrix: &buildMatrix{CompileEnvs: []int64{jnlp, jdk17}}, app: app,
+			cells:      []matrixCell{{CompileEnvID: jnlp, CompileEnvName: constant.DefaultContainerName}, {CompileEnvID: jdk17, CompileEnvName: "jdk17"}},
+			containers: []string{"api-jdk17"}},
+		{name: "empty matrix", matrix: &buildMatrix{}, app: app, wantErr: true},
+		{name: "invalid arch", matrix: &buildMatrix{Arch: []string{"amd64", "x86"}}, app: app, wantErr: true},
+		{name: "duplicated arch", matrix: &buildMatrix{Arch: []string{"arm64", "arm64"}}, app: app, wantErr: true},
+		{name: "missing compile env", matrix: &buildMatrix{CompileEnvs: []int64{jdk8, missing}}, app: app, wantErr: true},
+		{name: "duplicated compile env", matrix: &buildMatrix{CompileEnvs: []int64{jdk17, jdk17}}, app: app, wantErr: true},
+		{name: "missing compile env of app", matrix: &buildMatrix{Arch: []string{"amd64"}},
+			app: &RunBuildAllParms{ScmApp: &models.ScmApp{Name: "web", CompileEnvID: missing}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pm.verifyMatrix(tt.matrix)
+			var params []compileEnv
+			if err == nil {
+				params, err = pm.generateMatrixCompileEnvParams([]*RunBuildAllParms{tt.app}, tt.matrix)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matrix %+v error = %v, wantErr %v", tt.matrix, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cells := pm.matrixCells(tt.matrix, tt.app); !reflect.DeepEqual(cells, tt.cells) {
+				t.Errorf("matrixCells() = %+v, want %+v", cells, tt.cells)
+			}
+			containers := []string{}
+			for _, param := range params {
+				containers = append(containers, param.Name)
+			}
+			if !reflect.DeepEqual(containers, tt.containers) {
+				t.Errorf("containers = %v, want %v", containers, tt.containers)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	k6Image     = beego.AppConfig.DefaultString("perftest::k6_image", "grafana/k6:0.43.1")
+	jmeterImage = beego.AppConfig.DefaultString("perftest::jmeter_image", "justb4/jmeter:5.5")
+)
+
+// the tools of perf-test sub task
+const (
+	PerfToolK6     = "k6"
+	PerfToolJMeter = "jmeter"
+)
+
+// defaultPerfMaxRegression the default max percent of regression compared with the baseline
+const defaultPerfMaxRegression = 10
+
+var (
+	perfScriptPattern = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+	perfArgsPattern   = regexp.MustCompile(`^[A-Za-z0-9_./:=,@ -]*$`)
+)
+
+// perfTest the params of perf-test sub task, the script is the k6 script or jmeter test plan in the image,
+// the address of env is passed by $E2E_BASE_URL, and by -Jbase_url for jmeter
+type perfTest struct {
+	Tool    string `json:"tool"`
+	Image   string `json:"image,omitempty"`
+	Script  string `json:"script"`
+	Args    string `json:"args,omitempty"`
+	BaseURL string `json:"base_url,omitempty"`
+	// MaxRegression the max percent of p95 latency increase or throughput decrease compared with the baseline, 0 means 10
+	MaxRegression float64 `json:"max_regression,omitempty"`
+}
+
+// validate the params are rendered into pipeline script, only the safe characters are allowed
+func (t *perfTest) validate() error {
+	if t == nil {
+		return fmt.Errorf("性能测试子任务缺少参数配置")
+	}
+	if t.Tool != PerfToolK6 && t.Tool != PerfToolJMeter {
+		return fmt.Errorf("性能测试不支持的工具: %v，可选值为 %v/%v", t.Tool, PerfToolK6, PerfToolJMeter)
+	}
+	if t.Image != "" && !e2eImagePattern.MatchString(t.Image) {
+		return fmt.Errorf("性能测试镜像: %v 无效", t.Image)
+	}
+	if !perfScriptPattern.MatchString(t.Script) || strings.Contains(t.Script, "..") {
+		return fmt.Errorf("性能测试脚本: %v 无效", t.Script)
+	}
+	if !perfArgsPattern.MatchString(t.Args) {
+		return fmt.Errorf("性能测试参数: %v 无效", t.Args)
+	}
+	if !e2eBaseURLPattern.MatchString(t.BaseURL) {
+		return fmt.Errorf("性能测试环境地址: %v 无效", t.BaseURL)
+	}
+	if t.MaxRegression < 0 {
+		return fmt.Errorf("性能测试最大退化比例: %v 无效", t.MaxRegression)
+	}
+	return nil
+}
+
+func (t *perfTest) image() string {
+	if t.Image != "" {
+		return t.Image
+	}
+	if t.Tool == PerfToolJMeter {
+		return jmeterImage
+	}
+	return k6Image
+}
+
+func (t *perfTest) maxRegression() float64 {
+	if t.MaxRegression == 0 {
+		return defaultPerfMaxRegression
+	}
+	return t.MaxRegression
+}
+
+// resultFile the file of the metrics reported, k6 summary json or jmeter csv results
+func (t *perfTest) resultFile() string {
+	if t.Tool == PerfToolJMeter {
+		return "results.jtl"
+	}
+	return "summary.json"
+}
+
+// command run the perf test and write the result file into the report dir
+func (t *perfTest) command(reportDir string) string {
+	args := ""
+	if t.Args != "" {
+		args = " " + t.Args
+	}
+	if t.Tool == PerfToolJMeter {
+		return fmt.Sprintf(`jmeter -n -t %s -l %s/%s -Jjmeter.save.saveservice.output_format=csv -Jbase_url="$E2E_BASE_URL"%s`, t.Script, reportDir, t.resultFile(), args)
+	}
+	return fmt.Sprintf(`k6 run --summary-export %s/%s%s %s`, reportDir, t.resultFile(), args, t.Script)
+}
+
+// renderPerfTestStage run the perf test in its container, the test failure does not break the job,
+// the comparison with the baseline decides the result
+func renderPerfTestStage(projectID, publishID, stageID, publishJobID int64, index int, task *subTask, baseURL, workspace string) (jenkins.ContainerEnv, string, error) {
+	container := jenkins.ContainerEnv{}
+	if err := task.Perf.validate(); err != nil {
+		return container, "", err
+	}
+	container = jenkins.ContainerEnv{
+		Name:       fmt.Sprintf("perf-%d", index),
+		Image:      task.Perf.image(),
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	}
+	query := url.Values{}
+	query.Set("publish_job_id", fmt.Sprint(publishJobID))
+	query.Set("task", fmt.Sprint(index))
+	reportURL := fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/perf-report?%s", atomciServer, projectID, publishID, stageID, models.StepE2ETest, query.Encode())
+	reportDir := fmt.Sprintf("%s/perf/%d/%d", workspace, publishJobID, index)
+	commands := []string{
+		fmt.Sprintf("container('%s') {", container.Name),
+		fmt.Sprintf("withEnv(['E2E_BASE_URL=%s', 'REPORT_DIR=%s']) {", baseURL, reportDir),
+		fmt.Sprintf(`sh 'rm -rf %s && mkdir -p %s'`, reportDir, reportDir),
+		fmt.Sprintf(`sh script: '%s', returnStatus: true`, task.Perf.command(reportDir)),
+		fmt.Sprintf(`sh 'touch %s/%s'`, reportDir, task.Perf.resultFile()),
+		"}",
+		"}",
+		fmt.Sprintf(
+			`httpRequest acceptType: 'APPLICATION_JSON', contentType: 'TEXT_PLAIN', customHeaders: [[maskValue: true, name: 'Authorization', value: "Bearer ${env.ACCESS_TOKEN}"]], httpMode: 'POST', requestBody: readFile('%s/%s'), responseHandle: 'NONE', timeout: 30, url: '%s'`,
+			reportDir, task.Perf.resultFile(), reportURL),
+	}
+	item := jenkins.StepItem{
+		Name:    fmt.Sprintf("'Perf-%d-%s'", index, groovyEscape(task.Name)),
+		Command: strings.Join(commands, "\n"),
+	}
+	stage, err := jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+	return container, stage, err
+}
+
+// perfMetrics the latencies are in milliseconds, the throughput is requests per second, the error rate is percent
+type perfMetrics struct {
+	Requests   int64
+	AvgLatency float64
+	P95Latency float64
+	Throughput float64
+	ErrorRate  float64
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// parseK6Summary parse the metrics from the summary exported by k6 run --summary-export
+func parseK6Summary(data []byte) (*perfMetrics, error) {
+	summary := struct {
+		Metrics map[string]map[string]interface{} `json:"metrics"`
+	}{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, err
+	}
+	value := func(metric, key string) float64 {
+		v, _ := summary.Metrics[metric][key].(float64)
+		return v
+	}
+	if summary.Metrics["http_req_duration"] == nil || summary.Metrics["http_reqs"] == nil {
+		return nil, fmt.Errorf("k6 summary has no http metrics")
+	}
+	return &perfMetrics{
+		Requests:   int64(value("http_reqs", "count")),
+		AvgLatency: round2(value("http_req_duration", "avg")),
+		P95Latency: round2(value("http_req_duration", "p(95)")),
+		Throughput: round2(value("http_reqs", "rate")),
+		ErrorRate:  round2(value("http_req_failed", "value") * 100),
+	}, nil
+}
+
+// parseJMeterResults parse the metrics from the jmeter csv results, the first line is the header
+func parseJMeterResults(r io.Reader) (*perfMetrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"timeStamp", "elapsed", "success"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("jmeter results has no column: %v", name)
+		}
+	}
+	latencies := []float64{}
+	var start, end, failures int64
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) != len(header) {
+			continue
+		}
+		timestamp, err1 := strconv.ParseInt(record[columns["timeStamp"]], 10, 64)
+		elapsed, err2 := strconv.ParseInt(record[columns["elapsed"]], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if start == 0 || timestamp < start {
+			start = timestamp
+		}
+		if timestamp+elapsed > end {
+			end = timestamp + elapsed
+		}
+		if record[columns["success"]] != "true" {
+			failures++
+		}
+		latencies = append(latencies, float64(elapsed))
+	}
+	metrics := &perfMetrics{Requests: int64(len(latencies))}
+	if len(latencies) == 0 {
+		return metrics, nil
+	}
+	sort.Float64s(latencies)
+	sum := 0.0
+	for _, latency := range latencies {
+		sum += latency
+	}
+	metrics.AvgLatency = round2(sum / float64(len(latencies)))
+	metrics.P95Latency = latencies[int(math.Ceil(0.95*float64(len(latencies))))-1]
+	metrics.Throughput = float64(len(latencies))
+	if end > start {
+		metrics.Throughput = round2(float64(len(latencies)) * 1000 / float64(end-start))
+	}
+	metrics.ErrorRate = round2(float64(failures) * 100 / float64(len(latencies)))
+	return metrics, nil
+}
+
+// perfRegression the max percent of p95 latency increase and throughput decrease, negative means improved
+func perfRegression(current, baseline *models.PerfTestResult) float64 {
+	regressions := []float64{}
+	if baseline.P95Latency > 0 {
+		regressions = append(regressions, (current.P95Latency-baseline.P95Latency)*100/baseline.P95Latency)
+	}
+	if baseline.Throughput > 0 {
+		regressions = append(regressions, (baseline.Throughput-current.Throughput)*100/baseline.Throughput)
+	}
+	if len(regressions) == 0 {
+		return 0
+	}
+	sort.Float64s(regressions)
+	return round2(regressions[len(regressions)-1])
+}
+
+// PerfTestReportReq the perf test result reported by the e2e test job
+type PerfTestReportReq struct {
+	PublishJobID int64 `json:"publish_job_id"`
+	TaskIndex    int   `json:"task"`
+}
+
+// ReportPerfTest store the metrics of perf test and compare them with the baseline of previous publish
+func (pm *PipelineManager) ReportPerfTest(projectID, publishID, stageID int64, req *PerfTestReportReq, data []byte) error {
+	job, task, err := pm.e2eJobSubTask(publishID, stageID, req.PublishJobID, constant.StepSubTaskPerfTest, req.TaskIndex)
+	if err != nil {
+		return err
+	}
+	result := &models.PerfTestResult{
+		Addons:        models.NewAddons(),
+		ProjectID:     projectID,
+		PublishID:     publishID,
+		StageID:       stageID,
+		PublishJobID:  job.ID,
+		Task:          task.Name,
+		Tool:          task.Perf.Tool,
+		MaxRegression: task.Perf.maxRegression(),
+	}
+	var metrics *perfMetrics
+	if task.Perf.Tool == PerfToolJMeter {
+		metrics, err = parseJMeterResults(bytes.NewReader(data))
+	} else {
+		metrics, err = parseK6Summary(data)
+	}
+	switch {
+	case err != nil:
+		log.Log.Warn("parse publish job: %v perf test: %v result error: %s", job.ID, task.Name, err.Error())
+		result.Message = "解析性能测试结果失败"
+	case metrics.Requests == 0:
+		result.Message = "未采集到性能指标"
+	default:
+		result.Requests = metrics.Requests
+		result.AvgLatency = metrics.AvgLatency
+		result.P95Latency = metrics.P95Latency
+		result.Throughput = metrics.Throughput
+		result.ErrorRate = metrics.ErrorRate
+		result.GatePassed, result.Message, err = pm.comparePerfBaseline(result)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = pm.modelPerfTest.CreatePerfTestResult(result)
+	return err
+}
+
+// comparePerfBaseline the result without baseline passes and becomes the baseline of later publishes
+func (pm *PipelineManager) comparePerfBaseline(result *models.PerfTestResult) (bool, string, error) {
+	baseline, err := pm.modelPerfTest.GetPerfTestBaseline(result.ProjectID, result.StageID, result.PublishID, result.Task)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return true, "无基线数据，当前结果将作为后续发布的基线", nil
+		}
+		return false, "", err
+	}
+	result.BaselineID = baseline.ID
+	result.BaselinePublishID = baseline.PublishID
+	result.BaselineP95Latency = baseline.P95Latency
+	result.BaselineThroughput = baseline.Throughput
+	result.Regression = perfRegression(result, baseline)
+	if result.Regression > result.MaxRegression {
+		return false, fmt.Sprintf("相比发布 %v 的基线性能退化 %.2f%% 超过阈值 %.2f%% (P95 %.2fms -> %.2fms, 吞吐 %.2f/s -> %.2f/s)",
+			baseline.PublishID, result.Regression, result.MaxRegression, baseline.P95Latency, result.P95Latency, baseline.Throughput, result.Throughput), nil
+	}
+	return true, fmt.Sprintf("相比发布 %v 的基线性能变化 %.2f%%", baseline.PublishID, result.Regression), nil
+}
+
+// GetPerfTestResults return the perf test results of publish
+func (pm *PipelineManager) GetPerfTestResults(projectID, publishID int64) ([]*models.PerfTestResult, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return nil, err
+	}
+	return pm.modelPerfTest.GetPerfTestResults(publishID, 0)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestParseK6Summary(t *testing.T) {
+	summary := `{"metrics": {
+	"http_req_duration": {"avg": 120.456, "med": 100, "p(90)": 180, "p(95)": 210.5, "thresholds": {"p(95)<500": false}},
+	"http_reqs": {"count": 3000, "rate": 99.987},
+	"http_req_failed": {"passes": 30, "fails": 2970, "value": 0.01}
+}}`
+	metrics, err := parseK6Summary([]byte(summary))
+	if err != nil {
+		t.Fatalf("parseK6Summary() error = %v", err)
+	}
+	want := perfMetrics{Requests: 3000, AvgLatency: 120.46, P95Latency: 210.5, Throughput: 99.99, ErrorRate: 1}
+	if *metrics != want {
+		t.Errorf("parseK6Summary() = %+v, want %+v", *metrics, want)
+	}
+	if _, err := parseK6Summary([]byte(`{"metrics": {}}`)); err == nil {
+		t.Errorf("parseK6Summary() expects error without http metrics")
+	}
+}
+
+func TestParseJMeterResults(t *testing.T) {
+	results := `timeStamp,elapsed,label,responseCode,responseMessage,threadName,dataType,success
+1000,100,home,200,OK,"group 1-1",text,true
+1500,200,"login, post",200,OK,group 1-1,text,true
+2000,300,home,500,"Server Error",group 1-2,text,false
+2500,400,home,200,OK,group 1-2,text,true
+`
+	metrics, err := parseJMeterResults(strings.NewReader(results))
+	if err != nil {
+		t.Fatalf("parseJMeterResults() error = %v", err)
+	}
+	want := perfMetrics{Requests: 4, AvgLatency: 250, P95Latency: 400, Throughput: 2.11, ErrorRate: 25}
+	if *metrics != want {
+		t.Errorf("parseJMeterResults() = %+v, want %+v", *metrics, want)
+	}
+	if _, err := parseJMeterResults(strings.NewReader("a,b\n1,2\n")); err == nil {
+		t.Errorf("parseJMeterResults() expects error without required columns")
+	}
+}
+
+func TestPerfRegression(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  *models.PerfTestResult
+		baseline *models.PerfTestResult
+		want     float64
+	}{
+		{"latency increased", &models.PerfTestResult{P95Latency: 120, Throughput: 100}, &models.PerfTestResult{P95Latency: 100, Throughput: 100}, 20},
+		{"throughput decreased", &models.PerfTestResult{P95Latency: 100, Throughput: 85}, &models.PerfTestResult{P95Latency: 100, Throughput: 100}, 15},
+		{"improved", &models.PerfTestResult{P95Latency: 80, Throughput: 110}, &models.PerfTestResult{P95Latency: 100, Throughput: 100}, -10},
+		{"empty baseline", &models.PerfTestResult{P95Latency: 80, Throughput: 110}, &models.PerfTestResult{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := perfRegression(tt.current, tt.baseline); got != tt.want {
+				t.Errorf("perfRegression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
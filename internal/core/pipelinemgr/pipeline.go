@@ -19,9 +19,14 @@ package pipelinemgr
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/go-atomci/atomci/internal/core/rbac"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
 
 	"github.com/go-atomci/workflow"
 	"github.com/go-atomci/workflow/jenkins"
@@ -47,6 +52,13 @@ func NewWorkFlowProvide(driver, addr, user, token, jobName string, flowProcessor
 			return nil, err
 		}
 		return workFlowProvider, nil
+	case driver != "":
+		external, err := settings.WorkflowDriver(driver)
+		if err != nil {
+			log.Log.Error("get workflow driver: %v occur error: %s", driver, err.Error())
+			return nil, err
+		}
+		return newPluginWorkFlow(external, addr, user, token, jobName, flowProcessor), nil
 	}
 	log.Log.Error("work flow system not configured")
 	return nil, fmt.Errorf("work flow system not configured")
@@ -62,31 +74,62 @@ func (pm *PipelineManager) GetStepInfo(projectID int64, publishID int64, stageID
 		return pm.getStepInfoByInstanceID(publishID)
 	case "build":
 		return pm.getPublishStepPreBranchList(projectID, publishID, stageID)
-	case "deploy":
+	case "deploy", "promote":
 		return pm.getDeployStepAppImages(publishID)
+	case models.StepE2ETest:
+		return pm.modelE2ETest.GetE2ETestReports(publishID, stageID)
 	default:
 		log.Log.Error("unknow step_name: %s", stepName)
 		return nil, fmt.Errorf(fmt.Sprintf("unknown args step_name: %s", stepName))
 	}
 }
 
-// RunManualStep .. return publish status, error
-func (pm *PipelineManager) RunManualStep(publishID, stageID int64, request *ManualStepReq) (int64, error) {
+// RunManualStep .. return publish status, the input values filled in by the approver, error
+func (pm *PipelineManager) RunManualStep(publishID, stageID int64, operator string, request *ManualStepReq) (int64, map[string]string, error) {
 	if err := pm.verifyProjectPublish(0, publishID); err != nil {
-		return models.Skipped, fmt.Errorf("请选择有效的流水线后重试：%s", err.Error())
+		return models.Skipped, nil, fmt.Errorf("请选择有效的流水线后重试：%s", err.Error())
+	}
+	publish, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return models.Failed, nil, err
+	}
+	if err := rbac.VerifyEnvAction(operator, publish.ProjectID, stageID, rbac.ActionApprove); err != nil {
+		return models.Skipped, nil, err
 	}
-	if _, err := pm.modelPublish.GetPublishByID(publishID); err != nil {
-		return models.Failed, err
+	if publish.Approvers != "" && !utils.Contains(strings.Split(publish.Approvers, ","), operator) {
+		return models.Skipped, nil, fmt.Errorf("仅审批人 %v 可以执行人工审核，操作拒绝", publish.Approvers)
 	}
 	switch request.Status {
 	case "success":
-		return models.Success, nil
+		inputs, err := pm.getManualStepInputs(publish.LastPipelineInstanceID, stageID, publish.StepIndex)
+		if err != nil {
+			log.Log.Error("get manual step inputs of publish: %v occur error: %s", publishID, err.Error())
+			return models.Skipped, nil, err
+		}
+		values, err := resolveManualInputs(inputs, request.Inputs)
+		if err != nil {
+			return models.Skipped, nil, err
+		}
+		pm.reviewTerraformPlans(publishID, stageID, operator, models.TerraformApproved)
+		return models.Success, values, nil
 	case "failed":
-		return models.Failed, nil
+		pm.reviewTerraformPlans(publishID, stageID, operator, models.TerraformRejected)
+		return models.Failed, nil, nil
 	default:
 		log.Log.Error("request status is unexception, status: %v", request.Status)
-		return models.Skipped, nil
+		return models.Skipped, nil, nil
+	}
+}
+
+// lockStageTrigger serialize the triggers of the env stage among the server replicas,
+// so the running job checks are not passed by two requests at the same time
+func lockStageTrigger(projectID, stageID int64) (func(), error) {
+	unlock, err := dao.Lock(fmt.Sprintf("trigger-%d-%d", projectID, stageID), 2*time.Minute, 10*time.Second)
+	if err != nil {
+		log.Log.Warn("lock trigger of project: %v stage: %v occur error: %s", projectID, stageID, err.Error())
+		return nil, fmt.Errorf("当前环境有其他任务正在触发，请稍后重试")
 	}
+	return unlock, nil
 }
 
 // RunBuildStep publish-order build operation
@@ -94,6 +137,11 @@ func (pm *PipelineManager) RunBuildStep(projectID, publishID, stageID int64, cre
 	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
 		return models.Failed, 0, "", fmt.Errorf("请选择有效的项目/流水线后重试：%s", err.Error())
 	}
+	if !params.Automated {
+		if err := rbac.VerifyEnvAction(creator, projectID, stageID, rbac.ActionBuild); err != nil {
+			return models.Skipped, 0, "", err
+		}
+	}
 
 	publish, _ := pm.modelPublish.GetPublishByID(publishID)
 	envStageJSON, err := pm.GetPipelineInstanceEnvStageByID(publish.LastPipelineInstanceID, stageID)
@@ -107,10 +155,44 @@ func (pm *PipelineManager) RunBuildStep(projectID, publishID, stageID int64, cre
 		if len(params.Apps) == 0 {
 			return models.Failed, 0, "", fmt.Errorf("至少包含一个代码仓库 才允许触发构建")
 		}
+		if params.SkipUnchanged {
+			apps, skipped := pm.skipUnchangedApps(publishID, params.Apps)
+			if len(apps) == 0 {
+				log.Log.Info("publish: %v apps: %v are built already, skip the build", publishID, skipped)
+				return models.Success, 0, "", nil
+			}
+			if len(skipped) > 0 {
+				log.Log.Info("publish: %v skip the build of unchanged apps: %v", publishID, skipped)
+			}
+			params.Apps = apps
+		}
+		unlock, err := lockStageTrigger(projectID, stageID)
+		if err != nil {
+			return models.Skipped, 0, "", err
+		}
+		defer unlock()
 
 		runningJobVerify, jobString := pm.ifHasRunningBuildJob(projectID, stageID, publishID)
 		if runningJobVerify {
-			return models.Skipped, 0, "", fmt.Errorf(fmt.Sprintf("此阶段的流水线存在构建中的任务, 任务ID: %s", jobString))
+			status, proceed, err := pm.applyConcurrencyPolicy(publish, stageID, models.JobTypeBuild, creator, jobString, params)
+			if !proceed {
+				return status, 0, "", err
+			}
+		}
+		unlockSlot, err := lockBuildSlot()
+		if err != nil {
+			return models.Skipped, 0, "", err
+		}
+		defer unlockSlot()
+		// queue the build job when the shared ci server is busy or other builds are queued ahead
+		available, reason, err := pm.buildSlotInOrder(projectID, params.Dispatched)
+		if err != nil {
+			log.Log.Warn("check build slot occur error: %s, skip build concurrency limit", err.Error())
+		} else if !available {
+			if err := pm.enqueueJob(publish, stageID, models.JobTypeBuild, creator, reason, params); err != nil {
+				return models.Skipped, 0, "", err
+			}
+			return models.Pending, 0, "", nil
 		}
 
 		// Create Publish job
@@ -136,15 +218,26 @@ func (pm *PipelineManager) RunBuildStep(projectID, publishID, stageID int64, cre
 
 // RunBuildDeployCallBackStep publish-order build callback operation
 func (pm *PipelineManager) RunBuildDeployCallBackStep(request *BuildStepCallbackReq) (int64, error) {
+	jobStatus := request.JobStatus
+	if jobStatus == "" {
+		jobStatus = models.StatusSuccess
+	}
 	// update publish job id status
-	if err := pm.UpdatePublishJobStatus(request.PublishJobID, "SUCCESS"); err != nil {
-		if strings.Contains(err.Error(), "already was end status") {
+	if err := pm.UpdatePublishJobStatus(request.PublishJobID, jobStatus); err != nil {
+		if !strings.Contains(err.Error(), "already was end status") {
+			log.Log.Error("build callback, update publish job status occur error: %s", err.Error())
+			return models.Skipped, err
+		}
+		// the resumed callback continue the publish transition, which failed after the job status updated
+		if !request.Resume {
 			return models.Skipped, nil
 		}
-		log.Log.Error("build callback, update publish job status occur error: %s", err.Error())
+	}
+	job, err := pm.modelPublishJob.GetPublishJobByID(request.PublishJobID)
+	if err != nil {
 		return models.Skipped, err
 	}
-	return models.Success, nil
+	return jobPublishStatus(job.Status), nil
 }
 
 // RunDeployStep publish-order deploy operation
@@ -152,6 +245,11 @@ func (pm *PipelineManager) RunDeployStep(projectID, publishID, stageID int64, cr
 	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
 		return models.Failed, 0, "", fmt.Errorf("请选择有效的项目/流水线后重试：%s", err.Error())
 	}
+	if !params.Automated {
+		if err := rbac.VerifyEnvAction(creator, projectID, stageID, rbac.ActionDeploy); err != nil {
+			return models.Skipped, 0, "", err
+		}
+	}
 	publish, _ := pm.modelPublish.GetPublishByID(publishID)
 	envStageJSON, err := pm.GetPipelineInstanceEnvStageByID(publish.LastPipelineInstanceID, stageID)
 	if err != nil {
@@ -164,9 +262,20 @@ func (pm *PipelineManager) RunDeployStep(projectID, publishID, stageID int64, cr
 		if len(params.Apps) == 0 {
 			return models.Failed, 0, "", fmt.Errorf("至少包含一个应用，才允许触发部署")
 		}
+		unlock, err := lockStageTrigger(projectID, stageID)
+		if err != nil {
+			return models.Skipped, 0, "", err
+		}
+		defer unlock()
+		if status, proceed, err := pm.applyDeployWindowPolicy(publish, stageID, creator, params); !proceed {
+			return status, 0, "", err
+		}
 		runningJobVerify, jobString := pm.ifHasRunningJob(projectID, stageID)
 		if runningJobVerify {
-			return models.Skipped, 0, "", fmt.Errorf(fmt.Sprintf("此阶段的流水线存在部署中的任务, 任务ID: %s", jobString))
+			status, proceed, err := pm.applyConcurrencyPolicy(publish, stageID, models.JobTypeDeploy, creator, jobString, params)
+			if !proceed {
+				return status, 0, "", err
+			}
 		}
 
 		projectAppsint := []int64{}
@@ -205,11 +314,16 @@ func (pm *PipelineManager) AutoTriggerNextStep(publish *models.Publish, nextStep
 	case "build":
 		return models.Pending, 0, "", nil
 	case "deploy":
-		params, err := pm.generateAutoDeployStep(publish.ID)
+		params, err := pm.GenerateDeployStepReq(publish.ID)
 		if err != nil {
 			return models.Failed, 0, "", err
 		}
 		return pm.RunDeployStep(publish.ProjectID, publish.ID, publish.StageID, "admin", "deploy", params)
+	case "promote":
+		status, err := pm.RunPromoteStep(publish.ProjectID, publish.ID, publish.StageID, "admin")
+		return status, 0, "", err
+	case models.StepE2ETest:
+		return pm.RunE2ETestStep(publish.ProjectID, publish.ID, publish.StageID, "admin", &E2ETestStepReq{ActionName: "trigger"})
 	default:
 		log.Log.Error("stepType: %s is not exception", nextStepType)
 		return models.Pending, 0, "", nil
@@ -218,11 +332,11 @@ func (pm *PipelineManager) AutoTriggerNextStep(publish *models.Publish, nextStep
 
 // GetJenkinsConfig ..
 func (pm *PipelineManager) GetJenkinsConfig(stageID int64) (*JenkinsConfigRsp, error) {
-	jenkinsConfigSlice, err := pm.GetCIConfig(stageID)
+	jenkinsConfig, err := pm.GetCIConfig(stageID)
 	if err != nil {
 		return nil, err
 	}
 	return &JenkinsConfigRsp{
-		Jenkins: jenkinsConfigSlice[0],
+		Jenkins: jenkinsConfig.URL,
 	}, nil
 }
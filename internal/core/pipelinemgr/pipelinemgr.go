@@ -29,32 +29,48 @@ import (
 
 // PipelineManager ...
 type PipelineManager struct {
-	model           *dao.PipelineStageModel
-	modelProject    *dao.ProjectModel
-	modelPublish    *dao.PublishModel
-	modelArrange    *dao.AppArrangeModel
-	modelPublishJob *dao.PublishJobModel
-	modelK8s        *dao.K8sClusterModel
-	appHandler      *appmgr.AppManager
+	model            *dao.PipelineStageModel
+	modelProject     *dao.ProjectModel
+	modelPublish     *dao.PublishModel
+	modelArrange     *dao.AppArrangeModel
+	modelPublishJob  *dao.PublishJobModel
+	modelK8s         *dao.K8sClusterModel
+	modelTerraform   *dao.TerraformPlanModel
+	modelDBMigration *dao.DBMigrationModel
+	modelE2ETest     *dao.E2ETestReportModel
+	modelSBOM        *dao.SBOMModel
+	modelPerfTest    *dao.PerfTestResultModel
+	modelQuality     *dao.QualityReportModel
+	modelJobStage    *dao.PublishJobStageModel
+	appHandler       *appmgr.AppManager
 	// TODO: modelApp, modelAppArrnage change to appHandler
 	modelApp        *dao.ScmAppModel
 	modelAppArrange *dao.AppArrangeModel
 	settingsHandler *settings.SettingManager
+	// commits is set by withCommitCache when render a publish job
+	commits *commitCache
 }
 
 // NewPipelineManager ...
 func NewPipelineManager() *PipelineManager {
 	return &PipelineManager{
-		model:           dao.NewPipelineStageModel(),
-		modelProject:    dao.NewProjectModel(),
-		modelPublish:    dao.NewPublishModel(),
-		modelArrange:    dao.NewAppArrangeModel(),
-		modelPublishJob: dao.NewPublishJobModel(),
-		modelK8s:        dao.NewK8sClusterModel(),
-		modelApp:        dao.NewScmAppModel(),
-		modelAppArrange: dao.NewAppArrangeModel(),
-		appHandler:      appmgr.NewAppManager(),
-		settingsHandler: settings.NewSettingManager(),
+		model:            dao.NewPipelineStageModel(),
+		modelProject:     dao.NewProjectModel(),
+		modelPublish:     dao.NewPublishModel(),
+		modelArrange:     dao.NewAppArrangeModel(),
+		modelPublishJob:  dao.NewPublishJobModel(),
+		modelK8s:         dao.NewK8sClusterModel(),
+		modelTerraform:   dao.NewTerraformPlanModel(),
+		modelDBMigration: dao.NewDBMigrationModel(),
+		modelE2ETest:     dao.NewE2ETestReportModel(),
+		modelSBOM:        dao.NewSBOMModel(),
+		modelPerfTest:    dao.NewPerfTestResultModel(),
+		modelQuality:     dao.NewQualityReportModel(),
+		modelJobStage:    dao.NewPublishJobStageModel(),
+		modelApp:         dao.NewScmAppModel(),
+		modelAppArrange:  dao.NewAppArrangeModel(),
+		appHandler:       appmgr.NewAppManager(),
+		settingsHandler:  settings.NewSettingManager(),
 	}
 }
 
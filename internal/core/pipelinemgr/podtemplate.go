@@ -0,0 +1,286 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/workflow"
+	"github.com/go-atomci/workflow/jenkins"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// archNodeLabel the well-known label of the node cpu architecture
+const archNodeLabel = "kubernetes.io/arch"
+
+// podSpecAnchor the spec line of the pod template generated by workflow
+const podSpecAnchor = "\nspec:\n"
+
+// buildPodSpec the pod level spec of build pod, the pod template of workflow only renders the containers
+type buildPodSpec struct {
+	NodeSelector     map[string]string               `json:"nodeSelector,omitempty"`
+	Tolerations      []settings.CompileEnvToleration `json:"tolerations,omitempty"`
+	ImagePullSecrets []podSecretRef                  `json:"imagePullSecrets,omitempty"`
+	// ServiceAccountName/Volumes from the agent template of project
+	ServiceAccountName string         `json:"serviceAccountName,omitempty"`
+	Volumes            []apiv1.Volume `json:"volumes,omitempty"`
+}
+
+type podSecretRef struct {
+	Name string `json:"name"`
+}
+
+func (s *buildPodSpec) empty() bool {
+	return s == nil || (len(s.NodeSelector) == 0 && len(s.Tolerations) == 0 && len(s.ImagePullSecrets) == 0 &&
+		s.ServiceAccountName == "" && len(s.Volumes) == 0)
+}
+
+// mergeBuildPodSpec merge the scheduling of compile params into one pod spec,
+// the first compile env wins if the node selectors conflict
+func mergeBuildPodSpec(params []compileEnv) *buildPodSpec {
+	spec := &buildPodSpec{NodeSelector: map[string]string{}}
+	secrets := map[string]bool{}
+	tolerations := map[settings.CompileEnvToleration]bool{}
+	for _, param := range params {
+		if param.OSType == models.CompileEnvOSWindows {
+			for key, value := range windowsNodeSelector {
+				spec.NodeSelector[key] = value
+			}
+		}
+		for key, value := range param.NodeSelector {
+			if exists, ok := spec.NodeSelector[key]; ok {
+				if exists != value {
+					log.Log.Warn("node selector: %v=%v of container: %v conflicts with %v, ignored", key, value, param.Name, exists)
+				}
+				continue
+			}
+			spec.NodeSelector[key] = value
+		}
+		for _, toleration := range param.Tolerations {
+			if !tolerations[toleration] {
+				tolerations[toleration] = true
+				spec.Tolerations = append(spec.Tolerations, toleration)
+			}
+		}
+		for _, secret := range param.ImagePullSecrets {
+			if !secrets[secret] {
+				secrets[secret] = true
+				spec.ImagePullSecrets = append(spec.ImagePullSecrets, podSecretRef{Name: secret})
+			}
+		}
+	}
+	return spec
+}
+
+// yaml return the fields of pod spec in yaml flow style, which indented under the spec
+func (s *buildPodSpec) yaml() (string, error) {
+	lines := []string{}
+	for _, field := range []struct {
+		name  string
+		value interface{}
+		empty bool
+	}{
+		{name: "nodeSelector", value: s.NodeSelector, empty: len(s.NodeSelector) == 0},
+		{name: "tolerations", value: s.Tolerations, empty: len(s.Tolerations) == 0},
+		{name: "imagePullSecrets", value: s.ImagePullSecrets, empty: len(s.ImagePullSecrets) == 0},
+		{name: "serviceAccountName", value: s.ServiceAccountName, empty: s.ServiceAccountName == ""},
+		{name: "volumes", value: s.Volumes, empty: len(s.Volumes) == 0},
+	} {
+		if field.empty {
+			continue
+		}
+		bytes, err := json.Marshal(field.value)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("  %v: %s\n", field.name, bytes))
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// patchPodTemplate insert the pod spec into the pod template of the pipeline xml
+func patchPodTemplate(pipelineXML string, spec *buildPodSpec) (string, error) {
+	if spec.empty() {
+		return pipelineXML, nil
+	}
+	index := strings.Index(pipelineXML, podSpecAnchor)
+	if index < 0 {
+		return "", errors.New("the pod template of pipeline has no spec")
+	}
+	fields, err := spec.yaml()
+	if err != nil {
+		return "", err
+	}
+	index += len(podSpecAnchor)
+	return pipelineXML[:index] + fields + pipelineXML[index:], nil
+}
+
+// podTemplateContext the ci context whose pod template is patched with the pod spec, the agent template and the build cloud
+type podTemplateContext struct {
+	jenkins.CIContext
+	PodSpec *buildPodSpec  `json:"pod_spec,omitempty"`
+	Agent   *AgentTemplate `json:"agent,omitempty"`
+	Cloud   *buildCloud    `json:"cloud,omitempty"`
+	// Label the agent label of project when the jenkins folder isolation is enabled
+	Label string `json:"-"`
+}
+
+// Run create or update the jenkins job with the patched pipeline, then trigger it
+func (c *podTemplateContext) Run(addr, user, token, crumbKey, crumbValue, jobName string, param []byte) (int64, error) {
+	job := &jenkinsJob{
+		url:        strings.TrimSuffix(addr, "/"),
+		user:       user,
+		token:      token,
+		crumbKey:   crumbKey,
+		crumbValue: crumbValue,
+		name:       jobName,
+	}
+	if c.Cloud != nil {
+		if err := job.syncCloud(c.Cloud); err != nil {
+			return 0, err
+		}
+	}
+	configXML, err := c.configXML(jobName)
+	if err != nil {
+		return 0, err
+	}
+	if err := job.createOrUpdate(configXML); err != nil {
+		return 0, err
+	}
+	nextBuildNumber, err := job.nextBuildNumber()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := job.request("POST", fmt.Sprintf("%v/job/%v/build?delay=0sec", job.url, job.name), xmlContentType, nil); err != nil {
+		return 0, err
+	}
+	return nextBuildNumber, nil
+}
+
+// configXML return the config xml of job, which calls the shared library when it is set
+func (c *podTemplateContext) configXML(jobName string) (string, error) {
+	pipelineXML, err := c.GetCIPipelineXML(c.CIContext)
+	if err != nil {
+		return "", err
+	}
+	if pipelineXML, err = patchPodTemplate(pipelineXML, c.PodSpec); err != nil {
+		return "", err
+	}
+	if pipelineXML, err = patchAgentTemplate(pipelineXML, c.Agent); err != nil {
+		return "", err
+	}
+	_, name := splitJenkinsJobPath(jobName)
+	if jenkinsSharedLibrary != "" {
+		params, err := c.sharedLibraryParams(pipelineXML, name)
+		if err != nil {
+			return "", err
+		}
+		return sharedLibraryJobXML(params)
+	}
+	directives := c.Agent.agentDirectives(name)
+	if len(directives) == 0 && c.Label != "" {
+		directives = append(directives, fmt.Sprintf("label '%v'", c.Label))
+	}
+	if c.Cloud != nil {
+		directives = append(directives, fmt.Sprintf("cloud '%v'", c.Cloud.Name))
+	}
+	return patchPipelineAgent(pipelineXML, directives...)
+}
+
+var errJenkinsJobNotFound = errors.New("404 not found")
+
+// jenkinsJob the jenkins job operations used by the custom flow processor
+type jenkinsJob struct {
+	url        string
+	user       string
+	token      string
+	crumbKey   string
+	crumbValue string
+	name       string
+}
+
+func (j *jenkinsJob) nextBuildNumber() (int64, error) {
+	body, err := j.request("GET", fmt.Sprintf("%v/job/%v/api/json", j.url, j.name), xmlContentType, nil)
+	if err != nil {
+		return 0, err
+	}
+	job := jenkins.Job{}
+	if err := json.Unmarshal(body, &job); err != nil {
+		return 0, err
+	}
+	return job.NextBuildNumber, nil
+}
+
+func (j *jenkinsJob) createOrUpdate(configXML string) error {
+	url := fmt.Sprintf("%v/job/%v/config.xml", j.url, j.name)
+	if _, err := j.nextBuildNumber(); err == errJenkinsJobNotFound {
+		folder, name := splitJenkinsJobPath(j.name)
+		if folder != "" {
+			if err := j.ensureFolder(folder); err != nil {
+				return err
+			}
+		}
+		url = fmt.Sprintf("%v/createItem?name=%v", j.parentURL(folder), name)
+	} else if err != nil {
+		return err
+	}
+	_, err := j.request("POST", url, xmlContentType, bytes.NewBufferString(configXML))
+	return err
+}
+
+const xmlContentType = "application/xml;charset=UTF-8"
+
+func (j *jenkinsJob) request(method, url, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Add("Accept-Charset", "utf-8")
+	if j.crumbKey != "" && j.crumbValue != "" {
+		req.Header.Set(j.crumbKey, j.crumbValue)
+	}
+	req.SetBasicAuth(j.user, j.token)
+	rsp, err := workflow.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	rspBody, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return rspBody, nil
+	case http.StatusNotFound:
+		return nil, errJenkinsJobNotFound
+	}
+	return nil, fmt.Errorf("http code: %d, response: %s", rsp.StatusCode, string(rspBody))
+}
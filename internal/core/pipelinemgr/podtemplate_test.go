@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/workflow/jenkins"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestMergeBuildPodSpec(t *testing.T) {
+	gpu := settings.CompileEnvToleration{Key: "gpu", Operator: "Exists", Effect: "NoSchedule"}
+	spec := mergeBuildPodSpec([]compileEnv{
+		{Name: "a", NodeSelector: map[string]string{"pool": "ci"}, Tolerations: []settings.CompileEnvToleration{gpu}, ImagePullSecrets: []string{"harbor"}},
+		{Name: "b", NodeSelector: map[string]string{"pool": "build", "arch": "amd64"}, Tolerations: []settings.CompileEnvToleration{gpu}, ImagePullSecrets: []string{"harbor", "dockerhub"}},
+	})
+	want := &buildPodSpec{
+		NodeSelector:     map[string]string{"pool": "ci", "arch": "amd64"},
+		Tolerations:      []settings.CompileEnvToleration{gpu},
+		ImagePullSecrets: []podSecretRef{{Name: "harbor"}, {Name: "dockerhub"}},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Fatalf("merged pod spec = %+v, want %+v", spec, want)
+	}
+	if !mergeBuildPodSpec([]compileEnv{{Name: "a"}}).empty() {
+		t.Fatalf("pod spec without scheduling should be empty")
+	}
+}
+
+func TestPatchPodTemplate(t *testing.T) {
+	ciContext := jenkins.CIContext{
+		ContainerTemplates: []jenkins.ContainerEnv{
+			compileContainer(compileEnv{Name: "demo", Image: "golang:1.15", WorkingDir: "/home/jenkins/agent", CPULimit: "1"}),
+		},
+		CommonContext: jenkins.CommonContext{Namespace: "devops"},
+	}
+	pipelineXML, err := ciContext.GetCIPipelineXML(ciContext)
+	if err != nil {
+		t.Fatalf("generate pipeline xml: %v", err)
+	}
+	spec := &buildPodSpec{
+		NodeSelector:     map[string]string{"pool": "ci"},
+		Tolerations:      []settings.CompileEnvToleration{{Key: "dedicated", Operator: "Equal", Value: "ci", Effect: "NoSchedule"}},
+		ImagePullSecrets: []podSecretRef{{Name: "harbor"}},
+	}
+	patched, err := patchPodTemplate(pipelineXML, spec)
+	if err != nil {
+		t.Fatalf("patch pod template: %v", err)
+	}
+
+	start := strings.Index(patched, "apiVersion: v1")
+	end := strings.Index(patched[start:], `"""`)
+	if start < 0 || end < 0 {
+		t.Fatalf("pod template not found in %v", patched)
+	}
+	pod := apiv1.Pod{}
+	if err := yaml.Unmarshal([]byte(patched[start:start+end]), &pod); err != nil {
+		t.Fatalf("parse pod template: %v", err)
+	}
+	if pod.Spec.NodeSelector["pool"] != "ci" || len(pod.Spec.Tolerations) != 1 || pod.Spec.Tolerations[0].Value != "ci" ||
+		len(pod.Spec.ImagePullSecrets) != 1 || pod.Spec.ImagePullSecrets[0].Name != "harbor" {
+		t.Fatalf("pod spec not patched: %+v", pod.Spec)
+	}
+	if len(pod.Spec.Containers) != 1 || pod.Spec.Containers[0].Resources.Limits.Cpu().String() != "1" {
+		t.Fatalf("containers broken by patch: %+v", pod.Spec.Containers)
+	}
+
+	if unchanged, _ := patchPodTemplate(pipelineXML, &buildPodSpec{}); unchanged != pipelineXML {
+		t.Fatalf("empty pod spec should not patch the pipeline")
+	}
+}
+
+func TestBuildCloudPipeline(t *testing.T) {
+	cloud := &buildCloud{Name: buildCloudName(3), ServerURL: "https://10.0.0.1:6443", Namespace: "ci", Token: "secret\"token", AgentURL: "http://jenkins.devops:8080"}
+	script, err := cloud.script()
+	if err != nil {
+		t.Fatalf("render cloud script: %v", err)
+	}
+	for _, want := range []string{"StringCredentialsImpl(", "setSkipTlsVerify(true)", "setJenkinsUrl(", base64.StdEncoding.EncodeToString([]byte(cloud.Token))} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("cloud script should contain %q:\n%v", want, script)
+		}
+	}
+	if strings.Contains(script, cloud.Token) || strings.Contains(script, "setJenkinsTunnel(") {
+		t.Fatalf("cloud script should encode the token and skip the empty tunnel:\n%v", script)
+	}
+
+	cloud = &buildCloud{Name: buildCloudName(3), ServerURL: "https://10.0.0.1:6443", Namespace: "ci", KubeConfig: "apiVersion: v1", CACert: "ca"}
+	if script, _ = cloud.script(); !strings.Contains(script, "FileCredentialsImpl(") || !strings.Contains(script, "setSkipTlsVerify(false)") {
+		t.Fatalf("cloud script with kubeconfig:\n%v", script)
+	}
+
+	ciContext := jenkins.CIContext{CommonContext: jenkins.CommonContext{Namespace: "ci"}}
+	pipelineXML, _ := ciContext.GetCIPipelineXML(ciContext)
+	patched, err := patchPipelineAgent(pipelineXML, fmt.Sprintf("cloud '%v'", cloud.Name))
+	if err != nil {
+		t.Fatalf("patch pipeline cloud: %v", err)
+	}
+	if !strings.Contains(patched, "cloud 'atomci-cluster-3'\n            defaultContainer 'jnlp'") {
+		t.Fatalf("pipeline agent not patched:\n%v", patched)
+	}
+}
+
+func TestAgentTemplate(t *testing.T) {
+	agent := &AgentTemplate{
+		Labels:         map[string]string{"team": "payment"},
+		Volumes:        []*AgentVolume{{Name: "m2", Type: AgentVolumePVC, Source: "maven-cache", MountPath: "/root/.m2"}},
+		ServiceAccount: "builder",
+		IdleMinutes:    10,
+	}
+	if err := agent.Validate(); err != nil {
+		t.Fatalf("valid agent template: %v", err)
+	}
+	for _, invalid := range []*AgentTemplate{
+		{Volumes: []*AgentVolume{{Name: agentWorkspaceVolume, Type: AgentVolumeEmptyDir, MountPath: "/cache"}}},
+		{Volumes: []*AgentVolume{{Name: "cache", Type: AgentVolumeHostPath, MountPath: "/cache"}}},
+		{Volumes: []*AgentVolume{{Name: "cache", Type: "nfs", Source: "x", MountPath: "/cache"}}},
+		{Volumes: []*AgentVolume{{Name: "cache", Type: AgentVolumeEmptyDir, MountPath: "cache"}}},
+		{IdleMinutes: -1},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Fatalf("agent template %+v should be invalid", invalid)
+		}
+	}
+
+	ciContext := jenkins.CIContext{
+		ContainerTemplates: []jenkins.ContainerEnv{{Name: "jnlp", Image: "jenkins/inbound-agent"}, {Name: "kaniko", Image: "kaniko"}},
+		CommonContext:      jenkins.CommonContext{Namespace: "devops"},
+	}
+	pipelineXML, _ := ciContext.GetCIPipelineXML(ciContext)
+	patched, err := patchPodTemplate(pipelineXML, &buildPodSpec{ServiceAccountName: agent.ServiceAccount, Volumes: agent.podVolumes()})
+	if err == nil {
+		patched, err = patchAgentTemplate(patched, agent)
+	}
+	if err == nil {
+		patched, err = patchPipelineAgent(patched, agent.agentDirectives("atomci_1_2_3_build")...)
+	}
+	if err != nil {
+		t.Fatalf("patch agent template: %v", err)
+	}
+	if !strings.Contains(patched, "label 'atomci_1_2_3_build'\n            idleMinutes 10\n            defaultContainer 'jnlp'") {
+		t.Fatalf("agent directives not patched:\n%v", patched)
+	}
+
+	start := strings.Index(patched, "apiVersion: v1")
+	end := strings.Index(patched[start:], `"""`)
+	pod := apiv1.Pod{}
+	if err := yaml.Unmarshal([]byte(patched[start:start+end]), &pod); err != nil {
+		t.Fatalf("parse pod template: %v", err)
+	}
+	if pod.Labels["team"] != "payment" || pod.Namespace != "devops" || pod.Spec.ServiceAccountName != "builder" ||
+		len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName != "maven-cache" {
+		t.Fatalf("pod not patched: %+v", pod)
+	}
+	for _, container := range pod.Spec.Containers {
+		if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != "/root/.m2" {
+			t.Fatalf("container %v volume mounts: %+v", container.Name, container.VolumeMounts)
+		}
+	}
+}
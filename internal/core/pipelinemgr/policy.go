@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// the actions when the deploy of stage failed
+const (
+	// OnFailureHalt keep the publish failed and wait for manual operation
+	OnFailureHalt = "halt"
+	// OnFailureRollback roll back the workloads to the previous revision, then halt
+	OnFailureRollback = "rollback"
+)
+
+// StagePolicy the progression policy of pipeline stage
+type StagePolicy struct {
+	// AutoPromote promote the publish to the next stage when all steps of this stage success,
+	// the deploy step success means the health check passed.
+	AutoPromote bool `json:"auto_promote,omitempty"`
+	// RequireApproval the publish can enter this stage only after one of the publish approvers approved
+	RequireApproval bool `json:"require_approval,omitempty"`
+	// OnFailure halt/rollback, default is halt
+	OnFailure string `json:"on_failure,omitempty"`
+	// SLAWarnHours/SLAEscalateHours override the system sla thresholds of the publish waiting in the manual step of this stage,
+	// 0 means use the system default
+	SLAWarnHours     int `json:"sla_warn_hours,omitempty"`
+	SLAEscalateHours int `json:"sla_escalate_hours,omitempty"`
+	// QualityGate the publish can enter this stage only when the quality verdict passed, eg: the prod stage
+	QualityGate *QualityGate `json:"quality_gate,omitempty"`
+}
+
+// Validate ..
+func (p *StagePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.SLAWarnHours < 0 || p.SLAEscalateHours < 0 {
+		return fmt.Errorf("SLA 时长不能为负数")
+	}
+	if p.SLAWarnHours > 0 && p.SLAEscalateHours > 0 && p.SLAEscalateHours < p.SLAWarnHours {
+		return fmt.Errorf("SLA 升级时长: %v 不能小于提醒时长: %v", p.SLAEscalateHours, p.SLAWarnHours)
+	}
+	if err := p.QualityGate.Validate(); err != nil {
+		return err
+	}
+	switch p.OnFailure {
+	case "", OnFailureHalt, OnFailureRollback:
+		return nil
+	default:
+		return fmt.Errorf("不支持的失败处理策略: %v, 可选值: %v/%v", p.OnFailure, OnFailureHalt, OnFailureRollback)
+	}
+}
+
+// GetStagePolicy return the progression policy of the stage in pipeline instance, never return nil
+func (pm *PipelineManager) GetStagePolicy(instanceID, stageID int64) (*StagePolicy, error) {
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(instanceID, stageID)
+	if err != nil {
+		return nil, err
+	}
+	if stageJSON.Policy == nil {
+		return &StagePolicy{}, nil
+	}
+	return stageJSON.Policy, nil
+}
+
+// RollbackDeployJob roll back the workloads deployed by the failed deploy job to the previous revision,
+// only the env applied to cluster directly is supported, argo cd env should roll back by git revert.
+func (pm *PipelineManager) RollbackDeployJob(job *models.PublishJob) ([]string, error) {
+	envStage, err := pm.modelProject.GetProjectEnvByID(job.EnvID)
+	if err != nil {
+		return nil, err
+	}
+	if envStage.ArgoCD != 0 {
+		return nil, fmt.Errorf("环境 %v 通过 Argo CD 部署, 不支持自动回滚", envStage.Name)
+	}
+	clusterItem, err := pm.settingsHandler.GetIntegrateSettingByID(envStage.Cluster)
+	if err != nil {
+		log.Log.Error("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
+		return nil, err
+	}
+	items, err := pm.getDeployHealthCheckItems(job)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := kuberes.NewHealthChecker(clusterItem.Name, envStage.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return checker.Rollback(items)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// PreparePreviewNamespace create the namespace and registry secret of preview env, which are deleted with the preview
+func (pm *PipelineManager) PreparePreviewNamespace(env *models.ProjectEnv) error {
+	clusterModel, err := pm.settingsHandler.GetIntegrateSettingByID(env.Cluster)
+	if err != nil {
+		return err
+	}
+	if err := kuberes.CreateK8sNamespace(clusterModel.Name, env.Namespace); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("创建命名空间 %v 失败: %s", env.Namespace, err.Error())
+	}
+	return kuberes.CreateRegistrySecret(clusterModel.Name, env.Namespace, env.ID)
+}
+
+// DeployPreview apply the arranges of apps to the preview env with the images built for the head of branch,
+// the image which is not built yet fails the deploy, so the preview is retried after the build finished.
+func (pm *PipelineManager) DeployPreview(env *models.ProjectEnv, appIDs []int64, branch string) error {
+	pm = pm.withCommitCache()
+	registryConf, err := pm.getEnvRegistryConfig(env.ID)
+	if err != nil {
+		return err
+	}
+	provider, err := registryConf.Provider()
+	if err != nil {
+		return err
+	}
+	envRegistryHost := registryHost(registryConf.URL)
+	verifier, err := pm.newSignatureVerifier(env)
+	if err != nil {
+		return err
+	}
+
+	templates := []string{}
+	for _, appID := range appIDs {
+		arrange, err := pm.appHandler.GetRealArrange(appID, env.ID)
+		if err != nil {
+			log.Log.Debug("app: %v has no arrange in preview env: %v, skip deploy", appID, env.ID)
+			continue
+		}
+		imageMapping, err := pm.modelAppArrange.GetAppImageMappingByArrangeIDAndProjectAppID(arrange.ID, appID)
+		if err != nil {
+			return fmt.Errorf("应用: %v 未配置镜像映射: %s", appID, err.Error())
+		}
+		image, originImage, err := pm.imageAddrOfMapping(imageMapping, appID, branch)
+		if err != nil {
+			return err
+		}
+		if host, repo, tag := splitImage(image); strings.ToLower(host) == envRegistryHost {
+			if _, err := provider.ManifestDigest(repo, tag); err != nil {
+				return fmt.Errorf("镜像 %v 尚未构建: %s", image, err.Error())
+			}
+		}
+		if verifier != nil {
+			if err := verifier.verify(image); err != nil {
+				return err
+			}
+		}
+		arrangeConfig, err := pm.appHandler.RenderRealArrange(arrange, image, branch)
+		if err != nil {
+			return fmt.Errorf("应用编排渲染失败: %s", err.Error())
+		}
+		arrangeConfig = strings.Replace(arrangeConfig, originImage, image, -1)
+		arrangeConfig, err = kuberes.LabelOwnedResources(arrangeConfig, appID, env.ID)
+		if err != nil {
+			return fmt.Errorf("应用编排解析失败: %s", err.Error())
+		}
+		templates = append(templates, arrangeConfig)
+	}
+	if len(templates) == 0 {
+		return fmt.Errorf("预览环境没有可部署的应用编排")
+	}
+	// the preview env is applied to cluster directly, even if the source env is deployed by argo cd
+	return pm.applyDeployTemplate(env, env.ProjectID, strings.Join(templates, "\n---\n"))
+}
+
+// TeardownPreview delete the namespace of preview env with all the resources deployed
+func (pm *PipelineManager) TeardownPreview(env *models.ProjectEnv) error {
+	clusterModel, err := pm.settingsHandler.GetIntegrateSettingByID(env.Cluster)
+	if err != nil {
+		return err
+	}
+	return kuberes.DeleteK8sNamespace(clusterModel.Name, env.Namespace)
+}
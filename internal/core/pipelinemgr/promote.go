@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/rbac"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/registry"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// RunPromoteStep copy the images built in previous stage to the registry of current stage instead of rebuilding,
+// the digest of promoted image was recorded on the publish app. return publish status, error
+func (pm *PipelineManager) RunPromoteStep(projectID, publishID, stageID int64, operator string) (int64, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return models.Failed, fmt.Errorf("请选择有效的项目/流水线后重试：%s", err.Error())
+	}
+	if err := rbac.VerifyEnvAction(operator, projectID, stageID, rbac.ActionPromote); err != nil {
+		return models.Skipped, err
+	}
+	buildJob, err := pm.modelPublishJob.GetLastSuccessBuildJob(publishID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return models.Failed, fmt.Errorf("流水线尚未构建成功, 无法晋级镜像")
+		}
+		return models.Failed, err
+	}
+	srcRegistry, err := pm.getEnvRegistryProvider(buildJob.EnvID)
+	if err != nil {
+		return models.Failed, err
+	}
+	dstRegistry, err := pm.getEnvRegistryProvider(stageID)
+	if err != nil {
+		return models.Failed, err
+	}
+
+	publishApps, err := pm.modelPublish.GetPublishAppsByID(publishID)
+	if err != nil {
+		return models.Failed, err
+	}
+	for _, publishApp := range publishApps {
+		srcImage, err := pm.getAppEnvImage(publishApp, buildJob.EnvID)
+		if err != nil {
+			return models.Failed, err
+		}
+		dstImage, err := pm.getAppEnvImage(publishApp, stageID)
+		if err != nil {
+			return models.Failed, err
+		}
+		digest, err := promoteImage(srcRegistry, srcImage, dstRegistry, dstImage)
+		if err != nil {
+			log.Log.Error("promote image %v to %v occur error: %s", srcImage, dstImage, err.Error())
+			return models.Failed, fmt.Errorf("镜像 %v 晋级至 %v 失败: %s", srcImage, dstImage, err.Error())
+		}
+		log.Log.Info("operator: %v promoted image %v to %v, digest: %v", operator, srcImage, dstImage, digest)
+		publishApp.ImageDigest = digest
+		if err := pm.modelPublish.UpdatePublishApp(publishApp); err != nil {
+			return models.Failed, err
+		}
+	}
+	return models.Success, nil
+}
+
+// getEnvRegistryProvider return the registry provider of env
+func (pm *PipelineManager) getEnvRegistryProvider(envID int64) (registry.Provider, error) {
+	registryConf, err := pm.getEnvRegistryConfig(envID)
+	if err != nil {
+		return nil, err
+	}
+	return registryConf.Provider()
+}
+
+// getEnvRegistryConfig ..
+func (pm *PipelineManager) getEnvRegistryConfig(envID int64) (*settings.RegistryConfig, error) {
+	envStage, err := pm.modelProject.GetProjectEnvByID(envID)
+	if err != nil {
+		return nil, err
+	}
+	registryItem, err := pm.settingsHandler.GetIntegrateSettingByID(envStage.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("get env: %v registry occur error: %s", envStage.Name, err.Error())
+	}
+	registryConf, ok := registryItem.Config.(*settings.RegistryConfig)
+	if !ok {
+		return nil, fmt.Errorf("集成配置 %v 不是有效的镜像仓库配置", registryItem.Name)
+	}
+	return registryConf, nil
+}
+
+// getAppEnvImage return the image address with tag of app in env arrange
+func (pm *PipelineManager) getAppEnvImage(publishApp *models.PublishApp, envID int64) (string, error) {
+	arrange, err := pm.appHandler.GetRealArrange(publishApp.ProjectAppID, envID)
+	if err != nil {
+		return "", fmt.Errorf("获取应用: %v 环境: %v 编排失败: %s", publishApp.ProjectAppID, envID, err.Error())
+	}
+	image, _, err := pm.generateImageAddr(arrange.ID, publishApp.ProjectAppID, PublishAppBuildBranch(publishApp))
+	return image, err
+}
+
+// promoteImage copy image between registries, the image address is registry host/repository:tag
+func promoteImage(src registry.Provider, srcImage string, dst registry.Provider, dstImage string) (string, error) {
+	_, srcRepo, srcTag := splitImage(srcImage)
+	_, dstRepo, dstTag := splitImage(dstImage)
+	return registry.Copy(src, srcRepo, srcTag, dst, dstRepo, dstTag)
+}
+
+// splitImage split image address into registry host, repository and tag, tag is latest by default
+func splitImage(image string) (string, string, string) {
+	name, _ := removeImageUrlTag(image)
+	tag := "latest"
+	if name != image {
+		tag = image[len(name)+1:]
+	}
+	host := ""
+	parts := strings.SplitN(name, "/", 2)
+	// the first part is registry host when it contains `.` or `:`, eg: harbor.example.com, 10.10.0.8:9980
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		host, name = parts[0], parts[1]
+	}
+	return host, name, tag
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import "testing"
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image               string
+		host, repo, tagWant string
+	}{
+		{"harbor.example.com/atomci/app:master-1a2b3c4", "harbor.example.com", "atomci/app", "master-1a2b3c4"},
+		{"10.10.0.8:9980/atomci/app:v1", "10.10.0.8:9980", "atomci/app", "v1"},
+		{"10.10.0.8:9980/atomci/app", "10.10.0.8:9980", "atomci/app", "latest"},
+		{"atomci/app:v1", "", "atomci/app", "v1"},
+	}
+	for _, tt := range tests {
+		host, repo, tag := splitImage(tt.image)
+		if host != tt.host || repo != tt.repo || tag != tt.tagWant {
+			t.Errorf("splitImage(%q) = %q, %q, %q, want %q, %q, %q", tt.image, host, repo, tag, tt.host, tt.repo, tt.tagWant)
+		}
+	}
+}
+
+func TestWithImageTag(t *testing.T) {
+	tests := []struct {
+		image, tag, want string
+	}{
+		{"harbor.example.com/atomci/app:master-1a2b3c4", "v1.0", "harbor.example.com/atomci/app:v1.0"},
+		{"10.10.0.8:9980/atomci/app", "v1.0", "10.10.0.8:9980/atomci/app:v1.0"},
+		{"harbor.example.com/atomci/app:v1", "sha256:abc", "harbor.example.com/atomci/app@sha256:abc"},
+		{"harbor.example.com/atomci/app@sha256:abc", "v2", "harbor.example.com/atomci/app:v2"},
+	}
+	for _, tt := range tests {
+		got, err := withImageTag(tt.image, tt.tag)
+		if err != nil || got != tt.want {
+			t.Errorf("withImageTag(%q, %q) = %q, %v, want %q", tt.image, tt.tag, got, err, tt.want)
+		}
+	}
+	if _, err := withImageTag("atomci/app", "a/b"); err == nil {
+		t.Errorf("withImageTag accepted invalid tag")
+	}
+}
+
+func TestWithPinnedImageTag(t *testing.T) {
+	got, err := withImageTag("harbor.example.com/atomci/app:master-1a2b3c4", "master-1a2b3c4@sha256:abc")
+	if want := "harbor.example.com/atomci/app:master-1a2b3c4@sha256:abc"; err != nil || got != want {
+		t.Errorf("withImageTag pinned = %q, %v, want %q", got, err, want)
+	}
+	if _, err := withImageTag("atomci/app", "v1@md5:abc"); err == nil {
+		t.Errorf("withImageTag accepted invalid digest")
+	}
+	if got := registryHost("https://Harbor.example.com/library"); got != "harbor.example.com" {
+		t.Errorf("registryHost = %q, want harbor.example.com", got)
+	}
+}
@@ -18,11 +18,14 @@ package pipelinemgr
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-atomci/atomci/internal/core/events"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/metrics"
 	"github.com/go-atomci/atomci/utils"
 )
 
@@ -88,6 +91,18 @@ func (pm *PipelineManager) GetPublishStats(projectID int64, request *PublishStat
 func (pm *PipelineManager) CreatePublishJob(projectID, publishID, stageID int64,
 	operator string, jobType string,
 	allAppsParms []*AppParamsForCreatePublishJob) (int64, error) {
+	route := &ciServerRoute{Version: pm.ciCredentialVersion(stageID)}
+	return pm.createPublishJob(projectID, publishID, stageID, operator, jobType, allAppsParms, route)
+}
+
+// createPublishJob create the publish job which runs on the jenkins of route
+func (pm *PipelineManager) createPublishJob(projectID, publishID, stageID int64,
+	operator string, jobType string,
+	allAppsParms []*AppParamsForCreatePublishJob, route *ciServerRoute) (int64, error) {
+	publishItem, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil {
+		return 0, err
+	}
 	publishJob := &models.PublishJob{
 		Operator:  operator,
 		ProjectID: projectID,
@@ -95,6 +110,9 @@ func (pm *PipelineManager) CreatePublishJob(projectID, publishID, stageID int64,
 		EnvID:     stageID,
 		Status:    models.StatusInit,
 		JobType:   jobType,
+		StepIndex: publishItem.StepIndex,
+		CIVersion: route.Version,
+		CIServer:  route.SettingID,
 	}
 	id, err := pm.modelPublishJob.CreatePublishJobifNotExist(publishJob)
 	if err != nil {
@@ -102,14 +120,16 @@ func (pm *PipelineManager) CreatePublishJob(projectID, publishID, stageID int64,
 	}
 	for _, app := range allAppsParms {
 		publishJobApp := &models.PublishJobApp{
-			ProjectID:    projectID,
-			PublishJobID: id,
-			ProjectAPPID: app.ProjectAppID,
-			BranchName:   app.Branch,
-			BranchURL:    app.Path,
-			ImageVersion: app.ImageVersion,
-			Gray:         app.Gray,
-			ImageAddr:    app.ImageAddr,
+			ProjectID:       projectID,
+			PublishJobID:    id,
+			ProjectAPPID:    app.ProjectAppID,
+			BranchName:      app.Branch,
+			BranchURL:       app.Path,
+			ImageVersion:    app.ImageVersion,
+			Gray:            app.Gray,
+			ImageAddr:       app.ImageAddr,
+			ArrangeRevision: app.ArrangeRevision,
+			Wave:            app.Wave,
 		}
 		_, err := pm.modelPublishJob.CreateJobAppIfNotExist(publishJobApp)
 		if err != nil {
@@ -118,6 +138,9 @@ func (pm *PipelineManager) CreatePublishJob(projectID, publishID, stageID int64,
 			return 0, err
 		}
 	}
+	publishJob.ID = id
+	events.EmitJobStatus(publishJob)
+	go pm.ReportJobCommitStatus(publishJob)
 	return id, nil
 }
 
@@ -139,6 +162,7 @@ func (pm *PipelineManager) UpdatePublishJob(publishJobID, runID int64) error {
 		log.Log.Error("udpate publishjob runID occur error: %s", err)
 		return err
 	}
+	events.EmitJobStatus(modelPublishJob)
 	return nil
 }
 
@@ -163,5 +187,33 @@ func (pm *PipelineManager) UpdatePublishJobStatus(publishJobID int64, status str
 		log.Log.Error("status: %v, unexception, reset to FAILURE")
 		publishJob.Status = "FAILURE"
 	}
-	return pm.modelPublishJob.UpdatePublishJob(publishJob)
+	if err := pm.modelPublishJob.UpdatePublishJob(publishJob); err != nil {
+		return err
+	}
+	ObserveJobFinished(publishJob)
+	events.EmitJobStatus(publishJob)
+	go pm.RecordJobStages(publishJob, "")
+	return nil
+}
+
+// ObserveJobFinished record the metrics of the publish job in end status
+func ObserveJobFinished(job *models.PublishJob) {
+	if !utils.Contains([]string{models.StatusSuccess, models.StatusFailure, models.StatusAbort}, job.Status) {
+		return
+	}
+	duration := float64(job.DurationInMillis) / 1000
+	if job.DurationInMillis == 0 {
+		duration = time.Since(job.CreateAt).Seconds()
+	}
+	metrics.StepDuration.Observe(duration, job.JobType, job.Status)
+	if job.JobType != models.JobTypeBuild {
+		return
+	}
+	project := strconv.FormatInt(job.ProjectID, 10)
+	switch job.Status {
+	case models.StatusSuccess:
+		metrics.BuildsSucceeded.Inc(project)
+	case models.StatusFailure:
+		metrics.BuildsFailed.Inc(project)
+	}
 }
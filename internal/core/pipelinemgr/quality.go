@@ -0,0 +1,375 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// the verdicts of quality summary
+const (
+	QualityPassed  = "passed"
+	QualityFailed  = "failed"
+	QualityPending = "pending"
+)
+
+// the severities of vulnerability, from high to low
+var vulnSeverities = []string{"critical", "high", "medium", "low"}
+
+// QualityGate the thresholds of the quality verdict, the zero value of threshold means not checked
+type QualityGate struct {
+	MinUnitTestPassRate float64 `json:"min_unit_test_pass_rate,omitempty"`
+	MinCoverage         float64 `json:"min_coverage,omitempty"`
+	RequireSonarPass    bool    `json:"require_sonar_pass,omitempty"`
+	// BlockSeverity the vulnerabilities at or above the severity block the release, eg: high
+	BlockSeverity  string  `json:"block_severity,omitempty"`
+	MinE2EPassRate float64 `json:"min_e2e_pass_rate,omitempty"`
+}
+
+// Validate ..
+func (g *QualityGate) Validate() error {
+	if g == nil {
+		return nil
+	}
+	for _, rate := range []float64{g.MinUnitTestPassRate, g.MinCoverage, g.MinE2EPassRate} {
+		if rate < 0 || rate > 100 {
+			return fmt.Errorf("质量门禁阈值: %v 无效，须在 0-100 之间", rate)
+		}
+	}
+	if g.BlockSeverity != "" && severityLevel(g.BlockSeverity) < 0 {
+		return fmt.Errorf("质量门禁漏洞等级: %v 无效，可选值为 %v", g.BlockSeverity, strings.Join(vulnSeverities, "/"))
+	}
+	return nil
+}
+
+// defaultQualityGate the thresholds of the ready to release indicator
+func defaultQualityGate() *QualityGate {
+	return &QualityGate{
+		MinUnitTestPassRate: beego.AppConfig.DefaultFloat("quality::min_unit_test_pass_rate", 0),
+		MinCoverage:         beego.AppConfig.DefaultFloat("quality::min_coverage", 0),
+		RequireSonarPass:    beego.AppConfig.DefaultBool("quality::require_sonar_pass", false),
+		BlockSeverity:       beego.AppConfig.DefaultString("quality::block_severity", ""),
+		MinE2EPassRate:      beego.AppConfig.DefaultFloat("quality::min_e2e_pass_rate", 0),
+	}
+}
+
+func severityLevel(severity string) int {
+	for i, item := range vulnSeverities {
+		if item == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// QualityCheck the result of one threshold of quality gate
+type QualityCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// QualityTests the test counts, the pass rate is the percent of passed tests in the executed tests
+type QualityTests struct {
+	Total    int     `json:"total"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	Skipped  int     `json:"skipped"`
+	PassRate float64 `json:"pass_rate"`
+}
+
+func (t *QualityTests) add(total, failed, skipped int) {
+	t.Total += total
+	t.Failed += failed
+	t.Skipped += skipped
+	t.Passed = t.Total - t.Failed - t.Skipped
+	t.PassRate = e2ePassRate(t.Total, t.Failed, t.Skipped)
+}
+
+// QualitySummary the quality verdict of publish aggregated from the latest reports of all steps
+type QualitySummary struct {
+	Verdict         string                  `json:"verdict"`
+	Ready           bool                    `json:"ready"`
+	Gate            *QualityGate            `json:"gate"`
+	UnitTests       QualityTests            `json:"unit_tests"`
+	Coverage        map[string]float64      `json:"coverage"`
+	Sonar           map[string]string       `json:"sonar"`
+	Vulnerabilities map[string]int          `json:"vulnerabilities"`
+	E2ETests        QualityTests            `json:"e2e_tests"`
+	Checks          []*QualityCheck         `json:"checks"`
+	Reports         []*models.QualityReport `json:"reports"`
+}
+
+// failedChecks the messages of the checks not passed
+func (s *QualitySummary) failedChecks() string {
+	messages := []string{}
+	for _, check := range s.Checks {
+		if check.Status != QualityPassed {
+			messages = append(messages, check.Message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// evaluateQuality aggregate the latest report of each app and kind, and the reports of the latest e2e test job,
+// the reports are ordered by id desc
+func evaluateQuality(gate *QualityGate, reports []*models.QualityReport, e2eReports []*models.E2ETestReport) *QualitySummary {
+	summary := &QualitySummary{
+		Gate:            gate,
+		Coverage:        map[string]float64{},
+		Sonar:           map[string]string{},
+		Vulnerabilities: map[string]int{},
+		Checks:          []*QualityCheck{},
+		Reports:         []*models.QualityReport{},
+	}
+	latest := map[string]bool{}
+	for _, report := range reports {
+		key := report.AppName + "/" + report.Kind
+		if latest[key] {
+			continue
+		}
+		latest[key] = true
+		summary.Reports = append(summary.Reports, report)
+		switch report.Kind {
+		case models.QualityUnitTest:
+			summary.UnitTests.add(report.Total, report.Failed, report.Skipped)
+		case models.QualityCoverage:
+			summary.Coverage[report.AppName] = report.Coverage
+		case models.QualitySonar:
+			summary.Sonar[report.AppName] = report.SonarStatus
+		case models.QualityVulnerability:
+			for severity, count := range map[string]int{"critical": report.Critical, "high": report.High, "medium": report.Medium, "low": report.Low} {
+				summary.Vulnerabilities[severity] += count
+			}
+		}
+	}
+	for _, report := range e2eReports {
+		if report.PublishJobID == e2eReports[0].PublishJobID {
+			summary.E2ETests.add(report.Total, report.Failed, report.Skipped)
+		}
+	}
+
+	check := func(name string, reported, passed bool, message string) {
+		status := QualityPassed
+		if !reported {
+			status, message = QualityPending, fmt.Sprintf("%s 未上报", name)
+		} else if !passed {
+			status = QualityFailed
+		}
+		summary.Checks = append(summary.Checks, &QualityCheck{Name: name, Status: status, Message: message})
+	}
+	if gate.MinUnitTestPassRate > 0 {
+		rate := summary.UnitTests.PassRate
+		check(models.QualityUnitTest, summary.UnitTests.Total-summary.UnitTests.Skipped > 0, rate >= gate.MinUnitTestPassRate,
+			fmt.Sprintf("单元测试通过率 %.2f%%，阈值 %.2f%%", rate, gate.MinUnitTestPassRate))
+	}
+	if gate.MinCoverage > 0 {
+		lowApps := []string{}
+		for app, coverage := range summary.Coverage {
+			if coverage < gate.MinCoverage {
+				lowApps = append(lowApps, fmt.Sprintf("%s(%.2f%%)", app, coverage))
+			}
+		}
+		sort.Strings(lowApps)
+		check(models.QualityCoverage, len(summary.Coverage) > 0, len(lowApps) == 0,
+			fmt.Sprintf("覆盖率阈值 %.2f%%，未达标应用: %s", gate.MinCoverage, strings.Join(lowApps, ",")))
+	}
+	if gate.RequireSonarPass {
+		failedApps := []string{}
+		for app, status := range summary.Sonar {
+			if status != "OK" {
+				failedApps = append(failedApps, fmt.Sprintf("%s(%s)", app, status))
+			}
+		}
+		sort.Strings(failedApps)
+		check(models.QualitySonar, len(summary.Sonar) > 0, len(failedApps) == 0,
+			fmt.Sprintf("Sonar 质量门禁未通过应用: %s", strings.Join(failedApps, ",")))
+	}
+	if gate.BlockSeverity != "" {
+		blocked := 0
+		for _, severity := range vulnSeverities[:severityLevel(gate.BlockSeverity)+1] {
+			blocked += summary.Vulnerabilities[severity]
+		}
+		check(models.QualityVulnerability, latestKindReported(summary.Reports, models.QualityVulnerability), blocked == 0,
+			fmt.Sprintf("%v 及以上等级漏洞 %v 个", gate.BlockSeverity, blocked))
+	}
+	if gate.MinE2EPassRate > 0 {
+		rate := summary.E2ETests.PassRate
+		check(models.StepE2ETest, summary.E2ETests.Total-summary.E2ETests.Skipped > 0, rate >= gate.MinE2EPassRate,
+			fmt.Sprintf("端到端测试通过率 %.2f%%，阈值 %.2f%%", rate, gate.MinE2EPassRate))
+	}
+
+	summary.Verdict = QualityPassed
+	for _, item := range summary.Checks {
+		if item.Status == QualityFailed {
+			summary.Verdict = QualityFailed
+			break
+		}
+		if item.Status == QualityPending {
+			summary.Verdict = QualityPending
+		}
+	}
+	summary.Ready = summary.Verdict == QualityPassed
+	return summary
+}
+
+func latestKindReported(reports []*models.QualityReport, kind string) bool {
+	for _, report := range reports {
+		if report.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// GetQualitySummary return the quality verdict of publish, evaluated by the quality gate of the stage if configured,
+// otherwise by the system default thresholds
+func (pm *PipelineManager) GetQualitySummary(projectID, publishID, stageID int64) (*QualitySummary, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return nil, err
+	}
+	gate := defaultQualityGate()
+	if stageID > 0 {
+		publish, err := pm.modelPublish.GetPublishByID(publishID)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := pm.GetStagePolicy(publish.LastPipelineInstanceID, stageID)
+		if err != nil {
+			return nil, err
+		}
+		if policy.QualityGate != nil {
+			gate = policy.QualityGate
+		}
+	}
+	return pm.evaluatePublishQuality(publishID, gate)
+}
+
+func (pm *PipelineManager) evaluatePublishQuality(publishID int64, gate *QualityGate) (*QualitySummary, error) {
+	reports, err := pm.modelQuality.GetQualityReports(publishID)
+	if err != nil {
+		return nil, err
+	}
+	e2eReports, err := pm.modelE2ETest.GetE2ETestReports(publishID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateQuality(gate, reports, e2eReports), nil
+}
+
+// VerifyQualityGate the publish enters the stage with quality gate only when the quality verdict passed
+func (pm *PipelineManager) VerifyQualityGate(instanceID, publishID, stageID int64) error {
+	policy, err := pm.GetStagePolicy(instanceID, stageID)
+	if err != nil {
+		return err
+	}
+	if policy.QualityGate == nil {
+		return nil
+	}
+	summary, err := pm.evaluatePublishQuality(publishID, policy.QualityGate)
+	if err != nil {
+		return err
+	}
+	if !summary.Ready {
+		return fmt.Errorf("质量门禁未通过，不能流转到此阶段: %s", summary.failedChecks())
+	}
+	return nil
+}
+
+// QualityReportReq the quality result of app reported by the build job
+type QualityReportReq struct {
+	Total       int     `json:"total"`
+	Failed      int     `json:"failed"`
+	Skipped     int     `json:"skipped"`
+	Coverage    float64 `json:"coverage"`
+	SonarStatus string  `json:"sonar_status"`
+	Critical    int     `json:"critical"`
+	High        int     `json:"high"`
+	Medium      int     `json:"medium"`
+	Low         int     `json:"low"`
+	ReportURL   string  `json:"report_url"`
+}
+
+// ReportQuality store the quality result of app in the publish job
+func (pm *PipelineManager) ReportQuality(projectID, publishID, stageID, publishJobID int64, appName, kind string, req *QualityReportReq) error {
+	job, err := pm.modelPublishJob.GetPublishJobByID(publishJobID)
+	if err != nil || job.PublishID != publishID || job.EnvID != stageID {
+		return fmt.Errorf("发布任务 %v 不属于流水线 %v 的阶段 %v", publishJobID, publishID, stageID)
+	}
+	if appName == "" {
+		return fmt.Errorf("应用名称不能为空")
+	}
+	switch kind {
+	case models.QualityUnitTest:
+		if req.Total < 0 || req.Failed < 0 || req.Skipped < 0 || req.Failed+req.Skipped > req.Total {
+			return fmt.Errorf("单元测试数量无效")
+		}
+	case models.QualityCoverage:
+		if req.Coverage < 0 || req.Coverage > 100 {
+			return fmt.Errorf("覆盖率: %v 无效，须在 0-100 之间", req.Coverage)
+		}
+	case models.QualitySonar:
+		req.SonarStatus = strings.ToUpper(req.SonarStatus)
+		if req.SonarStatus != "OK" && req.SonarStatus != "WARN" && req.SonarStatus != "ERROR" {
+			return fmt.Errorf("Sonar 质量门禁状态: %v 无效，可选值为 OK/WARN/ERROR", req.SonarStatus)
+		}
+	case models.QualityVulnerability:
+		if req.Critical < 0 || req.High < 0 || req.Medium < 0 || req.Low < 0 {
+			return fmt.Errorf("漏洞数量无效")
+		}
+	default:
+		return fmt.Errorf("不支持的质量报告类型: %v", kind)
+	}
+	if req.ReportURL != "" {
+		if u, err := url.Parse(req.ReportURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("报告地址: %v 无效", req.ReportURL)
+		}
+	}
+	report := &models.QualityReport{
+		Addons:       models.NewAddons(),
+		ProjectID:    projectID,
+		PublishID:    publishID,
+		StageID:      stageID,
+		PublishJobID: publishJobID,
+		AppName:      appName,
+		Kind:         kind,
+		Total:        req.Total,
+		Failed:       req.Failed,
+		Skipped:      req.Skipped,
+		Coverage:     req.Coverage,
+		SonarStatus:  req.SonarStatus,
+		Critical:     req.Critical,
+		High:         req.High,
+		Medium:       req.Medium,
+		Low:          req.Low,
+		ReportURL:    req.ReportURL,
+	}
+	_, err = pm.modelQuality.CreateQualityReport(report)
+	return err
+}
+
+// qualityReportURL the address the build job reports the quality results to, the app and kind query are appended by the job
+func qualityReportURL(projectID, publishID, stageID, publishJobID int64, step string) string {
+	return fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/quality?publish_job_id=%d", atomciServer, projectID, publishID, stageID, step, publishJobID)
+}
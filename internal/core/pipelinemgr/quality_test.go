@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestEvaluateQuality(t *testing.T) {
+	// ordered by id desc, the older report of app is ignored
+	reports := []*models.QualityReport{
+		{AppName: "api", Kind: models.QualityUnitTest, Total: 100, Failed: 1},
+		{AppName: "web", Kind: models.QualityUnitTest, Total: 50, Skipped: 10},
+		{AppName: "api", Kind: models.QualityUnitTest, Total: 100, Failed: 50},
+		{AppName: "api", Kind: models.QualityCoverage, Coverage: 82.5},
+		{AppName: "web", Kind: models.QualityCoverage, Coverage: 61},
+		{AppName: "api", Kind: models.QualitySonar, SonarStatus: "OK"},
+		{AppName: "api", Kind: models.QualityVulnerability, High: 2, Medium: 5},
+	}
+	e2eReports := []*models.E2ETestReport{
+		{PublishJobID: 9, Total: 10, Failed: 1},
+		{PublishJobID: 8, Total: 10, Failed: 10},
+	}
+	tests := []struct {
+		name    string
+		gate    *QualityGate
+		verdict string
+	}{
+		{"no thresholds", &QualityGate{}, QualityPassed},
+		{"all passed", &QualityGate{MinUnitTestPassRate: 99, MinCoverage: 60, RequireSonarPass: true, BlockSeverity: "critical", MinE2EPassRate: 90}, QualityPassed},
+		{"unit test failed", &QualityGate{MinUnitTestPassRate: 100}, QualityFailed},
+		{"coverage failed", &QualityGate{MinCoverage: 80}, QualityFailed},
+		{"vulnerability failed", &QualityGate{BlockSeverity: "high"}, QualityFailed},
+		{"e2e failed", &QualityGate{MinE2EPassRate: 95}, QualityFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := evaluateQuality(tt.gate, reports, e2eReports)
+			if summary.Verdict != tt.verdict || summary.Ready != (tt.verdict == QualityPassed) {
+				t.Errorf("evaluateQuality() verdict = %v, want %v, checks: %s", summary.Verdict, tt.verdict, summary.failedChecks())
+			}
+			if summary.UnitTests.Total != 150 || summary.UnitTests.Failed != 1 || summary.E2ETests.Total != 10 {
+				t.Errorf("evaluateQuality() aggregated unit tests %+v, e2e tests %+v", summary.UnitTests, summary.E2ETests)
+			}
+		})
+	}
+
+	summary := evaluateQuality(&QualityGate{RequireSonarPass: true, MinCoverage: 60}, nil, nil)
+	if summary.Verdict != QualityPending || summary.Ready {
+		t.Errorf("evaluateQuality() without reports verdict = %v, want %v", summary.Verdict, QualityPending)
+	}
+}
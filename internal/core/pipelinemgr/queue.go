@@ -0,0 +1,328 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/events"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+)
+
+var jobTypeDescriptions = map[string]string{
+	models.JobTypeBuild:  "构建",
+	models.JobTypeDeploy: "部署",
+}
+
+// getRunningJobs build job is exclusive in the publish stage, deploy job is exclusive in the env
+func (pm *PipelineManager) getRunningJobs(projectID, publishID, stageID int64, jobType string) ([]*models.PublishJob, error) {
+	status := []string{models.StatusRunning, models.StatusInit}
+	if jobType == models.JobTypeBuild {
+		return pm.modelPublishJob.GetCurrentRunningBuildJob(projectID, stageID, publishID, status, jobType)
+	}
+	return pm.modelPublishJob.GetCurrentRunningJob(projectID, stageID, status, jobType)
+}
+
+// applyConcurrencyPolicy handle the new triggered job when the env already has running jobs,
+// proceed is true when the new job could be created right now
+func (pm *PipelineManager) applyConcurrencyPolicy(publish *models.Publish, stageID int64, jobType, creator, jobString string, params interface{}) (int64, bool, error) {
+	policy := models.ConcurrencyPolicyReject
+	if envModel, err := pm.modelProject.GetProjectEnvByID(stageID); err != nil {
+		log.Log.Warn("get project env: %v occur error: %s, use reject concurrency policy", stageID, err.Error())
+	} else if envModel.ConcurrencyPolicy != "" {
+		policy = envModel.ConcurrencyPolicy
+	}
+
+	switch policy {
+	case models.ConcurrencyPolicyQueue:
+		if err := pm.enqueueJob(publish, stageID, jobType, creator, fmt.Sprintf("waiting for running jobs: %s", jobString), params); err != nil {
+			return models.Skipped, false, err
+		}
+		return models.Pending, false, nil
+	case models.ConcurrencyPolicyCancelPrevious:
+		if err := pm.cancelRunningJobs(publish, stageID, jobType, creator); err != nil {
+			return models.Skipped, false, fmt.Errorf("取消此阶段%v中的任务失败: %s", jobTypeDescriptions[jobType], err.Error())
+		}
+		return models.Running, true, nil
+	default:
+		return models.Skipped, false, fmt.Errorf("此阶段的流水线存在%v中的任务, 任务ID: %s", jobTypeDescriptions[jobType], jobString)
+	}
+}
+
+func (pm *PipelineManager) enqueueJob(publish *models.Publish, stageID int64, jobType, creator, message string, params interface{}) error {
+	waitingItems, err := pm.modelPublishJob.GetJobQueueItems(publish.ProjectID, stageID, []string{models.QueueStatusWaiting})
+	if err != nil {
+		return err
+	}
+	for _, item := range waitingItems {
+		if item.PublishID == publish.ID && item.StepIndex == publish.StepIndex {
+			return fmt.Errorf("此任务已在排队中, 排队ID: %v", item.ID)
+		}
+	}
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	message = utils.Truncate(message, 256)
+	item := &models.PublishJobQueue{
+		Addons:             models.NewAddons(),
+		ProjectID:          publish.ProjectID,
+		PublishID:          publish.ID,
+		PipelineInstanceID: publish.LastPipelineInstanceID,
+		EnvID:              stageID,
+		StepIndex:          publish.StepIndex,
+		JobType:            jobType,
+		Params:             string(paramsBytes),
+		Creator:            creator,
+		Status:             models.QueueStatusWaiting,
+		Message:            message,
+	}
+	id, err := pm.modelPublishJob.CreateJobQueueItem(item)
+	if err != nil {
+		return err
+	}
+	log.Log.Info("publish: %v %v job was queued, queue id: %v", publish.ID, jobType, id)
+	return nil
+}
+
+// cancelRunningJobs abort the running jobs of the env, so the new triggered job could run
+func (pm *PipelineManager) cancelRunningJobs(publish *models.Publish, stageID int64, jobType, creator string) error {
+	runningJobs, err := pm.getRunningJobs(publish.ProjectID, publish.ID, stageID, jobType)
+	if err != nil {
+		return err
+	}
+	for _, job := range runningJobs {
+		if job.JobType == models.JobTypeBuild {
+			if err := pm.abortBuildJob(job); err != nil {
+				// the jenkins build maybe already finished, abort the publish job all the same
+				log.Log.Warn("abort build job: %v run id: %v occur error: %s", job.ID, job.RunID, err.Error())
+			}
+		}
+		if err := pm.updatePublishJob(job, models.StatusAbort); err != nil {
+			return err
+		}
+		log.Log.Info("publish job: %v was canceled by the new triggered job of publish: %v", job.ID, publish.ID)
+		if job.PublishID != publish.ID {
+			if err := pm.terminateCanceledPublish(job, creator); err != nil {
+				log.Log.Error("terminate publish: %v of canceled job: %v occur error: %s", job.PublishID, job.ID, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (pm *PipelineManager) abortBuildJob(job *models.PublishJob) error {
+	CIInfo, err := pm.GetJobCIConfig(job)
+	if err != nil {
+		return err
+	}
+	if err := verifyDriverAbort(CIInfo.Driver); err != nil {
+		return err
+	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
+	jobName := BuildJobName(job.ProjectID, job.PublishID, job.EnvID)
+	workerflowClient, err := NewWorkFlowProvide(CIInfo.Driver, addr, user, token, jobName, nil)
+	if err != nil {
+		return err
+	}
+	return workerflowClient.Abort(job.RunID)
+}
+
+// terminateCanceledPublish the canceled job never callback, terminate the publish which is waiting for it
+func (pm *PipelineManager) terminateCanceledPublish(job *models.PublishJob, creator string) error {
+	publishItem, err := pm.modelPublish.GetPublishByID(job.PublishID)
+	if err != nil {
+		return err
+	}
+	if publishItem.Status != models.Running || publishItem.StageID != job.EnvID || publishItem.StepIndex != job.StepIndex {
+		return nil
+	}
+	publishItem.Status = models.TerminateSuccess
+	if err := pm.modelPublish.UpdatePublish(publishItem); err != nil {
+		return err
+	}
+	operationLog := &models.PublishOperationLog{
+		Creator:            creator,
+		Type:               "取消",
+		Stage:              publishItem.StageName,
+		StageID:            publishItem.StageID,
+		Step:               publishItem.Step,
+		StepIndex:          publishItem.StepIndex,
+		Status:             models.TerminateSuccess,
+		PublishID:          publishItem.ID,
+		PipelineInstanceID: publishItem.LastPipelineInstanceID,
+		RunID:              job.RunID,
+		Message:            "被新触发的任务取消",
+	}
+	if err := pm.modelPublish.CreatePublishOperation(operationLog); err != nil {
+		return err
+	}
+	go events.EmitPublishTransition(operationLog)
+	return nil
+}
+
+// GetJobQueue return the waiting jobs of env in schedule order
+func (pm *PipelineManager) GetJobQueue(projectID, stageID int64) ([]*JobQueueItemRsp, error) {
+	items, err := pm.modelPublishJob.GetJobQueueItems(projectID, stageID, []string{models.QueueStatusWaiting})
+	if err != nil {
+		return nil, err
+	}
+	rsp := []*JobQueueItemRsp{}
+	for i, item := range pm.ScheduleJobQueue(items) {
+		itemRsp := &JobQueueItemRsp{
+			PublishJobQueue: item,
+			Position:        i + 1,
+		}
+		if publishItem, err := pm.modelPublish.GetPublishByID(item.PublishID); err == nil {
+			itemRsp.PublishName = publishItem.Name
+			itemRsp.VersionNo = publishItem.VersionNo
+		}
+		if _, stepName, err := pm.GetNextStepType(item.PublishID, item.StepIndex); err == nil {
+			itemRsp.StepName = stepName
+		}
+		rsp = append(rsp, itemRsp)
+	}
+	return rsp, nil
+}
+
+// CancelJobQueueItem remove the waiting job from queue
+func (pm *PipelineManager) CancelJobQueueItem(projectID, queueID int64, user string) error {
+	item, err := pm.modelPublishJob.GetJobQueueItemByID(queueID)
+	if err != nil {
+		return err
+	}
+	if item.ProjectID != projectID {
+		return fmt.Errorf("排队任务: %v 不属于此项目，操作拒绝", queueID)
+	}
+	if item.Status != models.QueueStatusWaiting {
+		return fmt.Errorf("排队任务: %v 当前状态为 %v，操作拒绝", queueID, item.Status)
+	}
+	return pm.finishJobQueueItem(item, models.QueueStatusCanceled, fmt.Sprintf("canceled by %v", user))
+}
+
+// deployWindowAvailable the queued deploy job waits until the env deploy window open,
+// the job forced by admin is not restricted
+func (pm *PipelineManager) deployWindowAvailable(item *models.PublishJobQueue) (bool, string, error) {
+	params := &DeployStepReq{}
+	if err := json.Unmarshal([]byte(item.Params), params); err == nil && params.OverrideWindow {
+		return true, "", nil
+	}
+	envModel, err := pm.modelProject.GetProjectEnvByID(item.EnvID)
+	if err != nil {
+		return false, "", err
+	}
+	return pm.deployWindowOpen(envModel, time.Now())
+}
+
+func (pm *PipelineManager) finishJobQueueItem(item *models.PublishJobQueue, status, message string) error {
+	// message column size is 256
+	message = utils.Truncate(message, 256)
+	item.Status = status
+	item.Message = message
+	return pm.modelPublishJob.UpdateJobQueueItem(item)
+}
+
+// DispatchJobQueueItem run the queued job when the env is idle, the result is nil when the job still need waiting or was canceled
+func (pm *PipelineManager) DispatchJobQueueItem(item *models.PublishJobQueue) (*JobQueueDispatchResult, error) {
+	publishItem, err := pm.modelPublish.GetPublishByID(item.PublishID)
+	if err != nil {
+		return nil, pm.finishJobQueueItem(item, models.QueueStatusCanceled, "publish not exist")
+	}
+	if publishItem.LastPipelineInstanceID != item.PipelineInstanceID || publishItem.StageID != item.EnvID ||
+		utils.IntContains([]int64{models.END, models.Closed}, publishItem.Status) {
+		return nil, pm.finishJobQueueItem(item, models.QueueStatusCanceled, "publish stage was changed")
+	}
+	if publishItem.StepIndex == item.StepIndex && publishItem.Status != models.Pending {
+		return nil, pm.finishJobQueueItem(item, models.QueueStatusCanceled, "step was triggered again")
+	}
+
+	runningJobs, err := pm.getRunningJobs(item.ProjectID, item.PublishID, item.EnvID, item.JobType)
+	if err != nil {
+		return nil, err
+	}
+	if len(runningJobs) > 0 {
+		return nil, nil
+	}
+	if item.JobType == models.JobTypeBuild {
+		available, reason, err := pm.buildSlotAvailable(item.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			if item.Message != reason {
+				item.Message = reason
+				return nil, pm.modelPublishJob.UpdateJobQueueItem(item)
+			}
+			return nil, nil
+		}
+	}
+	if item.JobType == models.JobTypeDeploy {
+		available, reason, err := pm.deployWindowAvailable(item)
+		if err != nil {
+			return nil, err
+		}
+		// message column size is 256
+		reason = utils.Truncate(reason, 256)
+		if !available {
+			if item.Message != reason {
+				item.Message = reason
+				return nil, pm.modelPublishJob.UpdateJobQueueItem(item)
+			}
+			return nil, nil
+		}
+	}
+
+	if err := pm.SwitchPublishStep(item.PublishID, item.EnvID, item.StepIndex); err != nil {
+		return nil, pm.finishJobQueueItem(item, models.QueueStatusFailed, err.Error())
+	}
+	if err := pm.finishJobQueueItem(item, models.QueueStatusDispatched, ""); err != nil {
+		return nil, err
+	}
+	log.Log.Info("dispatch queued %v job: %v of publish: %v", item.JobType, item.ID, item.PublishID)
+
+	result := &JobQueueDispatchResult{
+		PublishID: item.PublishID,
+		StageID:   item.EnvID,
+		Creator:   item.Creator,
+	}
+	// the env permission of creator was verified when the job was queued
+	switch item.JobType {
+	case models.JobTypeBuild:
+		params := &BuildStepReq{Automated: true, Dispatched: true}
+		if err = json.Unmarshal([]byte(item.Params), params); err == nil {
+			result.Status, result.RunID, result.JobName, err = pm.RunBuildStep(item.ProjectID, item.PublishID, item.EnvID, item.Creator, item.JobType, params)
+		}
+	case models.JobTypeDeploy:
+		params := &DeployStepReq{Automated: true}
+		if err = json.Unmarshal([]byte(item.Params), params); err == nil {
+			result.Status, result.RunID, result.JobName, err = pm.RunDeployStep(item.ProjectID, item.PublishID, item.EnvID, item.Creator, item.JobType, params)
+		}
+	default:
+		err = fmt.Errorf("不支持此任务类型: %v 的排队", item.JobType)
+		result.Status = models.Skipped
+	}
+	if err != nil {
+		if finishErr := pm.finishJobQueueItem(item, models.QueueStatusFailed, err.Error()); finishErr != nil {
+			log.Log.Error("update job queue item: %v occur error: %s", item.ID, finishErr.Error())
+		}
+	}
+	return result, err
+}
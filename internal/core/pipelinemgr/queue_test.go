@@ -0,0 +1,246 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// newQueueEnv create the env of concurrency policy, and the publish at the step 1 of it
+func newQueueEnv(t *testing.T, policy string) (*models.ProjectEnv, *models.Publish) {
+	env := &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 100, Name: "queue", ConcurrencyPolicy: policy}
+	env.ID = insertTestItem(t, env)
+	return env, newQueuePublish(t, env, models.Pending)
+}
+
+func newQueuePublish(t *testing.T, env *models.ProjectEnv, status int64) *models.Publish {
+	publishItem := &models.Publish{Addons: models.NewAddons(), ProjectID: env.ProjectID, StageID: env.ID, StepIndex: 1, Status: status, LastPipelineInstanceID: 1}
+	publishItem.ID = insertTestItem(t, publishItem)
+	return publishItem
+}
+
+func newRunningJob(t *testing.T, publishItem *models.Publish, jobType string) *models.PublishJob {
+	job := &models.PublishJob{Addons: models.NewAddons(), ProjectID: publishItem.ProjectID, PublishID: publishItem.ID, EnvID: publishItem.StageID, StepIndex: publishItem.StepIndex, JobType: jobType, Status: models.StatusRunning}
+	job.ID = insertTestItem(t, job)
+	return job
+}
+
+func newQueueItem(t *testing.T, publishItem *models.Publish, stepIndex int, jobType string) *models.PublishJobQueue {
+	item := &models.PublishJobQueue{
+		Addons:             models.NewAddons(),
+		ProjectID:          publishItem.ProjectID,
+		PublishID:          publishItem.ID,
+		PipelineInstanceID: publishItem.LastPipelineInstanceID,
+		EnvID:              publishItem.StageID,
+		StepIndex:          stepIndex,
+		JobType:            jobType,
+		Params:             "{}",
+		Status:             models.QueueStatusWaiting,
+	}
+	item.ID = insertTestItem(t, item)
+	return item
+}
+
+func readTestItem(t *testing.T, item interface{}) {
+	t.Helper()
+	if err := orm.NewOrm().Read(item); err != nil {
+		t.Fatalf("read %T error: %v", item, err)
+	}
+}
+
+func TestApplyConcurrencyPolicy(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+
+	t.Run("reject", func(t *testing.T) {
+		env, publishItem := newQueueEnv(t, "")
+		status, proceed, err := pm.applyConcurrencyPolicy(publishItem, env.ID, models.JobTypeDeploy, "admin", "1", nil)
+		if status != models.Skipped || proceed || err == nil || !strings.Contains(err.Error(), "部署中的任务") {
+			t.Fatalf("applyConcurrencyPolicy() = %v, %v, %v", status, proceed, err)
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		env, publishItem := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		status, proceed, err := pm.applyConcurrencyPolicy(publishItem, env.ID, models.JobTypeDeploy, "admin", "1", &DeployStepReq{ActionName: "trigger"})
+		if status != models.Pending || proceed || err != nil {
+			t.Fatalf("applyConcurrencyPolicy() = %v, %v, %v", status, proceed, err)
+		}
+		items, err := pm.modelPublishJob.GetJobQueueItems(env.ProjectID, env.ID, []string{models.QueueStatusWaiting})
+		if err != nil || len(items) != 1 || items[0].PublishID != publishItem.ID || items[0].StepIndex != 1 || items[0].Creator != "admin" {
+			t.Fatalf("the job should be queued, items: %v, error: %v", items, err)
+		}
+		// the step is queued only once
+		if _, _, err := pm.applyConcurrencyPolicy(publishItem, env.ID, models.JobTypeDeploy, "admin", "1", nil); err == nil {
+			t.Fatalf("the queued step should not be queued again")
+		}
+	})
+
+	t.Run("cancel previous", func(t *testing.T) {
+		env, publishItem := newQueueEnv(t, models.ConcurrencyPolicyCancelPrevious)
+		previous := newQueuePublish(t, env, models.Running)
+		job := newRunningJob(t, previous, models.JobTypeDeploy)
+
+		status, proceed, err := pm.applyConcurrencyPolicy(publishItem, env.ID, models.JobTypeDeploy, "admin", "1", nil)
+		if status != models.Running || !proceed || err != nil {
+			t.Fatalf("applyConcurrencyPolicy() = %v, %v, %v", status, proceed, err)
+		}
+		readTestItem(t, job)
+		if job.Status != models.StatusAbort {
+			t.Errorf("the previous job should be aborted, status: %v", job.Status)
+		}
+		// the publish waiting for the canceled job is terminated
+		readTestItem(t, previous)
+		if previous.Status != models.TerminateSuccess {
+			t.Errorf("the publish of canceled job should be terminated, status: %v", previous.Status)
+		}
+		operationLog := models.PublishOperationLog{}
+		if err := orm.NewOrm().QueryTable(&operationLog).Filter("publish_id", previous.ID).One(&operationLog); err != nil || operationLog.Type != "取消" || operationLog.Creator != "admin" {
+			t.Errorf("the cancel operation should be logged, log: %+v, error: %v", operationLog, err)
+		}
+		readTestItem(t, publishItem)
+		if publishItem.Status != models.Pending {
+			t.Errorf("the publish of new job should not be changed, status: %v", publishItem.Status)
+		}
+	})
+}
+
+func TestCancelRunningJobs(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	env, publishItem := newQueueEnv(t, models.ConcurrencyPolicyCancelPrevious)
+	// the build job is exclusive in the publish stage, the build job of other publish keeps running
+	build := newRunningJob(t, publishItem, models.JobTypeBuild)
+	other := newQueuePublish(t, env, models.Running)
+	otherBuild := newRunningJob(t, other, models.JobTypeBuild)
+	// the previous publish moved to the next step, it is not terminated by the job canceled
+	moved := newQueuePublish(t, env, models.Running)
+	deploy := newRunningJob(t, moved, models.JobTypeDeploy)
+	moved.StepIndex = 2
+	if _, err := orm.NewOrm().Update(moved, "step_index"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ci server of build job does not exist, the publish job is aborted all the same
+	if err := pm.cancelRunningJobs(publishItem, env.ID, models.JobTypeBuild, "admin"); err != nil {
+		t.Fatalf("cancel build jobs error: %v", err)
+	}
+	if err := pm.cancelRunningJobs(publishItem, env.ID, models.JobTypeDeploy, "admin"); err != nil {
+		t.Fatalf("cancel deploy jobs error: %v", err)
+	}
+	for _, tt := range []struct {
+		job  *models.PublishJob
+		want string
+	}{
+		{build, models.StatusAbort},
+		{otherBuild, models.StatusRunning},
+		{deploy, models.StatusAbort},
+	} {
+		readTestItem(t, tt.job)
+		if tt.job.Status != tt.want {
+			t.Errorf("job: %v of publish: %v status = %v, want %v", tt.job.ID, tt.job.PublishID, tt.job.Status, tt.want)
+		}
+	}
+	readTestItem(t, moved)
+	if moved.Status != models.Running {
+		t.Errorf("the publish moved to the next step should not be terminated, status: %v", moved.Status)
+	}
+}
+
+func TestDispatchJobQueueItem(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+
+	dispatch := func(t *testing.T, item *models.PublishJobQueue) *JobQueueDispatchResult {
+		t.Helper()
+		result, err := pm.DispatchJobQueueItem(item)
+		if err != nil && result == nil {
+			t.Fatalf("DispatchJobQueueItem() error: %v", err)
+		}
+		readTestItem(t, item)
+		return result
+	}
+
+	t.Run("publish not exist", func(t *testing.T) {
+		_, publishItem := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		item := newQueueItem(t, publishItem, 1, models.JobTypeDeploy)
+		publishItem.Deleted = true
+		if _, err := orm.NewOrm().Update(publishItem, "deleted"); err != nil {
+			t.Fatal(err)
+		}
+		if result := dispatch(t, item); result != nil || item.Status != models.QueueStatusCanceled || item.Message != "publish not exist" {
+			t.Fatalf("the item should be canceled, result: %v, item: %+v", result, item)
+		}
+	})
+
+	t.Run("stage changed", func(t *testing.T) {
+		_, publishItem := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		item := newQueueItem(t, publishItem, 1, models.JobTypeDeploy)
+		publishItem.StageID++
+		if _, err := orm.NewOrm().Update(publishItem, "stage_id"); err != nil {
+			t.Fatal(err)
+		}
+		if result := dispatch(t, item); result != nil || item.Status != models.QueueStatusCanceled || item.Message != "publish stage was changed" {
+			t.Fatalf("the item should be canceled, result: %v, item: %+v", result, item)
+		}
+	})
+
+	t.Run("publish closed", func(t *testing.T) {
+		env, _ := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		item := newQueueItem(t, newQueuePublish(t, env, models.Closed), 1, models.JobTypeDeploy)
+		if result := dispatch(t, item); result != nil || item.Status != models.QueueStatusCanceled || item.Message != "publish stage was changed" {
+			t.Fatalf("the item should be canceled, result: %v, item: %+v", result, item)
+		}
+	})
+
+	t.Run("step triggered again", func(t *testing.T) {
+		env, _ := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		item := newQueueItem(t, newQueuePublish(t, env, models.Running), 1, models.JobTypeDeploy)
+		if result := dispatch(t, item); result != nil || item.Status != models.QueueStatusCanceled || item.Message != "step was triggered again" {
+			t.Fatalf("the item should be canceled, result: %v, item: %+v", result, item)
+		}
+	})
+
+	t.Run("waiting for running jobs", func(t *testing.T) {
+		env, publishItem := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		newRunningJob(t, newQueuePublish(t, env, models.Running), models.JobTypeDeploy)
+		item := newQueueItem(t, publishItem, 1, models.JobTypeDeploy)
+		if result := dispatch(t, item); result != nil || item.Status != models.QueueStatusWaiting {
+			t.Fatalf("the item should keep waiting, result: %v, item: %+v", result, item)
+		}
+	})
+
+	t.Run("dispatch", func(t *testing.T) {
+		_, publishItem := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+		item := newQueueItem(t, publishItem, 1, models.StepE2ETest)
+		item.Creator = "admin"
+		result, err := pm.DispatchJobQueueItem(item)
+		if result == nil || result.PublishID != publishItem.ID || result.Creator != "admin" || result.Status != models.Skipped {
+			t.Fatalf("DispatchJobQueueItem() = %+v, %v", result, err)
+		}
+		// the job type could not be queued, the item dispatched is failed
+		readTestItem(t, item)
+		if err == nil || item.Status != models.QueueStatusFailed || !strings.Contains(item.Message, "不支持此任务类型") {
+			t.Fatalf("the item should fail, error: %v, item: %+v", err, item)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// CreateReleaseBranch create the release branch from the head of branch in app repo, reuse it when already exist
+func (pm *PipelineManager) CreateReleaseBranch(appID int64, branchName, releaseBranch string) error {
+	client, scmApp, err := pm.getAppScmClient(appID)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if _, _, err := client.Git.FindBranch(ctx, scmApp.FullName, releaseBranch); err == nil {
+		log.Log.Info("app: %v release branch: %v already exist, reuse it", scmApp.Name, releaseBranch)
+		return nil
+	}
+	branch, _, err := client.Git.FindBranch(ctx, scmApp.FullName, branchName)
+	if err != nil {
+		return fmt.Errorf("get app: %v branch: %v occur error: %s", scmApp.Name, branchName, err.Error())
+	}
+	if err := apps.CreateBranch(ctx, client, scmApp.FullName, releaseBranch, branchName, branch.Sha); err != nil {
+		return fmt.Errorf("create app: %v release branch: %v occur error: %s", scmApp.Name, releaseBranch, err.Error())
+	}
+	return nil
+}
+
+// applyReleaseBranches replace the request build branch with the release branch of publish app
+func (pm *PipelineManager) applyReleaseBranches(publishID int64, apps []*RunBuildAppReq) {
+	for _, app := range apps {
+		publishApp, err := pm.modelPublish.GetPublishAppByPublishIDAndAppID(publishID, app.ProjectAppID)
+		if err != nil || publishApp.ReleaseBranch == "" {
+			continue
+		}
+		if app.Branch != publishApp.ReleaseBranch {
+			log.Log.Info("publish: %v app: %v build release branch: %v instead of %v", publishID, app.ProjectAppID, publishApp.ReleaseBranch, app.Branch)
+			app.Branch = publishApp.ReleaseBranch
+		}
+	}
+}
+
+// PublishAppBuildBranch return the release branch when it was created, otherwise the dev branch
+func PublishAppBuildBranch(app *models.PublishApp) string {
+	if app.ReleaseBranch != "" {
+		return app.ReleaseBranch
+	}
+	return app.BranchName
+}
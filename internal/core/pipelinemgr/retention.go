@@ -0,0 +1,230 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/registry"
+
+	"github.com/astaxie/beego"
+)
+
+var (
+	// retentionKeep the number of latest atomci built tags kept in each image repository
+	retentionKeep = beego.AppConfig.DefaultInt("retention::keep", 10)
+)
+
+// atomciTagRegexp the system default image tag built by atomci: branch-commit
+var atomciTagRegexp = regexp.MustCompile(`^.+-[0-9a-f]{7}$`)
+
+// ImageRetentionPlan the atomci built tags to keep and to delete of one image repository
+type ImageRetentionPlan struct {
+	Registry int64    `json:"registry"`
+	Image    string   `json:"image"`
+	Keep     []string `json:"keep"`
+	Delete   []string `json:"delete"`
+	Error    string   `json:"error,omitempty"`
+
+	repo     string
+	provider registry.Provider
+	// deployedTags/deployedDigests the images currently deployed in envs
+	deployedTags    map[string]bool
+	deployedDigests map[string]bool
+	// deleteDigests the manifest digest of tags to delete
+	deleteDigests map[string]string
+}
+
+// PlanImageRetention return the image tags will be cleaned up of project, all projects when project id is 0,
+// the latest tags of each repository and the tags deployed in envs are kept.
+func (pm *PipelineManager) PlanImageRetention(projectID int64) ([]*ImageRetentionPlan, error) {
+	projects := []*models.Project{}
+	if projectID > 0 {
+		project, err := pm.modelProject.GetProjectByID(projectID)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	} else {
+		var err error
+		if projects, err = pm.modelProject.GetProjects(); err != nil {
+			return nil, err
+		}
+	}
+
+	plans := []*ImageRetentionPlan{}
+	planMap := map[string]*ImageRetentionPlan{}
+	providers := map[int64]registry.Provider{}
+	for _, project := range projects {
+		envs, err := pm.modelProject.GetProjectEnvs(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		apps, err := pm.modelProject.GetProjectApps(project.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, env := range envs {
+			if env.Registry == 0 {
+				continue
+			}
+			for _, app := range apps {
+				image, err := pm.retentionAppImage(app.ID, env.ID)
+				if err != nil || image == "" {
+					continue
+				}
+				_, repo, _ := splitImage(image)
+				key := fmt.Sprintf("%d/%s", env.Registry, repo)
+				plan, ok := planMap[key]
+				if !ok {
+					provider, ok := providers[env.Registry]
+					if !ok {
+						if provider, err = pm.getEnvRegistryProvider(env.ID); err != nil {
+							log.Log.Warn("when plan image retention, get env: %v registry occur error: %s", env.ID, err.Error())
+							continue
+						}
+						providers[env.Registry] = provider
+					}
+					plan = &ImageRetentionPlan{
+						Registry:        env.Registry,
+						Image:           image,
+						repo:            repo,
+						provider:        provider,
+						deployedTags:    map[string]bool{},
+						deployedDigests: map[string]bool{},
+						deleteDigests:   map[string]string{},
+					}
+					planMap[key] = plan
+					plans = append(plans, plan)
+				}
+				if jobApp, err := pm.modelPublishJob.GetLastSuccessDeployJobApp(app.ID, env.ID, 0); err == nil {
+					plan.addDeployed(jobApp.ImageAddr)
+				}
+			}
+		}
+	}
+
+	for _, plan := range plans {
+		if err := plan.resolve(retentionKeep); err != nil {
+			plan.Error = err.Error()
+		}
+	}
+	return plans, nil
+}
+
+// ApplyImageRetention delete the tags of plans, return the count of deleted tags
+func (pm *PipelineManager) ApplyImageRetention(plans []*ImageRetentionPlan) int {
+	deleted := 0
+	for _, plan := range plans {
+		if plan.Error != "" {
+			continue
+		}
+		// the tags share one manifest are deleted together
+		digests := map[string]bool{}
+		for _, tag := range plan.Delete {
+			digest := plan.deleteDigests[tag]
+			if digests[digest] {
+				deleted++
+				continue
+			}
+			if err := plan.provider.DeleteManifest(plan.repo, digest); err != nil {
+				log.Log.Warn("delete image %v:%v occur error: %s", plan.Image, tag, err.Error())
+				continue
+			}
+			digests[digest] = true
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// retentionAppImage return the image name without tag of app in env, only the images tagged by atomci are cleaned up
+func (pm *PipelineManager) retentionAppImage(appID, envID int64) (string, error) {
+	arrange, err := pm.appHandler.GetRealArrange(appID, envID)
+	if err != nil {
+		return "", err
+	}
+	imageMapping, err := pm.modelAppArrange.GetAppImageMappingByArrangeIDAndProjectAppID(arrange.ID, appID)
+	if err != nil {
+		return "", err
+	}
+	if imageMapping.ImageTagType != models.SystemDefaultTag {
+		return "", nil
+	}
+	return removeImageUrlTag(imageMapping.Image)
+}
+
+func (plan *ImageRetentionPlan) addDeployed(image string) {
+	if index := strings.Index(image, "@"); index != -1 {
+		plan.deployedDigests[image[index+1:]] = true
+		return
+	}
+	if _, _, tag := splitImage(image); tag != "" {
+		plan.deployedTags[tag] = true
+	}
+}
+
+// resolve split the atomci built tags into keep and delete, the latest `keep` tags and the deployed ones are kept
+func (plan *ImageRetentionPlan) resolve(keep int) error {
+	tags, err := plan.provider.ListTags(plan.repo)
+	if err != nil {
+		return err
+	}
+	infos := []*registry.TagInfo{}
+	for _, tag := range tags {
+		if !atomciTagRegexp.MatchString(tag) {
+			continue
+		}
+		info, err := plan.provider.TagInfo(plan.repo, tag)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Created.After(infos[j].Created)
+	})
+
+	keepDigests := map[string]bool{}
+	for digest := range plan.deployedDigests {
+		keepDigests[digest] = true
+	}
+	candidates := []*registry.TagInfo{}
+	for index, info := range infos {
+		if index < keep || plan.deployedTags[info.Tag] || keepDigests[info.Digest] {
+			plan.Keep = append(plan.Keep, info.Tag)
+			keepDigests[info.Digest] = true
+			continue
+		}
+		candidates = append(candidates, info)
+	}
+	for _, info := range candidates {
+		// deleting the manifest removes all its tags, skip the one shared with a kept tag
+		if keepDigests[info.Digest] {
+			plan.Keep = append(plan.Keep, info.Tag)
+			continue
+		}
+		plan.Delete = append(plan.Delete, info.Tag)
+		plan.deleteDigests[info.Tag] = info.Digest
+	}
+	return nil
+}
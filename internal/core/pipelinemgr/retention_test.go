@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/registry"
+)
+
+type fakeRegistryProvider struct {
+	registry.Provider
+	infos []*registry.TagInfo
+}
+
+func (f *fakeRegistryProvider) ListTags(repo string) ([]string, error) {
+	tags := []string{"latest"}
+	for _, info := range f.infos {
+		tags = append(tags, info.Tag)
+	}
+	return tags, nil
+}
+
+func (f *fakeRegistryProvider) TagInfo(repo, tag string) (*registry.TagInfo, error) {
+	for _, info := range f.infos {
+		if info.Tag == tag {
+			return info, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestImageRetentionResolve(t *testing.T) {
+	now := time.Now()
+	provider := &fakeRegistryProvider{infos: []*registry.TagInfo{
+		{Tag: "master-0000001", Digest: "d1", Created: now.Add(-5 * time.Hour)},
+		{Tag: "master-0000002", Digest: "d2", Created: now.Add(-4 * time.Hour)},
+		{Tag: "master-0000003", Digest: "d3", Created: now.Add(-3 * time.Hour)},
+		{Tag: "dev-0000003", Digest: "d3", Created: now.Add(-3 * time.Hour)},
+		{Tag: "master-0000004", Digest: "d4", Created: now.Add(-2 * time.Hour)},
+		{Tag: "master-0000005", Digest: "d5", Created: now.Add(-1 * time.Hour)},
+	}}
+	plan := &ImageRetentionPlan{
+		provider:        provider,
+		deployedTags:    map[string]bool{},
+		deployedDigests: map[string]bool{"d3": true},
+		deleteDigests:   map[string]string{},
+	}
+	plan.addDeployed("harbor.example.com/atomci/app:master-0000001")
+	if err := plan.resolve(2); err != nil {
+		t.Fatal(err)
+	}
+	wantKeep := []string{"master-0000005", "master-0000004", "master-0000003", "dev-0000003", "master-0000001"}
+	if !reflect.DeepEqual(plan.Keep, wantKeep) {
+		t.Errorf("got keep %v, want %v", plan.Keep, wantKeep)
+	}
+	if want := []string{"master-0000002"}; !reflect.DeepEqual(plan.Delete, want) {
+		t.Errorf("got delete %v, want %v", plan.Delete, want)
+	}
+}
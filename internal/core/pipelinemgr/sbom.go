@@ -0,0 +1,293 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	sbomImage = beego.AppConfig.DefaultString("sbom::image", "anchore/syft:debug")
+)
+
+const sbomContainerName = "sbom"
+
+// validateSBOMTask the sbom is generated from the images built by the previous build-image sub task of build step
+func validateSBOMTask(stepType string, tasks []*subTask, position int) error {
+	if stepType != models.StepBuild {
+		return fmt.Errorf("%v 子任务只能用于构建任务节点", constant.StepSubTaskSBOM)
+	}
+	for _, task := range tasks[:position] {
+		if task.Type == constant.StepSubTaskBuildImage {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v 子任务须在 %v 子任务之后执行", constant.StepSubTaskSBOM, constant.StepSubTaskBuildImage)
+}
+
+// sbomContainer the container of syft, which pulls the image from registry to generate the sbom
+func sbomContainer() jenkins.ContainerEnv {
+	return jenkins.ContainerEnv{
+		Name:       sbomContainerName,
+		Image:      sbomImage,
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	}
+}
+
+func sbomReportURL(projectID, publishID, stageID, publishJobID, projectAppID int64, image string) string {
+	return fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/build/sbom?publish_job_id=%d&project_app_id=%d&image=%s",
+		atomciServer, projectID, publishID, stageID, publishJobID, projectAppID, url.QueryEscape(image))
+}
+
+// sbomCommands generate the cyclonedx sbom of image and report it, the failure of sbom does not break the build
+func sbomCommands(dir, appName, image, reportURL string, insecure bool) []string {
+	file := fmt.Sprintf("%s/%s.json", dir, appName)
+	env := ""
+	if insecure {
+		env = "SYFT_REGISTRY_INSECURE_USE_HTTP=true SYFT_REGISTRY_INSECURE_SKIP_TLS_VERIFY=true "
+	}
+	return []string{
+		fmt.Sprintf("container('%s') {", sbomContainerName),
+		fmt.Sprintf(`sh script: '%s/syft registry:%s -q -o %s > %s', returnStatus: true`, env, image, models.SBOMFormatCycloneDX, file),
+		"}",
+		fmt.Sprintf(`script { if (fileExists('%s')) { httpRequest acceptType: 'APPLICATION_JSON', contentType: 'APPLICATION_JSON', customHeaders: [[maskValue: true, name: 'Authorization', value: "Bearer ${env.ACCESS_TOKEN}"]], httpMode: 'POST', requestBody: readFile('%s'), responseHandle: 'NONE', timeout: 60, url: '%s' } }`,
+			file, file, reportURL),
+	}
+}
+
+// renderSBOMStageForBuild generate the sbom of the images built by the job
+func (pm *PipelineManager) renderSBOMStageForBuild(projectID, publishID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, deployTarget *DeployTarget) (string, error) {
+	imageURLs, err := pm.buildImageAddrs(stageID, allParms)
+	if err != nil {
+		return "", err
+	}
+	dir := fmt.Sprintf("%s/sbom/%d", ciConfig.Workspace, publishJobID)
+	commands := []string{
+		fmt.Sprintf("container('%s') {", sbomContainerName),
+		fmt.Sprintf(`sh 'rm -rf %s && mkdir -p %s && mkdir -p $DOCKER_CONFIG'`, dir, dir),
+		`sh """
+        echo '{"auths": {"'$REGISTRY_ADDR'": {"auth": "'$DOCKER_AUTH'"}}}' > $DOCKER_CONFIG/config.json
+        """`,
+		"}",
+	}
+	for i, app := range allParms {
+		if imageURLs[i] == "" {
+			continue
+		}
+		reportURL := sbomReportURL(projectID, publishID, stageID, publishJobID, app.ProjectAppID, imageURLs[i])
+		commands = append(commands, sbomCommands(dir, sanitizeName(app.Name), imageURLs[i], reportURL, !deployTarget.RegistryHTTPS)...)
+	}
+	item := jenkins.StepItem{
+		Name:    "'SBOM'",
+		Command: strings.Join(commands, "\n"),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
+
+// cycloneDXComponent the component of cyclonedx bom, the components may be nested
+type cycloneDXComponent struct {
+	Type       string               `json:"type"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version"`
+	Purl       string               `json:"purl"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+// parseCycloneDX return the packages of the cyclonedx json bom, the duplicate packages are merged
+func parseCycloneDX(content []byte) ([]*models.SBOMPackage, error) {
+	bom := struct {
+		BOMFormat  string               `json:"bomFormat"`
+		Components []cycloneDXComponent `json:"components"`
+	}{}
+	if err := json.Unmarshal(content, &bom); err != nil {
+		return nil, fmt.Errorf("SBOM 格式错误: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		return nil, fmt.Errorf("不支持的 SBOM 格式: %v, 仅支持 CycloneDX", bom.BOMFormat)
+	}
+	packages := []*models.SBOMPackage{}
+	seen := map[string]bool{}
+	var walk func(components []cycloneDXComponent)
+	walk = func(components []cycloneDXComponent) {
+		for _, component := range components {
+			key := component.Name + "@" + component.Version + "@" + component.Purl
+			if component.Name != "" && !seen[key] {
+				seen[key] = true
+				packages = append(packages, &models.SBOMPackage{
+					Addons:  models.NewAddons(),
+					Name:    truncateString(component.Name, 255),
+					Version: truncateString(component.Version, 128),
+					Type:    truncateString(component.Type, 32),
+					Purl:    truncateString(component.Purl, 512),
+				})
+			}
+			walk(component.Components)
+		}
+	}
+	walk(bom.Components)
+	return packages, nil
+}
+
+func truncateString(value string, size int) string {
+	if len(value) > size {
+		return value[:size]
+	}
+	return value
+}
+
+// ReportSBOM store the sbom of the image built by the build job app
+func (pm *PipelineManager) ReportSBOM(projectID, publishID, stageID, publishJobID, projectAppID int64, image string, content []byte) error {
+	job, err := pm.modelPublishJob.GetPublishJobByID(publishJobID)
+	if err != nil || job.PublishID != publishID || job.EnvID != stageID || job.JobType != models.JobTypeBuild {
+		return fmt.Errorf("构建任务 %v 不存在", publishJobID)
+	}
+	if _, err := pm.modelPublishJob.GetPublishJobApp(publishJobID, projectAppID); err != nil {
+		return fmt.Errorf("构建任务 %v 未包含应用 %v", publishJobID, projectAppID)
+	}
+	if image == "" {
+		return fmt.Errorf("缺少镜像地址")
+	}
+	packages, err := parseCycloneDX(content)
+	if err != nil {
+		return err
+	}
+	appName := ""
+	if projectApp, err := pm.modelProject.GetProjectApp(projectAppID); err == nil {
+		if scmApp, err := pm.modelApp.GetScmAppByID(projectApp.ScmID); err == nil {
+			appName = scmApp.Name
+		}
+	}
+	for _, item := range packages {
+		item.ProjectID = projectID
+		item.ProjectAppID = projectAppID
+		item.ImageAddr = image
+	}
+	sbom := &models.ImageSBOM{
+		Addons:       models.NewAddons(),
+		ProjectID:    projectID,
+		PublishID:    publishID,
+		StageID:      stageID,
+		PublishJobID: publishJobID,
+		ProjectAppID: projectAppID,
+		AppName:      appName,
+		ImageAddr:    image,
+		Format:       models.SBOMFormatCycloneDX,
+		Components:   len(packages),
+		Content:      string(content),
+	}
+	if err := pm.modelSBOM.CreateImageSBOM(sbom, packages); err != nil {
+		log.Log.Error("create sbom of publish job: %v app: %v occur error: %s", publishJobID, projectAppID, err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetPublishSBOMs return the sboms of the images built by the publish
+func (pm *PipelineManager) GetPublishSBOMs(projectID, publishID int64) ([]*models.ImageSBOM, error) {
+	publish, err := pm.modelPublish.GetPublishByID(publishID)
+	if err != nil || publish.ProjectID != projectID {
+		return nil, fmt.Errorf("版本 %v 不存在", publishID)
+	}
+	return pm.modelSBOM.GetPublishSBOMs(publishID)
+}
+
+// GetSBOM return the sbom with content for download
+func (pm *PipelineManager) GetSBOM(projectID, sbomID int64) (*models.ImageSBOM, error) {
+	sbom, err := pm.modelSBOM.GetImageSBOM(sbomID)
+	if err != nil || sbom.ProjectID != projectID {
+		return nil, fmt.Errorf("SBOM %v 不存在", sbomID)
+	}
+	return sbom, nil
+}
+
+// SBOMDeployment the app deployed in env with the image contains the package
+type SBOMDeployment struct {
+	ProjectID      int64     `json:"project_id"`
+	ProjectAppID   int64     `json:"project_app_id"`
+	StageID        int64     `json:"stage_id"`
+	PublishID      int64     `json:"publish_id"`
+	PublishJobID   int64     `json:"publish_job_id"`
+	ImageAddr      string    `json:"image_addr"`
+	Package        string    `json:"package"`
+	PackageVersion string    `json:"package_version"`
+	DeployedAt     time.Time `json:"deployed_at"`
+	// Current the image is still the latest deployed image of the app in the env
+	Current bool `json:"current"`
+}
+
+// FindSBOMDeployments return the latest deployment of each app in each env whose image contains the package,
+// the empty version matches all versions of the package
+func (pm *PipelineManager) FindSBOMDeployments(name, version string) ([]*SBOMDeployment, error) {
+	if name == "" {
+		return nil, fmt.Errorf("请输入软件包名称")
+	}
+	packages, err := pm.modelSBOM.FindSBOMPackages(name, version)
+	if err != nil {
+		return nil, err
+	}
+	versionOfImage := map[string]string{}
+	images := []string{}
+	for _, item := range packages {
+		if _, ok := versionOfImage[item.ImageAddr]; !ok {
+			versionOfImage[item.ImageAddr] = item.Version
+			images = append(images, item.ImageAddr)
+		}
+	}
+	jobApps, jobs, err := pm.modelSBOM.GetDeployJobAppsByImages(images)
+	if err != nil {
+		return nil, err
+	}
+	deployments := []*SBOMDeployment{}
+	seen := map[string]bool{}
+	for _, jobApp := range jobApps {
+		job := jobs[jobApp.PublishJobID]
+		key := fmt.Sprintf("%d/%d", job.EnvID, jobApp.ProjectAPPID)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deployment := &SBOMDeployment{
+			ProjectID:      job.ProjectID,
+			ProjectAppID:   jobApp.ProjectAPPID,
+			StageID:        job.EnvID,
+			PublishID:      job.PublishID,
+			PublishJobID:   job.ID,
+			ImageAddr:      jobApp.ImageAddr,
+			Package:        name,
+			PackageVersion: versionOfImage[jobApp.ImageAddr],
+			DeployedAt:     job.UpdateAt,
+		}
+		if last, err := pm.modelPublishJob.GetLastSuccessDeployJobApp(jobApp.ProjectAPPID, job.EnvID, 0); err == nil {
+			deployment.Current = last.ImageAddr == jobApp.ImageAddr
+		}
+		deployments = append(deployments, deployment)
+	}
+	return deployments, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestParseCycloneDX(t *testing.T) {
+	content := `{
+	"bomFormat": "CycloneDX",
+	"specVersion": "1.4",
+	"components": [
+		{"type": "library", "name": "log4j-core", "version": "2.14.1", "purl": "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1",
+		 "components": [{"type": "library", "name": "log4j-api", "version": "2.14.1"}]},
+		{"type": "library", "name": "log4j-core", "version": "2.14.1", "purl": "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+		{"type": "operating-system", "name": "alpine", "version": "3.15.0"}
+	]
+}`
+	packages, err := parseCycloneDX([]byte(content))
+	if err != nil {
+		t.Fatalf("parseCycloneDX() error: %v", err)
+	}
+	got := []string{}
+	for _, item := range packages {
+		got = append(got, item.Name+"@"+item.Version)
+	}
+	want := []string{"log4j-core@2.14.1", "log4j-api@2.14.1", "alpine@3.15.0"}
+	if len(got) != len(want) {
+		t.Fatalf("packages = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("packages = %v, want %v", got, want)
+		}
+	}
+
+	for _, content := range []string{`not json`, `{"spdxVersion": "SPDX-2.2"}`} {
+		if _, err := parseCycloneDX([]byte(content)); err == nil {
+			t.Fatalf("parseCycloneDX(%q) expect error", content)
+		}
+	}
+}
+
+func TestValidateSBOMTask(t *testing.T) {
+	tasks := []*subTask{
+		{Type: constant.StepSubTaskCompile},
+		{Type: constant.StepSubTaskSBOM},
+		{Type: constant.StepSubTaskBuildImage},
+		{Type: constant.StepSubTaskSBOM},
+	}
+	if err := validateSBOMTask(models.StepBuild, tasks, 1); err == nil {
+		t.Fatalf("sbom before build-image expect error")
+	}
+	if err := validateSBOMTask(models.StepBuild, tasks, 3); err != nil {
+		t.Fatalf("sbom after build-image error: %v", err)
+	}
+	if err := validateSBOMTask(models.StepDeploy, tasks, 3); err == nil {
+		t.Fatalf("sbom of deploy step expect error")
+	}
+}
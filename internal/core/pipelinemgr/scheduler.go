@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+var (
+	// maxConcurrentBuilds max running build jobs of all projects, 0 means unlimited
+	maxConcurrentBuilds = beego.AppConfig.DefaultInt("scheduler::max_builds", 0)
+	// maxProjectConcurrentBuilds default max running build jobs of one project, 0 means unlimited
+	maxProjectConcurrentBuilds = beego.AppConfig.DefaultInt("scheduler::max_project_builds", 0)
+)
+
+// runningBuildsByProject return the count of running build jobs group by project id
+func (pm *PipelineManager) runningBuildsByProject() (map[int64]int, error) {
+	runningJobs, err := pm.modelPublishJob.GetPublishJobsByFilter([]string{models.StatusRunning, models.StatusInit}, []string{models.JobTypeBuild})
+	if err != nil {
+		return nil, err
+	}
+	running := map[int64]int{}
+	for _, job := range runningJobs {
+		running[job.ProjectID]++
+	}
+	return running, nil
+}
+
+func (pm *PipelineManager) projectBuildLimit(projectID int64) int {
+	project, err := pm.modelProject.GetProjectByID(projectID)
+	if err != nil {
+		log.Log.Warn("get project: %v occur error: %s, use default build limit", projectID, err.Error())
+		return maxProjectConcurrentBuilds
+	}
+	if project.MaxConcurrentBuilds > 0 {
+		return project.MaxConcurrentBuilds
+	}
+	return maxProjectConcurrentBuilds
+}
+
+// orgBuildLimit return the build limit of the organization of project and the projects of the organization
+func (pm *PipelineManager) orgBuildLimit(projectID int64) (int, []*models.Project) {
+	project, err := pm.modelProject.GetProjectByID(projectID)
+	if err != nil || project.OrgID == 0 {
+		return 0, nil
+	}
+	orgModel := dao.NewOrganizationModel()
+	org, err := orgModel.GetOrganizationByID(project.OrgID)
+	if err != nil {
+		log.Log.Warn("get organization: %v occur error: %s, skip organization build limit", project.OrgID, err.Error())
+		return 0, nil
+	}
+	if org.MaxConcurrentBuilds <= 0 {
+		return 0, nil
+	}
+	projects, err := orgModel.GetOrganizationProjects(org.ID)
+	if err != nil {
+		log.Log.Warn("get projects of organization: %v occur error: %s, skip organization build limit", org.ID, err.Error())
+		return 0, nil
+	}
+	return org.MaxConcurrentBuilds, projects
+}
+
+// buildSlotAvailable verify the running build jobs did not reach the global, organization and project limit,
+// the reason is returned when there is no available slot
+func (pm *PipelineManager) buildSlotAvailable(projectID int64) (bool, string, error) {
+	running, err := pm.runningBuildsByProject()
+	if err != nil {
+		return false, "", err
+	}
+	total := 0
+	for _, count := range running {
+		total += count
+	}
+	if maxConcurrentBuilds > 0 && total >= maxConcurrentBuilds {
+		return false, fmt.Sprintf("running build jobs reached the global limit %v", maxConcurrentBuilds), nil
+	}
+	if limit, projects := pm.orgBuildLimit(projectID); limit > 0 {
+		orgRunning := 0
+		for _, item := range projects {
+			orgRunning += running[item.ID]
+		}
+		if orgRunning >= limit {
+			return false, fmt.Sprintf("running build jobs reached the organization limit %v", limit), nil
+		}
+	}
+	if limit := pm.projectBuildLimit(projectID); limit > 0 && running[projectID] >= limit {
+		return false, fmt.Sprintf("running build jobs reached the project limit %v", limit), nil
+	}
+	return true, "", nil
+}
+
+// lockBuildSlot the build slots are shared by all the replicas, the slot check and the build job creation are serialized,
+// otherwise the concurrent triggers see the same free slot and exceed the limit together
+func lockBuildSlot() (func(), error) {
+	unlock, err := dao.Lock("build-slot", 2*time.Minute, time.Minute)
+	if err != nil {
+		log.Log.Warn("lock build slot occur error: %s", err.Error())
+		return nil, fmt.Errorf("当前有其他构建任务正在调度，请稍后重试")
+	}
+	return unlock, nil
+}
+
+// buildSlotInOrder the new build queues after the waiting builds instead of taking the slot released for them,
+// the build dispatched from queue was scheduled in order already
+func (pm *PipelineManager) buildSlotInOrder(projectID int64, dispatched bool) (bool, string, error) {
+	if !dispatched {
+		items, err := pm.modelPublishJob.GetJobQueueItems(0, 0, []string{models.QueueStatusWaiting})
+		if err != nil {
+			return false, "", err
+		}
+		waiting := 0
+		for _, item := range items {
+			if item.JobType == models.JobTypeBuild {
+				waiting++
+			}
+		}
+		if waiting > 0 {
+			return false, fmt.Sprintf("there are %v build jobs queued ahead", waiting), nil
+		}
+	}
+	return pm.buildSlotAvailable(projectID)
+}
+
+// ScheduleJobQueue order the waiting items by publish level, the items of the same level are interleaved
+// between projects and the project has less running builds goes first, so one large project can not starve the others
+func (pm *PipelineManager) ScheduleJobQueue(items []*models.PublishJobQueue) []*models.PublishJobQueue {
+	levels := map[int64]int{}
+	for _, item := range items {
+		if _, ok := levels[item.PublishID]; ok {
+			continue
+		}
+		levels[item.PublishID] = models.PublishLevelNormal
+		if publishItem, err := pm.modelPublish.GetPublishByID(item.PublishID); err == nil {
+			levels[item.PublishID] = publishItem.Level
+		}
+	}
+	running, err := pm.runningBuildsByProject()
+	if err != nil {
+		log.Log.Warn("when schedule job queue, get running builds occur error: %s", err.Error())
+		running = map[int64]int{}
+	}
+	return fairOrder(items, levels, running)
+}
+
+// fairOrder the rank of item is its position in the project queue of the same level plus the running builds of project,
+// items are sorted by level desc, rank asc and enqueue order
+func fairOrder(items []*models.PublishJobQueue, levels map[int64]int, running map[int64]int) []*models.PublishJobQueue {
+	type projectLevel struct {
+		projectID int64
+		level     int
+	}
+	positions := map[projectLevel]int{}
+	ranks := map[int64]int{}
+	ordered := make([]*models.PublishJobQueue, len(items))
+	copy(ordered, items)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].ID < ordered[j].ID })
+	for _, item := range ordered {
+		key := projectLevel{projectID: item.ProjectID, level: levels[item.PublishID]}
+		rank := positions[key]
+		if item.JobType == models.JobTypeBuild {
+			rank += running[item.ProjectID]
+		}
+		ranks[item.ID] = rank
+		positions[key]++
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		li, lj := levels[ordered[i].PublishID], levels[ordered[j].PublishID]
+		if li != lj {
+			return li > lj
+		}
+		return ranks[ordered[i].ID] < ranks[ordered[j].ID]
+	})
+	return ordered
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestFairOrder(t *testing.T) {
+	newItem := func(id, projectID, publishID int64) *models.PublishJobQueue {
+		item := &models.PublishJobQueue{ProjectID: projectID, PublishID: publishID, JobType: models.JobTypeBuild}
+		item.ID = id
+		return item
+	}
+	tests := []struct {
+		name    string
+		items   []*models.PublishJobQueue
+		levels  map[int64]int
+		running map[int64]int
+		want    []int64
+	}{
+		{
+			name:  "fifo",
+			items: []*models.PublishJobQueue{newItem(2, 1, 1), newItem(1, 1, 1), newItem(3, 1, 1)},
+			want:  []int64{1, 2, 3},
+		},
+		{
+			name:  "interleave projects",
+			items: []*models.PublishJobQueue{newItem(1, 1, 1), newItem(2, 1, 1), newItem(3, 1, 1), newItem(4, 2, 2), newItem(5, 2, 2)},
+			want:  []int64{1, 4, 2, 5, 3},
+		},
+		{
+			name:    "less running project first",
+			items:   []*models.PublishJobQueue{newItem(1, 1, 1), newItem(2, 1, 1), newItem(3, 2, 2)},
+			running: map[int64]int{1: 2},
+			want:    []int64{3, 1, 2},
+		},
+		{
+			name:    "urgent publish first",
+			items:   []*models.PublishJobQueue{newItem(1, 1, 1), newItem(2, 2, 2), newItem(3, 1, 3)},
+			levels:  map[int64]int{3: models.PublishLevelUrgent},
+			running: map[int64]int{1: 5},
+			want:    []int64{3, 2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int64{}
+			for _, item := range fairOrder(tt.items, tt.levels, tt.running) {
+				got = append(got, item.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fairOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSlotInOrder(t *testing.T) {
+	initTestDB(t)
+	pm := NewPipelineManager()
+	running, err := pm.runningBuildsByProject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, count := range running {
+		total += count
+	}
+	// one free slot left
+	origin := maxConcurrentBuilds
+	maxConcurrentBuilds = total + 1
+	t.Cleanup(func() { maxConcurrentBuilds = origin })
+
+	env, publishItem := newQueueEnv(t, models.ConcurrencyPolicyQueue)
+	item := newQueueItem(t, publishItem, 1, models.JobTypeBuild)
+	if available, reason, err := pm.buildSlotInOrder(env.ProjectID, false); err != nil || available {
+		t.Fatalf("the new build should queue after the waiting build, available: %v, reason: %v, error: %v", available, reason, err)
+	}
+	if available, reason, err := pm.buildSlotInOrder(env.ProjectID, true); err != nil || !available {
+		t.Fatalf("the dispatched build should take the free slot, available: %v, reason: %v, error: %v", available, reason, err)
+	}
+	if err := pm.finishJobQueueItem(item, models.QueueStatusDispatched, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the concurrent triggers take the only free slot one by one
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := lockBuildSlot()
+			if err != nil {
+				t.Errorf("lock build slot error: %v", err)
+				return
+			}
+			defer unlock()
+			available, _, err := pm.buildSlotInOrder(env.ProjectID, true)
+			if err != nil || !available {
+				return
+			}
+			newRunningJob(t, publishItem, models.JobTypeBuild)
+			mu.Lock()
+			created++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Fatalf("%v build jobs were created with one free slot", created)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/astaxie/beego"
+)
+
+// the ci job only calls the step of the versioned shared library with the structured params when shared library is set,
+// instead of the pipeline concatenated by the xml snippets
+var (
+	jenkinsSharedLibrary        = beego.AppConfig.DefaultString("jenkins::shared_library", "")
+	jenkinsSharedLibraryVersion = beego.AppConfig.DefaultString("jenkins::shared_library_version", "v1")
+)
+
+// sharedLibraryStep the global variable of shared library which runs the ci pipeline
+const sharedLibraryStep = "atomciPipeline"
+
+// sharedLibrarySchemaVersion the version of the params, increased when the params changed incompatibly
+const sharedLibrarySchemaVersion = 1
+
+const sharedLibraryJobTemplate = `<flow-definition plugin="workflow-job">
+  <description>atomci jenkins pipeline, powered by shared library %s</description>
+  <keepDependencies>false</keepDependencies>
+  <properties>
+    <jenkins.model.BuildDiscarderProperty>
+      <strategy class="hudson.tasks.LogRotator">
+        <daysToKeep>-1</daysToKeep>
+        <numToKeep>10</numToKeep>
+        <artifactDaysToKeep>-1</artifactDaysToKeep>
+        <artifactNumToKeep>-1</artifactNumToKeep>
+      </strategy>
+    </jenkins.model.BuildDiscarderProperty>
+  </properties>
+  <definition class="org.jenkinsci.plugins.workflow.cps.CpsFlowDefinition" plugin="workflow-cps">
+    <script>%s</script>
+    <sandbox>true</sandbox>
+  </definition>
+  <triggers/>
+  <disabled>false</disabled>
+</flow-definition>`
+
+// sharedLibraryParams the params passed to the step of shared library
+type sharedLibraryParams struct {
+	SchemaVersion int                    `json:"schemaVersion"`
+	Namespace     string                 `json:"namespace"`
+	Agent         sharedLibraryAgent     `json:"agent"`
+	Env           map[string]interface{} `json:"env"`
+	// Stages the declarative stages generated by the steps of job
+	Stages   string                `json:"stages"`
+	Callback sharedLibraryCallback `json:"callback"`
+}
+
+// sharedLibraryAgent the kubernetes agent of pipeline
+type sharedLibraryAgent struct {
+	PodYAML     string `json:"podYaml"`
+	Cloud       string `json:"cloud,omitempty"`
+	Label       string `json:"label,omitempty"`
+	IdleMinutes int    `json:"idleMinutes,omitempty"`
+}
+
+type sharedLibraryCallback struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	Body  string `json:"body"`
+}
+
+// podTemplateYAML return the pod template of the pipeline generated by workflow
+func podTemplateYAML(pipelineXML string) (string, error) {
+	start := strings.Index(pipelineXML, "apiVersion: v1")
+	if start < 0 {
+		return "", errors.New("the pipeline has no pod template")
+	}
+	end := strings.Index(pipelineXML[start:], `"""`)
+	if end < 0 {
+		return "", errors.New("the pod template of pipeline is not closed")
+	}
+	return pipelineXML[start : start+end], nil
+}
+
+// sharedLibraryParams return the params of shared library, pipelineXML is the patched pipeline of the context
+func (c *podTemplateContext) sharedLibraryParams(pipelineXML, jobName string) (*sharedLibraryParams, error) {
+	podYAML, err := podTemplateYAML(pipelineXML)
+	if err != nil {
+		return nil, err
+	}
+	params := &sharedLibraryParams{
+		SchemaVersion: sharedLibrarySchemaVersion,
+		Namespace:     c.Namespace,
+		Agent:         sharedLibraryAgent{PodYAML: podYAML, Label: c.Label},
+		Env:           map[string]interface{}{},
+		Stages:        c.Stages,
+		Callback:      sharedLibraryCallback{URL: c.CallBack.URL, Token: c.CallBack.Token, Body: c.CallBack.Body},
+	}
+	if c.Agent != nil && c.Agent.IdleMinutes > 0 {
+		params.Agent.Label = jobName
+		params.Agent.IdleMinutes = c.Agent.IdleMinutes
+	}
+	if c.Cloud != nil {
+		params.Agent.Cloud = c.Cloud.Name
+	}
+	for _, item := range c.EnvVars {
+		params.Env[item.Key] = item.Value
+	}
+	return params, nil
+}
+
+// sharedLibraryJobXML return the config xml of the job which calls the shared library,
+// the params are encoded by base64 so that no escaping is needed in groovy
+func sharedLibraryJobXML(params *sharedLibraryParams) (string, error) {
+	content, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	library := fmt.Sprintf("%s@%s", jenkinsSharedLibrary, jenkinsSharedLibraryVersion)
+	script := fmt.Sprintf("@Library('%s') _\n%s('%s')\n", library, sharedLibraryStep, base64.StdEncoding.EncodeToString(content))
+	return fmt.Sprintf(sharedLibraryJobTemplate, escapeXML(library), escapeXML(script)), nil
+}
+
+func escapeXML(text string) string {
+	var buf bytes.Buffer
+	// the writes of bytes.Buffer never fail
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
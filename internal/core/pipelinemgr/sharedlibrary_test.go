@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/workflow/jenkins"
+)
+
+func TestSharedLibraryJobXML(t *testing.T) {
+	jenkinsSharedLibrary, jenkinsSharedLibraryVersion = "atomci-lib", "v2"
+	defer func() { jenkinsSharedLibrary, jenkinsSharedLibraryVersion = "", "v1" }()
+
+	ciContext := &podTemplateContext{
+		CIContext: jenkins.CIContext{
+			CommonContext:      jenkins.CommonContext{Namespace: "devops"},
+			Stages:             `stage('Build') { steps { sh "make && echo '<done>'" } }`,
+			EnvVars:            []jenkins.EnvItem{{Key: "REGISTRY_ADDR", Value: "harbor.example.com"}},
+			ContainerTemplates: []jenkins.ContainerEnv{compileContainer(compileEnv{Name: "demo", Image: "golang:1.15", WorkingDir: "/home/jenkins/agent"})},
+			CallBack:           jenkins.CallbackRequest{URL: "http://atomci/callback", Token: "token", Body: `{"a":"b&c"}`},
+		},
+		PodSpec: &buildPodSpec{NodeSelector: map[string]string{"pool": "ci"}},
+		Agent:   &AgentTemplate{IdleMinutes: 10},
+		Cloud:   &buildCloud{Name: "kubernetes-1"},
+	}
+	configXML, err := ciContext.configXML("atomci-project-1/job/atomci_1_2_3")
+	if err != nil {
+		t.Fatalf("generate config xml: %v", err)
+	}
+	job := struct {
+		Script string `xml:"definition>script"`
+	}{}
+	if err := xml.Unmarshal([]byte(configXML), &job); err != nil {
+		t.Fatalf("parse config xml: %v, %v", err, configXML)
+	}
+	lines := strings.Split(strings.TrimSpace(job.Script), "\n")
+	if len(lines) != 2 || lines[0] != "@Library('atomci-lib@v2') _" || !strings.HasPrefix(lines[1], "atomciPipeline('") {
+		t.Fatalf("unexpected script: %v", job.Script)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(lines[1], "atomciPipeline('"), "')"))
+	if err != nil {
+		t.Fatalf("decode params: %v", err)
+	}
+	params := sharedLibraryParams{}
+	if err := json.Unmarshal(content, &params); err != nil {
+		t.Fatalf("parse params: %v", err)
+	}
+	if params.SchemaVersion != sharedLibrarySchemaVersion || params.Stages != ciContext.Stages || params.Callback.Body != ciContext.CallBack.Body {
+		t.Fatalf("unexpected params: %+v", params)
+	}
+	if params.Agent.Cloud != "kubernetes-1" || params.Agent.Label != "atomci_1_2_3" || params.Agent.IdleMinutes != 10 {
+		t.Fatalf("unexpected agent: %+v", params.Agent)
+	}
+	if !strings.Contains(params.Agent.PodYAML, "namespace: devops") || !strings.Contains(params.Agent.PodYAML, `nodeSelector: {"pool":"ci"}`) {
+		t.Fatalf("unexpected pod yaml: %v", params.Agent.PodYAML)
+	}
+	if params.Env["REGISTRY_ADDR"] != "harbor.example.com" {
+		t.Fatalf("unexpected env: %v", params.Env)
+	}
+}
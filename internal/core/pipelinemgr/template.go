@@ -18,6 +18,8 @@ package pipelinemgr
 
 import (
 	"encoding/json"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
 )
 
 // PipelineBaseReq ..
@@ -46,6 +48,8 @@ type PipelineStageStruct struct {
 	ID         int64         `json:"id"`
 	Steps      PipelineSteps `json:"steps"`
 	Name       string        `json:"name,omitempty"`
+	// Policy the progression policy of stage, nil means manual progression
+	Policy *StagePolicy `json:"policy,omitempty"`
 	// instance part
 	PipelineInstanceID int64 `json:"pipeline_instance_id,omitempty"`
 }
@@ -59,6 +63,13 @@ type PipelineSteps []*struct {
 	Index       int        `json:"index"`
 	Driver      string     `json:"driver"`
 	SubTask     []*subTask `json:"sub_task"`
+	// DependsOn the indexes of the steps which must be success before this step,
+	// nil means depends on the previous step, empty means no dependency
+	DependsOn []int `json:"depends_on,omitempty"`
+	// Resources override the resources of compile env for the compile containers of build step
+	Resources *stepResources `json:"resources,omitempty"`
+	// Timeout the minutes the job of step allowed to run, overrides the watchdog config of the step type
+	Timeout int `json:"timeout,omitempty"`
 }
 
 type subTask struct {
@@ -66,6 +77,20 @@ type subTask struct {
 	Name   string       `json:"name,omitempty"`
 	Type   string       `json:"type,omitempty"`
 	Params []compileEnv `json:"params,omitempty"`
+	// Matrix only for compile sub task
+	Matrix *buildMatrix `json:"matrix,omitempty"`
+	// Terraform only for terraform sub task
+	Terraform *terraformTask `json:"terraform,omitempty"`
+	// E2E only for e2e-suite sub task of e2e-test step
+	E2E *e2eSuite `json:"e2e,omitempty"`
+	// Perf only for perf-test sub task of e2e-test step
+	Perf *perfTest `json:"perf,omitempty"`
+	// Input only for input sub task of manual step
+	Input *manualInput `json:"input,omitempty"`
+	// Artifact only for artifact sub task of build and e2e-test step
+	Artifact *artifactTask `json:"artifact,omitempty"`
+	// Sign only for sign sub task of build step
+	Sign *signTask `json:"sign,omitempty"`
 }
 
 type SubTask subTask
@@ -78,6 +103,19 @@ type compileEnv struct {
 	Command         string `json:"command,omitempty"`
 	Args            string `json:"args,omitempty"`
 	CompileCommpand string `json:"compile_commpand,omitempty"` // compile command, eg mvn, npm
+	CPURequest      string `json:"cpu_request,omitempty"`
+	CPULimit        string `json:"cpu_limit,omitempty"`
+	MemoryRequest   string `json:"memory_request,omitempty"`
+	MemoryLimit     string `json:"memory_limit,omitempty"`
+	// NodeSelector/Tolerations/ImagePullSecrets are merged into the build pod spec
+	NodeSelector     map[string]string               `json:"node_selector,omitempty"`
+	Tolerations      []settings.CompileEnvToleration `json:"tolerations,omitempty"`
+	ImagePullSecrets []string                        `json:"image_pull_secrets,omitempty"`
+	// OSType/Shell the os of build node and the shell renders the compile command
+	OSType string `json:"os_type,omitempty"`
+	Shell  string `json:"shell,omitempty"`
+	// Arch the cpu architectures supported by the compile env
+	Arch []string `json:"arch,omitempty"`
 }
 
 // String ...
@@ -0,0 +1,316 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+var (
+	terraformImage = beego.AppConfig.DefaultString("terraform::image", "hashicorp/terraform:1.3.7")
+)
+
+const terraformContainerName = "terraform"
+
+// maxTerraformOutput the max length of plan/apply output stored
+const maxTerraformOutput = 65535
+
+// the actions of terraform sub task
+const (
+	TerraformActionPlan  = "plan"
+	TerraformActionApply = "apply"
+)
+
+var (
+	terraformDirPattern  = regexp.MustCompile(`^[A-Za-z0-9_./-]*$`)
+	terraformNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// terraformTask the params of terraform sub task, the commands run in the dir of each app repo,
+// the values of backend config and vars are able to refer the build env vars, eg: ${TF_BACKEND_TOKEN}
+type terraformTask struct {
+	Action        string            `json:"action,omitempty"`
+	Dir           string            `json:"dir,omitempty"`
+	Workspace     string            `json:"workspace,omitempty"`
+	BackendConfig map[string]string `json:"backend_config,omitempty"`
+	Vars          map[string]string `json:"vars,omitempty"`
+}
+
+// validate the params are rendered into shell commands, only the safe characters are allowed
+func (t *terraformTask) validate() error {
+	if t == nil {
+		return fmt.Errorf("terraform 子任务缺少参数配置")
+	}
+	if t.Action != TerraformActionPlan && t.Action != TerraformActionApply {
+		return fmt.Errorf("terraform 子任务不支持的操作: %v，可选值为 %v/%v", t.Action, TerraformActionPlan, TerraformActionApply)
+	}
+	if !terraformDirPattern.MatchString(t.Dir) || strings.Contains(t.Dir, "..") || strings.HasPrefix(t.Dir, "/") {
+		return fmt.Errorf("terraform 目录: %v 无效，须为代码仓库内的相对路径", t.Dir)
+	}
+	if t.Workspace != "" && !terraformNamePattern.MatchString(t.Workspace) {
+		return fmt.Errorf("terraform workspace: %v 无效", t.Workspace)
+	}
+	for _, items := range []map[string]string{t.BackendConfig, t.Vars} {
+		for key, value := range items {
+			if !terraformNamePattern.MatchString(key) {
+				return fmt.Errorf("terraform 参数名: %v 无效", key)
+			}
+			if strings.ContainsAny(value, "'\"`\\\n") {
+				return fmt.Errorf("terraform 参数 %v 的值不能包含引号、反斜杠或换行", key)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSubTasks verify the params of the sub tasks
+func (p PipelineSteps) validateSubTasks() error {
+	for _, step := range p {
+		if step.Type == models.StepE2ETest && len(p.e2eSubTasks(step.Index, constant.StepSubTaskE2ESuite))+len(p.e2eSubTasks(step.Index, constant.StepSubTaskPerfTest)) == 0 {
+			return fmt.Errorf("任务节点 %v: 至少包含一个端到端测试套件或性能测试", step.Name)
+		}
+		if err := step.Resources.validate(); err != nil {
+			return fmt.Errorf("任务节点 %v: %s", step.Name, err.Error())
+		}
+		if err := validateManualInputKeys(step.SubTask); err != nil {
+			return fmt.Errorf("任务节点 %v: %s", step.Name, err.Error())
+		}
+		for i, task := range step.SubTask {
+			var err error
+			switch task.Type {
+			case constant.StepSubTaskTerraform:
+				err = task.Terraform.validate()
+			case constant.StepSubTaskDBMigration:
+				err = p.validateBeforeDeploy(step.Index)
+			case constant.StepSubTaskE2ESuite:
+				err = task.E2E.validate()
+			case constant.StepSubTaskPerfTest:
+				err = task.Perf.validate()
+			case constant.StepSubTaskArtifact:
+				err = task.Artifact.validate(step.Type)
+			case constant.StepSubTaskSBOM:
+				err = validateSBOMTask(step.Type, step.SubTask, i)
+			case constant.StepSubTaskSign:
+				err = validateSignTask(step.Type, step.SubTask, i)
+			case constant.StepSubTaskInput:
+				err = task.Input.validate()
+				if err == nil && step.Type != models.StepManual {
+					err = fmt.Errorf("%v 子任务只能用于人工卡点任务节点", task.Type)
+				}
+			}
+			if err == nil && step.Type != models.StepE2ETest && utils.Contains([]string{constant.StepSubTaskE2ESuite, constant.StepSubTaskPerfTest}, task.Type) {
+				err = fmt.Errorf("%v 子任务只能用于端到端测试任务节点", task.Type)
+			}
+			if err != nil {
+				return fmt.Errorf("任务节点 %v: %s", step.Name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// flags return the sorted command flags, eg: -var "region=cn"
+func terraformFlags(flag string, items map[string]string) string {
+	keys := []string{}
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	flags := []string{}
+	for _, key := range keys {
+		flags = append(flags, fmt.Sprintf(`%s "%s=%s"`, flag, key, items[key]))
+	}
+	return strings.Join(flags, " ")
+}
+
+// terraformChecksum the checksum of the plan output, apply verifies the plan is not changed after approval
+func terraformChecksum(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return hex.EncodeToString(sum[:])
+}
+
+// terraformCommands return the pipeline steps of terraform sub task in the work dir,
+// apply plans again and refuses to apply when the plan differs from the approved one
+func terraformCommands(task *terraformTask, workDir, checksum, reportURL string) []string {
+	cd := fmt.Sprintf("cd %s && ", workDir)
+	commands := []string{
+		fmt.Sprintf(`sh '%sterraform init -input=false -no-color %s'`, cd, terraformFlags("-backend-config", task.BackendConfig)),
+	}
+	if task.Workspace != "" {
+		commands = append(commands, fmt.Sprintf(`sh '%s(terraform workspace select %s || terraform workspace new %s)'`, cd, task.Workspace, task.Workspace))
+	}
+	plan := fmt.Sprintf(`%sterraform plan -input=false -no-color %s -out=tfplan && terraform show -no-color tfplan > tfplan.txt`, cd, terraformFlags("-var", task.Vars))
+	outputFile := "tfplan.txt"
+	if task.Action == TerraformActionApply {
+		commands = append(commands,
+			fmt.Sprintf(`sh '%s && echo "%s  tfplan.txt" | sha256sum -c -'`, plan, checksum),
+			fmt.Sprintf(`sh '%sterraform apply -input=false -no-color tfplan > tfapply.txt 2>&1; status=$?; cat tfapply.txt; exit $status'`, cd),
+		)
+		outputFile = "tfapply.txt"
+	} else {
+		commands = append(commands, fmt.Sprintf(`sh '%s && cat tfplan.txt'`, plan))
+	}
+	commands = append(commands, fmt.Sprintf(
+		`httpRequest acceptType: 'APPLICATION_JSON', contentType: 'TEXT_PLAIN', customHeaders: [[maskValue: true, name: 'Authorization', value: "Bearer ${env.ACCESS_TOKEN}"]], httpMode: 'POST', requestBody: readFile('%s/%s'), responseHandle: 'NONE', timeout: 30, url: '%s'`,
+		workDir, outputFile, reportURL))
+	return commands
+}
+
+// terraformContainer the container used to run terraform
+func terraformContainer() jenkins.ContainerEnv {
+	return jenkins.ContainerEnv{
+		Name:       terraformContainerName,
+		Image:      terraformImage,
+		WorkingDir: "/home/jenkins/agent",
+		CommandArr: []string{"cat"},
+	}
+}
+
+// renderTerraformStageForBuild run terraform in the dir of each app repo
+func (pm *PipelineManager) renderTerraformStageForBuild(projectID, publishID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, task *terraformTask) (string, error) {
+	if err := task.validate(); err != nil {
+		return "", err
+	}
+	stages := []string{}
+	for _, app := range allParms {
+		checksum := ""
+		if task.Action == TerraformActionApply {
+			plan, err := pm.modelTerraform.GetLatestTerraformPlan(publishID, stageID, app.Name, task.Dir, task.Workspace)
+			if err != nil {
+				if err == orm.ErrNoRows {
+					return "", fmt.Errorf("应用 %v 未执行 terraform plan，请先执行 plan 并经人工审核后再 apply", app.Name)
+				}
+				return "", err
+			}
+			if plan.Status != models.TerraformApproved {
+				return "", fmt.Errorf("应用 %v 的 terraform plan 状态为 %v，需经人工审核通过后才能 apply", app.Name, plan.Status)
+			}
+			checksum = plan.Checksum
+		}
+		workDir := strings.TrimSuffix(strings.Join([]string{pm.generateAppRepoPth(stageID, projectID, ciConfig.Workspace, app), task.Dir}, "/"), "/")
+		query := url.Values{}
+		query.Set("publish_job_id", fmt.Sprint(publishJobID))
+		query.Set("app", app.Name)
+		query.Set("dir", task.Dir)
+		query.Set("workspace", task.Workspace)
+		query.Set("action", task.Action)
+		reportURL := fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/terraform?%s", atomciServer, projectID, publishID, stageID, constant.StepBuild, query.Encode())
+		item := jenkins.StepItem{
+			Name:    fmt.Sprintf("'Terraform-%s-%s'", task.Action, app.Name),
+			Command: fmt.Sprintf("container('%s') {\n%s\n}", terraformContainerName, strings.Join(terraformCommands(task, workDir, checksum, reportURL), "\n")),
+		}
+		stage, err := jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+		if err != nil {
+			return "", err
+		}
+		stages = append(stages, stage)
+	}
+	return strings.Join(stages, " "), nil
+}
+
+// TerraformReportReq the terraform output reported by the build job
+type TerraformReportReq struct {
+	PublishJobID int64  `json:"publish_job_id"`
+	AppName      string `json:"app"`
+	Dir          string `json:"dir"`
+	Workspace    string `json:"workspace"`
+	Action       string `json:"action"`
+}
+
+// ReportTerraform store the plan output for approval, or the apply output of the approved plan
+func (pm *PipelineManager) ReportTerraform(projectID, publishID, stageID int64, req *TerraformReportReq, output string) error {
+	job, err := pm.modelPublishJob.GetPublishJobByID(req.PublishJobID)
+	if err != nil || job.PublishID != publishID || job.EnvID != stageID {
+		return fmt.Errorf("发布任务 %v 不属于流水线 %v 的阶段 %v", req.PublishJobID, publishID, stageID)
+	}
+	checksum := terraformChecksum(output)
+	if len(output) > maxTerraformOutput {
+		output = strings.ToValidUTF8(output[:maxTerraformOutput], "")
+	}
+	switch req.Action {
+	case TerraformActionPlan:
+		plan := &models.TerraformPlan{
+			Addons:       models.NewAddons(),
+			ProjectID:    projectID,
+			PublishID:    publishID,
+			StageID:      stageID,
+			PublishJobID: req.PublishJobID,
+			AppName:      req.AppName,
+			Dir:          req.Dir,
+			Workspace:    req.Workspace,
+			Checksum:     checksum,
+			Output:       output,
+			Status:       models.TerraformPlanned,
+		}
+		_, err := pm.modelTerraform.CreateTerraformPlan(plan)
+		return err
+	case TerraformActionApply:
+		plan, err := pm.modelTerraform.GetLatestTerraformPlan(publishID, stageID, req.AppName, req.Dir, req.Workspace)
+		if err != nil {
+			return fmt.Errorf("应用 %v 的 terraform plan 不存在: %s", req.AppName, err.Error())
+		}
+		if plan.Status != models.TerraformApproved {
+			return fmt.Errorf("应用 %v 的 terraform plan 状态为 %v，不能 apply", req.AppName, plan.Status)
+		}
+		plan.Status = models.TerraformApplied
+		plan.ApplyJobID = req.PublishJobID
+		plan.ApplyOutput = output
+		return pm.modelTerraform.UpdateTerraformPlan(plan, "status", "apply_job_id", "apply_output", "update_at")
+	default:
+		return fmt.Errorf("terraform 不支持的操作: %v", req.Action)
+	}
+}
+
+// reviewTerraformPlans the manual step approves or rejects the plans waiting for review in the stage
+func (pm *PipelineManager) reviewTerraformPlans(publishID, stageID int64, reviewer, status string) {
+	plans, err := pm.modelTerraform.GetTerraformPlansByStatus(publishID, stageID, models.TerraformPlanned)
+	if err != nil {
+		log.Log.Error("get publish: %v stage: %v terraform plans error: %s", publishID, stageID, err.Error())
+		return
+	}
+	for _, plan := range plans {
+		plan.Status = status
+		plan.Reviewer = reviewer
+		if err := pm.modelTerraform.UpdateTerraformPlan(plan, "status", "reviewer", "update_at"); err != nil {
+			log.Log.Error("review terraform plan: %v error: %s", plan.ID, err.Error())
+		}
+	}
+}
+
+// GetTerraformPlans return the terraform plans of publish
+func (pm *PipelineManager) GetTerraformPlans(projectID, publishID int64) ([]*models.TerraformPlan, error) {
+	if err := pm.verifyProjectPublish(projectID, publishID); err != nil {
+		return nil, err
+	}
+	return pm.modelTerraform.GetTerraformPlans(publishID, 0)
+}
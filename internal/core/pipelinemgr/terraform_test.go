@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTerraformTaskValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    *terraformTask
+		wantErr bool
+	}{
+		{"plan", &terraformTask{Action: "plan", Dir: "infra/prod", Workspace: "prod", Vars: map[string]string{"region": "${TF_REGION}"}}, false},
+		{"apply root dir", &terraformTask{Action: "apply"}, false},
+		{"nil", nil, true},
+		{"unknown action", &terraformTask{Action: "destroy"}, true},
+		{"parent dir", &terraformTask{Action: "plan", Dir: "../secrets"}, true},
+		{"absolute dir", &terraformTask{Action: "plan", Dir: "/etc"}, true},
+		{"dir injection", &terraformTask{Action: "plan", Dir: "infra; rm -rf /"}, true},
+		{"invalid workspace", &terraformTask{Action: "plan", Workspace: "prod && ls"}, true},
+		{"quote in var", &terraformTask{Action: "plan", Vars: map[string]string{"region": "cn' && ls '"}}, true},
+		{"invalid backend key", &terraformTask{Action: "plan", BackendConfig: map[string]string{"a b": "c"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.task.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTerraformCommands(t *testing.T) {
+	task := &terraformTask{
+		Action:        "plan",
+		Workspace:     "prod",
+		BackendConfig: map[string]string{"key": "app.tfstate", "bucket": "state"},
+		Vars:          map[string]string{"region": "cn"},
+	}
+	commands := strings.Join(terraformCommands(task, "/ws/1/2/infra/master", "", "http://atomci/report"), "\n")
+	for _, want := range []string{
+		`terraform init -input=false -no-color -backend-config "bucket=state" -backend-config "key=app.tfstate"`,
+		`terraform workspace select prod || terraform workspace new prod`,
+		`terraform plan -input=false -no-color -var "region=cn" -out=tfplan`,
+		`readFile('/ws/1/2/infra/master/tfplan.txt')`,
+		`url: 'http://atomci/report'`,
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("terraformCommands() = %v, want contains %v", commands, want)
+		}
+	}
+	if strings.Contains(commands, "terraform apply") {
+		t.Errorf("terraformCommands() of plan must not apply")
+	}
+
+	task.Action = "apply"
+	commands = strings.Join(terraformCommands(task, "/ws/infra", "abc", "http://atomci/report"), "\n")
+	for _, want := range []string{
+		`echo "abc  tfplan.txt" | sha256sum -c -`,
+		`terraform apply -input=false -no-color tfplan`,
+		`readFile('/ws/infra/tfapply.txt')`,
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("terraformCommands() = %v, want contains %v", commands, want)
+		}
+	}
+	if strings.Index(commands, "sha256sum") > strings.Index(commands, "terraform apply") {
+		t.Errorf("terraformCommands() must verify the plan before apply")
+	}
+}
@@ -32,6 +32,8 @@ type ResetPipelineReq struct {
 type ManualStepReq struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
+	// Inputs the values of the input fields defined by the manual step, keyed by the field key
+	Inputs map[string]string `json:"inputs,omitempty"`
 }
 
 // RunBuildAppReq .
@@ -46,6 +48,12 @@ type BuildStepReq struct {
 	ActionName string            `json:"action_name,omitempty"`
 	Apps       []*RunBuildAppReq `json:"apps,omitempty"`
 	EnvVars    []EnvItem         `json:"env_vars,omitempty"`
+	// SkipUnchanged skip the apps whose branch head was built by the publish already
+	SkipUnchanged bool `json:"skip_unchanged,omitempty"`
+	// Automated the build triggered automatically, eg: scm webhook, the queued job verified already, which skip the env permission check
+	Automated bool `json:"-"`
+	// Dispatched the build dispatched from job queue, which does not queue again after the other waiting builds
+	Dispatched bool `json:"-"`
 }
 
 // EnvItem env variable
@@ -57,18 +65,34 @@ type EnvItem struct {
 // BuildStepCallbackReq ..
 type BuildStepCallbackReq struct {
 	PublishJobID int64 `json:"publish_job_id"`
+	// IdempotencyKey the key issued to the job, the callback of same key is processed only once
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// Signature the hmac of publish job id and idempotency key, signed when the job created
+	Signature string `json:"signature,omitempty"`
+	// JobStatus the status of the job finished, the callback of job means success
+	JobStatus string `json:"-"`
+	// Resume the callback failed before, the job maybe in end status already
+	Resume bool `json:"-"`
 }
 
 // RunDeployAppReq .
 type RunDeployAppReq struct {
 	ProjectAppID int64 `json:"project_app_id"`
 	Gray         bool  `json:"gray"`
+	// ImageTag deploy the explicit image tag or digest(sha256:xxx), derived from branch and commit when empty
+	ImageTag string `json:"image_tag,omitempty"`
+	// BuiltTag the image tag built by the publish, which takes the place of the branch head when image tag is empty
+	BuiltTag string `json:"-"`
 }
 
 // DeployStepReq ..
 type DeployStepReq struct {
 	ActionName string             `json:"action_name"`
 	Apps       []*RunDeployAppReq `json:"apps"`
+	// OverrideWindow deploy out of the deploy windows or during the freeze period, only admin is allowed
+	OverrideWindow bool `json:"override_window,omitempty"`
+	// Automated the deploy triggered automatically, eg: the queued job verified already, which skip the env permission check
+	Automated bool `json:"-"`
 }
 
 // WeeklyDenyList ..
@@ -190,15 +214,20 @@ type FlowStageReq struct {
 
 // StepRsp .. manual step defined
 type StepRsp struct {
-	Name    string `json:"name,omitempty"`
-	Creator string `json:"creator,omitempty"`
-	Message string `json:"message,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Creator string            `json:"creator,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Inputs  map[string]string `json:"inputs,omitempty"`
 }
 
 // ManualStepResp ..
 type ManualStepResp struct {
 	PreviousStep *StepRsp `json:"previous_step"`
 	CurrenStep   *StepRsp `json:"current_step"`
+	// TerraformPlans the plans of stage waiting for the review of manual step
+	TerraformPlans []*models.TerraformPlan `json:"terraform_plans,omitempty"`
+	// Inputs the input fields of current manual step filled in by the approver
+	Inputs []*manualInput `json:"inputs,omitempty"`
 }
 
 // PublishStepResp ...
@@ -212,6 +241,8 @@ type PublishStepResp struct {
 	BuildPath         string   `json:"build_path,omitempty"`
 	CompileCommand    string   `json:"compile_command,omitempty"`
 	BranchHistoryList []string `json:"branch_history_list,omitempty"`
+	// BuiltVersion the `branch-commit` built by the latest success build of publish, the app could be deselected when unchanged
+	BuiltVersion string `json:"built_version,omitempty"`
 }
 
 // BuildStepResp ..
@@ -228,6 +259,13 @@ type DeployStepAppRsp struct {
 	ProjectAppID int64  `json:"project_app_id"`
 }
 
+// AppImageTagsRsp ..
+type AppImageTagsRsp struct {
+	ProjectAppID int64    `json:"project_app_id"`
+	Image        string   `json:"image"`
+	Tags         []string `json:"tags"`
+}
+
 // AppMergeInfo ..
 type AppMergeInfo struct {
 	Name         string `json:"name"`
@@ -264,8 +302,9 @@ type RunBuildAllParms struct {
 type RunDeployAllParms struct {
 	*models.ScmApp
 	*RunDeployAppReq
-	ImageAddr string `json:"image_addr"`
-	ProjectID int64
+	ImageAddr       string `json:"image_addr"`
+	ProjectID       int64
+	ArrangeRevision int64
 }
 
 // AppParamsForCreatePublishJob ..
@@ -276,14 +315,10 @@ type AppParamsForCreatePublishJob struct {
 	ImageVersion string `json:"image_version"`
 	Gray         bool   `json:"gray"`
 	ImageAddr    string `json:"image_addr"`
-}
-
-// AppParamsForHealthCheck ..
-type AppParamsForHealthCheck struct {
-	ID       int64  `json:"id"`
-	Name     string `json:"name"`
-	Type     string `json:"type"`
-	FullName string `json:"full_name"`
+	// ArrangeRevision the revision of app arrange which deploy used
+	ArrangeRevision int64 `json:"arrange_revision,omitempty"`
+	// Wave the deploy wave of app, zero when the apps are applied at once
+	Wave int `json:"wave,omitempty"`
 }
 
 // PublishJobBuildResult ..
@@ -313,6 +348,29 @@ type FakePublishJobRsp struct {
 	Status   string `json:"status"`
 }
 
+// JenkinsCI the jenkins server which runs the pipeline jobs of env
+type JenkinsCI struct {
+	URL   string
+	User  string
+	Token string
+	// Workspace the workspace of jenkins agent, the repos of apps are checked out under it
+	Workspace string
+	// Namespace the kubernetes namespace of the build pods
+	Namespace string
+	// Driver the workflow driver of ci server, jenkins or the external driver
+	Driver string
+}
+
+// DeployTarget the cluster which the apps of env deploy to and the registry which the images push to
+type DeployTarget struct {
+	EnvID       int64
+	Cluster     string
+	RegistryURL string
+	// RegistryAuth base64 encoded `user:password` of registry
+	RegistryAuth  string
+	RegistryHTTPS bool
+}
+
 // JenkinsConfigRsp ..
 type JenkinsConfigRsp struct {
 	Jenkins string `json:"jenkins"`
@@ -356,3 +414,22 @@ type ClusterListRsp struct {
 	Jenkins []*clusterItem `json:"jenkins"`
 	K8s     []*clusterItem `json:"k8s"`
 }
+
+// JobQueueItemRsp ..
+type JobQueueItemRsp struct {
+	*models.PublishJobQueue
+	Position    int    `json:"position"`
+	PublishName string `json:"publish_name"`
+	VersionNo   string `json:"version_no"`
+	StepName    string `json:"step_name"`
+}
+
+// JobQueueDispatchResult the result of running queued job, update publish by it
+type JobQueueDispatchResult struct {
+	PublishID int64
+	StageID   int64
+	Status    int64
+	RunID     int64
+	JobName   string
+	Creator   string
+}
@@ -20,16 +20,19 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/go-atomci/atomci/constant"
 	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/events"
 	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/core/runnermgr"
 	"github.com/go-atomci/atomci/internal/core/settings"
-	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/metrics"
 	"github.com/go-atomci/atomci/utils"
 
 	"github.com/drone/go-scm/scm"
@@ -45,6 +48,9 @@ var (
 	atomciServer = beego.AppConfig.String("atomci::url")
 )
 
+// mergeCheckMaxCommits max commits/pull requests of target branch scanned when check branch merged
+const mergeCheckMaxCommits = 100
+
 func (pm *PipelineManager) getManualStepInfo(instanceID, stageID int64, stepIndex int) (*StepRsp, error) {
 	operationLogs, err := pm.modelPublish.GetOperationLogByInstanceIDAndStageIDStepType(instanceID, stageID, stepIndex)
 	if err != nil {
@@ -60,6 +66,7 @@ func (pm *PipelineManager) getManualStepInfo(instanceID, stageID int64, stepInde
 			Name:    latestOperation.Step,
 			Creator: latestOperation.Creator,
 			Message: latestOperation.Message,
+			Inputs:  parseManualInputValues(latestOperation.Inputs),
 		}
 	}
 	return currentStepRsp, nil
@@ -76,12 +83,19 @@ func (pm *PipelineManager) getStepInfoByInstanceID(publishID int64) (*ManualStep
 	instanceID, stageID, stepIndex := publishModel.LastPipelineInstanceID, publishModel.StageID, publishModel.StepIndex
 
 	rsp := ManualStepResp{}
+	if plans, err := pm.modelTerraform.GetTerraformPlansByStatus(publishID, stageID, models.TerraformPlanned); err == nil {
+		rsp.TerraformPlans = plans
+	}
 	// Get Current Step Operation
 	StepRsp, err := pm.getManualStepInfo(instanceID, stageID, stepIndex)
 	if err != nil {
 		return nil, err
 	}
 	rsp.CurrenStep = StepRsp
+	if rsp.Inputs, err = pm.getManualStepInputs(instanceID, stageID, stepIndex); err != nil {
+		log.Log.Error("when get manual step info, get step inputs occur error: %s", err.Error())
+		return nil, err
+	}
 
 	// Get Pervious Step Operation
 	if stepIndex == 1 {
@@ -97,15 +111,6 @@ func (pm *PipelineManager) getStepInfoByInstanceID(publishID int64) (*ManualStep
 	return &rsp, nil
 }
 
-func (pm *PipelineManager) getUserToken(user string) (string, error) {
-	userInfo, err := dao.NewUserRolesModel().GetUserByName(user)
-	if err != nil {
-		log.Log.Error("when get %v token by name occur error: %s", user, err.Error())
-		return "", err
-	}
-	return userInfo.Token, nil
-}
-
 // generate compileEnv based on project app compileEnvID
 func (pm *PipelineManager) generateCompileEnvParams(apps []*RunBuildAppReq) []compileEnv {
 	compileParams := []compileEnv{}
@@ -133,14 +138,7 @@ func (pm *PipelineManager) generateCompileEnvParams(apps []*RunBuildAppReq) []co
 			log.Log.Warn("app: %v setup complie env to %v, skip this compileItem generate", constant.DefaultContainerName, scmApp.Name)
 			continue
 		}
-		compileEnvItem := compileEnv{
-			Image:      compileItem.Image,
-			Args:       compileItem.Args,
-			Command:    compileItem.Command,
-			WorkingDir: "/home/jenkins/agent",
-			Name:       strings.ToLower(scmApp.Name),
-		}
-		compileParams = append(compileParams, compileEnvItem)
+		compileParams = append(compileParams, newCompileEnvParam(strings.ToLower(scmApp.Name), compileItem))
 	}
 	return compileParams
 }
@@ -154,7 +152,7 @@ func (pm *PipelineManager) getSysDefaultCompileEnv(compileEnvName string) (jenki
 	return jenkins.ContainerEnv{
 		Name:       compileEnv.Name,
 		Image:      compileEnv.Image,
-		WorkingDir: "/home/jenkins/agent",
+		WorkingDir: agentWorkingDir(compileEnv.CompileEnvOS()),
 		// TODO: command / args is valid
 		CommandArr: commandAndArgSplit(compileEnv.Command),
 		ArgsArr:    commandAndArgSplit(compileEnv.Args),
@@ -163,25 +161,19 @@ func (pm *PipelineManager) getSysDefaultCompileEnv(compileEnvName string) (jenki
 
 // CreateBuildJob return publishjob run id, error
 func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID int64, envStageJSON *PipelineStageStruct, apps []*RunBuildAppReq, customeEnvVars []EnvItem) (int64, string, error) {
-	// Prerequisites -jenkins
-	CIInfo, err := pm.GetCIConfig(envStageJSON.StageID)
+	// the images of all the apps in the job are tagged by the same commit of branch
+	pm = pm.withCommitCache()
+	// Prerequisites -jenkins, the healthy jenkins of ci server pool which has the shortest build queue
+	route, err := pm.routeCIServer(envStageJSON.StageID)
 	if err != nil {
-		log.Log.Error("getCIConfig occur error: %s", err.Error())
 		return 0, "", err
 	}
-	if len(CIInfo) != 5 {
-		log.Log.Error("get ci config len is not 5, ciinfo: %+v", CIInfo)
-		return 0, "", fmt.Errorf("get ci config len is not 5, ciinfo: %+v", CIInfo)
-	}
-	addr, user, token := CIInfo[0], CIInfo[1], CIInfo[2]
-
-	jenkinsClient, err := NewWorkFlowProvide(workflow.DriverJenkins.String(), addr, user, token, "", nil)
+	CIInfo, err := pm.getCIConfig(envStageJSON.StageID, route.SettingID, route.Version)
 	if err != nil {
+		log.Log.Error("getCIConfig occur error: %s", err.Error())
 		return 0, "", err
 	}
-	if _, err := jenkinsClient.Ping(); err != nil {
-		return 0, "", fmt.Errorf("jenkins is unhealthy, error: %s", err.Error())
-	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
 
 	publishItem, err := pm.modelPublish.GetPublishByID(publishID)
 	if err != nil {
@@ -190,6 +182,7 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 	}
 
 	stepSubTasks := []*subTask{}
+	var buildResources *stepResources
 	stepIndex := publishItem.StepIndex
 	compileParams := pm.generateCompileEnvParams(apps)
 
@@ -199,6 +192,7 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 			// stepSubTasks = item.SubTasks
 			// step sub tasks defined
 			stepSubTasks = item.SubTask
+			buildResources = item.Resources
 			if len(stepSubTasks) == 0 {
 				logs.Warn("sub tasks redefined")
 				stepSubTasks = []*subTask{
@@ -237,17 +231,16 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 		return 0, "", fmt.Errorf("this build jod did not have sub tasks, or rquest invalid")
 	}
 
+	// the release branch created for publish takes the place of dev branch
+	pm.applyReleaseBranches(publishID, apps)
 	// Aggregate the app parms for build based on request params
 	appsAllParams, _ := pm.aggregateAppsParamsForBuild(apps, envStageJSON)
 
-	deployInfo, _, err := pm.getDeployInfo(envStageJSON.StageID)
+	deployTarget, err := pm.GetDeployTarget(envStageJSON.StageID)
 	if err != nil {
-		log.Log.Error("getDeployInfo occur error: %s", err.Error())
+		log.Log.Error("get deploy target occur error: %s", err.Error())
 		return 0, "", err
 	}
-	if len(deployInfo) != 4 {
-		log.Log.Error("deploy info is validate, len: %v", len(deployInfo))
-	}
 	// Create publishJob publishJobApps
 	appsParamsForJob := []*AppParamsForCreatePublishJob{}
 	for _, param := range appsAllParams {
@@ -255,18 +248,18 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 			ProjectAppID: param.ProjectAppID,
 			Branch:       param.Branch,
 			Path:         param.Path,
-			// TODO: image version get based on image tag rule
-			ImageVersion: "",
+			// the `branch-commit` built by the job, the unchanged app is skipped by the later build
+			ImageVersion: pm.builtImageVersion(param.ProjectAppID, param.Branch),
 		}
 		appsParamsForJob = append(appsParamsForJob, paramForJob)
 	}
 
-	publishJobID, err := pm.CreatePublishJob(projectID, publishID, envStageJSON.StageID, creator, "build", appsParamsForJob)
+	publishJobID, err := pm.createPublishJob(projectID, publishID, envStageJSON.StageID, creator, "build", appsParamsForJob, route)
 	if err != nil {
 		log.Log.Error("when create build job, create publish job error: %s", err.Error())
 		return 0, "", err
 	}
-	jobName := fmt.Sprintf("atomci_%v_%v_%v", projectID, publishID, envStageJSON.StageID)
+	jobName := BuildJobName(projectID, publishID, envStageJSON.StageID)
 
 	jenkinsJNLPTemplate, err := pm.getSysDefaultCompileEnv(constant.DefaultContainerName)
 	if err != nil {
@@ -284,14 +277,97 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 		jenkinsJNLPTemplate,
 		jenkinsKanikoTemplate,
 	}
+	// the build matrix declared by compile sub task, the target architectures of apps are built if it has no arch
+	var matrix *buildMatrix
+	for _, subTask := range stepSubTasks {
+		if subTask.Type != constant.StepSubTaskCompile {
+			continue
+		}
+		if subTask.Matrix, err = withAppArchs(subTask.Matrix, appsAllParams); err != nil {
+			return 0, "", err
+		}
+		if err := pm.verifyMatrix(subTask.Matrix); err != nil {
+			return 0, "", err
+		}
+		if subTask.Matrix != nil {
+			matrix = subTask.Matrix
+			if subTask.Params, err = pm.generateMatrixCompileEnvParams(appsAllParams, matrix); err != nil {
+				return 0, "", err
+			}
+		}
+	}
+	if matrix.multiArch() {
+		containerTemplates = append(containerTemplates, manifestContainer())
+	}
+	// the shells render the steps in containers, the windows build runs on windows nodes with powershell by default
+	compileOSParams := []compileEnv{}
+	for _, subTask := range stepSubTasks {
+		if subTask.Type == constant.StepSubTaskCompile {
+			compileOSParams = append(compileOSParams, subTask.Params...)
+		}
+	}
+	osType, err := buildOS(compileOSParams)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := verifyNodeArch(matrix.nodeArch(), compileOSParams); err != nil {
+		return 0, "", err
+	}
+	shells := map[string]string{constant.DefaultContainerName: models.CompileEnvShellSh}
+	dockerConfig := "/kaniko/.docker"
+	if osType == models.CompileEnvOSWindows {
+		if err := verifyWindowsSubTasks(stepSubTasks, appsAllParams); err != nil {
+			return 0, "", err
+		}
+		if containerTemplates, err = pm.windowsContainerTemplates(); err != nil {
+			return 0, "", err
+		}
+		shells[constant.DefaultContainerName] = models.CompileEnvShellPowershell
+		dockerConfig = windowsAgentWorkingDir + "/.docker"
+	}
+	for _, param := range compileOSParams {
+		shells[param.Name] = param.Shell
+	}
+	for _, subTask := range stepSubTasks {
+		if subTask.Type == constant.StepSubTaskTerraform {
+			containerTemplates = append(containerTemplates, terraformContainer())
+			break
+		}
+	}
+	for _, subTask := range stepSubTasks {
+		if subTask.Type == constant.StepSubTaskSBOM {
+			containerTemplates = append(containerTemplates, sbomContainer())
+			break
+		}
+	}
+	signEnvVars := []jenkins.EnvItem{}
+	for _, subTask := range stepSubTasks {
+		if subTask.Type == constant.StepSubTaskSign {
+			if signEnvVars, err = pm.cosignEnvVars(subTask.Sign.Cosign); err != nil {
+				return 0, "", err
+			}
+			containerTemplates = append(containerTemplates, cosignContainer())
+			break
+		}
+	}
+	artifactEnvVars := []jenkins.EnvItem{}
+	for _, subTask := range stepSubTasks {
+		if subTask.Type == constant.StepSubTaskArtifact {
+			var container jenkins.ContainerEnv
+			container, artifactEnvVars = artifactContainer()
+			containerTemplates = append(containerTemplates, container)
+			break
+		}
+	}
 	// TaskTmplItem.SubTask
 	taskPipelineXMLStrArr := []string{}
+	podCompileParams := []compileEnv{}
 	for _, subTask := range stepSubTasks {
 		taskPipelineXMLStr := ""
 		switch subTask.Type {
 		case constant.StepSubTaskCheckout:
 			//
-			appCheckoutItems, err := pm.renderAppCheckoutItemsForBuild(projectID, envStageJSON.StageID, publishJobID, appsAllParams)
+			appCheckoutItems, err := pm.renderAppCheckoutItemsForBuild(projectID, envStageJSON.StageID, appsAllParams, CIInfo, shells[constant.DefaultContainerName])
 			if err != nil {
 				return 0, "", err
 			}
@@ -303,17 +379,12 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 		case constant.StepSubTaskCompile:
 			for _, compileItem := range subTask.Params {
 				log.Log.Debug("sub task image: %v", compileItem.Name)
-				compileContainerItem := jenkins.ContainerEnv{
-					Name:       compileItem.Name,
-					Image:      compileItem.Image,
-					CommandArr: commandAndArgSplit(compileItem.Command),
-					ArgsArr:    commandAndArgSplit(compileItem.Args),
-					WorkingDir: compileItem.WorkingDir,
-				}
-				containerTemplates = append(containerTemplates, compileContainerItem)
+				compileItem = buildResources.apply(compileItem)
+				containerTemplates = append(containerTemplates, compileContainer(compileItem))
+				podCompileParams = append(podCompileParams, compileItem)
 			}
 
-			appBuildItems, err := pm.renderAppBuildItemsForBuild(projectID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo)
+			appBuildItems, err := pm.renderAppBuildItemsForBuild(projectID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo, matrix, shells)
 			if err != nil {
 				return 0, "", err
 			}
@@ -324,8 +395,14 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 			}
 
 		case constant.StepSubTaskBuildImage:
-			//
-			appImageItems, err := pm.renderAppImageitemsForBuild(projectID, publishID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo, deployInfo)
+			if osType == models.CompileEnvOSWindows {
+				taskPipelineXMLStr, err = pm.renderWindowsImageStageForBuild(projectID, envStageJSON.StageID, appsAllParams, CIInfo)
+				if err != nil {
+					return 0, "", err
+				}
+				break
+			}
+			appImageItems, err := pm.renderAppImageitemsForBuild(projectID, publishID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo, deployTarget, matrix)
 			if err != nil {
 				return 0, "", err
 			}
@@ -334,6 +411,61 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 			if err != nil {
 				return 0, "", err
 			}
+			buildpacksContainers, containerOfApp := pm.buildpacksContainers(appsAllParams)
+			buildpacksStageStr, err := pm.renderBuildpacksStageForBuild(projectID, envStageJSON.StageID, appsAllParams, CIInfo, deployTarget, matrix, containerOfApp)
+			if err != nil {
+				return 0, "", err
+			}
+			if buildpacksStageStr != "" {
+				containerTemplates = append(containerTemplates, buildpacksContainers...)
+				taskPipelineXMLStr = taskPipelineXMLStr + " " + buildpacksStageStr
+			}
+			dockerfileStageStr, err := pm.renderDockerfileStageForBuild(projectID, envStageJSON.StageID, appsAllParams, CIInfo, matrix)
+			if err != nil {
+				return 0, "", err
+			}
+			if dockerfileStageStr != "" {
+				taskPipelineXMLStr = dockerfileStageStr + " " + taskPipelineXMLStr
+			}
+			if matrix.multiArch() {
+				manifestStageStr, err := pm.renderManifestStageForBuild(envStageJSON.StageID, appsAllParams, matrix, deployTarget)
+				if err != nil {
+					return 0, "", err
+				}
+				taskPipelineXMLStr = taskPipelineXMLStr + " " + manifestStageStr
+			}
+
+		case constant.StepSubTaskTerraform:
+			taskPipelineXMLStr, err = pm.renderTerraformStageForBuild(projectID, publishID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo, subTask.Terraform)
+			if err != nil {
+				return 0, "", err
+			}
+
+		case constant.StepSubTaskDBMigration:
+			var migrationContainers []jenkins.ContainerEnv
+			taskPipelineXMLStr, migrationContainers, err = pm.renderDBMigrationStageForBuild(projectID, publishID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo)
+			if err != nil {
+				return 0, "", err
+			}
+			containerTemplates = append(containerTemplates, migrationContainers...)
+
+		case constant.StepSubTaskSBOM:
+			taskPipelineXMLStr, err = pm.renderSBOMStageForBuild(projectID, publishID, envStageJSON.StageID, publishJobID, appsAllParams, CIInfo, deployTarget)
+			if err != nil {
+				return 0, "", err
+			}
+
+		case constant.StepSubTaskSign:
+			taskPipelineXMLStr, err = pm.renderSignStageForBuild(envStageJSON.StageID, appsAllParams, deployTarget)
+			if err != nil {
+				return 0, "", err
+			}
+
+		case constant.StepSubTaskArtifact:
+			taskPipelineXMLStr, err = pm.renderArtifactUploadStageForBuild(projectID, publishID, envStageJSON.StageID, appsAllParams, CIInfo, subTask.Artifact)
+			if err != nil {
+				return 0, "", err
+			}
 
 		default:
 			logs.Info("%v sub task type did not matched, taskPipelineXmlStr is empty value", subTask.Type)
@@ -347,46 +479,34 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 		log.Log.Error("project app len is 0, invalidate")
 		return 0, "", fmt.Errorf("project app len is 0, invalidate")
 	}
-	oneAppReq := apps[0]
-	projectApp, err := pm.modelProject.GetProjectApp(oneAppReq.ProjectAppID)
-	if err != nil {
-		log.Log.Error("when crate build job, get project app error: %s", err.Error())
-		return 0, "", err
-	}
-	scmApp, err := pm.modelApp.GetScmAppByID(projectApp.ScmID)
-	if err != nil {
-		log.Log.Error("when crate build job, get scm app error: %s", err.Error())
-		return 0, "", err
-	}
-
-	scmIntegrateResp, err := pm.settingsHandler.GetSCMIntegrateSettinByID(scmApp.RepoID)
+	scmCredentialEnvVars, err := pm.generateSCMCredentialEnvVars(appsAllParams)
 	if err != nil {
-		log.Log.Error("when crate build job, get scm integrate setting error: %s", err.Error())
 		return 0, "", err
 	}
 
-	baseURL := strings.Replace(scmIntegrateResp.URL, "http://", "", -1)
-	baseURL = strings.Replace(baseURL, "https://", "", -1)
-	if strings.HasSuffix(baseURL, "/") {
-		baseURL = strings.Replace(baseURL, "/", "", -1)
-	}
-	repoConfStr := fmt.Sprintf("{\"%s\":[\"%s\",\"%s\"]}", baseURL, scmIntegrateResp.User, scmIntegrateResp.Token)
-
-	adminToken, err := pm.getUserToken("admin")
+	callbackToken, err := issueCallbackToken(projectID, publishID, envStageJSON.StageID, publishJobID, "build")
 	if err != nil {
-		log.Log.Error("get admin token occur error: %v", err.Error())
+		log.Log.Error("issue callback token occur error: %v", err.Error())
 		return 0, "", fmt.Errorf("网络错误，请重试")
 	}
 
 	// TODO: Input correct env values
 	envVars := []jenkins.EnvItem{
-		{Key: "JENKINS_SLAVE_WORKSPACE", Value: CIInfo[3]},
-		{Key: "ACCESS_TOKEN", Value: adminToken},
-		{Key: "REPO_CNF", Value: repoConfStr},
-		{Key: "DOCKER_AUTH", Value: deployInfo[2]},
-		{Key: "REGISTRY_ADDR", Value: deployInfo[1]},
-		{Key: "DOCKER_CONFIG", Value: "/kaniko/.docker"},
+		{Key: "JENKINS_SLAVE_WORKSPACE", Value: CIInfo.Workspace},
+		{Key: "ACCESS_TOKEN", Value: callbackToken},
+		{Key: "ATOMCI_QUALITY_URL", Value: qualityReportURL(projectID, publishID, envStageJSON.StageID, publishJobID, "build")},
+		{Key: "DOCKER_AUTH", Value: deployTarget.RegistryAuth},
+		{Key: "REGISTRY_ADDR", Value: deployTarget.RegistryURL},
+		{Key: "DOCKER_CONFIG", Value: dockerConfig},
+	}
+	envVars = append(envVars, scmCredentialEnvVars...)
+	inputEnvVars, err := pm.manualInputEnvVars(publishItem.LastPipelineInstanceID)
+	if err != nil {
+		return 0, "", err
 	}
+	envVars = append(envVars, inputEnvVars...)
+	envVars = append(envVars, artifactEnvVars...)
+	envVars = append(envVars, signEnvVars...)
 
 	for _, env := range customeEnvVars {
 		jenkinsEnvItem := jenkins.EnvItem{
@@ -397,7 +517,11 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 	}
 
 	callBackURL := fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/callback", atomciServer, projectID, publishID, envStageJSON.StageID, "build")
-	callBackRequestBody := fmt.Sprintf("{\"publish_job_id\": %d}", publishJobID)
+	callBackRequestBody, err := callbackRequestBody(publishJobID)
+	if err != nil {
+		log.Log.Error("generate callback request body occur error: %v", err.Error())
+		return 0, "", fmt.Errorf("网络错误，请重试")
+	}
 
 	// k8sDeployInfo, err := pm.getDeployInfo(stageJSON.StageID)
 	// k8sDeployInfo: []string{harbor.HarborName, harbor.HarborAddr, flowStage.ArrangeEnv, harbor.HarborUser, harbor.HarborPassword}
@@ -405,21 +529,26 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 	// return 0, "", err
 	// }
 
-	flowProcessor := &jenkins.CIContext{
+	ciContext := jenkins.CIContext{
 		EnvVars:            envVars,
 		ContainerTemplates: containerTemplates,
 		Stages:             pipelineStagesStr,
 		CommonContext: jenkins.CommonContext{
-			Namespace: CIInfo[4],
+			Namespace: CIInfo.Namespace,
 		},
 		CallBack: jenkins.CallbackRequest{
-			Token: adminToken,
+			Token: callbackToken,
 			URL:   callBackURL,
 			Body:  callBackRequestBody,
 		},
 	}
+	// the build cluster and the scheduling of compile envs are patched into the pipeline
+	flowProcessor, err := pm.ciFlowProcessor(envStageJSON.StageID, ciContext, podCompileParams, matrix.nodeArch())
+	if err != nil {
+		return 0, "", err
+	}
 
-	workerflowClient, err := NewWorkFlowProvide(workflow.DriverJenkins.String(), addr, user, token, jobName, flowProcessor)
+	workerflowClient, err := NewWorkFlowProvide(CIInfo.Driver, addr, user, token, jobName, flowProcessor)
 	if err != nil {
 		log.Log.Error("when new workflow provide error: %s", err.Error())
 		return 0, "", err
@@ -429,6 +558,7 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 		// TODO: deleted publishjob item already created
 		return 0, "", err
 	}
+	metrics.BuildsStarted.Inc(strconv.FormatInt(projectID, 10))
 	// Update runID/status to publishjob
 	err = pm.UpdatePublishJob(publishJobID, runID)
 	if err != nil {
@@ -438,145 +568,136 @@ func (pm *PipelineManager) CreateBuildJob(creator string, projectID, publishID i
 }
 
 // CreateDeployJob return publishjob run id, error
+// the deploy job was driven by atomci itself, the rollout status of apps will be checked by health check server.
 func (pm *PipelineManager) CreateDeployJob(creator string, projectID, publishID int64, stageJSON *PipelineStageStruct, apps []*RunDeployAppReq) (int64, string, error) {
-	// Aggregate the app parms for deploy based on request params
-	appsAllParams, _ := pm.aggregateAppsParamsForDeploy(publishID, stageJSON.StageID, apps, stageJSON)
-
-	CIInfo, err := pm.GetCIConfig(stageJSON.StageID)
-	if err != nil {
-		log.Log.Error("getCIConfig occur error: %s", err.Error())
+	// the images of all the apps in the job are tagged by the same commit of branch
+	pm = pm.withCommitCache()
+	pm.applyBuiltImageTags(publishID, apps)
+	// deploy by digest, the image which does not exist fails the deploy before apply
+	if err := pm.pinDeployImages(publishID, stageJSON.StageID, apps); err != nil {
+		log.Log.Error("when create deploy job, pin images digest occur error: %s", err.Error())
 		return 0, "", err
 	}
-	addr, user, token := CIInfo[0], CIInfo[1], CIInfo[2]
-
-	jenkinsClient, err := NewWorkFlowProvide(workflow.DriverJenkins.String(), addr, user, token, "", nil)
+	envModel, err := pm.modelProject.GetProjectEnvByID(stageJSON.StageID)
 	if err != nil {
+		log.Log.Error("when create deploy job, get project env by id occur error: %s", err.Error())
 		return 0, "", err
 	}
-	if _, err := jenkinsClient.Ping(); err != nil {
-		return 0, "", fmt.Errorf("jenkins is unhealthy, error: %s", err.Error())
+	if err := pm.VerifyEnvUnlocked(envModel.ID); err != nil {
+		return 0, "", err
 	}
+	// the images are verified by digest which is pinned already, so the verified images are deployed
+	if err := pm.verifyDeployImages(envModel, publishID, apps); err != nil {
+		log.Log.Error("when create deploy job, verify images signature occur error: %s", err.Error())
+		return 0, "", err
+	}
+
+	// the apps applied directly are deployed in waves by their dependencies, argo cd syncs all the apps at once
+	appWaves, lastWave := map[int64]int{}, 0
+	if envModel.ArgoCD == 0 {
+		appWaves, lastWave, err = pm.planDeployWaves(apps)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	// Aggregate the app parms for deploy based on request params
+	appsAllParams, _ := pm.aggregateAppsParamsForDeploy(publishID, stageJSON.StageID, apps, stageJSON)
 
 	// Create publishJob publishJobApps
 	appsParamsForJob := []*AppParamsForCreatePublishJob{}
 	for _, param := range appsAllParams {
 		paramForJob := &AppParamsForCreatePublishJob{
-			ProjectAppID: param.ProjectAppID,
-			Path:         param.Path,
-			ImageAddr:    param.ImageAddr,
+			ProjectAppID:    param.ProjectAppID,
+			Path:            param.Path,
+			ImageAddr:       param.ImageAddr,
+			ArrangeRevision: param.ArrangeRevision,
+		}
+		if lastWave > 1 {
+			paramForJob.Wave = appWaves[param.ProjectAppID]
 		}
 		appsParamsForJob = append(appsParamsForJob, paramForJob)
 	}
 
 	jobName := fmt.Sprintf("atomci_%v_%v", projectID, stageJSON.StageID)
 
-	// deploy app, combine app arrange to temmplateStr
-	templateStr, err := pm.renderTemplateStr(apps, publishID, stageJSON.StageID)
-	if err != nil {
-		return 0, "", err
+	// the apps of later waves are applied by health check once the apps of previous wave are ready
+	applyApps := apps
+	if lastWave > 1 {
+		applyApps = appsOfWave(apps, appWaves, 1)
 	}
-
-	envModel, err := pm.modelProject.GetProjectEnvByID(stageJSON.StageID)
-	if err != nil {
-		log.Log.Error("when create deploy job, get project env by id occur error: %s", err.Error())
-		return 0, "", err
-	}
-
-	clusterModel, err := pm.settingsHandler.GetIntegrateSettingByID(envModel.Cluster)
-	if err != nil {
-		log.Log.Error("when create deploy job, get cluster by id %v occur error: %s", envModel.Cluster, err.Error())
+	if err := pm.deployApps(envModel, applyApps, publishID); err != nil {
 		return 0, "", err
 	}
 
-	err = kuberes.TriggerApplicationCreate(clusterModel.Name, envModel.Namespace, templateStr, projectID, stageJSON.StageID, true)
+	publishJobID, err := pm.CreatePublishJob(projectID, publishID, stageJSON.StageID, creator, "deploy", appsParamsForJob)
 	if err != nil {
-		log.Log.Error("when crate deploy job, trigger application create occur error: %s", err.Error())
 		return 0, "", err
 	}
-
-	appsParamsHealth := []*AppParamsForHealthCheck{}
-	for _, param := range appsAllParams {
-		item := &AppParamsForHealthCheck{
-			Name:     param.Name,
-			ID:       param.ProjectAppID,
-			FullName: param.FullName,
-			Type:     "app",
+	if lastWave > 1 {
+		// the wave is recorded before the job running, which is when health check starts
+		if err := pm.startDeployWave(publishJobID); err != nil {
+			return 0, "", err
 		}
-		appsParamsHealth = append(appsParamsHealth, item)
-	}
-	publishJobID, err := pm.CreatePublishJob(projectID, publishID, stageJSON.StageID, creator, "deploy", appsParamsForJob)
-	if err != nil {
-		return 0, "", err
 	}
 
-	healthCheckItems, err := pm.renderHealthCheckCommand(projectID, stageJSON.StageID, publishJobID, appsParamsHealth, stageJSON)
+	// there is no external run for deploy job, use publish job id as run id
+	runID := publishJobID
+	// Update runID/status to publishjob
+	err = pm.UpdatePublishJob(publishJobID, runID)
 	if err != nil {
 		return 0, "", err
 	}
+	return runID, jobName, nil
+}
 
-	callBackURL := fmt.Sprintf("%s/atomci/api/v1/pipelines/%d/publishes/%d/stages/%d/steps/%s/callback", atomciServer, projectID, publishID, stageJSON.StageID, "deploy")
-	callBackRequestBody := fmt.Sprintf("{\"publish_job_id\": %d}", publishJobID)
-	// TODO: Use pm.getAppConfig() get all config
-	adminToken, err := pm.getUserToken("admin")
-	if err != nil {
-		log.Log.Error("get admin token occur error: %v", err.Error())
-		return 0, "", fmt.Errorf("网络错误，请重试")
-	}
-	userToken, err := pm.getUserToken(creator)
-	if err != nil {
-		log.Log.Error("get %v token occur error: %v", creator, err.Error())
-		return 0, "", fmt.Errorf("网络错误，请重试")
-	}
-
-	envVars := []jenkins.EnvItem{
-		{Key: "JENKINS_SLAVE_WORKSPACE", Value: CIInfo[3]},
-		{Key: "ATOMCI_SERVER", Value: atomciServer},
-		{Key: "ACCESS_TOKEN", Value: adminToken},
-		{Key: "USER_TOKEN", Value: userToken},
+// deployApps apply the arrange of apps to env, or commit them to git config repo if env deployed by argo cd
+func (pm *PipelineManager) deployApps(envModel *models.ProjectEnv, apps []*RunDeployAppReq, publishID int64) error {
+	if envModel.ArgoCD != 0 {
+		// gitops, argo cd apply the arrange committed to git config repo
+		templates, err := pm.renderAppTemplates(apps, publishID, envModel.ID, false)
+		if err != nil {
+			return err
+		}
+		if err := pm.deployByArgoCD(envModel, publishID, templates); err != nil {
+			log.Log.Error("deploy publish: %v by argocd occur error: %s", publishID, err.Error())
+			return err
+		}
+		return nil
 	}
-
-	jenkinsJNLPTemplate, err := pm.getSysDefaultCompileEnv(constant.DefaultContainerName)
+	// deploy app, combine app arrange to temmplateStr, the resources applied directly are labeled with their owner
+	templateStr, err := pm.renderTemplateStr(apps, publishID, envModel.ID, true)
 	if err != nil {
-		log.Log.Error("when create deploy job, get sys default %v compile env error: %s", constant.DefaultContainerName, err.Error())
-		return 0, "", err
-	}
-
-	// default container template
-	containerTemplates := []jenkins.ContainerEnv{
-		jenkinsJNLPTemplate,
+		return err
 	}
+	return pm.applyDeployTemplate(envModel, envModel.ProjectID, templateStr)
+}
 
-	flowProcessor := &jenkins.DeployContext{
-		HealthCheckItems:   healthCheckItems,
-		EnvVars:            envVars,
-		ContainerTemplates: containerTemplates,
-		CallBack: jenkins.CallbackRequest{
-			Token: adminToken,
-			URL:   callBackURL,
-			Body:  callBackRequestBody,
-		},
-		CommonContext: jenkins.CommonContext{
-			Namespace: CIInfo[4],
-		},
-	}
+// appTemplate the rendered arrange of project app
+type appTemplate struct {
+	ProjectAppID int64
+	Content      string
+}
 
-	workerflowClient, err := NewWorkFlowProvide(workflow.DriverJenkins.String(), addr, user, token, jobName, flowProcessor)
+func (pm *PipelineManager) renderTemplateStr(apps []*RunDeployAppReq, publishID, envID int64, ownerLabels bool) (string, error) {
+	templates, err := pm.renderAppTemplates(apps, publishID, envID, ownerLabels)
 	if err != nil {
-		return 0, "", err
-	}
-	runID, err := workerflowClient.Build()
-	if err != nil {
-		return 0, "", err
+		return "", err
 	}
-	// Update runID/status to publishjob
-	err = pm.UpdatePublishJob(publishJobID, runID)
-	if err != nil {
-		return 0, "", err
+	var templateStr string
+	for _, item := range templates {
+		if templateStr == "" {
+			templateStr = item.Content
+		} else {
+			templateStr = templateStr + "\n---\n" + item.Content
+		}
 	}
-	return runID, jobName, nil
+	return templateStr, nil
 }
-func (pm *PipelineManager) renderTemplateStr(apps []*RunDeployAppReq, publishID, envID int64) (string, error) {
-	var templateStr string
+
+// renderAppTemplates render the real arrange of apps, the apps without arrange or image are skipped
+func (pm *PipelineManager) renderAppTemplates(apps []*RunDeployAppReq, publishID, envID int64, ownerLabels bool) ([]*appTemplate, error) {
+	templates := []*appTemplate{}
 	for _, item := range apps {
 		arrange, err := pm.appHandler.GetRealArrange(item.ProjectAppID, envID)
 		if err != nil {
@@ -584,26 +705,33 @@ func (pm *PipelineManager) renderTemplateStr(apps []*RunDeployAppReq, publishID,
 			continue
 		}
 
-		// replace template str
-		arrangeConfig := arrange.Config
 		publishApp, err := pm.modelPublish.GetPublishAppByPublishIDAndAppID(publishID, item.ProjectAppID)
 		if err != nil {
 			logs.Warn("when get publish app by publishid/appid occur error:%s, did not update app arrange image info", err.Error())
 			continue
 		}
 
-		newImageAddr, originImage, err := pm.generateImageAddr(arrange.ID, item.ProjectAppID, publishApp.BranchName)
+		newImageAddr, originImage, err := pm.deployImageAddr(item, arrange.ID, publishApp)
 		if err != nil {
 			continue
 		}
+		// render the arrange template with env/app variables, then replace template str
+		arrangeConfig, err := pm.appHandler.RenderRealArrange(arrange, newImageAddr, publishApp.BranchName)
+		if err != nil {
+			log.Log.Error("render app id: %v env id: %v arrange occur error: %s", item.ProjectAppID, envID, err.Error())
+			return nil, fmt.Errorf("应用编排渲染失败: %s", err.Error())
+		}
 		arrangeConfig = strings.Replace(arrangeConfig, originImage, newImageAddr, -1)
-		if templateStr == "" {
-			templateStr = arrangeConfig
-		} else {
-			templateStr = templateStr + "\n---\n" + arrangeConfig
+		if ownerLabels {
+			arrangeConfig, err = kuberes.LabelOwnedResources(arrangeConfig, item.ProjectAppID, envID)
+			if err != nil {
+				log.Log.Error("label app id: %v env id: %v arrange occur error: %s", item.ProjectAppID, envID, err.Error())
+				return nil, fmt.Errorf("应用编排解析失败: %s", err.Error())
+			}
 		}
+		templates = append(templates, &appTemplate{ProjectAppID: item.ProjectAppID, Content: arrangeConfig})
 	}
-	return templateStr, nil
+	return templates, nil
 }
 
 func (pm *PipelineManager) generateImageAddr(arrangeID, projectAppID int64, branch string) (string, string, error) {
@@ -612,6 +740,11 @@ func (pm *PipelineManager) generateImageAddr(arrangeID, projectAppID int64, bran
 		log.Log.Error("get imagemapping error: %s", err.Error())
 		return "", "", err
 	}
+	return pm.imageAddrOfMapping(imageMapping, projectAppID, branch)
+}
+
+// imageAddrOfMapping return the image to build of app and the origin image of image mapping
+func (pm *PipelineManager) imageAddrOfMapping(imageMapping *models.AppImageMapping, projectAppID int64, branch string) (string, string, error) {
 	newImageAddr := imageMapping.Image
 	switch imageMapping.ImageTagType {
 	case models.SystemDefaultTag:
@@ -655,48 +788,122 @@ func removeImageUrlTag(imageUrl string) (string, error) {
 	}
 	real := imageUrl[0:strings.LastIndex(imageUrl, ":")]
 	return real, nil
-
-	return "", nil
 }
 
+// GetAppCodeCommitByBranch return the image tag `branch-commit` of the latest commit of app branch,
+// the lookup is cached in the publish job rendered by manager of withCommitCache
 func (pm *PipelineManager) GetAppCodeCommitByBranch(appID int64, branchName string) (string, error) {
+	if pm.commits != nil {
+		return pm.commits.get(appID, branchName, func() (string, error) {
+			return pm.getAppCodeCommitByBranch(appID, branchName)
+		})
+	}
+	return pm.getAppCodeCommitByBranch(appID, branchName)
+}
+
+func (pm *PipelineManager) getAppCodeCommitByBranch(appID int64, branchName string) (string, error) {
+	got, scmApp, err := pm.ListAppCommits(appID, branchName, 10)
+	if err != nil {
+		return "", err
+	}
+
+	if len(got) > 0 {
+		return branchName + "-" + got[0].Sha[0:7], nil
+	} else {
+		logs.Warn("branch: %v did not include any commit", branchName)
+		return "", fmt.Errorf("应用:%v 分支:%v 未包含任何提交, 请通过“我的应用”-“应用详情”-“同步远程分支”后重新选择", scmApp.Name, branchName)
+	}
+}
+
+// getAppScmClient return the scm client of the repo which project app belongs to
+func (pm *PipelineManager) getAppScmClient(appID int64) (*scm.Client, *models.ScmApp, error) {
 	projectApp, err := pm.modelProject.GetProjectApp(appID)
 	if err != nil {
 		log.Log.Error("when get app code commit, get project ap by id: %v error:%s", appID, err.Error())
-		return "", err
+		return nil, nil, err
 	}
 
 	scmApp, err := pm.modelApp.GetScmAppByID(projectApp.ScmID)
 	if err != nil {
 		log.Log.Error("when get app code commit, get scm ap by id: %v error:%s", appID, err.Error())
-		return "", err
+		return nil, nil, err
 	}
 
 	scmIntegrateResp, err := pm.settingsHandler.GetSCMIntegrateSettinByID(scmApp.RepoID)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	client, err := apps.NewScmProvider(scmIntegrateResp.Type, scmIntegrateResp.URL, scmIntegrateResp.Token)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	return client, scmApp, nil
+}
+
+// ListAppCommits return the latest commits of the project app branch
+func (pm *PipelineManager) ListAppCommits(appID int64, branchName string, size int) ([]*scm.Commit, *models.ScmApp, error) {
+	client, scmApp, err := pm.getAppScmClient(appID)
+	if err != nil {
+		return nil, nil, err
 	}
 	opt := scm.CommitListOptions{
 		Ref:  branchName,
 		Page: 1,
-		Size: 10,
+		Size: size,
 	}
 
-	got, _, err := client.Git.ListCommits(context.Background(), scmApp.FullName, opt)
+	got, err := apps.ListCommits(context.Background(), client, scmApp.FullName, opt)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
+	return got, scmApp, nil
+}
 
-	if len(got) > 0 {
-		return branchName + "-" + got[0].Sha[0:7], nil
-	} else {
-		logs.Warn("branch: %v did not include any commit", branchName)
-		return "", fmt.Errorf("应用:%v 分支:%v 未包含任何提交, 请通过“我的应用”-“应用详情”-“同步远程分支”后重新选择", scmApp.Name, branchName)
+// IsAppBranchMerged check the head of branch was merged into target branch, the branch is regarded as merged
+// when its head commit is in the latest commits of target, or a merged pull request of the head exists(squash merge)
+func (pm *PipelineManager) IsAppBranchMerged(appID int64, branchName, targetBranch string) (bool, error) {
+	if branchName == targetBranch {
+		return true, nil
+	}
+	client, scmApp, err := pm.getAppScmClient(appID)
+	if err != nil {
+		return false, err
+	}
+	ctx := context.Background()
+	branch, _, err := client.Git.FindBranch(ctx, scmApp.FullName, branchName)
+	if err != nil {
+		return false, fmt.Errorf("get app: %v branch: %v occur error: %s", scmApp.Name, branchName, err.Error())
+	}
+
+	targetCommits, err := apps.ListCommits(ctx, client, scmApp.FullName, scm.CommitListOptions{
+		Ref:  targetBranch,
+		Page: 1,
+		Size: mergeCheckMaxCommits,
+	})
+	if err != nil {
+		return false, fmt.Errorf("list app: %v branch: %v commits occur error: %s", scmApp.Name, targetBranch, err.Error())
 	}
+	for _, commit := range targetCommits {
+		if commit.Sha == branch.Sha {
+			return true, nil
+		}
+	}
+
+	pullRequests, _, err := client.PullRequests.List(ctx, scmApp.FullName, scm.PullRequestListOptions{
+		Page:   1,
+		Size:   mergeCheckMaxCommits,
+		Closed: true,
+	})
+	if err != nil {
+		log.Log.Warn("list app: %v pull requests occur error: %s", scmApp.Name, err.Error())
+		return false, nil
+	}
+	for _, pr := range pullRequests {
+		if pr.Merged && pr.Source == branchName && pr.Target == targetBranch && pr.Sha == branch.Sha {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // Pipeline Operation:: publish step, get branch list for publish
@@ -704,6 +911,7 @@ func (pm *PipelineManager) getPublishStepPreBranchList(projectID, publishID, sta
 	targetBranch := []string{"master"}
 	publishApps, err := pm.modelPublish.GetPublishAppsByID(publishID)
 
+	builtVersions := pm.lastBuiltVersions(publishID)
 	publishStepResp := []*PublishStepResp{}
 	for _, app := range publishApps {
 		projectApp, _ := pm.modelProject.GetProjectApp(app.ProjectAppID)
@@ -721,7 +929,7 @@ func (pm *PipelineManager) getPublishStepPreBranchList(projectID, publishID, sta
 			branchItems = []string{"master"}
 		}
 		appInfo := &PublishStepResp{
-			BranchName:        app.BranchName,
+			BranchName:        PublishAppBuildBranch(app),
 			AppName:           scmApp.Name,
 			Language:          scmApp.Language,
 			ProjectAppID:      app.ProjectAppID,
@@ -730,6 +938,7 @@ func (pm *PipelineManager) getPublishStepPreBranchList(projectID, publishID, sta
 			TargetBranch:      targetBranch,
 			CompileCommand:    app.CompileCommand,
 			BranchHistoryList: branchItems,
+			BuiltVersion:      builtVersions[app.ProjectAppID],
 		}
 		publishStepResp = append(publishStepResp, appInfo)
 	}
@@ -757,28 +966,36 @@ func (pm *PipelineManager) publishTerminatePublish(projectID, publishID, stageID
 		return fmt.Errorf("publish Order current status is not allowed terminate, operation reject")
 	}
 
-	CIInfo, err := pm.GetCIConfig(stageID)
+	latestPublishJob, err := pm.modelPublishJob.GetLastPublishJobByPublishID(publishID)
 	if err != nil {
-		log.Log.Error("getCIConfig occur error: %s", err.Error())
 		return err
 	}
-	addr, user, token := CIInfo[0], CIInfo[1], CIInfo[2]
 
 	var jobName string
 	switch jobType {
 	case "build":
-		jobName = fmt.Sprintf("atomci_%v_%v_%v", projectID, publishID, stageID)
+		jobName = BuildJobName(projectID, publishID, stageID)
+	case models.JobTypeE2ETest:
+		jobName = E2ETestJobName(projectID, publishID, stageID)
 	case "deploy":
-		jobName = fmt.Sprintf("atomci_%v_%v", projectID, stageID)
+		// deploy job run inside atomci, abort it means stop the health check
+		return pm.updatePublishJob(latestPublishJob, models.StatusAbort)
 	default:
 		log.Log.Error("jobType: %s is noexception", jobType)
 		return fmt.Errorf("不支持此任务类型: %v 的终止", jobType)
 	}
-	workerflowClient, err := NewWorkFlowProvide(workflow.DriverJenkins.String(), addr, user, token, jobName, nil)
+
+	CIInfo, err := pm.GetJobCIConfig(latestPublishJob)
 	if err != nil {
+		log.Log.Error("getCIConfig occur error: %s", err.Error())
 		return err
 	}
-	latestPublishJob, err := pm.modelPublishJob.GetLastPublishJobByPublishID(publishID)
+	if err := verifyDriverAbort(CIInfo.Driver); err != nil {
+		return err
+	}
+	addr, user, token := CIInfo.URL, CIInfo.User, CIInfo.Token
+
+	workerflowClient, err := NewWorkFlowProvide(CIInfo.Driver, addr, user, token, jobName, nil)
 	if err != nil {
 		return err
 	}
@@ -921,6 +1138,8 @@ func (pm *PipelineManager) updatePublishJob(publishJob *models.PublishJob, statu
 	if err := pm.modelPublishJob.UpdatePublishJob(publishJob); err != nil {
 		return err
 	}
+	ObserveJobFinished(publishJob)
+	events.EmitJobStatus(publishJob)
 	return nil
 }
 
@@ -928,14 +1147,19 @@ func (pm *PipelineManager) updatePublishJob(publishJob *models.PublishJob, statu
 
 func (pm *PipelineManager) aggregateAppsParamsForBuild(apps []*RunBuildAppReq, stageJSON *PipelineStageStruct) ([]*RunBuildAllParms, error) {
 	allParms := []*RunBuildAllParms{}
+	appIDs := []int64{}
 	for _, app := range apps {
-		projectApp, err := pm.modelProject.GetProjectApp(app.ProjectAppID)
-		if err != nil {
-			log.Log.Error("get proejct modelapp occur error: %s", err)
-		}
-		scmApp, err := pm.modelApp.GetScmAppByID(projectApp.ScmID)
+		appIDs = append(appIDs, app.ProjectAppID)
+	}
+	records, err := pm.fetchAppRecords(0, 0, appIDs)
+	if err != nil {
+		log.Log.Error("get apps: %v occur error: %s", appIDs, err.Error())
+		return nil, err
+	}
+	for _, app := range apps {
+		projectApp, scmApp, err := records.projectApp(app.ProjectAppID)
 		if err != nil {
-			logs.Warn("get scm app error: %s", err.Error())
+			logs.Warn("get app: %v scm app error: %s", app.ProjectAppID, err.Error())
 			continue
 		}
 		releaseBranch := "None"
@@ -952,50 +1176,71 @@ func (pm *PipelineManager) aggregateAppsParamsForBuild(apps []*RunBuildAppReq, s
 }
 
 func (pm *PipelineManager) aggregateAppsParamsForDeploy(publishID, stageID int64, apps []*RunDeployAppReq, stageJSON *PipelineStageStruct) ([]*RunDeployAllParms, error) {
-
-	allParms := []*RunDeployAllParms{}
+	appIDs := []int64{}
 	for _, app := range apps {
-		projectApp, err := pm.modelProject.GetProjectApp(app.ProjectAppID)
-		if err != nil {
-			log.Log.Error("get gitmodelapp occur error: %s", err)
-		}
+		appIDs = append(appIDs, app.ProjectAppID)
+	}
+	records, err := pm.fetchAppRecords(publishID, stageID, appIDs)
+	if err != nil {
+		log.Log.Error("get publish: %v apps: %v occur error: %s", publishID, appIDs, err.Error())
+		return nil, err
+	}
 
-		scmApp, err := pm.modelApp.GetScmAppByID(projectApp.ScmID)
+	// the image of app may query the latest commit from scm, generate them concurrently and keep the order of apps
+	params := make([]*RunDeployAllParms, len(apps))
+	forEachLimit(len(apps), appLookupConcurrency, func(i int) {
+		app := apps[i]
+		projectApp, scmApp, err := records.projectApp(app.ProjectAppID)
 		if err != nil {
-			log.Log.Error("get scmapp error: %s", err.Error())
+			log.Log.Error("get app: %v scm app occur error: %s", app.ProjectAppID, err.Error())
+			if projectApp == nil {
+				projectApp = &models.ProjectApp{}
+			}
+			scmApp = &models.ScmApp{}
 		}
-		arrange, err := pm.appHandler.GetRealArrange(app.ProjectAppID, stageID)
+		arrange, err := records.realArrange(app.ProjectAppID, stageID)
 		if err != nil {
 			log.Log.Error("get app id: %v  env id: %v real arrange, occur error: %s", app.ProjectAppID, stageID, err.Error())
-			continue
+			return
 		}
-
-		publishApp, err := pm.modelPublish.GetPublishAppByPublishIDAndAppID(publishID, app.ProjectAppID)
+		publishApp, err := records.publishApp(app.ProjectAppID)
 		if err != nil {
 			logs.Warn("when get publish app by publishid/appid occur error:%s, did not update app arrange image info", err.Error())
-			continue
+			return
 		}
-
-		newImageAddr, _, err := pm.generateImageAddr(arrange.ID, app.ProjectAppID, publishApp.BranchName)
+		imageMapping, err := records.imageMapping(arrange.ID, app.ProjectAppID)
 		if err != nil {
-			continue
+			log.Log.Error("get app: %v imagemapping error: %s", app.ProjectAppID, err.Error())
+			return
+		}
+		newImageAddr, _, err := pm.deployImageAddrOfMapping(app, imageMapping, publishApp)
+		if err != nil {
+			log.Log.Error("generate app: %v deploy image occur error: %s", app.ProjectAppID, err.Error())
+			return
 		}
 		log.Log.Debug("imageAddr: %s", newImageAddr)
-		allParm := &RunDeployAllParms{
+		params[i] = &RunDeployAllParms{
 			ProjectID:       projectApp.ProjectID,
 			ScmApp:          scmApp,
 			RunDeployAppReq: app,
 			ImageAddr:       newImageAddr,
+			ArrangeRevision: arrange.Revision,
+		}
+	})
+
+	allParms := []*RunDeployAllParms{}
+	for _, item := range params {
+		if item != nil {
+			allParms = append(allParms, item)
 		}
-		allParms = append(allParms, allParm)
 	}
 	return allParms, nil
 }
 
 /*  auto Trigger part start */
 
-// AutoTrigger Deploy
-func (pm *PipelineManager) generateAutoDeployStep(publishID int64) (*DeployStepReq, error) {
+// GenerateDeployStepReq deploy all the apps of publish, used by auto trigger and batch trigger
+func (pm *PipelineManager) GenerateDeployStepReq(publishID int64) (*DeployStepReq, error) {
 	// TODO: 应该基于 publishID 索引到最新的一个 publishjob, then get according publishjobapps, 而不是直接根据 publish app 来部署；
 	publishApps, err := pm.modelPublish.GetPublishAppsByID(publishID)
 	if err != nil {
@@ -1016,92 +1261,239 @@ func (pm *PipelineManager) generateAutoDeployStep(publishID int64) (*DeployStepR
 	return params, nil
 }
 
+// GenerateBuildStepReq build all the apps of publish with the branch and compile command selected when create publish
+func (pm *PipelineManager) GenerateBuildStepReq(publishID int64) (*BuildStepReq, error) {
+	publishApps, err := pm.modelPublish.GetPublishAppsByID(publishID)
+	if err != nil {
+		log.Log.Error("when generate build step, GetPublishAppsByID occur error: %s", err.Error())
+		return nil, err
+	}
+	apps := []*RunBuildAppReq{}
+	for _, app := range publishApps {
+		apps = append(apps, &RunBuildAppReq{
+			ProjectAppID:   app.ProjectAppID,
+			Branch:         PublishAppBuildBranch(app),
+			CompileCommand: app.CompileCommand,
+		})
+	}
+	return &BuildStepReq{
+		ActionName: "trigger",
+		Apps:       apps,
+	}, nil
+}
+
 /*  auto Trigger part end */
 
-func (pm *PipelineManager) generateBaseInfo(projectID, stageID, publishJobID int64) (string, string) {
-	scriptsDir := "/home/admin/scripts_dev"
-	scriptBaseInfo := fmt.Sprintf(" --project-id %d --stage-id %d --publish-job-id %d ", projectID, stageID, publishJobID)
-	return scriptsDir, scriptBaseInfo
+func (pm *PipelineManager) generateAppRepoPth(stageID, projectID int64, workSpace string, appArgs *RunBuildAllParms) string {
+	appRepoPath := strings.Join([]string{workSpace, strconv.Itoa(int(projectID)), strconv.Itoa(int(stageID)), appArgs.Name, appArgs.Branch}, "/")
+	return strings.ReplaceAll(appRepoPath, "//", "/")
+}
+
+// shellQuote quote the argument of sh command by single quotes
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// validBranchName check the branch name by the rules of `git check-ref-format --branch`, the branch is
+// a part of the workspace path which the build commands run in, so the shell special characters are rejected too
+func validBranchName(branch string) bool {
+	if branch == "" || branch == "@" || strings.HasPrefix(branch, "-") || strings.HasPrefix(branch, "/") ||
+		strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".") ||
+		strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.Contains(branch, "@{") {
+		return false
+	}
+	for _, c := range branch {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\\"'`$;&|<>(){}!#", c) {
+			return false
+		}
+	}
+	for _, component := range strings.Split(branch, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// scmCredentialEnvKey return the jenkins env key which store the clone credential of scm integrate setting
+func scmCredentialEnvKey(repoID int64) string {
+	return fmt.Sprintf("SCM_CREDENTIAL_%d", repoID)
 }
-func (pm *PipelineManager) generateAppPth(stageID, projectID int64, workSpace string, appArgs *RunBuildAllParms) string {
-	appPath := strings.Join([]string{workSpace, strconv.Itoa(int(projectID)), strconv.Itoa(int(stageID)), appArgs.Name, appArgs.Branch, appArgs.BuildPath}, "/")
-	return strings.ReplaceAll(appPath, "//", "/")
+
+// generateSCMCredentialEnvVars return the clone credentials of all scm repos used by apps,
+// the checkout commands refer them by env key, so the token did not appear in the pipeline steps
+func (pm *PipelineManager) generateSCMCredentialEnvVars(allParms []*RunBuildAllParms) ([]jenkins.EnvItem, error) {
+	envVars := []jenkins.EnvItem{}
+	repos := map[int64]bool{}
+	for _, app := range allParms {
+		if repos[app.RepoID] {
+			continue
+		}
+		scmIntegrateResp, err := pm.settingsHandler.GetSCMIntegrateSettinByID(app.RepoID)
+		if err != nil {
+			log.Log.Error("get scm integrate setting by id: %v error: %s", app.RepoID, err.Error())
+			return nil, err
+		}
+		user, token := scmIntegrateResp.User, scmIntegrateResp.Token
+		if basicUser, password, ok := apps.SplitBasicToken(scmIntegrateResp.Type, token); ok {
+			user, token = basicUser, password
+		}
+		if user == "" {
+			user = "oauth2"
+		}
+		envVars = append(envVars, jenkins.EnvItem{
+			Key:   scmCredentialEnvKey(app.RepoID),
+			Value: url.UserPassword(user, token).String(),
+		})
+		repos[app.RepoID] = true
+	}
+	return envVars, nil
 }
 
 // Rendering parameters for app checkout items's command
-func (pm *PipelineManager) renderAppCheckoutItemsForBuild(projectID, stageID, publishJobID int64, allParms []*RunBuildAllParms) ([]jenkins.StepItem, error) {
+// the checkout runs by powershell in the jnlp container of windows build pod
+func (pm *PipelineManager) renderAppCheckoutItemsForBuild(projectID, stageID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, shell string) ([]jenkins.StepItem, error) {
 	appCheckoutItems := []jenkins.StepItem{}
 
-	scriptsDir, buildBaseInfo := pm.generateBaseInfo(projectID, stageID, publishJobID)
 	for _, app := range allParms {
 		// TODO: if GitAPP type is not app, how to deal with this, skip ??
 		item := jenkins.StepItem{}
 		item.Name = app.Name
 
-		// TODO: app build vcsType use git
-		appInfoStr := fmt.Sprintf(" --scm-app-id %d --app-name %s --app-language %s --branch-url %s --vcs-type %s --build-path %s ", app.ProjectAppID, app.Name, app.Language, app.Path, "git", app.BuildPath)
-		appParms := fmt.Sprintf(" --branch-name %s ", app.Branch)
-		Command := fmt.Sprintf("sh 'python3 %s/app_checkout.py %s %s %s'", scriptsDir, buildBaseInfo, appInfoStr, appParms)
-		item.Command = Command
+		// TODO: only support http(s) clone url, do not support git@gitlab.com:/dddd.git
+		repoURL, err := url.Parse(app.Path)
+		if err != nil || repoURL.Host == "" {
+			log.Log.Error("app: %v repo path: %v is invalid", app.Name, app.Path)
+			return nil, fmt.Errorf("应用 %v 代码仓库地址 %v 无效，仅支持 http(s) 地址", app.Name, app.Path)
+		}
+		if !validBranchName(app.Branch) {
+			log.Log.Error("app: %v branch: %v is invalid", app.Name, app.Branch)
+			return nil, fmt.Errorf("应用 %v 分支名称 %v 无效", app.Name, app.Branch)
+		}
+		appRepoPath := pm.generateAppRepoPth(stageID, projectID, ciConfig.Workspace, app)
+		if shell == models.CompileEnvShellPowershell {
+			item.Command = windowsCheckoutCommand(repoURL, app.RepoID, app.Branch, appRepoPath)
+			appCheckoutItems = append(appCheckoutItems, item)
+			continue
+		}
+		// only the clone credential is expanded by shell, the other arguments are quoted
+		cloneURL := fmt.Sprintf("%s://\"${%s}\"@%s", repoURL.Scheme, scmCredentialEnvKey(app.RepoID), shellQuote(repoURL.Host+repoURL.EscapedPath()))
+		repoPath := shellQuote(appRepoPath)
+		// set +x, avoid the clone credential was printed in the build log
+		script := fmt.Sprintf("set +x; rm -rf %v; git clone --depth 1 -b %v %v %v; cd %v; git log -1 --oneline", repoPath, shellQuote(app.Branch), cloneURL, repoPath, repoPath)
+		item.Command = fmt.Sprintf("sh '%s'", groovyEscape(script))
 		appCheckoutItems = append(appCheckoutItems, item)
 	}
 
 	return appCheckoutItems, nil
 }
 
-// Rendering parameters for app build items's command
-func (pm *PipelineManager) renderAppBuildItemsForBuild(projectID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig []string) ([]*jenkins.StepItem, error) {
+// Rendering parameters for app build items's command, the shells of containers render the commands
+func (pm *PipelineManager) renderAppBuildItemsForBuild(projectID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, matrix *buildMatrix, shells map[string]string) ([]*jenkins.StepItem, error) {
 	appBuildItems := []*jenkins.StepItem{}
 
 	for _, app := range allParms {
-		item := &jenkins.StepItem{}
-		item.Name = app.Name
-		// Default containername is constant.DefaultContainerName(jnlp)
-		item.ContainerName = constant.DefaultContainerName
-		command := fmt.Sprintf("sh 'echo app:%v language:%v, did not defined compile command, skip compile'", app.Name, app.Language)
-		customCompileCommand := app.RunBuildAppReq.CompileCommand
-
-		appPath := pm.generateAppPth(stageID, projectID, ciConfig[3], app)
-		appRootPath := appPath
-		if app.CompileEnvID == 0 {
-			command = fmt.Sprintf("sh 'echo app:%v language:%v, did not setup compile env,skip compile...'", app.Name, app.Language)
-		} else if len(customCompileCommand) > 0 {
-			item.ContainerName = strings.ToLower(app.Name)
-			command = fmt.Sprintf("sh 'cd %v; %v'", appRootPath, customCompileCommand)
+		customCompileCommand, err := pm.resolveCompileCommand(app)
+		if err != nil {
+			return nil, fmt.Errorf("应用 %v 编译命令无效: %v", app.Name, err)
+		}
+		for _, cell := range pm.matrixCells(matrix, app) {
+			item := &jenkins.StepItem{}
+			item.Name = app.Name
+			if cell.suffix() != "" {
+				item.Name = fmt.Sprintf("%s-%s", app.Name, cell.suffix())
+			}
+			// Default containername is constant.DefaultContainerName(jnlp)
+			item.ContainerName = constant.DefaultContainerName
+			script := fmt.Sprintf("echo app:%v language:%v, did not defined compile command, skip compile", app.Name, app.Language)
+
+			appRootPath := pm.matrixAppPath(stageID, projectID, ciConfig.Workspace, app, cell)
+			if cell.CompileEnvID == 0 {
+				script = fmt.Sprintf("echo app:%v language:%v, did not setup compile env,skip compile...", app.Name, app.Language)
+			} else if len(customCompileCommand) > 0 {
+				item.ContainerName = cell.containerName(app.Name)
+				prepare := ""
+				if cell.suffix() != "" {
+					// build in the copy of app repo
+					repoPath := pm.generateAppRepoPth(stageID, projectID, ciConfig.Workspace, app)
+					cellRepoPath := pm.matrixRepoPath(stageID, projectID, ciConfig.Workspace, app, cell)
+					prepare = fmt.Sprintf("rm -rf %v; cp -r %v %v; ", cellRepoPath, repoPath, cellRepoPath)
+				}
+				if cell.Arch != "" {
+					prepare = fmt.Sprintf("%sexport TARGETARCH=%v GOARCH=%v; ", prepare, cell.Arch, cell.Arch)
+				}
+				shell := shells[item.ContainerName]
+				script = prepare + shellCommands(shell, changeDirCommand(shell, appRootPath), customCompileCommand)
+			}
+			item.Command = shellStep(shells[item.ContainerName], script)
+			appBuildItems = append(appBuildItems, item)
 		}
-		item.Command = command
-		appBuildItems = append(appBuildItems, item)
 	}
 
 	return appBuildItems, nil
 }
 
-// Rendering parameters for app images items's command
-func (pm *PipelineManager) renderAppImageitemsForBuild(projectID, publishID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig []string, deployInfo []string) ([]*jenkins.StepItem, error) {
-	appImageItems := []*jenkins.StepItem{}
-
-	if len(ciConfig) != 5 {
-		log.Log.Error("ciConfig is invalide, real len: %v", len(ciConfig))
+// resolveCompileCommand the compile command selected when trigger is used first, otherwise the compile command
+// of project app or the template of app language
+func (pm *PipelineManager) resolveCompileCommand(app *RunBuildAllParms) (string, error) {
+	if app.RunBuildAppReq.CompileCommand != "" {
+		return app.RunBuildAppReq.CompileCommand, nil
 	}
+	projectApp, err := pm.modelProject.GetProjectApp(app.ProjectAppID)
+	if err != nil {
+		log.Log.Warn("get project app: %v occur error: %s, use the compile command of language", app.ProjectAppID, err.Error())
+		projectApp = nil
+	}
+	return pm.settingsHandler.ResolveCompileCommand(projectApp, settings.CompileCommandData{
+		AppName:   app.Name,
+		Language:  app.Language,
+		BuildPath: app.BuildPath,
+		Branch:    app.Branch,
+	})
+}
 
-	if len(deployInfo) == 0 {
-		log.Log.Error("deployinfo is invalide, real len: %v", len(deployInfo))
-		return nil, fmt.Errorf("ciConfig is invalide, real len: %v", len(deployInfo))
-	}
+// buildImageAddrs generate the images to build of apps in env, the image of app which arrange or image mapping
+// is invalid is empty, the scm lookups of apps run concurrently
+func (pm *PipelineManager) buildImageAddrs(stageID int64, allParms []*RunBuildAllParms) ([]string, error) {
+	appIDs := []int64{}
 	for _, app := range allParms {
-		item := &jenkins.StepItem{}
-		item.Name = app.Name
-
-		appPath := pm.generateAppPth(stageID, projectID, ciConfig[3], app)
-
-		arrange, err := pm.appHandler.GetRealArrange(app.ProjectAppID, stageID)
+		appIDs = append(appIDs, app.ProjectAppID)
+	}
+	records, err := pm.fetchAppRecords(0, stageID, appIDs)
+	if err != nil {
+		log.Log.Error("get apps: %v env id: %v arranges occur error: %s", appIDs, stageID, err.Error())
+		return nil, err
+	}
+	imageURLs := make([]string, len(allParms))
+	forEachLimit(len(allParms), appLookupConcurrency, func(i int) {
+		app := allParms[i]
+		arrange, err := records.realArrange(app.ProjectAppID, stageID)
 		if err != nil {
 			log.Log.Error("get app id: %v  env id: %v real arrange, occur error: %s", app.ProjectAppID, stageID, err.Error())
-			continue
+			return
 		}
-
-		imageURL, _, err := pm.generateImageAddr(arrange.ID, app.ProjectAppID, app.Branch)
+		imageMapping, err := records.imageMapping(arrange.ID, app.ProjectAppID)
 		if err != nil {
+			log.Log.Error("get app: %v imagemapping error: %s", app.ProjectAppID, err.Error())
+			return
+		}
+		imageURLs[i], _, _ = pm.imageAddrOfMapping(imageMapping, app.ProjectAppID, app.Branch)
+	})
+	return imageURLs, nil
+}
+
+// Rendering parameters for app images items's command
+func (pm *PipelineManager) renderAppImageitemsForBuild(projectID, publishID, stageID, publishJobID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI, deployTarget *DeployTarget, matrix *buildMatrix) ([]*jenkins.StepItem, error) {
+	appImageItems := []*jenkins.StepItem{}
+
+	imageURLs, err := pm.buildImageAddrs(stageID, allParms)
+	if err != nil {
+		return nil, err
+	}
+	for i, app := range allParms {
+		imageURL := imageURLs[i]
+		if imageURL == "" || buildWithBuildpacks(app) {
+			// the image without dockerfile is built by the buildpacks stage
 			continue
 		}
 		dockerfile := app.Dockerfile
@@ -1109,85 +1501,81 @@ func (pm *PipelineManager) renderAppImageitemsForBuild(projectID, publishID, sta
 			dockerfile = "Dockerfile"
 		}
 		var insecure = ""
-		if isHttps, _ := strconv.ParseBool(deployInfo[3]); !isHttps {
+		if !deployTarget.RegistryHTTPS {
 			insecure = "--insecure --skip-tls-verify --insecure-pull"
 		}
-		Command := fmt.Sprintf("sh \"cd %v; export DOCKER_CONFIG=$DOCKER_CONFIG; /kaniko/executor -f %v -c ./  -d %v %s \"", appPath, dockerfile, imageURL, insecure)
-		item.Command = Command
-		appImageItems = append(appImageItems, item)
+
+		cells := pm.matrixCells(matrix, app)
+		if !matrix.multiArch() {
+			// the image build from the first compile env output
+			appPath := pm.matrixAppPath(stageID, projectID, ciConfig.Workspace, app, cells[0])
+			Command := fmt.Sprintf("sh \"cd %v; export DOCKER_CONFIG=$DOCKER_CONFIG; /kaniko/executor -f %v -c ./  -d %v %s \"", appPath, dockerfile, imageURL, insecure)
+			appImageItems = append(appImageItems, &jenkins.StepItem{Name: app.Name, Command: Command})
+			continue
+		}
+		// one image per arch, combined by the manifest stage
+		for _, cell := range cells[:len(matrix.Arch)] {
+			appPath := pm.matrixAppPath(stageID, projectID, ciConfig.Workspace, app, cell)
+			archImageURL := fmt.Sprintf("%s-%s", imageURL, sanitizeName(cell.Arch))
+			Command := fmt.Sprintf("sh \"cd %v; export DOCKER_CONFIG=$DOCKER_CONFIG; /kaniko/executor -f %v -c ./  -d %v --custom-platform=linux/%v %s \"", appPath, dockerfile, archImageURL, cell.Arch, insecure)
+			appImageItems = append(appImageItems, &jenkins.StepItem{Name: fmt.Sprintf("%s-%s", app.Name, sanitizeName(cell.Arch)), Command: Command})
+		}
 	}
 
 	return appImageItems, nil
 }
 
-// Rendering parameters for healthcheck command
-func (pm *PipelineManager) renderHealthCheckCommand(projectID, stageID, publishJobID int64, allParms []*AppParamsForHealthCheck, stageJSON *PipelineStageStruct) ([]*jenkins.StepItem, error) {
-	healthCheckItems := []*jenkins.StepItem{}
-	scriptsDir, buildBaseInfo := pm.generateBaseInfo(projectID, stageID, publishJobID)
+// GetCIConfig return the jenkins server of env with the active credential
+func (pm *PipelineManager) GetCIConfig(stageID int64) (*JenkinsCI, error) {
+	return pm.getCIConfig(stageID, 0, 0)
+}
+
+// GetJobCIConfig the ci config of the jenkins and the credential version which the job started with
+func (pm *PipelineManager) GetJobCIConfig(job *models.PublishJob) (*JenkinsCI, error) {
+	return pm.getCIConfig(job.EnvID, job.CIServer, job.CIVersion)
+}
 
-	envStage, err := pm.modelProject.GetProjectEnvByID(stageJSON.StageID)
+// ciCredentialVersion the active credential version of the ci server of env
+func (pm *PipelineManager) ciCredentialVersion(stageID int64) int {
+	projectEnv, err := pm.modelProject.GetProjectEnvByID(stageID)
 	if err != nil {
-		log.Log.Error("when render healthcheck command, get flow stage byid: %v, occur error: %s", stageJSON.StageID, err.Error())
-		return nil, fmt.Errorf("未能获取到指定的阶段")
+		log.Log.Warn("get project env: %v error: %s", stageID, err.Error())
+		return 0
 	}
-	for _, app := range allParms {
-		// skip GitAPP's type is not app
-		if app.Type != "app" {
-			logs.Info("app name: %s type: %s, is not app type, skip health check", app.Name, app.Type)
-			continue
-		}
-		item := &jenkins.StepItem{}
-		item.Name = app.Name
-
-		appArrange, err := pm.appHandler.GetRealArrange(app.ID, stageID)
-		if err != nil {
-			log.Log.Warn("get app id: %v, env id: %v occur error: %s", err.Error())
-			continue
-		}
-
-		native := &kuberes.NativeTemplate{
-			Template: appArrange.Config,
-		}
-		appResItems, err := native.GetAppResourceNames()
-		if err != nil {
-			log.Log.Warn("parse app arrange occur error: %s", err.Error())
-			continue
-		}
-
-		settingKubernetesItem, err := pm.settingsHandler.GetIntegrateSettingByID(envStage.Cluster)
-		if err != nil {
-			log.Log.Error("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
-			return nil, fmt.Errorf("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
-		}
-
-		for _, appRes := range appResItems {
-			svcName := appRes.Name
-			svcInfo := fmt.Sprintf(" --cluster %s --namespace %s --app-name %s --service-name %s", settingKubernetesItem.Name, envStage.Namespace, app.Name, svcName)
-			item.Command = fmt.Sprintf("sh 'python3 %s/healthcheck.py %s %s'", scriptsDir, buildBaseInfo, svcInfo)
-			healthCheckItems = append(healthCheckItems, item)
-		}
+	setting, err := pm.settingsHandler.GetIntegrateSettingByID(projectEnv.CIServer)
+	if err != nil {
+		log.Log.Warn("get ci server: %v error: %s", projectEnv.CIServer, err.Error())
+		return 0
 	}
-	return healthCheckItems, nil
+	return setting.CredentialVersion
 }
 
-// GetCIConfig ..
-func (pm *PipelineManager) GetCIConfig(stageID int64) ([]string, error) {
+// getCIConfig the ci config of env, ciServer 0 means the ci server of env
+func (pm *PipelineManager) getCIConfig(stageID, ciServer int64, version int) (*JenkinsCI, error) {
 	projectEnv, err := pm.modelProject.GetProjectEnvByID(stageID)
 	if err != nil {
 		log.Log.Error("when getCIConfig, GetProjectEnvByID %v occur error: %s", stageID, err.Error())
 		return nil, fmt.Errorf("未能找到到 id: %v 的配置，请联系管理员后重试", stageID)
 	}
-	CIServer := projectEnv.CIServer
+	if ciServer == 0 {
+		ciServer = projectEnv.CIServer
+	}
+	return pm.jenkinsCI(projectEnv, ciServer, version)
+}
+
+// jenkinsCI the ci config of the jenkins integrate setting for env
+func (pm *PipelineManager) jenkinsCI(projectEnv *models.ProjectEnv, CIServer int64, version int) (*JenkinsCI, error) {
 	log.Log.Debug("current CIServer integrate_setting id: %v", CIServer)
-	settingItem, err := pm.settingsHandler.GetIntegrateSettingByID(CIServer)
+	settingItem, err := pm.settingsHandler.GetIntegrateSettingByVersion(CIServer, version)
 	if err != nil {
 		log.Log.Error("when get ci config, get integrate setting by id: %v error: %s", CIServer, err.Error())
 		return nil, err
 	}
 	if settingItem.Type != "jenkins" {
-		return []string{}, fmt.Errorf("settings type is: %s, current ci server only support jenkins", settingItem.Type)
+		return nil, fmt.Errorf("settings type is: %s, current ci server only support jenkins", settingItem.Type)
 	}
 	var url, user, token, namespace, workSpace string
+	driver := workflow.DriverJenkins.String()
 	if jenkinsConfig, ok := settingItem.Config.(*settings.JenkinsConfig); ok {
 		url = jenkinsConfig.URL
 		user = jenkinsConfig.User
@@ -1197,54 +1585,77 @@ func (pm *PipelineManager) GetCIConfig(stageID int64) ([]string, error) {
 			namespace = "devops"
 		}
 		workSpace = jenkinsConfig.WorkSpace
+		if jenkinsConfig.Driver != "" {
+			driver = jenkinsConfig.Driver
+		}
 	} else {
 		log.Log.Error("parse jenkins config error")
-		return []string{}, fmt.Errorf("parse jenkins config error")
+		return nil, fmt.Errorf("parse jenkins config error")
+	}
+	// the build pods of env run in its own namespace
+	if projectEnv.BuildNamespace != "" {
+		namespace = projectEnv.BuildNamespace
 	}
 	log.Log.Debug("jenkins user: %v, url: %v, token: %v, workspace: %v", user, url, token, workSpace)
-	if url == "" || user == "" || token == "" || workSpace == "" {
+	// the runners connect to atomci instead
+	remote := driver != runnermgr.DriverName
+	if (remote && (url == "" || user == "" || token == "")) || workSpace == "" {
 		return nil, fmt.Errorf("请联系管理员确认 系统管理-服务集成 %v 的配置, 当前配置为: url: %v, user: %v, token: %v, workSpace: %v", settingItem.Name, url, user, token, workSpace)
 	}
-	return []string{url, user, token, workSpace, namespace}, nil
+	return &JenkinsCI{
+		URL:       url,
+		User:      user,
+		Token:     token,
+		Workspace: workSpace,
+		Namespace: namespace,
+		Driver:    driver,
+	}, nil
 }
 
-// getDeployInfo cluster,registry auth info,arrangeEnv
-func (pm *PipelineManager) getDeployInfo(stageID int64) ([]string, int64, error) {
+// GetDeployTarget return the cluster and registry of env
+func (pm *PipelineManager) GetDeployTarget(stageID int64) (*DeployTarget, error) {
 	envStage, err := pm.modelProject.GetProjectEnvByID(stageID)
 	if err != nil {
 		log.Log.Error("when get deploy info, get project env by id:%v, errror: %v", stageID, err.Error())
-		return nil, 0, err
+		return nil, err
 	}
 
 	settingKubernetesItem, err := pm.settingsHandler.GetIntegrateSettingByID(envStage.Cluster)
 	if err != nil {
 		log.Log.Error("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
-		return []string{}, 0, fmt.Errorf("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
+		return nil, fmt.Errorf("integrate setting cluster by id: %v error: %s", envStage.Cluster, err.Error())
 	}
 	if settingKubernetesItem.Type != "kubernetes" {
-		return []string{}, 0, fmt.Errorf("settings type is: %s, current deploy server only support kubernetes", settingKubernetesItem.Type)
+		return nil, fmt.Errorf("settings type is: %s, current deploy server only support kubernetes", settingKubernetesItem.Type)
 	}
 
 	settingRegistryItem, err := pm.settingsHandler.GetIntegrateSettingByID(envStage.Registry)
 	if err != nil {
 		log.Log.Error("integrate setting registry by id: %v error: %s", envStage.Registry, err.Error())
-		return []string{}, 0, fmt.Errorf("integrate setting registry by id: %v error: %s", envStage.Registry, err.Error())
+		return nil, fmt.Errorf("integrate setting registry by id: %v error: %s", envStage.Registry, err.Error())
 	}
 	if settingRegistryItem.Type != "registry" {
-		return []string{}, 0, fmt.Errorf("settings type is: %s, current deploy server only support kubernetes", settingRegistryItem.Type)
+		return nil, fmt.Errorf("settings type is: %s, current deploy server only support kubernetes", settingRegistryItem.Type)
 	}
 
-	var registryAddr, registryAuth string
-	var isHttps bool
+	target := &DeployTarget{
+		EnvID:   envStage.ID,
+		Cluster: settingKubernetesItem.Name,
+	}
 	if registryConf, ok := settingRegistryItem.Config.(*settings.RegistryConfig); ok {
-		registryAddr = registryConf.URL
-		registryAuth = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", registryConf.User, registryConf.Password)))
-		isHttps = registryConf.IsHttps
+		target.RegistryURL = registryConf.URL
+		registryUser, registryPassword, err := registryConf.Credential()
+		if err != nil {
+			log.Log.Error("get registry: %v credential occur error: %s", settingRegistryItem.Name, err.Error())
+			return nil, fmt.Errorf("get registry: %v credential occur error: %s", settingRegistryItem.Name, err.Error())
+		}
+		target.RegistryAuth = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", registryUser, registryPassword)))
+		target.RegistryHTTPS = registryConf.IsHttps
 	} else {
 		log.Log.Error("parse kubernetes config error")
-		return []string{}, 0, fmt.Errorf("parse jenkins config error")
+		return nil, fmt.Errorf("parse jenkins config error")
 	}
-	return []string{settingKubernetesItem.Name, registryAddr, registryAuth, strconv.FormatBool(isHttps)}, envStage.ID, nil
+	return target, nil
 }
 
 func (pm *PipelineManager) publishStepVerify(publishID int64, step string) (bool, error) {
@@ -1260,20 +1671,16 @@ func (pm *PipelineManager) publishStepVerify(publishID int64, step string) (bool
 }
 
 // CheckCurrentStepWhertherLastStageLastStep ..
-func (pm *PipelineManager) CheckCurrentStepWhertherLastStageLastStep(publishID, stageID int64) (bool, bool, error) {
+func (pm *PipelineManager) CheckCurrentStepWhertherLastStageLastStep(publishID, stageID int64, stepIndex int) (bool, bool, error) {
 	publishItem, _ := pm.modelPublish.GetPublishByID(publishID)
-	pipelineInstanceID, stepIndex := publishItem.LastPipelineInstanceID, publishItem.StepIndex
+	pipelineInstanceID := publishItem.LastPipelineInstanceID
 
-	var lastStage, lastStep bool
-	currentStageInstanceJSON, err := pm.GetPipelineInstanceEnvStageByID(pipelineInstanceID, stageID)
+	var lastStage bool
+	// the steps maybe parallel, the last step means all steps of the stage success
+	_, lastStep, err := pm.GetReadyStepIndexes(publishID, stageID, stepIndex)
 	if err != nil {
 		return false, false, fmt.Errorf("网络异常，请重试")
 	}
-	steps := currentStageInstanceJSON.Steps
-	lastStepIndex := steps[len(steps)-1:][0].Index
-	if lastStepIndex == stepIndex {
-		lastStep = true
-	}
 	pipelineStagesJSON, err := pm.GetPipelineInstanceJSONByID(pipelineInstanceID)
 	if err != nil {
 		return false, false, err
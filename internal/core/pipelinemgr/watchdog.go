@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// defaultStepTimeouts the timeout minutes of the job types, the deploy timeout covers its health check
+var defaultStepTimeouts = map[string]int{
+	models.JobTypeBuild:   30,
+	models.JobTypeDeploy:  15,
+	models.JobTypeE2ETest: 60,
+}
+
+// StepTimeout return the timeout of job, the timeout of pipeline step overrides watchdog::<job type>_timeout,
+// 0 means the job never timeout
+func (pm *PipelineManager) StepTimeout(job *models.PublishJob) time.Duration {
+	key := fmt.Sprintf("watchdog::%s_timeout", strings.Replace(job.JobType, "-", "_", -1))
+	minutes := beego.AppConfig.DefaultInt(key, defaultStepTimeouts[job.JobType])
+	if stepMinutes := pm.jobStepTimeout(job); stepMinutes > 0 {
+		minutes = stepMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// jobStepTimeout return the timeout minutes of the pipeline step of job, 0 when it is not set
+func (pm *PipelineManager) jobStepTimeout(job *models.PublishJob) int {
+	if job.StepIndex == 0 {
+		return 0
+	}
+	publishItem, err := pm.modelPublish.GetPublishByID(job.PublishID)
+	if err != nil {
+		return 0
+	}
+	stageJSON, err := pm.GetPipelineInstanceEnvStageByID(publishItem.LastPipelineInstanceID, job.EnvID)
+	if err != nil {
+		return 0
+	}
+	for _, step := range stageJSON.Steps {
+		if step.Index == job.StepIndex {
+			return step.Timeout
+		}
+	}
+	return 0
+}
+
+// AbortJobRun abort the jenkins run of job, the deploy job run inside atomci is stopped by its status
+func (pm *PipelineManager) AbortJobRun(job *models.PublishJob) error {
+	var jobName string
+	switch job.JobType {
+	case models.JobTypeBuild:
+		jobName = BuildJobName(job.ProjectID, job.PublishID, job.EnvID)
+	case models.JobTypeE2ETest:
+		jobName = E2ETestJobName(job.ProjectID, job.PublishID, job.EnvID)
+	default:
+		return nil
+	}
+	if job.RunID == 0 {
+		return nil
+	}
+	CIInfo, err := pm.GetJobCIConfig(job)
+	if err != nil {
+		return err
+	}
+	workerflowClient, err := NewWorkFlowProvide(CIInfo.Driver, CIInfo.URL, CIInfo.User, CIInfo.Token, jobName, nil)
+	if err != nil {
+		return err
+	}
+	return workerflowClient.Abort(job.RunID)
+}
+
+// TimeoutJob abort the job running longer than its timeout, the job is failed with the reason
+func (pm *PipelineManager) TimeoutJob(job *models.PublishJob, reason string) error {
+	if err := pm.AbortJobRun(job); err != nil {
+		// the job is failed anyway, otherwise the publish is left running forever
+		log.Log.Warn("abort timeout publish job: %v run: %v occur error: %s", job.ID, job.RunID, err.Error())
+	}
+	if err := pm.updatePublishJob(job, models.StatusFailure); err != nil {
+		return err
+	}
+	go pm.RecordJobStages(job, reason)
+	go pm.ReportJobCommitStatus(job)
+	return nil
+}
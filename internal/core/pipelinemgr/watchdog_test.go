@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestStepTimeout(t *testing.T) {
+	pm := &PipelineManager{}
+	tests := []struct {
+		jobType string
+		want    time.Duration
+	}{
+		{jobType: models.JobTypeBuild, want: 30 * time.Minute},
+		{jobType: models.JobTypeDeploy, want: 15 * time.Minute},
+		{jobType: models.JobTypeE2ETest, want: 60 * time.Minute},
+		{jobType: "unknown", want: 0},
+	}
+	for _, tt := range tests {
+		if got := pm.StepTimeout(&models.PublishJob{JobType: tt.jobType}); got != tt.want {
+			t.Errorf("expect %v job timeout %v, got %v", tt.jobType, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+// the working dir of the containers of agent pod
+const (
+	linuxAgentWorkingDir   = "/home/jenkins/agent"
+	windowsAgentWorkingDir = "C:/home/jenkins/agent"
+)
+
+// windowsDockerContainerName the container builds the images by the docker engine of windows node
+const windowsDockerContainerName = "docker"
+
+// windowsNodeSelector the build pod of windows compile envs is scheduled to the windows nodes
+var windowsNodeSelector = map[string]string{"kubernetes.io/os": models.CompileEnvOSWindows}
+
+// dockerEngineVolume the named pipe of the docker engine on windows node, kubelet accepts the forward slashes,
+// which need not to be escaped in the pipeline
+var dockerEngineVolume = AgentVolume{
+	Name:      "docker-engine",
+	Type:      AgentVolumeHostPath,
+	Source:    "//./pipe/docker_engine",
+	MountPath: "//./pipe/docker_engine",
+}
+
+// the sub tasks supported by windows build, the others run the linux tools
+var windowsSubTasks = map[string]bool{
+	constant.StepSubTaskCheckout:   true,
+	constant.StepSubTaskCompile:    true,
+	constant.StepSubTaskBuildImage: true,
+}
+
+// agentWorkingDir return the working dir of the containers on the os
+func agentWorkingDir(osType string) string {
+	if osType == models.CompileEnvOSWindows {
+		return windowsAgentWorkingDir
+	}
+	return linuxAgentWorkingDir
+}
+
+// buildOS return the os of build pod, all the compile envs of a build must run on the same os
+func buildOS(params []compileEnv) (string, error) {
+	osType := ""
+	for _, param := range params {
+		paramOS := param.OSType
+		if paramOS == "" {
+			paramOS = models.CompileEnvOSLinux
+		}
+		if osType != "" && paramOS != osType {
+			return "", fmt.Errorf("编译环境 %v 的操作系统 %v 与其它编译环境的操作系统 %v 不一致", param.Name, paramOS, osType)
+		}
+		osType = paramOS
+	}
+	if osType == "" {
+		return models.CompileEnvOSLinux, nil
+	}
+	return osType, nil
+}
+
+// verifyWindowsSubTasks the windows build only supports checkout/compile/build-image without build matrix
+func verifyWindowsSubTasks(subTasks []*subTask, allParms []*RunBuildAllParms) error {
+	for _, subTask := range subTasks {
+		if !windowsSubTasks[subTask.Type] {
+			return fmt.Errorf("Windows 构建不支持子任务 %v", subTask.Name)
+		}
+		if subTask.Matrix != nil {
+			return fmt.Errorf("Windows 构建不支持构建矩阵")
+		}
+	}
+	for _, app := range allParms {
+		if buildWithBuildpacks(app) {
+			return fmt.Errorf("Windows 构建不支持 buildpacks, 应用 %v 需要提供 Dockerfile", app.Name)
+		}
+	}
+	return nil
+}
+
+// windowsContainerTemplates the jnlp agent and the image builder of windows build pod
+func (pm *PipelineManager) windowsContainerTemplates() ([]jenkins.ContainerEnv, error) {
+	jnlp, err := pm.getSysDefaultCompileEnv(constant.WindowsDefaultContainerName)
+	if err != nil {
+		log.Log.Error("get sys default %v compile env error: %s", constant.WindowsDefaultContainerName, err.Error())
+		return nil, fmt.Errorf("未找到 Windows 构建代理的编译环境 %v", constant.WindowsDefaultContainerName)
+	}
+	// the steps run in the default container named jnlp
+	jnlp.Name = constant.DefaultContainerName
+	docker, err := pm.getSysDefaultCompileEnv(constant.WindowsBuildImageContainerName)
+	if err != nil {
+		log.Log.Error("get sys default %v compile env error: %s", constant.WindowsBuildImageContainerName, err.Error())
+		return nil, fmt.Errorf("未找到 Windows 镜像构建的编译环境 %v", constant.WindowsBuildImageContainerName)
+	}
+	docker.Name = windowsDockerContainerName
+	return []jenkins.ContainerEnv{jnlp, docker}, nil
+}
+
+// withDockerEngine return the agent template which mounts the docker engine of windows node into the containers
+func withDockerEngine(agent *AgentTemplate) *AgentTemplate {
+	patched := &AgentTemplate{}
+	if agent != nil {
+		*patched = *agent
+	}
+	volume := dockerEngineVolume
+	patched.Volumes = append(append([]*AgentVolume{}, patched.Volumes...), &volume)
+	return patched
+}
+
+// shellStep render the script as the pipeline step of the shell, sh by default
+func shellStep(shell, script string) string {
+	switch shell {
+	case models.CompileEnvShellBat, models.CompileEnvShellPowershell:
+		return fmt.Sprintf("%s '%s'", shell, script)
+	}
+	return fmt.Sprintf("sh '%s'", script)
+}
+
+// shellCommands join the commands executed in sequence, the commands of bat are chained by &&
+func shellCommands(shell string, commands ...string) string {
+	if shell == models.CompileEnvShellBat {
+		return strings.Join(commands, " && ")
+	}
+	return strings.Join(commands, "; ")
+}
+
+// changeDirCommand the command changes the working dir, cd of bat changes the drive too with /d
+func changeDirCommand(shell, dir string) string {
+	if shell == models.CompileEnvShellBat {
+		return "cd /d " + dir
+	}
+	return "cd " + dir
+}
+
+// windowsCheckoutCommand the checkout step runs by powershell, which does not print the clone credential in env,
+// only the clone credential is expanded, the other arguments are quoted
+func windowsCheckoutCommand(repoURL *url.URL, repoID int64, branch, repoPath string) string {
+	cloneURL := fmt.Sprintf("(%s + $env:%s + %s)", powershellQuote(repoURL.Scheme+"://"), scmCredentialEnvKey(repoID), powershellQuote("@"+repoURL.Host+repoURL.EscapedPath()))
+	repoPath = powershellQuote(repoPath)
+	return shellStep(models.CompileEnvShellPowershell, groovyEscape(fmt.Sprintf(
+		"if (Test-Path %v) { Remove-Item -Recurse -Force %v }; git clone --depth 1 -b %v %v %v; cd %v; git log -1 --oneline",
+		repoPath, repoPath, powershellQuote(branch), cloneURL, repoPath, repoPath)))
+}
+
+// powershellQuote quote the argument of powershell command by single quotes
+func powershellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+}
+
+// renderWindowsImageStageForBuild build and push the images of apps by the docker engine of windows node
+func (pm *PipelineManager) renderWindowsImageStageForBuild(projectID, stageID int64, allParms []*RunBuildAllParms, ciConfig *JenkinsCI) (string, error) {
+	imageURLs, err := pm.buildImageAddrs(stageID, allParms)
+	if err != nil {
+		return "", err
+	}
+	commands := []string{
+		`powershell '''
+        New-Item -ItemType Directory -Force -Path $env:DOCKER_CONFIG | Out-Null
+        Set-Content -Path "$env:DOCKER_CONFIG/config.json" -Value ('{"auths": {"' + $env:REGISTRY_ADDR + '": {"auth": "' + $env:DOCKER_AUTH + '"}}}')
+        '''`,
+	}
+	for i, app := range allParms {
+		if imageURLs[i] == "" {
+			continue
+		}
+		dockerfile := app.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		appPath := pm.matrixAppPath(stageID, projectID, ciConfig.Workspace, app, pm.matrixCells(nil, app)[0])
+		commands = append(commands, shellStep(models.CompileEnvShellPowershell, fmt.Sprintf(
+			"cd %v; docker build -f %v -t %v .; if ($LASTEXITCODE -ne 0) { exit $LASTEXITCODE }; docker push %v",
+			appPath, dockerfile, imageURLs[i], imageURLs[i])))
+	}
+	item := jenkins.StepItem{
+		Name:    "'Images'",
+		Command: fmt.Sprintf("container('%s') {\n%s\n}", windowsDockerContainerName, strings.Join(commands, "\n")),
+	}
+	return jenkins.GeneratePipelineXMLStr(templates.CustomScript, map[string]interface{}{"CustomScriptItem": item})
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestShellStep(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{shell: "", want: "sh 'cd /src; make'"},
+		{shell: models.CompileEnvShellSh, want: "sh 'cd /src; make'"},
+		{shell: models.CompileEnvShellPowershell, want: "powershell 'cd /src; make'"},
+		{shell: models.CompileEnvShellBat, want: "bat 'cd /d /src && make'"},
+	}
+	for _, tt := range tests {
+		script := shellCommands(tt.shell, changeDirCommand(tt.shell, "/src"), "make")
+		if got := shellStep(tt.shell, script); got != tt.want {
+			t.Fatalf("shell step of %q = %q, want %q", tt.shell, got, tt.want)
+		}
+	}
+}
+
+func TestBuildOS(t *testing.T) {
+	osType, err := buildOS([]compileEnv{{Name: "api"}, {Name: "web", OSType: models.CompileEnvOSLinux}})
+	if err != nil || osType != models.CompileEnvOSLinux {
+		t.Fatalf("build os = %v, %v, want linux", osType, err)
+	}
+	windows := []compileEnv{{Name: "api", OSType: models.CompileEnvOSWindows}}
+	if osType, err = buildOS(windows); err != nil || osType != models.CompileEnvOSWindows {
+		t.Fatalf("build os = %v, %v, want windows", osType, err)
+	}
+	if spec := mergeBuildPodSpec(windows); spec.NodeSelector["kubernetes.io/os"] != models.CompileEnvOSWindows {
+		t.Fatalf("windows build pod node selector = %v", spec.NodeSelector)
+	}
+	if _, err = buildOS(append(windows, compileEnv{Name: "web"})); err == nil {
+		t.Fatalf("mixed os compile envs should be rejected")
+	}
+}
+
+func TestWithDockerEngine(t *testing.T) {
+	agent := &AgentTemplate{ServiceAccount: "builder", Volumes: []*AgentVolume{{Name: "cache", Type: AgentVolumeEmptyDir, MountPath: "/cache"}}}
+	patched := withDockerEngine(agent)
+	if len(agent.Volumes) != 1 || len(patched.Volumes) != 2 || patched.ServiceAccount != "builder" {
+		t.Fatalf("unexpected patched agent template: %+v", patched)
+	}
+	if volumes := withDockerEngine(nil).podVolumes(); len(volumes) != 1 || volumes[0].HostPath.Path != dockerEngineVolume.Source {
+		t.Fatalf("unexpected docker engine volumes: %+v", volumes)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinemgr
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/pkg/workflowplugin"
+	"github.com/go-atomci/workflow"
+	"github.com/go-atomci/workflow/jenkins"
+)
+
+// pluginWorkFlow the workflow served by the external driver, the flow processor is passed to the driver as json
+type pluginWorkFlow struct {
+	driver    workflowplugin.Driver
+	conn      workflowplugin.Connection
+	jobName   string
+	processor jenkins.FlowProcessor
+}
+
+func newPluginWorkFlow(driver workflowplugin.Driver, addr, user, token, jobName string, processor jenkins.FlowProcessor) workflow.WorkFlow {
+	return &pluginWorkFlow{
+		driver:    driver,
+		conn:      workflowplugin.Connection{URL: addr, User: user, Token: token},
+		jobName:   jobName,
+		processor: processor,
+	}
+}
+
+func (w *pluginWorkFlow) Ping() (string, error) {
+	return w.driver.Ping(&w.conn)
+}
+
+func (w *pluginWorkFlow) Build() (int64, error) {
+	pipeline, err := json.Marshal(w.processor)
+	if err != nil {
+		return 0, err
+	}
+	return w.driver.Build(&workflowplugin.BuildRequest{Connection: w.conn, JobName: w.jobName, Pipeline: pipeline})
+}
+
+func (w *pluginWorkFlow) Abort(runID int64) error {
+	return w.driver.Abort(&workflowplugin.JobRequest{Connection: w.conn, JobName: w.jobName, RunID: runID})
+}
+
+func (w *pluginWorkFlow) GetJobInfo(runID int64) (*workflow.JobInfo, error) {
+	return w.driver.GetJobInfo(&workflowplugin.JobRequest{Connection: w.conn, JobName: w.jobName, RunID: runID})
+}
+
+// verifyDriverAbort the job can only be aborted when the driver supports
+func verifyDriverAbort(driver string) error {
+	capabilities, err := settings.DriverCapabilities(driver)
+	if err != nil {
+		return err
+	}
+	if !capabilities.SupportsAbort {
+		return fmt.Errorf("CI 驱动 %v 不支持终止任务", driver)
+	}
+	return nil
+}
+
+// getPluginJobLog get the log of job from the external driver
+func getPluginJobLog(CIInfo *JenkinsCI, jobName string, jobLog *JobLog, runID int64) error {
+	capabilities, err := settings.DriverCapabilities(CIInfo.Driver)
+	if err != nil {
+		return err
+	}
+	if !capabilities.SupportsLogs {
+		return fmt.Errorf("CI 驱动 %v 不支持查看任务日志", CIInfo.Driver)
+	}
+	driver, err := settings.WorkflowDriver(CIInfo.Driver)
+	if err != nil {
+		return err
+	}
+	log, err := driver.GetJobLog(&workflowplugin.LogRequest{
+		JobRequest: workflowplugin.JobRequest{
+			Connection: workflowplugin.Connection{URL: CIInfo.URL, User: CIInfo.User, Token: CIInfo.Token},
+			JobName:    jobName,
+			RunID:      runID,
+		},
+		Start: jobLog.NextStart,
+	})
+	if err != nil {
+		return err
+	}
+	jobLog.Text, jobLog.NextStart, jobLog.More = log.Text, log.NextStart, log.More
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// GetAgentTemplate return the agent template of project, the empty template is returned if not customized
+func (pm *ProjectManager) GetAgentTemplate(projectID int64) (*pipelinemgr.AgentTemplate, error) {
+	item, err := pm.model.GetProjectAgentTemplate(projectID)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return &pipelinemgr.AgentTemplate{Labels: map[string]string{}, Volumes: []*pipelinemgr.AgentVolume{}}, nil
+		}
+		return nil, err
+	}
+	return pipelinemgr.ParseAgentTemplate(item)
+}
+
+// UpdateAgentTemplate the agent template is applied to the ci jobs triggered after update
+func (pm *ProjectManager) UpdateAgentTemplate(projectID int64, request *pipelinemgr.AgentTemplate, updater string) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	if _, err := pm.model.GetProjectByID(projectID); err != nil {
+		return err
+	}
+	item, err := pm.model.GetProjectAgentTemplate(projectID)
+	if err != nil {
+		if err != orm.ErrNoRows {
+			return err
+		}
+		item = &models.ProjectAgentTemplate{Addons: models.NewAddons(), ProjectID: projectID}
+	}
+	if err := pipelinemgr.EncodeAgentTemplate(request, item); err != nil {
+		return err
+	}
+	item.Updater = updater
+	return pm.model.SaveProjectAgentTemplate(item)
+}
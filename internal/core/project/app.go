@@ -18,7 +18,13 @@ package project
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/go-atomci/atomci/internal/core/kuberes"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils/query"
@@ -73,7 +79,7 @@ func (pm *ProjectManager) GetProjectAppsByPagination(projectID int64, filter *mo
 func (pm *ProjectManager) DeleteProjectApp(projectAppID int64) error {
 	log.Log.Debug("delete project app, projectAppID: %v", projectAppID)
 
-	_, err := pm.model.GetProjectApp(projectAppID)
+	projectApp, err := pm.model.GetProjectApp(projectAppID)
 	if err != nil {
 		log.Log.Error("when delete project app, get project app occur error: %s", err.Error())
 		return fmt.Errorf("当前代码库可能已经删除，请你刷新页面后重试")
@@ -84,10 +90,37 @@ func (pm *ProjectManager) DeleteProjectApp(projectAppID int64) error {
 	if err != nil {
 		return err
 	}
+	pm.pruneProjectAppResources(projectApp)
 	// TODO: delete app service constraint
 	return nil
 }
 
+// pruneProjectAppResources delete the resources of the app removed from the envs which opt in pruning,
+// the app was removed already, so the failure is only logged
+func (pm *ProjectManager) pruneProjectAppResources(projectApp *models.ProjectApp) {
+	envs, err := pm.model.GetProjectEnvs(projectApp.ProjectID)
+	if err != nil {
+		log.Log.Warn("when prune app: %v resources, get project envs occur error: %s", projectApp.ID, err.Error())
+		return
+	}
+	for _, env := range envs {
+		if !env.Prune || env.ArgoCD != 0 || env.Cluster == 0 {
+			continue
+		}
+		cluster, err := pm.settingModel.GetIntegrateSettingByID(env.Cluster)
+		if err != nil {
+			log.Log.Warn("when prune app: %v resources, get cluster: %v occur error: %s", projectApp.ID, env.Cluster, err.Error())
+			continue
+		}
+		pruned, err := kuberes.PruneAppResources(cluster.Name, env.Namespace, env.ID, projectApp.ID)
+		if err != nil {
+			log.Log.Warn("prune app: %v resources in env: %v occur error: %s", projectApp.ID, env.Name, err.Error())
+			continue
+		}
+		log.Log.Info("app: %v removed, pruned resources in env: %v: %v", projectApp.ID, env.Name, pruned)
+	}
+}
+
 // UpdateProjectApp ..
 func (pm *ProjectManager) UpdateProjectApp(projectID, projectAppID int64, req *ProjectAppUpdateReq) error {
 	_, err := pm.model.GetProjectAppByScmID(projectID, req.ScmID)
@@ -101,3 +134,83 @@ func (pm *ProjectManager) UpdateProjectApp(projectID, projectAppID int64, req *P
 	projectApp.ScmID = req.ScmID
 	return pm.model.UpdateProjectApp(projectApp)
 }
+
+// UpdateProjectAppDeployAfter set the apps which must be ready before the app is deployed in the same stage
+func (pm *ProjectManager) UpdateProjectAppDeployAfter(projectID, projectAppID int64, req *ProjectAppDeployAfterReq) error {
+	projectApp, err := pm.model.GetProjectApp(projectAppID)
+	if err != nil || projectApp.ProjectID != projectID {
+		return fmt.Errorf("项目应用不存在")
+	}
+	projectApps, err := pm.model.GetProjectApps(projectID)
+	if err != nil {
+		return err
+	}
+	appIDs := []int64{}
+	deps := map[int64][]int64{}
+	for _, app := range projectApps {
+		appIDs = append(appIDs, app.ID)
+		deps[app.ID] = app.DeployAfterIDs()
+	}
+
+	deployAfter := []string{}
+	seen := map[int64]bool{}
+	for _, id := range req.DeployAfter {
+		if id == projectAppID {
+			return fmt.Errorf("应用不能依赖自身")
+		}
+		if _, ok := deps[id]; !ok {
+			return fmt.Errorf("依赖的应用: %v 不属于当前项目", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			deployAfter = append(deployAfter, strconv.FormatInt(id, 10))
+		}
+	}
+	deps[projectAppID] = req.DeployAfter
+	if _, err := pipelinemgr.DeployWaves(appIDs, deps); err != nil {
+		return err
+	}
+
+	projectApp.DeployAfter = strings.Join(deployAfter, ",")
+	if len(projectApp.DeployAfter) > 256 {
+		return fmt.Errorf("依赖的应用过多")
+	}
+	return pm.model.UpdateProjectApp(projectApp)
+}
+
+// UpdateProjectAppCompileCommand choose the compile command template of app, or override it in the project
+func (pm *ProjectManager) UpdateProjectAppCompileCommand(projectID, projectAppID int64, req *ProjectAppCompileCommandReq) error {
+	projectApp, err := pm.model.GetProjectApp(projectAppID)
+	if err != nil || projectApp.ProjectID != projectID {
+		return fmt.Errorf("项目应用不存在")
+	}
+	if req.CompileTemplate != "" {
+		if _, err := dao.NewSysSettingModel().GetCompileCommandTemplateByName(req.CompileTemplate); err != nil {
+			return fmt.Errorf("编译命令 %v 不存在", req.CompileTemplate)
+		}
+	}
+	req.CompileCommand = strings.TrimSpace(req.CompileCommand)
+	if req.CompileCommand != "" {
+		if err := settings.VerifyCompileCommand(req.CompileCommand); err != nil {
+			return err
+		}
+	}
+	projectApp.CompileTemplate = req.CompileTemplate
+	projectApp.CompileCommand = req.CompileCommand
+	return pm.model.UpdateProjectApp(projectApp)
+}
+
+// UpdateProjectAppDockerfileTemplate choose the dockerfile template of app, empty means the template of app language
+func (pm *ProjectManager) UpdateProjectAppDockerfileTemplate(projectID, projectAppID int64, req *ProjectAppDockerfileTemplateReq) error {
+	projectApp, err := pm.model.GetProjectApp(projectAppID)
+	if err != nil || projectApp.ProjectID != projectID {
+		return fmt.Errorf("项目应用不存在")
+	}
+	if req.DockerfileTemplate != "" {
+		if _, err := dao.NewSysSettingModel().GetDockerfileTemplateByName(req.DockerfileTemplate); err != nil {
+			return fmt.Errorf("Dockerfile 模板 %v 不存在", req.DockerfileTemplate)
+		}
+	}
+	projectApp.DockerfileTemplate = req.DockerfileTemplate
+	return pm.model.UpdateProjectApp(projectApp)
+}
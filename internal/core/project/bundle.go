@@ -0,0 +1,766 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// BundleVersion the version of project bundle, the fields are only added in the same version
+const BundleVersion = "v1"
+
+// ProjectBundle the portable configurations of project, the integrate settings, compile envs and task templates
+// are referenced by name, their configs and credentials are never exported
+type ProjectBundle struct {
+	Version    string            `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Project    *BundleProject    `json:"project"`
+	Envs       []*BundleEnv      `json:"envs"`
+	Apps       []*BundleApp      `json:"apps"`
+	Pipelines  []*BundlePipeline `json:"pipelines"`
+}
+
+// BundleProject ..
+type BundleProject struct {
+	Name                string `json:"name"`
+	Description         string `json:"description"`
+	MaxConcurrentBuilds int    `json:"max_concurrent_builds"`
+	RetentionMonths     int    `json:"retention_months"`
+}
+
+// BundleSettingRef the integrate setting referenced by name and type
+type BundleSettingRef struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// BundleEnv the env is identified by its arrange env
+type BundleEnv struct {
+	Name              string                      `json:"name"`
+	Description       string                      `json:"description"`
+	ArrangeEnv        string                      `json:"arrange_env"`
+	Namespace         string                      `json:"namespace"`
+	Cluster           *BundleSettingRef           `json:"cluster,omitempty"`
+	CIServer          *BundleSettingRef           `json:"ci_server,omitempty"`
+	Registry          *BundleSettingRef           `json:"registry,omitempty"`
+	ArgoCD            *BundleSettingRef           `json:"argocd,omitempty"`
+	IssueTracker      *BundleSettingRef           `json:"issue_tracker,omitempty"`
+	MergeTarget       string                      `json:"merge_target,omitempty"`
+	ReleaseBranch     string                      `json:"release_branch,omitempty"`
+	ConcurrencyPolicy string                      `json:"concurrency_policy,omitempty"`
+	DeployWindows     []*pipelinemgr.DeployWindow `json:"deploy_windows,omitempty"`
+	WindowPolicy      string                      `json:"window_policy,omitempty"`
+	BuildCluster      *BundleSettingRef           `json:"build_cluster,omitempty"`
+	BuildNamespace    string                      `json:"build_namespace,omitempty"`
+	Prune             bool                        `json:"prune,omitempty"`
+	Cosign            *BundleSettingRef           `json:"cosign,omitempty"`
+	SignaturePolicy   string                      `json:"signature_policy,omitempty"`
+}
+
+// BundleApp the app is identified by its repository and full name
+type BundleApp struct {
+	Name        string            `json:"name"`
+	FullName    string            `json:"full_name"`
+	Language    string            `json:"language"`
+	BranchName  string            `json:"branch_name"`
+	Path        string            `json:"path"`
+	Repo        *BundleSettingRef `json:"repo"`
+	CompileEnv  string            `json:"compile_env,omitempty"`
+	BuildPath   string            `json:"build_path"`
+	Dockerfile  string            `json:"dockerfile"`
+	WatchPaths  string            `json:"watch_paths,omitempty"`
+	DBMigration string            `json:"db_migration,omitempty"`
+	Arranges    []*BundleArrange  `json:"arranges,omitempty"`
+}
+
+// BundleArrange the arrange of app in the env
+type BundleArrange struct {
+	ArrangeEnv string            `json:"arrange_env"`
+	Config     string            `json:"config"`
+	Variables  map[string]string `json:"variables,omitempty"`
+	Images     []*BundleImage    `json:"images,omitempty"`
+}
+
+// BundleImage the image of arrange replaced by the image built from the app
+type BundleImage struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	App          string `json:"app,omitempty"`
+	ImageTagType int64  `json:"image_tag_type"`
+}
+
+// BundlePipeline the stage_id and step_id of config are replaced by stage_env and step_template
+type BundlePipeline struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	IsDefault   bool                     `json:"is_default"`
+	Config      []map[string]interface{} `json:"config"`
+}
+
+// ImportAction the object created or updated by import
+type ImportAction struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// the actions of import
+const (
+	ImportActionCreate = "create"
+	ImportActionUpdate = "update"
+)
+
+// ProjectImportResult ..
+type ProjectImportResult struct {
+	ProjectID int64           `json:"project_id"`
+	DryRun    bool            `json:"dry_run"`
+	Actions   []*ImportAction `json:"actions"`
+	Warnings  []string        `json:"warnings"`
+}
+
+// ProjectImportReq ..
+type ProjectImportReq struct {
+	// Name override the project name of bundle, only used when import as a new project
+	Name string
+	// OrgID the organization of new project
+	OrgID int64
+	// DryRun only resolve the references and return the actions
+	DryRun bool
+}
+
+func (r *ProjectImportResult) add(kind, name, action string) {
+	r.Actions = append(r.Actions, &ImportAction{Kind: kind, Name: name, Action: action})
+}
+
+// ExportProject export the project into bundle
+func (pm *ProjectManager) ExportProject(projectID int64) (*ProjectBundle, error) {
+	project, err := pm.model.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &ProjectBundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now(),
+		Project: &BundleProject{
+			Name:                project.Name,
+			Description:         project.Description,
+			MaxConcurrentBuilds: project.MaxConcurrentBuilds,
+			RetentionMonths:     project.RetentionMonths,
+		},
+		Envs:      []*BundleEnv{},
+		Apps:      []*BundleApp{},
+		Pipelines: []*BundlePipeline{},
+	}
+	settingRefs := map[int64]*BundleSettingRef{}
+	settingRef := func(settingID int64) (*BundleSettingRef, error) {
+		if settingID == 0 {
+			return nil, nil
+		}
+		if ref, ok := settingRefs[settingID]; ok {
+			return ref, nil
+		}
+		setting, err := pm.settingModel.GetIntegrateSettingByID(settingID)
+		if err != nil {
+			return nil, fmt.Errorf("集成配置 %v 不存在", settingID)
+		}
+		settingRefs[settingID] = &BundleSettingRef{Name: setting.Name, Type: setting.Type}
+		return settingRefs[settingID], nil
+	}
+
+	envs, err := pm.model.GetProjectEnvs(projectID)
+	if err != nil {
+		return nil, err
+	}
+	envTags := map[int64]string{}
+	for _, env := range envs {
+		envTags[env.ID] = env.ArrangeEnv
+		item := &BundleEnv{
+			Name:              env.Name,
+			Description:       env.Description,
+			ArrangeEnv:        env.ArrangeEnv,
+			Namespace:         env.Namespace,
+			MergeTarget:       env.MergeTarget,
+			ReleaseBranch:     env.ReleaseBranch,
+			ConcurrencyPolicy: env.ConcurrencyPolicy,
+			WindowPolicy:      env.WindowPolicy,
+			BuildNamespace:    env.BuildNamespace,
+			Prune:             env.Prune,
+			SignaturePolicy:   env.SignaturePolicy,
+		}
+		refs := []**BundleSettingRef{&item.Cluster, &item.CIServer, &item.Registry, &item.ArgoCD, &item.IssueTracker, &item.BuildCluster, &item.Cosign}
+		for i, settingID := range []int64{env.Cluster, env.CIServer, env.Registry, env.ArgoCD, env.IssueTracker, env.BuildCluster, env.Cosign} {
+			if *refs[i], err = settingRef(settingID); err != nil {
+				return nil, fmt.Errorf("环境 %v: %v", env.Name, err.Error())
+			}
+		}
+		if item.DeployWindows, err = pipelinemgr.ParseDeployWindows(env.DeployWindows); err != nil {
+			return nil, fmt.Errorf("环境 %v: %v", env.Name, err.Error())
+		}
+		bundle.Envs = append(bundle.Envs, item)
+	}
+
+	projectApps, err := pm.model.GetProjectApps(projectID)
+	if err != nil {
+		return nil, err
+	}
+	appNames := map[int64]string{}
+	scmApps := map[int64]*models.ScmApp{}
+	for _, projectApp := range projectApps {
+		scmApp, err := pm.scmAppModel.GetScmAppByID(projectApp.ScmID)
+		if err != nil {
+			log.Log.Warn("when export project: %v, get scm app: %v occur error: %s", projectID, projectApp.ScmID, err.Error())
+			continue
+		}
+		appNames[projectApp.ID] = scmApp.FullName
+		scmApps[projectApp.ID] = scmApp
+	}
+	for _, projectApp := range projectApps {
+		scmApp, ok := scmApps[projectApp.ID]
+		if !ok {
+			continue
+		}
+		item := &BundleApp{
+			Name:        scmApp.Name,
+			FullName:    scmApp.FullName,
+			Language:    scmApp.Language,
+			BranchName:  scmApp.BranchName,
+			Path:        scmApp.Path,
+			BuildPath:   scmApp.BuildPath,
+			Dockerfile:  scmApp.Dockerfile,
+			WatchPaths:  scmApp.WatchPaths,
+			DBMigration: scmApp.DBMigration,
+		}
+		if item.Repo, err = settingRef(scmApp.RepoID); err != nil {
+			return nil, fmt.Errorf("应用 %v: %v", scmApp.FullName, err.Error())
+		}
+		if scmApp.CompileEnvID != 0 {
+			if compileEnv, err := pm.settingModel.GetCompileEnvByID(scmApp.CompileEnvID); err == nil {
+				item.CompileEnv = compileEnv.Name
+			}
+		}
+		for _, env := range envs {
+			arrange, err := pm.arrangeModel.GetAppArrange(projectApp.ID, env.ID)
+			if err != nil {
+				if err != orm.ErrNoRows {
+					return nil, err
+				}
+				continue
+			}
+			if arrange.Config == "" {
+				continue
+			}
+			bundleArrange := &BundleArrange{ArrangeEnv: env.ArrangeEnv, Config: arrange.Config}
+			if bundleArrange.Variables, err = apps.DecodeArrangeVariables(arrange.Variables); err != nil {
+				return nil, fmt.Errorf("应用 %v 环境 %v 的编排变量解析失败: %s", scmApp.FullName, env.Name, err.Error())
+			}
+			mappings, err := pm.arrangeModel.GetAppImageMappingByArrangeID(arrange.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, mapping := range mappings {
+				bundleArrange.Images = append(bundleArrange.Images, &BundleImage{
+					Name:         mapping.Name,
+					Image:        mapping.Image,
+					App:          appNames[mapping.ProjectAppID],
+					ImageTagType: mapping.ImageTagType,
+				})
+			}
+			item.Arranges = append(item.Arranges, bundleArrange)
+		}
+		bundle.Apps = append(bundle.Apps, item)
+	}
+
+	pipelines, err := pm.model.GetProjectPipelines(projectID)
+	if err != nil {
+		return nil, err
+	}
+	stepNames := map[int64]string{}
+	stepName := func(stepID int64) (string, error) {
+		if name, ok := stepNames[stepID]; ok {
+			return name, nil
+		}
+		step, err := pm.pipelineModel.GetTaskTmplByID(stepID)
+		if err != nil {
+			return "", fmt.Errorf("任务模板 %v 不存在", stepID)
+		}
+		stepNames[stepID] = step.Name
+		return step.Name, nil
+	}
+	envTag := func(envID int64) (string, error) {
+		if tag, ok := envTags[envID]; ok {
+			return tag, nil
+		}
+		return "", fmt.Errorf("环境 %v 不存在", envID)
+	}
+	for _, pipeline := range pipelines {
+		config, err := exportPipelineConfig(pipeline.Config, envTag, stepName)
+		if err != nil {
+			return nil, fmt.Errorf("流程 %v: %v", pipeline.Name, err.Error())
+		}
+		bundle.Pipelines = append(bundle.Pipelines, &BundlePipeline{
+			Name:        pipeline.Name,
+			Description: pipeline.Description,
+			IsDefault:   pipeline.IsDefault,
+			Config:      config,
+		})
+	}
+	return bundle, nil
+}
+
+// ImportProject import the bundle into the project, a new project is created when projectID is 0,
+// the envs are matched by arrange env, the apps by repository and full name, and the pipelines by name
+func (pm *ProjectManager) ImportProject(projectID int64, bundle *ProjectBundle, request *ProjectImportReq, user, groupName string) (*ProjectImportResult, error) {
+	if bundle == nil || bundle.Project == nil {
+		return nil, fmt.Errorf("无效的项目导出包")
+	}
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("不支持的项目导出包版本: %v", bundle.Version)
+	}
+	result := &ProjectImportResult{ProjectID: projectID, DryRun: request.DryRun, Actions: []*ImportAction{}, Warnings: []string{}}
+	plan, err := pm.resolveBundle(projectID, bundle, request, result)
+	if err != nil {
+		return nil, err
+	}
+	if request.DryRun {
+		return result, nil
+	}
+
+	if projectID == 0 {
+		project, err := pm.CreateProject(user, groupName, &ProjectReq{
+			Name:                plan.projectName,
+			Description:         bundle.Project.Description,
+			MaxConcurrentBuilds: bundle.Project.MaxConcurrentBuilds,
+			RetentionMonths:     bundle.Project.RetentionMonths,
+			OrgID:               request.OrgID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if project == nil {
+			return nil, fmt.Errorf("创建项目 %v 失败，请重试", plan.projectName)
+		}
+		projectID = project.ID
+		result.ProjectID = projectID
+	}
+
+	for _, env := range bundle.Envs {
+		envReq := &ProjectEnvReq{
+			Name:              env.Name,
+			Description:       env.Description,
+			ArrangeEnv:        env.ArrangeEnv,
+			Namespace:         env.Namespace,
+			Cluster:           plan.settings[env.Cluster],
+			CIServer:          plan.settings[env.CIServer],
+			Registry:          plan.settings[env.Registry],
+			ArgoCD:            plan.settings[env.ArgoCD],
+			IssueTracker:      plan.settings[env.IssueTracker],
+			MergeTarget:       env.MergeTarget,
+			ReleaseBranch:     env.ReleaseBranch,
+			ConcurrencyPolicy: env.ConcurrencyPolicy,
+			DeployWindows:     env.DeployWindows,
+			WindowPolicy:      env.WindowPolicy,
+			BuildCluster:      plan.settings[env.BuildCluster],
+			BuildNamespace:    env.BuildNamespace,
+			Prune:             env.Prune,
+			Cosign:            plan.settings[env.Cosign],
+			SignaturePolicy:   env.SignaturePolicy,
+		}
+		if existing, err := pm.model.GetProjectEnvBycIDAndEnvTag(env.ArrangeEnv, projectID); err == nil {
+			err = pm.UpdateProjectEnv(envReq, existing.ID)
+		} else {
+			err = pm.CreateProjectEnv(envReq, user, projectID)
+		}
+		if err != nil {
+			return result, fmt.Errorf("导入环境 %v 失败: %v", env.Name, err.Error())
+		}
+	}
+	envs, err := pm.model.GetProjectEnvs(projectID)
+	if err != nil {
+		return result, err
+	}
+	envIDs := map[string]int64{}
+	for _, env := range envs {
+		envIDs[env.ArrangeEnv] = env.ID
+	}
+
+	projectAppIDs := map[string]int64{}
+	for _, app := range bundle.Apps {
+		scmAppID := plan.scmApps[app]
+		if scmAppID == 0 {
+			scmApp := &models.ScmApp{
+				Addons:       models.NewAddons(),
+				Creator:      user,
+				Name:         app.Name,
+				FullName:     app.FullName,
+				Language:     app.Language,
+				BranchName:   app.BranchName,
+				Path:         app.Path,
+				RepoID:       plan.settings[app.Repo],
+				CompileEnvID: plan.compileEnvs[app.CompileEnv],
+				BuildPath:    app.BuildPath,
+				Dockerfile:   app.Dockerfile,
+				WatchPaths:   app.WatchPaths,
+				DBMigration:  app.DBMigration,
+			}
+			if scmAppID, err = pm.scmAppModel.CreateScmAppIfNotExist(scmApp); err != nil {
+				return result, fmt.Errorf("导入应用 %v 失败: %v", app.FullName, err.Error())
+			}
+		}
+		projectApp, err := pm.model.GetProjectAppByScmID(projectID, scmAppID)
+		if err != nil {
+			if err := pm.CreateProjectApp(projectID, &ProjectAppReq{SCMID: scmAppID}, user); err != nil {
+				return result, fmt.Errorf("导入应用 %v 失败: %v", app.FullName, err.Error())
+			}
+			if projectApp, err = pm.model.GetProjectAppByScmID(projectID, scmAppID); err != nil {
+				return result, err
+			}
+		}
+		projectAppIDs[app.FullName] = projectApp.ID
+	}
+	appManager := apps.NewAppManager()
+	for _, app := range bundle.Apps {
+		for _, arrange := range app.Arranges {
+			arrangeReq := &apps.AppArrangeReq{Config: arrange.Config, Variables: arrange.Variables, ImageMapings: []apps.ImageMaping{}}
+			for _, image := range arrange.Images {
+				arrangeReq.ImageMapings = append(arrangeReq.ImageMapings, apps.ImageMaping{
+					Name:         image.Name,
+					Image:        image.Image,
+					ProjectAppID: projectAppIDs[image.App],
+					ImageTagType: image.ImageTagType,
+				})
+			}
+			if err := appManager.SetArrange(projectAppIDs[app.FullName], envIDs[arrange.ArrangeEnv], arrangeReq, user); err != nil {
+				return result, fmt.Errorf("导入应用 %v 环境 %v 的编排失败: %v", app.FullName, arrange.ArrangeEnv, err.Error())
+			}
+		}
+	}
+
+	pipelines, err := pm.model.GetProjectPipelines(projectID)
+	if err != nil {
+		return result, err
+	}
+	pipelineIDs := map[string]int64{}
+	defaultPipeline := ""
+	for _, pipeline := range pipelines {
+		pipelineIDs[pipeline.Name] = pipeline.ID
+		if pipeline.IsDefault {
+			defaultPipeline = pipeline.Name
+		}
+	}
+	for _, pipeline := range bundle.Pipelines {
+		config, err := importPipelineConfig(pipeline.Config, func(tag string) (int64, error) {
+			if envID, ok := envIDs[tag]; ok {
+				return envID, nil
+			}
+			return 0, fmt.Errorf("环境 %v 不存在", tag)
+		}, func(name string) (int64, error) {
+			return plan.steps[name], nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("导入流程 %v 失败: %v", pipeline.Name, err.Error())
+		}
+		pipelineReq := &PipelineReq{
+			Name:        pipeline.Name,
+			Description: pipeline.Description,
+			ProjectID:   projectID,
+			IsDefault:   pipeline.IsDefault && (defaultPipeline == "" || defaultPipeline == pipeline.Name),
+			Config:      config,
+		}
+		pipelineID, ok := pipelineIDs[pipeline.Name]
+		if !ok {
+			if pipelineID, err = pm.CreateProjectPipeline(&PipelineReq{Name: pipeline.Name, Description: pipeline.Description, ProjectID: projectID}, user); err != nil {
+				return result, fmt.Errorf("导入流程 %v 失败: %v", pipeline.Name, err.Error())
+			}
+		}
+		if err := pm.UpdateProjectPipelineConfig(pipelineReq, user, projectID, pipelineID); err != nil {
+			return result, fmt.Errorf("导入流程 %v 失败: %v", pipeline.Name, err.Error())
+		}
+		if pipelineReq.IsDefault {
+			defaultPipeline = pipeline.Name
+		}
+	}
+	return result, nil
+}
+
+// importPlan the references of bundle resolved in the target instance
+type importPlan struct {
+	projectName string
+	settings    map[*BundleSettingRef]int64
+	compileEnvs map[string]int64
+	// scmApps the existing scm apps of the bundle apps, 0 means the app is created
+	scmApps map[*BundleApp]int64
+	steps   map[string]int64
+}
+
+// resolveBundle resolve all the references before any change, so the missing ones are reported together
+func (pm *ProjectManager) resolveBundle(projectID int64, bundle *ProjectBundle, request *ProjectImportReq, result *ProjectImportResult) (*importPlan, error) {
+	plan := &importPlan{
+		settings:    map[*BundleSettingRef]int64{},
+		compileEnvs: map[string]int64{},
+		scmApps:     map[*BundleApp]int64{},
+		steps:       map[string]int64{},
+	}
+	missing := []string{}
+	resolveSetting := func(ref *BundleSettingRef) {
+		if ref == nil {
+			return
+		}
+		setting, err := pm.settingModel.GetIntegrateSettingByName(ref.Name, ref.Type)
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("集成配置 %v(%v) 不存在", ref.Name, ref.Type))
+			return
+		}
+		plan.settings[ref] = setting.ID
+	}
+
+	if projectID == 0 {
+		plan.projectName = strings.TrimSpace(request.Name)
+		if plan.projectName == "" {
+			plan.projectName = bundle.Project.Name
+		}
+		if _, err := pm.model.GetProjectByProjectName(plan.projectName); err == nil {
+			return nil, fmt.Errorf("项目名称 %v 已经存在，请指定新的项目名称后重试", plan.projectName)
+		}
+		if err := pm.verifyOrganizationExist(request.OrgID); err != nil {
+			return nil, err
+		}
+		result.add("project", plan.projectName, ImportActionCreate)
+	} else if _, err := pm.model.GetProjectByID(projectID); err != nil {
+		return nil, err
+	}
+
+	envTags := map[string]bool{}
+	for _, env := range bundle.Envs {
+		if env.ArrangeEnv == "" || envTags[env.ArrangeEnv] {
+			return nil, fmt.Errorf("环境 %v 的环境标识为空或重复", env.Name)
+		}
+		envTags[env.ArrangeEnv] = true
+		for _, ref := range []*BundleSettingRef{env.Cluster, env.CIServer, env.Registry, env.ArgoCD, env.IssueTracker, env.BuildCluster, env.Cosign} {
+			resolveSetting(ref)
+		}
+		action := ImportActionCreate
+		if projectID != 0 {
+			if _, err := pm.model.GetProjectEnvBycIDAndEnvTag(env.ArrangeEnv, projectID); err == nil {
+				action = ImportActionUpdate
+			}
+		}
+		result.add("env", env.Name, action)
+	}
+	if projectID != 0 {
+		if envs, err := pm.model.GetProjectEnvs(projectID); err == nil {
+			for _, env := range envs {
+				envTags[env.ArrangeEnv] = true
+			}
+		}
+	}
+
+	bundleApps := map[string]bool{}
+	for _, app := range bundle.Apps {
+		bundleApps[app.FullName] = true
+	}
+	appNames := map[string]bool{}
+	for _, app := range bundle.Apps {
+		if app.Repo == nil || app.FullName == "" || appNames[app.FullName] {
+			return nil, fmt.Errorf("应用 %v 的代码仓库为空或重复", app.FullName)
+		}
+		appNames[app.FullName] = true
+		resolveSetting(app.Repo)
+		if app.CompileEnv != "" {
+			if compileEnv, err := pm.settingModel.GetCompileEnvByName(app.CompileEnv); err == nil {
+				plan.compileEnvs[app.CompileEnv] = compileEnv.ID
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("应用 %v 的编译环境 %v 不存在，将使用默认编译环境", app.FullName, app.CompileEnv))
+			}
+		}
+		if repoID, ok := plan.settings[app.Repo]; ok {
+			if scmApps, err := pm.scmAppModel.GetScmAppsByRepo(repoID, app.FullName); err == nil && len(scmApps) > 0 {
+				plan.scmApps[app] = scmApps[0].ID
+			}
+		}
+		action := ImportActionCreate
+		if scmAppID := plan.scmApps[app]; scmAppID != 0 && projectID != 0 {
+			if _, err := pm.model.GetProjectAppByScmID(projectID, scmAppID); err == nil {
+				action = ImportActionUpdate
+			}
+		}
+		result.add("app", app.FullName, action)
+		for _, arrange := range app.Arranges {
+			if !envTags[arrange.ArrangeEnv] {
+				missing = append(missing, fmt.Sprintf("应用 %v 编排的环境 %v 不存在", app.FullName, arrange.ArrangeEnv))
+			}
+			for _, image := range arrange.Images {
+				if image.App != "" && !bundleApps[image.App] {
+					missing = append(missing, fmt.Sprintf("应用 %v 编排的镜像 %v 关联的应用 %v 不存在", app.FullName, image.Image, image.App))
+				}
+			}
+			result.add("arrange", app.FullName+"/"+arrange.ArrangeEnv, ImportActionUpdate)
+		}
+	}
+
+	existingPipelines := map[string]bool{}
+	if projectID != 0 {
+		if pipelines, err := pm.model.GetProjectPipelines(projectID); err == nil {
+			for _, pipeline := range pipelines {
+				existingPipelines[pipeline.Name] = true
+			}
+		}
+	}
+	for _, pipeline := range bundle.Pipelines {
+		_, err := importPipelineConfig(pipeline.Config, func(tag string) (int64, error) {
+			if !envTags[tag] {
+				return 0, fmt.Errorf("环境 %v 不存在", tag)
+			}
+			return 0, nil
+		}, func(name string) (int64, error) {
+			if stepID, ok := plan.steps[name]; ok {
+				return stepID, nil
+			}
+			step, err := pm.pipelineModel.GetTaskTmplByName(name)
+			if err != nil {
+				return 0, fmt.Errorf("任务模板 %v 不存在", name)
+			}
+			plan.steps[name] = step.ID
+			return step.ID, nil
+		})
+		if err != nil {
+			missing = append(missing, fmt.Sprintf("流程 %v: %v", pipeline.Name, err.Error()))
+			continue
+		}
+		action := ImportActionCreate
+		if existingPipelines[pipeline.Name] {
+			action = ImportActionUpdate
+		}
+		result.add("pipeline", pipeline.Name, action)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("项目导出包引用的对象在当前实例中不存在: %v", strings.Join(missing, "; "))
+	}
+	return plan, nil
+}
+
+// exportPipelineConfig replace the stage_id with the arrange env and the step_id with the task template name
+func exportPipelineConfig(config string, envTag func(int64) (string, error), stepName func(int64) (string, error)) ([]map[string]interface{}, error) {
+	stages, err := decodePipelineConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, stage := range stages {
+		tag, err := envTag(configInt64(stage["stage_id"]))
+		if err != nil {
+			return nil, err
+		}
+		stage["stage_env"] = tag
+		for _, key := range []string{"stage_id", "id", "pipeline_id", "pipeline_instance_id"} {
+			delete(stage, key)
+		}
+		for _, step := range configSteps(stage) {
+			name, err := stepName(configInt64(step["step_id"]))
+			if err != nil {
+				return nil, err
+			}
+			step["step_template"] = name
+			delete(step, "step_id")
+		}
+	}
+	return stages, nil
+}
+
+// importPipelineConfig the reverse of exportPipelineConfig, returns the config json
+func importPipelineConfig(stages []map[string]interface{}, envID func(string) (int64, error), stepID func(string) (int64, error)) (interface{}, error) {
+	// copy the stages, so the bundle can be resolved more than once
+	raw, err := json.Marshal(stages)
+	if err != nil {
+		return nil, err
+	}
+	copied, err := decodePipelineConfig(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	for _, stage := range copied {
+		tag, _ := stage["stage_env"].(string)
+		id, err := envID(tag)
+		if err != nil {
+			return nil, err
+		}
+		stage["stage_id"] = id
+		stage["id"] = id
+		delete(stage, "stage_env")
+		for _, step := range configSteps(stage) {
+			name, _ := step["step_template"].(string)
+			id, err := stepID(name)
+			if err != nil {
+				return nil, err
+			}
+			step["step_id"] = id
+			delete(step, "step_template")
+		}
+	}
+	return copied, nil
+}
+
+func decodePipelineConfig(config string) ([]map[string]interface{}, error) {
+	stages := []map[string]interface{}{}
+	if strings.TrimSpace(config) == "" {
+		return stages, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(config)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&stages); err != nil {
+		return nil, fmt.Errorf("流程配置解析失败: %s", err.Error())
+	}
+	return stages, nil
+}
+
+func configSteps(stage map[string]interface{}) []map[string]interface{} {
+	items, _ := stage["steps"].([]interface{})
+	steps := []map[string]interface{}{}
+	for _, item := range items {
+		if step, ok := item.(map[string]interface{}); ok {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func configInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestPipelineConfigRoundTrip(t *testing.T) {
+	config := `[{"id":3,"stage_id":3,"name":"dev","pipeline_id":1,"steps":[{"step_id":11,"name":"build","index":1}]}]`
+	envTags := map[int64]string{3: "dev"}
+	stepNames := map[int64]string{11: "build"}
+	stages, err := exportPipelineConfig(config, func(id int64) (string, error) {
+		if tag, ok := envTags[id]; ok {
+			return tag, nil
+		}
+		return "", fmt.Errorf("env %v not found", id)
+	}, func(id int64) (string, error) {
+		return stepNames[id], nil
+	})
+	if err != nil {
+		t.Fatalf("export pipeline config: %v", err)
+	}
+	if stages[0]["stage_env"] != "dev" || stages[0]["stage_id"] != nil || stages[0]["pipeline_id"] != nil {
+		t.Fatalf("unexpected exported stage: %v", stages[0])
+	}
+	if step := configSteps(stages[0])[0]; step["step_template"] != "build" || step["step_id"] != nil {
+		t.Fatalf("unexpected exported step: %v", step)
+	}
+
+	imported, err := importPipelineConfig(stages, func(tag string) (int64, error) {
+		return 7, nil
+	}, func(name string) (int64, error) {
+		return 21, nil
+	})
+	if err != nil {
+		t.Fatalf("import pipeline config: %v", err)
+	}
+	raw, _ := json.Marshal(imported)
+	want := `[{"id":7,"name":"dev","stage_id":7,"steps":[{"index":1,"name":"build","step_id":21}]}]`
+	if string(raw) != want {
+		t.Fatalf("import pipeline config = %s, want %s", raw, want)
+	}
+	if stages[0]["stage_env"] != "dev" {
+		t.Fatalf("import should not modify the bundle")
+	}
+
+	if _, err := exportPipelineConfig(`[{"stage_id":4}]`, func(id int64) (string, error) {
+		return "", fmt.Errorf("env %v not found", id)
+	}, nil); err == nil {
+		t.Fatalf("export should fail when the env not found")
+	}
+}
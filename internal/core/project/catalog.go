@@ -0,0 +1,275 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// catalogDeployJobLimit the max deploy jobs scanned to find the deployed version of services
+const catalogDeployJobLimit = 1000
+
+// service health in catalog
+const (
+	ServiceHealthy   = "healthy"
+	ServiceUnhealthy = "unhealthy"
+	ServiceDeploying = "deploying"
+	ServiceUnknown   = "unknown"
+)
+
+// CatalogFilter the filter of service catalog, empty field means no filter
+type CatalogFilter struct {
+	Team string
+	Tier string
+	Name string
+}
+
+// CatalogEnvDeploy the deployed version of service in env
+type CatalogEnvDeploy struct {
+	EnvID        int64      `json:"env_id"`
+	EnvName      string     `json:"env_name"`
+	PublishID    int64      `json:"publish_id"`
+	ImageAddr    string     `json:"image_addr"`
+	ImageVersion string     `json:"image_version"`
+	BranchName   string     `json:"branch_name"`
+	CommitSha    string     `json:"commit_sha"`
+	DeployedAt   *time.Time `json:"deployed_at"`
+	Health       string     `json:"health"`
+}
+
+// CatalogService the service item of catalog
+type CatalogService struct {
+	ProjectAppID int64               `json:"project_app_id"`
+	ProjectID    int64               `json:"project_id"`
+	ProjectName  string              `json:"project_name"`
+	Owner        string              `json:"owner"`
+	Team         string              `json:"team"`
+	OnCall       string              `json:"on_call"`
+	Tier         string              `json:"tier"`
+	Name         string              `json:"name"`
+	FullName     string              `json:"full_name"`
+	Language     string              `json:"language"`
+	RepoURL      string              `json:"repo_url"`
+	Health       string              `json:"health"`
+	Envs         []*CatalogEnvDeploy `json:"envs"`
+}
+
+// UpdateProjectAppOwnership set the team, on-call contact and tier of app
+func (pm *ProjectManager) UpdateProjectAppOwnership(projectID, projectAppID int64, req *ProjectAppOwnershipReq) error {
+	projectApp, err := pm.model.GetProjectApp(projectAppID)
+	if err != nil || projectApp.ProjectID != projectID {
+		return fmt.Errorf("项目应用不存在")
+	}
+	req.Team = strings.TrimSpace(req.Team)
+	req.OnCall = strings.TrimSpace(req.OnCall)
+	if len(req.Team) > 64 {
+		return fmt.Errorf("团队名称不能超过64个字符")
+	}
+	if len(req.OnCall) > 128 {
+		return fmt.Errorf("值班联系人不能超过128个字符")
+	}
+	if err := verifyAppTier(req.Tier); err != nil {
+		return err
+	}
+	projectApp.Team = req.Team
+	projectApp.OnCall = req.OnCall
+	projectApp.Tier = req.Tier
+	return pm.model.UpdateProjectApp(projectApp)
+}
+
+// GetServiceCatalog aggregate the apps of projects into service catalog, with the owner and the deployed version of each env
+func (pm *ProjectManager) GetServiceCatalog(projectIDs []int64, filter *CatalogFilter) ([]*CatalogService, error) {
+	services := []*CatalogService{}
+	if len(projectIDs) == 0 {
+		return services, nil
+	}
+	projects, err := pm.model.GetProjects()
+	if err != nil {
+		return nil, err
+	}
+	projectMap := map[int64]*models.Project{}
+	for _, item := range projects {
+		projectMap[item.ID] = item
+	}
+	apps, err := pm.model.GetProjectAppsByProjectIDs(projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmIDs := []int64{}
+	for _, app := range apps {
+		scmIDs = append(scmIDs, app.ScmID)
+	}
+	scmApps, err := pm.scmAppModel.GetScmAppsByIDs(scmIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmAppMap := map[int64]*models.ScmApp{}
+	for _, item := range scmApps {
+		scmAppMap[item.ID] = item
+	}
+	envs, err := pm.model.GetProjectEnvsByProjectIDs(projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	envMap := map[int64]*models.ProjectEnv{}
+	for _, env := range envs {
+		envMap[env.ID] = env
+	}
+	deploys, err := latestEnvDeploys(projectIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, app := range apps {
+		project, ok := projectMap[app.ProjectID]
+		if !ok {
+			continue
+		}
+		service := &CatalogService{
+			ProjectAppID: app.ID,
+			ProjectID:    app.ProjectID,
+			ProjectName:  project.Name,
+			Owner:        project.Owner,
+			Team:         app.Team,
+			OnCall:       app.OnCall,
+			Tier:         app.Tier,
+			Envs:         []*CatalogEnvDeploy{},
+		}
+		if scmApp, ok := scmAppMap[app.ScmID]; ok {
+			service.Name = scmApp.Name
+			service.FullName = scmApp.FullName
+			service.Language = scmApp.Language
+			service.RepoURL = scmApp.Path
+		}
+		if !filter.match(service) {
+			continue
+		}
+		healths := []string{}
+		for envID, item := range deploys[app.ID] {
+			env, ok := envMap[envID]
+			if !ok {
+				continue
+			}
+			item.EnvName = env.Name
+			service.Envs = append(service.Envs, item)
+			healths = append(healths, item.Health)
+		}
+		sort.Slice(service.Envs, func(i, j int) bool { return service.Envs[i].EnvID < service.Envs[j].EnvID })
+		service.Health = serviceHealth(healths)
+		services = append(services, service)
+	}
+	sort.SliceStable(services, func(i, j int) bool {
+		if services[i].ProjectID != services[j].ProjectID {
+			return services[i].ProjectID < services[j].ProjectID
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services, nil
+}
+
+// latestEnvDeploys return the deployed version of apps in each env, keyed by project app id and env id,
+// the health is decided by the latest deploy job of app while the version is from the latest success one
+func latestEnvDeploys(projectIDs []int64) (map[int64]map[int64]*CatalogEnvDeploy, error) {
+	jobModel := dao.NewPublishJobModel()
+	jobs, err := jobModel.GetRecentDeployJobs(projectIDs, catalogDeployJobLimit)
+	if err != nil {
+		return nil, err
+	}
+	jobMap := map[int64]*models.PublishJob{}
+	jobIDs := []int64{}
+	for _, job := range jobs {
+		jobMap[job.ID] = job
+		jobIDs = append(jobIDs, job.ID)
+	}
+	jobApps, err := jobModel.GetPublishJobAppsByJobIDs(jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	// the newer jobs first, so the first job app met is the latest one
+	sort.Slice(jobApps, func(i, j int) bool { return jobApps[i].PublishJobID > jobApps[j].PublishJobID })
+
+	deploys := map[int64]map[int64]*CatalogEnvDeploy{}
+	for _, jobApp := range jobApps {
+		job := jobMap[jobApp.PublishJobID]
+		if _, ok := deploys[jobApp.ProjectAPPID]; !ok {
+			deploys[jobApp.ProjectAPPID] = map[int64]*CatalogEnvDeploy{}
+		}
+		item, ok := deploys[jobApp.ProjectAPPID][job.EnvID]
+		if !ok {
+			item = &CatalogEnvDeploy{EnvID: job.EnvID, Health: deployHealth(job.Status)}
+			deploys[jobApp.ProjectAPPID][job.EnvID] = item
+		}
+		if item.PublishID == 0 && job.Status == models.StatusSuccess {
+			deployedAt := job.UpdateAt
+			item.PublishID = job.PublishID
+			item.ImageAddr = jobApp.ImageAddr
+			item.ImageVersion = jobApp.ImageVersion
+			item.BranchName = jobApp.BranchName
+			item.CommitSha = jobApp.CommitSha
+			item.DeployedAt = &deployedAt
+		}
+	}
+	return deploys, nil
+}
+
+// deployHealth map the status of the latest deploy job to the health of service in env
+func deployHealth(status string) string {
+	switch status {
+	case models.StatusSuccess:
+		return ServiceHealthy
+	case models.StatusFailure, models.StatusInitFailure, models.StatusAbort:
+		return ServiceUnhealthy
+	case models.StatusInit, models.StatusRunning:
+		return ServiceDeploying
+	default:
+		return ServiceUnknown
+	}
+}
+
+// serviceHealth the worst health of service in all envs
+func serviceHealth(healths []string) string {
+	rank := map[string]int{ServiceUnknown: 0, ServiceHealthy: 1, ServiceDeploying: 2, ServiceUnhealthy: 3}
+	health := ServiceUnknown
+	for _, item := range healths {
+		if rank[item] > rank[health] {
+			health = item
+		}
+	}
+	return health
+}
+
+func (f *CatalogFilter) match(service *CatalogService) bool {
+	if f == nil {
+		return true
+	}
+	if f.Team != "" && service.Team != f.Team {
+		return false
+	}
+	if f.Tier != "" && service.Tier != f.Tier {
+		return false
+	}
+	if f.Name != "" && !strings.Contains(strings.ToLower(service.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	return true
+}
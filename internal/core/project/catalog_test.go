@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestServiceHealth(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		want     string
+	}{
+		{nil, ServiceUnknown},
+		{[]string{models.StatusSuccess}, ServiceHealthy},
+		{[]string{models.StatusSuccess, models.StatusRunning}, ServiceDeploying},
+		{[]string{models.StatusFailure, models.StatusRunning, models.StatusSuccess}, ServiceUnhealthy},
+		{[]string{models.StatusUnknown}, ServiceUnknown},
+	}
+	for _, tt := range tests {
+		healths := []string{}
+		for _, status := range tt.statuses {
+			healths = append(healths, deployHealth(status))
+		}
+		if got := serviceHealth(healths); got != tt.want {
+			t.Errorf("serviceHealth(%v) = %v, want %v", tt.statuses, got, tt.want)
+		}
+	}
+}
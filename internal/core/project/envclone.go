@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// CloneProjectEnv create a new env with the settings of env, and copy the arranges of project apps to it,
+// the ingress hosts of arranges are overridden so the envs do not serve the same hosts
+func (pm *ProjectManager) CloneProjectEnv(projectID, envID int64, request *ProjectEnvCloneReq, creator string) (*ProjectEnvCloneRsp, error) {
+	source, err := pm.model.GetProjectEnvByID(envID)
+	if err != nil || source.ProjectID != projectID {
+		return nil, fmt.Errorf("项目环境不存在")
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	request.ArrangeEnv = strings.TrimSpace(request.ArrangeEnv)
+	request.Namespace = strings.TrimSpace(request.Namespace)
+	if request.Name == "" || request.ArrangeEnv == "" || request.Namespace == "" {
+		return nil, fmt.Errorf("环境名称、环境标识和命名空间不能为空")
+	}
+	existStage, err := pm.model.GetProjectEnvBycIDAndEnvTag(request.ArrangeEnv, projectID)
+	if err == nil {
+		return nil, fmt.Errorf("环境标识必须唯一，%v 环境已经使用此标识 %s，请你更新后重试", existStage.Name, request.ArrangeEnv)
+	}
+	if err != orm.ErrNoRows {
+		return nil, err
+	}
+	project, err := pm.model.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.verifyEnvQuota(project.OrgID); err != nil {
+		return nil, err
+	}
+
+	env := *source
+	env.Addons = models.NewAddons()
+	env.Name = request.Name
+	env.ArrangeEnv = request.ArrangeEnv
+	env.Namespace = request.Namespace
+	env.Description = fmt.Sprintf("克隆自环境: %v", source.Name)
+	env.PreviewSource = false
+	env.Creator = creator
+	if err := pm.model.CreateProjectEnv(&env); err != nil {
+		return nil, err
+	}
+
+	prefix := request.HostPrefix
+	if prefix == "" {
+		prefix = request.ArrangeEnv
+	}
+	rewriteHost := func(host string) string {
+		return cloneHost(host, prefix, request.Hosts)
+	}
+	projectApps, err := pm.model.GetProjectApps(projectID)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &ProjectEnvCloneRsp{Env: &env, Hosts: []string{}}
+	appManager := apps.NewAppManager()
+	for _, app := range projectApps {
+		if _, err := appManager.GetRealArrange(app.ID, source.ID); err != nil {
+			log.Log.Debug("app: %v has no arrange in env: %v, skip clone", app.ID, source.ID)
+			continue
+		}
+		hosts, err := appManager.CloneArrange(app.ID, source.ID, env.ID, rewriteHost, creator)
+		if err != nil {
+			return nil, fmt.Errorf("克隆应用: %v 编排失败: %s", app.ID, err.Error())
+		}
+		rsp.Hosts = append(rsp.Hosts, hosts...)
+	}
+	log.Log.Info("env: %v cloned to env: %v by %v", source.Name, env.Name, creator)
+	return rsp, nil
+}
+
+// GetPreviewEnvs return the preview envs of the merge requests, the latest first
+func (pm *ProjectManager) GetPreviewEnvs(projectID int64) ([]*models.PreviewEnv, error) {
+	return pm.model.GetPreviewEnvs(projectID)
+}
+
+// cloneHost return the host of cloned env, the wildcard is kept and the host is prefixed by default
+func cloneHost(host, prefix string, hosts map[string]string) string {
+	if newHost, ok := hosts[host]; ok && newHost != "" {
+		return newHost
+	}
+	if strings.HasPrefix(host, "*.") {
+		return "*." + cloneHost(host[2:], prefix, nil)
+	}
+	return prefix + "-" + host
+}
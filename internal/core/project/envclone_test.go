@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import "testing"
+
+func TestCloneHost(t *testing.T) {
+	hosts := map[string]string{"demo.example.com": "demo-test.example.com"}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"demo.example.com", "demo-test.example.com"},
+		{"api.example.com", "pr-1-api.example.com"},
+		{"*.example.com", "*.pr-1-example.com"},
+	}
+	for _, tt := range tests {
+		if got := cloneHost(tt.host, "pr-1", hosts); got != tt.want {
+			t.Errorf("cloneHost(%v) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
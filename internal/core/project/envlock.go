@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// envLockHistorySize the count of the latest locks returned with the lock status
+const envLockHistorySize = 20
+
+// GetProjectEnvLock return the lock status and the latest locks of env
+func (pm *ProjectManager) GetProjectEnvLock(projectID, envID int64) (*EnvLockRsp, error) {
+	if _, err := pm.getProjectEnv(projectID, envID); err != nil {
+		return nil, err
+	}
+	rsp := &EnvLockRsp{}
+	lock, err := pm.model.GetActiveEnvLock(envID, time.Now())
+	if err == nil {
+		rsp.Locked = true
+		rsp.Current = lock
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+	if rsp.History, err = pm.model.GetEnvLocks(envID, envLockHistorySize); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// LockProjectEnv lock the env to block the deploy and auto promote, only admin could lock the env
+func (pm *ProjectManager) LockProjectEnv(projectID, envID int64, request *EnvLockReq, user string) (*models.EnvLock, error) {
+	if !dao.UserIsAdmin(user) {
+		return nil, fmt.Errorf("仅管理员可以锁定环境")
+	}
+	env, err := pm.getProjectEnv(projectID, envID)
+	if err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("锁定原因不能为空")
+	}
+	if len(reason) > 256 {
+		return nil, fmt.Errorf("锁定原因不能超过256个字符")
+	}
+	now := time.Now()
+	lock := &models.EnvLock{
+		Addons:    models.NewAddons(),
+		ProjectID: projectID,
+		EnvID:     envID,
+		Reason:    reason,
+		Locker:    user,
+	}
+	if strings.TrimSpace(request.UnlockAt) != "" {
+		unlockAt, err := pipelinemgr.ParseDeployTime(request.UnlockAt)
+		if err != nil {
+			return nil, fmt.Errorf("自动解锁时间: %v 无效，格式为 2006-01-02 15:04", request.UnlockAt)
+		}
+		if !unlockAt.After(now) {
+			return nil, fmt.Errorf("自动解锁时间必须晚于当前时间")
+		}
+		lock.UnlockAt = &unlockAt
+	}
+	if current, err := pm.model.GetActiveEnvLock(envID, now); err == nil {
+		return nil, fmt.Errorf("%s", pipelinemgr.EnvLockedReason(env.Name, current))
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+	if lock.ID, err = pm.model.CreateEnvLock(lock); err != nil {
+		return nil, err
+	}
+	log.Log.Warn("project: %v env: %v was locked by %v: %s", projectID, envID, user, reason)
+	return lock, nil
+}
+
+// UnlockProjectEnv unlock the env before the scheduled unlock time, only admin could unlock the env
+func (pm *ProjectManager) UnlockProjectEnv(projectID, envID int64, user string) error {
+	if !dao.UserIsAdmin(user) {
+		return fmt.Errorf("仅管理员可以解锁环境")
+	}
+	if _, err := pm.getProjectEnv(projectID, envID); err != nil {
+		return err
+	}
+	now := time.Now()
+	lock, err := pm.model.GetActiveEnvLock(envID, now)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return fmt.Errorf("环境未被锁定")
+		}
+		return err
+	}
+	lock.Unlocker = user
+	lock.UnlockedAt = &now
+	log.Log.Info("project: %v env: %v was unlocked by %v", projectID, envID, user)
+	return pm.model.UpdateEnvLock(lock)
+}
+
+func (pm *ProjectManager) getProjectEnv(projectID, envID int64) (*models.ProjectEnv, error) {
+	env, err := pm.model.GetProjectEnvByID(envID)
+	if err != nil {
+		return nil, fmt.Errorf("获取项目环境: %v 失败: %s", envID, err.Error())
+	}
+	if env.ProjectID != projectID {
+		return nil, fmt.Errorf("环境: %v 不属于此项目，操作拒绝", envID)
+	}
+	return env, nil
+}
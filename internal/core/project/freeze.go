@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// GetDeployFreezes return the freeze periods of project which not ended yet
+func (pm *ProjectManager) GetDeployFreezes(projectID int64) ([]*models.DeployFreeze, error) {
+	return pm.model.GetDeployFreezes(projectID)
+}
+
+// CreateDeployFreeze ..
+func (pm *ProjectManager) CreateDeployFreeze(projectID int64, request *DeployFreezeReq, creator string) (int64, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return 0, fmt.Errorf("封版名称不能为空")
+	}
+	startAt, err := pipelinemgr.ParseDeployTime(request.StartAt)
+	if err != nil {
+		return 0, fmt.Errorf("开始时间: %v 无效，格式为 2006-01-02 15:04", request.StartAt)
+	}
+	endAt, err := pipelinemgr.ParseDeployTime(request.EndAt)
+	if err != nil {
+		return 0, fmt.Errorf("结束时间: %v 无效，格式为 2006-01-02 15:04", request.EndAt)
+	}
+	if !endAt.After(startAt) {
+		return 0, fmt.Errorf("结束时间必须晚于开始时间")
+	}
+	if request.EnvID != 0 {
+		envModel, err := pm.model.GetProjectEnvByID(request.EnvID)
+		if err != nil {
+			return 0, fmt.Errorf("获取项目环境: %v 失败: %s", request.EnvID, err.Error())
+		}
+		if envModel.ProjectID != projectID {
+			return 0, fmt.Errorf("环境: %v 不属于此项目，操作拒绝", request.EnvID)
+		}
+	}
+	return pm.model.CreateDeployFreeze(&models.DeployFreeze{
+		Addons:    models.NewAddons(),
+		ProjectID: projectID,
+		EnvID:     request.EnvID,
+		Name:      name,
+		Reason:    request.Reason,
+		StartAt:   startAt,
+		EndAt:     endAt,
+		Creator:   creator,
+	})
+}
+
+// DeleteDeployFreeze ..
+func (pm *ProjectManager) DeleteDeployFreeze(projectID, freezeID int64) error {
+	freeze, err := pm.model.GetDeployFreezeByID(freezeID)
+	if err != nil {
+		return err
+	}
+	if freeze.ProjectID != projectID {
+		return fmt.Errorf("封版: %v 不属于此项目，操作拒绝", freezeID)
+	}
+	return pm.model.DeleteDeployFreeze(freeze)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"fmt"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// verifyOrganizationExist the project is allowed to be created without organization
+func (pm *ProjectManager) verifyOrganizationExist(orgID int64) error {
+	if orgID == 0 {
+		return nil
+	}
+	if _, err := pm.orgModel.GetOrganizationByID(orgID); err != nil {
+		log.Log.Error("get organization: %v occur error: %s", orgID, err.Error())
+		return fmt.Errorf("组织 %v 不存在", orgID)
+	}
+	return nil
+}
+
+// verifyOrganizationMember only the members of organization are allowed to join the projects of organization,
+// the system admin is excepted
+func (pm *ProjectManager) verifyOrganizationMember(project *models.Project, user string) error {
+	if project.OrgID == 0 || dao.UserIsAdmin(user) {
+		return nil
+	}
+	if _, err := pm.orgModel.GetOrganizationUser(project.OrgID, user); err != nil {
+		if err != orm.ErrNoRows {
+			log.Log.Error("get member: %v of organization: %v occur error: %s", user, project.OrgID, err.Error())
+		}
+		return fmt.Errorf("用户 %v 不是项目所属组织的成员，请先将其加入组织", user)
+	}
+	return nil
+}
+
+// verifyEnvSettings the integrate settings of env must be shared or belong to the organization of project
+func (pm *ProjectManager) verifyEnvSettings(orgID int64, env *models.ProjectEnv) error {
+	pool, err := pipelinemgr.ParseCIServerPool(env.CIServerPool)
+	if err != nil {
+		return err
+	}
+	settingIDs := []int64{env.Cluster, env.CIServer, env.Registry, env.ArgoCD, env.IssueTracker, env.BuildCluster, env.Cosign}
+	// the settings of ci server pool are at the tail
+	poolStart := len(settingIDs)
+	settingIDs = append(settingIDs, pool...)
+	for i, settingID := range settingIDs {
+		if settingID == 0 {
+			continue
+		}
+		setting, err := pm.settingModel.GetIntegrateSettingByID(settingID)
+		if err != nil {
+			log.Log.Error("get integrate setting: %v occur error: %s", settingID, err.Error())
+			return fmt.Errorf("集成配置 %v 不存在", settingID)
+		}
+		if setting.OrgID != 0 && setting.OrgID != orgID {
+			return fmt.Errorf("集成配置 %v 不属于项目所在的组织", setting.Name)
+		}
+		if settingID == env.BuildCluster && setting.Type != settings.KubernetesType {
+			return fmt.Errorf("构建集群 %v 必须是 kubernetes 集成配置", setting.Name)
+		}
+		if settingID == env.Cosign && setting.Type != settings.CosignType {
+			return fmt.Errorf("镜像签名验证 %v 必须是 cosign 集成配置", setting.Name)
+		}
+		if i >= poolStart && setting.Type != settings.JenkinsType {
+			return fmt.Errorf("CI 服务器池中的 %v 必须是 jenkins 集成配置", setting.Name)
+		}
+	}
+	if env.BuildNamespace != "" {
+		if errs := validation.IsDNS1123Label(env.BuildNamespace); len(errs) != 0 {
+			return fmt.Errorf("无效的构建命名空间: %v", env.BuildNamespace)
+		}
+	}
+	return nil
+}
+
+// verifyEnvQuota the envs of all the projects of organization must not exceed the quota
+func (pm *ProjectManager) verifyEnvQuota(orgID int64) error {
+	if orgID == 0 {
+		return nil
+	}
+	org, err := pm.orgModel.GetOrganizationByID(orgID)
+	if err != nil {
+		return err
+	}
+	if org.MaxEnvs <= 0 {
+		return nil
+	}
+	count, err := pm.orgModel.CountOrganizationEnvs(orgID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(org.MaxEnvs) {
+		return fmt.Errorf("组织 %v 的环境数量已达到上限 %v，请联系管理员调整配额", org.Name, org.MaxEnvs)
+	}
+	return nil
+}
+
+// MoveProjectToOrganization the envs of project must only use the shared settings or the settings of target organization,
+// the members of project are added to the target organization
+func (pm *ProjectManager) MoveProjectToOrganization(projectID, orgID int64) error {
+	project, err := pm.model.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+	if err := pm.verifyOrganizationExist(orgID); err != nil {
+		return err
+	}
+	envs, err := pm.model.GetProjectEnvs(projectID)
+	if err != nil {
+		return err
+	}
+	for _, env := range envs {
+		if err := pm.verifyEnvSettings(orgID, env); err != nil {
+			return fmt.Errorf("环境 %v: %v", env.Name, err.Error())
+		}
+	}
+	if orgID != 0 {
+		if org, err := pm.orgModel.GetOrganizationByID(orgID); err == nil && org.MaxEnvs > 0 {
+			count, err := pm.orgModel.CountOrganizationEnvs(orgID)
+			if err != nil {
+				return err
+			}
+			if project.OrgID != orgID && count+int64(len(envs)) > int64(org.MaxEnvs) {
+				return fmt.Errorf("组织 %v 的环境数量将超过上限 %v，请联系管理员调整配额", org.Name, org.MaxEnvs)
+			}
+		}
+		for _, user := range pm.getProjectUsers(projectID) {
+			if _, err := pm.orgModel.GetOrganizationUser(orgID, user.User); err == nil {
+				continue
+			}
+			member := &models.OrganizationUser{OrgID: orgID, User: user.User, Role: models.OrgRoleMember}
+			if _, err := pm.orgModel.CreateOrganizationUser(member); err != nil {
+				log.Log.Error("add project member: %v to organization: %v occur error: %s", user.User, orgID, err.Error())
+				return fmt.Errorf("添加组织成员 %v 失败，请重试", user.User)
+			}
+		}
+	}
+	project.OrgID = orgID
+	return pm.model.UpdateProject(project)
+}
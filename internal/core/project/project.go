@@ -42,6 +42,9 @@ type ProjectManager struct {
 	userrolesModel *dao.UserRolesModel
 	publishModel   *dao.PublishModel
 	settingModel   *dao.SysSettingModel
+	orgModel       *dao.OrganizationModel
+	arrangeModel   *dao.AppArrangeModel
+	templateModel  *dao.ServiceTemplateModel
 }
 
 // NewProjectManager ...
@@ -54,11 +57,23 @@ func NewProjectManager() *ProjectManager {
 		k8sModel:       dao.NewK8sClusterModel(),
 		userrolesModel: dao.NewUserRolesModel(),
 		publishModel:   dao.NewPublishModel(),
+		orgModel:       dao.NewOrganizationModel(),
+		arrangeModel:   dao.NewAppArrangeModel(),
+		templateModel:  dao.NewServiceTemplateModel(),
 	}
 }
 
 // CreateProject ...
 func (pm *ProjectManager) CreateProject(user, groupName string, p *ProjectReq) (*models.ProjectResponse, error) {
+	if p.MaxConcurrentBuilds < 0 {
+		return nil, fmt.Errorf("最大并发构建数不能小于0")
+	}
+	if p.RetentionMonths < 0 {
+		return nil, fmt.Errorf("数据保留月数不能小于0")
+	}
+	if err := pm.verifyOrganizationExist(p.OrgID); err != nil {
+		return nil, err
+	}
 
 	projectModel := models.Project{
 		Addons:      models.NewAddons(),
@@ -67,6 +82,10 @@ func (pm *ProjectManager) CreateProject(user, groupName string, p *ProjectReq) (
 		Owner:       user,
 		Creator:     user,
 		Status:      models.ProjectRuning,
+
+		MaxConcurrentBuilds: p.MaxConcurrentBuilds,
+		RetentionMonths:     p.RetentionMonths,
+		OrgID:               p.OrgID,
 	}
 	projectID, err := pm.model.CreateProjectifNotExist(&projectModel)
 	if err != nil {
@@ -133,6 +152,7 @@ func (pm *ProjectManager) GetProjectResp(projectID int64) *models.ProjectRespons
 		Status:      project.Status,
 		Creator:     project.Creator,
 		Owner:       project.Owner,
+		OrgID:       project.OrgID,
 		Members:     numbers,
 		MembersName: membersName,
 	}
@@ -180,6 +200,14 @@ func (pm *ProjectManager) UpdateProject(user string, projectID int64, p *Project
 		modelProject.Name = p.Name
 	}
 	modelProject.Description = p.Description
+	if p.MaxConcurrentBuilds < 0 {
+		return fmt.Errorf("最大并发构建数不能小于0")
+	}
+	modelProject.MaxConcurrentBuilds = p.MaxConcurrentBuilds
+	if p.RetentionMonths < 0 {
+		return fmt.Errorf("数据保留月数不能小于0")
+	}
+	modelProject.RetentionMonths = p.RetentionMonths
 	// if p.Owner changed, update project constraint
 	if UpdateConstraint {
 		// TODO: add project constraint for owner
@@ -278,6 +306,13 @@ func (pm *ProjectManager) AddProjectMembers(projectID int64, request *ProjectNum
 		log.Log.Error("when add project number, get role by id occur error: %v", err.Error())
 		return fmt.Errorf("请选择有效的角色后重试")
 	}
+	project, err := pm.model.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+	if err := pm.verifyOrganizationMember(project, request.User); err != nil {
+		return err
+	}
 	pp := &models.ProjectUser{
 		ProjectID: projectID,
 		User:      request.User,
@@ -0,0 +1,501 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// serviceArrangeSkeleton the deployment and service of the app, the port and replicas can be overridden by variables
+const serviceArrangeSkeleton = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .App.Name }}
+  namespace: {{ .Env.Namespace }}
+  labels:
+    app: {{ .App.Name }}
+spec:
+  replicas: {{ .Vars.replicas | default "1" }}
+  selector:
+    matchLabels:
+      app: {{ .App.Name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .App.Name }}
+    spec:
+      containers:
+      - name: {{ .App.Name }}
+        image: {{ .App.ImageAddr }}
+        ports:
+        - containerPort: {{ .Vars.port | default "%v" }}
+        readinessProbe:
+          tcpSocket:
+            port: {{ .Vars.port | default "%v" }}
+          initialDelaySeconds: %v
+        resources:
+          requests:
+            cpu: 100m
+            memory: %v
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .App.Name }}
+  namespace: {{ .Env.Namespace }}
+spec:
+  selector:
+    app: {{ .App.Name }}
+  ports:
+  - port: {{ .Vars.port | default "%v" }}
+    targetPort: {{ .Vars.port | default "%v" }}
+`
+
+// serviceSkeleton the defaults of the language
+type serviceSkeleton struct {
+	compileEnv   string
+	port         int
+	initialDelay int
+	memory       string
+}
+
+var serviceSkeletons = map[string]serviceSkeleton{
+	"go":   {compileEnv: "golang", port: 8080, initialDelay: 5, memory: "64Mi"},
+	"java": {compileEnv: "maven", port: 8080, initialDelay: 30, memory: "512Mi"},
+	"node": {compileEnv: "node", port: 3000, initialDelay: 10, memory: "128Mi"},
+}
+
+// ServiceArrangeSkeleton return the arrange skeleton of the language, empty if the language has no skeleton
+func ServiceArrangeSkeleton(language string) string {
+	skeleton, ok := serviceSkeletons[strings.ToLower(language)]
+	if !ok {
+		return ""
+	}
+	p := skeleton.port
+	return fmt.Sprintf(serviceArrangeSkeleton, p, p, skeleton.initialDelay, skeleton.memory, p, p)
+}
+
+// DefaultServiceTemplates the built-in templates of go/java/node services, which build and deploy into the target env
+func DefaultServiceTemplates() []*ServiceTemplateReq {
+	pipelineConfig := []map[string]interface{}{
+		{
+			"name":  "开发环境",
+			"index": 1,
+			"steps": []interface{}{
+				map[string]interface{}{"name": "应用构建", "type": "build", "step_template": "应用构建", "index": 1},
+				map[string]interface{}{"name": "应用部署", "type": "deploy", "step_template": "应用部署", "index": 2},
+			},
+		},
+	}
+	templates := []*ServiceTemplateReq{}
+	for _, language := range []string{"go", "java", "node"} {
+		templates = append(templates, &ServiceTemplateReq{
+			Name:           fmt.Sprintf("%v-service", language),
+			Description:    fmt.Sprintf("%v 微服务, 构建后部署到开发环境", language),
+			Language:       language,
+			CompileEnv:     serviceSkeletons[language].compileEnv,
+			Dockerfile:     "Dockerfile",
+			PipelineName:   "开发流程",
+			PipelineConfig: pipelineConfig,
+		})
+	}
+	return templates
+}
+
+// ServiceTemplateReq ..
+type ServiceTemplateReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Language    string `json:"language"`
+	// CompileEnv the name of compile env
+	CompileEnv string `json:"compile_env"`
+	BuildPath  string `json:"build_path"`
+	Dockerfile string `json:"dockerfile"`
+	// Arrange the arrange template, the skeleton of language is used when empty
+	Arrange   string            `json:"arrange"`
+	Variables map[string]string `json:"variables"`
+	// PipelineConfig the stages use stage_env as the arrange env, empty means the env of service,
+	// the steps use step_template as the task template name
+	PipelineName   string                   `json:"pipeline_name"`
+	PipelineConfig []map[string]interface{} `json:"pipeline_config"`
+}
+
+// Verify ..
+func (req *ServiceTemplateReq) Verify() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("请输入模板名称")
+	}
+	if len(req.Name) > 64 {
+		return fmt.Errorf("模板名称不能超过64个字符")
+	}
+	if req.Language == "" {
+		return fmt.Errorf("请选择开发语言")
+	}
+	if strings.TrimSpace(req.Arrange) == "" {
+		req.Arrange = ServiceArrangeSkeleton(req.Language)
+		if req.Arrange == "" {
+			return fmt.Errorf("开发语言 %v 没有默认的应用编排，请输入应用编排", req.Language)
+		}
+	}
+	if len(req.PipelineConfig) > 0 && strings.TrimSpace(req.PipelineName) == "" {
+		return fmt.Errorf("请输入流程名称")
+	}
+	return nil
+}
+
+// ServiceTemplateRsp ..
+type ServiceTemplateRsp struct {
+	*models.ServiceTemplate
+	Variables      map[string]string        `json:"variables"`
+	PipelineConfig []map[string]interface{} `json:"pipeline_config"`
+}
+
+// ServiceScaffoldReq create the app of the template into the project, then arrange and deploy it into the env
+type ServiceScaffoldReq struct {
+	TemplateID int64  `json:"template_id"`
+	RepoID     int64  `json:"repo_id"`
+	Name       string `json:"name"`
+	FullName   string `json:"full_name"`
+	Path       string `json:"path"`
+	BranchName string `json:"branch_name"`
+	// ArrangeEnv the env which the app arranged in, default is dev
+	ArrangeEnv string `json:"arrange_env"`
+	// Image the image of app, default is <registry of env>/<project name>/<app name>
+	Image     string            `json:"image"`
+	Variables map[string]string `json:"variables"`
+}
+
+// Verify ..
+func (req *ServiceScaffoldReq) Verify() error {
+	if req.TemplateID == 0 {
+		return fmt.Errorf("请选择服务模板")
+	}
+	if req.RepoID == 0 {
+		return fmt.Errorf("请选择代码仓库")
+	}
+	if req.Name == "" || req.FullName == "" || req.Path == "" {
+		return fmt.Errorf("请输入有效的『仓库名』及『路径』")
+	}
+	if req.ArrangeEnv == "" {
+		req.ArrangeEnv = "dev"
+	}
+	return nil
+}
+
+// ServiceScaffoldRsp ..
+type ServiceScaffoldRsp struct {
+	ScmAppID     int64    `json:"scm_app_id"`
+	ProjectAppID int64    `json:"project_app_id"`
+	EnvID        int64    `json:"env_id"`
+	PipelineID   int64    `json:"pipeline_id"`
+	Warnings     []string `json:"warnings"`
+}
+
+// GetServiceTemplates ..
+func (pm *ProjectManager) GetServiceTemplates() ([]*ServiceTemplateRsp, error) {
+	items, err := pm.templateModel.GetServiceTemplates()
+	if err != nil {
+		return nil, err
+	}
+	rsp := []*ServiceTemplateRsp{}
+	for _, item := range items {
+		rsp = append(rsp, formatServiceTemplate(item))
+	}
+	return rsp, nil
+}
+
+// GetServiceTemplate ..
+func (pm *ProjectManager) GetServiceTemplate(templateID int64) (*ServiceTemplateRsp, error) {
+	item, err := pm.templateModel.GetServiceTemplateByID(templateID)
+	if err != nil {
+		return nil, err
+	}
+	return formatServiceTemplate(item), nil
+}
+
+// CreateServiceTemplate ..
+func (pm *ProjectManager) CreateServiceTemplate(req *ServiceTemplateReq, creator string) (int64, error) {
+	if err := pm.verifyServiceTemplate(0, req); err != nil {
+		return 0, err
+	}
+	item := &models.ServiceTemplate{
+		Addons:  models.NewAddons(),
+		Creator: creator,
+	}
+	if err := setServiceTemplate(item, req); err != nil {
+		return 0, err
+	}
+	return pm.templateModel.CreateServiceTemplate(item)
+}
+
+// UpdateServiceTemplate ..
+func (pm *ProjectManager) UpdateServiceTemplate(templateID int64, req *ServiceTemplateReq) error {
+	item, err := pm.templateModel.GetServiceTemplateByID(templateID)
+	if err != nil {
+		return err
+	}
+	if err := pm.verifyServiceTemplate(templateID, req); err != nil {
+		return err
+	}
+	if err := setServiceTemplate(item, req); err != nil {
+		return err
+	}
+	item.Addons = item.Addons.UpdateAddons()
+	return pm.templateModel.UpdateServiceTemplate(item)
+}
+
+// DeleteServiceTemplate ..
+func (pm *ProjectManager) DeleteServiceTemplate(templateID int64) error {
+	item, err := pm.templateModel.GetServiceTemplateByID(templateID)
+	if err != nil {
+		return err
+	}
+	return pm.templateModel.DeleteServiceTemplate(item)
+}
+
+// ScaffoldService create the scm app of the template and add it into the project,
+// then set the arrange of the env and create the pipeline if the project does not have it
+func (pm *ProjectManager) ScaffoldService(projectID int64, req *ServiceScaffoldReq, creator string) (*ServiceScaffoldRsp, error) {
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+	project, err := pm.model.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := pm.templateModel.GetServiceTemplateByID(req.TemplateID)
+	if err != nil {
+		return nil, fmt.Errorf("服务模板 %v 不存在", req.TemplateID)
+	}
+	env, err := pm.model.GetProjectEnvBycIDAndEnvTag(req.ArrangeEnv, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("项目环境 %v 不存在，请先创建环境", req.ArrangeEnv)
+	}
+	rsp := &ServiceScaffoldRsp{EnvID: env.ID, Warnings: []string{}}
+
+	// resolve all the references before any change
+	var compileEnvID int64
+	if tpl.CompileEnv != "" {
+		if compileEnv, err := pm.settingModel.GetCompileEnvByName(tpl.CompileEnv); err == nil {
+			compileEnvID = compileEnv.ID
+			if compileEnv.Deprecated {
+				compileEnvID = compileEnv.ReplacedBy
+				rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("编译环境 %v 已经废弃，将使用其替代的编译环境", tpl.CompileEnv))
+			}
+		} else {
+			rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("编译环境 %v 不存在，将使用默认编译环境", tpl.CompileEnv))
+		}
+	}
+	if scmApps, err := pm.scmAppModel.GetScmAppsByRepo(req.RepoID, req.FullName); err == nil && len(scmApps) > 0 {
+		if _, err := pm.model.GetProjectAppByScmID(projectID, scmApps[0].ID); err == nil {
+			return nil, fmt.Errorf("应用 %v 已经添加到当前项目", req.FullName)
+		}
+	}
+	image := strings.TrimSpace(req.Image)
+	if image == "" {
+		if image, err = pm.defaultServiceImage(env.Registry, project.Name, req.Name); err != nil {
+			return nil, err
+		}
+	}
+	variables, err := apps.DecodeArrangeVariables(tpl.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("服务模板的编排变量解析失败: %s", err.Error())
+	}
+	for key, value := range req.Variables {
+		variables[key] = value
+	}
+	var pipelineConfig interface{}
+	createPipeline := false
+	if tpl.PipelineConfig != "" {
+		pipelines, err := pm.model.GetProjectPipelines(projectID)
+		if err != nil {
+			return nil, err
+		}
+		exist := false
+		for _, pipeline := range pipelines {
+			exist = exist || pipeline.Name == tpl.PipelineName
+		}
+		if exist {
+			rsp.Warnings = append(rsp.Warnings, fmt.Sprintf("流程 %v 已经存在，跳过创建", tpl.PipelineName))
+		} else {
+			stages, err := decodePipelineConfig(tpl.PipelineConfig)
+			if err != nil {
+				return nil, err
+			}
+			pipelineConfig, err = importPipelineConfig(stages, func(tag string) (int64, error) {
+				if tag == "" || tag == env.ArrangeEnv {
+					return env.ID, nil
+				}
+				stageEnv, err := pm.model.GetProjectEnvBycIDAndEnvTag(tag, projectID)
+				if err != nil {
+					return 0, fmt.Errorf("项目环境 %v 不存在", tag)
+				}
+				return stageEnv.ID, nil
+			}, pm.taskTmplID)
+			if err != nil {
+				return nil, fmt.Errorf("流程 %v: %v", tpl.PipelineName, err.Error())
+			}
+			createPipeline = true
+		}
+	}
+
+	appManager := apps.NewAppManager()
+	rsp.ScmAppID, err = appManager.CreateSCMApp(&apps.ScmAppReq{
+		Name:         req.Name,
+		CompileEnvID: compileEnvID,
+		Language:     tpl.Language,
+		Path:         req.Path,
+		RepoID:       req.RepoID,
+		FullName:     req.FullName,
+		BranchName:   req.BranchName,
+		BuildPath:    tpl.BuildPath,
+		Dockerfile:   tpl.Dockerfile,
+	}, creator)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.CreateProjectApp(projectID, &ProjectAppReq{SCMID: rsp.ScmAppID}, creator); err != nil {
+		return nil, err
+	}
+	projectApp, err := pm.model.GetProjectAppByScmID(projectID, rsp.ScmAppID)
+	if err != nil {
+		return nil, err
+	}
+	rsp.ProjectAppID = projectApp.ID
+	err = appManager.SetArrange(projectApp.ID, env.ID, &apps.AppArrangeReq{
+		Config:    tpl.Arrange,
+		Variables: variables,
+		ImageMapings: []apps.ImageMaping{
+			{Name: req.Name, Image: image, ProjectAppID: projectApp.ID, ImageTagType: models.SystemDefaultTag},
+		},
+	}, creator)
+	if err != nil {
+		return rsp, fmt.Errorf("设置应用编排失败: %v", err.Error())
+	}
+	if createPipeline {
+		_, defaultErr := pm.model.GetDefaultPipeline(projectID)
+		pipelineReq := &PipelineReq{Name: tpl.PipelineName, Description: tpl.Description, ProjectID: projectID, IsDefault: defaultErr != nil}
+		if rsp.PipelineID, err = pm.CreateProjectPipeline(pipelineReq, creator); err != nil {
+			return rsp, fmt.Errorf("创建流程 %v 失败: %v", tpl.PipelineName, err.Error())
+		}
+		pipelineReq.Config = pipelineConfig
+		if err := pm.UpdateProjectPipelineConfig(pipelineReq, creator, projectID, rsp.PipelineID); err != nil {
+			return rsp, fmt.Errorf("创建流程 %v 失败: %v", tpl.PipelineName, err.Error())
+		}
+	}
+	log.Log.Info("scaffold service: %v of template: %v into project: %v by %v", req.FullName, tpl.Name, projectID, creator)
+	return rsp, nil
+}
+
+// defaultServiceImage return the image <registry of env>/<project name>/<app name>
+func (pm *ProjectManager) defaultServiceImage(registryID int64, projectName, appName string) (string, error) {
+	if registryID == 0 {
+		return "", fmt.Errorf("项目环境未配置镜像仓库，请输入应用镜像")
+	}
+	registry, err := settings.NewSettingManager().GetIntegrateSettingByID(registryID)
+	if err != nil {
+		return "", err
+	}
+	conf, ok := registry.Config.(*settings.RegistryConfig)
+	if !ok || conf.URL == "" {
+		return "", fmt.Errorf("镜像仓库 %v 配置无效，请输入应用镜像", registry.Name)
+	}
+	addr := strings.TrimSuffix(conf.URL, "/")
+	if index := strings.Index(addr, "://"); index != -1 {
+		addr = addr[index+3:]
+	}
+	return strings.ToLower(fmt.Sprintf("%v/%v/%v", addr, projectName, appName)), nil
+}
+
+func (pm *ProjectManager) taskTmplID(name string) (int64, error) {
+	step, err := pm.pipelineModel.GetTaskTmplByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("任务模板 %v 不存在", name)
+	}
+	return step.ID, nil
+}
+
+func (pm *ProjectManager) verifyServiceTemplate(templateID int64, req *ServiceTemplateReq) error {
+	if err := req.Verify(); err != nil {
+		return err
+	}
+	if item, err := pm.templateModel.GetServiceTemplateByName(req.Name); err == nil && item.ID != templateID {
+		return fmt.Errorf("服务模板 %v 已经存在", req.Name)
+	}
+	if req.CompileEnv != "" {
+		if _, err := pm.settingModel.GetCompileEnvByName(req.CompileEnv); err != nil {
+			return fmt.Errorf("编译环境 %v 不存在", req.CompileEnv)
+		}
+	}
+	if _, err := apps.RenderArrange(req.Arrange, &apps.ArrangeTemplateData{Vars: req.Variables}); err != nil {
+		return fmt.Errorf("应用编排无效: %s", err.Error())
+	}
+	_, err := importPipelineConfig(req.PipelineConfig, func(string) (int64, error) {
+		return 0, nil
+	}, pm.taskTmplID)
+	return err
+}
+
+func setServiceTemplate(item *models.ServiceTemplate, req *ServiceTemplateReq) error {
+	item.Name = req.Name
+	item.Description = req.Description
+	item.Language = req.Language
+	item.CompileEnv = req.CompileEnv
+	item.BuildPath = req.BuildPath
+	item.Dockerfile = req.Dockerfile
+	if item.Dockerfile == "" {
+		item.Dockerfile = "Dockerfile"
+	}
+	item.Arrange = req.Arrange
+	item.PipelineName = strings.TrimSpace(req.PipelineName)
+	item.Variables = ""
+	if len(req.Variables) > 0 {
+		variables, err := json.Marshal(req.Variables)
+		if err != nil {
+			return err
+		}
+		item.Variables = string(variables)
+	}
+	item.PipelineConfig = ""
+	if len(req.PipelineConfig) > 0 {
+		config, err := json.Marshal(req.PipelineConfig)
+		if err != nil {
+			return err
+		}
+		item.PipelineConfig = string(config)
+	}
+	return nil
+}
+
+func formatServiceTemplate(item *models.ServiceTemplate) *ServiceTemplateRsp {
+	rsp := &ServiceTemplateRsp{ServiceTemplate: item, Variables: map[string]string{}, PipelineConfig: []map[string]interface{}{}}
+	if variables, err := apps.DecodeArrangeVariables(item.Variables); err == nil {
+		rsp.Variables = variables
+	}
+	if config, err := decodePipelineConfig(item.PipelineConfig); err == nil {
+		rsp.PipelineConfig = config
+	}
+	return rsp
+}
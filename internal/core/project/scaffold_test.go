@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+)
+
+func TestServiceArrangeSkeleton(t *testing.T) {
+	for _, language := range []string{"go", "Java", "node"} {
+		skeleton := ServiceArrangeSkeleton(language)
+		if skeleton == "" {
+			t.Fatalf("language %v should have skeleton", language)
+		}
+		got, err := apps.RenderArrange(skeleton, &apps.ArrangeTemplateData{
+			App:  apps.ArrangeTemplateApp{Name: "demo", ImageAddr: "harbor.example.com/demo/demo:abc"},
+			Env:  apps.ArrangeTemplateEnv{Namespace: "dev"},
+			Vars: map[string]string{"port": "9090"},
+		})
+		if err != nil {
+			t.Fatalf("render skeleton of %v: %v", language, err)
+		}
+		if !strings.Contains(got, "image: harbor.example.com/demo/demo:abc") || !strings.Contains(got, "containerPort: 9090") {
+			t.Fatalf("unexpected rendered skeleton of %v: %s", language, got)
+		}
+	}
+	if ServiceArrangeSkeleton("cobol") != "" {
+		t.Fatalf("unknown language should not have skeleton")
+	}
+}
+
+func TestServiceTemplateReqVerify(t *testing.T) {
+	for _, req := range DefaultServiceTemplates() {
+		if err := req.Verify(); err != nil {
+			t.Fatalf("built-in template %v: %v", req.Name, err)
+		}
+		if req.Arrange == "" {
+			t.Fatalf("built-in template %v should use the skeleton", req.Name)
+		}
+	}
+	invalid := []*ServiceTemplateReq{
+		{Language: "go"},
+		{Name: "demo"},
+		{Name: "demo", Language: "cobol"},
+		{Name: "demo", Language: "go", PipelineConfig: []map[string]interface{}{{"name": "dev"}}},
+	}
+	for _, req := range invalid {
+		if err := req.Verify(); err == nil {
+			t.Fatalf("template %+v should be invalid", req)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/core/accesstoken"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+)
+
+// serviceAccountPrefix the user name prefix of the project service accounts
+func serviceAccountPrefix(projectID int64) string {
+	return fmt.Sprintf("sa-%d-", projectID)
+}
+
+// getServiceAccount return the service account user which belongs to the project
+func (pm *ProjectManager) getServiceAccount(projectID int64, account string) (*models.User, error) {
+	if !strings.HasPrefix(account, serviceAccountPrefix(projectID)) {
+		return nil, fmt.Errorf("服务账号 %v 不属于项目 %v", account, projectID)
+	}
+	user, err := dao.GetUser(account)
+	if err != nil || user.LoginType != models.ServiceAccountAuth {
+		return nil, fmt.Errorf("服务账号 %v 不存在", account)
+	}
+	return user, nil
+}
+
+// GetServiceAccounts ..
+func (pm *ProjectManager) GetServiceAccounts(projectID int64) ([]*ServiceAccountRsp, error) {
+	users, err := dao.GetServiceAccounts(serviceAccountPrefix(projectID))
+	if err != nil {
+		return nil, err
+	}
+	members, err := pm.model.GetProjectUsers(projectID)
+	if err != nil {
+		return nil, err
+	}
+	memberRoles := map[string]int64{}
+	for _, member := range members {
+		memberRoles[member.User] = member.RoleID
+	}
+	rsp := []*ServiceAccountRsp{}
+	for _, user := range users {
+		item := &ServiceAccountRsp{
+			User:        user.User,
+			Description: user.Name,
+			CreateAt:    user.CreateAt,
+		}
+		if roleID, ok := memberRoles[user.User]; ok {
+			if role, err := pm.userrolesModel.GetRoleByID(roleID); err == nil {
+				item.Role = role.Role
+			}
+		}
+		rsp = append(rsp, item)
+	}
+	return rsp, nil
+}
+
+// CreateServiceAccount create the service account user and join it to the project with the role
+func (pm *ProjectManager) CreateServiceAccount(projectID int64, request *models.ServiceAccountReq) (*ServiceAccountRsp, error) {
+	if err := request.Verify(); err != nil {
+		return nil, err
+	}
+	account := serviceAccountPrefix(projectID) + request.Name
+	if dao.UserExist(account) {
+		return nil, fmt.Errorf("服务账号 %v 已存在", request.Name)
+	}
+	user := &models.User{
+		Addons:    models.NewAddons(),
+		User:      account,
+		Name:      request.Description,
+		LoginType: models.ServiceAccountAuth,
+		Token:     utils.MakeToken(),
+	}
+	if err := dao.InitSystemMember(user); err != nil {
+		return nil, err
+	}
+	if err := pm.AddProjectMembers(projectID, &ProjectNumberReq{User: account, RoleID: request.RoleID}, constant.SystemGroup); err != nil {
+		if err := dao.DeleteUser(user); err != nil {
+			log.Log.Error("rollback service account: %v error: %s", account, err.Error())
+		}
+		return nil, err
+	}
+	return &ServiceAccountRsp{User: account, Description: user.Name, CreateAt: user.CreateAt}, nil
+}
+
+// DeleteServiceAccount revoke the tokens, remove the account from project and delete the account
+func (pm *ProjectManager) DeleteServiceAccount(projectID int64, account string) error {
+	user, err := pm.getServiceAccount(projectID, account)
+	if err != nil {
+		return err
+	}
+	if err := dao.RevokeUserAccessTokens(account); err != nil {
+		return err
+	}
+	members, err := pm.model.GetProjectUsers(projectID)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.User == account {
+			member.MarkDeleted()
+			if err := pm.model.UpdateProjectUser(member); err != nil {
+				return err
+			}
+		}
+	}
+	return dao.DeleteUser(user)
+}
+
+// GetServiceAccountTokens ..
+func (pm *ProjectManager) GetServiceAccountTokens(projectID int64, account string) ([]*models.AccessToken, error) {
+	if _, err := pm.getServiceAccount(projectID, account); err != nil {
+		return nil, err
+	}
+	return dao.GetUserAccessTokens(account)
+}
+
+// CreateServiceAccountToken the token of service account is restricted to the project
+func (pm *ProjectManager) CreateServiceAccountToken(projectID int64, account string, request *models.AccessTokenReq, creator string) (*models.AccessTokenRsp, error) {
+	if _, err := pm.getServiceAccount(projectID, account); err != nil {
+		return nil, err
+	}
+	return accesstoken.CreateToken(account, projectID, request, creator)
+}
+
+// RevokeServiceAccountToken ..
+func (pm *ProjectManager) RevokeServiceAccountToken(projectID int64, account string, tokenID int64) error {
+	if _, err := pm.getServiceAccount(projectID, account); err != nil {
+		return err
+	}
+	return accesstoken.RevokeToken(account, tokenID)
+}
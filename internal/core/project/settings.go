@@ -19,6 +19,7 @@ package project
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
 	"github.com/go-atomci/atomci/internal/middleware/log"
@@ -53,6 +54,59 @@ type ProjectEnvReq struct {
 	ArrangeEnv  string `json:"arrange_env"`
 	CIServer    int64  `json:"ci_server"`
 	Registry    int64  `json:"registry"`
+	// ArgoCD argo cd integrate setting id, 0 means apply the arrange to cluster directly
+	ArgoCD int64 `json:"argocd"`
+	// IssueTracker jira integrate setting id, the linked issues are transitioned when deploy to this env success
+	IssueTracker int64 `json:"issue_tracker"`
+	// MergeTarget the app branches must be merged into this branch before promote to next stage, empty means no gate
+	MergeTarget string `json:"merge_target"`
+	// ReleaseBranch the release branch template created for the apps when publish enter this env, eg: release/{version}
+	ReleaseBranch string `json:"release_branch"`
+	// ConcurrencyPolicy reject/queue/cancel-previous the new job when the env already has running job, default is reject
+	ConcurrencyPolicy string `json:"concurrency_policy"`
+	// DeployWindows the periods which deploy is allowed, empty means no restriction
+	DeployWindows []*pipelinemgr.DeployWindow `json:"deploy_windows"`
+	// WindowPolicy reject/queue the deploy job triggered out of the deploy windows, default is reject
+	WindowPolicy string `json:"window_policy"`
+	// BuildCluster kubernetes integrate setting id which the build pods run on, 0 means follow the ci server
+	BuildCluster int64 `json:"build_cluster"`
+	// BuildNamespace the namespace of build pods, empty means follow the ci server
+	BuildNamespace string `json:"build_namespace"`
+	// Prune delete the orphaned resources of the apps in namespace after deploy
+	Prune bool `json:"prune"`
+	// PreviewSource clone the env as the ephemeral preview env of every merge request
+	PreviewSource bool `json:"preview_source"`
+	// Cosign cosign integrate setting id, the image signatures are verified by its public key before deploy, 0 means no verification
+	Cosign int64 `json:"cosign"`
+	// SignaturePolicy enforce/warn the images whose signature is missing or invalid, default is enforce
+	SignaturePolicy string `json:"signature_policy"`
+	// CIServerPool the jenkins integrate setting ids besides the ci server, the ci jobs are routed to the least loaded healthy one
+	CIServerPool []int64 `json:"ci_server_pool"`
+}
+
+// DeployFreezeReq ..
+type DeployFreezeReq struct {
+	// EnvID 0 means all envs of the project
+	EnvID  int64  `json:"env_id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	// StartAt/EndAt format is 2006-01-02 15:04
+	StartAt string `json:"start_at"`
+	EndAt   string `json:"end_at"`
+}
+
+// EnvLockReq ..
+type EnvLockReq struct {
+	Reason string `json:"reason"`
+	// UnlockAt format is 2006-01-02 15:04, empty means locked until unlocked manually
+	UnlockAt string `json:"unlock_at"`
+}
+
+// EnvLockRsp the lock status of env
+type EnvLockRsp struct {
+	Locked  bool              `json:"locked"`
+	Current *models.EnvLock   `json:"current"`
+	History []*models.EnvLock `json:"history"`
 }
 
 func (s *PipelineReq) String() (string, error) {
@@ -99,14 +153,26 @@ func (pm *ProjectManager) UpdateProjectPipelineConfig(request *PipelineReq, crea
 	if err != nil {
 		return err
 	}
-
 	if len(configString) > 0 {
+		if err := validatePipelineConfig(configString); err != nil {
+			log.Log.Error("verify pipeline: %v config occur error: %s", pipelineModel.ID, err.Error())
+			return err
+		}
 		pipelineModel.Config = configString
 	}
 
 	return pm.model.UpdateProjectPipeline(pipelineModel)
 }
 
+// validatePipelineConfig the config which could not be parsed is rejected, the steps dependencies must be a DAG
+func validatePipelineConfig(configString string) error {
+	configJSON, err := (pipelinemgr.PipelineConfig{}).Struct(configString)
+	if err != nil {
+		return fmt.Errorf("流程配置格式错误: %s", err.Error())
+	}
+	return configJSON.Validate()
+}
+
 // GetPipelineConfig ..
 func (pm *ProjectManager) GetPipelineConfig(pipelineID int64) (ProjectPipelineRespone, error) {
 	pipelineInfo, err := pm.model.GetProjectPipelineByID(pipelineID)
@@ -234,6 +300,48 @@ func (pm *ProjectManager) UpdateProjectEnv(request *ProjectEnvReq, stepID int64)
 	if request.Registry != 0 {
 		stageModel.Registry = request.Registry
 	}
+	stageModel.ArgoCD = request.ArgoCD
+	stageModel.IssueTracker = request.IssueTracker
+	stageModel.MergeTarget = strings.TrimSpace(request.MergeTarget)
+	stageModel.ReleaseBranch = strings.TrimSpace(request.ReleaseBranch)
+	if request.ConcurrencyPolicy != "" {
+		if err := verifyConcurrencyPolicy(request.ConcurrencyPolicy); err != nil {
+			return err
+		}
+		stageModel.ConcurrencyPolicy = request.ConcurrencyPolicy
+	}
+	if request.WindowPolicy != "" {
+		if err := verifyWindowPolicy(request.WindowPolicy); err != nil {
+			return err
+		}
+		stageModel.WindowPolicy = request.WindowPolicy
+	}
+	deployWindows, err := encodeDeployWindows(request.DeployWindows)
+	if err != nil {
+		return err
+	}
+	stageModel.DeployWindows = deployWindows
+	if stageModel.CIServerPool, err = encodeCIServerPool(request.CIServerPool); err != nil {
+		return err
+	}
+	stageModel.BuildCluster = request.BuildCluster
+	stageModel.BuildNamespace = strings.TrimSpace(request.BuildNamespace)
+	stageModel.Prune = request.Prune
+	stageModel.PreviewSource = request.PreviewSource
+	stageModel.Cosign = request.Cosign
+	if request.SignaturePolicy != "" {
+		if err := verifySignaturePolicy(request.SignaturePolicy); err != nil {
+			return err
+		}
+		stageModel.SignaturePolicy = request.SignaturePolicy
+	}
+	project, err := pm.model.GetProjectByID(stageModel.ProjectID)
+	if err != nil {
+		return err
+	}
+	if err := pm.verifyEnvSettings(project.OrgID, stageModel); err != nil {
+		return err
+	}
 
 	return pm.model.UpdateProjectEnv(stageModel)
 }
@@ -248,6 +356,33 @@ func (pm *ProjectManager) CreateProjectEnv(request *ProjectEnvReq, creator strin
 		return fmt.Errorf("你请选择环境标识后，再重试")
 	}
 
+	if request.ConcurrencyPolicy == "" {
+		request.ConcurrencyPolicy = models.ConcurrencyPolicyReject
+	}
+	if err := verifyConcurrencyPolicy(request.ConcurrencyPolicy); err != nil {
+		return err
+	}
+	if request.WindowPolicy == "" {
+		request.WindowPolicy = models.WindowPolicyReject
+	}
+	if err := verifyWindowPolicy(request.WindowPolicy); err != nil {
+		return err
+	}
+	if request.SignaturePolicy == "" {
+		request.SignaturePolicy = models.SignaturePolicyEnforce
+	}
+	if err := verifySignaturePolicy(request.SignaturePolicy); err != nil {
+		return err
+	}
+	deployWindows, err := encodeDeployWindows(request.DeployWindows)
+	if err != nil {
+		return err
+	}
+	ciServerPool, err := encodeCIServerPool(request.CIServerPool)
+	if err != nil {
+		return err
+	}
+
 	// TODO: verify projectID is validate
 	if projectID == 0 {
 		return fmt.Errorf("无效的 project id: %v", projectID)
@@ -267,8 +402,33 @@ func (pm *ProjectManager) CreateProjectEnv(request *ProjectEnvReq, creator strin
 		Namespace:   request.Namespace,
 		CIServer:    request.CIServer,
 		Registry:    request.Registry,
+		ArgoCD:      request.ArgoCD,
 		ArrangeEnv:  request.ArrangeEnv,
 		Creator:     creator,
+
+		IssueTracker:      request.IssueTracker,
+		MergeTarget:       strings.TrimSpace(request.MergeTarget),
+		ReleaseBranch:     strings.TrimSpace(request.ReleaseBranch),
+		ConcurrencyPolicy: request.ConcurrencyPolicy,
+		DeployWindows:     deployWindows,
+		WindowPolicy:      request.WindowPolicy,
+		CIServerPool:      ciServerPool,
+		BuildCluster:      request.BuildCluster,
+		BuildNamespace:    strings.TrimSpace(request.BuildNamespace),
+		Prune:             request.Prune,
+		PreviewSource:     request.PreviewSource,
+		Cosign:            request.Cosign,
+		SignaturePolicy:   request.SignaturePolicy,
+	}
+	project, err := pm.model.GetProjectByID(projectID)
+	if err != nil {
+		return err
+	}
+	if err := pm.verifyEnvSettings(project.OrgID, newProjectEnv); err != nil {
+		return err
+	}
+	if err := pm.verifyEnvQuota(project.OrgID); err != nil {
+		return err
 	}
 	return pm.model.CreateProjectEnv(newProjectEnv)
 }
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package project
+
+import "testing"
+
+func TestValidatePipelineConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "dag", config: `[{"name":"dev","steps":[{"index":1},{"index":2,"depends_on":[1]},{"index":3,"depends_on":[1]},{"index":4,"depends_on":[2,3]}]}]`},
+		{name: "invalid json", config: `[{"name":"dev","steps":[{"index":1}`, wantErr: true},
+		{name: "invalid depends on", config: `[{"name":"dev","steps":[{"index":1,"depends_on":"1"}]}]`, wantErr: true},
+		{name: "cycle", config: `[{"name":"dev","steps":[{"index":1,"depends_on":[2]},{"index":2,"depends_on":[1]}]}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePipelineConfig(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("validatePipelineConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package project
 
 import (
+	"time"
+
 	"github.com/go-atomci/atomci/internal/models"
 )
 
@@ -25,6 +27,12 @@ type ProjectReq struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Status      int8   `json:"status"`
+	// MaxConcurrentBuilds max running build jobs of the project, 0 means use the system default
+	MaxConcurrentBuilds int `json:"max_concurrent_builds"`
+	// RetentionMonths the publish jobs and operation logs older than the months are archived, 0 means use the system default
+	RetentionMonths int `json:"retention_months"`
+	// OrgID the organization of project, only used when create, move the project by the organization api
+	OrgID int64 `json:"org_id"`
 }
 
 // ProjectUpdateReq ..
@@ -38,6 +46,46 @@ type ProjectAppUpdateReq struct {
 	ScmID int64 `json:"scm_id"`
 }
 
+// ProjectEnvCloneReq ..
+type ProjectEnvCloneReq struct {
+	Name       string `json:"name"`
+	ArrangeEnv string `json:"arrange_env"`
+	Namespace  string `json:"namespace"`
+	// Hosts the ingress hosts overridden explicitly, eg: {"demo.example.com": "demo-test.example.com"}
+	Hosts map[string]string `json:"hosts"`
+	// HostPrefix prefix the other ingress hosts, default is the arrange env, eg: pr-1-demo.example.com
+	HostPrefix string `json:"host_prefix"`
+}
+
+// ProjectEnvCloneRsp ..
+type ProjectEnvCloneRsp struct {
+	Env   *models.ProjectEnv `json:"env"`
+	Hosts []string           `json:"hosts"`
+}
+
+// ProjectAppDeployAfterReq the apps which are deployed and ready before the app
+type ProjectAppDeployAfterReq struct {
+	DeployAfter []int64 `json:"deploy_after"`
+}
+
+// ProjectAppCompileCommandReq the compile command of app in the project, the command overrides the template
+type ProjectAppCompileCommandReq struct {
+	CompileTemplate string `json:"compile_template"`
+	CompileCommand  string `json:"compile_command"`
+}
+
+// ProjectAppDockerfileTemplateReq the dockerfile template used when the app lacks dockerfile
+type ProjectAppDockerfileTemplateReq struct {
+	DockerfileTemplate string `json:"dockerfile_template"`
+}
+
+// ProjectAppOwnershipReq the ownership of app shown in the service catalog
+type ProjectAppOwnershipReq struct {
+	Team   string `json:"team"`
+	OnCall string `json:"on_call"`
+	Tier   string `json:"tier"`
+}
+
 // ProjectAppBranchUpdateReq ..
 type ProjectAppBranchUpdateReq struct {
 	BranchName string `json:"branch_name"`
@@ -83,6 +131,7 @@ type ProjectAppRsp struct {
 	Path              string   `json:"path,omitempty"`
 	BuildPath         string   `json:"build_path,omitempty"`
 	Dockerfile        string   `json:"dockerfile,omitempty"`
+	BuildMode         string   `json:"build_mode,omitempty"`
 }
 
 // ProjectPipelineRsp ..
@@ -96,3 +145,11 @@ type ProjectNumberRsp struct {
 	*models.ProjectUser
 	Role string `json:"role"`
 }
+
+// ServiceAccountRsp ..
+type ServiceAccountRsp struct {
+	User        string    `json:"user"`
+	Description string    `json:"description"`
+	Role        string    `json:"role"`
+	CreateAt    time.Time `json:"create_at"`
+}
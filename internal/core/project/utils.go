@@ -17,8 +17,11 @@ limitations under the License.
 package project
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils"
@@ -70,7 +73,7 @@ func (pm *ProjectManager) createProjectUser(users []string, role string, project
 		}
 		_, err := pm.model.CreateProjectUserIfNotExist(&userModel)
 		if err != nil {
-			return errors.NewBadRequest().SetMessage("创建项目用户失败: %s, msg: %s", user, err)
+			return errors.New(errors.CodeProjectUserCreateFailed, errors.Params{"user": user, "reason": err.Error()})
 		}
 	}
 	return nil
@@ -158,6 +161,7 @@ func (pm *ProjectManager) formatProjectAppsResp(modelApps []*models.ProjectApp)
 			Language:   scmapp.Language,
 			Path:       scmapp.Path,
 			Dockerfile: scmapp.Dockerfile,
+			BuildMode:  scmapp.BuildMode,
 			BuildPath:  scmapp.BuildPath,
 			// CompileEnv:        compileEnvName,
 			BranchHistoryList: branchList,
@@ -167,3 +171,67 @@ func (pm *ProjectManager) formatProjectAppsResp(modelApps []*models.ProjectApp)
 
 	return projectAppsRsp, nil
 }
+
+func verifyConcurrencyPolicy(policy string) error {
+	switch policy {
+	case models.ConcurrencyPolicyReject, models.ConcurrencyPolicyQueue, models.ConcurrencyPolicyCancelPrevious:
+		return nil
+	default:
+		return fmt.Errorf("不支持的并发策略: %v，可选值为 reject/queue/cancel-previous", policy)
+	}
+}
+
+func verifyWindowPolicy(policy string) error {
+	switch policy {
+	case models.WindowPolicyReject, models.WindowPolicyQueue:
+		return nil
+	default:
+		return fmt.Errorf("不支持的部署窗口策略: %v，可选值为 reject/queue", policy)
+	}
+}
+
+func verifySignaturePolicy(policy string) error {
+	switch policy {
+	case models.SignaturePolicyEnforce, models.SignaturePolicyWarn:
+		return nil
+	default:
+		return fmt.Errorf("不支持的镜像签名策略: %v，可选值为 enforce/warn", policy)
+	}
+}
+
+// verifyAppTier empty tier means the tier of app is not set
+func verifyAppTier(tier string) error {
+	if tier == "" {
+		return nil
+	}
+	for _, item := range models.AppTiers {
+		if item == tier {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的服务等级: %v，可选值为 %v", tier, strings.Join(models.AppTiers, "/"))
+}
+
+func encodeDeployWindows(windows []*pipelinemgr.DeployWindow) (string, error) {
+	if len(windows) == 0 {
+		return "", nil
+	}
+	if err := pipelinemgr.ValidateDeployWindows(windows); err != nil {
+		return "", err
+	}
+	bytes, err := json.Marshal(windows)
+	return string(bytes), err
+}
+
+func encodeCIServerPool(pool []int64) (string, error) {
+	if len(pool) == 0 {
+		return "", nil
+	}
+	for _, settingID := range pool {
+		if settingID <= 0 {
+			return "", fmt.Errorf("无效的 CI 服务器: %v", settingID)
+		}
+	}
+	bytes, err := json.Marshal(pool)
+	return string(bytes), err
+}
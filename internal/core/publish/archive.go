@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// archiveLock only one replica archives at the same time, both the scheduled and the admin triggered
+const archiveLock = "archive"
+
+// ArchiveInterval the interval of the scheduled archival
+func ArchiveInterval() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("archive::interval", 24)) * time.Hour
+}
+
+// archiveBatch the rows moved in one transaction
+func archiveBatch() int {
+	if batch := beego.AppConfig.DefaultInt("archive::batch", 500); batch > 0 {
+		return batch
+	}
+	return 500
+}
+
+// ArchiveManager archive the publish jobs and operation logs out of the retention of project, keep the primary tables lean
+type ArchiveManager struct {
+	model        *dao.ArchiveModel
+	projectModel *dao.ProjectModel
+}
+
+// NewArchiveManager ...
+func NewArchiveManager() *ArchiveManager {
+	return &ArchiveManager{
+		model:        dao.NewArchiveModel(),
+		projectModel: dao.NewProjectModel(),
+	}
+}
+
+// ArchiveResult the archival of project
+type ArchiveResult struct {
+	ProjectID       int64     `json:"project_id"`
+	ProjectName     string    `json:"project_name"`
+	RetentionMonths int       `json:"retention_months"`
+	Before          time.Time `json:"before"`
+	Jobs            int64     `json:"jobs"`
+	OperationLogs   int64     `json:"operation_logs"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// PurgeArchiveReq permanently delete the archived records created before the date
+type PurgeArchiveReq struct {
+	// ProjectID 0 means all projects
+	ProjectID int64 `json:"project_id"`
+	// Before the date like 2006-01-02, exclusive
+	Before string `json:"before"`
+	// DryRun only count the records will be deleted
+	DryRun bool `json:"dry_run"`
+}
+
+// PurgeArchiveResult ..
+type PurgeArchiveResult struct {
+	Before        time.Time `json:"before"`
+	Jobs          int64     `json:"jobs"`
+	OperationLogs int64     `json:"operation_logs"`
+	DryRun        bool      `json:"dry_run"`
+}
+
+// RetentionMonths the publish jobs and operation logs of project are kept for the months, 0 means forever
+func RetentionMonths(project *models.Project) int {
+	if project.RetentionMonths > 0 {
+		return project.RetentionMonths
+	}
+	return beego.AppConfig.DefaultInt("archive::months", 0)
+}
+
+// Archive archive the project out of its retention, projectID 0 means all projects
+func (am *ArchiveManager) Archive(projectID int64) ([]*ArchiveResult, error) {
+	locked, err := dao.TryLock(archiveLock, 2*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, fmt.Errorf("归档任务正在执行，请稍后重试")
+	}
+	defer func() {
+		if err := dao.Unlock(archiveLock); err != nil {
+			log.Log.Warn("release archive lock occur error: %s", err.Error())
+		}
+	}()
+
+	projects := []*models.Project{}
+	if projectID > 0 {
+		project, err := am.projectModel.GetProjectByID(projectID)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	} else if projects, err = am.projectModel.GetProjects(); err != nil {
+		return nil, err
+	}
+
+	results := []*ArchiveResult{}
+	now := time.Now()
+	for _, project := range projects {
+		months := RetentionMonths(project)
+		if months <= 0 {
+			continue
+		}
+		result := &ArchiveResult{
+			ProjectID:       project.ID,
+			ProjectName:     project.Name,
+			RetentionMonths: months,
+			Before:          now.AddDate(0, -months, 0),
+		}
+		if err := am.archiveProject(result); err != nil {
+			result.Error = err.Error()
+			log.Log.Error("archive project: %v occur error: %s", project.ID, err.Error())
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (am *ArchiveManager) archiveProject(result *ArchiveResult) error {
+	batch := archiveBatch()
+	for {
+		count, err := am.model.ArchivePublishJobs(result.ProjectID, result.Before, batch)
+		result.Jobs += count
+		if err != nil {
+			return err
+		}
+		if count < int64(batch) {
+			break
+		}
+	}
+	for {
+		count, err := am.model.ArchiveOperationLogs(result.ProjectID, result.Before, batch)
+		result.OperationLogs += count
+		if err != nil {
+			return err
+		}
+		if count < int64(batch) {
+			break
+		}
+	}
+	return nil
+}
+
+// PurgeArchives permanently delete the archived records created before the date
+func (am *ArchiveManager) PurgeArchives(req *PurgeArchiveReq) (*PurgeArchiveResult, error) {
+	if req.Before == "" {
+		return nil, fmt.Errorf("请指定清理截止日期")
+	}
+	before, err := time.ParseInLocation("2006-01-02", req.Before, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("清理截止日期格式错误，应为 2006-01-02: %v", req.Before)
+	}
+	result := &PurgeArchiveResult{Before: before, DryRun: req.DryRun}
+	if req.DryRun {
+		result.Jobs, result.OperationLogs, err = am.model.CountArchives(req.ProjectID, before)
+	} else {
+		result.Jobs, result.OperationLogs, err = am.model.PurgeArchives(req.ProjectID, before)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
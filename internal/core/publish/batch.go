@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// batch publish operation actions
+const (
+	BatchActionBuild     = "build"
+	BatchActionDeploy    = "deploy"
+	BatchActionTerminate = "terminate"
+	BatchActionClose     = "close"
+)
+
+const batchPublishMaxSize = 100
+
+// BatchPublish run the action on publishes one by one, the failure of one publish does not stop the others
+func (pm *PublishManager) BatchPublish(user string, projectID int64, req *BatchPublishReq) ([]*BatchPublishResult, error) {
+	publishes, err := pm.getBatchPublishes(projectID, req)
+	if err != nil {
+		return nil, err
+	}
+	rsp := []*BatchPublishResult{}
+	for _, publishItem := range publishes {
+		result := &BatchPublishResult{
+			PublishID: publishItem.ID,
+			Name:      publishItem.Name,
+			VersionNo: publishItem.VersionNo,
+		}
+		result.Status, err = pm.batchPublishItem(user, publishItem, req.Action)
+		if err != nil {
+			log.Log.Warn("batch %v publish: %v occur error: %s", req.Action, publishItem.ID, err.Error())
+			result.Message = err.Error()
+		} else {
+			result.Success = true
+		}
+		rsp = append(rsp, result)
+	}
+	return rsp, nil
+}
+
+func (pm *PublishManager) getBatchPublishes(projectID int64, req *BatchPublishReq) ([]*models.Publish, error) {
+	switch req.Action {
+	case BatchActionBuild, BatchActionDeploy, BatchActionTerminate, BatchActionClose:
+	default:
+		return nil, fmt.Errorf("不支持的批量操作: %v", req.Action)
+	}
+
+	publishes := []*models.Publish{}
+	if len(req.PublishIDs) == 0 {
+		if req.StaleDays <= 0 || (req.Action != BatchActionTerminate && req.Action != BatchActionClose) {
+			return nil, fmt.Errorf("请选择需要批量操作的流水线")
+		}
+		staleItems, err := pm.model.GetStalePublishesByProjectID(projectID, time.Now().AddDate(0, 0, -req.StaleDays))
+		if err != nil {
+			return nil, err
+		}
+		publishes = staleItems
+	}
+	for _, publishID := range req.PublishIDs {
+		publishItem, err := pm.model.GetPublishByID(publishID)
+		if err != nil {
+			return nil, fmt.Errorf("流水线: %v 不存在", publishID)
+		}
+		if publishItem.ProjectID != projectID {
+			return nil, fmt.Errorf("流水线: %v 不属于此项目，操作拒绝", publishID)
+		}
+		publishes = append(publishes, publishItem)
+	}
+	if len(publishes) > batchPublishMaxSize {
+		return nil, fmt.Errorf("单次批量操作的流水线不允许超过 %v 个", batchPublishMaxSize)
+	}
+	return publishes, nil
+}
+
+// batchPublishItem return the publish status after the action
+func (pm *PublishManager) batchPublishItem(user string, publishItem *models.Publish, action string) (int64, error) {
+	if action == BatchActionClose {
+		if err := pm.ClosePublish(publishItem.ID); err != nil {
+			return publishItem.Status, err
+		}
+		return models.Closed, nil
+	}
+
+	actionName := "trigger"
+	stepType := action
+	if action == BatchActionTerminate {
+		actionName = "terminate"
+		stepType = publishItem.StepType
+	} else if publishItem.StepType != action {
+		return publishItem.Status, fmt.Errorf("流水线当前步骤为 %v，不允许执行 %v", publishItem.Step, action)
+	}
+
+	var status, runID int64
+	var jobName string
+	var err error
+	switch stepType {
+	case models.StepBuild:
+		params := &pipelinemgr.BuildStepReq{ActionName: actionName}
+		if actionName == "trigger" {
+			if params, err = pm.pipelineHandler.GenerateBuildStepReq(publishItem.ID); err != nil {
+				return publishItem.Status, err
+			}
+		}
+		status, runID, jobName, err = pm.pipelineHandler.RunBuildStep(publishItem.ProjectID, publishItem.ID, publishItem.StageID, user, stepType, params)
+	case models.StepDeploy:
+		params := &pipelinemgr.DeployStepReq{ActionName: actionName}
+		if actionName == "trigger" {
+			if params, err = pm.pipelineHandler.GenerateDeployStepReq(publishItem.ID); err != nil {
+				return publishItem.Status, err
+			}
+		}
+		status, runID, jobName, err = pm.pipelineHandler.RunDeployStep(publishItem.ProjectID, publishItem.ID, publishItem.StageID, user, stepType, params)
+	case models.StepPromote:
+		status, err = pm.pipelineHandler.RunPromoteStep(publishItem.ProjectID, publishItem.ID, publishItem.StageID, user)
+	default:
+		return publishItem.Status, fmt.Errorf("流水线当前步骤 %v 不支持批量操作", publishItem.Step)
+	}
+	if updateErr := pm.UpdatePublish(publishItem.ID, publishItem.StageID, status, runID, user, "批量操作", jobName); updateErr != nil && err == nil {
+		err = updateErr
+	}
+	if status == models.Skipped {
+		status = publishItem.Status
+	}
+	return status, err
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// newBatchPublish create the publish at the step of the project, updated days ago
+func newBatchPublish(t *testing.T, projectID int64, stepType string, status int64, days int) *models.Publish {
+	publishItem := &models.Publish{Addons: models.NewAddons(), ProjectID: projectID, Name: stepType, Step: stepType, StepType: stepType, StepIndex: 1, Status: status}
+	publishItem.ID = insertTestItem(t, publishItem)
+	updateAt := time.Now().AddDate(0, 0, -days)
+	if _, err := orm.NewOrm().QueryTable("pub_publish").Filter("id", publishItem.ID).Update(orm.Params{"update_at": updateAt}); err != nil {
+		t.Fatal(err)
+	}
+	return publishItem
+}
+
+func TestGetBatchPublishes(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+	project := newTestProject(t)
+	other := newTestProject(t)
+	publishItem := newBatchPublish(t, project.ID, models.StepBuild, models.Pending, 0)
+	otherItem := newBatchPublish(t, other.ID, models.StepBuild, models.Pending, 0)
+
+	invalid := []struct {
+		name string
+		req  *BatchPublishReq
+		err  string
+	}{
+		{"unsupported action", &BatchPublishReq{Action: "delete", PublishIDs: []int64{publishItem.ID}}, "不支持的批量操作"},
+		{"no publish", &BatchPublishReq{Action: BatchActionClose}, "请选择需要批量操作的流水线"},
+		{"stale days only for terminate or close", &BatchPublishReq{Action: BatchActionBuild, StaleDays: 7}, "请选择需要批量操作的流水线"},
+		{"publish not exist", &BatchPublishReq{Action: BatchActionClose, PublishIDs: []int64{publishItem.ID, 99999}}, "不存在"},
+		{"publish of other project", &BatchPublishReq{Action: BatchActionClose, PublishIDs: []int64{publishItem.ID, otherItem.ID}}, "不属于此项目"},
+	}
+	for _, tt := range invalid {
+		if _, err := pm.getBatchPublishes(project.ID, tt.req); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: getBatchPublishes() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	ids := []int64{}
+	for i := 0; i <= batchPublishMaxSize; i++ {
+		ids = append(ids, publishItem.ID)
+	}
+	if _, err := pm.getBatchPublishes(project.ID, &BatchPublishReq{Action: BatchActionClose, PublishIDs: ids}); err == nil || !strings.Contains(err.Error(), "不允许超过") {
+		t.Errorf("getBatchPublishes() of too many publishes error = %v", err)
+	}
+
+	// the unfinished publishes which were not updated in days are selected
+	stale := newBatchPublish(t, project.ID, models.StepDeploy, models.Failed, 10)
+	newBatchPublish(t, project.ID, models.StepDeploy, models.Closed, 10)
+	newBatchPublish(t, other.ID, models.StepDeploy, models.Failed, 10)
+	publishes, err := pm.getBatchPublishes(project.ID, &BatchPublishReq{Action: BatchActionTerminate, StaleDays: 7})
+	if err != nil || len(publishes) != 1 || publishes[0].ID != stale.ID {
+		t.Errorf("getBatchPublishes() of stale publishes = %v, %v", publishes, err)
+	}
+}
+
+func TestBatchPublish(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+	project := newTestProject(t)
+	pending := newBatchPublish(t, project.ID, models.StepDeploy, models.Pending, 0)
+	running := newBatchPublish(t, project.ID, models.StepDeploy, models.Running, 0)
+	manual := newBatchPublish(t, project.ID, models.StepManual, models.Pending, 0)
+
+	// the failure of one publish does not stop the others
+	results, err := pm.BatchPublish("admin", project.ID, &BatchPublishReq{Action: BatchActionClose, PublishIDs: []int64{running.ID, pending.ID}})
+	if err != nil || len(results) != 2 {
+		t.Fatalf("BatchPublish() = %v, %v", results, err)
+	}
+	if results[0].PublishID != running.ID || results[0].Success || results[0].Status != models.Running || !strings.Contains(results[0].Message, "禁止归档") {
+		t.Errorf("close the running publish result = %+v", results[0])
+	}
+	if results[1].PublishID != pending.ID || !results[1].Success || results[1].Status != models.Closed {
+		t.Errorf("close the pending publish result = %+v", results[1])
+	}
+	for _, tt := range []struct {
+		publishItem *models.Publish
+		want        int64
+	}{{running, models.Running}, {pending, models.Closed}} {
+		item, err := pm.model.GetPublishByID(tt.publishItem.ID)
+		if err != nil || item.Status != tt.want {
+			t.Errorf("publish: %v status = %v, want %v, error: %v", tt.publishItem.ID, item.Status, tt.want, err)
+		}
+	}
+
+	results, err = pm.BatchPublish("admin", project.ID, &BatchPublishReq{Action: BatchActionBuild, PublishIDs: []int64{running.ID}})
+	if err != nil || results[0].Success || !strings.Contains(results[0].Message, "不允许执行 build") {
+		t.Errorf("build the publish at deploy step = %+v, %v", results[0], err)
+	}
+	results, err = pm.BatchPublish("admin", project.ID, &BatchPublishReq{Action: BatchActionTerminate, PublishIDs: []int64{manual.ID}})
+	if err != nil || results[0].Success || results[0].Status != models.Pending || !strings.Contains(results[0].Message, "不支持批量操作") {
+		t.Errorf("terminate the publish at manual step = %+v, %v", results[0], err)
+	}
+}
+
+func TestGenerateBuildStepReq(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+	project := newTestProject(t)
+	publishItem := newBatchPublish(t, project.ID, models.StepBuild, models.Pending, 0)
+	if err := pm.createPublishApps([]*PubllishReqApp{{AppID: project.AppID, BranchName: "feature", CompileCommand: "make"}}, publishItem.ID); err != nil {
+		t.Fatal(err)
+	}
+	req, err := pm.pipelineHandler.GenerateBuildStepReq(publishItem.ID)
+	if err != nil || req.ActionName != "trigger" || len(req.Apps) != 1 {
+		t.Fatalf("GenerateBuildStepReq() = %+v, %v", req, err)
+	}
+	if app := req.Apps[0]; app.ProjectAppID != project.AppID || app.Branch != "feature" || app.CompileCommand != "make" {
+		t.Errorf("the build app = %+v", app)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/notification"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/astaxie/beego"
+)
+
+// callbackOperator the operator of the callbacks retried or reconciled
+const callbackOperator = "system"
+
+// callbackMaxAttempts the failed callback is dead after max attempts, it waits for replay then
+func callbackMaxAttempts() int {
+	return beego.AppConfig.DefaultInt("callback::max_attempts", 5)
+}
+
+// CallbackRetryInterval the interval between the attempts of failed callback, it grows with the attempts
+func CallbackRetryInterval() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("callback::retry_interval", 60)) * time.Second
+}
+
+// HandleJobCallback process the callback of publish job once by its idempotency key,
+// the duplicated callback return the result recorded without any transition
+func (pm *PublishManager) HandleJobCallback(req *JobCallbackReq) (*models.PublishJobCallback, error) {
+	jobModel := dao.NewPublishJobModel()
+	job, err := jobModel.GetPublishJobByID(req.PublishJobID)
+	if err != nil || job.PublishID != req.PublishID || job.EnvID != req.StageID {
+		return nil, fmt.Errorf("流水线任务: %v 不存在", req.PublishJobID)
+	}
+	if req.JobStatus == "" {
+		req.JobStatus = models.StatusSuccess
+	}
+	item, created, err := jobModel.ReadOrCreateJobCallback(&models.PublishJobCallback{
+		IdempotencyKey: req.IdempotencyKey,
+		ProjectID:      job.ProjectID,
+		PublishID:      job.PublishID,
+		EnvID:          job.EnvID,
+		PublishJobID:   job.ID,
+		Step:           req.Step,
+		Source:         req.Source,
+		JobStatus:      req.JobStatus,
+		Status:         models.CallbackStatusProcessing,
+		Message:        req.Message,
+	})
+	if err != nil {
+		log.Log.Error("record callback: %v of publish job: %v occur error: %s", req.IdempotencyKey, req.PublishJobID, err.Error())
+		return nil, fmt.Errorf("网络错误，请重试")
+	}
+	if !created && item.Status != models.CallbackStatusFailed {
+		log.Log.Info("callback: %v of publish job: %v is %v already, skip it", item.IdempotencyKey, item.PublishJobID, item.Status)
+		return item, nil
+	}
+	return item, pm.ProcessJobCallback(item, req.Creator)
+}
+
+// ProcessJobCallback run the transition of callback and record the result,
+// the callback failed before is resumed, which may fail after the job status updated
+func (pm *PublishManager) ProcessJobCallback(item *models.PublishJobCallback, creator string) error {
+	// the transitions of publish job are serialized among the server replicas
+	unlock, err := dao.Lock(fmt.Sprintf("publish-job-%d", item.PublishJobID), 5*time.Minute, 30*time.Second)
+	if err != nil {
+		log.Log.Warn("lock publish job: %v occur error: %s", item.PublishJobID, err.Error())
+		return fmt.Errorf("流水线任务正在处理中，请稍后重试")
+	}
+	defer unlock()
+	if latest, err := dao.NewPublishJobModel().GetJobCallbackByID(item.ID); err == nil && latest.Attempts != item.Attempts {
+		log.Log.Info("callback: %v of publish job: %v is processed by another replica, skip it", item.IdempotencyKey, item.PublishJobID)
+		*item = *latest
+		return nil
+	}
+
+	item.Attempts++
+	status, message, err := pm.runJobCallback(item, creator, &pipelinemgr.BuildStepCallbackReq{
+		PublishJobID:   item.PublishJobID,
+		IdempotencyKey: item.IdempotencyKey,
+		JobStatus:      item.JobStatus,
+		Resume:         item.Attempts > 1,
+	})
+	if err != nil {
+		item.Status = models.CallbackStatusFailed
+		if item.Attempts >= callbackMaxAttempts() {
+			item.Status = models.CallbackStatusDead
+		}
+		message = err.Error()
+		log.Log.Warn("callback: %v of publish job: %v attempt %v failed: %s", item.IdempotencyKey, item.PublishJobID, item.Attempts, message)
+	} else {
+		item.Status = models.CallbackStatusProcessed
+		item.PublishStatus = status
+	}
+	// operation log message column size is 256
+	message = utils.Truncate(message, 256)
+	if message != "" {
+		item.Message = message
+	}
+	if updateErr := dao.NewPublishJobModel().UpdateJobCallback(item); updateErr != nil {
+		log.Log.Error("update callback: %v of publish job: %v occur error: %s", item.IdempotencyKey, item.PublishJobID, updateErr.Error())
+	}
+	return err
+}
+
+func (pm *PublishManager) runJobCallback(item *models.PublishJobCallback, creator string, request *pipelinemgr.BuildStepCallbackReq) (int64, string, error) {
+	publishItem, err := pm.model.GetPublishByID(item.PublishID)
+	if err != nil {
+		return models.Skipped, "", err
+	}
+	// the closed publish is not reopened by the job finished late
+	if publishItem.Status == models.Closed || publishItem.Status == models.END {
+		log.Log.Info("publish: %v was ended, callback of publish job: %v only update job status", publishItem.ID, item.PublishJobID)
+		if err := pm.pipelineHandler.UpdatePublishJobStatus(item.PublishJobID, item.JobStatus); err != nil {
+			log.Log.Warn("update publish job: %v status occur error: %s", item.PublishJobID, err.Error())
+		}
+		return models.Skipped, "", nil
+	}
+
+	var status int64
+	var message string
+	switch item.Step {
+	case models.StepBuild, models.StepDeploy:
+		status, err = pm.pipelineHandler.RunBuildDeployCallBackStep(request)
+	case models.StepE2ETest:
+		status, message, err = pm.pipelineHandler.RunE2ETestCallBackStep(request)
+	default:
+		err = fmt.Errorf("unknown step: %s", item.Step)
+	}
+	if err != nil {
+		return status, message, err
+	}
+	if message == "" {
+		message = item.Message
+	}
+	// the steps maybe parallel, the result of job updates the step of job
+	job, err := dao.NewPublishJobModel().GetPublishJobByID(item.PublishJobID)
+	if err != nil {
+		return status, message, err
+	}
+	if err := pm.UpdatePublishStep(item.PublishID, item.EnvID, job.StepIndex, status, 0, creator, message, ""); err != nil {
+		return status, message, err
+	}
+	if status == models.Skipped {
+		return status, message, nil
+	}
+	if publishInfo, err := pm.GetPublishInfo(item.PublishID); err == nil {
+		go notification.Send(notification.NewPushNotification(status, publishInfo.Name, publishInfo.StageName, publishInfo.Step))
+	}
+	return status, message, nil
+}
+
+// RetryJobCallbacks retry the failed callbacks with growing interval, and the callbacks interrupted in processing
+func (pm *PublishManager) RetryJobCallbacks() {
+	jobModel := dao.NewPublishJobModel()
+	items, err := jobModel.GetJobCallbacks(0, []string{models.CallbackStatusFailed, models.CallbackStatusProcessing}, 0)
+	if err != nil {
+		log.Log.Error("get failed job callbacks occur error: %s", err.Error())
+		return
+	}
+	interval := CallbackRetryInterval()
+	for _, item := range items {
+		if time.Since(item.UpdateAt) < time.Duration(item.Attempts+1)*interval {
+			continue
+		}
+		log.Log.Info("retry callback: %v of publish job: %v, attempts: %v", item.IdempotencyKey, item.PublishJobID, item.Attempts)
+		pm.ProcessJobCallback(item, callbackOperator)
+	}
+}
+
+// GetJobCallbacks return the callbacks of project by status, the dead callbacks wait for replay
+func (pm *PublishManager) GetJobCallbacks(projectID int64, status string, publishJobID int64) ([]*models.PublishJobCallback, error) {
+	statuses := []string{}
+	if status != "" {
+		statuses = append(statuses, status)
+	}
+	return dao.NewPublishJobModel().GetJobCallbacks(projectID, statuses, publishJobID)
+}
+
+// ReplayJobCallback process the dead callback again
+func (pm *PublishManager) ReplayJobCallback(projectID, callbackID int64, creator string) (*models.PublishJobCallback, error) {
+	item, err := dao.NewPublishJobModel().GetJobCallbackByID(callbackID)
+	if err != nil || item.ProjectID != projectID {
+		return nil, fmt.Errorf("回调记录: %v 不存在", callbackID)
+	}
+	if item.Status != models.CallbackStatusDead && item.Status != models.CallbackStatusFailed {
+		return nil, fmt.Errorf("回调记录状态为 %v，不允许重放", item.Status)
+	}
+	item.Source = models.CallbackSourceReplay
+	return item, pm.ProcessJobCallback(item, creator)
+}
+
+// ReconcileJobCallback finish the job whose callback never arrived, the job is regarded as failed
+func (pm *PublishManager) ReconcileJobCallback(job *models.PublishJob, message string) (*models.PublishJobCallback, error) {
+	return pm.HandleJobCallback(&JobCallbackReq{
+		PublishID:      job.PublishID,
+		StageID:        job.EnvID,
+		PublishJobID:   job.ID,
+		IdempotencyKey: fmt.Sprintf("reconcile-%d", job.ID),
+		Step:           job.JobType,
+		Source:         models.CallbackSourceReconcile,
+		Creator:        callbackOperator,
+		JobStatus:      models.StatusFailure,
+		Message:        message,
+	})
+}
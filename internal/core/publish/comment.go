@@ -0,0 +1,343 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/notification"
+)
+
+// the limits of comment, the attachments are stored in database
+const (
+	maxCommentLength     = 65535
+	maxCommentAttachment = 5
+	// MaxAttachmentSize the max size of each attachment
+	MaxAttachmentSize = 10 << 20
+)
+
+// mentionPattern match `@user` which is not part of email address, eg: `@admin` but not `dev@example.com`
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w][\w.-]*)`)
+
+// PublishCommentReq ..
+type PublishCommentReq struct {
+	Content string `json:"content"`
+	// ParentID the comment replied, the reply of reply belongs to the same thread
+	ParentID int64 `json:"parent_id"`
+}
+
+// CommentAttachment the file uploaded with comment
+type CommentAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// PublishCommentRsp the comment thread, the replies are only returned for the top level comments
+type PublishCommentRsp struct {
+	*models.PublishComment
+	Attachments []*models.PublishCommentAttachment `json:"attachments"`
+	Replies     []*PublishCommentRsp               `json:"replies,omitempty"`
+}
+
+// parseMentions return the sorted users mentioned by the markdown content, the code blocks are ignored
+func parseMentions(content string) []string {
+	lines := []string{}
+	inCode := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if !inCode {
+			lines = append(lines, line)
+		}
+	}
+	seen := map[string]bool{}
+	users := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(strings.Join(lines, "\n"), -1) {
+		user := strings.TrimRight(match[1], ".-")
+		if user != "" && !seen[user] {
+			seen[user] = true
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// getProjectPublish the publish must belong to the project of request path
+func (pm *PublishManager) getProjectPublish(projectID, publishID int64) (*models.Publish, error) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	if publishItem.ProjectID != projectID {
+		return nil, fmt.Errorf("发布单 %v 不属于项目 %v", publishID, projectID)
+	}
+	return publishItem, nil
+}
+
+// projectMentions return the mentioned users who are the members or owner of project, the others are ignored
+func (pm *PublishManager) projectMentions(projectID int64, content string) ([]string, error) {
+	mentions := parseMentions(content)
+	if len(mentions) == 0 {
+		return mentions, nil
+	}
+	members := map[string]bool{}
+	if project, err := pm.projectModel.GetProjectByID(projectID); err == nil {
+		members[project.Owner] = true
+	}
+	users, err := pm.projectModel.GetProjectUsers(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		members[user.User] = true
+	}
+	valid := []string{}
+	for _, user := range mentions {
+		if members[user] {
+			valid = append(valid, user)
+		} else {
+			log.Log.Debug("user: %v mentioned is not the member of project: %v, ignore", user, projectID)
+		}
+	}
+	return valid, nil
+}
+
+// notifyMentions notify the users mentioned by comment, the author is not notified
+func notifyMentions(publishItem *models.Publish, comment *models.PublishComment, users []string) {
+	receivers := []string{}
+	for _, user := range users {
+		if user != comment.Author {
+			receivers = append(receivers, user)
+		}
+	}
+	if len(receivers) == 0 {
+		return
+	}
+	excerpt := comment.Content
+	if utf8.RuneCountInString(excerpt) > 200 {
+		excerpt = string([]rune(excerpt)[:200]) + "..."
+	}
+	options := notification.NewPushNotification(publishItem.Status, publishItem.Name, publishItem.StageName, publishItem.Step)
+	options.Subject = fmt.Sprintf("%s 在发布单 %s 的评论中提到了你", comment.Author, publishItem.Name)
+	options.Message = html.EscapeString(excerpt)
+	options.Receivers = userEmails(receivers)
+	if len(options.Receivers) == 0 {
+		return
+	}
+	go notification.Send(options)
+	log.Log.Info("publish: %v comment: %v notified mentions: %v", publishItem.ID, comment.ID, receivers)
+}
+
+func verifyCommentContent(content string, attachments int) error {
+	if strings.TrimSpace(content) == "" && attachments == 0 {
+		return fmt.Errorf("评论内容不能为空")
+	}
+	if len(content) > maxCommentLength {
+		return fmt.Errorf("评论内容不能超过 %v 字节", maxCommentLength)
+	}
+	return nil
+}
+
+// CreatePublishComment create the comment of publish, the mentioned project members are notified
+func (pm *PublishManager) CreatePublishComment(projectID, publishID int64, author string, req *PublishCommentReq, attachments []*CommentAttachment) (*PublishCommentRsp, error) {
+	publishItem, err := pm.getProjectPublish(projectID, publishID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyCommentContent(req.Content, len(attachments)); err != nil {
+		return nil, err
+	}
+	if len(attachments) > maxCommentAttachment {
+		return nil, fmt.Errorf("每条评论最多上传 %v 个附件", maxCommentAttachment)
+	}
+	parentID := req.ParentID
+	if parentID != 0 {
+		parent, err := pm.commentModel.GetPublishComment(parentID)
+		if err != nil || parent.PublishID != publishID {
+			return nil, fmt.Errorf("回复的评论 %v 不存在", parentID)
+		}
+		if parent.ParentID != 0 {
+			parentID = parent.ParentID
+		}
+	}
+	mentions, err := pm.projectMentions(projectID, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	comment := &models.PublishComment{
+		ProjectID: projectID,
+		PublishID: publishID,
+		ParentID:  parentID,
+		Author:    author,
+		Content:   req.Content,
+		Mentions:  strings.Join(mentions, ","),
+	}
+	items := []*models.PublishCommentAttachment{}
+	for _, attachment := range attachments {
+		name := strings.TrimSpace(attachment.Name)
+		if name == "" || len(name) > 255 {
+			return nil, fmt.Errorf("无效的附件名称: %v", attachment.Name)
+		}
+		if len(attachment.Data) > MaxAttachmentSize {
+			return nil, fmt.Errorf("附件 %v 不能超过 %vMB", name, MaxAttachmentSize>>20)
+		}
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		items = append(items, &models.PublishCommentAttachment{
+			Name:        name,
+			ContentType: contentType,
+			Size:        int64(len(attachment.Data)),
+			Content:     base64.StdEncoding.EncodeToString(attachment.Data),
+		})
+	}
+	if err := pm.commentModel.CreatePublishComment(comment, items); err != nil {
+		return nil, err
+	}
+	notifyMentions(publishItem, comment, mentions)
+	return &PublishCommentRsp{PublishComment: comment, Attachments: items}, nil
+}
+
+// GetPublishComments return the comment threads of publish, the earliest is the first
+func (pm *PublishManager) GetPublishComments(projectID, publishID int64) ([]*PublishCommentRsp, error) {
+	if _, err := pm.getProjectPublish(projectID, publishID); err != nil {
+		return nil, err
+	}
+	comments, err := pm.commentModel.GetPublishComments(publishID)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := pm.commentModel.GetPublishAttachments(publishID)
+	if err != nil {
+		return nil, err
+	}
+	commentAttachments := map[int64][]*models.PublishCommentAttachment{}
+	for _, item := range attachments {
+		commentAttachments[item.CommentID] = append(commentAttachments[item.CommentID], item)
+	}
+	threads := map[int64]*PublishCommentRsp{}
+	rsp := []*PublishCommentRsp{}
+	replies := []*PublishCommentRsp{}
+	for _, comment := range comments {
+		item := &PublishCommentRsp{PublishComment: comment, Attachments: commentAttachments[comment.ID]}
+		if item.Attachments == nil {
+			item.Attachments = []*models.PublishCommentAttachment{}
+		}
+		if comment.ParentID == 0 {
+			threads[comment.ID] = item
+			rsp = append(rsp, item)
+		} else {
+			replies = append(replies, item)
+		}
+	}
+	for _, reply := range replies {
+		if thread, ok := threads[reply.ParentID]; ok {
+			thread.Replies = append(thread.Replies, reply)
+		} else {
+			// the thread was deleted, the reply is kept as top level comment
+			rsp = append(rsp, reply)
+		}
+	}
+	return rsp, nil
+}
+
+// getAuthorComment only the author can update or delete the comment
+func (pm *PublishManager) getAuthorComment(projectID, publishID, commentID int64, user string) (*models.PublishComment, error) {
+	comment, err := pm.commentModel.GetPublishComment(commentID)
+	if err != nil || comment.ProjectID != projectID || comment.PublishID != publishID {
+		return nil, fmt.Errorf("评论 %v 不存在", commentID)
+	}
+	if comment.Author != user {
+		return nil, fmt.Errorf("只有评论的作者可以修改或删除评论")
+	}
+	return comment, nil
+}
+
+// UpdatePublishComment update the content of comment, only the users newly mentioned are notified
+func (pm *PublishManager) UpdatePublishComment(projectID, publishID, commentID int64, user string, req *PublishCommentReq) (*models.PublishComment, error) {
+	publishItem, err := pm.getProjectPublish(projectID, publishID)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := pm.getAuthorComment(projectID, publishID, commentID, user)
+	if err != nil {
+		return nil, err
+	}
+	// the attachments are not changed by update, so the content must not be empty
+	if err := verifyCommentContent(req.Content, 0); err != nil {
+		return nil, err
+	}
+	mentions, err := pm.projectMentions(projectID, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	notified := map[string]bool{}
+	for _, name := range strings.Split(comment.Mentions, ",") {
+		notified[name] = true
+	}
+	added := []string{}
+	for _, name := range mentions {
+		if !notified[name] {
+			added = append(added, name)
+		}
+	}
+	comment.Content = req.Content
+	comment.Mentions = strings.Join(mentions, ",")
+	if err := pm.commentModel.UpdatePublishComment(comment); err != nil {
+		return nil, err
+	}
+	notifyMentions(publishItem, comment, added)
+	return comment, nil
+}
+
+// DeletePublishComment delete the comment with its attachments, the replies are kept
+func (pm *PublishManager) DeletePublishComment(projectID, publishID, commentID int64, user string) error {
+	comment, err := pm.getAuthorComment(projectID, publishID, commentID, user)
+	if err != nil {
+		return err
+	}
+	return pm.commentModel.DeletePublishComment(comment)
+}
+
+// GetCommentAttachment return the attachment with decoded content
+func (pm *PublishManager) GetCommentAttachment(projectID, publishID, attachmentID int64) (*models.PublishCommentAttachment, []byte, error) {
+	if _, err := pm.getProjectPublish(projectID, publishID); err != nil {
+		return nil, nil, err
+	}
+	attachment, err := pm.commentModel.GetCommentAttachment(attachmentID)
+	if err != nil || attachment.PublishID != publishID {
+		return nil, nil, fmt.Errorf("附件 %v 不存在", attachmentID)
+	}
+	data, err := base64.StdEncoding.DecodeString(attachment.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, data, nil
+}
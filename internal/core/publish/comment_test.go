@@ -0,0 +1,35 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	content := "@bob please check the db migration, cc @alice and @bob.\n" +
+		"mail ops@example.com for the window\n" +
+		"```\ndocker login -u @ignored\n```\n" +
+		"(@carol-1)"
+	if got, want := parseMentions(content), []string{"alice", "bob", "carol-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMentions() = %v, want %v", got, want)
+	}
+	if got := parseMentions("no mention"); len(got) != 0 {
+		t.Errorf("parseMentions() = %v, want empty", got)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	initTestDBOnce sync.Once
+	testProjectID  int64 = 1000
+)
+
+// initTestDB the orm can only be initialized once, the tests share the sqlite database,
+// which outlives the temp dir of the first test
+func initTestDB(t *testing.T) {
+	initTestDBOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "atomci")
+		if err != nil {
+			t.Fatalf("create temp dir error: %v", err)
+		}
+		models.InitSQLite(filepath.Join(dir, "atomci.db"))
+	})
+}
+
+// insertTestItem insert the item into the sqlite database, return its id
+func insertTestItem(t *testing.T, item interface{}) int64 {
+	id, err := orm.NewOrm().Insert(item)
+	if err != nil {
+		t.Fatalf("insert %T error: %v", item, err)
+	}
+	return id
+}
+
+// fakeGitlab the gitlab api of the app repo, branches are the commits of branch, the head is the first one
+type fakeGitlab struct {
+	branches      map[string][]string
+	mergeRequests []map[string]interface{}
+}
+
+func (s *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Private-Token") != "scm-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var rsp interface{}
+	switch path := strings.TrimPrefix(r.URL.Path, "/api/v4/projects/atomci/app"); {
+	case strings.HasPrefix(path, "/repository/branches/"):
+		commits := s.branches[strings.TrimPrefix(path, "/repository/branches/")]
+		if len(commits) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rsp = map[string]interface{}{"name": path, "commit": map[string]string{"id": commits[0]}}
+	case path == "/repository/commits":
+		items := []map[string]string{}
+		for _, sha := range s.branches[r.URL.Query().Get("ref_name")] {
+			items = append(items, map[string]string{"id": sha})
+		}
+		rsp = items
+	case path == "/merge_requests":
+		rsp = s.mergeRequests
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(rsp)
+}
+
+// testProject the project of one env pipeline and one app of the fake gitlab repo
+type testProject struct {
+	ID         int64
+	EnvID      int64
+	PipelineID int64
+	AppID      int64
+	gitlab     *fakeGitlab
+}
+
+func newTestProject(t *testing.T) *testProject {
+	project := &testProject{
+		ID:     atomic.AddInt64(&testProjectID, 1),
+		gitlab: &fakeGitlab{branches: map[string][]string{"master": {"c0000000000"}}},
+	}
+	server := httptest.NewServer(project.gitlab)
+	t.Cleanup(server.Close)
+
+	project.EnvID = insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: project.ID, Name: "dev"})
+	config := fmt.Sprintf(`[{"stage_id":%d,"index":1,"steps":[{"index":1,"name":"build","type":"build"}]}]`, project.EnvID)
+	project.PipelineID = insertTestItem(t, &models.ProjectPipeline{Addons: models.NewAddons(), ProjectID: project.ID, Name: "default", Config: config})
+
+	setting := &models.IntegrateSetting{Addons: models.NewAddons(), Name: fmt.Sprintf("gitlab-%d", project.ID), Type: "gitlab"}
+	setting.CryptoConfig(fmt.Sprintf(`{"url":%q,"token":"scm-token"}`, server.URL))
+	scmApp := &models.ScmApp{Addons: models.NewAddons(), Name: "app", FullName: "atomci/app", Path: server.URL + "/atomci/app.git", RepoID: insertTestItem(t, setting)}
+	project.AppID = insertTestItem(t, &models.ProjectApp{Addons: models.NewAddons(), ProjectID: project.ID, ScmID: insertTestItem(t, scmApp)})
+	return project
+}
+
+// newTestUser create the user if it does not exist
+func newTestUser(t *testing.T, name string) {
+	if exist := orm.NewOrm().QueryTable("sys_user").Filter("user", name).Exist(); !exist {
+		insertTestItem(t, &models.User{Addons: models.NewAddons(), User: name, Name: name, Token: name})
+	}
+}
+
+// getProjectPublishes return the publishes of project, ordered by id
+func getProjectPublishes(t *testing.T, projectID int64) []*models.Publish {
+	publishes := []*models.Publish{}
+	if _, err := orm.NewOrm().QueryTable("pub_publish").Filter("project_id", projectID).OrderBy("id").All(&publishes); err != nil {
+		t.Fatalf("get publishes of project: %v error: %v", projectID, err)
+	}
+	return publishes
+}
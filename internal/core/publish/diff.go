@@ -0,0 +1,340 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/drone/go-scm/scm"
+)
+
+// diffMaxCommits max commits of one app listed in publish diff
+const diffMaxCommits = 100
+
+// the change of app between two publishes
+const (
+	AppAdded     = "added"
+	AppRemoved   = "removed"
+	AppChanged   = "changed"
+	AppUnchanged = "unchanged"
+)
+
+// PublishDiffItem the publish compared
+type PublishDiffItem struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	VersionNo string    `json:"version_no"`
+	StageName string    `json:"stage_name"`
+	CreateAt  time.Time `json:"create_at"`
+}
+
+// DiffCommit ..
+type DiffCommit struct {
+	Sha     string    `json:"sha"`
+	Message string    `json:"message"`
+	Author  string    `json:"author"`
+	Created time.Time `json:"created"`
+}
+
+// VariableChange the change of arrange variable, From is empty when the variable is added and To is empty when removed
+type VariableChange struct {
+	Key    string `json:"key"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Change string `json:"change"`
+}
+
+// AppDiff the change of app between two publishes
+type AppDiff struct {
+	ProjectAppID int64  `json:"project_app_id"`
+	Name         string `json:"name"`
+	Change       string `json:"change"`
+	FromBranch   string `json:"from_branch"`
+	ToBranch     string `json:"to_branch"`
+	FromCommit   string `json:"from_commit"`
+	ToCommit     string `json:"to_commit"`
+	// Commits the commits from FromCommit (exclusive) to ToCommit (inclusive), newest first
+	Commits []*DiffCommit `json:"commits"`
+	// CommitsComplete false when FromCommit was not found in the latest commits, or the commits could not be listed
+	CommitsComplete     bool                      `json:"commits_complete"`
+	CommitsError        string                    `json:"commits_error,omitempty"`
+	FromArrangeRevision int64                     `json:"from_arrange_revision"`
+	ToArrangeRevision   int64                     `json:"to_arrange_revision"`
+	Arrange             *apps.ArrangeRevisionDiff `json:"arrange,omitempty"`
+	Variables           []*VariableChange         `json:"variables"`
+}
+
+// PublishDiff what changed from one publish to another of the same pipeline
+type PublishDiff struct {
+	From    *PublishDiffItem `json:"from"`
+	To      *PublishDiffItem `json:"to"`
+	EnvID   int64            `json:"env_id"`
+	EnvName string           `json:"env_name"`
+	Apps    []*AppDiff       `json:"apps"`
+}
+
+// publishAppState the branch, built commit and deployed arrange revision of app in a publish
+type publishAppState struct {
+	branch   string
+	commit   string
+	revision int64
+}
+
+// DiffPublishes compare the apps, commits and arrange of the publish with the base publish of the same pipeline,
+// the arrange is compared by the revisions deployed in env, envID 0 means the current stage of the publish
+func (pm *PublishManager) DiffPublishes(projectID, basePublishID, publishID, envID int64) (*PublishDiff, error) {
+	from, err := pm.getProjectPublish(projectID, basePublishID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := pm.getProjectPublish(projectID, publishID)
+	if err != nil {
+		return nil, err
+	}
+	if from.PipelineID != to.PipelineID {
+		return nil, fmt.Errorf("只能对比同一流程的发布单")
+	}
+	if envID == 0 {
+		envID = to.StageID
+	}
+	rsp := &PublishDiff{
+		From:  newPublishDiffItem(from),
+		To:    newPublishDiffItem(to),
+		EnvID: envID,
+		Apps:  []*AppDiff{},
+	}
+	if env, err := pm.projectModel.GetProjectEnvByID(envID); err == nil {
+		rsp.EnvName = env.Name
+	}
+	fromStates, err := pm.publishAppStates(from.ID, envID)
+	if err != nil {
+		return nil, err
+	}
+	toStates, err := pm.publishAppStates(to.ID, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	appIDs := []int64{}
+	for appID := range fromStates {
+		appIDs = append(appIDs, appID)
+	}
+	for appID := range toStates {
+		if _, ok := fromStates[appID]; !ok {
+			appIDs = append(appIDs, appID)
+		}
+	}
+	sort.Slice(appIDs, func(i, j int) bool { return appIDs[i] < appIDs[j] })
+	names, err := pm.projectAppNames(appIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, appID := range appIDs {
+		item := &AppDiff{
+			ProjectAppID: appID,
+			Name:         names[appID],
+			Commits:      []*DiffCommit{},
+			Variables:    []*VariableChange{},
+		}
+		fromState, inFrom := fromStates[appID]
+		toState, inTo := toStates[appID]
+		if inFrom {
+			item.FromBranch, item.FromCommit, item.FromArrangeRevision = fromState.branch, fromState.commit, fromState.revision
+		}
+		if inTo {
+			item.ToBranch, item.ToCommit, item.ToArrangeRevision = toState.branch, toState.commit, toState.revision
+		}
+		item.Change = appChange(fromState, toState)
+		if item.Change == AppChanged {
+			pm.diffAppCommits(item)
+			pm.diffAppArrange(item, envID)
+		}
+		rsp.Apps = append(rsp.Apps, item)
+	}
+	return rsp, nil
+}
+
+// publishAppStates return the state of each publish app, keyed by project app id
+func (pm *PublishManager) publishAppStates(publishID, envID int64) (map[int64]*publishAppState, error) {
+	publishApps, err := pm.model.GetPublishAppsByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	jobModel := dao.NewPublishJobModel()
+	buildApps, err := jobModel.GetLastSuccessBuildJobApps(publishID)
+	if err != nil {
+		return nil, err
+	}
+	deployApps, err := jobModel.GetLastSuccessDeployJobApps(publishID, envID)
+	if err != nil {
+		return nil, err
+	}
+	states := map[int64]*publishAppState{}
+	for _, app := range publishApps {
+		state := &publishAppState{branch: app.BranchName}
+		if jobApp, ok := buildApps[app.ProjectAppID]; ok {
+			state.commit = jobApp.CommitSha
+			if state.commit == "" {
+				state.commit = imageTagCommit(jobApp.ImageAddr)
+			}
+		}
+		if jobApp, ok := deployApps[app.ProjectAppID]; ok {
+			state.revision = jobApp.ArrangeRevision
+		}
+		states[app.ProjectAppID] = state
+	}
+	return states, nil
+}
+
+// projectAppNames return the scm app name of project apps, keyed by project app id
+func (pm *PublishManager) projectAppNames(projectAppIDs []int64) (map[int64]string, error) {
+	projectApps, err := pm.projectModel.GetProjectAppsByAppIDs(projectAppIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmIDs := []int64{}
+	for _, app := range projectApps {
+		scmIDs = append(scmIDs, app.ScmID)
+	}
+	scmApps, err := pm.gitAppModel.GetScmAppsByIDs(scmIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmNames := map[int64]string{}
+	for _, item := range scmApps {
+		scmNames[item.ID] = item.Name
+	}
+	names := map[int64]string{}
+	for _, app := range projectApps {
+		names[app.ID] = scmNames[app.ScmID]
+	}
+	return names, nil
+}
+
+// diffAppCommits list the commits from the base commit to the commit of publish, the head of branch is used
+// when the publish has not been built
+func (pm *PublishManager) diffAppCommits(item *AppDiff) {
+	if item.FromCommit != "" && strings.HasPrefix(item.ToCommit, item.FromCommit) {
+		item.CommitsComplete = true
+		return
+	}
+	commits, _, err := pm.pipelineHandler.ListAppCommits(item.ProjectAppID, item.ToBranch, diffMaxCommits)
+	if err != nil {
+		log.Log.Warn("when diff publishes, list app: %v commits occur error: %s", item.ProjectAppID, err.Error())
+		item.CommitsError = err.Error()
+		return
+	}
+	commits, item.CommitsComplete = commitsBetween(commits, item.FromCommit, item.ToCommit)
+	for _, commit := range commits {
+		item.Commits = append(item.Commits, &DiffCommit{
+			Sha:     commit.Sha,
+			Message: strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0],
+			Author:  commit.Author.Name,
+			Created: commit.Author.Date,
+		})
+	}
+}
+
+// diffAppArrange compare the arrange revisions deployed by the publishes
+func (pm *PublishManager) diffAppArrange(item *AppDiff, envID int64) {
+	if item.FromArrangeRevision == 0 || item.ToArrangeRevision == 0 || item.FromArrangeRevision == item.ToArrangeRevision {
+		return
+	}
+	appManager := apps.NewAppManager()
+	diff, err := appManager.DiffArrangeRevisions(item.ProjectAppID, envID, item.FromArrangeRevision, item.ToArrangeRevision)
+	if err != nil {
+		log.Log.Warn("when diff publishes, diff app: %v arrange revisions occur error: %s", item.ProjectAppID, err.Error())
+		return
+	}
+	item.Arrange = diff
+	fromRevision, err := appManager.GetArrangeRevision(item.ProjectAppID, envID, item.FromArrangeRevision)
+	if err != nil {
+		return
+	}
+	toRevision, err := appManager.GetArrangeRevision(item.ProjectAppID, envID, item.ToArrangeRevision)
+	if err != nil {
+		return
+	}
+	item.Variables = diffVariables(fromRevision.Variables, toRevision.Variables)
+}
+
+func newPublishDiffItem(item *models.Publish) *PublishDiffItem {
+	return &PublishDiffItem{
+		ID:        item.ID,
+		Name:      item.Name,
+		VersionNo: item.VersionNo,
+		StageName: item.StageName,
+		CreateAt:  item.CreateAt,
+	}
+}
+
+// appChange nil state means the app is not in the publish
+func appChange(from, to *publishAppState) string {
+	switch {
+	case from == nil:
+		return AppAdded
+	case to == nil:
+		return AppRemoved
+	case from.branch != to.branch || from.commit != to.commit || from.revision != to.revision:
+		return AppChanged
+	default:
+		return AppUnchanged
+	}
+}
+
+// commitsBetween return the commits newer than from and not newer than to, the newest commits are used when to is empty
+// or not found, all the commits until to are returned when from was not found
+func commitsBetween(commits []*scm.Commit, from, to string) ([]*scm.Commit, bool) {
+	if to != "" {
+		for i, commit := range commits {
+			if strings.HasPrefix(commit.Sha, to) {
+				commits = commits[i:]
+				break
+			}
+		}
+	}
+	return commitsSince(commits, from)
+}
+
+// diffVariables return the changed variables order by key
+func diffVariables(from, to map[string]string) []*VariableChange {
+	changes := []*VariableChange{}
+	for key, value := range from {
+		toValue, ok := to[key]
+		switch {
+		case !ok:
+			changes = append(changes, &VariableChange{Key: key, From: value, Change: AppRemoved})
+		case toValue != value:
+			changes = append(changes, &VariableChange{Key: key, From: value, To: toValue, Change: AppChanged})
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, &VariableChange{Key: key, To: value, Change: AppAdded})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"testing"
+
+	"github.com/drone/go-scm/scm"
+)
+
+func TestCommitsBetween(t *testing.T) {
+	commits := []*scm.Commit{{Sha: "ddddddd000"}, {Sha: "ccccccc111"}, {Sha: "bbbbbbb222"}, {Sha: "aaaaaaa333"}}
+	if got, found := commitsBetween(commits, "aaaaaaa", "ccccccc"); !found || len(got) != 2 || got[0].Sha != "ccccccc111" {
+		t.Errorf("commitsBetween() = %v, %v, want ccccccc and bbbbbbb", got, found)
+	}
+	if got, found := commitsBetween(commits, "bbbbbbb", ""); !found || len(got) != 2 || got[0].Sha != "ddddddd000" {
+		t.Errorf("commitsBetween() = %v, %v, want the commits since bbbbbbb", got, found)
+	}
+	if got, found := commitsBetween(commits, "eeeeeee", "bbbbbbb"); found || len(got) != 2 {
+		t.Errorf("commitsBetween() = %v, %v, want the commits until bbbbbbb when from not found", got, found)
+	}
+}
+
+func TestDiffVariables(t *testing.T) {
+	from := map[string]string{"A": "1", "B": "2", "C": "3"}
+	to := map[string]string{"A": "1", "B": "20", "D": "4"}
+	got := diffVariables(from, to)
+	want := []VariableChange{
+		{Key: "B", From: "2", To: "20", Change: AppChanged},
+		{Key: "C", From: "3", Change: AppRemoved},
+		{Key: "D", To: "4", Change: AppAdded},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diffVariables() = %v changes, want %v", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("diffVariables()[%v] = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestAppChange(t *testing.T) {
+	state := &publishAppState{branch: "master", commit: "abc1234", revision: 2}
+	tests := []struct {
+		from, to *publishAppState
+		want     string
+	}{
+		{nil, state, AppAdded},
+		{state, nil, AppRemoved},
+		{state, &publishAppState{branch: "master", commit: "abc1234", revision: 2}, AppUnchanged},
+		{state, &publishAppState{branch: "master", commit: "def5678", revision: 2}, AppChanged},
+		{state, &publishAppState{branch: "master", commit: "abc1234", revision: 3}, AppChanged},
+	}
+	for _, tt := range tests {
+		if got := appChange(tt.from, tt.to); got != tt.want {
+			t.Errorf("appChange() = %v, want %v", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/jira"
+)
+
+// LinkPublishIssues parse the issue keys from the commit messages of publish apps since the image deployed in env,
+// and attach them to the publish order, envID 0 means the current stage of publish
+func (pm *PublishManager) LinkPublishIssues(publishID, envID int64) ([]string, error) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	if envID == 0 {
+		envID = publishItem.StageID
+	}
+	publishApps, err := pm.model.GetPublishAppsByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	issues := splitIssues(publishItem.Issues)
+	for _, app := range publishApps {
+		commits, _, err := pm.pipelineHandler.ListAppCommits(app.ProjectAppID, app.BranchName, releaseNotesMaxCommits)
+		if err != nil {
+			log.Log.Warn("when link publish issues, list app: %v commits occur error: %s", app.ProjectAppID, err.Error())
+			continue
+		}
+		previous := ""
+		if jobApp, err := dao.NewPublishJobModel().GetLastSuccessDeployJobApp(app.ProjectAppID, envID, publishID); err == nil {
+			previous = imageTagCommit(jobApp.ImageAddr)
+		}
+		commits, _ = commitsSince(commits, previous)
+		for _, commit := range commits {
+			issues = mergeIssues(issues, jira.ParseIssueKeys(commit.Message))
+		}
+	}
+	publishItem.Issues = strings.Join(issues, ",")
+	if err := pm.model.UpdatePublish(publishItem); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// GetPublishIssues ..
+func (pm *PublishManager) GetPublishIssues(publishID int64) ([]string, error) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	return splitIssues(publishItem.Issues), nil
+}
+
+// TransitionPublishIssues transition the linked issues of publish order when the env has issue tracker
+func (pm *PublishManager) TransitionPublishIssues(publishID, envID int64) error {
+	env, err := pm.projectModel.GetProjectEnvByID(envID)
+	if err != nil {
+		return err
+	}
+	if env.IssueTracker == 0 {
+		return nil
+	}
+	issues, err := pm.GetPublishIssues(publishID)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	jiraConf, err := pm.settingsHandler.GetJiraIntegrateSettingByID(env.IssueTracker)
+	if err != nil {
+		return err
+	}
+	client, err := jiraConf.Client()
+	if err != nil {
+		return err
+	}
+	failed := []string{}
+	for _, key := range filterIssues(issues, jiraConf.ProjectKeys) {
+		if err := client.DoTransition(key, jiraConf.ReleaseTransition); err != nil {
+			log.Log.Warn("transition issue: %v to %v occur error: %s", key, jiraConf.ReleaseTransition, err.Error())
+			failed = append(failed, key)
+			continue
+		}
+		log.Log.Info("publish: %v issue: %v was transitioned to %v", publishID, key, jiraConf.ReleaseTransition)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("transition issues: %v failed", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+func splitIssues(issues string) []string {
+	rsp := []string{}
+	for _, key := range strings.Split(issues, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			rsp = append(rsp, key)
+		}
+	}
+	return rsp
+}
+
+// mergeIssues append the keys which not exist in issues
+func mergeIssues(issues, keys []string) []string {
+	for _, key := range keys {
+		exist := false
+		for _, issue := range issues {
+			if issue == key {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			issues = append(issues, key)
+		}
+	}
+	return issues
+}
+
+// filterIssues return the issues belong to the jira projects, empty projects means all
+func filterIssues(issues, projectKeys []string) []string {
+	if len(projectKeys) == 0 {
+		return issues
+	}
+	rsp := []string{}
+	for _, issue := range issues {
+		for _, projectKey := range projectKeys {
+			if strings.HasPrefix(issue, strings.ToUpper(projectKey)+"-") {
+				rsp = append(rsp, issue)
+				break
+			}
+		}
+	}
+	return rsp
+}
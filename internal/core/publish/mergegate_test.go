@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+func TestVerifyMergeGate(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+	project := newTestProject(t)
+	project.gitlab.branches = map[string][]string{
+		"master":  {"m2", "f1", "m1"},
+		"merged":  {"f1", "m1"},
+		"squash":  {"s1", "m1"},
+		"pending": {"p1", "m1"},
+	}
+	project.gitlab.mergeRequests = []map[string]interface{}{
+		{"iid": 1, "sha": "s1", "state": "merged", "source_branch": "squash", "target_branch": "master"},
+		{"iid": 2, "sha": "p1", "state": "closed", "source_branch": "pending", "target_branch": "master"},
+	}
+	newPublish := func(branch string) int64 {
+		publishID := insertTestItem(t, &models.Publish{Addons: models.NewAddons(), ProjectID: project.ID, StageID: project.EnvID})
+		if err := pm.createPublishApps([]*PubllishReqApp{{AppID: project.AppID, BranchName: branch}}, publishID); err != nil {
+			t.Fatal(err)
+		}
+		return publishID
+	}
+
+	// the env without merge target has no gate
+	if err := pm.verifyMergeGate(newPublish("pending"), project.EnvID); err != nil {
+		t.Fatalf("verifyMergeGate() without merge target error: %v", err)
+	}
+	if _, err := orm.NewOrm().QueryTable("project_env").Filter("id", project.EnvID).Update(orm.Params{"merge_target": "master"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		branch string
+		err    string
+	}{
+		{"master", ""},
+		{"merged", ""},
+		{"squash", ""},
+		{"pending", "应用分支 pending 尚未合并到 master"},
+		{"missing", "检查应用分支 missing 是否合并到 master 失败"},
+	}
+	for _, tt := range tests {
+		err := pm.verifyMergeGate(newPublish(tt.branch), project.EnvID)
+		if (tt.err == "" && err != nil) || (tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err))) {
+			t.Errorf("verifyMergeGate() of branch %v error = %v, want %q", tt.branch, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/rbac"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/metrics"
+	"github.com/go-atomci/atomci/utils"
+)
+
+// the operation log step labels of progression policy, the logs use step index 0 so that the step states keep unchanged
+const (
+	approvalStepLabel    = "approval"
+	autoPromoteStepLabel = "auto-promote"
+	rollbackStepLabel    = "auto-rollback"
+)
+
+// ApproveStage approve the publish to enter the stage which require approval
+func (pm *PublishManager) ApproveStage(projectID, publishID, stageID int64, req *StageApprovalReq, user string) error {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return err
+	}
+	if publishItem.ProjectID != projectID {
+		return fmt.Errorf("发布单 %v 不属于项目 %v", publishID, projectID)
+	}
+	policy, err := pm.pipelineHandler.GetStagePolicy(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return err
+	}
+	if !policy.RequireApproval {
+		return fmt.Errorf("该阶段无需审批")
+	}
+	if approvers := splitApprovers(publishItem.Approvers); len(approvers) > 0 && !approvers[user] {
+		return fmt.Errorf("%v 不是发布单的审批人，无权审批", user)
+	}
+	if err := rbac.VerifyEnvAction(user, projectID, stageID, rbac.ActionApprove); err != nil {
+		return err
+	}
+	stage, err := pm.projectModel.GetProjectEnvByID(stageID)
+	if err != nil {
+		return err
+	}
+	operationLog := &CreateOperationLogReq{
+		Creator:            user,
+		StageName:          stage.Name,
+		StepName:           approvalStepLabel,
+		Message:            req.Message,
+		Type:               "审批",
+		PipelineInstanceID: publishItem.LastPipelineInstanceID,
+		Status:             models.Success,
+		PublishID:          publishID,
+		StageID:            stageID,
+	}
+	if err := pm.createPublishOperationLogItem(operationLog); err != nil {
+		return err
+	}
+	// the publish may be waiting for the approval to promote automatically
+	if publishItem.StageID != stageID && publishItem.Status == models.Success {
+		go NewPublishManager().autoPromote(publishID)
+	}
+	return nil
+}
+
+// verifyStageApproval check the publish was approved to enter the stage which require approval
+func (pm *PublishManager) verifyStageApproval(publishItem *models.Publish, stageID int64) error {
+	policy, err := pm.pipelineHandler.GetStagePolicy(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return err
+	}
+	if !policy.RequireApproval {
+		return nil
+	}
+	approvals, err := pm.model.GetOperationLogsByStep(publishItem.LastPipelineInstanceID, stageID, approvalStepLabel)
+	if err != nil {
+		return err
+	}
+	if len(approvals) == 0 {
+		stage, _ := pm.projectModel.GetProjectEnvByID(stageID)
+		stageName := fmt.Sprint(stageID)
+		if stage != nil {
+			stageName = stage.Name
+		}
+		return fmt.Errorf("进入阶段 %v 需要审批，请等待审批人审批后再流转", stageName)
+	}
+	return nil
+}
+
+// autoPromote promote the publish to the next stage if the policy of current stage is auto promote,
+// and trigger the first step of the next stage if it is deploy or promote.
+func (pm *PublishManager) autoPromote(publishID int64) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		log.Log.Error("auto promote, get publish: %v occur error: %s", publishID, err.Error())
+		return
+	}
+	stageID := publishItem.StageID
+	policy, err := pm.pipelineHandler.GetStagePolicy(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil || !policy.AutoPromote {
+		return
+	}
+	if done, err := pm.stageAllStepsSuccess(publishItem); err != nil || !done {
+		log.Log.Debug("publish: %v stage: %v steps are not all success, skip auto promote, error: %v", publishID, stageID, err)
+		return
+	}
+	nextStages, err := pm.GetNextStage(publishItem.ProjectID, publishID, stageID)
+	if err != nil || len(nextStages) == 0 {
+		log.Log.Debug("publish: %v stage: %v has no next stage to promote, error: %v", publishID, stageID, err)
+		return
+	}
+	nextStageID := nextStages[0].ID
+	if err := pm.pipelineHandler.VerifyEnvUnlocked(nextStageID); err != nil {
+		log.Log.Info("auto promote publish: %v to stage: %v was held: %s", publishID, nextStageID, err.Error())
+		pm.createPolicyOperationLog(publishItem, stageID, autoPromoteStepLabel, "自动晋级", models.Skipped, err.Error())
+		return
+	}
+	if err := pm.TriggerNextStage(publishItem.ProjectID, publishID, stageID, &TriggerBackToReq{StageID: nextStageID, Automated: true}, "system"); err != nil {
+		log.Log.Info("auto promote publish: %v to stage: %v was held: %s", publishID, nextStageID, err.Error())
+		pm.createPolicyOperationLog(publishItem, stageID, autoPromoteStepLabel, "自动晋级", models.Skipped, err.Error())
+		return
+	}
+
+	publishItem, err = pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return
+	}
+	switch publishItem.StepType {
+	case models.StepDeploy, models.StepPromote:
+		status, runID, jobName, err := pm.pipelineHandler.AutoTriggerNextStep(publishItem, publishItem.StepType)
+		message := ""
+		if err != nil {
+			log.Log.Error("auto promote publish: %v, trigger step: %v occur error: %s", publishID, publishItem.Step, err.Error())
+			message = err.Error()
+		}
+		if err := pm.UpdatePublish(publishID, nextStageID, status, runID, "system", message, jobName); err != nil {
+			log.Log.Error("auto promote publish: %v, update publish occur error: %s", publishID, err.Error())
+		}
+	}
+}
+
+func (pm *PublishManager) stageAllStepsSuccess(publishItem *models.Publish) (bool, error) {
+	stageJSON, err := pm.pipelineHandler.GetPipelineInstanceEnvStageByID(publishItem.LastPipelineInstanceID, publishItem.StageID)
+	if err != nil {
+		return false, err
+	}
+	states, err := pm.pipelineHandler.GetStageStepStates(publishItem.LastPipelineInstanceID, publishItem.StageID)
+	if err != nil {
+		return false, err
+	}
+	for _, step := range stageJSON.Steps {
+		if states[step.Index] != models.Success {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// HandleDeployFailure apply the failure policy of stage when the deploy job failed
+func (pm *PublishManager) HandleDeployFailure(job *models.PublishJob) {
+	publishItem, err := pm.model.GetPublishByID(job.PublishID)
+	if err != nil {
+		log.Log.Error("handle deploy failure, get publish: %v occur error: %s", job.PublishID, err.Error())
+		return
+	}
+	policy, err := pm.pipelineHandler.GetStagePolicy(publishItem.LastPipelineInstanceID, job.EnvID)
+	if err != nil || policy.OnFailure != pipelinemgr.OnFailureRollback {
+		return
+	}
+	var status int64 = models.Success
+	rolledBack, err := pm.pipelineHandler.RollbackDeployJob(job)
+	message := fmt.Sprintf("已回滚: %s", strings.Join(rolledBack, ","))
+	if err != nil {
+		log.Log.Error("roll back publish: %v deploy job: %v occur error: %s", job.PublishID, job.ID, err.Error())
+		metrics.DeployRollbacks.Inc(strconv.FormatInt(job.ProjectID, 10), "failed")
+		status = models.Failed
+		message = fmt.Sprintf("回滚失败: %s", err.Error())
+	} else {
+		metrics.DeployRollbacks.Inc(strconv.FormatInt(job.ProjectID, 10), "success")
+		// the down migrations run after the workloads rolled back, so the new version of apps never runs on the old schema
+		migrations, err := pm.pipelineHandler.RollbackDBMigrations(job)
+		if err != nil {
+			log.Log.Error("roll back publish: %v db migrations occur error: %s", job.PublishID, err.Error())
+			status = models.Failed
+			message = fmt.Sprintf("%s; 数据库回滚失败: %s", message, err.Error())
+		} else if len(migrations) > 0 {
+			message = fmt.Sprintf("%s; 数据库回滚中: %s", message, strings.Join(migrations, ","))
+		}
+	}
+	pm.createPolicyOperationLog(publishItem, job.EnvID, rollbackStepLabel, "自动回滚", status, message)
+}
+
+func (pm *PublishManager) createPolicyOperationLog(publishItem *models.Publish, stageID int64, step, logType string, status int64, message string) {
+	// operation log message column size is 256
+	message = utils.Truncate(message, 256)
+	operationLog := &CreateOperationLogReq{
+		Creator:            "system",
+		StageName:          publishItem.StageName,
+		StepName:           step,
+		Message:            message,
+		Type:               logType,
+		PipelineInstanceID: publishItem.LastPipelineInstanceID,
+		Status:             status,
+		PublishID:          publishItem.ID,
+		StageID:            stageID,
+	}
+	if err := pm.createPublishOperationLogItem(operationLog); err != nil {
+		log.Log.Error("create publish: %v %v operation log occur error: %s", publishItem.ID, step, err.Error())
+	}
+}
+
+func splitApprovers(approvers string) map[string]bool {
+	rsp := map[string]bool{}
+	for _, item := range strings.Split(approvers, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			rsp[item] = true
+		}
+	}
+	return rsp
+}
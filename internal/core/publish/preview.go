@@ -0,0 +1,275 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/project"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/drone/go-scm/scm"
+)
+
+// the pending preview env which images are not built before timeout is regarded as failure
+var previewTimeout = time.Duration(beego.AppConfig.DefaultInt("preview::timeout", 60)) * time.Minute
+
+var invalidDNSChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// dnsLabel convert the name to the rfc1123 label, which is used as the namespace and arrange env of preview
+func dnsLabel(name string, size int) string {
+	label := invalidDNSChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(label) > size {
+		label = label[:size]
+	}
+	return strings.Trim(label, "-")
+}
+
+// previewArrangeEnv eg: api-pr-12, the repo name is kept since the merge requests of repos in project have the same numbers
+func previewArrangeEnv(fullName string, number int) string {
+	name := fullName
+	if index := strings.LastIndex(fullName, "/"); index != -1 {
+		name = fullName[index+1:]
+	}
+	return fmt.Sprintf("%s-pr-%d", dnsLabel(name, 32), number)
+}
+
+// previewURL return the url of the first host which is not wildcard
+func previewURL(hosts []string) string {
+	for _, host := range hosts {
+		if !strings.HasPrefix(host, "*") {
+			return "http://" + host
+		}
+	}
+	return ""
+}
+
+// handlePullRequest deploy the preview envs of the merge request when it opened or updated,
+// and tear them down when it merged or closed, return the summary of the previews handled
+func (pm *PublishManager) handlePullRequest(repoID int64, hook *scm.PullRequestHook) []string {
+	fullName := scm.Join(hook.Repo.Namespace, hook.Repo.Name)
+	pr := hook.PullRequest
+	summary := []string{}
+	for projectID, appIDs := range pm.previewProjectApps(repoID, fullName) {
+		var preview *models.PreviewEnv
+		var err error
+		switch hook.Action {
+		case scm.ActionOpen, scm.ActionReopen, scm.ActionSync:
+			preview, err = pm.openPreview(projectID, repoID, fullName, &pr, appIDs)
+		case scm.ActionClose, scm.ActionMerge:
+			preview, err = pm.closePreview(projectID, fullName, pr.Number)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Log.Warn("handle project: %v preview of %v!%d occur error: %s", projectID, fullName, pr.Number, err.Error())
+			summary = append(summary, fmt.Sprintf("project: %v, error: %s", projectID, err.Error()))
+			continue
+		}
+		if preview != nil {
+			summary = append(summary, fmt.Sprintf("project: %v, env: %v, status: %v", projectID, preview.EnvID, preview.Status))
+		}
+	}
+	return summary
+}
+
+// previewProjectApps return the apps of repo grouped by the projects which have preview source env
+func (pm *PublishManager) previewProjectApps(repoID int64, fullName string) map[int64][]int64 {
+	projectApps := map[int64][]int64{}
+	repoApps, err := pm.gitAppModel.GetScmAppsByRepo(repoID, fullName)
+	if err != nil {
+		log.Log.Warn("get repo: %v apps occur error: %s", fullName, err.Error())
+		return projectApps
+	}
+	for _, scmApp := range repoApps {
+		apps, err := pm.projectModel.GetProjectAppsByScmID(scmApp.ID)
+		if err != nil {
+			log.Log.Warn("get project apps by scm app: %v occur error: %s", scmApp.ID, err.Error())
+			continue
+		}
+		for _, app := range apps {
+			projectApps[app.ProjectID] = append(projectApps[app.ProjectID], app.ID)
+		}
+	}
+	for projectID := range projectApps {
+		if _, err := pm.projectModel.GetPreviewSourceEnv(projectID); err != nil {
+			delete(projectApps, projectID)
+		}
+	}
+	return projectApps
+}
+
+// openPreview clone the preview source env for the merge request at the first time, then deploy the head of it
+func (pm *PublishManager) openPreview(projectID, repoID int64, fullName string, pr *scm.PullRequest, appIDs []int64) (*models.PreviewEnv, error) {
+	preview, err := pm.projectModel.GetPreviewEnv(projectID, fullName, pr.Number)
+	if err == orm.ErrNoRows {
+		preview, err = pm.createPreviewEnv(projectID, repoID, fullName, pr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	preview.Branch = pr.Source
+	preview.Sha = pr.Sha
+	preview.Status = models.PreviewStatusPending
+	preview.SyncAt = &now
+	pm.deployPreview(preview, appIDs)
+	return preview, nil
+}
+
+func (pm *PublishManager) createPreviewEnv(projectID, repoID int64, fullName string, pr *scm.PullRequest) (*models.PreviewEnv, error) {
+	source, err := pm.projectModel.GetPreviewSourceEnv(projectID)
+	if err != nil {
+		return nil, err
+	}
+	arrangeEnv := previewArrangeEnv(fullName, pr.Number)
+	rsp, err := pm.projectHandler.CloneProjectEnv(projectID, source.ID, &project.ProjectEnvCloneReq{
+		Name:       fmt.Sprintf("%v 预览", arrangeEnv),
+		ArrangeEnv: arrangeEnv,
+		Namespace:  dnsLabel(source.Namespace+"-"+arrangeEnv, 63),
+	}, webhookOperator)
+	if err != nil {
+		return nil, err
+	}
+	env := rsp.Env
+	if env.ArgoCD != 0 {
+		// the preview env is applied to cluster directly
+		env.ArgoCD = 0
+		if err := pm.projectModel.UpdateProjectEnv(env); err != nil {
+			return nil, err
+		}
+	}
+	preview := &models.PreviewEnv{
+		Addons:      models.NewAddons(),
+		ProjectID:   projectID,
+		SourceEnvID: source.ID,
+		EnvID:       env.ID,
+		RepoID:      repoID,
+		Repo:        fullName,
+		Number:      pr.Number,
+		Branch:      pr.Source,
+		URL:         previewURL(rsp.Hosts),
+		Status:      models.PreviewStatusPending,
+	}
+	if _, err := pm.projectModel.CreatePreviewEnv(preview); err != nil {
+		return nil, err
+	}
+	log.Log.Info("preview env: %v of %v!%d created", env.Name, fullName, pr.Number)
+	return preview, nil
+}
+
+// deployPreview deploy the apps of merge request repo to the preview env, the preview keeps pending when deploy failed,
+// which is retried by the preview server until timeout. the url is commented on the merge request after the first deploy.
+func (pm *PublishManager) deployPreview(preview *models.PreviewEnv, appIDs []int64) {
+	err := pm.applyPreview(preview, appIDs)
+	switch {
+	case err == nil:
+		preview.Status = models.PreviewStatusDeployed
+		preview.Message = ""
+	case preview.SyncAt != nil && time.Since(*preview.SyncAt) > previewTimeout:
+		preview.Status = models.PreviewStatusFailed
+		preview.Message = err.Error()
+	default:
+		preview.Message = err.Error()
+	}
+	preview.Message = truncateMessage(preview.Message)
+	if preview.Status == models.PreviewStatusDeployed && !preview.Notified {
+		body := fmt.Sprintf("预览环境已部署: %v", preview.URL)
+		if preview.URL == "" {
+			body = "预览环境已部署, 应用编排中没有 ingress 域名"
+		}
+		if err := pm.commentPullRequest(preview, body); err != nil {
+			log.Log.Warn("comment preview url on %v!%d occur error: %s", preview.Repo, preview.Number, err.Error())
+		} else {
+			preview.Notified = true
+		}
+	}
+	if err := pm.projectModel.UpdatePreviewEnv(preview); err != nil {
+		log.Log.Error("update preview env: %v occur error: %s", preview.ID, err.Error())
+	}
+}
+
+func (pm *PublishManager) applyPreview(preview *models.PreviewEnv, appIDs []int64) error {
+	env, err := pm.projectModel.GetProjectEnvByID(preview.EnvID)
+	if err != nil {
+		return err
+	}
+	if err := pm.pipelineHandler.PreparePreviewNamespace(env); err != nil {
+		return err
+	}
+	return pm.pipelineHandler.DeployPreview(env, appIDs, preview.Branch)
+}
+
+// closePreview delete the namespace and env of the preview
+func (pm *PublishManager) closePreview(projectID int64, fullName string, number int) (*models.PreviewEnv, error) {
+	preview, err := pm.projectModel.GetPreviewEnv(projectID, fullName, number)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	env, err := pm.projectModel.GetProjectEnvByID(preview.EnvID)
+	if err == nil {
+		if err := pm.pipelineHandler.TeardownPreview(env); err != nil {
+			return nil, err
+		}
+		if err := pm.projectModel.DeleteProjectEnv(env.ID); err != nil {
+			return nil, err
+		}
+	}
+	preview.Status = models.PreviewStatusClosed
+	if err := pm.projectModel.UpdatePreviewEnv(preview); err != nil {
+		return nil, err
+	}
+	log.Log.Info("preview env: %v of %v!%d torn down", preview.EnvID, fullName, number)
+	return preview, nil
+}
+
+func (pm *PublishManager) commentPullRequest(preview *models.PreviewEnv, body string) error {
+	scmSetting, err := pm.settingsHandler.GetSCMIntegrateSettinByID(preview.RepoID)
+	if err != nil {
+		return err
+	}
+	client, err := apps.NewScmProvider(scmSetting.Type, scmSetting.URL, scmSetting.Token)
+	if err != nil {
+		return err
+	}
+	_, _, err = client.PullRequests.CreateComment(context.Background(), preview.Repo, preview.Number, &scm.CommentInput{Body: body})
+	return err
+}
+
+// SyncPendingPreviews retry to deploy the pending previews, whose images were not built when the merge request updated
+func (pm *PublishManager) SyncPendingPreviews() {
+	previews, err := pm.projectModel.GetPreviewEnvsByStatus(models.PreviewStatusPending)
+	if err != nil {
+		log.Log.Error("get pending preview envs occur error: %s", err.Error())
+		return
+	}
+	for _, preview := range previews {
+		appIDs := pm.previewProjectApps(preview.RepoID, preview.Repo)[preview.ProjectID]
+		pm.deployPreview(preview, appIDs)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import "testing"
+
+func TestPreviewArrangeEnv(t *testing.T) {
+	if got := previewArrangeEnv("group/My_API", 12); got != "my-api-pr-12" {
+		t.Errorf("previewArrangeEnv() = %v, want my-api-pr-12", got)
+	}
+	if got := dnsLabel("default-my-api-pr-12", 11); got != "default-my" {
+		t.Errorf("dnsLabel() = %v, want default-my", got)
+	}
+	if got := previewURL([]string{"*.example.com", "pr-12-demo.example.com"}); got != "http://pr-12-demo.example.com" {
+		t.Errorf("previewURL() = %v", got)
+	}
+}
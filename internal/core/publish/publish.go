@@ -17,11 +17,16 @@ limitations under the License.
 package publish
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
 	"github.com/go-atomci/atomci/internal/core/project"
+	"github.com/go-atomci/atomci/internal/core/rbac"
+	"github.com/go-atomci/atomci/internal/core/settings"
 	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
@@ -30,6 +35,9 @@ import (
 	"github.com/astaxie/beego/logs"
 )
 
+// autoTriggerNextStep trigger the job of step whose previous step driver is auto, replaced in tests
+var autoTriggerNextStep = (*pipelinemgr.PipelineManager).AutoTriggerNextStep
+
 // PublishManager ...
 type PublishManager struct {
 	model           *dao.PublishModel
@@ -37,8 +45,11 @@ type PublishManager struct {
 	gitAppModel     *dao.ScmAppModel
 	projectModel    *dao.ProjectModel
 	k8sModel        *dao.K8sClusterModel
+	planModel       *dao.ReleasePlanModel
+	commentModel    *dao.CommentModel
 	pipelineHandler *pipelinemgr.PipelineManager
 	projectHandler  *project.ProjectManager
+	settingsHandler *settings.SettingManager
 }
 
 // NewPublishManager ...
@@ -49,8 +60,11 @@ func NewPublishManager() *PublishManager {
 		projectModel:    dao.NewProjectModel(),
 		gitAppModel:     dao.NewScmAppModel(),
 		k8sModel:        dao.NewK8sClusterModel(),
+		planModel:       dao.NewReleasePlanModel(),
+		commentModel:    dao.NewCommentModel(),
 		pipelineHandler: pipelinemgr.NewPipelineManager(),
 		projectHandler:  project.NewProjectManager(),
+		settingsHandler: settings.NewSettingManager(),
 	}
 }
 
@@ -80,6 +94,8 @@ func (pm *PublishManager) CreatePublish(user string, projectID int64, p *Publish
 		PipelineID: p.BindPipelineID,
 		Creator:    user,
 		VersionNo:  p.VersionNo,
+		Level:      p.Level,
+		Approvers:  strings.Join(p.Approvers, ","),
 	}
 	publishID, err := pm.model.CreatePublishifNotExist(&publishModel)
 	log.Log.Debug("create publish success ID: %v", publishID)
@@ -153,6 +169,12 @@ func (pm *PublishManager) GetPublishInfo(publishID int64) (*PublishInfoResp, err
 
 // UpdatePublish ..
 func (pm *PublishManager) UpdatePublish(publishID, stageID, status, runID int64, creator, message, jobName string) error {
+	return pm.UpdatePublishWithInputs(publishID, stageID, status, runID, creator, message, jobName, nil)
+}
+
+// UpdatePublishWithInputs update the publish status, the input values filled in by the approver of manual step
+// are stored on the operation log, so the subsequent jobs of the pipeline instance get them
+func (pm *PublishManager) UpdatePublishWithInputs(publishID, stageID, status, runID int64, creator, message, jobName string, inputs map[string]string) error {
 	// status is -1, mean skip update status
 	if status == models.Skipped {
 		return nil
@@ -162,30 +184,66 @@ func (pm *PublishManager) UpdatePublish(publishID, stageID, status, runID int64,
 	if err != nil {
 		return err
 	}
+	return pm.updatePublishStep(publishItem, stageID, publishItem.StepIndex, status, runID, creator, message, jobName, inputs)
+}
+
+// UpdatePublishStep update the status of the step by index, the steps of stage maybe parallel,
+// so the result of publish job updates the step of job instead of the current step of publish
+func (pm *PublishManager) UpdatePublishStep(publishID, stageID int64, stepIndex int, status, runID int64, creator, message, jobName string) error {
+	if status == models.Skipped {
+		return nil
+	}
+
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return err
+	}
+	if stepIndex == 0 {
+		stepIndex = publishItem.StepIndex
+	}
+	return pm.updatePublishStep(publishItem, stageID, stepIndex, status, runID, creator, message, jobName, nil)
+}
+
+func (pm *PublishManager) updatePublishStep(publishItem *models.Publish, stageID int64, stepIndex int, status, runID int64, creator, message, jobName string, inputs map[string]string) error {
+	publishID := publishItem.ID
+	stepType, stepName := publishItem.StepType, publishItem.Step
+	if stepIndex != publishItem.StepIndex {
+		var err error
+		if stepType, stepName, err = pm.pipelineHandler.GetNextStepType(publishID, stepIndex); err != nil {
+			return err
+		}
+	}
 
 	// create operation log
 	createOperationLogReq := &CreateOperationLogReq{
 		Creator:            creator,
 		StageName:          publishItem.StageName,
-		StepName:           publishItem.Step,
+		StepName:           stepName,
 		Message:            message,
 		PipelineInstanceID: publishItem.LastPipelineInstanceID,
-		StepIndex:          publishItem.StepIndex,
+		StepIndex:          stepIndex,
 		Status:             status,
 		PublishID:          publishItem.ID,
 		StageID:            publishItem.StageID,
 		RunID:              runID,
 		JobName:            jobName,
 	}
+	if len(inputs) > 0 {
+		bytes, err := json.Marshal(inputs)
+		if err != nil {
+			return err
+		}
+		createOperationLogReq.Inputs = string(bytes)
+	}
 	if err := pm.createPublishOperationLogItem(createOperationLogReq); err != nil {
 		log.Log.Error("when update publish order status, create publish OperationLog occur error: %s", err.Error())
 	}
 
-	nextStepIndex := publishItem.StepIndex
-	nextStepType := publishItem.StepType
-	nextStepName := publishItem.Step
+	nextStepIndex := stepIndex
+	nextStepType := stepType
+	nextStepName := stepName
 	if status == models.Success {
-		lastStage, lastStep, err := pm.pipelineHandler.CheckCurrentStepWhertherLastStageLastStep(publishID, stageID)
+		lastStage, lastStep, err := pm.pipelineHandler.CheckCurrentStepWhertherLastStageLastStep(publishID, stageID, stepIndex)
 		if err != nil {
 			log.Log.Error("when updatePublish, check current step Wherther last stage last step occur error: %s", err.Error())
 		}
@@ -193,50 +251,111 @@ func (pm *PublishManager) UpdatePublish(publishID, stageID, status, runID int64,
 			if lastStage {
 				status = models.END
 			}
-		} else {
-			// when status is success, and not lastStep
-			nextStepIndex = nextStepIndex + 1
-			stepType, nstepName, err := pm.pipelineHandler.GetNextStepType(publishID, nextStepIndex)
-			if err != nil {
-				log.Log.Error("after trigger pipeline operation, get nextStepType failed: %v", err.Error())
+		} else if ready, _, err := pm.pipelineHandler.GetReadyStepIndexes(publishID, stageID, stepIndex); err != nil || len(ready) == 0 {
+			// the parallel steps are still running, focus on the running step and wait for them
+			log.Log.Info("publish: %v step: %v success, there is no ready step to continue, error: %v", publishID, stepIndex, err)
+			if index, ok := pm.runningStepIndex(publishItem, stageID); ok {
+				if nextStepType, nextStepName, err = pm.pipelineHandler.GetNextStepType(publishID, index); err == nil {
+					nextStepIndex, status = index, models.Running
+				}
 			}
-			nextStepType = stepType
-			nextStepName = nstepName
-			status = models.Pending
+		} else {
+			nextStepIndex, nextStepType, nextStepName, status = pm.triggerReadySteps(publishItem, stageID, stepIndex, ready)
+		}
+	}
+	log.Log.Debug("==>nextStepType: %v， nextStepName: %v, nextStepIndex: %v", nextStepType, nextStepName, nextStepIndex)
+	if err := pm.updatePublishModel(publishItem, stageID, status, nextStepIndex, nextStepType, nextStepName); err != nil {
+		return err
+	}
+	if status == models.Success {
+		// the stage finished, promote to the next stage if the stage policy is auto promote
+		go NewPublishManager().autoPromote(publishID)
+	}
+	return nil
+}
 
-			// check driver type: auto/ manual
-			status, runID, jobName, err = pm.autoDriverCheckAndTrigger(publishItem, nextStepType)
-			if err != nil {
-				log.Log.Error("when updatePublish, autoDriverCheckAndTrigger, occur error: %s", err.Error())
+// runningStepIndex return the first running step of stage
+func (pm *PublishManager) runningStepIndex(publishItem *models.Publish, stageID int64) (int, bool) {
+	states, err := pm.pipelineHandler.GetStageStepStates(publishItem.LastPipelineInstanceID, stageID)
+	if err != nil {
+		return 0, false
+	}
+	indexes := []int{}
+	for index, status := range states {
+		if status == models.Running {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return 0, false
+	}
+	sort.Ints(indexes)
+	return indexes[0], true
+}
+
+// triggerReadySteps trigger each ready step after the step success if the driver of step is auto,
+// the publish focus on the first step waiting for manual trigger, otherwise the first step triggered
+func (pm *PublishManager) triggerReadySteps(publishItem *models.Publish, stageID int64, stepIndex int, ready []int) (int, string, string, int64) {
+	type readyStep struct {
+		index    int
+		stepType string
+		stepName string
+		status   int64
+	}
+	steps := []*readyStep{}
+	for _, index := range ready {
+		stepType, stepName, err := pm.pipelineHandler.GetNextStepType(publishItem.ID, index)
+		if err != nil {
+			log.Log.Error("after trigger pipeline operation, get nextStepType failed: %v", err.Error())
+			continue
+		}
+		// save the next step before auto trigger, the publish job created belongs to it
+		if err := pm.updatePublishModel(publishItem, stageID, models.Pending, index, stepType, stepName); err != nil {
+			log.Log.Error("when trigger ready step: %v, update publish occur error: %s", index, err.Error())
+			continue
+		}
+		// check driver type: auto/ manual
+		status, runID, jobName, err := pm.autoDriverCheckAndTrigger(publishItem, stepIndex, stepType)
+		if err != nil {
+			log.Log.Error("when updatePublish, autoDriverCheckAndTrigger, occur error: %s", err.Error())
+		}
+		if status != models.Pending {
+			// create operation log
+			operationLog := &CreateOperationLogReq{
+				Creator:            "system",
+				StageName:          publishItem.StageName,
+				StepName:           stepName,
+				Message:            "",
+				Type:               "自动流转",
+				PipelineInstanceID: publishItem.LastPipelineInstanceID,
+				StepIndex:          index,
+				Status:             status,
+				PublishID:          publishItem.ID,
+				StageID:            publishItem.StageID,
+				RunID:              runID,
+				JobName:            jobName,
 			}
-			if status != models.Pending {
-				// create operation log
-				operationLog := &CreateOperationLogReq{
-					Creator:            "system",
-					StageName:          publishItem.StageName,
-					StepName:           nextStepName,
-					Message:            "",
-					Type:               "自动流转",
-					PipelineInstanceID: publishItem.LastPipelineInstanceID,
-					StepIndex:          nextStepIndex,
-					Status:             status,
-					PublishID:          publishItem.ID,
-					StageID:            publishItem.StageID,
-					RunID:              runID,
-					JobName:            jobName,
-				}
-				if err := pm.createPublishOperationLogItem(operationLog); err != nil {
-					log.Log.Error("when update publish order status, create publish OperationLog occur error: %s", err.Error())
-				}
+			if err := pm.createPublishOperationLogItem(operationLog); err != nil {
+				log.Log.Error("when update publish order status, create publish OperationLog occur error: %s", err.Error())
 			}
 		}
+		steps = append(steps, &readyStep{index: index, stepType: stepType, stepName: stepName, status: status})
 	}
-	log.Log.Debug("==>nextStepType: %v， nextStepName: %v, nextStepIndex: %v", nextStepType, nextStepName, nextStepIndex)
-	return pm.updatePublishModel(publishItem, stageID, status, nextStepIndex, nextStepType, nextStepName)
+	if len(steps) == 0 {
+		return publishItem.StepIndex, publishItem.StepType, publishItem.Step, models.Pending
+	}
+	focus := steps[0]
+	for _, step := range steps {
+		if step.status == models.Pending {
+			focus = step
+			break
+		}
+	}
+	return focus.index, focus.stepType, focus.stepName, focus.status
 }
 
 // auto driver check
-func (pm *PublishManager) autoDriverCheckAndTrigger(publishItem *models.Publish, nextStepType string) (int64, int64, string, error) {
+func (pm *PublishManager) autoDriverCheckAndTrigger(publishItem *models.Publish, stepIndex int, nextStepType string) (int64, int64, string, error) {
 	// check driver type: auto/ manual
 	stageInstanceJSON, err := pm.pipelineHandler.GetPipelineInstanceEnvStageByID(publishItem.LastPipelineInstanceID, publishItem.StageID)
 	if err != nil {
@@ -247,14 +366,14 @@ func (pm *PublishManager) autoDriverCheckAndTrigger(publishItem *models.Publish,
 		}
 	}
 	for _, step := range stageInstanceJSON.Steps {
-		if step.Type == publishItem.StepType {
+		if step.Index == stepIndex {
 			switch step.Driver {
 			case "auto":
 				log.Log.Debug("step's Driver is auto, start autoTrigger check..")
 				if nextStepType == "manual" {
 					break
 				}
-				status, runID, jobName, err := pm.pipelineHandler.AutoTriggerNextStep(publishItem, nextStepType)
+				status, runID, jobName, err := autoTriggerNextStep(pm.pipelineHandler, publishItem, nextStepType)
 				if err != nil {
 					log.Log.Error("Auto trigger next step failed, msg: %s", err.Error())
 					return models.Failed, 0, "", err
@@ -317,8 +436,12 @@ func (pm *PublishManager) UpdatePublishBaseInfo(publishID int64, req *PublishUpd
 	if publish.Status == models.Closed {
 		return fmt.Errorf("流水线的状态为：已归档，禁止更新")
 	}
+	if err := verifyPublishLevel(req.Level); err != nil {
+		return err
+	}
 	publish.Name = req.Name
 	publish.VersionNo = req.VersionNo
+	publish.Level = req.Level
 	return pm.model.UpdatePublish(publish)
 }
 
@@ -463,7 +586,36 @@ func (pm *PublishManager) TriggerNextStage(projectID, publishID, envID int64, re
 	if currentStage.Index > reqStage.Index {
 		return fmt.Errorf("NextStage operation can only be returned to the next stage, publish-Order id: %d", modelPublish.ID)
 	}
-	return pm.updatePublishOrderStatus(modelPublish, modelPublish.LastPipelineInstanceID, req.StageID, reqStage, currentUser, "next-stage", "")
+	if !req.Automated {
+		if err := rbac.VerifyEnvAction(currentUser, projectID, req.StageID, rbac.ActionPromote); err != nil {
+			return err
+		}
+	}
+	if err := pm.verifyStageApproval(modelPublish, req.StageID); err != nil {
+		return err
+	}
+	if err := pm.pipelineHandler.VerifyQualityGate(modelPublish.LastPipelineInstanceID, publishID, req.StageID); err != nil {
+		return err
+	}
+	if err := pm.verifyMergeGate(publishID, envID); err != nil {
+		return err
+	}
+	if err := pm.pipelineHandler.VerifyE2ETestGate(modelPublish.LastPipelineInstanceID, publishID, envID); err != nil {
+		return err
+	}
+	if err := pm.createReleaseBranches(modelPublish, req.StageID); err != nil {
+		return err
+	}
+	if err := pm.updatePublishOrderStatus(modelPublish, modelPublish.LastPipelineInstanceID, req.StageID, reqStage, currentUser, "next-stage", ""); err != nil {
+		return err
+	}
+	// listing commits from scm is slow, generate the release notes of promoted env in background
+	go func() {
+		if _, err := NewPublishManager().GenerateReleaseNotes(publishID, req.StageID); err != nil {
+			log.Log.Error("generate publish: %v release notes occur error: %s", publishID, err.Error())
+		}
+	}()
+	return nil
 }
 
 // GetPublishOperationLog ..
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// TestUpdatePublishStepParallel the success of step triggers all the ready branches,
+// the result of each branch updates its own step
+func TestUpdatePublishStepParallel(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+
+	triggered := []string{}
+	origin := autoTriggerNextStep
+	autoTriggerNextStep = func(handler *pipelinemgr.PipelineManager, publishItem *models.Publish, nextStepType string) (int64, int64, string, error) {
+		triggered = append(triggered, fmt.Sprintf("%v:%v", publishItem.StepIndex, nextStepType))
+		return models.Running, int64(publishItem.StepIndex), nextStepType, nil
+	}
+	t.Cleanup(func() { autoTriggerNextStep = origin })
+
+	stageID := insertTestItem(t, &models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 1, Name: "dev"})
+	stepIDs := []int64{}
+	for _, name := range []string{models.StepBuild, models.StepDeploy, models.StepE2ETest, models.StepVerify} {
+		stepIDs = append(stepIDs, insertTestItem(t, &models.TaskTmpl{Addons: models.NewAddons(), Name: name, Type: name}))
+	}
+	config := fmt.Sprintf(`[{"stage_id":%d,"steps":[{"index":1,"step_id":%d,"type":"build","driver":"auto"},{"index":2,"step_id":%d,"type":"deploy","depends_on":[1]},{"index":3,"step_id":%d,"type":"e2e-test","depends_on":[1]},{"index":4,"step_id":%d,"type":"verify","depends_on":[2,3]}]}]`,
+		stageID, stepIDs[0], stepIDs[1], stepIDs[2], stepIDs[3])
+	instanceID := insertTestItem(t, &models.PipelineInstance{Addons: models.NewAddons(), Config: config})
+	publishID := insertTestItem(t, &models.Publish{Addons: models.NewAddons(), ProjectID: 1, StageID: stageID, StepIndex: 1, StepType: models.StepBuild, Step: models.StepBuild, Status: models.Running, LastPipelineInstanceID: instanceID})
+	insertTestItem(t, &models.PublishOperationLog{Addons: models.NewAddons(), PublishID: publishID, PipelineInstanceID: instanceID, StageID: stageID, StepIndex: 1, Status: models.Running})
+
+	check := func(wantIndex int, wantStatus int64, wantStates map[int]int64) {
+		t.Helper()
+		publishItem, err := pm.model.GetPublishByID(publishID)
+		if err != nil || publishItem.StepIndex != wantIndex || publishItem.Status != wantStatus {
+			t.Fatalf("publish step = %v, status = %v, %v, want %v, %v", publishItem.StepIndex, publishItem.Status, err, wantIndex, wantStatus)
+		}
+		states, err := pm.pipelineHandler.GetStageStepStates(instanceID, stageID)
+		if err != nil || !reflect.DeepEqual(states, wantStates) {
+			t.Fatalf("step states = %v, %v, want %v", states, err, wantStates)
+		}
+	}
+
+	// the build success, both the deploy and e2e test branches are triggered
+	if err := pm.UpdatePublishStep(publishID, stageID, 1, models.Success, 0, "system", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2:deploy", "3:e2e-test"}; !reflect.DeepEqual(triggered, want) {
+		t.Fatalf("triggered steps = %v, want %v", triggered, want)
+	}
+	check(2, models.Running, map[int]int64{1: models.Success, 2: models.Running, 3: models.Running})
+
+	// the e2e test success while the deploy is running, the publish waits for the deploy
+	if err := pm.UpdatePublishStep(publishID, stageID, 3, models.Success, 0, "system", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	check(2, models.Running, map[int]int64{1: models.Success, 2: models.Running, 3: models.Success})
+
+	// the deploy failed, the e2e test keeps its own state
+	if err := pm.UpdatePublishStep(publishID, stageID, 2, models.Failed, 0, "system", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	check(2, models.Failed, map[int]int64{1: models.Success, 2: models.Failed, 3: models.Success})
+
+	// the deploy retried success, the join step waits for manual trigger
+	if err := pm.UpdatePublishStep(publishID, stageID, 2, models.Success, 0, "system", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(triggered) != 2 {
+		t.Fatalf("the manual driver step should not trigger next step, triggered: %v", triggered)
+	}
+	check(4, models.Pending, map[int]int64{1: models.Success, 2: models.Success, 3: models.Success})
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// renderReleaseBranch replace the placeholders {version}/{id} of release branch template
+func renderReleaseBranch(template string, publishItem *models.Publish) string {
+	replacer := strings.NewReplacer(
+		"{version}", strings.TrimSpace(publishItem.VersionNo),
+		"{id}", fmt.Sprintf("%v", publishItem.ID),
+	)
+	return replacer.Replace(template)
+}
+
+// createReleaseBranches create the release branch in each app repo when publish enter the env,
+// the branch was recorded on publish app and used by the subsequent builds
+func (pm *PublishManager) createReleaseBranches(publishItem *models.Publish, envID int64) error {
+	env, err := pm.projectModel.GetProjectEnvByID(envID)
+	if err != nil {
+		return err
+	}
+	if env.ReleaseBranch == "" {
+		return nil
+	}
+	releaseBranch := renderReleaseBranch(env.ReleaseBranch, publishItem)
+	publishApps, err := pm.model.GetPublishAppsByID(publishItem.ID)
+	if err != nil {
+		return err
+	}
+	for _, app := range publishApps {
+		if app.ReleaseBranch == releaseBranch {
+			continue
+		}
+		if err := pm.pipelineHandler.CreateReleaseBranch(app.ProjectAppID, app.BranchName, releaseBranch); err != nil {
+			log.Log.Error("publish: %v create release branch occur error: %s", publishItem.ID, err.Error())
+			return fmt.Errorf("创建发布分支 %v 失败: %s", releaseBranch, err.Error())
+		}
+		app.ReleaseBranch = releaseBranch
+		if err := pm.model.UpdatePublishApp(app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
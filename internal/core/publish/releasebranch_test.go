@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestRenderReleaseBranch(t *testing.T) {
+	publishItem := &models.Publish{VersionNo: " 1.2 "}
+	publishItem.ID = 10
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"release/{version}", "release/1.2"},
+		{"release/{version}-{id}", "release/1.2-10"},
+		{"release/latest", "release/latest"},
+	}
+	for _, tt := range tests {
+		if got := renderReleaseBranch(tt.template, publishItem); got != tt.want {
+			t.Errorf("renderReleaseBranch(%q) = %v, want %v", tt.template, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/drone/go-scm/scm"
+)
+
+// releaseNotesMaxCommits max commits of one app listed in release notes
+const releaseNotesMaxCommits = 100
+
+// the system default image tag is branch-sha7
+var imageTagCommitRegexp = regexp.MustCompile(`-([0-9a-f]{7,40})$`)
+
+// GenerateReleaseNotes aggregate the commits of each publish app since the image deployed in env,
+// render them as markdown and store on the publish order, envID 0 means the current stage of publish
+func (pm *PublishManager) GenerateReleaseNotes(publishID, envID int64) (string, error) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return "", err
+	}
+	if envID == 0 {
+		envID = publishItem.StageID
+	}
+	envName := fmt.Sprintf("%v", envID)
+	if env, err := pm.projectModel.GetProjectEnvByID(envID); err == nil {
+		envName = env.Name
+	}
+	publishApps, err := pm.model.GetPublishAppsByID(publishID)
+	if err != nil {
+		return "", err
+	}
+
+	var notes strings.Builder
+	fmt.Fprintf(&notes, "# %s %s\n\n", publishItem.Name, publishItem.VersionNo)
+	fmt.Fprintf(&notes, "- Environment: %s\n", envName)
+	fmt.Fprintf(&notes, "- Generated At: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	for _, app := range publishApps {
+		notes.WriteString("\n")
+		notes.WriteString(pm.appReleaseNotes(app, envID, publishID))
+	}
+
+	publishItem.ReleaseNotes = notes.String()
+	if err := pm.model.UpdatePublish(publishItem); err != nil {
+		return "", err
+	}
+	return publishItem.ReleaseNotes, nil
+}
+
+// GetReleaseNotes return the stored release notes, generate it for current env when it is empty
+func (pm *PublishManager) GetReleaseNotes(publishID int64) (*models.Publish, error) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		return nil, err
+	}
+	if publishItem.ReleaseNotes == "" {
+		if publishItem.ReleaseNotes, err = pm.GenerateReleaseNotes(publishID, 0); err != nil {
+			return nil, err
+		}
+	}
+	return publishItem, nil
+}
+
+func (pm *PublishManager) appReleaseNotes(app *models.PublishApp, envID, publishID int64) string {
+	commits, scmApp, err := pm.pipelineHandler.ListAppCommits(app.ProjectAppID, app.BranchName, releaseNotesMaxCommits)
+	if err != nil {
+		log.Log.Warn("when generate release notes, list app: %v commits occur error: %s", app.ProjectAppID, err.Error())
+		return fmt.Sprintf("## %v (%s)\n\n> list commits failed: %s\n", app.ProjectAppID, app.BranchName, err.Error())
+	}
+
+	previous := ""
+	if jobApp, err := dao.NewPublishJobModel().GetLastSuccessDeployJobApp(app.ProjectAppID, envID, publishID); err == nil {
+		previous = imageTagCommit(jobApp.ImageAddr)
+	}
+	commits, found := commitsSince(commits, previous)
+
+	var notes strings.Builder
+	fmt.Fprintf(&notes, "## %s (%s)\n\n", scmApp.Name, app.BranchName)
+	switch {
+	case previous == "":
+		notes.WriteString("> no previous deployment found in this environment\n\n")
+	case !found:
+		fmt.Fprintf(&notes, "> previous deployed commit %s was not found in the latest %v commits\n\n", previous, releaseNotesMaxCommits)
+	default:
+		fmt.Fprintf(&notes, "> changes since %s\n\n", previous)
+	}
+	if len(commits) == 0 {
+		notes.WriteString("- no changes\n")
+	}
+	for _, commit := range commits {
+		fmt.Fprintf(&notes, "- %s %s (%s)\n", shortSha(commit.Sha), strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0], commit.Author.Name)
+	}
+	return notes.String()
+}
+
+// imageTagCommit return the commit sha of the image tag, empty when the tag is not generated by system
+func imageTagCommit(image string) string {
+	index := strings.LastIndex(image, ":")
+	if index < 0 || strings.Contains(image[index:], "/") {
+		return ""
+	}
+	matches := imageTagCommitRegexp.FindStringSubmatch(image[index+1:])
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+// commitsSince return the commits newer than sha, all the commits are returned when the sha was not found
+func commitsSince(commits []*scm.Commit, sha string) ([]*scm.Commit, bool) {
+	if sha == "" {
+		return commits, false
+	}
+	for i, commit := range commits {
+		if strings.HasPrefix(commit.Sha, sha) {
+			return commits[:i], true
+		}
+	}
+	return commits, false
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
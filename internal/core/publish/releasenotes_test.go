@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"testing"
+
+	"github.com/drone/go-scm/scm"
+)
+
+func TestImageTagCommit(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "10.10.0.8:9980/atomci/app:master-1a2b3c4", want: "1a2b3c4"},
+		{image: "registry.io/app:release-1.0-abcdef0", want: "abcdef0"},
+		{image: "registry.io/app:latest", want: ""},
+		{image: "10.10.0.8:9980/atomci/app", want: ""},
+	}
+	for _, tt := range tests {
+		if got := imageTagCommit(tt.image); got != tt.want {
+			t.Errorf("imageTagCommit(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestCommitsSince(t *testing.T) {
+	commits := []*scm.Commit{{Sha: "ccccccc111"}, {Sha: "bbbbbbb222"}, {Sha: "aaaaaaa333"}}
+	if got, found := commitsSince(commits, "bbbbbbb"); !found || len(got) != 1 || got[0].Sha != "ccccccc111" {
+		t.Errorf("commitsSince() = %v, %v, want the newest commit", got, found)
+	}
+	if got, found := commitsSince(commits, "ddddddd"); found || len(got) != 3 {
+		t.Errorf("commitsSince() = %v, %v, want all commits when sha not found", got, found)
+	}
+}
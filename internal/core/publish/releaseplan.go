@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// GetReleasePlans ..
+func (pm *PublishManager) GetReleasePlans(projectID int64) ([]*ReleasePlanRsp, error) {
+	plans, err := pm.planModel.GetReleasePlansByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	rsp := []*ReleasePlanRsp{}
+	for _, plan := range plans {
+		rsp = append(rsp, pm.formatReleasePlanRsp(plan))
+	}
+	return rsp, nil
+}
+
+// GetReleasePlan ..
+func (pm *PublishManager) GetReleasePlan(projectID, planID int64) (*ReleasePlanRsp, error) {
+	plan, err := pm.getProjectReleasePlan(projectID, planID)
+	if err != nil {
+		return nil, err
+	}
+	return pm.formatReleasePlanRsp(plan), nil
+}
+
+// CreateReleasePlan ..
+func (pm *PublishManager) CreateReleasePlan(user string, projectID int64, req *ReleasePlanReq) error {
+	if err := pm.releasePlanParamVerify(projectID, req); err != nil {
+		return err
+	}
+	if _, err := pm.planModel.GetReleasePlanByName(projectID, req.Name); err == nil {
+		return fmt.Errorf("发布计划名称 %v 已存在，请更换后重试", req.Name)
+	}
+	plan := &models.ReleasePlan{
+		Addons:    models.NewAddons(),
+		ProjectID: projectID,
+		Creator:   user,
+	}
+	if err := fillReleasePlan(plan, req); err != nil {
+		return err
+	}
+	_, err := pm.planModel.CreateReleasePlan(plan)
+	return err
+}
+
+// UpdateReleasePlan ..
+func (pm *PublishManager) UpdateReleasePlan(projectID, planID int64, req *ReleasePlanReq) error {
+	plan, err := pm.getProjectReleasePlan(projectID, planID)
+	if err != nil {
+		return err
+	}
+	if err := pm.releasePlanParamVerify(projectID, req); err != nil {
+		return err
+	}
+	if item, err := pm.planModel.GetReleasePlanByName(projectID, req.Name); err == nil && item.ID != planID {
+		return fmt.Errorf("发布计划名称 %v 已存在，请更换后重试", req.Name)
+	}
+	if err := fillReleasePlan(plan, req); err != nil {
+		return err
+	}
+	return pm.planModel.UpdateReleasePlan(plan)
+}
+
+// DeleteReleasePlan ..
+func (pm *PublishManager) DeleteReleasePlan(projectID, planID int64) error {
+	if _, err := pm.getProjectReleasePlan(projectID, planID); err != nil {
+		return err
+	}
+	return pm.planModel.DeleteReleasePlan(planID)
+}
+
+// CreatePublishFromReleasePlan create publish order with the apps, pipeline and approvers of release plan
+func (pm *PublishManager) CreatePublishFromReleasePlan(user string, projectID, planID int64, req *ReleasePlanPublishReq) error {
+	plan, err := pm.GetReleasePlan(projectID, planID)
+	if err != nil {
+		return err
+	}
+	branches := map[int64]string{}
+	for _, app := range req.Apps {
+		branches[app.AppID] = app.BranchName
+	}
+	apps := []*PubllishReqApp{}
+	for _, app := range plan.Apps {
+		item := *app
+		if branch := branches[app.AppID]; branch != "" {
+			item.BranchName = branch
+		}
+		apps = append(apps, &item)
+	}
+	name := req.Name
+	if name == "" {
+		name = plan.Name
+	}
+	log.Log.Info("create publish: %v from release plan: %v", req.VersionNo, plan.ID)
+	return pm.CreatePublish(user, projectID, &PublishReq{
+		Apps:           apps,
+		Name:           name,
+		BindPipelineID: plan.PipelineID,
+		VersionNo:      req.VersionNo,
+		Level:          plan.Level,
+		Approvers:      plan.Approvers,
+	})
+}
+
+func (pm *PublishManager) getProjectReleasePlan(projectID, planID int64) (*models.ReleasePlan, error) {
+	plan, err := pm.planModel.GetReleasePlanByID(planID)
+	if err != nil {
+		log.Log.Error("get release plan: %v occur error: %s", planID, err.Error())
+		return nil, fmt.Errorf("发布计划: %v 不存在", planID)
+	}
+	if plan.ProjectID != projectID {
+		return nil, fmt.Errorf("发布计划: %v 不属于此项目，操作拒绝", planID)
+	}
+	return plan, nil
+}
+
+func (pm *PublishManager) releasePlanParamVerify(projectID int64, req *ReleasePlanReq) error {
+	if req.Name == "" || len(req.Name) > 64 {
+		return fmt.Errorf("发布计划名称不能为空，且不允许超过64个字符")
+	}
+	pipeline, err := pm.projectModel.GetProjectPipelineByID(req.BindPipelineID)
+	if err != nil || pipeline.ProjectID != projectID {
+		return fmt.Errorf("请选择此项目有效的流程后重试")
+	}
+	if len(req.Apps) == 0 {
+		return fmt.Errorf("请至少勾选一个代码库后，重试")
+	}
+	for _, app := range req.Apps {
+		projectApp, err := pm.projectModel.GetProjectApp(app.AppID)
+		if err != nil || projectApp.ProjectID != projectID {
+			return fmt.Errorf("应用: %v 不属于此项目，请确认后重试", app.AppID)
+		}
+		if app.BranchName == "" {
+			return fmt.Errorf("请确认分支选择")
+		}
+	}
+	if err := verifyApprovers(req.Approvers); err != nil {
+		return err
+	}
+	return verifyPublishLevel(req.Level)
+}
+
+func fillReleasePlan(plan *models.ReleasePlan, req *ReleasePlanReq) error {
+	apps, err := json.Marshal(req.Apps)
+	if err != nil {
+		return err
+	}
+	plan.Name = req.Name
+	plan.Description = req.Description
+	plan.PipelineID = req.BindPipelineID
+	plan.Apps = string(apps)
+	plan.Approvers = strings.Join(req.Approvers, ",")
+	plan.Level = req.Level
+	return nil
+}
+
+func (pm *PublishManager) formatReleasePlanRsp(plan *models.ReleasePlan) *ReleasePlanRsp {
+	rsp := &ReleasePlanRsp{
+		ReleasePlan: plan,
+		Apps:        []*PubllishReqApp{},
+		Approvers:   []string{},
+	}
+	if err := json.Unmarshal([]byte(plan.Apps), &rsp.Apps); err != nil {
+		log.Log.Warn("parse release plan: %v apps occur error: %s", plan.ID, err.Error())
+	}
+	if plan.Approvers != "" {
+		rsp.Approvers = strings.Split(plan.Approvers, ",")
+	}
+	if pipeline, err := pm.projectModel.GetProjectPipelineByID(plan.PipelineID); err == nil {
+		rsp.PipelineName = pipeline.Name
+	}
+	return rsp
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+func TestReleasePlan(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+	project := newTestProject(t)
+	other := newTestProject(t)
+	newTestUser(t, "approver")
+
+	newReq := func(name string) *ReleasePlanReq {
+		return &ReleasePlanReq{
+			Name:           name,
+			BindPipelineID: project.PipelineID,
+			Apps:           []*PubllishReqApp{{AppID: project.AppID, BranchName: "master"}},
+			Approvers:      []string{"approver"},
+			Level:          models.PublishLevelHigh,
+		}
+	}
+	invalid := []struct {
+		name   string
+		modify func(req *ReleasePlanReq)
+		err    string
+	}{
+		{"empty name", func(req *ReleasePlanReq) { req.Name = "" }, "发布计划名称不能为空"},
+		{"pipeline of other project", func(req *ReleasePlanReq) { req.BindPipelineID = other.PipelineID }, "请选择此项目有效的流程"},
+		{"no app", func(req *ReleasePlanReq) { req.Apps = nil }, "请至少勾选一个代码库"},
+		{"app of other project", func(req *ReleasePlanReq) { req.Apps[0].AppID = other.AppID }, "不属于此项目"},
+		{"no branch", func(req *ReleasePlanReq) { req.Apps[0].BranchName = "" }, "请确认分支选择"},
+		{"unknown approver", func(req *ReleasePlanReq) { req.Approvers = []string{"nobody"} }, "审批人 nobody 不存在"},
+		{"invalid level", func(req *ReleasePlanReq) { req.Level = 9 }, "不支持的流水线紧急程度"},
+	}
+	for _, tt := range invalid {
+		req := newReq("weekly")
+		tt.modify(req)
+		if err := pm.CreateReleasePlan("admin", project.ID, req); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: CreateReleasePlan() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	for _, name := range []string{"weekly", "hotfix"} {
+		if err := pm.CreateReleasePlan("admin", project.ID, newReq(name)); err != nil {
+			t.Fatalf("CreateReleasePlan() error: %v", err)
+		}
+	}
+	if err := pm.CreateReleasePlan("admin", project.ID, newReq("weekly")); err == nil {
+		t.Errorf("the plan name should be unique in project")
+	}
+	plans, err := pm.GetReleasePlans(project.ID)
+	if err != nil || len(plans) != 2 {
+		t.Fatalf("GetReleasePlans() = %v, %v", plans, err)
+	}
+	plan := plans[0]
+	if plan.Name == "hotfix" {
+		plan = plans[1]
+	}
+	if plan.PipelineName != "default" || !reflect.DeepEqual(plan.Approvers, []string{"approver"}) || len(plan.Apps) != 1 || plan.Apps[0].AppID != project.AppID {
+		t.Errorf("the release plan = %+v", plan)
+	}
+
+	if err := pm.UpdateReleasePlan(project.ID, plan.ID, newReq("hotfix")); err == nil {
+		t.Errorf("the plan should not be renamed to the name of other plan")
+	}
+	if _, err := pm.GetReleasePlan(other.ID, plan.ID); err == nil || !strings.Contains(err.Error(), "不属于此项目") {
+		t.Errorf("GetReleasePlan() of other project error = %v", err)
+	}
+	if err := pm.DeleteReleasePlan(other.ID, plan.ID); err == nil {
+		t.Errorf("the plan of other project should not be deleted")
+	}
+
+	// the publish is created with the plan, the branch of app is overridden
+	project.gitlab.branches["feature"] = []string{"f0000000000"}
+	err = pm.CreatePublishFromReleasePlan("admin", project.ID, plan.ID, &ReleasePlanPublishReq{
+		VersionNo: "v1.0.0",
+		Apps:      []*PubllishReqApp{{AppID: project.AppID, BranchName: "feature"}},
+	})
+	if err != nil {
+		t.Fatalf("CreatePublishFromReleasePlan() error: %v", err)
+	}
+	publishes := getProjectPublishes(t, project.ID)
+	if len(publishes) != 1 {
+		t.Fatalf("the publish should be created, publishes: %v", publishes)
+	}
+	publishItem := publishes[0]
+	if publishItem.Name != "weekly" || publishItem.VersionNo != "v1.0.0" || publishItem.Approvers != "approver" || publishItem.Level != models.PublishLevelHigh ||
+		publishItem.PipelineID != project.PipelineID || publishItem.StageID != project.EnvID || publishItem.LastPipelineInstanceID == 0 {
+		t.Errorf("the publish of release plan = %+v", publishItem)
+	}
+	publishApps := []*models.PublishApp{}
+	if _, err := orm.NewOrm().QueryTable("pub_publish_app").Filter("publish_id", publishItem.ID).All(&publishApps); err != nil || len(publishApps) != 1 || publishApps[0].BranchName != "feature" {
+		t.Errorf("the publish apps = %v, error: %v", publishApps, err)
+	}
+
+	if err := pm.DeleteReleasePlan(project.ID, plan.ID); err != nil {
+		t.Fatalf("DeleteReleasePlan() error: %v", err)
+	}
+	if _, err := pm.GetReleasePlan(project.ID, plan.ID); err == nil {
+		t.Errorf("the deleted plan should not exist")
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/notification"
+
+	"github.com/astaxie/beego"
+)
+
+const slaStepLabel = "sla"
+
+var (
+	// slaWarnHours notify the publish creator when the publish waits in the manual step over the hours, 0 means disabled
+	slaWarnHours = beego.AppConfig.DefaultInt("sla::warn", 24)
+	// slaEscalateHours escalate to the publish approvers when the publish waits in the manual step over the hours, 0 means disabled
+	slaEscalateHours = beego.AppConfig.DefaultInt("sla::escalate", 72)
+)
+
+// agingBuckets the upper bound hours of the publish aging buckets
+var agingBuckets = []struct {
+	label string
+	hours float64
+}{
+	{"<1h", 1},
+	{"1h-1d", 24},
+	{"1d-3d", 72},
+	{">3d", math.MaxFloat64},
+}
+
+// slaLevel return the sla level reached by the publish waited for the duration
+func slaLevel(waited time.Duration, warnHours, escalateHours int) int {
+	hours := waited.Hours()
+	if escalateHours > 0 && hours >= float64(escalateHours) {
+		return models.SLALevelEscalated
+	}
+	if warnHours > 0 && hours >= float64(warnHours) {
+		return models.SLALevelWarned
+	}
+	return models.SLALevelNone
+}
+
+// slaThresholds return the warn and escalate hours of the publish current stage, the stage policy overrides the system default
+func (pm *PublishManager) slaThresholds(publishItem *models.Publish) (int, int) {
+	warn, escalate := slaWarnHours, slaEscalateHours
+	policy, err := pm.pipelineHandler.GetStagePolicy(publishItem.LastPipelineInstanceID, publishItem.StageID)
+	if err != nil {
+		log.Log.Warn("get publish: %v stage: %v policy occur error: %s, use default sla", publishItem.ID, publishItem.StageID, err.Error())
+		return warn, escalate
+	}
+	if policy.SLAWarnHours > 0 {
+		warn = policy.SLAWarnHours
+	}
+	if policy.SLAEscalateHours > 0 {
+		escalate = policy.SLAEscalateHours
+	}
+	return warn, escalate
+}
+
+// GetPublishAging return the aging metrics of the project publishes waiting for manual operation
+func (pm *PublishManager) GetPublishAging(projectID int64) (*PublishAgingRsp, error) {
+	publishes, err := pm.model.GetWaitingPublishes(projectID)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &PublishAgingRsp{
+		Buckets: []*PublishAgingBucket{},
+		Items:   []*PublishAgingItem{},
+	}
+	for _, bucket := range agingBuckets {
+		rsp.Buckets = append(rsp.Buckets, &PublishAgingBucket{Label: bucket.label})
+	}
+	now := time.Now()
+	totalHours := 0.0
+	for _, publishItem := range publishes {
+		waited := now.Sub(*publishItem.WaitingSince)
+		warn, escalate := pm.slaThresholds(publishItem)
+		item := &PublishAgingItem{
+			PublishID:     publishItem.ID,
+			Name:          publishItem.Name,
+			VersionNo:     publishItem.VersionNo,
+			StageID:       publishItem.StageID,
+			StageName:     publishItem.StageName,
+			Step:          publishItem.Step,
+			Status:        publishItem.Status,
+			WaitingSince:  *publishItem.WaitingSince,
+			WaitingHours:  math.Round(waited.Hours()*10) / 10,
+			WarnHours:     warn,
+			EscalateHours: escalate,
+			SLALevel:      publishItem.SLALevel,
+			Overdue:       slaLevel(waited, warn, escalate) != models.SLALevelNone,
+		}
+		rsp.Items = append(rsp.Items, item)
+		if item.Overdue {
+			rsp.Overdue++
+		}
+		if item.SLALevel == models.SLALevelEscalated {
+			rsp.Escalated++
+		}
+		for i, bucket := range agingBuckets {
+			if waited.Hours() < bucket.hours {
+				rsp.Buckets[i].Count++
+				break
+			}
+		}
+		totalHours += waited.Hours()
+		rsp.MaxHours = math.Max(rsp.MaxHours, item.WaitingHours)
+	}
+	rsp.Total = len(rsp.Items)
+	if rsp.Total > 0 {
+		rsp.AverageHours = math.Round(totalHours/float64(rsp.Total)*10) / 10
+	}
+	return rsp, nil
+}
+
+// CheckPublishSLA notify the creator when the publish waits in the manual step over the warn hours,
+// and escalate to the approvers over the escalate hours, each level is notified once per step
+func (pm *PublishManager) CheckPublishSLA() {
+	publishes, err := pm.model.GetWaitingPublishes(0)
+	if err != nil {
+		log.Log.Error("get waiting publishes occur error: %s", err.Error())
+		return
+	}
+	now := time.Now()
+	for _, publishItem := range publishes {
+		waited := now.Sub(*publishItem.WaitingSince)
+		warn, escalate := pm.slaThresholds(publishItem)
+		level := slaLevel(waited, warn, escalate)
+		if level <= publishItem.SLALevel {
+			continue
+		}
+		threshold := warn
+		if level == models.SLALevelEscalated {
+			threshold = escalate
+		}
+		pm.notifySLA(publishItem, level, waited, threshold)
+		publishItem.SLALevel = level
+		if err := pm.model.UpdatePublishSLALevel(publishItem); err != nil {
+			log.Log.Error("update publish: %v sla level occur error: %s", publishItem.ID, err.Error())
+		}
+	}
+}
+
+func (pm *PublishManager) notifySLA(publishItem *models.Publish, level int, waited time.Duration, threshold int) {
+	receivers := []string{publishItem.Creator}
+	logType := "SLA提醒"
+	message := fmt.Sprintf("发布单在 %v 阶段的 %v 步骤已等待 %.1f 小时, 超过 SLA %v 小时",
+		publishItem.StageName, publishItem.Step, waited.Hours(), threshold)
+	if level == models.SLALevelEscalated {
+		escalations := []string{}
+		for approver := range splitApprovers(publishItem.Approvers) {
+			escalations = append(escalations, approver)
+		}
+		sort.Strings(escalations)
+		if len(escalations) == 0 {
+			if project, err := pm.projectModel.GetProjectByID(publishItem.ProjectID); err == nil && project.Owner != "" {
+				escalations = append(escalations, project.Owner)
+			}
+		}
+		receivers = append(receivers, escalations...)
+		logType = "SLA升级"
+		message = fmt.Sprintf("%s, 已升级至: %s", message, strings.Join(escalations, ","))
+	}
+
+	options := notification.NewPushNotification(publishItem.Status, publishItem.Name, publishItem.StageName, publishItem.Step)
+	options.Message = message
+	options.Receivers = userEmails(receivers)
+	go notification.Send(options)
+	pm.createPolicyOperationLog(publishItem, publishItem.StageID, slaStepLabel, logType, publishItem.Status, message)
+	log.Log.Info("publish: %v sla level: %v notified, %s", publishItem.ID, level, message)
+}
+
+// userEmails return the emails of the users, the user without email is ignored
+func userEmails(users []string) []string {
+	emails := []string{}
+	seen := map[string]bool{}
+	for _, name := range users {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		user, err := dao.GetUser(name)
+		if err != nil || user.Email == "" {
+			continue
+		}
+		emails = append(emails, user.Email)
+	}
+	return emails
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestSLALevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		waited   time.Duration
+		warn     int
+		escalate int
+		want     int
+	}{
+		{"within sla", 2 * time.Hour, 24, 72, models.SLALevelNone},
+		{"warned", 24 * time.Hour, 24, 72, models.SLALevelWarned},
+		{"escalated", 80 * time.Hour, 24, 72, models.SLALevelEscalated},
+		{"warn disabled", 30 * time.Hour, 0, 72, models.SLALevelNone},
+		{"escalate disabled", 100 * time.Hour, 24, 0, models.SLALevelWarned},
+		{"all disabled", 1000 * time.Hour, 0, 0, models.SLALevelNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slaLevel(tt.waited, tt.warn, tt.escalate); got != tt.want {
+				t.Errorf("slaLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
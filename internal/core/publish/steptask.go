@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/astaxie/beego"
+)
+
+// stepTaskStaleTimeout the running task not finished in time was interrupted by the server stopped
+const stepTaskStaleTimeout = 30 * time.Minute
+
+// StepTaskAsync the build, deploy and e2e test triggers run in background when it is enabled,
+// the client should poll the task until finished, so it is opt-in: the clients polling the task
+// enable it by the async query of each trigger, or all the triggers by task::async
+func StepTaskAsync() bool {
+	return beego.AppConfig.DefaultBool("task::async", false)
+}
+
+// stepTaskRunner run the step trigger of task, return the publish status, run id and job name
+var stepTaskRunner = (*PublishManager).runStepTask
+
+// stepTaskMaxAttempts the step task failed is retried until max attempts
+func stepTaskMaxAttempts() int {
+	return beego.AppConfig.DefaultInt("task::max_attempts", 3)
+}
+
+// StepTaskRetryInterval the interval between the attempts of step task, it grows with the attempts
+func StepTaskRetryInterval() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("task::retry_interval", 10)) * time.Second
+}
+
+// SubmitStepTask record the step trigger and run it in background, the ui polls the task by id
+func (pm *PublishManager) SubmitStepTask(projectID, publishID, stageID int64, step, creator string, params interface{}) (*models.PublishStepTask, error) {
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil || publishItem.ProjectID != projectID {
+		return nil, fmt.Errorf("流水线: %v 不存在", publishID)
+	}
+	if publishItem.StageID != stageID {
+		return nil, fmt.Errorf("流水线当前不在此阶段，请刷新后重试")
+	}
+	jobModel := dao.NewPublishJobModel()
+	tasks, err := jobModel.GetStepTasks(publishID, []string{models.StepTaskStatusPending, models.StepTaskStatusRunning})
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.EnvID == stageID && task.StepIndex == publishItem.StepIndex {
+			return nil, fmt.Errorf("此步骤已在执行中, 任务ID: %v", task.ID)
+		}
+	}
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	task := &models.PublishStepTask{
+		Addons:             models.NewAddons(),
+		ProjectID:          projectID,
+		PublishID:          publishID,
+		PipelineInstanceID: publishItem.LastPipelineInstanceID,
+		EnvID:              stageID,
+		StepIndex:          publishItem.StepIndex,
+		Step:               step,
+		Params:             string(paramsBytes),
+		Creator:            creator,
+		Status:             models.StepTaskStatusPending,
+	}
+	if task.ID, err = jobModel.CreateStepTask(task); err != nil {
+		return nil, err
+	}
+	log.Log.Info("publish: %v %v step task: %v was submitted by %v", publishID, step, task.ID, creator)
+	// run the task at once, the worker picks it up when this replica stopped before
+	go pm.RunStepTask(task)
+	return task, nil
+}
+
+// GetStepTask return the step task of project, the ui polls it until succeeded or failed
+func (pm *PublishManager) GetStepTask(projectID, taskID int64) (*models.PublishStepTask, error) {
+	task, err := dao.NewPublishJobModel().GetStepTaskByID(taskID)
+	if err != nil || task.ProjectID != projectID {
+		return nil, fmt.Errorf("任务: %v 不存在", taskID)
+	}
+	return task, nil
+}
+
+// RunStepTasks run the pending step tasks whose retry interval passed, and fail the tasks interrupted
+func (pm *PublishManager) RunStepTasks() {
+	jobModel := dao.NewPublishJobModel()
+	tasks, err := jobModel.GetStepTasks(0, []string{models.StepTaskStatusPending, models.StepTaskStatusRunning})
+	if err != nil {
+		log.Log.Error("get pending step tasks occur error: %s", err.Error())
+		return
+	}
+	interval := StepTaskRetryInterval()
+	for _, task := range tasks {
+		if task.Status == models.StepTaskStatusRunning {
+			if time.Since(task.UpdateAt) > stepTaskStaleTimeout {
+				pm.finishStepTask(task, models.StepTaskStatusFailed, "服务重启，任务中断，请重新触发")
+			}
+			continue
+		}
+		if task.Attempts > 0 && time.Since(task.UpdateAt) < time.Duration(task.Attempts)*interval {
+			continue
+		}
+		pm.RunStepTask(task)
+	}
+}
+
+// RunStepTask run the step trigger of task once it is claimed, the attempt failed before any publish job created
+// is transient, such as the ci server or scm unavailable, which is retried later
+func (pm *PublishManager) RunStepTask(task *models.PublishStepTask) {
+	jobModel := dao.NewPublishJobModel()
+	claimed, err := jobModel.ClaimStepTask(task)
+	if err != nil {
+		log.Log.Error("claim step task: %v occur error: %s", task.ID, err.Error())
+		return
+	}
+	if !claimed {
+		return
+	}
+	task.Attempts++
+	publishItem, err := pm.model.GetPublishByID(task.PublishID)
+	if err != nil {
+		pm.finishStepTask(task, models.StepTaskStatusFailed, "流水线不存在")
+		return
+	}
+	if publishItem.LastPipelineInstanceID != task.PipelineInstanceID || publishItem.StageID != task.EnvID || publishItem.StepIndex != task.StepIndex {
+		pm.finishStepTask(task, models.StepTaskStatusFailed, "流水线步骤已变更，任务取消")
+		return
+	}
+
+	lastJobID := int64(0)
+	if lastJob, err := jobModel.GetLastPublishJobByPublishID(task.PublishID); err == nil {
+		lastJobID = lastJob.ID
+	}
+	status, runID, jobName, err := stepTaskRunner(pm, task)
+	if err != nil {
+		log.Log.Warn("step task: %v of publish: %v attempt %v failed: %s", task.ID, task.PublishID, task.Attempts, err.Error())
+		jobCreated := false
+		if lastJob, jobErr := jobModel.GetLastPublishJobByPublishID(task.PublishID); jobErr == nil {
+			jobCreated = lastJob.ID != lastJobID
+		}
+		if status == models.Failed && !jobCreated && task.Attempts < stepTaskMaxAttempts() {
+			task.Status = models.StepTaskStatusPending
+			task.Message = truncateMessage(err.Error())
+			if updateErr := jobModel.UpdateStepTask(task); updateErr != nil {
+				log.Log.Error("update step task: %v occur error: %s", task.ID, updateErr.Error())
+			}
+			return
+		}
+	}
+	task.PublishStatus = status
+	task.RunID = runID
+	if updateErr := pm.UpdatePublish(task.PublishID, task.EnvID, status, runID, task.Creator, "", jobName); updateErr != nil {
+		log.Log.Error("after step task: %v, update publish: %v occur error: %s", task.ID, task.PublishID, updateErr.Error())
+		if err == nil {
+			err = updateErr
+		}
+	}
+	if err != nil {
+		pm.finishStepTask(task, models.StepTaskStatusFailed, err.Error())
+		return
+	}
+	pm.finishStepTask(task, models.StepTaskStatusSucceeded, "")
+}
+
+func (pm *PublishManager) runStepTask(task *models.PublishStepTask) (int64, int64, string, error) {
+	switch task.Step {
+	case models.JobTypeBuild:
+		params := &pipelinemgr.BuildStepReq{}
+		if err := json.Unmarshal([]byte(task.Params), params); err != nil {
+			return models.Skipped, 0, "", err
+		}
+		return pm.pipelineHandler.RunBuildStep(task.ProjectID, task.PublishID, task.EnvID, task.Creator, task.Step, params)
+	case models.JobTypeDeploy:
+		params := &pipelinemgr.DeployStepReq{}
+		if err := json.Unmarshal([]byte(task.Params), params); err != nil {
+			return models.Skipped, 0, "", err
+		}
+		return pm.pipelineHandler.RunDeployStep(task.ProjectID, task.PublishID, task.EnvID, task.Creator, task.Step, params)
+	case models.StepE2ETest:
+		params := &pipelinemgr.E2ETestStepReq{}
+		if err := json.Unmarshal([]byte(task.Params), params); err != nil {
+			return models.Skipped, 0, "", err
+		}
+		return pm.pipelineHandler.RunE2ETestStep(task.ProjectID, task.PublishID, task.EnvID, task.Creator, params)
+	default:
+		return models.Skipped, 0, "", fmt.Errorf("不支持此步骤: %v 的后台执行", task.Step)
+	}
+}
+
+func (pm *PublishManager) finishStepTask(task *models.PublishStepTask, status, message string) {
+	task.Status = status
+	task.Message = truncateMessage(message)
+	if err := dao.NewPublishJobModel().UpdateStepTask(task); err != nil {
+		log.Log.Error("update step task: %v occur error: %s", task.ID, err.Error())
+	}
+}
+
+// truncateMessage the message column size is 256
+func truncateMessage(message string) string {
+	return utils.Truncate(message, 256)
+}
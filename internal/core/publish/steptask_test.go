@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// newStepTask create the publish at the step and the pending task of it
+func newStepTask(t *testing.T, step string) *models.PublishStepTask {
+	stageID, err := orm.NewOrm().Insert(&models.ProjectEnv{Addons: models.NewAddons(), ProjectID: 1, Name: "dev"})
+	if err != nil {
+		t.Fatalf("create project env error: %v", err)
+	}
+	publishItem := &models.Publish{
+		Addons:                 models.NewAddons(),
+		Name:                   "step task",
+		ProjectID:              1,
+		StageID:                stageID,
+		StepIndex:              1,
+		Status:                 models.Pending,
+		LastPipelineInstanceID: 3,
+	}
+	publishID, err := orm.NewOrm().Insert(publishItem)
+	if err != nil {
+		t.Fatalf("create publish error: %v", err)
+	}
+	task := &models.PublishStepTask{
+		Addons:             models.NewAddons(),
+		ProjectID:          1,
+		PublishID:          publishID,
+		PipelineInstanceID: 3,
+		EnvID:              stageID,
+		StepIndex:          1,
+		Step:               step,
+		Status:             models.StepTaskStatusPending,
+	}
+	if task.ID, err = dao.NewPublishJobModel().CreateStepTask(task); err != nil {
+		t.Fatalf("create step task error: %v", err)
+	}
+	return task
+}
+
+// stubStepTaskRunner replace the step trigger, createJob creates the publish job before it returns
+func stubStepTaskRunner(t *testing.T, status int64, createJob bool, err error) *int {
+	calls := 0
+	origin := stepTaskRunner
+	stepTaskRunner = func(pm *PublishManager, task *models.PublishStepTask) (int64, int64, string, error) {
+		calls++
+		if createJob {
+			job := &models.PublishJob{Addons: models.NewAddons(), PublishID: task.PublishID, ProjectID: task.ProjectID, EnvID: task.EnvID}
+			if _, err := orm.NewOrm().Insert(job); err != nil {
+				t.Fatalf("create publish job error: %v", err)
+			}
+		}
+		return status, 10, "job", err
+	}
+	t.Cleanup(func() { stepTaskRunner = origin })
+	return &calls
+}
+
+func getStepTask(t *testing.T, id int64) *models.PublishStepTask {
+	task, err := dao.NewPublishJobModel().GetStepTaskByID(id)
+	if err != nil {
+		t.Fatalf("get step task error: %v", err)
+	}
+	return task
+}
+
+func TestRunStepTaskRetry(t *testing.T) {
+	initTestDB(t)
+	pm := NewPublishManager()
+
+	t.Run("retry the attempt failed before the job created", func(t *testing.T) {
+		calls := stubStepTaskRunner(t, models.Failed, false, errors.New("jenkins unavailable"))
+		task := newStepTask(t, models.JobTypeBuild)
+		pm.RunStepTask(task)
+		got := getStepTask(t, task.ID)
+		if *calls != 1 || got.Status != models.StepTaskStatusPending || got.Attempts != 1 || got.Message != "jenkins unavailable" {
+			t.Fatalf("the task should be pending to retry, calls: %v, task: %+v", *calls, got)
+		}
+
+		// the retries run until max attempts
+		pm.RunStepTask(got)
+		pm.RunStepTask(got)
+		got = getStepTask(t, task.ID)
+		if *calls != 3 || got.Status != models.StepTaskStatusFailed || got.Attempts != 3 {
+			t.Fatalf("the task should fail after max attempts, calls: %v, task: %+v", *calls, got)
+		}
+	})
+
+	t.Run("do not retry when the job was created", func(t *testing.T) {
+		calls := stubStepTaskRunner(t, models.Failed, true, errors.New("trigger job failed"))
+		task := newStepTask(t, models.JobTypeBuild)
+		pm.RunStepTask(task)
+		got := getStepTask(t, task.ID)
+		if *calls != 1 || got.Status != models.StepTaskStatusFailed || got.Attempts != 1 {
+			t.Fatalf("the task should fail without retry, calls: %v, task: %+v", *calls, got)
+		}
+	})
+
+	t.Run("do not retry the invalid request", func(t *testing.T) {
+		calls := stubStepTaskRunner(t, models.Skipped, false, errors.New("invalid params"))
+		task := newStepTask(t, models.JobTypeBuild)
+		pm.RunStepTask(task)
+		if got := getStepTask(t, task.ID); *calls != 1 || got.Status != models.StepTaskStatusFailed {
+			t.Fatalf("the task should fail without retry, calls: %v, task: %+v", *calls, got)
+		}
+	})
+
+	t.Run("succeeded", func(t *testing.T) {
+		calls := stubStepTaskRunner(t, models.Running, true, nil)
+		task := newStepTask(t, models.JobTypeBuild)
+		pm.RunStepTask(task)
+		got := getStepTask(t, task.ID)
+		if *calls != 1 || got.Status != models.StepTaskStatusSucceeded || got.RunID != 10 || got.PublishStatus != models.Running {
+			t.Fatalf("the task should succeed, calls: %v, task: %+v", *calls, got)
+		}
+		// the task finished is not claimed again
+		pm.RunStepTask(got)
+		if *calls != 1 {
+			t.Errorf("the finished task should not run again")
+		}
+	})
+
+	t.Run("cancel when the step changed", func(t *testing.T) {
+		calls := stubStepTaskRunner(t, models.Running, false, nil)
+		task := newStepTask(t, models.JobTypeBuild)
+		if _, err := orm.NewOrm().QueryTable("pub_publish").Filter("id", task.PublishID).Update(orm.Params{"step_index": 2}); err != nil {
+			t.Fatalf("update publish error: %v", err)
+		}
+		pm.RunStepTask(task)
+		got := getStepTask(t, task.ID)
+		if *calls != 0 || got.Status != models.StepTaskStatusFailed || got.Message != "流水线步骤已变更，任务取消" {
+			t.Fatalf("the task should be canceled, calls: %v, task: %+v", *calls, got)
+		}
+	})
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package publish
 
 import (
+	"time"
+
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils/query"
 )
@@ -39,12 +41,61 @@ type PublishReq struct {
 	Name           string            `json:"name"`
 	BindPipelineID int64             `json:"bind_pipeline_id"`
 	VersionNo      string            `json:"version_no"`
+	Level          int               `json:"level"`
+	// Approvers the users allowed to pass the manual steps, empty means everyone
+	Approvers []string `json:"approvers"`
+}
+
+// ReleasePlanReq ..
+type ReleasePlanReq struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	BindPipelineID int64             `json:"bind_pipeline_id"`
+	Apps           []*PubllishReqApp `json:"apps"`
+	Approvers      []string          `json:"approvers"`
+	Level          int               `json:"level"`
+}
+
+// ReleasePlanRsp ..
+type ReleasePlanRsp struct {
+	*models.ReleasePlan
+	PipelineName string            `json:"pipeline_name"`
+	Apps         []*PubllishReqApp `json:"apps"`
+	Approvers    []string          `json:"approvers"`
+}
+
+// ReleasePlanPublishReq instantiate the release plan into publish order
+type ReleasePlanPublishReq struct {
+	Name      string `json:"name"`
+	VersionNo string `json:"version_no"`
+	// Apps override the branch of the plan apps, optional
+	Apps []*PubllishReqApp `json:"apps"`
+}
+
+// BatchPublishReq ..
+type BatchPublishReq struct {
+	// Action build/deploy trigger the current step, terminate/close the publishes
+	Action     string  `json:"action"`
+	PublishIDs []int64 `json:"publish_ids"`
+	// StaleDays select the unfinished publishes which did not updated in days when publish_ids is empty, only for terminate/close
+	StaleDays int `json:"stale_days"`
+}
+
+// BatchPublishResult the result of each publish in batch operation
+type BatchPublishResult struct {
+	PublishID int64  `json:"publish_id"`
+	Name      string `json:"name"`
+	VersionNo string `json:"version_no"`
+	Success   bool   `json:"success"`
+	Status    int64  `json:"status"`
+	Message   string `json:"message"`
 }
 
 // PublishUpdate ..
 type PublishUpdate struct {
 	VersionNo string `json:"version_no"`
 	Name      string `json:"name"`
+	Level     int    `json:"level"`
 }
 
 // PublishReqFilterQuery ...
@@ -64,10 +115,11 @@ type PublishInfoApp struct {
 
 // PublishStep ..
 type PublishStep struct {
-	Type   string `json:"type"`
-	Name   string `json:"name"`
-	Status int64  `json:"status"`
-	Index  int    `json:"index"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Status    int64  `json:"status"`
+	Index     int    `json:"index"`
+	DependsOn []int  `json:"depends_on"`
 }
 
 // PublishInfoResp ...
@@ -83,6 +135,13 @@ type PublishInfoResp struct {
 type TriggerBackToReq struct {
 	StageID int64  `json:"stage_id"`
 	Message string `json:"message"`
+	// Automated the promotion triggered automatically, eg: auto promote policy, which skip the env permission check
+	Automated bool `json:"-"`
+}
+
+// StageApprovalReq ..
+type StageApprovalReq struct {
+	Message string `json:"message"`
 }
 
 // CreateOperationLogReq ..
@@ -100,6 +159,7 @@ type CreateOperationLogReq struct {
 	StageID            int64  `json:"stage_id"`
 	Status             int64  `json:"status"`
 	RunID              int64  `json:"run_id"`
+	Inputs             string `json:"inputs"`
 }
 
 // CirculationRsp back-to/next-stage
@@ -107,3 +167,61 @@ type CirculationRsp struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
 }
+
+// WebhookPushRsp the builds triggered by scm push webhook
+type WebhookPushRsp struct {
+	Branch      string                `json:"branch"`
+	ChangedApps []string              `json:"changed_apps"`
+	Publishes   []*BatchPublishResult `json:"publishes"`
+	// Previews the preview envs handled by the merge request event
+	Previews []string `json:"previews,omitempty"`
+}
+
+// PublishAgingItem the publish waiting for manual operation in the current step
+type PublishAgingItem struct {
+	PublishID     int64     `json:"publish_id"`
+	Name          string    `json:"name"`
+	VersionNo     string    `json:"version_no"`
+	StageID       int64     `json:"stage_id"`
+	StageName     string    `json:"stage_name"`
+	Step          string    `json:"step"`
+	Status        int64     `json:"status"`
+	WaitingSince  time.Time `json:"waiting_since"`
+	WaitingHours  float64   `json:"waiting_hours"`
+	WarnHours     int       `json:"warn_hours"`
+	EscalateHours int       `json:"escalate_hours"`
+	SLALevel      int       `json:"sla_level"`
+	Overdue       bool      `json:"overdue"`
+}
+
+// PublishAgingBucket the count of publishes whose waiting hours in the bucket
+type PublishAgingBucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// PublishAgingRsp the aging metrics of the project publishes waiting for manual operation
+type PublishAgingRsp struct {
+	Total        int                   `json:"total"`
+	Overdue      int                   `json:"overdue"`
+	Escalated    int                   `json:"escalated"`
+	AverageHours float64               `json:"average_hours"`
+	MaxHours     float64               `json:"max_hours"`
+	Buckets      []*PublishAgingBucket `json:"buckets"`
+	Items        []*PublishAgingItem   `json:"items"`
+}
+
+// JobCallbackReq the callback of publish job, which is sent by the job or reconciled
+type JobCallbackReq struct {
+	PublishID      int64
+	StageID        int64
+	PublishJobID   int64
+	IdempotencyKey string
+	Step           string
+	Source         string
+	Creator        string
+	// JobStatus the status of the job finished, empty means success
+	JobStatus string
+	// Message the message of publish operation log
+	Message string
+}
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/go-atomci/atomci/internal/core/events"
 	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 
@@ -45,6 +47,15 @@ func getOperationCanEnableByStepStatus(stepType string, status int64, operations
 			operations.Deploy = false
 			operations.Terminate = true
 		}
+	case models.StepPromote:
+		operations.Promote = true
+	case models.StepE2ETest:
+		operations.E2ETest = true
+		if status == models.Running {
+			operations.BackTo = false
+			operations.E2ETest = false
+			operations.Terminate = true
+		}
 	case "None":
 		operations.NextStage = true
 	default:
@@ -85,26 +96,27 @@ func (pm *PublishManager) getPublishStepsByPipelineInstanceID(publish *models.Pu
 		return nil, err
 	}
 	currentIndex := publish.StepIndex
+	states, err := pm.pipelineHandler.GetStageStepStates(publish.LastPipelineInstanceID, publish.StageID)
+	if err != nil {
+		return nil, err
+	}
+	deps := currentEnvStage.Steps.Dependencies()
 	steps := []*PublishStep{}
 	for _, step := range currentEnvStage.Steps {
 		item := &PublishStep{
-			Index: step.Index,
-			Type:  step.Type,
-			Name:  step.Name,
+			Index:     step.Index,
+			Type:      step.Type,
+			Name:      step.Name,
+			DependsOn: deps[step.Index],
+			Status:    models.Pending,
 		}
-		steps = append(steps, item)
-	}
-
-	for _, s := range steps {
-		if s.Index < currentIndex {
-			s.Status = models.Success
-		} else {
-			if s.Index > currentIndex {
-				s.Status = models.Pending
-			} else {
-				s.Status = publish.Status
-			}
+		// the steps maybe parallel, status based on the step operation logs
+		if status, ok := states[step.Index]; ok {
+			item.Status = status
+		} else if step.Index == currentIndex {
+			item.Status = publish.Status
 		}
+		steps = append(steps, item)
 	}
 	return steps, nil
 }
@@ -308,8 +320,13 @@ func (pm *PublishManager) createPublishOperationLogItem(co *CreateOperationLogRe
 		StepIndex:          co.StepIndex,
 		RunID:              co.RunID,
 		JobName:            co.JobName,
+		Inputs:             co.Inputs,
 	}
-	return pm.model.CreatePublishOperation(operationLog)
+	if err := pm.model.CreatePublishOperation(operationLog); err != nil {
+		return err
+	}
+	go events.EmitPublishTransition(operationLog)
+	return nil
 }
 
 func (pm *PublishManager) publishCreateParamVerify(req *PublishReq) error {
@@ -321,6 +338,12 @@ func (pm *PublishManager) publishCreateParamVerify(req *PublishReq) error {
 	if len(req.VersionNo) > 64 {
 		return fmt.Errorf("流水线名称不允许超过64个字符")
 	}
+	if err := verifyPublishLevel(req.Level); err != nil {
+		return err
+	}
+	if err := verifyApprovers(req.Approvers); err != nil {
+		return err
+	}
 	// App
 	if len(req.Apps) == 0 {
 		return fmt.Errorf("请至少勾选一个代码库后，重试")
@@ -340,3 +363,45 @@ func (pm *PublishManager) publishCreateParamVerify(req *PublishReq) error {
 	}
 	return nil
 }
+
+func verifyApprovers(approvers []string) error {
+	for _, user := range approvers {
+		if !dao.UserExist(user) {
+			return fmt.Errorf("审批人 %v 不存在，请确认后重试", user)
+		}
+	}
+	return nil
+}
+
+func verifyPublishLevel(level int) error {
+	if level < models.PublishLevelNormal || level > models.PublishLevelUrgent {
+		return fmt.Errorf("不支持的流水线紧急程度: %v", level)
+	}
+	return nil
+}
+
+// verifyMergeGate check the publish app branches were merged into the merge target of current env
+func (pm *PublishManager) verifyMergeGate(publishID, envID int64) error {
+	env, err := pm.projectModel.GetProjectEnvByID(envID)
+	if err != nil {
+		return err
+	}
+	if env.MergeTarget == "" {
+		return nil
+	}
+	publishApps, err := pm.model.GetPublishAppsByID(publishID)
+	if err != nil {
+		return err
+	}
+	for _, app := range publishApps {
+		merged, err := pm.pipelineHandler.IsAppBranchMerged(app.ProjectAppID, app.BranchName, env.MergeTarget)
+		if err != nil {
+			log.Log.Error("check publish: %v app: %v branch merged occur error: %s", publishID, app.ProjectAppID, err.Error())
+			return fmt.Errorf("检查应用分支 %v 是否合并到 %v 失败: %s", app.BranchName, env.MergeTarget, err.Error())
+		}
+		if !merged {
+			return fmt.Errorf("应用分支 %v 尚未合并到 %v，请合并后再流转到下一阶段", app.BranchName, env.MergeTarget)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/notification"
+)
+
+// watchdogOperator the operator of the publish transitions made by the watchdog
+const watchdogOperator = "watchdog"
+
+// WatchStuckJobs fail the jobs running longer than the timeout of their steps
+func (pm *PublishManager) WatchStuckJobs() {
+	jobs, err := dao.NewPublishJobModel().GetPublishJobsByFilter(
+		[]string{models.StatusRunning, models.StatusUnknown},
+		[]string{models.JobTypeBuild, models.JobTypeDeploy, models.JobTypeE2ETest},
+	)
+	if err != nil {
+		log.Log.Error("when watch stuck jobs, get running publish jobs occur error: %s", err.Error())
+		return
+	}
+	for _, job := range jobs {
+		timeout := pm.pipelineHandler.StepTimeout(job)
+		if timeout <= 0 || time.Since(job.CreateAt) < timeout {
+			continue
+		}
+		if err := pm.timeoutJob(job, timeout); err != nil {
+			log.Log.Error("timeout publish job: %v occur error: %s", job.ID, err.Error())
+		}
+	}
+}
+
+// timeoutJob abort the job and fail the publish step of job, the user triggered the job is notified
+func (pm *PublishManager) timeoutJob(job *models.PublishJob, timeout time.Duration) error {
+	reason := fmt.Sprintf("%v 任务执行超过 %v 未结束，已超时终止", job.JobType, timeout)
+	log.Log.Warn("publish job: %v timeout, started at: %v, timeout: %v", job.ID, job.CreateAt, timeout)
+	if err := pm.pipelineHandler.TimeoutJob(job, reason); err != nil {
+		return err
+	}
+	publishItem, err := pm.model.GetPublishByID(job.PublishID)
+	if err != nil {
+		return err
+	}
+	// the closed publish is not reopened by the job timeout
+	if publishItem.Status != models.Closed && publishItem.Status != models.END {
+		if err := pm.UpdatePublishStep(job.PublishID, job.EnvID, job.StepIndex, models.Failed, job.RunID, watchdogOperator, reason, ""); err != nil {
+			return err
+		}
+		if job.JobType == models.JobTypeDeploy {
+			go pm.HandleDeployFailure(job)
+		}
+	}
+
+	options := notification.NewPushNotification(models.Failed, publishItem.Name, publishItem.StageName, publishItem.Step)
+	options.Message = reason
+	options.Receivers = userEmails([]string{job.Operator})
+	go notification.Send(options)
+	return nil
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+	"github.com/drone/go-scm/scm"
+)
+
+// webhookOperator the operator of the jobs triggered by webhook
+const webhookOperator = "webhook"
+
+// webhookMaxChanges max changed files fetched for one push
+const webhookMaxChanges = 1000
+
+var webhookSecret = beego.AppConfig.String("webhook::secret")
+
+// HandleScmPush trigger builds for the apps whose watched paths were changed by the push,
+// only the publishes which build the pushed branch and are waiting at build step are triggered.
+// the merge request events deploy or tear down the preview envs of the projects.
+func (pm *PublishManager) HandleScmPush(repoID int64, req *http.Request) (*WebhookPushRsp, error) {
+	scmSetting, err := pm.settingsHandler.GetSCMIntegrateSettinByID(repoID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := apps.NewScmProvider(scmSetting.Type, scmSetting.URL, scmSetting.Token)
+	if err != nil {
+		return nil, err
+	}
+	hook, err := client.Webhooks.Parse(req, func(scm.Webhook) (string, error) {
+		return webhookSecret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("解析 webhook 请求失败: %s", err.Error())
+	}
+
+	rsp := &WebhookPushRsp{
+		ChangedApps: []string{},
+		Publishes:   []*BatchPublishResult{},
+	}
+	if pullRequest, ok := hook.(*scm.PullRequestHook); ok {
+		rsp.Branch = pullRequest.PullRequest.Source
+		rsp.Previews = pm.handlePullRequest(repoID, pullRequest)
+		return rsp, nil
+	}
+	push, ok := hook.(*scm.PushHook)
+	if !ok || !scm.IsBranch(push.Ref) {
+		log.Log.Info("repo: %v webhook event is not a branch push, ignore it", repoID)
+		return rsp, nil
+	}
+	rsp.Branch = scm.TrimRef(push.Ref)
+	fullName := scm.Join(push.Repo.Namespace, push.Repo.Name)
+
+	repoApps, err := pm.gitAppModel.GetScmAppsByRepo(repoID, fullName)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := pushChangedFiles(client, fullName, push)
+	if err != nil {
+		return nil, err
+	}
+	changedApps := []*models.ScmApp{}
+	for _, app := range repoApps {
+		// the changes of new branch are unknown, build all the apps
+		if changes == nil || apps.PathsChanged(apps.AppWatchPaths(app), changes) {
+			changedApps = append(changedApps, app)
+			rsp.ChangedApps = append(rsp.ChangedApps, app.Name)
+		}
+	}
+
+	publishIDs, publishBuildApps := pm.getPushBuildApps(changedApps, rsp.Branch)
+	for _, publishID := range publishIDs {
+		rsp.Publishes = append(rsp.Publishes, pm.webhookBuildPublish(publishID, publishBuildApps[publishID]))
+	}
+	return rsp, nil
+}
+
+// pushChangedFiles return nil when the push created a new branch or the changes are unknown
+func pushChangedFiles(client *scm.Client, fullName string, push *scm.PushHook) ([]string, error) {
+	if push.Before == "" || strings.Trim(push.Before, "0") == "" {
+		return nil, nil
+	}
+	changes, _, err := client.Git.CompareChanges(context.Background(), fullName, push.Before, push.After, scm.ListOptions{
+		Page: 1,
+		Size: webhookMaxChanges,
+	})
+	if err == scm.ErrNotSupported {
+		// gitea/gogs can not compare changes, regard all the apps as changed
+		log.Log.Info("repo: %v does not support compare changes, build all the apps", fullName)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compare repo: %v changes %v...%v occur error: %s", fullName, push.Before, push.After, err.Error())
+	}
+	paths := []string{}
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+		if change.Renamed && change.PrevFilePath != "" {
+			paths = append(paths, change.PrevFilePath)
+		}
+	}
+	return paths, nil
+}
+
+// getPushBuildApps group the apps to build by publish, which publish app build the branch
+func (pm *PublishManager) getPushBuildApps(changedApps []*models.ScmApp, branch string) ([]int64, map[int64][]*pipelinemgr.RunBuildAppReq) {
+	publishIDs := []int64{}
+	buildApps := map[int64][]*pipelinemgr.RunBuildAppReq{}
+	for _, scmApp := range changedApps {
+		projectApps, err := pm.projectModel.GetProjectAppsByScmID(scmApp.ID)
+		if err != nil {
+			log.Log.Warn("get project apps by scm app: %v occur error: %s", scmApp.ID, err.Error())
+			continue
+		}
+		for _, projectApp := range projectApps {
+			publishApps, err := pm.model.GetPublishAppsByProjectAppID(projectApp.ID)
+			if err != nil {
+				log.Log.Warn("get publish apps by project app: %v occur error: %s", projectApp.ID, err.Error())
+				continue
+			}
+			for _, publishApp := range publishApps {
+				if pipelinemgr.PublishAppBuildBranch(publishApp) != branch {
+					continue
+				}
+				if _, ok := buildApps[publishApp.PublishID]; !ok {
+					publishIDs = append(publishIDs, publishApp.PublishID)
+				}
+				buildApps[publishApp.PublishID] = append(buildApps[publishApp.PublishID], &pipelinemgr.RunBuildAppReq{
+					ProjectAppID:   publishApp.ProjectAppID,
+					Branch:         branch,
+					CompileCommand: publishApp.CompileCommand,
+				})
+			}
+		}
+	}
+	return publishIDs, buildApps
+}
+
+func (pm *PublishManager) webhookBuildPublish(publishID int64, buildApps []*pipelinemgr.RunBuildAppReq) *BatchPublishResult {
+	result := &BatchPublishResult{PublishID: publishID}
+	publishItem, err := pm.model.GetPublishByID(publishID)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.Name = publishItem.Name
+	result.VersionNo = publishItem.VersionNo
+	result.Status = publishItem.Status
+	if publishItem.StepType != models.StepBuild || publishItem.Status == models.Running ||
+		publishItem.Status == models.END || publishItem.Status == models.Closed {
+		result.Message = fmt.Sprintf("流水线当前步骤为 %v，跳过构建", publishItem.Step)
+		return result
+	}
+
+	params := &pipelinemgr.BuildStepReq{
+		ActionName: "trigger",
+		Apps:       buildApps,
+		Automated:  true,
+	}
+	status, runID, jobName, err := pm.pipelineHandler.RunBuildStep(publishItem.ProjectID, publishItem.ID, publishItem.StageID, webhookOperator, models.StepBuild, params)
+	if updateErr := pm.UpdatePublish(publishItem.ID, publishItem.StageID, status, runID, webhookOperator, "代码推送触发构建", jobName); updateErr != nil && err == nil {
+		err = updateErr
+	}
+	if err != nil {
+		log.Log.Warn("webhook build publish: %v occur error: %s", publishID, err.Error())
+		result.Message = err.Error()
+		return result
+	}
+	if status != models.Skipped {
+		result.Status = status
+	}
+	result.Success = true
+	return result
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publish
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+
+	"github.com/drone/go-scm/scm"
+)
+
+func TestPushChangedFilesNotSupported(t *testing.T) {
+	// gitea/gogs can not compare changes, all the apps are regarded as changed
+	for _, scmType := range []string{"gitea", "gogs"} {
+		client, err := apps.NewScmProvider(scmType, "http://127.0.0.1/atomci/app.git", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths, err := pushChangedFiles(client, "atomci/app", &scm.PushHook{Before: "c1", After: "c2"})
+		if paths != nil || err != nil {
+			t.Errorf("pushChangedFiles() of %v = %v, %v", scmType, paths, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// the actions restricted by the role env permissions
+const (
+	ActionAll     = "*"
+	ActionBuild   = "build"
+	ActionDeploy  = "deploy"
+	ActionPromote = "promote"
+	ActionApprove = "approve"
+	ActionArrange = "arrange"
+	ActionTest    = "test"
+)
+
+// AllEnvs the env permission matches all envs
+const AllEnvs = "*"
+
+var actionDescriptions = map[string]string{
+	ActionAll:     "所有",
+	ActionBuild:   "构建",
+	ActionDeploy:  "部署",
+	ActionPromote: "晋级",
+	ActionApprove: "审批",
+	ActionArrange: "编辑应用编排",
+	ActionTest:    "端到端测试",
+}
+
+// ValidatePermissions verify the actions and envs of env permissions
+func ValidatePermissions(items []*models.RoleEnvPermissionItem) error {
+	for _, item := range items {
+		if item == nil {
+			return fmt.Errorf("环境权限不能为空")
+		}
+		item.Action = strings.TrimSpace(item.Action)
+		item.Env = strings.TrimSpace(item.Env)
+		if _, ok := actionDescriptions[item.Action]; !ok {
+			return fmt.Errorf("不支持的操作: %v，可选值为 %v", item.Action, strings.Join(Actions(), "/"))
+		}
+		if item.Env == "" {
+			return fmt.Errorf("操作 %v 的环境标识不能为空，* 表示所有环境", item.Action)
+		}
+	}
+	return nil
+}
+
+// Actions return the supported actions
+func Actions() []string {
+	actions := []string{}
+	for action := range actionDescriptions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// allowed the user is allowed when any role grants the action in the env or is not restricted by env permissions
+func allowed(roles []string, permissions []*models.GroupRoleEnvPermission, action, env string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	owned := map[string]bool{}
+	for _, role := range roles {
+		owned[role] = true
+	}
+	restricted := map[string]bool{}
+	for _, permission := range permissions {
+		if !owned[permission.Role] {
+			continue
+		}
+		restricted[permission.Role] = true
+		if (permission.Action == ActionAll || permission.Action == action) && (permission.Env == AllEnvs || permission.Env == env) {
+			return true
+		}
+	}
+	for _, role := range roles {
+		if !restricted[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// userRoles return the system roles and the project member role of the user
+func userRoles(user string, projectID int64) ([]string, error) {
+	groupRoles, err := dao.GetGroupUserRoles(constant.SystemGroup, user)
+	if err != nil {
+		return nil, err
+	}
+	roles := []string{}
+	for _, role := range groupRoles {
+		roles = append(roles, role.Role)
+	}
+	members, err := dao.NewProjectModel().GetProjectUsers(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		if member.User != user {
+			continue
+		}
+		role, err := dao.NewUserRolesModel().GetRoleByID(member.RoleID)
+		if err != nil {
+			log.Log.Warn("get project: %v member: %v role: %v occur error: %s", projectID, user, member.RoleID, err.Error())
+			continue
+		}
+		roles = append(roles, role.Role)
+	}
+	return roles, nil
+}
+
+// VerifyEnvAction verify the user is allowed to do the action in the project env
+func VerifyEnvAction(user string, projectID, envID int64, action string) error {
+	if dao.UserIsAdmin(user) {
+		return nil
+	}
+	env, err := dao.NewProjectModel().GetProjectEnvByID(envID)
+	if err != nil {
+		return fmt.Errorf("获取项目环境: %v 失败: %s", envID, err.Error())
+	}
+	roles, err := userRoles(user, projectID)
+	if err != nil {
+		return err
+	}
+	permissions, err := dao.GetRolesEnvPermissions(constant.SystemGroup, roles)
+	if err != nil {
+		return err
+	}
+	if !allowed(roles, permissions, action, env.ArrangeEnv) {
+		return fmt.Errorf("用户 %v 无权在环境 %v 执行%v操作，操作拒绝", user, env.Name, actionDescriptions[action])
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestAllowed(t *testing.T) {
+	permissions := []*models.GroupRoleEnvPermission{
+		{Role: "developer", Action: ActionDeploy, Env: "dev"},
+		{Role: "developer", Action: ActionBuild, Env: AllEnvs},
+		{Role: "ops", Action: ActionAll, Env: "prod"},
+	}
+	tests := []struct {
+		name   string
+		roles  []string
+		action string
+		env    string
+		want   bool
+	}{
+		{"no roles", []string{}, ActionDeploy, "prod", true},
+		{"unrestricted role", []string{"tester"}, ActionDeploy, "prod", true},
+		{"granted env", []string{"developer"}, ActionDeploy, "dev", true},
+		{"denied env", []string{"developer"}, ActionDeploy, "prod", false},
+		{"all envs", []string{"developer"}, ActionBuild, "prod", true},
+		{"denied action", []string{"developer"}, ActionApprove, "dev", false},
+		{"all actions", []string{"ops"}, ActionApprove, "prod", true},
+		{"any role grants", []string{"developer", "ops"}, ActionDeploy, "prod", true},
+		{"restricted role with unrestricted role", []string{"developer", "tester"}, ActionDeploy, "prod", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowed(tt.roles, permissions, tt.action, tt.env); got != tt.want {
+				t.Errorf("allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []*models.RoleEnvPermissionItem
+		wantErr bool
+	}{
+		{"valid", []*models.RoleEnvPermissionItem{{Action: " deploy ", Env: "prod"}, {Action: "*", Env: "*"}}, false},
+		{"empty", []*models.RoleEnvPermissionItem{}, false},
+		{"unknown action", []*models.RoleEnvPermissionItem{{Action: "delete", Env: "prod"}}, true},
+		{"empty env", []*models.RoleEnvPermissionItem{{Action: "deploy", Env: " "}}, true},
+		{"nil item", []*models.RoleEnvPermissionItem{nil}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePermissions(tt.items); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePermissions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runnermgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/runner"
+	"github.com/go-atomci/atomci/pkg/workflowplugin"
+	"github.com/go-atomci/workflow"
+)
+
+// DriverName the name of the builtin runner driver, the ci server whose driver is runner runs the jobs by the runners
+const DriverName = "runner"
+
+// Driver the workflow driver queuing the jobs for the runners, the run id of job is the id of runner task
+type Driver struct{}
+
+// Capabilities ..
+func (d *Driver) Capabilities() (*workflowplugin.Capabilities, error) {
+	return &workflowplugin.Capabilities{Name: DriverName, Version: "builtin", SupportsAbort: true, SupportsLogs: true}, nil
+}
+
+// Ping the runner driver is available when any runner is online
+func (d *Driver) Ping(conn *workflowplugin.Connection) (string, error) {
+	items, err := GetRunners()
+	if err != nil {
+		return "", err
+	}
+	online := 0
+	for _, item := range items {
+		if item.Online {
+			online++
+		}
+	}
+	if online == 0 {
+		return "", fmt.Errorf("没有在线的 runner")
+	}
+	return fmt.Sprintf("%v online", online), nil
+}
+
+// Build queue the task of job
+func (d *Driver) Build(req *workflowplugin.BuildRequest) (int64, error) {
+	spec, err := buildSpec(req.Pipeline)
+	if err != nil {
+		return 0, err
+	}
+	content, err := json.Marshal(spec)
+	if err != nil {
+		return 0, err
+	}
+	task := &models.RunnerTask{
+		Addons:  models.NewAddons(),
+		JobName: req.JobName,
+		Spec:    string(content),
+		Status:  models.RunnerTaskPending,
+	}
+	return dao.CreateRunnerTask(task)
+}
+
+// Abort ..
+func (d *Driver) Abort(req *workflowplugin.JobRequest) error {
+	task, err := getTask(req)
+	if err != nil {
+		return err
+	}
+	if task.Finished() {
+		return nil
+	}
+	return dao.AbortRunnerTask(task, time.Now())
+}
+
+// GetJobInfo the steps of task are reported as the stages of job
+func (d *Driver) GetJobInfo(req *workflowplugin.JobRequest) (*workflow.JobInfo, error) {
+	task, err := getTask(req)
+	if err != nil {
+		return nil, err
+	}
+	spec := &runner.Spec{}
+	if err := json.Unmarshal([]byte(task.Spec), spec); err != nil {
+		return nil, err
+	}
+	info := &workflow.JobInfo{
+		ID:       strconv.FormatInt(task.ID, 10),
+		Number:   int(task.ID),
+		Building: !task.Finished(),
+		Status:   "IN_PROGRESS",
+	}
+	if task.Finished() {
+		info.Result, info.Status = task.Status, task.Status
+	}
+	if task.StartedAt != nil {
+		info.StartTimeMillis = task.StartedAt.UnixNano() / int64(time.Millisecond)
+		end := time.Now()
+		if task.FinishedAt != nil {
+			end = *task.FinishedAt
+		}
+		info.DurationMillis = int(end.Sub(*task.StartedAt) / time.Millisecond)
+	}
+	for i, step := range spec.Steps {
+		stage := workflow.Stage{ID: strconv.Itoa(i + 1), Name: step.Stage, Status: "NOT_EXECUTED"}
+		switch {
+		case i < task.FinishedSteps:
+			stage.Status = "SUCCESS"
+		case i == task.FinishedSteps && task.Status == models.RunnerTaskRunning:
+			stage.Status = "IN_PROGRESS"
+		case i == task.FinishedSteps && task.Finished():
+			stage.Status = task.Status
+		}
+		info.Stages = append(info.Stages, stage)
+	}
+	return info, nil
+}
+
+// GetJobLog ..
+func (d *Driver) GetJobLog(req *workflowplugin.LogRequest) (*workflowplugin.Log, error) {
+	task, err := getTask(&req.JobRequest)
+	if err != nil {
+		return nil, err
+	}
+	start := req.Start
+	if start < 0 || start > int64(len(task.Log)) {
+		start = int64(len(task.Log))
+	}
+	return &workflowplugin.Log{Text: task.Log[start:], NextStart: int64(len(task.Log)), More: !task.Finished()}, nil
+}
+
+func getTask(req *workflowplugin.JobRequest) (*models.RunnerTask, error) {
+	task, err := dao.GetRunnerTask(req.RunID)
+	if err != nil || task.JobName != req.JobName {
+		return nil, fmt.Errorf("runner 任务 %v 不存在", req.RunID)
+	}
+	return task, nil
+}
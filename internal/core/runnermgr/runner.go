@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runnermgr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/runner"
+
+	"github.com/astaxie/beego"
+)
+
+// RunnerRsp the runner with its online status
+type RunnerRsp struct {
+	*models.Runner
+	Online bool `json:"online"`
+}
+
+// registrationToken the token shared by the runners to register, empty means the registration is closed
+func registrationToken() string {
+	return beego.AppConfig.DefaultString("runner::registration_token", "")
+}
+
+// heartbeatTimeout the runner is offline when no heartbeat received in the timeout
+func heartbeatTimeout() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("runner::heartbeat_timeout", 60)) * time.Second
+}
+
+// hashToken only the hash of runner token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Register register the runner, the runner token is returned only once
+func Register(req *runner.RegisterReq) (*runner.RegisterRsp, error) {
+	expected := registrationToken()
+	if expected == "" {
+		return nil, fmt.Errorf("未开启 runner 注册，请联系管理员配置注册令牌")
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(expected)) != 1 {
+		return nil, fmt.Errorf("runner 注册令牌无效")
+	}
+	if req.Name == "" {
+		return nil, fmt.Errorf("runner 名称不能为空")
+	}
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %s", err.Error())
+	}
+	token := models.RunnerTokenPrefix + hex.EncodeToString(random)
+	now := time.Now()
+	item := &models.Runner{
+		Addons:        models.NewAddons(),
+		Name:          req.Name,
+		Version:       req.Version,
+		TokenHash:     hashToken(token),
+		LastHeartbeat: &now,
+	}
+	id, err := dao.CreateRunner(item)
+	if err != nil {
+		return nil, err
+	}
+	log.Log.Info("runner: %v registered, id: %v", req.Name, id)
+	return &runner.RegisterRsp{ID: id, Token: token}, nil
+}
+
+// Authenticate return the runner of token
+func Authenticate(token string) (*models.Runner, error) {
+	item, err := dao.GetRunnerByHash(hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("runner 令牌无效")
+	}
+	return item, nil
+}
+
+// Heartbeat ..
+func Heartbeat(item *models.Runner, req *runner.HeartbeatReq) error {
+	return dao.UpdateRunnerHeartbeat(item, req.Version, time.Now())
+}
+
+// Claim assign the earliest pending task to runner, nil means no task queued
+func Claim(item *models.Runner) (*runner.Task, error) {
+	task, err := dao.ClaimRunnerTask(item.ID, time.Now())
+	if err != nil || task == nil {
+		return nil, err
+	}
+	spec := &runner.Spec{}
+	if err := json.Unmarshal([]byte(task.Spec), spec); err != nil {
+		log.Log.Error("decode spec of runner task: %v occur error: %s", task.ID, err.Error())
+		if err := dao.FinishRunnerTask(task, models.RunnerTaskFailure, time.Now()); err != nil {
+			log.Log.Error("finish runner task: %v occur error: %s", task.ID, err.Error())
+		}
+		return nil, err
+	}
+	log.Log.Info("runner task: %v of job: %v is claimed by runner: %v", task.ID, task.JobName, item.Name)
+	return &runner.Task{ID: task.ID, JobName: task.JobName, Spec: spec}, nil
+}
+
+// runningTask return the task running by runner
+func runningTask(item *models.Runner, taskID int64) (*models.RunnerTask, error) {
+	task, err := dao.GetRunnerTask(taskID)
+	if err != nil || task.RunnerID != item.ID {
+		return nil, fmt.Errorf("任务 %v 不存在", taskID)
+	}
+	if task.Status != models.RunnerTaskRunning {
+		return nil, fmt.Errorf("任务 %v 已结束", taskID)
+	}
+	return task, nil
+}
+
+// AppendLog append the log of task, the runner should abort the task when abort is responded
+func AppendLog(item *models.Runner, taskID int64, req *runner.LogReq) (*runner.LogRsp, error) {
+	task, err := runningTask(item, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := dao.AppendRunnerTaskLog(task, req.Text, req.FinishedSteps); err != nil {
+		return nil, err
+	}
+	return &runner.LogRsp{Abort: task.Abort}, nil
+}
+
+// ReportStatus finish the task with the status reported by runner
+func ReportStatus(item *models.Runner, taskID int64, req *runner.StatusReq) error {
+	switch req.Status {
+	case models.RunnerTaskSuccess, models.RunnerTaskFailure, models.RunnerTaskAborted:
+	default:
+		return fmt.Errorf("任务状态 %v 无效", req.Status)
+	}
+	task, err := runningTask(item, taskID)
+	if err != nil {
+		return err
+	}
+	log.Log.Info("runner task: %v of job: %v is finished by runner: %v, status: %v", task.ID, task.JobName, item.Name, req.Status)
+	return dao.FinishRunnerTask(task, req.Status, time.Now())
+}
+
+// GetRunners ..
+func GetRunners() ([]*RunnerRsp, error) {
+	items, err := dao.GetRunners()
+	if err != nil {
+		return nil, err
+	}
+	now, timeout := time.Now(), heartbeatTimeout()
+	rsp := make([]*RunnerRsp, 0, len(items))
+	for _, item := range items {
+		rsp = append(rsp, &RunnerRsp{Runner: item, Online: item.Online(now, timeout)})
+	}
+	return rsp, nil
+}
+
+// DeleteRunner the token of runner is invalid after deleted
+func DeleteRunner(id int64) error {
+	item, err := dao.GetRunnerByID(id)
+	if err != nil {
+		return fmt.Errorf("runner %v 不存在", id)
+	}
+	return dao.DeleteRunner(item)
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runnermgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/pkg/runner"
+	"github.com/go-atomci/workflow/jenkins"
+)
+
+// defaultWorkspace the workspace of task when the ci server has no workspace
+const defaultWorkspace = "/workspace"
+
+// blockSteps the steps of jenkins declarative pipeline which only group the nested steps
+var blockSteps = map[string]bool{"steps": true, "parallel": true}
+
+// pipelineContext the fields of the ci context which the runner task is built from
+type pipelineContext struct {
+	Stages             string
+	EnvVars            []jenkins.EnvItem
+	ContainerTemplates []jenkins.ContainerEnv
+	CallBack           jenkins.CallbackRequest
+}
+
+// buildSpec build the runner task spec from the json encoded ci context, the stages rendered for jenkins
+// are translated into the shell steps, the parallel stages are run one by one
+func buildSpec(content []byte) (*runner.Spec, error) {
+	context := pipelineContext{}
+	if err := json.Unmarshal(content, &context); err != nil {
+		return nil, fmt.Errorf("runner 不支持当前任务: %s", err.Error())
+	}
+	steps, err := parseStages(context.Stages)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("runner 任务没有可执行的步骤")
+	}
+	spec := &runner.Spec{
+		Workspace:  defaultWorkspace,
+		Env:        map[string]string{},
+		Containers: map[string]runner.Container{},
+		Steps:      steps,
+	}
+	for _, item := range context.EnvVars {
+		spec.Env[item.Key] = fmt.Sprint(item.Value)
+	}
+	if workspace := spec.Env["JENKINS_SLAVE_WORKSPACE"]; workspace != "" {
+		spec.Workspace = workspace
+	}
+	for _, container := range context.ContainerTemplates {
+		shell := "sh"
+		// the debug images of kaniko and crane have the busybox shell only
+		if len(container.CommandArr) > 0 && strings.HasPrefix(container.CommandArr[0], "/busybox/") {
+			shell = "/busybox/sh"
+		}
+		spec.Containers[container.Name] = runner.Container{Image: container.Image, Shell: shell}
+	}
+	for _, step := range steps {
+		if _, ok := spec.Containers[step.Container]; step.Container != runner.DefaultContainer && !ok {
+			return nil, fmt.Errorf("runner 任务步骤 %v 的容器 %v 未定义", step.Stage, step.Container)
+		}
+	}
+	if context.CallBack.URL != "" {
+		spec.Callback = &runner.Callback{URL: context.CallBack.URL, Token: context.CallBack.Token, Body: context.CallBack.Body}
+	}
+	return spec, nil
+}
+
+// stageScope the stage and the container which the nested steps belong to
+type stageScope struct {
+	stage     string
+	container string
+}
+
+// parseStages translate the stages of jenkins declarative pipeline into the shell steps,
+// only stage, container and sh are supported, the other steps are refused
+func parseStages(stages string) ([]runner.Step, error) {
+	steps := []runner.Step{}
+	scopes := []stageScope{{}}
+	var pending *stageScope
+	for i := 0; i < len(stages); {
+		c := stages[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ';':
+			i++
+		case strings.HasPrefix(stages[i:], "//"):
+			if end := strings.IndexByte(stages[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(stages)
+			}
+		case c == '{':
+			scope := scopes[len(scopes)-1]
+			if pending != nil {
+				scope = *pending
+				pending = nil
+			}
+			scopes = append(scopes, scope)
+			i++
+		case c == '}':
+			if len(scopes) == 1 {
+				return nil, fmt.Errorf("流水线阶段的括号不匹配")
+			}
+			scopes = scopes[:len(scopes)-1]
+			i++
+		case isIdentStart(c):
+			start := i
+			for i < len(stages) && isIdentPart(stages[i]) {
+				i++
+			}
+			ident := stages[start:i]
+			scope := scopes[len(scopes)-1]
+			switch {
+			case ident == "stage":
+				name, next, err := parseArgument(stages, i)
+				if err != nil {
+					return nil, err
+				}
+				if scope.stage != "" {
+					name = scope.stage + "/" + name
+				}
+				pending, i = &stageScope{stage: name, container: scope.container}, next
+			case ident == "container":
+				name, next, err := parseArgument(stages, i)
+				if err != nil {
+					return nil, err
+				}
+				if name == constant.DefaultContainerName {
+					name = runner.DefaultContainer
+				}
+				pending, i = &stageScope{stage: scope.stage, container: name}, next
+			case ident == "sh":
+				script, next, err := parseShell(stages, i)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, runner.Step{Stage: scope.stage, Container: scope.container, Script: script})
+				i = next
+			case blockSteps[ident]:
+			default:
+				return nil, fmt.Errorf("runner 不支持流水线步骤: %v", ident)
+			}
+		default:
+			return nil, fmt.Errorf("runner 无法解析流水线: %v", excerpt(stages, i))
+		}
+	}
+	if len(scopes) != 1 {
+		return nil, fmt.Errorf("流水线阶段的括号不匹配")
+	}
+	return steps, nil
+}
+
+// parseArgument parse the only argument of stage or container, which is quoted or not
+func parseArgument(text string, i int) (string, int, error) {
+	i = skipSpaces(text, i)
+	if i >= len(text) || text[i] != '(' {
+		return "", 0, fmt.Errorf("runner 无法解析流水线: %v", excerpt(text, i))
+	}
+	i = skipSpaces(text, i+1)
+	var value string
+	if i < len(text) && (text[i] == '\'' || text[i] == '"') {
+		var err error
+		if value, i, err = parseString(text, i); err != nil {
+			return "", 0, err
+		}
+	} else {
+		end := strings.IndexByte(text[i:], ')')
+		if end < 0 {
+			return "", 0, fmt.Errorf("runner 无法解析流水线: %v", excerpt(text, i))
+		}
+		value, i = strings.TrimSpace(text[i:i+end]), i+end
+	}
+	i = skipSpaces(text, i)
+	if i >= len(text) || text[i] != ')' {
+		return "", 0, fmt.Errorf("runner 无法解析流水线: %v", excerpt(text, i))
+	}
+	return value, i + 1, nil
+}
+
+// parseShell parse the script of sh, sh 'script' or sh('script')
+func parseShell(text string, i int) (string, int, error) {
+	i = skipSpaces(text, i)
+	wrapped := i < len(text) && text[i] == '('
+	if wrapped {
+		i = skipSpaces(text, i+1)
+	}
+	script, i, err := parseString(text, i)
+	if err != nil {
+		return "", 0, err
+	}
+	if wrapped {
+		if i = skipSpaces(text, i); i >= len(text) || text[i] != ')' {
+			return "", 0, fmt.Errorf("runner 无法解析流水线: %v", excerpt(text, i))
+		}
+		i++
+	}
+	return script, i, nil
+}
+
+// parseString parse the groovy string literal, the escapes are unquoted, the interpolations of
+// the double quoted strings are kept since the env vars are interpolated by shell in the same way
+func parseString(text string, i int) (string, int, error) {
+	if i >= len(text) || (text[i] != '\'' && text[i] != '"') {
+		return "", 0, fmt.Errorf("runner 仅支持 sh 字符串脚本: %v", excerpt(text, i))
+	}
+	quote := text[i : i+1]
+	if strings.HasPrefix(text[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	builder := strings.Builder{}
+	for j := i + len(quote); j < len(text); j++ {
+		if strings.HasPrefix(text[j:], quote) {
+			return builder.String(), j + len(quote), nil
+		}
+		if text[j] == '\\' && j+1 < len(text) {
+			j++
+			switch text[j] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			case '\\', '\'', '"', '$':
+				builder.WriteByte(text[j])
+			default:
+				builder.WriteByte('\\')
+				builder.WriteByte(text[j])
+			}
+			continue
+		}
+		builder.WriteByte(text[j])
+	}
+	return "", 0, fmt.Errorf("runner 无法解析流水线，字符串未结束: %v", excerpt(text, i))
+}
+
+func skipSpaces(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\r' || text[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// excerpt the text near the position for the error message
+func excerpt(text string, i int) string {
+	if i > len(text) {
+		i = len(text)
+	}
+	end := i + 40
+	if end > len(text) {
+		end = len(text)
+	}
+	return strings.TrimSpace(text[i:end])
+}
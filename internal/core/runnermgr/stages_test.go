@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runnermgr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-atomci/workflow/jenkins"
+	"github.com/go-atomci/workflow/jenkins/templates"
+)
+
+func TestBuildSpec(t *testing.T) {
+	checkout, err := jenkins.GeneratePipelineXMLStr(templates.Checkout, map[string]interface{}{
+		"CheckoutItems": []jenkins.StepItem{{Name: "demo", Command: `sh 'set +x; git clone --depth 1 -b master "https://${SCM_CREDENTIAL_1}@git.example.com/demo.git" /workspace/demo'`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compile, err := jenkins.GeneratePipelineXMLStr(templates.Compile, map[string]interface{}{
+		"BuildItems": []*jenkins.StepItem{{Name: "demo", ContainerName: "demo-golang", Command: `sh "cd /workspace/demo && go build -ldflags \"-s -w\" ./..."`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := jenkins.GeneratePipelineXMLStr(templates.BuildImage, map[string]interface{}{
+		"ImageItems": []*jenkins.StepItem{{Name: "demo", Command: `sh "/kaniko/executor --context /workspace/demo --destination $REGISTRY_ADDR/demo:v1"`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := json.Marshal(jenkins.CIContext{
+		Stages: strings.Join([]string{checkout, compile, images}, " "),
+		EnvVars: []jenkins.EnvItem{
+			{Key: "JENKINS_SLAVE_WORKSPACE", Value: "/workspace"},
+			{Key: "REGISTRY_ADDR", Value: "harbor.example.com"},
+		},
+		ContainerTemplates: []jenkins.ContainerEnv{
+			{Name: "demo-golang", Image: "golang:1.15", CommandArr: []string{"cat"}},
+			{Name: "kaniko", Image: "gcr.io/kaniko-project/executor:debug", CommandArr: []string{"/busybox/cat"}},
+		},
+		CallBack: jenkins.CallbackRequest{URL: "http://atomci/callback", Token: "token", Body: `{"job":1}`},
+	})
+
+	spec, err := buildSpec(content)
+	if err != nil {
+		t.Fatalf("build spec: %v", err)
+	}
+	if spec.Workspace != "/workspace" || spec.Env["REGISTRY_ADDR"] != "harbor.example.com" || spec.Callback == nil {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	if shell := spec.Containers["kaniko"].Shell; shell != "/busybox/sh" {
+		t.Fatalf("unexpected kaniko shell: %v", shell)
+	}
+	expected := []struct{ stage, container, script string }{
+		{"Checkout/demo", "", `set +x; git clone --depth 1 -b master "https://${SCM_CREDENTIAL_1}@git.example.com/demo.git" /workspace/demo`},
+		{"Builds/demo", "demo-golang", `cd /workspace/demo && go build -ldflags "-s -w" ./...`},
+		{"Images/demo", "kaniko", "[ -d $DOCKER_CONFIG ] || mkdir -pv $DOCKER_CONFIG"},
+		{"Images/demo", "kaniko", `echo '{"auths": {"'$REGISTRY_ADDR'": {"auth": "'$DOCKER_AUTH'"}}}' > $DOCKER_CONFIG/config.json`},
+		{"Images/demo", "kaniko", "/kaniko/executor --context /workspace/demo --destination $REGISTRY_ADDR/demo:v1"},
+	}
+	if len(spec.Steps) != len(expected) {
+		t.Fatalf("expected %v steps, got: %+v", len(expected), spec.Steps)
+	}
+	for i, step := range spec.Steps {
+		if step.Stage != expected[i].stage || step.Container != expected[i].container || strings.TrimSpace(step.Script) != expected[i].script {
+			t.Errorf("step %v: unexpected %+v", i, step)
+		}
+	}
+}
+
+func TestParseStagesUnsupported(t *testing.T) {
+	for _, stages := range []string{
+		`stage('Upload') { steps { httpRequest url: 'http://atomci/report' } }`,
+		`stage('Build') { steps { sh 'make' }`,
+		`stage('Build') { steps { sh "make }`,
+	} {
+		if _, err := parseStages(stages); err == nil {
+			t.Errorf("expected error of stages: %v", stages)
+		}
+	}
+}
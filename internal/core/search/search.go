@@ -0,0 +1,404 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// search entity type
+const (
+	TypeProject = "project"
+	TypeApp     = "app"
+	TypePublish = "publish"
+	TypeImage   = "image"
+)
+
+// Types all entity types which can be searched
+var Types = []string{TypeProject, TypeApp, TypePublish, TypeImage}
+
+// limit the max hits of each entity type
+const limit = 50
+
+// keyword length range
+const (
+	minKeywordLength = 2
+	maxKeywordLength = 128
+)
+
+// ProjectHit ..
+type ProjectHit struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Owner       string `json:"owner"`
+	Description string `json:"description"`
+}
+
+// AppHit ..
+type AppHit struct {
+	ProjectAppID int64  `json:"project_app_id"`
+	ProjectID    int64  `json:"project_id"`
+	ProjectName  string `json:"project_name"`
+	Name         string `json:"name"`
+	FullName     string `json:"full_name"`
+	Path         string `json:"path"`
+}
+
+// PublishHit ..
+type PublishHit struct {
+	ID          int64     `json:"id"`
+	ProjectID   int64     `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Name        string    `json:"name"`
+	VersionNo   string    `json:"version_no"`
+	StageName   string    `json:"stage_name"`
+	Status      int64     `json:"status"`
+	Creator     string    `json:"creator"`
+	CreateAt    time.Time `json:"create_at"`
+}
+
+// ImageHit the app built or deployed by a job, which answers where the commit or image is deployed
+type ImageHit struct {
+	ProjectID    int64     `json:"project_id"`
+	ProjectName  string    `json:"project_name"`
+	ProjectAppID int64     `json:"project_app_id"`
+	AppName      string    `json:"app_name"`
+	PublishID    int64     `json:"publish_id"`
+	PublishName  string    `json:"publish_name"`
+	JobType      string    `json:"job_type"`
+	EnvID        int64     `json:"env_id"`
+	EnvName      string    `json:"env_name"`
+	Status       string    `json:"status"`
+	BranchName   string    `json:"branch_name"`
+	CommitSha    string    `json:"commit_sha"`
+	ImageAddr    string    `json:"image_addr"`
+	ImageVersion string    `json:"image_version"`
+	Matched      string    `json:"matched"`
+	CreateAt     time.Time `json:"create_at"`
+}
+
+// Result the hits grouped by entity type
+type Result struct {
+	Projects  []*ProjectHit `json:"projects"`
+	Apps      []*AppHit     `json:"apps"`
+	Publishes []*PublishHit `json:"publishes"`
+	Images    []*ImageHit   `json:"images"`
+}
+
+// Manager search the entities of the projects
+type Manager struct {
+	projectModel    *dao.ProjectModel
+	scmAppModel     *dao.ScmAppModel
+	publishModel    *dao.PublishModel
+	publishJobModel *dao.PublishJobModel
+	// projectNames the name of visible projects, keyed by project id
+	projectNames map[int64]string
+}
+
+// NewManager ..
+func NewManager() *Manager {
+	return &Manager{
+		projectModel:    dao.NewProjectModel(),
+		scmAppModel:     dao.NewScmAppModel(),
+		publishModel:    dao.NewPublishModel(),
+		publishJobModel: dao.NewPublishJobModel(),
+	}
+}
+
+// ParseTypes parse the comma separated entity types, empty means all types
+func ParseTypes(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return Types, nil
+	}
+	types := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if !contains(Types, item) {
+			return nil, fmt.Errorf("不支持的搜索类型: %v，可选值为 %v", item, strings.Join(Types, "/"))
+		}
+		if !contains(types, item) {
+			types = append(types, item)
+		}
+	}
+	return types, nil
+}
+
+// VerifyKeyword return the trimmed keyword if its length is valid
+func VerifyKeyword(keyword string) (string, error) {
+	keyword = strings.TrimSpace(keyword)
+	if len(keyword) < minKeywordLength || len(keyword) > maxKeywordLength {
+		return "", fmt.Errorf("搜索关键字长度应为 %v-%v 个字符", minKeywordLength, maxKeywordLength)
+	}
+	return keyword, nil
+}
+
+// Search find the projects, apps, publishes and images of visible projects by the verified keyword,
+// which matches the name, image tag, commit sha or branch
+func (m *Manager) Search(projectIDs []int64, keyword string, types []string) (*Result, error) {
+	result := &Result{
+		Projects:  []*ProjectHit{},
+		Apps:      []*AppHit{},
+		Publishes: []*PublishHit{},
+		Images:    []*ImageHit{},
+	}
+	if len(projectIDs) == 0 {
+		return result, nil
+	}
+	projects, err := m.projectModel.GetProjects()
+	if err != nil {
+		return nil, err
+	}
+	m.projectNames = map[int64]string{}
+	for _, project := range projects {
+		if containsInt64(projectIDs, project.ID) {
+			m.projectNames[project.ID] = project.Name
+		}
+	}
+
+	if contains(types, TypeProject) {
+		if result.Projects, err = m.searchProjects(projectIDs, keyword); err != nil {
+			return nil, err
+		}
+	}
+	if contains(types, TypeApp) {
+		if result.Apps, err = m.searchApps(projectIDs, keyword); err != nil {
+			return nil, err
+		}
+	}
+	if contains(types, TypePublish) {
+		if result.Publishes, err = m.searchPublishes(projectIDs, keyword); err != nil {
+			return nil, err
+		}
+	}
+	if contains(types, TypeImage) {
+		if result.Images, err = m.searchImages(projectIDs, keyword); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (m *Manager) searchProjects(projectIDs []int64, keyword string) ([]*ProjectHit, error) {
+	projects, err := m.projectModel.SearchProjects(projectIDs, keyword, limit)
+	if err != nil {
+		return nil, err
+	}
+	hits := []*ProjectHit{}
+	for _, project := range projects {
+		hits = append(hits, &ProjectHit{
+			ID:          project.ID,
+			Name:        project.Name,
+			Owner:       project.Owner,
+			Description: project.Description,
+		})
+	}
+	return hits, nil
+}
+
+func (m *Manager) searchApps(projectIDs []int64, keyword string) ([]*AppHit, error) {
+	// the scm apps are not owned by projects, so all matched ones are filtered by the visible projects
+	scmApps, err := m.scmAppModel.SearchScmApps(keyword, -1)
+	if err != nil {
+		return nil, err
+	}
+	scmAppMap := map[int64]*models.ScmApp{}
+	scmIDs := []int64{}
+	for _, item := range scmApps {
+		scmAppMap[item.ID] = item
+		scmIDs = append(scmIDs, item.ID)
+	}
+	apps, err := m.projectModel.GetProjectAppsOfScmApps(projectIDs, scmIDs)
+	if err != nil {
+		return nil, err
+	}
+	hits := []*AppHit{}
+	if len(apps) > limit {
+		apps = apps[:limit]
+	}
+	for _, app := range apps {
+		scmApp := scmAppMap[app.ScmID]
+		hits = append(hits, &AppHit{
+			ProjectAppID: app.ID,
+			ProjectID:    app.ProjectID,
+			ProjectName:  m.projectNames[app.ProjectID],
+			Name:         scmApp.Name,
+			FullName:     scmApp.FullName,
+			Path:         scmApp.Path,
+		})
+	}
+	return hits, nil
+}
+
+func (m *Manager) searchPublishes(projectIDs []int64, keyword string) ([]*PublishHit, error) {
+	publishes, err := m.publishModel.SearchPublishes(projectIDs, keyword, limit)
+	if err != nil {
+		return nil, err
+	}
+	hits := []*PublishHit{}
+	for _, publish := range publishes {
+		hits = append(hits, &PublishHit{
+			ID:          publish.ID,
+			ProjectID:   publish.ProjectID,
+			ProjectName: m.projectNames[publish.ProjectID],
+			Name:        publish.Name,
+			VersionNo:   publish.VersionNo,
+			StageName:   publish.StageName,
+			Status:      publish.Status,
+			Creator:     publish.Creator,
+			CreateAt:    publish.CreateAt,
+		})
+	}
+	return hits, nil
+}
+
+func (m *Manager) searchImages(projectIDs []int64, keyword string) ([]*ImageHit, error) {
+	jobApps, err := m.publishJobModel.SearchPublishJobApps(projectIDs, keyword, limit)
+	if err != nil {
+		return nil, err
+	}
+	hits := []*ImageHit{}
+	if len(jobApps) == 0 {
+		return hits, nil
+	}
+	jobIDs := []int64{}
+	appIDs := []int64{}
+	for _, item := range jobApps {
+		jobIDs = append(jobIDs, item.PublishJobID)
+		appIDs = append(appIDs, item.ProjectAPPID)
+	}
+	jobs, err := m.publishJobModel.GetPublishJobsByIDs(jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	jobMap := map[int64]*models.PublishJob{}
+	publishIDs := []int64{}
+	for _, job := range jobs {
+		jobMap[job.ID] = job
+		publishIDs = append(publishIDs, job.PublishID)
+	}
+	publishes, err := m.publishModel.GetPublishesByIDs(publishIDs)
+	if err != nil {
+		return nil, err
+	}
+	publishNames := map[int64]string{}
+	for _, publish := range publishes {
+		publishNames[publish.ID] = publish.Name
+	}
+	envs, err := m.projectModel.GetProjectEnvsByProjectIDs(projectIDs)
+	if err != nil {
+		return nil, err
+	}
+	envNames := map[int64]string{}
+	for _, env := range envs {
+		envNames[env.ID] = env.Name
+	}
+	appNames, err := m.appNames(appIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range jobApps {
+		job, ok := jobMap[item.PublishJobID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, &ImageHit{
+			ProjectID:    item.ProjectID,
+			ProjectName:  m.projectNames[item.ProjectID],
+			ProjectAppID: item.ProjectAPPID,
+			AppName:      appNames[item.ProjectAPPID],
+			PublishID:    job.PublishID,
+			PublishName:  publishNames[job.PublishID],
+			JobType:      job.JobType,
+			EnvID:        job.EnvID,
+			EnvName:      envNames[job.EnvID],
+			Status:       job.Status,
+			BranchName:   item.BranchName,
+			CommitSha:    item.CommitSha,
+			ImageAddr:    item.ImageAddr,
+			ImageVersion: item.ImageVersion,
+			Matched:      matchedField(item, keyword),
+			CreateAt:     item.CreateAt,
+		})
+	}
+	return hits, nil
+}
+
+// appNames return the scm app name of project apps, keyed by project app id
+func (m *Manager) appNames(projectAppIDs []int64) (map[int64]string, error) {
+	apps, err := m.projectModel.GetProjectAppsByAppIDs(projectAppIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmIDs := []int64{}
+	for _, app := range apps {
+		scmIDs = append(scmIDs, app.ScmID)
+	}
+	scmApps, err := m.scmAppModel.GetScmAppsByIDs(scmIDs)
+	if err != nil {
+		return nil, err
+	}
+	scmNames := map[int64]string{}
+	for _, item := range scmApps {
+		scmNames[item.ID] = item.Name
+	}
+	names := map[int64]string{}
+	for _, app := range apps {
+		names[app.ID] = scmNames[app.ScmID]
+	}
+	return names, nil
+}
+
+// matchedField the field of job app which the keyword matched, in the same order of the query
+func matchedField(item *models.PublishJobApp, keyword string) string {
+	keyword = strings.ToLower(keyword)
+	switch {
+	case strings.HasPrefix(strings.ToLower(item.CommitSha), keyword):
+		return "commit_sha"
+	case strings.Contains(strings.ToLower(item.ImageVersion), keyword):
+		return "image_version"
+	case strings.Contains(strings.ToLower(item.ImageAddr), keyword):
+		return "image_addr"
+	case strings.Contains(strings.ToLower(item.BranchName), keyword):
+		return "branch_name"
+	default:
+		return ""
+	}
+}
+
+func contains(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt64(items []int64, item int64) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
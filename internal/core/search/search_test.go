@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("")
+	if err != nil || len(types) != len(Types) {
+		t.Errorf("ParseTypes(\"\") = %v, %v, want all types", types, err)
+	}
+	types, err = ParseTypes("image, app,image")
+	if err != nil || len(types) != 2 || types[0] != TypeImage || types[1] != TypeApp {
+		t.Errorf("ParseTypes() = %v, %v, want [image app]", types, err)
+	}
+	if _, err := ParseTypes("image,commit"); err == nil {
+		t.Errorf("ParseTypes() expect error of unknown type")
+	}
+}
+
+func TestMatchedField(t *testing.T) {
+	item := &models.PublishJobApp{
+		BranchName:   "feature/abc",
+		CommitSha:    "abc123def",
+		ImageAddr:    "registry.example.com/demo/api:v1.2.0",
+		ImageVersion: "v1.2.0",
+	}
+	tests := []struct {
+		keyword string
+		want    string
+	}{
+		{"ABC123", "commit_sha"},
+		{"123def", ""},
+		{"1.2", "image_version"},
+		{"demo/api", "image_addr"},
+		{"feature", "branch_name"},
+	}
+	for _, tt := range tests {
+		if got := matchedField(item, tt.keyword); got != tt.want {
+			t.Errorf("matchedField(%v) = %v, want %v", tt.keyword, got, tt.want)
+		}
+	}
+}
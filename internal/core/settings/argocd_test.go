@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"testing"
+)
+
+func TestArgoCDConfigStruct(t *testing.T) {
+	config, err := (&Config{}).Struct(`{"url":"https://argocd.example.com","repo_id":3,"repo_branch":"main"}`, ArgoCDType)
+	if err != nil {
+		t.Fatalf("Struct() error: %v", err)
+	}
+	argoCDConf, ok := config.(*ArgoCDConfig)
+	if !ok {
+		t.Fatalf("Struct() = %T, want *ArgoCDConfig", config)
+	}
+	want := ArgoCDConfig{
+		URL:        "https://argocd.example.com",
+		Project:    "default",
+		DestServer: "https://kubernetes.default.svc",
+		RepoID:     3,
+		RepoBranch: "main",
+		RepoPath:   "atomci",
+	}
+	if *argoCDConf != want {
+		t.Errorf("Struct() = %+v, want %+v", *argoCDConf, want)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterCapability the kubernetes version and the available resources of cluster
+type ClusterCapability struct {
+	SettingID  int64      `json:"setting_id"`
+	Version    string     `json:"version"`
+	Resources  []string   `json:"resources"`
+	DetectedAt *time.Time `json:"detected_at"`
+
+	resources map[string]bool
+}
+
+// capabilityResource the key of resource, eg: apps/v1/Deployment, v1/Service
+func capabilityResource(apiVersion, kind string) string {
+	return apiVersion + "/" + kind
+}
+
+// Supports return true if the cluster serves the kind of apiVersion
+func (c *ClusterCapability) Supports(apiVersion, kind string) bool {
+	if c.resources == nil {
+		c.resources = map[string]bool{}
+		for _, item := range c.Resources {
+			c.resources[item] = true
+		}
+	}
+	return c.resources[capabilityResource(apiVersion, kind)]
+}
+
+func formatClusterCapability(item *models.ClusterCapability) *ClusterCapability {
+	rsp := &ClusterCapability{
+		SettingID:  item.SettingID,
+		Version:    item.Version,
+		Resources:  []string{},
+		DetectedAt: item.DetectedAt,
+	}
+	if err := json.Unmarshal([]byte(item.Resources), &rsp.Resources); err != nil {
+		log.Log.Warn("parse resources of cluster capability: %v error: %s", item.SettingID, err.Error())
+	}
+	return rsp
+}
+
+// GetClusterCapability return the capability of kubernetes setting, it is detected if never detected or refresh
+func (pm *SettingManager) GetClusterCapability(settingID int64, refresh bool) (*ClusterCapability, error) {
+	if !refresh {
+		item, err := pm.model.GetClusterCapability(settingID)
+		if err == nil {
+			return formatClusterCapability(item), nil
+		}
+		if err != orm.ErrNoRows {
+			return nil, err
+		}
+	}
+	return pm.DetectClusterCapability(settingID)
+}
+
+// DetectClusterCapability detect the version and the available resources of cluster and save them
+func (pm *SettingManager) DetectClusterCapability(settingID int64) (*ClusterCapability, error) {
+	setting, err := pm.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return nil, err
+	}
+	kube, ok := setting.Config.(*KubeConfig)
+	if setting.Type != KubernetesType || !ok {
+		return nil, fmt.Errorf("集成配置 %v 不是 kubernetes 集群", setting.Name)
+	}
+	restConfig, err := kube.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	version, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("获取集群 %v 版本失败: %v", setting.Name, err.Error())
+	}
+	_, resourceLists, err := client.Discovery().ServerGroupsAndResources()
+	if err != nil {
+		// the resources of the unavailable aggregated apis are skipped
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, fmt.Errorf("获取集群 %v 的 API 资源失败: %v", setting.Name, err.Error())
+		}
+		log.Log.Warn("discovery cluster: %v partial failed: %s", setting.Name, err.Error())
+	}
+	resources, _ := json.Marshal(apiResources(resourceLists))
+
+	item, err := pm.model.GetClusterCapability(settingID)
+	if err != nil {
+		if err != orm.ErrNoRows {
+			return nil, err
+		}
+		item = &models.ClusterCapability{SettingID: settingID}
+	}
+	now := time.Now()
+	item.Version = version.GitVersion
+	item.Resources = string(resources)
+	item.DetectedAt = &now
+	if err := pm.model.SaveClusterCapability(item); err != nil {
+		return nil, err
+	}
+	log.Log.Info("cluster: %v capability detected, version: %v", setting.Name, item.Version)
+	return formatClusterCapability(item), nil
+}
+
+// detectClusterCapability detect the capability of cluster bound in background, the failure is only logged
+// since the capability is detected again when used
+func (pm *SettingManager) detectClusterCapability(settingID int64) {
+	go func() {
+		if _, err := pm.DetectClusterCapability(settingID); err != nil {
+			log.Log.Warn("detect capability of cluster: %v occur error: %s", settingID, err.Error())
+		}
+	}()
+}
+
+// apiResources the sorted resources of discovery, subresources are skipped
+func apiResources(resourceLists []*metav1.APIResourceList) []string {
+	items := []string{}
+	seen := map[string]bool{}
+	for _, list := range resourceLists {
+		if list == nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			key := capabilityResource(list.GroupVersion, resource.Kind)
+			if !seen[key] {
+				seen[key] = true
+				items = append(items, key)
+			}
+		}
+	}
+	sort.Strings(items)
+	return items
+}
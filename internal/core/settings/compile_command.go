@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// CompileCommandReq ..
+type CompileCommandReq struct {
+	Name        string `json:"name,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Command     string `json:"command,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CompileCommandData the placeholders of compile command, eg: mvn -f {{.BuildPath}}/pom.xml package
+type CompileCommandData struct {
+	AppName   string
+	Language  string
+	BuildPath string
+	Branch    string
+}
+
+// the sample data used to validate the compile command when save
+var sampleCompileCommandData = CompileCommandData{AppName: "app", Language: "java", BuildPath: "/", Branch: "master"}
+
+var compileCommandNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// languageAliases the aliases of app language, the app language is lower case before lookup
+var languageAliases = map[string]string{
+	"golang":     "go",
+	"nodejs":     "node",
+	"javascript": "node",
+	"typescript": "node",
+	"python3":    "python",
+}
+
+// normalizeLanguage return the language used to lookup the compile command template
+func normalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := languageAliases[language]; ok {
+		return alias
+	}
+	return language
+}
+
+// RenderCompileCommand render the placeholders of compile command, the command is executed in single quotes of
+// jenkins sh step, so it must not contain single quote
+func RenderCompileCommand(command string, data CompileCommandData) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", fmt.Errorf("编译命令不能为空")
+	}
+	if strings.Contains(command, "'") {
+		return "", fmt.Errorf("编译命令不能包含单引号")
+	}
+	tpl, err := template.New("compile").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("编译命令模板格式错误: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("编译命令模板渲染失败: %v", err)
+	}
+	rendered := strings.TrimSpace(buf.String())
+	if strings.Contains(rendered, "'") {
+		return "", fmt.Errorf("编译命令不能包含单引号")
+	}
+	return rendered, nil
+}
+
+// VerifyCompileCommand the compile command must be rendered with the placeholders
+func VerifyCompileCommand(command string) error {
+	_, err := RenderCompileCommand(command, sampleCompileCommandData)
+	return err
+}
+
+// GetCompileCommandTemplates ..
+func (pm *SettingManager) GetCompileCommandTemplates() ([]*models.CompileCommandTemplate, error) {
+	return pm.model.GetCompileCommandTemplates()
+}
+
+// GetCompileCommandTemplate ..
+func (pm *SettingManager) GetCompileCommandTemplate(templateID int64) (*models.CompileCommandTemplate, error) {
+	return pm.model.GetCompileCommandTemplateByID(templateID)
+}
+
+// verifyCompileCommandReq ..
+func (pm *SettingManager) verifyCompileCommandReq(request *CompileCommandReq, templateID int64) error {
+	if !compileCommandNameRegexp.MatchString(request.Name) {
+		return fmt.Errorf("无效的编译命令名称: %v, 只能包含小写字母、数字、下划线和中划线", request.Name)
+	}
+	if exists, _ := pm.model.GetCompileCommandTemplateByName(request.Name); exists != nil && exists.ID != templateID {
+		return fmt.Errorf("编译命令 `%v` 已经存在", request.Name)
+	}
+	request.Language = normalizeLanguage(request.Language)
+	if request.Language != "" {
+		if exists, _ := pm.model.GetCompileCommandTemplateByLanguage(request.Language); exists != nil && exists.ID != templateID {
+			return fmt.Errorf("语言 %v 已经使用编译命令 %v", request.Language, exists.Name)
+		}
+	}
+	return VerifyCompileCommand(request.Command)
+}
+
+// createCompileCommandRevision snapshot the current revision of compile command template
+func (pm *SettingManager) createCompileCommandRevision(item *models.CompileCommandTemplate, creator string) error {
+	return pm.model.CreateCompileCommandRevision(&models.CompileCommandRevision{
+		TemplateID: item.ID,
+		Revision:   item.Revision,
+		Command:    item.Command,
+		Creator:    creator,
+	})
+}
+
+// CreateCompileCommandTemplate ..
+func (pm *SettingManager) CreateCompileCommandTemplate(request *CompileCommandReq, creator string) (*models.CompileCommandTemplate, error) {
+	if err := pm.verifyCompileCommandReq(request, 0); err != nil {
+		return nil, err
+	}
+	item := &models.CompileCommandTemplate{
+		Name:        request.Name,
+		Language:    request.Language,
+		Command:     strings.TrimSpace(request.Command),
+		Description: request.Description,
+		Revision:    1,
+		Creator:     creator,
+	}
+	if err := pm.model.CreateCompileCommandTemplate(item); err != nil {
+		log.Log.Error("create compile command: %v occur error: %s", request.Name, err.Error())
+		return nil, err
+	}
+	return item, pm.createCompileCommandRevision(item, creator)
+}
+
+// UpdateCompileCommandTemplate the next revision is created if the command changed
+func (pm *SettingManager) UpdateCompileCommandTemplate(templateID int64, request *CompileCommandReq, creator string) (*models.CompileCommandTemplate, error) {
+	item, err := pm.model.GetCompileCommandTemplateByID(templateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.verifyCompileCommandReq(request, templateID); err != nil {
+		return nil, err
+	}
+	command := strings.TrimSpace(request.Command)
+	changed := command != item.Command
+	item.Name = request.Name
+	item.Language = request.Language
+	item.Command = command
+	item.Description = request.Description
+	if changed {
+		item.Revision++
+	}
+	if err := pm.model.UpdateCompileCommandTemplate(item); err != nil {
+		return nil, err
+	}
+	if changed {
+		return item, pm.createCompileCommandRevision(item, creator)
+	}
+	return item, nil
+}
+
+// DeleteCompileCommandTemplate the template used by project apps can not be deleted
+func (pm *SettingManager) DeleteCompileCommandTemplate(templateID int64) error {
+	item, err := pm.model.GetCompileCommandTemplateByID(templateID)
+	if err != nil {
+		return err
+	}
+	count, err := dao.NewProjectModel().CountProjectAppsByCompileTemplate(item.Name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("编译命令仍被 %v 个项目应用使用", count)
+	}
+	item.MarkDeleted()
+	return pm.model.UpdateCompileCommandTemplate(item)
+}
+
+// GetCompileCommandRevisions ..
+func (pm *SettingManager) GetCompileCommandRevisions(templateID int64) ([]*models.CompileCommandRevision, error) {
+	if _, err := pm.model.GetCompileCommandTemplateByID(templateID); err != nil {
+		return nil, err
+	}
+	return pm.model.GetCompileCommandRevisions(templateID)
+}
+
+// RestoreCompileCommandRevision restore the command of the revision as the next revision
+func (pm *SettingManager) RestoreCompileCommandRevision(templateID, revision int64, creator string) (*models.CompileCommandTemplate, error) {
+	item, err := pm.model.GetCompileCommandTemplateByID(templateID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := pm.model.GetCompileCommandRevision(templateID, revision)
+	if err != nil {
+		return nil, fmt.Errorf("编译命令版本 %v 不存在", revision)
+	}
+	if snapshot.Command == item.Command {
+		return item, nil
+	}
+	item.Command = snapshot.Command
+	item.Revision++
+	if err := pm.model.UpdateCompileCommandTemplate(item); err != nil {
+		return nil, err
+	}
+	return item, pm.createCompileCommandRevision(item, creator)
+}
+
+// ResolveCompileCommand return the compile command of project app rendered with the placeholders, the override of
+// project app is used first, then the template chosen by the project app or the template of app language,
+// empty means there is no compile command for the app
+func (pm *SettingManager) ResolveCompileCommand(projectApp *models.ProjectApp, data CompileCommandData) (string, error) {
+	command := ""
+	if projectApp != nil && strings.TrimSpace(projectApp.CompileCommand) != "" {
+		command = projectApp.CompileCommand
+	} else {
+		var item *models.CompileCommandTemplate
+		var err error
+		if projectApp != nil && projectApp.CompileTemplate != "" {
+			if item, err = pm.model.GetCompileCommandTemplateByName(projectApp.CompileTemplate); err != nil {
+				return "", fmt.Errorf("编译命令 %v 不存在", projectApp.CompileTemplate)
+			}
+		} else if language := normalizeLanguage(data.Language); language != "" {
+			item, _ = pm.model.GetCompileCommandTemplateByLanguage(language)
+		}
+		if item == nil {
+			return "", nil
+		}
+		command = item.Command
+	}
+	return RenderCompileCommand(command, data)
+}
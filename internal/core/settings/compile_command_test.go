@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"testing"
+)
+
+func TestRenderCompileCommand(t *testing.T) {
+	data := CompileCommandData{AppName: "demo", Language: "java", BuildPath: "server", Branch: "dev"}
+	cases := []struct {
+		command string
+		want    string
+		wantErr bool
+	}{
+		{command: "mvn -B clean package", want: "mvn -B clean package"},
+		{command: "mvn -f {{.BuildPath}}/pom.xml package -Dapp={{.AppName}}", want: "mvn -f server/pom.xml package -Dapp=demo"},
+		{command: "go build -o {{.Unknown}}", wantErr: true},
+		{command: "echo 'quoted'", wantErr: true},
+		{command: "npm run {{.Branch", wantErr: true},
+		{command: "  ", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := RenderCompileCommand(c.command, data)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("RenderCompileCommand(%q) expect error, got %q", c.command, got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Fatalf("RenderCompileCommand(%q) = %q, %v, want %q", c.command, got, err, c.want)
+		}
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	for language, want := range map[string]string{"Java": "java", "Golang": "go", "NodeJS": "node", "python3": "python", "": ""} {
+		if got := normalizeLanguage(language); got != want {
+			t.Fatalf("normalizeLanguage(%q) = %q, want %q", language, got, want)
+		}
+	}
+}
@@ -17,11 +17,20 @@ limitations under the License.
 package settings
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils/query"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // CompileEnvReq ..
@@ -31,11 +40,57 @@ type CompileEnvReq struct {
 	Command     string `json:"command,omitempty"`
 	Args        string `json:"args,omitempty"`
 	Description string `json:"description,omitempty"`
+	// OrgID the organization of compile env, only used when create, 0 means shared by all organizations
+	OrgID int64 `json:"org_id,omitempty"`
+	// Version the version of image/command/args/resources, the next version is generated if they changed without version
+	Version string `json:"version,omitempty"`
+	// VersionCommand the command executed by validation, eg: go version
+	VersionCommand string `json:"version_command,omitempty"`
+	CPURequest     string `json:"cpu_request,omitempty"`
+	CPULimit       string `json:"cpu_limit,omitempty"`
+	MemoryRequest  string `json:"memory_request,omitempty"`
+	MemoryLimit    string `json:"memory_limit,omitempty"`
+	// NodeSelector/Tolerations/ImagePullSecrets of the build pod, they are not versioned
+	NodeSelector     map[string]string      `json:"node_selector,omitempty"`
+	Tolerations      []CompileEnvToleration `json:"tolerations,omitempty"`
+	ImagePullSecrets []string               `json:"image_pull_secrets,omitempty"`
+	// BuilderImage the buildpacks builder of the apps build without dockerfile, it is not versioned
+	BuilderImage string `json:"builder_image,omitempty"`
+	// OSType/Shell the os of build node and the shell of build commands, linux/sh by default, they are not versioned
+	OSType string `json:"os_type,omitempty"`
+	Shell  string `json:"shell,omitempty"`
+	// Arch the cpu architectures supported by the image, amd64 by default, it is not versioned
+	Arch []string `json:"arch,omitempty"`
+}
+
+// CompileEnvToleration the toleration of build pod, same as the toleration of kubernetes pod
+type CompileEnvToleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+// CompileEnvDeprecationReq ..
+type CompileEnvDeprecationReq struct {
+	Deprecated bool   `json:"deprecated"`
+	Message    string `json:"message,omitempty"`
+	// ReplacedBy the compile env which the apps should migrate to
+	ReplacedBy int64 `json:"replaced_by,omitempty"`
 }
 
-// GetCompileEnvs ..
-func (pm *SettingManager) GetCompileEnvs(integrateType string) ([]*models.CompileEnv, error) {
-	items, err := pm.model.GetCompileEnvs(integrateType)
+// CompileEnvValidation the result of compile env validation
+type CompileEnvValidation struct {
+	Status string `json:"status"`
+	Output string `json:"output"`
+}
+
+// the max length of validation output stored
+const maxValidationOutput = 2048
+
+// GetCompileEnvs return the compile envs of the organizations, all the compile envs are returned if orgIDs is nil
+func (pm *SettingManager) GetCompileEnvs(integrateType string, orgIDs []int64) ([]*models.CompileEnv, error) {
+	items, err := pm.model.GetCompileEnvs(integrateType, orgIDs)
 	if err != nil {
 		log.Log.Error("get interate settings error: %s", err.Error())
 		return nil, err
@@ -65,8 +120,8 @@ func (pm *SettingManager) GetCompileEnvByName(name string) (*models.CompileEnv,
 }
 
 // GetCompileEnvsByPagination ..
-func (pm *SettingManager) GetCompileEnvsByPagination(filter *query.FilterQuery) (*query.QueryResult, error) {
-	queryResult, settingsList, err := pm.model.GetCompileEnvsByPagination(filter)
+func (pm *SettingManager) GetCompileEnvsByPagination(filter *query.FilterQuery, orgIDs []int64) (*query.QueryResult, error) {
+	queryResult, settingsList, err := pm.model.GetCompileEnvsByPagination(filter, orgIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +129,51 @@ func (pm *SettingManager) GetCompileEnvsByPagination(filter *query.FilterQuery)
 	return queryResult, err
 }
 
+// verifyOrganization the organization of setting must exist, 0 means shared by all organizations
+func verifyOrganization(orgID int64) error {
+	if orgID == 0 {
+		return nil
+	}
+	if _, err := dao.NewOrganizationModel().GetOrganizationByID(orgID); err != nil {
+		log.Log.Error("get organization: %v occur error: %s", orgID, err.Error())
+		return fmt.Errorf("组织 %v 不存在", orgID)
+	}
+	return nil
+}
+
+// InOrgs whether the setting of organization is accessible by the user of orgIDs, nil orgIDs means all the organizations
+func InOrgs(orgID int64, orgIDs []int64) bool {
+	if orgIDs == nil {
+		return true
+	}
+	for _, item := range orgIDs {
+		if item == orgID {
+			return true
+		}
+	}
+	return false
+}
+
+// IntegrateSettingInOrgs whether the integrate setting belongs to the organizations
+func (pm *SettingManager) IntegrateSettingInOrgs(settingID int64, orgIDs []int64) (bool, error) {
+	setting, err := pm.model.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		log.Log.Error("get integrate setting: %v occur error: %s", settingID, err.Error())
+		return false, fmt.Errorf("集成配置 %v 不存在", settingID)
+	}
+	return InOrgs(setting.OrgID, orgIDs), nil
+}
+
+// CompileEnvInOrgs whether the compile env belongs to the organizations
+func (pm *SettingManager) CompileEnvInOrgs(compileEnvID int64, orgIDs []int64) (bool, error) {
+	compileEnv, err := pm.model.GetCompileEnvByID(compileEnvID)
+	if err != nil {
+		log.Log.Error("get compile env: %v occur error: %s", compileEnvID, err.Error())
+		return false, fmt.Errorf("编译环境 %v 不存在", compileEnvID)
+	}
+	return InOrgs(compileEnv.OrgID, orgIDs), nil
+}
+
 // resetEnv clear env config
 func resetEnv(env *string) {
 	*env = ""
@@ -93,8 +193,229 @@ func compileEnvNameUnique(pm *SettingManager, name string, stepId int64) error {
 	return nil
 }
 
-// UpdateCompileEnv ..
-func (pm *SettingManager) UpdateCompileEnv(request *CompileEnvReq, stepID int64) error {
+// verifyCompileEnvResources the resources must be valid quantities, and the requests must not exceed the limits
+func verifyCompileEnvResources(request *CompileEnvReq) error {
+	pairs := []struct {
+		name           string
+		request, limit string
+	}{
+		{name: "CPU", request: request.CPURequest, limit: request.CPULimit},
+		{name: "内存", request: request.MemoryRequest, limit: request.MemoryLimit},
+	}
+	for _, pair := range pairs {
+		quantities := []resource.Quantity{}
+		for _, value := range []string{pair.request, pair.limit} {
+			if value == "" {
+				continue
+			}
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				return fmt.Errorf("无效的%v资源配置: %v", pair.name, value)
+			}
+			quantities = append(quantities, quantity)
+		}
+		if len(quantities) == 2 && quantities[0].Cmp(quantities[1]) > 0 {
+			return fmt.Errorf("%v资源请求 %v 不能大于限制 %v", pair.name, pair.request, pair.limit)
+		}
+	}
+	return nil
+}
+
+// verifyCompileEnvScheduling the node selector/tolerations/image pull secrets must be valid for kubernetes
+func verifyCompileEnvScheduling(request *CompileEnvReq) error {
+	for key, value := range request.NodeSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return fmt.Errorf("无效的节点选择器标签: %v, %v", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			return fmt.Errorf("无效的节点选择器标签值: %v, %v", value, strings.Join(errs, "; "))
+		}
+	}
+	for _, toleration := range request.Tolerations {
+		if toleration.Key != "" {
+			if errs := validation.IsQualifiedName(toleration.Key); len(errs) != 0 {
+				return fmt.Errorf("无效的容忍度键: %v, %v", toleration.Key, strings.Join(errs, "; "))
+			}
+		}
+		switch toleration.Operator {
+		case "", "Equal":
+			if toleration.Key == "" {
+				return errors.New("容忍度键为空时操作符必须为 Exists")
+			}
+		case "Exists":
+			if toleration.Value != "" {
+				return errors.New("容忍度操作符为 Exists 时值必须为空")
+			}
+		default:
+			return fmt.Errorf("无效的容忍度操作符: %v, 仅支持 Equal/Exists", toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return fmt.Errorf("无效的容忍度效果: %v, 仅支持 NoSchedule/PreferNoSchedule/NoExecute", toleration.Effect)
+		}
+	}
+	for _, secret := range request.ImagePullSecrets {
+		if errs := validation.IsDNS1123Subdomain(secret); len(errs) != 0 {
+			return fmt.Errorf("无效的镜像拉取密钥: %v, %v", secret, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// verifyCompileEnvShell the shell must be supported by the os of compile env
+func verifyCompileEnvShell(request *CompileEnvReq) error {
+	switch request.OSType {
+	case "", models.CompileEnvOSLinux:
+		switch request.Shell {
+		case "", models.CompileEnvShellSh:
+		default:
+			return fmt.Errorf("Linux 编译环境不支持 %v, 仅支持 sh", request.Shell)
+		}
+	case models.CompileEnvOSWindows:
+		switch request.Shell {
+		case "", models.CompileEnvShellBat, models.CompileEnvShellPowershell:
+		default:
+			return fmt.Errorf("Windows 编译环境不支持 %v, 仅支持 bat/powershell", request.Shell)
+		}
+		if request.BuilderImage != "" {
+			return errors.New("Windows 编译环境不支持 buildpacks 构建")
+		}
+	default:
+		return fmt.Errorf("无效的操作系统类型: %v, 仅支持 linux/windows", request.OSType)
+	}
+	return nil
+}
+
+// VerifyArchs the cpu architectures must be supported and distinct
+func VerifyArchs(archs []string) error {
+	seen := map[string]bool{}
+	for _, arch := range archs {
+		switch arch {
+		case models.ArchAmd64, models.ArchArm64:
+		default:
+			return fmt.Errorf("不支持的 CPU 架构: %v, 仅支持 amd64/arm64", arch)
+		}
+		if seen[arch] {
+			return fmt.Errorf("CPU 架构 %v 重复", arch)
+		}
+		seen[arch] = true
+	}
+	return nil
+}
+
+// verifyCompileEnvArch the windows compile env only supports amd64
+func verifyCompileEnvArch(request *CompileEnvReq) error {
+	if err := VerifyArchs(request.Arch); err != nil {
+		return err
+	}
+	if request.OSType == models.CompileEnvOSWindows {
+		for _, arch := range request.Arch {
+			if arch != models.ArchAmd64 {
+				return fmt.Errorf("Windows 编译环境不支持 CPU 架构 %v", arch)
+			}
+		}
+	}
+	return nil
+}
+
+// setCompileEnvScheduling encode the scheduling of request into the compile env
+func setCompileEnvScheduling(compileEnv *models.CompileEnv, request *CompileEnvReq) error {
+	compileEnv.NodeSelector = ""
+	if len(request.NodeSelector) > 0 {
+		bytes, err := json.Marshal(request.NodeSelector)
+		if err != nil {
+			return err
+		}
+		compileEnv.NodeSelector = string(bytes)
+	}
+	compileEnv.Tolerations = ""
+	if len(request.Tolerations) > 0 {
+		bytes, err := json.Marshal(request.Tolerations)
+		if err != nil {
+			return err
+		}
+		compileEnv.Tolerations = string(bytes)
+	}
+	compileEnv.ImagePullSecrets = strings.Join(request.ImagePullSecrets, ",")
+	return nil
+}
+
+// CompileEnvScheduling decode the node selector/tolerations/image pull secrets of the compile env
+func CompileEnvScheduling(compileEnv *models.CompileEnv) (map[string]string, []CompileEnvToleration, []string, error) {
+	nodeSelector := map[string]string{}
+	if compileEnv.NodeSelector != "" {
+		if err := json.Unmarshal([]byte(compileEnv.NodeSelector), &nodeSelector); err != nil {
+			return nil, nil, nil, fmt.Errorf("compile env: %v node selector is invalid: %v", compileEnv.Name, err)
+		}
+	}
+	tolerations := []CompileEnvToleration{}
+	if compileEnv.Tolerations != "" {
+		if err := json.Unmarshal([]byte(compileEnv.Tolerations), &tolerations); err != nil {
+			return nil, nil, nil, fmt.Errorf("compile env: %v tolerations is invalid: %v", compileEnv.Name, err)
+		}
+	}
+	secrets := []string{}
+	for _, secret := range strings.Split(compileEnv.ImagePullSecrets, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return nodeSelector, tolerations, secrets, nil
+}
+
+// NextCompileEnvVersion return the next version, eg: v1 -> v2, 1.9 -> 1.10, empty -> v1
+func NextCompileEnvVersion(version string) string {
+	if version == "" {
+		return "v1"
+	}
+	end := len(version)
+	start := end
+	for start > 0 && version[start-1] >= '0' && version[start-1] <= '9' {
+		start--
+	}
+	if start == end {
+		return version + ".1"
+	}
+	number, err := strconv.Atoi(version[start:end])
+	if err != nil {
+		return version + ".1"
+	}
+	return version[:start] + strconv.Itoa(number+1)
+}
+
+// compileEnvVersionChanged return true if the versioned fields changed
+func compileEnvVersionChanged(compileEnv *models.CompileEnv, request *CompileEnvReq) bool {
+	return (request.Image != "" && compileEnv.Image != request.Image) ||
+		compileEnv.Command != request.Command ||
+		compileEnv.Args != request.Args ||
+		compileEnv.VersionCommand != request.VersionCommand ||
+		compileEnv.CPURequest != request.CPURequest ||
+		compileEnv.CPULimit != request.CPULimit ||
+		compileEnv.MemoryRequest != request.MemoryRequest ||
+		compileEnv.MemoryLimit != request.MemoryLimit
+}
+
+// createCompileEnvVersion snapshot the current version of compile env
+func (pm *SettingManager) createCompileEnvVersion(compileEnv *models.CompileEnv, creator string) error {
+	return pm.model.CreateCompileEnvVersion(&models.CompileEnvVersion{
+		Addons:         models.NewAddons(),
+		CompileEnvID:   compileEnv.ID,
+		Version:        compileEnv.Version,
+		Image:          compileEnv.Image,
+		Command:        compileEnv.Command,
+		Args:           compileEnv.Args,
+		VersionCommand: compileEnv.VersionCommand,
+		CPURequest:     compileEnv.CPURequest,
+		CPULimit:       compileEnv.CPULimit,
+		MemoryRequest:  compileEnv.MemoryRequest,
+		MemoryLimit:    compileEnv.MemoryLimit,
+		Creator:        creator,
+	})
+}
+
+// UpdateCompileEnv update the compile env, a new version is created if the image/command/args/resources changed
+func (pm *SettingManager) UpdateCompileEnv(request *CompileEnvReq, stepID int64, creator string) error {
 	compileEnv, err := pm.model.GetCompileEnvByID(stepID)
 	if err != nil {
 		return err
@@ -103,6 +424,35 @@ func (pm *SettingManager) UpdateCompileEnv(request *CompileEnvReq, stepID int64)
 	if err := compileEnvNameUnique(pm, request.Name, stepID); err != nil {
 		return err
 	}
+	if err := verifyCompileEnvResources(request); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvScheduling(request); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvShell(request); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvArch(request); err != nil {
+		return err
+	}
+
+	newVersion := ""
+	if request.Version != "" && request.Version != compileEnv.Version {
+		newVersion = request.Version
+	} else if compileEnvVersionChanged(compileEnv, request) {
+		newVersion = NextCompileEnvVersion(compileEnv.Version)
+	}
+	if newVersion != "" {
+		if _, err := pm.model.GetCompileEnvVersion(stepID, newVersion); err == nil {
+			return fmt.Errorf("编译环境版本 %v 已经存在，请指定新的版本号", newVersion)
+		}
+		compileEnv.Version = newVersion
+		// the validation result belongs to the previous version
+		compileEnv.ValidationStatus = ""
+		compileEnv.ValidationOutput = ""
+		compileEnv.ValidatedAt = nil
+	}
 
 	if request.Name != "" {
 		compileEnv.Name = request.Name
@@ -129,8 +479,26 @@ func (pm *SettingManager) UpdateCompileEnv(request *CompileEnvReq, stepID int64)
 	if request.Image != "" {
 		compileEnv.Image = request.Image
 	}
+	compileEnv.VersionCommand = request.VersionCommand
+	compileEnv.CPURequest = request.CPURequest
+	compileEnv.CPULimit = request.CPULimit
+	compileEnv.MemoryRequest = request.MemoryRequest
+	compileEnv.MemoryLimit = request.MemoryLimit
+	compileEnv.BuilderImage = request.BuilderImage
+	compileEnv.OSType = request.OSType
+	compileEnv.Shell = request.Shell
+	compileEnv.Arch = strings.Join(request.Arch, ",")
+	if err := setCompileEnvScheduling(compileEnv, request); err != nil {
+		return err
+	}
 
-	return pm.model.UpdateCompileEnv(compileEnv)
+	if err := pm.model.UpdateCompileEnv(compileEnv); err != nil {
+		return err
+	}
+	if newVersion != "" {
+		return pm.createCompileEnvVersion(compileEnv, creator)
+	}
+	return nil
 }
 
 // CreateCompileEnv ..
@@ -139,22 +507,194 @@ func (pm *SettingManager) CreateCompileEnv(request *CompileEnvReq, creator strin
 	if err := compileEnvNameUnique(pm, request.Name, 0); err != nil {
 		return err
 	}
+	if err := verifyOrganization(request.OrgID); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvResources(request); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvScheduling(request); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvShell(request); err != nil {
+		return err
+	}
+	if err := verifyCompileEnvArch(request); err != nil {
+		return err
+	}
+	if request.Version == "" {
+		request.Version = NextCompileEnvVersion("")
+	}
 
 	// TODO: verify req struct is valid
 	newCompileEnv := &models.CompileEnv{
-		Name:        request.Name,
-		Description: request.Description,
-		Creator:     creator,
-		Image:       request.Image,
-		Command:     request.Command,
-		Args:        request.Args,
+		Name:           request.Name,
+		Description:    request.Description,
+		Creator:        creator,
+		Image:          request.Image,
+		Command:        request.Command,
+		Args:           request.Args,
+		OrgID:          request.OrgID,
+		Version:        request.Version,
+		VersionCommand: request.VersionCommand,
+		CPURequest:     request.CPURequest,
+		CPULimit:       request.CPULimit,
+		MemoryRequest:  request.MemoryRequest,
+		MemoryLimit:    request.MemoryLimit,
+		BuilderImage:   request.BuilderImage,
+		OSType:         request.OSType,
+		Shell:          request.Shell,
+		Arch:           strings.Join(request.Arch, ","),
+	}
+	if err := setCompileEnvScheduling(newCompileEnv, request); err != nil {
+		return err
 	}
 
-	return pm.model.CreateCompileEnv(newCompileEnv)
+	if err := pm.model.CreateCompileEnv(newCompileEnv); err != nil {
+		return err
+	}
+	compileEnv, err := pm.model.GetCompileEnvByName(request.Name)
+	if err != nil {
+		return err
+	}
+	return pm.createCompileEnvVersion(compileEnv, creator)
 }
 
-// DeleteCompileEnv ..
+// DeleteCompileEnv the compile env used by apps is not allowed to delete, deprecate it and migrate the apps first
 func (pm *SettingManager) DeleteCompileEnv(stageID int64) error {
-	// TODO: add compile env delete verify
+	apps, err := dao.NewScmAppModel().GetScmAppsByCompileEnv(stageID)
+	if err != nil {
+		return err
+	}
+	if len(apps) > 0 {
+		names := []string{}
+		for i, app := range apps {
+			if i == 5 {
+				names = append(names, "...")
+				break
+			}
+			names = append(names, app.FullName)
+		}
+		return fmt.Errorf("编译环境仍被 %v 个应用使用(%v)，请先将其标记为废弃并迁移应用", len(apps), strings.Join(names, ", "))
+	}
 	return pm.model.DeleteCompileEnv(stageID)
 }
+
+// GetCompileEnvVersions ..
+func (pm *SettingManager) GetCompileEnvVersions(compileEnvID int64) ([]*models.CompileEnvVersion, error) {
+	if _, err := pm.model.GetCompileEnvByID(compileEnvID); err != nil {
+		return nil, err
+	}
+	return pm.model.GetCompileEnvVersions(compileEnvID)
+}
+
+// ActivateCompileEnvVersion set the version as the current version of compile env, eg: rollback the image
+func (pm *SettingManager) ActivateCompileEnvVersion(compileEnvID int64, version string) error {
+	compileEnv, err := pm.model.GetCompileEnvByID(compileEnvID)
+	if err != nil {
+		return err
+	}
+	item, err := pm.model.GetCompileEnvVersion(compileEnvID, version)
+	if err != nil {
+		return fmt.Errorf("编译环境版本 %v 不存在", version)
+	}
+	compileEnv.Version = item.Version
+	compileEnv.Image = item.Image
+	compileEnv.Command = item.Command
+	compileEnv.Args = item.Args
+	compileEnv.VersionCommand = item.VersionCommand
+	compileEnv.CPURequest = item.CPURequest
+	compileEnv.CPULimit = item.CPULimit
+	compileEnv.MemoryRequest = item.MemoryRequest
+	compileEnv.MemoryLimit = item.MemoryLimit
+	compileEnv.ValidationStatus = ""
+	compileEnv.ValidationOutput = ""
+	compileEnv.ValidatedAt = nil
+	return pm.model.UpdateCompileEnv(compileEnv)
+}
+
+// DeprecateCompileEnv mark the compile env deprecated or not, the deprecated compile env can not be used by new apps
+func (pm *SettingManager) DeprecateCompileEnv(compileEnvID int64, request *CompileEnvDeprecationReq) error {
+	compileEnv, err := pm.model.GetCompileEnvByID(compileEnvID)
+	if err != nil {
+		return err
+	}
+	if request.Deprecated && request.ReplacedBy != 0 {
+		if request.ReplacedBy == compileEnvID {
+			return fmt.Errorf("不能使用自身作为替代的编译环境")
+		}
+		replacement, err := pm.model.GetCompileEnvByID(request.ReplacedBy)
+		if err != nil {
+			return fmt.Errorf("替代的编译环境 %v 不存在", request.ReplacedBy)
+		}
+		if replacement.Deprecated {
+			return fmt.Errorf("替代的编译环境 %v 已经废弃", replacement.Name)
+		}
+	}
+	compileEnv.Deprecated = request.Deprecated
+	compileEnv.DeprecationMessage = ""
+	compileEnv.ReplacedBy = 0
+	if request.Deprecated {
+		compileEnv.DeprecationMessage = request.Message
+		compileEnv.ReplacedBy = request.ReplacedBy
+	}
+	return pm.model.UpdateCompileEnv(compileEnv)
+}
+
+// VerifyCompileEnvUsable the compile env must exist and not be deprecated
+func (pm *SettingManager) VerifyCompileEnvUsable(compileEnvID int64) error {
+	if compileEnvID == 0 {
+		return nil
+	}
+	compileEnv, err := pm.model.GetCompileEnvByID(compileEnvID)
+	if err != nil {
+		return fmt.Errorf("编译环境 %v 不存在", compileEnvID)
+	}
+	if !compileEnv.Deprecated {
+		return nil
+	}
+	msg := fmt.Sprintf("编译环境 %v 已经废弃", compileEnv.Name)
+	if compileEnv.DeprecationMessage != "" {
+		msg = fmt.Sprintf("%v: %v", msg, compileEnv.DeprecationMessage)
+	}
+	if replacement, err := pm.model.GetCompileEnvByID(compileEnv.ReplacedBy); err == nil {
+		msg = fmt.Sprintf("%v，请使用 %v", msg, replacement.Name)
+	}
+	return errors.New(msg)
+}
+
+// VerifyCompileEnvArchs the app image of single architecture is built on the node of the architecture natively,
+// so the compile env must support it, the multi-arch images are cross built on the default nodes
+func (pm *SettingManager) VerifyCompileEnvArchs(compileEnvID int64, archs []string) error {
+	if compileEnvID == 0 || len(archs) != 1 {
+		return nil
+	}
+	compileEnv, err := pm.model.GetCompileEnvByID(compileEnvID)
+	if err != nil {
+		return fmt.Errorf("编译环境 %v 不存在", compileEnvID)
+	}
+	for _, arch := range compileEnv.CompileEnvArchs() {
+		if arch == archs[0] {
+			return nil
+		}
+	}
+	return fmt.Errorf("编译环境 %v 不支持 CPU 架构 %v", compileEnv.Name, archs[0])
+}
+
+// RecordCompileEnvValidation save the result of the latest validation
+func (pm *SettingManager) RecordCompileEnvValidation(compileEnvID int64, validation *CompileEnvValidation) error {
+	compileEnv, err := pm.model.GetCompileEnvByID(compileEnvID)
+	if err != nil {
+		return err
+	}
+	output := validation.Output
+	if len(output) > maxValidationOutput {
+		// keep the tail, which has the error usually
+		output = output[len(output)-maxValidationOutput:]
+	}
+	now := time.Now()
+	compileEnv.ValidationStatus = validation.Status
+	compileEnv.ValidationOutput = output
+	compileEnv.ValidatedAt = &now
+	return pm.model.UpdateCompileEnv(compileEnv)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestNextCompileEnvVersion(t *testing.T) {
+	tests := map[string]string{
+		"":       "v1",
+		"v1":     "v2",
+		"1.9":    "1.10",
+		"v2.0.9": "v2.0.10",
+		"beta":   "beta.1",
+	}
+	for version, want := range tests {
+		if got := NextCompileEnvVersion(version); got != want {
+			t.Fatalf("NextCompileEnvVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestVerifyCompileEnvResources(t *testing.T) {
+	valid := &CompileEnvReq{CPURequest: "500m", CPULimit: "1", MemoryRequest: "512Mi", MemoryLimit: "1Gi"}
+	if err := verifyCompileEnvResources(valid); err != nil {
+		t.Fatalf("valid resources: %v", err)
+	}
+	for _, req := range []*CompileEnvReq{
+		{CPURequest: "abc"},
+		{CPURequest: "2", CPULimit: "1"},
+		{MemoryRequest: "2Gi", MemoryLimit: "512Mi"},
+	} {
+		if err := verifyCompileEnvResources(req); err == nil {
+			t.Fatalf("resources %+v should be invalid", req)
+		}
+	}
+}
+
+func TestVerifyCompileEnvScheduling(t *testing.T) {
+	valid := &CompileEnvReq{
+		NodeSelector: map[string]string{"node-role.kubernetes.io/ci": "true"},
+		Tolerations: []CompileEnvToleration{
+			{Key: "dedicated", Operator: "Equal", Value: "ci", Effect: "NoSchedule"},
+			{Operator: "Exists"},
+		},
+		ImagePullSecrets: []string{"harbor-secret"},
+	}
+	if err := verifyCompileEnvScheduling(valid); err != nil {
+		t.Fatalf("valid scheduling: %v", err)
+	}
+	for _, req := range []*CompileEnvReq{
+		{NodeSelector: map[string]string{"invalid key": "v"}},
+		{Tolerations: []CompileEnvToleration{{Key: "dedicated", Operator: "In"}}},
+		{Tolerations: []CompileEnvToleration{{Key: "dedicated", Operator: "Exists", Value: "ci"}}},
+		{Tolerations: []CompileEnvToleration{{Value: "ci"}}},
+		{Tolerations: []CompileEnvToleration{{Key: "dedicated", Value: "ci", Effect: "Never"}}},
+		{ImagePullSecrets: []string{"Invalid_Secret"}},
+	} {
+		if err := verifyCompileEnvScheduling(req); err == nil {
+			t.Fatalf("scheduling %+v should be invalid", req)
+		}
+	}
+
+	compileEnv := &models.CompileEnv{}
+	if err := setCompileEnvScheduling(compileEnv, valid); err != nil {
+		t.Fatalf("set scheduling: %v", err)
+	}
+	nodeSelector, tolerations, secrets, err := CompileEnvScheduling(compileEnv)
+	if err != nil {
+		t.Fatalf("decode scheduling: %v", err)
+	}
+	if !reflect.DeepEqual(nodeSelector, valid.NodeSelector) || !reflect.DeepEqual(tolerations, valid.Tolerations) || !reflect.DeepEqual(secrets, valid.ImagePullSecrets) {
+		t.Fatalf("decoded scheduling %v %v %v, want %+v", nodeSelector, tolerations, secrets, valid)
+	}
+}
+
+func TestInOrgs(t *testing.T) {
+	initTestDB(t)
+	pm := NewSettingManager()
+	settingID := insertTestItem(t, &models.IntegrateSetting{Addons: models.NewAddons(), Name: "org2-jenkins", Type: "jenkins", OrgID: 2})
+	sharedID := insertTestItem(t, &models.IntegrateSetting{Addons: models.NewAddons(), Name: "shared-harbor", Type: "harbor"})
+	compileEnvID := insertTestItem(t, &models.CompileEnv{Addons: models.NewAddons(), Name: "org2-golang", Image: "golang", OrgID: 2})
+
+	tests := []struct {
+		name   string
+		orgIDs []int64
+		want   bool
+	}{
+		{"the member of other organization", []int64{0, 1}, false},
+		{"the member of organization", []int64{0, 1, 2}, true},
+		{"the sys admin", nil, true},
+	}
+	for _, tt := range tests {
+		if ok, err := pm.IntegrateSettingInOrgs(settingID, tt.orgIDs); ok != tt.want || err != nil {
+			t.Errorf("%s: IntegrateSettingInOrgs() = %v, %v", tt.name, ok, err)
+		}
+		if ok, err := pm.CompileEnvInOrgs(compileEnvID, tt.orgIDs); ok != tt.want || err != nil {
+			t.Errorf("%s: CompileEnvInOrgs() = %v, %v", tt.name, ok, err)
+		}
+		if ok := InOrgs(2, tt.orgIDs); ok != tt.want {
+			t.Errorf("%s: InOrgs() = %v", tt.name, ok)
+		}
+	}
+	// the shared settings are accessible for all
+	if ok, err := pm.IntegrateSettingInOrgs(sharedID, []int64{0, 1}); !ok || err != nil {
+		t.Errorf("IntegrateSettingInOrgs() of shared setting = %v, %v", ok, err)
+	}
+	if _, err := pm.IntegrateSettingInOrgs(-1, []int64{0, 1}); err == nil {
+		t.Errorf("IntegrateSettingInOrgs() of missing setting should fail")
+	}
+	if _, err := pm.CompileEnvInOrgs(-1, []int64{0, 1}); err == nil {
+		t.Errorf("CompileEnvInOrgs() of missing compile env should fail")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/go-atomci/atomci/pkg/registry"
+)
+
+// the pem types of the encrypted private key generated by cosign
+var cosignPrivateKeyTypes = []string{"ENCRYPTED COSIGN PRIVATE KEY", "ENCRYPTED SIGSTORE PRIVATE KEY"}
+
+// Verify check the key pair is generated by cosign, the private key is optional when the setting only verifies the images
+func (c *CosignConfig) Verify() error {
+	if strings.TrimSpace(c.PublicKey) == "" {
+		return fmt.Errorf("公钥不能为空")
+	}
+	if _, err := registry.ParsePublicKey(c.PublicKey); err != nil {
+		return fmt.Errorf("无效的公钥: %v", err)
+	}
+	if strings.TrimSpace(c.PrivateKey) == "" {
+		return nil
+	}
+	block, _ := pem.Decode([]byte(strings.TrimSpace(c.PrivateKey)))
+	if block == nil {
+		return fmt.Errorf("无效的私钥: 不是 PEM 格式")
+	}
+	for _, keyType := range cosignPrivateKeyTypes {
+		if block.Type == keyType {
+			return nil
+		}
+	}
+	return fmt.Errorf("无效的私钥类型: %v, 请使用 cosign generate-key-pair 生成的私钥", block.Type)
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// IntegrateCredentialReq the new credential version of integrate setting
+type IntegrateCredentialReq struct {
+	Config interface{} `json:"config"`
+}
+
+// IntegrateCredentialRsp the credential version, the config is never returned
+type IntegrateCredentialRsp struct {
+	SettingID   int64      `json:"setting_id"`
+	Version     int        `json:"version"`
+	Status      string     `json:"status"`
+	Message     string     `json:"message"`
+	Active      bool       `json:"active"`
+	Creator     string     `json:"creator"`
+	CreateAt    *time.Time `json:"create_at"`
+	ActivatedAt *time.Time `json:"activated_at"`
+}
+
+func formatIntegrateCredential(item *models.IntegrateSettingCredential) *IntegrateCredentialRsp {
+	return &IntegrateCredentialRsp{
+		SettingID:   item.SettingID,
+		Version:     item.Version,
+		Status:      item.Status,
+		Message:     item.Message,
+		Active:      item.Active,
+		Creator:     item.Creator,
+		CreateAt:    &item.CreateAt,
+		ActivatedAt: item.ActivatedAt,
+	}
+}
+
+// ensureIntegrateCredential the config of setting which was created before credential versioning becomes version 1
+func (pm *SettingManager) ensureIntegrateCredential(setting *models.IntegrateSetting) error {
+	if setting.CredentialVersion > 0 {
+		return nil
+	}
+	item := &models.IntegrateSettingCredential{
+		SettingID: setting.ID,
+		Version:   1,
+		Config:    setting.Config,
+		Status:    models.CredentialValidated,
+		Creator:   setting.Creator,
+	}
+	if err := pm.model.CreateIntegrateCredential(item); err != nil {
+		return err
+	}
+	return pm.model.ActivateIntegrateCredential(setting, item)
+}
+
+// GetIntegrateCredentials ..
+func (pm *SettingManager) GetIntegrateCredentials(settingID int64) ([]*IntegrateCredentialRsp, error) {
+	setting, err := pm.model.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.ensureIntegrateCredential(setting); err != nil {
+		return nil, err
+	}
+	items, err := pm.model.GetIntegrateCredentials(settingID)
+	if err != nil {
+		return nil, err
+	}
+	rsp := []*IntegrateCredentialRsp{}
+	for _, item := range items {
+		rsp = append(rsp, formatIntegrateCredential(item))
+	}
+	return rsp, nil
+}
+
+// AddIntegrateCredential add the new credential version, it is not used until validated and activated
+func (pm *SettingManager) AddIntegrateCredential(settingID int64, request *IntegrateCredentialReq, creator string) (*IntegrateCredentialRsp, error) {
+	setting, err := pm.model.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Config == nil {
+		return nil, fmt.Errorf("凭据配置不能为空")
+	}
+	if err := pm.ensureIntegrateCredential(setting); err != nil {
+		return nil, err
+	}
+	items, err := pm.model.GetIntegrateCredentials(settingID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := (&IntegrateSettingReq{Config: request.Config}).String()
+	if err != nil {
+		log.Log.Error("json marshal error: %s", err.Error())
+		return nil, err
+	}
+	item := &models.IntegrateSettingCredential{
+		SettingID: settingID,
+		Version:   nextCredentialVersion(items),
+		Status:    models.CredentialPending,
+		Creator:   creator,
+	}
+	item.CryptoConfig(config)
+	if err := pm.model.CreateIntegrateCredential(item); err != nil {
+		return nil, err
+	}
+	return formatIntegrateCredential(item), nil
+}
+
+// ValidateIntegrateCredential verify the credential version with the server of setting and save the result
+func (pm *SettingManager) ValidateIntegrateCredential(settingID int64, version int, scmChecker ScmChecker) (*IntegrateCredentialRsp, error) {
+	setting, err := pm.model.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return nil, err
+	}
+	item, err := pm.model.GetIntegrateCredential(settingID, version)
+	if err != nil {
+		return nil, fmt.Errorf("集成配置 %v 的凭据版本 %v 不存在", setting.Name, version)
+	}
+	candidate := formatCredentialSetting(setting, item)
+	msg, checkErr := pm.checkIntegrateSetting(candidate, scmChecker, integrateCheckTimeout())
+	item.Status = models.CredentialValidated
+	item.Message = msg
+	if checkErr != nil {
+		item.Status = models.CredentialFailed
+		item.Message = checkErr.Error()
+	}
+	if len(item.Message) > maxHealthMessage {
+		item.Message = item.Message[:maxHealthMessage]
+	}
+	if err := pm.model.UpdateIntegrateCredential(item); err != nil {
+		return nil, err
+	}
+	return formatIntegrateCredential(item), nil
+}
+
+// ActivateIntegrateCredential switch the setting to the validated credential version, the running jobs keep the
+// version they started with
+func (pm *SettingManager) ActivateIntegrateCredential(settingID int64, version int) error {
+	setting, err := pm.model.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return err
+	}
+	if err := pm.ensureIntegrateCredential(setting); err != nil {
+		return err
+	}
+	item, err := pm.model.GetIntegrateCredential(settingID, version)
+	if err != nil {
+		return fmt.Errorf("集成配置 %v 的凭据版本 %v 不存在", setting.Name, version)
+	}
+	if item.Status != models.CredentialValidated {
+		return fmt.Errorf("凭据版本 %v 未通过校验，不能启用", version)
+	}
+	if item.Active {
+		return nil
+	}
+	log.Log.Info("integrate setting: %v credential switch from version %v to %v", setting.Name, setting.CredentialVersion, version)
+	return pm.model.ActivateIntegrateCredential(setting, item)
+}
+
+// RollbackIntegrateCredential activate the version which was active before the current one
+func (pm *SettingManager) RollbackIntegrateCredential(settingID int64) (int, error) {
+	setting, err := pm.model.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return 0, err
+	}
+	items, err := pm.model.GetIntegrateCredentials(settingID)
+	if err != nil {
+		return 0, err
+	}
+	previous := previousCredential(items, setting.CredentialVersion)
+	if previous == nil {
+		return 0, fmt.Errorf("集成配置 %v 没有可回滚的凭据版本", setting.Name)
+	}
+	log.Log.Info("integrate setting: %v credential rollback from version %v to %v", setting.Name, setting.CredentialVersion, previous.Version)
+	return previous.Version, pm.model.ActivateIntegrateCredential(setting, previous)
+}
+
+// GetIntegrateSettingByVersion return the setting with the config of credential version, the current config is
+// returned if the version is 0 or active
+func (pm *SettingManager) GetIntegrateSettingByVersion(id int64, version int) (*IntegrateSettingResponse, error) {
+	setting, err := pm.model.GetIntegrateSettingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 || version == setting.CredentialVersion {
+		return formatSignalIntegrateSetting(setting, &Config{}), nil
+	}
+	item, err := pm.model.GetIntegrateCredential(id, version)
+	if err != nil {
+		log.Log.Error("get integrate setting: %v credential version: %v error: %s", id, version, err.Error())
+		return nil, fmt.Errorf("集成配置 %v 的凭据版本 %v 不存在", setting.Name, version)
+	}
+	return formatCredentialSetting(setting, item), nil
+}
+
+// updateActiveCredential keep the active credential the same as the config of setting updated directly
+func (pm *SettingManager) updateActiveCredential(setting *models.IntegrateSetting) error {
+	if setting.CredentialVersion == 0 {
+		return nil
+	}
+	item, err := pm.model.GetIntegrateCredential(setting.ID, setting.CredentialVersion)
+	if err != nil {
+		return err
+	}
+	item.Config = setting.Config
+	return pm.model.UpdateIntegrateCredential(item)
+}
+
+func formatCredentialSetting(setting *models.IntegrateSetting, item *models.IntegrateSettingCredential) *IntegrateSettingResponse {
+	candidate := *setting
+	candidate.Config = item.Config
+	candidate.CredentialVersion = item.Version
+	return formatSignalIntegrateSetting(&candidate, &Config{})
+}
+
+// nextCredentialVersion return the version after the latest one
+func nextCredentialVersion(items []*models.IntegrateSettingCredential) int {
+	next := 1
+	for _, item := range items {
+		if item.Version >= next {
+			next = item.Version + 1
+		}
+	}
+	return next
+}
+
+// previousCredential return the validated version activated most recently except the current one
+func previousCredential(items []*models.IntegrateSettingCredential, current int) *models.IntegrateSettingCredential {
+	var previous *models.IntegrateSettingCredential
+	for _, item := range items {
+		if item.Version == current || item.ActivatedAt == nil || item.Status != models.CredentialValidated {
+			continue
+		}
+		if previous == nil || item.ActivatedAt.After(*previous.ActivatedAt) {
+			previous = item
+		}
+	}
+	return previous
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestNextCredentialVersion(t *testing.T) {
+	if v := nextCredentialVersion(nil); v != 1 {
+		t.Errorf("expect 1, got %v", v)
+	}
+	items := []*models.IntegrateSettingCredential{{Version: 3}, {Version: 1}}
+	if v := nextCredentialVersion(items); v != 4 {
+		t.Errorf("expect 4, got %v", v)
+	}
+}
+
+func TestPreviousCredential(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	items := []*models.IntegrateSettingCredential{
+		{Version: 4, Status: models.CredentialPending},
+		{Version: 3, Status: models.CredentialValidated, Active: true, ActivatedAt: &now},
+		{Version: 2, Status: models.CredentialValidated},
+		{Version: 1, Status: models.CredentialValidated, ActivatedAt: &earlier},
+	}
+	previous := previousCredential(items, 3)
+	if previous == nil || previous.Version != 1 {
+		t.Fatalf("expect version 1, got %v", previous)
+	}
+	// rollback again switch back to the version activated later
+	later := now.Add(time.Hour)
+	items[3].ActivatedAt = &later
+	if previous := previousCredential(items, 1); previous == nil || previous.Version != 3 {
+		t.Fatalf("expect version 3, got %v", previous)
+	}
+	if previous := previousCredential(items[:1], 4); previous != nil {
+		t.Errorf("expect no previous version, got %v", previous.Version)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// DockerfileTemplateReq ..
+type DockerfileTemplateReq struct {
+	Name        string `json:"name,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// RenderDockerfile render the placeholders of dockerfile template, the placeholders are the same as compile command,
+// eg: COPY target/{{.AppName}}.jar /app.jar
+func RenderDockerfile(content string, data CompileCommandData) (string, error) {
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("Dockerfile 模板不能为空")
+	}
+	tpl, err := template.New("dockerfile").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("Dockerfile 模板格式错误: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Dockerfile 模板渲染失败: %v", err)
+	}
+	rendered := buf.String()
+	for _, line := range strings.Split(rendered, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], "FROM") {
+			return rendered, nil
+		}
+	}
+	return "", fmt.Errorf("Dockerfile 模板缺少 FROM 指令")
+}
+
+// GetDockerfileTemplates ..
+func (pm *SettingManager) GetDockerfileTemplates() ([]*models.DockerfileTemplate, error) {
+	return pm.model.GetDockerfileTemplates()
+}
+
+// verifyDockerfileTemplateReq ..
+func (pm *SettingManager) verifyDockerfileTemplateReq(request *DockerfileTemplateReq, templateID int64) error {
+	if !compileCommandNameRegexp.MatchString(request.Name) {
+		return fmt.Errorf("无效的 Dockerfile 模板名称: %v, 只能包含小写字母、数字、下划线和中划线", request.Name)
+	}
+	if exists, _ := pm.model.GetDockerfileTemplateByName(request.Name); exists != nil && exists.ID != templateID {
+		return fmt.Errorf("Dockerfile 模板 `%v` 已经存在", request.Name)
+	}
+	request.Language = normalizeLanguage(request.Language)
+	if request.Language != "" {
+		if exists, _ := pm.model.GetDockerfileTemplateByLanguage(request.Language); exists != nil && exists.ID != templateID {
+			return fmt.Errorf("语言 %v 已经使用 Dockerfile 模板 %v", request.Language, exists.Name)
+		}
+	}
+	_, err := RenderDockerfile(request.Content, sampleCompileCommandData)
+	return err
+}
+
+// CreateDockerfileTemplate ..
+func (pm *SettingManager) CreateDockerfileTemplate(request *DockerfileTemplateReq, creator string) (*models.DockerfileTemplate, error) {
+	if err := pm.verifyDockerfileTemplateReq(request, 0); err != nil {
+		return nil, err
+	}
+	item := &models.DockerfileTemplate{
+		Name:        request.Name,
+		Language:    request.Language,
+		Content:     request.Content,
+		Description: request.Description,
+		Creator:     creator,
+	}
+	if err := pm.model.CreateDockerfileTemplate(item); err != nil {
+		log.Log.Error("create dockerfile template: %v occur error: %s", request.Name, err.Error())
+		return nil, err
+	}
+	return item, nil
+}
+
+// UpdateDockerfileTemplate ..
+func (pm *SettingManager) UpdateDockerfileTemplate(templateID int64, request *DockerfileTemplateReq) (*models.DockerfileTemplate, error) {
+	item, err := pm.model.GetDockerfileTemplateByID(templateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.verifyDockerfileTemplateReq(request, templateID); err != nil {
+		return nil, err
+	}
+	item.Name = request.Name
+	item.Language = request.Language
+	item.Content = request.Content
+	item.Description = request.Description
+	return item, pm.model.UpdateDockerfileTemplate(item)
+}
+
+// DeleteDockerfileTemplate the template used by project apps can not be deleted
+func (pm *SettingManager) DeleteDockerfileTemplate(templateID int64) error {
+	item, err := pm.model.GetDockerfileTemplateByID(templateID)
+	if err != nil {
+		return err
+	}
+	count, err := dao.NewProjectModel().CountProjectAppsByDockerfileTemplate(item.Name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("Dockerfile 模板仍被 %v 个项目应用使用", count)
+	}
+	item.MarkDeleted()
+	return pm.model.UpdateDockerfileTemplate(item)
+}
+
+// ResolveDockerfile return the rendered dockerfile template chosen by the project app or the template of app
+// language, empty means there is no template for the app
+func (pm *SettingManager) ResolveDockerfile(projectApp *models.ProjectApp, data CompileCommandData) (string, error) {
+	var item *models.DockerfileTemplate
+	var err error
+	if projectApp != nil && projectApp.DockerfileTemplate != "" {
+		if item, err = pm.model.GetDockerfileTemplateByName(projectApp.DockerfileTemplate); err != nil {
+			return "", fmt.Errorf("Dockerfile 模板 %v 不存在", projectApp.DockerfileTemplate)
+		}
+	} else if language := normalizeLanguage(data.Language); language != "" {
+		item, _ = pm.model.GetDockerfileTemplateByLanguage(language)
+	}
+	if item == nil {
+		return "", nil
+	}
+	return RenderDockerfile(item.Content, data)
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"testing"
+)
+
+func TestRenderDockerfile(t *testing.T) {
+	data := CompileCommandData{AppName: "demo", Language: "go", BuildPath: "/", Branch: "master"}
+	got, err := RenderDockerfile("FROM alpine:3.15\nCOPY {{.AppName}} /usr/local/bin/app\n", data)
+	if err != nil || got != "FROM alpine:3.15\nCOPY demo /usr/local/bin/app\n" {
+		t.Fatalf("RenderDockerfile() = %q, %v", got, err)
+	}
+	for _, content := range []string{"", "COPY . /app", "FROM {{.Image}}", "from {{.AppName"} {
+		if _, err := RenderDockerfile(content, data); err == nil {
+			t.Fatalf("RenderDockerfile(%q) expect error", content)
+		}
+	}
+}
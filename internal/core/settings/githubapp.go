@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/pkg/githubapp"
+)
+
+// githubAppTokenSources cache the token source of every github app installation,
+// so the installation token was reused until it is going to expire.
+var githubAppTokenSources sync.Map
+
+// GithubAppTokenSource return the cached token source of the github app configured in conf
+func GithubAppTokenSource(conf *ScmAuthConf) (*githubapp.TokenSource, error) {
+	apiURL := githubapp.APIURL(conf.URL)
+	key := fmt.Sprintf("%s/%d/%d", apiURL, conf.AppID, conf.InstallationID)
+	if v, ok := githubAppTokenSources.Load(key); ok {
+		ts := v.(*githubapp.TokenSource)
+		// the private key may be rotated
+		if ts.PrivateKey() == conf.PrivateKey {
+			return ts, nil
+		}
+	}
+	ts, err := githubapp.NewTokenSource(apiURL, conf.AppID, conf.InstallationID, conf.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	githubAppTokenSources.Store(key, ts)
+	return ts, nil
+}
+
+// ResolveGithubAppToken replace the token with the installation access token when the github app was configured
+func ResolveGithubAppToken(scmType string, conf *ScmAuthConf) error {
+	if strings.ToLower(scmType) != constant.SCMGithub || conf.AppID == 0 {
+		return nil
+	}
+	ts, err := GithubAppTokenSource(conf)
+	if err != nil {
+		return err
+	}
+	token, err := ts.Token()
+	if err != nil {
+		return err
+	}
+	conf.User = githubapp.TokenUser
+	conf.Token = token
+	return nil
+}
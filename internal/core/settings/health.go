@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// ScmChecker verify the scm server is reachable and the token is valid
+type ScmChecker func(scmType string, conf *ScmAuthConf) (string, error)
+
+// IntegrateHealthRsp the health of integrate setting, the status is empty if never checked
+type IntegrateHealthRsp struct {
+	SettingID     int64      `json:"setting_id"`
+	Name          string     `json:"name"`
+	Type          string     `json:"type"`
+	OrgID         int64      `json:"org_id"`
+	Status        string     `json:"status"`
+	Latency       int64      `json:"latency"`
+	Message       string     `json:"message"`
+	Failures      int        `json:"failures"`
+	CheckedAt     *time.Time `json:"checked_at"`
+	LastHealthyAt *time.Time `json:"last_healthy_at"`
+}
+
+// the max length of health message stored
+const maxHealthMessage = 1024
+
+// integrateCheckTimeout the check is abandoned after timeout
+func integrateCheckTimeout() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("integratecheck::timeout", 10)) * time.Second
+}
+
+// integrateCheckSlow the reachable setting is degraded if the check is slower than it
+func integrateCheckSlow() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt("integratecheck::slow", 3000)) * time.Millisecond
+}
+
+// integrateHealthTypes the types of integrate settings which are checked
+func integrateHealthTypes() []string {
+	types := append([]string{}, constant.Integratetypes...)
+	return append(types, constant.ScmIntegratetypes...)
+}
+
+// integrateHealthStatus the status of check result
+func integrateHealthStatus(err error, latency, slow time.Duration) string {
+	switch {
+	case err != nil:
+		return models.IntegrateUnhealthy
+	case slow > 0 && latency > slow:
+		return models.IntegrateDegraded
+	default:
+		return models.IntegrateHealthy
+	}
+}
+
+// checkIntegrateSetting return the message of check, the setting is unhealthy if the check does not finish before timeout
+func (pm *SettingManager) checkIntegrateSetting(setting *IntegrateSettingResponse, scmChecker ScmChecker, timeout time.Duration) (string, error) {
+	result := make(chan VerifyResponse, 1)
+	go func() {
+		if !utils.Contains(constant.ScmIntegratetypes, setting.Type) {
+			result <- pm.VerifyIntegrateSetting(&setting.IntegrateSettingReq)
+			return
+		}
+		conf, ok := setting.Config.(*ScmAuthConf)
+		if !ok {
+			result <- VerifyResponse{Error: fmt.Errorf("parse scm config error")}
+			return
+		}
+		if err := ResolveGithubAppToken(setting.Type, conf); err != nil {
+			result <- VerifyResponse{Error: err}
+			return
+		}
+		msg, err := scmChecker(setting.Type, conf)
+		result <- VerifyResponse{Msg: msg, Error: err}
+	}()
+	select {
+	case rsp := <-result:
+		return rsp.Msg, rsp.Error
+	case <-time.After(timeout):
+		return "", fmt.Errorf("检查超时(%v)", timeout)
+	}
+}
+
+// CheckIntegrateSetting check the integrate setting and save the result
+func (pm *SettingManager) CheckIntegrateSetting(settingID int64, scmChecker ScmChecker) (*IntegrateHealthRsp, error) {
+	setting, err := pm.GetIntegrateSettingByID(settingID)
+	if err != nil {
+		return nil, err
+	}
+	return pm.recordIntegrateHealth(setting, scmChecker)
+}
+
+// CheckIntegrateSettings check all the integrate settings concurrently
+func (pm *SettingManager) CheckIntegrateSettings(scmChecker ScmChecker) ([]*IntegrateHealthRsp, error) {
+	items, err := pm.GetIntegrateSettings(integrateHealthTypes())
+	if err != nil {
+		return nil, err
+	}
+	rsp := make([]*IntegrateHealthRsp, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item *IntegrateSettingResponse) {
+			defer wg.Done()
+			health, err := pm.recordIntegrateHealth(item, scmChecker)
+			if err != nil {
+				log.Log.Error("save health of integrate setting: %v occur error: %s", item.Name, err.Error())
+				return
+			}
+			rsp[i] = health
+		}(i, item)
+	}
+	wg.Wait()
+	checked := []*IntegrateHealthRsp{}
+	for _, item := range rsp {
+		if item != nil {
+			checked = append(checked, item)
+		}
+	}
+	return checked, nil
+}
+
+func (pm *SettingManager) recordIntegrateHealth(setting *IntegrateSettingResponse, scmChecker ScmChecker) (*IntegrateHealthRsp, error) {
+	start := time.Now()
+	msg, checkErr := pm.checkIntegrateSetting(setting, scmChecker, integrateCheckTimeout())
+	latency := time.Since(start)
+
+	health, err := pm.model.GetIntegrateSettingHealth(setting.ID)
+	if err != nil {
+		if err != orm.ErrNoRows {
+			return nil, err
+		}
+		health = &models.IntegrateSettingHealth{Addons: models.NewAddons(), SettingID: setting.ID}
+	}
+	now := time.Now()
+	status := integrateHealthStatus(checkErr, latency, integrateCheckSlow())
+	if status == models.IntegrateUnhealthy {
+		msg = checkErr.Error()
+		health.Failures++
+		if health.Status != models.IntegrateUnhealthy {
+			log.Log.Warn("integrate setting: %v(%v) becomes unhealthy: %s", setting.Name, setting.Type, msg)
+		}
+	} else {
+		health.Failures = 0
+		health.LastHealthyAt = &now
+	}
+	if len(msg) > maxHealthMessage {
+		msg = msg[:maxHealthMessage]
+	}
+	health.Status = status
+	health.Latency = latency.Milliseconds()
+	health.Message = msg
+	health.CheckedAt = &now
+	if err := pm.model.SaveIntegrateSettingHealth(health); err != nil {
+		return nil, err
+	}
+	return formatIntegrateHealth(setting, health), nil
+}
+
+// GetIntegrateSettingHealths return the health of the integrate settings of organizations,
+// only the degraded and unhealthy settings are returned if degradedOnly
+func (pm *SettingManager) GetIntegrateSettingHealths(orgIDs []int64, degradedOnly bool) ([]*IntegrateHealthRsp, error) {
+	items, err := pm.GetOrgIntegrateSettings(integrateHealthTypes(), orgIDs)
+	if err != nil {
+		return nil, err
+	}
+	healths, err := pm.model.GetIntegrateSettingHealths()
+	if err != nil {
+		return nil, err
+	}
+	settingHealths := map[int64]*models.IntegrateSettingHealth{}
+	for _, health := range healths {
+		settingHealths[health.SettingID] = health
+	}
+	rsp := []*IntegrateHealthRsp{}
+	for _, item := range items {
+		health := formatIntegrateHealth(item, settingHealths[item.ID])
+		if degradedOnly && (health.Status == "" || health.Status == models.IntegrateHealthy) {
+			continue
+		}
+		rsp = append(rsp, health)
+	}
+	return rsp, nil
+}
+
+func formatIntegrateHealth(setting *IntegrateSettingResponse, health *models.IntegrateSettingHealth) *IntegrateHealthRsp {
+	rsp := &IntegrateHealthRsp{
+		SettingID: setting.ID,
+		Name:      setting.Name,
+		Type:      setting.Type,
+		OrgID:     setting.OrgID,
+	}
+	if health != nil {
+		rsp.Status = health.Status
+		rsp.Latency = health.Latency
+		rsp.Message = health.Message
+		rsp.Failures = health.Failures
+		rsp.CheckedAt = health.CheckedAt
+		rsp.LastHealthyAt = health.LastHealthyAt
+	}
+	return rsp
+}
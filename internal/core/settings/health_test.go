@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestIntegrateHealthStatus(t *testing.T) {
+	slow := time.Second
+	tests := []struct {
+		err     error
+		latency time.Duration
+		want    string
+	}{
+		{latency: 100 * time.Millisecond, want: models.IntegrateHealthy},
+		{latency: 2 * time.Second, want: models.IntegrateDegraded},
+		{err: errors.New("401 unauthorized"), latency: 100 * time.Millisecond, want: models.IntegrateUnhealthy},
+	}
+	for _, test := range tests {
+		if got := integrateHealthStatus(test.err, test.latency, slow); got != test.want {
+			t.Fatalf("integrateHealthStatus(%v, %v) = %v, want %v", test.err, test.latency, got, test.want)
+		}
+	}
+}
+
+func TestCheckIntegrateSettingTimeout(t *testing.T) {
+	pm := &SettingManager{}
+	setting := &IntegrateSettingResponse{
+		IntegrateSettingReq: IntegrateSettingReq{Name: "gitlab", Type: "gitlab", Config: &ScmAuthConf{}},
+	}
+	checker := func(delay time.Duration) ScmChecker {
+		return func(scmType string, conf *ScmAuthConf) (string, error) {
+			time.Sleep(delay)
+			return "Connected to gitlab as root", nil
+		}
+	}
+	if msg, err := pm.checkIntegrateSetting(setting, checker(0), time.Second); err != nil || msg != "Connected to gitlab as root" {
+		t.Fatalf("check scm setting = %q, %v", msg, err)
+	}
+	if _, err := pm.checkIntegrateSetting(setting, checker(time.Second), 10*time.Millisecond); err == nil {
+		t.Fatalf("the check slower than timeout should fail")
+	}
+}
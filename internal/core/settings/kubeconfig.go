@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeConfigInfo the uploaded kubeconfig, the config of kubernetes setting is filled with it
+type KubeConfigInfo struct {
+	KubeConfig
+	Contexts []string `json:"contexts"`
+}
+
+// ParseKubeConfig validate the uploaded kubeconfig and return the server of the context,
+// the current context is used if context is empty
+func ParseKubeConfig(content []byte, context string) (*KubeConfigInfo, error) {
+	rawConfig, err := clientcmd.Load(content)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig 格式错误: %v", err.Error())
+	}
+	info := &KubeConfigInfo{
+		KubeConfig: KubeConfig{
+			Conf:    string(content),
+			Type:    KubernetesConfig,
+			Context: context,
+		},
+		Contexts: []string{},
+	}
+	for name := range rawConfig.Contexts {
+		info.Contexts = append(info.Contexts, name)
+	}
+	sort.Strings(info.Contexts)
+	restConfig, err := info.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	info.URL = restConfig.Host
+	return info, nil
+}
+
+// RESTConfig the client config of kubernetes setting
+func (kube *KubeConfig) RESTConfig() (*rest.Config, error) {
+	switch kube.Type {
+	case KubernetesConfig, "":
+		rawConfig, err := clientcmd.Load([]byte(kube.Conf))
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig 格式错误: %v", err.Error())
+		}
+		if kube.Context != "" {
+			if _, ok := rawConfig.Contexts[kube.Context]; !ok {
+				return nil, fmt.Errorf("kubeconfig 中不存在 context: %v", kube.Context)
+			}
+		}
+		restConfig, err := clientcmd.NewNonInteractiveClientConfig(*rawConfig, kube.Context, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig 无效: %v", err.Error())
+		}
+		return restConfig, kube.applyTLS(restConfig)
+	case KubernetesToken:
+		if kube.URL == "" || kube.Conf == "" {
+			return nil, fmt.Errorf("使用 ServiceAccount Token 认证时，集群地址和 Token 不能为空")
+		}
+		if _, err := url.ParseRequestURI(kube.URL); err != nil {
+			return nil, fmt.Errorf("集群地址 %v 无效", kube.URL)
+		}
+		tlsConfig := rest.TLSClientConfig{
+			Insecure:   kube.Insecure,
+			ServerName: kube.TLSServerName,
+		}
+		if !kube.Insecure && kube.CACert != "" {
+			if !x509.NewCertPool().AppendCertsFromPEM([]byte(kube.CACert)) {
+				return nil, fmt.Errorf("CA 证书不是有效的 PEM 格式")
+			}
+			tlsConfig.CAData = []byte(kube.CACert)
+		}
+		restConfig := &rest.Config{
+			Host:            kube.URL,
+			BearerToken:     kube.Conf,
+			TLSClientConfig: tlsConfig,
+		}
+		return restConfig, kube.applyTLS(restConfig)
+	default:
+		return nil, fmt.Errorf("不支持的 kubernetes 认证方式: %v", kube.Type)
+	}
+}
+
+// applyTLS add the custom CA bundle/client certificate of the tls options into the rest config
+func (kube *KubeConfig) applyTLS(restConfig *rest.Config) error {
+	if kube.TLS.empty() {
+		return nil
+	}
+	if _, err := kube.TLS.ClientConfig(); err != nil {
+		return err
+	}
+	if kube.TLS.InsecureSkipVerify {
+		// the root certificates are not allowed with the insecure flag
+		restConfig.Insecure = true
+		restConfig.CAData = nil
+		restConfig.CAFile = ""
+	} else if kube.TLS.CACert != "" {
+		if restConfig.CAFile != "" {
+			caData, err := ioutil.ReadFile(restConfig.CAFile)
+			if err != nil {
+				return fmt.Errorf("读取 CA 证书文件 %v 失败: %v", restConfig.CAFile, err)
+			}
+			restConfig.CAData, restConfig.CAFile = caData, ""
+		}
+		restConfig.CAData = append(append(restConfig.CAData, '\n'), []byte(kube.TLS.CACert)...)
+	}
+	if kube.TLS.ClientCert != "" {
+		restConfig.CertData, restConfig.CertFile = []byte(kube.TLS.ClientCert), ""
+		restConfig.KeyData, restConfig.KeyFile = []byte(kube.TLS.ClientKey), ""
+	}
+	return nil
+}
+
+// verifyKubeConfig connect to the cluster, the credential must be able to get the version and list the namespaces
+func verifyKubeConfig(kube *KubeConfig) (string, error) {
+	restConfig, err := kube.RESTConfig()
+	if err != nil {
+		return "", err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return "", err
+	}
+	k8sVersion, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	namespaces, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("获取 namespace 列表失败，请确认凭据的权限: %v", err.Error())
+	}
+	return fmt.Sprintf("Connected to Kubernetes %s, %d namespaces", k8sVersion.GitVersion, len(namespaces.Items)), nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"testing"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+users:
+- name: admin
+  user:
+    token: abc
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+current-context: dev
+`
+
+func TestParseKubeConfig(t *testing.T) {
+	info, err := ParseKubeConfig([]byte(testKubeConfig), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URL != "https://dev.example.com:6443" || len(info.Contexts) != 2 {
+		t.Errorf("unexpected kubeconfig info: %v, %v", info.URL, info.Contexts)
+	}
+	info, err = ParseKubeConfig([]byte(testKubeConfig), "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URL != "https://prod.example.com:6443" {
+		t.Errorf("expect the server of prod context, got %v", info.URL)
+	}
+	if _, err := ParseKubeConfig([]byte(testKubeConfig), "test"); err == nil {
+		t.Error("expect error of unknown context")
+	}
+	if _, err := ParseKubeConfig([]byte("{"), ""); err == nil {
+		t.Error("expect error of invalid kubeconfig")
+	}
+}
+
+func TestTokenRESTConfig(t *testing.T) {
+	kube := &KubeConfig{Type: KubernetesToken, URL: "https://k8s.example.com:6443", Conf: "token", TLSServerName: "k8s"}
+	restConfig, err := kube.RESTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Insecure || restConfig.ServerName != "k8s" || restConfig.BearerToken != "token" {
+		t.Errorf("unexpected rest config: %+v", restConfig)
+	}
+	kube.CACert = "invalid"
+	if _, err := kube.RESTConfig(); err == nil {
+		t.Error("expect error of invalid ca cert")
+	}
+	kube.Insecure = true
+	if restConfig, err := kube.RESTConfig(); err != nil || !restConfig.Insecure || len(restConfig.CAData) > 0 {
+		t.Errorf("expect insecure config without ca, got: %v", err)
+	}
+	if _, err := (&KubeConfig{Type: KubernetesToken, URL: "https://k8s.example.com"}).RESTConfig(); err == nil {
+		t.Error("expect error of empty token")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"strings"
+
+	"github.com/astaxie/beego"
+)
+
+// the network of build pods without direct internet access, the proxy is injected into the env of build pods,
+// and the images are pulled from the mirrors of their registries
+var (
+	httpProxy    = beego.AppConfig.DefaultString("network::http_proxy", "")
+	httpsProxy   = beego.AppConfig.DefaultString("network::https_proxy", "")
+	noProxy      = beego.AppConfig.DefaultString("network::no_proxy", "")
+	imageMirrors = ParseImageMirrors(beego.AppConfig.DefaultString("network::image_mirrors", ""))
+)
+
+// the registry of the image without registry host
+const defaultImageRegistry = "docker.io"
+
+// ProxyEnv the proxy env var of build pods
+type ProxyEnv struct {
+	Key   string
+	Value string
+}
+
+// ProxyEnvVars return the proxy env vars of build pods, both upper and lower case are set since the tools read either
+func ProxyEnvVars() []ProxyEnv {
+	envs := []ProxyEnv{}
+	for _, item := range []ProxyEnv{
+		{Key: "HTTP_PROXY", Value: httpProxy},
+		{Key: "HTTPS_PROXY", Value: httpsProxy},
+		{Key: "NO_PROXY", Value: noProxy},
+	} {
+		if item.Value == "" {
+			continue
+		}
+		envs = append(envs, item, ProxyEnv{Key: strings.ToLower(item.Key), Value: item.Value})
+	}
+	return envs
+}
+
+// ParseImageMirrors parse the comma separated registry=mirror prefix, eg: docker.io=harbor.local/dockerhub
+func ParseImageMirrors(value string) map[string]string {
+	mirrors := map[string]string{}
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		mirrors[strings.TrimSpace(parts[0])] = strings.TrimSuffix(strings.TrimSpace(parts[1]), "/")
+	}
+	return mirrors
+}
+
+// MirrorImage return the image pulled from the mirror of its registry, the image is unchanged without mirror
+func MirrorImage(image string) string {
+	return mirrorImage(imageMirrors, image)
+}
+
+func mirrorImage(mirrors map[string]string, image string) string {
+	if len(mirrors) == 0 || image == "" {
+		return image
+	}
+	registry, repository := defaultImageRegistry, image
+	if index := strings.Index(image, "/"); index > 0 {
+		host := image[:index]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry, repository = host, image[index+1:]
+		}
+	}
+	mirror, ok := mirrors[registry]
+	if !ok {
+		return image
+	}
+	if registry == defaultImageRegistry && !strings.Contains(repository, "/") {
+		// the official images of docker hub are in library
+		repository = "library/" + repository
+	}
+	return mirror + "/" + repository
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMirrorImage(t *testing.T) {
+	mirrors := ParseImageMirrors(" docker.io=harbor.local/dockerhub/, gcr.io=harbor.local/gcr,invalid, quay.io= ")
+	if !reflect.DeepEqual(mirrors, map[string]string{"docker.io": "harbor.local/dockerhub", "gcr.io": "harbor.local/gcr"}) {
+		t.Fatalf("unexpected image mirrors: %v", mirrors)
+	}
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "node:12.12-alpine", want: "harbor.local/dockerhub/library/node:12.12-alpine"},
+		{image: "colynn/kaniko-executor:debug", want: "harbor.local/dockerhub/colynn/kaniko-executor:debug"},
+		{image: "docker.io/jenkins/inbound-agent", want: "harbor.local/dockerhub/jenkins/inbound-agent"},
+		{image: "gcr.io/go-containerregistry/crane:debug", want: "harbor.local/gcr/go-containerregistry/crane:debug"},
+		{image: "quay.io/prometheus/busybox", want: "quay.io/prometheus/busybox"},
+		{image: "localhost:5000/demo", want: "localhost:5000/demo"},
+	}
+	for _, tt := range tests {
+		if got := mirrorImage(mirrors, tt.image); got != tt.want {
+			t.Fatalf("mirror image of %v = %v, want %v", tt.image, got, tt.want)
+		}
+	}
+	if got := mirrorImage(nil, "node:12"); got != "node:12" {
+		t.Fatalf("image without mirrors changed: %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/registry"
+)
+
+// registryProviders cache the registry providers, so the short-lived credential of cloud registry was reused
+var registryProviders sync.Map
+
+// Provider return the registry provider selected by the registry type
+func (c *RegistryConfig) Provider() (registry.Provider, error) {
+	tlsOptions := TLSConfig{}
+	if c.TLS != nil {
+		tlsOptions = *c.TLS
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%v|%+v", c.Type, c.URL, c.User, c.Password, c.Region, c.IsHttps, tlsOptions)
+	if v, ok := registryProviders.Load(key); ok {
+		return v.(registry.Provider), nil
+	}
+	tlsConfig, err := c.TLS.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := registry.New(registry.Options{
+		Type:     c.Type,
+		URL:      c.URL,
+		User:     c.User,
+		Password: c.Password,
+		Region:   c.Region,
+		Insecure: !c.IsHttps,
+		TLS:      tlsConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+	registryProviders.Store(key, provider)
+	return provider, nil
+}
+
+// Credential return the username and password used to login registry
+func (c *RegistryConfig) Credential() (string, string, error) {
+	provider, err := c.Provider()
+	if err != nil {
+		return "", "", err
+	}
+	return provider.Credential()
+}
+
+// Verify check the registry connection and credential
+func (c *RegistryConfig) Verify() error {
+	switch strings.ToLower(c.Type) {
+	case "", registry.TypeGeneric, registry.TypeHarbor:
+		client, err := c.TLS.HTTPClient(30 * time.Second)
+		if err != nil {
+			return err
+		}
+		return tryLoginRegistry(client, c.URL, c.User, c.Password, !c.IsHttps)
+	}
+	provider, err := c.Provider()
+	if err != nil {
+		return err
+	}
+	return provider.Ping()
+}
@@ -8,6 +8,11 @@ import (
 )
 
 func TryLoginRegistry(basicUrl, username, password string, insecure bool) error {
+	return tryLoginRegistry(http.DefaultClient, basicUrl, username, password, insecure)
+}
+
+// tryLoginRegistry login the registry by the client, which may use the tls options of the registry
+func tryLoginRegistry(client *http.Client, basicUrl, username, password string, insecure bool) error {
 	var schema string
 	if insecure {
 		schema = "http"
@@ -15,12 +20,12 @@ func TryLoginRegistry(basicUrl, username, password string, insecure bool) error
 		schema = "https"
 	}
 	hostUrl := fmt.Sprintf("%s://%s", schema, strings.TrimRight(basicUrl, "/"))
-	resp, err := http.Get(hostUrl)
+	resp, err := client.Get(hostUrl)
 	if err != nil {
 		return errors.New(fmt.Sprintf("%s访问异常:%s", hostUrl, err.Error()))
 	}
 	url := fmt.Sprintf("%s/v2/", hostUrl)
-	resp, err = http.Get(url)
+	resp, err = client.Get(url)
 	if err != nil {
 		return errors.New(fmt.Sprintf("%s访问异常:%s", url, err.Error()))
 	}
@@ -65,7 +70,6 @@ func TryLoginRegistry(basicUrl, username, password string, insecure bool) error
 		return errors.New("账号或密码不正确")
 	}
 	req.SetBasicAuth(username, password)
-	client := http.Client{}
 	resp, err = client.Do(req)
 	if err != nil {
 		return err
@@ -22,18 +22,16 @@ import (
 	"strings"
 	"time"
 
-	"k8s.io/client-go/rest"
-
 	"github.com/go-atomci/atomci/constant"
 	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/argocd"
+	"github.com/go-atomci/atomci/pkg/eventbus"
+	"github.com/go-atomci/atomci/pkg/jira"
+	"github.com/go-atomci/atomci/pkg/tlsutil"
 	"github.com/go-atomci/atomci/utils/query"
 	"github.com/go-atomci/atomci/utils/validate"
-
-	"github.com/go-atomci/workflow/jenkins"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 // SettingManager ...
@@ -48,6 +46,8 @@ type IntegrateSettingResponse struct {
 	CreateAt *time.Time `json:"create_at,omitempty"`
 	UpdateAt *time.Time `json:"update_at,omitempty"`
 	ID       int64      `json:"id,omitempty"`
+	// CredentialVersion the credential version of config
+	CredentialVersion int `json:"credential_version,omitempty"`
 }
 
 type ScmIntegrateSetting struct {
@@ -69,6 +69,8 @@ type IntegrateSettingReq struct {
 	Description string      `json:"description"`
 	Config      interface{} `json:"config,omitempty"`
 	Type        string      `json:"type"`
+	// OrgID the organization of setting, only used when create, 0 means shared by all organizations
+	OrgID int64 `json:"org_id"`
 }
 
 // const variables
@@ -76,6 +78,11 @@ const (
 	KubernetesType = "kubernetes"
 	RegistryType   = "registry"
 	JenkinsType    = "jenkins"
+	ArgoCDType     = "argocd"
+	JiraType       = "jira"
+	KafkaType      = "kafka"
+	NATSType       = "nats"
+	CosignType     = "cosign"
 
 	KubernetesConfig = "kubernetesConfig"
 	KubernetesToken  = "kubernetesToken"
@@ -92,12 +99,27 @@ type KubeConfig struct {
 	URL  string `json:"url,omitempty"`
 	Conf string `json:"conf,omitempty"`
 	Type string `json:"type,omitempty"`
+	// Context the context of kubeconfig, default is the current context
+	Context string `json:"context,omitempty"`
+	// CACert/Insecure/TLSServerName the tls verification of service account token,
+	// the system root CAs are used if CACert is empty
+	CACert        string `json:"ca_cert,omitempty"`
+	Insecure      bool   `json:"insecure,omitempty"`
+	TLSServerName string `json:"tls_server_name,omitempty"`
+	// TLS the custom CA bundle/client certificate of the cluster, which are added to the tls config of kubeconfig
+	TLS *TLSConfig `json:"tls,omitempty"`
 }
 type RegistryConfig struct {
 	BaseConfig
 	Name     string `json:"name,omitempty"`
 	Password string `json:"password,omitempty"`
 	IsHttps  bool   `json:"isHttps,omitempty"`
+	// Type registry provider: generic/harbor/dockerhub/acr/ecr/gcr, default is generic docker registry
+	Type string `json:"type,omitempty"`
+	// Region aws region of ecr, parsed from url when empty
+	Region string `json:"region,omitempty"`
+	// TLS the tls options of the registry which uses the private CA
+	TLS *TLSConfig `json:"tls,omitempty"`
 }
 
 type ScmBaseConfig struct {
@@ -108,6 +130,13 @@ type ScmBaseConfig struct {
 type ScmAuthConf struct {
 	ScmBaseConfig
 	User string `json:"user,omitempty"`
+	// AppID, InstallationID, PrivateKey github app authorization, the short-lived installation token
+	// was used instead of the personal access token when they were configured
+	AppID          int64  `json:"app_id,omitempty"`
+	InstallationID int64  `json:"installation_id,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"`
+	// TLS the tls options of the self-hosted scm server which uses the private CA
+	TLS *TLSConfig `json:"tls,omitempty"`
 }
 
 type JenkinsConfig struct {
@@ -115,6 +144,99 @@ type JenkinsConfig struct {
 	Token     string `json:"token,omitempty"`
 	Namespace string `json:"namespace,omitempty"`
 	WorkSpace string `json:"workspace,omitempty"`
+	// BuildCluster kubernetes integrate setting id which the build pods run on by default,
+	// 0 means the kubernetes cloud configured in jenkins
+	BuildCluster int64 `json:"build_cluster,omitempty"`
+	// AgentURL/AgentTunnel the jenkins url and tunnel which the agents of build cluster connect to
+	AgentURL    string `json:"agent_url,omitempty"`
+	AgentTunnel string `json:"agent_tunnel,omitempty"`
+	// Driver the external workflow driver which serves the ci server, empty means jenkins,
+	// runner means the self hosted runners, which need no url, user and token
+	Driver string `json:"driver,omitempty"`
+	// TLS the tls options of the jenkins which uses the private CA
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// ArgoCDConfig argo cd server and the git config repo which store the rendered arrange
+type ArgoCDConfig struct {
+	URL   string `json:"url,omitempty"`
+	Token string `json:"token,omitempty"`
+	// Insecure skip the verification of server certificate, the same as the insecure_skip_verify of TLS
+	Insecure bool `json:"insecure,omitempty"`
+	// TLS the tls options of the argo cd which uses the private CA
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// Project argo cd project, default is `default`
+	Project string `json:"project,omitempty"`
+	// DestServer kubernetes api server registered in argo cd, default is `https://kubernetes.default.svc`
+	DestServer string `json:"dest_server,omitempty"`
+	// RepoID scm integrate setting id of the git config repo
+	RepoID     int64  `json:"repo_id,omitempty"`
+	RepoURL    string `json:"repo_url,omitempty"`
+	RepoBranch string `json:"repo_branch,omitempty"`
+	// RepoPath the dir prefix of the arrange files in git config repo, default is `atomci`
+	RepoPath string `json:"repo_path,omitempty"`
+}
+
+// Client return the argo cd client with the dedicated transport of the tls options
+func (c *ArgoCDConfig) Client() (*argocd.Client, error) {
+	tlsConfig, err := withInsecure(c.TLS, c.Insecure).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return argocd.NewClient(c.URL, c.Token, tlsConfig), nil
+}
+
+// JiraConfig jira server which the issues mentioned in commit messages belong to
+type JiraConfig struct {
+	URL   string `json:"url,omitempty"`
+	User  string `json:"user,omitempty"`
+	Token string `json:"token,omitempty"`
+	// Insecure skip the verification of server certificate, the same as the insecure_skip_verify of TLS
+	Insecure bool `json:"insecure,omitempty"`
+	// TLS the tls options of the jira which uses the private CA
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// ProjectKeys only link the issues of these jira projects, empty means all
+	ProjectKeys []string `json:"project_keys,omitempty"`
+	// ReleaseTransition the transition or target status name when issues deployed to the env, default is `Released`
+	ReleaseTransition string `json:"release_transition,omitempty"`
+}
+
+// Client return the jira client with the dedicated transport of the tls options
+func (c *JiraConfig) Client() (*jira.Client, error) {
+	tlsConfig, err := withInsecure(c.TLS, c.Insecure).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return jira.NewClient(c.URL, c.User, c.Token, tlsConfig), nil
+}
+
+// KafkaConfig kafka rest proxy which the pipeline events are produced to
+type KafkaConfig struct {
+	URL      string `json:"url,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	// Topic the topic of pipeline events, default is `atomci.pipeline.events`
+	Topic string `json:"topic,omitempty"`
+}
+
+// NATSConfig nats server which the pipeline events are published to
+type NATSConfig struct {
+	URL      string `json:"url,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	// Subject the subject prefix of pipeline events, the event type is appended, default is `atomci.pipeline`
+	Subject string `json:"subject,omitempty"`
+}
+
+// CosignConfig the key pair generated by `cosign generate-key-pair`, the images are signed by the private key
+// after build, and verified by the public key before deploy to the envs which require signature.
+type CosignConfig struct {
+	PrivateKey string `json:"private_key,omitempty"`
+	Password   string `json:"password,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
 }
 
 func (intergrateItem *IntegrateSettingReq) String() (string, error) {
@@ -137,7 +259,38 @@ func (config *Config) Struct(sc string, settingType string) (interface{}, error)
 		registry := &RegistryConfig{}
 		err := json.Unmarshal([]byte(sc), registry)
 		return registry, err
-	case "gitlab":
+	case "argocd":
+		argoCDConf := &ArgoCDConfig{
+			Project:    "default",
+			DestServer: "https://kubernetes.default.svc",
+			RepoBranch: "master",
+			RepoPath:   "atomci",
+		}
+		err := json.Unmarshal([]byte(sc), argoCDConf)
+		return argoCDConf, err
+	case "jira":
+		jiraConf := &JiraConfig{
+			ReleaseTransition: "Released",
+		}
+		err := json.Unmarshal([]byte(sc), jiraConf)
+		return jiraConf, err
+	case "kafka":
+		kafkaConf := &KafkaConfig{
+			Topic: "atomci.pipeline.events",
+		}
+		err := json.Unmarshal([]byte(sc), kafkaConf)
+		return kafkaConf, err
+	case "nats":
+		natsConf := &NATSConfig{
+			Subject: "atomci.pipeline",
+		}
+		err := json.Unmarshal([]byte(sc), natsConf)
+		return natsConf, err
+	case "cosign":
+		cosignConf := &CosignConfig{}
+		err := json.Unmarshal([]byte(sc), cosignConf)
+		return cosignConf, err
+	case "gitlab", "gogs", "bitbucket-server":
 		scmConf := &ScmAuthConf{}
 		err := json.Unmarshal([]byte(sc), scmConf)
 		return scmConf, err
@@ -146,6 +299,12 @@ func (config *Config) Struct(sc string, settingType string) (interface{}, error)
 		scmConf.User = "oauth2"
 		err := json.Unmarshal([]byte(sc), scmConf)
 		return scmConf, err
+	case "bitbucket":
+		// bitbucket cloud access token must clone with the user `x-token-auth`
+		scmConf := &ScmAuthConf{}
+		scmConf.User = "x-token-auth"
+		err := json.Unmarshal([]byte(sc), scmConf)
+		return scmConf, err
 	default:
 		log.Log.Warn("this settings type %s is not support, return origin string", settingType)
 		return sc, nil
@@ -161,7 +320,12 @@ func NewSettingManager() *SettingManager {
 
 // GetIntegrateSettings ..
 func (pm *SettingManager) GetIntegrateSettings(integrateTypes []string) ([]*IntegrateSettingResponse, error) {
-	items, err := pm.model.GetIntegrateSettings(integrateTypes)
+	return pm.GetOrgIntegrateSettings(integrateTypes, nil)
+}
+
+// GetOrgIntegrateSettings return the settings of the organizations, all the settings are returned if orgIDs is nil
+func (pm *SettingManager) GetOrgIntegrateSettings(integrateTypes []string, orgIDs []int64) ([]*IntegrateSettingResponse, error) {
+	items, err := pm.model.GetIntegrateSettings(integrateTypes, orgIDs)
 	if err != nil {
 		log.Log.Error("get interate settings error: %s", err.Error())
 		return nil, err
@@ -191,18 +355,65 @@ func (pm *SettingManager) GetSCMIntegrateSettinByID(id int64) (*ScmIntegrateSett
 		Type: resp.Type,
 	}
 	scmCONF := getScmConf(resp.Type, resp.Config)
+	if err := ResolveGithubAppToken(resp.Type, &scmCONF); err != nil {
+		log.Log.Error("get github app installation token of scm: %v occur error: %s", resp.Name, err.Error())
+		return nil, err
+	}
 	scmResp.ScmAuthConf = scmCONF
 	return scmResp, nil
 }
 
+// GetArgoCDIntegrateSettingByID ..
+func (pm *SettingManager) GetArgoCDIntegrateSettingByID(id int64) (*ArgoCDConfig, error) {
+	resp, err := pm.GetIntegrateSettingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	argoCDConf, ok := resp.Config.(*ArgoCDConfig)
+	if !ok || resp.Type != ArgoCDType {
+		return nil, fmt.Errorf("集成配置 %v 不是有效的 Argo CD 配置", resp.Name)
+	}
+	return argoCDConf, nil
+}
+
+// GetJiraIntegrateSettingByID ..
+func (pm *SettingManager) GetJiraIntegrateSettingByID(id int64) (*JiraConfig, error) {
+	resp, err := pm.GetIntegrateSettingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	jiraConf, ok := resp.Config.(*JiraConfig)
+	if !ok || resp.Type != JiraType {
+		return nil, fmt.Errorf("集成配置 %v 不是有效的 Jira 配置", resp.Name)
+	}
+	return jiraConf, nil
+}
+
+// GetCosignIntegrateSettingByID ..
+func (pm *SettingManager) GetCosignIntegrateSettingByID(id int64) (*CosignConfig, error) {
+	resp, err := pm.GetIntegrateSettingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	cosignConf, ok := resp.Config.(*CosignConfig)
+	if !ok || resp.Type != CosignType {
+		return nil, fmt.Errorf("集成配置 %v 不是有效的 Cosign 配置", resp.Name)
+	}
+	return cosignConf, nil
+}
+
 func getScmConf(scmType string, config interface{}) ScmAuthConf {
 	scmCONF := ScmAuthConf{}
 	switch strings.ToLower(scmType) {
-	case constant.SCMGitlab, constant.SCMGitea, constant.SCMGitee, constant.SCMGithub:
+	case constant.SCMGitlab, constant.SCMGitea, constant.SCMGitee, constant.SCMGithub, constant.SCMGogs,
+		constant.SCMBitbucket, constant.SCMBitbucketServer:
 		if conf, ok := config.(*ScmAuthConf); ok {
 			scmCONF.URL = conf.URL
 			scmCONF.User = conf.User
 			scmCONF.Token = conf.Token
+			scmCONF.AppID = conf.AppID
+			scmCONF.InstallationID = conf.InstallationID
+			scmCONF.PrivateKey = conf.PrivateKey
 		} else {
 			log.Log.Error("parse type: %s conf error", constant.SCMGitlab)
 		}
@@ -221,8 +432,8 @@ func (pm *SettingManager) GetIntegrateSettingByName(name, integrateType string)
 }
 
 // GetIntegrateSettingsByPagination ..
-func (pm *SettingManager) GetIntegrateSettingsByPagination(filter *query.FilterQuery, intergrateTypes []string) (*query.QueryResult, error) {
-	queryResult, settingsList, err := pm.model.GetIntegrateSettingsByPagination(filter, intergrateTypes)
+func (pm *SettingManager) GetIntegrateSettingsByPagination(filter *query.FilterQuery, intergrateTypes []string, orgIDs []int64) (*query.QueryResult, error) {
+	queryResult, settingsList, err := pm.model.GetIntegrateSettingsByPagination(filter, intergrateTypes, orgIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -261,10 +472,23 @@ func (pm *SettingManager) UpdateIntegrateSetting(request *IntegrateSettingReq, s
 		log.Log.Error("json marshal error: %s", err.Error())
 		return err
 	}
+	if _, err := verifyIntegrateTLS(stageModel.Type, config); err != nil {
+		return err
+	}
+	if err := pm.verifyHostTLSConflict(stageModel.ID, stageModel.Type, config); err != nil {
+		return err
+	}
 
 	stageModel.CryptoConfig(config)
 
-	return pm.model.UpdateIntegrateSetting(stageModel)
+	if err := pm.model.UpdateIntegrateSetting(stageModel); err != nil {
+		return err
+	}
+	registerIntegrateTLS(stageModel.ID, stageModel.Type, config)
+	if stageModel.Type == KubernetesType {
+		pm.detectClusterCapability(stageModel.ID)
+	}
+	return pm.updateActiveCredential(stageModel)
 }
 
 // VerifyIntegrateSetting ..
@@ -276,7 +500,21 @@ func (pm *SettingManager) VerifyIntegrateSetting(request *IntegrateSettingReq) V
 		resp.Error = err
 		return resp
 	}
+	// the connection of verification uses the dedicated client of the tls options of request
+	warning, err := verifyIntegrateTLS(request.Type, config)
+	if err != nil {
+		resp.Error = err
+		return resp
+	}
+	resp = pm.verifyIntegrateSetting(request, config)
+	if warning != "" && resp.Error == nil {
+		resp.Msg = fmt.Sprintf("%v, %v", resp.Msg, warning)
+	}
+	return resp
+}
 
+func (pm *SettingManager) verifyIntegrateSetting(request *IntegrateSettingReq, config string) VerifyResponse {
+	resp := VerifyResponse{}
 	switch strings.ToLower(request.Type) {
 	case KubernetesType:
 		kube := &KubeConfig{}
@@ -289,34 +527,12 @@ func (pm *SettingManager) VerifyIntegrateSetting(request *IntegrateSettingReq) V
 			resp.Error = err
 			return resp
 		}
-		var k8sconf *rest.Config
-		switch kube.Type {
-		case KubernetesConfig:
-			k8sconf, err = clientcmd.RESTConfigFromKubeConfig([]byte(kube.Conf))
-			if err != nil {
-				resp.Error = err
-				return resp
-			}
-		case KubernetesToken:
-			k8sconf = &rest.Config{
-				BearerToken:     kube.Conf,
-				TLSClientConfig: rest.TLSClientConfig{Insecure: true},
-				Host:            kube.URL,
-			}
-		}
-
-		clientset, err := kubernetes.NewForConfig(k8sconf)
-		if err != nil {
-			resp.Error = err
-			return resp
-		}
-		k8sVersion, err := clientset.Discovery().ServerVersion()
+		msg, err := verifyKubeConfig(kube)
 		if err != nil {
-			log.Log.Error("get kubernetes verison error: %s", err.Error())
+			log.Log.Error("verify kubernetes conf error: %s", err.Error())
 			resp.Error = err
 			return resp
 		}
-		msg := fmt.Sprintf("Connected to Kubernetes %s", k8sVersion.GitVersion)
 		resp.Msg = msg
 	case RegistryType:
 		registryConf := &RegistryConfig{}
@@ -327,7 +543,7 @@ func (pm *SettingManager) VerifyIntegrateSetting(request *IntegrateSettingReq) V
 		} else {
 			log.Log.Debug("verify registry conf: %v", registryConf)
 
-			if err := TryLoginRegistry(registryConf.URL, registryConf.User, registryConf.Password, !registryConf.IsHttps); err != nil {
+			if err := registryConf.Verify(); err != nil {
 				resp.Error = err
 			} else {
 				resp.Msg = "连接成功"
@@ -342,22 +558,96 @@ func (pm *SettingManager) VerifyIntegrateSetting(request *IntegrateSettingReq) V
 			return resp
 		}
 		log.Log.Debug("verify jenkins conf: %v", jenkinsConf)
-		jClient, err := jenkins.NewJenkinsClient(
-			jenkins.URL(jenkinsConf.URL),
-			jenkins.JenkinsUser(jenkinsConf.User),
-			jenkins.JenkinsToken(jenkinsConf.Token),
-		)
+		if jenkinsConf.Driver != "" {
+			resp.Msg, resp.Error = pingWorkflowDriver(jenkinsConf)
+			return resp
+		}
+		pingInfo, err := pingJenkins(jenkinsConf)
+		if err != nil {
+			resp.Error = err
+		} else {
+			resp.Msg = fmt.Sprintf("Connected to Jenkins %v", pingInfo)
+		}
+	case ArgoCDType:
+		argoCDConf := &ArgoCDConfig{}
+		err := json.Unmarshal([]byte(config), argoCDConf)
 		if err != nil {
-			log.Log.Error("create jenkins client error: %s", err.Error())
+			log.Log.Error("argocd conf format error:  %v", err.Error())
 			resp.Error = err
 			return resp
 		}
-
-		pingInfo, err := jClient.Ping()
+		client, err := argoCDConf.Client()
+		if err != nil {
+			resp.Error = err
+			return resp
+		}
+		version, err := client.Version()
 		if err != nil {
 			resp.Error = err
 		} else {
-			resp.Msg = fmt.Sprintf("Connected to Jenkins %v", pingInfo)
+			resp.Msg = fmt.Sprintf("Connected to Argo CD %v", version)
+		}
+	case JiraType:
+		jiraConf := &JiraConfig{}
+		err := json.Unmarshal([]byte(config), jiraConf)
+		if err != nil {
+			log.Log.Error("jira conf format error:  %v", err.Error())
+			resp.Error = err
+			return resp
+		}
+		client, err := jiraConf.Client()
+		if err != nil {
+			resp.Error = err
+			return resp
+		}
+		version, err := client.Version()
+		if err != nil {
+			resp.Error = err
+		} else {
+			resp.Msg = fmt.Sprintf("Connected to Jira %v", version)
+		}
+	case KafkaType:
+		kafkaConf := &KafkaConfig{}
+		err := json.Unmarshal([]byte(config), kafkaConf)
+		if err != nil {
+			log.Log.Error("kafka conf format error:  %v", err.Error())
+			resp.Error = err
+			return resp
+		}
+		version, err := eventbus.NewKafkaPublisher(kafkaConf.URL, kafkaConf.User, kafkaConf.Password, kafkaConf.Insecure).Ping()
+		if err != nil {
+			resp.Error = err
+		} else {
+			resp.Msg = fmt.Sprintf("Connected to %v", version)
+		}
+	case NATSType:
+		natsConf := &NATSConfig{}
+		err := json.Unmarshal([]byte(config), natsConf)
+		if err != nil {
+			log.Log.Error("nats conf format error:  %v", err.Error())
+			resp.Error = err
+			return resp
+		}
+		publisher := eventbus.NewNATSPublisher(natsConf.URL, natsConf.User, natsConf.Password, natsConf.Token, natsConf.Insecure)
+		defer publisher.Close()
+		version, err := publisher.Ping()
+		if err != nil {
+			resp.Error = err
+		} else {
+			resp.Msg = fmt.Sprintf("Connected to %v", version)
+		}
+	case CosignType:
+		cosignConf := &CosignConfig{}
+		err := json.Unmarshal([]byte(config), cosignConf)
+		if err != nil {
+			log.Log.Error("cosign conf format error:  %v", err.Error())
+			resp.Error = err
+			return resp
+		}
+		if err := cosignConf.Verify(); err != nil {
+			resp.Error = err
+		} else {
+			resp.Msg = "Cosign key pair is valid"
 		}
 	default:
 		resp.Error = fmt.Errorf("no support type: %s integrate setting", request.Type)
@@ -378,6 +668,15 @@ func (pm *SettingManager) CreateIntegrateSetting(request *IntegrateSettingReq, c
 			return err
 		}
 	}
+	if err := verifyOrganization(request.OrgID); err != nil {
+		return err
+	}
+	if _, err := verifyIntegrateTLS(request.Type, config); err != nil {
+		return err
+	}
+	if err := pm.verifyHostTLSConflict(0, request.Type, config); err != nil {
+		return err
+	}
 
 	newIntegrateSetting := &models.IntegrateSetting{
 		Name:        request.Name,
@@ -385,17 +684,29 @@ func (pm *SettingManager) CreateIntegrateSetting(request *IntegrateSettingReq, c
 		Creator:     creator,
 		Type:        request.Type,
 		Config:      config,
+		OrgID:       request.OrgID,
 	}
 
 	newIntegrateSetting.CryptoConfig(config)
 
-	return pm.model.CreateIntegrateSetting(newIntegrateSetting)
+	if err := pm.model.CreateIntegrateSetting(newIntegrateSetting); err != nil {
+		return err
+	}
+	registerIntegrateTLS(newIntegrateSetting.ID, newIntegrateSetting.Type, config)
+	if newIntegrateSetting.Type == KubernetesType {
+		pm.detectClusterCapability(newIntegrateSetting.ID)
+	}
+	return nil
 }
 
 // DeleteIntegrateSetting ..
 func (pm *SettingManager) DeleteIntegrateSetting(integrateID int64) error {
 	// TODO: verify integrateID is referenced by project env or not.
-	return pm.model.DeleteIntegrateSetting(integrateID)
+	if err := pm.model.DeleteIntegrateSetting(integrateID); err != nil {
+		return err
+	}
+	tlsutil.Unregister(tlsOwner(integrateID))
+	return nil
 }
 
 func formatIntegrateSettingResponse(items []*models.IntegrateSetting) []*IntegrateSettingResponse {
@@ -413,15 +724,17 @@ func formatSignalIntegrateSetting(item *models.IntegrateSetting, config *Config)
 		log.Log.Error("parse config error: %s", err.Error())
 	}
 	return &IntegrateSettingResponse{
-		Creator:  item.Creator,
-		UpdateAt: &item.UpdateAt,
-		CreateAt: &item.CreateAt,
-		ID:       item.ID,
+		Creator:           item.Creator,
+		UpdateAt:          &item.UpdateAt,
+		CreateAt:          &item.CreateAt,
+		ID:                item.ID,
+		CredentialVersion: item.CredentialVersion,
 		IntegrateSettingReq: IntegrateSettingReq{
 			Name:        item.Name,
 			Description: item.Description,
 			Type:        item.Type,
 			Config:      configJSON,
+			OrgID:       item.OrgID,
 		},
 	}
 }
@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/tlsutil"
+)
+
+// TLSConfig the tls options of the connection to the integrated service, eg: the on-prem service uses the private CA
+type TLSConfig struct {
+	// CACert the PEM CA bundle trusted besides the system root CAs
+	CACert string `json:"ca_cert,omitempty"`
+	// ClientCert/ClientKey the PEM client certificate and key of mutual tls
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// InsecureSkipVerify skip the verification of server certificate, which is vulnerable to man-in-the-middle attacks
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// insecureTLSWarning the warning of the setting skips the verification of server certificate
+const insecureTLSWarning = "警告: 已跳过服务端证书校验，连接存在中间人攻击风险，建议配置 CA 证书"
+
+func (c *TLSConfig) empty() bool {
+	return c == nil || (c.CACert == "" && c.ClientCert == "" && c.ClientKey == "" && !c.InsecureSkipVerify)
+}
+
+// ClientConfig return the tls config of the options, nil means the default tls config
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if c.empty() {
+		return nil, nil
+	}
+	return tlsutil.ClientConfig(c.CACert, c.ClientCert, c.ClientKey, c.InsecureSkipVerify)
+}
+
+// Transport return the dedicated transport of the tls options, which does not use the tls configs registered by host
+func (c *TLSConfig) Transport() (*http.Transport, error) {
+	config, err := c.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return tlsutil.Transport(nil, config), nil
+}
+
+// HTTPClient return the dedicated http client of the tls options
+func (c *TLSConfig) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	transport, err := c.Transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// equal the nil options are the same as the empty ones
+func (c *TLSConfig) equal(other *TLSConfig) bool {
+	if c.empty() || other.empty() {
+		return c.empty() && other.empty()
+	}
+	return *c == *other
+}
+
+// integrateTLS return the server url and the tls options of the integrate setting config,
+// the kubernetes setting applies the tls options to the rest config, so it is not returned
+func integrateTLS(config interface{}) (string, *TLSConfig) {
+	switch item := config.(type) {
+	case *JenkinsConfig:
+		return item.URL, item.TLS
+	case *RegistryConfig:
+		return registryTLSURL(item), item.TLS
+	case *ScmAuthConf:
+		return item.URL, item.TLS
+	case *ArgoCDConfig:
+		return item.URL, withInsecure(item.TLS, item.Insecure)
+	case *JiraConfig:
+		return item.URL, withInsecure(item.TLS, item.Insecure)
+	}
+	return "", nil
+}
+
+// withInsecure the insecure option of the settings saved before is the same as skipping the verification
+// of server certificate
+func withInsecure(tlsConfig *TLSConfig, insecure bool) *TLSConfig {
+	if !insecure || (tlsConfig != nil && tlsConfig.InsecureSkipVerify) {
+		return tlsConfig
+	}
+	options := TLSConfig{InsecureSkipVerify: true}
+	if tlsConfig != nil {
+		options = *tlsConfig
+		options.InsecureSkipVerify = true
+	}
+	return &options
+}
+
+// registryTLSURL the registry url may have no scheme, eg: harbor.local
+func registryTLSURL(registry *RegistryConfig) string {
+	if strings.Contains(registry.URL, "://") {
+		return registry.URL
+	}
+	return "https://" + registry.URL
+}
+
+// hostTLSTypes the jenkins and scm clients use the default transport, the tls options of their settings
+// are registered by host, the registry and kubernetes clients use the dedicated transport of the setting,
+// the argo cd and jira clients use the dedicated transport too, their hosts are registered for the other clients
+func hostTLSTypes() []string {
+	return append([]string{JenkinsType, ArgoCDType, JiraType}, constant.ScmIntegratetypes...)
+}
+
+func isHostTLSType(settingType string) bool {
+	for _, item := range hostTLSTypes() {
+		if strings.EqualFold(item, settingType) {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsOwner the owner of the tls config registered by the integrate setting
+func tlsOwner(settingID int64) string {
+	return fmt.Sprintf("integrate-setting-%d", settingID)
+}
+
+// verifyIntegrateTLS the tls options must be valid, return the warning if the verification of certificate is skipped
+func verifyIntegrateTLS(settingType, config string) (string, error) {
+	item, err := (&Config{}).Struct(config, settingType)
+	if err != nil {
+		return "", err
+	}
+	var tlsConfig *TLSConfig
+	if kube, ok := item.(*KubeConfig); ok {
+		tlsConfig = kube.TLS
+	} else {
+		_, tlsConfig = integrateTLS(item)
+	}
+	if _, err := tlsConfig.ClientConfig(); err != nil {
+		return "", err
+	}
+	if tlsConfig != nil && tlsConfig.InsecureSkipVerify {
+		return insecureTLSWarning, nil
+	}
+	return "", nil
+}
+
+// verifyHostTLSConflict the settings of the same host share the tls config registered by host,
+// so their tls options must be the same
+func (pm *SettingManager) verifyHostTLSConflict(settingID int64, settingType, config string) error {
+	if !isHostTLSType(settingType) {
+		return nil
+	}
+	item, err := (&Config{}).Struct(config, settingType)
+	if err != nil {
+		return err
+	}
+	serverURL, tlsConfig := integrateTLS(item)
+	host := tlsutil.HostKey(serverURL)
+	if host == "" {
+		return nil
+	}
+	items, err := pm.model.GetIntegrateSettings(hostTLSTypes(), nil)
+	if err != nil {
+		return err
+	}
+	for _, other := range items {
+		if other.ID == settingID {
+			continue
+		}
+		otherItem, err := (&Config{}).Struct(other.DecryptConfig(), other.Type)
+		if err != nil {
+			continue
+		}
+		otherURL, otherTLS := integrateTLS(otherItem)
+		if tlsutil.HostKey(otherURL) == host && !tlsConfig.equal(otherTLS) {
+			return fmt.Errorf("服务地址 %v 已被集成配置 %v 使用，同一服务地址的 TLS 配置必须一致", host, other.Name)
+		}
+	}
+	return nil
+}
+
+// registerIntegrateTLS apply the tls options of the jenkins/scm setting to the connections of its server
+func registerIntegrateTLS(settingID int64, settingType, config string) {
+	if !isHostTLSType(settingType) {
+		return
+	}
+	owner := tlsOwner(settingID)
+	item, err := (&Config{}).Struct(config, settingType)
+	if err != nil {
+		tlsutil.Unregister(owner)
+		return
+	}
+	serverURL, tlsConfig := integrateTLS(item)
+	clientConfig, err := tlsConfig.ClientConfig()
+	if err != nil {
+		log.Log.Warn("the tls options of %v server: %v are invalid, %v", settingType, serverURL, err.Error())
+	}
+	if serverURL == "" || clientConfig == nil {
+		tlsutil.Unregister(owner)
+		return
+	}
+	if tlsConfig.InsecureSkipVerify {
+		log.Log.Warn("the certificate verification of %v server: %v is skipped", settingType, serverURL)
+	}
+	if err := tlsutil.Register(owner, serverURL, clientConfig); err != nil {
+		log.Log.Warn("register the tls options of %v server: %v occur error: %v", settingType, serverURL, err.Error())
+	}
+}
+
+// RegisterIntegrateTLS apply the tls options of all the jenkins and scm settings when server starts
+func (pm *SettingManager) RegisterIntegrateTLS() error {
+	tlsutil.Install()
+	items, err := pm.model.GetIntegrateSettings(hostTLSTypes(), nil)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		registerIntegrateTLS(item.ID, item.Type, item.DecryptConfig())
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-atomci/atomci/pkg/tlsutil"
+)
+
+func TestPingJenkinsTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, token, _ := r.BasicAuth(); user != "admin" || token != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Jenkins", "2.332")
+	}))
+	defer server.Close()
+
+	config := &JenkinsConfig{BaseConfig: BaseConfig{URL: server.URL, User: "admin"}, Token: "token"}
+	if _, err := pingJenkins(config); err == nil {
+		t.Fatalf("ping the jenkins with unknown CA should fail")
+	}
+
+	config.TLS = &TLSConfig{CACert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))}
+	version, err := pingJenkins(config)
+	if err != nil || version != "2.332" {
+		t.Fatalf("pingJenkins() = %v, %v", version, err)
+	}
+	// verify uses the dedicated client, the tls config registered by host is not changed
+	if tlsutil.Lookup(server.URL) != nil {
+		t.Errorf("verify should not register the tls config")
+	}
+
+	config.Token = "invalid"
+	if _, err := pingJenkins(config); err == nil || err.Error() != "401 unauthorized" {
+		t.Errorf("pingJenkins() with invalid token error = %v", err)
+	}
+}
+
+func TestArgoCDJiraTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/version":
+			w.Write([]byte(`{"Version":"v2.4.0"}`))
+		case "/rest/api/2/serverInfo":
+			w.Write([]byte(`{"version":"8.20.0"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	for _, settingType := range []string{ArgoCDType, JiraType} {
+		if !isHostTLSType(settingType) {
+			t.Errorf("the tls options of %v should be registered by host", settingType)
+		}
+	}
+	tests := []struct {
+		name     string
+		tls      *TLSConfig
+		insecure bool
+		wantErr  bool
+	}{
+		{name: "unknown CA", wantErr: true},
+		{name: "CA of setting", tls: &TLSConfig{CACert: caCert}},
+		{name: "legacy insecure", insecure: true},
+		{name: "invalid CA", tls: &TLSConfig{CACert: "invalid"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argoCDConf := &ArgoCDConfig{URL: server.URL, Token: "token", Insecure: tt.insecure, TLS: tt.tls}
+			version, err := func() (string, error) {
+				client, err := argoCDConf.Client()
+				if err != nil {
+					return "", err
+				}
+				return client.Version()
+			}()
+			if (err != nil) != tt.wantErr || (err == nil && version != "v2.4.0") {
+				t.Errorf("argo cd version = %v, %v, wantErr %v", version, err, tt.wantErr)
+			}
+			jiraConf := &JiraConfig{URL: server.URL, User: "admin", Token: "token", Insecure: tt.insecure, TLS: tt.tls}
+			version, err = func() (string, error) {
+				client, err := jiraConf.Client()
+				if err != nil {
+					return "", err
+				}
+				return client.Version()
+			}()
+			if (err != nil) != tt.wantErr || (err == nil && version != "8.20.0") {
+				t.Errorf("jira version = %v, %v, wantErr %v", version, err, tt.wantErr)
+			}
+		})
+	}
+	// the clients use the dedicated transport, the tls config registered by host is not changed
+	if tlsutil.Lookup(server.URL) != nil {
+		t.Errorf("the clients should not register the tls config")
+	}
+}
+
+func TestTLSConfigEqual(t *testing.T) {
+	tests := []struct {
+		a, b  *TLSConfig
+		equal bool
+	}{
+		{nil, nil, true},
+		{nil, &TLSConfig{}, true},
+		{&TLSConfig{CACert: "ca"}, nil, false},
+		{&TLSConfig{CACert: "ca"}, &TLSConfig{CACert: "ca"}, true},
+		{&TLSConfig{CACert: "ca"}, &TLSConfig{CACert: "ca", InsecureSkipVerify: true}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.equal(tt.b); got != tt.equal {
+			t.Errorf("%+v equal %+v = %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
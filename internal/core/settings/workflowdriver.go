@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/runnermgr"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/workflowplugin"
+
+	"github.com/astaxie/beego"
+)
+
+// WorkflowDrivers the external workflow drivers, which serve the ci servers other than jenkins
+var WorkflowDrivers = workflowplugin.NewRegistry(beego.AppConfig.DefaultString("workflow::driver_dir", ""))
+
+// JenkinsCapabilities the capabilities of the builtin jenkins driver
+var JenkinsCapabilities = &workflowplugin.Capabilities{Name: "jenkins", Version: "builtin", SupportsAbort: true, SupportsLogs: true}
+
+// runnerDriver the builtin driver running the jobs by the self hosted runners
+var runnerDriver = &runnermgr.Driver{}
+
+// WorkflowDriver return the builtin runner driver or the external driver of name
+func WorkflowDriver(name string) (workflowplugin.Driver, error) {
+	if name == runnermgr.DriverName {
+		return runnerDriver, nil
+	}
+	return WorkflowDrivers.Driver(name)
+}
+
+// GetWorkflowDrivers return the builtin jenkins and runner drivers and the external drivers, the driver failed to start is skipped
+func (pm *SettingManager) GetWorkflowDrivers() ([]*workflowplugin.Capabilities, error) {
+	names, err := WorkflowDrivers.Names()
+	if err != nil {
+		return nil, err
+	}
+	runnerCapabilities, _ := runnerDriver.Capabilities()
+	drivers := []*workflowplugin.Capabilities{JenkinsCapabilities, runnerCapabilities}
+	for _, name := range names {
+		if name == runnermgr.DriverName {
+			// the external driver is shadowed by the builtin one
+			continue
+		}
+		capabilities, err := DriverCapabilities(name)
+		if err != nil {
+			log.Log.Warn("get capabilities of workflow driver: %v occur error: %s", name, err.Error())
+			continue
+		}
+		drivers = append(drivers, capabilities)
+	}
+	return drivers, nil
+}
+
+// DriverCapabilities return the capabilities of the workflow driver, empty name means jenkins
+func DriverCapabilities(name string) (*workflowplugin.Capabilities, error) {
+	if name == "" || name == JenkinsCapabilities.Name {
+		return JenkinsCapabilities, nil
+	}
+	driver, err := WorkflowDriver(name)
+	if err != nil {
+		return nil, err
+	}
+	capabilities, err := driver.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	// the driver is named by its plugin binary
+	capabilities.Name = name
+	return capabilities, nil
+}
+
+func pingWorkflowDriver(config *JenkinsConfig) (string, error) {
+	driver, err := WorkflowDriver(config.Driver)
+	if err != nil {
+		return "", err
+	}
+	info, err := driver.Ping(&workflowplugin.Connection{URL: config.URL, User: config.User, Token: config.Token})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Connected to %v %v", config.Driver, info), nil
+}
+
+// pingJenkins request the crumb issuer of jenkins, the same as the ping of workflow client, but by the dedicated
+// client of the tls options, so the unsaved tls options do not affect the other connections to jenkins
+func pingJenkins(config *JenkinsConfig) (string, error) {
+	client, err := config.TLS.HTTPClient(30 * time.Second)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(config.URL, "/")+"/crumbIssuer/api/json", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(config.User, config.Token)
+	rsp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		return rsp.Header.Get("X-Jenkins"), nil
+	case http.StatusNotFound:
+		return "", fmt.Errorf("404 not found")
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("401 unauthorized")
+	}
+	body, _ := ioutil.ReadAll(rsp.Body)
+	return "", fmt.Errorf("http code: %d, response: %s", rsp.StatusCode, string(body))
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/astaxie/beego"
+)
+
+// RunArchiveServer archive the publish jobs and operation logs out of the retention of projects periodically
+func RunArchiveServer() {
+	if !beego.AppConfig.DefaultBool("archive::enable", false) {
+		return
+	}
+	runLoop("archive", publish.ArchiveInterval(), archiveProjects)
+}
+
+func archiveProjects() {
+	results, err := publish.NewArchiveManager().Archive(0)
+	if err != nil {
+		log.Log.Warn("archive publish jobs occur error: %s", err.Error())
+		return
+	}
+	var jobs, operationLogs int64
+	for _, result := range results {
+		jobs += result.Jobs
+		operationLogs += result.OperationLogs
+	}
+	log.Log.Info("archive finished, %v publish jobs and %v operation logs archived", jobs, operationLogs)
+}
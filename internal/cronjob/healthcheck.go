@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/events"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/notification"
+	"github.com/go-atomci/atomci/utils"
+
+	"github.com/astaxie/beego"
+)
+
+// RunDeployHealthCheckServer watch the running deploy jobs until the apps rollout finished
+func RunDeployHealthCheckServer() {
+	interval := time.Duration(beego.AppConfig.DefaultInt("healthcheck::interval", 10)) * time.Second
+	runLoop("healthcheck", interval, syncAllDeployJobHealth)
+}
+
+func syncAllDeployJobHealth() {
+	newPublishJob := dao.NewPublishJobModel()
+	publishJobs, err := newPublishJob.GetPublishJobsByFilter([]string{models.StatusRunning}, []string{models.JobTypeDeploy})
+	if err != nil {
+		log.Log.Error("when sync deploy job health, get publish jobs occur error: %s", err.Error())
+		return
+	}
+	pipeline := pipelinemgr.NewPipelineManager()
+	for _, job := range publishJobs {
+		if err := syncDeployJobHealth(job, newPublishJob, pipeline); err != nil {
+			log.Log.Error("sync deploy job id: %d health occur error: %s", job.ID, err.Error())
+		}
+	}
+}
+
+func syncDeployJobHealth(job *models.PublishJob, newPublishJob *dao.PublishJobModel, pipeline *pipelinemgr.PipelineManager) error {
+	result, err := pipeline.CheckDeployJobHealth(job)
+	if err != nil {
+		return err
+	}
+	if job.Progress < result.Progress {
+		job.Progress = result.Progress
+	}
+	job.DurationInMillis = time.Since(job.CreateAt).Milliseconds()
+	job.Status = result.JobStatus
+	if err := newPublishJob.UpdatePublishJob(job); err != nil {
+		return err
+	}
+	if result.JobStatus == models.StatusRunning {
+		log.Log.Debug("deploy job: %d is waiting for apps ready: %s", job.ID, result.Message)
+		return nil
+	}
+
+	pipelinemgr.ObserveJobFinished(job)
+	events.EmitJobStatus(job)
+	go pipeline.RecordJobStages(job, result.Message)
+	go pipeline.ReportJobCommitStatus(job)
+	log.Log.Info("deploy job: %d health check finished, status: %v, message: %s", job.ID, result.JobStatus, result.Message)
+	return deployJobFinished(job, result, pipeline)
+}
+
+// deployJobFinished act as the deploy step callback
+func deployJobFinished(job *models.PublishJob, result *pipelinemgr.DeployHealthResult, pipeline *pipelinemgr.PipelineManager) error {
+	publishmgr := publish.NewPublishManager()
+	publishItem, err := dao.NewPublishModel().GetPublishByID(job.PublishID)
+	if err != nil {
+		return err
+	}
+	if !jobStepRunning(job, publishItem, pipeline) {
+		log.Log.Warn("publish order id: %v step %v of job: %v is not running, skip update", publishItem.ID, job.StepIndex, job.ID)
+		return nil
+	}
+	// operation log message column size is 256
+	message := utils.Truncate(result.Message, 256)
+	if err := publishmgr.UpdatePublishStep(job.PublishID, job.EnvID, job.StepIndex, result.PublishStatus, job.RunID, "system", message, ""); err != nil {
+		return err
+	}
+
+	if result.PublishStatus == models.Failed {
+		go publishmgr.HandleDeployFailure(job)
+	}
+	if result.PublishStatus == models.Success {
+		go func() {
+			if err := publishmgr.TransitionPublishIssues(job.PublishID, job.EnvID); err != nil {
+				log.Log.Warn("transition publish: %v issues occur error: %s", job.PublishID, err.Error())
+			}
+		}()
+	}
+	go notification.Send(notification.NewPushNotification(result.PublishStatus, publishItem.Name, publishItem.StageName, publishItem.Step))
+	return nil
+}
+
+// jobStepRunning check the step of job is still running, the steps of stage maybe parallel,
+// so the state of the step is used instead of the current step of publish
+func jobStepRunning(job *models.PublishJob, publishItem *models.Publish, pipeline *pipelinemgr.PipelineManager) bool {
+	if publishItem.Status == models.Closed || publishItem.Status == models.END || publishItem.StageID != job.EnvID {
+		return false
+	}
+	states, err := pipeline.GetStageStepStates(publishItem.LastPipelineInstanceID, job.EnvID)
+	if err != nil {
+		log.Log.Error("get publish: %v step states occur error: %s", publishItem.ID, err.Error())
+		return false
+	}
+	return states[job.StepIndex] == models.Running
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/apps"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/astaxie/beego"
+)
+
+// RunIntegrateCheckServer check the connectivity of all the integrate settings periodically
+func RunIntegrateCheckServer() {
+	if !beego.AppConfig.DefaultBool("integratecheck::enable", true) {
+		return
+	}
+	interval := time.Duration(beego.AppConfig.DefaultInt("integratecheck::interval", 5)) * time.Minute
+	runLoop("integratecheck", interval, checkIntegrateSettings)
+}
+
+func checkIntegrateSettings() {
+	items, err := settings.NewSettingManager().CheckIntegrateSettings(apps.VerifyScmSetting)
+	if err != nil {
+		log.Log.Error("check integrate settings occur error: %s", err.Error())
+		return
+	}
+	log.Log.Debug("%v integrate settings checked", len(items))
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego"
+)
+
+// RunJobQueueServer dispatch the queued jobs after the running job of env completed
+func RunJobQueueServer() {
+	interval := time.Duration(beego.AppConfig.DefaultInt("queue::interval", 10)) * time.Second
+	runLoop("jobqueue", interval, dispatchJobQueue)
+}
+
+func dispatchJobQueue() {
+	items, err := dao.NewPublishJobModel().GetJobQueueItems(0, 0, []string{models.QueueStatusWaiting})
+	if err != nil {
+		log.Log.Error("when dispatch job queue, get waiting items occur error: %s", err.Error())
+		return
+	}
+	pipeline := pipelinemgr.NewPipelineManager()
+	publishmgr := publish.NewPublishManager()
+	// the later item of the same env keep waiting while the env is busy
+	for _, item := range pipeline.ScheduleJobQueue(items) {
+		result, err := pipeline.DispatchJobQueueItem(item)
+		if err != nil {
+			log.Log.Error("dispatch queue item: %v occur error: %s", item.ID, err.Error())
+		}
+		if result == nil {
+			continue
+		}
+		if err := publishmgr.UpdatePublish(result.PublishID, result.StageID, result.Status, result.RunID, result.Creator, "", result.JobName); err != nil {
+			log.Log.Error("after dispatch queue item: %v, update publish: %v occur error: %s", item.ID, result.PublishID, err.Error())
+		}
+	}
+}
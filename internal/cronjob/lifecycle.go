@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+var (
+	stopCh   = make(chan struct{})
+	stopOnce sync.Once
+	loops    sync.WaitGroup
+	leases   sync.Map
+)
+
+var (
+	// tryLease acquire or renew the lease of the loop, only the replica holding the lease runs the task
+	tryLease = dao.TryLock
+	// releaseLease release the lease of the loop on shutdown
+	releaseLease = dao.Unlock
+)
+
+// runLoop run the task every interval in background until shutdown, the running task is not interrupted,
+// among the server replicas the task is only run by the one holding the lease of the loop name
+func runLoop(name string, interval time.Duration, task func()) {
+	lease := "cron-" + name
+	// the lease outlives a missed tick, another replica takes over when the holder is gone
+	ttl := 2*interval + time.Minute
+	loops.Add(1)
+	go func() {
+		defer loops.Done()
+		for {
+			locked, err := tryLease(lease, ttl)
+			if err != nil {
+				log.Log.Warn("acquire lease of loop: %v occur error: %s", name, err.Error())
+			}
+			if locked {
+				leases.Store(lease, true)
+				task()
+			} else {
+				leases.Delete(lease)
+			}
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// Shutdown stop the background loops and wait for the running tasks, return false when they did not finish in timeout
+func Shutdown(timeout time.Duration) bool {
+	stopOnce.Do(func() {
+		close(stopCh)
+	})
+	done := make(chan struct{})
+	go func() {
+		loops.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		releaseLeases()
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// releaseLeases let another replica take over the loops at once
+func releaseLeases() {
+	leases.Range(func(key, _ interface{}) bool {
+		if err := releaseLease(key.(string)); err != nil {
+			log.Log.Warn("release lease: %v occur error: %s", key, err.Error())
+		}
+		return true
+	})
+}
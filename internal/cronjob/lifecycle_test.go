@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitRunningTask(t *testing.T) {
+	var runs, finished int32
+	started := make(chan struct{}, 1)
+	tryLease = func(name string, ttl time.Duration) (bool, error) {
+		return true, nil
+	}
+	releaseLease = func(name string) error {
+		return nil
+	}
+	runLoop("test", time.Millisecond, func() {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			started <- struct{}{}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&finished, 1)
+	})
+	<-started
+	if !Shutdown(time.Second) {
+		t.Fatalf("expect the loop stopped before timeout")
+	}
+	if atomic.LoadInt32(&runs) != atomic.LoadInt32(&finished) {
+		t.Errorf("expect the running task finished before shutdown, runs: %v, finished: %v", runs, finished)
+	}
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != stopped {
+		t.Errorf("expect no task started after shutdown")
+	}
+}
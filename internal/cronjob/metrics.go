@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/pkg/metrics"
+
+	"github.com/astaxie/beego"
+	"github.com/go-atomci/workflow/jenkins"
+)
+
+// RunMetricsServer register the metrics collected from db and ping the jenkins servers periodically
+func RunMetricsServer() {
+	metrics.NewGaugeFunc("atomci_job_queue_depth", "The number of jobs waiting in the job queue.", func() (float64, error) {
+		items, err := dao.NewPublishJobModel().GetJobQueueItems(0, 0, []string{models.QueueStatusWaiting})
+		return float64(len(items)), err
+	})
+	interval := time.Duration(beego.AppConfig.DefaultInt("metrics::jenkins_ping_interval", 60)) * time.Second
+	runLoop("metrics", interval, pingJenkinsServers)
+}
+
+func pingJenkinsServers() {
+	servers, err := settings.NewSettingManager().GetIntegrateSettings([]string{settings.JenkinsType})
+	if err != nil {
+		log.Log.Error("when ping jenkins servers, get jenkins settings occur error: %s", err.Error())
+		return
+	}
+	for _, server := range servers {
+		config, ok := server.Config.(*settings.JenkinsConfig)
+		if !ok {
+			continue
+		}
+		client, err := jenkins.NewJenkinsClient(
+			jenkins.URL(config.URL),
+			jenkins.JenkinsUser(config.User),
+			jenkins.JenkinsToken(config.Token),
+		)
+		if err == nil {
+			_, err = client.Ping()
+		}
+		if err != nil {
+			log.Log.Warn("ping jenkins server: %v occur error: %s", server.Name, err.Error())
+			metrics.JenkinsPingFailures.Inc(server.Name)
+			metrics.JenkinsUp.Set(0, server.Name)
+			continue
+		}
+		metrics.JenkinsUp.Set(1, server.Name)
+	}
+}
@@ -17,26 +17,19 @@ limitations under the License.
 package cronjob
 
 import (
-	"fmt"
 	"time"
 
+	"github.com/go-atomci/atomci/internal/core/events"
 	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/publish"
 	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/internal/models"
-	"github.com/go-atomci/atomci/utils"
-
-	"github.com/go-atomci/workflow/jenkins"
 )
 
 // RunPublishJobServer ..
 func RunPublishJobServer() {
-	go func() {
-		for {
-			syncAllPublishJobStatus()
-			time.Sleep(time.Minute * 2)
-		}
-	}()
+	runLoop("publishjob", time.Minute*2, syncAllPublishJobStatus)
 }
 
 func syncAllPublishJobStatus() {
@@ -55,13 +48,31 @@ func syncAllPublishJobStatus() {
 		}
 		if err := updatePublishJobStatus(job, newPublishJob, newPublish, pipeline); err != nil {
 			log.Log.Error("sync publish job id: %d, run id: %d, occur error: %s", job.ID, job.RunID, err.Error())
+			reconcileStalePublishJob(job)
 			continue
 		}
+		reconcileStalePublishJob(job)
+	}
+}
+
+// reconcileStalePublishJob fail the job still running after the callback token expired, its callback never arrives,
+// the publish is not left running forever
+func reconcileStalePublishJob(job *models.PublishJob) {
+	if job.Status != models.StatusRunning && job.Status != models.StatusUnknown {
+		return
+	}
+	if time.Since(job.CreateAt) < pipelinemgr.CallbackTokenTTL() {
+		return
+	}
+	log.Log.Warn("publish job: %d callback never arrived in %v, reconcile it as failed", job.ID, pipelinemgr.CallbackTokenTTL())
+	if _, err := publish.NewPublishManager().ReconcileJobCallback(job, "任务超时未回调，已标记为失败"); err != nil {
+		log.Log.Error("reconcile publish job: %d occur error: %s", job.ID, err.Error())
 	}
 }
 
 func getRunningPublishJob(newPublishJob *dao.PublishJobModel) []*models.PublishJob {
-	publishJobs, err := newPublishJob.GetPublishJobsByFilter([]string{models.StatusRunning, models.StatusUnknown, models.StatusInit}, []string{models.JobTypeBuild, models.JobTypeDeploy})
+	// deploy job was synced by deploy health check server
+	publishJobs, err := newPublishJob.GetPublishJobsByFilter([]string{models.StatusRunning, models.StatusUnknown, models.StatusInit}, []string{models.JobTypeBuild, models.JobTypeE2ETest})
 	if err != nil {
 		log.Log.Error("when sync publish job, get publish jobs occur error: %s", err.Error())
 		return nil
@@ -70,56 +81,70 @@ func getRunningPublishJob(newPublishJob *dao.PublishJobModel) []*models.PublishJ
 }
 func justUpdateModelPublishJobStatus(job *models.PublishJob, status string, newPublishJob *dao.PublishJobModel) error {
 	job.Status = status
-	return newPublishJob.UpdatePublishJob(job)
+	if err := newPublishJob.UpdatePublishJob(job); err != nil {
+		return err
+	}
+	events.EmitJobStatus(job)
+	go pipelinemgr.NewPipelineManager().ReportJobCommitStatus(job)
+	return nil
 }
 
 func updatePublishJobStatus(job *models.PublishJob, newPublishJob *dao.PublishJobModel,
 	newPublish *dao.PublishModel, pipeline *pipelinemgr.PipelineManager) error {
 	log.Log.Info("sync publish job: %d, runID: %d", job.ID, job.RunID)
 	switch job.JobType {
-	case models.JobTypeBuild:
-		jobName := fmt.Sprintf("atomci_%v_%v_%v", job.ProjectID, job.PublishID, job.EnvID)
+	case models.JobTypeBuild, models.JobTypeE2ETest:
 		var publishStatus int
 		var err error
-		job, publishStatus, err = getPipelineJobStatus(jobName, job, pipeline)
+		job, publishStatus, err = getPipelineJobStatus(pipelineJobName(job), job, pipeline)
 		if err != nil {
 			return err
 		}
+		// the e2e job success means the suites finished, the pass rates decide the result
+		if job.JobType == models.JobTypeE2ETest && publishStatus == models.Success {
+			if passed, _, err := pipeline.E2ETestGate(job.ID); err != nil || !passed {
+				job.Status = models.StatusFailure
+				publishStatus = models.Failed
+			}
+		}
 		// publish Order update
-		updatePublishOrderStatus(job.PublishID, publishStatus, newPublish)
-		return newPublishJob.UpdatePublishJob(job)
-	case models.JobTypeDeploy:
-		jobName := fmt.Sprintf("atomci_%v_%v", job.ProjectID, job.EnvID)
-		var publishStatus int
-		var err error
-		job, publishStatus, err = getPipelineJobStatus(jobName, job, pipeline)
-		if err != nil {
+		updatePublishOrderStatus(job, publishStatus, newPublish, pipeline)
+		if publishStatus == models.Success && job.JobType == models.JobTypeBuild {
+			go linkPublishIssues(job.PublishID, job.EnvID)
+		}
+		if err := newPublishJob.UpdatePublishJob(job); err != nil {
 			return err
 		}
-		// publish Order update
-		updatePublishOrderStatus(job.PublishID, publishStatus, newPublish)
-		return newPublishJob.UpdatePublishJob(job)
-
+		if publishStatus != models.Running {
+			pipelinemgr.ObserveJobFinished(job)
+			events.EmitJobStatus(job)
+			go pipeline.RecordJobStages(job, "")
+			go pipeline.ReportJobCommitStatus(job)
+		}
+		return nil
 	default:
 		log.Log.Error("publish job type: %v is not support currently", job.JobType)
 	}
 	return nil
 }
 
+// pipelineJobName the jenkins job name of the build and e2e test job
+func pipelineJobName(job *models.PublishJob) string {
+	if job.JobType == models.JobTypeE2ETest {
+		return pipelinemgr.E2ETestJobName(job.ProjectID, job.PublishID, job.EnvID)
+	}
+	return pipelinemgr.BuildJobName(job.ProjectID, job.PublishID, job.EnvID)
+}
+
 func getPipelineJobStatus(jobName string, job *models.PublishJob, pipeline *pipelinemgr.PipelineManager) (*models.PublishJob, int, error) {
-	jenkinsInfo, err := pipeline.GetCIConfig(job.EnvID)
+	jenkinsInfo, err := pipeline.GetJobCIConfig(job)
 	if err != nil {
 		log.Log.Error("get Jenkins Config occur error: %s", err.Error())
 		return nil, 0, err
 	}
-	addr, user, token := jenkinsInfo[0], jenkinsInfo[1], jenkinsInfo[2]
+	addr, user, token := jenkinsInfo.URL, jenkinsInfo.User, jenkinsInfo.Token
 
-	workFlowProvider, err := jenkins.NewJenkinsClient(
-		jenkins.URL(addr),
-		jenkins.JenkinsUser(user),
-		jenkins.JenkinsToken(token),
-		jenkins.JenkinsJob(jobName),
-	)
+	workFlowProvider, err := pipelinemgr.NewWorkFlowProvide(jenkinsInfo.Driver, addr, user, token, jobName, nil)
 
 	if err != nil {
 		log.Log.Error("create workflow Client occur error: %s", err.Error())
@@ -183,39 +208,31 @@ func getPipelineJobStatus(jobName string, job *models.PublishJob, pipeline *pipe
 	return job, publishStatus, nil
 }
 
-func updatePublishOrderStatus(publishID int64, publishStatus int, newPublish *dao.PublishModel) {
+func updatePublishOrderStatus(job *models.PublishJob, publishStatus int, newPublish *dao.PublishModel, pipeline *pipelinemgr.PipelineManager) {
+	if publishStatus == models.Running {
+		return
+	}
 	// publish Order update
-	modelPublishItem, err := newPublish.GetPublishByID(publishID)
+	modelPublishItem, err := newPublish.GetPublishByID(job.PublishID)
 	if err != nil {
 		log.Log.Error("when get publishOrder model, occur error: %s", err.Error())
+		return
 	}
-	if utils.Contains([]string{"build", "deploy"}, modelPublishItem.StepType) && publishStatus != models.Running {
-		modelPublishItem.Status = int64(publishStatus)
-		err := newPublish.UpdatePublish(modelPublishItem)
-		if err != nil {
-			log.Log.Error("when update publishOrder status, occur error: %s", err.Error())
-		}
-		// create publish operation log
-		if err := createPublishOperationLog(modelPublishItem, int64(publishStatus), newPublish); err != nil {
-			log.Log.Error("after update publish order status, create publish operation log occur error: %s", err.Error())
-		}
-	} else {
-		log.Log.Warn("current publishOrder id: %v 's stepType %v is not %v, Or publishStaus is not running, skip status update", publishID, modelPublishItem.StepType, fmt.Sprintf("%v, %v", models.StepBuild, models.StepDeploy))
+	if !jobStepRunning(job, modelPublishItem, pipeline) {
+		log.Log.Warn("current publishOrder id: %v 's step %v of job: %v is not running, skip status update", job.PublishID, job.StepIndex, job.ID)
+		return
+	}
+	if err := publish.NewPublishManager().UpdatePublishStep(job.PublishID, job.EnvID, job.StepIndex, int64(publishStatus), job.RunID, "system", "", ""); err != nil {
+		log.Log.Error("when update publishOrder status, occur error: %s", err.Error())
 	}
 }
 
-func createPublishOperationLog(publish *models.Publish, status int64, newPublish *dao.PublishModel) error {
-	operationLog := &models.PublishOperationLog{
-		Creator:   "system",
-		Stage:     publish.StageName,
-		StageID:   publish.StageID,
-		Step:      publish.Step,
-		Message:   "",
-		Status:    status,
-		PublishID: publish.ID,
-	}
-	if err := newPublish.CreatePublishOperation(operationLog); err != nil {
-		return err
+// linkPublishIssues attach the issues mentioned in the built commits to publish order
+func linkPublishIssues(publishID, envID int64) {
+	issues, err := publish.NewPublishManager().LinkPublishIssues(publishID, envID)
+	if err != nil {
+		log.Log.Warn("link publish: %v issues occur error: %s", publishID, err.Error())
+		return
 	}
-	return nil
+	log.Log.Debug("publish: %v linked issues: %v", publishID, issues)
 }
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/core/publish"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+const (
+	// recoveryGrace the init job created recently maybe still starting by another instance
+	recoveryGrace = time.Minute
+	// recoveryLock the replicas started together recover the jobs only once
+	recoveryLock = "recovery"
+)
+
+// RecoverInFlightJobs recover the jobs in flight when the server stopped, it should run before the background loops:
+// the job never started is failed, the finished build whose callback was lost is processed as callback,
+// the running jobs are left to the status sync and deploy health check, which resume them from db
+func RecoverInFlightJobs() {
+	locked, err := dao.TryLock(recoveryLock, 10*time.Minute)
+	if err != nil {
+		log.Log.Error("when recover in-flight jobs, acquire lock occur error: %s", err.Error())
+		return
+	}
+	if !locked {
+		log.Log.Info("in-flight jobs are recovering by another replica, skip")
+		return
+	}
+	defer func() {
+		if err := dao.Unlock(recoveryLock); err != nil {
+			log.Log.Warn("release recovery lock occur error: %s", err.Error())
+		}
+	}()
+
+	jobs, err := dao.NewPublishJobModel().GetPublishJobsByFilter(
+		[]string{models.StatusInit, models.StatusRunning, models.StatusUnknown},
+		[]string{models.JobTypeBuild, models.JobTypeDeploy, models.JobTypeE2ETest},
+	)
+	if err != nil {
+		log.Log.Error("when recover in-flight jobs, get publish jobs occur error: %s", err.Error())
+		return
+	}
+	log.Log.Info("start recover %v in-flight publish jobs", len(jobs))
+	pipeline := pipelinemgr.NewPipelineManager()
+	for _, job := range jobs {
+		if err := recoverPublishJob(job, pipeline); err != nil {
+			log.Log.Error("recover publish job: %v occur error: %s", job.ID, err.Error())
+		}
+	}
+}
+
+func recoverPublishJob(job *models.PublishJob, pipeline *pipelinemgr.PipelineManager) error {
+	if job.Status == models.StatusInit && job.RunID == 0 {
+		if time.Since(job.CreateAt) < recoveryGrace {
+			return nil
+		}
+		return recoverJobCallback(job, models.StatusFailure, "服务重启，任务未能启动，已标记为失败")
+	}
+	if job.JobType == models.JobTypeDeploy {
+		log.Log.Info("deploy job: %v is resumed by health check", job.ID)
+		return nil
+	}
+
+	_, publishStatus, err := getPipelineJobStatus(pipelineJobName(job), job, pipeline)
+	if err != nil {
+		return fmt.Errorf("query jenkins run: %v status occur error: %s", job.RunID, err.Error())
+	}
+	// only the success job sends callback, the others are synced by status sync as before
+	if publishStatus != models.Success {
+		log.Log.Info("publish job: %v is resumed by status sync, jenkins status: %v", job.ID, job.Status)
+		return nil
+	}
+	return recoverJobCallback(job, models.StatusSuccess, "")
+}
+
+func recoverJobCallback(job *models.PublishJob, jobStatus, message string) error {
+	item, err := publish.NewPublishManager().HandleJobCallback(&publish.JobCallbackReq{
+		PublishID:      job.PublishID,
+		StageID:        job.EnvID,
+		PublishJobID:   job.ID,
+		IdempotencyKey: fmt.Sprintf("recovery-%d", job.ID),
+		Step:           job.JobType,
+		Source:         models.CallbackSourceRecovery,
+		Creator:        "system",
+		JobStatus:      jobStatus,
+		Message:        message,
+	})
+	if err != nil {
+		return err
+	}
+	log.Log.Info("publish job: %v recovered as %v, callback status: %v", job.ID, jobStatus, item.Status)
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+
+	"github.com/astaxie/beego"
+)
+
+// RunImageRetentionServer clean up the old image tags built by atomci periodically
+func RunImageRetentionServer() {
+	if !beego.AppConfig.DefaultBool("retention::enable", false) {
+		return
+	}
+	interval := time.Duration(beego.AppConfig.DefaultInt("retention::interval", 24)) * time.Hour
+	runLoop("retention", interval, cleanupImages)
+}
+
+func cleanupImages() {
+	pipeline := pipelinemgr.NewPipelineManager()
+	plans, err := pipeline.PlanImageRetention(0)
+	if err != nil {
+		log.Log.Error("plan image retention occur error: %s", err.Error())
+		return
+	}
+	for _, plan := range plans {
+		if plan.Error != "" {
+			log.Log.Warn("plan image: %v retention occur error: %s", plan.Image, plan.Error)
+		}
+	}
+	deleted := pipeline.ApplyImageRetention(plans)
+	log.Log.Info("image retention finished, %v tags deleted", deleted)
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/publish"
+
+	"github.com/astaxie/beego"
+)
+
+// RunPublishSLAServer check the publishes waiting in the manual step periodically, notify and escalate the overdue ones
+func RunPublishSLAServer() {
+	if !beego.AppConfig.DefaultBool("sla::enable", false) {
+		return
+	}
+	interval := time.Duration(beego.AppConfig.DefaultInt("sla::interval", 30)) * time.Minute
+	runLoop("sla", interval, func() {
+		publish.NewPublishManager().CheckPublishSLA()
+	})
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronjob
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/core/publish"
+
+	"github.com/astaxie/beego"
+)
+
+// RunJobWatchdogServer fail the jobs stuck longer than the timeout of their steps
+func RunJobWatchdogServer() {
+	interval := time.Duration(beego.AppConfig.DefaultInt("watchdog::interval", 60)) * time.Second
+	runLoop("watchdog", interval, func() {
+		publish.NewPublishManager().WatchStuckJobs()
+	})
+}
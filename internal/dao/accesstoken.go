@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+const accessTokenTableName = "sys_access_token"
+
+// CreateAccessToken ..
+func CreateAccessToken(token *models.AccessToken) (int64, error) {
+	return GetOrmer().Insert(token)
+}
+
+// GetAccessTokenByID ..
+func GetAccessTokenByID(id int64) (*models.AccessToken, error) {
+	token := models.AccessToken{}
+	if err := GetOrmer().QueryTable(accessTokenTableName).
+		Filter("deleted", false).Filter("id", id).One(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetAccessTokenByHash ..
+func GetAccessTokenByHash(tokenHash string) (*models.AccessToken, error) {
+	token := models.AccessToken{}
+	if err := GetOrmer().QueryTable(accessTokenTableName).
+		Filter("deleted", false).Filter("token_hash", tokenHash).One(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetUserAccessTokens return the tokens of user, include the revoked and expired ones
+func GetUserAccessTokens(user string) ([]*models.AccessToken, error) {
+	tokens := []*models.AccessToken{}
+	_, err := GetOrmer().QueryTable(accessTokenTableName).
+		Filter("deleted", false).Filter("user", user).OrderBy("-id").All(&tokens)
+	return tokens, err
+}
+
+// RevokeAccessToken ..
+func RevokeAccessToken(token *models.AccessToken) error {
+	token.Revoked = true
+	_, err := GetOrmer().Update(token, "revoked", "update_at")
+	return err
+}
+
+// RevokeUserAccessTokens revoke all the tokens of user
+func RevokeUserAccessTokens(user string) error {
+	_, err := GetOrmer().QueryTable(accessTokenTableName).
+		Filter("deleted", false).Filter("user", user).Update(map[string]interface{}{"revoked": true})
+	return err
+}
+
+// UpdateAccessTokenLastUsed ..
+func UpdateAccessTokenLastUsed(token *models.AccessToken, moment time.Time) error {
+	token.LastUsedAt = &moment
+	_, err := GetOrmer().Update(token, "last_used_at")
+	return err
+}
+
+// GetServiceAccounts return the service account users of project
+func GetServiceAccounts(userPrefix string) ([]*models.User, error) {
+	users := []*models.User{}
+	_, err := GetOrmer().QueryTable("sys_user").
+		Filter("login_type", models.ServiceAccountAuth).Filter("user__startswith", userPrefix).All(&users)
+	return users, err
+}
@@ -18,6 +18,7 @@ package dao
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-atomci/atomci/internal/models"
 	util "github.com/go-atomci/atomci/utils"
@@ -130,8 +131,8 @@ func (am *AppModel) CreateApp(ins models.CaasApplication) error {
 }
 
 func (am *AppModel) DeleteApp(app models.CaasApplication) error {
-	_, err := am.tOrmer.Raw("UPDATE "+am.TableName+" SET deleted=1, delete_at=now() WHERE name=? AND cluster=? AND namespace=? AND deleted=0",
-		app.Name, app.Cluster, app.Namespace).Exec()
+	_, err := am.tOrmer.Raw("UPDATE "+am.TableName+" SET deleted=?, delete_at=? WHERE name=? AND cluster=? AND namespace=? AND deleted=?",
+		true, time.Now(), app.Name, app.Cluster, app.Namespace, false).Exec()
 	return err
 }
 
@@ -162,14 +163,14 @@ func (am *AppModel) UpdateApp(ins *models.CaasApplication, updateTime bool) erro
 }
 
 func (am *AppModel) SetLabels(cluster, namespace, name, labels string) error {
-	_, err := am.tOrmer.Raw("UPDATE "+am.TableName+" SET labels=? WHERE cluster=? AND namespace=? AND name=? AND deleted=0",
-		labels, cluster, namespace, name).Exec()
+	_, err := am.tOrmer.Raw("UPDATE "+am.TableName+" SET labels=? WHERE cluster=? AND namespace=? AND name=? AND deleted=?",
+		labels, cluster, namespace, name, false).Exec()
 	return err
 }
 
 func (am *AppModel) SetDeployStatus(cluster, namespace, name, status string) error {
-	_, err := am.tOrmer.Raw("UPDATE "+am.TableName+" SET deploy_status=? WHERE name=? AND cluster=? AND namespace=? AND deleted=0",
-		status, name, cluster, namespace).Exec()
+	_, err := am.tOrmer.Raw("UPDATE "+am.TableName+" SET deploy_status=? WHERE name=? AND cluster=? AND namespace=? AND deleted=?",
+		status, name, cluster, namespace, false).Exec()
 	return err
 }
 
@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// ArchiveModel move the publish jobs and operation logs out of the retention of project into the archive tables
+type ArchiveModel struct {
+	jobTableName        string
+	jobArchiveTableName string
+	logTableName        string
+	logArchiveTableName string
+	publishTableName    string
+}
+
+// NewArchiveModel ...
+func NewArchiveModel() *ArchiveModel {
+	return &ArchiveModel{
+		jobTableName:        (&models.PublishJob{}).TableName(),
+		jobArchiveTableName: (&models.PublishJobArchive{}).TableName(),
+		logTableName:        (&models.PublishOperationLog{}).TableName(),
+		logArchiveTableName: (&models.PublishOperationLogArchive{}).TableName(),
+		publishTableName:    (&models.Publish{}).TableName(),
+	}
+}
+
+// ArchivePublishJobs move at most limit finished jobs of project created before into the archive table, return the number archived
+func (model *ArchiveModel) ArchivePublishJobs(projectID int64, before time.Time, limit int) (int64, error) {
+	var ids orm.ParamsList
+	_, err := GetOrmer().QueryTable(model.jobTableName).
+		Filter("project_id", projectID).
+		Filter("create_at__lt", before).
+		Exclude("status__in", models.StatusInit, models.StatusRunning).
+		OrderBy("id").Limit(limit).ValuesFlat(&ids, "id")
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	err = model.move(model.jobTableName, model.jobArchiveTableName, modelColumns(&models.PublishJob{}), nil, ids)
+	return int64(len(ids)), err
+}
+
+// ArchiveOperationLogs move at most limit operation logs of project created before into the archive table, return the number archived
+func (model *ArchiveModel) ArchiveOperationLogs(projectID int64, before time.Time, limit int) (int64, error) {
+	var ids orm.ParamsList
+	sql := fmt.Sprintf("select id from %s where publish_id in (select id from %s where project_id = ?) and create_at < ? order by id limit %d",
+		model.logTableName, model.publishTableName, limit)
+	if _, err := GetOrmer().Raw(sql, projectID, before).ValuesFlat(&ids); err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	extra := []archiveColumn{{name: "project_id", value: projectID}}
+	err := model.move(model.logTableName, model.logArchiveTableName, modelColumns(&models.PublishOperationLog{}), extra, ids)
+	return int64(len(ids)), err
+}
+
+// archiveColumn the column of archive table not in the primary table
+type archiveColumn struct {
+	name  string
+	value int64
+}
+
+// move copy the rows into the archive table and delete them in one transaction, the extra columns of archive table are filled by the values
+func (model *ArchiveModel) move(table, archiveTable string, columns []string, extra []archiveColumn, ids orm.ParamsList) error {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quote(column)
+	}
+	insertColumns, selectColumns := strings.Join(quoted, ","), strings.Join(quoted, ",")
+	for _, column := range extra {
+		insertColumns += "," + quote(column.name)
+		selectColumns += fmt.Sprintf(",%d", column.value)
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := []interface{}(ids)
+	archivedAt := time.Now()
+
+	// the transaction of the shared ormer would be used by the other goroutines
+	ormer := orm.NewOrm()
+	return Transactional(ormer, func() error {
+		if _, err := ormer.Raw(fmt.Sprintf("insert into %s(%s) select %s from %s where id in (%s)",
+			archiveTable, insertColumns, selectColumns, table, marks), args...).Exec(); err != nil {
+			return err
+		}
+		// the parameters in the select list are untyped in postgres, set the time by update
+		if _, err := ormer.Raw(fmt.Sprintf("update %s set archived_at = ? where id in (%s)", archiveTable, marks),
+			append([]interface{}{archivedAt}, args...)...).Exec(); err != nil {
+			return err
+		}
+		_, err := ormer.Raw(fmt.Sprintf("delete from %s where id in (%s)", table, marks), args...).Exec()
+		return err
+	})
+}
+
+// CountArchives count the archived jobs and operation logs created before, projectID 0 means all projects
+func (model *ArchiveModel) CountArchives(projectID int64, before time.Time) (jobs, operationLogs int64, err error) {
+	if jobs, err = model.archiveQuerySeter(model.jobArchiveTableName, projectID, before).Count(); err != nil {
+		return
+	}
+	operationLogs, err = model.archiveQuerySeter(model.logArchiveTableName, projectID, before).Count()
+	return
+}
+
+// PurgeArchives delete the archived jobs and operation logs created before permanently, projectID 0 means all projects
+func (model *ArchiveModel) PurgeArchives(projectID int64, before time.Time) (jobs, operationLogs int64, err error) {
+	if jobs, err = model.archiveQuerySeter(model.jobArchiveTableName, projectID, before).Delete(); err != nil {
+		return
+	}
+	operationLogs, err = model.archiveQuerySeter(model.logArchiveTableName, projectID, before).Delete()
+	return
+}
+
+func (model *ArchiveModel) archiveQuerySeter(table string, projectID int64, before time.Time) orm.QuerySeter {
+	qs := GetOrmer().QueryTable(table).Filter("create_at__lt", before)
+	if projectID > 0 {
+		qs = qs.Filter("project_id", projectID)
+	}
+	return qs
+}
+
+// modelColumns the columns of the orm model in the field order, including the embedded structs
+func modelColumns(model interface{}) []string {
+	columns := []string{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("orm")
+			if tag == "-" || field.PkgPath != "" {
+				continue
+			}
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+				continue
+			}
+			columns = append(columns, columnName(field.Name, tag))
+		}
+	}
+	walk(reflect.Indirect(reflect.ValueOf(model)).Type())
+	return columns
+}
+
+// columnName the column(...) of the orm tag, or the snake case of the field name like the orm
+func columnName(fieldName, tag string) string {
+	for _, attr := range strings.Split(tag, ";") {
+		attr = strings.TrimSpace(attr)
+		if strings.HasPrefix(attr, "column(") && strings.HasSuffix(attr, ")") {
+			return strings.TrimSuffix(strings.TrimPrefix(attr, "column("), ")")
+		}
+	}
+	name := []rune{}
+	for i, r := range fieldName {
+		if i > 0 && unicode.IsUpper(r) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToLower(r))
+	}
+	return string(name)
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+func TestModelColumns(t *testing.T) {
+	want := []string{"id", "deleted", "create_at", "update_at", "delete_at",
+		"publish_id", "project_id", "status", "run_id", "progress", "duration_in_millis",
+		"stage_id", "operator", "job_type", "step_index", "ci_version", "ci_server", "wave", "wave_start_at"}
+	if got := modelColumns(&models.PublishJob{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("modelColumns(PublishJob) = %v, want %v", got, want)
+	}
+	// the columns ignored by the orm are skipped
+	for _, column := range modelColumns(&models.Publish{}) {
+		if column == "operations" || column == "next_step" {
+			t.Errorf("unexpected column: %v", column)
+		}
+	}
+	// the same as the snake case of the orm for the field without column tag
+	if got := columnName("ProjectAppID", ""); got != "project_app_i_d" {
+		t.Errorf("columnName = %v", got)
+	}
+}
@@ -29,6 +29,7 @@ type AppArrangeModel struct {
 	ormer                    orm.Ormer
 	AppArrangeTableName      string
 	AppImageMappingTableName string
+	AppArrangeRevisionTable  string
 }
 
 // NewAppArrangeModel ...
@@ -37,6 +38,7 @@ func NewAppArrangeModel() (model *AppArrangeModel) {
 		ormer:                    GetOrmer(),
 		AppArrangeTableName:      (&models.AppArrange{}).TableName(),
 		AppImageMappingTableName: (&models.AppImageMapping{}).TableName(),
+		AppArrangeRevisionTable:  (&models.AppArrangeRevision{}).TableName(),
 	}
 }
 
@@ -98,6 +100,19 @@ func (model *AppArrangeModel) GetAppImageMappingByArrangeIDAndProjectAppID(arran
 	return &imageMapping, err
 }
 
+// GetAppImageMappingsByArrangeIDs return the image mappings of arranges in batch, order by id
+func (model *AppArrangeModel) GetAppImageMappingsByArrangeIDs(arrangeIDs []int64) ([]*models.AppImageMapping, error) {
+	imageMappings := []*models.AppImageMapping{}
+	if len(arrangeIDs) == 0 {
+		return imageMappings, nil
+	}
+	_, err := model.ormer.QueryTable(model.AppImageMappingTableName).
+		Filter("deleted", false).
+		Filter("arrange_id__in", arrangeIDs).
+		OrderBy("id").All(&imageMappings)
+	return imageMappings, err
+}
+
 // InsertAppImageMapping ...
 func (model *AppArrangeModel) InsertAppImageMapping(appImageMappingItem *models.AppImageMapping) (int64, error) {
 	id, err := model.ormer.Insert(appImageMappingItem)
@@ -116,18 +131,40 @@ func (model *AppArrangeModel) DeleteAppImageMapping(appImageMappingItem *models.
 	return err
 }
 
-// GetAppArrange ...
+// GetAppArrange the arrange is cached, since it is read by every deploy job of the publish
 func (model *AppArrangeModel) GetAppArrange(appID, envID int64) (*models.AppArrange, error) {
 	arrange := &models.AppArrange{}
 	qs := model.ormer.QueryTable(model.AppArrangeTableName).Filter("deleted", false)
 	if appID == 0 || envID == 0 {
 		return nil, fmt.Errorf("args invalidate app id: %v, env id: %v", appID, envID)
 	}
+	if cacheGet(appArrangeCacheKey(appID, envID), arrange) {
+		return arrange, nil
+	}
 	qs = qs.Filter("project_app_id", appID).Filter("env_id", envID)
 	err := qs.One(arrange)
+	if err == nil {
+		cacheSet(appArrangeCacheKey(appID, envID), arrange)
+	}
 	return arrange, err
 }
 
+// GetAppArrangesByEnvID return the arranges of apps in env in batch, the cache is skipped
+func (model *AppArrangeModel) GetAppArrangesByEnvID(envID int64, appIDs []int64) ([]*models.AppArrange, error) {
+	arranges := []*models.AppArrange{}
+	if envID == 0 {
+		return nil, fmt.Errorf("args invalidate env id: %v", envID)
+	}
+	if len(appIDs) == 0 {
+		return arranges, nil
+	}
+	_, err := model.ormer.QueryTable(model.AppArrangeTableName).
+		Filter("deleted", false).
+		Filter("env_id", envID).
+		Filter("project_app_id__in", appIDs).All(&arranges)
+	return arranges, err
+}
+
 // AppArrangeIsExisted check
 func (model *AppArrangeModel) AppArrangeIsExisted(AppID int64, arrangeEnv string) bool {
 	return model.ormer.QueryTable(model.AppArrangeTableName).Filter("deleted", false).Filter("publish_app_id", AppID).Filter("arrange_env", arrangeEnv).Exist()
@@ -136,6 +173,7 @@ func (model *AppArrangeModel) AppArrangeIsExisted(AppID int64, arrangeEnv string
 // InsertOrUpdateAppArrange ...
 func (model *AppArrangeModel) InsertAppArrange(arrange *models.AppArrange) error {
 	_, err := model.ormer.Insert(arrange)
+	cacheDelete(appArrangeCacheKey(arrange.ProjectAppID, arrange.EnvID))
 	return err
 }
 
@@ -147,11 +185,34 @@ func (model *AppArrangeModel) DeleteAppArrange(AppID, envID int64) error {
 	}
 	arrange.MarkDeleted()
 	_, err = model.ormer.Delete(arrange)
+	cacheDelete(appArrangeCacheKey(AppID, envID))
 	return err
 }
 
 // UpdateAppArrange ...
 func (model *AppArrangeModel) UpdateAppArrange(arrange *models.AppArrange) error {
 	_, err := model.ormer.Update(arrange)
+	cacheDelete(appArrangeCacheKey(arrange.ProjectAppID, arrange.EnvID))
 	return err
 }
+
+// InsertAppArrangeRevision ...
+func (model *AppArrangeModel) InsertAppArrangeRevision(revision *models.AppArrangeRevision) (int64, error) {
+	return model.ormer.Insert(revision)
+}
+
+// GetAppArrangeRevisions return the revisions of arrange, the latest first
+func (model *AppArrangeModel) GetAppArrangeRevisions(arrangeID int64) ([]*models.AppArrangeRevision, error) {
+	revisions := []*models.AppArrangeRevision{}
+	qs := model.ormer.QueryTable(model.AppArrangeRevisionTable).Filter("deleted", false)
+	_, err := qs.Filter("arrange_id", arrangeID).OrderBy("-revision").All(&revisions)
+	return revisions, err
+}
+
+// GetAppArrangeRevision ...
+func (model *AppArrangeModel) GetAppArrangeRevision(arrangeID, revision int64) (*models.AppArrangeRevision, error) {
+	item := &models.AppArrangeRevision{}
+	qs := model.ormer.QueryTable(model.AppArrangeRevisionTable).Filter("deleted", false)
+	err := qs.Filter("arrange_id", arrangeID).Filter("revision", revision).One(item)
+	return item, err
+}
@@ -17,7 +17,13 @@ limitations under the License.
 package dao
 
 import (
+	"time"
+
 	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils/query"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/astaxie/beego/orm"
 )
 
 func AuditInsert(audit *models.Audit) error {
@@ -27,7 +33,73 @@ func AuditInsert(audit *models.Audit) error {
 
 func AuditList() ([]*models.Audit, error) {
 	auditList := []*models.Audit{}
-	if _, err := GetOrmer().QueryTable("sys_audit").OrderBy("-create_at").All(&auditList); err != nil {
+	if _, err := GetReadOrmer().QueryTable("sys_audit").OrderBy("-create_at").All(&auditList); err != nil {
+		return nil, err
+	}
+	return auditList, nil
+}
+
+func auditQuerySeter(filter *models.AuditFilterQuery) orm.QuerySeter {
+	cond := orm.NewCondition()
+	if filter.User != "" {
+		cond = cond.And("user", filter.User)
+	}
+	if filter.ResourceType != "" {
+		cond = cond.And("resource_type", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		cond = cond.And("resource_id", filter.ResourceID)
+	}
+	if filter.Action != "" {
+		cond = cond.And("action", filter.Action)
+	}
+	if filter.IP != "" {
+		cond = cond.And("ip", filter.IP)
+	}
+	if filter.CreateAtStart != "" {
+		if createAtStart, err := time.Parse("2006-01-02", filter.CreateAtStart); err == nil {
+			cond = cond.And("create_at__gte", createAtStart)
+		} else {
+			logs.Error("time parse error: %s", err.Error())
+		}
+	}
+	if filter.CreateAtEnd != "" {
+		if createAtEnd, err := time.Parse("2006-01-02", filter.CreateAtEnd); err == nil {
+			// the end date is inclusive
+			cond = cond.And("create_at__lt", createAtEnd.AddDate(0, 0, 1))
+		} else {
+			logs.Error("time parse error: %s", err.Error())
+		}
+	}
+	if filterCond := query.FilterCondition(&filter.FilterQuery, "operation"); filterCond != nil {
+		cond = cond.AndCond(filterCond)
+	}
+	return GetReadOrmer().QueryTable("sys_audit").SetCond(cond).OrderBy("-create_at")
+}
+
+// AuditListByPagination ..
+func AuditListByPagination(filter *models.AuditFilterQuery) (*query.QueryResult, error) {
+	rst := &query.QueryResult{Item: []*models.Audit{}}
+	qs := auditQuerySeter(filter)
+	count, err := qs.Count()
+	if err != nil {
+		return nil, err
+	}
+	if err = query.FillPageInfo(rst, filter.PageIndex, filter.PageSize, int(count)); err != nil {
+		return nil, err
+	}
+	auditList := []*models.Audit{}
+	if _, err := qs.Limit(filter.PageSize, filter.PageSize*(filter.PageIndex-1)).All(&auditList); err != nil {
+		return nil, err
+	}
+	rst.Item = auditList
+	return rst, nil
+}
+
+// AuditListByFilter return the latest audits match the filter, at most limit items
+func AuditListByFilter(filter *models.AuditFilterQuery, limit int) ([]*models.Audit, error) {
+	auditList := []*models.Audit{}
+	if _, err := auditQuerySeter(filter).Limit(limit).All(&auditList); err != nil {
 		return nil, err
 	}
 	return auditList, nil
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/redis"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/logs"
+)
+
+// cacheKeyPrefix the prefix of keys in the redis shared with other applications
+const cacheKeyPrefix = "atomci:cache:"
+
+// cacheStore the backend of the hot read cache, the process memory by default,
+// or the redis shared by the replicas so the invalidation of one replica is seen by the others
+type cacheStore interface {
+	get(key string) ([]byte, bool)
+	set(key string, value []byte, ttl time.Duration)
+	delete(keys ...string)
+}
+
+var (
+	cacheOnce   sync.Once
+	cacheTTL    time.Duration
+	sharedCache cacheStore
+)
+
+// getCacheStore return nil if the cache is disabled
+func getCacheStore() cacheStore {
+	cacheOnce.Do(func() {
+		if !beego.AppConfig.DefaultBool("cache::enable", true) {
+			return
+		}
+		cacheTTL = time.Duration(beego.AppConfig.DefaultInt("cache::ttl", 30)) * time.Second
+		if addr := beego.AppConfig.String("cache::redis"); addr != "" {
+			sharedCache = &redisCacheStore{client: redis.NewClient(addr,
+				beego.AppConfig.String("cache::redis_password"),
+				beego.AppConfig.DefaultInt("cache::redis_db", 0),
+				time.Second)}
+			return
+		}
+		sharedCache = &memoryCacheStore{}
+	})
+	return sharedCache
+}
+
+// cacheGet decode the cached value of key into value, return false if missed
+func cacheGet(key string, value interface{}) bool {
+	store := getCacheStore()
+	if store == nil {
+		return false
+	}
+	data, ok := store.get(key)
+	if !ok {
+		return false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(value); err != nil {
+		logs.Warn("decode cache: %v occur error: %s", key, err.Error())
+		return false
+	}
+	return true
+}
+
+// cacheSet cache the copy of value, so the changes of the caller are not seen by others
+func cacheSet(key string, value interface{}) {
+	store := getCacheStore()
+	if store == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		logs.Warn("encode cache: %v occur error: %s", key, err.Error())
+		return
+	}
+	store.set(key, buf.Bytes(), cacheTTL)
+}
+
+// cacheDelete invalidate the keys, called by the writes
+func cacheDelete(keys ...string) {
+	if store := getCacheStore(); store != nil {
+		store.delete(keys...)
+	}
+}
+
+func projectAppCacheKey(projectAppID int64) string {
+	return fmt.Sprintf("project-app:%d", projectAppID)
+}
+
+func integrateSettingCacheKey(settingID int64) string {
+	return fmt.Sprintf("integrate-setting:%d", settingID)
+}
+
+func appArrangeCacheKey(projectAppID, envID int64) string {
+	return fmt.Sprintf("app-arrange:%d:%d", projectAppID, envID)
+}
+
+type memoryCacheItem struct {
+	value    []byte
+	expireAt time.Time
+}
+
+// memoryCacheStore the invalidation is only seen by the current replica, the others see the changes after ttl
+type memoryCacheStore struct {
+	items sync.Map
+}
+
+func (s *memoryCacheStore) get(key string) ([]byte, bool) {
+	cached, ok := s.items.Load(key)
+	if !ok {
+		return nil, false
+	}
+	item := cached.(*memoryCacheItem)
+	if time.Now().After(item.expireAt) {
+		s.items.Delete(key)
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (s *memoryCacheStore) set(key string, value []byte, ttl time.Duration) {
+	s.items.Store(key, &memoryCacheItem{value: value, expireAt: time.Now().Add(ttl)})
+}
+
+func (s *memoryCacheStore) delete(keys ...string) {
+	for _, key := range keys {
+		s.items.Delete(key)
+	}
+}
+
+// redisCacheStore the errors are treated as missed, the database is always the source of truth
+type redisCacheStore struct {
+	client *redis.Client
+}
+
+func (s *redisCacheStore) get(key string) ([]byte, bool) {
+	value, err := s.client.Get(cacheKeyPrefix + key)
+	if err != nil {
+		logs.Warn("get cache: %v from redis occur error: %s", key, err.Error())
+		return nil, false
+	}
+	return value, value != nil
+}
+
+func (s *redisCacheStore) set(key string, value []byte, ttl time.Duration) {
+	if err := s.client.Set(cacheKeyPrefix+key, value, ttl); err != nil {
+		logs.Warn("set cache: %v to redis occur error: %s", key, err.Error())
+	}
+}
+
+func (s *redisCacheStore) delete(keys ...string) {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = cacheKeyPrefix + key
+	}
+	if err := s.client.Del(prefixed...); err != nil {
+		logs.Error("delete cache: %v from redis occur error: %s", keys, err.Error())
+	}
+}
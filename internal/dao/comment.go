@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// CommentModel ...
+type CommentModel struct {
+	ormer               orm.Ormer
+	commentTableName    string
+	attachmentTableName string
+}
+
+// NewCommentModel ...
+func NewCommentModel() (model *CommentModel) {
+	return &CommentModel{
+		ormer:               GetOrmer(),
+		commentTableName:    (&models.PublishComment{}).TableName(),
+		attachmentTableName: (&models.PublishCommentAttachment{}).TableName(),
+	}
+}
+
+// the columns of attachment list, the content is only returned by download
+var attachmentListColumns = []string{"id", "create_at", "update_at", "comment_id", "publish_id", "name", "content_type", "size"}
+
+// CreatePublishComment create the comment with its attachments
+func (model *CommentModel) CreatePublishComment(comment *models.PublishComment, attachments []*models.PublishCommentAttachment) error {
+	id, err := model.ormer.Insert(comment)
+	if err != nil {
+		return err
+	}
+	for _, item := range attachments {
+		item.CommentID = id
+		item.PublishID = comment.PublishID
+	}
+	if len(attachments) > 0 {
+		_, err = model.ormer.InsertMulti(len(attachments), attachments)
+	}
+	return err
+}
+
+// GetPublishComment ..
+func (model *CommentModel) GetPublishComment(commentID int64) (*models.PublishComment, error) {
+	item := models.PublishComment{}
+	if err := model.ormer.QueryTable(model.commentTableName).Filter("deleted", false).
+		Filter("id", commentID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetPublishComments return the comments of publish, the earliest is the first
+func (model *CommentModel) GetPublishComments(publishID int64) ([]*models.PublishComment, error) {
+	items := []*models.PublishComment{}
+	_, err := model.ormer.QueryTable(model.commentTableName).Filter("deleted", false).
+		Filter("publish_id", publishID).OrderBy("id").Limit(-1).All(&items)
+	return items, err
+}
+
+// UpdatePublishComment ..
+func (model *CommentModel) UpdatePublishComment(comment *models.PublishComment) error {
+	_, err := model.ormer.Update(comment)
+	return err
+}
+
+// DeletePublishComment mark the comment and its attachments deleted
+func (model *CommentModel) DeletePublishComment(comment *models.PublishComment) error {
+	comment.MarkDeleted()
+	if _, err := model.ormer.Update(comment); err != nil {
+		return err
+	}
+	_, err := model.ormer.QueryTable(model.attachmentTableName).Filter("comment_id", comment.ID).Update(orm.Params{"deleted": true})
+	return err
+}
+
+// GetPublishAttachments return the attachments of publish comments without content
+func (model *CommentModel) GetPublishAttachments(publishID int64) ([]*models.PublishCommentAttachment, error) {
+	items := []*models.PublishCommentAttachment{}
+	_, err := model.ormer.QueryTable(model.attachmentTableName).Filter("deleted", false).
+		Filter("publish_id", publishID).OrderBy("id").Limit(-1).All(&items, attachmentListColumns...)
+	return items, err
+}
+
+// GetCommentAttachment return the attachment with content
+func (model *CommentModel) GetCommentAttachment(attachmentID int64) (*models.PublishCommentAttachment, error) {
+	item := models.PublishCommentAttachment{}
+	if err := model.ormer.QueryTable(model.attachmentTableName).Filter("deleted", false).
+		Filter("id", attachmentID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -135,13 +135,13 @@ func (model *PipelineStageModel) GetTaskTmplsByPagination(filter *query.FilterQu
 
 // CreateFlowComponent ...
 func (model *PipelineStageModel) CreateFlowComponent(comp *models.FlowComponent) error {
-	_, err := model.ormer.InsertOrUpdate(comp)
+	_, err := insertOrUpdate(model.ormer, comp)
 	return err
 }
 
 // CreateTaskTmpl ...
 func (model *PipelineStageModel) CreateTaskTmpl(step *models.TaskTmpl) error {
-	_, err := model.ormer.InsertOrUpdate(step)
+	_, err := insertOrUpdate(model.ormer, step)
 	return err
 }
 
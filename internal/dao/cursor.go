@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/utils/query"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// cursorQuerySeter filter the items after the cursor and sort them by the cursor, fetch one more to tell whether there is a next page
+func cursorQuerySeter(qs orm.QuerySeter, cond *orm.Condition, cursor *query.CursorQuery) orm.QuerySeter {
+	op, order := "__lt", "-"
+	if cursor.Asc {
+		op, order = "__gt", ""
+	}
+	orders := []string{order + "id"}
+	if cursor.SortKey != "" {
+		orders = []string{order + cursor.SortKey, order + "id"}
+	}
+	if cursor.After > 0 {
+		if cursor.SortKey == "" {
+			cond = cond.And("id"+op, cursor.After)
+		} else {
+			// the items of the same sort key are sorted by id
+			cond = cond.AndCond(orm.NewCondition().
+				And(cursor.SortKey+op, cursor.AfterKey).
+				OrCond(orm.NewCondition().And(cursor.SortKey, cursor.AfterKey).And("id"+op, cursor.After)))
+		}
+	}
+	return qs.SetCond(cond).OrderBy(orders...).Limit(cursor.Limit + 1)
+}
+
+// createAtCond filter the items created in the time range, the nil means unbounded
+func createAtCond(cond *orm.Condition, start, end *time.Time) *orm.Condition {
+	if start != nil {
+		cond = cond.And("create_at__gte", *start)
+	}
+	if end != nil {
+		cond = cond.And("create_at__lt", *end)
+	}
+	return cond
+}
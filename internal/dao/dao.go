@@ -19,12 +19,17 @@ package dao
 import (
 	"sync"
 
+	"github.com/go-atomci/atomci/internal/models"
+
 	"github.com/astaxie/beego/orm"
 )
 
 var globalOrm orm.Ormer
 var once sync.Once
 
+var readOrm orm.Ormer
+var readOnce sync.Once
+
 // GetOrmer :set ormer singleton
 func GetOrmer() orm.Ormer {
 	once.Do(func() {
@@ -33,6 +38,20 @@ func GetOrmer() orm.Ormer {
 	return globalOrm
 }
 
+// GetReadOrmer the ormer of the read replica for the heavy list queries,
+// it is the default ormer if no replica configured
+func GetReadOrmer() orm.Ormer {
+	readOnce.Do(func() {
+		if _, err := orm.GetDB(models.ReplicaAlias); err != nil {
+			readOrm = GetOrmer()
+			return
+		}
+		readOrm = orm.NewOrm()
+		readOrm.Using(models.ReplicaAlias)
+	})
+	return readOrm
+}
+
 // Transactional invoke lambda function within transaction
 func Transactional(ormer orm.Ormer, handle func() error) (err error) {
 	err = ormer.Begin()
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// DBMigrationModel ...
+type DBMigrationModel struct {
+	ormer                orm.Ormer
+	dbMigrationTableName string
+}
+
+// NewDBMigrationModel ...
+func NewDBMigrationModel() (model *DBMigrationModel) {
+	return &DBMigrationModel{
+		ormer:                GetOrmer(),
+		dbMigrationTableName: (&models.DBMigration{}).TableName(),
+	}
+}
+
+// CreateDBMigration ..
+func (model *DBMigrationModel) CreateDBMigration(migration *models.DBMigration) (int64, error) {
+	return model.ormer.Insert(migration)
+}
+
+// UpdateDBMigration ..
+func (model *DBMigrationModel) UpdateDBMigration(migration *models.DBMigration, cols ...string) error {
+	_, err := model.ormer.Update(migration, cols...)
+	return err
+}
+
+// GetDBMigrationByID ..
+func (model *DBMigrationModel) GetDBMigrationByID(id int64) (*models.DBMigration, error) {
+	migration := &models.DBMigration{}
+	err := model.ormer.QueryTable(model.dbMigrationTableName).
+		Filter("deleted", false).
+		Filter("id", id).One(migration)
+	return migration, err
+}
+
+// GetDBMigrations return the migrations of publish, the stage id 0 means all stages
+func (model *DBMigrationModel) GetDBMigrations(publishID, stageID int64) ([]*models.DBMigration, error) {
+	migrations := []*models.DBMigration{}
+	qs := model.ormer.QueryTable(model.dbMigrationTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID)
+	if stageID != 0 {
+		qs = qs.Filter("stage_id", stageID)
+	}
+	_, err := qs.OrderBy("-id").All(&migrations)
+	return migrations, err
+}
+
+// GetJobDBMigration return the migration of app executed by the publish job
+func (model *DBMigrationModel) GetJobDBMigration(publishJobID, projectAppID int64) (*models.DBMigration, error) {
+	migration := &models.DBMigration{}
+	err := model.ormer.QueryTable(model.dbMigrationTableName).
+		Filter("deleted", false).
+		Filter("publish_job_id", publishJobID).
+		Filter("project_app_id", projectAppID).
+		OrderBy("-id").Limit(1).One(migration)
+	return migration, err
+}
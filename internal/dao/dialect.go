@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// driverType the raw sql is written for mysql, postgres and sqlite, the differences are built by the helpers below
+func driverType() orm.DriverType {
+	return GetOrmer().Driver().Type()
+}
+
+// quote quote the column name, such as the reserved words group, user and constraint
+func quote(name string) string {
+	return quoteFor(driverType(), name)
+}
+
+func quoteFor(driver orm.DriverType, name string) string {
+	if driver == orm.DRMySQL {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// insertIgnoreSQL build the insert statement which skips the rows conflicting with the unique keys
+func insertIgnoreSQL(table string, columns []string, values string) string {
+	return insertIgnoreSQLFor(driverType(), table, columns, values)
+}
+
+func insertIgnoreSQLFor(driver orm.DriverType, table string, columns []string, values string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteFor(driver, column)
+	}
+	switch driver {
+	case orm.DRPostgres:
+		return fmt.Sprintf("insert into %s(%s) values%s on conflict do nothing", table, strings.Join(quoted, ","), values)
+	case orm.DRSqlite:
+		return fmt.Sprintf("insert or ignore into %s(%s) values%s", table, strings.Join(quoted, ","), values)
+	default:
+		return fmt.Sprintf("insert ignore into %s(%s) values%s", table, strings.Join(quoted, ","), values)
+	}
+}
+
+// insertOrUpdate the InsertOrUpdate of beego works on mysql only, postgres needs the conflict column and sqlite is
+// not supported, so the others insert the new record or update the existing one by the primary key
+func insertOrUpdate(ormer orm.Ormer, md interface{}) (int64, error) {
+	if ormer.Driver().Type() == orm.DRMySQL {
+		return ormer.InsertOrUpdate(md)
+	}
+	if id := reflect.Indirect(reflect.ValueOf(md)).FieldByName("ID"); id.IsValid() && id.Int() > 0 {
+		if _, err := ormer.Update(md); err != nil {
+			return 0, err
+		}
+		return id.Int(), nil
+	}
+	return ormer.Insert(md)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+type fakeDriver orm.DriverType
+
+func (d fakeDriver) Name() string         { return "default" }
+func (d fakeDriver) Type() orm.DriverType { return orm.DriverType(d) }
+
+// fakeOrmer record the write operations
+type fakeOrmer struct {
+	orm.Ormer
+	driver orm.DriverType
+	calls  []string
+}
+
+func (o *fakeOrmer) Driver() orm.Driver { return fakeDriver(o.driver) }
+
+func (o *fakeOrmer) InsertOrUpdate(md interface{}, colConflitAndArgs ...string) (int64, error) {
+	o.calls = append(o.calls, "InsertOrUpdate")
+	return 1, nil
+}
+
+func (o *fakeOrmer) Insert(md interface{}) (int64, error) {
+	o.calls = append(o.calls, "Insert")
+	return 2, nil
+}
+
+func (o *fakeOrmer) Update(md interface{}, cols ...string) (int64, error) {
+	o.calls = append(o.calls, "Update")
+	return 1, nil
+}
+
+func TestDialectSQL(t *testing.T) {
+	tests := []struct {
+		driver    orm.DriverType
+		quoted    string
+		insertSQL string
+	}{
+		{orm.DRMySQL, "`group`", "insert ignore into sys_group_user_rel(`group`,`user`) values(?,?)"},
+		{orm.DRPostgres, `"group"`, `insert into sys_group_user_rel("group","user") values(?,?) on conflict do nothing`},
+		{orm.DRSqlite, `"group"`, `insert or ignore into sys_group_user_rel("group","user") values(?,?)`},
+	}
+	for _, tt := range tests {
+		if got := quoteFor(tt.driver, "group"); got != tt.quoted {
+			t.Errorf("driver %v: quote() = %v, want %v", tt.driver, got, tt.quoted)
+		}
+		if got := insertIgnoreSQLFor(tt.driver, "sys_group_user_rel", []string{"group", "user"}, "(?,?)"); got != tt.insertSQL {
+			t.Errorf("driver %v: insertIgnoreSQL() = %v, want %v", tt.driver, got, tt.insertSQL)
+		}
+	}
+}
+
+func TestInsertOrUpdate(t *testing.T) {
+	tests := []struct {
+		driver orm.DriverType
+		id     int64
+		wantID int64
+		want   []string
+	}{
+		{orm.DRMySQL, 0, 1, []string{"InsertOrUpdate"}},
+		{orm.DRPostgres, 0, 2, []string{"Insert"}},
+		{orm.DRSqlite, 0, 2, []string{"Insert"}},
+		{orm.DRSqlite, 5, 5, []string{"Update"}},
+	}
+	for _, tt := range tests {
+		ormer := &fakeOrmer{driver: tt.driver}
+		env := &models.CompileEnv{Addons: models.Addons{ID: tt.id}}
+		id, err := insertOrUpdate(ormer, env)
+		if err != nil || id != tt.wantID {
+			t.Errorf("driver %v: insertOrUpdate() = %v, %v, want %v", tt.driver, id, err, tt.wantID)
+		}
+		if !reflect.DeepEqual(ormer.calls, tt.want) {
+			t.Errorf("driver %v: calls %v, want %v", tt.driver, ormer.calls, tt.want)
+		}
+	}
+}
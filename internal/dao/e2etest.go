@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// E2ETestReportModel ...
+type E2ETestReportModel struct {
+	ormer                  orm.Ormer
+	e2eTestReportTableName string
+}
+
+// NewE2ETestReportModel ...
+func NewE2ETestReportModel() (model *E2ETestReportModel) {
+	return &E2ETestReportModel{
+		ormer:                  GetOrmer(),
+		e2eTestReportTableName: (&models.E2ETestReport{}).TableName(),
+	}
+}
+
+// CreateE2ETestReport ..
+func (model *E2ETestReportModel) CreateE2ETestReport(report *models.E2ETestReport) (int64, error) {
+	return model.ormer.Insert(report)
+}
+
+// GetJobE2ETestReports return the reports of the suites run by the publish job
+func (model *E2ETestReportModel) GetJobE2ETestReports(publishJobID int64) ([]*models.E2ETestReport, error) {
+	reports := []*models.E2ETestReport{}
+	_, err := model.ormer.QueryTable(model.e2eTestReportTableName).
+		Filter("deleted", false).
+		Filter("publish_job_id", publishJobID).
+		OrderBy("id").All(&reports)
+	return reports, err
+}
+
+// GetE2ETestReports return the reports of publish, the stage id 0 means all stages
+func (model *E2ETestReportModel) GetE2ETestReports(publishID, stageID int64) ([]*models.E2ETestReport, error) {
+	reports := []*models.E2ETestReport{}
+	qs := model.ormer.QueryTable(model.e2eTestReportTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID)
+	if stageID != 0 {
+		qs = qs.Filter("stage_id", stageID)
+	}
+	_, err := qs.OrderBy("-id").All(&reports)
+	return reports, err
+}
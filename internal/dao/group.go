@@ -100,33 +100,33 @@ func InsertGroup(group *models.Group) (int64, error) {
 }
 
 func UpdateGroup(groupName, description string) error {
-	sql := `update sys_group set description=? where ` + "`group`" + `=? and description<>?`
+	sql := `update sys_group set description=? where ` + quote("group") + `=? and description<>?`
 	_, err := GetOrmer().Raw(sql, description, groupName, description).Exec()
 	return err
 }
 
 func DeleteGroup(groupName string) error {
 	// 删除用户组关联的用户
-	sql := `delete from sys_group_role_user where ` + "`group`" + `=?`
+	sql := `delete from sys_group_role_user where ` + quote("group") + `=?`
 	if _, err := GetOrmer().Raw(sql, groupName).Exec(); err != nil {
 		return err
 	}
-	sql = `delete from sys_group_user_constraint where ` + "`group`" + `=?`
+	sql = `delete from sys_group_user_constraint where ` + quote("group") + `=?`
 	if _, err := GetOrmer().Raw(sql, groupName).Exec(); err != nil {
 		return err
 	}
 	// 删除组角色
-	sql = `delete from sys_group_role where ` + "`group`" + `=?`
+	sql = `delete from sys_group_role where ` + quote("group") + `=?`
 	if _, err := GetOrmer().Raw(sql, groupName).Exec(); err != nil {
 		return err
 	}
-	sql = `delete from sys_group_role_operation where ` + "`group`" + `=?`
+	sql = `delete from sys_group_role_operation where ` + quote("group") + `=?`
 	if _, err := GetOrmer().Raw(sql, groupName).Exec(); err != nil {
 		return err
 	}
 
 	// 删除用户组
-	sql = `delete from sys_group where ` + "`group`" + `=?`
+	sql = `delete from sys_group where ` + quote("group") + `=?`
 	if _, err := GetOrmer().Raw(sql, groupName).Exec(); err != nil {
 		return err
 	}
@@ -136,13 +136,10 @@ func DeleteGroup(groupName string) error {
 
 // 用户组关联用户
 func GroupUserList(group string) ([]*models.GroupRoleUserRsp, error) {
-	// TODO: fix group by error colynn.
-	// Error 1055: Expression #1 of SELECT list is not in GROUP BY clause
-	// and contains nonaggregated column 'atomci.pgu.id' which is not functionally dependent
-	// on columns in GROUP BY clause;
-	// this is incompatible with sql_mode=only_full_group_by
-	sql := `select pgu.id,pgu.create_at,pgu.update_at,pgu.user,pg.name,pg.email from sys_group_role_user as pgu join sys_user as pg on 
-			pgu.user=pg.user where pgu.group=? group by pgu.user order by pgu.create_at desc;`
+	// the columns not grouped are aggregated, which is required by postgres and mysql only_full_group_by
+	sql := `select min(pgu.id) as id,min(pgu.create_at) as create_at,max(pgu.update_at) as update_at,pgu.user,pg.name,pg.email
+			from sys_group_role_user as pgu join sys_user as pg on pgu.user=pg.user where pgu.group=?
+			group by pgu.user,pg.name,pg.email order by min(pgu.create_at) desc`
 	users := []*models.GroupRoleUserRsp{}
 	if _, err := GetOrmer().Raw(sql, group).QueryRows(&users); err != nil {
 		return nil, err
@@ -160,8 +157,8 @@ func GroupUserList(group string) ([]*models.GroupRoleUserRsp, error) {
 func GetGroupUserRoles(group, user string) ([]*models.UserGroupRole, error) {
 	roles := []*models.UserGroupRole{}
 	sql := `select * from sys_group_role_user as a 
-		inner join sys_group_role as b on a.group = b.group and a.role = b.role 
-		where a.group = ? and a.user = ?`
+		inner join sys_group_role as b on a.` + quote("group") + ` = b.` + quote("group") + ` and a.role = b.role 
+		where a.` + quote("group") + ` = ? and a.` + quote("user") + ` = ?`
 	if _, err := GetOrmer().Raw(sql, group, user).QueryRows(&roles); err != nil {
 		if err != orm.ErrNoRows {
 			return nil, err
@@ -171,7 +168,7 @@ func GetGroupUserRoles(group, user string) ([]*models.UserGroupRole, error) {
 }
 
 func AddGroupUsers(groupUsers []*models.GroupRoleUser) error {
-	sql := `insert ignore into sys_group_role_user(` + "`group`" + `,user,role) values(?,?,?)`
+	sql := insertIgnoreSQL("sys_group_role_user", []string{"group", "user", "role"}, "(?,?,?)")
 	for _, user := range groupUsers {
 		if _, err := GetOrmer().Raw(sql, user.Group, user.User, user.Role).Exec(); err != nil {
 			return err
@@ -207,14 +204,14 @@ func GetGroupUserConstraint(group, user string) (map[string][]string, error) {
 }
 
 func DeleteGroupUserConstraint(group, user, constraint string) error {
-	sql := `delete from sys_group_user_constraint where ` + "`group`" + `=? and user=? and ` + "`constraint`" + `=?`
+	sql := `delete from sys_group_user_constraint where ` + quote("group") + `=? and ` + quote("user") + `=? and ` + quote("constraint") + `=?`
 	_, err := GetOrmer().Raw(sql, group, user, constraint).Exec()
 	return err
 }
 
 func AddGroupUserConstraintValues(group, user, constraint string, conValues []string) error {
 	for _, val := range conValues {
-		sql := `insert ignore into sys_group_user_constraint(` + "`group`" + `,user,` + "`constraint`" + `,value) values(?,?,?,?)`
+		sql := insertIgnoreSQL("sys_group_user_constraint", []string{"group", "user", "constraint", "value"}, "(?,?,?,?)")
 		if _, err := GetOrmer().Raw(sql, group, user, constraint, val).Exec(); err != nil {
 			return err
 		}
@@ -232,7 +229,7 @@ func UpdateGroupUserConstraintValues(group, user, constraint string, conValues [
 		}
 	}
 	delValues := []string{}
-	sql := `select value from sys_group_user_constraint where ` + "`group`" + `=? and user=? and ` + "`constraint`" + `=? and value not in (` + values + `)`
+	sql := `select value from sys_group_user_constraint where ` + quote("group") + `=? and ` + quote("user") + `=? and ` + quote("constraint") + `=? and value not in (` + values + `)`
 	if _, err := GetOrmer().Raw(sql, group, user, constraint).QueryRows(&delValues); err != nil {
 		return err
 	}
@@ -245,7 +242,7 @@ func UpdateGroupUserConstraintValues(group, user, constraint string, conValues [
 				values = values + "," + fmt.Sprintf("'%v'", val)
 			}
 		}
-		sql = `delete from sys_group_user_constraint where ` + "`group`" + `=? and user=? and ` + "`constraint`" + `=? and value in (` + values + `)`
+		sql = `delete from sys_group_user_constraint where ` + quote("group") + `=? and ` + quote("user") + `=? and ` + quote("constraint") + `=? and value in (` + values + `)`
 		if _, err := GetOrmer().Raw(sql, group, user, constraint).Exec(); err != nil {
 			return err
 		}
@@ -258,7 +255,7 @@ func UpdateGroupUserConstraintValues(group, user, constraint string, conValues [
 			values = values + "," + fmt.Sprintf("('%v','%v','%v','%v')", group, user, constraint, val)
 		}
 	}
-	sql = `insert ignore into sys_group_user_constraint(` + "`group`" + `,user,` + "`constraint`" + `,value) values` + values
+	sql = insertIgnoreSQL("sys_group_user_constraint", []string{"group", "user", "constraint", "value"}, values)
 	if _, err := GetOrmer().Raw(sql).Exec(); err != nil {
 		return err
 	}
@@ -268,7 +265,7 @@ func UpdateGroupUserConstraintValues(group, user, constraint string, conValues [
 
 func DeleteGroupUserConstraintValues(group, user, constraint string, conValues []string) error {
 	for _, val := range conValues {
-		sql := `delete from sys_group_user_constraint where ` + "`group`" + `=? and user=? and ` + "`constraint`" + `=? and value=?`
+		sql := `delete from sys_group_user_constraint where ` + quote("group") + `=? and ` + quote("user") + `=? and ` + quote("constraint") + `=? and value=?`
 		if _, err := GetOrmer().Raw(sql, group, user, constraint, val).Exec(); err != nil {
 			return err
 		}
@@ -302,9 +299,15 @@ func GetGroupUserConstraintWithFilter(group, user string, constraintList []strin
 }
 
 func InsertGroupUserRel(groupId int64, userId int64) (int64, error) {
-	return GetOrmer().InsertOrUpdate(&models.GroupUserRel{
+	rel := &models.GroupUserRel{
 		GroupId: groupId,
 		UserId:  userId,
 		Addons:  models.NewAddons(),
-	})
+	}
+	if driverType() == orm.DRMySQL {
+		return GetOrmer().InsertOrUpdate(rel)
+	}
+	// the relation is unique by group and user
+	_, id, err := GetOrmer().ReadOrCreate(rel, "GroupId", "UserId")
+	return id, err
 }
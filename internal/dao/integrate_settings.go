@@ -17,6 +17,8 @@ limitations under the License.
 package dao
 
 import (
+	"time"
+
 	"github.com/go-atomci/atomci/internal/models"
 	"github.com/go-atomci/atomci/utils/query"
 
@@ -25,27 +27,45 @@ import (
 
 // SysSettingModel ...
 type SysSettingModel struct {
-	ormer                     orm.Ormer
-	IntegrateSettingTableName string
-	CompileEnvTableName       string
+	ormer                      orm.Ormer
+	IntegrateSettingTableName  string
+	CompileEnvTableName        string
+	CompileEnvVersionTableName string
+	HealthTableName            string
+	CredentialTableName        string
+	CapabilityTableName        string
+	CompileCommandTableName    string
+	CompileRevisionTableName   string
+	DockerfileTableName        string
 }
 
 // NewSysSettingModel ...
 func NewSysSettingModel() (model *SysSettingModel) {
 	return &SysSettingModel{
-		ormer:                     GetOrmer(),
-		IntegrateSettingTableName: (&models.IntegrateSetting{}).TableName(),
-		CompileEnvTableName:       (&models.CompileEnv{}).TableName(),
+		ormer:                      GetOrmer(),
+		IntegrateSettingTableName:  (&models.IntegrateSetting{}).TableName(),
+		CompileEnvTableName:        (&models.CompileEnv{}).TableName(),
+		CompileEnvVersionTableName: (&models.CompileEnvVersion{}).TableName(),
+		HealthTableName:            (&models.IntegrateSettingHealth{}).TableName(),
+		CredentialTableName:        (&models.IntegrateSettingCredential{}).TableName(),
+		CapabilityTableName:        (&models.ClusterCapability{}).TableName(),
+		CompileCommandTableName:    (&models.CompileCommandTemplate{}).TableName(),
+		CompileRevisionTableName:   (&models.CompileCommandRevision{}).TableName(),
+		DockerfileTableName:        (&models.DockerfileTemplate{}).TableName(),
 	}
 }
 
-// GetIntegrateSettingByID ...
+// GetIntegrateSettingByID the setting is cached, since it is read by every job of the publish
 func (model *SysSettingModel) GetIntegrateSettingByID(integrateSettingID int64) (*models.IntegrateSetting, error) {
 	integrateSetting := models.IntegrateSetting{}
+	if cacheGet(integrateSettingCacheKey(integrateSettingID), &integrateSetting) {
+		return &integrateSetting, nil
+	}
 	qs := model.ormer.QueryTable(model.IntegrateSettingTableName).Filter("deleted", false)
 	if err := qs.Filter("id", integrateSettingID).One(&integrateSetting); err != nil {
 		return nil, err
 	}
+	cacheSet(integrateSettingCacheKey(integrateSettingID), &integrateSetting)
 	return &integrateSetting, nil
 }
 
@@ -58,13 +78,16 @@ func (model *SysSettingModel) GetIntegrateSettingByName(name, integrateType stri
 	return &integrateSetting, nil
 }
 
-// GetIntegrateSettings ...
-func (model *SysSettingModel) GetIntegrateSettings(integrateTypes []string) ([]*models.IntegrateSetting, error) {
+// GetIntegrateSettings return the settings of the organizations, all the settings are returned if orgIDs is nil
+func (model *SysSettingModel) GetIntegrateSettings(integrateTypes []string, orgIDs []int64) ([]*models.IntegrateSetting, error) {
 	var integrateSettings []*models.IntegrateSetting
 	qs := model.ormer.QueryTable(model.IntegrateSettingTableName).Filter("deleted", false)
 	if len(integrateTypes) > 0 {
 		qs = qs.Filter("type__in", integrateTypes)
 	}
+	if orgIDs != nil {
+		qs = qs.Filter("org_id__in", orgIDs)
+	}
 	_, err := qs.All(&integrateSettings)
 	if err != nil {
 		return nil, err
@@ -73,7 +96,7 @@ func (model *SysSettingModel) GetIntegrateSettings(integrateTypes []string) ([]*
 }
 
 // GetIntegrateSettingsByPagination ..
-func (model *SysSettingModel) GetIntegrateSettingsByPagination(filter *query.FilterQuery, intergrateTypes []string) (*query.QueryResult, []*models.IntegrateSetting, error) {
+func (model *SysSettingModel) GetIntegrateSettingsByPagination(filter *query.FilterQuery, intergrateTypes []string, orgIDs []int64) (*query.QueryResult, []*models.IntegrateSetting, error) {
 	rst := &query.QueryResult{Item: []*models.IntegrateSetting{}}
 	queryCond := orm.NewCondition().AndCond(orm.NewCondition().And("deleted", false))
 
@@ -84,6 +107,9 @@ func (model *SysSettingModel) GetIntegrateSettingsByPagination(filter *query.Fil
 	if len(intergrateTypes) > 0 {
 		qs = qs.Filter("type__in", intergrateTypes)
 	}
+	if orgIDs != nil {
+		qs = qs.Filter("org_id__in", orgIDs)
+	}
 	count, err := qs.Count()
 	if err != nil {
 		return nil, nil, err
@@ -105,6 +131,7 @@ func (model *SysSettingModel) GetIntegrateSettingsByPagination(filter *query.Fil
 // UpdateIntegrateSetting ..
 func (model *SysSettingModel) UpdateIntegrateSetting(integrateSetting *models.IntegrateSetting) error {
 	_, err := model.ormer.Update(integrateSetting)
+	cacheDelete(integrateSettingCacheKey(integrateSetting.ID))
 	return err
 }
 
@@ -116,12 +143,14 @@ func (model *SysSettingModel) DeleteIntegrateSetting(integrateSettingID int64) e
 	}
 	integrateSetting.MarkDeleted()
 	_, err = model.ormer.Update(integrateSetting)
+	cacheDelete(integrateSettingCacheKey(integrateSettingID))
 	return err
 }
 
 // CreateIntegrateSetting ...
 func (model *SysSettingModel) CreateIntegrateSetting(integrateSetting *models.IntegrateSetting) error {
-	_, err := model.ormer.InsertOrUpdate(integrateSetting)
+	_, err := insertOrUpdate(model.ormer, integrateSetting)
+	cacheDelete(integrateSettingCacheKey(integrateSetting.ID))
 	return err
 }
 
@@ -145,13 +174,16 @@ func (model *SysSettingModel) GetCompileEnvByName(compileEnvItem string) (*model
 	return &compileEnv, nil
 }
 
-// GetCompileEnvs ...
-func (model *SysSettingModel) GetCompileEnvs(integrateType string) ([]*models.CompileEnv, error) {
+// GetCompileEnvs return the compile envs of the organizations, all the compile envs are returned if orgIDs is nil
+func (model *SysSettingModel) GetCompileEnvs(integrateType string, orgIDs []int64) ([]*models.CompileEnv, error) {
 	integrateSettings := []*models.CompileEnv{}
 	qs := model.ormer.QueryTable(model.CompileEnvTableName).Filter("deleted", false)
 	if integrateType != "" {
 		qs = qs.Filter("type", integrateType)
 	}
+	if orgIDs != nil {
+		qs = qs.Filter("org_id__in", orgIDs)
+	}
 	_, err := qs.All(&integrateSettings)
 	if err != nil {
 		return nil, err
@@ -160,7 +192,7 @@ func (model *SysSettingModel) GetCompileEnvs(integrateType string) ([]*models.Co
 }
 
 // GetCompileEnvsByPagination ..
-func (model *SysSettingModel) GetCompileEnvsByPagination(filter *query.FilterQuery) (*query.QueryResult, []*models.CompileEnv, error) {
+func (model *SysSettingModel) GetCompileEnvsByPagination(filter *query.FilterQuery, orgIDs []int64) (*query.QueryResult, []*models.CompileEnv, error) {
 	rst := &query.QueryResult{Item: []*models.CompileEnv{}}
 	queryCond := orm.NewCondition().AndCond(orm.NewCondition().And("deleted", false))
 
@@ -168,6 +200,9 @@ func (model *SysSettingModel) GetCompileEnvsByPagination(filter *query.FilterQue
 		queryCond = queryCond.AndCond(filterCond)
 	}
 	qs := model.ormer.QueryTable(model.CompileEnvTableName).OrderBy("-create_at").SetCond(queryCond)
+	if orgIDs != nil {
+		qs = qs.Filter("org_id__in", orgIDs)
+	}
 	count, err := qs.Count()
 	if err != nil {
 		return nil, nil, err
@@ -205,6 +240,254 @@ func (model *SysSettingModel) DeleteCompileEnv(integrateSettingID int64) error {
 
 // CreateCompileEnv ...
 func (model *SysSettingModel) CreateCompileEnv(integrateSetting *models.CompileEnv) error {
-	_, err := model.ormer.InsertOrUpdate(integrateSetting)
+	_, err := insertOrUpdate(model.ormer, integrateSetting)
+	return err
+}
+
+// GetCompileEnvVersions return the versions of compile env, the latest is the first
+func (model *SysSettingModel) GetCompileEnvVersions(compileEnvID int64) ([]*models.CompileEnvVersion, error) {
+	versions := []*models.CompileEnvVersion{}
+	_, err := model.ormer.QueryTable(model.CompileEnvVersionTableName).Filter("deleted", false).
+		Filter("compile_env_id", compileEnvID).OrderBy("-id").Limit(-1).All(&versions)
+	return versions, err
+}
+
+// GetCompileEnvVersion ...
+func (model *SysSettingModel) GetCompileEnvVersion(compileEnvID int64, version string) (*models.CompileEnvVersion, error) {
+	item := models.CompileEnvVersion{}
+	if err := model.ormer.QueryTable(model.CompileEnvVersionTableName).Filter("deleted", false).
+		Filter("compile_env_id", compileEnvID).Filter("version", version).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateCompileEnvVersion ...
+func (model *SysSettingModel) CreateCompileEnvVersion(version *models.CompileEnvVersion) error {
+	_, err := model.ormer.Insert(version)
+	return err
+}
+
+// GetCompileCommandTemplates ...
+func (model *SysSettingModel) GetCompileCommandTemplates() ([]*models.CompileCommandTemplate, error) {
+	items := []*models.CompileCommandTemplate{}
+	_, err := model.ormer.QueryTable(model.CompileCommandTableName).Filter("deleted", false).
+		OrderBy("name").Limit(-1).All(&items)
+	return items, err
+}
+
+// GetCompileCommandTemplateByID ...
+func (model *SysSettingModel) GetCompileCommandTemplateByID(templateID int64) (*models.CompileCommandTemplate, error) {
+	item := models.CompileCommandTemplate{}
+	if err := model.ormer.QueryTable(model.CompileCommandTableName).Filter("deleted", false).
+		Filter("id", templateID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetCompileCommandTemplateByName ...
+func (model *SysSettingModel) GetCompileCommandTemplateByName(name string) (*models.CompileCommandTemplate, error) {
+	item := models.CompileCommandTemplate{}
+	if err := model.ormer.QueryTable(model.CompileCommandTableName).Filter("deleted", false).
+		Filter("name", name).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetCompileCommandTemplateByLanguage return the default template of the language
+func (model *SysSettingModel) GetCompileCommandTemplateByLanguage(language string) (*models.CompileCommandTemplate, error) {
+	item := models.CompileCommandTemplate{}
+	if err := model.ormer.QueryTable(model.CompileCommandTableName).Filter("deleted", false).
+		Filter("language", language).OrderBy("id").Limit(1).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateCompileCommandTemplate ...
+func (model *SysSettingModel) CreateCompileCommandTemplate(item *models.CompileCommandTemplate) error {
+	_, err := model.ormer.Insert(item)
+	return err
+}
+
+// UpdateCompileCommandTemplate ...
+func (model *SysSettingModel) UpdateCompileCommandTemplate(item *models.CompileCommandTemplate) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+// GetCompileCommandRevisions return the revisions of compile command template, the latest is the first
+func (model *SysSettingModel) GetCompileCommandRevisions(templateID int64) ([]*models.CompileCommandRevision, error) {
+	items := []*models.CompileCommandRevision{}
+	_, err := model.ormer.QueryTable(model.CompileRevisionTableName).Filter("deleted", false).
+		Filter("template_id", templateID).OrderBy("-revision").Limit(-1).All(&items)
+	return items, err
+}
+
+// GetCompileCommandRevision ...
+func (model *SysSettingModel) GetCompileCommandRevision(templateID, revision int64) (*models.CompileCommandRevision, error) {
+	item := models.CompileCommandRevision{}
+	if err := model.ormer.QueryTable(model.CompileRevisionTableName).Filter("deleted", false).
+		Filter("template_id", templateID).Filter("revision", revision).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateCompileCommandRevision ...
+func (model *SysSettingModel) CreateCompileCommandRevision(item *models.CompileCommandRevision) error {
+	_, err := model.ormer.Insert(item)
+	return err
+}
+
+// GetDockerfileTemplates ...
+func (model *SysSettingModel) GetDockerfileTemplates() ([]*models.DockerfileTemplate, error) {
+	items := []*models.DockerfileTemplate{}
+	_, err := model.ormer.QueryTable(model.DockerfileTableName).Filter("deleted", false).
+		OrderBy("name").Limit(-1).All(&items)
+	return items, err
+}
+
+// GetDockerfileTemplateByID ...
+func (model *SysSettingModel) GetDockerfileTemplateByID(templateID int64) (*models.DockerfileTemplate, error) {
+	item := models.DockerfileTemplate{}
+	if err := model.ormer.QueryTable(model.DockerfileTableName).Filter("deleted", false).
+		Filter("id", templateID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetDockerfileTemplateByName ...
+func (model *SysSettingModel) GetDockerfileTemplateByName(name string) (*models.DockerfileTemplate, error) {
+	item := models.DockerfileTemplate{}
+	if err := model.ormer.QueryTable(model.DockerfileTableName).Filter("deleted", false).
+		Filter("name", name).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetDockerfileTemplateByLanguage return the default template of the language
+func (model *SysSettingModel) GetDockerfileTemplateByLanguage(language string) (*models.DockerfileTemplate, error) {
+	item := models.DockerfileTemplate{}
+	if err := model.ormer.QueryTable(model.DockerfileTableName).Filter("deleted", false).
+		Filter("language", language).OrderBy("id").Limit(1).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateDockerfileTemplate ...
+func (model *SysSettingModel) CreateDockerfileTemplate(item *models.DockerfileTemplate) error {
+	_, err := model.ormer.Insert(item)
+	return err
+}
+
+// UpdateDockerfileTemplate ...
+func (model *SysSettingModel) UpdateDockerfileTemplate(item *models.DockerfileTemplate) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+// GetIntegrateSettingHealths ...
+func (model *SysSettingModel) GetIntegrateSettingHealths() ([]*models.IntegrateSettingHealth, error) {
+	items := []*models.IntegrateSettingHealth{}
+	_, err := model.ormer.QueryTable(model.HealthTableName).
+		Filter("deleted", false).Limit(-1).All(&items)
+	return items, err
+}
+
+// GetIntegrateSettingHealth ...
+func (model *SysSettingModel) GetIntegrateSettingHealth(settingID int64) (*models.IntegrateSettingHealth, error) {
+	item := models.IntegrateSettingHealth{}
+	if err := model.ormer.QueryTable(model.HealthTableName).
+		Filter("deleted", false).Filter("setting_id", settingID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SaveIntegrateSettingHealth create or update the health of integrate setting
+func (model *SysSettingModel) SaveIntegrateSettingHealth(item *models.IntegrateSettingHealth) error {
+	if item.ID == 0 {
+		_, err := model.ormer.Insert(item)
+		return err
+	}
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+// GetIntegrateCredentials return the credential versions of integrate setting, the latest is the first
+func (model *SysSettingModel) GetIntegrateCredentials(settingID int64) ([]*models.IntegrateSettingCredential, error) {
+	items := []*models.IntegrateSettingCredential{}
+	_, err := model.ormer.QueryTable(model.CredentialTableName).Filter("deleted", false).
+		Filter("setting_id", settingID).OrderBy("-version").Limit(-1).All(&items)
+	return items, err
+}
+
+// GetIntegrateCredential ...
+func (model *SysSettingModel) GetIntegrateCredential(settingID int64, version int) (*models.IntegrateSettingCredential, error) {
+	item := models.IntegrateSettingCredential{}
+	if err := model.ormer.QueryTable(model.CredentialTableName).Filter("deleted", false).
+		Filter("setting_id", settingID).Filter("version", version).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateIntegrateCredential ...
+func (model *SysSettingModel) CreateIntegrateCredential(item *models.IntegrateSettingCredential) error {
+	_, err := model.ormer.Insert(item)
+	return err
+}
+
+// UpdateIntegrateCredential ...
+func (model *SysSettingModel) UpdateIntegrateCredential(item *models.IntegrateSettingCredential) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+// ActivateIntegrateCredential switch the config of setting to the credential and mark it as the only active version
+// within one transaction, so the readers never see a half switched setting
+func (model *SysSettingModel) ActivateIntegrateCredential(setting *models.IntegrateSetting, item *models.IntegrateSettingCredential) error {
+	now := time.Now()
+	defer cacheDelete(integrateSettingCacheKey(setting.ID))
+	return Transactional(model.ormer, func() error {
+		setting.Config = item.Config
+		setting.CredentialVersion = item.Version
+		if _, err := model.ormer.Update(setting, "config", "credential_version", "update_at"); err != nil {
+			return err
+		}
+		if _, err := model.ormer.QueryTable(model.CredentialTableName).Filter("setting_id", setting.ID).
+			Exclude("id", item.ID).Update(orm.Params{"active": false}); err != nil {
+			return err
+		}
+		item.Active = true
+		item.ActivatedAt = &now
+		_, err := model.ormer.Update(item, "active", "activated_at", "update_at")
+		return err
+	})
+}
+
+// GetClusterCapability ...
+func (model *SysSettingModel) GetClusterCapability(settingID int64) (*models.ClusterCapability, error) {
+	item := models.ClusterCapability{}
+	if err := model.ormer.QueryTable(model.CapabilityTableName).
+		Filter("deleted", false).Filter("setting_id", settingID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SaveClusterCapability create or update the capability of cluster
+func (model *SysSettingModel) SaveClusterCapability(item *models.ClusterCapability) error {
+	if item.ID == 0 {
+		_, err := model.ormer.Insert(item)
+		return err
+	}
+	_, err := model.ormer.Update(item)
 	return err
 }
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// PublishJobStageModel ...
+type PublishJobStageModel struct {
+	ormer                    orm.Ormer
+	publishJobStageTableName string
+}
+
+// NewPublishJobStageModel ...
+func NewPublishJobStageModel() (model *PublishJobStageModel) {
+	return &PublishJobStageModel{
+		ormer:                    GetOrmer(),
+		publishJobStageTableName: (&models.PublishJobStage{}).TableName(),
+	}
+}
+
+// ReplaceJobStages replace the stages recorded of the publish job, the job maybe recorded again
+// by both the callback and the status sync
+func (model *PublishJobStageModel) ReplaceJobStages(publishJobID int64, stages []*models.PublishJobStage) error {
+	if _, err := model.ormer.QueryTable(model.publishJobStageTableName).
+		Filter("publish_job_id", publishJobID).Delete(); err != nil {
+		return err
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+	_, err := model.ormer.InsertMulti(len(stages), stages)
+	return err
+}
+
+// GetPublishJobStages return the stages of the jobs of publish, order by the job and start time
+func (model *PublishJobStageModel) GetPublishJobStages(publishID int64) ([]*models.PublishJobStage, error) {
+	stages := []*models.PublishJobStage{}
+	_, err := model.ormer.QueryTable(model.publishJobStageTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID).
+		OrderBy("-publish_job_id", "start_at", "id").Limit(-1).All(&stages)
+	return stages, err
+}
+
+// GetJobStagesByTimeRange return the stages of project started in the time range, the stage id 0 means all envs
+// and empty job type means all types of job
+func (model *PublishJobStageModel) GetJobStagesByTimeRange(projectID, stageID int64, jobType string, start, end time.Time) ([]*models.PublishJobStage, error) {
+	stages := []*models.PublishJobStage{}
+	qs := model.ormer.QueryTable(model.publishJobStageTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("start_at__gte", start).
+		Filter("start_at__lt", end)
+	if stageID > 0 {
+		qs = qs.Filter("stage_id", stageID)
+	}
+	if jobType != "" {
+		qs = qs.Filter("job_type", jobType)
+	}
+	_, err := qs.OrderBy("start_at", "id").Limit(-1).All(&stages)
+	return stages, err
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/astaxie/beego/orm"
+)
+
+// lockRetryInterval the interval to retry the lock held by another replica
+const lockRetryInterval = 500 * time.Millisecond
+
+// LockHolder identify this server replica among the lock holders
+var LockHolder = newLockHolder()
+
+func newLockHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), randomHex())
+}
+
+func randomHex() string {
+	random := make([]byte, 4)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
+
+// TryLock acquire the lock when it is free, expired or held by this replica already, which renews the lease,
+// the lease expires after ttl in case the holder crashed
+func TryLock(name string, ttl time.Duration) (bool, error) {
+	return tryLock(name, LockHolder, ttl)
+}
+
+// Unlock release the lock held by this replica
+func Unlock(name string) error {
+	return unlock(name, LockHolder)
+}
+
+// tryLock acquire the lock for holder, the lock held by the holder already is re-entered and renewed
+func tryLock(name, holder string, ttl time.Duration) (bool, error) {
+	// the locks are not joined into the transaction of the global ormer
+	ormer := orm.NewOrm()
+	tableName := (&models.DistributedLock{}).TableName()
+	now := time.Now()
+	expireAt := now.Add(ttl).UnixNano()
+	result, err := ormer.Raw(insertIgnoreSQL(tableName, []string{"name", "holder", "expire_at"}, "(?,?,?)"),
+		name, holder, expireAt).Exec()
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 1 {
+		return true, nil
+	}
+	result, err = ormer.Raw(fmt.Sprintf("UPDATE %s SET holder = ?, expire_at = ? WHERE name = ? AND (holder = ? OR expire_at < ?)", tableName),
+		holder, expireAt, name, holder, now.UnixNano()).Exec()
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+func unlock(name, holder string) error {
+	_, err := orm.NewOrm().Raw(fmt.Sprintf("DELETE FROM %s WHERE name = ? AND holder = ?", (&models.DistributedLock{}).TableName()),
+		name, holder).Exec()
+	return err
+}
+
+// Lock wait until the lock acquired, return the function releasing it,
+// each call holds the lock by its own token, so the callers of the same replica are serialized too,
+// the error is returned when the lock is still held by another caller after wait
+func Lock(name string, ttl, wait time.Duration) (func(), error) {
+	token := LockHolder + "-" + randomHex()
+	deadline := time.Now().Add(wait)
+	for {
+		locked, err := tryLock(name, token, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return func() {
+				if err := unlock(name, token); err != nil {
+					logs.Warn("release lock: %v occur error: %s", name, err.Error())
+				}
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock: %v is held by another caller", name)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
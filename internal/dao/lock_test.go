@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := os.MkdirTemp("", "atomci")
+	if err != nil {
+		t.Fatalf("create temp dir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// the same as the default sqlite url, the concurrent writers wait for the busy database
+	models.InitSQLite(filepath.Join(dir, "atomci.db") + "?_pragma=busy_timeout(5000)")
+
+	// the callers of the same replica are serialized
+	var inside, overlapped int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				unlock, err := Lock("trigger-1-2", time.Minute, 10*time.Second)
+				if err != nil {
+					t.Errorf("Lock() error: %v", err)
+					return
+				}
+				if atomic.AddInt32(&inside, 1) > 1 {
+					atomic.StoreInt32(&overlapped, 1)
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&inside, -1)
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if overlapped != 0 {
+		t.Errorf("the callers hold the lock at the same time")
+	}
+
+	// the lock released by one caller is not released for another
+	unlock, err := Lock("publish-job-1", time.Minute, time.Second)
+	if err != nil {
+		t.Fatalf("Lock() error: %v", err)
+	}
+	if _, err := Lock("publish-job-1", time.Minute, 0); err == nil {
+		t.Errorf("the lock should be held by the first caller")
+	}
+	if err := Unlock("publish-job-1"); err != nil {
+		t.Fatalf("Unlock() error: %v", err)
+	}
+	if locked, _ := TryLock("publish-job-1", time.Minute); locked {
+		t.Errorf("the lock of caller should not be acquired by the replica lease")
+	}
+	unlock()
+	if unlock, err := Lock("publish-job-1", time.Minute, 0); err != nil {
+		t.Errorf("the lock should be free after released, error: %v", err)
+	} else {
+		unlock()
+	}
+
+	// the replica lease is re-entered and renewed
+	for i := 0; i < 2; i++ {
+		if locked, err := TryLock("recovery", time.Minute); !locked || err != nil {
+			t.Errorf("TryLock() = %v, %v", locked, err)
+		}
+	}
+	if err := Unlock("recovery"); err != nil {
+		t.Errorf("Unlock() error: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// OrganizationModel ...
+type OrganizationModel struct {
+	ormer                     orm.Ormer
+	organizationTableName     string
+	organizationUserTableName string
+	projectTableName          string
+	projectEnvTableName       string
+}
+
+// NewOrganizationModel ...
+func NewOrganizationModel() (model *OrganizationModel) {
+	return &OrganizationModel{
+		ormer:                     GetOrmer(),
+		organizationTableName:     (&models.Organization{}).TableName(),
+		organizationUserTableName: (&models.OrganizationUser{}).TableName(),
+		projectTableName:          (&models.Project{}).TableName(),
+		projectEnvTableName:       (&models.ProjectEnv{}).TableName(),
+	}
+}
+
+// GetOrganizations return the organizations, all the organizations are returned if orgIDs is nil
+func (model *OrganizationModel) GetOrganizations(orgIDs []int64) ([]*models.Organization, error) {
+	orgs := []*models.Organization{}
+	if orgIDs != nil && len(orgIDs) == 0 {
+		return orgs, nil
+	}
+	qs := model.ormer.QueryTable(model.organizationTableName).Filter("deleted", false)
+	if orgIDs != nil {
+		qs = qs.Filter("id__in", orgIDs)
+	}
+	_, err := qs.OrderBy("id").Limit(-1).All(&orgs)
+	return orgs, err
+}
+
+// GetOrganizationByID ...
+func (model *OrganizationModel) GetOrganizationByID(orgID int64) (*models.Organization, error) {
+	org := models.Organization{}
+	if err := model.ormer.QueryTable(model.organizationTableName).
+		Filter("deleted", false).Filter("id", orgID).One(&org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetOrganizationByName ...
+func (model *OrganizationModel) GetOrganizationByName(name string) (*models.Organization, error) {
+	org := models.Organization{}
+	if err := model.ormer.QueryTable(model.organizationTableName).
+		Filter("deleted", false).Filter("name", name).One(&org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// CreateOrganization ...
+func (model *OrganizationModel) CreateOrganization(org *models.Organization) (int64, error) {
+	return model.ormer.Insert(org)
+}
+
+// UpdateOrganization ...
+func (model *OrganizationModel) UpdateOrganization(org *models.Organization) error {
+	_, err := model.ormer.Update(org)
+	return err
+}
+
+// DeleteOrganization mark the organization deleted and remove its members
+func (model *OrganizationModel) DeleteOrganization(org *models.Organization) error {
+	if _, err := model.ormer.QueryTable(model.organizationUserTableName).Filter("org_id", org.ID).Delete(); err != nil {
+		return err
+	}
+	org.MarkDeleted()
+	_, err := model.ormer.Update(org)
+	return err
+}
+
+// GetOrganizationUsers ...
+func (model *OrganizationModel) GetOrganizationUsers(orgID int64) ([]*models.OrganizationUser, error) {
+	users := []*models.OrganizationUser{}
+	_, err := model.ormer.QueryTable(model.organizationUserTableName).
+		Filter("deleted", false).Filter("org_id", orgID).OrderBy("id").Limit(-1).All(&users)
+	return users, err
+}
+
+// GetOrganizationUser ...
+func (model *OrganizationModel) GetOrganizationUser(orgID int64, user string) (*models.OrganizationUser, error) {
+	item := models.OrganizationUser{}
+	if err := model.ormer.QueryTable(model.organizationUserTableName).
+		Filter("deleted", false).Filter("org_id", orgID).Filter("user", user).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetUserOrganizationIDs return the ids of organizations which the user is member of
+func (model *OrganizationModel) GetUserOrganizationIDs(user string) ([]int64, error) {
+	users := []*models.OrganizationUser{}
+	if _, err := model.ormer.QueryTable(model.organizationUserTableName).
+		Filter("deleted", false).Filter("user", user).Limit(-1).All(&users, "OrgID"); err != nil {
+		return nil, err
+	}
+	orgIDs := []int64{}
+	for _, item := range users {
+		orgIDs = append(orgIDs, item.OrgID)
+	}
+	return orgIDs, nil
+}
+
+// CreateOrganizationUser ...
+func (model *OrganizationModel) CreateOrganizationUser(user *models.OrganizationUser) (int64, error) {
+	return model.ormer.Insert(user)
+}
+
+// UpdateOrganizationUser ...
+func (model *OrganizationModel) UpdateOrganizationUser(user *models.OrganizationUser) error {
+	_, err := model.ormer.Update(user, "role", "update_at")
+	return err
+}
+
+// DeleteOrganizationUser ...
+func (model *OrganizationModel) DeleteOrganizationUser(user *models.OrganizationUser) error {
+	_, err := model.ormer.Delete(user)
+	return err
+}
+
+// GetOrganizationProjects ...
+func (model *OrganizationModel) GetOrganizationProjects(orgID int64) ([]*models.Project, error) {
+	projects := []*models.Project{}
+	_, err := model.ormer.QueryTable(model.projectTableName).
+		Filter("deleted", false).Filter("org_id", orgID).Limit(-1).All(&projects)
+	return projects, err
+}
+
+// CountOrganizationEnvs return the count of envs of all the projects of organization
+func (model *OrganizationModel) CountOrganizationEnvs(orgID int64) (int64, error) {
+	projects, err := model.GetOrganizationProjects(orgID)
+	if err != nil || len(projects) == 0 {
+		return 0, err
+	}
+	projectIDs := []int64{}
+	for _, item := range projects {
+		projectIDs = append(projectIDs, item.ID)
+	}
+	return model.ormer.QueryTable(model.projectEnvTableName).
+		Filter("deleted", false).Filter("project_id__in", projectIDs).Count()
+}
+
+// CountOrganizationSettings return the count of integrate settings and compile envs of organization
+func (model *OrganizationModel) CountOrganizationSettings(orgID int64) (int64, error) {
+	settings, err := model.ormer.QueryTable((&models.IntegrateSetting{}).TableName()).
+		Filter("deleted", false).Filter("org_id", orgID).Count()
+	if err != nil {
+		return 0, err
+	}
+	compileEnvs, err := model.ormer.QueryTable((&models.CompileEnv{}).TableName()).
+		Filter("deleted", false).Filter("org_id", orgID).Count()
+	return settings + compileEnvs, err
+}
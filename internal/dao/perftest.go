@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// PerfTestResultModel ...
+type PerfTestResultModel struct {
+	ormer                   orm.Ormer
+	perfTestResultTableName string
+}
+
+// NewPerfTestResultModel ...
+func NewPerfTestResultModel() (model *PerfTestResultModel) {
+	return &PerfTestResultModel{
+		ormer:                   GetOrmer(),
+		perfTestResultTableName: (&models.PerfTestResult{}).TableName(),
+	}
+}
+
+// CreatePerfTestResult ..
+func (model *PerfTestResultModel) CreatePerfTestResult(result *models.PerfTestResult) (int64, error) {
+	return model.ormer.Insert(result)
+}
+
+// GetJobPerfTestResults return the results of the perf tests run by the publish job
+func (model *PerfTestResultModel) GetJobPerfTestResults(publishJobID int64) ([]*models.PerfTestResult, error) {
+	results := []*models.PerfTestResult{}
+	_, err := model.ormer.QueryTable(model.perfTestResultTableName).
+		Filter("deleted", false).
+		Filter("publish_job_id", publishJobID).
+		OrderBy("id").All(&results)
+	return results, err
+}
+
+// GetPerfTestBaseline return the latest passed result of the task in the previous publishes of the stage
+func (model *PerfTestResultModel) GetPerfTestBaseline(projectID, stageID, publishID int64, task string) (*models.PerfTestResult, error) {
+	result := &models.PerfTestResult{}
+	err := model.ormer.QueryTable(model.perfTestResultTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("stage_id", stageID).
+		Filter("publish_id__lt", publishID).
+		Filter("task", task).
+		Filter("gate_passed", true).
+		OrderBy("-id").Limit(1).One(result)
+	return result, err
+}
+
+// GetPerfTestResults return the results of publish, the stage id 0 means all stages
+func (model *PerfTestResultModel) GetPerfTestResults(publishID, stageID int64) ([]*models.PerfTestResult, error) {
+	results := []*models.PerfTestResult{}
+	qs := model.ormer.QueryTable(model.perfTestResultTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID)
+	if stageID != 0 {
+		qs = qs.Filter("stage_id", stageID)
+	}
+	_, err := qs.OrderBy("-id").All(&results)
+	return results, err
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// GetPreviewSourceEnv return the env of project which is cloned as the preview envs
+func (model *ProjectModel) GetPreviewSourceEnv(projectID int64) (*models.ProjectEnv, error) {
+	env := models.ProjectEnv{}
+	err := model.ormer.QueryTable(model.projectEnvTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("preview_source", true).
+		OrderBy("id").Limit(1).One(&env)
+	return &env, err
+}
+
+// GetPreviewEnv return the preview env of project for the merge request which is not closed
+func (model *ProjectModel) GetPreviewEnv(projectID int64, repo string, number int) (*models.PreviewEnv, error) {
+	preview := models.PreviewEnv{}
+	err := model.ormer.QueryTable(model.previewEnvTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("repo", repo).
+		Filter("number", number).
+		Exclude("status", models.PreviewStatusClosed).
+		OrderBy("-id").Limit(1).One(&preview)
+	return &preview, err
+}
+
+// GetPreviewEnvs return the preview envs of project, the latest first
+func (model *ProjectModel) GetPreviewEnvs(projectID int64) ([]*models.PreviewEnv, error) {
+	previews := []*models.PreviewEnv{}
+	_, err := model.ormer.QueryTable(model.previewEnvTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		OrderBy("-id").All(&previews)
+	return previews, err
+}
+
+// GetPreviewEnvsByStatus ..
+func (model *ProjectModel) GetPreviewEnvsByStatus(status string) ([]*models.PreviewEnv, error) {
+	previews := []*models.PreviewEnv{}
+	_, err := model.ormer.QueryTable(model.previewEnvTableName).
+		Filter("deleted", false).
+		Filter("status", status).
+		OrderBy("id").All(&previews)
+	return previews, err
+}
+
+// CreatePreviewEnv ..
+func (model *ProjectModel) CreatePreviewEnv(preview *models.PreviewEnv) (int64, error) {
+	return model.ormer.Insert(preview)
+}
+
+// UpdatePreviewEnv ..
+func (model *ProjectModel) UpdatePreviewEnv(preview *models.PreviewEnv) error {
+	_, err := model.ormer.Update(preview)
+	return err
+}
@@ -41,6 +41,10 @@ type ProjectModel struct {
 	projectPipelineTableName string
 	projectUserTableName     string
 	projectAppTableName      string
+	deployFreezeTableName    string
+	envLockTableName         string
+	agentTemplateTableName   string
+	previewEnvTableName      string
 }
 
 // NewProjectModel ...
@@ -52,6 +56,10 @@ func NewProjectModel() (model *ProjectModel) {
 		projectPipelineTableName: (&models.ProjectPipeline{}).TableName(),
 		projectUserTableName:     (&models.ProjectUser{}).TableName(),
 		projectAppTableName:      (&models.ProjectApp{}).TableName(),
+		deployFreezeTableName:    (&models.DeployFreeze{}).TableName(),
+		envLockTableName:         (&models.EnvLock{}).TableName(),
+		agentTemplateTableName:   (&models.ProjectAgentTemplate{}).TableName(),
+		previewEnvTableName:      (&models.PreviewEnv{}).TableName(),
 	}
 }
 
@@ -115,6 +123,22 @@ func (model *ProjectModel) GetProjectByID(projectID int64) (*models.Project, err
 	return &project, err
 }
 
+// GetProjectsByCursor list the projects of the given ids order by id desc, fetch one more to tell whether there is a next page
+func (model *ProjectModel) GetProjectsByCursor(projectIDs []int64, cursor *query.CursorQuery) ([]*models.Project, error) {
+	projects := []*models.Project{}
+	if len(projectIDs) == 0 {
+		return projects, nil
+	}
+	qs := model.ormer.QueryTable(model.projectTableName).
+		Filter("deleted", false).
+		Filter("id__in", projectIDs)
+	if cursor.After > 0 {
+		qs = qs.Filter("id__lt", cursor.After)
+	}
+	_, err := qs.OrderBy("-id").Limit(cursor.Limit + 1).All(&projects)
+	return projects, err
+}
+
 // GetProjects ...
 func (model *ProjectModel) GetProjects() ([]*models.Project, error) {
 	projects := []*models.Project{}
@@ -168,6 +192,17 @@ func (model *ProjectModel) GetProjectEnvs(projectID int64) ([]*models.ProjectEnv
 	return stages, err
 }
 
+// GetProjectEnvsByProjectIDs return the envs of projects
+func (model *ProjectModel) GetProjectEnvsByProjectIDs(projectIDs []int64) ([]*models.ProjectEnv, error) {
+	envs := []*models.ProjectEnv{}
+	if len(projectIDs) == 0 {
+		return envs, nil
+	}
+	_, err := model.ormer.QueryTable(model.projectEnvTableName).Filter("deleted", false).
+		Filter("project_id__in", projectIDs).Limit(-1).All(&envs)
+	return envs, err
+}
+
 // GetProjectEnvsByPagination ..
 func (model *ProjectModel) GetProjectEnvsByPagination(filter *query.FilterQuery, projectID int64) (*query.QueryResult, error) {
 	if projectID == 0 {
@@ -227,7 +262,104 @@ func (model *ProjectModel) DeleteProjectEnv(stageID int64) error {
 
 // CreateProjectEnv ...
 func (model *ProjectModel) CreateProjectEnv(stage *models.ProjectEnv) error {
-	_, err := model.ormer.InsertOrUpdate(stage)
+	_, err := insertOrUpdate(model.ormer, stage)
+	return err
+}
+
+// GetDeployFreezes return the freeze periods of project which not ended yet
+func (model *ProjectModel) GetDeployFreezes(projectID int64) ([]*models.DeployFreeze, error) {
+	freezes := []*models.DeployFreeze{}
+	_, err := model.ormer.QueryTable(model.deployFreezeTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("end_at__gt", time.Now()).
+		OrderBy("start_at").All(&freezes)
+	return freezes, err
+}
+
+// GetActiveDeployFreezes return the freeze periods which cover the moment, env id 0 means all envs of project
+func (model *ProjectModel) GetActiveDeployFreezes(projectID, envID int64, moment time.Time) ([]*models.DeployFreeze, error) {
+	freezes := []*models.DeployFreeze{}
+	_, err := model.ormer.QueryTable(model.deployFreezeTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("env_id__in", 0, envID).
+		Filter("start_at__lte", moment).
+		Filter("end_at__gt", moment).
+		OrderBy("start_at").All(&freezes)
+	return freezes, err
+}
+
+// GetActiveEnvLock return the lock of env which is active at the moment, orm.ErrNoRows when the env is not locked
+func (model *ProjectModel) GetActiveEnvLock(envID int64, moment time.Time) (*models.EnvLock, error) {
+	lock := models.EnvLock{}
+	cond := orm.NewCondition()
+	cond = cond.And("deleted", false).And("env_id", envID).And("unlocked_at__isnull", true).
+		AndCond(cond.Or("unlock_at__isnull", true).Or("unlock_at__gt", moment))
+	err := model.ormer.QueryTable(model.envLockTableName).SetCond(cond).
+		OrderBy("-id").Limit(1).One(&lock)
+	return &lock, err
+}
+
+// GetEnvLocks return the latest locks of env, the latest first
+func (model *ProjectModel) GetEnvLocks(envID int64, limit int) ([]*models.EnvLock, error) {
+	locks := []*models.EnvLock{}
+	_, err := model.ormer.QueryTable(model.envLockTableName).
+		Filter("deleted", false).
+		Filter("env_id", envID).
+		OrderBy("-id").Limit(limit).All(&locks)
+	return locks, err
+}
+
+// CreateEnvLock ..
+func (model *ProjectModel) CreateEnvLock(lock *models.EnvLock) (int64, error) {
+	return model.ormer.Insert(lock)
+}
+
+// UpdateEnvLock ..
+func (model *ProjectModel) UpdateEnvLock(lock *models.EnvLock) error {
+	_, err := model.ormer.Update(lock)
+	return err
+}
+
+// GetDeployFreezeByID ..
+func (model *ProjectModel) GetDeployFreezeByID(freezeID int64) (*models.DeployFreeze, error) {
+	freeze := models.DeployFreeze{}
+	err := model.ormer.QueryTable(model.deployFreezeTableName).
+		Filter("deleted", false).
+		Filter("id", freezeID).One(&freeze)
+	return &freeze, err
+}
+
+// CreateDeployFreeze ..
+func (model *ProjectModel) CreateDeployFreeze(freeze *models.DeployFreeze) (int64, error) {
+	return model.ormer.Insert(freeze)
+}
+
+// DeleteDeployFreeze ..
+func (model *ProjectModel) DeleteDeployFreeze(freeze *models.DeployFreeze) error {
+	freeze.MarkDeleted()
+	_, err := model.ormer.Update(freeze)
+	return err
+}
+
+// GetProjectAgentTemplate ..
+func (model *ProjectModel) GetProjectAgentTemplate(projectID int64) (*models.ProjectAgentTemplate, error) {
+	item := models.ProjectAgentTemplate{}
+	if err := model.ormer.QueryTable(model.agentTemplateTableName).
+		Filter("deleted", false).Filter("project_id", projectID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SaveProjectAgentTemplate create or update the agent template of project
+func (model *ProjectModel) SaveProjectAgentTemplate(item *models.ProjectAgentTemplate) error {
+	if item.ID == 0 {
+		_, err := model.ormer.Insert(item)
+		return err
+	}
+	_, err := model.ormer.Update(item)
 	return err
 }
 
@@ -494,6 +626,30 @@ func (model *ProjectModel) GetProjectAppsByIDs(projectID int64, projectAppIDs []
 	return app, err
 }
 
+// GetProjectAppsByAppIDs return the apps of ids regardless of project, used to fetch the apps of publish in batch
+func (model *ProjectModel) GetProjectAppsByAppIDs(projectAppIDs []int64) ([]*models.ProjectApp, error) {
+	apps := []*models.ProjectApp{}
+	if len(projectAppIDs) == 0 {
+		return apps, nil
+	}
+	_, err := model.ormer.QueryTable(model.projectAppTableName).
+		Filter("deleted", false).
+		Filter("id__in", projectAppIDs).All(&apps)
+	return apps, err
+}
+
+// GetProjectAppsByProjectIDs return the apps of projects, used to build the service catalog
+func (model *ProjectModel) GetProjectAppsByProjectIDs(projectIDs []int64) ([]*models.ProjectApp, error) {
+	apps := []*models.ProjectApp{}
+	if len(projectIDs) == 0 {
+		return apps, nil
+	}
+	_, err := model.ormer.QueryTable(model.projectAppTableName).
+		Filter("deleted", false).
+		Filter("project_id__in", projectIDs).Limit(-1).All(&apps)
+	return apps, err
+}
+
 // GetProjectAppCounts ..
 func (model *ProjectModel) GetProjectAppCounts(projectID int64) (int64, error) {
 	qs := model.ormer.QueryTable(model.projectAppTableName).Filter("deleted", false)
@@ -504,6 +660,18 @@ func (model *ProjectModel) GetProjectAppCounts(projectID int64) (int64, error) {
 	return num, err
 }
 
+// CountProjectAppsByCompileTemplate return the count of project apps which use the compile command template
+func (model *ProjectModel) CountProjectAppsByCompileTemplate(name string) (int64, error) {
+	return model.ormer.QueryTable(model.projectAppTableName).Filter("deleted", false).
+		Filter("compile_template", name).Count()
+}
+
+// CountProjectAppsByDockerfileTemplate return the count of project apps which use the dockerfile template
+func (model *ProjectModel) CountProjectAppsByDockerfileTemplate(name string) (int64, error) {
+	return model.ormer.QueryTable(model.projectAppTableName).Filter("deleted", false).
+		Filter("dockerfile_template", name).Count()
+}
+
 // GetProjectApps ...
 func (model *ProjectModel) GetProjectApps(projectID int64) ([]*models.ProjectApp, error) {
 	app := []*models.ProjectApp{}
@@ -515,14 +683,20 @@ func (model *ProjectModel) GetProjectApps(projectID int64) ([]*models.ProjectApp
 	return app, err
 }
 
-// GetProjectApp ...
+// GetProjectApp the app is cached, since it is read by every job of the publish
 func (model *ProjectModel) GetProjectApp(projectAppID int64) (*models.ProjectApp, error) {
 	app := models.ProjectApp{}
+	if projectAppID != 0 && cacheGet(projectAppCacheKey(projectAppID), &app) {
+		return &app, nil
+	}
 	qs := model.ormer.QueryTable(model.projectAppTableName).Filter("deleted", false)
 	if projectAppID != 0 {
 		qs = qs.Filter("id", projectAppID)
 	}
 	err := qs.One(&app)
+	if err == nil && projectAppID != 0 {
+		cacheSet(projectAppCacheKey(projectAppID), &app)
+	}
 	return &app, err
 }
 
@@ -535,9 +709,18 @@ func (model *ProjectModel) GetProjectAppByScmID(projectID, scmID int64) (*models
 	return &app, err
 }
 
+// GetProjectAppsByScmID return the project apps of scm app in all projects
+func (model *ProjectModel) GetProjectAppsByScmID(scmID int64) ([]*models.ProjectApp, error) {
+	apps := []*models.ProjectApp{}
+	qs := model.ormer.QueryTable(model.projectAppTableName).Filter("deleted", false)
+	_, err := qs.Filter("scm_id", scmID).All(&apps)
+	return apps, err
+}
+
 // UpdateProjectApp ...
 func (model *ProjectModel) UpdateProjectApp(projectApp *models.ProjectApp) error {
 	_, err := model.ormer.Update(projectApp)
+	cacheDelete(projectAppCacheKey(projectApp.ID))
 	return err
 }
 
@@ -549,5 +732,6 @@ func (model *ProjectModel) DeleteProjectApp(projectAppID int64) error {
 	}
 	app.MarkDeleted()
 	_, err = model.ormer.Delete(app)
+	cacheDelete(projectAppCacheKey(projectAppID))
 	return err
 }
@@ -44,6 +44,7 @@ var publishOpertaionEnableFilterKeys = []string{
 // PublishModel ...
 type PublishModel struct {
 	ormer                     orm.Ormer
+	readOrmer                 orm.Ormer
 	publishTableName          string
 	publishOpertaionTableName string
 	publishAppTableName       string
@@ -54,6 +55,7 @@ type PublishModel struct {
 func NewPublishModel() (model *PublishModel) {
 	return &PublishModel{
 		ormer:                     GetOrmer(),
+		readOrmer:                 GetReadOrmer(),
 		publishTableName:          (&models.Publish{}).TableName(),
 		publishOpertaionTableName: (&models.PublishOperationLog{}).TableName(),
 		publishAppTableName:       (&models.PublishApp{}).TableName(),
@@ -101,7 +103,7 @@ func (model *PublishModel) GetPublishesList(projectID int64, filter *models.Proe
 		}
 	}
 
-	qs := model.ormer.QueryTable(model.publishTableName).OrderBy("-create_at").SetCond(ormCond)
+	qs := model.readOrmer.QueryTable(model.publishTableName).OrderBy("-create_at").SetCond(ormCond)
 	count, err := qs.Count()
 	if err != nil {
 		return nil, nil, err
@@ -132,10 +134,21 @@ func (model *PublishModel) GetRunninbPublishesByProjectID(projectID int64) ([]*m
 	return publishes, err
 }
 
+// GetStalePublishesByProjectID return the unfinished publishes which did not updated since the time
+func (model *PublishModel) GetStalePublishesByProjectID(projectID int64, updatedBefore time.Time) ([]*models.Publish, error) {
+	publishes := []*models.Publish{}
+	_, err := model.ormer.QueryTable(model.publishTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("update_at__lt", updatedBefore).
+		Exclude("status__in", []int64{models.Closed, models.END}).All(&publishes)
+	return publishes, err
+}
+
 // GetPublishReleasesByProjectID ..
 func (model *PublishModel) GetPublishReleasesByProjectID(projectID int64) (interface{}, error) {
 	var maps []orm.Params
-	_, err := model.ormer.Raw("select stage_name as 'env', count(id) as 'count' from pub_publish where `project_id` = ? and deleted = 0 group by stage_name", projectID).Values(&maps)
+	_, err := model.readOrmer.Raw("select stage_name as env, count(id) as count from pub_publish where project_id = ? and deleted = ? group by stage_name", projectID, false).Values(&maps)
 	return maps, err
 }
 
@@ -150,6 +163,47 @@ func (model *PublishModel) GetPublishByID(publishID int64) (*models.Publish, err
 	return &publish, err
 }
 
+// GetPublishesByIDs ...
+func (model *PublishModel) GetPublishesByIDs(publishIDs []int64) ([]*models.Publish, error) {
+	publishes := []*models.Publish{}
+	if len(publishIDs) == 0 {
+		return publishes, nil
+	}
+	_, err := model.ormer.QueryTable(model.publishTableName).
+		Filter("id__in", publishIDs).Limit(-1).All(&publishes)
+	return publishes, err
+}
+
+// GetPublishesByCursor list the publishes of project match the filter sorted by the cursor, fetch one more to tell whether there is a next page
+func (model *PublishModel) GetPublishesByCursor(projectID int64, filter *models.PublishListFilter, cursor *query.CursorQuery) ([]*models.Publish, error) {
+	publishes := []*models.Publish{}
+	cond := orm.NewCondition().And("deleted", false).And("project_id", projectID)
+	if filter.StageID > 0 {
+		cond = cond.And("stage_id", filter.StageID)
+	}
+	if len(filter.Status) > 0 {
+		cond = cond.And("status__in", filter.Status)
+	}
+	if filter.Creator != "" {
+		cond = cond.And("creator", filter.Creator)
+	}
+	if filter.ProjectAppID > 0 {
+		var publishIDs orm.ParamsList
+		_, err := model.readOrmer.QueryTable(model.publishAppTableName).
+			Filter("deleted", false).
+			Filter("project_app_id", filter.ProjectAppID).
+			Limit(-1).ValuesFlat(&publishIDs, "publish_id")
+		if err != nil || len(publishIDs) == 0 {
+			return publishes, err
+		}
+		cond = cond.And("id__in", publishIDs)
+	}
+	cond = createAtCond(cond, filter.CreateAtStart, filter.CreateAtEnd)
+	qs := model.readOrmer.QueryTable(model.publishTableName)
+	_, err := cursorQuerySeter(qs, cond, cursor).All(&publishes)
+	return publishes, err
+}
+
 // GetPublishByPipelineInstanceID ...
 func (model *PublishModel) GetPublishByPipelineInstanceID(pipelineInstanceID int64) (*models.Publish, error) {
 	publish := models.Publish{}
@@ -172,6 +226,7 @@ func (model *PublishModel) GetPublishUnEndBystatus(status []int64, projectIDs []
 
 // CreatePublishifNotExist ...
 func (model *PublishModel) CreatePublishifNotExist(publish *models.Publish) (int64, error) {
+	trackPublishWaiting(nil, publish, time.Now())
 	created, id, err := model.ormer.ReadOrCreate(publish, "version_no", "name", "deleted", "project_id")
 	if err == nil {
 		if !created {
@@ -183,10 +238,55 @@ func (model *PublishModel) CreatePublishifNotExist(publish *models.Publish) (int
 
 // UpdatePublish ...
 func (model *PublishModel) UpdatePublish(publish *models.Publish) error {
-	_, err := model.ormer.Update(publish)
+	previous, err := model.GetPublishByID(publish.ID)
+	if err != nil {
+		previous = nil
+	}
+	trackPublishWaiting(previous, publish, time.Now())
+	_, err = model.ormer.Update(publish)
+	return err
+}
+
+// UpdatePublishSLALevel only update the sla level, the publish status maybe changed concurrently
+func (model *PublishModel) UpdatePublishSLALevel(publish *models.Publish) error {
+	_, err := model.ormer.Update(publish, "sla_level")
 	return err
 }
 
+// trackPublishWaiting the publish waits for manual operation when pending for the next step or the stage success,
+// the waiting time starts over when the publish enter another step
+func trackPublishWaiting(previous, publish *models.Publish, now time.Time) {
+	if publish.Status != models.Pending && publish.Status != models.Success {
+		publish.WaitingSince = nil
+		publish.SLALevel = models.SLALevelNone
+		return
+	}
+	if previous == nil || previous.WaitingSince == nil || previous.Status != publish.Status ||
+		previous.LastPipelineInstanceID != publish.LastPipelineInstanceID ||
+		previous.StageID != publish.StageID || previous.StepIndex != publish.StepIndex {
+		publish.WaitingSince = &now
+		publish.SLALevel = models.SLALevelNone
+		return
+	}
+	// the sla level is only changed by UpdatePublishSLALevel
+	publish.WaitingSince = previous.WaitingSince
+	publish.SLALevel = previous.SLALevel
+}
+
+// GetWaitingPublishes return the publishes waiting for manual operation, project id 0 means all projects
+func (model *PublishModel) GetWaitingPublishes(projectID int64) ([]*models.Publish, error) {
+	publishes := []*models.Publish{}
+	qs := model.ormer.QueryTable(model.publishTableName).
+		Filter("deleted", false).
+		Filter("status__in", models.Pending, models.Success).
+		Filter("waiting_since__isnull", false)
+	if projectID != 0 {
+		qs = qs.Filter("project_id", projectID)
+	}
+	_, err := qs.OrderBy("waiting_since").All(&publishes)
+	return publishes, err
+}
+
 // DeletePublish ...
 func (model *PublishModel) DeletePublish(publishID int64) error {
 	publish, err := model.GetPublishByID(publishID)
@@ -210,6 +310,39 @@ func (model *PublishModel) GetOperationLogByInstanceIDAndStageIDStepType(instanc
 	return operationLogs, err
 }
 
+// GetOperationLogsByInstanceIDAndStageID order by id asc
+func (model *PublishModel) GetOperationLogsByInstanceIDAndStageID(instanceID, stageID int64) ([]*models.PublishOperationLog, error) {
+	operationLogs := []*models.PublishOperationLog{}
+	_, err := model.ormer.QueryTable(model.publishOpertaionTableName).
+		Filter("deleted", false).
+		Filter("pipeline_instance_id", instanceID).
+		Filter("stage_id", stageID).
+		OrderBy("id").All(&operationLogs)
+	return operationLogs, err
+}
+
+// GetOperationLogsByInstanceID return the operation logs of all stages in the pipeline instance, order by id asc
+func (model *PublishModel) GetOperationLogsByInstanceID(instanceID int64) ([]*models.PublishOperationLog, error) {
+	operationLogs := []*models.PublishOperationLog{}
+	_, err := model.ormer.QueryTable(model.publishOpertaionTableName).
+		Filter("deleted", false).
+		Filter("pipeline_instance_id", instanceID).
+		OrderBy("id").All(&operationLogs)
+	return operationLogs, err
+}
+
+// GetOperationLogsByStep return the operation logs of step label in the pipeline instance stage, order by id desc
+func (model *PublishModel) GetOperationLogsByStep(instanceID, stageID int64, step string) ([]*models.PublishOperationLog, error) {
+	operationLogs := []*models.PublishOperationLog{}
+	_, err := model.ormer.QueryTable(model.publishOpertaionTableName).
+		Filter("deleted", false).
+		Filter("pipeline_instance_id", instanceID).
+		Filter("stage_id", stageID).
+		Filter("step", step).
+		OrderBy("-id").All(&operationLogs)
+	return operationLogs, err
+}
+
 // GetOperationLogsByPublishID ...
 func (model *PublishModel) GetOperationLogsByPublishID(publishID int64, filter *query.FilterQuery) (*query.QueryResult, error) {
 	rst := &query.QueryResult{Item: []*models.PublishOperationLog{}}
@@ -219,7 +352,7 @@ func (model *PublishModel) GetOperationLogsByPublishID(publishID int64, filter *
 	if filterCond := query.FilterCondition(filter, filter.FilterKey); filterCond != nil {
 		queryCond = queryCond.AndCond(filterCond)
 	}
-	qs := model.ormer.QueryTable(model.publishOpertaionTableName).OrderBy("-id").SetCond(queryCond)
+	qs := model.readOrmer.QueryTable(model.publishOpertaionTableName).OrderBy("-id").SetCond(queryCond)
 	count, err := qs.Count()
 	if err != nil {
 		return nil, err
@@ -238,9 +371,28 @@ func (model *PublishModel) GetOperationLogsByPublishID(publishID int64, filter *
 	return rst, nil
 }
 
+// GetOperationLogsByCursor list the operation logs of publish match the filter sorted by the cursor, fetch one more to tell whether there is a next page
+func (model *PublishModel) GetOperationLogsByCursor(publishID int64, filter *models.OperationLogListFilter, cursor *query.CursorQuery) ([]*models.PublishOperationLog, error) {
+	items := []*models.PublishOperationLog{}
+	cond := orm.NewCondition().And("deleted", false).And("publish_id", publishID)
+	if filter.StageID > 0 {
+		cond = cond.And("stage_id", filter.StageID)
+	}
+	if filter.Step != "" {
+		cond = cond.And("step", filter.Step)
+	}
+	if filter.Creator != "" {
+		cond = cond.And("creator", filter.Creator)
+	}
+	cond = createAtCond(cond, filter.CreateAtStart, filter.CreateAtEnd)
+	qs := model.readOrmer.QueryTable(model.publishOpertaionTableName)
+	_, err := cursorQuerySeter(qs, cond, cursor).All(&items)
+	return items, err
+}
+
 // CreatePublishOperation ...
 func (model *PublishModel) CreatePublishOperation(item *models.PublishOperationLog) error {
-	_, err := model.ormer.InsertOrUpdate(item)
+	_, err := insertOrUpdate(model.ormer, item)
 	return err
 }
 
@@ -269,6 +421,22 @@ func (model *PublishModel) GetPublishAppByPublishIDAndAppID(publishID, appID int
 	return &app, err
 }
 
+// GetPublishAppsByPublishIDAndAppIDs ..
+func (model *PublishModel) GetPublishAppsByPublishIDAndAppIDs(publishID int64, appIDs []int64) ([]*models.PublishApp, error) {
+	apps := []*models.PublishApp{}
+	if publishID == 0 {
+		return nil, fmt.Errorf("publish_id must be a valid num, but current publish_id: %v", publishID)
+	}
+	if len(appIDs) == 0 {
+		return apps, nil
+	}
+	_, err := model.ormer.QueryTable(model.publishAppTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID).
+		Filter("project_app_id__in", appIDs).All(&apps)
+	return apps, err
+}
+
 // UpdatePublishApp ...
 func (model *PublishModel) UpdatePublishApp(publishApp *models.PublishApp) error {
 	_, err := model.ormer.Update(publishApp)
@@ -286,6 +454,14 @@ func (model *PublishModel) GetPublishAppsByID(publishID int64) ([]*models.Publis
 	return apps, err
 }
 
+// GetPublishAppsByProjectAppID ..
+func (model *PublishModel) GetPublishAppsByProjectAppID(projectAppID int64) ([]*models.PublishApp, error) {
+	apps := []*models.PublishApp{}
+	qs := model.ormer.QueryTable(model.publishAppTableName).Filter("deleted", false)
+	_, err := qs.Filter("project_app_id", projectAppID).All(&apps)
+	return apps, err
+}
+
 // GetPublishApp ...
 func (model *PublishModel) GetPublishApp(publishAppID int64) (*models.PublishApp, error) {
 	app := models.PublishApp{}
@@ -18,8 +18,10 @@ package dao
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-atomci/atomci/internal/models"
+	"github.com/go-atomci/atomci/utils/query"
 
 	"github.com/astaxie/beego/logs"
 	"github.com/astaxie/beego/orm"
@@ -30,6 +32,9 @@ type PublishJobModel struct {
 	ormer                  orm.Ormer
 	publishJobTableName    string
 	publishJobAppTableName string
+	jobQueueTableName      string
+	jobCallbackTableName   string
+	stepTaskTableName      string
 }
 
 // NewPublishJobModel ...
@@ -38,6 +43,9 @@ func NewPublishJobModel() (model *PublishJobModel) {
 		ormer:                  GetOrmer(),
 		publishJobTableName:    (&models.PublishJob{}).TableName(),
 		publishJobAppTableName: (&models.PublishJobApp{}).TableName(),
+		jobQueueTableName:      (&models.PublishJobQueue{}).TableName(),
+		jobCallbackTableName:   (&models.PublishJobCallback{}).TableName(),
+		stepTaskTableName:      (&models.PublishStepTask{}).TableName(),
 	}
 }
 
@@ -102,6 +110,38 @@ func (model *PublishJobModel) GetPublishJobByID(ID int64) (*models.PublishJob, e
 	return publishJobModel, err
 }
 
+// GetPublishJobsByPublishID get all the jobs of publish order by id desc
+func (model *PublishJobModel) GetPublishJobsByPublishID(publishID int64) ([]*models.PublishJob, error) {
+	jobs := []*models.PublishJob{}
+	_, err := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID).
+		OrderBy("-id").Limit(-1).All(&jobs)
+	return jobs, err
+}
+
+// GetPublishJobsByCursor list the jobs of publish match the filter sorted by the cursor, fetch one more to tell whether there is a next page
+func (model *PublishJobModel) GetPublishJobsByCursor(publishID int64, filter *models.PublishJobListFilter, cursor *query.CursorQuery) ([]*models.PublishJob, error) {
+	jobs := []*models.PublishJob{}
+	cond := orm.NewCondition().And("deleted", false).And("publish_id", publishID)
+	if filter.StageID > 0 {
+		cond = cond.And("stage_id", filter.StageID)
+	}
+	if len(filter.Status) > 0 {
+		cond = cond.And("status__in", filter.Status)
+	}
+	if filter.JobType != "" {
+		cond = cond.And("job_type", filter.JobType)
+	}
+	if filter.Operator != "" {
+		cond = cond.And("operator", filter.Operator)
+	}
+	cond = createAtCond(cond, filter.CreateAtStart, filter.CreateAtEnd)
+	qs := GetReadOrmer().QueryTable(model.publishJobTableName)
+	_, err := cursorQuerySeter(qs, cond, cursor).All(&jobs)
+	return jobs, err
+}
+
 // GetLastPublishJobByPublishID ..
 func (model *PublishJobModel) GetLastPublishJobByPublishID(publishID int64) (*models.PublishJob, error) {
 	publishJobModel := &models.PublishJob{}
@@ -112,6 +152,30 @@ func (model *PublishJobModel) GetLastPublishJobByPublishID(publishID int64) (*mo
 	return publishJobModel, err
 }
 
+// GetLastSuccessBuildJob return the latest success build job of publish
+func (model *PublishJobModel) GetLastSuccessBuildJob(publishID int64) (*models.PublishJob, error) {
+	publishJobModel := &models.PublishJob{}
+	err := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("publish_id", publishID).
+		Filter("job_type", models.JobTypeBuild).
+		Filter("status", models.StatusSuccess).
+		Filter("deleted", false).
+		OrderBy("-id").One(publishJobModel)
+	return publishJobModel, err
+}
+
+// GetLastStageJobByType return the latest job of the type in the stage of publish
+func (model *PublishJobModel) GetLastStageJobByType(publishID, stageID int64, jobType string) (*models.PublishJob, error) {
+	publishJobModel := &models.PublishJob{}
+	err := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("publish_id", publishID).
+		Filter("stage_id", stageID).
+		Filter("job_type", jobType).
+		Filter("deleted", false).
+		OrderBy("-id").Limit(1).One(publishJobModel)
+	return publishJobModel, err
+}
+
 // GetPublishJobsByFilter For PublishJob Serer sync publish/publish job status
 func (model *PublishJobModel) GetPublishJobsByFilter(status []string, jobType []string) ([]*models.PublishJob, error) {
 	publishJobsModel := []*models.PublishJob{}
@@ -122,6 +186,38 @@ func (model *PublishJobModel) GetPublishJobsByFilter(status []string, jobType []
 	return publishJobsModel, err
 }
 
+// GetFinishedDeployJobs get the deploy jobs finished in the time range, order by the finish time,
+// envID 0 means all envs of the project
+func (model *PublishJobModel) GetFinishedDeployJobs(projectID, envID int64, start, end time.Time) ([]*models.PublishJob, error) {
+	jobs := []*models.PublishJob{}
+	qs := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("project_id", projectID).
+		Filter("job_type", models.JobTypeDeploy).
+		Filter("status__in", models.StatusSuccess, models.StatusFailure).
+		Filter("update_at__gte", start).
+		Filter("update_at__lt", end).
+		Filter("deleted", false)
+	if envID > 0 {
+		qs = qs.Filter("stage_id", envID)
+	}
+	_, err := qs.OrderBy("update_at", "id").Limit(-1).All(&jobs)
+	return jobs, err
+}
+
+// GetRecentDeployJobs return the latest deploy jobs of projects order by id desc
+func (model *PublishJobModel) GetRecentDeployJobs(projectIDs []int64, limit int) ([]*models.PublishJob, error) {
+	jobs := []*models.PublishJob{}
+	if len(projectIDs) == 0 {
+		return jobs, nil
+	}
+	_, err := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("project_id__in", projectIDs).
+		Filter("job_type", models.JobTypeDeploy).
+		Filter("deleted", false).
+		OrderBy("-id").Limit(limit).All(&jobs)
+	return jobs, err
+}
+
 // GetCurrentRunningBuildJob For Trigger publishOrder build verify, running job include init and running
 func (model *PublishJobModel) GetCurrentRunningBuildJob(projectID, stageID, publishID int64, status []string, jobType string) ([]*models.PublishJob, error) {
 	publishJobsModel := []*models.PublishJob{}
@@ -167,6 +263,18 @@ func (model *PublishJobModel) GetPublishJobApps(publishJobID int64) ([]*models.P
 	return jobAppsModel, err
 }
 
+// GetPublishJobAppsByJobIDs return the apps of jobs
+func (model *PublishJobModel) GetPublishJobAppsByJobIDs(publishJobIDs []int64) ([]*models.PublishJobApp, error) {
+	items := []*models.PublishJobApp{}
+	if len(publishJobIDs) == 0 {
+		return items, nil
+	}
+	_, err := model.ormer.QueryTable(model.publishJobAppTableName).
+		Filter("publish_job_id__in", publishJobIDs).
+		Filter("deleted", false).Limit(-1).All(&items)
+	return items, err
+}
+
 // GetPublishJobApp ..
 func (model *PublishJobModel) GetPublishJobApp(publishJobID, AppID int64) (*models.PublishJobApp, error) {
 	jobAppModel := &models.PublishJobApp{}
@@ -188,3 +296,205 @@ func (model *PublishJobModel) GetPublishJobAppByID(ID int64) (*models.PublishJob
 	err := model.ormer.QueryTable(model.publishJobAppTableName).Filter("id", ID).Filter("Deleted", false).One(JobAppModel)
 	return JobAppModel, err
 }
+
+// GetLastSuccessDeployJobApp return the app of the latest success deploy job in env, the jobs of excluded publish are ignored
+func (model *PublishJobModel) GetLastSuccessDeployJobApp(projectAppID, envID, excludePublishID int64) (*models.PublishJobApp, error) {
+	jobs := []*models.PublishJob{}
+	_, err := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("stage_id", envID).
+		Filter("job_type", models.JobTypeDeploy).
+		Filter("status", models.StatusSuccess).
+		Exclude("publish_id", excludePublishID).
+		Filter("Deleted", false).
+		OrderBy("-id").Limit(100).All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		jobApp := &models.PublishJobApp{}
+		err := model.ormer.QueryTable(model.publishJobAppTableName).
+			Filter("publish_job_id", job.ID).
+			Filter("project_app_id", projectAppID).
+			Filter("Deleted", false).One(jobApp)
+		if err == nil {
+			return jobApp, nil
+		}
+		if err != orm.ErrNoRows {
+			return nil, err
+		}
+	}
+	return nil, orm.ErrNoRows
+}
+
+// GetLastSuccessBuildJobApps return the app of the latest success build job of publish for each app built by the publish
+func (model *PublishJobModel) GetLastSuccessBuildJobApps(publishID int64) (map[int64]*models.PublishJobApp, error) {
+	return model.lastSuccessJobApps(publishID, 0, models.JobTypeBuild)
+}
+
+// GetLastSuccessDeployJobApps return the app of the latest success deploy job of publish in env for each app deployed
+func (model *PublishJobModel) GetLastSuccessDeployJobApps(publishID, envID int64) (map[int64]*models.PublishJobApp, error) {
+	return model.lastSuccessJobApps(publishID, envID, models.JobTypeDeploy)
+}
+
+// lastSuccessJobApps envID 0 means the jobs of all envs
+func (model *PublishJobModel) lastSuccessJobApps(publishID, envID int64, jobType string) (map[int64]*models.PublishJobApp, error) {
+	jobs := []*models.PublishJob{}
+	qs := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("publish_id", publishID).
+		Filter("job_type", jobType).
+		Filter("status", models.StatusSuccess).
+		Filter("Deleted", false)
+	if envID > 0 {
+		qs = qs.Filter("stage_id", envID)
+	}
+	_, err := qs.OrderBy("-id").Limit(100).All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	jobApps := map[int64]*models.PublishJobApp{}
+	if len(jobs) == 0 {
+		return jobApps, nil
+	}
+	jobIDs := []int64{}
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+	items := []*models.PublishJobApp{}
+	_, err = model.ormer.QueryTable(model.publishJobAppTableName).
+		Filter("publish_job_id__in", jobIDs).
+		Filter("Deleted", false).
+		OrderBy("-publish_job_id").Limit(-1).All(&items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if _, ok := jobApps[item.ProjectAPPID]; !ok {
+			jobApps[item.ProjectAPPID] = item
+		}
+	}
+	return jobApps, nil
+}
+
+/* --- PublishJob Queue Part --- */
+
+// CreateJobQueueItem ...
+func (model *PublishJobModel) CreateJobQueueItem(item *models.PublishJobQueue) (int64, error) {
+	return model.ormer.Insert(item)
+}
+
+// GetJobQueueItemByID ..
+func (model *PublishJobModel) GetJobQueueItemByID(id int64) (*models.PublishJobQueue, error) {
+	item := &models.PublishJobQueue{}
+	err := model.ormer.QueryTable(model.jobQueueTableName).Filter("id", id).Filter("Deleted", false).One(item)
+	return item, err
+}
+
+// GetJobQueueItems return the queue items of env by status, ordered by enqueue time
+func (model *PublishJobModel) GetJobQueueItems(projectID, envID int64, status []string) ([]*models.PublishJobQueue, error) {
+	items := []*models.PublishJobQueue{}
+	qs := model.ormer.QueryTable(model.jobQueueTableName).Filter("Deleted", false)
+	if projectID > 0 {
+		qs = qs.Filter("project_id", projectID)
+	}
+	if envID > 0 {
+		qs = qs.Filter("stage_id", envID)
+	}
+	if len(status) > 0 {
+		qs = qs.Filter("status__in", status)
+	}
+	_, err := qs.OrderBy("id").All(&items)
+	return items, err
+}
+
+// UpdateJobQueueItem ...
+func (model *PublishJobModel) UpdateJobQueueItem(item *models.PublishJobQueue) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+/* --- PublishJob Callback Part --- */
+
+// ReadOrCreateJobCallback return the callback of the idempotency key, created is false when it was received already
+func (model *PublishJobModel) ReadOrCreateJobCallback(item *models.PublishJobCallback) (*models.PublishJobCallback, bool, error) {
+	created, _, err := model.ormer.ReadOrCreate(item, "IdempotencyKey")
+	return item, created, err
+}
+
+// GetJobCallbackByID ..
+func (model *PublishJobModel) GetJobCallbackByID(id int64) (*models.PublishJobCallback, error) {
+	item := &models.PublishJobCallback{}
+	err := model.ormer.QueryTable(model.jobCallbackTableName).Filter("id", id).Filter("Deleted", false).One(item)
+	return item, err
+}
+
+// GetJobCallbacks return the callbacks by status, the latest first
+func (model *PublishJobModel) GetJobCallbacks(projectID int64, status []string, publishJobID int64) ([]*models.PublishJobCallback, error) {
+	items := []*models.PublishJobCallback{}
+	qs := model.ormer.QueryTable(model.jobCallbackTableName).Filter("Deleted", false)
+	if projectID > 0 {
+		qs = qs.Filter("project_id", projectID)
+	}
+	if len(status) > 0 {
+		qs = qs.Filter("status__in", status)
+	}
+	if publishJobID > 0 {
+		qs = qs.Filter("publish_job_id", publishJobID)
+	}
+	_, err := qs.OrderBy("-id").All(&items)
+	return items, err
+}
+
+// UpdateJobCallback ...
+func (model *PublishJobModel) UpdateJobCallback(item *models.PublishJobCallback) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+/* --- Publish Step Task Part --- */
+
+// CreateStepTask ...
+func (model *PublishJobModel) CreateStepTask(item *models.PublishStepTask) (int64, error) {
+	return model.ormer.Insert(item)
+}
+
+// GetStepTaskByID ..
+func (model *PublishJobModel) GetStepTaskByID(id int64) (*models.PublishStepTask, error) {
+	item := &models.PublishStepTask{}
+	err := model.ormer.QueryTable(model.stepTaskTableName).Filter("id", id).Filter("Deleted", false).One(item)
+	return item, err
+}
+
+// GetStepTasks return the step tasks by status, ordered by submit time
+func (model *PublishJobModel) GetStepTasks(publishID int64, status []string) ([]*models.PublishStepTask, error) {
+	items := []*models.PublishStepTask{}
+	qs := model.ormer.QueryTable(model.stepTaskTableName).Filter("Deleted", false)
+	if publishID > 0 {
+		qs = qs.Filter("publish_id", publishID)
+	}
+	if len(status) > 0 {
+		qs = qs.Filter("status__in", status)
+	}
+	_, err := qs.OrderBy("id").All(&items)
+	return items, err
+}
+
+// ClaimStepTask switch the pending step task to running, false when it was claimed by another worker
+func (model *PublishJobModel) ClaimStepTask(item *models.PublishStepTask) (bool, error) {
+	now := time.Now()
+	affected, err := model.ormer.QueryTable(model.stepTaskTableName).
+		Filter("id", item.ID).
+		Filter("status", models.StepTaskStatusPending).
+		Update(orm.Params{"status": models.StepTaskStatusRunning, "update_at": now})
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	item.Status = models.StepTaskStatusRunning
+	item.UpdateAt = now
+	return true, nil
+}
+
+// UpdateStepTask ...
+func (model *PublishJobModel) UpdateStepTask(item *models.PublishStepTask) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// QualityReportModel ...
+type QualityReportModel struct {
+	ormer                  orm.Ormer
+	qualityReportTableName string
+}
+
+// NewQualityReportModel ...
+func NewQualityReportModel() (model *QualityReportModel) {
+	return &QualityReportModel{
+		ormer:                  GetOrmer(),
+		qualityReportTableName: (&models.QualityReport{}).TableName(),
+	}
+}
+
+// CreateQualityReport ..
+func (model *QualityReportModel) CreateQualityReport(report *models.QualityReport) (int64, error) {
+	return model.ormer.Insert(report)
+}
+
+// GetQualityReports return the quality reports of publish, the latest first
+func (model *QualityReportModel) GetQualityReports(publishID int64) ([]*models.QualityReport, error) {
+	reports := []*models.QualityReport{}
+	_, err := model.ormer.QueryTable(model.qualityReportTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID).
+		OrderBy("-id").All(&reports)
+	return reports, err
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// ReleasePlanModel ...
+type ReleasePlanModel struct {
+	ormer                orm.Ormer
+	releasePlanTableName string
+}
+
+// NewReleasePlanModel ...
+func NewReleasePlanModel() (model *ReleasePlanModel) {
+	return &ReleasePlanModel{
+		ormer:                GetOrmer(),
+		releasePlanTableName: (&models.ReleasePlan{}).TableName(),
+	}
+}
+
+// GetReleasePlansByProjectID ..
+func (model *ReleasePlanModel) GetReleasePlansByProjectID(projectID int64) ([]*models.ReleasePlan, error) {
+	plans := []*models.ReleasePlan{}
+	_, err := model.ormer.QueryTable(model.releasePlanTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		OrderBy("-id").All(&plans)
+	return plans, err
+}
+
+// GetReleasePlanByID ..
+func (model *ReleasePlanModel) GetReleasePlanByID(planID int64) (*models.ReleasePlan, error) {
+	plan := &models.ReleasePlan{}
+	err := model.ormer.QueryTable(model.releasePlanTableName).
+		Filter("deleted", false).
+		Filter("id", planID).One(plan)
+	return plan, err
+}
+
+// GetReleasePlanByName ..
+func (model *ReleasePlanModel) GetReleasePlanByName(projectID int64, name string) (*models.ReleasePlan, error) {
+	plan := &models.ReleasePlan{}
+	err := model.ormer.QueryTable(model.releasePlanTableName).
+		Filter("deleted", false).
+		Filter("project_id", projectID).
+		Filter("name", name).One(plan)
+	return plan, err
+}
+
+// CreateReleasePlan ...
+func (model *ReleasePlanModel) CreateReleasePlan(plan *models.ReleasePlan) (int64, error) {
+	return model.ormer.Insert(plan)
+}
+
+// UpdateReleasePlan ...
+func (model *ReleasePlanModel) UpdateReleasePlan(plan *models.ReleasePlan) error {
+	_, err := model.ormer.Update(plan)
+	return err
+}
+
+// DeleteReleasePlan ...
+func (model *ReleasePlanModel) DeleteReleasePlan(planID int64) error {
+	plan, err := model.GetReleasePlanByID(planID)
+	if err != nil {
+		return err
+	}
+	plan.MarkDeleted()
+	return model.UpdateReleasePlan(plan)
+}
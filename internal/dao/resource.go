@@ -87,7 +87,7 @@ func GetResourceType(rt string) (*models.ResourceType, error) {
 func BatchCreateResourceType(req models.BatchResourceTypeReq) error {
 	for _, resource := range req.Resources {
 		resourceType := resource.ResourceType.ResourceType
-		sql := `insert ignore into sys_resource_type(resource_type,description) values(?,?)`
+		sql := insertIgnoreSQL("sys_resource_type", []string{"resource_type", "description"}, "(?,?)")
 		if _, err := GetOrmer().Raw(sql, resourceType, resource.ResourceType.Description).Exec(); err != nil {
 			return err
 		}
@@ -101,7 +101,7 @@ func BatchCreateResourceType(req models.BatchResourceTypeReq) error {
 					values = values + "," + fmt.Sprintf("('%v','%v','%v')", resourceType, op.ResourceOperation, op.Description)
 				}
 			}
-			sql = `insert ignore into sys_resource_operation(resource_type,resource_operation,description) values` + values
+			sql = insertIgnoreSQL("sys_resource_operation", []string{"resource_type", "resource_operation", "description"}, values)
 			if _, err := GetOrmer().Raw(sql).Exec(); err != nil {
 				return err
 			}
@@ -116,7 +116,7 @@ func BatchCreateResourceType(req models.BatchResourceTypeReq) error {
 					values = values + "," + fmt.Sprintf("('%v','%v','%v')", resourceType, con.ResourceConstraint, con.Description)
 				}
 			}
-			sql = `insert ignore into sys_resource_constraint(resource_type,resource_constraint,description) values` + values
+			sql = insertIgnoreSQL("sys_resource_constraint", []string{"resource_type", "resource_constraint", "description"}, values)
 			if _, err := GetOrmer().Raw(sql).Exec(); err != nil {
 				return err
 			}
@@ -25,7 +25,7 @@ import (
 )
 
 func CreateGatewayRoute(router, method, backend, resourceType, resourceOperation string) error {
-	sql := `insert ignore into sys_resource_router(router,method,backend,resource_type,resource_operation) values(?,?,?,?,?)`
+	sql := insertIgnoreSQL("sys_resource_router", []string{"router", "method", "backend", "resource_type", "resource_operation"}, "(?,?,?,?,?)")
 	if _, err := GetOrmer().Raw(sql, router, method, backend, resourceType, resourceOperation).Exec(); err != nil {
 		return err
 	}
@@ -27,7 +27,7 @@ import (
 // 组角色
 func GroupRoleList(group string) ([]*models.GroupRole, error) {
 	roles := []*models.GroupRole{}
-	sql := `select * from sys_group_role where ` + "`group`" + `=? order by create_at desc`
+	sql := `select * from sys_group_role where ` + quote("group") + `=? order by create_at desc`
 	if _, err := GetOrmer().Raw(sql, group).QueryRows(&roles); err != nil {
 		return nil, err
 	}
@@ -64,7 +64,7 @@ func GetRoleOperationsByRoleName(role string) ([]*models.GroupRoleOperation, err
 func CreateGroupRole(req *models.GroupRoleReq) (*models.GroupRole, error) {
 	role, _ := GetGroupRoleByName(req.Group, req.Role)
 	if role == nil {
-		sql := `insert into sys_group_role(` + "`group`" + `,role,description) values(?,?,?)`
+		sql := `insert into sys_group_role(` + quote("group") + `,role,description) values(?,?,?)`
 		if _, err := GetOrmer().Raw(sql, req.Group, req.Role, req.Description).Exec(); err != nil {
 			return nil, err
 		}
@@ -93,27 +93,73 @@ func CreateGroupRole(req *models.GroupRoleReq) (*models.GroupRole, error) {
 }
 
 func UpdateGroupRole(req *models.GroupRoleReq) error {
-	sql := `update sys_group_role set description=? where ` + "`group`" + `=? and role=? and description<>?`
+	sql := `update sys_group_role set description=? where ` + quote("group") + `=? and role=? and description<>?`
 	_, err := GetOrmer().Raw(sql, req.Description, req.Group, req.Role, req.Description).Exec()
 	return err
 }
 
 func DeleteGroupRole(group, role string) error {
-	sql := `delete from sys_group_role_operation where ` + "`group`" + `=? and role=?`
+	sql := `delete from sys_group_role_operation where ` + quote("group") + `=? and role=?`
 	if _, err := GetOrmer().Raw(sql, group, role).Exec(); err != nil {
 		return err
 	}
-	sql = `delete from sys_group_role where ` + "`group`" + `=? and role=?`
+	sql = `delete from sys_group_role where ` + quote("group") + `=? and role=?`
 	if _, err := GetOrmer().Raw(sql, group, role).Exec(); err != nil {
 		return err
 	}
-	sql = `delete from sys_group_role_user where ` + "`group`" + `=? and role=?`
+	sql = `delete from sys_group_role_user where ` + quote("group") + `=? and role=?`
+	if _, err := GetOrmer().Raw(sql, group, role).Exec(); err != nil {
+		return err
+	}
+	sql = `delete from sys_group_role_env_permission where ` + quote("group") + `=? and role=?`
 	if _, err := GetOrmer().Raw(sql, group, role).Exec(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// GetRoleEnvPermissions ..
+func GetRoleEnvPermissions(group, role string) ([]*models.GroupRoleEnvPermission, error) {
+	return GetRolesEnvPermissions(group, []string{role})
+}
+
+// GetRolesEnvPermissions return the env permissions of the roles
+func GetRolesEnvPermissions(group string, roles []string) ([]*models.GroupRoleEnvPermission, error) {
+	permissions := []*models.GroupRoleEnvPermission{}
+	if len(roles) == 0 {
+		return permissions, nil
+	}
+	if _, err := GetOrmer().QueryTable("sys_group_role_env_permission").
+		Filter("group", group).Filter("role__in", roles).
+		OrderBy("action", "env").All(&permissions); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// SetRoleEnvPermissions replace the env permissions of the role
+func SetRoleEnvPermissions(group, role string, items []*models.RoleEnvPermissionItem) error {
+	if _, err := GetOrmer().QueryTable("sys_group_role_env_permission").
+		Filter("group", group).Filter("role", role).Delete(); err != nil {
+		return err
+	}
+	permissions := []*models.GroupRoleEnvPermission{}
+	for _, item := range items {
+		permissions = append(permissions, &models.GroupRoleEnvPermission{
+			Addons: models.NewAddons(),
+			Group:  group,
+			Role:   role,
+			Action: item.Action,
+			Env:    item.Env,
+		})
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	_, err := GetOrmer().InsertMulti(len(permissions), permissions)
+	return err
+}
+
 // 组角色绑定用户
 func GroupRoleBundlingList(group, role string) ([]*models.GroupRoleBundlingUser, error) {
 	userList := []*models.GroupRoleBundlingUser{}
@@ -127,7 +173,7 @@ func GroupRoleBundlingList(group, role string) ([]*models.GroupRoleBundlingUser,
 
 func GroupRoleBundling(req *models.GroupRoleBundlingReq) error {
 	for _, user := range req.Users {
-		sql := `insert ignore into sys_group_role_user(` + "`group`" + `,user,role) values(?,?,?)`
+		sql := insertIgnoreSQL("sys_group_role_user", []string{"group", "user", "role"}, "(?,?,?)")
 		if _, err := GetOrmer().Raw(sql, req.Group, user, req.Role).Exec(); err != nil {
 			return err
 		}
@@ -137,7 +183,7 @@ func GroupRoleBundling(req *models.GroupRoleBundlingReq) error {
 
 func GroupRoleUnbundling(req *models.GroupRoleBundlingReq) error {
 	for _, user := range req.Users {
-		sql := `delete from sys_group_role_user where ` + "`group`" + `=? and user=? and role=?`
+		sql := `delete from sys_group_role_user where ` + quote("group") + `=? and ` + quote("user") + `=? and role=?`
 		if _, err := GetOrmer().Raw(sql, req.Group, user, req.Role).Exec(); err != nil {
 			return err
 		}
@@ -156,7 +202,7 @@ func AddRoleOperation(req *models.GroupRoleOperationReq) error {
 			}
 		}
 		// TODO: add casbin items;
-		sql := `insert ignore into sys_group_role_operation(` + "`group`" + `,role, operation_id) values` + values
+		sql := insertIgnoreSQL("sys_group_role_operation", []string{"group", "role", "operation_id"}, values)
 		if _, err := GetOrmer().Raw(sql).Exec(); err != nil {
 			return err
 		}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"time"
+
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+const (
+	runnerTableName     = "sys_runner"
+	runnerTaskTableName = "sys_runner_task"
+)
+
+// claimRetries the times to claim another pending task when the task was claimed by another runner
+const claimRetries = 3
+
+// CreateRunner ..
+func CreateRunner(runner *models.Runner) (int64, error) {
+	return GetOrmer().Insert(runner)
+}
+
+// GetRunnerByID ..
+func GetRunnerByID(id int64) (*models.Runner, error) {
+	runner := models.Runner{}
+	if err := GetOrmer().QueryTable(runnerTableName).
+		Filter("deleted", false).Filter("id", id).One(&runner); err != nil {
+		return nil, err
+	}
+	return &runner, nil
+}
+
+// GetRunnerByHash ..
+func GetRunnerByHash(hash string) (*models.Runner, error) {
+	runner := models.Runner{}
+	if err := GetOrmer().QueryTable(runnerTableName).
+		Filter("deleted", false).Filter("token_hash", hash).One(&runner); err != nil {
+		return nil, err
+	}
+	return &runner, nil
+}
+
+// GetRunners ..
+func GetRunners() ([]*models.Runner, error) {
+	runners := []*models.Runner{}
+	_, err := GetOrmer().QueryTable(runnerTableName).Filter("deleted", false).OrderBy("id").All(&runners)
+	return runners, err
+}
+
+// DeleteRunner ..
+func DeleteRunner(runner *models.Runner) error {
+	runner.MarkDeleted()
+	_, err := GetOrmer().Update(runner, "deleted", "delete_at")
+	return err
+}
+
+// UpdateRunnerHeartbeat ..
+func UpdateRunnerHeartbeat(runner *models.Runner, version string, moment time.Time) error {
+	runner.Version = version
+	runner.LastHeartbeat = &moment
+	_, err := GetOrmer().Update(runner, "version", "last_heartbeat")
+	return err
+}
+
+// CreateRunnerTask ..
+func CreateRunnerTask(task *models.RunnerTask) (int64, error) {
+	return GetOrmer().Insert(task)
+}
+
+// GetRunnerTask ..
+func GetRunnerTask(id int64) (*models.RunnerTask, error) {
+	task := models.RunnerTask{}
+	if err := GetOrmer().QueryTable(runnerTaskTableName).
+		Filter("deleted", false).Filter("id", id).One(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ClaimRunnerTask assign the earliest pending task to runner, nil means no pending task,
+// the status is updated conditionally so that one task is never claimed by two runners
+func ClaimRunnerTask(runnerID int64, moment time.Time) (*models.RunnerTask, error) {
+	for i := 0; i < claimRetries; i++ {
+		task := models.RunnerTask{}
+		err := GetOrmer().QueryTable(runnerTaskTableName).
+			Filter("deleted", false).Filter("status", models.RunnerTaskPending).OrderBy("id").One(&task)
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		affected, err := GetOrmer().QueryTable(runnerTaskTableName).
+			Filter("id", task.ID).Filter("status", models.RunnerTaskPending).
+			Update(orm.Params{"status": models.RunnerTaskRunning, "runner_id": runnerID, "started_at": moment})
+		if err != nil {
+			return nil, err
+		}
+		if affected == 1 {
+			task.Status, task.RunnerID, task.StartedAt = models.RunnerTaskRunning, runnerID, &moment
+			return &task, nil
+		}
+	}
+	return nil, nil
+}
+
+// AbortRunnerTask abort the pending task at once, the running one is stopped by its runner later
+func AbortRunnerTask(task *models.RunnerTask, moment time.Time) error {
+	task.Abort = true
+	if _, err := GetOrmer().Update(task, "abort"); err != nil {
+		return err
+	}
+	affected, err := GetOrmer().QueryTable(runnerTaskTableName).
+		Filter("id", task.ID).Filter("status", models.RunnerTaskPending).
+		Update(orm.Params{"status": models.RunnerTaskAborted, "finished_at": moment})
+	if err == nil && affected == 1 {
+		task.Status, task.FinishedAt = models.RunnerTaskAborted, &moment
+	}
+	return err
+}
+
+// AppendRunnerTaskLog the log is only written by the runner claimed the task
+func AppendRunnerTaskLog(task *models.RunnerTask, text string, finishedSteps int) error {
+	task.Log += text
+	task.FinishedSteps = finishedSteps
+	_, err := GetOrmer().Update(task, "log", "finished_steps")
+	return err
+}
+
+// FinishRunnerTask ..
+func FinishRunnerTask(task *models.RunnerTask, status string, moment time.Time) error {
+	task.Status = status
+	task.FinishedAt = &moment
+	_, err := GetOrmer().Update(task, "status", "finished_at")
+	return err
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// SBOMModel ...
+type SBOMModel struct {
+	ormer                  orm.Ormer
+	sbomTableName          string
+	packageTableName       string
+	publishJobTableName    string
+	publishJobAppTableName string
+}
+
+// NewSBOMModel ...
+func NewSBOMModel() (model *SBOMModel) {
+	return &SBOMModel{
+		ormer:                  GetOrmer(),
+		sbomTableName:          (&models.ImageSBOM{}).TableName(),
+		packageTableName:       (&models.SBOMPackage{}).TableName(),
+		publishJobTableName:    (&models.PublishJob{}).TableName(),
+		publishJobAppTableName: (&models.PublishJobApp{}).TableName(),
+	}
+}
+
+// the columns of sbom list, the content is only returned by download
+var sbomListColumns = []string{"id", "create_at", "update_at", "project_id", "publish_id", "stage_id", "publish_job_id", "project_app_id", "app_name", "image_addr", "format", "components"}
+
+// CreateImageSBOM create the sbom and its packages, the previous sbom of the job app is replaced
+func (model *SBOMModel) CreateImageSBOM(sbom *models.ImageSBOM, packages []*models.SBOMPackage) error {
+	previous := []*models.ImageSBOM{}
+	if _, err := model.ormer.QueryTable(model.sbomTableName).Filter("deleted", false).
+		Filter("publish_job_id", sbom.PublishJobID).Filter("project_app_id", sbom.ProjectAppID).All(&previous, "id"); err != nil {
+		return err
+	}
+	for _, item := range previous {
+		if _, err := model.ormer.QueryTable(model.packageTableName).Filter("sbom_id", item.ID).Delete(); err != nil {
+			return err
+		}
+		if _, err := model.ormer.QueryTable(model.sbomTableName).Filter("id", item.ID).Delete(); err != nil {
+			return err
+		}
+	}
+	id, err := model.ormer.Insert(sbom)
+	if err != nil {
+		return err
+	}
+	for _, item := range packages {
+		item.SBOMID = id
+	}
+	for start := 0; start < len(packages); start += 500 {
+		end := start + 500
+		if end > len(packages) {
+			end = len(packages)
+		}
+		if _, err := model.ormer.InsertMulti(end-start, packages[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetImageSBOM return the sbom with content
+func (model *SBOMModel) GetImageSBOM(sbomID int64) (*models.ImageSBOM, error) {
+	item := models.ImageSBOM{}
+	if err := model.ormer.QueryTable(model.sbomTableName).Filter("deleted", false).
+		Filter("id", sbomID).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetPublishSBOMs return the sboms of publish without content, the latest is the first
+func (model *SBOMModel) GetPublishSBOMs(publishID int64) ([]*models.ImageSBOM, error) {
+	items := []*models.ImageSBOM{}
+	_, err := model.ormer.QueryTable(model.sbomTableName).Filter("deleted", false).
+		Filter("publish_id", publishID).OrderBy("-id").Limit(-1).All(&items, sbomListColumns...)
+	return items, err
+}
+
+// FindSBOMPackages return the packages of the name, the empty version matches all versions
+func (model *SBOMModel) FindSBOMPackages(name, version string) ([]*models.SBOMPackage, error) {
+	items := []*models.SBOMPackage{}
+	qs := model.ormer.QueryTable(model.packageTableName).Filter("deleted", false).Filter("name", name)
+	if version != "" {
+		qs = qs.Filter("version", version)
+	}
+	_, err := qs.OrderBy("-id").Limit(1000).All(&items)
+	return items, err
+}
+
+// GetDeployJobAppsByImages return the apps of success deploy jobs which deployed the images, the latest is the first
+func (model *SBOMModel) GetDeployJobAppsByImages(images []string) ([]*models.PublishJobApp, map[int64]*models.PublishJob, error) {
+	jobs := map[int64]*models.PublishJob{}
+	if len(images) == 0 {
+		return nil, jobs, nil
+	}
+	jobApps := []*models.PublishJobApp{}
+	if _, err := model.ormer.QueryTable(model.publishJobAppTableName).Filter("deleted", false).
+		Filter("image_addr__in", images).OrderBy("-id").Limit(1000).All(&jobApps); err != nil {
+		return nil, nil, err
+	}
+	jobIDs := []int64{}
+	for _, jobApp := range jobApps {
+		jobIDs = append(jobIDs, jobApp.PublishJobID)
+	}
+	if len(jobIDs) == 0 {
+		return nil, jobs, nil
+	}
+	items := []*models.PublishJob{}
+	if _, err := model.ormer.QueryTable(model.publishJobTableName).Filter("deleted", false).
+		Filter("id__in", jobIDs).Filter("job_type", models.JobTypeDeploy).Filter("status", models.StatusSuccess).
+		Limit(-1).All(&items); err != nil {
+		return nil, nil, err
+	}
+	for _, job := range items {
+		jobs[job.ID] = job
+	}
+	deployed := []*models.PublishJobApp{}
+	for _, jobApp := range jobApps {
+		if _, ok := jobs[jobApp.PublishJobID]; ok {
+			deployed = append(deployed, jobApp)
+		}
+	}
+	return deployed, jobs, nil
+}
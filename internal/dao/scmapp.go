@@ -68,6 +68,34 @@ func (model *ScmAppModel) GetScmAppByID(appID int64) (*models.ScmApp, error) {
 	return &app, err
 }
 
+// GetScmAppsByIDs ..
+func (model *ScmAppModel) GetScmAppsByIDs(appIDs []int64) ([]*models.ScmApp, error) {
+	apps := []*models.ScmApp{}
+	if len(appIDs) == 0 {
+		return apps, nil
+	}
+	_, err := model.ormer.QueryTable(model.scmAppTableName).
+		Filter("deleted", false).
+		Filter("id__in", appIDs).All(&apps)
+	return apps, err
+}
+
+// GetScmAppsByRepo return the apps of the same repository, the apps of monorepo have distinct build paths
+func (model *ScmAppModel) GetScmAppsByRepo(repoID int64, fullName string) ([]*models.ScmApp, error) {
+	apps := []*models.ScmApp{}
+	qs := model.ormer.QueryTable(model.scmAppTableName).Filter("deleted", false)
+	_, err := qs.Filter("repo_id", repoID).Filter("full_name", fullName).All(&apps)
+	return apps, err
+}
+
+// GetScmAppsByCompileEnv return the apps which use the compile env
+func (model *ScmAppModel) GetScmAppsByCompileEnv(compileEnvID int64) ([]*models.ScmApp, error) {
+	apps := []*models.ScmApp{}
+	qs := model.ormer.QueryTable(model.scmAppTableName).Filter("deleted", false)
+	_, err := qs.Filter("compile_env_id", compileEnvID).Limit(-1).All(&apps)
+	return apps, err
+}
+
 // UpdateProjectApp ...
 func (model *ScmAppModel) UpdateSCMApp(scmApp *models.ScmApp) error {
 	_, err := model.ormer.Update(scmApp)
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/astaxie/beego/orm"
+
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+// SearchProjects find the projects whose name contains the keyword
+func (model *ProjectModel) SearchProjects(projectIDs []int64, keyword string, limit int) ([]*models.Project, error) {
+	projects := []*models.Project{}
+	if len(projectIDs) == 0 {
+		return projects, nil
+	}
+	_, err := model.ormer.QueryTable(model.projectTableName).
+		Filter("deleted", false).
+		Filter("id__in", projectIDs).
+		Filter("name__icontains", keyword).
+		OrderBy("-id").Limit(limit).All(&projects)
+	return projects, err
+}
+
+// GetProjectAppsOfScmApps return the project apps of scm apps in projects
+func (model *ProjectModel) GetProjectAppsOfScmApps(projectIDs, scmIDs []int64) ([]*models.ProjectApp, error) {
+	apps := []*models.ProjectApp{}
+	if len(projectIDs) == 0 || len(scmIDs) == 0 {
+		return apps, nil
+	}
+	_, err := model.ormer.QueryTable(model.projectAppTableName).
+		Filter("deleted", false).
+		Filter("project_id__in", projectIDs).
+		Filter("scm_id__in", scmIDs).Limit(-1).All(&apps)
+	return apps, err
+}
+
+// SearchScmApps find the scm apps whose name or full name contains the keyword
+func (model *ScmAppModel) SearchScmApps(keyword string, limit int) ([]*models.ScmApp, error) {
+	apps := []*models.ScmApp{}
+	cond := orm.NewCondition()
+	cond = cond.And("deleted", false).AndCond(cond.Or("name__icontains", keyword).Or("full_name__icontains", keyword))
+	_, err := model.ormer.QueryTable(model.scmAppTableName).SetCond(cond).
+		OrderBy("-id").Limit(limit).All(&apps)
+	return apps, err
+}
+
+// SearchPublishes find the publishes of projects whose name or version contains the keyword
+func (model *PublishModel) SearchPublishes(projectIDs []int64, keyword string, limit int) ([]*models.Publish, error) {
+	publishes := []*models.Publish{}
+	if len(projectIDs) == 0 {
+		return publishes, nil
+	}
+	cond := orm.NewCondition()
+	cond = cond.And("deleted", false).And("project_id__in", projectIDs).
+		AndCond(cond.Or("name__icontains", keyword).Or("version_no__icontains", keyword))
+	_, err := model.readOrmer.QueryTable(model.publishTableName).SetCond(cond).
+		OrderBy("-id").Limit(limit).All(&publishes)
+	return publishes, err
+}
+
+// SearchPublishJobApps find the built or deployed apps of projects by the image tag, commit sha or branch,
+// the commit sha is matched by prefix since the short sha is used mostly
+func (model *PublishJobModel) SearchPublishJobApps(projectIDs []int64, keyword string, limit int) ([]*models.PublishJobApp, error) {
+	items := []*models.PublishJobApp{}
+	if len(projectIDs) == 0 {
+		return items, nil
+	}
+	cond := orm.NewCondition()
+	cond = cond.And("deleted", false).And("project_id__in", projectIDs).
+		AndCond(cond.Or("commit_sha__istartswith", keyword).
+			Or("image_version__icontains", keyword).
+			Or("image_addr__icontains", keyword).
+			Or("branch_name__icontains", keyword))
+	_, err := model.ormer.QueryTable(model.publishJobAppTableName).SetCond(cond).
+		OrderBy("-id").Limit(limit).All(&items)
+	return items, err
+}
+
+// GetPublishJobsByIDs ..
+func (model *PublishJobModel) GetPublishJobsByIDs(jobIDs []int64) ([]*models.PublishJob, error) {
+	jobs := []*models.PublishJob{}
+	if len(jobIDs) == 0 {
+		return jobs, nil
+	}
+	_, err := model.ormer.QueryTable(model.publishJobTableName).
+		Filter("deleted", false).
+		Filter("id__in", jobIDs).Limit(-1).All(&jobs)
+	return jobs, err
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// ServiceTemplateModel ...
+type ServiceTemplateModel struct {
+	ormer     orm.Ormer
+	tableName string
+}
+
+// NewServiceTemplateModel ...
+func NewServiceTemplateModel() (model *ServiceTemplateModel) {
+	return &ServiceTemplateModel{
+		ormer:     GetOrmer(),
+		tableName: (&models.ServiceTemplate{}).TableName(),
+	}
+}
+
+// GetServiceTemplates ...
+func (model *ServiceTemplateModel) GetServiceTemplates() ([]*models.ServiceTemplate, error) {
+	items := []*models.ServiceTemplate{}
+	_, err := model.ormer.QueryTable(model.tableName).
+		Filter("deleted", false).OrderBy("id").Limit(-1).All(&items)
+	return items, err
+}
+
+// GetServiceTemplateByID ...
+func (model *ServiceTemplateModel) GetServiceTemplateByID(id int64) (*models.ServiceTemplate, error) {
+	item := models.ServiceTemplate{}
+	if err := model.ormer.QueryTable(model.tableName).
+		Filter("deleted", false).Filter("id", id).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetServiceTemplateByName ...
+func (model *ServiceTemplateModel) GetServiceTemplateByName(name string) (*models.ServiceTemplate, error) {
+	item := models.ServiceTemplate{}
+	if err := model.ormer.QueryTable(model.tableName).
+		Filter("deleted", false).Filter("name", name).One(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateServiceTemplate ...
+func (model *ServiceTemplateModel) CreateServiceTemplate(item *models.ServiceTemplate) (int64, error) {
+	return model.ormer.Insert(item)
+}
+
+// UpdateServiceTemplate ...
+func (model *ServiceTemplateModel) UpdateServiceTemplate(item *models.ServiceTemplate) error {
+	_, err := model.ormer.Update(item)
+	return err
+}
+
+// DeleteServiceTemplate ...
+func (model *ServiceTemplateModel) DeleteServiceTemplate(item *models.ServiceTemplate) error {
+	item.MarkDeleted()
+	_, err := model.ormer.Update(item)
+	return err
+}
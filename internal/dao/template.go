@@ -17,6 +17,8 @@ limitations under the License.
 package dao
 
 import (
+	"time"
+
 	"github.com/go-atomci/atomci/internal/models"
 
 	"github.com/astaxie/beego/orm"
@@ -52,8 +54,8 @@ func (tm *TemplateModel) UpdateTemplate(template models.CaasTemplate) error {
 }
 
 func (tm *TemplateModel) DeleteTemplate(namespace, name string) error {
-	sql := "update " + tm.TableName + " set deleted=1, delete_at=now() where namespace=? and name=? and deleted=0"
-	_, err := tm.tOrmer.Raw(sql, namespace, name).Exec()
+	sql := "update " + tm.TableName + " set deleted=?, delete_at=? where namespace=? and name=? and deleted=?"
+	_, err := tm.tOrmer.Raw(sql, true, time.Now(), namespace, name, false).Exec()
 
 	return err
 }
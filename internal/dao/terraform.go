@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dao
+
+import (
+	"github.com/go-atomci/atomci/internal/models"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// TerraformPlanModel ...
+type TerraformPlanModel struct {
+	ormer                  orm.Ormer
+	terraformPlanTableName string
+}
+
+// NewTerraformPlanModel ...
+func NewTerraformPlanModel() (model *TerraformPlanModel) {
+	return &TerraformPlanModel{
+		ormer:                  GetOrmer(),
+		terraformPlanTableName: (&models.TerraformPlan{}).TableName(),
+	}
+}
+
+// CreateTerraformPlan ..
+func (model *TerraformPlanModel) CreateTerraformPlan(plan *models.TerraformPlan) (int64, error) {
+	return model.ormer.Insert(plan)
+}
+
+// UpdateTerraformPlan ..
+func (model *TerraformPlanModel) UpdateTerraformPlan(plan *models.TerraformPlan, cols ...string) error {
+	_, err := model.ormer.Update(plan, cols...)
+	return err
+}
+
+// GetTerraformPlans return the plans of publish, the stage id 0 means all stages
+func (model *TerraformPlanModel) GetTerraformPlans(publishID, stageID int64) ([]*models.TerraformPlan, error) {
+	plans := []*models.TerraformPlan{}
+	qs := model.ormer.QueryTable(model.terraformPlanTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID)
+	if stageID != 0 {
+		qs = qs.Filter("stage_id", stageID)
+	}
+	_, err := qs.OrderBy("-id").All(&plans)
+	return plans, err
+}
+
+// GetTerraformPlansByStatus ..
+func (model *TerraformPlanModel) GetTerraformPlansByStatus(publishID, stageID int64, status string) ([]*models.TerraformPlan, error) {
+	plans := []*models.TerraformPlan{}
+	_, err := model.ormer.QueryTable(model.terraformPlanTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID).
+		Filter("stage_id", stageID).
+		Filter("status", status).
+		OrderBy("-id").All(&plans)
+	return plans, err
+}
+
+// GetLatestTerraformPlan return the latest plan of the app dir and workspace
+func (model *TerraformPlanModel) GetLatestTerraformPlan(publishID, stageID int64, appName, dir, workspace string) (*models.TerraformPlan, error) {
+	plan := &models.TerraformPlan{}
+	err := model.ormer.QueryTable(model.terraformPlanTableName).
+		Filter("deleted", false).
+		Filter("publish_id", publishID).
+		Filter("stage_id", stageID).
+		Filter("app_name", appName).
+		Filter("dir", dir).
+		Filter("workspace", workspace).
+		OrderBy("-id").Limit(1).One(plan)
+	return plan, err
+}
@@ -138,15 +138,15 @@ func UpdateUser(user *models.User) error {
 
 func DeleteUser(user *models.User) error {
 	// 删除用户关联的组
-	sql := `delete from sys_group_role_user where user=?`
+	sql := `delete from sys_group_role_user where ` + quote("user") + `=?`
 	if _, err := GetOrmer().Raw(sql, user.User).Exec(); err != nil {
 		return err
 	}
-	sql = `delete from sys_group_user_constraint where user=?`
+	sql = `delete from sys_group_user_constraint where ` + quote("user") + `=?`
 	if _, err := GetOrmer().Raw(sql, user.User).Exec(); err != nil {
 		return err
 	}
-	sql = `delete from sys_user where user=?`
+	sql = `delete from sys_user where ` + quote("user") + `=?`
 	if _, err := GetOrmer().Raw(sql, user.User).Exec(); err != nil {
 		return err
 	}
@@ -172,6 +172,9 @@ func UserExist(user string) bool {
 
 // InitSystemMember create system user and role
 func InitSystemMember(user *models.User) error {
+	if models.IsReservedUser(user.User) {
+		return fmt.Errorf("用户名 %v 为系统保留名称", user.User)
+	}
 	if !UserExist(user.User) {
 		userId, err := CreateUser(user)
 		if err != nil {
@@ -34,6 +34,9 @@ var resourceReq = ResourceReq{
 				[]string{"UserLogin", "用户登录"},
 				[]string{"UserLogout", "用户登出"},
 				[]string{"GetCurrentUser", "获取当前用户信息"},
+				[]string{"GetAccessTokens", "获取个人访问令牌列表"},
+				[]string{"CreateAccessToken", "创建个人访问令牌"},
+				[]string{"RevokeAccessToken", "吊销个人访问令牌"},
 			},
 			ResourceConstraint: [][]string{},
 		},
@@ -42,6 +45,8 @@ var resourceReq = ResourceReq{
 			ResourceOperation: [][]string{
 				[]string{"*", "操作审计所有操作"},
 				[]string{"AuditList", "获取操作审计列表"},
+				[]string{"AuditListByPagination", "操作审计分页查询"},
+				[]string{"AuditExport", "导出操作审计"},
 			},
 			ResourceConstraint: [][]string{},
 		},
@@ -75,6 +80,7 @@ var resourceReq = ResourceReq{
 
 				[]string{"GetAppBranches", "获取应用分支"},
 				[]string{"SyncAppBranches", "同步远程分支"},
+				[]string{"RegisterAppWebhook", "注册代码仓库Webhook"},
 				[]string{"GetGitProjectsByRepoID", "获取代码仓库项目列表"},
 			},
 			ResourceConstraint: [][]string{
@@ -87,19 +93,34 @@ var resourceReq = ResourceReq{
 			ResourceOperation: [][]string{
 				[]string{"*", "项目所有操作"},
 				[]string{"ProjectList", "获取项目列表"},
+				[]string{"GetServiceCatalog", "获取服务目录"},
+				[]string{"GlobalSearch", "全局搜索"},
 				[]string{"CreateProject", "创建项目"},
 				[]string{"UpdateProject", "更新项目信息"},
 				[]string{"DeleteProject", "删除项目"},
 				[]string{"GetProject", "获取项目信息"},
 				[]string{"GetprojectMemberByConstraint", "获取项目成员信息"},
+				[]string{"ExportProject", "导出项目"},
+				[]string{"ImportProject", "导入新项目"},
+				[]string{"ImportProjectConfig", "导入项目配置"},
 
 				[]string{"CreateProjectApp", "项目添加应用"},
+				[]string{"ScaffoldService", "从服务模板创建应用"},
 				[]string{"UpdateProjectApp", "更新项目应用"},
+				[]string{"UpdateProjectAppDeployAfter", "设置项目应用部署依赖"},
+				[]string{"UpdateProjectAppCompileCommand", "设置项目应用编译命令"},
+				[]string{"UpdateProjectAppDockerfileTemplate", "设置项目应用Dockerfile模板"},
+				[]string{"UpdateProjectAppOwnership", "设置项目应用归属信息"},
 				[]string{"GetProjectApps", "获取项目应用列表"},
 				[]string{"GetProjectApp", "获取项目应用详情"},
 				[]string{"GetProjectAppsByPagination", "获取项目应用分页列表"},
 				[]string{"GetArrange", "获取应用编排"},
 				[]string{"SetArrange", "设置应用编排"},
+				[]string{"RenderArrange", "预览应用编排渲染"},
+				[]string{"GetArrangeRevisions", "获取应用编排历史版本"},
+				[]string{"GetArrangeRevision", "获取应用编排版本详情"},
+				[]string{"DiffArrangeRevisions", "对比应用编排版本"},
+				[]string{"RestoreArrangeRevision", "恢复应用编排版本"},
 				[]string{"DeleteProjectApp", "删除项目应用"},
 				[]string{"ParserAppArrange", "应用编排解析"},
 				[]string{"GetJenkinsConfig", "获取Jenkins配置"},
@@ -127,7 +148,27 @@ var resourceReq = ResourceReq{
 				[]string{"GetProjectEnvsByPagination", "项目环境分页列表"},
 				[]string{"CreateProjectEnv", "新建项目环境"},
 				[]string{"UpdateProjectEnv", "更新项目环境"},
+				[]string{"CloneProjectEnv", "克隆项目环境"},
+				[]string{"GetPreviewEnvs", "合并请求预览环境列表"},
+				[]string{"GetDeployFreezes", "项目封版列表"},
+				[]string{"CreateDeployFreeze", "新建项目封版"},
+				[]string{"DeleteDeployFreeze", "删除项目封版"},
+				[]string{"GetProjectEnvLock", "获取环境锁定状态"},
+				[]string{"LockProjectEnv", "锁定环境"},
+				[]string{"UnlockProjectEnv", "解锁环境"},
+				[]string{"GetAgentTemplate", "获取构建代理模板"},
+				[]string{"UpdateAgentTemplate", "更新构建代理模板"},
+				[]string{"GetServiceAccounts", "项目服务账号列表"},
+				[]string{"CreateServiceAccount", "新建项目服务账号"},
+				[]string{"DeleteServiceAccount", "删除项目服务账号"},
+				[]string{"GetServiceAccountTokens", "服务账号令牌列表"},
+				[]string{"CreateServiceAccountToken", "新建服务账号令牌"},
+				[]string{"RevokeServiceAccountToken", "吊销服务账号令牌"},
 				[]string{"ProjectAppServiceStats", "获取项目应用统计"},
+				[]string{"GetDORAMetrics", "获取项目DORA效能指标"},
+				[]string{"ExportDORAMetrics", "导出项目DORA效能指标"},
+				[]string{"GetStepAnalytics", "获取项目步骤耗时与失败分析"},
+				[]string{"GetGraphQLQuery", "GraphQL 只读查询"},
 			},
 			ResourceConstraint: [][]string{
 				[]string{"project_id", "项目ID"},
@@ -140,6 +181,7 @@ var resourceReq = ResourceReq{
 				[]string{"GetProjectPipelines", "项目流程列表"},
 				[]string{"PublishList", "流水线列表"},
 				[]string{"CreatePublishOrder", "创建流水线"},
+				[]string{"BatchPublish", "批量操作流水线"},
 				[]string{"GetPublish", "流水线详情"},
 				[]string{"ClosePublish", "关闭流水线"},
 				[]string{"DeletePublish", "删除流水线"},
@@ -147,13 +189,55 @@ var resourceReq = ResourceReq{
 				[]string{"AddPublishApp", "版本添加应用"},
 				[]string{"DeletePublishApp", "版本删除应用"},
 				[]string{"GetOpertaionLogByPagination", "获取流水线操作日志"},
+				[]string{"GetPublishJobStages", "获取流水线任务步骤耗时"},
+				[]string{"GetPublishJobLog", "获取流水线任务日志"},
 				[]string{"GetBackTo", "获取回退列表"},
 				[]string{"TriggerBackTo", "触发流水线回退操作"},
 				[]string{"GetNextStage", "获取流转列表"},
 				[]string{"TriggerNextStage", "触发流水线流转操作"},
+				[]string{"ApproveStage", "审批发布单进入阶段"},
+				[]string{"GetPublishAging", "发布单等待时长统计"},
 				[]string{"GetStepInfo", "获取步骤执行信息"},
 				[]string{"RunStep", "触发步骤执行"},
 				[]string{"RunStepCallback", "步骤执行回调"},
+				[]string{"ReportTerraform", "上报Terraform执行结果"},
+				[]string{"GetTerraformPlans", "获取Terraform计划列表"},
+				[]string{"GetPublishGraph", "获取流水线执行图"},
+				[]string{"ReportDBMigration", "上报数据库迁移版本"},
+				[]string{"GetDBMigrations", "获取数据库迁移记录"},
+				[]string{"ReportE2ETest", "上报端到端测试报告"},
+				[]string{"GetE2ETestReports", "获取端到端测试报告"},
+				[]string{"ReportPerfTest", "上报性能测试结果"},
+				[]string{"GetPerfTestResults", "获取性能测试结果"},
+				[]string{"ReportQuality", "上报质量报告"},
+				[]string{"GetQualitySummary", "获取质量汇总"},
+				[]string{"ReportSBOM", "上报镜像SBOM"},
+				[]string{"GetPublishSBOMs", "获取镜像SBOM列表"},
+				[]string{"DownloadSBOM", "下载镜像SBOM"},
+				[]string{"FindSBOMDeployments", "查询包含软件包的部署"},
+				[]string{"GetJobQueue", "获取任务排队列表"},
+				[]string{"CancelJobQueueItem", "取消排队任务"},
+				[]string{"GetStepTask", "获取步骤任务状态"},
+				[]string{"GetJobCallbacks", "获取任务回调记录"},
+				[]string{"ReplayJobCallback", "重放任务回调"},
+				[]string{"GetAppImageTags", "获取应用镜像版本列表"},
+				[]string{"PreviewImageRetention", "预览镜像清理"},
+				[]string{"ExportReleaseNotes", "导出发布说明"},
+				[]string{"GenerateReleaseNotes", "生成发布说明"},
+				[]string{"DiffPublishes", "对比发布单"},
+				[]string{"GetPublishIssues", "获取关联需求"},
+				[]string{"LinkPublishIssues", "关联需求"},
+				[]string{"GetPublishComments", "获取发布单评论"},
+				[]string{"CreatePublishComment", "发表发布单评论"},
+				[]string{"UpdatePublishComment", "编辑发布单评论"},
+				[]string{"DeletePublishComment", "删除发布单评论"},
+				[]string{"DownloadCommentAttachment", "下载评论附件"},
+				[]string{"GetReleasePlans", "发布计划列表"},
+				[]string{"CreateReleasePlan", "创建发布计划"},
+				[]string{"GetReleasePlan", "发布计划详情"},
+				[]string{"UpdateReleasePlan", "更新发布计划"},
+				[]string{"DeleteReleasePlan", "删除发布计划"},
+				[]string{"CreatePublishFromReleasePlan", "根据发布计划创建流水线"},
 			},
 			ResourceConstraint: [][]string{
 				[]string{"project_id", "项目ID"},
@@ -161,19 +245,68 @@ var resourceReq = ResourceReq{
 				[]string{"envID", "环境ID"},
 			},
 		},
+		BatchResourceTypeSpec{
+			ResourceType: []string{"organization", "组织"},
+			ResourceOperation: [][]string{
+				[]string{"*", "组织所有操作"},
+				[]string{"GetOrganizations", "获取组织列表"},
+				[]string{"CreateOrganization", "创建组织"},
+				[]string{"GetOrganization", "获取组织详情及配额使用"},
+				[]string{"UpdateOrganization", "更新组织及配额"},
+				[]string{"DeleteOrganization", "删除组织"},
+				[]string{"GetOrganizationMembers", "获取组织成员列表"},
+				[]string{"AddOrganizationMember", "添加组织成员"},
+				[]string{"RemoveOrganizationMember", "移除组织成员"},
+				[]string{"MoveOrganizationProject", "移动项目到组织"},
+			},
+			ResourceConstraint: [][]string{},
+		},
 		BatchResourceTypeSpec{
 			ResourceType: []string{"system", "系统设置"},
 			ResourceOperation: [][]string{
 				[]string{"*", "系统设置所有操作"},
 				[]string{"GetCompileEnvs", "编译环境列表"},
+				[]string{"GetCompileEnvVersions", "编译环境版本列表"},
+				[]string{"ActivateCompileEnvVersion", "启用编译环境版本"},
+				[]string{"GetCompileCommands", "编译命令列表"},
+				[]string{"CreateCompileCommand", "创建编译命令"},
+				[]string{"UpdateCompileCommand", "更新编译命令"},
+				[]string{"DeleteCompileCommand", "删除编译命令"},
+				[]string{"GetCompileCommandRevisions", "编译命令版本列表"},
+				[]string{"RestoreCompileCommandRevision", "恢复编译命令版本"},
+				[]string{"GetDockerfileTemplates", "Dockerfile模板列表"},
+				[]string{"CreateDockerfileTemplate", "创建Dockerfile模板"},
+				[]string{"UpdateDockerfileTemplate", "更新Dockerfile模板"},
+				[]string{"DeleteDockerfileTemplate", "删除Dockerfile模板"},
+				[]string{"DeprecateCompileEnv", "废弃编译环境"},
+				[]string{"ValidateCompileEnv", "校验编译环境"},
+				[]string{"GetServiceTemplates", "服务模板列表"},
+				[]string{"GetServiceTemplate", "服务模板详情"},
+				[]string{"CreateServiceTemplate", "创建服务模板"},
+				[]string{"UpdateServiceTemplate", "更新服务模板"},
+				[]string{"DeleteServiceTemplate", "删除服务模板"},
 				[]string{"GetIntegrateClusters", "获取集成的集群列表"},
 				[]string{"GetIntegrateSettings", "获取集成配置列表"},
+				[]string{"GetIntegrateSettingHealths", "集成配置健康状态列表"},
+				[]string{"CheckIntegrateSetting", "检查集成配置健康状态"},
+				[]string{"ParseKubeConfig", "解析上传的 kubeconfig"},
+				[]string{"GetClusterCapability", "集群版本及 API 资源"},
+				[]string{"GetWorkflowDrivers", "CI 驱动及其能力列表"},
+				[]string{"GetRunners", "Runner 列表"},
+				[]string{"DeleteRunner", "删除 Runner"},
+				[]string{"GetIntegrateCredentials", "集成配置凭据版本列表"},
+				[]string{"AddIntegrateCredential", "新增集成配置凭据版本"},
+				[]string{"ValidateIntegrateCredential", "校验集成配置凭据版本"},
+				[]string{"ActivateIntegrateCredential", "启用集成配置凭据版本"},
+				[]string{"RollbackIntegrateCredential", "回滚集成配置凭据版本"},
 
 				[]string{"FlowComponentList", "获取基础组件列表"},
 				[]string{"FlowStepListByPagination", "获取任务模板分页列表"},
 				[]string{"FlowStepCreate", "创建任务模板"},
 				[]string{"FlowStepUpdate", "更新任务模板"},
 				[]string{"FlowStepDelete", "删除任务模板"},
+				[]string{"Archive", "归档流水线任务及操作记录"},
+				[]string{"PurgeArchives", "清理归档数据"},
 			},
 			ResourceConstraint: [][]string{},
 		},
@@ -185,7 +318,12 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/login", "POST", "atomci", "auth", "UserLogin"},
 		[]string{"atomci/api/v1/logout", "GET", "atomci", "auth", "UserLogout"},
 		[]string{"atomci/api/v1/getCurrentUser", "GET", "atomci", "auth", "GetCurrentUser"},
+		[]string{"atomci/api/v1/tokens", "GET", "atomci", "auth", "GetAccessTokens"},
+		[]string{"atomci/api/v1/tokens", "POST", "atomci", "auth", "CreateAccessToken"},
+		[]string{"atomci/api/v1/tokens/:token_id", "DELETE", "atomci", "auth", "RevokeAccessToken"},
 		[]string{"atomci/api/v1/audit", "GET", "atomci", "audit", "AuditList"},
+		[]string{"atomci/api/v1/audit", "POST", "atomci", "audit", "AuditListByPagination"},
+		[]string{"atomci/api/v1/audit/export", "POST", "atomci", "audit", "AuditExport"},
 		[]string{"atomci/api/v1/users", "GET", "atomci", "user", "UserList"},
 		[]string{"atomci/api/v1/users", "POST", "atomci", "user", "CreateUser"},
 		[]string{"atomci/api/v1/users/:user", "GET", "atomci", "user", "GetUser"},
@@ -200,6 +338,15 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/groups/:group/users", "POST", "atomci", "group", "AddGroupUsers"},
 		[]string{"atomci/api/v1/groups/:group/users/:user", "PUT", "atomci", "group", "UpdateGroupUser"},
 		[]string{"atomci/api/v1/groups/:group/users/:user", "DELETE", "atomci", "group", "RemoveGroupUser"},
+		[]string{"atomci/api/v1/orgs", "GET", "atomci", "organization", "GetOrganizations"},
+		[]string{"atomci/api/v1/orgs", "POST", "atomci", "organization", "CreateOrganization"},
+		[]string{"atomci/api/v1/orgs/:org_id", "GET", "atomci", "organization", "GetOrganization"},
+		[]string{"atomci/api/v1/orgs/:org_id", "PUT", "atomci", "organization", "UpdateOrganization"},
+		[]string{"atomci/api/v1/orgs/:org_id", "DELETE", "atomci", "organization", "DeleteOrganization"},
+		[]string{"atomci/api/v1/orgs/:org_id/members", "GET", "atomci", "organization", "GetOrganizationMembers"},
+		[]string{"atomci/api/v1/orgs/:org_id/members", "POST", "atomci", "organization", "AddOrganizationMember"},
+		[]string{"atomci/api/v1/orgs/:org_id/members/:user", "DELETE", "atomci", "organization", "RemoveOrganizationMember"},
+		[]string{"atomci/api/v1/orgs/:org_id/projects/:project_id", "PUT", "atomci", "organization", "MoveOrganizationProject"},
 
 		// app repo
 		[]string{"atomci/api/v1/integrate/settings/scms", "GET", "atomci", "repository", "GetSCMIntegrateSettings"},
@@ -210,17 +357,23 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/repos/:repo_id/projects", "POST", "atomci", "repository", "GetGitProjectsByRepoID"},
 		[]string{"atomci/api/v1/apps/:app_id/branches", "POST", "atomci", "repository", "GetAppBranches"},
 		[]string{"atomci/api/v1/apps/:app_id/syncBranches", "POST", "atomci", "repository", "SyncAppBranches"},
+		[]string{"atomci/api/v1/apps/:app_id/webhook", "POST", "atomci", "repository", "RegisterAppWebhook"},
 		[]string{"atomci/api/v1/apps/:app_id", "GET", "atomci", "repository", "GetScmApp"},
 		[]string{"atomci/api/v1/apps/:app_id", "PUT", "atomci", "repository", "UpdateScmApp"},
 		[]string{"atomci/api/v1/apps/:app_id", "DELETE", "atomci", "repository", "DeleteScmApp"},
 
 		// project
 		[]string{"atomci/api/v1/projects", "POST", "atomci", "project", "ProjectList"},
+		[]string{"atomci/api/v1/catalog", "GET", "atomci", "project", "GetServiceCatalog"},
+		[]string{"atomci/api/v1/search", "GET", "atomci", "project", "GlobalSearch"},
 		[]string{"atomci/api/v1/users/:project_id/projectMemberByConstraint", "GET", "atomci", "project", "GetprojectMemberByConstraint"},
 		[]string{"atomci/api/v1/projects/create", "POST", "atomci", "project", "CreateProject"},
 		[]string{"atomci/api/v1/projects/:project_id", "PUT", "atomci", "project", "UpdateProject"},
 		[]string{"atomci/api/v1/projects/:project_id", "DELETE", "atomci", "project", "DeleteProject"},
 		[]string{"atomci/api/v1/projects/:project_id", "GET", "atomci", "project", "GetProject"},
+		[]string{"atomci/api/v1/projects/:project_id/export", "GET", "atomci", "project", "ExportProject"},
+		[]string{"atomci/api/v1/projects/import", "POST", "atomci", "project", "ImportProject"},
+		[]string{"atomci/api/v1/projects/:project_id/import", "POST", "atomci", "project", "ImportProjectConfig"},
 		[]string{"atomci/api/v1/projects/:project_id/pipelines", "GET", "atomci", "project", "GetProjectPipelines"},
 		[]string{"atomci/api/v1/projects/:project_id/pipelines", "POST", "atomci", "project", "GetProjectPipelinesByPagination"},
 		[]string{"atomci/api/v1/pipelines/flow/steps", "GET", "atomci", "project", "FlowStepList"},
@@ -229,15 +382,25 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/projects/:project_id/pipelines/:id", "PUT", "atomci", "project", "PipelineUpdate"},
 		[]string{"atomci/api/v1/projects/:project_id/pipelines/:id", "DELETE", "atomci", "project", "PipelineDelete"},
 		[]string{"atomci/api/v1/projects/:project_id/apps/create", "POST", "atomci", "project", "CreateProjectApp"},
+		[]string{"atomci/api/v1/projects/:project_id/services", "POST", "atomci", "project", "ScaffoldService"},
 		[]string{"atomci/api/v1/projects/:project_id/apps", "GET", "atomci", "project", "GetProjectApps"},
 		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id", "GET", "atomci", "project", "GetProjectApp"},
 		[]string{"atomci/api/v1/projects/:project_id/apps", "POST", "atomci", "project", "GetProjectAppsByPagination"},
 		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange", "GET", "atomci", "project", "GetArrange"},
 		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange", "POST", "atomci", "project", "SetArrange"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange/render", "POST", "atomci", "project", "RenderArrange"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange/revisions", "GET", "atomci", "project", "GetArrangeRevisions"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange/revisions/:revision", "GET", "atomci", "project", "GetArrangeRevision"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange/revisions/:revision/diff", "GET", "atomci", "project", "DiffArrangeRevisions"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:app_id/:arrange_env/arrange/revisions/:revision/restore", "POST", "atomci", "project", "RestoreArrangeRevision"},
 		[]string{"atomci/api/v1/arrange/yaml/parser", "POST", "atomci", "project", "ParserAppArrange"},
 		[]string{"atomci/api/v1/pipelines/stages/:stage_id/jenkins-config", "GET", "atomci", "project", "GetJenkinsConfig"},
 
 		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id", "PUT", "atomci", "project", "UpdateProjectApp"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id/deploy-after", "PUT", "atomci", "project", "UpdateProjectAppDeployAfter"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id/compile-command", "PUT", "atomci", "project", "UpdateProjectAppCompileCommand"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id/dockerfile-template", "PUT", "atomci", "project", "UpdateProjectAppDockerfileTemplate"},
+		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id/ownership", "PUT", "atomci", "project", "UpdateProjectAppOwnership"},
 		[]string{"atomci/api/v1/projects/:project_id/apps/:project_app_id", "DELETE", "atomci", "project", "DeleteProjectApp"},
 		[]string{"atomci/api/v1/projects/:project_id/clusters/:cluster/apps", "POST", "atomci", "project", "GetProjectAppServices"},
 		[]string{"atomci/api/v1/clusters/:cluster/namespaces/:namespace/apps/:app", "GET", "atomci", "project", "GetAppServiceInspect"},
@@ -249,14 +412,36 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/clusters/:cluster/namespaces/:namespace/pods/:podname/containernames/:containername", "GET", "atomci", "project", "AppServiceTerminal"},
 
 		[]string{"atomci/api/v1/projects/:project_id/publish/stats", "POST", "atomci", "project", "ProjectPublishStats"},
+		[]string{"atomci/api/v1/projects/:project_id/analytics/dora", "GET", "atomci", "project", "GetDORAMetrics"},
+		[]string{"atomci/api/v1/projects/:project_id/analytics/dora/export", "GET", "atomci", "project", "ExportDORAMetrics"},
+		[]string{"atomci/api/v1/projects/:project_id/analytics/steps", "GET", "atomci", "project", "GetStepAnalytics"},
+		[]string{"atomci/api/v1/graphql", "GET", "atomci", "project", "GetGraphQLQuery"},
+		[]string{"atomci/api/v1/graphql", "POST", "atomci", "project", "GetGraphQLQuery"},
 		[]string{"atomci/api/v1/projects/:project_id/envs", "GET", "atomci", "project", "GetProjectEnvs"},
 		[]string{"atomci/api/v1/projects/:project_id/envs", "POST", "atomci", "project", "GetProjectEnvsByPagination"},
 		[]string{"atomci/api/v1/projects/:project_id/envs/create", "POST", "atomci", "project", "CreateProjectEnv"},
 		[]string{"atomci/api/v1/projects/:project_id/envs/:env_id", "PUT", "atomci", "project", "UpdateProjectEnv"},
+		[]string{"atomci/api/v1/projects/:project_id/envs/:env_id/clone", "POST", "atomci", "project", "CloneProjectEnv"},
+		[]string{"atomci/api/v1/projects/:project_id/previews", "GET", "atomci", "project", "GetPreviewEnvs"},
+		[]string{"atomci/api/v1/projects/:project_id/freezes", "GET", "atomci", "project", "GetDeployFreezes"},
+		[]string{"atomci/api/v1/projects/:project_id/freezes", "POST", "atomci", "project", "CreateDeployFreeze"},
+		[]string{"atomci/api/v1/projects/:project_id/freezes/:freeze_id", "DELETE", "atomci", "project", "DeleteDeployFreeze"},
+		[]string{"atomci/api/v1/projects/:project_id/envs/:env_id/lock", "GET", "atomci", "project", "GetProjectEnvLock"},
+		[]string{"atomci/api/v1/projects/:project_id/envs/:env_id/lock", "POST", "atomci", "project", "LockProjectEnv"},
+		[]string{"atomci/api/v1/projects/:project_id/envs/:env_id/lock", "DELETE", "atomci", "project", "UnlockProjectEnv"},
+		[]string{"atomci/api/v1/projects/:project_id/agent-template", "GET", "atomci", "project", "GetAgentTemplate"},
+		[]string{"atomci/api/v1/projects/:project_id/agent-template", "PUT", "atomci", "project", "UpdateAgentTemplate"},
+		[]string{"atomci/api/v1/projects/:project_id/service-accounts", "GET", "atomci", "project", "GetServiceAccounts"},
+		[]string{"atomci/api/v1/projects/:project_id/service-accounts", "POST", "atomci", "project", "CreateServiceAccount"},
+		[]string{"atomci/api/v1/projects/:project_id/service-accounts/:account", "DELETE", "atomci", "project", "DeleteServiceAccount"},
+		[]string{"atomci/api/v1/projects/:project_id/service-accounts/:account/tokens", "GET", "atomci", "project", "GetServiceAccountTokens"},
+		[]string{"atomci/api/v1/projects/:project_id/service-accounts/:account/tokens", "POST", "atomci", "project", "CreateServiceAccountToken"},
+		[]string{"atomci/api/v1/projects/:project_id/service-accounts/:account/tokens/:token_id", "DELETE", "atomci", "project", "RevokeServiceAccountToken"},
 
 		// publish
 		[]string{"atomci/api/v1/projects/:project_id/publishes", "POST", "atomci", "publish", "PublishList"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/create", "POST", "atomci", "publish", "CreatePublishOrder"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/batch", "POST", "atomci", "publish", "BatchPublish"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id", "GET", "atomci", "publish", "GetPublish"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id", "PUT", "atomci", "publish", "ClosePublish"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id", "DELETE", "atomci", "publish", "DeletePublish"},
@@ -264,18 +449,91 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/apps/create", "POST", "atomci", "publish", "AddPublishApp"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/apps/:publish_app_id", "DELETE", "atomci", "publish", "DeletePublishApp"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/audits", "POST", "atomci", "publish", "GetOpertaionLogByPagination"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/job-stages", "GET", "atomci", "publish", "GetPublishJobStages"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/jobs/:job_id/log", "GET", "atomci", "publish", "GetPublishJobLog"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/stages/:stage_id/back-to", "GET", "atomci", "publish", "GetBackTo"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/stages/:stage_id/back-to", "POST", "atomci", "publish", "TriggerBackTo"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/stages/:stage_id/next-stage", "GET", "atomci", "publish", "GetNextStage"},
 		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/stages/:stage_id/next-stage", "POST", "atomci", "publish", "TriggerNextStage"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/stages/:stage_id/approval", "POST", "atomci", "publish", "ApproveStage"},
+		[]string{"atomci/api/v1/projects/:project_id/publish/aging", "GET", "atomci", "publish", "GetPublishAging"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/release-notes", "GET", "atomci", "publish", "ExportReleaseNotes"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/release-notes", "POST", "atomci", "publish", "GenerateReleaseNotes"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/diff", "GET", "atomci", "publish", "DiffPublishes"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/issues", "GET", "atomci", "publish", "GetPublishIssues"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/issues", "POST", "atomci", "publish", "LinkPublishIssues"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/comments", "GET", "atomci", "publish", "GetPublishComments"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/comments", "POST", "atomci", "publish", "CreatePublishComment"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/comments/:comment_id", "PUT", "atomci", "publish", "UpdatePublishComment"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/comments/:comment_id", "DELETE", "atomci", "publish", "DeletePublishComment"},
+		[]string{"atomci/api/v1/projects/:project_id/publishes/:publish_id/attachments/:attachment_id", "GET", "atomci", "publish", "DownloadCommentAttachment"},
+		[]string{"atomci/api/v1/projects/:project_id/release-plans", "GET", "atomci", "publish", "GetReleasePlans"},
+		[]string{"atomci/api/v1/projects/:project_id/release-plans/create", "POST", "atomci", "publish", "CreateReleasePlan"},
+		[]string{"atomci/api/v1/projects/:project_id/release-plans/:plan_id", "GET", "atomci", "publish", "GetReleasePlan"},
+		[]string{"atomci/api/v1/projects/:project_id/release-plans/:plan_id", "PUT", "atomci", "publish", "UpdateReleasePlan"},
+		[]string{"atomci/api/v1/projects/:project_id/release-plans/:plan_id", "DELETE", "atomci", "publish", "DeleteReleasePlan"},
+		[]string{"atomci/api/v1/projects/:project_id/release-plans/:plan_id/publishes", "POST", "atomci", "publish", "CreatePublishFromReleasePlan"},
 		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name", "GET", "atomci", "publish", "GetStepInfo"},
 		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name", "POST", "atomci", "publish", "RunStep"},
 		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/callback", "POST", "atomci", "publish", "RunStepCallback"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/terraform", "POST", "atomci", "publish", "ReportTerraform"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/terraform-plans", "GET", "atomci", "publish", "GetTerraformPlans"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/graph", "GET", "atomci", "publish", "GetPublishGraph"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/db-migration", "POST", "atomci", "publish", "ReportDBMigration"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/db-migrations", "GET", "atomci", "publish", "GetDBMigrations"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/e2e-report", "POST", "atomci", "publish", "ReportE2ETest"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/e2e-reports", "GET", "atomci", "publish", "GetE2ETestReports"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/perf-report", "POST", "atomci", "publish", "ReportPerfTest"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/perf-tests", "GET", "atomci", "publish", "GetPerfTestResults"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/quality", "POST", "atomci", "publish", "ReportQuality"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/quality", "GET", "atomci", "publish", "GetQualitySummary"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/sbom", "POST", "atomci", "publish", "ReportSBOM"},
+		[]string{"atomci/api/v1/pipelines/:project_id/publishes/:publish_id/sboms", "GET", "atomci", "publish", "GetPublishSBOMs"},
+		[]string{"atomci/api/v1/pipelines/:project_id/sboms/:sbom_id/download", "GET", "atomci", "publish", "DownloadSBOM"},
+		[]string{"atomci/api/v1/sbom/deployments", "GET", "atomci", "publish", "FindSBOMDeployments"},
+		[]string{"atomci/api/v1/pipelines/:project_id/stages/:stage_id/queue", "GET", "atomci", "publish", "GetJobQueue"},
+		[]string{"atomci/api/v1/pipelines/:project_id/queue/:queue_id", "DELETE", "atomci", "publish", "CancelJobQueueItem"},
+		[]string{"atomci/api/v1/pipelines/:project_id/tasks/:task_id", "GET", "atomci", "publish", "GetStepTask"},
+		[]string{"atomci/api/v1/pipelines/:project_id/callbacks", "GET", "atomci", "publish", "GetJobCallbacks"},
+		[]string{"atomci/api/v1/pipelines/:project_id/callbacks/:callback_id/replay", "POST", "atomci", "publish", "ReplayJobCallback"},
+		[]string{"atomci/api/v1/pipelines/:project_id/stages/:stage_id/apps/:app_id/image-tags", "GET", "atomci", "publish", "GetAppImageTags"},
+		[]string{"atomci/api/v1/pipelines/:project_id/image-retention", "GET", "atomci", "publish", "PreviewImageRetention"},
 
 		// integrate
 		[]string{"atomci/api/v1/integrate/compile_envs", "GET", "atomci", "system", "GetCompileEnvs"},
+		[]string{"atomci/api/v1/integrate/compile_envs/:id/versions", "GET", "atomci", "system", "GetCompileEnvVersions"},
+		[]string{"atomci/api/v1/integrate/compile_envs/:id/versions/:version/activate", "POST", "atomci", "system", "ActivateCompileEnvVersion"},
+		[]string{"atomci/api/v1/integrate/compile_commands", "GET", "atomci", "system", "GetCompileCommands"},
+		[]string{"atomci/api/v1/integrate/compile_commands", "POST", "atomci", "system", "CreateCompileCommand"},
+		[]string{"atomci/api/v1/integrate/compile_commands/:id", "PUT", "atomci", "system", "UpdateCompileCommand"},
+		[]string{"atomci/api/v1/integrate/compile_commands/:id", "DELETE", "atomci", "system", "DeleteCompileCommand"},
+		[]string{"atomci/api/v1/integrate/compile_commands/:id/revisions", "GET", "atomci", "system", "GetCompileCommandRevisions"},
+		[]string{"atomci/api/v1/integrate/compile_commands/:id/revisions/:revision/restore", "POST", "atomci", "system", "RestoreCompileCommandRevision"},
+		[]string{"atomci/api/v1/integrate/dockerfile_templates", "GET", "atomci", "system", "GetDockerfileTemplates"},
+		[]string{"atomci/api/v1/integrate/dockerfile_templates", "POST", "atomci", "system", "CreateDockerfileTemplate"},
+		[]string{"atomci/api/v1/integrate/dockerfile_templates/:id", "PUT", "atomci", "system", "UpdateDockerfileTemplate"},
+		[]string{"atomci/api/v1/integrate/dockerfile_templates/:id", "DELETE", "atomci", "system", "DeleteDockerfileTemplate"},
+		[]string{"atomci/api/v1/integrate/compile_envs/:id/deprecation", "PUT", "atomci", "system", "DeprecateCompileEnv"},
+		[]string{"atomci/api/v1/integrate/compile_envs/:id/validate", "POST", "atomci", "system", "ValidateCompileEnv"},
+		[]string{"atomci/api/v1/integrate/service_templates", "GET", "atomci", "system", "GetServiceTemplates"},
+		[]string{"atomci/api/v1/integrate/service_templates", "POST", "atomci", "system", "CreateServiceTemplate"},
+		[]string{"atomci/api/v1/integrate/service_templates/:id", "GET", "atomci", "system", "GetServiceTemplate"},
+		[]string{"atomci/api/v1/integrate/service_templates/:id", "PUT", "atomci", "system", "UpdateServiceTemplate"},
+		[]string{"atomci/api/v1/integrate/service_templates/:id", "DELETE", "atomci", "system", "DeleteServiceTemplate"},
 		[]string{"atomci/api/v1/integrate/clusters", "GET", "atomci", "system", "GetIntegrateClusters"},
 		[]string{"atomci/api/v1/integrate/settings", "GET", "atomci", "system", "GetIntegrateSettings"},
+		[]string{"atomci/api/v1/integrate/health", "GET", "atomci", "system", "GetIntegrateSettingHealths"},
+		[]string{"atomci/api/v1/integrate/settings/:id/health", "POST", "atomci", "system", "CheckIntegrateSetting"},
+		[]string{"atomci/api/v1/integrate/settings/kubeconfig", "POST", "atomci", "system", "ParseKubeConfig"},
+		[]string{"atomci/api/v1/integrate/settings/:id/capability", "GET", "atomci", "system", "GetClusterCapability"},
+		[]string{"atomci/api/v1/integrate/workflow-drivers", "GET", "atomci", "system", "GetWorkflowDrivers"},
+		[]string{"atomci/api/v1/integrate/runners", "GET", "atomci", "system", "GetRunners"},
+		[]string{"atomci/api/v1/integrate/runners/:runner_id", "DELETE", "atomci", "system", "DeleteRunner"},
+		[]string{"atomci/api/v1/integrate/settings/:id/credentials", "GET", "atomci", "system", "GetIntegrateCredentials"},
+		[]string{"atomci/api/v1/integrate/settings/:id/credentials", "POST", "atomci", "system", "AddIntegrateCredential"},
+		[]string{"atomci/api/v1/integrate/settings/:id/credentials/rollback", "POST", "atomci", "system", "RollbackIntegrateCredential"},
+		[]string{"atomci/api/v1/integrate/settings/:id/credentials/:version/validate", "POST", "atomci", "system", "ValidateIntegrateCredential"},
+		[]string{"atomci/api/v1/integrate/settings/:id/credentials/:version/activate", "POST", "atomci", "system", "ActivateIntegrateCredential"},
 
 		// task template
 		[]string{"atomci/api/v1/pipelines/flow/components", "GET", "atomci", "system", "FlowComponentList"},
@@ -283,5 +541,16 @@ var gaetwayReq = RouterReq{
 		[]string{"atomci/api/v1/pipelines/flow/steps/create", "POST", "atomci", "system", "FlowStepCreate"},
 		[]string{"atomci/api/v1/pipelines/flow/steps/:step_id", "PUT", "atomci", "system", "FlowStepUpdate"},
 		[]string{"atomci/api/v1/pipelines/flow/steps/:step_id", "DELETE", "atomci", "system", "FlowStepDelete"},
+		[]string{"atomci/api/v1/archive", "POST", "atomci", "system", "Archive"},
+		[]string{"atomci/api/v1/archive/purge", "POST", "atomci", "system", "PurgeArchives"},
+
+		// api v2, reuse the operations of api v1
+		[]string{"atomci/api/v2/projects", "GET", "atomci", "project", "ProjectList"},
+		[]string{"atomci/api/v2/projects/:project_id", "GET", "atomci", "project", "GetProject"},
+		[]string{"atomci/api/v2/projects/:project_id/envs", "GET", "atomci", "project", "GetProjectEnvs"},
+		[]string{"atomci/api/v2/projects/:project_id/publishes", "GET", "atomci", "publish", "PublishList"},
+		[]string{"atomci/api/v2/projects/:project_id/publishes/:publish_id", "GET", "atomci", "publish", "GetPublish"},
+		[]string{"atomci/api/v2/projects/:project_id/publishes/:publish_id/jobs", "GET", "atomci", "publish", "GetPublish"},
+		[]string{"atomci/api/v2/projects/:project_id/publishes/:publish_id/operation-logs", "GET", "atomci", "publish", "GetOpertaionLogByPagination"},
 	},
 }
@@ -19,6 +19,7 @@ package initialize
 import (
 	"os"
 
+	"github.com/go-atomci/atomci/internal/core/settings"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 	"github.com/go-atomci/atomci/utils/errors"
 )
@@ -47,4 +48,9 @@ func Init() {
 			os.Exit(2)
 		}
 	}
+
+	// 注册集成配置的 TLS 证书
+	if err := settings.NewSettingManager().RegisterIntegrateTLS(); err != nil {
+		log.Log.Warn("register integrate settings tls error: %s", err.Error())
+	}
 }
@@ -110,14 +110,27 @@ func initSystemRole() error {
 
 	sysMemberResourceOperations, err := dao.GetResourceOperationByResourceOperations([]string{
 		"GetCurrentUser",
+		"GetAccessTokens",
+		"CreateAccessToken",
+		"RevokeAccessToken",
 
 		"ProjectList",
+		"GetServiceCatalog",
+		"GlobalSearch",
 		"CreateProject",
 		"UpdateProject",
 		"GetprojectMemberByConstraint",
 		"GetProject",
+		"ExportProject",
+		"ImportProject",
+		"ImportProjectConfig",
 		"CreateProjectApp",
+		"ScaffoldService",
 		"UpdateProjectApp",
+		"UpdateProjectAppDeployAfter",
+		"UpdateProjectAppCompileCommand",
+		"UpdateProjectAppDockerfileTemplate",
+		"UpdateProjectAppOwnership",
 		"GetProjectApps",
 		"GetProjectApp",
 		"GetAppsByPagination",
@@ -125,18 +138,45 @@ func initSystemRole() error {
 		"GetAllApps",
 		"GetArrange",
 		"SetArrange",
+		"RenderArrange",
+		"GetArrangeRevisions",
+		"GetArrangeRevision",
+		"DiffArrangeRevisions",
+		"RestoreArrangeRevision",
 		"GetAppBranches",
 		"GetGitProjectsByRepoID",
 		"SyncAppBranches",
+		"RegisterAppWebhook",
 		"DeleteProjectApp",
 		"GetProjectEnvs",
 		"GetIntegrateSettings",
 		"GetProjectEnvsByPagination",
 		"CreateProjectEnv",
 		"UpdateProjectEnv",
+		"CloneProjectEnv",
+		"GetPreviewEnvs",
+		"GetDeployFreezes",
+		"CreateDeployFreeze",
+		"DeleteDeployFreeze",
+		"GetProjectEnvLock",
+		"GetAgentTemplate",
 		"GetCompileEnvs",
+		"GetCompileEnvVersions",
+		"GetCompileCommands",
+		"GetDockerfileTemplates",
+		"GetServiceTemplates",
+		"GetServiceTemplate",
 		"GetIntegrateClusters",
 		"GetProjectPipelinesByPagination",
+		"GetDORAMetrics",
+		"ExportDORAMetrics",
+		"GetStepAnalytics",
+		"GetGraphQLQuery",
+		"GetOrganizations",
+		"GetOrganization",
+		"GetOrganizationMembers",
+		"AddOrganizationMember",
+		"RemoveOrganizationMember",
 
 		"ProjectPipelineInfo",
 		"PipelineCreate",
@@ -147,6 +187,7 @@ func initSystemRole() error {
 		"GetProjectPipelines",
 		"PublishList",
 		"CreatePublishOrder",
+		"BatchPublish",
 		"GetPublish",
 		"GetJenkinsConfig",
 		"ClosePublish",
@@ -155,13 +196,47 @@ func initSystemRole() error {
 		"AddPublishApp",
 		"DeletePublishApp",
 		"GetOpertaionLogByPagination",
+		"GetPublishJobStages",
+		"GetPublishJobLog",
 		"GetBackTo",
 		"TriggerBackTo",
 		"GetNextStage",
 		"TriggerNextStage",
+		"ApproveStage",
+		"GetPublishAging",
 		"GetStepInfo",
 		"RunStep",
 		"RunStepCallback",
+		"GetTerraformPlans",
+		"GetPublishGraph",
+		"GetDBMigrations",
+		"GetE2ETestReports",
+		"GetPerfTestResults",
+		"GetQualitySummary",
+		"GetPublishSBOMs",
+		"DownloadSBOM",
+		"GetJobQueue",
+		"CancelJobQueueItem",
+		"GetStepTask",
+		"GetJobCallbacks",
+		"GetAppImageTags",
+		"PreviewImageRetention",
+		"ExportReleaseNotes",
+		"GenerateReleaseNotes",
+		"DiffPublishes",
+		"GetPublishIssues",
+		"LinkPublishIssues",
+		"GetPublishComments",
+		"CreatePublishComment",
+		"UpdatePublishComment",
+		"DeletePublishComment",
+		"DownloadCommentAttachment",
+		"GetReleasePlans",
+		"CreateReleasePlan",
+		"GetReleasePlan",
+		"UpdateReleasePlan",
+		"DeleteReleasePlan",
+		"CreatePublishFromReleasePlan",
 
 		"GetProjectAppServices",
 		"GetAppServiceInspect",
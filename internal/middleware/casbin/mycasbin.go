@@ -20,8 +20,8 @@ import (
 	glog "log"
 	"sync"
 
-	"github.com/astaxie/beego"
 	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
@@ -81,8 +81,8 @@ m = g(r.sub, p.sub) && keyMatch2(r.obj,p.obj) && (r.act == p.act || p.act == "*"
 }
 
 func initAdapter() (*beegoormadapter.Adapter, error) {
-	dsn := beego.AppConfig.String("DB::url")
-	a, err := beegoormadapter.NewAdapter("casbin", "mysql", dsn)
+	dsn := models.DBURL()
+	a, err := beegoormadapter.NewAdapter("casbin", models.DBDriver(), dsn)
 	if err != nil {
 		log.Log.Error("beego orm adapter error: %s", err.Error())
 		return nil, err
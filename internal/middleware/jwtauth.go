@@ -54,5 +54,53 @@ func JwtParse(c *context.Context, token string) (string, error) {
 		return "", err
 	}
 	claims := jwtToken.Claims.(jwt.MapClaims)
-	return claims["username"].(string), nil
+	username, ok := claims["username"].(string)
+	if !ok {
+		return "", errors.New("Invalid token, username is missing")
+	}
+	return username, nil
+}
+
+// callbackSubject the subject of callback tokens, tell them from the login tokens
+const callbackSubject = "callback"
+
+// CallbackClaims the claims of callback token, which is issued to a single publish job
+type CallbackClaims struct {
+	ProjectID    int64  `json:"project_id"`
+	PublishID    int64  `json:"publish_id"`
+	StageID      int64  `json:"stage_id"`
+	Step         string `json:"step"`
+	PublishJobID int64  `json:"publish_job_id"`
+	jwt.StandardClaims
+}
+
+// CallbackAuth sign the callback token of publish job, the token expires after ttl
+func CallbackAuth(claims CallbackClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.Subject = callbackSubject
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	t, err := token.SignedString([]byte(beego.AppConfig.String("jwt::secret")))
+	if err != nil {
+		return "", errors.New("JWT Generate Failure")
+	}
+	return t, nil
+}
+
+// CallbackParse return the claims of the valid callback token
+func CallbackParse(token string) (*CallbackClaims, error) {
+	claims := &CallbackClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if jwt.GetSigningMethod(jwt.SigningMethodHS256.Alg()) != t.Method {
+			return nil, errors.New("Invalid signing algorithm")
+		}
+		return []byte(beego.AppConfig.String("jwt::secret")), nil
+	}); err != nil {
+		return nil, err
+	}
+	if claims.Subject != callbackSubject {
+		return nil, errors.New("Invalid callback token")
+	}
+	return claims, nil
 }
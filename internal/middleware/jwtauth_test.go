@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallbackToken(t *testing.T) {
+	claims := CallbackClaims{ProjectID: 1, PublishID: 2, StageID: 3, Step: "build", PublishJobID: 4}
+	token, err := CallbackAuth(claims, time.Hour)
+	if err != nil {
+		t.Fatalf("CallbackAuth() error = %v", err)
+	}
+	got, err := CallbackParse(token)
+	if err != nil {
+		t.Fatalf("CallbackParse() error = %v", err)
+	}
+	if got.ProjectID != 1 || got.PublishID != 2 || got.StageID != 3 || got.Step != "build" || got.PublishJobID != 4 {
+		t.Errorf("CallbackParse() = %+v, want %+v", got, claims)
+	}
+
+	expired, err := CallbackAuth(claims, -time.Minute)
+	if err != nil {
+		t.Fatalf("CallbackAuth() error = %v", err)
+	}
+	if _, err := CallbackParse(expired); err == nil {
+		t.Errorf("CallbackParse() of expired token error = nil")
+	}
+
+	login, err := JwtAuth("admin", "admin")
+	if err != nil {
+		t.Fatalf("JwtAuth() error = %v", err)
+	}
+	if _, err := CallbackParse(login); err == nil {
+		t.Errorf("CallbackParse() of login token error = nil")
+	}
+	if _, err := JwtParse(nil, token); err == nil {
+		t.Errorf("JwtParse() of callback token error = nil")
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/pkg/metrics"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+)
+
+// the limits of the api requests, the limit label of metrics
+const (
+	LimitIP    = "ip"
+	LimitToken = "token"
+	LimitLogin = "login"
+)
+
+// sweepInterval the interval of evicting the idle buckets and expired login failures
+const sweepInterval = time.Minute
+
+// rateLimiter the token bucket limiter by key, the rate is requests per second, 0 disables the limit
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst int) *rateLimiter {
+	if burst < rate {
+		burst = rate
+	}
+	return &rateLimiter{rate: float64(rate), burst: float64(burst), buckets: map[string]*bucket{}}
+}
+
+// allow take a token of the key, return the duration to wait when no token left
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep evict the buckets which are full again, they are the same as the new ones
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// loginGuard lock the login after too many failures in the window
+type loginGuard struct {
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+
+	mu        sync.Mutex
+	failures  map[string]*loginFailure
+	lastSweep time.Time
+}
+
+type loginFailure struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+func newLoginGuard(maxFailures int, window, lockout time.Duration) *loginGuard {
+	return &loginGuard{maxFailures: maxFailures, window: window, lockout: lockout, failures: map[string]*loginFailure{}}
+}
+
+// locked return the remaining lockout duration of the key
+func (g *loginGuard) locked(key string, now time.Time) time.Duration {
+	if g.maxFailures <= 0 {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.failures[key]; ok && now.Before(f.lockedUntil) {
+		return f.lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// fail record the failure of the key, return true when the key is locked by this failure
+func (g *loginGuard) fail(key string, now time.Time) bool {
+	if g.maxFailures <= 0 {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sweep(now)
+
+	f, ok := g.failures[key]
+	if !ok || now.Sub(f.first) > g.window {
+		f = &loginFailure{first: now}
+		g.failures[key] = f
+	}
+	f.count++
+	if f.count < g.maxFailures {
+		return false
+	}
+	f.count, f.first, f.lockedUntil = 0, now, now.Add(g.lockout)
+	return true
+}
+
+// reset clear the failures of the key after login succeeded
+func (g *loginGuard) reset(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.failures, key)
+}
+
+func (g *loginGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < sweepInterval {
+		return
+	}
+	g.lastSweep = now
+	for key, f := range g.failures {
+		if now.Sub(f.first) > g.window && now.After(f.lockedUntil) {
+			delete(g.failures, key)
+		}
+	}
+}
+
+var (
+	limitOnce    sync.Once
+	limitEnabled bool
+	trustProxy   bool
+	// trustedProxies the networks of the reverse proxies whose X-Forwarded-For hops are trusted
+	trustedProxies []*net.IPNet
+	ipLimiter      *rateLimiter
+	tokenLimiter   *rateLimiter
+	// userGuard lock the user on the client ip, ipGuard lock the client ip trying many users
+	userGuard *loginGuard
+	ipGuard   *loginGuard
+)
+
+func initRateLimit() {
+	limitOnce.Do(func() {
+		limitEnabled = beego.AppConfig.DefaultBool("ratelimit::enabled", true)
+		trustProxy = beego.AppConfig.DefaultBool("ratelimit::trust_proxy", false)
+		trustedProxies = parseTrustedProxies(beego.AppConfig.DefaultString("ratelimit::trusted_proxies", defaultTrustedProxies))
+		ipLimiter = newRateLimiter(beego.AppConfig.DefaultInt("ratelimit::ip_rate", 50), beego.AppConfig.DefaultInt("ratelimit::ip_burst", 100))
+		tokenLimiter = newRateLimiter(beego.AppConfig.DefaultInt("ratelimit::token_rate", 20), beego.AppConfig.DefaultInt("ratelimit::token_burst", 40))
+
+		window := time.Duration(beego.AppConfig.DefaultInt("ratelimit::login_window", 300)) * time.Second
+		lockout := time.Duration(beego.AppConfig.DefaultInt("ratelimit::login_lockout", 900)) * time.Second
+		userGuard = newLoginGuard(beego.AppConfig.DefaultInt("ratelimit::login_max_failures", 5), window, lockout)
+		// the clients behind the same NAT share the ip, the lockout by ip is disabled by default
+		ipGuard = newLoginGuard(beego.AppConfig.DefaultInt("ratelimit::login_max_failures_per_ip", 0), window, lockout)
+	})
+}
+
+// defaultTrustedProxies the reverse proxy on the same host
+const defaultTrustedProxies = "127.0.0.0/8,::1/128"
+
+// parseTrustedProxies parse the comma separated ips or cidrs, the invalid ones are ignored
+func parseTrustedProxies(value string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Log.Warn("invalid trusted proxy %v of rate limit: %s", item, err.Error())
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func isTrustedProxy(ip net.IP, proxies []*net.IPNet) bool {
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveClientIP return the remote ip, if the remote is a trusted proxy, the X-Forwarded-For hops are walked
+// from right to left and the first untrusted one is the client, the hops on the left are set by the client
+func resolveClientIP(remoteAddr, forwardedFor string, proxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	client := net.ParseIP(host)
+	if client == nil || !isTrustedProxy(client, proxies) {
+		return host
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop, proxies) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// ClientIP return the ip of client, the X-Forwarded-For header is used only when the proxy is trusted
+func ClientIP(ctx *context.Context) string {
+	initRateLimit()
+	if !trustProxy {
+		return resolveClientIP(ctx.Request.RemoteAddr, "", nil)
+	}
+	return resolveClientIP(ctx.Request.RemoteAddr, ctx.Input.Header("X-Forwarded-For"), trustedProxies)
+}
+
+// tokenKey the digest of the bearer token, the token itself is not kept in memory
+func tokenKey(ctx *context.Context) string {
+	authHeader := ctx.Input.Header("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.TrimPrefix(authHeader, "Bearer ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RateLimit check the rate limits of client ip and bearer token, return the limit rejected the request
+// and the duration to retry, the limit is empty if the request is allowed
+func RateLimit(ctx *context.Context) (string, time.Duration) {
+	initRateLimit()
+	if !limitEnabled {
+		return "", 0
+	}
+	now := time.Now()
+	if ok, wait := ipLimiter.allow(ClientIP(ctx), now); !ok {
+		metrics.RateLimitRejected.Inc(LimitIP)
+		return LimitIP, wait
+	}
+	if key := tokenKey(ctx); key != "" {
+		if ok, wait := tokenLimiter.allow(key, now); !ok {
+			metrics.RateLimitRejected.Inc(LimitToken)
+			return LimitToken, wait
+		}
+	}
+	return "", 0
+}
+
+func loginKey(user, ip string) string {
+	return user + "|" + ip
+}
+
+// LoginLocked return the remaining lockout duration of the login of user from the client ip
+func LoginLocked(user, ip string) time.Duration {
+	initRateLimit()
+	if !limitEnabled {
+		return 0
+	}
+	now := time.Now()
+	wait := userGuard.locked(loginKey(user, ip), now)
+	if ipWait := ipGuard.locked(ip, now); ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		metrics.RateLimitRejected.Inc(LimitLogin)
+	}
+	return wait
+}
+
+// LoginFailed record the failed login of user from the client ip
+func LoginFailed(user, ip string) {
+	initRateLimit()
+	if !limitEnabled {
+		return
+	}
+	now := time.Now()
+	if userGuard.fail(loginKey(user, ip), now) {
+		log.Log.Warn("login of user %v from %v is locked after too many failures", user, ip)
+	}
+	if ipGuard.fail(ip, now) {
+		log.Log.Warn("login from %v is locked after too many failures", ip)
+	}
+}
+
+// LoginSucceeded clear the failures of user and the client ip
+func LoginSucceeded(user, ip string) {
+	initRateLimit()
+	userGuard.reset(loginKey(user, ip))
+	ipGuard.reset(ip)
+}
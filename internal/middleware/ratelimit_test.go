@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 4)
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if ok, _ := l.allow("10.0.0.1", now); !ok {
+			t.Fatalf("request %d within the burst should be allowed", i)
+		}
+	}
+	ok, wait := l.allow("10.0.0.1", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("allow() after the burst = %v, %v, want false, 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("10.0.0.2", now); !ok {
+		t.Errorf("the other key should be allowed")
+	}
+	if ok, _ := l.allow("10.0.0.1", now.Add(wait)); !ok {
+		t.Errorf("request after the wait should be allowed")
+	}
+
+	if ok, _ := newRateLimiter(0, 0).allow("10.0.0.1", now); !ok {
+		t.Errorf("the disabled limiter should allow all requests")
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	g := newLoginGuard(3, time.Minute, 10*time.Minute)
+	now := time.Now()
+	key := loginKey("admin", "10.0.0.1")
+
+	g.fail(key, now)
+	g.fail(key, now)
+	g.reset(key)
+	g.fail(key, now)
+	if g.fail(key, now) || g.locked(key, now) != 0 {
+		t.Fatalf("the failures before reset should not be counted")
+	}
+	// the failures out of window are not counted
+	if g.fail(key, now.Add(2*time.Minute)) {
+		t.Fatalf("the failures out of window should not lock")
+	}
+	g.fail(key, now.Add(2*time.Minute))
+	if !g.fail(key, now.Add(2*time.Minute)) {
+		t.Fatalf("the key should be locked after max failures")
+	}
+	if wait := g.locked(key, now.Add(3*time.Minute)); wait != 9*time.Minute {
+		t.Errorf("locked() = %v, want 9m", wait)
+	}
+	if wait := g.locked(key, now.Add(12*time.Minute)); wait != 0 {
+		t.Errorf("locked() after lockout = %v, want 0", wait)
+	}
+}
+
+func TestLoginLockoutByUser(t *testing.T) {
+	ip := "172.18.0.3"
+	for i := 0; i < 50; i++ {
+		LoginFailed("attacker-target", ip)
+	}
+	if LoginLocked("attacker-target", ip) == 0 {
+		t.Errorf("the user failed too many times should be locked")
+	}
+	// the users sharing the ip, eg: behind the same proxy or NAT, are not locked
+	for _, user := range []string{"admin", "dev"} {
+		if wait := LoginLocked(user, ip); wait != 0 {
+			t.Errorf("LoginLocked(%v) = %v, the other users of ip should not be locked", user, wait)
+		}
+	}
+	if LoginLocked("attacker-target", "172.18.0.4") != 0 {
+		t.Errorf("the user from the other ip should not be locked")
+	}
+}
+
+func TestResolveClientIP(t *testing.T) {
+	proxies := parseTrustedProxies("127.0.0.1, 172.16.0.0/12, invalid")
+	if len(proxies) != 2 {
+		t.Fatalf("parseTrustedProxies() = %v, want 2 networks", proxies)
+	}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct client", "203.0.113.9:5123", "", "203.0.113.9"},
+		{"untrusted remote spoofs header", "203.0.113.9:5123", "10.0.0.1", "203.0.113.9"},
+		{"trusted proxy", "172.18.0.2:40000", "198.51.100.7", "198.51.100.7"},
+		{"client spoofs leftmost hop", "172.18.0.2:40000", "1.1.1.1, 198.51.100.7", "198.51.100.7"},
+		{"proxy chain", "127.0.0.1:40000", "198.51.100.7, 172.18.0.5", "198.51.100.7"},
+		{"trusted proxy without header", "172.18.0.2:40000", "", "172.18.0.2"},
+		{"invalid hop", "172.18.0.2:40000", "1.1.1.1, unknown", "172.18.0.2"},
+	}
+	for _, tt := range tests {
+		if got := resolveClientIP(tt.remoteAddr, tt.forwardedFor, proxies); got != tt.want {
+			t.Errorf("%v: resolveClientIP() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-atomci/atomci/internal/dao"
+)
+
+const commandUsage = `usage: atomci migrate <status|up|down> [flags]
+
+  status              show the registered and applied migrations
+  up [--dry-run]      apply the pending and failed migrations
+  down [--dry-run] [--steps n]
+                      roll back the latest n migrations, 1 by default
+`
+
+// RunCommand run the migrate command of cli, the database is initialized before
+func RunCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Print(commandUsage)
+		return fmt.Errorf("migrate command is required")
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the statements without execution")
+	steps := flags.Int("steps", 1, "the number of migrations rolled back")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	output := func(format string, args ...interface{}) {
+		fmt.Printf(format+"\n", args...)
+	}
+	mg := newMigrator(registeredMigrations(), *dryRun, output)
+
+	switch args[0] {
+	case "status":
+		statuses, err := mg.Status()
+		if err != nil {
+			return err
+		}
+		printStatuses(statuses)
+		return verifyChecksums(statuses)
+	case "up", "down":
+		if !*dryRun {
+			unlock, err := dao.Lock(migrationLock, 10*time.Minute, time.Minute)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+		if args[0] == "up" {
+			return mg.Up()
+		}
+		if *steps < 1 {
+			return fmt.Errorf("steps must be greater than 0")
+		}
+		return mg.Down(*steps)
+	default:
+		fmt.Print(commandUsage)
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+}
+
+func printStatuses(statuses []*MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tREVERSIBLE\tCHECKSUM\tAPPLIED AT\tMESSAGE")
+	for _, status := range statuses {
+		checksum := "ok"
+		if status.ChecksumMismatch {
+			checksum = "mismatch"
+		}
+		appliedAt := "-"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\t%s\n", status.Version, status.Name, status.Status, status.Reversible, checksum, appliedAt, status.Message)
+	}
+	w.Flush()
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"github.com/astaxie/beego/orm"
+)
+
+// isMySQL the migrations are written for mysql, postgres and sqlite, the differences are built by the helpers below,
+// notice the failed statement aborts the whole migration transaction of postgres, check before change
+func isMySQL(ormer orm.Ormer) bool {
+	return ormer.Driver().Type() == orm.DRMySQL
+}
+
+func isPostgres(ormer orm.Ormer) bool {
+	return ormer.Driver().Type() == orm.DRPostgres
+}
+
+func isSQLite(ormer orm.Ormer) bool {
+	return ormer.Driver().Type() == orm.DRSqlite
+}
+
+// quote quote the column name, such as the reserved word user
+func quote(ormer orm.Ormer, name string) string {
+	if isMySQL(ormer) {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// currentSchema the sql function of the schema which the tables belong to
+func currentSchema(ormer orm.Ormer) string {
+	if isPostgres(ormer) {
+		return "current_schema()"
+	}
+	return "DATABASE()"
+}
+
+func tableExists(ormer orm.Ormer, table string) (bool, error) {
+	var count int
+	sql := `SELECT count(1) FROM information_schema.tables WHERE table_schema=` + currentSchema(ormer) + ` AND table_name=?`
+	if isSQLite(ormer) {
+		sql = `SELECT count(1) FROM sqlite_master WHERE type='table' AND name=?`
+	}
+	err := ormer.Raw(sql, table).QueryRow(&count)
+	return count > 0, err
+}
+
+func columnExists(ormer orm.Ormer, table, column string) (bool, error) {
+	var count int
+	sql := `SELECT count(1) FROM information_schema.columns WHERE table_schema=` + currentSchema(ormer) + ` AND table_name=? AND column_name=?`
+	if isSQLite(ormer) {
+		sql = `SELECT count(1) FROM pragma_table_info(?) WHERE name=?`
+	}
+	err := ormer.Raw(sql, table, column).QueryRow(&count)
+	return count > 0, err
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/orm"
+)
+
+type fakeDriver orm.DriverType
+
+func (d fakeDriver) Name() string         { return "default" }
+func (d fakeDriver) Type() orm.DriverType { return orm.DriverType(d) }
+
+// fakeOrmer record the raw queries and the executed statements, the count queries return 1 if the args are in existing
+type fakeOrmer struct {
+	orm.Ormer
+	driver   orm.DriverType
+	existing map[string]bool
+	queries  []string
+	executed []string
+}
+
+func (o *fakeOrmer) Driver() orm.Driver {
+	return fakeDriver(o.driver)
+}
+
+func (o *fakeOrmer) Raw(query string, args ...interface{}) orm.RawSeter {
+	o.queries = append(o.queries, query)
+	return &fakeRawSeter{ormer: o, query: query, args: args}
+}
+
+type fakeRawSeter struct {
+	orm.RawSeter
+	ormer *fakeOrmer
+	query string
+	args  []interface{}
+}
+
+func (r *fakeRawSeter) Exec() (sql.Result, error) {
+	r.ormer.executed = append(r.ormer.executed, r.query)
+	return nil, nil
+}
+
+func (r *fakeRawSeter) QueryRow(containers ...interface{}) error {
+	keys := make([]string, len(r.args))
+	for i, arg := range r.args {
+		keys[i] = fmt.Sprint(arg)
+	}
+	if count, ok := containers[0].(*int); ok && r.ormer.existing[strings.Join(keys, ".")] {
+		*count = 1
+	}
+	return nil
+}
+
+func TestColumnExists(t *testing.T) {
+	tests := []struct {
+		driver orm.DriverType
+		want   string
+	}{
+		{orm.DRMySQL, "table_schema=DATABASE()"},
+		{orm.DRPostgres, "table_schema=current_schema()"},
+		{orm.DRSqlite, "pragma_table_info(?)"},
+	}
+	for _, tt := range tests {
+		ormer := &fakeOrmer{driver: tt.driver, existing: map[string]bool{"project_env.harbor": true}}
+		exists, err := columnExists(ormer, "project_env", "harbor")
+		if err != nil || !exists {
+			t.Errorf("driver %v: columnExists() = %v, %v", tt.driver, exists, err)
+		}
+		if !strings.Contains(ormer.queries[0], tt.want) {
+			t.Errorf("driver %v: query %v should contain %v", tt.driver, ormer.queries[0], tt.want)
+		}
+	}
+}
+
+func TestMigration20220309(t *testing.T) {
+	update := "UPDATE sys_integrate_setting SET type='registry' WHERE type='harbor'"
+	tests := []struct {
+		name     string
+		driver   orm.DriverType
+		existing map[string]bool
+		want     []string
+	}{
+		{"mysql rename", orm.DRMySQL, map[string]bool{"project_env.harbor": true},
+			[]string{update, "ALTER TABLE project_env CHANGE COLUMN harbor registry bigint(20) NOT NULL DEFAULT 0"}},
+		{"postgres rename", orm.DRPostgres, map[string]bool{"project_env.harbor": true},
+			[]string{update, "ALTER TABLE project_env RENAME COLUMN harbor TO registry"}},
+		{"sqlite rename", orm.DRSqlite, map[string]bool{"project_env.harbor": true},
+			[]string{update, "ALTER TABLE project_env RENAME COLUMN harbor TO registry"}},
+		{"both columns", orm.DRPostgres, map[string]bool{"project_env.harbor": true, "project_env.registry": true},
+			[]string{update, "UPDATE project_env SET registry=harbor", "ALTER TABLE project_env DROP COLUMN harbor"}},
+		{"migrated", orm.DRMySQL, map[string]bool{"project_env.registry": true}, []string{update}},
+	}
+	for _, tt := range tests {
+		ormer := &fakeOrmer{driver: tt.driver, existing: tt.existing}
+		if err := (Migration20220309{}).Upgrade(ormer); err != nil {
+			t.Fatalf("%v: Upgrade() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(ormer.executed, tt.want) {
+			t.Errorf("%v: executed %v, want %v", tt.name, ormer.executed, tt.want)
+		}
+	}
+}
+
+func TestSetTimestampColumns(t *testing.T) {
+	tables := []string{"sys_user"}
+	tests := []struct {
+		driver   orm.DriverType
+		existing map[string]bool
+		want     []string
+	}{
+		{orm.DRMySQL, nil, []string{
+			"alter table sys_user modify column create_at datetime not null DEFAULT CURRENT_TIMESTAMP",
+			"alter table sys_user modify column update_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP",
+		}},
+		// the columns altered already are skipped
+		{orm.DRMySQL, map[string]bool{"sys_user": true}, nil},
+		{orm.DRPostgres, nil, []string{
+			"alter table sys_user alter column create_at set DEFAULT CURRENT_TIMESTAMP",
+			"alter table sys_user alter column update_at set DEFAULT CURRENT_TIMESTAMP",
+		}},
+		{orm.DRSqlite, nil, nil},
+	}
+	for _, tt := range tests {
+		ormer := &fakeOrmer{driver: tt.driver, existing: tt.existing}
+		if err := setCreateAt(ormer, tables); err != nil {
+			t.Fatalf("driver %v: setCreateAt() error = %v", tt.driver, err)
+		}
+		if err := setUpdateAt(ormer, tables); err != nil {
+			t.Fatalf("driver %v: setUpdateAt() error = %v", tt.driver, err)
+		}
+		if !reflect.DeepEqual(ormer.executed, tt.want) {
+			t.Errorf("driver %v: executed %v, want %v", tt.driver, ormer.executed, tt.want)
+		}
+	}
+}
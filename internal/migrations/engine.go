@@ -0,0 +1,408 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/utils"
+)
+
+// history status const defined
+const (
+	historyStatusApplied = "applied"
+	historyStatusFailed  = "failed"
+)
+
+// versionLayout the version of migration is its create time
+const versionLayout = "20060102150405"
+
+// historyItem the migration applied or failed, which is recorded in __dbmigration_history
+type historyItem struct {
+	Version   string    `orm:"column(version)"`
+	Name      string    `orm:"column(name)"`
+	Checksum  string    `orm:"column(checksum)"`
+	Status    string    `orm:"column(status)"`
+	Message   string    `orm:"column(message)"`
+	AppliedAt time.Time `orm:"column(applied_at)"`
+}
+
+// MigrationStatus the status of the registered or recorded migration
+type MigrationStatus struct {
+	Version string
+	Name    string
+	// Status pending, applied, failed, or missing when the recorded migration is not registered any more
+	Status     string
+	Reversible bool
+	// ChecksumMismatch the migration recorded is changed, it must be investigated before migrate
+	ChecksumMismatch bool
+	Message          string
+	AppliedAt        time.Time
+}
+
+func migrationVersion(m Migration) string {
+	return m.GetCreateAt().Format(versionLayout)
+}
+
+func migrationName(m Migration) string {
+	t := reflect.TypeOf(m)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// migrationChecksum the identity of the migration, the migration renamed or replaced at the same version mismatches
+func migrationChecksum(m Migration) string {
+	sum := sha256.Sum256([]byte(migrationVersion(m) + "/" + migrationName(m)))
+	return hex.EncodeToString(sum[:])
+}
+
+func sureCreateHistoryTable(ormer orm.Ormer) error {
+	columnType := "datetime"
+	if isPostgres(ormer) {
+		columnType = "timestamp"
+	}
+	ddl := `CREATE TABLE IF NOT EXISTS __dbmigration_history (
+	  version varchar(32) NOT NULL PRIMARY KEY,
+	  name varchar(128) NOT NULL,
+	  checksum varchar(64) NOT NULL,
+	  status varchar(16) NOT NULL,
+	  message varchar(256),
+	  applied_at ` + columnType + ` NOT NULL
+	)`
+	_, err := ormer.Raw(ddl).Exec()
+	return err
+}
+
+// loadHistory return the recorded migrations, the history is seeded from the last migration date of the legacy table
+func loadHistory(ormer orm.Ormer, migrationTypes MigrationTypes) (map[string]*historyItem, error) {
+	if err := sureCreateHistoryTable(ormer); err != nil {
+		return nil, err
+	}
+	items := []*historyItem{}
+	if _, err := ormer.Raw("SELECT version, name, checksum, status, message, applied_at FROM __dbmigration_history").QueryRows(&items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		last := getNewestData(ormer)
+		for _, m := range migrationTypes {
+			if m.GetCreateAt().After(last) {
+				continue
+			}
+			item := &historyItem{
+				Version:   migrationVersion(m),
+				Name:      migrationName(m),
+				Checksum:  migrationChecksum(m),
+				Status:    historyStatusApplied,
+				Message:   "migrated before history",
+				AppliedAt: last,
+			}
+			if err := saveHistory(ormer, item); err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+	history := map[string]*historyItem{}
+	for _, item := range items {
+		history[item.Version] = item
+	}
+	return history, nil
+}
+
+func saveHistory(ormer orm.Ormer, item *historyItem) error {
+	item.Message = utils.Truncate(item.Message, 256)
+	if _, err := ormer.Raw("DELETE FROM __dbmigration_history WHERE version=?", item.Version).Exec(); err != nil {
+		return err
+	}
+	_, err := ormer.Raw("INSERT INTO __dbmigration_history (version, name, checksum, status, message, applied_at) VALUES (?, ?, ?, ?, ?, ?)",
+		item.Version, item.Name, item.Checksum, item.Status, item.Message, item.AppliedAt).Exec()
+	return err
+}
+
+// syncLegacyDate keep the last migration date of the legacy table, the server of the previous version reads it
+func syncLegacyDate(ormer orm.Ormer, history map[string]*historyItem) error {
+	last := time.Unix(0, 0)
+	for _, item := range history {
+		if item.Status != historyStatusApplied {
+			continue
+		}
+		if version, err := time.ParseInLocation(versionLayout, item.Version, time.Local); err == nil && version.After(last) {
+			last = version
+		}
+	}
+	return updateNewestData(ormer, last)
+}
+
+// migrationStatuses merge the registered migrations and the history, ordered by version
+func migrationStatuses(migrationTypes MigrationTypes, history map[string]*historyItem) []*MigrationStatus {
+	statuses := []*MigrationStatus{}
+	registered := map[string]bool{}
+	for _, m := range migrationTypes {
+		version := migrationVersion(m)
+		registered[version] = true
+		_, reversible := m.(Downgrader)
+		status := &MigrationStatus{
+			Version:    version,
+			Name:       migrationName(m),
+			Status:     "pending",
+			Reversible: reversible,
+		}
+		if item, ok := history[version]; ok {
+			status.Status = item.Status
+			status.Message = item.Message
+			status.AppliedAt = item.AppliedAt
+			status.ChecksumMismatch = item.Checksum != migrationChecksum(m)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, item := range history {
+		if registered[version] {
+			continue
+		}
+		statuses = append(statuses, &MigrationStatus{
+			Version:          version,
+			Name:             item.Name,
+			Status:           "missing",
+			ChecksumMismatch: true,
+			Message:          item.Message,
+			AppliedAt:        item.AppliedAt,
+		})
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses
+}
+
+// verifyChecksums the migrations applied must be registered without change
+func verifyChecksums(statuses []*MigrationStatus) error {
+	broken := []string{}
+	for _, status := range statuses {
+		if status.ChecksumMismatch {
+			broken = append(broken, fmt.Sprintf("%s(%s)", status.Version, status.Name))
+		}
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("checksum verification failed, the migrations applied were changed or removed: %s", strings.Join(broken, ", "))
+	}
+	return nil
+}
+
+// migrator run the migrations up and down, each migration runs in its own transaction and is recorded in history,
+// notice the ddl of mysql commits implicitly, the failed migration is recorded as failed and retried by the next up
+type migrator struct {
+	migrationTypes MigrationTypes
+	dryRun         bool
+	// output the statements previewed by dry run and the progress
+	output func(format string, args ...interface{})
+}
+
+func newMigrator(migrationTypes MigrationTypes, dryRun bool, output func(format string, args ...interface{})) *migrator {
+	sort.Sort(migrationTypes)
+	return &migrator{migrationTypes: migrationTypes, dryRun: dryRun, output: output}
+}
+
+// Status return the status of the migrations
+func (mg *migrator) Status() ([]*MigrationStatus, error) {
+	history, err := loadHistory(orm.NewOrm(), mg.migrationTypes)
+	if err != nil {
+		return nil, err
+	}
+	return migrationStatuses(mg.migrationTypes, history), nil
+}
+
+// Up apply the pending and failed migrations in order, it stops at the first failure
+func (mg *migrator) Up() error {
+	ormer := orm.NewOrm()
+	history, err := loadHistory(ormer, mg.migrationTypes)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksums(migrationStatuses(mg.migrationTypes, history)); err != nil {
+		return err
+	}
+	for _, m := range mg.migrationTypes {
+		version := migrationVersion(m)
+		if item, ok := history[version]; ok && item.Status == historyStatusApplied {
+			continue
+		}
+		if mg.dryRun {
+			mg.preview(m, "up", m.Upgrade)
+			continue
+		}
+		mg.output("migrate up: %s %s", version, migrationName(m))
+		item := &historyItem{
+			Version:   version,
+			Name:      migrationName(m),
+			Checksum:  migrationChecksum(m),
+			Status:    historyStatusApplied,
+			AppliedAt: time.Now(),
+		}
+		if err := mg.inTx(func(o orm.Ormer) error {
+			if err := m.Upgrade(o); err != nil {
+				return err
+			}
+			return saveHistory(o, item)
+		}); err != nil {
+			item.Status = historyStatusFailed
+			item.Message = err.Error()
+			if saveErr := saveHistory(ormer, item); saveErr != nil {
+				log.Log.Error("record failed migration: %v occur error: %s", version, saveErr.Error())
+			}
+			return fmt.Errorf("migrate up %s %s failed: %s", version, item.Name, err.Error())
+		}
+		history[version] = item
+		if err := syncLegacyDate(ormer, history); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down roll back the latest steps migrations applied or failed, the irreversible migration stops it
+func (mg *migrator) Down(steps int) error {
+	ormer := orm.NewOrm()
+	history, err := loadHistory(ormer, mg.migrationTypes)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksums(migrationStatuses(mg.migrationTypes, history)); err != nil {
+		return err
+	}
+	for i := len(mg.migrationTypes) - 1; i >= 0 && steps > 0; i-- {
+		m := mg.migrationTypes[i]
+		version := migrationVersion(m)
+		if _, ok := history[version]; !ok {
+			continue
+		}
+		steps--
+		downgrader, ok := m.(Downgrader)
+		if !ok {
+			return fmt.Errorf("migration %s %s is irreversible", version, migrationName(m))
+		}
+		if mg.dryRun {
+			mg.preview(m, "down", downgrader.Downgrade)
+			continue
+		}
+		mg.output("migrate down: %s %s", version, migrationName(m))
+		if err := mg.inTx(func(o orm.Ormer) error {
+			if err := downgrader.Downgrade(o); err != nil {
+				return err
+			}
+			_, err := o.Raw("DELETE FROM __dbmigration_history WHERE version=?", version).Exec()
+			return err
+		}); err != nil {
+			return fmt.Errorf("migrate down %s %s failed: %s", version, migrationName(m), err.Error())
+		}
+		delete(history, version)
+		if err := syncLegacyDate(ormer, history); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preview print the statements of the migration without execution, the queries are still run to decide the statements
+func (mg *migrator) preview(m Migration, direction string, run func(ormer orm.Ormer) error) {
+	mg.output("-- migrate %s: %s %s", direction, migrationVersion(m), migrationName(m))
+	if _, ok := m.(statementMigration); !ok && direction == "up" {
+		mg.output("-- data migration by code, which could not be previewed")
+		return
+	}
+	ormer := &dryRunOrmer{Ormer: orm.NewOrm()}
+	if err := run(ormer); err != nil {
+		mg.output("-- preview failed: %s", err.Error())
+	}
+	for _, statement := range ormer.statements {
+		mg.output("%s;", statement)
+	}
+}
+
+func (mg *migrator) inTx(run func(ormer orm.Ormer) error) error {
+	ormer := orm.NewOrm()
+	// sqlite allows only one writer, the migrations writing by the other ormers would wait for the transaction forever
+	if isSQLite(ormer) {
+		return run(ormer)
+	}
+	if err := ormer.Begin(); err != nil {
+		return err
+	}
+	if err := run(ormer); err != nil {
+		if rollbackErr := ormer.Rollback(); rollbackErr != nil {
+			log.Log.Error("rollback migration occur error: %s", rollbackErr.Error())
+		}
+		return err
+	}
+	return ormer.Commit()
+}
+
+// dryRunOrmer record the statements executed by raw instead of executing them
+type dryRunOrmer struct {
+	orm.Ormer
+	statements []string
+}
+
+// Raw ..
+func (o *dryRunOrmer) Raw(query string, args ...interface{}) orm.RawSeter {
+	return &dryRunRawSeter{RawSeter: o.Ormer.Raw(query, args...), ormer: o, query: query, args: args}
+}
+
+type dryRunRawSeter struct {
+	orm.RawSeter
+	ormer *dryRunOrmer
+	query string
+	args  []interface{}
+}
+
+// Exec ..
+func (r *dryRunRawSeter) Exec() (sql.Result, error) {
+	r.ormer.statements = append(r.ormer.statements, formatStatement(r.query, r.args))
+	return dryRunResult{}, nil
+}
+
+type dryRunResult struct{}
+
+// LastInsertId ..
+func (dryRunResult) LastInsertId() (int64, error) { return 0, nil }
+
+// RowsAffected ..
+func (dryRunResult) RowsAffected() (int64, error) { return 0, nil }
+
+// formatStatement fill the placeholders with the args for preview only
+func formatStatement(query string, args []interface{}) string {
+	statement := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	for _, arg := range args {
+		value := fmt.Sprintf("%v", arg)
+		switch v := arg.(type) {
+		case string:
+			value = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		case time.Time:
+			value = "'" + v.Format("2006-01-02 15:04:05") + "'"
+		}
+		statement = strings.Replace(statement, "?", value, 1)
+	}
+	return statement
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrationStatuses(t *testing.T) {
+	migrationTypes := MigrationTypes{new(Migration20220101), new(Migration20220501), new(Migration20220601)}
+	history := map[string]*historyItem{
+		"20220101000000": {Version: "20220101000000", Name: "Migration20220101", Checksum: migrationChecksum(new(Migration20220101)), Status: historyStatusApplied},
+		"20220501000000": {Version: "20220501000000", Name: "Migration20220501", Checksum: "changed", Status: historyStatusFailed},
+		"20220201000000": {Version: "20220201000000", Name: "Migration20220201", Checksum: "removed", Status: historyStatusApplied},
+	}
+	statuses := migrationStatuses(migrationTypes, history)
+	expected := []struct {
+		version  string
+		status   string
+		mismatch bool
+	}{
+		{"20220101000000", historyStatusApplied, false},
+		{"20220201000000", "missing", true},
+		{"20220501000000", historyStatusFailed, true},
+		{"20220601000000", "pending", false},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("expect %v statuses, got: %v", len(expected), len(statuses))
+	}
+	for i, item := range expected {
+		if statuses[i].Version != item.version || statuses[i].Status != item.status || statuses[i].ChecksumMismatch != item.mismatch {
+			t.Errorf("expect status %+v, got: %+v", item, statuses[i])
+		}
+	}
+	if !statuses[2].Reversible || statuses[0].Reversible {
+		t.Errorf("expect only the migrations with downgrade reversible")
+	}
+	if err := verifyChecksums(statuses); err == nil {
+		t.Errorf("expect checksum verification failed")
+	}
+	if err := verifyChecksums(statuses[:1]); err != nil {
+		t.Errorf("expect checksum verification passed, got: %v", err)
+	}
+}
+
+func TestFormatStatement(t *testing.T) {
+	statement := formatStatement("UPDATE t SET name=?, at=? WHERE id=?;", []interface{}{"it's", time.Date(2022, 1, 2, 3, 4, 5, 0, time.Local), 1})
+	expected := "UPDATE t SET name='it''s', at='2022-01-02 03:04:05' WHERE id=1"
+	if statement != expected {
+		t.Errorf("expect statement: %v, got: %v", expected, statement)
+	}
+}
@@ -31,6 +31,8 @@ func (m Migration20220101) GetCreateAt() time.Time {
 	return time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local)
 }
 
+func (m Migration20220101) statementsOnly() {}
+
 func (m Migration20220101) Upgrade(ormer orm.Ormer) error {
 	tables := []string{
 		"sys_resource_type",
@@ -59,6 +61,13 @@ func (m Migration20220101) Upgrade(ormer orm.Ormer) error {
 }
 
 func setCreateAt(ormer orm.Ormer, tables []string) error {
+	// sqlite could not alter the column, the create_at is set by orm auto_now_add
+	if isSQLite(ormer) {
+		return nil
+	}
+	if isPostgres(ormer) {
+		return setTimestampDefault(ormer, tables, "create_at")
+	}
 	for _, table := range tables {
 		var count int
 		sql := `SELECT count(1) FROM INFORMATION_SCHEMA.Columns WHERE table_schema=DATABASE() AND table_name=? 
@@ -80,6 +89,13 @@ func setCreateAt(ormer orm.Ormer, tables []string) error {
 }
 
 func setUpdateAt(ormer orm.Ormer, tables []string) error {
+	// postgres and sqlite have no on update CURRENT_TIMESTAMP, the update_at is set by orm auto_now
+	if isSQLite(ormer) {
+		return nil
+	}
+	if isPostgres(ormer) {
+		return setTimestampDefault(ormer, tables, "update_at")
+	}
 	for _, table := range tables {
 		var count int
 		sql := `SELECT count(1) FROM INFORMATION_SCHEMA.Columns WHERE table_schema=DATABASE() AND table_name=? 
@@ -99,3 +115,15 @@ func setUpdateAt(ormer orm.Ormer, tables []string) error {
 	}
 	return nil
 }
+
+// setTimestampDefault set the default of the column for postgres, which is idempotent
+func setTimestampDefault(ormer orm.Ormer, tables []string, column string) error {
+	for _, table := range tables {
+		sql := `alter table ` + table + ` alter column ` + column + ` set DEFAULT CURRENT_TIMESTAMP`
+		if _, err := ormer.Raw(sql).Exec(); err != nil {
+			return err
+		}
+		log.Log.Info(sql)
+	}
+	return nil
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package migrations
 
 import (
-	"strings"
 	"time"
 
 	"github.com/astaxie/beego/orm"
@@ -30,34 +29,33 @@ func (m Migration20220309) GetCreateAt() time.Time {
 	return time.Date(2022, 3, 9, 0, 0, 0, 0, time.Local)
 }
 
+func (m Migration20220309) statementsOnly() {}
+
 func (m Migration20220309) Upgrade(ormer orm.Ormer) error {
-	_, err := ormer.Raw("UPDATE `sys_integrate_setting` SET `type`='registry' WHERE `type`='harbor';").Exec()
+	_, err := ormer.Raw("UPDATE sys_integrate_setting SET type='registry' WHERE type='harbor'").Exec()
 	if err != nil {
 		return err
 	}
 
-	_, err = ormer.Raw("DROP PROCEDURE IF EXISTS `ModifyHarborToRegistry`;").Exec()
-	_, err = ormer.Raw(strings.ReplaceAll(`CREATE PROCEDURE <|SPIT|>ModifyHarborToRegistry<|SPIT|>()
-BEGIN
-    DECLARE HARBOREXISTS int DEFAULT 0;
-    DECLARE REGISTRYEXISTS int DEFAULT 0;
-    SELECT count(1) INTO @HARBOREXISTS FROM information_schema.COLUMNS WHERE TABLE_NAME='project_env' AND COLUMN_NAME='harbor';
-    SELECT count(1) INTO @REGISTRYEXISTS FROM information_schema.COLUMNS WHERE TABLE_NAME='project_env' AND COLUMN_NAME='registry';
-    IF @HARBOREXISTS>0 AND @REGISTRYEXISTS=0
-    THEN
-        ALTER TABLE <|SPIT|>project_env<|SPIT|> CHANGE COLUMN <|SPIT|>harbor<|SPIT|> <|SPIT|>registry<|SPIT|> bigint(20) NOT NULL DEFAULT 0;
-    ELSEIF  @HARBOREXISTS>0 AND @REGISTRYEXISTS>0
-    THEN
-        UPDATE <|SPIT|>project_env<|SPIT|> SET <|SPIT|>registry<|SPIT|>=<|SPIT|>harbor<|SPIT|>;
-        ALTER TABLE <|SPIT|>project_env<|SPIT|> DROP COLUMN <|SPIT|>harbor<|SPIT|>;
-    END IF;
-END;`, "<|SPIT|>", "`")).Exec()
-
-	_, err = ormer.Raw("CALL `ModifyHarborToRegistry`;").Exec()
-	_, err = ormer.Raw("DROP PROCEDURE IF EXISTS `ModifyHarborToRegistry`;").Exec()
-
+	harborExists, err := columnExists(ormer, "project_env", "harbor")
+	if err != nil || !harborExists {
+		return err
+	}
+	registryExists, err := columnExists(ormer, "project_env", "registry")
 	if err != nil {
 		return err
 	}
-	return nil
+	if !registryExists {
+		sql := "ALTER TABLE project_env RENAME COLUMN harbor TO registry"
+		if isMySQL(ormer) {
+			sql = "ALTER TABLE project_env CHANGE COLUMN harbor registry bigint(20) NOT NULL DEFAULT 0"
+		}
+		_, err = ormer.Raw(sql).Exec()
+		return err
+	}
+	if _, err := ormer.Raw("UPDATE project_env SET registry=harbor").Exec(); err != nil {
+		return err
+	}
+	_, err = ormer.Raw("ALTER TABLE project_env DROP COLUMN harbor").Exec()
+	return err
 }
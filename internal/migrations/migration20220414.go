@@ -71,12 +71,14 @@ func (m Migration20220414) Upgrade(ormer orm.Ormer) error {
 	// 04. pub_project_app delete unused column
 
 	// 01.
+	// the fresh installed server has no pub_repo_server
+	exists, err := tableExists(ormer, "pub_repo_server")
+	if err != nil || !exists {
+		return err
+	}
 	var repoServerItems []repoServer
-	_, err := ormer.Raw("SELECT id,type,base_url,user,token,password,cid FROM pub_repo_server WHERE deleted=0;").QueryRows(&repoServerItems)
+	_, err = ormer.Raw("SELECT id,type,base_url,"+quote(ormer, "user")+",token,password,cid FROM pub_repo_server WHERE deleted=?", false).QueryRows(&repoServerItems)
 	if err != nil {
-		if strings.Contains(err.Error(), " doesn't exist") {
-			return nil
-		}
 		return fmt.Errorf("select pub_repo_server: %s", err.Error())
 	}
 	log.Log.Debug("reposerver len: %v", len(repoServerItems))
@@ -89,7 +91,7 @@ func (m Migration20220414) Upgrade(ormer orm.Ormer) error {
 	// 02.
 	// get all project apps
 	var appsRes []projectApp
-	_, err = ormer.Raw("SELECT id,creator,project_id,name,full_name,language,branch_name,path,repo_id,compile_env_id,build_path,dockerfile FROM pub_project_app WHERE deleted=0;").QueryRows(&appsRes)
+	_, err = ormer.Raw("SELECT id,creator,project_id,name,full_name,language,branch_name,path,repo_id,compile_env_id,build_path,dockerfile FROM pub_project_app WHERE deleted=?", false).QueryRows(&appsRes)
 	if err != nil {
 		return fmt.Errorf("select pub_project_app : %s", err.Error())
 	}
@@ -124,7 +126,7 @@ func (m Migration20220414) Upgrade(ormer orm.Ormer) error {
 		log.Log.Debug("project app id: %v, migrated succeed", projectApp.ID)
 	}
 	// 03
-	if _, err := ormer.Raw("delete from pub_app_branch;").Exec(); err != nil {
+	if _, err := ormer.Raw("delete from pub_app_branch").Exec(); err != nil {
 		return err
 	}
 
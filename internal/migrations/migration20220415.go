@@ -69,6 +69,11 @@ func initComponent() error {
 			Type: "deploy",
 		},
 	}
+	return createComponents(components)
+}
+
+// createComponents create the flow components which do not exist
+func createComponents(components []component) error {
 	for _, comp := range components {
 		pipelineModel := dao.NewPipelineStageModel()
 		_, err := pipelineModel.GetFlowComponentByType(comp.Type)
@@ -119,6 +124,11 @@ var compileEnvs = []settings.CompileEnvReq{
 }
 
 func initCompileEnvs() error {
+	return createCompileEnvs(compileEnvs)
+}
+
+// createCompileEnvs create the compile envs which do not exist
+func createCompileEnvs(compileEnvs []settings.CompileEnvReq) error {
 	settingModel := dao.NewSysSettingModel()
 	for _, item := range compileEnvs {
 		_, err := settingModel.GetCompileEnvByName(item.Name)
@@ -132,6 +142,8 @@ func initCompileEnvs() error {
 					Creator:     "admin", // create use 'admin'
 					Args:        item.Args,
 					Description: item.Description,
+					OSType:      item.OSType,
+					Shell:       item.Shell,
 				}
 				if err := settingModel.CreateCompileEnv(component); err != nil {
 					log.Log.Warn("when init compile env, occur error: %s", err.Error())
@@ -183,6 +195,11 @@ func initTaskTemplates() error {
 		},
 	}
 
+	return createTaskTemplates(taskTmpls)
+}
+
+// createTaskTemplates create the task templates which do not exist
+func createTaskTemplates(taskTmpls []pipelinemgr.TaskTmplReq) error {
 	pipeline := pipelinemgr.NewPipelineManager()
 
 	for _, item := range taskTmpls {
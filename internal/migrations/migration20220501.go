@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+type Migration20220501 struct {
+}
+
+func (m Migration20220501) GetCreateAt() time.Time {
+	return time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local)
+}
+
+func (m Migration20220501) Upgrade(ormer orm.Ormer) error {
+	// init image promote component and task template
+	_ = createComponents([]component{
+		{
+			Name: "镜像晋级",
+			Type: models.StepPromote,
+		},
+	})
+	_ = createTaskTemplates([]pipelinemgr.TaskTmplReq{
+		{
+			Name:        "镜像晋级",
+			Type:        models.StepPromote,
+			Description: "将已构建的镜像复制到当前环境的镜像仓库, 无需重新构建",
+		},
+	})
+	return nil
+}
+
+// Downgrade remove the component and task template initialized
+func (m Migration20220501) Downgrade(ormer orm.Ormer) error {
+	if _, err := ormer.Raw("DELETE FROM pub_flow_step WHERE type=?", models.StepPromote).Exec(); err != nil {
+		return err
+	}
+	_, err := ormer.Raw("DELETE FROM pub_flow_component WHERE type=?", models.StepPromote).Exec()
+	return err
+}
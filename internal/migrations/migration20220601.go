@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/pipelinemgr"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+type Migration20220601 struct {
+}
+
+func (m Migration20220601) GetCreateAt() time.Time {
+	return time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)
+}
+
+func (m Migration20220601) Upgrade(ormer orm.Ormer) error {
+	// init e2e test component and task template
+	_ = createComponents([]component{
+		{
+			Name: "端到端测试",
+			Type: models.StepE2ETest,
+		},
+	})
+	_ = createTaskTemplates([]pipelinemgr.TaskTmplReq{
+		{
+			Name:        "端到端测试",
+			Type:        models.StepE2ETest,
+			Description: "在部署后的环境运行 Selenium/Playwright/newman 测试套件, 通过率达到阈值后才允许流转",
+		},
+	})
+	return nil
+}
+
+// Downgrade remove the component and task template initialized
+func (m Migration20220601) Downgrade(ormer orm.Ormer) error {
+	if _, err := ormer.Raw("DELETE FROM pub_flow_step WHERE type=?", models.StepE2ETest).Exec(); err != nil {
+		return err
+	}
+	_, err := ormer.Raw("DELETE FROM pub_flow_component WHERE type=?", models.StepE2ETest).Exec()
+	return err
+}
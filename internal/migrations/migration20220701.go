@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/project"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+)
+
+type Migration20220701 struct {
+}
+
+func (m Migration20220701) GetCreateAt() time.Time {
+	return time.Date(2022, 7, 1, 0, 0, 0, 0, time.Local)
+}
+
+func (m Migration20220701) Upgrade(ormer orm.Ormer) error {
+	// init golang compile env and the built-in service templates
+	_ = createCompileEnvs([]settings.CompileEnvReq{
+		{
+			Name:        "golang",
+			Image:       "golang:1.17",
+			Command:     "/bin/sh -c",
+			Args:        "cat",
+			Description: "golang编译环境",
+		},
+	})
+	_ = initServiceTemplates()
+	return nil
+}
+
+// initServiceTemplates create the built-in service templates which do not exist
+func initServiceTemplates() error {
+	pm := project.NewProjectManager()
+	templateModel := dao.NewServiceTemplateModel()
+	for _, item := range project.DefaultServiceTemplates() {
+		if _, err := templateModel.GetServiceTemplateByName(item.Name); err == nil {
+			log.Log.Debug("service template `%s` already exists, skip", item.Name)
+			continue
+		}
+		if _, err := pm.CreateServiceTemplate(item, "admin"); err != nil {
+			log.Log.Warn("when init service template, occur error: %s", err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+	"github.com/go-atomci/atomci/internal/middleware/log"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+type Migration20220715 struct {
+}
+
+func (m Migration20220715) GetCreateAt() time.Time {
+	return time.Date(2022, 7, 15, 0, 0, 0, 0, time.Local)
+}
+
+// the version commands of the built-in compile envs
+var compileEnvVersionCommands = map[string]string{
+	"golang": "go version",
+	"maven":  "mvn --version",
+	"node":   "node --version",
+	"kaniko": "/kaniko/executor version",
+}
+
+func (m Migration20220715) Upgrade(ormer orm.Ormer) error {
+	// init the first version of the compile envs created before versioning
+	settingModel := dao.NewSysSettingModel()
+	compileEnvs, err := settingModel.GetCompileEnvs("", nil)
+	if err != nil {
+		log.Log.Warn("when init compile env versions, occur error: %s", err.Error())
+		return nil
+	}
+	for _, item := range compileEnvs {
+		if item.Version != "" {
+			continue
+		}
+		item.Version = settings.NextCompileEnvVersion("")
+		if item.VersionCommand == "" {
+			item.VersionCommand = compileEnvVersionCommands[item.Name]
+		}
+		if err := settingModel.UpdateCompileEnv(item); err != nil {
+			log.Log.Warn("when init compile env: %v version, occur error: %s", item.Name, err.Error())
+			continue
+		}
+		err := settingModel.CreateCompileEnvVersion(&models.CompileEnvVersion{
+			Addons:         models.NewAddons(),
+			CompileEnvID:   item.ID,
+			Version:        item.Version,
+			Image:          item.Image,
+			Command:        item.Command,
+			Args:           item.Args,
+			VersionCommand: item.VersionCommand,
+			Creator:        item.Creator,
+		})
+		if err != nil {
+			log.Log.Warn("when init compile env: %v version, occur error: %s", item.Name, err.Error())
+		}
+	}
+	return nil
+}
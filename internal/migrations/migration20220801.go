@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/settings"
+)
+
+type Migration20220801 struct {
+}
+
+func (m Migration20220801) GetCreateAt() time.Time {
+	return time.Date(2022, 8, 1, 0, 0, 0, 0, time.Local)
+}
+
+func (m Migration20220801) Upgrade(ormer orm.Ormer) error {
+	// the token settings created before tls verification skipped it, keep them working
+	pm := settings.NewSettingManager()
+	k8sSettings, err := pm.GetIntegrateSettings([]string{"kubernetes"})
+	if err != nil {
+		return err
+	}
+	for _, setting := range k8sSettings {
+		req := &setting.IntegrateSettingReq
+		cfg, ok := req.Config.(*settings.KubeConfig)
+		if !ok || cfg.Type != settings.KubernetesToken || cfg.CACert != "" || cfg.Insecure {
+			continue
+		}
+		cfg.Insecure = true
+		if err := pm.UpdateIntegrateSetting(req, setting.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+)
+
+type Migration20220901 struct {
+}
+
+func (m Migration20220901) GetCreateAt() time.Time {
+	return time.Date(2022, 9, 1, 0, 0, 0, 0, time.Local)
+}
+
+// the default compile commands of build tools, the apps of the language use them if no compile command selected
+var defaultCompileCommands = []settings.CompileCommandReq{
+	{Name: "maven", Language: "java", Command: "mvn -B -DskipTests clean package", Description: "Maven 构建"},
+	{Name: "gradle", Command: "gradle build -x test", Description: "Gradle 构建"},
+	{Name: "go", Language: "go", Command: "go build ./...", Description: "Go 构建"},
+	{Name: "npm", Language: "node", Command: "npm ci && npm run build", Description: "npm 构建"},
+	{Name: "pip", Language: "python", Command: "pip install -r requirements.txt", Description: "pip 安装依赖"},
+}
+
+func (m Migration20220901) Upgrade(ormer orm.Ormer) error {
+	model := dao.NewSysSettingModel()
+	pm := settings.NewSettingManager()
+	for _, item := range defaultCompileCommands {
+		if _, err := model.GetCompileCommandTemplateByName(item.Name); err == nil {
+			continue
+		}
+		req := item
+		if _, err := pm.CreateCompileCommandTemplate(&req, "admin"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
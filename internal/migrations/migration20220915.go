@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/dao"
+)
+
+type Migration20220915 struct {
+}
+
+func (m Migration20220915) GetCreateAt() time.Time {
+	return time.Date(2022, 9, 15, 0, 0, 0, 0, time.Local)
+}
+
+// the default dockerfile templates of languages, they are written into the apps which lack dockerfile
+var defaultDockerfileTemplates = []settings.DockerfileTemplateReq{
+	{
+		Name:        "go",
+		Language:    "go",
+		Description: "Go 多阶段构建",
+		Content: `FROM golang:1.17-alpine AS builder
+WORKDIR /src
+COPY . .
+RUN CGO_ENABLED=0 go build -o /out/{{.AppName}} .
+
+FROM alpine:3.15
+COPY --from=builder /out/{{.AppName}} /usr/local/bin/app
+EXPOSE 8080
+ENTRYPOINT ["/usr/local/bin/app"]
+`,
+	},
+	{
+		Name:        "java",
+		Language:    "java",
+		Description: "运行编译生成的 jar 包",
+		Content: `FROM openjdk:8-jre-alpine
+WORKDIR /app
+COPY target/*.jar /app/app.jar
+EXPOSE 8080
+ENTRYPOINT ["java", "-jar", "/app/app.jar"]
+`,
+	},
+	{
+		Name:        "node",
+		Language:    "node",
+		Description: "Node.js 应用",
+		Content: `FROM node:16-alpine
+WORKDIR /app
+COPY . .
+RUN npm ci --only=production
+EXPOSE 8080
+CMD ["npm", "start"]
+`,
+	},
+	{
+		Name:        "python",
+		Language:    "python",
+		Description: "Python 应用",
+		Content: `FROM python:3.9-slim
+WORKDIR /app
+COPY requirements.txt .
+RUN pip install --no-cache-dir -r requirements.txt
+COPY . .
+EXPOSE 8080
+CMD ["python", "app.py"]
+`,
+	},
+}
+
+func (m Migration20220915) Upgrade(ormer orm.Ormer) error {
+	model := dao.NewSysSettingModel()
+	pm := settings.NewSettingManager()
+	for _, item := range defaultDockerfileTemplates {
+		if _, err := model.GetDockerfileTemplateByName(item.Name); err == nil {
+			continue
+		}
+		req := item
+		if _, err := pm.CreateDockerfileTemplate(&req, "admin"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
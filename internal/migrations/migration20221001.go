@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/constant"
+	"github.com/go-atomci/atomci/internal/core/settings"
+	"github.com/go-atomci/atomci/internal/models"
+)
+
+type Migration20221001 struct {
+}
+
+func (m Migration20221001) GetCreateAt() time.Time {
+	return time.Date(2022, 10, 1, 0, 0, 0, 0, time.Local)
+}
+
+// the compile envs of the windows build pod, the windows nodes must run the same os version as the images
+var windowsCompileEnvs = []settings.CompileEnvReq{
+	{
+		Name:        constant.WindowsDefaultContainerName,
+		Image:       "jenkins/inbound-agent:windowsservercore-ltsc2019",
+		Description: "Windows 构建节点的 jnlp 代理",
+		OSType:      models.CompileEnvOSWindows,
+		Shell:       models.CompileEnvShellPowershell,
+	},
+	{
+		Name:        constant.WindowsBuildImageContainerName,
+		Image:       "docker:windowsservercore-ltsc2019",
+		Command:     "powershell",
+		Args:        "Start-Sleep -Seconds 2147483",
+		Description: "Windows 镜像构建环境，使用节点的 docker 引擎",
+		OSType:      models.CompileEnvOSWindows,
+		Shell:       models.CompileEnvShellPowershell,
+	},
+	{
+		Name:        "dotnet-framework",
+		Image:       "mcr.microsoft.com/dotnet/framework/sdk:4.8-windowsservercore-ltsc2019",
+		Command:     "powershell",
+		Args:        "Start-Sleep -Seconds 2147483",
+		Description: ".NET Framework 编译环境",
+		OSType:      models.CompileEnvOSWindows,
+		Shell:       models.CompileEnvShellPowershell,
+	},
+}
+
+func (m Migration20221001) Upgrade(ormer orm.Ormer) error {
+	return createCompileEnvs(windowsCompileEnvs)
+}
@@ -17,14 +17,18 @@ limitations under the License.
 package migrations
 
 import (
+	"fmt"
 	"os"
-	"sort"
 	"time"
 
 	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/dao"
 	"github.com/go-atomci/atomci/internal/middleware/log"
 )
 
+// migrationLock the replicas started together migrate one by one, the later one finds nothing to migrate
+const migrationLock = "migration"
+
 type MigrationTypes []Migration
 
 // Migration db migration base interface
@@ -33,6 +37,18 @@ type Migration interface {
 	Upgrade(ormer orm.Ormer) error
 }
 
+// Downgrader the migration could be rolled back by migrate down, Downgrade changes the db by the ormer given only,
+// so its statements are previewed by dry run
+type Downgrader interface {
+	Downgrade(ormer orm.Ormer) error
+}
+
+// statementMigration the migration upgrades by the statements of the ormer given only, without the managers,
+// so its statements are previewed by dry run
+type statementMigration interface {
+	statementsOnly()
+}
+
 // Len 排序三人组
 func (t MigrationTypes) Len() int {
 	return len(t)
@@ -48,43 +64,22 @@ func (t MigrationTypes) Swap(i, j int) {
 	t[i], t[j] = t[j], t[i]
 }
 
-// initMigration db migration register
-func initMigration() {
-	migrationTypes := MigrationTypes{
+// registeredMigrations db migration register
+func registeredMigrations() MigrationTypes {
+	return MigrationTypes{
 		new(Migration20220101),
 		new(Migration20220309),
 		new(Migration20220324),
 		new(Migration20220414),
 		new(Migration20220415),
-	}
-
-	migrateInTx(migrationTypes)
-}
-
-func migrateInTx(migrationTypes MigrationTypes) {
-	//升序
-	sort.Sort(migrationTypes)
-
-	//数据迁移(事务）
-	ormClient := orm.NewOrm()
-	last := getNewestData(ormClient)
-	tempLast := last
-	errRet := ormClient.Begin()
-	for _, m := range migrationTypes {
-		if m.GetCreateAt().After(last) {
-			errRet = m.Upgrade(ormClient)
-			if errRet != nil {
-				log.Log.Error("migrate: %v, upgrade error: %v", m.GetCreateAt(), errRet.Error())
-				break
-			}
-		}
-		tempLast = m.GetCreateAt()
-	}
-	errRet = updateNewestData(ormClient, tempLast)
-	if errRet != nil {
-		ormClient.Rollback()
-	} else {
-		ormClient.Commit()
+		new(Migration20220501),
+		new(Migration20220601),
+		new(Migration20220701),
+		new(Migration20220715),
+		new(Migration20220801),
+		new(Migration20220901),
+		new(Migration20220915),
+		new(Migration20221001),
 	}
 }
 
@@ -113,8 +108,12 @@ func updateNewestData(ormer orm.Ormer, lastTime time.Time) error {
 }
 
 func sureCreateTable(ormer orm.Ormer) {
+	columnType := "datetime"
+	if isPostgres(ormer) {
+		columnType = "timestamp"
+	}
 	ddl := `CREATE TABLE IF NOT EXISTS __dbmigration (
-	  last_migration_date datetime DEFAULT CURRENT_TIMESTAMP
+	  last_migration_date ` + columnType + ` DEFAULT CURRENT_TIMESTAMP
 	)`
 	ormer.Raw(ddl).Exec()
 }
@@ -123,5 +122,12 @@ func Migrate() {
 	if len(os.Args) > 1 && os.Args[1][:5] == "-test" {
 		return
 	}
-	initMigration()
+	unlock, err := dao.Lock(migrationLock, 10*time.Minute, 10*time.Minute)
+	if err != nil {
+		panic(fmt.Sprintf("acquire migration lock occur error: %s", err.Error()))
+	}
+	defer unlock()
+	if err := newMigrator(registeredMigrations(), false, log.Log.Info).Up(); err != nil {
+		panic(err.Error())
+	}
 }
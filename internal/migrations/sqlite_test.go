@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/go-atomci/atomci/internal/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestMigrateSQLite boot the orm against a sqlite database file and run all the migrations twice
+func TestMigrateSQLite(t *testing.T) {
+	models.InitSQLite(filepath.Join(t.TempDir(), "atomci.db"))
+	logf := func(format string, args ...interface{}) { t.Logf(format, args...) }
+
+	if err := newMigrator(registeredMigrations(), false, logf).Up(); err != nil {
+		t.Fatalf("migrate up error: %v", err)
+	}
+	statuses, err := newMigrator(registeredMigrations(), false, logf).Status()
+	if err != nil {
+		t.Fatalf("migration status error: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Status != historyStatusApplied {
+			t.Errorf("migration %v %v is %v: %v", status.Version, status.Name, status.Status, status.Message)
+		}
+	}
+	// the migrations applied are skipped
+	if err := newMigrator(registeredMigrations(), false, logf).Up(); err != nil {
+		t.Fatalf("migrate up again error: %v", err)
+	}
+
+	var count int
+	if err := orm.NewOrm().Raw("SELECT count(1) FROM sys_compile_env").QueryRow(&count); err != nil || count == 0 {
+		t.Errorf("the compile envs should be seeded, count: %v, error: %v", count, err)
+	}
+}
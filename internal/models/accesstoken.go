@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/utils/validate"
+)
+
+// ServiceAccountAuth the login type of project service account, which is only able to use access tokens
+const ServiceAccountAuth = LDAPAuth + 1
+
+// AccessTokenPrefix the prefix of access token, used to tell access tokens from the login tokens
+const AccessTokenPrefix = "atci_"
+
+// the scopes of access token
+const (
+	// AccessTokenScopeRead read only, only GET/HEAD requests and the list apis of POST are allowed
+	AccessTokenScopeRead = "read"
+	// AccessTokenScopePipeline read and trigger the pipelines and publishes
+	AccessTokenScopePipeline = "pipeline"
+	// AccessTokenScopeAPI all the apis that the owner of token is allowed
+	AccessTokenScopeAPI = "api"
+)
+
+// AccessToken the personal access token of user or the token of project service account
+type AccessToken struct {
+	Addons
+	Name        string     `orm:"column(name);size(64)" json:"name"`
+	User        string     `orm:"column(user);size(64)" json:"user"`
+	ProjectID   int64      `orm:"column(project_id);default(0)" json:"project_id"`
+	TokenHash   string     `orm:"column(token_hash);size(64);unique" json:"-"`
+	TokenPrefix string     `orm:"column(token_prefix);size(16)" json:"token_prefix"`
+	Scopes      string     `orm:"column(scopes);size(128)" json:"scopes"`
+	ExpireAt    *time.Time `orm:"column(expire_at);null;type(datetime)" json:"expire_at"`
+	LastUsedAt  *time.Time `orm:"column(last_used_at);null;type(datetime)" json:"last_used_at"`
+	Revoked     bool       `orm:"column(revoked);default(false)" json:"revoked"`
+	Creator     string     `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *AccessToken) TableName() string {
+	return "sys_access_token"
+}
+
+// ScopeList return the scopes of token
+func (t *AccessToken) ScopeList() []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(t.Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Expired the token is expired at the moment
+func (t *AccessToken) Expired(moment time.Time) bool {
+	return t.ExpireAt != nil && !moment.Before(*t.ExpireAt)
+}
+
+// AccessTokenReq ..
+type AccessTokenReq struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	ExpireDays int      `json:"expire_days"`
+}
+
+// Verify ..
+func (v *AccessTokenReq) Verify() error {
+	v.Name = validate.FormatString(v.Name)
+	if err := validate.ValidateDescription(v.Name); err != nil {
+		return err
+	}
+	if len(v.Scopes) == 0 {
+		return fmt.Errorf("请至少选择一个令牌权限范围")
+	}
+	for _, scope := range v.Scopes {
+		switch scope {
+		case AccessTokenScopeRead, AccessTokenScopePipeline, AccessTokenScopeAPI:
+		default:
+			return fmt.Errorf("不支持的令牌权限范围: %v，可选值为 %v/%v/%v", scope, AccessTokenScopeRead, AccessTokenScopePipeline, AccessTokenScopeAPI)
+		}
+	}
+	if v.ExpireDays < 0 {
+		return fmt.Errorf("令牌有效天数不能小于 0")
+	}
+	return nil
+}
+
+// AccessTokenRsp the token is only returned when it is created
+type AccessTokenRsp struct {
+	*AccessToken
+	Token string `json:"token,omitempty"`
+}
+
+// ServiceAccountReq ..
+type ServiceAccountReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	RoleID      int64  `json:"role_id"`
+}
+
+// Verify ..
+func (v *ServiceAccountReq) Verify() error {
+	v.Name = validate.FormatString(v.Name)
+	v.Description = validate.FormatString(v.Description)
+	if err := validate.ValidateName(v.Name); err != nil {
+		return err
+	}
+	if len(v.Name) > 32 {
+		return fmt.Errorf("服务账号名称不能超过 32 个字符")
+	}
+	if v.Description != "" {
+		if err := validate.ValidateDescription(v.Description); err != nil {
+			return err
+		}
+	}
+	if v.RoleID == 0 {
+		return fmt.Errorf("请选择服务账号的项目角色")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "time"
+
+// PublishJobArchive the publish job moved out of the primary table by the retention policy of project, the id is kept
+type PublishJobArchive struct {
+	PublishJob
+	ArchivedAt *time.Time `orm:"column(archived_at);type(datetime);index;null" json:"archived_at"`
+}
+
+// TableName ...
+func (t *PublishJobArchive) TableName() string {
+	return "pub_publish_job_archive"
+}
+
+// TableIndex ...
+func (t *PublishJobArchive) TableIndex() [][]string {
+	return [][]string{
+		[]string{"ProjectID", "CreateAt"},
+		[]string{"PublishID"},
+	}
+}
+
+// PublishOperationLogArchive the operation log moved out of the primary table by the retention policy of project, the id is kept
+type PublishOperationLogArchive struct {
+	PublishOperationLog
+	ProjectID  int64      `orm:"column(project_id);default(0)" json:"project_id"`
+	ArchivedAt *time.Time `orm:"column(archived_at);type(datetime);index;null" json:"archived_at"`
+}
+
+// TableName ...
+func (t *PublishOperationLogArchive) TableName() string {
+	return "pub_publish_operation_archive"
+}
+
+// TableIndex ...
+func (t *PublishOperationLogArchive) TableIndex() [][]string {
+	return [][]string{
+		[]string{"ProjectID", "CreateAt"},
+		[]string{"PublishID"},
+	}
+}
@@ -24,6 +24,8 @@ type AppArrange struct {
 	EnvID        int64  `orm:"column(env_id);" json:"env_id"`
 	ProjectAppID int64  `orm:"column(project_app_id)" json:"project_app_id"`
 	Config       string `orm:"column(config);type(text)" json:"config"`
+	Variables    string `orm:"column(variables);type(text);null" json:"variables"`
+	Revision     int64  `orm:"column(revision);default(0)" json:"revision"`
 }
 
 // TableName ...
@@ -64,3 +66,28 @@ type AppImageMapping struct {
 func (t *AppImageMapping) TableName() string {
 	return "app_image_mapping"
 }
+
+// AppArrangeRevision the immutable snapshot of app arrange, created on every save
+type AppArrangeRevision struct {
+	Addons
+	ArrangeID     int64  `orm:"column(arrange_id)" json:"arrange_id"`
+	ProjectAppID  int64  `orm:"column(project_app_id)" json:"project_app_id"`
+	EnvID         int64  `orm:"column(env_id)" json:"env_id"`
+	Revision      int64  `orm:"column(revision)" json:"revision"`
+	Config        string `orm:"column(config);type(text)" json:"config"`
+	Variables     string `orm:"column(variables);type(text);null" json:"variables"`
+	ImageMappings string `orm:"column(image_mappings);type(text);null" json:"image_mappings"`
+	Creator       string `orm:"column(creator);size(64);null" json:"creator"`
+}
+
+// TableName ...
+func (t *AppArrangeRevision) TableName() string {
+	return "pub_app_arrange_revision"
+}
+
+// TableUnique ...
+func (t *AppArrangeRevision) TableUnique() [][]string {
+	return [][]string{
+		[]string{"ArrangeID", "Revision"},
+	}
+}
@@ -16,6 +16,10 @@ limitations under the License.
 
 package models
 
+import (
+	"github.com/go-atomci/atomci/utils/query"
+)
+
 type Audit struct {
 	Addons
 	User            string `orm:"column(user)" json:"user"`
@@ -24,8 +28,34 @@ type Audit struct {
 	OperationObject string `orm:"column(operation_object)" json:"operation_object"`
 	OperationBody   string `orm:"column(operation_body);type(text)" json:"operation_body"`
 	OperationStatus int    `orm:"column(operation_status)" json:"operation_status"`
+	IP              string `orm:"column(ip);size(64);null" json:"ip"`
+	ResourceType    string `orm:"column(resource_type);size(64);null" json:"resource_type"`
+	ResourceID      string `orm:"column(resource_id);size(128);null" json:"resource_id"`
+	Action          string `orm:"column(action);size(32);null" json:"action"`
+	Before          string `orm:"column(before_snapshot);type(text);null" json:"before"`
+	After           string `orm:"column(after_snapshot);type(text);null" json:"after"`
+}
+
+// AuditFilterQuery ..
+type AuditFilterQuery struct {
+	query.FilterQuery
+	User          string `json:"user"`
+	ResourceType  string `json:"resource_type"`
+	ResourceID    string `json:"resource_id"`
+	Action        string `json:"action"`
+	IP            string `json:"ip"`
+	CreateAtStart string `json:"createAtStart"`
+	CreateAtEnd   string `json:"createAtEnd"`
 }
 
+// audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionExecute = "execute"
+)
+
 func (t *Audit) TableName() string {
 	return "sys_audit"
 }
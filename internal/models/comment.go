@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// PublishComment the markdown comment of publish order, the reply refers to the comment it replies by ParentID
+type PublishComment struct {
+	Addons
+	ProjectID int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID int64  `orm:"column(publish_id)" json:"publish_id"`
+	ParentID  int64  `orm:"column(parent_id);default(0)" json:"parent_id"`
+	Author    string `orm:"column(author);size(64)" json:"author"`
+	Content   string `orm:"column(content);type(text)" json:"content"`
+	// Mentions comma separated users mentioned by the comment, which were notified
+	Mentions string `orm:"column(mentions);size(1024);null" json:"mentions"`
+}
+
+// TableName ...
+func (t *PublishComment) TableName() string {
+	return "pub_publish_comment"
+}
+
+// TableIndex ...
+func (t *PublishComment) TableIndex() [][]string {
+	return [][]string{
+		[]string{"PublishID"},
+	}
+}
+
+// PublishCommentAttachment the file attached to the comment, the content is base64 encoded
+type PublishCommentAttachment struct {
+	Addons
+	CommentID   int64  `orm:"column(comment_id)" json:"comment_id"`
+	PublishID   int64  `orm:"column(publish_id)" json:"publish_id"`
+	Name        string `orm:"column(name);size(255)" json:"name"`
+	ContentType string `orm:"column(content_type);size(128)" json:"content_type"`
+	Size        int64  `orm:"column(size)" json:"size"`
+	Content     string `orm:"column(content);type(text)" json:"-"`
+}
+
+// TableName ...
+func (t *PublishCommentAttachment) TableName() string {
+	return "pub_publish_comment_attachment"
+}
+
+// TableIndex ...
+func (t *PublishCommentAttachment) TableIndex() [][]string {
+	return [][]string{
+		[]string{"CommentID"},
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// CompileCommandTemplate the compile command template of build tool, eg: maven/gradle/go/npm/pip,
+// the apps use the template of their language unless the project app overrides it
+type CompileCommandTemplate struct {
+	Addons
+	Name string `orm:"column(name);size(64);unique" json:"name"`
+	// Language the apps of the language use the template by default, empty means chosen explicitly only
+	Language    string `orm:"column(language);size(64);null" json:"language"`
+	Command     string `orm:"column(command);type(text)" json:"command"`
+	Description string `orm:"column(description);size(256);null" json:"description"`
+	// Revision the current revision, the commands of all revisions are kept in CompileCommandRevision
+	Revision int64  `orm:"column(revision);default(0)" json:"revision"`
+	Creator  string `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *CompileCommandTemplate) TableName() string {
+	return "sys_compile_command"
+}
+
+// CompileCommandRevision the immutable snapshot of compile command template, created on every save
+type CompileCommandRevision struct {
+	Addons
+	TemplateID int64  `orm:"column(template_id)" json:"template_id"`
+	Revision   int64  `orm:"column(revision)" json:"revision"`
+	Command    string `orm:"column(command);type(text)" json:"command"`
+	Creator    string `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *CompileCommandRevision) TableName() string {
+	return "sys_compile_command_revision"
+}
+
+// TableIndex ...
+func (t *CompileCommandRevision) TableIndex() [][]string {
+	return [][]string{
+		[]string{"TemplateID", "Revision"},
+	}
+}
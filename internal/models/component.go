@@ -16,6 +16,11 @@ limitations under the License.
 
 package models
 
+import (
+	"strings"
+	"time"
+)
+
 // FlowComponent ...
 type FlowComponent struct {
 	Addons
@@ -56,9 +61,127 @@ type CompileEnv struct {
 	Args        string `orm:"column(args);size(128)" json:"args"`
 	Creator     string `orm:"column(creator);size(64)" json:"creator"`
 	Description string `orm:"column(description);size(256)" json:"description"`
+	// OrgID the organization which the compile env belongs to, 0 means shared by all organizations
+	OrgID int64 `orm:"column(org_id);default(0)" json:"org_id"`
+	// Version the current version, the image/command/args/resources of a version are kept in CompileEnvVersion
+	Version string `orm:"column(version);size(64);null" json:"version"`
+	// VersionCommand the command executed by validation, eg: go version, mvn --version
+	VersionCommand string `orm:"column(version_command);size(256);null" json:"version_command"`
+	CPURequest     string `orm:"column(cpu_request);size(32);null" json:"cpu_request"`
+	CPULimit       string `orm:"column(cpu_limit);size(32);null" json:"cpu_limit"`
+	MemoryRequest  string `orm:"column(memory_request);size(32);null" json:"memory_request"`
+	MemoryLimit    string `orm:"column(memory_limit);size(32);null" json:"memory_limit"`
+	// NodeSelector/Tolerations json of the build pod scheduling, ImagePullSecrets comma separated secret names
+	NodeSelector     string `orm:"column(node_selector);type(text);null" json:"node_selector"`
+	Tolerations      string `orm:"column(tolerations);type(text);null" json:"tolerations"`
+	ImagePullSecrets string `orm:"column(image_pull_secrets);size(512);null" json:"image_pull_secrets"`
+	// BuilderImage the cloud native buildpacks builder used by the apps build without dockerfile
+	BuilderImage string `orm:"column(builder_image);size(256);null" json:"builder_image"`
+	// OSType the os of the build node, linux or windows, empty means linux
+	OSType string `orm:"column(os_type);size(16);null" json:"os_type"`
+	// Shell the shell runs the build commands, sh for linux, bat or powershell for windows
+	Shell string `orm:"column(shell);size(16);null" json:"shell"`
+	// Arch comma separated cpu architectures supported by the image, empty means amd64
+	Arch string `orm:"column(arch);size(64);null" json:"arch"`
+	// Deprecated the deprecated compile env is not allowed to be used by the new apps
+	Deprecated         bool   `orm:"column(deprecated);default(false)" json:"deprecated"`
+	DeprecationMessage string `orm:"column(deprecation_message);size(256);null" json:"deprecation_message"`
+	// ReplacedBy the compile env which the apps should migrate to, 0 means none
+	ReplacedBy int64 `orm:"column(replaced_by);default(0)" json:"replaced_by"`
+	// the result of the latest validation
+	ValidationStatus string     `orm:"column(validation_status);size(32);null" json:"validation_status"`
+	ValidationOutput string     `orm:"column(validation_output);size(2048);null" json:"validation_output"`
+	ValidatedAt      *time.Time `orm:"column(validated_at);null;type(datetime)" json:"validated_at"`
 }
 
 // TableName ...
 func (t *CompileEnv) TableName() string {
 	return "sys_compile_env"
 }
+
+// the os types and shells of compile env
+const (
+	CompileEnvOSLinux         = "linux"
+	CompileEnvOSWindows       = "windows"
+	CompileEnvShellSh         = "sh"
+	CompileEnvShellBat        = "bat"
+	CompileEnvShellPowershell = "powershell"
+)
+
+// CompileEnvOS return the os type of compile env, linux by default
+func (t *CompileEnv) CompileEnvOS() string {
+	if t.OSType == "" {
+		return CompileEnvOSLinux
+	}
+	return t.OSType
+}
+
+// CompileEnvShell return the shell of compile env, sh for linux and powershell for windows by default
+func (t *CompileEnv) CompileEnvShell() string {
+	if t.Shell != "" {
+		return t.Shell
+	}
+	if t.CompileEnvOS() == CompileEnvOSWindows {
+		return CompileEnvShellPowershell
+	}
+	return CompileEnvShellSh
+}
+
+// the cpu architectures of compile env and app image
+const (
+	ArchAmd64 = "amd64"
+	ArchArm64 = "arm64"
+)
+
+// ParseArchs split the comma separated cpu architectures
+func ParseArchs(value string) []string {
+	archs := []string{}
+	for _, arch := range strings.Split(value, ",") {
+		if arch = strings.TrimSpace(arch); arch != "" {
+			archs = append(archs, arch)
+		}
+	}
+	return archs
+}
+
+// CompileEnvArchs return the cpu architectures supported by the image of compile env, amd64 by default
+func (t *CompileEnv) CompileEnvArchs() []string {
+	if archs := ParseArchs(t.Arch); len(archs) > 0 {
+		return archs
+	}
+	return []string{ArchAmd64}
+}
+
+// the validation status of compile env
+const (
+	CompileEnvValidationSuccess = "success"
+	CompileEnvValidationFailed  = "failed"
+)
+
+// CompileEnvVersion the snapshot of compile env version
+type CompileEnvVersion struct {
+	Addons
+	CompileEnvID   int64  `orm:"column(compile_env_id)" json:"compile_env_id"`
+	Version        string `orm:"column(version);size(64)" json:"version"`
+	Image          string `orm:"column(image);size(256)" json:"image"`
+	Command        string `orm:"column(command);size(128)" json:"command"`
+	Args           string `orm:"column(args);size(128)" json:"args"`
+	VersionCommand string `orm:"column(version_command);size(256);null" json:"version_command"`
+	CPURequest     string `orm:"column(cpu_request);size(32);null" json:"cpu_request"`
+	CPULimit       string `orm:"column(cpu_limit);size(32);null" json:"cpu_limit"`
+	MemoryRequest  string `orm:"column(memory_request);size(32);null" json:"memory_request"`
+	MemoryLimit    string `orm:"column(memory_limit);size(32);null" json:"memory_limit"`
+	Creator        string `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *CompileEnvVersion) TableName() string {
+	return "sys_compile_env_version"
+}
+
+// TableUnique ..
+func (t *CompileEnvVersion) TableUnique() [][]string {
+	return [][]string{
+		{"CompileEnvID", "Version"},
+	}
+}
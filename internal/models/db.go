@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/astaxie/beego/orm"
+)
+
+// ReplicaAlias the orm alias of the read replica, registered only when DB::replicaUrl configured
+const ReplicaAlias = "replica"
+
+// default connection pool settings
+const (
+	defaultMaxIdleConns = 100
+	defaultMaxOpenConns = 200
+)
+
+// withStatementTimeout append the statement timeout in milliseconds to the database url,
+// mysql only limits the select statements by max_execution_time, sqlite is not supported
+func withStatementTimeout(driver, dsn string, timeout int) string {
+	if timeout <= 0 {
+		return dsn
+	}
+	switch driver {
+	case DriverMySQL:
+		return appendQueryParam(dsn, "max_execution_time", timeout)
+	case DriverPostgres:
+		// both the url and the key/value connection strings are accepted by lib/pq
+		if strings.Contains(dsn, "://") {
+			return appendQueryParam(dsn, "statement_timeout", timeout)
+		}
+		return fmt.Sprintf("%s statement_timeout=%d", strings.TrimSpace(dsn), timeout)
+	}
+	return dsn
+}
+
+func appendQueryParam(dsn, key string, value int) string {
+	parts := strings.SplitN(dsn, "?", 2)
+	if len(parts) == 2 {
+		if params, err := url.ParseQuery(parts[1]); err == nil && params.Get(key) != "" {
+			// the one in the url takes precedence
+			return dsn
+		}
+		return fmt.Sprintf("%s&%s=%d", dsn, key, value)
+	}
+	return fmt.Sprintf("%s?%s=%d", dsn, key, value)
+}
+
+// setConnPool set the connection pool of the database alias, zero means the default
+func setConnPool(aliasName string, maxIdle, maxOpen int, maxLifetime time.Duration) {
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	if maxOpen == 0 {
+		maxOpen = defaultMaxOpenConns
+	}
+	orm.SetMaxIdleConns(aliasName, maxIdle)
+	orm.SetMaxOpenConns(aliasName, maxOpen)
+	if maxLifetime > 0 {
+		if db, err := orm.GetDB(aliasName); err == nil {
+			db.SetConnMaxLifetime(maxLifetime)
+		}
+	}
+}
+
+// enableSlowQueryLog log the queries cost more than threshold milliseconds as warning,
+// the orm debug output is discarded unless DB::debug enabled
+func enableSlowQueryLog(threshold float64, debug bool) {
+	if threshold <= 0 {
+		return
+	}
+	orm.Debug = true
+	if !debug {
+		orm.DebugLog = orm.NewLog(ioutil.Discard)
+	}
+	orm.LogFunc = func(query map[string]interface{}) {
+		if isSlowQuery(query, threshold) {
+			logs.Warn("slow query: %.1fms [%v] %v", query["cost_time"], query["flag"], query["sql"])
+		}
+	}
+}
+
+func isSlowQuery(query map[string]interface{}, threshold float64) bool {
+	cost, ok := query["cost_time"].(float64)
+	return ok && cost >= threshold
+}
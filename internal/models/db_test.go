@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "testing"
+
+func TestWithStatementTimeout(t *testing.T) {
+	cases := []struct {
+		driver, dsn string
+		timeout     int
+		want        string
+	}{
+		{DriverMySQL, "root:root@tcp(127.0.0.1:3306)/atomci", 0, "root:root@tcp(127.0.0.1:3306)/atomci"},
+		{DriverMySQL, "root:root@tcp(127.0.0.1:3306)/atomci", 3000, "root:root@tcp(127.0.0.1:3306)/atomci?max_execution_time=3000"},
+		{DriverMySQL, "root:root@tcp(127.0.0.1:3306)/atomci?charset=utf8mb4", 3000, "root:root@tcp(127.0.0.1:3306)/atomci?charset=utf8mb4&max_execution_time=3000"},
+		{DriverMySQL, "root:root@tcp(127.0.0.1:3306)/atomci?max_execution_time=100", 3000, "root:root@tcp(127.0.0.1:3306)/atomci?max_execution_time=100"},
+		{DriverPostgres, "postgres://root@127.0.0.1/atomci?sslmode=disable", 3000, "postgres://root@127.0.0.1/atomci?sslmode=disable&statement_timeout=3000"},
+		{DriverPostgres, "host=127.0.0.1 dbname=atomci", 3000, "host=127.0.0.1 dbname=atomci statement_timeout=3000"},
+		{DriverSQLite, defaultSQLiteURL, 3000, defaultSQLiteURL},
+	}
+	for _, c := range cases {
+		if got := withStatementTimeout(c.driver, c.dsn, c.timeout); got != c.want {
+			t.Errorf("withStatementTimeout(%s, %s, %d) = %s, want %s", c.driver, c.dsn, c.timeout, got, c.want)
+		}
+	}
+}
+
+func TestIsSlowQuery(t *testing.T) {
+	if !isSlowQuery(map[string]interface{}{"cost_time": 1500.0}, 1000) {
+		t.Error("expected slow query")
+	}
+	if isSlowQuery(map[string]interface{}{"cost_time": 10.5}, 1000) {
+		t.Error("unexpected slow query")
+	}
+	if isSlowQuery(map[string]interface{}{}, 1000) {
+		t.Error("unexpected slow query without cost")
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// the status of database migration, the migration in migrating status failed or is running
+const (
+	DBMigrationMigrating      = "migrating"
+	DBMigrationApplied        = "applied"
+	DBMigrationRollingBack    = "rolling-back"
+	DBMigrationRolledBack     = "rolled-back"
+	DBMigrationRollbackFailed = "rollback-failed"
+)
+
+// DBMigration the database migration of app executed by db-migration sub task,
+// the version before migration is the target of the down migration on rollback
+type DBMigration struct {
+	Addons
+	ProjectID       int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID       int64  `orm:"column(publish_id)" json:"publish_id"`
+	StageID         int64  `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID    int64  `orm:"column(publish_job_id)" json:"publish_job_id"`
+	ProjectAppID    int64  `orm:"column(project_app_id)" json:"project_app_id"`
+	AppName         string `orm:"column(app_name);size(128)" json:"app_name"`
+	Branch          string `orm:"column(branch);size(128)" json:"branch"`
+	Tool            string `orm:"column(tool);size(32)" json:"tool"`
+	Config          string `orm:"column(config);type(text);null" json:"-"`
+	PreviousVersion string `orm:"column(previous_version);size(128);null" json:"previous_version"`
+	Version         string `orm:"column(version);size(128);null" json:"version"`
+	Status          string `orm:"column(status);size(16)" json:"status"`
+	Message         string `orm:"column(message);size(256);null" json:"message"`
+}
+
+// TableName ...
+func (t *DBMigration) TableName() string {
+	return "pub_db_migration"
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// DockerfileTemplate the dockerfile template of language, it is written into the workspace of the app which
+// lacks dockerfile before build image
+type DockerfileTemplate struct {
+	Addons
+	Name string `orm:"column(name);size(64);unique" json:"name"`
+	// Language the apps of the language use the template by default, empty means chosen explicitly only
+	Language    string `orm:"column(language);size(64);null" json:"language"`
+	Content     string `orm:"column(content);type(text)" json:"content"`
+	Description string `orm:"column(description);size(256);null" json:"description"`
+	Creator     string `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *DockerfileTemplate) TableName() string {
+	return "sys_dockerfile_template"
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// E2ETestReport the junit result of the e2e test suite, the html reports are uploaded to object storage
+type E2ETestReport struct {
+	Addons
+	ProjectID    int64   `orm:"column(project_id)" json:"project_id"`
+	PublishID    int64   `orm:"column(publish_id)" json:"publish_id"`
+	StageID      int64   `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID int64   `orm:"column(publish_job_id)" json:"publish_job_id"`
+	Suite        string  `orm:"column(suite);size(64)" json:"suite"`
+	Framework    string  `orm:"column(framework);size(32)" json:"framework"`
+	Total        int     `orm:"column(total)" json:"total"`
+	Passed       int     `orm:"column(passed)" json:"passed"`
+	Failed       int     `orm:"column(failed)" json:"failed"`
+	Skipped      int     `orm:"column(skipped)" json:"skipped"`
+	PassRate     float64 `orm:"column(pass_rate);digits(5);decimals(2)" json:"pass_rate"`
+	Threshold    float64 `orm:"column(threshold);digits(5);decimals(2)" json:"threshold"`
+	GatePassed   bool    `orm:"column(gate_passed)" json:"gate_passed"`
+	ReportURL    string  `orm:"column(report_url);size(512);null" json:"report_url"`
+}
+
+// TableName ...
+func (t *E2ETestReport) TableName() string {
+	return "pub_e2e_test_report"
+}
@@ -18,9 +18,52 @@ package models
 
 import (
 	"encoding/base64"
+	"time"
+
 	"github.com/go-atomci/atomci/utils"
 )
 
+// IntegrateSettingHealth the latest health check result of integrate setting
+type IntegrateSettingHealth struct {
+	Addons
+	SettingID int64  `orm:"column(setting_id);unique" json:"setting_id"`
+	Status    string `orm:"column(status);size(32)" json:"status"`
+	// Latency the duration of the latest check in milliseconds
+	Latency int64  `orm:"column(latency);default(0)" json:"latency"`
+	Message string `orm:"column(message);size(1024);null" json:"message"`
+	// Failures the consecutive failed checks
+	Failures      int        `orm:"column(failures);default(0)" json:"failures"`
+	CheckedAt     *time.Time `orm:"column(checked_at);null;type(datetime)" json:"checked_at"`
+	LastHealthyAt *time.Time `orm:"column(last_healthy_at);null;type(datetime)" json:"last_healthy_at"`
+}
+
+// TableName ...
+func (t *IntegrateSettingHealth) TableName() string {
+	return "sys_integrate_setting_health"
+}
+
+// the health status of integrate setting
+const (
+	IntegrateHealthy   = "healthy"
+	IntegrateDegraded  = "degraded"
+	IntegrateUnhealthy = "unhealthy"
+)
+
+// ClusterCapability the kubernetes version and api resources of cluster detected when bound
+type ClusterCapability struct {
+	Addons
+	SettingID int64  `orm:"column(setting_id);unique" json:"setting_id"`
+	Version   string `orm:"column(version);size(64)" json:"version"`
+	// Resources the json array of available resources, eg: apps/v1/Deployment
+	Resources  string     `orm:"column(resources);type(text)" json:"-"`
+	DetectedAt *time.Time `orm:"column(detected_at);null;type(datetime)" json:"detected_at"`
+}
+
+// TableName ...
+func (t *ClusterCapability) TableName() string {
+	return "sys_cluster_capability"
+}
+
 // IntegrateSetting the Basic Data of stages based on commpany
 type IntegrateSetting struct {
 	Addons
@@ -29,6 +72,10 @@ type IntegrateSetting struct {
 	Config      string `orm:"column(config);type(text)" json:"config"`
 	Description string `orm:"column(description);size(256)" json:"description"`
 	Creator     string `orm:"column(creator);size(64)" json:"creator"`
+	// OrgID the organization which the setting belongs to, 0 means shared by all organizations
+	OrgID int64 `orm:"column(org_id);default(0)" json:"org_id"`
+	// CredentialVersion the active credential version, 0 means the credentials are not versioned yet
+	CredentialVersion int `orm:"column(credential_version);default(0)" json:"credential_version"`
 }
 
 // TableName ...
@@ -53,3 +100,50 @@ func (t *IntegrateSetting) decrypt() string {
 	cfg, _ := base64.StdEncoding.DecodeString(t.Config)
 	return string(utils.AesEny(cfg))
 }
+
+// IntegrateSettingCredential the versioned config of integrate setting, the config of active version is
+// the same as the config of setting
+type IntegrateSettingCredential struct {
+	Addons
+	SettingID int64 `orm:"column(setting_id)" json:"setting_id"`
+	Version   int   `orm:"column(version)" json:"version"`
+	// Config the encrypted config, same as IntegrateSetting.Config
+	Config      string     `orm:"column(config);type(text)" json:"-"`
+	Status      string     `orm:"column(status);size(32)" json:"status"`
+	Message     string     `orm:"column(message);size(1024);null" json:"message"`
+	Active      bool       `orm:"column(active);default(false)" json:"active"`
+	Creator     string     `orm:"column(creator);size(64)" json:"creator"`
+	ActivatedAt *time.Time `orm:"column(activated_at);null;type(datetime)" json:"activated_at"`
+}
+
+// CryptoConfig ..
+func (t *IntegrateSettingCredential) CryptoConfig(raw string) {
+	setting := &IntegrateSetting{}
+	setting.CryptoConfig(raw)
+	t.Config = setting.Config
+}
+
+// DecryptConfig ..
+func (t *IntegrateSettingCredential) DecryptConfig() string {
+	setting := &IntegrateSetting{Config: t.Config}
+	return setting.DecryptConfig()
+}
+
+// TableName ...
+func (t *IntegrateSettingCredential) TableName() string {
+	return "sys_integrate_setting_credential"
+}
+
+// TableUnique ..
+func (t *IntegrateSettingCredential) TableUnique() [][]string {
+	return [][]string{
+		{"SettingID", "Version"},
+	}
+}
+
+// the validation status of integrate setting credential
+const (
+	CredentialPending   = "pending"
+	CredentialValidated = "validated"
+	CredentialFailed    = "failed"
+)
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "time"
+
+// the failure causes of publish job classified from the failed stage and the job log
+const (
+	FailureCauseCheckout           = "checkout-error"
+	FailureCauseCompile            = "compile-error"
+	FailureCauseImagePush          = "image-push-error"
+	FailureCauseTest               = "test-failure"
+	FailureCauseHealthCheckTimeout = "health-check-timeout"
+	FailureCauseDeploy             = "deploy-error"
+	FailureCauseUnknown            = "unknown"
+)
+
+// PublishJobStage the timing of the sub task run by publish job, which is the stage of jenkins pipeline,
+// the failure cause is classified on the failed stage only
+type PublishJobStage struct {
+	Addons
+	ProjectID        int64     `orm:"column(project_id)" json:"project_id"`
+	PublishID        int64     `orm:"column(publish_id)" json:"publish_id"`
+	PublishJobID     int64     `orm:"column(publish_job_id);index" json:"publish_job_id"`
+	StageID          int64     `orm:"column(stage_id)" json:"stage_id"`
+	JobType          string    `orm:"column(job_type);size(64)" json:"job_type"`
+	Name             string    `orm:"column(name);size(128)" json:"name"`
+	Status           string    `orm:"column(status);size(16)" json:"status"`
+	StartAt          time.Time `orm:"column(start_at);type(datetime)" json:"start_at"`
+	EndAt            time.Time `orm:"column(end_at);type(datetime)" json:"end_at"`
+	DurationInMillis int64     `orm:"column(duration_in_millis)" json:"duration_in_millis"`
+	FailureCause     string    `orm:"column(failure_cause);size(32);null" json:"failure_cause"`
+	FailureMessage   string    `orm:"column(failure_message);size(512);null" json:"failure_message"`
+}
+
+// TableName ...
+func (t *PublishJobStage) TableName() string {
+	return "pub_publish_job_stage"
+}
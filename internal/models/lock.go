@@ -0,0 +1,30 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// DistributedLock the lease lock shared by the server replicas, the lock is free after expired
+type DistributedLock struct {
+	Name   string `orm:"pk;column(name);size(128)" json:"name"`
+	Holder string `orm:"column(holder);size(128)" json:"holder"`
+	// ExpireAt the unix nano time the lease expires
+	ExpireAt int64 `orm:"column(expire_at)" json:"expire_at"`
+}
+
+// TableName ...
+func (t *DistributedLock) TableName() string {
+	return "sys_distributed_lock"
+}
@@ -19,6 +19,8 @@ package models
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/astaxie/beego"
@@ -38,6 +40,47 @@ type Addons struct {
 // TableNamePrefix ..
 const TableNamePrefix = "atom"
 
+// database driver const defined, the postgres and sqlite drivers are linked by the build tags postgres and sqlite
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite3"
+)
+
+// sqliteSQLDriver the name registered by the pure go sqlite driver modernc.org/sqlite, which needs no cgo
+const sqliteSQLDriver = "sqlite"
+
+// defaultSQLiteURL the database file of the single node evaluation
+const defaultSQLiteURL = "data/atomci.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// sqlDriverName the name of database/sql driver of the configured driver
+func sqlDriverName(driver string) string {
+	if driver == DriverSQLite {
+		return sqliteSQLDriver
+	}
+	return driver
+}
+
+// DBDriver the database driver configured, mysql by default, sqlite when no database url configured
+func DBDriver() string {
+	if driver := beego.AppConfig.String("DB::driver"); driver != "" {
+		return driver
+	}
+	if beego.AppConfig.String("DB::url") == "" {
+		return DriverSQLite
+	}
+	return DriverMySQL
+}
+
+// DBURL the database url configured, the sqlite database file is under data by default
+func DBURL() string {
+	url := beego.AppConfig.String("DB::url")
+	if url == "" && DBDriver() == DriverSQLite {
+		return defaultSQLiteURL
+	}
+	return url
+}
+
 // NewAddons basic fields
 func NewAddons() Addons {
 	return Addons{
@@ -65,15 +108,35 @@ var (
 )
 
 func initOrm() {
-	DatabaseURL := beego.AppConfig.String("DB::url")
+	DatabaseURL := DBURL()
 	DatabaseDebug, _ := beego.AppConfig.Bool("DB::debug")
 
 	DefaultRowsLimit, _ := beego.AppConfig.Int("DB::rowsLimit")
 	MaxIdleConns, _ := beego.AppConfig.Int("DB::maxIdelConns")
 	MaxOpenConns, _ := beego.AppConfig.Int("DB::maxOpenConns")
+	ConnMaxLifetime, _ := beego.AppConfig.Int("DB::connMaxLifetime")
+	StatementTimeout, _ := beego.AppConfig.Int("DB::statementTimeout")
+	SlowQueryThreshold, _ := beego.AppConfig.Float("DB::slowQueryThreshold")
+	ReplicaURL := beego.AppConfig.String("DB::replicaUrl")
 
-	if cfg, err := mysql.ParseDSN(DatabaseURL); err == nil {
-		dbName = cfg.DBName
+	driver := DBDriver()
+	driverType := orm.DRMySQL
+	switch driver {
+	case DriverMySQL:
+		if cfg, err := mysql.ParseDSN(DatabaseURL); err == nil {
+			dbName = cfg.DBName
+		}
+	case DriverPostgres:
+		driverType = orm.DRPostgres
+	case DriverSQLite:
+		driverType = orm.DRSqlite
+		// the database file is created by the driver, but not its directory
+		path := strings.TrimPrefix(strings.SplitN(DatabaseURL, "?", 2)[0], "file:")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			panic(fmt.Sprintf(`failed to create database directory, error: "%s"`, err.Error()))
+		}
+	default:
+		panic(fmt.Sprintf(`unsupported database driver: "%s"`, driver))
 	}
 
 	orm.Debug = DatabaseDebug
@@ -81,22 +144,21 @@ func initOrm() {
 		orm.DefaultRowsLimit = DefaultRowsLimit
 	}
 
-	if err := orm.RegisterDriver("mysql", orm.DRMySQL); err != nil {
+	if err := orm.RegisterDriver(sqlDriverName(driver), driverType); err != nil {
 		panic(fmt.Sprintf(`failed to register driver, error: "%s"`, err.Error()))
 	}
-	if err := orm.RegisterDataBase("default", "mysql", DatabaseURL); err != nil {
+	if err := orm.RegisterDataBase("default", sqlDriverName(driver), withStatementTimeout(driver, DatabaseURL, StatementTimeout)); err != nil {
 		panic(fmt.Sprintf(`failed to register database, error: "%s", url: "%s"`, err.Error(), DatabaseURL))
 	}
-	if MaxIdleConns != 0 {
-		orm.SetMaxIdleConns("default", MaxIdleConns)
-	} else {
-		orm.SetMaxIdleConns("default", 100)
-	}
-	if MaxOpenConns != 0 {
-		orm.SetMaxOpenConns("default", MaxOpenConns)
-	} else {
-		orm.SetMaxOpenConns("default", 200)
+	setConnPool("default", MaxIdleConns, MaxOpenConns, time.Duration(ConnMaxLifetime)*time.Second)
+	// the heavy list queries are routed to the read replica if configured
+	if ReplicaURL != "" {
+		if err := orm.RegisterDataBase(ReplicaAlias, sqlDriverName(driver), withStatementTimeout(driver, ReplicaURL, StatementTimeout)); err != nil {
+			panic(fmt.Sprintf(`failed to register replica database, error: "%s", url: "%s"`, err.Error(), ReplicaURL))
+		}
+		setConnPool(ReplicaAlias, MaxIdleConns, MaxOpenConns, time.Duration(ConnMaxLifetime)*time.Second)
 	}
+	enableSlowQueryLog(SlowQueryThreshold, DatabaseDebug)
 	registerModel := func(models ...interface{}) {
 		tableNames = make([]string, len(models))
 		for i, model := range models {
@@ -112,16 +174,21 @@ func initOrm() {
 		new(ResourceOperation),
 		new(ResourceConstraint),
 		new(User),
+		new(AccessToken),
 		new(Group),
 		new(GroupUserRel),
 		new(GroupRoleUser),
 		new(GroupUserConstraint),
 		new(GroupRole),
 		new(GroupRoleOperation),
+		new(GroupRoleEnvPermission),
 		new(Audit),
 		new(GatewayRouter),
+		new(Organization),
+		new(OrganizationUser),
 
 		new(ScmApp),
+		new(ServiceTemplate),
 		new(Project),
 		new(ProjectUser),
 		new(ProjectApp),
@@ -129,20 +196,51 @@ func initOrm() {
 		new(TaskTmpl),
 
 		new(IntegrateSetting),
+		new(IntegrateSettingHealth),
+		new(IntegrateSettingCredential),
+		new(ClusterCapability),
+		new(DistributedLock),
 		new(ProjectEnv),
+		new(DeployFreeze),
+		new(EnvLock),
+		new(PreviewEnv),
+		new(ProjectAgentTemplate),
 		new(ProjectPipeline),
 		new(PipelineInstance),
 		new(CompileEnv),
+		new(CompileEnvVersion),
+		new(CompileCommandTemplate),
+		new(CompileCommandRevision),
+		new(DockerfileTemplate),
+		new(ImageSBOM),
+		new(SBOMPackage),
+		new(PublishComment),
+		new(PublishCommentAttachment),
 
 		new(AppBranch),
 		new(AppImageMapping),
 		new(CaasApplication),
 		new(AppArrange),
+		new(AppArrangeRevision),
 		new(Publish),
 		new(PublishOperationLog),
 		new(PublishApp),
 		new(PublishJob),
 		new(PublishJobApp),
+		new(PublishJobQueue),
+		new(PublishJobCallback),
+		new(PublishStepTask),
+		new(PublishJobArchive),
+		new(PublishOperationLogArchive),
+		new(TerraformPlan),
+		new(DBMigration),
+		new(E2ETestReport),
+		new(PerfTestResult),
+		new(QualityReport),
+		new(PublishJobStage),
+		new(ReleasePlan),
+		new(Runner),
+		new(RunnerTask),
 	)
 
 	orm.RunSyncdb("default", false, true)
@@ -157,3 +255,10 @@ func InitDB() {
 	initOrm()
 	// orm.RunSyncdb("default", false, true)
 }
+
+// InitSQLite init the orm with the sqlite database file, the tests against a real database use it
+func InitSQLite(path string) {
+	beego.AppConfig.Set("DB::driver", DriverSQLite)
+	beego.AppConfig.Set("DB::url", path)
+	initOrm()
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// the role of organization member
+const (
+	// OrgRoleAdmin manage the members of organization
+	OrgRoleAdmin = "admin"
+	// OrgRoleMember only allowed to use the projects and settings of organization
+	OrgRoleMember = "member"
+)
+
+// Organization the department which the projects, integrate settings and compile envs belong to,
+// the resources with org id 0 are shared by the whole instance
+type Organization struct {
+	Addons
+	Name        string `orm:"column(name);size(64)" json:"name"`
+	Description string `orm:"column(description);size(256);null" json:"description"`
+	Creator     string `orm:"column(creator);size(64)" json:"creator"`
+	// MaxConcurrentBuilds max running build jobs of all the projects of organization, 0 means unlimited
+	MaxConcurrentBuilds int `orm:"column(max_concurrent_builds);default(0)" json:"max_concurrent_builds"`
+	// MaxEnvs max envs of all the projects of organization, 0 means unlimited
+	MaxEnvs int `orm:"column(max_envs);default(0)" json:"max_envs"`
+}
+
+// TableName ...
+func (t *Organization) TableName() string {
+	return "sys_organization"
+}
+
+// OrganizationUser the member of organization
+type OrganizationUser struct {
+	Addons
+	OrgID int64  `orm:"column(org_id)" json:"org_id"`
+	User  string `orm:"column(user);size(64)" json:"user"`
+	Role  string `orm:"column(role);size(32)" json:"role"`
+}
+
+// TableName ...
+func (t *OrganizationUser) TableName() string {
+	return "sys_organization_user"
+}
+
+// TableUnique ..
+func (t *OrganizationUser) TableUnique() [][]string {
+	return [][]string{
+		{"OrgID", "User"},
+	}
+}
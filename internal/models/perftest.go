@@ -0,0 +1,49 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// PerfTestResult the metrics of the perf test and the comparison with the baseline of previous publish
+type PerfTestResult struct {
+	Addons
+	ProjectID    int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID    int64  `orm:"column(publish_id)" json:"publish_id"`
+	StageID      int64  `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID int64  `orm:"column(publish_job_id)" json:"publish_job_id"`
+	Task         string `orm:"column(task);size(64)" json:"task"`
+	Tool         string `orm:"column(tool);size(32)" json:"tool"`
+	Requests     int64  `orm:"column(requests)" json:"requests"`
+	// the latencies are in milliseconds, the throughput is requests per second
+	AvgLatency float64 `orm:"column(avg_latency);digits(12);decimals(2)" json:"avg_latency"`
+	P95Latency float64 `orm:"column(p95_latency);digits(12);decimals(2)" json:"p95_latency"`
+	Throughput float64 `orm:"column(throughput);digits(12);decimals(2)" json:"throughput"`
+	ErrorRate  float64 `orm:"column(error_rate);digits(5);decimals(2)" json:"error_rate"`
+	// BaselineID the result of previous publish compared with, 0 means no baseline
+	BaselineID         int64   `orm:"column(baseline_id);default(0)" json:"baseline_id"`
+	BaselinePublishID  int64   `orm:"column(baseline_publish_id);default(0)" json:"baseline_publish_id"`
+	BaselineP95Latency float64 `orm:"column(baseline_p95_latency);digits(12);decimals(2)" json:"baseline_p95_latency"`
+	BaselineThroughput float64 `orm:"column(baseline_throughput);digits(12);decimals(2)" json:"baseline_throughput"`
+	// Regression the max percent of p95 latency increase and throughput decrease
+	Regression    float64 `orm:"column(regression);digits(12);decimals(2)" json:"regression"`
+	MaxRegression float64 `orm:"column(max_regression);digits(5);decimals(2)" json:"max_regression"`
+	GatePassed    bool    `orm:"column(gate_passed)" json:"gate_passed"`
+	Message       string  `orm:"column(message);size(512);null" json:"message"`
+}
+
+// TableName ...
+func (t *PerfTestResult) TableName() string {
+	return "pub_perf_test_result"
+}
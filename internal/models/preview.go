@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "time"
+
+// the status of preview env
+const (
+	PreviewStatusPending  = "pending"
+	PreviewStatusDeployed = "deployed"
+	PreviewStatusFailed   = "failed"
+	PreviewStatusClosed   = "closed"
+)
+
+// PreviewEnv the ephemeral env cloned from the preview source env of project for a merge request,
+// which deploys the merge request branch and is torn down when the merge request merged or closed
+type PreviewEnv struct {
+	Addons
+	ProjectID   int64 `orm:"column(project_id)" json:"project_id"`
+	SourceEnvID int64 `orm:"column(source_env_id)" json:"source_env_id"`
+	EnvID       int64 `orm:"column(env_id)" json:"env_id"`
+	// RepoID the scm integrate setting id which the webhook came from
+	RepoID  int64  `orm:"column(repo_id)" json:"repo_id"`
+	Repo    string `orm:"column(repo);size(256)" json:"repo"`
+	Number  int    `orm:"column(number)" json:"number"`
+	Branch  string `orm:"column(branch);size(128)" json:"branch"`
+	Sha     string `orm:"column(sha);size(64);null" json:"sha"`
+	URL     string `orm:"column(url);size(256);null" json:"url"`
+	Status  string `orm:"column(status);size(16)" json:"status"`
+	Message string `orm:"column(message);size(256);null" json:"message"`
+	// SyncAt the time when the head of merge request updated, the pending preview fails after timeout since then
+	SyncAt *time.Time `orm:"column(sync_at);null;type(datetime)" json:"sync_at"`
+	// Notified the url of preview was commented on the merge request
+	Notified bool `orm:"column(notified);default(false)" json:"notified"`
+}
+
+// TableName ...
+func (t *PreviewEnv) TableName() string {
+	return "project_preview_env"
+}
+
+// TableIndex ...
+func (t *PreviewEnv) TableIndex() [][]string {
+	return [][]string{
+		[]string{"Repo", "Number"},
+		[]string{"Status"},
+	}
+}
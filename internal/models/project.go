@@ -17,6 +17,8 @@ limitations under the License.
 package models
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-atomci/atomci/utils/query"
@@ -60,6 +62,12 @@ type Project struct {
 	Creator     string     `orm:"column(creator);size(64)" json:"creator"`
 	StartAt     time.Time  `orm:"column(start_at);auto_now;type(datetime);null" json:"start_at"`
 	EndAt       *time.Time `orm:"column(end_at);type(datetime);null" json:"end_at"`
+	// MaxConcurrentBuilds max running build jobs of the project, 0 means use the system default
+	MaxConcurrentBuilds int `orm:"column(max_concurrent_builds);default(0)" json:"max_concurrent_builds"`
+	// OrgID the organization of project, 0 means the project does not belong to any organization
+	OrgID int64 `orm:"column(org_id);default(0)" json:"org_id"`
+	// RetentionMonths the publish jobs and operation logs older than the months are archived, 0 means use the system default
+	RetentionMonths int `orm:"column(retention_months);default(0)" json:"retention_months"`
 }
 
 // TableName ...
@@ -79,6 +87,7 @@ type ProjectResponse struct {
 	Status      int8       `json:"status"`
 	Owner       string     `json:"owner"`
 	Creator     string     `json:"creator"`
+	OrgID       int64      `json:"org_id"`
 	Members     int        `json:"members"`
 	MembersName []string   `json:"membersName"`
 }
@@ -106,36 +115,170 @@ func (t *ProjectUser) TableName() string {
 // ProjectApp ...
 type ProjectApp struct {
 	Addons
-	Creator           string   `orm:"column(creator);size(64);null" json:"creator"`
-	ProjectID         int64    `orm:"column(project_id)" json:"project_id"`
-	ScmID             int64    `orm:"column(scm_id)" json:"scm_id"`
+	Creator   string `orm:"column(creator);size(64);null" json:"creator"`
+	ProjectID int64  `orm:"column(project_id)" json:"project_id"`
+	ScmID     int64  `orm:"column(scm_id)" json:"scm_id"`
+	// DeployAfter the comma separated ids of project apps which must be ready before the app is deployed
+	DeployAfter string `orm:"column(deploy_after);size(256);null" json:"deploy_after"`
+	// CompileTemplate the compile command template used by the app, empty means the template of app language
+	CompileTemplate string `orm:"column(compile_template);size(64);null" json:"compile_template"`
+	// CompileCommand override the compile command template in the project, which supports the same placeholders
+	CompileCommand string `orm:"column(compile_command);type(text);null" json:"compile_command"`
+	// DockerfileTemplate the dockerfile template written when the app lacks dockerfile, empty means the template of app language
+	DockerfileTemplate string `orm:"column(dockerfile_template);size(64);null" json:"dockerfile_template"`
+	// Team the team which owns the app
+	Team string `orm:"column(team);size(64);null" json:"team"`
+	// OnCall the on-call contact of the app, such as user name, email or phone
+	OnCall string `orm:"column(on_call);size(128);null" json:"on_call"`
+	// Tier the service tier of the app, tier-1 is the most critical
+	Tier              string   `orm:"column(tier);size(16);null" json:"tier"`
 	BranchHistoryList []string `orm:"-" json:"branch_history_list"`
 }
 
+// project app service tier
+const (
+	AppTier1 = "tier-1"
+	AppTier2 = "tier-2"
+	AppTier3 = "tier-3"
+)
+
+// AppTiers the service tiers of project app
+var AppTiers = []string{AppTier1, AppTier2, AppTier3}
+
 // TableName ..
 func (t *ProjectApp) TableName() string {
 	return "pub_project_app"
 }
 
+// DeployAfterIDs return the ids of project apps which the app depends on when deploy
+func (t *ProjectApp) DeployAfterIDs() []int64 {
+	ids := []int64{}
+	for _, item := range strings.Split(t.DeployAfter, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(item), 10, 64)
+		if err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 // ProjectEnv the Basic Data of stages based on commpany
 type ProjectEnv struct {
 	Addons
-	ProjectID   int64  `orm:"column(project_id)" json:"project_id"`
-	Name        string `orm:"column(name);size(64)" json:"name"`
-	Description string `orm:"column(description);size(256)" json:"description"`
-	Cluster     int64  `orm:"column(cluster);" json:"cluster"`
-	Namespace   string `orm:"column(namespace);size(256)" json:"namespace"`
-	ArrangeEnv  string `orm:"column(arrange_env);size(64)" json:"arrange_env"`
-	CIServer    int64  `orm:"column(ci_server);" json:"ci_server"`
-	Registry    int64  `orm:"column(registry);" json:"registry"`
-	Creator     string `orm:"column(creator);size(64)" json:"creator"`
+	ProjectID         int64  `orm:"column(project_id)" json:"project_id"`
+	Name              string `orm:"column(name);size(64)" json:"name"`
+	Description       string `orm:"column(description);size(256)" json:"description"`
+	Cluster           int64  `orm:"column(cluster);" json:"cluster"`
+	Namespace         string `orm:"column(namespace);size(256)" json:"namespace"`
+	ArrangeEnv        string `orm:"column(arrange_env);size(64)" json:"arrange_env"`
+	CIServer          int64  `orm:"column(ci_server);" json:"ci_server"`
+	Registry          int64  `orm:"column(registry);" json:"registry"`
+	ArgoCD            int64  `orm:"column(argocd);default(0)" json:"argocd"`
+	IssueTracker      int64  `orm:"column(issue_tracker);default(0)" json:"issue_tracker"`
+	MergeTarget       string `orm:"column(merge_target);size(64);null" json:"merge_target"`
+	ReleaseBranch     string `orm:"column(release_branch);size(128);null" json:"release_branch"`
+	ConcurrencyPolicy string `orm:"column(concurrency_policy);size(32);default(reject)" json:"concurrency_policy"`
+	DeployWindows     string `orm:"column(deploy_windows);type(text);null" json:"deploy_windows"`
+	WindowPolicy      string `orm:"column(window_policy);size(32);default(reject)" json:"window_policy"`
+	// BuildCluster/BuildNamespace the kubernetes cluster and namespace which the build pods run on, 0 means follow the ci server
+	BuildCluster   int64  `orm:"column(build_cluster);default(0)" json:"build_cluster"`
+	BuildNamespace string `orm:"column(build_namespace);size(256);null" json:"build_namespace"`
+	// CIServerPool the jenkins integrate settings in json array, the ci jobs are routed to the least loaded one of them and the ci server
+	CIServerPool string `orm:"column(ci_server_pool);size(256);null" json:"ci_server_pool"`
+	// Prune delete the resources which no longer in the arranges of the apps deployed, or of the apps removed,
+	// it is also the prune option of argo cd application sync
+	Prune bool `orm:"column(prune);default(false)" json:"prune"`
+	// PreviewSource the env is cloned as the ephemeral preview env of the merge requests
+	PreviewSource bool `orm:"column(preview_source);default(false)" json:"preview_source"`
+	// Cosign the cosign integrate setting whose public key verifies the image signatures before deploy, 0 means no verification
+	Cosign          int64  `orm:"column(cosign);default(0)" json:"cosign"`
+	SignaturePolicy string `orm:"column(signature_policy);size(32);default(enforce)" json:"signature_policy"`
+	Creator         string `orm:"column(creator);size(64)" json:"creator"`
 }
 
+// project env concurrency policy
+const (
+	ConcurrencyPolicyReject         = "reject"
+	ConcurrencyPolicyQueue          = "queue"
+	ConcurrencyPolicyCancelPrevious = "cancel-previous"
+)
+
+// project env deploy window policy, handle the deploy job triggered out of the deploy windows
+const (
+	WindowPolicyReject = "reject"
+	WindowPolicyQueue  = "queue"
+)
+
+// project env signature policy, handle the images whose signature is missing or invalid when deploy
+const (
+	SignaturePolicyEnforce = "enforce"
+	SignaturePolicyWarn    = "warn"
+)
+
 // TableName ...
 func (t *ProjectEnv) TableName() string {
 	return "project_env"
 }
 
+// DeployFreeze the freeze period of project envs, deploy is not allowed during the period
+type DeployFreeze struct {
+	Addons
+	ProjectID int64     `orm:"column(project_id)" json:"project_id"`
+	EnvID     int64     `orm:"column(env_id);default(0)" json:"env_id"`
+	Name      string    `orm:"column(name);size(64)" json:"name"`
+	Reason    string    `orm:"column(reason);size(256);null" json:"reason"`
+	StartAt   time.Time `orm:"column(start_at);type(datetime)" json:"start_at"`
+	EndAt     time.Time `orm:"column(end_at);type(datetime)" json:"end_at"`
+	Creator   string    `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *DeployFreeze) TableName() string {
+	return "project_deploy_freeze"
+}
+
+// EnvLock the lock of project env, the deploy and auto promote to the env are blocked while it is locked
+type EnvLock struct {
+	Addons
+	ProjectID int64  `orm:"column(project_id)" json:"project_id"`
+	EnvID     int64  `orm:"column(env_id)" json:"env_id"`
+	Reason    string `orm:"column(reason);size(256)" json:"reason"`
+	Locker    string `orm:"column(locker);size(64)" json:"locker"`
+	// UnlockAt the env is unlocked automatically at the time, nil means locked until unlocked manually
+	UnlockAt   *time.Time `orm:"column(unlock_at);type(datetime);null" json:"unlock_at"`
+	Unlocker   string     `orm:"column(unlocker);size(64);null" json:"unlocker"`
+	UnlockedAt *time.Time `orm:"column(unlocked_at);type(datetime);null" json:"unlocked_at"`
+}
+
+// TableName ...
+func (t *EnvLock) TableName() string {
+	return "project_env_lock"
+}
+
+// Active the lock is neither unlocked manually nor expired at the moment
+func (t *EnvLock) Active(moment time.Time) bool {
+	return t.UnlockedAt == nil && (t.UnlockAt == nil || t.UnlockAt.After(moment))
+}
+
+// ProjectAgentTemplate the customization of the jenkins agent pod of project ci jobs
+type ProjectAgentTemplate struct {
+	Addons
+	ProjectID int64 `orm:"column(project_id);unique" json:"project_id"`
+	// Labels json of the pod labels
+	Labels string `orm:"column(labels);type(text);null" json:"labels"`
+	// Volumes json of the volumes mounted into all the containers of pod
+	Volumes        string `orm:"column(volumes);type(text);null" json:"volumes"`
+	ServiceAccount string `orm:"column(service_account);size(256);null" json:"service_account"`
+	// IdleMinutes the agent pod is kept for reuse by the next build of the same job, 0 means deleted after build
+	IdleMinutes int    `orm:"column(idle_minutes);default(0)" json:"idle_minutes"`
+	Updater     string `orm:"column(updater);size(64);null" json:"updater"`
+}
+
+// TableName ...
+func (t *ProjectAgentTemplate) TableName() string {
+	return "project_agent_template"
+}
+
 // ProjectPipeline ...
 type ProjectPipeline struct {
 	Addons
@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvLockActive(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	tests := []struct {
+		lock *EnvLock
+		want bool
+	}{
+		{&EnvLock{}, true},
+		{&EnvLock{UnlockAt: &later}, true},
+		{&EnvLock{UnlockAt: &earlier}, false},
+		{&EnvLock{UnlockAt: &later, UnlockedAt: &earlier}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.lock.Active(now); got != tt.want {
+			t.Errorf("case %v: Active() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
@@ -44,6 +44,10 @@ const (
 	StepManual = "manual"
 	StepBuild  = "build"
 	StepDeploy = "deploy"
+	// StepPromote copy the built image to the registry of current env instead of rebuilding
+	StepPromote = "promote"
+	// StepE2ETest run the e2e test suites against the deployed env, the pass rates gate the promotion
+	StepE2ETest = "e2e-test"
 )
 
 // ProejctReleaseFilterQuery ..
@@ -59,6 +63,25 @@ type ProejctReleaseFilterQuery struct {
 	CreateAtEnd   string `json:"createAtEnd"`
 }
 
+// PublishListFilter the server side filters of the publish list, the zero value matches all
+type PublishListFilter struct {
+	StageID       int64
+	Status        []int64
+	Creator       string
+	ProjectAppID  int64
+	CreateAtStart *time.Time
+	CreateAtEnd   *time.Time
+}
+
+// OperationLogListFilter the server side filters of the operation log list, the zero value matches all
+type OperationLogListFilter struct {
+	StageID       int64
+	Step          string
+	Creator       string
+	CreateAtStart *time.Time
+	CreateAtEnd   *time.Time
+}
+
 // PublishOperation ..
 type PublishOperation struct {
 	Manual      bool `json:"manual"`
@@ -66,6 +89,8 @@ type PublishOperation struct {
 	Terminate   bool `json:"terminate"`
 	Deploy      bool `json:"deploy"`
 	MergeBranch bool `json:"merge-branch"`
+	Promote     bool `json:"promote"`
+	E2ETest     bool `json:"e2e-test"`
 
 	NextStage bool `json:"next-stage"`
 	BackTo    bool `json:"back-to"`
@@ -88,6 +113,12 @@ type Publish struct {
 	PipelineID             int64             `orm:"column(pipeline_id)" json:"pipeline_id"`
 	LastPipelineInstanceID int64             `orm:"column(last_pipeline_instance_id)" json:"last_pipeline_instance_id"`
 	VersionNo              string            `orm:"column(version_no);size(64)" json:"version_no"`
+	Level                  int               `orm:"column(level);default(0)" json:"level"`
+	Approvers              string            `orm:"column(approvers);size(1024);null" json:"approvers"`
+	ReleaseNotes           string            `orm:"column(release_notes);type(text);null" json:"-"`
+	Issues                 string            `orm:"column(issues);type(text);null" json:"issues"`
+	WaitingSince           *time.Time        `orm:"column(waiting_since);type(datetime);null" json:"waiting_since"`
+	SLALevel               int               `orm:"column(sla_level);default(0)" json:"sla_level"`
 	Operations             *PublishOperation `orm:"-" json:"operations"`
 	NextStep               string            `orm:"-" json:"next_step"`
 	Previous               string            `orm:"-" json:"previous"`
@@ -98,6 +129,28 @@ func (t *Publish) TableName() string {
 	return "pub_publish"
 }
 
+// TableIndex ...
+func (t *Publish) TableIndex() [][]string {
+	return [][]string{
+		[]string{"ProjectID", "StageID"},
+		[]string{"ProjectID", "Status"},
+	}
+}
+
+// Publish sla level, the notification already sent for the publish waiting in the current manual step
+const (
+	SLALevelNone = iota
+	SLALevelWarned
+	SLALevelEscalated
+)
+
+// Publish urgency level, the build job of higher level publish is scheduled first
+const (
+	PublishLevelNormal = iota
+	PublishLevelHigh
+	PublishLevelUrgent
+)
+
 // PublishApp ..
 type PublishApp struct {
 	Addons
@@ -105,6 +158,9 @@ type PublishApp struct {
 	ProjectAppID   int64  `orm:"column(project_app_id)" json:"project_app_id"`
 	BranchName     string `orm:"column(branch_name);size(64)" json:"branch_name"`
 	CompileCommand string `orm:"column(compile_command);size(1024)" json:"compile_command"`
+	ReleaseBranch  string `orm:"column(release_branch);size(128);null" json:"release_branch"`
+	// ImageDigest the digest of image promoted to the registry of current env
+	ImageDigest string `orm:"column(image_digest);size(128);null" json:"image_digest"`
 }
 
 // TableName ...
@@ -112,6 +168,14 @@ func (t *PublishApp) TableName() string {
 	return "pub_publish_app"
 }
 
+// TableIndex ...
+func (t *PublishApp) TableIndex() [][]string {
+	return [][]string{
+		[]string{"PublishID"},
+		[]string{"ProjectAppID"},
+	}
+}
+
 // PublishOperationLog ..
 type PublishOperationLog struct {
 	Addons
@@ -129,9 +193,18 @@ type PublishOperationLog struct {
 	JobName            string `orm:"column(job_name);size(128)" json:"job_name"`
 	Code               string `orm:"column(code);size(128)" json:"code"`
 	Message            string `orm:"column(message);size(256)" json:"message"`
+	// Inputs the json of the input values filled in by the approver of manual step
+	Inputs string `orm:"column(inputs);type(text);null" json:"inputs"`
 }
 
 // TableName ...
 func (t *PublishOperationLog) TableName() string {
 	return "pub_publish_operation"
 }
+
+// TableIndex ...
+func (t *PublishOperationLog) TableIndex() [][]string {
+	return [][]string{
+		[]string{"PublishID", "StageID"},
+	}
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package models
 
+import "time"
+
 // PublishJob status const defined
 const (
 	StatusInit        = "INIT"
@@ -29,8 +31,9 @@ const (
 
 // publishjob job type
 const (
-	JobTypeBuild  = "build"
-	JobTypeDeploy = "deploy"
+	JobTypeBuild   = "build"
+	JobTypeDeploy  = "deploy"
+	JobTypeE2ETest = "e2e-test"
 )
 
 // PublishJob ..
@@ -45,6 +48,15 @@ type PublishJob struct {
 	EnvID            int64  `orm:"column(stage_id)" json:"stage_id"`
 	Operator         string `orm:"column(operator); size(64)" json:"operator"`
 	JobType          string `orm:"column(job_type);size(64)" json:"job_type"`
+	StepIndex        int    `orm:"column(step_index);default(0)" json:"step_index"`
+	// CIVersion the credential version of ci server when the job created, the job keep using it after rotation
+	CIVersion int `orm:"column(ci_version);default(0)" json:"ci_version"`
+	// CIServer the jenkins integrate setting which the job routed to, 0 means the ci server of env
+	CIServer int64 `orm:"column(ci_server);default(0)" json:"ci_server"`
+	// Wave the wave of apps which is being deployed, zero when the apps of deploy job are applied at once
+	Wave int `orm:"column(wave);default(0)" json:"wave"`
+	// WaveStartAt the time when the current wave applied
+	WaveStartAt *time.Time `orm:"column(wave_start_at);null;type(datetime)" json:"wave_start_at"`
 }
 
 // TableName ...
@@ -52,6 +64,23 @@ func (t *PublishJob) TableName() string {
 	return "pub_publish_job"
 }
 
+// TableIndex ...
+func (t *PublishJob) TableIndex() [][]string {
+	return [][]string{
+		[]string{"PublishID", "Status"},
+	}
+}
+
+// PublishJobListFilter the server side filters of the publish job list, the zero value matches all
+type PublishJobListFilter struct {
+	StageID       int64
+	Status        []string
+	JobType       string
+	Operator      string
+	CreateAtStart *time.Time
+	CreateAtEnd   *time.Time
+}
+
 // PublishJobApp ..
 type PublishJobApp struct {
 	Addons
@@ -60,13 +89,118 @@ type PublishJobApp struct {
 	ProjectAPPID int64  `orm:"column(project_app_id)" json:"project_app_id"`
 	BranchName   string `orm:"column(branch_name); size(64)" json:"branch_name"`
 	BranchURL    string `orm:"column(branch_url); size(255)" json:"branch_url"`
+	CommitSha    string `orm:"column(commit_sha);size(64);null" json:"commit_sha"`
 	ImageAddr    string `orm:"column(image_addr);size(255)" json:"image_addr"`
 	ImageVersion string `orm:"column(image_version);size(64)" json:"image_version"`
 	Release      string `orm:"column(release);size(64)" json:"release"`
 	Gray         bool   `orm:"column(gray)" json:"gray"`
+	// ArrangeRevision the revision of app arrange which the deploy used
+	ArrangeRevision int64 `orm:"column(arrange_revision);default(0)" json:"arrange_revision"`
+	// Wave the deploy wave of app, the apps of lower wave are ready before the higher wave applied
+	Wave int `orm:"column(wave);default(0)" json:"wave"`
 }
 
 // TableName ...
 func (t *PublishJobApp) TableName() string {
 	return "pub_publish_job_app"
 }
+
+// PublishJobQueue status const defined
+const (
+	QueueStatusWaiting    = "WAITING"
+	QueueStatusDispatched = "DISPATCHED"
+	QueueStatusCanceled   = "CANCELED"
+	QueueStatusFailed     = "FAILED"
+)
+
+// PublishJobQueue the job triggered when the env already has running job, dispatch it after the running job completed
+type PublishJobQueue struct {
+	Addons
+	ProjectID          int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID          int64  `orm:"column(publish_id)" json:"publish_id"`
+	PipelineInstanceID int64  `orm:"column(pipeline_instance_id)" json:"pipeline_instance_id"`
+	EnvID              int64  `orm:"column(stage_id)" json:"stage_id"`
+	StepIndex          int    `orm:"column(step_index);default(0)" json:"step_index"`
+	JobType            string `orm:"column(job_type);size(64)" json:"job_type"`
+	Params             string `orm:"column(params);type(text)" json:"-"`
+	Creator            string `orm:"column(creator);size(64)" json:"creator"`
+	Status             string `orm:"column(status);size(16)" json:"status"`
+	Message            string `orm:"column(message);size(256);null" json:"message"`
+}
+
+// TableName ...
+func (t *PublishJobQueue) TableName() string {
+	return "pub_publish_job_queue"
+}
+
+// PublishJobCallback status const defined
+const (
+	CallbackStatusProcessing = "PROCESSING"
+	CallbackStatusProcessed  = "PROCESSED"
+	CallbackStatusFailed     = "FAILED"
+	CallbackStatusDead       = "DEAD"
+)
+
+// PublishJobCallback source const defined
+const (
+	CallbackSourceJenkins   = "jenkins"
+	CallbackSourceReconcile = "reconcile"
+	CallbackSourceReplay    = "replay"
+	CallbackSourceRecovery  = "recovery"
+)
+
+// PublishJobCallback the callback of publish job, which is processed only once by its idempotency key,
+// the failed callback is retried until max attempts, then it is dead and waits for replay
+type PublishJobCallback struct {
+	Addons
+	IdempotencyKey string `orm:"column(idempotency_key);size(64);unique" json:"idempotency_key"`
+	ProjectID      int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID      int64  `orm:"column(publish_id)" json:"publish_id"`
+	EnvID          int64  `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID   int64  `orm:"column(publish_job_id);index" json:"publish_job_id"`
+	Step           string `orm:"column(step);size(64)" json:"step"`
+	Source         string `orm:"column(source);size(16)" json:"source"`
+	// JobStatus the job status reported, the e2e test result is decided by the pass rate gate
+	JobStatus     string `orm:"column(job_status);size(16)" json:"job_status"`
+	Status        string `orm:"column(status);size(16)" json:"status"`
+	PublishStatus int64  `orm:"column(publish_status);default(0)" json:"publish_status"`
+	Attempts      int    `orm:"column(attempts);default(0)" json:"attempts"`
+	Message       string `orm:"column(message);size(256);null" json:"message"`
+}
+
+// TableName ...
+func (t *PublishJobCallback) TableName() string {
+	return "pub_publish_job_callback"
+}
+
+// PublishStepTask status const defined
+const (
+	StepTaskStatusPending   = "PENDING"
+	StepTaskStatusRunning   = "RUNNING"
+	StepTaskStatusSucceeded = "SUCCEEDED"
+	StepTaskStatusFailed    = "FAILED"
+)
+
+// PublishStepTask the step triggered by api runs in background, the ui polls its status,
+// the attempt failed before any publish job created is retried until max attempts
+type PublishStepTask struct {
+	Addons
+	ProjectID          int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID          int64  `orm:"column(publish_id);index" json:"publish_id"`
+	PipelineInstanceID int64  `orm:"column(pipeline_instance_id)" json:"pipeline_instance_id"`
+	EnvID              int64  `orm:"column(stage_id)" json:"stage_id"`
+	StepIndex          int    `orm:"column(step_index);default(0)" json:"step_index"`
+	Step               string `orm:"column(step);size(64)" json:"step"`
+	Params             string `orm:"column(params);type(text)" json:"-"`
+	Creator            string `orm:"column(creator);size(64)" json:"creator"`
+	Status             string `orm:"column(status);size(16)" json:"status"`
+	Attempts           int    `orm:"column(attempts);default(0)" json:"attempts"`
+	PublishStatus      int64  `orm:"column(publish_status);default(0)" json:"publish_status"`
+	RunID              int64  `orm:"column(run_id);default(0)" json:"run_id"`
+	Message            string `orm:"column(message);size(256);null" json:"message"`
+}
+
+// TableName ...
+func (t *PublishStepTask) TableName() string {
+	return "pub_publish_step_task"
+}
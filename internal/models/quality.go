@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// the kinds of quality report
+const (
+	QualityUnitTest      = "unit-test"
+	QualityCoverage      = "coverage"
+	QualitySonar         = "sonar"
+	QualityVulnerability = "vulnerability"
+)
+
+// QualityReport the quality result of app reported by the build job, the fields are filled by kind
+type QualityReport struct {
+	Addons
+	ProjectID    int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID    int64  `orm:"column(publish_id)" json:"publish_id"`
+	StageID      int64  `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID int64  `orm:"column(publish_job_id)" json:"publish_job_id"`
+	AppName      string `orm:"column(app_name);size(64)" json:"app_name"`
+	Kind         string `orm:"column(kind);size(32)" json:"kind"`
+	// unit-test
+	Total   int `orm:"column(total);default(0)" json:"total"`
+	Failed  int `orm:"column(failed);default(0)" json:"failed"`
+	Skipped int `orm:"column(skipped);default(0)" json:"skipped"`
+	// coverage percent
+	Coverage float64 `orm:"column(coverage);digits(5);decimals(2)" json:"coverage"`
+	// sonar quality gate status, OK/WARN/ERROR
+	SonarStatus string `orm:"column(sonar_status);size(16);null" json:"sonar_status"`
+	// vulnerability counts by severity
+	Critical  int    `orm:"column(critical);default(0)" json:"critical"`
+	High      int    `orm:"column(high);default(0)" json:"high"`
+	Medium    int    `orm:"column(medium);default(0)" json:"medium"`
+	Low       int    `orm:"column(low);default(0)" json:"low"`
+	ReportURL string `orm:"column(report_url);size(512);null" json:"report_url"`
+}
+
+// TableName ...
+func (t *QualityReport) TableName() string {
+	return "pub_quality_report"
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// ReleasePlan the saved app set, branches, pipeline and approvers, which could be instantiated into publish order
+type ReleasePlan struct {
+	Addons
+	ProjectID   int64  `orm:"column(project_id)" json:"project_id"`
+	Name        string `orm:"column(name);size(64)" json:"name"`
+	Description string `orm:"column(description);size(256)" json:"description"`
+	PipelineID  int64  `orm:"column(pipeline_id)" json:"pipeline_id"`
+	Apps        string `orm:"column(apps);type(text)" json:"-"`
+	Approvers   string `orm:"column(approvers);size(1024);null" json:"-"`
+	Level       int    `orm:"column(level);default(0)" json:"level"`
+	Creator     string `orm:"column(creator);size(64)" json:"creator"`
+}
+
+// TableName ...
+func (t *ReleasePlan) TableName() string {
+	return "pub_release_plan"
+}
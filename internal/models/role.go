@@ -163,3 +163,44 @@ type GroupRoleUserRsp struct {
 	Phone string     `json:"phone"`
 	Roles []*RoleRsp `json:"roles"`
 }
+
+// GroupRoleEnvPermission the action allowed for the role in the envs, the role without env permission is not restricted
+type GroupRoleEnvPermission struct {
+	Addons
+	Group string `orm:"column(group);size(128)" json:"group"`
+	Role  string `orm:"column(role);size(128)" json:"role"`
+	// Action build/deploy/promote/approve/arrange, * means all actions
+	Action string `orm:"column(action);size(32)" json:"action"`
+	// Env the arrange env tag of project env, * means all envs
+	Env string `orm:"column(env);size(64)" json:"env"`
+}
+
+// TableName ..
+func (t *GroupRoleEnvPermission) TableName() string {
+	return "sys_group_role_env_permission"
+}
+
+// TableIndex ..
+func (t *GroupRoleEnvPermission) TableIndex() [][]string {
+	return [][]string{
+		{"Group", "Role"},
+	}
+}
+
+// TableUnique ..
+func (t *GroupRoleEnvPermission) TableUnique() [][]string {
+	return [][]string{
+		{"Group", "Role", "Action", "Env"},
+	}
+}
+
+// RoleEnvPermissionItem ..
+type RoleEnvPermissionItem struct {
+	Action string `json:"action"`
+	Env    string `json:"env"`
+}
+
+// RoleEnvPermissionReq replace the env permissions of role, empty means the role is not restricted
+type RoleEnvPermissionReq struct {
+	Permissions []*RoleEnvPermissionItem `json:"permissions"`
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "time"
+
+// RunnerTokenPrefix the prefix of runner token, which is returned once when the runner registers
+const RunnerTokenPrefix = "atcr_"
+
+// the status of runner task
+const (
+	RunnerTaskPending = "PENDING"
+	RunnerTaskRunning = "RUNNING"
+	RunnerTaskSuccess = "SUCCESS"
+	RunnerTaskFailure = "FAILURE"
+	RunnerTaskAborted = "ABORTED"
+)
+
+// Runner the self hosted runner agent which executes the build steps in local docker instead of jenkins
+type Runner struct {
+	Addons
+	Name          string     `orm:"column(name);size(64)" json:"name"`
+	Version       string     `orm:"column(version);size(32)" json:"version"`
+	TokenHash     string     `orm:"column(token_hash);size(64);unique" json:"-"`
+	LastHeartbeat *time.Time `orm:"column(last_heartbeat);null;type(datetime)" json:"last_heartbeat"`
+}
+
+// TableName ...
+func (t *Runner) TableName() string {
+	return "sys_runner"
+}
+
+// Online the runner sent the heartbeat in the timeout
+func (t *Runner) Online(moment time.Time, timeout time.Duration) bool {
+	return t.LastHeartbeat != nil && moment.Sub(*t.LastHeartbeat) < timeout
+}
+
+// RunnerTask the run of job queued for the runners, the spec is claimed by one runner
+type RunnerTask struct {
+	Addons
+	JobName  string `orm:"column(job_name);size(256)" json:"job_name"`
+	RunnerID int64  `orm:"column(runner_id);default(0)" json:"runner_id"`
+	// Spec the steps and containers of task encoded by json
+	Spec   string `orm:"column(spec);type(text)" json:"-"`
+	Status string `orm:"column(status);size(16);index" json:"status"`
+	// Abort the task is requested to abort, the runner stops it at the next log report
+	Abort         bool       `orm:"column(abort);default(false)" json:"abort"`
+	FinishedSteps int        `orm:"column(finished_steps);default(0)" json:"finished_steps"`
+	Log           string     `orm:"column(log);type(text)" json:"-"`
+	StartedAt     *time.Time `orm:"column(started_at);null;type(datetime)" json:"started_at"`
+	FinishedAt    *time.Time `orm:"column(finished_at);null;type(datetime)" json:"finished_at"`
+}
+
+// TableName ...
+func (t *RunnerTask) TableName() string {
+	return "sys_runner_task"
+}
+
+// Finished the task is finished by the runner or aborted before claimed
+func (t *RunnerTask) Finished() bool {
+	return t.Status == RunnerTaskSuccess || t.Status == RunnerTaskFailure || t.Status == RunnerTaskAborted
+}
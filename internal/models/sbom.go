@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// SBOMFormatCycloneDX the format of sbom generated by the sbom sub task
+const SBOMFormatCycloneDX = "cyclonedx-json"
+
+// ImageSBOM the software bill of materials of the image built by the publish job app
+type ImageSBOM struct {
+	Addons
+	ProjectID    int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID    int64  `orm:"column(publish_id)" json:"publish_id"`
+	StageID      int64  `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID int64  `orm:"column(publish_job_id)" json:"publish_job_id"`
+	ProjectAppID int64  `orm:"column(project_app_id)" json:"project_app_id"`
+	AppName      string `orm:"column(app_name);size(64)" json:"app_name"`
+	ImageAddr    string `orm:"column(image_addr);size(255)" json:"image_addr"`
+	Format       string `orm:"column(format);size(32)" json:"format"`
+	Components   int    `orm:"column(components)" json:"components"`
+	Content      string `orm:"column(content);type(text)" json:"-"`
+}
+
+// TableName ...
+func (t *ImageSBOM) TableName() string {
+	return "pub_image_sbom"
+}
+
+// TableIndex ...
+func (t *ImageSBOM) TableIndex() [][]string {
+	return [][]string{
+		[]string{"PublishJobID", "ProjectAppID"},
+		[]string{"ImageAddr"},
+	}
+}
+
+// SBOMPackage the package of image sbom, used to find the images contain the package
+type SBOMPackage struct {
+	Addons
+	SBOMID       int64  `orm:"column(sbom_id)" json:"sbom_id"`
+	ProjectID    int64  `orm:"column(project_id)" json:"project_id"`
+	ProjectAppID int64  `orm:"column(project_app_id)" json:"project_app_id"`
+	ImageAddr    string `orm:"column(image_addr);size(255)" json:"image_addr"`
+	Name         string `orm:"column(name);size(255)" json:"name"`
+	Version      string `orm:"column(version);size(128);null" json:"version"`
+	Type         string `orm:"column(type);size(32);null" json:"type"`
+	Purl         string `orm:"column(purl);size(512);null" json:"purl"`
+}
+
+// TableName ...
+func (t *SBOMPackage) TableName() string {
+	return "pub_image_sbom_package"
+}
+
+// TableIndex ...
+func (t *SBOMPackage) TableIndex() [][]string {
+	return [][]string{
+		[]string{"Name", "Version"},
+		[]string{"SBOMID"},
+	}
+}
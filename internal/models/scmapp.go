@@ -19,17 +19,23 @@ package models
 // ScmApp ...
 type ScmApp struct {
 	Addons
-	Creator           string   `orm:"column(creator);size(64);null" json:"creator"`
-	Name              string   `orm:"column(name);size(64)" json:"name"`
-	FullName          string   `orm:"column(full_name);size(64)" json:"full_name"`
-	Language          string   `orm:"column(language);size(64)" json:"language"`
-	BranchName        string   `orm:"column(branch_name);size(64)" json:"branch_name"`
-	Path              string   `orm:"column(path);size(255)" json:"path"`
-	RepoID            int64    `orm:"column(repo_id)" json:"repo_id"`
-	CompileEnvID      int64    `orm:"column(compile_env_id);size(64)" json:"compile_env_id"`
-	BuildPath         string   `orm:"column(build_path);size(64)" json:"build_path"`
-	Dockerfile        string   `orm:"column(dockerfile);size(256)" json:"dockerfile"`
+	Creator      string `orm:"column(creator);size(64);null" json:"creator"`
+	Name         string `orm:"column(name);size(64)" json:"name"`
+	FullName     string `orm:"column(full_name);size(64)" json:"full_name"`
+	Language     string `orm:"column(language);size(64)" json:"language"`
+	BranchName   string `orm:"column(branch_name);size(64)" json:"branch_name"`
+	Path         string `orm:"column(path);size(255)" json:"path"`
+	RepoID       int64  `orm:"column(repo_id)" json:"repo_id"`
+	CompileEnvID int64  `orm:"column(compile_env_id);size(64)" json:"compile_env_id"`
+	BuildPath    string `orm:"column(build_path);size(64)" json:"build_path"`
+	Dockerfile   string `orm:"column(dockerfile);size(256)" json:"dockerfile"`
+	WatchPaths   string `orm:"column(watch_paths);size(1024);null" json:"watch_paths"`
+	// BuildMode how the image is built, kaniko(default) build with dockerfile, buildpacks build without dockerfile
+	BuildMode         string   `orm:"column(build_mode);size(32);null" json:"build_mode"`
+	DBMigration       string   `orm:"column(db_migration);type(text);null" json:"-"`
 	BranchHistoryList []string `orm:"-" json:"branch_history_list"`
+	// Arch comma separated target cpu architectures of image, more than one builds the per-arch images and a manifest list
+	Arch string `orm:"column(arch);size(64);null" json:"arch"`
 }
 
 // TableName ..
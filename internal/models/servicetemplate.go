@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// ServiceTemplate the scaffolding of new service, includes the default compile env, arrange skeleton and pipeline flow,
+// the compile env and the task templates of pipeline config are referenced by name
+type ServiceTemplate struct {
+	Addons
+	Name        string `orm:"column(name);size(64)" json:"name"`
+	Description string `orm:"column(description);size(256);null" json:"description"`
+	Language    string `orm:"column(language);size(64)" json:"language"`
+	Creator     string `orm:"column(creator);size(64)" json:"creator"`
+	CompileEnv  string `orm:"column(compile_env);size(64);null" json:"compile_env"`
+	BuildPath   string `orm:"column(build_path);size(255);null" json:"build_path"`
+	Dockerfile  string `orm:"column(dockerfile);size(255);null" json:"dockerfile"`
+	// Arrange the arrange template of the service, eg: {{ .App.Name }}, {{ .Env.Namespace }}
+	Arrange   string `orm:"column(arrange);type(text)" json:"arrange"`
+	Variables string `orm:"column(variables);type(text);null" json:"variables"`
+	// PipelineName the pipeline created with PipelineConfig, skipped if the project has the pipeline
+	PipelineName   string `orm:"column(pipeline_name);size(64);null" json:"pipeline_name"`
+	PipelineConfig string `orm:"column(pipeline_config);type(text);null" json:"pipeline_config"`
+}
+
+// TableName ...
+func (t *ServiceTemplate) TableName() string {
+	return "pub_service_template"
+}
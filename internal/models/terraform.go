@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// the status of terraform plan, the plan is approved or rejected by the manual step after it
+const (
+	TerraformPlanned  = "planned"
+	TerraformApproved = "approved"
+	TerraformRejected = "rejected"
+	TerraformApplied  = "applied"
+)
+
+// TerraformPlan the plan output of terraform sub task, apply is only allowed for the approved plan
+type TerraformPlan struct {
+	Addons
+	ProjectID    int64  `orm:"column(project_id)" json:"project_id"`
+	PublishID    int64  `orm:"column(publish_id)" json:"publish_id"`
+	StageID      int64  `orm:"column(stage_id)" json:"stage_id"`
+	PublishJobID int64  `orm:"column(publish_job_id)" json:"publish_job_id"`
+	AppName      string `orm:"column(app_name);size(128)" json:"app_name"`
+	Dir          string `orm:"column(dir);size(256)" json:"dir"`
+	Workspace    string `orm:"column(workspace);size(64)" json:"workspace"`
+	Checksum     string `orm:"column(checksum);size(64)" json:"checksum"`
+	Output       string `orm:"column(output);type(text);null" json:"output"`
+	Status       string `orm:"column(status);size(16)" json:"status"`
+	Reviewer     string `orm:"column(reviewer);size(64);null" json:"reviewer"`
+	ApplyJobID   int64  `orm:"column(apply_job_id);default(0)" json:"apply_job_id"`
+	ApplyOutput  string `orm:"column(apply_output);type(text);null" json:"apply_output"`
+}
+
+// TableName ...
+func (t *TerraformPlan) TableName() string {
+	return "pub_terraform_plan"
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/go-atomci/atomci/utils/validate"
@@ -28,6 +29,18 @@ const (
 	LDAPAuth
 )
 
+// reservedUsers the operators of the actions triggered automatically, eg: auto promote, scm webhook,
+// which are not allowed to be the name of user
+var reservedUsers = map[string]bool{
+	"system":  true,
+	"webhook": true,
+}
+
+// IsReservedUser ..
+func IsReservedUser(user string) bool {
+	return reservedUsers[user]
+}
+
 type User struct {
 	Addons
 	User  string `orm:"column(user);unique" json:"user"`
@@ -72,6 +85,9 @@ func (v *UserReq) Verify() error {
 	if err := validate.ValidateName(v.User); err != nil {
 		return err
 	}
+	if IsReservedUser(v.User) {
+		return fmt.Errorf("用户名 %v 为系统保留名称", v.User)
+	}
 	if err := validate.ValidateDescription(v.Name); err != nil {
 		return err
 	}
@@ -37,9 +37,20 @@ func RegisterRoutes() {
 
 				beego.NSRouter("/logout", &api.AuthController{}, "get:Logout"),
 				beego.NSRouter("/login", &api.AuthController{}, "post:Authenticate"),
+				beego.NSRouter("/webhooks/scm/:repo_id", &api.WebhookController{}, "post:ScmPush"),
+				beego.NSRouter("/runner/register", &api.RunnerAgentController{}, "post:Register"),
+				beego.NSRouter("/runner/heartbeat", &api.RunnerAgentController{}, "post:Heartbeat"),
+				beego.NSRouter("/runner/tasks/claim", &api.RunnerAgentController{}, "post:ClaimTask"),
+				beego.NSRouter("/runner/tasks/:task_id/logs", &api.RunnerAgentController{}, "post:AppendTaskLog"),
+				beego.NSRouter("/runner/tasks/:task_id/status", &api.RunnerAgentController{}, "post:ReportTaskStatus"),
 				beego.NSRouter("/getCurrentUser", &api.UserController{}, "get:GetCurrentUser"),
+				beego.NSRouter("/tokens", &api.AccessTokenController{}, "get:GetAccessTokens;post:CreateAccessToken"),
+				beego.NSRouter("/tokens/:token_id", &api.AccessTokenController{}, "delete:RevokeAccessToken"),
 
-				beego.NSRouter("/audit", &api.AuditController{}, "get:AuditList"),
+				beego.NSRouter("/audit", &api.AuditController{}, "get:AuditList;post:AuditListByPagination"),
+				beego.NSRouter("/audit/export", &api.AuditController{}, "post:AuditExport"),
+				beego.NSRouter("/archive", &api.ArchiveController{}, "post:Archive"),
+				beego.NSRouter("/archive/purge", &api.ArchiveController{}, "post:PurgeArchives"),
 
 				beego.NSRouter("/resources", &api.ResourceController{}, "get:ResourceTypeList;post:CreateResourceType"),
 				beego.NSRouter("/resources-operations", &api.ResourceController{}, "get:ResourceOperationsList"),
@@ -71,8 +82,16 @@ func RegisterRoutes() {
 				beego.NSRouter("/roles/:role", &api.RoleController{}, "get:GetRole;put:UpdateRole;delete:DeleteRole"),
 				beego.NSRouter("/roles/:role/operations", &api.RoleController{}, "get:RoleOperationList;post:AddRoleOperation"),
 				beego.NSRouter("/roles/:role/operations/:operationID", &api.RoleController{}, "delete:RemoveRoleOperation"),
+				beego.NSRouter("/roles/:role/env-permissions", &api.RoleController{}, "get:GetRoleEnvPermissions;put:SetRoleEnvPermissions"),
 				beego.NSRouter("/groups/:group/roles/:role/bundling", &api.RoleController{}, "get:RoleBundlingList;post:RoleBundling;delete:RoleUnbundling"),
 
+				// Organization
+				beego.NSRouter("/orgs", &api.OrganizationController{}, "get:GetOrganizations;post:CreateOrganization"),
+				beego.NSRouter("/orgs/:org_id", &api.OrganizationController{}, "get:GetOrganization;put:UpdateOrganization;delete:DeleteOrganization"),
+				beego.NSRouter("/orgs/:org_id/members", &api.OrganizationController{}, "get:GetOrganizationMembers;post:AddOrganizationMember"),
+				beego.NSRouter("/orgs/:org_id/members/:user", &api.OrganizationController{}, "delete:RemoveOrganizationMember"),
+				beego.NSRouter("/orgs/:org_id/projects/:project_id", &api.OrganizationController{}, "put:MoveOrganizationProject"),
+
 				// PipelineStage
 				beego.NSRouter("/pipelines/flow/components", &api.PipelineController{}, "get:GetFlowComponents"),
 				beego.NSRouter("/pipelines/flow/steps", &api.PipelineController{}, "get:GetTaskTmpls;post:GetTaskTmplsByPagination"),
@@ -85,12 +104,36 @@ func RegisterRoutes() {
 				beego.NSRouter("/integrate/settings/scms", &api.IntegrateController{}, "get:GetSCMIntegrateSettings;post:GetSCMIntegrateSettingsByPagination"),
 				beego.NSRouter("/integrate/settings/:id", &api.IntegrateController{}, "put:UpdateIntegrateSetting;delete:DeleteIntegrateSetting"),
 				beego.NSRouter("/integrate/settings/verify", &api.IntegrateController{}, "post:VerifyIntegrateSetting"),
+				beego.NSRouter("/integrate/settings/kubeconfig", &api.IntegrateController{}, "post:ParseKubeConfig"),
 				beego.NSRouter("/integrate/settings/verifyrepo", &api.IntegrateController{}, "post:VerifyRepoConnetion"),
+				beego.NSRouter("/integrate/settings/:id/health", &api.IntegrateController{}, "post:CheckIntegrateSetting"),
+				beego.NSRouter("/integrate/settings/:id/credentials", &api.IntegrateController{}, "get:GetIntegrateCredentials;post:AddIntegrateCredential"),
+				beego.NSRouter("/integrate/settings/:id/credentials/rollback", &api.IntegrateController{}, "post:RollbackIntegrateCredential"),
+				beego.NSRouter("/integrate/settings/:id/credentials/:version/validate", &api.IntegrateController{}, "post:ValidateIntegrateCredential"),
+				beego.NSRouter("/integrate/settings/:id/credentials/:version/activate", &api.IntegrateController{}, "post:ActivateIntegrateCredential"),
+				beego.NSRouter("/integrate/settings/:id/capability", &api.IntegrateController{}, "get:GetClusterCapability"),
+				beego.NSRouter("/integrate/health", &api.IntegrateController{}, "get:GetIntegrateSettingHealths"),
+				beego.NSRouter("/integrate/workflow-drivers", &api.IntegrateController{}, "get:GetWorkflowDrivers"),
+				beego.NSRouter("/integrate/runners", &api.IntegrateController{}, "get:GetRunners"),
+				beego.NSRouter("/integrate/runners/:runner_id", &api.IntegrateController{}, "delete:DeleteRunner"),
 				beego.NSRouter("/integrate/clusters", &api.IntegrateController{}, "get:GetClusterIntegrateSettings"),
 				// CompileEnv
 				beego.NSRouter("/integrate/compile_envs", &api.IntegrateController{}, "get:GetCompileEnvs;post:GetCompileEnvsByPagination"),
 				beego.NSRouter("/integrate/compile_envs/create", &api.IntegrateController{}, "post:CreateCompileEnv"),
 				beego.NSRouter("/integrate/compile_envs/:id", &api.IntegrateController{}, "put:UpdateCompileEnv;delete:DeleteCompileEnv"),
+				beego.NSRouter("/integrate/compile_envs/:id/versions", &api.IntegrateController{}, "get:GetCompileEnvVersions"),
+				beego.NSRouter("/integrate/compile_envs/:id/versions/:version/activate", &api.IntegrateController{}, "post:ActivateCompileEnvVersion"),
+				beego.NSRouter("/integrate/compile_commands", &api.IntegrateController{}, "get:GetCompileCommands;post:CreateCompileCommand"),
+				beego.NSRouter("/integrate/compile_commands/:id", &api.IntegrateController{}, "put:UpdateCompileCommand;delete:DeleteCompileCommand"),
+				beego.NSRouter("/integrate/compile_commands/:id/revisions", &api.IntegrateController{}, "get:GetCompileCommandRevisions"),
+				beego.NSRouter("/integrate/compile_commands/:id/revisions/:revision/restore", &api.IntegrateController{}, "post:RestoreCompileCommandRevision"),
+				beego.NSRouter("/integrate/dockerfile_templates", &api.IntegrateController{}, "get:GetDockerfileTemplates;post:CreateDockerfileTemplate"),
+				beego.NSRouter("/integrate/dockerfile_templates/:id", &api.IntegrateController{}, "put:UpdateDockerfileTemplate;delete:DeleteDockerfileTemplate"),
+				beego.NSRouter("/integrate/compile_envs/:id/deprecation", &api.IntegrateController{}, "put:DeprecateCompileEnv"),
+				beego.NSRouter("/integrate/compile_envs/:id/validate", &api.IntegrateController{}, "post:ValidateCompileEnv"),
+				// ServiceTemplate
+				beego.NSRouter("/integrate/service_templates", &api.IntegrateController{}, "get:GetServiceTemplates;post:CreateServiceTemplate"),
+				beego.NSRouter("/integrate/service_templates/:id", &api.IntegrateController{}, "get:GetServiceTemplate;put:UpdateServiceTemplate;delete:DeleteServiceTemplate"),
 
 				// scm apps
 				beego.NSRouter("/repos/:repo_id/projects", &api.AppController{}, "post:GetGitProjectsByRepoID"),
@@ -99,19 +142,35 @@ func RegisterRoutes() {
 				beego.NSRouter("/apps", &api.AppController{}, "get:GetAllApps;post:GetAppsByPagination"),
 				beego.NSRouter("/apps/:app_id", &api.AppController{}, "get:ScmAppInfo;put:UpdateScmApp;delete:DeleteScmApp"),
 				beego.NSRouter("/apps/:app_id/syncBranches", &api.AppController{}, "post:SyncAppBranches"),
+				beego.NSRouter("/apps/:app_id/webhook", &api.AppController{}, "post:RegisterAppWebhook"),
 				beego.NSRouter("/apps/:app_id/branches", &api.AppController{}, "post:GetAppBranches"),
 
 				// Project
 				beego.NSRouter("/projects", &api.ProjectController{}, "post:ProjectList"),
+				beego.NSRouter("/catalog", &api.ProjectController{}, "get:GetServiceCatalog"),
+				beego.NSRouter("/search", &api.SearchController{}, "get:Search"),
 				beego.NSRouter("/projects/create", &api.ProjectController{}, "post:Create"),
+				beego.NSRouter("/projects/import", &api.ProjectController{}, "post:ImportProject"),
 				beego.NSRouter("/projects/:project_id", &api.ProjectController{}, "put:Update;delete:Delete;get:GetProject"),
+				beego.NSRouter("/projects/:project_id/export", &api.ProjectController{}, "get:ExportProject"),
+				beego.NSRouter("/projects/:project_id/import", &api.ProjectController{}, "post:ImportProjectConfig"),
 
 				// Project App
 				beego.NSRouter("/projects/:project_id/apps/create", &api.ProjectController{}, "post:CreateApp"),
+				beego.NSRouter("/projects/:project_id/services", &api.ProjectController{}, "post:ScaffoldService"),
 				beego.NSRouter("/projects/:project_id/apps", &api.ProjectController{}, "get:GetApps;post:GetAppsByPagination"),
 				beego.NSRouter("/projects/:project_id/apps/:app_id/:env_id/arrange", &api.AppController{}, "get:GetArrange;post:SetArrange"),
+				beego.NSRouter("/projects/:project_id/apps/:app_id/:env_id/arrange/render", &api.AppController{}, "post:RenderArrange"),
+				beego.NSRouter("/projects/:project_id/apps/:app_id/:env_id/arrange/revisions", &api.AppController{}, "get:GetArrangeRevisions"),
+				beego.NSRouter("/projects/:project_id/apps/:app_id/:env_id/arrange/revisions/:revision", &api.AppController{}, "get:GetArrangeRevision"),
+				beego.NSRouter("/projects/:project_id/apps/:app_id/:env_id/arrange/revisions/:revision/diff", &api.AppController{}, "get:DiffArrangeRevisions"),
+				beego.NSRouter("/projects/:project_id/apps/:app_id/:env_id/arrange/revisions/:revision/restore", &api.AppController{}, "post:RestoreArrangeRevision"),
 				beego.NSRouter("/arrange/yaml/parser", &api.AppController{}, "post:ParseArrangeYaml"),
 				beego.NSRouter("/projects/:project_id/apps/:project_app_id", &api.ProjectController{}, "put:UpdateProjectApp;delete:DeleteProjectApp"),
+				beego.NSRouter("/projects/:project_id/apps/:project_app_id/deploy-after", &api.ProjectController{}, "put:UpdateProjectAppDeployAfter"),
+				beego.NSRouter("/projects/:project_id/apps/:project_app_id/compile-command", &api.ProjectController{}, "put:UpdateProjectAppCompileCommand"),
+				beego.NSRouter("/projects/:project_id/apps/:project_app_id/dockerfile-template", &api.ProjectController{}, "put:UpdateProjectAppDockerfileTemplate"),
+				beego.NSRouter("/projects/:project_id/apps/:project_app_id/ownership", &api.ProjectController{}, "put:UpdateProjectAppOwnership"),
 
 				beego.NSRouter("/projects/:project_id/checkProjectOwner", &api.ProjectController{}, "post:CheckProjetCreator"),
 				beego.NSRouter("/projects/:project_id/clusters/:cluster/apps", &api.ProjectController{}, "post:GetAppserviceList"),
@@ -125,11 +184,21 @@ func RegisterRoutes() {
 				// Project Setup
 				beego.NSRouter("/projects/:project_id/members", &api.ProjectController{}, "get:GetProjectMembers;put:AddProjectMember"),
 				beego.NSRouter("/projects/:project_id/members/:id", &api.ProjectController{}, "delete:DeleteProjectMember"),
+				beego.NSRouter("/projects/:project_id/service-accounts", &api.ProjectController{}, "get:GetServiceAccounts;post:CreateServiceAccount"),
+				beego.NSRouter("/projects/:project_id/service-accounts/:account", &api.ProjectController{}, "delete:DeleteServiceAccount"),
+				beego.NSRouter("/projects/:project_id/service-accounts/:account/tokens", &api.ProjectController{}, "get:GetServiceAccountTokens;post:CreateServiceAccountToken"),
+				beego.NSRouter("/projects/:project_id/service-accounts/:account/tokens/:token_id", &api.ProjectController{}, "delete:RevokeServiceAccountToken"),
 
 				// Project env
 				beego.NSRouter("/projects/:project_id/envs", &api.ProjectController{}, "get:GetProjectEnvs;post:GetProjectEnvsByPagination"),
 				beego.NSRouter("/projects/:project_id/envs/create", &api.ProjectController{}, "post:CreateProjectEnv"),
 				beego.NSRouter("/projects/:project_id/envs/:env_id", &api.ProjectController{}, "put:UpdateProjectEnv;delete:DeleteProjectEnv"),
+				beego.NSRouter("/projects/:project_id/envs/:env_id/clone", &api.ProjectController{}, "post:CloneProjectEnv"),
+				beego.NSRouter("/projects/:project_id/envs/:env_id/lock", &api.ProjectController{}, "get:GetProjectEnvLock;post:LockProjectEnv;delete:UnlockProjectEnv"),
+				beego.NSRouter("/projects/:project_id/previews", &api.ProjectController{}, "get:GetPreviewEnvs"),
+				beego.NSRouter("/projects/:project_id/freezes", &api.ProjectController{}, "get:GetDeployFreezes;post:CreateDeployFreeze"),
+				beego.NSRouter("/projects/:project_id/freezes/:freeze_id", &api.ProjectController{}, "delete:DeleteDeployFreeze"),
+				beego.NSRouter("/projects/:project_id/agent-template", &api.ProjectController{}, "get:GetAgentTemplate;put:UpdateAgentTemplate"),
 
 				// Project pipeline
 				beego.NSRouter("/projects/:project_id/pipelines", &api.ProjectController{}, "get:GetProjectPipelines;post:GetPipelinesByPagination"),
@@ -137,29 +206,82 @@ func RegisterRoutes() {
 				beego.NSRouter("/projects/:project_id/pipelines/:id", &api.ProjectController{}, "get:GetProjectPipeline;put:UpdatePipelineConfig;delete:DeleteProjectPipeline"),
 				// Project stats
 				beego.NSRouter("/projects/:project_id/publish/stats", &api.PipelineController{}, "post:GetPublishStats"),
+				beego.NSRouter("/graphql", &api.GraphQLController{}, "get:Query;post:Query"),
+				beego.NSRouter("/projects/:project_id/analytics/dora", &api.AnalyticsController{}, "get:GetDORAMetrics"),
+				beego.NSRouter("/projects/:project_id/analytics/dora/export", &api.AnalyticsController{}, "get:ExportDORAMetrics"),
+				beego.NSRouter("/projects/:project_id/analytics/steps", &api.AnalyticsController{}, "get:GetStepAnalytics"),
 
 				// Publish-Order / release
 				beego.NSRouter("/projects/:project_id/publishes", &api.PublishController{}, "post:PublishList"),
 				beego.NSRouter("/projects/:project_id/publishes/create", &api.PublishController{}, "post:Create"),
+				beego.NSRouter("/projects/:project_id/publishes/batch", &api.PublishController{}, "post:BatchPublish"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id", &api.PublishController{}, "get:GetPublish;put:ClosePublish;delete:DeletePublish"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id/apps/can_added", &api.PublishController{}, "get:CanAddedApps"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id/apps/create", &api.PublishController{}, "post:AddPublishApp"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id/apps/:publish_app_id", &api.PublishController{}, "delete:DeletePublishApp"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id/audits", &api.PublishController{}, "post:GetOpertaionLogByPagination"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/job-stages", &api.AnalyticsController{}, "get:GetPublishJobStages"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/jobs/:job_id/log", &api.PipelineController{}, "get:GetPublishJobLog"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id/stages/:stage_id/back-to", &api.PublishController{}, "get:GetBackTo;post:TriggerBackTo"),
 				beego.NSRouter("/projects/:project_id/publishes/:publish_id/stages/:stage_id/next-stage", &api.PublishController{}, "get:GetNextStage;post:TriggerNextStage"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/stages/:stage_id/approval", &api.PublishController{}, "post:ApproveStage"),
+				beego.NSRouter("/projects/:project_id/publish/aging", &api.PublishController{}, "get:GetPublishAging"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/release-notes", &api.PublishController{}, "get:ExportReleaseNotes;post:GenerateReleaseNotes"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/diff", &api.PublishController{}, "get:DiffPublishes"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/issues", &api.PublishController{}, "get:GetPublishIssues;post:LinkPublishIssues"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/comments", &api.PublishController{}, "get:GetPublishComments;post:CreatePublishComment"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/comments/:comment_id", &api.PublishController{}, "put:UpdatePublishComment;delete:DeletePublishComment"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/attachments/:attachment_id", &api.PublishController{}, "get:DownloadCommentAttachment"),
+				beego.NSRouter("/projects/:project_id/release-plans", &api.PublishController{}, "get:GetReleasePlans"),
+				beego.NSRouter("/projects/:project_id/release-plans/create", &api.PublishController{}, "post:CreateReleasePlan"),
+				beego.NSRouter("/projects/:project_id/release-plans/:plan_id", &api.PublishController{}, "get:GetReleasePlan;put:UpdateReleasePlan;delete:DeleteReleasePlan"),
+				beego.NSRouter("/projects/:project_id/release-plans/:plan_id/publishes", &api.PublishController{}, "post:CreatePublishFromReleasePlan"),
 
 				// Publish pipeline
 				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name", &api.PipelineController{}, "get:GetStepInfo;post:RunStep"),
 				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/callback", &api.PipelineController{}, "post:RunStepCallback"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/terraform", &api.PipelineController{}, "post:ReportTerraform"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/terraform-plans", &api.PipelineController{}, "get:GetTerraformPlans"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/graph", &api.PipelineController{}, "get:GetPublishGraph"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/db-migration", &api.PipelineController{}, "post:ReportDBMigration"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/db-migrations", &api.PipelineController{}, "get:GetDBMigrations"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/e2e-report", &api.PipelineController{}, "post:ReportE2ETest"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/e2e-reports", &api.PipelineController{}, "get:GetE2ETestReports"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/perf-report", &api.PipelineController{}, "post:ReportPerfTest"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/perf-tests", &api.PipelineController{}, "get:GetPerfTestResults"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/quality", &api.PipelineController{}, "post:ReportQuality"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/quality", &api.PipelineController{}, "get:GetQualitySummary"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/stages/:stage_id/steps/:step_name/sbom", &api.PipelineController{}, "post:ReportSBOM"),
+				beego.NSRouter("/pipelines/:project_id/publishes/:publish_id/sboms", &api.PipelineController{}, "get:GetPublishSBOMs"),
+				beego.NSRouter("/pipelines/:project_id/sboms/:sbom_id/download", &api.PipelineController{}, "get:DownloadSBOM"),
+				beego.NSRouter("/sbom/deployments", &api.PipelineController{}, "get:FindSBOMDeployments"),
+				beego.NSRouter("/pipelines/:project_id/stages/:stage_id/queue", &api.PipelineController{}, "get:GetJobQueue"),
+				beego.NSRouter("/pipelines/:project_id/queue/:queue_id", &api.PipelineController{}, "delete:CancelJobQueueItem"),
+				beego.NSRouter("/pipelines/:project_id/tasks/:task_id", &api.PipelineController{}, "get:GetStepTask"),
+				beego.NSRouter("/pipelines/:project_id/callbacks", &api.PipelineController{}, "get:GetJobCallbacks"),
+				beego.NSRouter("/pipelines/:project_id/callbacks/:callback_id/replay", &api.PipelineController{}, "post:ReplayJobCallback"),
+				beego.NSRouter("/pipelines/:project_id/stages/:stage_id/apps/:app_id/image-tags", &api.PipelineController{}, "get:GetAppImageTags"),
+				beego.NSRouter("/pipelines/:project_id/image-retention", &api.PipelineController{}, "get:PreviewImageRetention"),
 				beego.NSRouter("/pipelines/stages/:stage_id/jenkins-config", &api.PipelineController{}, "get:GetJenkinsConfig"),
+			),
+			beego.NSNamespace("/v2",
+				beego.NSRouter("/openapi.json", &api.OpenAPIController{}, "get:Document"),
+				beego.NSRouter("/projects", &api.APIV2Controller{}, "get:ListProjects"),
+				beego.NSRouter("/projects/:project_id", &api.APIV2Controller{}, "get:GetProject"),
+				beego.NSRouter("/projects/:project_id/envs", &api.APIV2Controller{}, "get:ListProjectEnvs"),
+				beego.NSRouter("/projects/:project_id/publishes", &api.APIV2Controller{}, "get:ListPublishes"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id", &api.APIV2Controller{}, "get:GetPublish"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/jobs", &api.APIV2Controller{}, "get:ListPublishJobs"),
+				beego.NSRouter("/projects/:project_id/publishes/:publish_id/operation-logs", &api.APIV2Controller{}, "get:ListPublishOperationLogs"),
 			))
 
 	beego.AddNamespace(publishAPI)
+	beego.InsertFilter("/atomci/api/*", beego.BeforeRouter, api.RateLimitFilter)
 
 	beego.Get("/health", func(ctx *context.Context) {
 		ctx.Output.Body([]byte("Ok"))
 	})
+	beego.Router("/metrics", &api.MetricsController{}, "get:Metrics")
 
 	beego.ErrorController(&api.ErrorController{})
 
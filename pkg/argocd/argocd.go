@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/tlsutil"
+)
+
+// Argo CD application sync/health/operation status
+const (
+	SyncStatusSynced = "Synced"
+
+	HealthStatusHealthy  = "Healthy"
+	HealthStatusDegraded = "Degraded"
+
+	OperationPhaseSucceeded = "Succeeded"
+	OperationPhaseFailed    = "Failed"
+	OperationPhaseError     = "Error"
+)
+
+// Client a tiny Argo CD REST API client
+type Client struct {
+	URL        string
+	Token      string
+	httpClient *http.Client
+}
+
+// NewClient the client uses the dedicated transport of the tls config of setting, nil means the default tls config
+func NewClient(addr, token string, tlsConfig *tls.Config) *Client {
+	return &Client{
+		URL:   strings.TrimSuffix(addr, "/"),
+		Token: token,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: tlsutil.Transport(nil, tlsConfig),
+		},
+	}
+}
+
+// Repository the git repository which argo cd pull manifests from
+type Repository struct {
+	Repo     string `json:"repo"`
+	Type     string `json:"type,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Application ..
+type Application struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Spec     ApplicationSpec   `json:"spec"`
+	Status   ApplicationStatus `json:"status,omitempty"`
+}
+
+// ObjectMeta ..
+type ObjectMeta struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ApplicationSpec ..
+type ApplicationSpec struct {
+	Project     string                 `json:"project"`
+	Source      ApplicationSource      `json:"source"`
+	Destination ApplicationDestination `json:"destination"`
+}
+
+// ApplicationSource ..
+type ApplicationSource struct {
+	RepoURL        string `json:"repoURL"`
+	Path           string `json:"path"`
+	TargetRevision string `json:"targetRevision"`
+}
+
+// ApplicationDestination ..
+type ApplicationDestination struct {
+	Server    string `json:"server,omitempty"`
+	Namespace string `json:"namespace"`
+}
+
+// ApplicationStatus ..
+type ApplicationStatus struct {
+	Sync struct {
+		Status   string `json:"status"`
+		Revision string `json:"revision"`
+	} `json:"sync"`
+	Health struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"health"`
+	OperationState *struct {
+		Phase   string `json:"phase"`
+		Message string `json:"message"`
+	} `json:"operationState,omitempty"`
+}
+
+// Version return the argo cd server version
+func (c *Client) Version() (string, error) {
+	rsp := struct {
+		Version string `json:"Version"`
+	}{}
+	if err := c.do(http.MethodGet, "/api/version", nil, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.Version, nil
+}
+
+// UpsertRepository register the git repository credentials to argo cd
+func (c *Client) UpsertRepository(repo *Repository) error {
+	return c.do(http.MethodPost, "/api/v1/repositories?upsert=true", repo, nil)
+}
+
+// UpsertApplication create or update application
+func (c *Client) UpsertApplication(app *Application) error {
+	return c.do(http.MethodPost, "/api/v1/applications?upsert=true", app, nil)
+}
+
+// SyncApplication trigger application sync to the revision, prune delete the resources no longer in git
+func (c *Client) SyncApplication(name, revision string, prune bool) error {
+	body := map[string]interface{}{
+		"revision": revision,
+		"prune":    prune,
+	}
+	return c.do(http.MethodPost, fmt.Sprintf("/api/v1/applications/%s/sync", url.PathEscape(name)), body, nil)
+}
+
+// GetApplication ..
+func (c *Client) GetApplication(name string) (*Application, error) {
+	app := &Application{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/applications/%s", url.PathEscape(name)), nil, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func (c *Client) do(method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, c.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request argo cd %s occur error: %s", path, err.Error())
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("request argo cd %s failed, status code: %d, response: %s", path, res.StatusCode, content)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/api/version":
+			w.Write([]byte(`{"Version":"v2.8.0"}`))
+		case "/api/v1/applications/atomci-1-2":
+			w.Write([]byte(`{"metadata":{"name":"atomci-1-2"},"status":{"sync":{"status":"Synced"},"health":{"status":"Healthy"}}}`))
+		case "/api/v1/applications/atomci-1-2/sync":
+			body := map[string]interface{}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["revision"] != "master" || body["prune"] != true {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case "/api/v1/applications", "/api/v1/repositories":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "token", nil)
+	if version, err := client.Version(); err != nil || version != "v2.8.0" {
+		t.Fatalf("Version() = %v, %v", version, err)
+	}
+	if err := client.UpsertRepository(&Repository{Repo: "http://gitlab.com/atomci/config.git"}); err != nil {
+		t.Fatalf("UpsertRepository() error: %v", err)
+	}
+	if err := client.UpsertApplication(&Application{Metadata: ObjectMeta{Name: "atomci-1-2"}}); err != nil {
+		t.Fatalf("UpsertApplication() error: %v", err)
+	}
+	if err := client.SyncApplication("atomci-1-2", "master", true); err != nil {
+		t.Fatalf("SyncApplication() error: %v", err)
+	}
+	app, err := client.GetApplication("atomci-1-2")
+	if err != nil || app.Status.Sync.Status != SyncStatusSynced || app.Status.Health.Status != HealthStatusHealthy {
+		t.Fatalf("GetApplication() = %+v, %v", app, err)
+	}
+	want := []string{
+		"GET /api/version",
+		"POST /api/v1/repositories?upsert=true",
+		"POST /api/v1/applications?upsert=true",
+		"POST /api/v1/applications/atomci-1-2/sync",
+		"GET /api/v1/applications/atomci-1-2",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+
+	if _, err := client.GetApplication("missing"); err == nil || !strings.Contains(err.Error(), "status code: 404") {
+		t.Errorf("GetApplication() of missing app error = %v", err)
+	}
+	if _, err := NewClient(server.URL, "invalid", nil).Version(); err == nil {
+		t.Errorf("the request of invalid token should fail")
+	}
+}
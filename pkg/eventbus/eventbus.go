@@ -0,0 +1,27 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+// Publisher publish the messages to the message bus
+type Publisher interface {
+	// Publish send the payload to the topic, the messages with the same key keep in order when supported
+	Publish(topic, key string, payload []byte) error
+	// Ping verify the connection, return the description of server
+	Ping() (string, error)
+	// Close release the connection
+	Close() error
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeNATSServer accept one connection, record the published messages and answer the pings
+func fakeNATSServer(t *testing.T, messages chan<- string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"version\":\"2.9.0\",\"tls_required\":false}\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case line == "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "CONNECT "):
+				if !strings.Contains(line, `"user":"atomci"`) {
+					fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+			case strings.HasPrefix(line, "PUB "):
+				var subject string
+				var size int
+				fmt.Sscanf(line, "PUB %s %d", &subject, &size)
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(reader, payload); err != nil {
+					return
+				}
+				messages <- subject + " " + string(payload[:size])
+			}
+		}
+	}()
+	return "nats://" + listener.Addr().String()
+}
+
+func TestNATSPublisher(t *testing.T) {
+	messages := make(chan string, 2)
+	publisher := NewNATSPublisher(fakeNATSServer(t, messages), "atomci", "secret", "", false)
+	defer publisher.Close()
+
+	version, err := publisher.Ping()
+	if err != nil || version != "NATS 2.9.0" {
+		t.Fatalf("Ping() = %v, %v", version, err)
+	}
+	if err := publisher.Publish("atomci.pipeline.job.status", "1", []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := <-messages; got != `atomci.pipeline.job.status {"id":"1"}` {
+		t.Errorf("published message = %v", got)
+	}
+}
+
+func TestNATSPublisherAuthError(t *testing.T) {
+	publisher := NewNATSPublisher(fakeNATSServer(t, make(chan string)), "", "", "token", false)
+	defer publisher.Close()
+	if _, err := publisher.Ping(); err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("Ping() error = %v, want authorization violation", err)
+	}
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "atomci" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/topics":
+			fmt.Fprint(w, `["atomci.pipeline.events"]`)
+		case "/topics/atomci.pipeline.events":
+			body := struct {
+				Records []struct {
+					Key   string          `json:"key"`
+					Value json.RawMessage `json:"value"`
+				} `json:"records"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" || len(body.Records) != 1 ||
+				body.Records[0].Key != "7" || string(body.Records[0].Value) != `{"id":"1"}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":3}]}`)
+		default:
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":40403,"error":"Topic not found"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewKafkaPublisher(server.URL+"/", "atomci", "secret", false)
+	if version, err := publisher.Ping(); err != nil || version != "Kafka REST Proxy, 1 topics" {
+		t.Errorf("Ping() = %v, %v", version, err)
+	}
+	if err := publisher.Publish("atomci.pipeline.events", "7", []byte(`{"id":"1"}`)); err != nil {
+		t.Errorf("Publish() error = %v", err)
+	}
+	if err := publisher.Publish("missing", "7", []byte(`{"id":"1"}`)); err == nil || !strings.Contains(err.Error(), "Topic not found") {
+		t.Errorf("Publish() to missing topic error = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/tlsutil"
+)
+
+// KafkaPublisher produce the messages through the kafka rest proxy (v2 api), which is provided by
+// confluent rest proxy, redpanda and so on
+type KafkaPublisher struct {
+	URL        string
+	User       string
+	Password   string
+	httpClient *http.Client
+}
+
+// NewKafkaPublisher the addr is the url of the rest proxy
+func NewKafkaPublisher(addr, user, password string, insecure bool) *KafkaPublisher {
+	return &KafkaPublisher{
+		URL:      strings.TrimSuffix(addr, "/"),
+		User:     user,
+		Password: password,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			// the tls config registered by host is used for the server which uses the private CA
+			Transport: tlsutil.NewTransport(&http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+			}),
+		},
+	}
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaOffset struct {
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	ErrorCode int    `json:"error_code"`
+	Error     string `json:"error"`
+}
+
+// Publish the payload must be json, the key decides the partition
+func (p *KafkaPublisher) Publish(topic, key string, payload []byte) error {
+	body := map[string]interface{}{
+		"records": []*kafkaRecord{{Key: key, Value: payload}},
+	}
+	rsp := struct {
+		Offsets []*kafkaOffset `json:"offsets"`
+	}{}
+	if err := p.do(http.MethodPost, "/topics/"+url.PathEscape(topic), body, &rsp); err != nil {
+		return err
+	}
+	for _, offset := range rsp.Offsets {
+		if offset.ErrorCode != 0 || offset.Error != "" {
+			return fmt.Errorf("produce to kafka topic %s failed, error code: %d, error: %s", topic, offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
+
+// Ping list the topics to verify the rest proxy
+func (p *KafkaPublisher) Ping() (string, error) {
+	topics := []string{}
+	if err := p.do(http.MethodGet, "/topics", nil, &topics); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Kafka REST Proxy, %d topics", len(topics)), nil
+}
+
+// Close ..
+func (p *KafkaPublisher) Close() error {
+	return nil
+}
+
+func (p *KafkaPublisher) do(method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, p.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if p.User != "" {
+		req.SetBasicAuth(p.User, p.Password)
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request kafka rest proxy %s occur error: %s", path, err.Error())
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("request kafka rest proxy %s failed, status code: %d, response: %s", path, res.StatusCode, content)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
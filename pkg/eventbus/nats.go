@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventbus
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsDefaultPort the default port of nats server
+const natsDefaultPort = "4222"
+
+// natsTimeout the timeout of connecting and every request
+const natsTimeout = 10 * time.Second
+
+// NATSPublisher a tiny nats core publisher speaks the text protocol, the connection is reused and
+// reconnected on the next publish when broken
+type NATSPublisher struct {
+	addr     string
+	user     string
+	password string
+	token    string
+	insecure bool
+
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	version string
+}
+
+// NewNATSPublisher the server like nats://host:4222 or tls://host:4222, the user info in url is used when
+// the user is empty
+func NewNATSPublisher(server, user, password, token string, insecure bool) *NATSPublisher {
+	return &NATSPublisher{
+		addr:     server,
+		user:     user,
+		password: password,
+		token:    token,
+		insecure: insecure,
+	}
+}
+
+type natsInfo struct {
+	Version     string `json:"version"`
+	TLSRequired bool   `json:"tls_required"`
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+func (p *NATSPublisher) connect() error {
+	server := p.addr
+	if !strings.Contains(server, "://") {
+		server = "nats://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("invalid nats server: %v", p.addr)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), natsDefaultPort)
+	}
+	connect := &natsConnect{Name: "atomci", Lang: "go", Version: "1.0.0", User: p.user, Pass: p.password, Token: p.token}
+	if connect.User == "" && u.User != nil {
+		connect.User = u.User.Username()
+		connect.Pass, _ = u.User.Password()
+	}
+
+	conn, err := net.DialTimeout("tcp", host, natsTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected nats server greeting: %s", strings.TrimSpace(line))
+	}
+	info := &natsInfo{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), info); err != nil {
+		conn.Close()
+		return err
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: p.insecure})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+	content, err := json.Marshal(connect)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.reader, p.version = conn, reader, info.Version
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", content); err != nil {
+		p.close()
+		return err
+	}
+	if err := p.waitPong(); err != nil {
+		p.close()
+		return err
+	}
+	return nil
+}
+
+// waitPong read until the pong of the ping sent, which means the former requests were processed
+func (p *NATSPublisher) waitPong() error {
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := fmt.Fprint(p.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (p *NATSPublisher) close() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn, p.reader = nil, nil
+}
+
+// Publish the key is not supported by nats core, the subject is the topic
+func (p *NATSPublisher) Publish(topic, key string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	p.conn.SetDeadline(time.Now().Add(natsTimeout))
+	if _, err := fmt.Fprintf(p.conn, "PUB %s %d\r\n%s\r\nPING\r\n", topic, len(payload), payload); err != nil {
+		p.close()
+		return err
+	}
+	if err := p.waitPong(); err != nil {
+		p.close()
+		return err
+	}
+	return nil
+}
+
+// Ping ..
+func (p *NATSPublisher) Ping() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return "", err
+		}
+	}
+	p.conn.SetDeadline(time.Now().Add(natsTimeout))
+	if _, err := fmt.Fprint(p.conn, "PING\r\n"); err != nil {
+		p.close()
+		return "", err
+	}
+	if err := p.waitPong(); err != nil {
+		p.close()
+		return "", err
+	}
+	return "NATS " + p.version, nil
+}
+
+// Close ..
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.close()
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package githubapp
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// DefaultAPIURL github.com api endpoint
+	DefaultAPIURL = "https://api.github.com"
+	// TokenUser the git username used together with installation access token
+	TokenUser = "x-access-token"
+
+	// refreshBefore refresh the installation token before it expired, the token lives one hour
+	refreshBefore = 5 * time.Minute
+	// jwtExpiration github allow the app jwt lives 10 minutes at most
+	jwtExpiration = 9 * time.Minute
+)
+
+// APIURL return the api endpoint of github.com or github enterprise server by its web url
+func APIURL(webURL string) string {
+	webURL = strings.TrimSuffix(strings.TrimSpace(webURL), "/")
+	if webURL == "" || strings.Contains(webURL, "://github.com") || strings.Contains(webURL, "://api.github.com") {
+		return DefaultAPIURL
+	}
+	if strings.HasSuffix(webURL, "/api/v3") {
+		return webURL
+	}
+	return webURL + "/api/v3"
+}
+
+// TokenSource issue short-lived installation access token for a github app installation,
+// the token was cached and refreshed automatically before it expired.
+type TokenSource struct {
+	APIURL         string
+	AppID          int64
+	InstallationID int64
+
+	privateKey string
+	key        *rsa.PrivateKey
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewTokenSource ..
+func NewTokenSource(apiURL string, appID, installationID int64, privateKey string) (*TokenSource, error) {
+	if appID == 0 || installationID == 0 {
+		return nil, fmt.Errorf("github app id and installation id are required")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse github app private key occur error: %s", err.Error())
+	}
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &TokenSource{
+		APIURL:         strings.TrimSuffix(apiURL, "/"),
+		AppID:          appID,
+		InstallationID: installationID,
+		privateKey:     privateKey,
+		key:            key,
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// PrivateKey return the pem encoded private key of the github app
+func (ts *TokenSource) PrivateKey() string {
+	return ts.privateKey
+}
+
+// Token return a valid installation access token, request a new one when the cached token is going to expire
+func (ts *TokenSource) Token() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && time.Until(ts.expiresAt) > refreshBefore {
+		return ts.token, nil
+	}
+
+	appToken, err := ts.appJWT()
+	if err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", ts.InstallationID)
+	rsp := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+	if err := ts.do(http.MethodPost, path, appToken, &rsp); err != nil {
+		return "", err
+	}
+	if rsp.Token == "" {
+		return "", fmt.Errorf("github app installation: %v return empty access token", ts.InstallationID)
+	}
+	ts.token = rsp.Token
+	ts.expiresAt = rsp.ExpiresAt
+	return ts.token, nil
+}
+
+// Installation ..
+type Installation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	} `json:"account"`
+	RepositorySelection string `json:"repository_selection"`
+}
+
+// Installations return the installations of the github app, used to choose installation id
+func (ts *TokenSource) Installations() ([]*Installation, error) {
+	appToken, err := ts.appJWT()
+	if err != nil {
+		return nil, err
+	}
+	rsp := []*Installation{}
+	if err := ts.do(http.MethodGet, "/app/installations", appToken, &rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// appJWT sign the jwt which authenticate as the github app itself
+func (ts *TokenSource) appJWT() (string, error) {
+	now := time.Now()
+	claims := jwt.StandardClaims{
+		// allow the clock drift between atomci and github
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(jwtExpiration).Unix(),
+		Issuer:    fmt.Sprintf("%d", ts.AppID),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(ts.key)
+}
+
+func (ts *TokenSource) do(method, path, appToken string, out interface{}) error {
+	req, err := http.NewRequest(method, ts.APIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+appToken)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	rsp, err := ts.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("github app api %v %v return status %v: %s", method, path, rsp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package githubapp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIURL(t *testing.T) {
+	cases := map[string]string{
+		"":                               DefaultAPIURL,
+		"https://github.com":             DefaultAPIURL,
+		"https://git.example.com/":       "https://git.example.com/api/v3",
+		"https://git.example.com/api/v3": "https://git.example.com/api/v3",
+	}
+	for in, want := range cases {
+		if got := APIURL(in); got != want {
+			t.Errorf("APIURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTokenCached(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/app/installations/2/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"token":"token-%d","expires_at":"%s"}`, requests, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	ts, err := NewTokenSource(server.URL, 1, 2, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1" {
+			t.Errorf("got token %q, want cached token-1", token)
+		}
+	}
+
+	// the token is going to expire, refresh it
+	ts.expiresAt = time.Now().Add(time.Minute)
+	if token, _ := ts.Token(); token != "token-2" {
+		t.Errorf("got token %q, want refreshed token-2", token)
+	}
+}
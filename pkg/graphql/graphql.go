@@ -0,0 +1,544 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package graphql is a minimal read only graphql executor, which supports queries with
+// variables, aliases, fragments and the @include/@skip directives. The schema is declared
+// by Object and Field, lists are resolved from slices and the scalar fields default to
+// the json tagged fields of the source struct or the keys of the source map.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxDepth the default max depth of the selection sets
+const DefaultMaxDepth = 10
+
+// Schema the graphql schema
+type Schema struct {
+	Query *Object
+	// MaxDepth the max depth of the selection sets, DefaultMaxDepth if zero
+	MaxDepth int
+}
+
+// Object the object type
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field the field of object type, the field is scalar if Type is nil
+type Field struct {
+	Type        *Object
+	Description string
+	Args        map[string]*Argument
+	// Resolve resolve the value of field, the default resolver reads the json tagged field or the map key of the source
+	Resolve ResolveFunc
+}
+
+// Argument the argument of field, Type is one of Int, Float, String, Boolean and ID with optional ! suffix
+type Argument struct {
+	Type    string
+	Default interface{}
+}
+
+// ResolveParams the params of resolver
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+// ResolveFunc the resolver of field
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+// Params the graphql request
+type Params struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// Error the graphql error
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Result the graphql response
+type Result struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// HasErrors whether the request failed
+func (r *Result) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// Do parse and execute the query, the request error returns the result without data
+func Do(ctx context.Context, schema *Schema, params Params) *Result {
+	doc, err := parse(params.Query)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(params.OperationName)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if op.kind != "query" {
+		return &Result{Errors: []*Error{{Message: fmt.Sprintf("the %v operation is not supported, only query is allowed", op.kind)}}}
+	}
+	variables, err := coerceVariables(op.variables, params.Variables)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	maxDepth := schema.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if depth := doc.depth(op.selectionSet, map[string]bool{}); depth > maxDepth {
+		return &Result{Errors: []*Error{{Message: fmt.Sprintf("the query depth %d exceeds the max depth %d", depth, maxDepth)}}}
+	}
+	e := &executor{
+		ctx:       ctx,
+		schema:    schema,
+		doc:       doc,
+		variables: variables,
+	}
+	data := e.executeSelectionSet(schema.Query, nil, op.selectionSet, nil)
+	return &Result{Data: data, Errors: e.errors}
+}
+
+// depth the max depth of the nested selection sets, the fragments are expanded
+func (d *document) depth(selections []selection, visited map[string]bool) int {
+	max := 0
+	for _, item := range selections {
+		depth := 0
+		switch s := item.(type) {
+		case *field:
+			if len(s.selectionSet) > 0 {
+				depth = d.depth(s.selectionSet, visited)
+			}
+		case *fragmentSpread:
+			if definition, ok := d.fragments[s.name]; ok && !visited[s.name] {
+				visited[s.name] = true
+				depth = d.depth(definition.selectionSet, visited) - 1
+				delete(visited, s.name)
+			}
+		case *inlineFragment:
+			depth = d.depth(s.selectionSet, visited) - 1
+		}
+		if depth > max {
+			max = depth
+		}
+	}
+	return max + 1
+}
+
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has multiple operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, item := range d.operations {
+		if item.name == name {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(definitions []*variableDefinition, values map[string]interface{}) (map[string]interface{}, error) {
+	variables := map[string]interface{}{}
+	for _, definition := range definitions {
+		value, ok := values[definition.name]
+		if !ok && definition.hasDefault {
+			value, ok = definition.defaultValue, true
+		}
+		if (!ok || value == nil) && strings.HasSuffix(definition.typ, "!") {
+			return nil, fmt.Errorf("variable $%v of type %v is required", definition.name, definition.typ)
+		}
+		if ok {
+			variables[definition.name] = value
+		}
+	}
+	return variables, nil
+}
+
+// orderedMap the response object keeps the order of the selections
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON ..
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type executor struct {
+	ctx       context.Context
+	schema    *Schema
+	doc       *document
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+func (e *executor) addError(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, &Error{Message: fmt.Sprintf(format, args...), Path: append([]interface{}{}, path...)})
+}
+
+// fieldGroup the fields of the same response key, whose selection sets are merged
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+func (e *executor) collectFields(object *Object, selections []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, item := range selections {
+		switch s := item.(type) {
+		case *field:
+			if !e.included(s.directives) {
+				continue
+			}
+			found := false
+			for _, group := range groups {
+				if group.key == s.responseKey() {
+					group.fields = append(group.fields, s)
+					found = true
+					break
+				}
+			}
+			if !found {
+				groups = append(groups, &fieldGroup{key: s.responseKey(), fields: []*field{s}})
+			}
+		case *fragmentSpread:
+			if !e.included(s.directives) || visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			definition, ok := e.doc.fragments[s.name]
+			if !ok {
+				e.addError(nil, "unknown fragment %q", s.name)
+				continue
+			}
+			if definition.typeCondition != object.Name {
+				continue
+			}
+			groups = e.collectFields(object, definition.selectionSet, groups, visited)
+		case *inlineFragment:
+			if !e.included(s.directives) || (s.typeCondition != "" && s.typeCondition != object.Name) {
+				continue
+			}
+			groups = e.collectFields(object, s.selectionSet, groups, visited)
+		}
+	}
+	return groups
+}
+
+// included evaluate the @include and @skip directives
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		if d.name != "include" && d.name != "skip" {
+			continue
+		}
+		for _, arg := range d.arguments {
+			if arg.name != "if" {
+				continue
+			}
+			value, _ := e.resolveValue(arg.value).(bool)
+			if (d.name == "include") != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (e *executor) executeSelectionSet(object *Object, source interface{}, selections []selection, path []interface{}) interface{} {
+	result := &orderedMap{values: map[string]interface{}{}}
+	for _, group := range e.collectFields(object, selections, nil, map[string]bool{}) {
+		first := group.fields[0]
+		fieldPath := append(append([]interface{}{}, path...), group.key)
+		if first.name == "__typename" {
+			result.set(group.key, object.Name)
+			continue
+		}
+		definition, ok := object.Fields[first.name]
+		if !ok {
+			e.addError(fieldPath, "cannot query field %q on type %q", first.name, object.Name)
+			result.set(group.key, nil)
+			continue
+		}
+		selectionSet := []selection{}
+		for _, item := range group.fields {
+			selectionSet = append(selectionSet, item.selectionSet...)
+		}
+		if definition.Type == nil && len(selectionSet) > 0 {
+			e.addError(fieldPath, "field %q of type %q must not have a selection", first.name, object.Name)
+			result.set(group.key, nil)
+			continue
+		}
+		if definition.Type != nil && len(selectionSet) == 0 {
+			e.addError(fieldPath, "field %q of type %q must have a selection of subfields", first.name, object.Name)
+			result.set(group.key, nil)
+			continue
+		}
+		args, err := e.coerceArguments(definition, first.arguments)
+		if err != nil {
+			e.addError(fieldPath, "field %q: %v", first.name, err)
+			result.set(group.key, nil)
+			continue
+		}
+		value, err := e.resolve(definition, first.name, source, args)
+		if err != nil {
+			e.addError(fieldPath, "%v", err)
+			result.set(group.key, nil)
+			continue
+		}
+		result.set(group.key, e.complete(definition, value, selectionSet, fieldPath))
+	}
+	return result
+}
+
+func (e *executor) resolve(definition *Field, name string, source interface{}, args map[string]interface{}) (interface{}, error) {
+	if definition.Resolve != nil {
+		return definition.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+	}
+	return defaultResolve(source, name), nil
+}
+
+// complete serialize the resolved value, the lists are completed item by item
+func (e *executor) complete(definition *Field, value interface{}, selectionSet []selection, path []interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return nil
+		}
+		if definition.Type == nil {
+			rv = rv.Elem()
+			value = rv.Interface()
+			continue
+		}
+		break
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		items := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			itemPath := append(append([]interface{}{}, path...), i)
+			items = append(items, e.complete(definition, rv.Index(i).Interface(), selectionSet, itemPath))
+		}
+		return items
+	}
+	if definition.Type == nil {
+		return value
+	}
+	return e.executeSelectionSet(definition.Type, value, selectionSet, path)
+}
+
+func (e *executor) resolveValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case variable:
+		return e.variables[string(v)]
+	case enumValue:
+		return string(v)
+	case []interface{}:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, e.resolveValue(item))
+		}
+		return items
+	case map[string]interface{}:
+		items := map[string]interface{}{}
+		for key, item := range v {
+			items[key] = e.resolveValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+func (e *executor) coerceArguments(definition *Field, arguments []*argument) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, arg := range arguments {
+		if _, ok := definition.Args[arg.name]; !ok {
+			return nil, fmt.Errorf("unknown argument %q", arg.name)
+		}
+	}
+	for name, spec := range definition.Args {
+		var value interface{}
+		found := false
+		for _, arg := range arguments {
+			if arg.name == name {
+				value, found = e.resolveValue(arg.value), true
+				if v, ok := arg.value.(variable); ok {
+					_, found = e.variables[string(v)]
+				}
+			}
+		}
+		if !found || value == nil {
+			if spec.Default != nil {
+				args[name] = spec.Default
+				continue
+			}
+			if strings.HasSuffix(spec.Type, "!") {
+				return nil, fmt.Errorf("argument %q of type %v is required", name, spec.Type)
+			}
+			continue
+		}
+		coerced, err := coerceScalar(strings.TrimSuffix(spec.Type, "!"), value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", name, err)
+		}
+		args[name] = coerced
+	}
+	return args, nil
+}
+
+// coerceScalar coerce the literal or the json variable to the scalar type, Int is int64 and Float is float64
+func coerceScalar(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "Int":
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int64(v), nil
+			}
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				return n, nil
+			}
+		}
+	case "Float":
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case json.Number:
+			if n, err := v.Float64(); err == nil {
+				return n, nil
+			}
+		}
+	case "String", "ID":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case int64:
+			if typ == "ID" {
+				return fmt.Sprint(v), nil
+			}
+		}
+	case "Boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported argument type %v", typ)
+	}
+	return nil, fmt.Errorf("expected %v, got %v", typ, value)
+}
+
+var jsonFieldCache sync.Map
+
+// defaultResolve read the json tagged field of struct or the key of map
+func defaultResolve(source interface{}, name string) interface{} {
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[name]
+	}
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	key := rv.Type().String() + "." + name
+	index, ok := jsonFieldCache.Load(key)
+	if !ok {
+		index = jsonFieldIndex(rv.Type(), name)
+		jsonFieldCache.Store(key, index)
+	}
+	if index.([]int) == nil {
+		return nil
+	}
+	fv, err := rv.FieldByIndexErr(index.([]int))
+	if err != nil {
+		return nil
+	}
+	return fv.Interface()
+}
+
+func jsonFieldIndex(t reflect.Type, name string) []int {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if index := jsonFieldIndex(f.Type, name); index != nil {
+				return append([]int{i}, index...)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == name || (tag == "" && f.Name == name) {
+			return []int{i}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+type testAddons struct {
+	ID int64 `json:"id"`
+}
+
+type testJob struct {
+	testAddons
+	Status string `json:"status"`
+}
+
+type testPublish struct {
+	testAddons
+	Name string     `json:"name"`
+	Jobs []*testJob `json:"-"`
+}
+
+func testSchema() *Schema {
+	job := &Object{Name: "Job", Fields: map[string]*Field{
+		"id":     {},
+		"status": {},
+	}}
+	publish := &Object{Name: "Publish", Fields: map[string]*Field{
+		"id":   {},
+		"name": {},
+		"jobs": {
+			Type: job,
+			Args: map[string]*Argument{"first": {Type: "Int", Default: int64(10)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				jobs := p.Source.(*testPublish).Jobs
+				if first := int(p.Args["first"].(int64)); first < len(jobs) {
+					jobs = jobs[:first]
+				}
+				return jobs, nil
+			},
+		},
+	}}
+	publishes := map[int64]*testPublish{
+		1: {testAddons: testAddons{ID: 1}, Name: "v1.0", Jobs: []*testJob{
+			{testAddons: testAddons{ID: 11}, Status: "SUCCESS"},
+			{testAddons: testAddons{ID: 12}, Status: "FAILED"},
+		}},
+	}
+	query := &Object{Name: "Query", Fields: map[string]*Field{
+		"publish": {
+			Type: publish,
+			Args: map[string]*Argument{"id": {Type: "Int!"}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				item, ok := publishes[p.Args["id"].(int64)]
+				if !ok {
+					return nil, fmt.Errorf("publish not found")
+				}
+				return item, nil
+			},
+		},
+	}}
+	return &Schema{Query: query, MaxDepth: 3}
+}
+
+func doJSON(t *testing.T, params Params) (string, *Result) {
+	result := Do(context.Background(), testSchema(), params)
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), result
+}
+
+func TestDo(t *testing.T) {
+	cases := []struct {
+		name   string
+		params Params
+		expect string
+	}{
+		{
+			name:   "nested with alias and argument",
+			params: Params{Query: `{ p: publish(id: 1) { name jobs(first: 1) { id status } } }`},
+			expect: `{"data":{"p":{"name":"v1.0","jobs":[{"id":11,"status":"SUCCESS"}]}}}`,
+		},
+		{
+			name: "variables and fragments",
+			params: Params{
+				Query:     `query Q($id: Int!, $withJobs: Boolean = true) { publish(id: $id) { ...P jobs @include(if: $withJobs) { ... on Job { status } } } } fragment P on Publish { id __typename }`,
+				Variables: map[string]interface{}{"id": float64(1)},
+			},
+			expect: `{"data":{"publish":{"id":1,"__typename":"Publish","jobs":[{"status":"SUCCESS"},{"status":"FAILED"}]}}}`,
+		},
+		{
+			name:   "resolver error",
+			params: Params{Query: `{ publish(id: 2) { id } }`},
+			expect: `{"data":{"publish":null},"errors":[{"message":"publish not found","path":["publish"]}]}`,
+		},
+		{
+			name:   "unknown field",
+			params: Params{Query: `{ publish(id: 1) { version } }`},
+			expect: `{"data":{"publish":{"version":null}},"errors":[{"message":"cannot query field \"version\" on type \"Publish\"","path":["publish","version"]}]}`,
+		},
+		{
+			name:   "required argument",
+			params: Params{Query: `{ publish { id } }`},
+			expect: `{"data":{"publish":null},"errors":[{"message":"field \"publish\": argument \"id\" of type Int! is required","path":["publish"]}]}`,
+		},
+	}
+	for _, c := range cases {
+		got, _ := doJSON(t, c.params)
+		if got != c.expect {
+			t.Errorf("%v: expect %v, got %v", c.name, c.expect, got)
+		}
+	}
+}
+
+func TestDoRequestError(t *testing.T) {
+	for _, query := range []string{
+		`{ publish(id: 1) { id }`,
+		`mutation { publish(id: 1) { id } }`,
+		`query A { publish(id: 1) { id } } query B { publish(id: 1) { id } }`,
+		`query($id: Int!) { publish(id: $id) { id } }`,
+	} {
+		_, result := doJSON(t, Params{Query: query})
+		if result.Data != nil || !result.HasErrors() {
+			t.Errorf("expect request error of %v, got %+v", query, result)
+		}
+	}
+}
+
+func TestDoMaxDepth(t *testing.T) {
+	schema := testSchema()
+	schema.MaxDepth = 2
+	query := `{ publish(id: 1) { ...P } } fragment P on Publish { jobs { ... on Job { status } } }`
+	result := Do(context.Background(), schema, Params{Query: query})
+	if result.Data != nil || len(result.Errors) != 1 || result.Errors[0].Message != "the query depth 3 exceeds the max depth 2" {
+		t.Errorf("unexpected result: %+v", result.Errors)
+	}
+}
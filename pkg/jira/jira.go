@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/tlsutil"
+)
+
+// issueKeyRegexp jira issue key, eg: PROJ-123
+var issueKeyRegexp = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// Client a tiny Jira REST API client
+type Client struct {
+	URL        string
+	User       string
+	Token      string
+	httpClient *http.Client
+}
+
+// NewClient the client uses the dedicated transport of the tls config of setting, nil means the default tls config
+func NewClient(addr, user, token string, tlsConfig *tls.Config) *Client {
+	return &Client{
+		URL:   strings.TrimSuffix(addr, "/"),
+		User:  user,
+		Token: token,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: tlsutil.Transport(nil, tlsConfig),
+		},
+	}
+}
+
+// Transition ..
+type Transition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// ParseIssueKeys return the distinct issue keys mentioned in text, keep the order of appearance
+func ParseIssueKeys(text string) []string {
+	keys := []string{}
+	exists := map[string]bool{}
+	for _, key := range issueKeyRegexp.FindAllString(text, -1) {
+		if exists[key] {
+			continue
+		}
+		exists[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Version return the jira server version
+func (c *Client) Version() (string, error) {
+	rsp := struct {
+		Version string `json:"version"`
+	}{}
+	if err := c.do(http.MethodGet, "/rest/api/2/serverInfo", nil, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.Version, nil
+}
+
+// GetTransitions return the available transitions of issue
+func (c *Client) GetTransitions(key string) ([]*Transition, error) {
+	rsp := struct {
+		Transitions []*Transition `json:"transitions"`
+	}{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/rest/api/2/issue/%s/transitions", url.PathEscape(key)), nil, &rsp); err != nil {
+		return nil, err
+	}
+	return rsp.Transitions, nil
+}
+
+// DoTransition transition the issue by transition name or target status name
+func (c *Client) DoTransition(key, name string) error {
+	transitions, err := c.GetTransitions(key)
+	if err != nil {
+		return err
+	}
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.Name, name) || strings.EqualFold(transition.To.Name, name) {
+			body := map[string]interface{}{
+				"transition": map[string]string{"id": transition.ID},
+			}
+			return c.do(http.MethodPost, fmt.Sprintf("/rest/api/2/issue/%s/transitions", url.PathEscape(key)), body, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no available transition: %s", key, name)
+}
+
+func (c *Client) do(method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, c.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.User, c.Token)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request jira %s occur error: %s", path, err.Error())
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("request jira %s failed, status code: %d, response: %s", path, res.StatusCode, content)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
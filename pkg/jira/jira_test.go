@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIssueKeys(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"fix login error", []string{}},
+		{"PROJ-12 fix login error", []string{"PROJ-12"}},
+		{"[OPS-1] merge PROJ-12, PROJ-12 and ab-3", []string{"OPS-1", "PROJ-12"}},
+		{"utf-8 PROJ-0 HTTP2-404", []string{"HTTP2-404"}},
+	}
+	for _, tt := range tests {
+		if got := ParseIssueKeys(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIssueKeys(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
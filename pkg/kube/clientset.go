@@ -20,7 +20,6 @@ import (
 	"github.com/go-atomci/atomci/internal/core/settings"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 func GetClientset(cluster string) (client kubernetes.Interface, cfg *rest.Config, err error) {
@@ -34,21 +33,10 @@ func GetClientset(cluster string) (client kubernetes.Interface, cfg *rest.Config
 }
 
 func buildK8sClient(kube *settings.KubeConfig) (client kubernetes.Interface, cfg *rest.Config, err error) {
-	var k8sConfig *rest.Config
-	switch kube.Type {
-	case settings.KubernetesConfig:
-		k8sConfig, err = clientcmd.RESTConfigFromKubeConfig([]byte(kube.Conf))
-		if err != nil {
-			return nil, nil, err
-		}
-	case settings.KubernetesToken:
-		k8sConfig = &rest.Config{
-			BearerToken:     kube.Conf,
-			TLSClientConfig: rest.TLSClientConfig{Insecure: true},
-			Host:            kube.URL,
-		}
+	k8sConfig, err := kube.RESTConfig()
+	if err != nil {
+		return nil, nil, err
 	}
-
 	clientSet, err := kubernetes.NewForConfig(k8sConfig)
 	return clientSet, k8sConfig, err
 }
@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+// the metrics of pipelines and the health of atomci, the project label is the project id
+var (
+	BuildsStarted   = NewCounterVec("atomci_builds_started_total", "The number of build jobs started.", "project_id")
+	BuildsSucceeded = NewCounterVec("atomci_builds_succeeded_total", "The number of build jobs succeeded.", "project_id")
+	BuildsFailed    = NewCounterVec("atomci_builds_failed_total", "The number of build jobs failed.", "project_id")
+
+	// StepDuration the duration of the finished publish jobs, the step is the job type, eg: build/deploy/e2e-test
+	StepDuration = NewHistogramVec("atomci_step_duration_seconds", "The duration of the finished pipeline step jobs in seconds.",
+		[]float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600}, "step", "status")
+
+	JenkinsPingFailures = NewCounterVec("atomci_jenkins_ping_failures_total", "The number of failed pings to the jenkins servers.", "server")
+	JenkinsUp           = NewGaugeVec("atomci_jenkins_up", "Whether the last ping to the jenkins server succeeded.", "server")
+
+	// DeployRollbacks the result is success or failed
+	DeployRollbacks = NewCounterVec("atomci_deploy_rollbacks_total", "The number of rollbacks of the failed deploy jobs.", "project_id", "result")
+
+	// RateLimitRejected the limit is ip, token or login
+	RateLimitRejected = NewCounterVec("atomci_ratelimit_rejected_total", "The number of api requests rejected by the rate limits.", "limit")
+)
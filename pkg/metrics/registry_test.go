@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	counter := NewCounterVec("test_jobs_total", "The jobs.", "project_id")
+	counter.Inc("2")
+	counter.Add(2, "1")
+	counter.Add(-1, "1")
+	gauge := NewGaugeVec("test_up", "The \"up\" state.", "server")
+	gauge.Set(1, `ci "main"`)
+	NewGaugeFunc("test_queue_depth", "The queue depth.", func() (float64, error) { return 3, nil })
+	histogram := NewHistogramVec("test_duration_seconds", "The duration.", []float64{60, 10}, "step")
+	histogram.Observe(5, "build")
+	histogram.Observe(30, "build")
+	histogram.Observe(120, "build")
+
+	buf := &bytes.Buffer{}
+	WriteTo(buf)
+	output := buf.String()
+	for _, want := range []string{
+		"# TYPE test_jobs_total counter\ntest_jobs_total{project_id=\"1\"} 2\ntest_jobs_total{project_id=\"2\"} 1\n",
+		"test_up{server=\"ci \\\"main\\\"\"} 1\n",
+		"# TYPE test_queue_depth gauge\ntest_queue_depth 3\n",
+		"test_duration_seconds_bucket{step=\"build\",le=\"10\"} 1\n",
+		"test_duration_seconds_bucket{step=\"build\",le=\"60\"} 2\n",
+		"test_duration_seconds_bucket{step=\"build\",le=\"+Inf\"} 3\n",
+		"test_duration_seconds_sum{step=\"build\"} 155\ntest_duration_seconds_count{step=\"build\"} 3\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("WriteTo() output missing %q, got:\n%s", want, output)
+		}
+	}
+}
@@ -60,7 +60,11 @@ func (email *Email) Send(result PushNotification) error {
 
 	m := gomail.NewMessage()
 	m.SetHeader("From", message.Mail.SmtpAccount)
-	m.SetHeader("To", message.Mail.SmtpAccount)
+	if len(result.Receivers) > 0 {
+		m.SetHeader("To", result.Receivers...)
+	} else {
+		m.SetHeader("To", message.Mail.SmtpAccount)
+	}
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
 
@@ -33,6 +33,9 @@ func Send(options PushNotification) {
 		StageName:     options.StageName,
 		StepName:      options.StepName,
 		Status:        options.Status,
+		Message:       options.Message,
+		Subject:       options.Subject,
+		Receivers:     options.Receivers,
 	}
 
 	handlers := NewHandlers(notify)
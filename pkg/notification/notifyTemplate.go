@@ -17,6 +17,10 @@ type DingRobotMarkdownTemplate struct{}
 func (temp *DingRobotMarkdownTemplate) GenSubject(buf *bytes.Buffer, m PushNotification) string {
 
 	buf.WriteString("## ")
+	if m.Subject != "" {
+		buf.WriteString(m.Subject)
+		return buf.String()
+	}
 	buf.WriteString(messages.StatusCodeToChinese(m.Status))
 
 	return buf.String()
@@ -29,6 +33,10 @@ func (temp *DingRobotMarkdownTemplate) GenContent(buf *bytes.Buffer, m PushNotif
 	buf.WriteString(m.StageName)
 	buf.WriteString("\r\n\r\n")
 	buf.WriteString(m.StepName)
+	if m.Message != "" {
+		buf.WriteString("\r\n\r\n")
+		buf.WriteString(m.Message)
+	}
 
 	return buf.String()
 }
@@ -44,6 +52,10 @@ type EmailTemplate struct{}
 
 func (temp *EmailTemplate) GenSubject(buf *bytes.Buffer, m PushNotification) string {
 
+	if m.Subject != "" {
+		buf.WriteString(m.Subject)
+		return buf.String()
+	}
 	buf.WriteString("流水线")
 	buf.WriteString(m.PublishName)
 	buf.WriteString("构建")
@@ -63,6 +75,11 @@ func (temp *EmailTemplate) GenContent(buf *bytes.Buffer, m PushNotification) str
 	buf.WriteString("</b></h2></p><p><h1>")
 	buf.WriteString(messages.StatusCodeToChinese(m.Status))
 	buf.WriteString("</h1>")
+	if m.Message != "" {
+		buf.WriteString("<p>")
+		buf.WriteString(m.Message)
+		buf.WriteString("</p>")
+	}
 
 	return buf.String()
 }
@@ -1,5 +1,7 @@
 package notification
 
+import "github.com/astaxie/beego"
+
 type PushNotification struct {
 	// dingtalk
 	DingURL    string
@@ -17,4 +19,31 @@ type PushNotification struct {
 	PublishName string
 	StepName    string
 	Status      int64
+	// Message the extra description, eg: the sla escalation
+	Message string
+	// Subject replace the subject generated by status, eg: the comment mentions
+	Subject string
+	// Receivers the email receivers, the smtp account is used when empty
+	Receivers []string
+}
+
+// NewPushNotification generate push notification options from the notification section of app config
+func NewPushNotification(status int64, publishName, stageName, stepName string) PushNotification {
+	smtpPort, _ := beego.AppConfig.Int("notification::smtpPort")
+	return PushNotification{
+		// message
+		Status:      status,
+		PublishName: publishName,
+		StageName:   stageName,
+		StepName:    stepName,
+		// dingding
+		DingURL:    beego.AppConfig.String("notification::ding"),
+		DingEnable: beego.AppConfig.DefaultBool("notification::dingEnable", false),
+		// email
+		EmailEnable:   beego.AppConfig.DefaultBool("notification::mailEnable", false),
+		EmailHost:     beego.AppConfig.String("notification::smtpHost"),
+		EmailPort:     smtpPort,
+		EmailUser:     beego.AppConfig.String("notification::smtpAccount"),
+		EmailPassword: beego.AppConfig.String("notification::smtpPassword"),
+	}
 }
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer serve the string commands from memory, the expiration is ignored
+func fakeServer(t *testing.T, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen occur error: %s", err.Error())
+	}
+	t.Cleanup(func() { listener.Close() })
+	var lock sync.Mutex
+	data := map[string]string{}
+	go func() {
+		for {
+			nc, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(nc net.Conn) {
+				defer nc.Close()
+				reader := bufio.NewReader(nc)
+				authed := password == ""
+				for {
+					reply, err := readReply(reader)
+					if err != nil {
+						return
+					}
+					args := []string{}
+					for _, item := range reply.([]interface{}) {
+						args = append(args, string(item.([]byte)))
+					}
+					lock.Lock()
+					switch cmd := strings.ToUpper(args[0]); {
+					case cmd == "AUTH":
+						authed = args[1] == password
+						fmt.Fprint(nc, "+OK\r\n")
+					case !authed:
+						fmt.Fprint(nc, "-NOAUTH Authentication required.\r\n")
+					case cmd == "GET":
+						if value, ok := data[args[1]]; ok {
+							fmt.Fprintf(nc, "$%d\r\n%s\r\n", len(value), value)
+						} else {
+							fmt.Fprint(nc, "$-1\r\n")
+						}
+					case cmd == "SET":
+						data[args[1]] = args[2]
+						fmt.Fprint(nc, "+OK\r\n")
+					case cmd == "DEL":
+						count := 0
+						for _, key := range args[1:] {
+							if _, ok := data[key]; ok {
+								delete(data, key)
+								count++
+							}
+						}
+						fmt.Fprintf(nc, ":%d\r\n", count)
+					default:
+						fmt.Fprintf(nc, "-ERR unknown command '%s'\r\n", args[0])
+					}
+					lock.Unlock()
+				}
+			}(nc)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	client := NewClient(fakeServer(t, "secret"), "secret", 0, time.Second)
+	defer client.Close()
+
+	if value, err := client.Get("missing"); err != nil || value != nil {
+		t.Fatalf("Get(missing) = %v, %v", value, err)
+	}
+	if err := client.Set("key", []byte("a\r\nb"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := client.Get("key"); err != nil || string(value) != "a\r\nb" {
+		t.Fatalf("Get(key) = %q, %v", value, err)
+	}
+	if err := client.Del("key", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := client.Get("key"); err != nil || value != nil {
+		t.Fatalf("Get(key) after Del = %v, %v", value, err)
+	}
+	// the error reply keeps the connection usable
+	if _, err := client.Do("UNKNOWN"); err == nil {
+		t.Fatal("expected error reply")
+	} else if _, ok := err.(Error); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Get("key"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientAuthFailed(t *testing.T) {
+	client := NewClient(fakeServer(t, "secret"), "", 0, time.Second)
+	defer client.Close()
+	if _, err := client.Get("key"); err == nil {
+		t.Fatal("expected NOAUTH error")
+	}
+}
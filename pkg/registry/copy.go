@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// manifest media types which can be copied
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestAccept = strings.Join([]string{
+	mediaTypeDockerManifestList, mediaTypeOCIIndex, mediaTypeDockerManifest, mediaTypeOCIManifest,
+}, ", ")
+
+// manifest the fields of image manifest and manifest list used to copy image
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    *descriptor  `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Copy copy the image from src registry to dst registry by the registry api directly, eg: crane copy,
+// the blobs are mounted instead of transferred when both repositories are in the same registry.
+// it return the digest of the copied manifest.
+func Copy(src Provider, srcRepo, srcRef string, dst Provider, dstRepo, dstRef string) (string, error) {
+	srcClient, ok := src.(*v2Client)
+	if !ok {
+		return "", fmt.Errorf("source registry did not support image copy")
+	}
+	dstClient, ok := dst.(*v2Client)
+	if !ok {
+		return "", fmt.Errorf("target registry did not support image copy")
+	}
+	c := &copier{
+		src:     srcClient,
+		dst:     dstClient,
+		srcRepo: srcClient.repository(srcRepo),
+		dstRepo: dstClient.repository(dstRepo),
+	}
+	return c.copyManifest(srcRef, dstRef)
+}
+
+type copier struct {
+	src, dst         *v2Client
+	srcRepo, dstRepo string
+}
+
+func (c *copier) pullScope() string {
+	return "repository:" + c.srcRepo + ":pull"
+}
+
+func (c *copier) pushScope() string {
+	scope := "repository:" + c.dstRepo + ":pull,push"
+	if c.src.URL == c.dst.URL && c.srcRepo != c.dstRepo {
+		scope += " " + c.pullScope()
+	}
+	return scope
+}
+
+// copyManifest copy the manifest referenced by srcRef and its blobs, the child manifests of manifest list are copied by digest
+func (c *copier) copyManifest(srcRef, dstRef string) (string, error) {
+	body, mediaType, err := c.getManifest(srcRef)
+	if err != nil {
+		return "", err
+	}
+	m := manifest{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", fmt.Errorf("parse manifest of %v:%v occur error: %s", c.srcRepo, srcRef, err.Error())
+	}
+	if mediaType == "" {
+		mediaType = m.MediaType
+	}
+
+	switch mediaType {
+	case mediaTypeDockerManifestList, mediaTypeOCIIndex:
+		for _, child := range m.Manifests {
+			if _, err := c.copyManifest(child.Digest, child.Digest); err != nil {
+				return "", err
+			}
+		}
+	case mediaTypeDockerManifest, mediaTypeOCIManifest:
+		blobs := append([]descriptor{}, m.Layers...)
+		if m.Config != nil {
+			blobs = append(blobs, *m.Config)
+		}
+		for _, blob := range blobs {
+			if err := c.copyBlob(blob); err != nil {
+				return "", err
+			}
+		}
+	default:
+		return "", fmt.Errorf("manifest media type: %v of %v:%v is not supported", mediaType, c.srcRepo, srcRef)
+	}
+	return c.putManifest(dstRef, mediaType, body)
+}
+
+func (c *copier) getManifest(ref string) ([]byte, string, error) {
+	body, mediaType, _, err := c.src.getManifest(c.srcRepo, ref)
+	return body, mediaType, err
+}
+
+func (c *copier) putManifest(ref, mediaType string, body []byte) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", mediaType)
+	rsp, err := c.dst.request(http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", c.dstRepo, ref), c.pushScope(), header, func() io.Reader {
+		return bytes.NewReader(body)
+	})
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusCreated && rsp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(rsp.Body)
+		return "", fmt.Errorf("put manifest %v:%v return status %v: %s", c.dstRepo, ref, rsp.StatusCode, string(msg))
+	}
+	if digest := rsp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+}
+
+// copyBlob skip the blob exists in target, otherwise mount or upload it
+func (c *copier) copyBlob(blob descriptor) error {
+	rsp, err := c.dst.request(http.MethodHead, fmt.Sprintf("/v2/%s/blobs/%s", c.dstRepo, blob.Digest), c.pushScope(), nil, nil)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	uploadPath := fmt.Sprintf("/v2/%s/blobs/uploads/", c.dstRepo)
+	if c.src.URL == c.dst.URL {
+		uploadPath += "?" + url.Values{"mount": {blob.Digest}, "from": {c.srcRepo}}.Encode()
+	}
+	rsp, err = c.dst.request(http.MethodPost, uploadPath, c.pushScope(), nil, nil)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	switch rsp.StatusCode {
+	case http.StatusCreated:
+		// mounted
+		return nil
+	case http.StatusAccepted:
+	default:
+		return fmt.Errorf("start upload blob %v to %v return status %v", blob.Digest, c.dstRepo, rsp.StatusCode)
+	}
+	location, err := c.uploadLocation(rsp.Header.Get("Location"), blob.Digest)
+	if err != nil {
+		return err
+	}
+
+	blobRsp, err := c.src.request(http.MethodGet, fmt.Sprintf("/v2/%s/blobs/%s", c.srcRepo, blob.Digest), c.pullScope(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer blobRsp.Body.Close()
+	if blobRsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get blob %v of %v return status %v", blob.Digest, c.srcRepo, blobRsp.StatusCode)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	if blobRsp.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(blobRsp.ContentLength, 10))
+	}
+	// the blob stream can be read only once, the upload was authorized by the previous request of same scope
+	rsp, err = c.dst.request(http.MethodPut, location, c.pushScope(), header, func() io.Reader {
+		return blobRsp.Body
+	})
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusCreated {
+		msg, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("upload blob %v to %v return status %v: %s", blob.Digest, c.dstRepo, rsp.StatusCode, string(msg))
+	}
+	return nil
+}
+
+// uploadLocation return the absolute upload url with the blob digest, the location may be relative
+func (c *copier) uploadLocation(location, digest string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("registry did not return blob upload location")
+	}
+	base, err := url.Parse(c.dst.URL + "/")
+	if err != nil {
+		return "", err
+	}
+	u, err := base.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("digest", digest)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
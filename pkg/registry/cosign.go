@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// cosignSignatureAnnotation the annotation of signature layer which holds the base64 signature of the layer
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// maxSignaturePayload the simple signing payload is small json, the larger blob is not a signature
+	maxSignaturePayload = 1 << 20
+)
+
+// Signature the cosign signature attached to the image, Payload is the simple signing json which was signed
+type Signature struct {
+	Payload   []byte
+	Signature string
+}
+
+// cosignSignatureTag the tag which cosign stores the signatures of image digest, eg: sha256-abc.sig
+func cosignSignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// Signatures ..
+func (c *v2Client) Signatures(repo, digest string) ([]*Signature, error) {
+	repo = c.repository(repo)
+	scope := "repository:" + repo + ":pull"
+	header := http.Header{}
+	header.Set("Accept", manifestAccept)
+	rsp, err := c.request(http.MethodGet, fmt.Sprintf("/v2/%s/manifests/%s", repo, cosignSignatureTag(digest)), scope, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// the image is not signed
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("get signatures of %v@%v return status %v: %s", repo, digest, rsp.StatusCode, string(body))
+	}
+	m := struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, err
+	}
+	signatures := []*Signature{}
+	for _, layer := range m.Layers {
+		signature := layer.Annotations[cosignSignatureAnnotation]
+		if signature == "" {
+			continue
+		}
+		rsp, err := c.get(fmt.Sprintf("/v2/%s/blobs/%s", repo, layer.Digest), scope)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxSignaturePayload))
+		rsp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if rsp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("get signature payload %v of %v return status %v", layer.Digest, repo, rsp.StatusCode)
+		}
+		signatures = append(signatures, &Signature{Payload: payload, Signature: signature})
+	}
+	return signatures, nil
+}
+
+// ParsePublicKey parse the pem encoded public key generated by `cosign generate-key-pair`
+func ParsePublicKey(publicKey string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(publicKey)))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not pem encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("public key type %T is not supported", key)
+	}
+}
+
+// VerifySignatures verify the image digest was signed by the private key of public key,
+// it succeeds when any of the signatures is valid and its payload references the digest.
+func VerifySignatures(publicKey crypto.PublicKey, digest string, signatures []*Signature) error {
+	if len(signatures) == 0 {
+		return fmt.Errorf("no signature of %v found", digest)
+	}
+	var lastErr error
+	for _, signature := range signatures {
+		if lastErr = verifySignature(publicKey, digest, signature); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func verifySignature(publicKey crypto.PublicKey, digest string, signature *Signature) error {
+	sig, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64 encoded: %v", err)
+	}
+	hash := sha256.Sum256(signature.Payload)
+	valid := false
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, hash[:], sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, signature.Payload, sig)
+	}
+	if !valid {
+		return fmt.Errorf("signature of %v is invalid", digest)
+	}
+	payload := struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}{}
+	if err := json.Unmarshal(signature.Payload, &payload); err != nil {
+		return fmt.Errorf("signature payload is invalid: %v", err)
+	}
+	if payload.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature was signed for %v instead of %v", payload.Critical.Image.DockerManifestDigest, digest)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ecrService       = "ecr"
+	ecrTarget        = "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"
+	ecrRefreshBefore = 30 * time.Minute
+)
+
+// ecrHostRegexp eg: 123456789012.dkr.ecr.us-east-1.amazonaws.com
+var ecrHostRegexp = regexp.MustCompile(`\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com`)
+
+func ecrRegion(addr string) string {
+	if match := ecrHostRegexp.FindStringSubmatch(addr); len(match) == 2 {
+		return match[1]
+	}
+	return ""
+}
+
+// ecrTokenSource exchange the aws access key for the registry password which lives 12 hours,
+// the password was cached and refreshed before it expired.
+type ecrTokenSource struct {
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	httpClient      *http.Client
+
+	mu        sync.Mutex
+	user      string
+	password  string
+	expiresAt time.Time
+}
+
+func newECRTokenSource(region, accessKeyID, secretAccessKey string) *ecrTokenSource {
+	return &ecrTokenSource{
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Credential ..
+func (s *ecrTokenSource) Credential() (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.password != "" && time.Until(s.expiresAt) > ecrRefreshBefore {
+		return s.user, s.password, nil
+	}
+
+	body := []byte("{}")
+	endpoint := fmt.Sprintf("https://api.ecr.%s.amazonaws.com/", s.region)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", ecrTarget)
+	s.sign(req, body, time.Now().UTC())
+
+	rsp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer rsp.Body.Close()
+	rspBody, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return "", "", err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("get ecr authorization token return status %v: %s", rsp.StatusCode, string(rspBody))
+	}
+	result := struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}{}
+	if err := json.Unmarshal(rspBody, &result); err != nil {
+		return "", "", err
+	}
+	if len(result.AuthorizationData) == 0 {
+		return "", "", fmt.Errorf("ecr did not return authorization data")
+	}
+	data := result.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(data.AuthorizationToken)
+	if err != nil {
+		return "", "", err
+	}
+	pair := strings.SplitN(string(decoded), ":", 2)
+	if len(pair) != 2 {
+		return "", "", fmt.Errorf("invalid ecr authorization token")
+	}
+	s.user, s.password = pair[0], pair[1]
+	s.expiresAt = time.Unix(int64(data.ExpiresAt), 0)
+	return s.user, s.password, nil
+}
+
+// sign the request by aws signature version 4
+func (s *ecrTokenSource) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-date:%s\nx-amz-target:%s\n",
+		req.Header.Get("Content-Type"), req.URL.Host, amzDate, req.Header.Get("X-Amz-Target"))
+	canonicalRequest := strings.Join([]string{
+		req.Method, "/", "", canonicalHeaders, signedHeaders, hexSHA256(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, s.region, ecrService)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, ecrService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
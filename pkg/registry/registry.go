@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// registry provider types, selected by the type of registry integrate setting
+const (
+	TypeGeneric   = "generic"
+	TypeHarbor    = "harbor"
+	TypeDockerHub = "dockerhub"
+	TypeACR       = "acr"
+	TypeECR       = "ecr"
+	TypeGCR       = "gcr"
+)
+
+const (
+	dockerHubAPIURL = "https://registry-1.docker.io"
+	// gcrJSONKeyUser gcr/artifact registry accept the service account json key as password of this user
+	gcrJSONKeyUser = "_json_key"
+)
+
+// Provider container registry provider
+type Provider interface {
+	// Credential return the username and password used by docker login and image pull secret
+	Credential() (user, password string, err error)
+	// Ping verify the registry is reachable and the credential is valid
+	Ping() error
+	// RepositoryExists check the image repository exists, repo is the path without registry host
+	RepositoryExists(repo string) (bool, error)
+	// ListTags return the tags of the image repository
+	ListTags(repo string) ([]string, error)
+	// ManifestDigest return the manifest digest of tag, it fails when the image does not exist
+	ManifestDigest(repo, tag string) (string, error)
+	// TagInfo return the manifest digest and image created time of tag
+	TagInfo(repo, tag string) (*TagInfo, error)
+	// DeleteManifest delete the manifest by digest, all the tags reference it are deleted
+	DeleteManifest(repo, digest string) error
+	// Signatures return the cosign signatures attached to the manifest digest, empty when the image is not signed
+	Signatures(repo, digest string) ([]*Signature, error)
+}
+
+// TagInfo ..
+type TagInfo struct {
+	Tag     string    `json:"tag"`
+	Digest  string    `json:"digest"`
+	Created time.Time `json:"created"`
+}
+
+// Options ..
+type Options struct {
+	Type     string
+	URL      string
+	User     string
+	Password string
+	// Region aws region of ecr, parsed from url when empty
+	Region string
+	// Insecure access the registry by http
+	Insecure bool
+	// TLS the tls config of the registry which uses the private CA, nil means the default
+	TLS *tls.Config
+}
+
+// New return the registry provider by type, the generic docker registry v2 provider is used by default
+func New(opts Options) (Provider, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("registry url is required")
+	}
+	switch strings.ToLower(opts.Type) {
+	case "", TypeGeneric, TypeHarbor, TypeACR:
+		return newV2Client(apiURL(opts.URL, opts.Insecure), staticCredential(opts.User, opts.Password)).withTLS(opts.TLS), nil
+	case TypeDockerHub:
+		client := newV2Client(dockerHubAPIURL, staticCredential(opts.User, opts.Password))
+		client.officialLibrary = true
+		return client, nil
+	case TypeGCR:
+		user := opts.User
+		if user == "" {
+			user = gcrJSONKeyUser
+		}
+		return newV2Client(apiURL(opts.URL, false), staticCredential(user, opts.Password)).withTLS(opts.TLS), nil
+	case TypeECR:
+		region := opts.Region
+		if region == "" {
+			region = ecrRegion(opts.URL)
+		}
+		if region == "" {
+			return nil, fmt.Errorf("can not parse aws region from ecr url: %v", opts.URL)
+		}
+		source := newECRTokenSource(region, opts.User, opts.Password)
+		return newV2Client(apiURL(opts.URL, false), source.Credential), nil
+	default:
+		return nil, fmt.Errorf("registry type: %v is not supported", opts.Type)
+	}
+}
+
+// apiURL return the registry api address with schema
+func apiURL(addr string, insecure bool) string {
+	addr = strings.TrimRight(strings.TrimSpace(addr), "/")
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return addr
+	}
+	// the url may be configured with project/namespace path, eg: harbor.example.com/library
+	host := strings.SplitN(addr, "/", 2)[0]
+	if insecure {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+func staticCredential(user, password string) func() (string, string, error) {
+	return func() (string, string, error) {
+		return user, password, nil
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEcrRegion(t *testing.T) {
+	if got := ecrRegion("123456789012.dkr.ecr.us-east-1.amazonaws.com/atomci"); got != "us-east-1" {
+		t.Errorf("got region %q, want us-east-1", got)
+	}
+	if got := ecrRegion("harbor.example.com"); got != "" {
+		t.Errorf("got region %q, want empty", got)
+	}
+}
+
+func TestListTagsWithBearerChallenge(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:library/nginx:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token":"t1"}`)
+		case "/v2/library/nginx/tags/list":
+			if r.Header.Get("Authorization") != "Bearer t1" {
+				w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/library/nginx/tags/list?last=v1&n=1>; rel="next"`)
+				fmt.Fprint(w, `{"tags":["v1"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"tags":["v2"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := newV2Client(server.URL, staticCredential("admin", "secret"))
+	client.officialLibrary = true
+	tags, err := client.ListTags("nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != "v1" || tags[1] != "v2" {
+		t.Errorf("got tags %v, want [v1 v2]", tags)
+	}
+
+	exists, err := client.RepositoryExists("atomci/missing")
+	if err != nil || exists {
+		t.Errorf("got exists %v, err %v, want repository missing", exists, err)
+	}
+}
+
+// fakeRegistry in memory registry without auth, blobs and manifests are keyed by repo and digest/tag
+type fakeRegistry struct {
+	blobs     map[string][]byte
+	manifests map[string][]byte
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/upload/"):
+		body, _ := ioutil.ReadAll(r.Body)
+		f.blobs[strings.TrimPrefix(r.URL.Path, "/upload/")+"@"+r.URL.Query().Get("digest")] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasSuffix(path, "/blobs/uploads/"):
+		w.Header().Set("Location", "/upload/"+strings.TrimSuffix(path, "/blobs/uploads/"))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/"):
+		parts := strings.SplitN(path, "/blobs/", 2)
+		blob, ok := f.blobs[parts[0]+"@"+parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(blob)
+	case strings.Contains(path, "/manifests/"):
+		parts := strings.SplitN(path, "/manifests/", 2)
+		key := parts[0] + ":" + parts[1]
+		if r.Method == http.MethodPut {
+			body, _ := ioutil.ReadAll(r.Body)
+			f.manifests[key] = body
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		manifest, ok := f.manifests[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", mediaTypeDockerManifest)
+		w.Write(manifest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"digest":"sha256:c1"},"layers":[{"digest":"sha256:l1"}]}`, mediaTypeDockerManifest)
+	src := &fakeRegistry{
+		blobs:     map[string][]byte{"dev/app@sha256:c1": []byte("config"), "dev/app@sha256:l1": []byte("layer")},
+		manifests: map[string][]byte{"dev/app:v1": []byte(manifest)},
+	}
+	dst := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	srcServer, dstServer := httptest.NewServer(src), httptest.NewServer(dst)
+	defer srcServer.Close()
+	defer dstServer.Close()
+
+	digest, err := Copy(newV2Client(srcServer.URL, staticCredential("", "")), "dev/app", "v1",
+		newV2Client(dstServer.URL, staticCredential("", "")), "prod/app", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest))); digest != want {
+		t.Errorf("got digest %v, want %v", digest, want)
+	}
+	if string(dst.blobs["prod/app@sha256:l1"]) != "layer" || string(dst.blobs["prod/app@sha256:c1"]) != "config" {
+		t.Errorf("blobs were not copied: %v", dst.blobs)
+	}
+	if string(dst.manifests["prod/app:v1"]) != manifest {
+		t.Errorf("manifest was not copied: %v", dst.manifests)
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey, err := ParsePublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := "sha256:abc"
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"dev/app"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`, digest))
+	hash := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"layers":[{"digest":"sha256:p1","annotations":{"%s":"%s"}}]}`,
+		cosignSignatureAnnotation, base64.StdEncoding.EncodeToString(sig))
+	server := httptest.NewServer(&fakeRegistry{
+		blobs:     map[string][]byte{"dev/app@sha256:p1": payload},
+		manifests: map[string][]byte{"dev/app:sha256-abc.sig": []byte(manifest)},
+	})
+	defer server.Close()
+	client := newV2Client(server.URL, staticCredential("", ""))
+
+	signatures, err := client.Signatures("dev/app", digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignatures(publicKey, digest, signatures); err != nil {
+		t.Errorf("valid signature was rejected: %v", err)
+	}
+	if err := VerifySignatures(publicKey, "sha256:other", signatures); err == nil {
+		t.Error("signature of another digest was accepted")
+	}
+
+	unsigned, err := client.Signatures("dev/app", "sha256:unsigned")
+	if err != nil || len(unsigned) != 0 {
+		t.Errorf("got signatures %v, err %v, want unsigned", unsigned, err)
+	}
+	if err := VerifySignatures(publicKey, "sha256:unsigned", unsigned); err == nil {
+		t.Error("unsigned image was accepted")
+	}
+}
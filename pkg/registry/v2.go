@@ -0,0 +1,377 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-atomci/atomci/pkg/tlsutil"
+)
+
+// v2Client docker registry http api v2 client, works with harbor, docker hub and the cloud registries
+type v2Client struct {
+	URL        string
+	credential func() (string, string, error)
+	// officialLibrary the repo without namespace belongs to `library`, eg: docker hub
+	officialLibrary bool
+	httpClient      *http.Client
+
+	mu             sync.Mutex
+	authorizations map[string]string
+}
+
+func newV2Client(apiURL string, credential func() (string, string, error)) *v2Client {
+	return &v2Client{
+		URL:            apiURL,
+		credential:     credential,
+		httpClient:     &http.Client{Transport: newV2Transport(nil)},
+		authorizations: map[string]string{},
+	}
+}
+
+// newV2Transport the blob may be large when copy image, do not limit the whole request time
+func newV2Transport(config *tls.Config) *http.Transport {
+	return tlsutil.Transport(&http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	}, config)
+}
+
+// withTLS use the tls config of the registry which uses the private CA
+func (c *v2Client) withTLS(config *tls.Config) *v2Client {
+	if config != nil {
+		c.httpClient.Transport = newV2Transport(config)
+	}
+	return c
+}
+
+// Credential ..
+func (c *v2Client) Credential() (string, string, error) {
+	return c.credential()
+}
+
+// Ping ..
+func (c *v2Client) Ping() error {
+	rsp, err := c.get("/v2/", "")
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s/v2/ 认证失败, 状态码: %v", c.URL, rsp.StatusCode)
+	}
+	return nil
+}
+
+// RepositoryExists ..
+func (c *v2Client) RepositoryExists(repo string) (bool, error) {
+	_, err := c.ListTags(repo)
+	if err == errRepositoryNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var errRepositoryNotFound = fmt.Errorf("repository not found")
+
+// ListTags ..
+func (c *v2Client) ListTags(repo string) ([]string, error) {
+	repo = c.repository(repo)
+	tags := []string{}
+	path := fmt.Sprintf("/v2/%s/tags/list", repo)
+	for path != "" {
+		rsp, err := c.get(path, "repository:"+repo+":pull")
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		switch rsp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			return nil, errRepositoryNotFound
+		default:
+			return nil, fmt.Errorf("list repository: %v tags return status %v: %s", repo, rsp.StatusCode, string(body))
+		}
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		path = nextLink(rsp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// ManifestDigest ..
+func (c *v2Client) ManifestDigest(repo, tag string) (string, error) {
+	_, _, digest, err := c.getManifest(c.repository(repo), tag)
+	return digest, err
+}
+
+// TagInfo ..
+func (c *v2Client) TagInfo(repo, tag string) (*TagInfo, error) {
+	repo = c.repository(repo)
+	body, _, digest, err := c.getManifest(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	info := &TagInfo{Tag: tag, Digest: digest}
+	m := manifest{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, err
+	}
+	// the created time of manifest list is taken from its first image
+	if len(m.Manifests) > 0 {
+		if body, _, _, err = c.getManifest(repo, m.Manifests[0].Digest); err != nil {
+			return nil, err
+		}
+		m = manifest{}
+		if err := json.Unmarshal(body, &m); err != nil {
+			return nil, err
+		}
+	}
+	if m.Config == nil {
+		return info, nil
+	}
+	rsp, err := c.get(fmt.Sprintf("/v2/%s/blobs/%s", repo, m.Config.Digest), "repository:"+repo+":pull")
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get image config %v of %v return status %v", m.Config.Digest, repo, rsp.StatusCode)
+	}
+	config := struct {
+		Created time.Time `json:"created"`
+	}{}
+	if err := json.NewDecoder(rsp.Body).Decode(&config); err != nil {
+		return nil, err
+	}
+	info.Created = config.Created
+	return info, nil
+}
+
+// DeleteManifest ..
+func (c *v2Client) DeleteManifest(repo, digest string) error {
+	repo = c.repository(repo)
+	rsp, err := c.request(http.MethodDelete, fmt.Sprintf("/v2/%s/manifests/%s", repo, digest), "repository:"+repo+":*", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusAccepted && rsp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("delete manifest %v of %v return status %v: %s", digest, repo, rsp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// getManifest return the manifest body, media type and digest of reference
+func (c *v2Client) getManifest(repo, ref string) ([]byte, string, string, error) {
+	header := http.Header{}
+	header.Set("Accept", manifestAccept)
+	rsp, err := c.request(http.MethodGet, fmt.Sprintf("/v2/%s/manifests/%s", repo, ref), "repository:"+repo+":pull", header, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("get manifest %v:%v return status %v: %s", repo, ref, rsp.StatusCode, string(body))
+	}
+	mediaType := strings.TrimSpace(strings.Split(rsp.Header.Get("Content-Type"), ";")[0])
+	digest := rsp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+	return body, mediaType, digest, nil
+}
+
+func (c *v2Client) repository(repo string) string {
+	repo = strings.Trim(repo, "/")
+	if c.officialLibrary && !strings.Contains(repo, "/") {
+		return "library/" + repo
+	}
+	return repo
+}
+
+// get request the registry api, answer the basic or bearer token challenge when it is unauthorized
+func (c *v2Client) get(path, scope string) (*http.Response, error) {
+	return c.request(http.MethodGet, path, scope, nil, nil)
+}
+
+// request the registry api with the authorization cached for scope, body return a new reader of
+// request body each time, the request was retried after answered the challenge when it is unauthorized.
+func (c *v2Client) request(method, path, scope string, header http.Header, body func() io.Reader) (*http.Response, error) {
+	c.mu.Lock()
+	authorization := c.authorizations[scope]
+	c.mu.Unlock()
+	rsp, err := c.do(method, path, authorization, header, body)
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		return rsp, err
+	}
+	challenge := rsp.Header.Get("Www-Authenticate")
+	rsp.Body.Close()
+
+	authorization, err = c.authorize(challenge, scope)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.authorizations[scope] = authorization
+	c.mu.Unlock()
+	return c.do(method, path, authorization, header, body)
+}
+
+func (c *v2Client) authorize(challenge, scope string) (string, error) {
+	user, password, err := c.credential()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(strings.ToLower(challenge), "basic"):
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(user, password)
+		return req.Header.Get("Authorization"), nil
+	case strings.HasPrefix(strings.ToLower(challenge), "bearer"):
+		token, err := c.bearerToken(challenge, scope, user, password)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported registry auth challenge: %q", challenge)
+	}
+}
+
+func (c *v2Client) do(method, path, authorization string, header http.Header, body func() io.Reader) (*http.Response, error) {
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = c.URL + path
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = body()
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	// the length of stream body must be set to request directly, otherwise it is sent chunked
+	if length := header.Get("Content-Length"); length != "" {
+		req.ContentLength, _ = strconv.ParseInt(length, 10, 64)
+	}
+	return c.httpClient.Do(req)
+}
+
+// bearerToken request token from the realm of challenge,
+// eg: Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func (c *v2Client) bearerToken(challenge, scope, user, password string) (string, error) {
+	params := parseChallenge(challenge[len("bearer"):])
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry auth challenge did not include realm: %q", challenge)
+	}
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	// multiple scopes are separated by space, eg: mount blob from another repository
+	for _, item := range strings.Fields(scope) {
+		query.Add("scope", item)
+	}
+	req, err := http.NewRequest(http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if user != "" || password != "" {
+		req.SetBasicAuth(user, password)
+	}
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("账号或密码不正确, 获取 registry token 状态码: %v", rsp.StatusCode)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(rsp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// parseChallenge parse the key="value" pairs of auth challenge
+func parseChallenge(s string) map[string]string {
+	params := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		index := strings.Index(item, "=")
+		if index == -1 {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(item[:index]))] = strings.Trim(strings.TrimSpace(item[index+1:]), "\"")
+	}
+	return params
+}
+
+// nextLink return the next page path of Link header, eg: </v2/app/tags/list?last=v1&n=100>; rel="next"
+func nextLink(link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start == -1 || end <= start {
+		return ""
+	}
+	return link[start+1 : end]
+}
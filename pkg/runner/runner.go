@@ -0,0 +1,206 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package runner is the protocol between AtomCI and the self hosted runner agents, the runner registers with
+// the registration token, then claims the queued tasks, runs their steps in local docker and reports the logs.
+//
+//	client := runner.NewClient("https://atomci.example.com", "")
+//	rsp, err := client.Register(&runner.RegisterReq{Token: "<registration token>", Name: "runner-1"})
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BasePath the path prefix of the runner apis
+const BasePath = "/atomci/api/v1/runner"
+
+// the final status of task reported by runner
+const (
+	StatusSuccess = "SUCCESS"
+	StatusFailure = "FAILURE"
+	StatusAborted = "ABORTED"
+)
+
+// DefaultContainer the step without container runs in the default image of runner, like the jnlp container of jenkins
+const DefaultContainer = ""
+
+// RegisterReq register the runner by the registration token configured in the server
+type RegisterReq struct {
+	Token   string `json:"token"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// RegisterRsp the token is returned only once, which authenticates the following requests of runner
+type RegisterRsp struct {
+	ID    int64  `json:"id"`
+	Token string `json:"token"`
+}
+
+// HeartbeatReq ..
+type HeartbeatReq struct {
+	Version string `json:"version"`
+}
+
+// Container the image and the shell which the steps of container run by
+type Container struct {
+	Image string `json:"image"`
+	Shell string `json:"shell"`
+}
+
+// Step the shell script run in the container, the steps are run one by one in order
+type Step struct {
+	Stage     string `json:"stage"`
+	Container string `json:"container"`
+	Script    string `json:"script"`
+}
+
+// Callback the request sent to AtomCI after all the steps succeed
+type Callback struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	Body  string `json:"body"`
+}
+
+// Spec the task spec, the workspace is shared by all the steps
+type Spec struct {
+	Workspace  string               `json:"workspace"`
+	Env        map[string]string    `json:"env"`
+	Containers map[string]Container `json:"containers"`
+	Steps      []Step               `json:"steps"`
+	Callback   *Callback            `json:"callback,omitempty"`
+}
+
+// Task the task claimed by runner
+type Task struct {
+	ID      int64  `json:"id"`
+	JobName string `json:"job_name"`
+	Spec    *Spec  `json:"spec"`
+}
+
+// LogReq append the log of task, finished_steps is the number of steps finished
+type LogReq struct {
+	Text          string `json:"text"`
+	FinishedSteps int    `json:"finished_steps"`
+}
+
+// LogRsp abort is true when the task is requested to abort
+type LogRsp struct {
+	Abort bool `json:"abort"`
+}
+
+// StatusReq report the final status of task, SUCCESS, FAILURE or ABORTED
+type StatusReq struct {
+	Status string `json:"status"`
+}
+
+// Client the client of the runner apis
+type Client struct {
+	BaseURL string
+	// Token the runner token returned by register
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient ..
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// result the response of api v1
+type result struct {
+	IsSuccess bool            `json:"IsSuccess"`
+	Data      json.RawMessage `json:"Data"`
+	ErrMsg    string          `json:"ErrMsg"`
+}
+
+// Register ..
+func (c *Client) Register(req *RegisterReq) (*RegisterRsp, error) {
+	rsp := &RegisterRsp{}
+	return rsp, c.post("/register", req, rsp)
+}
+
+// Heartbeat ..
+func (c *Client) Heartbeat(req *HeartbeatReq) error {
+	return c.post("/heartbeat", req, nil)
+}
+
+// Claim return nil when no task is queued
+func (c *Client) Claim() (*Task, error) {
+	var task *Task
+	return task, c.post("/tasks/claim", nil, &task)
+}
+
+// AppendLog ..
+func (c *Client) AppendLog(taskID int64, req *LogReq) (*LogRsp, error) {
+	rsp := &LogRsp{}
+	return rsp, c.post(fmt.Sprintf("/tasks/%v/logs", taskID), req, rsp)
+}
+
+// ReportStatus ..
+func (c *Client) ReportStatus(taskID int64, req *StatusReq) error {
+	return c.post(fmt.Sprintf("/tasks/%v/status", taskID), req, nil)
+}
+
+// post send the request and decode the data of result into out
+func (c *Client) post(path string, body, out interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL+BasePath+path, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(content)))
+	}
+	res := result{}
+	if err := json.Unmarshal(content, &res); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if !res.IsSuccess {
+		return fmt.Errorf("%v", res.ErrMsg)
+	}
+	if out == nil || len(res.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Data, out)
+}
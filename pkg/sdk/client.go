@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sdk is the go client of the AtomCI api v2, the resource types and the methods
+// of Client are generated from the openapi document by cmd/openapi-gen.
+//
+//	client := sdk.NewClient("https://atomci.example.com", "atci_xxx")
+//	projects, err := client.ListProjects(ctx, &sdk.ListProjectsOptions{Limit: 50})
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BasePath the path prefix of api v2
+const BasePath = "/atomci/api/v2"
+
+// Client the client of api v2
+type Client struct {
+	// BaseURL the address of AtomCI, e.g. https://atomci.example.com
+	BaseURL string
+	// Token the personal access token or the user token
+	Token string
+	// Language the language of the error messages, zh or en, empty means the server default
+	Language   string
+	HTTPClient *http.Client
+}
+
+// NewClient create the client with the address of AtomCI and the access token
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// APIError the error responded by api v2
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Detail     string
+	// Params the params of the error code
+	Params map[string]interface{}
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("atomci: %d %s: %s: %s", e.StatusCode, e.Code, e.Message, e.Detail)
+	}
+	return fmt.Sprintf("atomci: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// do send the request and decode the json response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, out interface{}) error {
+	endpoint := c.BaseURL + BasePath + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Language != "" {
+		req.Header.Set("Accept-Language", c.Language)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		envelope := ErrorResponse{}
+		if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Code != "" {
+			apiErr.Code, apiErr.Message, apiErr.Detail = envelope.Error.Code, envelope.Error.Message, envelope.Error.Detail
+			apiErr.Params = envelope.Error.Params
+		} else {
+			apiErr.Code = strings.ReplaceAll(http.StatusText(resp.StatusCode), " ", "")
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer atci_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case BasePath + "/projects/1/publishes":
+			if r.URL.Query().Get("status") != "running" || r.URL.Query().Get("limit") != "2" || r.URL.Query().Get("cursor") != "next" {
+				t.Errorf("unexpected query: %v", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"items":[{"id":3,"project_id":1,"status":"running"}],"next_cursor":"abc","has_more":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"NotFound","message":"流水线不存在"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "atci_test")
+	page, err := client.ListPublishes(context.Background(), 1, &ListPublishesOptions{Status: "running", Cursor: "next", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != 3 || !page.HasMore || page.NextCursor != "abc" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	_, err = client.GetPublish(context.Background(), 1, 9)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "NotFound" || apiErr.Message != "流水线不存在" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	client.Token = "invalid"
+	_, err = client.ListProjects(context.Background(), nil)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != "Unauthorized" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tlsutil builds the tls configs of the integrated services, the clients of the integrated service use
+// the dedicated transport of its tls config, the clients created by the third party libraries which use the
+// default transport, eg: jenkins, are covered by the tls configs registered by host.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ClientConfig return the tls config trusts the CA bundle besides the system root CAs,
+// and presents the client certificate if it is not empty
+func ClientConfig(caCert, clientCert, clientKey string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if caCert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("CA 证书不是有效的 PEM 格式")
+		}
+		config.RootCAs = pool
+	}
+	if clientCert != "" || clientKey != "" {
+		certificate, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, errors.New("客户端证书或私钥无效: " + err.Error())
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// Transport return the clone of base with the tls config, the default transport is used if base is nil
+func Transport(base *http.Transport, config *tls.Config) *http.Transport {
+	if base == nil {
+		base = defaultTransport
+	}
+	transport := base.Clone()
+	if config != nil {
+		transport.TLSClientConfig = config.Clone()
+	}
+	return transport
+}
+
+var (
+	mu sync.RWMutex
+	// configs the tls configs of the hosts registered by the owners, the key is host:port
+	configs = map[string]map[string]*tls.Config{}
+	// ownerHosts the host registered by the owner
+	ownerHosts = map[string]string{}
+	// version increased when the configs changed, the cached transports are rebuilt
+	version int
+
+	// defaultTransport the default transport before it is wrapped by Install
+	defaultTransport = http.DefaultTransport.(*http.Transport)
+	installOnce      sync.Once
+)
+
+// hostKey return the host:port of the url, the port of https is 443 by default
+func hostKey(host, scheme string) string {
+	host = strings.ToLower(host)
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if scheme == "http" {
+		return net.JoinHostPort(host, "80")
+	}
+	return net.JoinHostPort(host, "443")
+}
+
+// HostKey return the host:port of the server url, empty if the url is invalid
+func HostKey(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return hostKey(u.Host, u.Scheme)
+}
+
+// Register the tls config of the owner, eg: the integrate setting, for the server url, the config registered
+// by the owner before is replaced, nil config removes it. The owners of the same host should use the same config,
+// the one of the first owner in order is used otherwise.
+func Register(owner, serverURL string, config *tls.Config) error {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Host == "" {
+		return errors.New("无效的服务地址: " + serverURL)
+	}
+	Install()
+	mu.Lock()
+	defer mu.Unlock()
+	unregister(owner)
+	if config != nil {
+		key := hostKey(u.Host, u.Scheme)
+		if configs[key] == nil {
+			configs[key] = map[string]*tls.Config{}
+		}
+		configs[key][owner] = config
+		ownerHosts[owner] = key
+	}
+	version++
+	return nil
+}
+
+// Unregister remove the tls config registered by the owner
+func Unregister(owner string) {
+	mu.Lock()
+	defer mu.Unlock()
+	unregister(owner)
+	version++
+}
+
+func unregister(owner string) {
+	key, ok := ownerHosts[owner]
+	if !ok {
+		return
+	}
+	delete(configs[key], owner)
+	if len(configs[key]) == 0 {
+		delete(configs, key)
+	}
+	delete(ownerHosts, owner)
+}
+
+// Lookup return the tls config registered for the server url
+func Lookup(serverURL string) *tls.Config {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	config, _ := lookup(u)
+	return config
+}
+
+func lookup(u *url.URL) (*tls.Config, int) {
+	mu.RLock()
+	defer mu.RUnlock()
+	owners := configs[hostKey(u.Host, u.Scheme)]
+	if len(owners) == 0 {
+		return nil, version
+	}
+	keys := make([]string, 0, len(owners))
+	for owner := range owners {
+		keys = append(keys, owner)
+	}
+	sort.Strings(keys)
+	return owners[keys[0]], version
+}
+
+// hostTransport use the transport with the tls config registered for the request host
+type hostTransport struct {
+	base *http.Transport
+
+	mu         sync.Mutex
+	version    int
+	transports map[string]*http.Transport
+}
+
+// NewTransport wrap the base transport, the request to the host which has tls config registered uses
+// a clone of base with the tls config
+func NewTransport(base *http.Transport) http.RoundTripper {
+	return &hostTransport{base: base, transports: map[string]*http.Transport{}}
+}
+
+// RoundTrip ..
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.base.RoundTrip(req)
+	}
+	config, current := lookup(req.URL)
+	if config == nil {
+		return t.base.RoundTrip(req)
+	}
+	return t.transport(req.URL, config, current).RoundTrip(req)
+}
+
+func (t *hostTransport) transport(u *url.URL, config *tls.Config, current int) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.version != current {
+		for _, transport := range t.transports {
+			transport.CloseIdleConnections()
+		}
+		t.transports = map[string]*http.Transport{}
+		t.version = current
+	}
+	key := hostKey(u.Host, u.Scheme)
+	transport, ok := t.transports[key]
+	if !ok {
+		transport = t.base.Clone()
+		transport.TLSClientConfig = config.Clone()
+		t.transports[key] = transport
+	}
+	return transport
+}
+
+// Install wrap the default transport, which is used by the clients without transport
+func Install() {
+	installOnce.Do(func() {
+		http.DefaultTransport = NewTransport(defaultTransport)
+	})
+}
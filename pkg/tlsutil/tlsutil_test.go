@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlsutil
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&http.Transport{})}
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatalf("request to the server with unknown CA should fail")
+	}
+
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	config, err := ClientConfig(caCert, "", "", false)
+	if err != nil {
+		t.Fatalf("ClientConfig() error = %v", err)
+	}
+	if err := Register("test", server.URL, config); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if Lookup(server.URL+"/api/v2") != config {
+		t.Errorf("Lookup() should return the registered config")
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with the registered CA error = %v", err)
+	}
+	resp.Body.Close()
+
+	Unregister("test")
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("request after the CA unregistered should fail")
+	}
+}
+
+func TestRegisterOwners(t *testing.T) {
+	first, second := &tls.Config{}, &tls.Config{}
+	if err := Register("b", "https://git.example.com", second); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := Register("a", "https://git.example.com/group", first); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if Lookup("https://git.example.com") != first {
+		t.Errorf("Lookup() should return the config of the first owner")
+	}
+
+	// the owner removes its config, the config of the other owner on the same host is kept
+	Unregister("a")
+	if Lookup("https://git.example.com") != second {
+		t.Errorf("Lookup() should return the config of the remaining owner")
+	}
+	// the owner moves to another host
+	if err := Register("b", "https://jenkins.example.com", second); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if Lookup("https://git.example.com") != nil || Lookup("https://jenkins.example.com") != second {
+		t.Errorf("the config of the owner should be moved to the new host")
+	}
+	if err := Register("b", "https://jenkins.example.com", nil); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if Lookup("https://jenkins.example.com") != nil {
+		t.Errorf("Register() with nil config should remove the config")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	config, err := ClientConfig(caCert, "", "", false)
+	if err != nil {
+		t.Fatalf("ClientConfig() error = %v", err)
+	}
+
+	client := &http.Client{Transport: Transport(nil, config)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with the dedicated transport error = %v", err)
+	}
+	resp.Body.Close()
+	if Lookup(server.URL) != nil {
+		t.Errorf("the dedicated transport should not register the config")
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	if _, err := ClientConfig("invalid pem", "", "", false); err == nil {
+		t.Errorf("ClientConfig() with invalid CA should fail")
+	}
+	if _, err := ClientConfig("", "invalid cert", "", false); err == nil {
+		t.Errorf("ClientConfig() with invalid client cert should fail")
+	}
+	config, err := ClientConfig("", "", "", true)
+	if err != nil || !config.InsecureSkipVerify {
+		t.Errorf("ClientConfig() insecure = %v, %v", config, err)
+	}
+}
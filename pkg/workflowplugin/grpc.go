@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-atomci/atomci/pkg/workflowplugin/proto"
+
+	"github.com/go-atomci/workflow"
+	"github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// the proto/driver.pb.go is generated by: protoc --go_out=plugins=grpc,paths=source_relative:. proto/driver.proto
+
+// GRPCServer serve the driver over grpc, the driver plugins use it by default
+func (p *driverPlugin) GRPCServer(_ *plugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterDriverServer(s, &grpcServer{driver: p.driver})
+	return nil
+}
+
+// GRPCClient ..
+func (p *driverPlugin) GRPCClient(_ context.Context, _ *plugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return &grpcClient{client: proto.NewDriverClient(conn)}, nil
+}
+
+// grpcServer the grpc service served by plugin
+type grpcServer struct {
+	driver Driver
+}
+
+func (s *grpcServer) Capabilities(context.Context, *proto.Empty) (*proto.Capabilities, error) {
+	capabilities, err := s.driver.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Capabilities{
+		Name:          capabilities.Name,
+		Version:       capabilities.Version,
+		SupportsAbort: capabilities.SupportsAbort,
+		SupportsLogs:  capabilities.SupportsLogs,
+	}, nil
+}
+
+func (s *grpcServer) Ping(_ context.Context, conn *proto.Connection) (*proto.PingResponse, error) {
+	info, err := s.driver.Ping(fromProtoConnection(conn))
+	if err != nil {
+		return nil, err
+	}
+	return &proto.PingResponse{Info: info}, nil
+}
+
+func (s *grpcServer) Build(_ context.Context, req *proto.BuildRequest) (*proto.BuildResponse, error) {
+	runID, err := s.driver.Build(&BuildRequest{
+		Connection: *fromProtoConnection(req.Connection),
+		JobName:    req.JobName,
+		Pipeline:   req.Pipeline,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &proto.BuildResponse{RunId: runID}, nil
+}
+
+func (s *grpcServer) Abort(_ context.Context, req *proto.JobRequest) (*proto.Empty, error) {
+	if err := s.driver.Abort(fromProtoJobRequest(req)); err != nil {
+		return nil, err
+	}
+	return &proto.Empty{}, nil
+}
+
+func (s *grpcServer) GetJobInfo(_ context.Context, req *proto.JobRequest) (*proto.JobInfo, error) {
+	info, err := s.driver.GetJobInfo(fromProtoJobRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.JobInfo{Json: bytes}, nil
+}
+
+func (s *grpcServer) GetJobLog(_ context.Context, req *proto.LogRequest) (*proto.Log, error) {
+	log, err := s.driver.GetJobLog(&LogRequest{JobRequest: *fromProtoJobRequest(req.Job), Start: req.Start})
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Log{Text: log.Text, NextStart: log.NextStart, More: log.More}, nil
+}
+
+// grpcClient the driver which calls the plugin over grpc
+type grpcClient struct {
+	client proto.DriverClient
+}
+
+func (c *grpcClient) Capabilities() (*Capabilities, error) {
+	reply, err := c.client.Capabilities(context.Background(), &proto.Empty{})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &Capabilities{
+		Name:          reply.Name,
+		Version:       reply.Version,
+		SupportsAbort: reply.SupportsAbort,
+		SupportsLogs:  reply.SupportsLogs,
+	}, nil
+}
+
+func (c *grpcClient) Ping(conn *Connection) (string, error) {
+	reply, err := c.client.Ping(context.Background(), toProtoConnection(conn))
+	if err != nil {
+		return "", grpcError(err)
+	}
+	return reply.Info, nil
+}
+
+func (c *grpcClient) Build(req *BuildRequest) (int64, error) {
+	reply, err := c.client.Build(context.Background(), &proto.BuildRequest{
+		Connection: toProtoConnection(&req.Connection),
+		JobName:    req.JobName,
+		Pipeline:   req.Pipeline,
+	})
+	if err != nil {
+		return 0, grpcError(err)
+	}
+	return reply.RunId, nil
+}
+
+func (c *grpcClient) Abort(req *JobRequest) error {
+	_, err := c.client.Abort(context.Background(), toProtoJobRequest(req))
+	return grpcError(err)
+}
+
+func (c *grpcClient) GetJobInfo(req *JobRequest) (*workflow.JobInfo, error) {
+	reply, err := c.client.GetJobInfo(context.Background(), toProtoJobRequest(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	info := &workflow.JobInfo{}
+	if err := json.Unmarshal(reply.Json, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *grpcClient) GetJobLog(req *LogRequest) (*Log, error) {
+	reply, err := c.client.GetJobLog(context.Background(), &proto.LogRequest{Job: toProtoJobRequest(&req.JobRequest), Start: req.Start})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &Log{Text: reply.Text, NextStart: reply.NextStart, More: reply.More}, nil
+}
+
+// grpcError return the error message of driver without the grpc status code
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(status.Convert(err).Message())
+}
+
+func toProtoConnection(conn *Connection) *proto.Connection {
+	return &proto.Connection{Url: conn.URL, User: conn.User, Token: conn.Token}
+}
+
+func fromProtoConnection(conn *proto.Connection) *Connection {
+	if conn == nil {
+		return &Connection{}
+	}
+	return &Connection{URL: conn.Url, User: conn.User, Token: conn.Token}
+}
+
+func toProtoJobRequest(req *JobRequest) *proto.JobRequest {
+	return &proto.JobRequest{Connection: toProtoConnection(&req.Connection), JobName: req.JobName, RunId: req.RunID}
+}
+
+func fromProtoJobRequest(req *proto.JobRequest) *JobRequest {
+	if req == nil {
+		return &JobRequest{}
+	}
+	return &JobRequest{Connection: *fromProtoConnection(req.Connection), JobName: req.JobName, RunID: req.RunId}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowplugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+)
+
+// BinaryPrefix the driver plugin binaries are named <prefix><driver name>, eg: atomci-driver-teamcity
+const BinaryPrefix = "atomci-driver-"
+
+// Registry the driver plugins under the directory, the plugin is started on the first use and restarted after exited
+type Registry struct {
+	dir     string
+	lock    sync.Mutex
+	clients map[string]*plugin.Client
+}
+
+// NewRegistry ..
+func NewRegistry(dir string) *Registry {
+	return &Registry{dir: dir, clients: map[string]*plugin.Client{}}
+}
+
+// Names return the names of the driver plugins, nothing when the directory is not configured
+func (r *Registry) Names() ([]string, error) {
+	if r.dir == "" {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, file := range files {
+		if file.IsDir() || file.Mode()&0111 == 0 || !strings.HasPrefix(file.Name(), BinaryPrefix) {
+			continue
+		}
+		names = append(names, strings.TrimPrefix(file.Name(), BinaryPrefix))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Driver return the driver served by the plugin of name
+func (r *Registry) Driver(name string) (Driver, error) {
+	if r.dir == "" || name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("workflow driver: %v is not found", name)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	client, ok := r.clients[name]
+	if !ok || client.Exited() {
+		path := filepath.Join(r.dir, BinaryPrefix+name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("workflow driver: %v is not found", name)
+		}
+		// the plugins built before the grpc service fall back to net/rpc
+		client = plugin.NewClient(&plugin.ClientConfig{
+			HandshakeConfig:  Handshake,
+			Plugins:          map[string]plugin.Plugin{pluginName: &driverPlugin{}},
+			Cmd:              exec.Command(path),
+			AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC, plugin.ProtocolNetRPC},
+			Logger:           hclog.New(&hclog.LoggerOptions{Name: "workflow-driver-" + name, Level: hclog.Warn}),
+		})
+		r.clients[name] = client
+	}
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		delete(r.clients, name)
+		return nil, fmt.Errorf("start workflow driver: %v occur error: %s", name, err.Error())
+	}
+	raw, err := rpcClient.Dispense(pluginName)
+	if err != nil {
+		return nil, err
+	}
+	return raw.(Driver), nil
+}
+
+// Close stop all the started plugins
+func (r *Registry) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for name, client := range r.clients {
+		client.Kill()
+		delete(r.clients, name)
+	}
+}
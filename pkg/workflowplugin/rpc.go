@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowplugin
+
+import (
+	"encoding/gob"
+	"net/rpc"
+
+	"github.com/go-atomci/workflow"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+func init() {
+	// the artifacts/description/executor of job info are decoded as the json values
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// driverPlugin the driver over grpc, net/rpc is the fallback of the plugins built before the grpc service
+type driverPlugin struct {
+	driver Driver
+}
+
+func (p *driverPlugin) Server(*plugin.MuxBroker) (interface{}, error) {
+	return &rpcServer{driver: p.driver}, nil
+}
+
+func (p *driverPlugin) Client(_ *plugin.MuxBroker, client *rpc.Client) (interface{}, error) {
+	return &rpcClient{client: client}, nil
+}
+
+// rpcServer the rpc methods served by plugin
+type rpcServer struct {
+	driver Driver
+}
+
+func (s *rpcServer) Capabilities(_ struct{}, reply *Capabilities) error {
+	capabilities, err := s.driver.Capabilities()
+	if err != nil {
+		return err
+	}
+	*reply = *capabilities
+	return nil
+}
+
+func (s *rpcServer) Ping(conn Connection, reply *string) (err error) {
+	*reply, err = s.driver.Ping(&conn)
+	return err
+}
+
+func (s *rpcServer) Build(req BuildRequest, reply *int64) (err error) {
+	*reply, err = s.driver.Build(&req)
+	return err
+}
+
+func (s *rpcServer) Abort(req JobRequest, _ *struct{}) error {
+	return s.driver.Abort(&req)
+}
+
+func (s *rpcServer) GetJobInfo(req JobRequest, reply *workflow.JobInfo) error {
+	info, err := s.driver.GetJobInfo(&req)
+	if err != nil {
+		return err
+	}
+	*reply = *info
+	return nil
+}
+
+func (s *rpcServer) GetJobLog(req LogRequest, reply *Log) error {
+	log, err := s.driver.GetJobLog(&req)
+	if err != nil {
+		return err
+	}
+	*reply = *log
+	return nil
+}
+
+// rpcClient the driver which calls the plugin
+type rpcClient struct {
+	client *rpc.Client
+}
+
+func (c *rpcClient) Capabilities() (*Capabilities, error) {
+	reply := &Capabilities{}
+	if err := c.client.Call("Plugin.Capabilities", struct{}{}, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *rpcClient) Ping(conn *Connection) (string, error) {
+	var reply string
+	err := c.client.Call("Plugin.Ping", conn, &reply)
+	return reply, err
+}
+
+func (c *rpcClient) Build(req *BuildRequest) (int64, error) {
+	var reply int64
+	err := c.client.Call("Plugin.Build", req, &reply)
+	return reply, err
+}
+
+func (c *rpcClient) Abort(req *JobRequest) error {
+	return c.client.Call("Plugin.Abort", req, &struct{}{})
+}
+
+func (c *rpcClient) GetJobInfo(req *JobRequest) (*workflow.JobInfo, error) {
+	reply := &workflow.JobInfo{}
+	if err := c.client.Call("Plugin.GetJobInfo", req, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *rpcClient) GetJobLog(req *LogRequest) (*Log, error) {
+	reply := &Log{}
+	if err := c.client.Call("Plugin.GetJobLog", req, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowplugin
+
+import (
+	"github.com/go-atomci/workflow"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+// Handshake the handshake between atomci and the driver plugins, the plugins of the other protocol version are refused
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "ATOMCI_WORKFLOW_DRIVER",
+	MagicCookieValue: "4b3c7a0e-atomci-workflow-driver",
+}
+
+// pluginName the name which the driver is dispensed by
+const pluginName = "driver"
+
+// Capabilities the name and the optional features of driver
+type Capabilities struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	SupportsAbort bool   `json:"supports_abort"`
+	SupportsLogs  bool   `json:"supports_logs"`
+}
+
+// Connection the ci server which the driver connects to, it comes from the ci server integrate setting
+type Connection struct {
+	URL   string
+	User  string
+	Token string
+}
+
+// BuildRequest create or update the job with the pipeline, then trigger it
+type BuildRequest struct {
+	Connection
+	JobName string
+	// Pipeline the pipeline of job encoded by json, eg: the ci context of build job
+	Pipeline []byte
+}
+
+// JobRequest locate the run of job
+type JobRequest struct {
+	Connection
+	JobName string
+	RunID   int64
+}
+
+// LogRequest get the log of the run of job from the offset
+type LogRequest struct {
+	JobRequest
+	Start int64
+}
+
+// Log the piece of log of the run of job
+type Log struct {
+	Text string
+	// NextStart the offset to get the following log
+	NextStart int64
+	// More whether the run is still writing the log
+	More bool
+}
+
+// Driver the workflow driver served by the plugin, Abort/GetJobLog are called only when the capability is declared
+type Driver interface {
+	Capabilities() (*Capabilities, error)
+	// Ping verify the connection, return the description of ci server
+	Ping(conn *Connection) (string, error)
+	// Build return the run id of the triggered job
+	Build(req *BuildRequest) (int64, error)
+	Abort(req *JobRequest) error
+	GetJobInfo(req *JobRequest) (*workflow.JobInfo, error)
+	GetJobLog(req *LogRequest) (*Log, error)
+}
+
+// Serve serve the driver over grpc in the main function of the plugin binary
+func Serve(driver Driver) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         map[string]plugin.Plugin{pluginName: &driverPlugin{driver: driver}},
+		GRPCServer:      plugin.DefaultGRPCServer,
+	})
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowplugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-atomci/workflow"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+type fakeDriver struct{}
+
+func (d *fakeDriver) Capabilities() (*Capabilities, error) {
+	return &Capabilities{Name: "fake", Version: "v1", SupportsLogs: true}, nil
+}
+
+func (d *fakeDriver) Ping(conn *Connection) (string, error) {
+	return "fake " + conn.URL, nil
+}
+
+func (d *fakeDriver) Build(req *BuildRequest) (int64, error) {
+	return int64(len(req.Pipeline)), nil
+}
+
+func (d *fakeDriver) Abort(req *JobRequest) error {
+	return errors.New("abort is not supported")
+}
+
+func (d *fakeDriver) GetJobInfo(req *JobRequest) (*workflow.JobInfo, error) {
+	return &workflow.JobInfo{Number: int(req.RunID), Result: "SUCCESS", Artifacts: []interface{}{map[string]interface{}{"fileName": "app.tar"}}}, nil
+}
+
+func (d *fakeDriver) GetJobLog(req *LogRequest) (*Log, error) {
+	return &Log{Text: "done", NextStart: req.Start + 4}, nil
+}
+
+func TestDriverRPC(t *testing.T) {
+	client, _ := plugin.TestPluginRPCConn(t, map[string]plugin.Plugin{pluginName: &driverPlugin{driver: &fakeDriver{}}}, nil)
+	defer client.Close()
+	raw, err := client.Dispense(pluginName)
+	if err != nil {
+		t.Fatalf("dispense driver: %v", err)
+	}
+	testDriver(t, raw.(Driver))
+}
+
+func TestDriverGRPC(t *testing.T) {
+	client, server := plugin.TestPluginGRPCConn(t, map[string]plugin.Plugin{pluginName: &driverPlugin{driver: &fakeDriver{}}})
+	defer client.Close()
+	defer server.Stop()
+	raw, err := client.Dispense(pluginName)
+	if err != nil {
+		t.Fatalf("dispense driver: %v", err)
+	}
+	testDriver(t, raw.(Driver))
+}
+
+// testDriver call the fake driver served by plugin
+func testDriver(t *testing.T, driver Driver) {
+	capabilities, err := driver.Capabilities()
+	if err != nil || !reflect.DeepEqual(capabilities, &Capabilities{Name: "fake", Version: "v1", SupportsLogs: true}) {
+		t.Fatalf("Capabilities() = %+v, %v", capabilities, err)
+	}
+	conn := Connection{URL: "http://ci.example.com", User: "admin", Token: "token"}
+	if info, err := driver.Ping(&conn); err != nil || info != "fake http://ci.example.com" {
+		t.Fatalf("Ping() = %v, %v", info, err)
+	}
+	if runID, err := driver.Build(&BuildRequest{Connection: conn, JobName: "job", Pipeline: []byte(`{"a":1}`)}); err != nil || runID != 7 {
+		t.Fatalf("Build() = %v, %v", runID, err)
+	}
+	if err := driver.Abort(&JobRequest{Connection: conn, JobName: "job", RunID: 1}); err == nil || err.Error() != "abort is not supported" {
+		t.Fatalf("Abort() = %v", err)
+	}
+	info, err := driver.GetJobInfo(&JobRequest{Connection: conn, JobName: "job", RunID: 3})
+	if err != nil || info.Number != 3 || info.Result != "SUCCESS" || len(info.Artifacts) != 1 {
+		t.Fatalf("GetJobInfo() = %+v, %v", info, err)
+	}
+	log, err := driver.GetJobLog(&LogRequest{JobRequest: JobRequest{Connection: conn, JobName: "job", RunID: 3}, Start: 10})
+	if err != nil || log.Text != "done" || log.NextStart != 14 {
+		t.Fatalf("GetJobLog() = %+v, %v", log, err)
+	}
+}
+
+func TestRegistryNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workflowplugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, mode := range map[string]os.FileMode{
+		BinaryPrefix + "teamcity": 0755,
+		BinaryPrefix + "azure":    0755,
+		BinaryPrefix + "readme":   0644,
+		"jenkins":                 0755,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := NewRegistry(dir).Names()
+	if err != nil || !reflect.DeepEqual(names, []string{"azure", "teamcity"}) {
+		t.Fatalf("Names() = %v, %v", names, err)
+	}
+	if names, err := NewRegistry("").Names(); err != nil || len(names) != 0 {
+		t.Fatalf("Names() without dir = %v, %v", names, err)
+	}
+	if _, err := NewRegistry(dir).Driver("../jenkins"); err == nil {
+		t.Fatalf("the driver out of the directory should not be found")
+	}
+}
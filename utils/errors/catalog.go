@@ -0,0 +1,224 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the languages of the error messages
+const (
+	LangZh = "zh"
+	LangEn = "en"
+	// DefaultLang the language used when the request does not accept any supported language
+	DefaultLang = LangZh
+)
+
+// the codes of catalog, the clients branch on the code instead of the message
+const (
+	CodeBadRequest              = "BadRequest"
+	CodeConflict                = "Conflict"
+	CodeUnauthorized            = "Unauthorized"
+	CodeForbidden               = "Forbidden"
+	CodeNotFound                = "NotFound"
+	CodeMethodNotAllowed        = "MethodNotAllowed"
+	CodeInternalServerError     = "InternalServerError"
+	CodeInvalidParam            = "InvalidParam"
+	CodeProjectEnvNotFound      = "ProjectEnvNotFound"
+	CodeProjectUserCreateFailed = "ProjectUserCreateFailed"
+	CodeArrangeNotFound         = "ArrangeNotFound"
+	CodeArrangeRevisionNotFound = "ArrangeRevisionNotFound"
+	CodeArrangeRenderFailed     = "ArrangeRenderFailed"
+	CodeArrangeEnvRenderFailed  = "ArrangeEnvRenderFailed"
+	CodeArrangeYAMLInvalid      = "ArrangeYAMLInvalid"
+	CodeArrangeIncompatible     = "ArrangeIncompatible"
+	CodeTemplateAlreadyExists   = "TemplateAlreadyExists"
+	CodeTooManyRequests         = "TooManyRequests"
+	CodeLoginLocked             = "LoginLocked"
+)
+
+// Params the params of the catalog message, referenced as `{name}` in the message
+type Params map[string]interface{}
+
+type catalogEntry struct {
+	status   int
+	messages map[string]string
+}
+
+var catalog = map[string]catalogEntry{
+	CodeBadRequest: {http.StatusBadRequest, map[string]string{
+		LangZh: "请求参数错误",
+		LangEn: "bad request",
+	}},
+	CodeConflict: {http.StatusConflict, map[string]string{
+		LangZh: "资源冲突",
+		LangEn: "conflict",
+	}},
+	CodeUnauthorized: {http.StatusUnauthorized, map[string]string{
+		LangZh: "未登录或登录已过期",
+		LangEn: "unauthorized",
+	}},
+	CodeForbidden: {http.StatusForbidden, map[string]string{
+		LangZh: "没有权限执行此操作",
+		LangEn: "forbidden",
+	}},
+	CodeNotFound: {http.StatusNotFound, map[string]string{
+		LangZh: "资源不存在",
+		LangEn: "not found",
+	}},
+	CodeMethodNotAllowed: {http.StatusMethodNotAllowed, map[string]string{
+		LangZh: "不支持的请求方法",
+		LangEn: "method not allowed",
+	}},
+	CodeInternalServerError: {http.StatusInternalServerError, map[string]string{
+		LangZh: "服务器内部错误",
+		LangEn: "internal server error",
+	}},
+	CodeInvalidParam: {http.StatusBadRequest, map[string]string{
+		LangZh: "参数 {name} 无效",
+		LangEn: "invalid parameter {name}",
+	}},
+	CodeProjectEnvNotFound: {http.StatusNotFound, map[string]string{
+		LangZh: "项目环境 {env_id} 不存在",
+		LangEn: "project env {env_id} not found",
+	}},
+	CodeProjectUserCreateFailed: {http.StatusBadRequest, map[string]string{
+		LangZh: "创建项目用户 {user} 失败: {reason}",
+		LangEn: "create project user {user} failed: {reason}",
+	}},
+	CodeArrangeNotFound: {http.StatusNotFound, map[string]string{
+		LangZh: "应用编排不存在",
+		LangEn: "app arrange not found",
+	}},
+	CodeArrangeRevisionNotFound: {http.StatusNotFound, map[string]string{
+		LangZh: "应用编排版本 {revision} 不存在",
+		LangEn: "app arrange revision {revision} not found",
+	}},
+	CodeArrangeRenderFailed: {http.StatusBadRequest, map[string]string{
+		LangZh: "应用编排渲染失败: {reason}",
+		LangEn: "arrange render error: {reason}",
+	}},
+	CodeArrangeEnvRenderFailed: {http.StatusBadRequest, map[string]string{
+		LangZh: "[{env}] 应用编排渲染失败: {reason}",
+		LangEn: "[{env}] arrange render error: {reason}",
+	}},
+	CodeArrangeYAMLInvalid: {http.StatusBadRequest, map[string]string{
+		LangZh: "[{env}] 应用编排 yaml 格式错误: {reason}",
+		LangEn: "[{env}] yaml parse error: {reason}",
+	}},
+	CodeArrangeIncompatible: {http.StatusBadRequest, map[string]string{
+		LangZh: "[{env}] 编排与集群 {cluster} {version} 不兼容: {reason}",
+		LangEn: "[{env}] arrange is incompatible with cluster {cluster} {version}: {reason}",
+	}},
+	CodeTemplateAlreadyExists: {http.StatusConflict, map[string]string{
+		LangZh: "模板已存在",
+		LangEn: "template already exists",
+	}},
+	CodeTooManyRequests: {http.StatusTooManyRequests, map[string]string{
+		LangZh: "请求过于频繁，请 {retry_after} 秒后重试",
+		LangEn: "too many requests, retry after {retry_after} seconds",
+	}},
+	CodeLoginLocked: {http.StatusTooManyRequests, map[string]string{
+		LangZh: "登录失败次数过多，请 {retry_after} 秒后重试",
+		LangEn: "too many failed logins, retry after {retry_after} seconds",
+	}},
+}
+
+// New return the error of catalog code, the message is in english, use LocalizedMessage for the language of request
+func New(code string, params Params) *Error {
+	entry, ok := catalog[code]
+	if !ok {
+		return NewInternalServerError().SetCode(code)
+	}
+	return &Error{
+		status:  entry.status,
+		code:    code,
+		message: renderMessage(entry.messages[LangEn], params),
+		params:  params,
+	}
+}
+
+// NewInvalidParam the param of request path, query or body is invalid
+func NewInvalidParam(name string) *Error {
+	return New(CodeInvalidParam, Params{"name": name})
+}
+
+// NewTooManyRequests the request is rejected by the rate limits
+func NewTooManyRequests(retryAfter int) *Error {
+	return New(CodeTooManyRequests, Params{"retry_after": retryAfter})
+}
+
+// LocalizedMessage return the message in lang, the message set by SetMessage is returned as it is
+func (this *Error) LocalizedMessage(lang string) string {
+	if this.custom {
+		return this.message
+	}
+	entry, ok := catalog[this.code]
+	if !ok {
+		return this.message
+	}
+	message, ok := entry.messages[lang]
+	if !ok {
+		message = entry.messages[DefaultLang]
+	}
+	return renderMessage(message, this.params)
+}
+
+func renderMessage(message string, params Params) string {
+	for name, value := range params {
+		message = strings.Replace(message, "{"+name+"}", fmt.Sprint(value), -1)
+	}
+	return message
+}
+
+// ParseLang return the supported language of the highest quality in header `Accept-Language`,
+// eg: `en-US,en;q=0.9,zh-CN;q=0.8` is en
+func ParseLang(acceptLanguage string) string {
+	type candidate struct {
+		lang    string
+		quality float64
+	}
+	candidates := []candidate{}
+	for _, item := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		lang := strings.ToLower(strings.SplitN(parts[0], "-", 2)[0])
+		if lang != LangZh && lang != LangEn {
+			continue
+		}
+		quality := 1.0
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if strings.HasPrefix(part, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(part, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		candidates = append(candidates, candidate{lang: lang, quality: quality})
+	}
+	if len(candidates) == 0 {
+		return DefaultLang
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].lang
+}
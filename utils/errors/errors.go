@@ -31,6 +31,10 @@ type Error struct {
 	code    string
 	message string
 	cause   error
+	// params the params of catalog message
+	params Params
+	// custom the message was set explicitly, it is not localized
+	custom bool
 }
 
 func (this *Error) Error() string {
@@ -53,6 +57,10 @@ func (this *Error) Cause() error {
 	return this.cause
 }
 
+func (this *Error) Params() Params {
+	return this.params
+}
+
 func (this *Error) SetCode(code string) *Error {
 	this.code = code
 	return this
@@ -60,6 +68,12 @@ func (this *Error) SetCode(code string) *Error {
 
 func (this *Error) SetMessage(format string, args ...interface{}) *Error {
 	this.message = fmt.Sprintf(format, args...)
+	this.custom = true
+	return this
+}
+
+func (this *Error) SetParams(params Params) *Error {
+	this.params = params
 	return this
 }
 
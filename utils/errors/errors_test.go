@@ -68,4 +68,31 @@ func TestError(t *testing.T) {
 			assert.True(t, err.Status() >= 500 && err.Status() < 600, fmt.Sprintf(`should be 5XX: %#v`, err))
 		}
 	})
+	t.Run("Catalog", func(t *testing.T) {
+		err := NewInvalidParam("project_id")
+		assert.Equal(t, 400, err.Status())
+		assert.Equal(t, CodeInvalidParam, err.Code())
+		assert.Equal(t, "invalid parameter project_id", err.Message())
+		assert.Equal(t, "参数 project_id 无效", err.LocalizedMessage(LangZh))
+		assert.Equal(t, "invalid parameter project_id", err.LocalizedMessage(LangEn))
+		assert.Equal(t, "参数 project_id 无效", err.LocalizedMessage("fr"))
+
+		assert.Equal(t, "资源不存在", NewNotFound().LocalizedMessage(LangZh))
+		assert.Equal(t, "custom", NewNotFound().SetMessage("custom").LocalizedMessage(LangEn))
+		assert.Equal(t, "Unknown", New("Unknown", nil).Code())
+	})
+
+	t.Run("ParseLang", func(t *testing.T) {
+		tests := map[string]string{
+			"":                           DefaultLang,
+			"fr-FR":                      DefaultLang,
+			"en":                         LangEn,
+			"en-US,en;q=0.9,zh-CN;q=0.8": LangEn,
+			"zh-CN,zh;q=0.9,en;q=0.8":    LangZh,
+			"fr;q=1, en;q=0.5, zh;q=0.7": LangZh,
+		}
+		for header, want := range tests {
+			assert.Equal(t, want, ParseLang(header), header)
+		}
+	})
 }
@@ -0,0 +1,151 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCursorLimit the default page size of cursor pagination
+	DefaultCursorLimit = 20
+	// MaxCursorLimit the max page size of cursor pagination
+	MaxCursorLimit = 100
+
+	cursorPrefix = "id:"
+	// SortByID the items are sorted by id, which is the order of creation
+	SortByID = "id"
+)
+
+// CursorQuery cursor pagination query, items are ordered by id desc by default,
+// After is the id of the last item of the previous page, 0 means the first page
+type CursorQuery struct {
+	After int64
+	Limit int
+	// SortKey the time column sorted by before id, empty means sorted by id only
+	SortKey string
+	// AfterKey the SortKey value of the last item of the previous page
+	AfterKey time.Time
+	// Asc sorted in ascending order
+	Asc bool
+}
+
+// ParseSort parse the sort like -update_at, the leading - means descending, id desc by default.
+// create_at and id are the same order, the other sort keys must be the time columns in sortKeys
+func ParseSort(sort string, sortKeys ...string) (key string, asc bool, err error) {
+	asc = !strings.HasPrefix(sort, "-")
+	key = strings.TrimPrefix(sort, "-")
+	switch key {
+	case "":
+		return SortByID, false, nil
+	case SortByID, "create_at":
+		return SortByID, asc, nil
+	}
+	for _, item := range sortKeys {
+		if item == key {
+			return key, asc, nil
+		}
+	}
+	return "", false, fmt.Errorf("invalid sort: %v", sort)
+}
+
+// NewSortedCursorQuery parse the opaque cursor and the page size of the items sorted by the key
+func NewSortedCursorQuery(cursor string, limit int, key string, asc bool) (*CursorQuery, error) {
+	if key == SortByID {
+		query, err := NewCursorQuery(cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+		query.Asc = asc
+		return query, nil
+	}
+
+	query, err := NewCursorQuery("", limit)
+	if err != nil {
+		return nil, err
+	}
+	query.SortKey, query.Asc = key, asc
+	if cursor == "" {
+		return query, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	parts := strings.SplitN(string(raw), ";", 2)
+	if err != nil || len(parts) != 2 || !strings.HasPrefix(parts[0], key+":") {
+		return nil, fmt.Errorf("invalid cursor: %v", cursor)
+	}
+	sec, err := strconv.ParseInt(strings.TrimPrefix(parts[0], key+":"), 10, 64)
+	if err != nil || !strings.HasPrefix(parts[1], cursorPrefix) {
+		return nil, fmt.Errorf("invalid cursor: %v", cursor)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(parts[1], cursorPrefix), 10, 64)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("invalid cursor: %v", cursor)
+	}
+	query.After, query.AfterKey = id, time.Unix(sec, 0)
+	return query, nil
+}
+
+// NextCursor encode the cursor of the next page by the last item of current page
+func (c *CursorQuery) NextCursor(id int64, key time.Time) string {
+	if c.SortKey == "" {
+		return EncodeCursor(id)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d;%s%d", c.SortKey, key.Unix(), cursorPrefix, id)))
+}
+
+// NewCursorQuery parse the opaque cursor and the page size
+func NewCursorQuery(cursor string, limit int) (*CursorQuery, error) {
+	if limit <= 0 {
+		limit = DefaultCursorLimit
+	}
+	if limit > MaxCursorLimit {
+		limit = MaxCursorLimit
+	}
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &CursorQuery{After: after, Limit: limit}, nil
+}
+
+// EncodeCursor encode the id of the last item into an opaque cursor
+func EncodeCursor(id int64) string {
+	if id <= 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor decode the opaque cursor, the empty cursor means the first page
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor: %v", cursor)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid cursor: %v", cursor)
+	}
+	return id, nil
+}
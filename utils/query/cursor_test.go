@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The AtomCI Group Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		sort string
+		key  string
+		asc  bool
+		err  bool
+	}{
+		{"", SortByID, false, false},
+		{"id", SortByID, true, false},
+		{"-create_at", SortByID, false, false},
+		{"-update_at", "update_at", false, false},
+		{"update_at", "update_at", true, false},
+		{"name", "", false, true},
+	}
+	for _, c := range cases {
+		key, asc, err := ParseSort(c.sort, "update_at")
+		if (err != nil) != c.err || key != c.key || asc != c.asc {
+			t.Errorf("ParseSort(%q) = %v, %v, %v", c.sort, key, asc, err)
+		}
+	}
+}
+
+func TestSortedCursorRoundTrip(t *testing.T) {
+	first, err := NewSortedCursorQuery("", 10, "update_at", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateAt := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+	next, err := NewSortedCursorQuery(first.NextCursor(42, updateAt), 10, "update_at", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.After != 42 || !next.AfterKey.Equal(updateAt) || next.SortKey != "update_at" {
+		t.Errorf("unexpected cursor: %+v", next)
+	}
+
+	// the cursor of other sort is rejected
+	if _, err := NewSortedCursorQuery(EncodeCursor(42), 10, "update_at", false); err == nil {
+		t.Error("expected invalid cursor")
+	}
+	byID, err := NewSortedCursorQuery(EncodeCursor(42), 10, SortByID, true)
+	if err != nil || byID.After != 42 || !byID.Asc || byID.SortKey != "" {
+		t.Errorf("unexpected cursor: %+v, %v", byID, err)
+	}
+}
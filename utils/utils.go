@@ -79,6 +79,18 @@ func IntContains(s []int64, e int64) bool {
 	return false
 }
 
+// Truncate return the first n characters of s, the multi-byte characters are not cut off
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
 // EnsureAbs prepends the WorkDir to the given path if it is not an absolute path.
 func EnsureAbs(path string) string {
 	if filepath.IsAbs(path) {
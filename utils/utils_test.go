@@ -12,3 +12,11 @@ func TestAesCrypto(t *testing.T) {
 	log.Printf("%s", encrypted)
 	assert.NotEmpty(t, encrypted)
 }
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "hello", Truncate("hello", 5))
+	assert.Equal(t, "hel", Truncate("hello", 3))
+	assert.Equal(t, "部署失", Truncate("部署失败", 3))
+	assert.Equal(t, "部署失败", Truncate("部署失败", 4))
+	assert.Equal(t, "a部", Truncate("a部署", 2))
+}